@@ -3,7 +3,6 @@ package activitypub
 import (
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"net/url"
 	"strings"
@@ -14,7 +13,7 @@ import (
 )
 
 // defaultHTTPClient is the default HTTP client for production use
-var defaultHTTPClient HTTPClient = NewDefaultHTTPClient(10 * time.Second)
+var defaultHTTPClient HTTPClient = NewDefaultHTTPClient(defaultHTTPTimeout)
 
 // ActorResponse represents the JSON structure of an ActivityPub actor
 type ActorResponse struct {
@@ -26,16 +25,98 @@ type ActorResponse struct {
 	Summary           string `json:"summary"`
 	Inbox             string `json:"inbox"`
 	Outbox            string `json:"outbox"`
-	Icon              struct {
+	Endpoints         struct {
+		SharedInbox string `json:"sharedInbox"`
+	} `json:"endpoints"`
+	Icon struct {
 		Type      string `json:"type"`
 		MediaType string `json:"mediaType"`
 		URL       string `json:"url"`
 	} `json:"icon"`
-	PublicKey struct {
-		ID           string `json:"id"`
-		Owner        string `json:"owner"`
-		PublicKeyPem string `json:"publicKeyPem"`
-	} `json:"publicKey"`
+	PublicKey       actorPublicKeys `json:"publicKey"`
+	AssertionMethod json.RawMessage `json:"assertionMethod"` // Multikeys (object or array)
+}
+
+// actorPublicKey is a key from an actor's publicKey
+type actorPublicKey struct {
+	ID           string `json:"id"`
+	Owner        string `json:"owner"`
+	PublicKeyPem string `json:"publicKeyPem"`
+}
+
+// actorPublicKeys is an actor's publicKey, which some servers publish as an array of keys.
+// The embedded key is the actor's main key, the first one with a PEM.
+type actorPublicKeys struct {
+	actorPublicKey
+	Keys []actorPublicKey `json:"-"` // All published keys
+}
+
+func (k *actorPublicKeys) UnmarshalJSON(data []byte) error {
+	var keys []actorPublicKey
+	if err := json.Unmarshal(data, &keys); err != nil {
+		var key actorPublicKey
+		if err := json.Unmarshal(data, &key); err != nil {
+			return err
+		}
+		keys = []actorPublicKey{key}
+	}
+
+	*k = actorPublicKeys{Keys: keys}
+	for _, key := range keys {
+		if key.PublicKeyPem != "" {
+			k.actorPublicKey = key
+			break
+		}
+	}
+	return nil
+}
+
+// publicKeysByID returns the keys of an actor that publishes several, by key id, or nil for
+// an actor with a single key
+func (a *ActorResponse) publicKeysByID() map[string]string {
+	if len(a.PublicKey.Keys) < 2 {
+		return nil
+	}
+	keys := make(map[string]string, len(a.PublicKey.Keys))
+	for _, key := range a.PublicKey.Keys {
+		if key.ID != "" && key.PublicKeyPem != "" {
+			keys[key.ID] = key.PublicKeyPem
+		}
+	}
+	return keys
+}
+
+// actorMultikey is a Multikey verification method from an actor's assertionMethod
+type actorMultikey struct {
+	ID                 string `json:"id"`
+	Type               string `json:"type"`
+	PublicKeyMultibase string `json:"publicKeyMultibase"`
+}
+
+// signingKey returns the key used to verify the actor's signatures: the publicKeyPem, or for
+// actors that only publish an Ed25519 Multikey, its publicKeyMultibase
+func (a *ActorResponse) signingKey() string {
+	if a.PublicKey.PublicKeyPem != "" {
+		return a.PublicKey.PublicKeyPem
+	}
+
+	// assertionMethod may be a single entry or an array mixing objects and bare id strings
+	entries := []json.RawMessage{a.AssertionMethod}
+	var list []json.RawMessage
+	if err := json.Unmarshal(a.AssertionMethod, &list); err == nil {
+		entries = list
+	}
+	for _, entry := range entries {
+		var method actorMultikey
+		if err := json.Unmarshal(entry, &method); err != nil {
+			continue
+		}
+		// "z6Mk" is the multibase prefix of an Ed25519 public key
+		if method.Type == "Multikey" && strings.HasPrefix(method.PublicKeyMultibase, "z6Mk") {
+			return method.PublicKeyMultibase
+		}
+	}
+	return ""
 }
 
 // FetchRemoteActor fetches an actor from a remote server and stores in cache.
@@ -47,14 +128,13 @@ func FetchRemoteActor(actorURI string) (*domain.RemoteAccount, error) {
 // FetchRemoteActorWithDeps fetches an actor from a remote server and stores in cache.
 // This version accepts dependencies for testing.
 func FetchRemoteActorWithDeps(actorURI string, client HTTPClient, database Database) (*domain.RemoteAccount, error) {
-	// Create HTTP request with Accept: application/activity+json
+	// Create HTTP request asking for either ActivityStreams content type
 	req, err := http.NewRequest("GET", actorURI, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Accept", "application/activity+json")
-	req.Header.Set("User-Agent", "stegodon/1.0 ActivityPub")
+	SetRequestHeaders(req, ActivityAcceptHeader)
 
 	resp, err := client.Do(req)
 	if err != nil {
@@ -67,9 +147,9 @@ func FetchRemoteActorWithDeps(actorURI string, client HTTPClient, database Datab
 	}
 
 	// Parse ActivityPub actor JSON
-	body, err := io.ReadAll(resp.Body)
+	body, err := ReadJSONResponse(resp)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, err
 	}
 
 	var actor ActorResponse
@@ -78,7 +158,8 @@ func FetchRemoteActorWithDeps(actorURI string, client HTTPClient, database Datab
 	}
 
 	// Validate required fields
-	if actor.ID == "" || actor.Inbox == "" || actor.PublicKey.PublicKeyPem == "" {
+	publicKey := actor.signingKey()
+	if actor.ID == "" || actor.Inbox == "" || publicKey == "" {
 		return nil, fmt.Errorf("actor missing required fields")
 	}
 
@@ -89,23 +170,25 @@ func FetchRemoteActorWithDeps(actorURI string, client HTTPClient, database Datab
 	}
 
 	// Check if remote account already exists
-	err, existingAcc := database.ReadRemoteAccountByURI(actor.ID)
+	existingAcc, err := database.ReadRemoteAccountByURI(actor.ID)
 
 	var remoteAcc *domain.RemoteAccount
 	if err == nil && existingAcc != nil {
 		// Account exists - reuse the ID and update
 		remoteAcc = &domain.RemoteAccount{
-			Id:            existingAcc.Id, // Reuse existing ID
-			Username:      actor.PreferredUsername,
-			Domain:        domainName,
-			ActorURI:      actor.ID,
-			DisplayName:   actor.Name,
-			Summary:       actor.Summary,
-			InboxURI:      actor.Inbox,
-			OutboxURI:     actor.Outbox,
-			PublicKeyPem:  actor.PublicKey.PublicKeyPem,
-			AvatarURL:     actor.Icon.URL,
-			LastFetchedAt: time.Now(),
+			Id:             existingAcc.Id, // Reuse existing ID
+			Username:       actor.PreferredUsername,
+			Domain:         domainName,
+			ActorURI:       actor.ID,
+			DisplayName:    actor.Name,
+			Summary:        actor.Summary,
+			InboxURI:       actor.Inbox,
+			SharedInboxURI: actor.Endpoints.SharedInbox,
+			OutboxURI:      actor.Outbox,
+			PublicKeyPem:   publicKey,
+			PublicKeys:     actor.publicKeysByID(),
+			AvatarURL:      actor.Icon.URL,
+			LastFetchedAt:  time.Now(),
 		}
 		err = database.UpdateRemoteAccount(remoteAcc)
 		if err != nil {
@@ -114,17 +197,19 @@ func FetchRemoteActorWithDeps(actorURI string, client HTTPClient, database Datab
 	} else {
 		// Account doesn't exist - create new
 		remoteAcc = &domain.RemoteAccount{
-			Id:            uuid.New(),
-			Username:      actor.PreferredUsername,
-			Domain:        domainName,
-			ActorURI:      actor.ID,
-			DisplayName:   actor.Name,
-			Summary:       actor.Summary,
-			InboxURI:      actor.Inbox,
-			OutboxURI:     actor.Outbox,
-			PublicKeyPem:  actor.PublicKey.PublicKeyPem,
-			AvatarURL:     actor.Icon.URL,
-			LastFetchedAt: time.Now(),
+			Id:             uuid.New(),
+			Username:       actor.PreferredUsername,
+			Domain:         domainName,
+			ActorURI:       actor.ID,
+			DisplayName:    actor.Name,
+			Summary:        actor.Summary,
+			InboxURI:       actor.Inbox,
+			SharedInboxURI: actor.Endpoints.SharedInbox,
+			OutboxURI:      actor.Outbox,
+			PublicKeyPem:   publicKey,
+			PublicKeys:     actor.publicKeysByID(),
+			AvatarURL:      actor.Icon.URL,
+			LastFetchedAt:  time.Now(),
 		}
 		err = database.CreateRemoteAccount(remoteAcc)
 		if err != nil {
@@ -145,7 +230,7 @@ func GetOrFetchActor(actorURI string) (*domain.RemoteAccount, error) {
 // This version accepts dependencies for testing.
 func GetOrFetchActorWithDeps(actorURI string, client HTTPClient, database Database) (*domain.RemoteAccount, error) {
 	// Check cache first
-	err, cached := database.ReadRemoteAccountByURI(actorURI)
+	cached, err := database.ReadRemoteAccountByURI(actorURI)
 	if err == nil && cached != nil {
 		// Check if cache is fresh (< 24 hours)
 		if time.Since(cached.LastFetchedAt) < 24*time.Hour {
@@ -1,6 +1,7 @@
 package activitypub
 
 import (
+	"crypto/ed25519"
 	"encoding/json"
 	"strings"
 	"testing"
@@ -111,12 +112,10 @@ func TestActorResponseValidation(t *testing.T) {
 			actor: ActorResponse{
 				ID:    "https://example.com/users/alice",
 				Inbox: "https://example.com/users/alice/inbox",
-				PublicKey: struct {
-					ID           string `json:"id"`
-					Owner        string `json:"owner"`
-					PublicKeyPem string `json:"publicKeyPem"`
-				}{
-					PublicKeyPem: "-----BEGIN PUBLIC KEY-----\ntest\n-----END PUBLIC KEY-----",
+				PublicKey: actorPublicKeys{
+					actorPublicKey: actorPublicKey{
+						PublicKeyPem: "-----BEGIN PUBLIC KEY-----\ntest\n-----END PUBLIC KEY-----",
+					},
 				},
 			},
 			wantValid: true,
@@ -125,12 +124,10 @@ func TestActorResponseValidation(t *testing.T) {
 			name: "missing ID",
 			actor: ActorResponse{
 				Inbox: "https://example.com/inbox",
-				PublicKey: struct {
-					ID           string `json:"id"`
-					Owner        string `json:"owner"`
-					PublicKeyPem string `json:"publicKeyPem"`
-				}{
-					PublicKeyPem: "-----BEGIN PUBLIC KEY-----\ntest\n-----END PUBLIC KEY-----",
+				PublicKey: actorPublicKeys{
+					actorPublicKey: actorPublicKey{
+						PublicKeyPem: "-----BEGIN PUBLIC KEY-----\ntest\n-----END PUBLIC KEY-----",
+					},
 				},
 			},
 			wantValid: false,
@@ -139,12 +136,10 @@ func TestActorResponseValidation(t *testing.T) {
 			name: "missing Inbox",
 			actor: ActorResponse{
 				ID: "https://example.com/users/alice",
-				PublicKey: struct {
-					ID           string `json:"id"`
-					Owner        string `json:"owner"`
-					PublicKeyPem string `json:"publicKeyPem"`
-				}{
-					PublicKeyPem: "-----BEGIN PUBLIC KEY-----\ntest\n-----END PUBLIC KEY-----",
+				PublicKey: actorPublicKeys{
+					actorPublicKey: actorPublicKey{
+						PublicKeyPem: "-----BEGIN PUBLIC KEY-----\ntest\n-----END PUBLIC KEY-----",
+					},
 				},
 			},
 			wantValid: false,
@@ -906,12 +901,10 @@ func TestActorValidationRequired(t *testing.T) {
 			actor: ActorResponse{
 				ID:    "https://example.com/users/alice",
 				Inbox: "https://example.com/inbox",
-				PublicKey: struct {
-					ID           string `json:"id"`
-					Owner        string `json:"owner"`
-					PublicKeyPem string `json:"publicKeyPem"`
-				}{
-					PublicKeyPem: "-----BEGIN PUBLIC KEY-----\ntest\n-----END PUBLIC KEY-----",
+				PublicKey: actorPublicKeys{
+					actorPublicKey: actorPublicKey{
+						PublicKeyPem: "-----BEGIN PUBLIC KEY-----\ntest\n-----END PUBLIC KEY-----",
+					},
 				},
 			},
 			missingMsg: "",
@@ -920,12 +913,10 @@ func TestActorValidationRequired(t *testing.T) {
 			name: "missing ID",
 			actor: ActorResponse{
 				Inbox: "https://example.com/inbox",
-				PublicKey: struct {
-					ID           string `json:"id"`
-					Owner        string `json:"owner"`
-					PublicKeyPem string `json:"publicKeyPem"`
-				}{
-					PublicKeyPem: "-----BEGIN PUBLIC KEY-----\ntest\n-----END PUBLIC KEY-----",
+				PublicKey: actorPublicKeys{
+					actorPublicKey: actorPublicKey{
+						PublicKeyPem: "-----BEGIN PUBLIC KEY-----\ntest\n-----END PUBLIC KEY-----",
+					},
 				},
 			},
 			missingMsg: "ID",
@@ -934,12 +925,10 @@ func TestActorValidationRequired(t *testing.T) {
 			name: "missing Inbox",
 			actor: ActorResponse{
 				ID: "https://example.com/users/alice",
-				PublicKey: struct {
-					ID           string `json:"id"`
-					Owner        string `json:"owner"`
-					PublicKeyPem string `json:"publicKeyPem"`
-				}{
-					PublicKeyPem: "-----BEGIN PUBLIC KEY-----\ntest\n-----END PUBLIC KEY-----",
+				PublicKey: actorPublicKeys{
+					actorPublicKey: actorPublicKey{
+						PublicKeyPem: "-----BEGIN PUBLIC KEY-----\ntest\n-----END PUBLIC KEY-----",
+					},
 				},
 			},
 			missingMsg: "Inbox",
@@ -1205,7 +1194,7 @@ func TestFetchRemoteActorWithDeps_NewActor(t *testing.T) {
 	}
 
 	// Verify actor was stored in mock database
-	err, storedActor := mockDB.ReadRemoteAccountByURI(actorURI)
+	storedActor, err := mockDB.ReadRemoteAccountByURI(actorURI)
 	if err != nil || storedActor == nil {
 		t.Error("Actor should be stored in database")
 	}
@@ -1214,8 +1203,118 @@ func TestFetchRemoteActorWithDeps_NewActor(t *testing.T) {
 	if len(mockHTTP.Requests) != 1 {
 		t.Errorf("Expected 1 HTTP request, got %d", len(mockHTTP.Requests))
 	}
-	if mockHTTP.Requests[0].Header.Get("Accept") != "application/activity+json" {
-		t.Error("Request should have Accept: application/activity+json header")
+	if accept := mockHTTP.Requests[0].Header.Get("Accept"); accept != ActivityAcceptHeader {
+		t.Errorf("Request should accept both ActivityStreams content types, got %q", accept)
+	}
+}
+
+// TestFetchRemoteActorWithDeps_SharedInbox tests that endpoints.sharedInbox is stored for delivery
+func TestFetchRemoteActorWithDeps_SharedInbox(t *testing.T) {
+	mockDB := NewMockDatabase()
+	mockHTTP := NewMockHTTPClient()
+
+	actorURI := "https://remote.example.com/users/shared"
+	err := mockHTTP.SetJSONResponse(actorURI, 200, map[string]any{
+		"id":                actorURI,
+		"type":              "Person",
+		"preferredUsername": "shared",
+		"inbox":             actorURI + "/inbox",
+		"endpoints":         map[string]any{"sharedInbox": "https://remote.example.com/inbox"},
+		"publicKey":         map[string]any{"publicKeyPem": "key"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to set mock response: %v", err)
+	}
+
+	result, err := FetchRemoteActorWithDeps(actorURI, mockHTTP, mockDB)
+	if err != nil {
+		t.Fatalf("FetchRemoteActorWithDeps failed: %v", err)
+	}
+	if result.SharedInboxURI != "https://remote.example.com/inbox" {
+		t.Errorf("Expected SharedInboxURI from endpoints, got '%s'", result.SharedInboxURI)
+	}
+	if deliveryInbox(result) != "https://remote.example.com/inbox" {
+		t.Errorf("Expected delivery to prefer the shared inbox, got '%s'", deliveryInbox(result))
+	}
+
+	// Without a shared inbox the personal inbox is used
+	result.SharedInboxURI = ""
+	if deliveryInbox(result) != actorURI+"/inbox" {
+		t.Errorf("Expected fallback to personal inbox, got '%s'", deliveryInbox(result))
+	}
+}
+
+// TestFetchRemoteActorWithDeps_Ed25519Multikey tests actors that only publish an Ed25519 Multikey
+func TestFetchRemoteActorWithDeps_Ed25519Multikey(t *testing.T) {
+	multikey := ed25519Multikey(testEd25519Key.Public().(ed25519.PublicKey))
+
+	for name, assertionMethod := range map[string]any{
+		"single": map[string]any{"id": "#ed25519-key", "type": "Multikey", "publicKeyMultibase": multikey},
+		"array": []any{
+			"https://remote.example.com/users/ed#other",
+			map[string]any{"id": "#ed25519-key", "type": "Multikey", "publicKeyMultibase": multikey},
+		},
+	} {
+		mockDB := NewMockDatabase()
+		mockHTTP := NewMockHTTPClient()
+		actorURI := "https://remote.example.com/users/ed"
+		mockHTTP.SetJSONResponse(actorURI, 200, map[string]any{
+			"id":                actorURI,
+			"type":              "Person",
+			"preferredUsername": "ed",
+			"inbox":             actorURI + "/inbox",
+			"assertionMethod":   assertionMethod,
+		})
+
+		result, err := FetchRemoteActorWithDeps(actorURI, mockHTTP, mockDB)
+		if err != nil {
+			t.Fatalf("%s: FetchRemoteActorWithDeps failed: %v", name, err)
+		}
+		if result.PublicKeyPem != multikey {
+			t.Errorf("%s: expected multikey to be stored as the actor key, got %q", name, result.PublicKeyPem)
+		}
+	}
+}
+
+// TestFetchRemoteActorWithDeps_MultipleKeys tests actors that publish publicKey as an array
+func TestFetchRemoteActorWithDeps_MultipleKeys(t *testing.T) {
+	mainKey, _ := GenerateTestKeyPair()
+	otherKey, _ := GenerateTestKeyPair()
+
+	mockDB := NewMockDatabase()
+	mockHTTP := NewMockHTTPClient()
+	actorURI := "https://remote.example.com/users/keys"
+	mockHTTP.SetJSONResponse(actorURI, 200, map[string]any{
+		"id":                actorURI,
+		"type":              "Person",
+		"preferredUsername": "keys",
+		"inbox":             actorURI + "/inbox",
+		"publicKey": []any{
+			map[string]any{"id": actorURI + "#main-key", "owner": actorURI, "publicKeyPem": mainKey.PublicPEM},
+			map[string]any{"id": actorURI + "#other-key", "owner": actorURI, "publicKeyPem": otherKey.PublicPEM},
+		},
+	})
+
+	result, err := FetchRemoteActorWithDeps(actorURI, mockHTTP, mockDB)
+	if err != nil {
+		t.Fatalf("FetchRemoteActorWithDeps failed: %v", err)
+	}
+	if result.PublicKeyPem != mainKey.PublicPEM {
+		t.Error("Expected the first key to be stored as the actor key")
+	}
+	if len(result.PublicKeys) != 2 || result.PublicKeys[actorURI+"#other-key"] != otherKey.PublicPEM {
+		t.Errorf("Expected both keys to be stored by id, got %v", result.PublicKeys)
+	}
+
+	// Signatures are verified with the key their keyId names
+	signedHeaders := []string{"(request-target)", "host", "date"}
+	req := createSignedFetch(t, otherKey, actorURI+"#other-key", time.Now(), signedHeaders)
+	if signer, err := VerifyFetchSignatureWithDeps(req, mockHTTP, mockDB); err != nil || signer != actorURI {
+		t.Errorf("Expected a signature with the second key to verify, got %q, %v", signer, err)
+	}
+	req = createSignedFetch(t, otherKey, actorURI+"#main-key", time.Now(), signedHeaders)
+	if _, err := VerifyFetchSignatureWithDeps(req, mockHTTP, mockDB); err == nil {
+		t.Error("Expected a signature naming the main key but made with the second key to be rejected")
 	}
 }
 
@@ -1301,6 +1400,7 @@ func TestFetchRemoteActorWithDeps_InvalidJSON(t *testing.T) {
 
 	actorURI := "https://remote.example.com/users/invalid"
 	mockHTTP.SetResponse(actorURI, 200, []byte("{invalid json"))
+	mockHTTP.Responses[actorURI].Header.Set("Content-Type", "application/activity+json")
 
 	_, err := FetchRemoteActorWithDeps(actorURI, mockHTTP, mockDB)
 	if err == nil {
@@ -1497,7 +1597,7 @@ func TestGetOrFetchActorWithDeps_NotCached(t *testing.T) {
 	}
 
 	// Verify actor was stored
-	err, stored := mockDB.ReadRemoteAccountByURI(actorURI)
+	stored, err := mockDB.ReadRemoteAccountByURI(actorURI)
 	if err != nil || stored == nil {
 		t.Error("Actor should be stored in database")
 	}
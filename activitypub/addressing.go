@@ -0,0 +1,204 @@
+package activitypub
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+
+	"github.com/deemkeen/stegodon/domain"
+)
+
+// inboxAddressing are the fields of a delivered activity that say whom it is for
+type inboxAddressing struct {
+	Type     string `json:"type"`
+	Actor    string `json:"actor"`
+	Object   any    `json:"object"`
+	To       any    `json:"to"`
+	Cc       any    `json:"cc"`
+	Bto      any    `json:"bto"`
+	Bcc      any    `json:"bcc"`
+	Audience any    `json:"audience"`
+}
+
+// isAddressedToInbox reports whether an activity delivered to the inbox of a local account is
+// meant for it, so an activity for one account can't be injected into another one's inbox.
+// A Follow's object must be the account. Other activities must name the account, the public
+// collection, or a collection of their actor among their recipients; a followers collection
+// only counts if the account follows the actor. Activities without addressing, like most
+// Accepts, Likes and Undos, must be about the account: their object, or what it refers to,
+// is the account or one of its posts.
+func isAddressedToInbox(body []byte, username, localDomain string, database Database) bool {
+	var activity inboxAddressing
+	if err := json.Unmarshal(body, &activity); err != nil {
+		return false
+	}
+
+	localActorURI := "https://" + localDomain + "/users/" + username
+	if activity.Type == "Follow" {
+		return objectID(activity.Object) == localActorURI
+	}
+
+	audiences := []any{activity.To, activity.Cc, activity.Bto, activity.Bcc, activity.Audience}
+	// Posts may only be addressed on the object
+	if object, ok := activity.Object.(map[string]any); ok {
+		audiences = append(audiences, object["to"], object["cc"], object["bto"], object["bcc"], object["audience"])
+	}
+	recipients := recipientURIs(audiences...)
+	if len(recipients) == 0 {
+		return concernsAccount(activity.Object, localActorURI, username, database, 3)
+	}
+	for _, recipient := range recipients {
+		if admitsInbox(recipient, activity.Actor, username, localDomain, database) {
+			return true
+		}
+	}
+	return false
+}
+
+// admitsInbox reports whether a recipient of an activity by actorURI includes the given local account
+func admitsInbox(recipient, actorURI, username, localDomain string, database Database) bool {
+	if isPublicCollection(recipient) {
+		return true
+	}
+
+	// Our own actors and their collections only admit the account they belong to
+	u, err := url.Parse(recipient)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(u.Host, localDomain) {
+		owner, _, _ := strings.Cut(strings.TrimPrefix(u.Path, "/users/"), "/")
+		return strings.HasPrefix(u.Path, "/users/") && owner == username
+	}
+
+	// The actor's followers only include the account if it follows the actor; the followers of
+	// anyone else are not the actor's to address
+	if strings.HasSuffix(recipient, "/followers") {
+		return actorURI != "" && recipient == actorURI+"/followers" && followsActor(username, actorURI, database)
+	}
+
+	// Other collections must be the actor's own; its server's actors are someone else
+	actor, err := url.Parse(actorURI)
+	if err != nil || actor.Host == "" || !strings.EqualFold(u.Host, actor.Host) || recipient == actorURI {
+		return false
+	}
+	remote, err := database.ReadRemoteAccountByActorURI(recipient)
+	return err != nil || remote == nil
+}
+
+// followsActor reports whether a local account has an accepted follow of a remote actor
+func followsActor(username, actorURI string, database Database) bool {
+	account, err := database.ReadAccByUsername(username)
+	if err != nil || account == nil {
+		return false
+	}
+	remote, err := database.ReadRemoteAccountByActorURI(actorURI)
+	if err != nil || remote == nil {
+		return false
+	}
+	follow, err := database.ReadFollowByAccountIds(account.Id, remote.Id)
+	return err == nil && follow != nil && follow.Accepted
+}
+
+// concernsAccount reports whether an object, or the object or actor it refers to down to the
+// given depth, is the local account, one of its posts or one of its follows, e.g. the Follow
+// an Accept accepts or the post an undone Like was for
+func concernsAccount(object any, localActorURI, username string, database Database, depth int) bool {
+	if depth == 0 {
+		return false
+	}
+	if uri := objectID(object); uri != "" {
+		if uri == localActorURI {
+			return true
+		}
+		if note, err := database.ReadNoteByURI(uri); err == nil && note != nil && note.CreatedBy == username {
+			return true
+		}
+		if follow, err := database.ReadFollowByURI(uri); err == nil && follow != nil {
+			if account, err := database.ReadAccByUsername(username); err == nil && account != nil &&
+				(follow.AccountId == account.Id || follow.TargetAccountId == account.Id) {
+				return true
+			}
+		}
+	}
+	o, ok := object.(map[string]any)
+	if !ok {
+		return false
+	}
+	if actor, _ := o["actor"].(string); actor == localActorURI {
+		return true
+	}
+	return concernsAccount(o["object"], localActorURI, username, database, depth-1)
+}
+
+// isPublicCollection reports whether a recipient is the public collection, in any of its spellings
+func isPublicCollection(recipient string) bool {
+	return recipient == "https://www.w3.org/ns/activitystreams#Public" || recipient == "as:Public" || recipient == "Public"
+}
+
+// objectVisibility derives the visibility of a remote post from its addressing: public posts
+// are to the public collection, unlisted ones cc it, followers-only posts name a followers
+// collection and direct messages only actors. Posts without any addressing count as public.
+func objectVisibility(object map[string]any) string {
+	to := recipientURIs(object["to"])
+	cc := recipientURIs(object["cc"], object["audience"])
+	if len(to) == 0 && len(cc) == 0 {
+		return domain.VisibilityPublic
+	}
+	for _, recipient := range to {
+		if isPublicCollection(recipient) {
+			return domain.VisibilityPublic
+		}
+	}
+	for _, recipient := range cc {
+		if isPublicCollection(recipient) {
+			return domain.VisibilityUnlisted
+		}
+	}
+	for _, recipient := range append(to, cc...) {
+		if strings.HasSuffix(recipient, "/followers") {
+			return domain.VisibilityFollowers
+		}
+	}
+	return domain.VisibilityDirect
+}
+
+// isRestrictedObject reports whether a fetched post is followers-only or direct while no local
+// account follows its author, so it may be stored for context but not shown in timelines
+func isRestrictedObject(object map[string]any, actorURI string, database Database) bool {
+	switch objectVisibility(object) {
+	case domain.VisibilityFollowers, domain.VisibilityDirect:
+		return !isFollowedAuthor(actorURI, database)
+	}
+	return false
+}
+
+// recipientURIs returns the recipients of to, cc, bto, bcc and audience fields, each a URI,
+// an object with an id or an array of either
+func recipientURIs(audiences ...any) []string {
+	var uris []string
+	for _, audience := range audiences {
+		items, ok := audience.([]any)
+		if !ok {
+			items = []any{audience}
+		}
+		for _, item := range items {
+			if uri := objectID(item); uri != "" {
+				uris = append(uris, uri)
+			}
+		}
+	}
+	return uris
+}
+
+// objectID returns the URI of an object given as a URI or as an object with an id
+func objectID(object any) string {
+	switch o := object.(type) {
+	case string:
+		return o
+	case map[string]any:
+		id, _ := o["id"].(string)
+		return id
+	}
+	return ""
+}
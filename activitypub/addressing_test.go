@@ -0,0 +1,134 @@
+package activitypub
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/deemkeen/stegodon/domain"
+	"github.com/google/uuid"
+)
+
+func TestIsAddressedToInbox(t *testing.T) {
+	mockDB := NewMockDatabase()
+	alice := &domain.Account{Id: uuid.New(), Username: "alice"}
+	dave := &domain.RemoteAccount{Id: uuid.New(), ActorURI: "https://remote.example.com/users/dave"}
+	mockDB.AddAccount(alice)
+	mockDB.AddRemoteAccount(&domain.RemoteAccount{Id: uuid.New(), ActorURI: "https://remote.example.com/users/carol"})
+	mockDB.AddRemoteAccount(dave)
+	mockDB.AddRemoteAccount(&domain.RemoteAccount{Id: uuid.New(), ActorURI: "https://remote.example.com/users/erin"})
+	mockDB.AddFollow(&domain.Follow{Id: uuid.New(), AccountId: alice.Id, TargetAccountId: dave.Id, Accepted: true})
+	mockDB.AddFollow(&domain.Follow{Id: uuid.New(), AccountId: alice.Id, TargetAccountId: uuid.New(), URI: "https://local.example.com/activities/follow-1"})
+	mockDB.NotesByURI["https://local.example.com/notes/1"] = &domain.Note{Id: uuid.New(), CreatedBy: "alice"}
+	mockDB.NotesByURI["https://local.example.com/notes/2"] = &domain.Note{Id: uuid.New(), CreatedBy: "bob"}
+
+	tests := []struct {
+		name string
+		body string
+		want bool
+	}{
+		{"follow of the account", `{"type": "Follow", "object": "https://local.example.com/users/alice"}`, true},
+		{"follow of another account", `{"type": "Follow", "object": "https://local.example.com/users/bob"}`, false},
+		{"follow without object", `{"type": "Follow"}`, false},
+		{"public post", `{"type": "Create", "to": ["https://www.w3.org/ns/activitystreams#Public"], "cc": ["https://remote.example.com/users/dave/followers"]}`, true},
+		{"followers only of a followed actor", `{"type": "Create", "actor": "https://remote.example.com/users/dave", "to": "https://remote.example.com/users/dave/followers"}`, true},
+		{"followers only of an actor not followed", `{"type": "Create", "actor": "https://remote.example.com/users/erin", "to": "https://remote.example.com/users/erin/followers"}`, false},
+		{"another actor's followers", `{"type": "Create", "actor": "https://remote.example.com/users/erin", "to": "https://remote.example.com/users/dave/followers"}`, false},
+		{"collection on the actor's server", `{"type": "Create", "actor": "https://remote.example.com/users/dave", "to": "https://remote.example.com/lists/1"}`, true},
+		{"unknown URI on another server", `{"type": "Create", "actor": "https://remote.example.com/users/dave", "to": ["https://attacker.example/anything"]}`, false},
+		{"uncached actor on another server", `{"type": "Create", "actor": "https://remote.example.com/users/dave", "to": ["https://other.example.com/users/frank"]}`, false},
+		{"mention of the account", `{"type": "Create", "to": ["https://local.example.com/users/alice"]}`, true},
+		{"addressed on the object only", `{"type": "Create", "object": {"type": "Note", "to": ["https://local.example.com/users/alice"]}}`, true},
+		{"direct message to another account", `{"type": "Create", "to": ["https://local.example.com/users/bob"]}`, false},
+		{"another account's followers", `{"type": "Create", "to": ["https://local.example.com/users/bob/followers"]}`, false},
+		{"direct message to a remote actor", `{"type": "Create", "to": ["https://remote.example.com/users/carol"]}`, false},
+		{"bcc to the account", `{"type": "Create", "to": ["https://local.example.com/users/bob"], "bcc": ["https://local.example.com/users/alice"]}`, true},
+		{"accept of the account's follow", `{"type": "Accept", "object": {"type": "Follow", "actor": "https://local.example.com/users/alice"}}`, true},
+		{"accept of the account's follow by id", `{"type": "Accept", "object": "https://local.example.com/activities/follow-1"}`, true},
+		{"undo of a follow of the account", `{"type": "Undo", "object": {"type": "Follow", "object": "https://local.example.com/users/alice"}}`, true},
+		{"like of the account's post", `{"type": "Like", "object": "https://local.example.com/notes/1"}`, true},
+		{"undo of a like of another account's post", `{"type": "Undo", "object": {"type": "Like", "object": "https://local.example.com/notes/2"}}`, false},
+		{"no recipients", `{"type": "Create", "object": {"type": "Note", "content": "hi"}}`, false},
+		{"invalid JSON", `{`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isAddressedToInbox([]byte(tt.body), "alice", "local.example.com", mockDB); got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+// TestHandleInboxWithDeps_RejectsMisdirected tests that an activity for another local account
+// delivered to alice's inbox is rejected
+func TestHandleInboxWithDeps_RejectsMisdirected(t *testing.T) {
+	mockDB, _, deps, _, remoteActor, conf := setupFollowTest(t)
+	keypair, _ := GenerateTestKeyPair()
+	remoteActor.PublicKeyPem = keypair.PublicPEM
+	remoteActor.LastFetchedAt = time.Now()
+
+	body := []byte(`{"id": "https://remote.example.com/activities/follow-bob", "type": "Follow", "actor": "https://remote.example.com/users/bob", "object": "https://local.example.com/users/bob"}`)
+	req := createSignedRequest(t, "POST", "/users/alice/inbox", body, keypair, "https://remote.example.com/users/bob#main-key")
+
+	rr := httptest.NewRecorder()
+	HandleInboxWithDeps(rr, req, "alice", conf, deps)
+
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 for a misdirected delivery, got %d", rr.Code)
+	}
+	if len(mockDB.Follows) != 0 || len(mockDB.Activities) != 0 {
+		t.Errorf("Expected nothing stored, got %d follows and %d activities", len(mockDB.Follows), len(mockDB.Activities))
+	}
+}
+
+func TestObjectVisibility(t *testing.T) {
+	public := "https://www.w3.org/ns/activitystreams#Public"
+	followers := "https://remote.example.com/users/bob/followers"
+	carol := "https://other.example.com/users/carol"
+
+	tests := []struct {
+		name   string
+		object map[string]any
+		want   string
+	}{
+		{"public", map[string]any{"to": []any{public}, "cc": []any{followers}}, domain.VisibilityPublic},
+		{"public as a string", map[string]any{"to": "as:Public"}, domain.VisibilityPublic},
+		{"unlisted", map[string]any{"to": []any{followers}, "cc": []any{public}}, domain.VisibilityUnlisted},
+		{"followers only", map[string]any{"to": []any{followers}, "cc": []any{carol}}, domain.VisibilityFollowers},
+		{"direct", map[string]any{"to": []any{carol}}, domain.VisibilityDirect},
+		{"no addressing", map[string]any{"type": "Note"}, domain.VisibilityPublic},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := objectVisibility(tt.object); got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestIsRestrictedObject(t *testing.T) {
+	mockDB := NewMockDatabase()
+	bob := &domain.RemoteAccount{Id: uuid.New(), ActorURI: "https://remote.example.com/users/bob"}
+	carol := &domain.RemoteAccount{Id: uuid.New(), ActorURI: "https://other.example.com/users/carol"}
+	mockDB.AddRemoteAccount(bob)
+	mockDB.AddRemoteAccount(carol)
+	mockDB.AddFollow(&domain.Follow{Id: uuid.New(), AccountId: uuid.New(), TargetAccountId: bob.Id, Accepted: true})
+
+	followersOnly := map[string]any{"to": []any{"https://remote.example.com/users/bob/followers"}}
+	public := map[string]any{"to": []any{"https://www.w3.org/ns/activitystreams#Public"}}
+
+	if isRestrictedObject(followersOnly, bob.ActorURI, mockDB) {
+		t.Error("Expected a followers-only post of a followed author not to be restricted")
+	}
+	if !isRestrictedObject(followersOnly, carol.ActorURI, mockDB) {
+		t.Error("Expected a followers-only post of an author nobody follows to be restricted")
+	}
+	if isRestrictedObject(public, carol.ActorURI, mockDB) {
+		t.Error("Expected a public post not to be restricted")
+	}
+}
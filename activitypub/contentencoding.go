@@ -0,0 +1,112 @@
+package activitypub
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+var (
+	// ErrUnsupportedContentEncoding is returned for request bodies in an encoding we can't decode
+	ErrUnsupportedContentEncoding = errors.New("unsupported content encoding")
+	// ErrBodyTooLarge is returned when a request body decodes to more than the allowed size
+	ErrBodyTooLarge = errors.New("request body too large")
+	// ErrDigestMismatch is returned when the Digest header matches neither the sent nor the decoded body
+	ErrDigestMismatch = errors.New("digest does not match body")
+	// ErrMissingDigest is returned for a body without a SHA-256 or SHA-512 Digest header
+	ErrMissingDigest = errors.New("missing digest")
+	// ErrUnsignedDigest is returned when a request's signature doesn't cover its Digest header
+	ErrUnsignedDigest = errors.New("signature does not cover the digest")
+)
+
+// decodeRequestBody undoes the Content-Encoding of a request body. gzip (also as x-gzip)
+// and deflate (zlib, as HTTP defines it) are supported; the decoded body may be at most
+// limit bytes, so a small compressed request can't expand into an arbitrarily large one.
+func decodeRequestBody(contentEncoding string, body []byte, limit int) ([]byte, error) {
+	var reader io.Reader
+	var err error
+	switch strings.ToLower(strings.TrimSpace(contentEncoding)) {
+	case "", "identity":
+		return body, nil
+	case "gzip", "x-gzip":
+		reader, err = gzip.NewReader(bytes.NewReader(body))
+	case "deflate":
+		reader, err = zlib.NewReader(bytes.NewReader(body))
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedContentEncoding, contentEncoding)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s body: %w", contentEncoding, err)
+	}
+
+	decoded, err := io.ReadAll(io.LimitReader(reader, int64(limit)+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s body: %w", contentEncoding, err)
+	}
+	if len(decoded) > limit {
+		return nil, ErrBodyTooLarge
+	}
+	return decoded, nil
+}
+
+// checkSignedDigest checks that a POST carries a Digest header its signature covers. Only the
+// digest ties the signature to the body, so without it any body would pass as signed.
+func checkSignedDigest(r *http.Request, params *signatureParams) error {
+	if r.Header.Get("Digest") == "" {
+		return ErrMissingDigest
+	}
+	if params == nil || !slices.Contains(params.Headers, "digest") {
+		return ErrUnsignedDigest
+	}
+	return nil
+}
+
+// verifyBodyDigest checks a Digest header (RFC 3230) against the body. The digest is meant
+// to cover the body as sent, but some servers hash it before compressing, so a digest of
+// any of the given bodies is accepted. A header without a SHA-256 or SHA-512 value in it
+// can't be checked and is rejected like a missing one.
+func verifyBodyDigest(header string, bodies ...[]byte) error {
+	checked := false
+	for _, value := range strings.Split(header, ",") {
+		algorithm, encoded, ok := strings.Cut(strings.TrimSpace(value), "=")
+		if !ok {
+			continue
+		}
+		var newHash func() hash.Hash
+		switch strings.ToUpper(algorithm) {
+		case "SHA-256":
+			newHash = sha256.New
+		case "SHA-512":
+			newHash = sha512.New
+		default:
+			continue
+		}
+		want, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return fmt.Errorf("malformed %s digest: %w", algorithm, err)
+		}
+		checked = true
+		for _, body := range bodies {
+			h := newHash()
+			h.Write(body)
+			if subtle.ConstantTimeCompare(h.Sum(nil), want) == 1 {
+				return nil
+			}
+		}
+	}
+	if checked {
+		return ErrDigestMismatch
+	}
+	return ErrMissingDigest
+}
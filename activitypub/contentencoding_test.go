@@ -0,0 +1,102 @@
+package activitypub
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// gzipBody compresses a request body like a sender using Content-Encoding: gzip
+func gzipBody(t *testing.T, body []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(body); err != nil {
+		t.Fatalf("Failed to gzip body: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Failed to gzip body: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecodeRequestBody(t *testing.T) {
+	body := []byte(`{"type": "Follow"}`)
+
+	var deflated bytes.Buffer
+	w := zlib.NewWriter(&deflated)
+	w.Write(body)
+	w.Close()
+
+	tests := []struct {
+		name     string
+		encoding string
+		sent     []byte
+	}{
+		{"none", "", body},
+		{"identity", "identity", body},
+		{"gzip", "gzip", gzipBody(t, body)},
+		{"x-gzip", "x-gzip", gzipBody(t, body)},
+		{"mixed case", " GZip ", gzipBody(t, body)},
+		{"deflate", "deflate", deflated.Bytes()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoded, err := decodeRequestBody(tt.encoding, tt.sent, 1024)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !bytes.Equal(decoded, body) {
+				t.Errorf("Expected %q, got %q", body, decoded)
+			}
+		})
+	}
+}
+
+func TestDecodeRequestBody_Errors(t *testing.T) {
+	if _, err := decodeRequestBody("br", []byte("x"), 1024); !errors.Is(err, ErrUnsupportedContentEncoding) {
+		t.Errorf("Expected ErrUnsupportedContentEncoding, got %v", err)
+	}
+	if _, err := decodeRequestBody("gzip", []byte("not gzip"), 1024); err == nil {
+		t.Error("Expected an error for a body that isn't gzip")
+	}
+
+	// A few hundred compressed bytes must not expand past the limit
+	bomb := gzipBody(t, []byte(strings.Repeat("a", 100000)))
+	if _, err := decodeRequestBody("gzip", bomb, 1024); !errors.Is(err, ErrBodyTooLarge) {
+		t.Errorf("Expected ErrBodyTooLarge, got %v", err)
+	}
+}
+
+func TestVerifyBodyDigest(t *testing.T) {
+	body := []byte(`{"type": "Follow"}`)
+	sent := gzipBody(t, body)
+
+	tests := []struct {
+		name    string
+		header  string
+		wantErr bool
+	}{
+		{"no header", "", true},
+		{"digest of the sent body", calculateDigest(sent), false},
+		{"digest of the decoded body", calculateDigest(body), false},
+		{"lowercase algorithm", "sha-256=" + strings.TrimPrefix(calculateDigest(body), "SHA-256="), false},
+		{"unsupported algorithm only", "MD5=Q2hlY2sgSW50ZWdyaXR5IQ==", true},
+		{"mismatch", calculateDigest([]byte("other")), true},
+		{"unsupported and mismatch", "MD5=Q2hlY2sgSW50ZWdyaXR5IQ==," + calculateDigest([]byte("other")), true},
+		{"malformed", "SHA-256=not base64!", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyBodyDigest(tt.header, sent, body)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package activitypub
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// securityV1Context is the security vocabulary that defines publicKey and publicKeyPem
+const securityV1Context = "https://w3id.org/security/v1"
+
+// Context is a JSON-LD @context, which may be a single URL or an array mixing URLs and
+// inline term definitions. Both forms are normalized into a list of context URLs plus the
+// IRIs the inline terms map to. The original JSON is kept so it re-serializes unchanged.
+type Context struct {
+	urls  []string
+	terms map[string]string
+	raw   json.RawMessage
+}
+
+// UnmarshalJSON accepts a string, an object or an array of both. Entries of other types
+// are ignored rather than failing the whole activity.
+func (c *Context) UnmarshalJSON(data []byte) error {
+	*c = Context{raw: append(json.RawMessage(nil), data...)}
+
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	entries, ok := value.([]any)
+	if !ok {
+		entries = []any{value}
+	}
+	for _, entry := range entries {
+		switch e := entry.(type) {
+		case string:
+			c.urls = append(c.urls, e)
+		case map[string]any:
+			for term, def := range e {
+				if iri := termIRI(def); iri != "" {
+					if c.terms == nil {
+						c.terms = make(map[string]string)
+					}
+					c.terms[term] = iri
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// MarshalJSON writes the @context exactly as it was received
+func (c Context) MarshalJSON() ([]byte, error) {
+	if len(c.raw) == 0 {
+		return []byte("null"), nil
+	}
+	return c.raw, nil
+}
+
+// termIRI returns the IRI a term definition maps to: either the string itself or its @id
+func termIRI(def any) string {
+	switch d := def.(type) {
+	case string:
+		return d
+	case map[string]any:
+		if id, ok := d["@id"].(string); ok {
+			return id
+		}
+	}
+	return ""
+}
+
+// URLs returns the context URLs in their original order
+func (c Context) URLs() []string {
+	return c.urls
+}
+
+// Term returns the IRI an inline term definition maps to, if the context defines it
+func (c Context) Term(name string) (string, bool) {
+	iri, ok := c.terms[name]
+	return iri, ok
+}
+
+// HasActivityStreams reports whether the ActivityStreams context is included
+func (c Context) HasActivityStreams() bool {
+	return c.HasExtension(ActivityStreamsProfile)
+}
+
+// HasSecurityV1 reports whether the security vocabulary that defines publicKey is included
+func (c Context) HasSecurityV1() bool {
+	return c.HasExtension(securityV1Context)
+}
+
+// HasExtension reports whether url is one of the context URLs or the IRI of an inline term,
+// such as the "toot" namespace http://joinmastodon.org/ns#. A trailing "#" is not significant.
+func (c Context) HasExtension(url string) bool {
+	url = strings.TrimSuffix(url, "#")
+	for _, u := range c.urls {
+		if strings.TrimSuffix(u, "#") == url {
+			return true
+		}
+	}
+	for _, iri := range c.terms {
+		if strings.TrimSuffix(iri, "#") == url {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,103 @@
+package activitypub
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+func TestContextUnmarshal(t *testing.T) {
+	tests := []struct {
+		name     string
+		json     string
+		wantURLs []string
+	}{
+		{"string", `"https://www.w3.org/ns/activitystreams"`, []string{"https://www.w3.org/ns/activitystreams"}},
+		{"array", `["https://www.w3.org/ns/activitystreams", "https://w3id.org/security/v1"]`, []string{"https://www.w3.org/ns/activitystreams", "https://w3id.org/security/v1"}},
+		{"array with inline terms", `["https://www.w3.org/ns/activitystreams", {"toot": "http://joinmastodon.org/ns#"}]`, []string{"https://www.w3.org/ns/activitystreams"}},
+		{"object only", `{"as": "https://www.w3.org/ns/activitystreams"}`, nil},
+		{"unexpected entries", `["https://www.w3.org/ns/activitystreams", 42, null]`, []string{"https://www.w3.org/ns/activitystreams"}},
+		{"null", `null`, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c Context
+			if err := json.Unmarshal([]byte(tt.json), &c); err != nil {
+				t.Fatalf("Failed to unmarshal context: %v", err)
+			}
+			if !slices.Equal(c.URLs(), tt.wantURLs) {
+				t.Errorf("Expected URLs %v, got %v", tt.wantURLs, c.URLs())
+			}
+		})
+	}
+}
+
+func TestContextHelpers(t *testing.T) {
+	// Mastodon-style context with the security vocabulary and extension terms
+	var c Context
+	if err := json.Unmarshal([]byte(`[
+		"https://www.w3.org/ns/activitystreams",
+		"https://w3id.org/security/v1",
+		{
+			"toot": "http://joinmastodon.org/ns#",
+			"quoteUrl": "as:quoteUrl",
+			"sensitive": {"@id": "as:sensitive", "@type": "xsd:boolean"}
+		}
+	]`), &c); err != nil {
+		t.Fatalf("Failed to unmarshal context: %v", err)
+	}
+
+	if !c.HasActivityStreams() {
+		t.Error("Expected HasActivityStreams() to be true")
+	}
+	if !c.HasSecurityV1() {
+		t.Error("Expected HasSecurityV1() to be true")
+	}
+	if !c.HasExtension("http://joinmastodon.org/ns") || !c.HasExtension("http://joinmastodon.org/ns#") {
+		t.Error("Expected the toot namespace to be found with or without a trailing #")
+	}
+	if c.HasExtension("https://misskey-hub.net/ns#") {
+		t.Error("Expected the misskey namespace not to be found")
+	}
+	if iri, ok := c.Term("quoteUrl"); !ok || iri != "as:quoteUrl" {
+		t.Errorf("Expected quoteUrl term as:quoteUrl, got %q", iri)
+	}
+	if iri, ok := c.Term("sensitive"); !ok || iri != "as:sensitive" {
+		t.Errorf("Expected sensitive term from its @id, got %q", iri)
+	}
+	if _, ok := c.Term("_misskey_quote"); ok {
+		t.Error("Expected no _misskey_quote term")
+	}
+
+	var plain Context
+	if err := json.Unmarshal([]byte(`"https://www.w3.org/ns/activitystreams"`), &plain); err != nil {
+		t.Fatalf("Failed to unmarshal context: %v", err)
+	}
+	if plain.HasSecurityV1() {
+		t.Error("Expected HasSecurityV1() to be false for the plain ActivityStreams context")
+	}
+}
+
+// TestContextRoundTrip tests that an activity re-serializes with its original @context
+func TestContextRoundTrip(t *testing.T) {
+	tests := []string{
+		`{"@context":"https://www.w3.org/ns/activitystreams","id":"https://remote.example.com/activities/1","type":"Follow","actor":"https://remote.example.com/users/bob","object":"https://local.example.com/users/alice"}`,
+		`{"@context":["https://www.w3.org/ns/activitystreams",{"toot":"http://joinmastodon.org/ns#"}],"id":"https://remote.example.com/activities/1","type":"Follow","actor":"https://remote.example.com/users/bob","object":"https://local.example.com/users/alice"}`,
+		`{"@context":null,"id":"https://remote.example.com/activities/1","type":"Follow","actor":"https://remote.example.com/users/bob","object":"https://local.example.com/users/alice"}`,
+	}
+
+	for _, original := range tests {
+		var activity Activity
+		if err := json.Unmarshal([]byte(original), &activity); err != nil {
+			t.Fatalf("Failed to unmarshal activity: %v", err)
+		}
+		encoded, err := json.Marshal(activity)
+		if err != nil {
+			t.Fatalf("Failed to marshal activity: %v", err)
+		}
+		if string(encoded) != original {
+			t.Errorf("Expected %s, got %s", original, encoded)
+		}
+	}
+}
@@ -0,0 +1,337 @@
+package activitypub
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/deemkeen/stegodon/domain"
+	"github.com/deemkeen/stegodon/util"
+	"github.com/google/uuid"
+)
+
+// importMaxPages caps the number of pages read from a single replies collection. How deep,
+// how many fetches and how long an import may take is configured (see util.AppConfig).
+const importMaxPages = 5
+
+// ErrNotAPost is returned by ImportConversation for URLs that don't resolve to a post
+var ErrNotAPost = errors.New("not a post")
+
+// ConversationImport is the result of ImportConversation
+type ConversationImport struct {
+	RootURI   string // topmost post of the conversation that could be resolved
+	FocusURI  string // the post the import was started from
+	Imported  int    // posts that were stored; posts already stored are not counted
+	Truncated bool   // true if the depth, fetch or time limit cut the import short
+	// ContinueURI is the post to import from to load more of a truncated conversation: the
+	// topmost ancestor fetched, or the first post whose replies weren't all walked
+	ContinueURI string
+}
+
+// ImportConversation fetches the post at url together with its ancestors and the replies
+// listed in the replies collections of the conversation, and stores the posts that aren't
+// stored yet, so a discussion found elsewhere can be read here.
+func ImportConversation(url string, conf *util.AppConfig) (*ConversationImport, error) {
+	deps := &InboxDeps{
+		Database:   NewDBWrapper(),
+		HTTPClient: defaultHTTPClient,
+	}
+	return ImportConversationWithDeps(url, conf, deps)
+}
+
+// ImportConversationWithDeps is ImportConversation with dependencies for testing
+func ImportConversationWithDeps(url string, conf *util.AppConfig, deps *InboxDeps) (*ConversationImport, error) {
+	importer := &conversationImporter{
+		conf:       conf,
+		deps:       deps,
+		seen:       make(map[string]bool),
+		maxDepth:   conf.ThreadDepthLimit(),
+		maxFetches: conf.ThreadFetchLimit(),
+		deadline:   time.Now().Add(conf.ThreadImportDeadline()),
+	}
+
+	focus, err := importer.fetchPost(url)
+	if err != nil {
+		return nil, err
+	}
+	focusURI, _ := focus["id"].(string)
+	result := &ConversationImport{RootURI: focusURI, FocusURI: focusURI}
+	truncate := func(continueURI string) {
+		result.Truncated = true
+		if result.ContinueURI == "" {
+			result.ContinueURI = continueURI
+		}
+	}
+
+	// Walk up to the root first; ancestors are stored top-down so every reply finds its parent
+	chain := []map[string]any{focus}
+	for parentURI := stringField(focus, "inReplyTo"); parentURI != ""; {
+		if importer.isStored(parentURI) {
+			result.RootURI = parentURI
+			break
+		}
+		if len(chain) >= importer.maxDepth || importer.exhausted() {
+			truncate(result.RootURI)
+			break
+		}
+		parent, err := importer.fetchPost(parentURI)
+		if err != nil {
+			log.Printf("ImportConversation: Failed to fetch ancestor %s: %v", parentURI, err)
+			break
+		}
+		chain = append(chain, parent)
+		result.RootURI, _ = parent["id"].(string)
+		parentURI = stringField(parent, "inReplyTo")
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		importer.store(chain[i])
+	}
+
+	// Then walk down the reply trees of the fetched posts, breadth first. Each post of the
+	// chain is queued, as its own replies aren't reached through its parent.
+	type pending struct {
+		object map[string]any
+		depth  int
+	}
+	var queue []pending
+	for i := len(chain) - 1; i >= 0; i-- {
+		queue = append(queue, pending{chain[i], len(chain) - 1 - i})
+	}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		currentURI := stringField(current.object, "id")
+		if current.depth >= importer.maxDepth {
+			truncate(currentURI)
+			continue
+		}
+		if importer.exhausted() {
+			truncate(currentURI)
+			break
+		}
+		for _, item := range importer.replyItems(current.object) {
+			if importer.exhausted() {
+				truncate(currentURI)
+				break
+			}
+			reply := importer.resolveReply(item)
+			if reply == nil {
+				continue
+			}
+			importer.store(reply)
+			queue = append(queue, pending{reply, current.depth + 1})
+		}
+	}
+
+	result.Imported = importer.imported
+	log.Printf("ImportConversation: Imported %d posts of the conversation of %s (root %s)", result.Imported, result.FocusURI, result.RootURI)
+	return result, nil
+}
+
+// conversationImporter keeps track of the posts fetched and stored by one import
+type conversationImporter struct {
+	conf     *util.AppConfig
+	deps     *InboxDeps
+	seen     map[string]bool // object URIs already fetched or resolved
+	fetched  int
+	imported int
+	// Limits of the import
+	maxDepth   int
+	maxFetches int
+	deadline   time.Time
+}
+
+// exhausted reports whether the import used up its fetches or time
+func (i *conversationImporter) exhausted() bool {
+	return i.fetched >= i.maxFetches || time.Now().After(i.deadline)
+}
+
+// fetchPost fetches a post, unwrapping a Create, and checks that it is a Note, Article or Page
+func (i *conversationImporter) fetchPost(uri string) (map[string]any, error) {
+	i.fetched++
+	object, err := fetchActivityPubObject(uri, i.deps.HTTPClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", uri, err)
+	}
+	if embedded, ok := object["object"].(map[string]any); ok && object["type"] == "Create" {
+		object = embedded
+	}
+	switch object["type"] {
+	case "Note", "Article", "Page":
+	default:
+		return nil, fmt.Errorf("%w: %s is a %v", ErrNotAPost, uri, object["type"])
+	}
+	id := stringField(object, "id")
+	if id == "" {
+		return nil, fmt.Errorf("%w: %s has no id", ErrNotAPost, uri)
+	}
+	i.seen[uri] = true
+	i.seen[id] = true
+	return object, nil
+}
+
+// isStored reports whether a post is a local note or already stored as an activity
+func (i *conversationImporter) isStored(uri string) bool {
+	if note, err := i.deps.Database.ReadNoteByURI(uri); err == nil && note != nil {
+		return true
+	}
+	activity, err := i.deps.Database.ReadActivityByObjectURI(uri)
+	return err == nil && activity != nil
+}
+
+// store saves a fetched post as a Create activity, like relay-forwarded posts, unless it is
+// already stored. The parent's reply count is incremented for new replies.
+func (i *conversationImporter) store(object map[string]any) {
+	objectURI := stringField(object, "id")
+	if i.isStored(objectURI) {
+		return
+	}
+
+	actorURI := stringField(object, "attributedTo")
+	if actorURI == "" {
+		log.Printf("ImportConversation: Post %s has no attributedTo, skipping", objectURI)
+		return
+	}
+	if content, _ := object["content"].(string); exceedsInboundNoteChars(content, i.conf) {
+		log.Printf("ImportConversation: Post %s skipped: longer than %d characters", objectURI, i.conf.InboundNoteCharLimit())
+		return
+	}
+	if _, err := GetOrFetchActorWithDeps(actorURI, i.deps.HTTPClient, i.deps.Database); err != nil {
+		log.Printf("ImportConversation: Failed to fetch author %s of %s: %v", actorURI, objectURI, err)
+		// Continue anyway - we can still store the activity
+	}
+
+	rawJSON, err := json.Marshal(map[string]any{
+		"@context": "https://www.w3.org/ns/activitystreams",
+		"type":     "Create",
+		"actor":    actorURI,
+		"object":   object,
+	})
+	if err != nil {
+		log.Printf("ImportConversation: Failed to marshal %s: %v", objectURI, err)
+		return
+	}
+
+	activity := &domain.Activity{
+		Id:           uuid.New(),
+		ActivityURI:  objectURI,
+		ActivityType: "Create",
+		ActorURI:     actorURI,
+		ObjectURI:    objectURI,
+		RawJSON:      string(rawJSON),
+		Processed:    true,
+		Local:        false,
+		CreatedAt:    time.Now(),
+		Language:     activityLanguage(rawJSON),
+		Restricted:   isRestrictedObject(object, actorURI, i.deps.Database),
+	}
+	if activity.Restricted {
+		log.Printf("ImportConversation: Post %s is %s and its author isn't followed, storing it restricted", objectURI, objectVisibility(object))
+	}
+	if err := i.deps.Database.CreateActivity(activity); err != nil {
+		log.Printf("ImportConversation: Failed to store %s: %v", objectURI, err)
+		return
+	}
+	i.imported++
+
+	if parentURI := stringField(object, "inReplyTo"); parentURI != "" {
+		if err := i.deps.Database.IncrementReplyCountByURI(parentURI); err != nil {
+			log.Printf("ImportConversation: Failed to increment reply count for %s: %v", parentURI, err)
+		}
+	}
+}
+
+// replyItems returns the items of a post's replies collection, following its pages up to
+// importMaxPages. Items are URIs or embedded posts.
+func (i *conversationImporter) replyItems(object map[string]any) []any {
+	var page any
+	switch replies := object["replies"].(type) {
+	case string:
+		page = replies
+	case map[string]any:
+		page = replies
+	default:
+		return nil
+	}
+
+	var items []any
+	for pages := 0; page != nil && pages < importMaxPages; pages++ {
+		collection, ok := page.(map[string]any)
+		if !ok {
+			uri, _ := page.(string)
+			if uri == "" || i.exhausted() {
+				break
+			}
+			i.fetched++
+			fetched, err := fetchActivityPubObject(uri, i.deps.HTTPClient)
+			if err != nil {
+				log.Printf("ImportConversation: Failed to fetch replies %s: %v", uri, err)
+				break
+			}
+			collection = fetched
+		}
+
+		pageItems, _ := collection["items"].([]any)
+		if ordered, ok := collection["orderedItems"].([]any); ok {
+			pageItems = ordered
+		}
+		items = append(items, pageItems...)
+
+		// A collection links to its first page, a page to the next one
+		if first, ok := collection["first"]; ok && len(pageItems) == 0 {
+			page = first
+		} else {
+			page = collection["next"]
+		}
+	}
+	return items
+}
+
+// resolveReply returns the post for an item of a replies collection, fetching it unless it
+// is embedded, or nil if it was seen before, is a local note or can't be resolved. Stored
+// remote replies are resolved too, so replies to them that arrived since are found.
+func (i *conversationImporter) resolveReply(item any) map[string]any {
+	switch reply := item.(type) {
+	case string:
+		if reply == "" || i.seen[reply] {
+			return nil
+		}
+		i.seen[reply] = true
+		if note, err := i.deps.Database.ReadNoteByURI(reply); err == nil && note != nil {
+			return nil
+		}
+		object, err := i.fetchPost(reply)
+		if err != nil {
+			log.Printf("ImportConversation: Failed to fetch reply %s: %v", reply, err)
+			return nil
+		}
+		return object
+	case map[string]any:
+		id := stringField(reply, "id")
+		if id == "" || i.seen[id] {
+			return nil
+		}
+		// Embedded replies without content, e.g. only an id, are fetched
+		if _, ok := reply["content"]; !ok {
+			return i.resolveReply(id)
+		}
+		i.seen[id] = true
+		return reply
+	}
+	return nil
+}
+
+// stringField returns a string field of an object, or the id of an embedded object
+func stringField(object map[string]any, key string) string {
+	switch value := object[key].(type) {
+	case string:
+		return strings.TrimSpace(value)
+	case map[string]any:
+		id, _ := value["id"].(string)
+		return id
+	}
+	return ""
+}
@@ -0,0 +1,203 @@
+package activitypub
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/deemkeen/stegodon/domain"
+	"github.com/deemkeen/stegodon/util"
+	"github.com/google/uuid"
+)
+
+func TestImportConversationWithDeps(t *testing.T) {
+	mockDB := NewMockDatabase()
+	mockHTTP := NewMockHTTPClient()
+	deps := &InboxDeps{Database: mockDB, HTTPClient: mockHTTP}
+	conf := &util.AppConfig{}
+	conf.Conf.SslDomain = "local.example.com"
+
+	bob := "https://remote.example.com/users/bob"
+	carol := "https://other.example.com/users/carol"
+	for _, actorURI := range []string{bob, carol} {
+		mockDB.AddRemoteAccount(&domain.RemoteAccount{Id: uuid.New(), ActorURI: actorURI, LastFetchedAt: time.Now()})
+	}
+
+	root := "https://remote.example.com/notes/1"
+	focus := "https://remote.example.com/notes/2"
+	known := "https://other.example.com/notes/3"
+	nested := "https://remote.example.com/notes/4"
+
+	// The root lists the focus on its first page and an embedded reply on the next one
+	mockHTTP.SetJSONResponse(root, 200, map[string]any{
+		"id": root, "type": "Note", "attributedTo": bob, "content": "root",
+		"replies": map[string]any{
+			"type": "Collection",
+			"first": map[string]any{
+				"type":  "CollectionPage",
+				"items": []any{focus},
+				"next":  root + "/replies?page=2",
+			},
+		},
+	})
+	mockHTTP.SetJSONResponse(root+"/replies?page=2", 200, map[string]any{
+		"type": "CollectionPage",
+		"items": []any{map[string]any{
+			"id": known, "type": "Note", "attributedTo": carol, "content": "known", "inReplyTo": root,
+		}},
+	})
+	// The post the import starts from, wrapped in its Create
+	mockHTTP.SetJSONResponse(focus, 200, map[string]any{
+		"id": focus + "/activity", "type": "Create", "actor": bob,
+		"object": map[string]any{
+			"id": focus, "type": "Note", "attributedTo": bob, "content": "focus", "inReplyTo": root,
+			"replies": focus + "/replies",
+		},
+	})
+	mockHTTP.SetJSONResponse(focus+"/replies", 200, map[string]any{
+		"type":         "OrderedCollection",
+		"orderedItems": []any{nested},
+	})
+	mockHTTP.SetJSONResponse(nested, 200, map[string]any{
+		"id": nested, "type": "Note", "attributedTo": carol, "content": "nested", "inReplyTo": focus,
+	})
+
+	// One reply is already stored and isn't imported again
+	mockDB.CreateActivity(&domain.Activity{Id: uuid.New(), ActivityURI: known, ActivityType: "Create", ActorURI: carol, ObjectURI: known})
+
+	result, err := ImportConversationWithDeps(focus, conf, deps)
+	if err != nil {
+		t.Fatalf("ImportConversationWithDeps failed: %v", err)
+	}
+	if result.RootURI != root || result.FocusURI != focus {
+		t.Errorf("Expected root %s and focus %s, got %+v", root, focus, result)
+	}
+	if result.Imported != 3 || result.Truncated {
+		t.Errorf("Expected 3 imported posts without truncation, got %+v", result)
+	}
+	for _, uri := range []string{root, focus, nested} {
+		if activity, _ := mockDB.ReadActivityByObjectURI(uri); activity == nil || activity.ActivityType != "Create" {
+			t.Errorf("Expected %s to be stored as a Create", uri)
+		}
+	}
+	if !slices.Equal(mockDB.IncrementReplyCountCalls, []string{root, focus}) {
+		t.Errorf("Expected reply counts of the root and the focus to be incremented, got %v", mockDB.IncrementReplyCountCalls)
+	}
+}
+
+// TestImportConversationWithDeps_Restricted tests that a followers-only ancestor by an author
+// nobody here follows is stored for the thread but kept out of timelines
+func TestImportConversationWithDeps_Restricted(t *testing.T) {
+	mockDB := NewMockDatabase()
+	mockHTTP := NewMockHTTPClient()
+	deps := &InboxDeps{Database: mockDB, HTTPClient: mockHTTP}
+
+	bob := "https://remote.example.com/users/bob"
+	mockDB.AddRemoteAccount(&domain.RemoteAccount{Id: uuid.New(), ActorURI: bob, LastFetchedAt: time.Now()})
+
+	root := "https://remote.example.com/notes/1"
+	reply := "https://remote.example.com/notes/2"
+	mockHTTP.SetJSONResponse(root, 200, map[string]any{
+		"id": root, "type": "Note", "attributedTo": bob, "content": "private",
+		"to": []any{bob + "/followers"},
+	})
+	mockHTTP.SetJSONResponse(reply, 200, map[string]any{
+		"id": reply, "type": "Note", "attributedTo": bob, "content": "public", "inReplyTo": root,
+		"to": []any{"https://www.w3.org/ns/activitystreams#Public"},
+	})
+
+	if _, err := ImportConversationWithDeps(reply, &util.AppConfig{}, deps); err != nil {
+		t.Fatalf("ImportConversationWithDeps failed: %v", err)
+	}
+	if activity, _ := mockDB.ReadActivityByObjectURI(root); activity == nil || !activity.Restricted {
+		t.Errorf("Expected the followers-only root to be stored restricted, got %+v", activity)
+	}
+	if activity, _ := mockDB.ReadActivityByObjectURI(reply); activity == nil || activity.Restricted {
+		t.Errorf("Expected the public reply to be stored unrestricted, got %+v", activity)
+	}
+}
+
+func TestImportConversationWithDeps_NotAPost(t *testing.T) {
+	mockHTTP := NewMockHTTPClient()
+	deps := &InboxDeps{Database: NewMockDatabase(), HTTPClient: mockHTTP}
+
+	actorURI := "https://remote.example.com/users/bob"
+	mockHTTP.SetJSONResponse(actorURI, 200, map[string]any{"id": actorURI, "type": "Person"})
+
+	if _, err := ImportConversationWithDeps(actorURI, &util.AppConfig{}, deps); !errors.Is(err, ErrNotAPost) {
+		t.Errorf("Expected ErrNotAPost, got %v", err)
+	}
+}
+
+func TestImportConversationWithDeps_Limits(t *testing.T) {
+	mockDB := NewMockDatabase()
+	mockHTTP := NewMockHTTPClient()
+	deps := &InboxDeps{Database: mockDB, HTTPClient: mockHTTP}
+	conf := &util.AppConfig{}
+	conf.Conf.MaxThreadDepth = 2
+
+	bob := "https://remote.example.com/users/bob"
+	mockDB.AddRemoteAccount(&domain.RemoteAccount{Id: uuid.New(), ActorURI: bob, LastFetchedAt: time.Now()})
+
+	// A chain of five posts, each replying to the one before. Mock responses can be read
+	// once, so they are set up again for every import.
+	post := func(n int) string { return "https://remote.example.com/notes/" + string(rune('0'+n)) }
+	serveChain := func() {
+		for n := 1; n <= 5; n++ {
+			note := map[string]any{"id": post(n), "type": "Note", "attributedTo": bob, "content": "post"}
+			if n > 1 {
+				note["inReplyTo"] = post(n - 1)
+			}
+			mockHTTP.SetJSONResponse(post(n), 200, note)
+		}
+	}
+
+	serveChain()
+	result, err := ImportConversationWithDeps(post(5), conf, deps)
+	if err != nil {
+		t.Fatalf("ImportConversationWithDeps failed: %v", err)
+	}
+	if !result.Truncated || result.Imported != 2 || result.RootURI != post(4) {
+		t.Fatalf("Expected the depth limit to stop the import at %s, got %+v", post(4), result)
+	}
+	if result.ContinueURI != post(4) {
+		t.Errorf("Expected to continue from the topmost ancestor fetched, got %q", result.ContinueURI)
+	}
+
+	// Loading more walks further up from where the last import stopped
+	serveChain()
+	result, err = ImportConversationWithDeps(result.ContinueURI, conf, deps)
+	if err != nil {
+		t.Fatalf("ImportConversationWithDeps failed: %v", err)
+	}
+	if result.Imported != 1 || result.RootURI != post(3) || result.ContinueURI != post(3) {
+		t.Errorf("Expected %s to be imported next, got %+v", post(3), result)
+	}
+
+	// Running out of fetches stops the import as well
+	conf.Conf.MaxThreadDepth = 0
+	conf.Conf.MaxThreadFetches = 1
+	serveChain()
+	result, err = ImportConversationWithDeps(post(3), conf, deps)
+	if err != nil {
+		t.Fatalf("ImportConversationWithDeps failed: %v", err)
+	}
+	if !result.Truncated || result.ContinueURI != post(3) {
+		t.Errorf("Expected the fetch limit to truncate the import at %s, got %+v", post(3), result)
+	}
+	if activity, _ := mockDB.ReadActivityByObjectURI(post(2)); activity != nil {
+		t.Error("Expected no fetch beyond the limit")
+	}
+}
+
+func TestConversationImporterDeadline(t *testing.T) {
+	importer := &conversationImporter{maxFetches: 10, deadline: time.Now().Add(time.Minute)}
+	if importer.exhausted() {
+		t.Error("Expected a fresh import not to be exhausted")
+	}
+	importer.deadline = time.Now().Add(-time.Second)
+	if !importer.exhausted() {
+		t.Error("Expected an import past its deadline to be exhausted")
+	}
+}
@@ -22,28 +22,36 @@ func NewDBWrapper() *DBWrapper {
 
 // Account operations
 
-func (w *DBWrapper) ReadAccByUsername(username string) (error, *domain.Account) {
+func (w *DBWrapper) ReadAccByUsername(username string) (*domain.Account, error) {
 	return w.db.ReadAccByUsername(username)
 }
 
-func (w *DBWrapper) ReadAccById(id uuid.UUID) (error, *domain.Account) {
+func (w *DBWrapper) ReadAccById(id uuid.UUID) (*domain.Account, error) {
 	return w.db.ReadAccById(id)
 }
 
+func (w *DBWrapper) ReadAllAccounts() (*[]domain.Account, error) {
+	return w.db.ReadAllAccounts()
+}
+
 // Remote account operations
 
-func (w *DBWrapper) ReadRemoteAccountByURI(uri string) (error, *domain.RemoteAccount) {
+func (w *DBWrapper) ReadRemoteAccountByURI(uri string) (*domain.RemoteAccount, error) {
 	return w.db.ReadRemoteAccountByURI(uri)
 }
 
-func (w *DBWrapper) ReadRemoteAccountById(id uuid.UUID) (error, *domain.RemoteAccount) {
+func (w *DBWrapper) ReadRemoteAccountById(id uuid.UUID) (*domain.RemoteAccount, error) {
 	return w.db.ReadRemoteAccountById(id)
 }
 
-func (w *DBWrapper) ReadRemoteAccountByActorURI(actorURI string) (error, *domain.RemoteAccount) {
+func (w *DBWrapper) ReadRemoteAccountByActorURI(actorURI string) (*domain.RemoteAccount, error) {
 	return w.db.ReadRemoteAccountByActorURI(actorURI)
 }
 
+func (w *DBWrapper) ReadRemoteAccountByUsernameAndDomain(username, domainName string) (*domain.RemoteAccount, error) {
+	return w.db.ReadRemoteAccountByUsernameAndDomain(username, domainName)
+}
+
 func (w *DBWrapper) CreateRemoteAccount(acc *domain.RemoteAccount) error {
 	return w.db.CreateRemoteAccount(acc)
 }
@@ -62,14 +70,22 @@ func (w *DBWrapper) CreateFollow(follow *domain.Follow) error {
 	return w.db.CreateFollow(follow)
 }
 
-func (w *DBWrapper) ReadFollowByURI(uri string) (error, *domain.Follow) {
+func (w *DBWrapper) ReadFollowByURI(uri string) (*domain.Follow, error) {
 	return w.db.ReadFollowByURI(uri)
 }
 
-func (w *DBWrapper) ReadFollowByAccountIds(accountId, targetAccountId uuid.UUID) (error, *domain.Follow) {
+func (w *DBWrapper) ReadFollowByAccountIds(accountId, targetAccountId uuid.UUID) (*domain.Follow, error) {
 	return w.db.ReadFollowByAccountIds(accountId, targetAccountId)
 }
 
+func (w *DBWrapper) IsRemoteAccountFollowed(remoteAccountId uuid.UUID) (bool, error) {
+	return w.db.IsRemoteAccountFollowed(remoteAccountId)
+}
+
+func (w *DBWrapper) ReadFollowingAddresses(accountId uuid.UUID, localDomain string) ([]string, error) {
+	return w.db.ReadFollowingAddresses(accountId, localDomain)
+}
+
 func (w *DBWrapper) DeleteFollowByURI(uri string) error {
 	return w.db.DeleteFollowByURI(uri)
 }
@@ -78,10 +94,14 @@ func (w *DBWrapper) AcceptFollowByURI(uri string) error {
 	return w.db.AcceptFollowByURI(uri)
 }
 
-func (w *DBWrapper) ReadFollowersByAccountId(accountId uuid.UUID) (error, *[]domain.Follow) {
+func (w *DBWrapper) ReadFollowersByAccountId(accountId uuid.UUID) (*[]domain.Follow, error) {
 	return w.db.ReadFollowersByAccountId(accountId)
 }
 
+func (w *DBWrapper) ReadFollowerInboxURIs(accountId uuid.UUID) ([]string, error) {
+	return w.db.ReadFollowerInboxURIs(accountId)
+}
+
 func (w *DBWrapper) DeleteFollowsByRemoteAccountId(remoteAccountId uuid.UUID) error {
 	return w.db.DeleteFollowsByRemoteAccountId(remoteAccountId)
 }
@@ -96,11 +116,11 @@ func (w *DBWrapper) UpdateActivity(activity *domain.Activity) error {
 	return w.db.UpdateActivity(activity)
 }
 
-func (w *DBWrapper) ReadActivityByURI(uri string) (error, *domain.Activity) {
+func (w *DBWrapper) ReadActivityByURI(uri string) (*domain.Activity, error) {
 	return w.db.ReadActivityByURI(uri)
 }
 
-func (w *DBWrapper) ReadActivityByObjectURI(objectURI string) (error, *domain.Activity) {
+func (w *DBWrapper) ReadActivityByObjectURI(objectURI string) (*domain.Activity, error) {
 	return w.db.ReadActivityByObjectURI(objectURI)
 }
 
@@ -108,18 +128,30 @@ func (w *DBWrapper) DeleteActivity(id uuid.UUID) error {
 	return w.db.DeleteActivity(id)
 }
 
+func (w *DBWrapper) UpdateActivityQuote(activityId uuid.UUID, quoteURI, quoteAuthor, quoteContent string) error {
+	return w.db.UpdateActivityQuote(activityId, quoteURI, quoteAuthor, quoteContent)
+}
+
 // Note operations
 
-func (w *DBWrapper) ReadNoteByURI(objectURI string) (error, *domain.Note) {
+func (w *DBWrapper) ReadNoteByURI(objectURI string) (*domain.Note, error) {
 	return w.db.ReadNoteByURI(objectURI)
 }
 
+func (w *DBWrapper) UpdateNoteObjectJSON(noteId uuid.UUID, objectJSON string) error {
+	return w.db.UpdateNoteObjectJSON(noteId, objectJSON)
+}
+
 // Mention operations
 
 func (w *DBWrapper) CreateNoteMention(mention *domain.NoteMention) error {
 	return w.db.CreateNoteMention(mention)
 }
 
+func (w *DBWrapper) LinkActivityHashtags(activityId uuid.UUID, names []string) error {
+	return w.db.LinkActivityHashtags(activityId, names)
+}
+
 // Engagement count operations
 
 func (w *DBWrapper) IncrementReplyCountByURI(parentURI string) error {
@@ -140,7 +172,7 @@ func (w *DBWrapper) HasLike(accountId, noteId uuid.UUID) (bool, error) {
 	return w.db.HasLike(accountId, noteId)
 }
 
-func (w *DBWrapper) ReadLikeByAccountAndNote(accountId, noteId uuid.UUID) (error, *domain.Like) {
+func (w *DBWrapper) ReadLikeByAccountAndNote(accountId, noteId uuid.UUID) (*domain.Like, error) {
 	return w.db.ReadLikeByAccountAndNote(accountId, noteId)
 }
 
@@ -178,46 +210,106 @@ func (w *DBWrapper) DecrementBoostCountByNoteId(noteId uuid.UUID) error {
 	return w.db.DecrementBoostCountByNoteId(noteId)
 }
 
+// Reaction operations
+
+func (w *DBWrapper) CreateReaction(reaction *domain.Reaction) error {
+	return w.db.CreateReaction(reaction)
+}
+
+func (w *DBWrapper) DeleteReaction(actorURI, objectURI, emoji string) error {
+	return w.db.DeleteReaction(actorURI, objectURI, emoji)
+}
+
+func (w *DBWrapper) DeleteReactionByURI(actorURI, uri string) error {
+	return w.db.DeleteReactionByURI(actorURI, uri)
+}
+
 // Delivery queue operations
 
 func (w *DBWrapper) EnqueueDelivery(item *domain.DeliveryQueueItem) error {
 	return w.db.EnqueueDelivery(item)
 }
 
-func (w *DBWrapper) ReadPendingDeliveries(limit int) (error, *[]domain.DeliveryQueueItem) {
+func (w *DBWrapper) EnqueueDeliveryBatch(items []*domain.DeliveryQueueItem) error {
+	return w.db.EnqueueDeliveryBatch(items)
+}
+
+func (w *DBWrapper) ReadPendingDeliveries(limit int) (*[]domain.DeliveryQueueItem, error) {
 	return w.db.ReadPendingDeliveries(limit)
 }
 
-func (w *DBWrapper) UpdateDeliveryAttempt(id uuid.UUID, attempts int, nextRetry time.Time) error {
-	return w.db.UpdateDeliveryAttempt(id, attempts, nextRetry)
+func (w *DBWrapper) UpdateDeliveryAttempt(id uuid.UUID, attempts int, nextRetry time.Time, lastStatus int) error {
+	return w.db.UpdateDeliveryAttempt(id, attempts, nextRetry, lastStatus)
+}
+
+func (w *DBWrapper) DeadLetterDelivery(id uuid.UUID, attempts int, lastStatus int) error {
+	return w.db.DeadLetterDelivery(id, attempts, lastStatus)
 }
 
 func (w *DBWrapper) DeleteDelivery(id uuid.UUID) error {
 	return w.db.DeleteDelivery(id)
 }
 
+// Inbox queue operations
+
+func (w *DBWrapper) EnqueueInboxActivity(item *domain.InboxQueueItem) error {
+	return w.db.EnqueueInboxActivity(item)
+}
+
+func (w *DBWrapper) ReadPendingInboxActivities(limit int) (*[]domain.InboxQueueItem, error) {
+	return w.db.ReadPendingInboxActivities(limit)
+}
+
+func (w *DBWrapper) UpdateInboxActivityAttempt(id uuid.UUID, attempts int, nextAttempt time.Time) error {
+	return w.db.UpdateInboxActivityAttempt(id, attempts, nextAttempt)
+}
+
+func (w *DBWrapper) DeleteInboxActivity(id uuid.UUID) error {
+	return w.db.DeleteInboxActivity(id)
+}
+
 // Relay operations
 
 func (w *DBWrapper) CreateRelay(relay *domain.Relay) error {
 	return w.db.CreateRelay(relay)
 }
 
-func (w *DBWrapper) ReadActiveRelays() (error, *[]domain.Relay) {
+func (w *DBWrapper) ReadActiveRelays() (*[]domain.Relay, error) {
 	return w.db.ReadActiveRelays()
 }
 
-func (w *DBWrapper) ReadActiveUnpausedRelays() (error, *[]domain.Relay) {
+func (w *DBWrapper) ReadActiveUnpausedRelays() (*[]domain.Relay, error) {
 	return w.db.ReadActiveUnpausedRelays()
 }
 
-func (w *DBWrapper) ReadRelayByActorURI(actorURI string) (error, *domain.Relay) {
+func (w *DBWrapper) ReadRelayByActorURI(actorURI string) (*domain.Relay, error) {
 	return w.db.ReadRelayByActorURI(actorURI)
 }
 
+func (w *DBWrapper) ReadRelayByFollowURI(followURI string) (*domain.Relay, error) {
+	return w.db.ReadRelayByFollowURI(followURI)
+}
+
+func (w *DBWrapper) ReadPendingRelays() (*[]domain.Relay, error) {
+	return w.db.ReadPendingRelays()
+}
+
+func (w *DBWrapper) UpdateRelayFollowAttempt(id uuid.UUID, followURI string, attempts int, sentAt time.Time) error {
+	return w.db.UpdateRelayFollowAttempt(id, followURI, attempts, sentAt)
+}
+
 func (w *DBWrapper) UpdateRelayStatus(id uuid.UUID, status string, acceptedAt *time.Time) error {
 	return w.db.UpdateRelayStatus(id, status, acceptedAt)
 }
 
+func (w *DBWrapper) UpdateRelayScheduledPause(id uuid.UUID, paused bool, state string) error {
+	return w.db.UpdateRelayScheduledPause(id, paused, state)
+}
+
+func (w *DBWrapper) RecordRelayActivity(id uuid.UUID, at time.Time) error {
+	return w.db.RecordRelayActivity(id, at)
+}
+
 func (w *DBWrapper) DeleteRelay(id uuid.UUID) error {
 	return w.db.DeleteRelay(id)
 }
@@ -228,5 +320,13 @@ func (w *DBWrapper) CreateNotification(notification *domain.Notification) error
 	return w.db.CreateNotification(notification)
 }
 
+// Transactions
+
+func (w *DBWrapper) WithTx(fn func(tx Tx) error) error {
+	return w.db.WithTx(func(tx *db.DB) error {
+		return fn(&DBWrapper{db: tx})
+	})
+}
+
 // Ensure DBWrapper implements Database interface
 var _ Database = (*DBWrapper)(nil)
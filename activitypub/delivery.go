@@ -1,24 +1,42 @@
 package activitypub
 
 import (
-	"bytes"
-	"crypto/sha256"
-	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/deemkeen/stegodon/domain"
 	"github.com/deemkeen/stegodon/util"
 )
 
+const (
+	// maxAuthFailureAttempts is how often a delivery rejected with 401/403 is tried before dead-lettering
+	maxAuthFailureAttempts = 3
+	// maxRetryAfter caps how far a remote's Retry-After can push back a delivery
+	maxRetryAfter = 24 * time.Hour
+)
+
 // DeliveryDeps holds dependencies for delivery operations
 type DeliveryDeps struct {
 	Database   Database
 	HTTPClient HTTPClient
+	Logger     *slog.Logger // nil uses slog.Default()
+}
+
+// logger returns the leveled logger for delivery processing
+func (deps *DeliveryDeps) logger() *slog.Logger {
+	if deps.Logger != nil {
+		return deps.Logger
+	}
+	return slog.Default()
 }
 
 // StartDeliveryWorker starts a background worker that processes the delivery queue.
@@ -61,11 +79,12 @@ func processDeliveryQueue(conf *util.AppConfig) {
 // This version accepts dependencies for testing.
 func processDeliveryQueueWithDeps(conf *util.AppConfig, deps *DeliveryDeps) {
 	database := deps.Database
+	logger := deps.logger().With("component", "delivery")
 
 	// Get pending deliveries (max 50 at a time)
-	err, items := database.ReadPendingDeliveries(50)
+	items, err := database.ReadPendingDeliveries(50)
 	if err != nil {
-		log.Printf("DeliveryWorker: Failed to read queue: %v", err)
+		logger.Error("DeliveryWorker: Failed to read queue", "error", err)
 		return
 	}
 
@@ -73,32 +92,163 @@ func processDeliveryQueueWithDeps(conf *util.AppConfig, deps *DeliveryDeps) {
 		return
 	}
 
-	log.Printf("DeliveryWorker: Processing %d pending deliveries", len(*items))
+	logger.Info(fmt.Sprintf("DeliveryWorker: Processing %d pending deliveries", len(*items)), "count", len(*items))
 
-	for _, item := range *items {
-		if err := deliverActivityWithDeps(&item, conf, deps); err != nil {
-			// Failed delivery - retry with exponential backoff
-			item.Attempts++
-			backoffMinutes := []int{1, 5, 15, 60, 240, 1440}[min(item.Attempts-1, 5)]
-			item.NextRetryAt = time.Now().Add(time.Duration(backoffMinutes) * time.Minute)
-
-			if item.Attempts >= 10 {
-				// Give up after 10 attempts
-				log.Printf("DeliveryWorker: Giving up on delivery to %s after %d attempts", item.InboxURI, item.Attempts)
-				database.DeleteDelivery(item.Id)
-			} else {
-				log.Printf("DeliveryWorker: Delivery to %s failed (attempt %d), retry in %dm: %v",
-					item.InboxURI, item.Attempts, backoffMinutes, err)
-				database.UpdateDeliveryAttempt(item.Id, item.Attempts, item.NextRetryAt)
+	// Each server's deliveries are sent in queue order by a single worker, so no server gets
+	// more than one request at a time and e.g. a Create arrives before its Delete
+	groups := groupDeliveriesByHost(*items)
+	work := make(chan deliveryGroup)
+	var wg sync.WaitGroup
+	for range min(conf.DeliveryConcurrency(), len(groups)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for group := range work {
+				deliverGroupWithDeps(group, conf, deps, logger)
 			}
+		}()
+	}
+	for _, group := range groups {
+		work <- group
+	}
+	close(work)
+	wg.Wait()
+}
+
+// deliveryGroup is the pending deliveries to one server, in queue order
+type deliveryGroup struct {
+	host  string
+	items []domain.DeliveryQueueItem
+}
+
+// groupDeliveriesByHost splits deliveries by the host of their inbox, keeping the queue order
+// within each host and ordering the groups by their first delivery
+func groupDeliveriesByHost(items []domain.DeliveryQueueItem) []deliveryGroup {
+	var groups []deliveryGroup
+	index := make(map[string]int)
+	for _, item := range items {
+		host := item.InboxURI
+		if u, err := url.Parse(item.InboxURI); err == nil && u.Host != "" {
+			host = strings.ToLower(u.Host)
+		}
+		i, ok := index[host]
+		if !ok {
+			i = len(groups)
+			index[host] = i
+			groups = append(groups, deliveryGroup{host: host})
+		}
+		groups[i].items = append(groups[i].items, item)
+	}
+	return groups
+}
+
+// deliverGroupWithDeps sends the deliveries to one server one after another, holding the
+// server's lock so that overlapping runs of the worker don't send to it concurrently either
+func deliverGroupWithDeps(group deliveryGroup, conf *util.AppConfig, deps *DeliveryDeps, logger *slog.Logger) {
+	unlock := deliveryHosts.Lock(group.host)
+	defer unlock()
+
+	database := deps.Database
+	for _, item := range group.items {
+		itemLogger := logger.With("inbox", item.InboxURI, "delivery", item.Id.String())
+		if err := deliverActivityWithDeps(&item, conf, deps); err != nil {
+			handleFailedDelivery(&item, err, database, itemLogger)
 		} else {
 			// Successful delivery - remove from queue
-			log.Printf("DeliveryWorker: Successfully delivered to %s", item.InboxURI)
+			itemLogger.Info("DeliveryWorker: Successfully delivered to "+item.InboxURI, "attempts", item.Attempts+1)
 			database.DeleteDelivery(item.Id)
 		}
 	}
 }
 
+// deliveryHosts serializes deliveries to the same server
+var deliveryHosts = newKeyedMutex()
+
+// keyedMutex is a set of mutexes by key. A key's mutex exists while it is locked or waited for.
+type keyedMutex struct {
+	mu    sync.Mutex
+	locks map[string]*keyedLock
+}
+
+type keyedLock struct {
+	sync.Mutex
+	holders int // Goroutines holding or waiting for the lock
+}
+
+func newKeyedMutex() *keyedMutex {
+	return &keyedMutex{locks: make(map[string]*keyedLock)}
+}
+
+// Lock locks the mutex of key and returns the function that unlocks it
+func (k *keyedMutex) Lock(key string) func() {
+	k.mu.Lock()
+	lock, ok := k.locks[key]
+	if !ok {
+		lock = &keyedLock{}
+		k.locks[key] = lock
+	}
+	lock.holders++
+	k.mu.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+		k.mu.Lock()
+		lock.holders--
+		if lock.holders == 0 {
+			delete(k.locks, key)
+		}
+		k.mu.Unlock()
+	}
+}
+
+// handleFailedDelivery decides what happens to a failed delivery based on the remote's answer:
+// 404/410 drop it, 401/403 are retried a few times and then dead-lettered, 429/503 honour
+// Retry-After, and everything else uses exponential backoff.
+func handleFailedDelivery(item *domain.DeliveryQueueItem, err error, database Database, logger *slog.Logger) {
+	status := 0
+	var retryAfter time.Duration
+	var statusErr *deliveryStatusError
+	if errors.As(err, &statusErr) {
+		status = statusErr.StatusCode
+		retryAfter = statusErr.RetryAfter
+	}
+	item.Attempts++
+	item.LastStatus = status
+
+	switch status {
+	case http.StatusNotFound, http.StatusGone:
+		// The inbox is gone; retrying will not help
+		logger.Warn("DeliveryWorker: Dropping delivery to "+item.InboxURI, "error", err, "status", status, "attempts", item.Attempts)
+		database.DeleteDelivery(item.Id)
+		return
+	case http.StatusUnauthorized, http.StatusForbidden:
+		// Usually a signature or clock problem; retry a few times in case it clears up
+		if item.Attempts >= maxAuthFailureAttempts {
+			logger.Error(fmt.Sprintf("DeliveryWorker: Dead-lettering delivery to %s after %d attempts", item.InboxURI, item.Attempts), "error", err, "status", status, "attempts", item.Attempts)
+			database.DeadLetterDelivery(item.Id, item.Attempts, status)
+			return
+		}
+	}
+
+	if item.Attempts >= 10 {
+		// Give up after 10 attempts
+		logger.Error(fmt.Sprintf("DeliveryWorker: Giving up on delivery to %s after %d attempts", item.InboxURI, item.Attempts), "error", err, "status", status, "attempts", item.Attempts)
+		database.DeleteDelivery(item.Id)
+		return
+	}
+
+	delay := time.Duration([]int{1, 5, 15, 60, 240, 1440}[min(item.Attempts-1, 5)]) * time.Minute
+	if retryAfter > 0 && (status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable) {
+		delay = retryAfter
+	}
+	item.NextRetryAt = time.Now().Add(delay)
+
+	logger.Warn(fmt.Sprintf("DeliveryWorker: Delivery to %s failed (attempt %d), retry in %s", item.InboxURI, item.Attempts, delay),
+		"error", err, "status", status, "attempts", item.Attempts, "retry_in", delay.String())
+	database.UpdateDeliveryAttempt(item.Id, item.Attempts, item.NextRetryAt, status)
+}
+
 // deliverActivity attempts to deliver a single activity to an inbox.
 // This is the production wrapper that uses the default database and HTTP client.
 func deliverActivity(item *domain.DeliveryQueueItem, conf *util.AppConfig) error {
@@ -134,7 +284,7 @@ func deliverActivityWithDeps(item *domain.DeliveryQueueItem, conf *util.AppConfi
 
 	// Get local account
 	database := deps.Database
-	err, localAccount := database.ReadAccByUsername(username)
+	localAccount, err := database.ReadAccByUsername(username)
 	if err != nil {
 		return fmt.Errorf("failed to get local account: %w", err)
 	}
@@ -145,28 +295,11 @@ func deliverActivityWithDeps(item *domain.DeliveryQueueItem, conf *util.AppConfi
 		return fmt.Errorf("failed to parse private key: %w", err)
 	}
 
-	// Calculate digest for HTTP signature
-	activityBytes := []byte(item.ActivityJSON)
-	hash := sha256.Sum256(activityBytes)
-	digest := "SHA-256=" + base64.StdEncoding.EncodeToString(hash[:])
-
-	// Create HTTP request
-	req, err := http.NewRequest("POST", item.InboxURI, bytes.NewReader(activityBytes))
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/activity+json")
-	req.Header.Set("Accept", "application/activity+json")
-	req.Header.Set("User-Agent", "stegodon/1.0 ActivityPub")
-	req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
-	req.Header.Set("Host", req.URL.Host)
-	req.Header.Set("Digest", digest)
-
-	// Sign request
+	// Create the signed request
 	keyID := fmt.Sprintf("https://%s/users/%s#main-key", conf.Conf.SslDomain, username)
-	if err := SignRequest(req, privateKey, keyID); err != nil {
-		return fmt.Errorf("failed to sign request: %w", err)
+	req, err := NewSignedActivityRequest(item.InboxURI, []byte(item.ActivityJSON), privateKey, keyID)
+	if err != nil {
+		return err
 	}
 
 	// Send request
@@ -178,12 +311,49 @@ func deliverActivityWithDeps(item *domain.DeliveryQueueItem, conf *util.AppConfi
 	defer resp.Body.Close()
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return fmt.Errorf("remote server returned status: %d", resp.StatusCode)
+		return &deliveryStatusError{
+			StatusCode: resp.StatusCode,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		}
 	}
 
 	return nil
 }
 
+// deliveryStatusError is returned when a remote inbox answers with a non-2xx status
+type deliveryStatusError struct {
+	StatusCode int
+	RetryAfter time.Duration // From the Retry-After header, 0 if absent or invalid
+}
+
+func (e *deliveryStatusError) Error() string {
+	return fmt.Sprintf("remote server returned status: %d", e.StatusCode)
+}
+
+// parseRetryAfter parses a Retry-After header given either as delay-seconds or as an HTTP date.
+// Returns 0 for missing or invalid values; the delay is capped at maxRetryAfter.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+
+	var delay time.Duration
+	if seconds, err := strconv.Atoi(value); err == nil {
+		delay = time.Duration(seconds) * time.Second
+	} else if date, err := http.ParseTime(value); err == nil {
+		delay = date.Sub(now)
+	}
+
+	if delay <= 0 {
+		return 0
+	}
+	if delay > maxRetryAfter {
+		return maxRetryAfter
+	}
+	return delay
+}
+
 // min returns the minimum of two integers
 func min(a, b int) int {
 	if a < b {
@@ -1,8 +1,18 @@
 package activitypub
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -94,6 +104,60 @@ func TestDeliverActivityWithDeps_Success(t *testing.T) {
 	}
 }
 
+// TestDeliverActivityWithDeps_SignatureRoundTrip tests that a delivery passes our own
+// verification with the body digest and content type covered by the signature
+func TestDeliverActivityWithDeps_SignatureRoundTrip(t *testing.T) {
+	mockDB := NewMockDatabase()
+	mockHTTP := NewMockHTTPClient()
+	keypair, _ := GenerateTestKeyPair()
+	mockDB.AddAccount(&domain.Account{
+		Id:            uuid.New(),
+		Username:      "alice",
+		WebPrivateKey: keypair.PrivatePEM,
+		WebPublicKey:  keypair.PublicPEM,
+	})
+	mockHTTP.SetResponse("https://remote.example.com/inbox", 202, []byte(""))
+
+	conf := &util.AppConfig{}
+	conf.Conf.SslDomain = "local.example.com"
+
+	activityJSON := `{"id":"https://local.example.com/activities/1","type":"Create","actor":"https://local.example.com/users/alice"}`
+	item := &domain.DeliveryQueueItem{Id: uuid.New(), InboxURI: "https://remote.example.com/inbox", ActivityJSON: activityJSON}
+	if err := deliverActivityWithDeps(item, conf, &DeliveryDeps{Database: mockDB, HTTPClient: mockHTTP}); err != nil {
+		t.Fatalf("Delivery failed: %v", err)
+	}
+	req := mockHTTP.Requests[len(mockHTTP.Requests)-1]
+
+	actorURI, err := VerifyRequest(req, keypair.PublicPEM)
+	if err != nil {
+		t.Fatalf("Expected the delivery to pass VerifyRequest, got %v", err)
+	}
+	if actorURI != "https://local.example.com/users/alice" {
+		t.Errorf("Expected the signing actor alice, got %s", actorURI)
+	}
+
+	params, err := parseSignatureParams(req.Header.Get("Signature"))
+	if err != nil {
+		t.Fatalf("Failed to parse the Signature header: %v", err)
+	}
+	for _, header := range []string{"(request-target)", "host", "date", "digest", "content-type"} {
+		if !slices.Contains(params.Headers, header) {
+			t.Errorf("Expected %s to be signed, got headers %v", header, params.Headers)
+		}
+	}
+
+	hash := sha256.Sum256([]byte(activityJSON))
+	if digest := "SHA-256=" + base64.StdEncoding.EncodeToString(hash[:]); req.Header.Get("Digest") != digest {
+		t.Errorf("Expected Digest %s, got %s", digest, req.Header.Get("Digest"))
+	}
+
+	// A receiver must notice a swapped content type
+	req.Header.Set("Content-Type", "application/json")
+	if _, err := VerifyRequest(req, keypair.PublicPEM); err == nil {
+		t.Error("Expected verification to fail after the Content-Type changed")
+	}
+}
+
 // TestDeliverActivityWithDeps_InvalidJSON tests delivery with invalid JSON
 func TestDeliverActivityWithDeps_InvalidJSON(t *testing.T) {
 	mockDB := NewMockDatabase()
@@ -531,3 +595,424 @@ func TestMin(t *testing.T) {
 		}
 	}
 }
+
+// runFailedDelivery queues one delivery (with the given prior attempts), lets the remote answer
+// with status and Retry-After, processes the queue and returns the item left in the queue (nil if dropped)
+func runFailedDelivery(t *testing.T, status int, retryAfter string, attempts int) *domain.DeliveryQueueItem {
+	t.Helper()
+	mockDB := NewMockDatabase()
+	mockHTTP := NewMockHTTPClient()
+
+	keypair, _ := GenerateTestKeyPair()
+	mockDB.AddAccount(&domain.Account{
+		Id:            uuid.New(),
+		Username:      "alice",
+		WebPrivateKey: keypair.PrivatePEM,
+		WebPublicKey:  keypair.PublicPEM,
+	})
+
+	inboxURI := "https://remote.example.com/inbox"
+	mockHTTP.SetResponse(inboxURI, status, []byte("Error"))
+	if retryAfter != "" {
+		mockHTTP.Responses[inboxURI].Header.Set("Retry-After", retryAfter)
+	}
+
+	conf := &util.AppConfig{}
+	conf.Conf.SslDomain = "local.example.com"
+
+	item := &domain.DeliveryQueueItem{
+		Id:           uuid.New(),
+		InboxURI:     inboxURI,
+		ActivityJSON: `{"id": "https://local.example.com/activities/123", "type": "Create", "actor": "https://local.example.com/users/alice"}`,
+		Attempts:     attempts,
+		NextRetryAt:  time.Now().Add(-1 * time.Minute),
+		CreatedAt:    time.Now(),
+	}
+	mockDB.AddDeliveryQueueItem(item)
+
+	processDeliveryQueueWithDeps(conf, &DeliveryDeps{Database: mockDB, HTTPClient: mockHTTP})
+
+	return mockDB.DeliveryQueue[item.Id]
+}
+
+// TestProcessDeliveryQueueWithDeps_RetryAfter tests that 429/503 honour Retry-After instead of the backoff
+func TestProcessDeliveryQueueWithDeps_RetryAfter(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		retryAfter string
+		wantDelay  time.Duration
+	}{
+		{"429 with seconds", http.StatusTooManyRequests, "3600", time.Hour},
+		{"503 with HTTP date", http.StatusServiceUnavailable, time.Now().Add(2 * time.Hour).UTC().Format(http.TimeFormat), 2 * time.Hour},
+		{"429 without header uses backoff", http.StatusTooManyRequests, "", time.Minute},
+		{"500 ignores Retry-After", http.StatusInternalServerError, "3600", time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item := runFailedDelivery(t, tt.status, tt.retryAfter, 0)
+			if item == nil {
+				t.Fatal("Expected delivery to stay queued")
+			}
+			if item.LastStatus != tt.status {
+				t.Errorf("Expected last status %d, got %d", tt.status, item.LastStatus)
+			}
+			delay := time.Until(item.NextRetryAt)
+			if delay < tt.wantDelay-5*time.Second || delay > tt.wantDelay+5*time.Second {
+				t.Errorf("Expected retry in about %v, got %v", tt.wantDelay, delay)
+			}
+		})
+	}
+}
+
+// TestProcessDeliveryQueueWithDeps_GoneDropsImmediately tests that 404/410 are not retried
+func TestProcessDeliveryQueueWithDeps_GoneDropsImmediately(t *testing.T) {
+	for _, status := range []int{http.StatusNotFound, http.StatusGone} {
+		if item := runFailedDelivery(t, status, "", 0); item != nil {
+			t.Errorf("Expected delivery answered with %d to be dropped, still queued with %d attempts", status, item.Attempts)
+		}
+	}
+}
+
+// TestProcessDeliveryQueueWithDeps_AuthFailureDeadLetters tests that 401/403 are retried a few times, then dead-lettered
+func TestProcessDeliveryQueueWithDeps_AuthFailureDeadLetters(t *testing.T) {
+	for _, status := range []int{http.StatusUnauthorized, http.StatusForbidden} {
+		item := runFailedDelivery(t, status, "", 0)
+		if item == nil || item.DeadLettered {
+			t.Fatalf("Expected first %d to be retried", status)
+		}
+
+		item = runFailedDelivery(t, status, "", maxAuthFailureAttempts-1)
+		if item == nil {
+			t.Fatalf("Expected %d delivery to be kept as dead letter, got dropped", status)
+		}
+		if !item.DeadLettered {
+			t.Errorf("Expected %d delivery to be dead-lettered after %d attempts", status, maxAuthFailureAttempts)
+		}
+		if item.LastStatus != status {
+			t.Errorf("Expected last status %d, got %d", status, item.LastStatus)
+		}
+	}
+}
+
+// TestParseRetryAfter tests both Retry-After formats and the cap
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"120", 2 * time.Minute},
+		{" 30 ", 30 * time.Second},
+		{"-5", 0},
+		{"soon", 0},
+		{now.Add(10 * time.Minute).Format(http.TimeFormat), 10 * time.Minute},
+		{now.Add(-10 * time.Minute).Format(http.TimeFormat), 0},
+		{"604800", maxRetryAfter},
+	}
+
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+// TestNewFederationHTTPClient_Defaults tests that an unset config keeps the built-in defaults
+func TestNewFederationHTTPClient_Defaults(t *testing.T) {
+	client := NewFederationHTTPClient(&util.AppConfig{})
+
+	if client.client.Timeout != defaultHTTPTimeout {
+		t.Errorf("Expected timeout %v, got %v", defaultHTTPTimeout, client.client.Timeout)
+	}
+	transport := client.client.Transport.(*http.Transport)
+	if transport.TLSHandshakeTimeout != defaultHTTPTLSHandshakeTimeout {
+		t.Errorf("Expected TLS handshake timeout %v, got %v", defaultHTTPTLSHandshakeTimeout, transport.TLSHandshakeTimeout)
+	}
+	if transport.MaxIdleConnsPerHost != defaultHTTPMaxIdleConnsPerHost {
+		t.Errorf("Expected %d idle conns per host, got %d", defaultHTTPMaxIdleConnsPerHost, transport.MaxIdleConnsPerHost)
+	}
+	if transport.DisableCompression {
+		t.Error("Expected compressed responses to be accepted by default")
+	}
+}
+
+// TestNewFederationHTTPClient_Configured tests that configured values are applied
+func TestNewFederationHTTPClient_Configured(t *testing.T) {
+	conf := &util.AppConfig{}
+	conf.Conf.HttpTimeout = 30
+	conf.Conf.HttpTLSHandshakeTimeout = 3
+	conf.Conf.HttpMaxIdleConnsPerHost = 16
+	conf.Conf.HttpDisableCompression = true
+
+	client := NewFederationHTTPClient(conf)
+
+	if client.client.Timeout != 30*time.Second {
+		t.Errorf("Expected timeout 30s, got %v", client.client.Timeout)
+	}
+	transport := client.client.Transport.(*http.Transport)
+	if transport.TLSHandshakeTimeout != 3*time.Second {
+		t.Errorf("Expected TLS handshake timeout 3s, got %v", transport.TLSHandshakeTimeout)
+	}
+	if transport.MaxIdleConnsPerHost != 16 {
+		t.Errorf("Expected 16 idle conns per host, got %d", transport.MaxIdleConnsPerHost)
+	}
+	if !transport.DisableCompression {
+		t.Error("Expected compression to be disabled")
+	}
+}
+
+// TestNewFederationHTTPClient_SlowInboxTimesOut tests that a slow remote inbox hits the request deadline
+func TestNewFederationHTTPClient_SlowInboxTimesOut(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	conf := &util.AppConfig{}
+	conf.Conf.HttpTimeout = 1
+	conf.Conf.AllowPrivateFetch = true
+	conf.Conf.AllowHttpFetch = true
+	client := NewFederationHTTPClient(conf)
+
+	req, _ := http.NewRequest("POST", server.URL+"/inbox", strings.NewReader("{}"))
+	start := time.Now()
+	if _, err := client.Do(req); err == nil {
+		t.Fatal("Expected request to a stalled inbox to time out")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected timeout after about 1s, took %v", elapsed)
+	}
+}
+
+// TestProcessDeliveryQueueWithDeps_StructuredLogFields tests that failed deliveries are logged with inbox, status and attempts fields
+func TestProcessDeliveryQueueWithDeps_StructuredLogFields(t *testing.T) {
+	mockDB := NewMockDatabase()
+	mockHTTP := NewMockHTTPClient()
+
+	keypair, _ := GenerateTestKeyPair()
+	mockDB.AddAccount(&domain.Account{
+		Id:            uuid.New(),
+		Username:      "alice",
+		WebPrivateKey: keypair.PrivatePEM,
+		WebPublicKey:  keypair.PublicPEM,
+	})
+
+	inboxURI := "https://remote.example.com/inbox"
+	mockHTTP.SetResponse(inboxURI, http.StatusInternalServerError, []byte("Error"))
+
+	conf := &util.AppConfig{}
+	conf.Conf.SslDomain = "local.example.com"
+
+	mockDB.AddDeliveryQueueItem(&domain.DeliveryQueueItem{
+		Id:           uuid.New(),
+		InboxURI:     inboxURI,
+		ActivityJSON: `{"id": "https://local.example.com/activities/123", "type": "Create", "actor": "https://local.example.com/users/alice"}`,
+		NextRetryAt:  time.Now().Add(-1 * time.Minute),
+		CreatedAt:    time.Now(),
+	})
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	processDeliveryQueueWithDeps(conf, &DeliveryDeps{Database: mockDB, HTTPClient: mockHTTP, Logger: logger})
+
+	var failure map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("Log line is not JSON: %s", line)
+		}
+		if entry["level"] == "WARN" {
+			failure = entry
+		}
+	}
+	if failure == nil {
+		t.Fatalf("Expected a WARN entry for the failed delivery, got: %s", buf.String())
+	}
+	if failure["inbox"] != inboxURI {
+		t.Errorf("Expected inbox field %s, got %v", inboxURI, failure["inbox"])
+	}
+	if failure["status"] != float64(http.StatusInternalServerError) {
+		t.Errorf("Expected status field 500, got %v", failure["status"])
+	}
+	if failure["attempts"] != float64(1) {
+		t.Errorf("Expected attempts field 1, got %v", failure["attempts"])
+	}
+}
+
+// slowInboxClient answers every delivery after a delay, recording the activity ids each host
+// received and the most requests in flight, overall and to one host
+type slowInboxClient struct {
+	mu          sync.Mutex
+	active      map[string]int
+	maxActive   int
+	maxPerHost  int
+	activityIDs map[string][]string
+}
+
+func (c *slowInboxClient) Do(req *http.Request) (*http.Response, error) {
+	var activity struct {
+		ID string `json:"id"`
+	}
+	json.NewDecoder(req.Body).Decode(&activity)
+
+	c.mu.Lock()
+	c.active[req.URL.Host]++
+	total := 0
+	for _, n := range c.active {
+		total += n
+	}
+	c.maxActive = max(c.maxActive, total)
+	c.maxPerHost = max(c.maxPerHost, c.active[req.URL.Host])
+	c.activityIDs[req.URL.Host] = append(c.activityIDs[req.URL.Host], activity.ID)
+	c.mu.Unlock()
+
+	time.Sleep(20 * time.Millisecond)
+
+	c.mu.Lock()
+	c.active[req.URL.Host]--
+	c.mu.Unlock()
+	return &http.Response{StatusCode: http.StatusAccepted, Body: http.NoBody}, nil
+}
+
+// TestProcessDeliveryQueueWithDeps_ConcurrentHosts tests that different servers are delivered
+// to concurrently while each server gets its deliveries one at a time and in queue order
+func TestProcessDeliveryQueueWithDeps_ConcurrentHosts(t *testing.T) {
+	mockDB := NewMockDatabase()
+	client := &slowInboxClient{active: make(map[string]int), activityIDs: make(map[string][]string)}
+
+	keypair, _ := GenerateTestKeyPair()
+	mockDB.AddAccount(&domain.Account{
+		Id:            uuid.New(),
+		Username:      "alice",
+		WebPrivateKey: keypair.PrivatePEM,
+		WebPublicKey:  keypair.PublicPEM,
+	})
+
+	hosts := []string{"a.example.com", "b.example.com", "c.example.com"}
+	created := time.Now().Add(-time.Hour)
+	for i := range 3 {
+		for _, host := range hosts {
+			created = created.Add(time.Second)
+			mockDB.AddDeliveryQueueItem(&domain.DeliveryQueueItem{
+				Id:           uuid.New(),
+				InboxURI:     "https://" + host + "/inbox",
+				ActivityJSON: fmt.Sprintf(`{"id": "https://local.example.com/activities/%d", "type": "Create", "actor": "https://local.example.com/users/alice"}`, i),
+				NextRetryAt:  time.Now().Add(-time.Minute),
+				CreatedAt:    created,
+			})
+		}
+	}
+
+	conf := &util.AppConfig{}
+	conf.Conf.SslDomain = "local.example.com"
+	conf.Conf.DeliveryConcurrency = 3
+	processDeliveryQueueWithDeps(conf, &DeliveryDeps{Database: mockDB, HTTPClient: client})
+
+	if len(mockDB.DeliveryQueue) != 0 {
+		t.Errorf("Expected all deliveries to succeed, %d left", len(mockDB.DeliveryQueue))
+	}
+	if client.maxPerHost != 1 {
+		t.Errorf("Expected one request at a time per server, got up to %d", client.maxPerHost)
+	}
+	if client.maxActive < 2 {
+		t.Errorf("Expected servers to be delivered to concurrently, got up to %d requests at once", client.maxActive)
+	}
+	for _, host := range hosts {
+		want := []string{"https://local.example.com/activities/0", "https://local.example.com/activities/1", "https://local.example.com/activities/2"}
+		if !slices.Equal(client.activityIDs[host], want) {
+			t.Errorf("Expected %s to get the activities in queue order, got %v", host, client.activityIDs[host])
+		}
+	}
+}
+
+func TestKeyedMutex(t *testing.T) {
+	locks := newKeyedMutex()
+	unlockA := locks.Lock("a")
+
+	// Another key isn't blocked
+	locks.Lock("b")()
+
+	locked := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		unlock := locks.Lock("a")
+		close(locked)
+		unlock()
+		close(done)
+	}()
+	select {
+	case <-locked:
+		t.Fatal("Expected the second Lock of a key to wait")
+	case <-time.After(20 * time.Millisecond):
+	}
+	unlockA()
+	<-done
+
+	// Unlocked keys are forgotten
+	locks.mu.Lock()
+	defer locks.mu.Unlock()
+	if len(locks.locks) != 0 {
+		t.Errorf("Expected no locks to be kept, got %d", len(locks.locks))
+	}
+}
+
+// TestProcessDeliveryQueueWithDeps_DeleteWaitsForCreate tests that a Delete queued after its
+// Create is only delivered once the Create went through, even if the Create has to be retried
+func TestProcessDeliveryQueueWithDeps_DeleteWaitsForCreate(t *testing.T) {
+	mockDB := NewMockDatabase()
+	mockHTTP := NewMockHTTPClient()
+
+	keypair, _ := GenerateTestKeyPair()
+	mockDB.AddAccount(&domain.Account{
+		Id:            uuid.New(),
+		Username:      "alice",
+		WebPrivateKey: keypair.PrivatePEM,
+		WebPublicKey:  keypair.PublicPEM,
+	})
+	conf := &util.AppConfig{}
+	conf.Conf.SslDomain = "local.example.com"
+	deps := &DeliveryDeps{Database: mockDB, HTTPClient: mockHTTP}
+
+	inboxURI := "https://remote.example.com/inbox"
+	noteURI := "https://local.example.com/notes/1"
+	inboxes := map[string]bool{inboxURI: true}
+	create := `{"id": "https://local.example.com/activities/create", "type": "Create", "actor": "https://local.example.com/users/alice", "object": {"id": "` + noteURI + `", "type": "Note"}}`
+	del := `{"id": "https://local.example.com/activities/delete", "type": "Delete", "actor": "https://local.example.com/users/alice", "object": {"id": "` + noteURI + `", "type": "Tombstone"}}`
+	if _, err := enqueueDeliveries(inboxes, create, mockDB); err != nil {
+		t.Fatalf("enqueueDeliveries failed: %v", err)
+	}
+	if _, err := enqueueDeliveries(inboxes, del, mockDB); err != nil {
+		t.Fatalf("enqueueDeliveries failed: %v", err)
+	}
+
+	// The Create fails and is rescheduled; the Delete must not overtake it
+	mockHTTP.SetResponse(inboxURI, http.StatusInternalServerError, []byte("Error"))
+	processDeliveryQueueWithDeps(conf, deps)
+	for _, item := range mockDB.DeliveryQueue {
+		item.NextRetryAt = time.Now().Add(-time.Second)
+	}
+	mockHTTP.SetResponse(inboxURI, http.StatusAccepted, nil)
+	processDeliveryQueueWithDeps(conf, deps)
+	processDeliveryQueueWithDeps(conf, deps)
+
+	var delivered []string
+	for _, req := range mockHTTP.Requests {
+		var activity struct {
+			Type string `json:"type"`
+		}
+		body, _ := req.GetBody()
+		json.NewDecoder(body).Decode(&activity)
+		delivered = append(delivered, activity.Type)
+	}
+	if want := []string{"Create", "Create", "Delete"}; !slices.Equal(delivered, want) {
+		t.Errorf("Expected deliveries %v, got %v", want, delivered)
+	}
+	if len(mockDB.DeliveryQueue) != 0 {
+		t.Errorf("Expected the queue to be empty, %d left", len(mockDB.DeliveryQueue))
+	}
+}
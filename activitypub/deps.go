@@ -1,10 +1,12 @@
 package activitypub
 
 import (
+	"net"
 	"net/http"
 	"time"
 
 	"github.com/deemkeen/stegodon/domain"
+	"github.com/deemkeen/stegodon/util"
 	"github.com/google/uuid"
 )
 
@@ -12,39 +14,49 @@ import (
 // This interface allows for dependency injection and testing with mock implementations.
 type Database interface {
 	// Account operations
-	ReadAccByUsername(username string) (error, *domain.Account)
-	ReadAccById(id uuid.UUID) (error, *domain.Account)
+	ReadAccByUsername(username string) (*domain.Account, error)
+	ReadAccById(id uuid.UUID) (*domain.Account, error)
+	ReadAllAccounts() (*[]domain.Account, error)
 
 	// Remote account operations
-	ReadRemoteAccountByURI(uri string) (error, *domain.RemoteAccount)
-	ReadRemoteAccountById(id uuid.UUID) (error, *domain.RemoteAccount)
-	ReadRemoteAccountByActorURI(actorURI string) (error, *domain.RemoteAccount)
+	ReadRemoteAccountByURI(uri string) (*domain.RemoteAccount, error)
+	ReadRemoteAccountById(id uuid.UUID) (*domain.RemoteAccount, error)
+	ReadRemoteAccountByActorURI(actorURI string) (*domain.RemoteAccount, error)
+	ReadRemoteAccountByUsernameAndDomain(username, domainName string) (*domain.RemoteAccount, error)
 	CreateRemoteAccount(acc *domain.RemoteAccount) error
 	UpdateRemoteAccount(acc *domain.RemoteAccount) error
 	DeleteRemoteAccount(id uuid.UUID) error
 
 	// Follow operations
 	CreateFollow(follow *domain.Follow) error
-	ReadFollowByURI(uri string) (error, *domain.Follow)
-	ReadFollowByAccountIds(accountId, targetAccountId uuid.UUID) (error, *domain.Follow)
+	ReadFollowByURI(uri string) (*domain.Follow, error)
+	ReadFollowByAccountIds(accountId, targetAccountId uuid.UUID) (*domain.Follow, error)
+	IsRemoteAccountFollowed(remoteAccountId uuid.UUID) (bool, error)
+	ReadFollowingAddresses(accountId uuid.UUID, localDomain string) ([]string, error)
 	DeleteFollowByURI(uri string) error
 	AcceptFollowByURI(uri string) error
-	ReadFollowersByAccountId(accountId uuid.UUID) (error, *[]domain.Follow)
+	ReadFollowersByAccountId(accountId uuid.UUID) (*[]domain.Follow, error)
+	ReadFollowerInboxURIs(accountId uuid.UUID) ([]string, error)
 	DeleteFollowsByRemoteAccountId(remoteAccountId uuid.UUID) error
 
 	// Activity operations
 	CreateActivity(activity *domain.Activity) error
 	UpdateActivity(activity *domain.Activity) error
-	ReadActivityByURI(uri string) (error, *domain.Activity)
-	ReadActivityByObjectURI(objectURI string) (error, *domain.Activity)
+	ReadActivityByURI(uri string) (*domain.Activity, error)
+	ReadActivityByObjectURI(objectURI string) (*domain.Activity, error)
 	DeleteActivity(id uuid.UUID) error
+	UpdateActivityQuote(activityId uuid.UUID, quoteURI, quoteAuthor, quoteContent string) error
 
 	// Note operations (for replies)
-	ReadNoteByURI(objectURI string) (error, *domain.Note)
+	ReadNoteByURI(objectURI string) (*domain.Note, error)
+	UpdateNoteObjectJSON(noteId uuid.UUID, objectJSON string) error
 
 	// Mention operations
 	CreateNoteMention(mention *domain.NoteMention) error
 
+	// Hashtag operations
+	LinkActivityHashtags(activityId uuid.UUID, names []string) error
+
 	// Engagement count operations
 	IncrementReplyCountByURI(parentURI string) error
 
@@ -52,7 +64,7 @@ type Database interface {
 	CreateLike(like *domain.Like) error
 	HasLikeByURI(uri string) (bool, error)
 	HasLike(accountId, noteId uuid.UUID) (bool, error)
-	ReadLikeByAccountAndNote(accountId, noteId uuid.UUID) (error, *domain.Like)
+	ReadLikeByAccountAndNote(accountId, noteId uuid.UUID) (*domain.Like, error)
 	DeleteLikeByAccountAndNote(accountId, noteId uuid.UUID) error
 	IncrementLikeCountByNoteId(noteId uuid.UUID) error
 	DecrementLikeCountByNoteId(noteId uuid.UUID) error
@@ -64,43 +76,156 @@ type Database interface {
 	IncrementBoostCountByNoteId(noteId uuid.UUID) error
 	DecrementBoostCountByNoteId(noteId uuid.UUID) error
 
+	// Reaction operations
+	CreateReaction(reaction *domain.Reaction) error
+	DeleteReaction(actorURI, objectURI, emoji string) error
+	DeleteReactionByURI(actorURI, uri string) error
+
 	// Delivery queue operations
 	EnqueueDelivery(item *domain.DeliveryQueueItem) error
-	ReadPendingDeliveries(limit int) (error, *[]domain.DeliveryQueueItem)
-	UpdateDeliveryAttempt(id uuid.UUID, attempts int, nextRetry time.Time) error
+	EnqueueDeliveryBatch(items []*domain.DeliveryQueueItem) error
+	ReadPendingDeliveries(limit int) (*[]domain.DeliveryQueueItem, error)
+	UpdateDeliveryAttempt(id uuid.UUID, attempts int, nextRetry time.Time, lastStatus int) error
+	DeadLetterDelivery(id uuid.UUID, attempts int, lastStatus int) error
 	DeleteDelivery(id uuid.UUID) error
 
+	// Inbox queue operations
+	EnqueueInboxActivity(item *domain.InboxQueueItem) error
+	ReadPendingInboxActivities(limit int) (*[]domain.InboxQueueItem, error)
+	UpdateInboxActivityAttempt(id uuid.UUID, attempts int, nextAttempt time.Time) error
+	DeleteInboxActivity(id uuid.UUID) error
+
 	// Relay operations
 	CreateRelay(relay *domain.Relay) error
-	ReadActiveRelays() (error, *[]domain.Relay)
-	ReadActiveUnpausedRelays() (error, *[]domain.Relay)
-	ReadRelayByActorURI(actorURI string) (error, *domain.Relay)
+	ReadActiveRelays() (*[]domain.Relay, error)
+	ReadActiveUnpausedRelays() (*[]domain.Relay, error)
+	ReadRelayByActorURI(actorURI string) (*domain.Relay, error)
+	ReadRelayByFollowURI(followURI string) (*domain.Relay, error)
+	ReadPendingRelays() (*[]domain.Relay, error)
+	UpdateRelayFollowAttempt(id uuid.UUID, followURI string, attempts int, sentAt time.Time) error
 	UpdateRelayStatus(id uuid.UUID, status string, acceptedAt *time.Time) error
+	UpdateRelayScheduledPause(id uuid.UUID, paused bool, state string) error
+	RecordRelayActivity(id uuid.UUID, at time.Time) error
 	DeleteRelay(id uuid.UUID) error
 
 	// Notification operations
 	CreateNotification(notification *domain.Notification) error
+
+	// Transactions
+	WithTx(fn func(tx Tx) error) error
 }
 
+// Tx is the Database handed to a WithTx callback. All of its operations run in one
+// transaction that commits when the callback returns nil and rolls back otherwise.
+type Tx = Database
+
 // HTTPClient defines the HTTP client operations required by the ActivityPub package.
 // This interface allows for dependency injection and testing with mock implementations.
 type HTTPClient interface {
 	Do(req *http.Request) (*http.Response, error)
 }
 
-// DefaultHTTPClient is the default HTTP client used in production
+// DefaultHTTPClient is the default HTTP client used in production.
+// Every request is checked against its FetchPolicy.
 type DefaultHTTPClient struct {
 	client *http.Client
+	policy FetchPolicy
 }
 
+// Defaults for the federation HTTP client, used when the config leaves a value at 0
+const (
+	defaultHTTPTimeout             = 10 * time.Second
+	defaultHTTPDialTimeout         = 5 * time.Second
+	defaultHTTPTLSHandshakeTimeout = 5 * time.Second
+	defaultHTTPMaxIdleConnsPerHost = 4
+)
+
 // NewDefaultHTTPClient creates a new default HTTP client with the specified timeout
+// and the strict default fetch policy
 func NewDefaultHTTPClient(timeout time.Duration) *DefaultHTTPClient {
+	return newPolicyHTTPClient(timeout, http.DefaultTransport.(*http.Transport).Clone(), &net.Dialer{
+		Timeout:   defaultHTTPDialTimeout,
+		KeepAlive: 30 * time.Second,
+	}, FetchPolicy{})
+}
+
+// NewFederationHTTPClient creates the HTTP client for federation requests from the config.
+// The overall timeout is a per-request deadline, so a slow remote inbox cannot stall a worker.
+func NewFederationHTTPClient(conf *util.AppConfig) *DefaultHTTPClient {
+	timeout := secondsOrDefault(conf.Conf.HttpTimeout, defaultHTTPTimeout)
+	dialTimeout := secondsOrDefault(conf.Conf.HttpDialTimeout, defaultHTTPDialTimeout)
+	tlsTimeout := secondsOrDefault(conf.Conf.HttpTLSHandshakeTimeout, defaultHTTPTLSHandshakeTimeout)
+	maxIdlePerHost := conf.Conf.HttpMaxIdleConnsPerHost
+	if maxIdlePerHost <= 0 {
+		maxIdlePerHost = defaultHTTPMaxIdleConnsPerHost
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSHandshakeTimeout = tlsTimeout
+	transport.MaxIdleConnsPerHost = maxIdlePerHost
+	// With compression on, the transport sends Accept-Encoding: gzip and decodes the response,
+	// so the fetch size limit applies to the decoded document
+	transport.DisableCompression = conf.Conf.HttpDisableCompression
+
+	return newPolicyHTTPClient(timeout, transport, &net.Dialer{
+		Timeout:   dialTimeout,
+		KeepAlive: 30 * time.Second,
+	}, NewFetchPolicy(conf))
+}
+
+// newPolicyHTTPClient wires the fetch policy into the dialer and the redirect handling
+func newPolicyHTTPClient(timeout time.Duration, transport *http.Transport, dialer *net.Dialer, policy FetchPolicy) *DefaultHTTPClient {
+	dialer.Control = policy.dialControl
+	transport.DialContext = dialer.DialContext
+
 	return &DefaultHTTPClient{
-		client: &http.Client{Timeout: timeout},
+		client: &http.Client{
+			Timeout:       timeout,
+			Transport:     transport,
+			CheckRedirect: policy.checkRedirect,
+		},
+		policy: policy,
+	}
+}
+
+// userAgent is sent with every outgoing federation request; ConfigureHTTPClient sets it from the config
+var userAgent = (*util.AppConfig)(nil).FederationUserAgent()
+
+// ConfigureHTTPClient replaces the default federation HTTP client with one built from the config
+// and applies the configured maximum size of fetched documents and User-Agent
+func ConfigureHTTPClient(conf *util.AppConfig) {
+	defaultHTTPClient = NewFederationHTTPClient(conf)
+	userAgent = conf.FederationUserAgent()
+	maxFetchBytes = defaultMaxFetchBytes
+	if conf.Conf.MaxFetchBytes > 0 {
+		maxFetchBytes = conf.Conf.MaxFetchBytes
+	}
+}
+
+// secondsOrDefault converts a configured number of seconds, falling back for values <= 0
+func secondsOrDefault(seconds int, fallback time.Duration) time.Duration {
+	if seconds <= 0 {
+		return fallback
 	}
+	return time.Duration(seconds) * time.Second
 }
 
-// Do executes the HTTP request
+// Do executes the HTTP request if the fetch policy allows its URL
 func (c *DefaultHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	if err := c.policy.CheckURL(req.URL); err != nil {
+		return nil, err
+	}
 	return c.client.Do(req)
 }
+
+// SetRequestHeaders sets the Accept header and our User-Agent on an outgoing federation request
+func SetRequestHeaders(req *http.Request, accept string) {
+	req.Header.Set("Accept", accept)
+	req.Header.Set("User-Agent", userAgent)
+}
+
+// FederationHTTPClient returns the HTTP client used for federation requests,
+// for packages that fetch remote resources outside of ActivityPub handling
+func FederationHTTPClient() HTTPClient {
+	return defaultHTTPClient
+}
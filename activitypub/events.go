@@ -0,0 +1,25 @@
+package activitypub
+
+import (
+	"github.com/deemkeen/stegodon/util"
+)
+
+// publishInboxEvent tells connected SSH sessions about a processed inbox activity, so their
+// views update without waiting for the next refresh. Notifications, such as for a Like,
+// publish their own event when they are stored.
+func publishInboxEvent(activityType, username string, database Database) {
+	bus := util.GetEventBus()
+
+	switch activityType {
+	case "Create", "Announce", "Update", "Delete":
+		// Posts reach the timelines of all followers of the author, and shared inbox deliveries
+		// are routed to a single account, so every session reloads
+		bus.Broadcast(util.EventNewPost)
+	case "Follow":
+		account, err := database.ReadAccByUsername(username)
+		if err != nil || account == nil {
+			return
+		}
+		bus.Publish(account.Id, util.EventFollow)
+	}
+}
@@ -0,0 +1,73 @@
+package activitypub
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/deemkeen/stegodon/domain"
+	"github.com/deemkeen/stegodon/util"
+	"github.com/google/uuid"
+)
+
+// TestHandleInboxWithDeps_PublishesEvents tests that processed activities notify the
+// sessions of the inbox owner, and that new posts reach every session
+func TestHandleInboxWithDeps_PublishesEvents(t *testing.T) {
+	mockDB, _, deps, localAccount, remoteActor, conf := setupFollowTest(t)
+	keypair, _ := GenerateTestKeyPair()
+	remoteActor.PublicKeyPem = keypair.PublicPEM
+	remoteActor.LastFetchedAt = time.Now()
+
+	bus := util.GetEventBus()
+	aliceEvents := bus.Subscribe(localAccount.Id)
+	otherId := uuid.New()
+	otherEvents := bus.Subscribe(otherId)
+	t.Cleanup(func() {
+		bus.Unsubscribe(localAccount.Id, aliceEvents)
+		bus.Unsubscribe(otherId, otherEvents)
+	})
+
+	next := func(ch <-chan util.Event) (util.Event, bool) {
+		select {
+		case event := <-ch:
+			return event, true
+		default:
+			return util.Event{}, false
+		}
+	}
+	deliver := func(body string) {
+		t.Helper()
+		req := createSignedRequest(t, "POST", "/users/alice/inbox", []byte(body), keypair, "https://remote.example.com/users/bob#main-key")
+		rr := httptest.NewRecorder()
+		HandleInboxWithDeps(rr, req, "alice", conf, deps)
+		if rr.Code != http.StatusAccepted {
+			t.Fatalf("Expected status 202, got %d", rr.Code)
+		}
+	}
+
+	deliver(`{"id": "https://remote.example.com/activities/follow-events", "type": "Follow", "actor": "https://remote.example.com/users/bob", "object": "https://local.example.com/users/alice"}`)
+	if event, ok := next(aliceEvents); !ok || event.Type != util.EventFollow || event.AccountId != localAccount.Id {
+		t.Errorf("Expected a follow event for alice, got %+v (received: %v)", event, ok)
+	}
+	if event, ok := next(otherEvents); ok {
+		t.Errorf("Expected no event for another account, got %+v", event)
+	}
+
+	// alice follows bob, so his posts are accepted
+	mockDB.AddFollow(&domain.Follow{Id: uuid.New(), AccountId: localAccount.Id, TargetAccountId: remoteActor.Id, Accepted: true})
+	deliver(`{"id": "https://remote.example.com/activities/create-events", "type": "Create", "actor": "https://remote.example.com/users/bob", "to": ["https://remote.example.com/users/bob/followers"],
+		"object": {"id": "https://remote.example.com/notes/events", "type": "Note", "attributedTo": "https://remote.example.com/users/bob", "content": "hello"}}`)
+	if event, ok := next(aliceEvents); !ok || event.Type != util.EventNewPost {
+		t.Errorf("Expected a new post event for alice, got %+v (received: %v)", event, ok)
+	}
+	if event, ok := next(otherEvents); !ok || event.Type != util.EventNewPost {
+		t.Errorf("Expected the new post to be broadcast, got %+v (received: %v)", event, ok)
+	}
+
+	// Unsubscribing closes the channel, as when an SSH session ends
+	bus.Unsubscribe(localAccount.Id, aliceEvents)
+	if _, ok := <-aliceEvents; ok {
+		t.Error("Expected the subscription to be closed")
+	}
+}
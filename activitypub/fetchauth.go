@@ -0,0 +1,59 @@
+package activitypub
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrMissingSignature is returned for a fetch without an HTTP signature
+	ErrMissingSignature = errors.New("missing signature")
+	// ErrUnsignedRequestTarget is returned for a signature that doesn't cover the requested path
+	ErrUnsignedRequestTarget = errors.New("signature does not cover (request-target)")
+)
+
+// HasSignature reports whether a request carries an HTTP signature
+func HasSignature(r *http.Request) bool {
+	return signatureHeader(r) != ""
+}
+
+// VerifyFetchSignature verifies the HTTP signature of a GET for one of our actors, objects or
+// collections, as secure mode requires. It returns the URI of the signing actor, which may be
+// a remote server's instance actor rather than a person.
+func VerifyFetchSignature(r *http.Request) (string, error) {
+	return VerifyFetchSignatureWithDeps(r, defaultHTTPClient, NewDBWrapper())
+}
+
+// VerifyFetchSignatureWithDeps is VerifyFetchSignature with dependencies for testing
+func VerifyFetchSignatureWithDeps(r *http.Request, client HTTPClient, database Database) (string, error) {
+	signature := signatureHeader(r)
+	if signature == "" {
+		return "", ErrMissingSignature
+	}
+	params, err := parseSignatureParams(signature)
+	if err != nil {
+		return "", err
+	}
+
+	// A signature that doesn't cover the path could be reused to fetch any other document
+	if !slices.Contains(params.Headers, "(request-target)") {
+		return "", ErrUnsignedRequestTarget
+	}
+	if err := checkRequestDate(r, params, clockSkew, time.Now()); err != nil {
+		return "", err
+	}
+
+	signerURI := strings.Split(params.KeyId, "#")[0]
+	signer, err := GetOrFetchActorWithDeps(signerURI, client, database)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch signer %s: %w", signerURI, err)
+	}
+	if _, err := VerifyRequestCached(r, signer.PublicKeyFor(params.KeyId)); err != nil {
+		return "", err
+	}
+	return signer.ActorURI, nil
+}
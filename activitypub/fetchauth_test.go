@@ -0,0 +1,87 @@
+package activitypub
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"code.superseriousbusiness.org/httpsig"
+	"github.com/deemkeen/stegodon/domain"
+	"github.com/google/uuid"
+)
+
+// createSignedFetch signs a GET of alice's outbox over the given headers
+func createSignedFetch(t *testing.T, keypair *TestKeyPair, keyID string, date time.Time, headers []string) *http.Request {
+	t.Helper()
+
+	req := httptest.NewRequest("GET", "/users/alice/outbox", nil)
+	req.Header.Set("Accept", "application/activity+json")
+	req.Header.Set("Date", date.UTC().Format(http.TimeFormat))
+	req.Header.Set("Host", req.Host)
+
+	signer, _, err := httpsig.NewSigner([]httpsig.Algorithm{httpsig.RSA_SHA256}, httpsig.DigestSha256, headers, httpsig.Signature, 0)
+	if err != nil {
+		t.Fatalf("Failed to create signer: %v", err)
+	}
+	if err := signer.SignRequest(keypair.PrivateKey, keyID, req, nil); err != nil {
+		t.Fatalf("Failed to sign request: %v", err)
+	}
+	return req
+}
+
+func TestVerifyFetchSignature(t *testing.T) {
+	keypair, _ := GenerateTestKeyPair()
+	otherKeypair, _ := GenerateTestKeyPair()
+	signerURI := "https://remote.example.com/actor"
+
+	mockDB := NewMockDatabase()
+	mockDB.AddRemoteAccount(&domain.RemoteAccount{
+		Id:            uuid.New(),
+		Username:      "remote.example.com",
+		Domain:        "remote.example.com",
+		ActorURI:      signerURI,
+		PublicKeyPem:  keypair.PublicPEM,
+		LastFetchedAt: time.Now(),
+	})
+	signedHeaders := []string{"(request-target)", "host", "date"}
+
+	tests := []struct {
+		name    string
+		req     *http.Request
+		wantErr error
+	}{
+		{"signed by the instance actor", createSignedFetch(t, keypair, signerURI+"#main-key", time.Now(), signedHeaders), nil},
+		{"unsigned", httptest.NewRequest("GET", "/users/alice/outbox", nil), ErrMissingSignature},
+		{"path not signed", createSignedFetch(t, keypair, signerURI+"#main-key", time.Now(), []string{"host", "date"}), ErrUnsignedRequestTarget},
+		{"stale date", createSignedFetch(t, keypair, signerURI+"#main-key", time.Now().Add(-2*time.Hour), signedHeaders), ErrStaleDate},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actorURI, err := VerifyFetchSignatureWithDeps(tt.req, NewMockHTTPClient(), mockDB)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Expected %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil || actorURI != signerURI {
+				t.Errorf("Expected signer %s, got %q, %v", signerURI, actorURI, err)
+			}
+		})
+	}
+
+	// A signature made with another key than the signer's is rejected
+	req := createSignedFetch(t, otherKeypair, signerURI+"#main-key", time.Now(), signedHeaders)
+	if _, err := VerifyFetchSignatureWithDeps(req, NewMockHTTPClient(), mockDB); err == nil {
+		t.Error("Expected a signature with the wrong key to be rejected")
+	}
+
+	// A request that was redirected to another path no longer matches its signature
+	req = createSignedFetch(t, keypair, signerURI+"#main-key", time.Now(), signedHeaders)
+	req.URL.Path = "/users/alice/followers"
+	if _, err := VerifyFetchSignatureWithDeps(req, NewMockHTTPClient(), mockDB); err == nil {
+		t.Error("Expected a signature for another path to be rejected")
+	}
+}
@@ -0,0 +1,147 @@
+package activitypub
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+
+	"github.com/deemkeen/stegodon/util"
+)
+
+const (
+	// maxFetchRedirects is how many redirects a federation request may follow
+	maxFetchRedirects = 5
+	// defaultMaxFetchBytes is the largest remote document read when the config leaves it at 0
+	defaultMaxFetchBytes = 1 * 1024 * 1024
+)
+
+var (
+	// ErrFetchBlocked is returned for federation requests the fetch policy does not allow
+	ErrFetchBlocked = errors.New("blocked by fetch policy")
+	// ErrFetchTooLarge is returned for remote documents larger than the configured maximum
+	ErrFetchTooLarge = errors.New("remote document too large")
+	// ErrUnexpectedContentType is returned for remote documents that are not JSON
+	ErrUnexpectedContentType = errors.New("unexpected content type")
+)
+
+// maxFetchBytes is the largest remote document ReadJSONResponse accepts
+var maxFetchBytes int64 = defaultMaxFetchBytes
+
+// FetchPolicy decides which URLs federation requests may go to. Remote servers hand us
+// arbitrary URLs (actor ids, inboxes, objects), so by default only https URLs on public
+// addresses are allowed; otherwise a crafted actor could make us query internal services.
+type FetchPolicy struct {
+	AllowPrivate bool // Allow loopback, private and link-local addresses (local federation testing)
+	AllowHTTP    bool // Allow plain http:// URLs
+}
+
+// NewFetchPolicy returns the fetch policy configured in conf
+func NewFetchPolicy(conf *util.AppConfig) FetchPolicy {
+	return FetchPolicy{
+		AllowPrivate: conf.Conf.AllowPrivateFetch,
+		AllowHTTP:    conf.Conf.AllowHttpFetch,
+	}
+}
+
+// CheckURL returns an error wrapping ErrFetchBlocked if u may not be requested.
+// Hostnames are only checked once they are resolved, when the connection is dialed.
+func (p FetchPolicy) CheckURL(u *url.URL) error {
+	switch u.Scheme {
+	case "https":
+	case "http":
+		if !p.AllowHTTP {
+			return fmt.Errorf("%w: %s is not https", ErrFetchBlocked, u.Redacted())
+		}
+	default:
+		return fmt.Errorf("%w: unsupported scheme %q", ErrFetchBlocked, u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return fmt.Errorf("%w: %s has no host", ErrFetchBlocked, u.Redacted())
+	}
+	if ip := net.ParseIP(u.Hostname()); ip != nil && !p.AllowPrivate && isPrivateIP(ip) {
+		return fmt.Errorf("%w: %s is not a public address", ErrFetchBlocked, ip)
+	}
+	return nil
+}
+
+// dialControl runs after DNS resolution for every connection, so it also catches
+// hostnames that resolve (or re-resolve) to internal addresses
+func (p FetchPolicy) dialControl(network, address string, _ syscall.RawConn) error {
+	if p.AllowPrivate {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrFetchBlocked, err)
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || isPrivateIP(ip) {
+		return fmt.Errorf("%w: %s is not a public address", ErrFetchBlocked, host)
+	}
+	return nil
+}
+
+// checkRedirect caps the number of redirects and applies the policy to every redirect target
+func (p FetchPolicy) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxFetchRedirects {
+		return fmt.Errorf("%w: stopped after %d redirects", ErrFetchBlocked, maxFetchRedirects)
+	}
+	return p.CheckURL(req.URL)
+}
+
+// cgnatRange is the shared address space used by carrier-grade NAT (RFC 6598)
+var cgnatRange = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// isPrivateIP reports whether ip is not a public unicast address, e.g. loopback,
+// RFC 1918, link-local (including cloud metadata at 169.254.169.254) or unspecified
+func isPrivateIP(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil && ip4[0] == 0 {
+		// 0.0.0.0/8 reaches the local host on some systems
+		return true
+	}
+	return ip.IsLoopback() ||
+		ip.IsPrivate() ||
+		ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() ||
+		ip.IsUnspecified() ||
+		cgnatRange.Contains(ip)
+}
+
+// ReadJSONResponse reads the body of a fetched ActivityPub, WebFinger or other JSON document.
+// Bodies larger than the configured MaxFetchBytes and documents without a JSON
+// Content-Type are rejected before anything is unmarshaled.
+func ReadJSONResponse(resp *http.Response) ([]byte, error) {
+	contentType := resp.Header.Get("Content-Type")
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || !isJSONMediaType(mediaType) {
+		return nil, fmt.Errorf("%w: %q", ErrUnexpectedContentType, contentType)
+	}
+
+	limit := maxFetchBytes
+	if resp.ContentLength > limit {
+		return nil, fmt.Errorf("%w: %d bytes (max %d)", ErrFetchTooLarge, resp.ContentLength, limit)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if int64(len(body)) > limit {
+		return nil, fmt.Errorf("%w: more than %d bytes", ErrFetchTooLarge, limit)
+	}
+	return body, nil
+}
+
+// isJSONMediaType accepts application/json and its structured-syntax variants,
+// such as application/activity+json, application/ld+json and application/jrd+json
+func isJSONMediaType(mediaType string) bool {
+	return mediaType == "application/json" ||
+		(strings.HasPrefix(mediaType, "application/") && strings.HasSuffix(mediaType, "+json"))
+}
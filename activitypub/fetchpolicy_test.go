@@ -0,0 +1,163 @@
+package activitypub
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/deemkeen/stegodon/util"
+)
+
+// TestFetchPolicy_CheckURL tests which URLs the default and relaxed policies allow
+func TestFetchPolicy_CheckURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  FetchPolicy
+		rawURL  string
+		allowed bool
+	}{
+		{"https hostname", FetchPolicy{}, "https://mastodon.social/users/alice", true},
+		{"plain http", FetchPolicy{}, "http://mastodon.social/users/alice", false},
+		{"plain http allowed", FetchPolicy{AllowHTTP: true}, "http://mastodon.social/users/alice", true},
+		{"other scheme", FetchPolicy{AllowHTTP: true}, "file:///etc/passwd", false},
+		{"cloud metadata", FetchPolicy{}, "https://169.254.169.254/latest/meta-data", false},
+		{"loopback", FetchPolicy{}, "https://127.0.0.1:8080/inbox", false},
+		{"private range", FetchPolicy{}, "https://10.0.0.5/users/bob", false},
+		{"ipv6 loopback", FetchPolicy{}, "https://[::1]/users/bob", false},
+		{"public ip", FetchPolicy{}, "https://93.184.216.34/users/bob", true},
+		{"private allowed", FetchPolicy{AllowPrivate: true}, "https://127.0.0.1:8080/inbox", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, _ := url.Parse(tt.rawURL)
+			err := tt.policy.CheckURL(u)
+			if tt.allowed && err != nil {
+				t.Errorf("Expected %s to be allowed, got %v", tt.rawURL, err)
+			}
+			if !tt.allowed && !errors.Is(err, ErrFetchBlocked) {
+				t.Errorf("Expected %s to be blocked, got %v", tt.rawURL, err)
+			}
+		})
+	}
+}
+
+// TestIsPrivateIP tests the address ranges that are never fetched from by default
+func TestIsPrivateIP(t *testing.T) {
+	for _, addr := range []string{"127.0.0.1", "10.1.2.3", "172.16.0.1", "192.168.1.1", "169.254.169.254", "100.64.0.1", "0.0.0.0", "0.1.2.3", "::1", "fe80::1", "fc00::1", "::ffff:127.0.0.1"} {
+		if !isPrivateIP(net.ParseIP(addr)) {
+			t.Errorf("Expected %s to be private", addr)
+		}
+	}
+	for _, addr := range []string{"93.184.216.34", "1.1.1.1", "2606:4700:4700::1111"} {
+		if isPrivateIP(net.ParseIP(addr)) {
+			t.Errorf("Expected %s to be public", addr)
+		}
+	}
+}
+
+// TestNewFederationHTTPClient_BlocksPrivateAddresses tests that the dialer rejects internal
+// addresses after resolution, even when the URL names a hostname
+func TestNewFederationHTTPClient_BlocksPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Request should not have reached the server")
+	}))
+	defer server.Close()
+
+	conf := &util.AppConfig{}
+	conf.Conf.AllowHttpFetch = true
+	client := NewFederationHTTPClient(conf)
+
+	serverURL, _ := url.Parse(server.URL)
+	req, _ := http.NewRequest("GET", "http://localhost:"+serverURL.Port()+"/users/alice", nil)
+	if _, err := client.Do(req); !errors.Is(err, ErrFetchBlocked) {
+		t.Errorf("Expected request to localhost to be blocked, got %v", err)
+	}
+}
+
+// TestNewFederationHTTPClient_CapsRedirects tests that redirect loops stop after maxFetchRedirects
+func TestNewFederationHTTPClient_CapsRedirects(t *testing.T) {
+	hops := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hops++
+		http.Redirect(w, r, "/next", http.StatusFound)
+	}))
+	defer server.Close()
+
+	conf := &util.AppConfig{}
+	conf.Conf.AllowHttpFetch = true
+	conf.Conf.AllowPrivateFetch = true
+	client := NewFederationHTTPClient(conf)
+
+	req, _ := http.NewRequest("GET", server.URL+"/start", nil)
+	if _, err := client.Do(req); !errors.Is(err, ErrFetchBlocked) {
+		t.Errorf("Expected redirect loop to be stopped, got %v", err)
+	}
+	if hops != maxFetchRedirects {
+		t.Errorf("Expected %d requests, got %d", maxFetchRedirects, hops)
+	}
+}
+
+// TestReadJSONResponse tests which fetched documents are read and which are rejected
+func TestReadJSONResponse(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantErr     error
+	}{
+		{"activity json", "application/activity+json", `{"type":"Note"}`, nil},
+		{"ld json with profile", `application/ld+json; profile="https://www.w3.org/ns/activitystreams"`, `{"type":"Note"}`, nil},
+		{"ld json with profile and charset", `application/ld+json;profile="https://www.w3.org/ns/activitystreams";charset=utf-8`, `{"type":"Note"}`, nil},
+		{"plain json", "application/json; charset=utf-8", `{}`, nil},
+		{"no content type", "", `{}`, ErrUnexpectedContentType},
+		{"html", "text/html; charset=utf-8", `<html></html>`, ErrUnexpectedContentType},
+		{"oversized", "application/activity+json", `{"content":"` + strings.Repeat("a", defaultMaxFetchBytes) + `"}`, ErrFetchTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{
+				StatusCode:    http.StatusOK,
+				Header:        make(http.Header),
+				Body:          io.NopCloser(strings.NewReader(tt.body)),
+				ContentLength: -1,
+			}
+			if tt.contentType != "" {
+				resp.Header.Set("Content-Type", tt.contentType)
+			}
+
+			body, err := ReadJSONResponse(resp)
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("Expected document to be read, got %v", err)
+				}
+				if string(body) != tt.body {
+					t.Errorf("Expected body %q, got %q", tt.body, body)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+// TestReadJSONResponse_DeclaredLengthTooLarge tests that an oversized Content-Length is rejected without reading
+func TestReadJSONResponse_DeclaredLengthTooLarge(t *testing.T) {
+	resp := &http.Response{
+		StatusCode:    http.StatusOK,
+		Header:        http.Header{"Content-Type": []string{"application/activity+json"}},
+		Body:          io.NopCloser(strings.NewReader(`{}`)),
+		ContentLength: 5 * 1024 * 1024 * 1024,
+	}
+	if _, err := ReadJSONResponse(resp); !errors.Is(err, ErrFetchTooLarge) {
+		t.Errorf("Expected ErrFetchTooLarge, got %v", err)
+	}
+}
@@ -0,0 +1,149 @@
+package activitypub
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	"github.com/deemkeen/stegodon/domain"
+	"github.com/deemkeen/stegodon/util"
+)
+
+// followingCSVHeader is the header of Mastodon's following_accounts.csv
+var followingCSVHeader = []string{"Account address", "Show boosts", "Notify on new posts", "Languages"}
+
+// FollowImportResult reports the outcome of a following CSV import
+type FollowImportResult struct {
+	Followed int                 // Follows sent
+	Skipped  int                 // Accounts that were already followed
+	Errors   []FollowImportError // Rows that could not be imported
+}
+
+// FollowImportError describes a CSV row that could not be imported
+type FollowImportError struct {
+	Line    int
+	Address string
+	Err     error
+}
+
+func (e FollowImportError) Error() string {
+	if e.Address == "" {
+		return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+	}
+	return fmt.Sprintf("line %d (%s): %v", e.Line, e.Address, e.Err)
+}
+
+// ExportFollowingCSV writes the accounts the given account follows as a Mastodon-compatible
+// following_accounts.csv. This is the production wrapper that uses the default database.
+func ExportFollowingCSV(w io.Writer, account *domain.Account, conf *util.AppConfig) error {
+	return ExportFollowingCSVWithDeps(w, account, conf, NewDBWrapper())
+}
+
+// ExportFollowingCSVWithDeps writes the accounts the given account follows as a Mastodon-compatible
+// following_accounts.csv. This version accepts dependencies for testing.
+func ExportFollowingCSVWithDeps(w io.Writer, account *domain.Account, conf *util.AppConfig, database Database) error {
+	addresses, err := database.ReadFollowingAddresses(account.Id, conf.Conf.SslDomain)
+	if err != nil {
+		return fmt.Errorf("failed to read follows: %w", err)
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(followingCSVHeader); err != nil {
+		return err
+	}
+	// Stegodon has no per-follow boost, notification or language settings, so use Mastodon's defaults
+	for _, address := range addresses {
+		if err := writer.Write([]string{address, "true", "false", ""}); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// ImportFollowingCSV follows every account listed in a following_accounts.csv.
+// This is the production wrapper that uses the default HTTP client and database.
+func ImportFollowingCSV(r io.Reader, account *domain.Account, conf *util.AppConfig) (*FollowImportResult, error) {
+	return ImportFollowingCSVWithDeps(r, account, conf, defaultHTTPClient, NewDBWrapper())
+}
+
+// ImportFollowingCSVWithDeps follows every account listed in a following_accounts.csv, resolving
+// each address via WebFinger. Accounts already followed are skipped, and malformed or unresolvable
+// rows are reported in the result without stopping the import.
+// This version accepts dependencies for testing.
+func ImportFollowingCSVWithDeps(r io.Reader, account *domain.Account, conf *util.AppConfig, client HTTPClient, database Database) (*FollowImportResult, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1 // Only the address column is required
+	reader.TrimLeadingSpace = true
+
+	result := &FollowImportResult{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			result.Errors = append(result.Errors, FollowImportError{Line: parseErr.StartLine, Err: parseErr.Err})
+			continue
+		}
+		if err != nil {
+			return result, fmt.Errorf("failed to read CSV: %w", err)
+		}
+
+		line, _ := reader.FieldPos(0)
+		address := strings.TrimSpace(record[0])
+		if line == 1 && strings.EqualFold(address, followingCSVHeader[0]) {
+			continue
+		}
+
+		followed, err := importFollow(address, account, conf, client, database)
+		if err != nil {
+			log.Printf("Follow import: line %d (%s): %v", line, address, err)
+			result.Errors = append(result.Errors, FollowImportError{Line: line, Address: address, Err: err})
+			continue
+		}
+		if followed {
+			result.Followed++
+		} else {
+			result.Skipped++
+		}
+	}
+
+	log.Printf("Follow import for %s: %d followed, %d skipped, %d failed", account.Username, result.Followed, result.Skipped, len(result.Errors))
+	return result, nil
+}
+
+// importFollow follows a single user@domain address.
+// Returns false without an error if the account is already followed.
+func importFollow(address string, account *domain.Account, conf *util.AppConfig, client HTTPClient, database Database) (bool, error) {
+	parts := strings.Split(strings.TrimPrefix(address, "@"), "@")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return false, fmt.Errorf("invalid account address, expected user@domain")
+	}
+	if strings.EqualFold(parts[1], conf.Conf.SslDomain) {
+		return false, fmt.Errorf("local account, follow them directly on this server")
+	}
+
+	actorURI, _, err := ResolveMentionWithDeps(address, client, database)
+	if err != nil {
+		return false, err
+	}
+
+	// ResolveMention caches the remote account, so an existing follow can be checked without a request
+	remoteActor, err := database.ReadRemoteAccountByActorURI(actorURI)
+	if err == nil && remoteActor != nil {
+		follow, err := database.ReadFollowByAccountIds(account.Id, remoteActor.Id)
+		if err == nil && follow != nil {
+			return false, nil
+		}
+	}
+
+	if err := SendFollowWithDeps(account, actorURI, conf, client, database); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,122 @@
+package activitypub
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deemkeen/stegodon/domain"
+	"github.com/deemkeen/stegodon/util"
+	"github.com/google/uuid"
+)
+
+// setResolvableActor makes username@domain resolvable via WebFinger and returns its actor URI
+func setResolvableActor(t *testing.T, mockHTTP *MockHTTPClient, username, domainName string) string {
+	t.Helper()
+	actorURI := "https://" + domainName + "/users/" + username
+	webfingerURL := "https://" + domainName + "/.well-known/webfinger?resource=acct:" + username + "@" + domainName
+	if err := mockHTTP.SetJSONResponse(webfingerURL, 200, map[string]any{
+		"subject": "acct:" + username + "@" + domainName,
+		"links": []map[string]string{
+			{"rel": "self", "type": "application/activity+json", "href": actorURI},
+		},
+	}); err != nil {
+		t.Fatalf("Failed to set webfinger response: %v", err)
+	}
+
+	actorResponse := ActorResponse{ID: actorURI, Type: "Person", PreferredUsername: username, Inbox: actorURI + "/inbox"}
+	actorResponse.PublicKey.PublicKeyPem = "-----BEGIN PUBLIC KEY-----\ntest\n-----END PUBLIC KEY-----"
+	if err := mockHTTP.SetJSONResponse(actorURI, 200, actorResponse); err != nil {
+		t.Fatalf("Failed to set actor response: %v", err)
+	}
+	mockHTTP.SetResponse(actorURI+"/inbox", 202, nil)
+	return actorURI
+}
+
+func TestExportFollowingCSVWithDeps(t *testing.T) {
+	mockDB := NewMockDatabase()
+	alice := &domain.Account{Id: uuid.New(), Username: "alice"}
+	carol := &domain.Account{Id: uuid.New(), Username: "carol"}
+	mockDB.AddAccount(alice)
+	mockDB.AddAccount(carol)
+
+	bob := &domain.RemoteAccount{Id: uuid.New(), Username: "bob", Domain: "remote.example.com", ActorURI: "https://remote.example.com/users/bob"}
+	mockDB.AddRemoteAccount(bob)
+	mockDB.AddFollow(&domain.Follow{Id: uuid.New(), AccountId: alice.Id, TargetAccountId: bob.Id, Accepted: true, CreatedAt: time.Now().Add(-time.Hour)})
+	mockDB.AddFollow(&domain.Follow{Id: uuid.New(), AccountId: alice.Id, TargetAccountId: carol.Id, Accepted: true, IsLocal: true, CreatedAt: time.Now()})
+
+	conf := &util.AppConfig{}
+	conf.Conf.SslDomain = "local.example.com"
+
+	var buf bytes.Buffer
+	if err := ExportFollowingCSVWithDeps(&buf, alice, conf, mockDB); err != nil {
+		t.Fatalf("ExportFollowingCSVWithDeps failed: %v", err)
+	}
+
+	expected := "Account address,Show boosts,Notify on new posts,Languages\n" +
+		"bob@remote.example.com,true,false,\n" +
+		"carol@local.example.com,true,false,\n"
+	if buf.String() != expected {
+		t.Errorf("Unexpected CSV:\n%s\nwant:\n%s", buf.String(), expected)
+	}
+}
+
+func TestImportFollowingCSVWithDeps(t *testing.T) {
+	mockDB := NewMockDatabase()
+	mockHTTP := NewMockHTTPClient()
+
+	keypair, _ := GenerateTestKeyPair()
+	alice := CreateTestAccount("alice", keypair)
+	mockDB.AddAccount(alice)
+
+	conf := &util.AppConfig{}
+	conf.Conf.SslDomain = "local.example.com"
+
+	bobURI := setResolvableActor(t, mockHTTP, "bob", "remote.example.com")
+	daveURI := setResolvableActor(t, mockHTTP, "dave", "other.example.com")
+
+	// Dave is already followed
+	if _, _, err := ResolveMentionWithDeps("dave@other.example.com", mockHTTP, mockDB); err != nil {
+		t.Fatalf("ResolveMentionWithDeps failed: %v", err)
+	}
+	mockDB.AddFollow(&domain.Follow{Id: uuid.New(), AccountId: alice.Id, TargetAccountId: mockDB.RemoteByActor[daveURI].Id, Accepted: true})
+
+	csvData := strings.Join([]string{
+		"Account address,Show boosts,Notify on new posts,Languages",
+		"@bob@remote.example.com,true,false,",
+		"dave@other.example.com,true,false,",
+		"not-an-address,true,false,",
+		"carol@local.example.com,true,false,",
+		`bad"quote@remote.example.com,true,false,`,
+		"ghost@unknown.example.com",
+	}, "\n")
+
+	result, err := ImportFollowingCSVWithDeps(strings.NewReader(csvData), alice, conf, mockHTTP, mockDB)
+	if err != nil {
+		t.Fatalf("ImportFollowingCSVWithDeps failed: %v", err)
+	}
+
+	if result.Followed != 1 || result.Skipped != 1 {
+		t.Errorf("Expected 1 followed and 1 skipped, got %d and %d", result.Followed, result.Skipped)
+	}
+
+	// Malformed, local, unparseable and unresolvable rows are reported without aborting
+	var lines []int
+	for _, rowErr := range result.Errors {
+		lines = append(lines, rowErr.Line)
+	}
+	if len(lines) != 4 || lines[0] != 4 || lines[1] != 5 || lines[2] != 6 || lines[3] != 7 {
+		t.Errorf("Expected errors on lines 4-7, got %v", result.Errors)
+	}
+
+	var followedBob bool
+	for _, follow := range mockDB.Follows {
+		if remote, ok := mockDB.RemoteAccounts[follow.TargetAccountId]; ok && remote.ActorURI == bobURI {
+			followedBob = true
+		}
+	}
+	if !followedBob {
+		t.Error("Expected a Follow of bob to be stored")
+	}
+}
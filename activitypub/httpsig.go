@@ -1,13 +1,18 @@
 package activitypub
 
 import (
+	"bytes"
 	"code.superseriousbusiness.org/httpsig"
+	"crypto"
+	"crypto/ed25519"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
 	"fmt"
+	"math/big"
 	"net/http"
 	"strings"
+	"time"
 )
 
 // SignRequest signs an outgoing HTTP request with the given private key
@@ -29,43 +34,74 @@ func SignRequest(req *http.Request, privateKey *rsa.PrivateKey, keyId string) er
 	return signer.SignRequest(privateKey, keyId, req, nil)
 }
 
+// activityPostHeaders are the headers activity deliveries are signed over. Strict receivers
+// (e.g. Mastodon in secure mode) reject POSTs whose signature doesn't cover the body digest.
+var activityPostHeaders = []string{"(request-target)", "host", "date", "digest", "content-type"}
+
+// NewSignedActivityRequest builds a POST of an activity to an inbox. The Digest of the body
+// is computed here and signed along with the Content-Type.
+func NewSignedActivityRequest(inboxURI string, body []byte, privateKey *rsa.PrivateKey, keyId string) (*http.Request, error) {
+	req, err := http.NewRequest("POST", inboxURI, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", ActivityJSONContentType)
+	SetRequestHeaders(req, ActivityJSONContentType)
+	req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	req.Header.Set("Host", req.URL.Host)
+
+	signer, _, err := httpsig.NewSigner(
+		[]httpsig.Algorithm{httpsig.RSA_SHA256},
+		httpsig.DigestSha256,
+		activityPostHeaders,
+		httpsig.Signature,
+		0,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create signer: %w", err)
+	}
+	// Passing the body makes the signer set the Digest header before signing
+	if err := signer.SignRequest(privateKey, keyId, req, body); err != nil {
+		return nil, fmt.Errorf("failed to sign request: %w", err)
+	}
+	return req, nil
+}
+
 // VerifyRequest verifies the HTTP signature on an incoming request
 // Returns the actor URI if valid, error otherwise
+// RSA and Ed25519 keys are supported; the algorithm follows the key type and must match
+// the signature's algorithm parameter when one is given.
 func VerifyRequest(req *http.Request, publicKeyPem string) (string, error) {
-	// Create verifier from request
-	verifier, err := httpsig.NewVerifier(req)
+	params, err := parseSignatureParams(signatureHeader(req))
 	if err != nil {
-		return "", fmt.Errorf("failed to create verifier: %w", err)
+		return "", err
 	}
 
-	// Parse public key from PEM - support both PKIX (PKCS#8) and PKCS#1 formats
-	block, _ := pem.Decode([]byte(publicKeyPem))
-	if block == nil {
-		return "", fmt.Errorf("failed to parse PEM block")
+	// Create verifier from a copy of the request carrying the normalized Signature header,
+	// so the signing string is built strictly from the declared headers list
+	verifyReq := req.Clone(req.Context())
+	if verifyReq.Header.Get("Signature") == "" {
+		verifyReq.Header.Del("Authorization")
+	}
+	verifyReq.Header.Set("Signature", params.String())
+	verifier, err := httpsig.NewVerifier(verifyReq)
+	if err != nil {
+		return "", fmt.Errorf("failed to create verifier: %w", err)
 	}
 
-	var rsaPubKey *rsa.PublicKey
+	// Parse public key - PEM (RSA in PKIX or PKCS#1, Ed25519 in PKIX) or Ed25519 multikey
+	pubKey, err := ParsePublicKey(publicKeyPem)
+	if err != nil {
+		return "", err
+	}
 
-	// Try PKIX format first (standard format, used by new keys)
-	pubKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	algo, err := verificationAlgorithm(pubKey, params.Algorithm)
 	if err != nil {
-		// Try PKCS#1 format (old stegodon instances)
-		pkcs1Key, pkcs1Err := x509.ParsePKCS1PublicKey(block.Bytes)
-		if pkcs1Err != nil {
-			return "", fmt.Errorf("failed to parse public key (tried PKIX and PKCS#1): PKIX error: %v, PKCS#1 error: %v", err, pkcs1Err)
-		}
-		rsaPubKey = pkcs1Key
-	} else {
-		// PKIX succeeded, cast to RSA
-		var ok bool
-		rsaPubKey, ok = pubKey.(*rsa.PublicKey)
-		if !ok {
-			return "", fmt.Errorf("not an RSA public key")
-		}
+		return "", err
 	}
 
 	// Verify the signature
-	err = verifier.Verify(rsaPubKey, httpsig.RSA_SHA256)
+	err = verifier.Verify(pubKey, algo)
 	if err != nil {
 		return "", fmt.Errorf("signature verification failed: %w", err)
 	}
@@ -81,6 +117,134 @@ func VerifyRequest(req *http.Request, publicKeyPem string) (string, error) {
 	return actorURI, nil
 }
 
+// verificationAlgorithm picks the httpsig algorithm for a public key.
+// The signature's algorithm parameter is optional ("hs2019" means "derive it from the key").
+func verificationAlgorithm(pubKey crypto.PublicKey, algorithm string) (httpsig.Algorithm, error) {
+	algorithm = strings.ToLower(algorithm)
+	switch pubKey.(type) {
+	case *rsa.PublicKey:
+		if algorithm == "" || algorithm == "hs2019" || algorithm == "rsa-sha256" {
+			return httpsig.RSA_SHA256, nil
+		}
+	case ed25519.PublicKey:
+		if algorithm == "" || algorithm == "hs2019" || algorithm == "ed25519" {
+			return httpsig.ED25519, nil
+		}
+	default:
+		return "", fmt.Errorf("unsupported public key type %T", pubKey)
+	}
+	return "", fmt.Errorf("signature algorithm %q does not match %T key", algorithm, pubKey)
+}
+
+// signatureHeader returns the HTTP signature parameters from the Signature or Authorization header
+func signatureHeader(req *http.Request) string {
+	if signature := req.Header.Get("Signature"); signature != "" {
+		return signature
+	}
+	return strings.TrimPrefix(req.Header.Get("Authorization"), "Signature ")
+}
+
+// signatureParams are the parameters of an HTTP Signature header
+type signatureParams struct {
+	KeyId     string
+	Algorithm string
+	Headers   []string // Lowercased, in signing order; nil if the parameter is absent
+	Signature string
+	Created   string
+	Expires   string
+}
+
+// parseSignatureParams parses the parameters of a Signature header. Servers differ in
+// spacing, quoting and parameter order, so whitespace around separators is ignored,
+// values may be quoted (and then contain commas) or bare like created=1700000000, and
+// parameter names are matched case-insensitively. keyId and signature are required.
+func parseSignatureParams(header string) (*signatureParams, error) {
+	params := &signatureParams{}
+	seen := make(map[string]bool)
+	rest := strings.TrimSpace(header)
+	for rest != "" {
+		eq := strings.IndexByte(rest, '=')
+		if eq <= 0 {
+			return nil, fmt.Errorf("malformed signature parameter %q", rest)
+		}
+		name := strings.ToLower(strings.TrimSpace(rest[:eq]))
+		rest = strings.TrimLeft(rest[eq+1:], " \t")
+
+		var value string
+		if strings.HasPrefix(rest, "\"") {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated value for signature parameter %q", name)
+			}
+			value = rest[1 : end+1]
+			rest = rest[end+2:]
+		} else {
+			end := strings.IndexByte(rest, ',')
+			if end < 0 {
+				end = len(rest)
+			}
+			value = strings.TrimSpace(rest[:end])
+			rest = rest[end:]
+		}
+
+		// Skip the separator to the next parameter
+		rest = strings.TrimSpace(rest)
+		if rest != "" {
+			if rest[0] != ',' {
+				return nil, fmt.Errorf("malformed signature parameter %q", name)
+			}
+			rest = strings.TrimSpace(rest[1:])
+		}
+
+		// The first occurrence wins, so a parameter appended later can't override it
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		switch name {
+		case "keyid":
+			params.KeyId = value
+		case "algorithm":
+			params.Algorithm = value
+		case "headers":
+			params.Headers = strings.Fields(strings.ToLower(value))
+		case "signature":
+			params.Signature = value
+		case "created":
+			params.Created = value
+		case "expires":
+			params.Expires = value
+		}
+	}
+
+	if params.KeyId == "" {
+		return nil, fmt.Errorf("missing keyId in signature")
+	}
+	if params.Signature == "" {
+		return nil, fmt.Errorf("missing signature value in signature")
+	}
+	return params, nil
+}
+
+// String formats the parameters as a normalized Signature header
+func (p *signatureParams) String() string {
+	parts := []string{`keyId="` + p.KeyId + `"`}
+	if p.Algorithm != "" {
+		parts = append(parts, `algorithm="`+p.Algorithm+`"`)
+	}
+	if p.Headers != nil {
+		parts = append(parts, `headers="`+strings.Join(p.Headers, " ")+`"`)
+	}
+	parts = append(parts, `signature="`+p.Signature+`"`)
+	if p.Created != "" {
+		parts = append(parts, "created="+p.Created)
+	}
+	if p.Expires != "" {
+		parts = append(parts, "expires="+p.Expires)
+	}
+	return strings.Join(parts, ",")
+}
+
 // ParsePrivateKey converts PEM string to *rsa.PrivateKey
 // Supports both PKCS#1 (old format) and PKCS#8 (new format) for backwards compatibility
 func ParsePrivateKey(pemString string) (*rsa.PrivateKey, error) {
@@ -114,9 +278,14 @@ func ParsePrivateKey(pemString string) (*rsa.PrivateKey, error) {
 	return nil, fmt.Errorf("unsupported private key type: %s", block.Type)
 }
 
-// ParsePublicKey converts PEM string to *rsa.PublicKey
-// Supports both PKIX (PKCS#8) and PKCS#1 formats for backwards compatibility
-func ParsePublicKey(pemString string) (*rsa.PublicKey, error) {
+// ParsePublicKey converts a public key string to *rsa.PublicKey or ed25519.PublicKey
+// Supports PEM in PKIX (RSA or Ed25519) and PKCS#1 (RSA) formats, and Ed25519 Multikey
+// values (publicKeyMultibase, e.g. "z6Mk...")
+func ParsePublicKey(pemString string) (crypto.PublicKey, error) {
+	if strings.HasPrefix(pemString, "z") {
+		return parseEd25519Multikey(pemString)
+	}
+
 	block, _ := pem.Decode([]byte(pemString))
 	if block == nil {
 		return nil, fmt.Errorf("failed to parse PEM block")
@@ -133,10 +302,50 @@ func ParsePublicKey(pemString string) (*rsa.PublicKey, error) {
 		return pkcs1Key, nil
 	}
 
-	rsaPubKey, ok := pubKey.(*rsa.PublicKey)
-	if !ok {
-		return nil, fmt.Errorf("not an RSA public key")
+	switch key := pubKey.(type) {
+	case *rsa.PublicKey:
+		return key, nil
+	case ed25519.PublicKey:
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", pubKey)
+	}
+}
+
+// ed25519MulticodecPrefix marks an Ed25519 public key in a multikey (varint of codec 0xed)
+var ed25519MulticodecPrefix = []byte{0xed, 0x01}
+
+// parseEd25519Multikey decodes a base58btc multibase Ed25519 public key ("z" + base58(0xed01 + key))
+func parseEd25519Multikey(multibase string) (ed25519.PublicKey, error) {
+	decoded, err := decodeBase58(strings.TrimPrefix(multibase, "z"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode multikey: %w", err)
+	}
+	if len(decoded) != len(ed25519MulticodecPrefix)+ed25519.PublicKeySize || !bytes.HasPrefix(decoded, ed25519MulticodecPrefix) {
+		return nil, fmt.Errorf("multikey is not an Ed25519 public key")
+	}
+	return ed25519.PublicKey(decoded[len(ed25519MulticodecPrefix):]), nil
+}
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// decodeBase58 decodes a base58 (Bitcoin alphabet) string
+func decodeBase58(s string) ([]byte, error) {
+	n := new(big.Int)
+	radix := big.NewInt(58)
+	for _, c := range s {
+		digit := strings.IndexRune(base58Alphabet, c)
+		if digit < 0 {
+			return nil, fmt.Errorf("invalid base58 character %q", c)
+		}
+		n.Mul(n, radix)
+		n.Add(n, big.NewInt(int64(digit)))
 	}
 
-	return rsaPubKey, nil
+	// Each leading '1' encodes a leading zero byte
+	zeros := 0
+	for zeros < len(s) && s[zeros] == '1' {
+		zeros++
+	}
+	return append(make([]byte, zeros), n.Bytes()...), nil
 }
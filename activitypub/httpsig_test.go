@@ -2,15 +2,21 @@ package activitypub
 
 import (
 	"bytes"
+	"crypto"
+	"crypto/ed25519"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha256"
 	"crypto/x509"
 	"encoding/base64"
 	"encoding/pem"
+	"math/big"
 	"net/http"
+	"strings"
 	"testing"
 	"time"
+
+	"code.superseriousbusiness.org/httpsig"
 )
 
 // generateTestKeyPair generates an RSA key pair for testing
@@ -109,7 +115,11 @@ func TestParsePublicKey(t *testing.T) {
 	}
 
 	// Verify the key matches
-	if parsed.N.Cmp(publicKey.N) != 0 {
+	rsaKey, ok := parsed.(*rsa.PublicKey)
+	if !ok {
+		t.Fatalf("Expected *rsa.PublicKey, got %T", parsed)
+	}
+	if rsaKey.N.Cmp(publicKey.N) != 0 {
 		t.Error("Parsed key doesn't match original")
 	}
 }
@@ -463,7 +473,7 @@ func TestParsePublicKeyBothFormats(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Failed to parse PKCS#1 public key: %v", err)
 	}
-	if parsed1.N.Cmp(publicKey.N) != 0 {
+	if rsaKey, ok := parsed1.(*rsa.PublicKey); !ok || rsaKey.N.Cmp(publicKey.N) != 0 {
 		t.Error("PKCS#1 parsed key doesn't match original")
 	}
 
@@ -481,7 +491,7 @@ func TestParsePublicKeyBothFormats(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Failed to parse PKIX public key: %v", err)
 	}
-	if parsed2.N.Cmp(publicKey.N) != 0 {
+	if rsaKey, ok := parsed2.(*rsa.PublicKey); !ok || rsaKey.N.Cmp(publicKey.N) != 0 {
 		t.Error("PKIX parsed key doesn't match original")
 	}
 }
@@ -893,3 +903,304 @@ func TestKeyIdFragmentExtraction(t *testing.T) {
 		})
 	}
 }
+
+// testEd25519Key is a fixed Ed25519 test vector (seed 0x01 * 32)
+var testEd25519Key = ed25519.NewKeyFromSeed(bytes.Repeat([]byte{0x01}, ed25519.SeedSize))
+
+// encodeBase58 is the inverse of decodeBase58, used to build multikey test vectors
+func encodeBase58(data []byte) string {
+	n := new(big.Int).SetBytes(data)
+	radix := big.NewInt(58)
+	mod := new(big.Int)
+	var out []byte
+	for n.Sign() > 0 {
+		n.DivMod(n, radix, mod)
+		out = append([]byte{base58Alphabet[mod.Int64()]}, out...)
+	}
+	for _, b := range data {
+		if b != 0 {
+			break
+		}
+		out = append([]byte{'1'}, out...)
+	}
+	return string(out)
+}
+
+// ed25519Multikey returns the publicKeyMultibase form of an Ed25519 public key
+func ed25519Multikey(pub ed25519.PublicKey) string {
+	return "z" + encodeBase58(append(append([]byte{}, ed25519MulticodecPrefix...), pub...))
+}
+
+// newSignedRequest builds an inbox POST signed with the given algorithm and private key
+func newSignedRequest(t *testing.T, algo httpsig.Algorithm, privateKey crypto.PrivateKey) *http.Request {
+	t.Helper()
+	body := []byte(`{"type":"Create"}`)
+	req, _ := http.NewRequest("POST", "https://local.example.com/users/alice/inbox", bytes.NewReader(body))
+	req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	req.Header.Set("Host", req.URL.Host)
+	req.Header.Set("Digest", calculateDigest(body))
+
+	signer, _, err := httpsig.NewSigner([]httpsig.Algorithm{algo}, httpsig.DigestSha256,
+		[]string{"(request-target)", "host", "date", "digest"}, httpsig.Signature, 0)
+	if err != nil {
+		t.Fatalf("Failed to create signer: %v", err)
+	}
+	if err := signer.SignRequest(privateKey, "https://remote.example.com/users/bob#main-key", req, nil); err != nil {
+		t.Fatalf("Failed to sign request: %v", err)
+	}
+	return req
+}
+
+// withSignatureAlgorithm rewrites the algorithm parameter of a signed request
+func withSignatureAlgorithm(req *http.Request, algorithm string) *http.Request {
+	parts := strings.Split(req.Header.Get("Signature"), ",")
+	for i, part := range parts {
+		if strings.HasPrefix(part, "algorithm=") {
+			parts[i] = `algorithm="` + algorithm + `"`
+		}
+	}
+	req.Header.Set("Signature", strings.Join(parts, ","))
+	return req
+}
+
+func TestParsePublicKey_Ed25519(t *testing.T) {
+	pub := testEd25519Key.Public().(ed25519.PublicKey)
+	pkix, _ := x509.MarshalPKIXPublicKey(pub)
+	pemString := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pkix}))
+
+	for name, keyString := range map[string]string{
+		"PEM":      pemString,
+		"multikey": ed25519Multikey(pub),
+	} {
+		parsed, err := ParsePublicKey(keyString)
+		if err != nil {
+			t.Fatalf("%s: ParsePublicKey failed: %v", name, err)
+		}
+		edKey, ok := parsed.(ed25519.PublicKey)
+		if !ok {
+			t.Fatalf("%s: expected ed25519.PublicKey, got %T", name, parsed)
+		}
+		if !edKey.Equal(pub) {
+			t.Errorf("%s: parsed key doesn't match original", name)
+		}
+	}
+}
+
+func TestParsePublicKey_InvalidMultikey(t *testing.T) {
+	// A base58 character outside the alphabet, and a valid base58 value without the Ed25519 prefix
+	for _, keyString := range []string{"z0OIl", "z" + encodeBase58(bytes.Repeat([]byte{0x02}, 34))} {
+		if _, err := ParsePublicKey(keyString); err == nil {
+			t.Errorf("Expected error for multikey %q", keyString)
+		}
+	}
+}
+
+func TestDecodeBase58_LeadingZeros(t *testing.T) {
+	data := []byte{0x00, 0x00, 0xed, 0x01, 0xff}
+	decoded, err := decodeBase58(encodeBase58(data))
+	if err != nil {
+		t.Fatalf("decodeBase58 failed: %v", err)
+	}
+	if !bytes.Equal(decoded, data) {
+		t.Errorf("Expected %x, got %x", data, decoded)
+	}
+}
+
+func TestVerifyRequest_AlgorithmNegotiation(t *testing.T) {
+	rsaPrivate, rsaPublic, err := generateTestKeyPair()
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+	rsaPEM, _ := publicKeyToPEM(rsaPublic)
+	edPublic := testEd25519Key.Public().(ed25519.PublicKey)
+
+	tests := []struct {
+		name      string
+		req       func() *http.Request
+		key       string
+		wantValid bool
+	}{
+		{"RSA rsa-sha256", func() *http.Request { return newSignedRequest(t, httpsig.RSA_SHA256, rsaPrivate) }, rsaPEM, true},
+		{"RSA hs2019", func() *http.Request {
+			return withSignatureAlgorithm(newSignedRequest(t, httpsig.RSA_SHA256, rsaPrivate), "hs2019")
+		}, rsaPEM, true},
+		{"Ed25519 multikey", func() *http.Request { return newSignedRequest(t, httpsig.ED25519, testEd25519Key) }, ed25519Multikey(edPublic), true},
+		{"Ed25519 explicit algorithm", func() *http.Request {
+			return withSignatureAlgorithm(newSignedRequest(t, httpsig.ED25519, testEd25519Key), "ed25519")
+		}, ed25519Multikey(edPublic), true},
+		{"Ed25519 signature with RSA key", func() *http.Request { return newSignedRequest(t, httpsig.ED25519, testEd25519Key) }, rsaPEM, false},
+		{"RSA key with ed25519 algorithm", func() *http.Request {
+			return withSignatureAlgorithm(newSignedRequest(t, httpsig.RSA_SHA256, rsaPrivate), "ed25519")
+		}, rsaPEM, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actorURI, err := VerifyRequest(tt.req(), tt.key)
+			if tt.wantValid {
+				if err != nil {
+					t.Fatalf("Expected valid signature, got %v", err)
+				}
+				if actorURI != "https://remote.example.com/users/bob" {
+					t.Errorf("Expected signer actor URI, got %s", actorURI)
+				}
+			} else if err == nil {
+				t.Error("Expected verification to fail")
+			}
+		})
+	}
+}
+
+func TestParseSignatureParams(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		keyId   string
+		algo    string
+		headers string
+		created string
+	}{
+		{
+			name:    "Mastodon",
+			header:  `keyId="https://mastodon.social/users/Gargron#main-key",algorithm="rsa-sha256",headers="(request-target) host date digest content-type",signature="c2lnbmF0dXJl"`,
+			keyId:   "https://mastodon.social/users/Gargron#main-key",
+			algo:    "rsa-sha256",
+			headers: "(request-target) host date digest content-type",
+		},
+		{
+			name:    "Pleroma",
+			header:  `keyId="https://pleroma.example/users/lain#main-key",algorithm="rsa-sha256",headers="(request-target) content-length date digest host",signature="c2lnbmF0dXJl"`,
+			keyId:   "https://pleroma.example/users/lain#main-key",
+			algo:    "rsa-sha256",
+			headers: "(request-target) content-length date digest host",
+		},
+		{
+			name:    "Misskey",
+			header:  `keyId="https://misskey.io/users/9abcdefghi#main-key",algorithm="rsa-sha256",headers="(request-target) date host digest",signature="c2lnbmF0dXJl"`,
+			keyId:   "https://misskey.io/users/9abcdefghi#main-key",
+			algo:    "rsa-sha256",
+			headers: "(request-target) date host digest",
+		},
+		{
+			name:    "hs2019 with unquoted created",
+			header:  `keyId="https://gts.example/users/bob/main-key",algorithm="hs2019",created=1700000000,headers="(request-target) (created) host digest",signature="c2lnbmF0dXJl"`,
+			keyId:   "https://gts.example/users/bob/main-key",
+			algo:    "hs2019",
+			headers: "(request-target) (created) host digest",
+			created: "1700000000",
+		},
+		{
+			name:    "spaces after commas and around equals",
+			header:  `keyId = "https://remote.example/users/bob#main-key",  algorithm="rsa-sha256" , headers="(request-target)  host date",	signature="c2lnbmF0dXJl"`,
+			keyId:   "https://remote.example/users/bob#main-key",
+			algo:    "rsa-sha256",
+			headers: "(request-target) host date",
+		},
+		{
+			name:    "reordered parameters and mixed case",
+			header:  `signature="c2lnbmF0dXJl",Headers="(Request-Target) Host Date",KEYID="https://remote.example/users/bob#main-key"`,
+			keyId:   "https://remote.example/users/bob#main-key",
+			headers: "(request-target) host date",
+		},
+		{
+			name:   "headers omitted",
+			header: `keyId="https://remote.example/users/bob#main-key",signature="c2lnbmF0dXJl"`,
+			keyId:  "https://remote.example/users/bob#main-key",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, err := parseSignatureParams(tt.header)
+			if err != nil {
+				t.Fatalf("parseSignatureParams failed: %v", err)
+			}
+			if params.KeyId != tt.keyId {
+				t.Errorf("Expected keyId %q, got %q", tt.keyId, params.KeyId)
+			}
+			if params.Algorithm != tt.algo {
+				t.Errorf("Expected algorithm %q, got %q", tt.algo, params.Algorithm)
+			}
+			if got := strings.Join(params.Headers, " "); got != tt.headers {
+				t.Errorf("Expected headers %q, got %q", tt.headers, got)
+			}
+			if params.Created != tt.created {
+				t.Errorf("Expected created %q, got %q", tt.created, params.Created)
+			}
+			if params.Signature != "c2lnbmF0dXJl" {
+				t.Errorf("Expected signature value, got %q", params.Signature)
+			}
+		})
+	}
+}
+
+func TestParseSignatureParams_Malformed(t *testing.T) {
+	for _, header := range []string{
+		"",
+		`keyId="https://remote.example/users/bob#main-key"`,
+		`signature="c2lnbmF0dXJl"`,
+		`keyId="https://remote.example/users/bob#main-key,signature="c2lnbmF0dXJl`,
+		`keyId="https://remote.example/users/bob#main-key" signature="c2lnbmF0dXJl"`,
+		`keyId`,
+	} {
+		if _, err := parseSignatureParams(header); err == nil {
+			t.Errorf("Expected error for %q", header)
+		}
+	}
+}
+
+// TestVerifyRequest_SignatureHeaderVariants re-formats a valid signature the way other
+// servers do and checks it still verifies
+func TestVerifyRequest_SignatureHeaderVariants(t *testing.T) {
+	privateKey, publicKey, err := generateTestKeyPair()
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+	publicPEM, _ := publicKeyToPEM(publicKey)
+
+	variants := map[string]func(p *signatureParams) string{
+		"spaces after commas": func(p *signatureParams) string {
+			return `keyId="` + p.KeyId + `", algorithm="` + p.Algorithm + `", headers="` + strings.Join(p.Headers, " ") + `", signature="` + p.Signature + `"`
+		},
+		"signature first": func(p *signatureParams) string {
+			return `signature="` + p.Signature + `",headers="` + strings.Join(p.Headers, " ") + `",keyId="` + p.KeyId + `"`
+		},
+		"uppercase names": func(p *signatureParams) string {
+			return `KeyId="` + p.KeyId + `",Algorithm="hs2019",Headers="` + strings.Join(p.Headers, " ") + `",Signature="` + p.Signature + `"`
+		},
+	}
+
+	for name, format := range variants {
+		t.Run(name, func(t *testing.T) {
+			req := newSignedRequest(t, httpsig.RSA_SHA256, privateKey)
+			params, err := parseSignatureParams(req.Header.Get("Signature"))
+			if err != nil {
+				t.Fatalf("parseSignatureParams failed: %v", err)
+			}
+			req.Header.Set("Signature", format(params))
+
+			actorURI, err := VerifyRequest(req, publicPEM)
+			if err != nil {
+				t.Fatalf("Expected valid signature, got %v", err)
+			}
+			if actorURI != "https://remote.example.com/users/bob" {
+				t.Errorf("Expected signer actor URI, got %s", actorURI)
+			}
+		})
+	}
+}
+
+func TestVerifyRequest_MissingDeclaredHeader(t *testing.T) {
+	privateKey, publicKey, err := generateTestKeyPair()
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+	publicPEM, _ := publicKeyToPEM(publicKey)
+
+	req := newSignedRequest(t, httpsig.RSA_SHA256, privateKey)
+	req.Header.Del("Digest")
+
+	if _, err := VerifyRequest(req, publicPEM); err == nil {
+		t.Error("Expected verification to fail when a declared header is missing")
+	}
+}
@@ -3,12 +3,17 @@ package activitypub
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"sort"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/deemkeen/stegodon/domain"
 	"github.com/deemkeen/stegodon/util"
@@ -19,40 +24,160 @@ import (
 type InboxDeps struct {
 	Database   Database
 	HTTPClient HTTPClient
+	Logger     *slog.Logger  // nil uses slog.Default()
+	ClockSkew  time.Duration // Tolerance for request dates; 0 uses the configured clock skew, <0 disables the checks
+
+	// Handlers maps activity types to their handlers, nil uses DefaultInboxHandlers()
+	Handlers map[string]InboxHandlerFunc
+}
+
+// defaultInboxHandlers is shared by all InboxDeps that don't register their own handlers
+var defaultInboxHandlers = DefaultInboxHandlers()
+
+// logger returns the leveled logger for inbox processing
+func (deps *InboxDeps) logger() *slog.Logger {
+	if deps.Logger != nil {
+		return deps.Logger
+	}
+	return slog.Default()
+}
+
+// clockSkew returns the tolerance for the dates of signed requests
+func (deps *InboxDeps) clockSkew() time.Duration {
+	if deps.ClockSkew != 0 {
+		return deps.ClockSkew
+	}
+	return clockSkew
+}
+
+// InboxRequest is a verified incoming activity as passed to its InboxHandlerFunc
+type InboxRequest struct {
+	Body        []byte
+	Username    string                // local recipient of the activity
+	RemoteActor *domain.RemoteAccount // nil if the actor of relay-forwarded content couldn't be fetched
+	IsFromRelay bool
+	SourceRelay *domain.Relay // relay the activity was forwarded by, if known
+	Conf        *util.AppConfig
+}
+
+// InboxHandlerFunc processes one type of incoming activity. Returning an error
+// makes the inbox respond with 500 so the sender retries, unless it wraps
+// ErrActivityRejected.
+type InboxHandlerFunc func(req *InboxRequest, deps *InboxDeps) error
+
+// ErrActivityRejected is wrapped by handler errors for activities refused by policy, such as
+// spoofed authors or over-long posts. They are answered with 422 and not retried, as a
+// retry would be refused all the same.
+var ErrActivityRejected = errors.New("activity rejected")
+
+// DefaultInboxHandlers returns the handlers for the activity types stegodon understands
+func DefaultInboxHandlers() map[string]InboxHandlerFunc {
+	return map[string]InboxHandlerFunc{
+		"Follow": func(req *InboxRequest, deps *InboxDeps) error {
+			return handleFollowActivityWithDeps(req.Body, req.Username, req.RemoteActor, req.Conf, deps)
+		},
+		"Undo": func(req *InboxRequest, deps *InboxDeps) error {
+			return handleUndoActivityWithDeps(req.Body, req.Username, req.RemoteActor, deps)
+		},
+		"Create": func(req *InboxRequest, deps *InboxDeps) error {
+			// The stored activity keeps the truncated body, see HandleInboxWithDeps
+			body, err := limitInboundContent(req.Body, req.Conf)
+			if err != nil {
+				return err
+			}
+			req.Body = body
+			// Only a subscribed relay may sign posts on behalf of their authors; anyone else
+			// has to be the activity's actor, and handleCreateActivityWithDeps checks the author
+			fromRelay := req.SourceRelay != nil
+			if req.IsFromRelay && !fromRelay {
+				deps.logger().Warn("Inbox: Rejecting Create signed by another actor", "component", "inbox", "username", req.Username)
+				return ErrAttributionMismatch
+			}
+			if err := handleCreateActivityWithDeps(req.Body, req.Username, fromRelay, req.Conf, deps); err != nil {
+				return err
+			}
+			if req.SourceRelay != nil {
+				recordRelayActivity(req.SourceRelay, deps.Database)
+			}
+			return nil
+		},
+		"Like": func(req *InboxRequest, deps *InboxDeps) error {
+			return handleLikeActivityWithDeps(req.Body, req.Username, deps)
+		},
+		"EmojiReact": func(req *InboxRequest, deps *InboxDeps) error {
+			return handleEmojiReactActivityWithDeps(req.Body, req.Username, deps)
+		},
+		"Announce": func(req *InboxRequest, deps *InboxDeps) error {
+			return handleAnnounceActivityWithDeps(req.Body, req.Username, req.Conf, deps)
+		},
+		"Accept": func(req *InboxRequest, deps *InboxDeps) error {
+			// Accept activities are confirmations of Follow requests, failing them doesn't fail the request
+			if err := handleAcceptActivityWithDeps(req.Body, req.Username, deps); err != nil {
+				deps.logger().Warn("Inbox: Failed to handle Accept", "component", "inbox", "username", req.Username, "error", err)
+			}
+			return nil
+		},
+		"Reject": func(req *InboxRequest, deps *InboxDeps) error {
+			// Like Accept, failing to apply a Reject doesn't fail the request
+			if err := handleRejectActivityWithDeps(req.Body, req.Username, deps); err != nil {
+				deps.logger().Warn("Inbox: Failed to handle Reject", "component", "inbox", "username", req.Username, "error", err)
+			}
+			return nil
+		},
+		"Update": func(req *InboxRequest, deps *InboxDeps) error {
+			return handleUpdateActivityWithDeps(req.Body, req.Username, req.Conf, deps)
+		},
+		"Delete": func(req *InboxRequest, deps *InboxDeps) error {
+			return handleDeleteActivityWithDeps(req.Body, req.Username, deps)
+		},
+	}
+}
+
+// RegisterHandler sets the handler for an activity type, replacing any existing one.
+// Types without a handler are stored and logged but otherwise ignored.
+func (deps *InboxDeps) RegisterHandler(activityType string, handler InboxHandlerFunc) {
+	if deps.Handlers == nil {
+		deps.Handlers = DefaultInboxHandlers()
+	}
+	deps.Handlers[activityType] = handler
+}
+
+// handler returns the handler registered for an activity type
+func (deps *InboxDeps) handler(activityType string) (InboxHandlerFunc, bool) {
+	handlers := deps.Handlers
+	if handlers == nil {
+		handlers = defaultInboxHandlers
+	}
+	handler, ok := handlers[activityType]
+	return handler, ok
 }
 
 // extractKeyIdFromSignature extracts the keyId from an HTTP Signature header
 // The header format is: keyId="...",algorithm="...",headers="...",signature="..."
 func extractKeyIdFromSignature(signature string) string {
-	// Look for keyId="..." in the signature header
-	for _, part := range strings.Split(signature, ",") {
-		part = strings.TrimSpace(part)
-		if strings.HasPrefix(part, "keyId=") {
-			// Extract the value between quotes
-			value := strings.TrimPrefix(part, "keyId=")
-			value = strings.Trim(value, "\"")
-			return value
-		}
+	params, err := parseSignatureParams(signature)
+	if err != nil {
+		return ""
 	}
-	return ""
+	return params.KeyId
 }
 
 // Activity represents a generic ActivityPub activity
 type Activity struct {
-	Context any    `json:"@context"`
-	ID      string `json:"id"`
-	Type    string `json:"type"`
-	Actor   string `json:"actor"`
-	Object  any    `json:"object"`
+	Context Context `json:"@context"`
+	ID      string  `json:"id"`
+	Type    string  `json:"type"`
+	Actor   string  `json:"actor"`
+	Object  any     `json:"object"`
 }
 
 // FollowActivity represents an ActivityPub Follow activity
 type FollowActivity struct {
-	Context any    `json:"@context"`
-	ID      string `json:"id"`
-	Type    string `json:"type"`
-	Actor   string `json:"actor"`
-	Object  string `json:"object"` // URI of the person being followed
+	Context Context `json:"@context"`
+	ID      string  `json:"id"`
+	Type    string  `json:"type"`
+	Actor   string  `json:"actor"`
+	Object  string  `json:"object"` // URI of the person being followed
 }
 
 // HandleInbox processes incoming ActivityPub activities
@@ -67,10 +192,76 @@ func HandleInbox(w http.ResponseWriter, r *http.Request, username string, conf *
 // HandleInboxWithDeps processes incoming ActivityPub activities.
 // This version accepts dependencies for testing.
 func HandleInboxWithDeps(w http.ResponseWriter, r *http.Request, username string, conf *util.AppConfig, deps *InboxDeps) {
+	handleInboxWithDeps(w, r, username, nil, conf, deps)
+}
+
+// maxInboxBodySize is the largest inbox request body we read, before and after decoding (1MB, to prevent DoS)
+const maxInboxBodySize = 1 * 1024 * 1024
+
+// inboxBody is an inbox request body as sent and after undoing its Content-Encoding
+type inboxBody struct {
+	sent    []byte
+	decoded []byte
+}
+
+// readInboxBody reads and decodes the body of an inbox request. When it fails, the error
+// response has been written and ok is false.
+func readInboxBody(w http.ResponseWriter, r *http.Request, logger *slog.Logger) (body *inboxBody, ok bool) {
+	sent, err := io.ReadAll(io.LimitReader(r.Body, maxInboxBodySize))
+	if err != nil {
+		logger.Warn("Inbox: Failed to read body", "error", err, "status", http.StatusBadRequest)
+		http.Error(w, "Failed to read body", http.StatusBadRequest)
+		return nil, false
+	}
+	defer r.Body.Close()
+
+	// Check if body was truncated (too large)
+	if len(sent) == maxInboxBodySize {
+		logger.Warn("Inbox: Request body too large", "status", http.StatusRequestEntityTooLarge)
+		http.Error(w, "Request too large", http.StatusRequestEntityTooLarge)
+		return nil, false
+	}
+
+	// Compressed bodies are decoded; the Digest is later checked against both forms of the body
+	decoded, err := decodeRequestBody(r.Header.Get("Content-Encoding"), sent, maxInboxBodySize)
+	if err != nil {
+		status := http.StatusBadRequest
+		switch {
+		case errors.Is(err, ErrBodyTooLarge):
+			status = http.StatusRequestEntityTooLarge
+		case errors.Is(err, ErrUnsupportedContentEncoding):
+			status = http.StatusUnsupportedMediaType
+		}
+		logger.Warn("Inbox: Failed to decode body", "error", err, "status", status)
+		http.Error(w, "Failed to decode body", status)
+		return nil, false
+	}
+	return &inboxBody{sent: sent, decoded: decoded}, true
+}
+
+// handleInboxWithDeps processes an activity delivered to username's inbox. received is the
+// request body if it was read already, e.g. by the shared inbox, and nil to read it from r.
+func handleInboxWithDeps(w http.ResponseWriter, r *http.Request, username string, received *inboxBody, conf *util.AppConfig, deps *InboxDeps) {
+	logger := deps.logger().With("component", "inbox", "username", username)
+
+	// Only ActivityStreams documents are processed; the JSON-LD form may carry a profile parameter
+	if contentType := r.Header.Get("Content-Type"); !isInboxContentType(contentType) {
+		logger.Warn("Inbox: Unsupported content type", "content_type", contentType, "status", http.StatusUnsupportedMediaType)
+		http.Error(w, "Unsupported content type", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	// Disabled accounts don't federate: nothing is verified, stored or queued for them
+	if acc, err := deps.Database.ReadAccByUsername(username); err == nil && acc != nil && acc.Disabled {
+		logger.Warn("Inbox: Account is disabled", "status", http.StatusForbidden)
+		http.Error(w, "Account disabled", http.StatusForbidden)
+		return
+	}
+
 	// Verify HTTP signature
 	signature := r.Header.Get("Signature")
 	if signature == "" {
-		log.Printf("Inbox: Missing HTTP signature")
+		logger.Warn("Inbox: Missing HTTP signature", "status", http.StatusUnauthorized)
 		http.Error(w, "Missing signature", http.StatusUnauthorized)
 		return
 	}
@@ -79,65 +270,138 @@ func HandleInboxWithDeps(w http.ResponseWriter, r *http.Request, username string
 	// The signer may be different from the activity actor (e.g., relay forwarding content)
 	signerKeyId := extractKeyIdFromSignature(signature)
 	if signerKeyId == "" {
-		log.Printf("Inbox: Could not extract keyId from signature")
+		logger.Warn("Inbox: Could not extract keyId from signature", "status", http.StatusUnauthorized)
 		http.Error(w, "Invalid signature format", http.StatusUnauthorized)
 		return
 	}
 	signerActorURI := strings.Split(signerKeyId, "#")[0]
+	logger = logger.With("signer", signerActorURI)
 
-	// Read request body with size limit (1MB max to prevent DoS)
-	const maxBodySize = 1 * 1024 * 1024
-	body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize))
-	if err != nil {
-		log.Printf("Inbox: Failed to read body: %v", err)
-		http.Error(w, "Failed to read body", http.StatusBadRequest)
+	// Reject requests dated outside the skew window, so a captured request can't be replayed later
+	sigParams, _ := parseSignatureParams(signature)
+	if err := checkRequestDate(r, sigParams, deps.clockSkew(), time.Now()); err != nil {
+		logger.Warn("Inbox: Request date rejected", "error", err, "status", http.StatusUnauthorized)
+		http.Error(w, "Invalid request date", http.StatusUnauthorized)
 		return
 	}
-	defer r.Body.Close()
 
-	// Check if body was truncated (too large)
-	if len(body) == maxBodySize {
-		log.Printf("Inbox: Request body too large")
-		http.Error(w, "Request too large", http.StatusRequestEntityTooLarge)
+	// The body is only signed through its digest, so nothing is read without a signed one
+	if err := checkSignedDigest(r, sigParams); err != nil {
+		logger.Warn("Inbox: Digest not signed", "error", err, "status", http.StatusUnauthorized)
+		http.Error(w, "Invalid digest", http.StatusUnauthorized)
+		return
+	}
+
+	if received == nil {
+		var ok bool
+		if received, ok = readInboxBody(w, r, logger); !ok {
+			return
+		}
+	}
+	sentBody, body := received.sent, received.decoded
+	if err := verifyBodyDigest(r.Header.Get("Digest"), sentBody, body); err != nil {
+		logger.Warn("Inbox: Digest verification failed", "error", err, "status", http.StatusUnauthorized)
+		http.Error(w, "Invalid digest", http.StatusUnauthorized)
 		return
 	}
 
 	// Parse activity
 	var activity Activity
 	if err := json.Unmarshal(body, &activity); err != nil {
-		log.Printf("Inbox: Failed to parse activity: %v", err)
+		logger.Warn("Inbox: Failed to parse activity", "error", err, "status", http.StatusBadRequest)
 		http.Error(w, "Invalid activity", http.StatusBadRequest)
 		return
 	}
 
-	log.Printf("Inbox: Received %s from %s", activity.Type, activity.Actor)
+	logger = logger.With("actor", activity.Actor, "type", activity.Type, "activity", activity.ID)
+	logger.Info(fmt.Sprintf("Inbox: Received %s from %s", activity.Type, activity.Actor))
 
 	// Fetch the signer's actor (may be different from activity actor for relay-forwarded content)
 	signerActor, err := GetOrFetchActorWithDeps(signerActorURI, deps.HTTPClient, deps.Database)
 	if err != nil {
-		log.Printf("Inbox: Failed to fetch signer actor %s: %v", signerActorURI, err)
+		logger.Warn("Inbox: Failed to fetch signer actor", "error", err, "status", http.StatusBadRequest)
 		http.Error(w, "Failed to verify signer", http.StatusBadRequest)
 		return
 	}
 
 	// Restore body for signature verification (body was consumed during read)
-	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.Body = io.NopCloser(bytes.NewReader(sentBody))
 
-	// Verify HTTP signature with signer's public key
-	_, err = VerifyRequest(r, signerActor.PublicKeyPem)
+	// Verify HTTP signature with the signer's key named by the keyId
+	_, err = VerifyRequestCached(r, signerActor.PublicKeyFor(sigParams.KeyId))
 	if err != nil {
-		log.Printf("Inbox: Signature verification failed: %v", err)
+		logger.Warn("Inbox: Signature verification failed", "error", err, "status", http.StatusUnauthorized)
 		http.Error(w, "Invalid signature", http.StatusUnauthorized)
 		return
 	}
 
+	// Within the window, an activity delivered again with the very same signature is a replay
+	if isReplayedDelivery(username, activity.ID, sigParams.Signature) {
+		logger.Warn("Inbox: Replayed request rejected", "status", http.StatusUnauthorized)
+		http.Error(w, "Replayed request", http.StatusUnauthorized)
+		return
+	}
+
+	// An activity for another account must not end up in this one's inbox
+	if conf != nil && conf.Conf.SslDomain != "" && !isAddressedToInbox(body, username, conf.Conf.SslDomain, deps.Database) {
+		logger.Warn("Inbox: Activity is not addressed to this inbox", "status", http.StatusForbidden)
+		http.Error(w, "Activity not addressed to this inbox", http.StatusForbidden)
+		return
+	}
+
+	// In queue mode the activity is handled by the inbox worker, so the sender gets its answer
+	// without waiting for the handler and a failing handler is retried by us rather than the sender
+	if conf != nil && conf.Conf.InboxQueue {
+		item := &domain.InboxQueueItem{
+			Id:            uuid.New(),
+			Username:      username,
+			SignerURI:     signerActorURI,
+			ActivityJSON:  string(body),
+			NextAttemptAt: time.Now(),
+			CreatedAt:     time.Now(),
+		}
+		if err := deps.Database.EnqueueInboxActivity(item); err != nil {
+			logger.Error("Inbox: Failed to queue activity", "error", err, "status", http.StatusInternalServerError)
+			http.Error(w, "Failed to queue activity", http.StatusInternalServerError)
+			return
+		}
+		rememberDelivery(username, activity.ID, sigParams.Signature)
+		wakeInboxWorker()
+		logger.Debug("Inbox: Activity queued", "status", http.StatusAccepted)
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	if err := processInboxActivityWithDeps(body, activity, username, signerActorURI, signerActor, conf, deps, logger); errors.Is(err, ErrActivityRejected) {
+		rememberDelivery(username, activity.ID, sigParams.Signature)
+		http.Error(w, fmt.Sprintf("%s rejected", activity.Type), http.StatusUnprocessableEntity)
+		return
+	} else if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to process %s", activity.Type), http.StatusInternalServerError)
+		return
+	}
+
+	// Only remember deliveries that were processed, so a retry after a failure still goes through
+	rememberDelivery(username, activity.ID, sigParams.Signature)
+
+	// Return 202 Accepted
+	logger.Debug("Inbox: Activity processed", "status", http.StatusAccepted)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// processInboxActivityWithDeps runs the handler for a received activity with a verified
+// signature and stores the activity as processed. Activities that are skipped, e.g. from a
+// paused relay, are not an error. The activity is stored in the transaction the handler writes
+// in, so when the handler fails nothing is kept and the error is returned.
+func processInboxActivityWithDeps(body []byte, activity Activity, username, signerActorURI string, signerActor *domain.RemoteAccount, conf *util.AppConfig, deps *InboxDeps, logger *slog.Logger) error {
 	// If signer is different from activity actor, also fetch/cache the activity actor
 	var remoteActor *domain.RemoteAccount
+	var err error
 	if signerActorURI != activity.Actor {
-		log.Printf("Inbox: Activity signed by %s on behalf of %s", signerActorURI, activity.Actor)
+		logger.Info(fmt.Sprintf("Inbox: Activity signed by %s on behalf of %s", signerActorURI, activity.Actor))
 		remoteActor, err = GetOrFetchActorWithDeps(activity.Actor, deps.HTTPClient, deps.Database)
 		if err != nil {
-			log.Printf("Inbox: Failed to fetch activity actor %s: %v", activity.Actor, err)
+			logger.Warn("Inbox: Failed to fetch activity actor", "error", err)
 			// For relay content, we can continue without the original actor
 			// The activity will still be processed
 		}
@@ -168,108 +432,121 @@ func HandleInboxWithDeps(w http.ResponseWriter, r *http.Request, username string
 	isFromRelay := signerActorURI != activity.Actor
 
 	// If this is relay content, check if the specific relay is paused
+	var sourceRelay *domain.Relay
 	if isFromRelay {
 		relay := findRelayByActorDomain(signerActorURI, database)
+		sourceRelay = relay
+		if relay != nil && conf != nil && !conf.IsTrustedRelay(signerActorURI) {
+			// Subscribed, but the operator doesn't accept content from this relay
+			logger.Info(fmt.Sprintf("Inbox: Relay content from %s rejected (relay %s is not trusted)", activity.Actor, signerActorURI), "status", http.StatusAccepted)
+			return nil
+		}
 		if relay != nil && relay.Paused {
 			// This specific relay is paused - log but don't save
-			log.Printf("Inbox: Relay content from %s skipped (relay %s is paused)", activity.Actor, relay.ActorURI)
-			w.WriteHeader(http.StatusAccepted)
-			return
+			logger.Info(fmt.Sprintf("Inbox: Relay content from %s skipped (relay %s is paused)", activity.Actor, relay.ActorURI), "status", http.StatusAccepted)
+			return nil
+		}
+		// Raw Creates forwarded by the relay go through its filter like Announced posts
+		if object, ok := activity.Object.(map[string]any); ok && relay != nil && activity.Type == "Create" {
+			if reason := relayFilterRejection(relay.Filter, activity.Actor, object); reason != "" {
+				logger.Info(fmt.Sprintf("Inbox: Relay content from %s filtered out (%s)", activity.Actor, reason), "status", http.StatusAccepted)
+				return nil
+			}
 		}
 	}
 
-	var activityRecord *domain.Activity
+	// A redelivered activity was handled already; the unique activity URI also catches races below
 	if activity.Type != "Announce" {
-		activityRecord = &domain.Activity{
-			Id:           uuid.New(),
-			ActivityURI:  activity.ID,
-			ActivityType: activity.Type,
-			ActorURI:     activity.Actor,
-			ObjectURI:    objectURI,
-			RawJSON:      string(body),
-			Processed:    false,
-			Local:        false,
-			FromRelay:    isFromRelay,
-			CreatedAt:    time.Now(),
-		}
-
-		if err := database.CreateActivity(activityRecord); err != nil {
-			// Check if this is a duplicate (already processed)
-			if strings.Contains(err.Error(), "UNIQUE constraint failed") {
-				log.Printf("Inbox: Activity %s already processed, returning success", activity.ID)
-				w.WriteHeader(http.StatusAccepted)
-				return
-			}
-			log.Printf("Inbox: Failed to store activity: %v", err)
-			// Don't fail the request, we'll process it anyway
+		if existing, err := database.ReadActivityByURI(activity.ID); err == nil && existing != nil {
+			logger.Info("Inbox: Activity already processed, returning success", "status", http.StatusAccepted)
+			return nil
 		}
 	}
 
-	// Process activity based on type
-	switch activity.Type {
-	case "Follow":
-		if err := handleFollowActivityWithDeps(body, username, remoteActor, conf, deps); err != nil {
-			log.Printf("Inbox: Failed to handle Follow: %v", err)
-			http.Error(w, "Failed to process Follow", http.StatusInternalServerError)
-			return
-		}
-	case "Undo":
-		if err := handleUndoActivityWithDeps(body, username, remoteActor, deps); err != nil {
-			log.Printf("Inbox: Failed to handle Undo: %v", err)
-			http.Error(w, "Failed to process Undo", http.StatusInternalServerError)
-			return
-		}
-	case "Create":
-		if err := handleCreateActivityWithDeps(body, username, isFromRelay, deps); err != nil {
-			log.Printf("Inbox: Failed to handle Create: %v", err)
-			http.Error(w, "Failed to process Create", http.StatusInternalServerError)
-			return
-		}
-	case "Like":
-		if err := handleLikeActivityWithDeps(body, username, deps); err != nil {
-			log.Printf("Inbox: Failed to handle Like: %v", err)
-			http.Error(w, "Failed to process Like", http.StatusInternalServerError)
-			return
-		}
-	case "Announce":
-		if err := handleAnnounceActivityWithDeps(body, username, deps); err != nil {
-			log.Printf("Inbox: Failed to handle Announce: %v", err)
-			http.Error(w, "Failed to process Announce", http.StatusInternalServerError)
-			return
-		}
-	case "Accept":
-		// Accept activities are confirmations of Follow requests
-		if err := handleAcceptActivityWithDeps(body, username, deps); err != nil {
-			log.Printf("Inbox: Failed to handle Accept: %v", err)
-			// Don't fail the request
-		}
-	case "Update":
-		if err := handleUpdateActivityWithDeps(body, username, deps); err != nil {
-			log.Printf("Inbox: Failed to handle Update: %v", err)
-			http.Error(w, "Failed to process Update", http.StatusInternalServerError)
-			return
-		}
-	case "Delete":
-		if err := handleDeleteActivityWithDeps(body, username, deps); err != nil {
-			log.Printf("Inbox: Failed to handle Delete: %v", err)
-			http.Error(w, "Failed to process Delete", http.StatusInternalServerError)
-			return
+	req := &InboxRequest{
+		Body:        body,
+		Username:    username,
+		RemoteActor: remoteActor,
+		IsFromRelay: isFromRelay,
+		SourceRelay: sourceRelay,
+		Conf:        conf,
+	}
+	recorder := &activityRecorder{Database: database}
+	if activity.Type != "Announce" {
+		recorder.record = func(tx Tx) error {
+			// Handlers may have rewritten the body by now, e.g. to truncate oversized content
+			return tx.CreateActivity(&domain.Activity{
+				Id:           uuid.New(),
+				ActivityURI:  activity.ID,
+				ActivityType: activity.Type,
+				ActorURI:     activity.Actor,
+				ObjectURI:    objectURI,
+				RawJSON:      string(req.Body),
+				Processed:    true,
+				Local:        false,
+				FromRelay:    isFromRelay,
+				CreatedAt:    time.Now(),
+				Language:     activityLanguage(req.Body),
+			})
 		}
-	default:
-		log.Printf("Inbox: Unsupported activity type: %s", activity.Type)
 	}
 
-	// Mark activity as processed (only if we stored it above - Announce handles its own storage)
-	if activityRecord != nil {
-		activityRecord.Processed = true
-		if err := database.UpdateActivity(activityRecord); err != nil {
-			log.Printf("Inbox: Failed to update activity: %v", err)
-			// Continue anyway, this is not critical
+	// Process activity with the handler registered for its type. Handlers fetch what they need
+	// first and then write in a transaction, which the activity record joins.
+	if handler, ok := deps.handler(activity.Type); !ok {
+		logger.Info("Inbox: Unsupported activity type")
+	} else {
+		handlerDeps := *deps
+		handlerDeps.Database = recorder
+		if err := handler(req, &handlerDeps); errors.Is(err, ErrActivityRejected) {
+			logger.Warn(fmt.Sprintf("Inbox: Rejected %s", activity.Type), "error", err, "status", http.StatusUnprocessableEntity)
+			return err
+		} else if err != nil && !recorder.duplicate {
+			logger.Error(fmt.Sprintf("Inbox: Failed to handle %s", activity.Type), "error", err, "status", http.StatusInternalServerError)
+			return err
+		}
+	}
+	// Handlers that wrote nothing in a transaction, or no handler at all, leave the record to us
+	if !recorder.recorded && !recorder.duplicate {
+		if err := recorder.WithTx(func(tx Tx) error { return nil }); err != nil && !recorder.duplicate {
+			logger.Warn("Inbox: Failed to store activity", "error", err)
+			// Continue anyway, the activity was handled
 		}
 	}
+	if recorder.duplicate {
+		logger.Info("Inbox: Activity already processed, returning success", "status", http.StatusAccepted)
+		return nil
+	}
 
-	// Return 202 Accepted
-	w.WriteHeader(http.StatusAccepted)
+	publishInboxEvent(activity.Type, username, database)
+	return nil
+}
+
+// activityRecorder is the Database inbox handlers work with. The first transaction a handler
+// opens also stores the activity record, so the record is kept exactly when the handler's
+// writes are; network I/O before that transaction doesn't hold the database's write lock.
+type activityRecorder struct {
+	Database
+	record    func(tx Tx) error // nil if the handler stores the activity itself
+	recorded  bool
+	duplicate bool // the activity was stored by a concurrent delivery
+}
+
+func (a *activityRecorder) WithTx(fn func(tx Tx) error) error {
+	if a.record == nil || a.recorded {
+		return a.Database.WithTx(fn)
+	}
+	err := a.Database.WithTx(func(tx Tx) error {
+		if err := a.record(tx); err != nil {
+			if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+				a.duplicate = true
+			}
+			return err
+		}
+		return fn(tx)
+	})
+	a.recorded = err == nil
+	return err
 }
 
 // handleFollowActivity processes a Follow activity
@@ -293,56 +570,95 @@ func handleFollowActivityWithDeps(body []byte, username string, remoteActor *dom
 
 	// Get local account
 	database := deps.Database
-	err, localAccount := database.ReadAccByUsername(username)
+	localAccount, err := database.ReadAccByUsername(username)
 	if err != nil {
 		return fmt.Errorf("local account not found: %w", err)
 	}
 
-	// Check if follow relationship already exists
-	err, existingFollow := database.ReadFollowByAccountIds(remoteActor.Id, localAccount.Id)
-	if err == nil && existingFollow != nil {
-		// Follow already exists, just log and continue to send Accept
-		log.Printf("Inbox: Follow relationship from %s@%s already exists, skipping duplicate", remoteActor.Username, remoteActor.Domain)
-	} else {
-		// Create follow relationship
-		// When remote actor follows local account:
-		// - AccountId = remote actor (the follower)
-		// - TargetAccountId = local account (being followed)
-		followRecord := &domain.Follow{
-			Id:              uuid.New(),
-			AccountId:       remoteActor.Id,  // The follower
-			TargetAccountId: localAccount.Id, // The target being followed
-			URI:             follow.ID,
-			Accepted:        true, // Auto-accept for now
-			CreatedAt:       time.Now(),
+	// Follows from blocked actors and servers are rejected without being stored
+	if conf.IsBlockedActor(remoteActor.ActorURI) {
+		log.Printf("Inbox: Rejecting Follow from blocked actor %s", remoteActor.ActorURI)
+		if err := SendRejectWithDeps(localAccount, remoteActor, follow.ID, conf, deps.HTTPClient); err != nil {
+			return fmt.Errorf("failed to send Reject: %w", err)
 		}
+		return nil
+	}
 
-		if err := database.CreateFollow(followRecord); err != nil {
-			return fmt.Errorf("failed to create follow: %w", err)
-		}
+	// Check if follow relationship already exists
+	existingFollow, err := database.ReadFollowByAccountIds(remoteActor.Id, localAccount.Id)
+	alreadyFollowing := err == nil && existingFollow != nil
+	if alreadyFollowing && !existingFollow.Accepted {
+		// Still waiting for approval, the Accept is sent once the user approves it
+		log.Printf("Inbox: Follow request from %s@%s is already pending", remoteActor.Username, remoteActor.Domain)
+		return nil
+	}
+
+	// Store the follow and queue the Accept together, so a failure leaves neither behind
+	pending := false
+	err = database.WithTx(func(tx Tx) error {
+		if alreadyFollowing {
+			// Follow already exists, just log and continue to send Accept
+			log.Printf("Inbox: Follow relationship from %s@%s already exists, skipping duplicate", remoteActor.Username, remoteActor.Domain)
+		} else {
+			// Create follow relationship
+			// When remote actor follows local account:
+			// - AccountId = remote actor (the follower)
+			// - TargetAccountId = local account (being followed)
+			// Accounts that manually approve followers keep the follow pending until AcceptPendingFollow
+			followRecord := &domain.Follow{
+				Id:              uuid.New(),
+				AccountId:       remoteActor.Id,  // The follower
+				TargetAccountId: localAccount.Id, // The target being followed
+				URI:             follow.ID,
+				Accepted:        !localAccount.ManuallyApprovesFollowers,
+				CreatedAt:       time.Now(),
+			}
+
+			if err := tx.CreateFollow(followRecord); err != nil {
+				return fmt.Errorf("failed to create follow: %w", err)
+			}
+
+			notificationType := domain.NotificationFollow
+			if !followRecord.Accepted {
+				notificationType = domain.NotificationFollowRequest
+			}
+
+			// Create notification for the followed user
+			notification := &domain.Notification{
+				Id:               uuid.New(),
+				AccountId:        localAccount.Id, // The local user being followed
+				NotificationType: notificationType,
+				ActorId:          remoteActor.Id,
+				ActorUsername:    remoteActor.Username,
+				ActorDomain:      remoteActor.Domain,
+				Read:             false,
+				CreatedAt:        time.Now(),
+			}
+			if err := tx.CreateNotification(notification); err != nil {
+				log.Printf("Inbox: Failed to create follow notification: %v", err)
+				// Don't fail the request for notification errors
+			}
 
-		// Create notification for the followed user
-		notification := &domain.Notification{
-			Id:               uuid.New(),
-			AccountId:        localAccount.Id, // The local user being followed
-			NotificationType: domain.NotificationFollow,
-			ActorId:          remoteActor.Id,
-			ActorUsername:    remoteActor.Username,
-			ActorDomain:      remoteActor.Domain,
-			Read:             false,
-			CreatedAt:        time.Now(),
+			if !followRecord.Accepted {
+				pending = true
+				return nil
+			}
 		}
-		if err := database.CreateNotification(notification); err != nil {
-			log.Printf("Inbox: Failed to create follow notification: %v", err)
-			// Don't fail the request for notification errors
+
+		// Queue the Accept activity
+		if err := QueueAccept(localAccount, remoteActor, follow.ID, conf, tx); err != nil {
+			return fmt.Errorf("failed to queue Accept: %w", err)
 		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
-	// Send Accept activity
-	if err := SendAcceptWithDeps(localAccount, remoteActor, follow.ID, conf, deps.HTTPClient); err != nil {
-		return fmt.Errorf("failed to send Accept: %w", err)
+	if pending {
+		log.Printf("Inbox: Follow request from %s@%s is pending approval", remoteActor.Username, remoteActor.Domain)
+		return nil
 	}
-
 	log.Printf("Inbox: Accepted follow from %s@%s", remoteActor.Username, remoteActor.Domain)
 	return nil
 }
@@ -371,9 +687,10 @@ func handleUndoActivityWithDeps(body []byte, username string, remoteActor *domai
 
 	// Parse the embedded object
 	var obj struct {
-		Type   string `json:"type"`
-		ID     string `json:"id"`
-		Object string `json:"object"` // For Like, this is the URI of the liked note
+		Type    string `json:"type"`
+		ID      string `json:"id"`
+		Object  string `json:"object"`  // For Like, this is the URI of the liked note
+		Content string `json:"content"` // For EmojiReact and Misskey reactions, the emoji
 	}
 	if err := json.Unmarshal(undo.Object, &obj); err != nil {
 		return fmt.Errorf("failed to parse Undo object: %w", err)
@@ -381,11 +698,30 @@ func handleUndoActivityWithDeps(body []byte, username string, remoteActor *domai
 
 	database := deps.Database
 
-	if obj.Type == "Follow" {
+	if obj.Type == "EmojiReact" || (obj.Type == "Like" && obj.Content != "") {
+		// Handle Undo of an emoji reaction, removing only that emoji
+		if remoteActor.ActorURI != undo.Actor {
+			return fmt.Errorf("unauthorized: actor %s cannot undo reaction", undo.Actor)
+		}
+
+		var err error
+		emoji := strings.TrimSpace(obj.Content)
+		if emoji != "" && obj.Object != "" {
+			err = database.DeleteReaction(undo.Actor, obj.Object, emoji)
+		} else {
+			err = database.DeleteReactionByURI(undo.Actor, obj.ID)
+		}
+		if err != nil {
+			log.Printf("Inbox: Failed to remove reaction: %v", err)
+			return nil // Don't fail if reaction doesn't exist
+		}
+
+		log.Printf("Inbox: Removed reaction %s from %s@%s on %s", emoji, remoteActor.Username, remoteActor.Domain, obj.Object)
+	} else if obj.Type == "Follow" {
 		// Verify authorization: Undo actor must match Follow actor
 
 		// Fetch the follow to verify ownership
-		err, follow := database.ReadFollowByURI(obj.ID)
+		follow, err := database.ReadFollowByURI(obj.ID)
 		if err != nil {
 			return fmt.Errorf("follow not found: %w", err)
 		}
@@ -395,7 +731,7 @@ func handleUndoActivityWithDeps(body []byte, username string, remoteActor *domai
 
 		// Verify the Undo actor matches the Follow actor
 		// For remote follows, the AccountId is the remote actor who created the follow
-		err, followActor := database.ReadRemoteAccountById(follow.AccountId)
+		followActor, err := database.ReadRemoteAccountById(follow.AccountId)
 		if err != nil || followActor == nil {
 			return fmt.Errorf("follow actor not found")
 		}
@@ -411,7 +747,7 @@ func handleUndoActivityWithDeps(body []byte, username string, remoteActor *domai
 	} else if obj.Type == "Like" {
 		// Handle Undo Like
 		// Find the note being unliked
-		err, note := database.ReadNoteByURI(obj.Object)
+		note, err := database.ReadNoteByURI(obj.Object)
 		if err != nil || note == nil {
 			log.Printf("Inbox: Note not found for Undo Like object %s", obj.Object)
 			return nil // Not an error - note might not exist locally
@@ -422,22 +758,26 @@ func handleUndoActivityWithDeps(body []byte, username string, remoteActor *domai
 			return fmt.Errorf("unauthorized: actor %s cannot undo like", undo.Actor)
 		}
 
-		// Delete the like
-		if err := database.DeleteLikeByAccountAndNote(remoteActor.Id, note.Id); err != nil {
-			log.Printf("Inbox: Failed to delete like: %v", err)
+		// Delete the like and decrement the like count together
+		err = database.WithTx(func(tx Tx) error {
+			if err := tx.DeleteLikeByAccountAndNote(remoteActor.Id, note.Id); err != nil {
+				return fmt.Errorf("failed to delete like: %w", err)
+			}
+			if err := tx.DecrementLikeCountByNoteId(note.Id); err != nil {
+				return fmt.Errorf("failed to decrement like count: %w", err)
+			}
+			return nil
+		})
+		if err != nil {
+			log.Printf("Inbox: Failed to remove like: %v", err)
 			return nil // Don't fail if like doesn't exist
 		}
 
-		// Decrement like count
-		if err := database.DecrementLikeCountByNoteId(note.Id); err != nil {
-			log.Printf("Inbox: Failed to decrement like count: %v", err)
-		}
-
 		log.Printf("Inbox: Removed like from %s@%s on note %s", remoteActor.Username, remoteActor.Domain, note.Id)
 	} else if obj.Type == "Announce" {
 		// Handle Undo Announce (unboost)
 		// Find the note being unboosted
-		err, note := database.ReadNoteByURI(obj.Object)
+		note, err := database.ReadNoteByURI(obj.Object)
 		if err != nil || note == nil {
 			log.Printf("Inbox: Note not found for Undo Announce object %s", obj.Object)
 			return nil // Not an error - note might not exist locally
@@ -448,51 +788,90 @@ func handleUndoActivityWithDeps(body []byte, username string, remoteActor *domai
 			return fmt.Errorf("unauthorized: actor %s cannot undo boost", undo.Actor)
 		}
 
-		// Delete the boost
-		if err := database.DeleteBoostByAccountAndNote(remoteActor.Id, note.Id); err != nil {
-			log.Printf("Inbox: Failed to delete boost: %v", err)
+		// Delete the boost and decrement the boost count together
+		err = database.WithTx(func(tx Tx) error {
+			if err := tx.DeleteBoostByAccountAndNote(remoteActor.Id, note.Id); err != nil {
+				return fmt.Errorf("failed to delete boost: %w", err)
+			}
+			if err := tx.DecrementBoostCountByNoteId(note.Id); err != nil {
+				return fmt.Errorf("failed to decrement boost count: %w", err)
+			}
+			return nil
+		})
+		if err != nil {
+			log.Printf("Inbox: Failed to remove boost: %v", err)
 			return nil // Don't fail if boost doesn't exist
 		}
 
-		// Decrement boost count
-		if err := database.DecrementBoostCountByNoteId(note.Id); err != nil {
-			log.Printf("Inbox: Failed to decrement boost count: %v", err)
-		}
-
 		log.Printf("Inbox: Removed boost from %s@%s on note %s", remoteActor.Username, remoteActor.Domain, note.Id)
 	}
 
 	return nil
 }
 
+// inboundTag is an entry of an incoming object's tag array (Mention, Hashtag, ...)
+type inboundTag struct {
+	Type string `json:"type"`
+	Href string `json:"href"`
+	Name string `json:"name"`
+}
+
+// ErrAttributionMismatch is returned for a Create whose object is attributed to another actor
+var ErrAttributionMismatch = fmt.Errorf("%w: object attributed to another actor", ErrActivityRejected)
+
+// isAttributedTo reports whether an object's attributedTo (a URI, an object with an id, or
+// a list of either) names actor. Objects that don't claim an author are attributed to anyone.
+func isAttributedTo(attributedTo any, actor string) bool {
+	switch v := attributedTo.(type) {
+	case nil:
+		return true
+	case string:
+		return v == actor
+	case map[string]any:
+		id, _ := v["id"].(string)
+		return id == actor
+	case []any:
+		if len(v) == 0 {
+			return true
+		}
+		for _, item := range v {
+			if item != nil && isAttributedTo(item, actor) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // handleCreateActivity processes a Create activity (incoming post/note)
-func handleCreateActivity(body []byte, username string, isFromRelay bool) error {
+func handleCreateActivity(body []byte, username string, isFromRelay bool, conf *util.AppConfig) error {
 	deps := &InboxDeps{
 		Database:   NewDBWrapper(),
 		HTTPClient: defaultHTTPClient,
 	}
-	return handleCreateActivityWithDeps(body, username, isFromRelay, deps)
+	return handleCreateActivityWithDeps(body, username, isFromRelay, conf, deps)
 }
 
 // handleCreateActivityWithDeps processes a Create activity (incoming post/note).
+// Posts from actors no local account follows are handled by conf's InboundCreatePolicy.
 // This version accepts dependencies for testing.
-func handleCreateActivityWithDeps(body []byte, username string, isFromRelay bool, deps *InboxDeps) error {
+func handleCreateActivityWithDeps(body []byte, username string, isFromRelay bool, conf *util.AppConfig, deps *InboxDeps) error {
 	var create struct {
 		ID     string `json:"id"`
 		Type   string `json:"type"`
 		Actor  string `json:"actor"`
+		To     any    `json:"to"`
+		Cc     any    `json:"cc"`
 		Object struct {
-			ID           string `json:"id"`
-			Type         string `json:"type"`
-			Content      string `json:"content"`
-			Published    string `json:"published"`
-			AttributedTo string `json:"attributedTo"`
-			InReplyTo    string `json:"inReplyTo"`
-			Tag          []struct {
-				Type string `json:"type"`
-				Href string `json:"href"`
-				Name string `json:"name"`
-			} `json:"tag"`
+			ID           string       `json:"id"`
+			Type         string       `json:"type"`
+			Content      string       `json:"content"`
+			Published    string       `json:"published"`
+			AttributedTo any          `json:"attributedTo"`
+			InReplyTo    string       `json:"inReplyTo"`
+			To           any          `json:"to"`
+			Cc           any          `json:"cc"`
+			Tag          []inboundTag `json:"tag"`
 		} `json:"object"`
 	}
 
@@ -502,15 +881,37 @@ func handleCreateActivityWithDeps(body []byte, username string, isFromRelay bool
 
 	log.Printf("Inbox: Received post from %s", create.Actor)
 
+	// Without a relay in between, the actor signed the request and has to be the post's author;
+	// a subscribed relay forwards posts of other actors and is the exception
+	if !isFromRelay && !isAttributedTo(create.Object.AttributedTo, create.Actor) {
+		log.Printf("Inbox: Rejecting post %s from %s: attributed to %v", create.Object.ID, create.Actor, create.Object.AttributedTo)
+		return ErrAttributionMismatch
+	}
+
+	if exceedsInboundNoteChars(create.Object.Content, conf) {
+		log.Printf("Inbox: Rejecting post %s from %s: longer than %d characters", create.Object.ID, create.Actor, conf.InboundNoteCharLimit())
+		return fmt.Errorf("%w: post exceeds %d characters", ErrActivityRejected, conf.InboundNoteCharLimit())
+	}
+
 	// Log if this is a reply
 	if create.Object.InReplyTo != "" {
 		log.Printf("Inbox: Post is a reply to %s", create.Object.InReplyTo)
 	}
 
+	quoteURI := quotedObjectURI(body)
+	if quoteURI != "" {
+		log.Printf("Inbox: Post quotes %s", quoteURI)
+	}
+
 	database := deps.Database
 
+	localDomain := ""
+	if conf != nil {
+		localDomain = conf.Conf.SslDomain
+	}
+
 	// Get the local account
-	err, localAccount := database.ReadAccByUsername(username)
+	localAccount, err := database.ReadAccByUsername(username)
 	if err != nil {
 		log.Printf("Inbox: Failed to get local account %s: %v", username, err)
 		return fmt.Errorf("failed to get local account: %w", err)
@@ -518,7 +919,7 @@ func handleCreateActivityWithDeps(body []byte, username string, isFromRelay bool
 	log.Printf("Inbox: Local account: %s (ID: %s)", localAccount.Username, localAccount.Id)
 
 	// Get the remote actor (try cache first, fetch if not found)
-	err, remoteActor := database.ReadRemoteAccountByActorURI(create.Actor)
+	remoteActor, err := database.ReadRemoteAccountByActorURI(create.Actor)
 	if err != nil || remoteActor == nil {
 		// Not in cache, try to fetch it
 		log.Printf("Inbox: Actor %s not cached, fetching...", create.Actor)
@@ -531,7 +932,7 @@ func handleCreateActivityWithDeps(body []byte, username string, isFromRelay bool
 	log.Printf("Inbox: Remote actor: %s@%s (ID: %s)", remoteActor.Username, remoteActor.Domain, remoteActor.Id)
 
 	// Check if we follow this actor (skip for relay content - isFromRelay is set when signer != actor)
-	err, follow := database.ReadFollowByAccountIds(localAccount.Id, remoteActor.Id)
+	follow, err := database.ReadFollowByAccountIds(localAccount.Id, remoteActor.Id)
 	isFollowing := err == nil && follow != nil
 
 	if isFollowing {
@@ -544,7 +945,7 @@ func handleCreateActivityWithDeps(body []byte, username string, isFromRelay bool
 		isReplyToOurPost := false
 		if create.Object.InReplyTo != "" {
 			// Check if the parent post belongs to the local user
-			err, parentNote := database.ReadNoteByURI(create.Object.InReplyTo)
+			parentNote, err := database.ReadNoteByURI(create.Object.InReplyTo)
 			if err == nil && parentNote != nil && parentNote.CreatedBy == username {
 				isReplyToOurPost = true
 				log.Printf("Inbox: This is a reply to our post, accepting without follow check")
@@ -552,127 +953,205 @@ func handleCreateActivityWithDeps(body []byte, username string, isFromRelay bool
 		}
 
 		if !isReplyToOurPost {
-			log.Printf("Inbox: Rejecting Create from %s - not following and not a reply to our post", create.Actor)
-			return fmt.Errorf("not following this actor")
+			switch inboundCreatePolicy(conf) {
+			case util.CreatePolicyStoreAll:
+				log.Printf("Inbox: Storing Create from non-followed %s (policy %s)", create.Actor, util.CreatePolicyStoreAll)
+			case util.CreatePolicyStoreIfMentioned:
+				if !mentionsLocalAccount(create.Object.Tag, localDomain, database) {
+					log.Printf("Inbox: Rejecting Create from %s - not following and no local account mentioned", create.Actor)
+					return fmt.Errorf("not following this actor")
+				}
+				log.Printf("Inbox: Storing Create from non-followed %s as a mention", create.Actor)
+			default:
+				log.Printf("Inbox: Rejecting Create from %s - not following and not a reply to our post", create.Actor)
+				return fmt.Errorf("not following this actor")
+			}
 		}
 	}
 
-	// Increment reply count on the parent post if this is a reply
-	// But skip if this activity is a duplicate of a local note (our own post coming back via federation)
-	if create.Object.InReplyTo != "" {
-		// Check if this activity's object_uri matches an existing local note
-		// This happens when our own post is federated out and comes back
-		err, existingNote := database.ReadNoteByURI(create.Object.ID)
-		isDuplicate := err == nil && existingNote != nil
+	// Notifications preview the post; Article and Page posts start with their title
+	title, contentHTML := util.RemotePostBody(string(body))
+	notePreview := util.StripHTMLTags(contentHTML)
+	if title != "" {
+		notePreview = strings.TrimSpace(title + " — " + notePreview)
+	}
+	if len(notePreview) > 100 {
+		notePreview = notePreview[:100] + "..."
+	}
 
-		if isDuplicate {
-			log.Printf("Inbox: Skipping reply count increment - activity %s is a duplicate of local note", create.Object.ID)
-		} else {
-			if err := database.IncrementReplyCountByURI(create.Object.InReplyTo); err != nil {
-				log.Printf("Inbox: Failed to increment reply count for %s: %v", create.Object.InReplyTo, err)
-				// Don't fail the activity processing for this
+	// The quoted post may have to be fetched, which is done before the transaction
+	quoteAuthor, quoteContent := "", ""
+	if quoteURI != "" {
+		quoteAuthor, quoteContent = resolveQuotedPost(quoteURI, deps)
+	}
+
+	// Store the reply count, mentions, hashtags and quote together; notifications are best effort
+	err = database.WithTx(func(tx Tx) error {
+		// Increment reply count on the parent post if this is a reply
+		// But skip if this activity is a duplicate of a local note (our own post coming back via federation)
+		// Some servers also put the quoted post in inReplyTo; a quote is not counted as a reply
+		if create.Object.InReplyTo != "" && create.Object.InReplyTo != quoteURI {
+			// Check if this activity's object_uri matches an existing local note
+			// This happens when our own post is federated out and comes back
+			existingNote, err := tx.ReadNoteByURI(create.Object.ID)
+			isDuplicate := err == nil && existingNote != nil
+
+			if isDuplicate {
+				log.Printf("Inbox: Skipping reply count increment - activity %s is a duplicate of local note", create.Object.ID)
 			} else {
+				if err := tx.IncrementReplyCountByURI(create.Object.InReplyTo); err != nil {
+					return fmt.Errorf("failed to increment reply count for %s: %w", create.Object.InReplyTo, err)
+				}
 				log.Printf("Inbox: Incremented reply count for %s", create.Object.InReplyTo)
-			}
 
-			// Create reply notification for the parent note author
-			err, parentNote := database.ReadNoteByURI(create.Object.InReplyTo)
-			if err == nil && parentNote != nil {
-				err, parentAuthor := database.ReadAccByUsername(parentNote.CreatedBy)
-				if err == nil && parentAuthor != nil {
-					// Extract plain text preview from HTML content
-					preview := util.StripHTMLTags(create.Object.Content)
-					if len(preview) > 100 {
-						preview = preview[:100] + "..."
-					}
-					notification := &domain.Notification{
-						Id:               uuid.New(),
-						AccountId:        parentAuthor.Id,
-						NotificationType: domain.NotificationReply,
-						ActorId:          remoteActor.Id,
-						ActorUsername:    remoteActor.Username,
-						ActorDomain:      remoteActor.Domain,
-						NoteURI:          create.Object.ID,
-						NotePreview:      preview,
-						Read:             false,
-						CreatedAt:        time.Now(),
-					}
-					if err := database.CreateNotification(notification); err != nil {
-						log.Printf("Inbox: Failed to create reply notification: %v", err)
+				// Create reply notification for the parent note author
+				parentNote, err := tx.ReadNoteByURI(create.Object.InReplyTo)
+				if err == nil && parentNote != nil {
+					parentAuthor, err := tx.ReadAccByUsername(parentNote.CreatedBy)
+					if err == nil && parentAuthor != nil {
+						notification := &domain.Notification{
+							Id:               uuid.New(),
+							AccountId:        parentAuthor.Id,
+							NotificationType: domain.NotificationReply,
+							ActorId:          remoteActor.Id,
+							ActorUsername:    remoteActor.Username,
+							ActorDomain:      remoteActor.Domain,
+							NoteURI:          create.Object.ID,
+							NotePreview:      notePreview,
+							Read:             false,
+							CreatedAt:        time.Now(),
+						}
+						if err := tx.CreateNotification(notification); err != nil {
+							log.Printf("Inbox: Failed to create reply notification: %v", err)
+						}
 					}
 				}
 			}
 		}
-	}
 
-	// Process tags (hashtags and mentions) from the incoming activity
-	// Store mentions in the database for future notification support
-	if len(create.Object.Tag) > 0 {
-		// Get the activity record to link mentions to it
-		err, activityRecord := database.ReadActivityByObjectURI(create.Object.ID)
-		if err != nil || activityRecord == nil {
-			log.Printf("Inbox: Could not find activity record for %s, skipping mention storage", create.Object.ID)
-		}
-
-		for _, tag := range create.Object.Tag {
-			switch tag.Type {
-			case "Mention":
-				log.Printf("Inbox: Post mentions %s (%s)", tag.Name, tag.Href)
-
-				// Store the mention in the database
-				if activityRecord != nil {
-					// Parse username and domain from @username@domain format
-					mentionName := strings.TrimPrefix(tag.Name, "@")
-					parts := strings.SplitN(mentionName, "@", 2)
-					if len(parts) == 2 {
-						mention := &domain.NoteMention{
-							Id:                uuid.New(),
-							NoteId:            activityRecord.Id, // Use activity ID as the note reference
-							MentionedActorURI: tag.Href,
-							MentionedUsername: parts[0],
-							MentionedDomain:   parts[1],
-							CreatedAt:         time.Now(),
+		// Process tags (hashtags and mentions) from the incoming activity
+		// Store mentions in the database so we know who a federated post mentions
+		if len(create.Object.Tag) > 0 {
+			// Get the activity record to link mentions to it
+			activityRecord, err := tx.ReadActivityByObjectURI(create.Object.ID)
+			if err != nil || activityRecord == nil {
+				log.Printf("Inbox: Could not find activity record for %s, skipping mention storage", create.Object.ID)
+			}
+
+			if localDomain == "" {
+				if conf, confErr := util.ReadConf(); confErr == nil && conf != nil {
+					localDomain = conf.Conf.SslDomain
+				}
+			}
+
+			seenMentions := make(map[string]bool)
+			var hashtagNames []string
+			for _, tag := range create.Object.Tag {
+				switch tag.Type {
+				case "Mention":
+					log.Printf("Inbox: Post mentions %s (%s)", tag.Name, tag.Href)
+					if activityRecord == nil {
+						continue
+					}
+
+					mentionedUsername, mentionedDomain := resolveInboundMention(tag.Name, tag.Href, localDomain, tx)
+					if mentionedUsername == "" || mentionedDomain == "" {
+						log.Printf("Inbox: Could not resolve mention %s (%s), skipping", tag.Name, tag.Href)
+						continue
+					}
+					mentionKey := strings.ToLower(mentionedUsername + "@" + mentionedDomain)
+					if seenMentions[mentionKey] {
+						continue
+					}
+					seenMentions[mentionKey] = true
+
+					mention := &domain.NoteMention{
+						Id:                uuid.New(),
+						NoteId:            activityRecord.Id, // Use activity ID as the note reference
+						MentionedActorURI: tag.Href,
+						MentionedUsername: mentionedUsername,
+						MentionedDomain:   mentionedDomain,
+						CreatedAt:         time.Now(),
+					}
+					if err := tx.CreateNoteMention(mention); err != nil {
+						return fmt.Errorf("failed to store mention %s: %w", mentionKey, err)
+					}
+					log.Printf("Inbox: Stored mention %s for activity %s", mentionKey, activityRecord.Id)
+
+					// Create notification if the mentioned user is local
+					if localDomain == "" || !strings.EqualFold(mentionedDomain, localDomain) {
+						continue
+					}
+					mentionedUser, err := tx.ReadAccByUsername(mentionedUsername)
+					if err == nil && mentionedUser != nil {
+						notification := &domain.Notification{
+							Id:               uuid.New(),
+							AccountId:        mentionedUser.Id,
+							NotificationType: domain.NotificationMention,
+							ActorId:          remoteActor.Id,
+							ActorUsername:    remoteActor.Username,
+							ActorDomain:      remoteActor.Domain,
+							NoteURI:          create.Object.ID,
+							NotePreview:      notePreview,
+							Read:             false,
+							CreatedAt:        time.Now(),
 						}
-						if err := database.CreateNoteMention(mention); err != nil {
-							log.Printf("Inbox: Failed to store mention %s: %v", tag.Name, err)
-						} else {
-							log.Printf("Inbox: Stored mention %s for activity %s", tag.Name, activityRecord.Id)
-
-							// Create notification if the mentioned user is local
-							// Need to check if this domain matches our local domain
-							conf, confErr := util.ReadConf()
-							if confErr == nil && conf != nil && parts[1] == conf.Conf.SslDomain {
-								err, mentionedUser := database.ReadAccByUsername(parts[0])
-								if err == nil && mentionedUser != nil {
-									preview := util.StripHTMLTags(create.Object.Content)
-									if len(preview) > 100 {
-										preview = preview[:100] + "..."
-									}
-									notification := &domain.Notification{
-										Id:               uuid.New(),
-										AccountId:        mentionedUser.Id,
-										NotificationType: domain.NotificationMention,
-										ActorId:          remoteActor.Id,
-										ActorUsername:    remoteActor.Username,
-										ActorDomain:      remoteActor.Domain,
-										NoteURI:          create.Object.ID,
-										NotePreview:      preview,
-										Read:             false,
-										CreatedAt:        time.Now(),
-									}
-									if err := database.CreateNotification(notification); err != nil {
-										log.Printf("Inbox: Failed to create mention notification: %v", err)
-									}
-								}
-							}
+						if err := tx.CreateNotification(notification); err != nil {
+							log.Printf("Inbox: Failed to create mention notification: %v", err)
 						}
 					}
+				case "Hashtag":
+					log.Printf("Inbox: Post contains hashtag %s", tag.Name)
+					hashtagNames = append(hashtagNames, "#"+strings.TrimPrefix(tag.Name, "#"))
+				}
+			}
+
+			// Link hashtags so the post shows up in federated hashtag timelines.
+			// Parsing normalizes and deduplicates the names the same way as local notes.
+			hashtags := util.ParseHashtags(strings.Join(hashtagNames, " "))
+			if activityRecord != nil && len(hashtags) > 0 {
+				if err := tx.LinkActivityHashtags(activityRecord.Id, hashtags); err != nil {
+					return fmt.Errorf("failed to link hashtags for activity %s: %w", activityRecord.Id, err)
 				}
-			case "Hashtag":
-				// Hashtags are already included in the stored activity raw JSON
-				log.Printf("Inbox: Post contains hashtag %s", tag.Name)
 			}
 		}
+
+		// Notify the follower of a top-level post if they turned on notifications for this
+		// follow; direct messages only notify the mentioned accounts
+		if isFollowing && follow.Accepted && follow.Notify && create.Object.InReplyTo == "" &&
+			isPublicOrFollowersPost(create.To, create.Cc, create.Object.To, create.Object.Cc) {
+			notification := &domain.Notification{
+				Id:               uuid.New(),
+				AccountId:        localAccount.Id,
+				NotificationType: domain.NotificationPost,
+				ActorId:          remoteActor.Id,
+				ActorUsername:    remoteActor.Username,
+				ActorDomain:      remoteActor.Domain,
+				NoteURI:          create.Object.ID,
+				NotePreview:      notePreview,
+				Read:             false,
+				CreatedAt:        time.Now(),
+			}
+			if err := tx.CreateNotification(notification); err != nil {
+				log.Printf("Inbox: Failed to create post notification: %v", err)
+			}
+		}
+
+		// Record the quoted post on the activity so timelines can show what it quotes
+		if quoteURI != "" {
+			activityRecord, err := tx.ReadActivityByObjectURI(create.Object.ID)
+			if err != nil || activityRecord == nil {
+				log.Printf("Inbox: Could not find activity record for %s, skipping quote", create.Object.ID)
+			} else {
+				if err := tx.UpdateActivityQuote(activityRecord.Id, quoteURI, quoteAuthor, quoteContent); err != nil {
+					return fmt.Errorf("failed to store quote of %s for activity %s: %w", quoteURI, activityRecord.Id, err)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
 	// Note: Activity is already stored in HandleInbox before this function is called
@@ -681,6 +1160,268 @@ func handleCreateActivityWithDeps(body []byte, username string, isFromRelay bool
 	return nil
 }
 
+// isPublicOrFollowersPost reports whether a post is addressed to the public or to the author's
+// followers, given its to and cc fields (each a string or an array), rather than being a
+// direct message to the mentioned accounts only
+func isPublicOrFollowersPost(audiences ...any) bool {
+	for _, audience := range audiences {
+		var recipients []any
+		switch a := audience.(type) {
+		case string:
+			recipients = []any{a}
+		case []any:
+			recipients = a
+		}
+		for _, r := range recipients {
+			recipient, _ := r.(string)
+			switch {
+			case recipient == "https://www.w3.org/ns/activitystreams#Public", recipient == "as:Public", recipient == "Public":
+				return true
+			case strings.HasSuffix(recipient, "/followers"):
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// quotedObjectURI returns the URI of the post a Create's object quotes, or "" if it quotes
+// nothing. Supports FEP-044f (quote), Fedibird/Akkoma (quoteUri, quoteUrl), Misskey
+// (_misskey_quote) and FEP-e232 object links in the tag array.
+func quotedObjectURI(body []byte) string {
+	var create struct {
+		Object struct {
+			Quote        any `json:"quote"`
+			QuoteURI     any `json:"quoteUri"`
+			QuoteURL     any `json:"quoteUrl"`
+			MisskeyQuote any `json:"_misskey_quote"`
+			Tag          []struct {
+				Type      string `json:"type"`
+				Href      string `json:"href"`
+				MediaType string `json:"mediaType"`
+			} `json:"tag"`
+		} `json:"object"`
+	}
+	if err := json.Unmarshal(body, &create); err != nil {
+		return ""
+	}
+
+	for _, field := range []any{create.Object.Quote, create.Object.QuoteURI, create.Object.QuoteURL, create.Object.MisskeyQuote} {
+		switch quote := field.(type) {
+		case string:
+			if quote != "" {
+				return quote
+			}
+		case map[string]any:
+			// Quote given as an embedded object
+			if id, ok := quote["id"].(string); ok && id != "" {
+				return id
+			}
+		}
+	}
+
+	// FEP-e232: a Link tag whose media type is an ActivityStreams object
+	for _, tag := range create.Object.Tag {
+		if tag.Type == "Link" && tag.Href != "" &&
+			(strings.Contains(tag.MediaType, "activity+json") || strings.Contains(tag.MediaType, "https://www.w3.org/ns/activitystreams")) {
+			return tag.Href
+		}
+	}
+
+	return ""
+}
+
+// resolveQuotedPost returns the author and plain-text content of a quoted post. Posts already
+// stored as activities or local notes are used first; others are fetched from their server.
+// Returns empty strings if the post can't be resolved.
+func resolveQuotedPost(quoteURI string, deps *InboxDeps) (string, string) {
+	database := deps.Database
+
+	if activity, err := database.ReadActivityByObjectURI(quoteURI); err == nil && activity != nil {
+		var stored struct {
+			Object struct {
+				Content string `json:"content"`
+			} `json:"object"`
+		}
+		json.Unmarshal([]byte(activity.RawJSON), &stored)
+		author := ""
+		if actor, err := database.ReadRemoteAccountByActorURI(activity.ActorURI); err == nil && actor != nil {
+			author = "@" + actor.Username + "@" + actor.Domain
+		}
+		return author, util.StripHTMLTags(stored.Object.Content)
+	}
+
+	if note, err := database.ReadNoteByURI(quoteURI); err == nil && note != nil {
+		return "@" + note.CreatedBy, note.Message
+	}
+
+	object, err := fetchActivityPubObject(quoteURI, deps.HTTPClient)
+	if err != nil {
+		log.Printf("Inbox: Failed to fetch quoted post %s: %v", quoteURI, err)
+		return "", ""
+	}
+	content, _ := object["content"].(string)
+	author := ""
+	if attributedTo, ok := object["attributedTo"].(string); ok && attributedTo != "" {
+		if actor, err := GetOrFetchActorWithDeps(attributedTo, deps.HTTPClient, database); err == nil {
+			author = "@" + actor.Username + "@" + actor.Domain
+		} else {
+			log.Printf("Inbox: Failed to fetch author %s of quoted post: %v", attributedTo, err)
+		}
+	}
+	return author, util.StripHTMLTags(content)
+}
+
+// inboundCreatePolicy returns how Creates from non-followed actors are handled
+func inboundCreatePolicy(conf *util.AppConfig) string {
+	if conf == nil || conf.Conf.InboundCreatePolicy == "" {
+		return util.CreatePolicyReject
+	}
+	return conf.Conf.InboundCreatePolicy
+}
+
+// exceedsInboundNoteChars reports whether a remote post's content, converted to plain text,
+// has more characters (runes) than the configured MaxInboundNoteChars
+func exceedsInboundNoteChars(content string, conf *util.AppConfig) bool {
+	return utf8.RuneCountInString(util.HTMLToPlainText(content)) > conf.InboundNoteCharLimit()
+}
+
+// inboundContentTruncationMarker ends content cut to the configured MaxInboundContentBytes
+const inboundContentTruncationMarker = "<p>[…]</p>"
+
+// limitInboundContent enforces MaxInboundContentBytes on a Create's object. Larger content,
+// and the contentMap translations of it, is cut and marked, or the activity is rejected,
+// depending on InboundContentPolicy. The body is returned unchanged when nothing is cut.
+func limitInboundContent(body []byte, conf *util.AppConfig) ([]byte, error) {
+	var activity map[string]any
+	if err := json.Unmarshal(body, &activity); err != nil {
+		return nil, fmt.Errorf("failed to parse Create activity: %w", err)
+	}
+	object, ok := activity["object"].(map[string]any)
+	if !ok {
+		return body, nil
+	}
+
+	limit := conf.InboundContentByteLimit()
+	content, _ := object["content"].(string)
+	contentMap, _ := object["contentMap"].(map[string]any)
+	size := len(content)
+	for _, translation := range contentMap {
+		if s, ok := translation.(string); ok && len(s) > size {
+			size = len(s)
+		}
+	}
+	if size <= limit {
+		return body, nil
+	}
+
+	if conf.InboundContentOverLimitPolicy() == util.InboundContentPolicyReject {
+		log.Printf("Inbox: Rejecting post %v from %v: content is %d bytes, more than %d", object["id"], activity["actor"], size, limit)
+		return nil, fmt.Errorf("%w: post content exceeds %d bytes", ErrActivityRejected, limit)
+	}
+
+	log.Printf("Inbox: Truncating post %v from %v: content is %d bytes, more than %d", object["id"], activity["actor"], size, limit)
+	if content != "" {
+		object["content"] = truncateInboundContent(content, limit)
+	}
+	for language, translation := range contentMap {
+		if s, ok := translation.(string); ok {
+			contentMap[language] = truncateInboundContent(s, limit)
+		}
+	}
+	return json.Marshal(activity)
+}
+
+// truncateInboundContent cuts HTML content to at most limit bytes including the marker. The
+// cut is moved back to a character boundary and before a tag or entity it would split;
+// tags left open are closed when the content is sanitized for display.
+func truncateInboundContent(content string, limit int) string {
+	if len(content) <= limit {
+		return content
+	}
+	cut := max(limit-len(inboundContentTruncationMarker), 0)
+	for cut > 0 && !utf8.RuneStart(content[cut]) {
+		cut--
+	}
+	truncated := content[:cut]
+	if i := strings.LastIndexByte(truncated, '<'); i > strings.LastIndexByte(truncated, '>') {
+		truncated = truncated[:i]
+	}
+	if i := strings.LastIndexByte(truncated, '&'); i > strings.LastIndexByte(truncated, ';') {
+		truncated = truncated[:i]
+	}
+	return truncated + inboundContentTruncationMarker
+}
+
+// mentionsLocalAccount reports whether any Mention tag points to an existing local account
+func mentionsLocalAccount(tags []inboundTag, localDomain string, database Database) bool {
+	if localDomain == "" {
+		return false
+	}
+	for _, tag := range tags {
+		if tag.Type != "Mention" {
+			continue
+		}
+		username, mentionDomain := resolveInboundMention(tag.Name, tag.Href, localDomain, database)
+		if username == "" || !strings.EqualFold(mentionDomain, localDomain) {
+			continue
+		}
+		if acc, err := database.ReadAccByUsername(username); err == nil && acc != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveInboundMention determines the username and domain a Mention tag points to.
+// Local actor hrefs (https://<localDomain>/users/<name>) win over the name, since the
+// name is display text chosen by the remote server. Mentions given as a bare href
+// without a name are resolved via the cached remote account, falling back to the href's
+// host and last path segment (e.g. /users/bob or /@bob).
+func resolveInboundMention(name, href, localDomain string, database Database) (string, string) {
+	if localDomain != "" && href != "" {
+		localPrefix := "https://" + localDomain + "/users/"
+		if strings.HasPrefix(href, localPrefix) {
+			username := strings.Trim(strings.TrimPrefix(href, localPrefix), "/")
+			if username != "" && !strings.Contains(username, "/") {
+				return username, localDomain
+			}
+		}
+	}
+
+	// @username@domain or @username (domain taken from the href)
+	mentionName := strings.TrimPrefix(strings.TrimSpace(name), "@")
+	if mentionName != "" {
+		parts := strings.SplitN(mentionName, "@", 2)
+		if len(parts) == 2 && parts[0] != "" && parts[1] != "" {
+			return parts[0], parts[1]
+		}
+		if parsed, err := url.Parse(href); err == nil && parsed.Host != "" && parts[0] != "" {
+			return parts[0], parsed.Host
+		}
+	}
+
+	if href == "" {
+		return "", ""
+	}
+
+	// Bare href: prefer the account we already know about
+	if remoteAcc, err := database.ReadRemoteAccountByActorURI(href); err == nil && remoteAcc != nil {
+		return remoteAcc.Username, remoteAcc.Domain
+	}
+
+	parsed, err := url.Parse(href)
+	if err != nil || parsed.Host == "" {
+		return "", ""
+	}
+	segments := strings.Split(strings.Trim(parsed.Path, "/"), "/")
+	username := strings.TrimPrefix(segments[len(segments)-1], "@")
+	if username == "" {
+		return "", ""
+	}
+	return username, parsed.Host
+}
+
 // handleLikeActivity processes a Like activity
 func handleLikeActivity(body []byte, username string) error {
 	deps := &InboxDeps{
@@ -696,10 +1437,11 @@ func handleLikeActivityWithDeps(body []byte, username string, deps *InboxDeps) e
 	log.Printf("Inbox: Processing Like activity for %s", username)
 
 	var likeActivity struct {
-		ID     string `json:"id"`
-		Type   string `json:"type"`
-		Actor  string `json:"actor"`
-		Object string `json:"object"` // URI of the liked object (note)
+		ID      string `json:"id"`
+		Type    string `json:"type"`
+		Actor   string `json:"actor"`
+		Object  string `json:"object"`  // URI of the liked object (note)
+		Content string `json:"content"` // Emoji, set when Misskey sends a reaction as a Like
 	}
 
 	if err := json.Unmarshal(body, &likeActivity); err != nil {
@@ -716,10 +1458,15 @@ func handleLikeActivityWithDeps(body []byte, username string, deps *InboxDeps) e
 		return fmt.Errorf("Like activity missing object")
 	}
 
+	// A Like with content is an emoji reaction rather than a generic like
+	if likeActivity.Content != "" {
+		return storeReactionWithDeps(likeActivity.ID, likeActivity.Actor, likeActivity.Object, likeActivity.Content, deps)
+	}
+
 	database := deps.Database
 
 	// Find the note being liked by its object_uri
-	err, note := database.ReadNoteByURI(likeActivity.Object)
+	note, err := database.ReadNoteByURI(likeActivity.Object)
 	if err != nil || note == nil {
 		log.Printf("Inbox: Note not found for Like object %s: %v", likeActivity.Object, err)
 		return nil // Not an error - the note might not exist locally
@@ -751,56 +1498,133 @@ func handleLikeActivityWithDeps(body []byte, username string, deps *InboxDeps) e
 		CreatedAt: time.Now(),
 	}
 
-	if err := database.CreateLike(like); err != nil {
-		return fmt.Errorf("failed to store Like: %w", err)
-	}
-
-	// Increment like count on the note
-	if err := database.IncrementLikeCountByNoteId(note.Id); err != nil {
-		log.Printf("Inbox: Failed to increment like count: %v", err)
-	}
-
-	// Create notification for the note author
-	err, noteAuthor := database.ReadAccByUsername(note.CreatedBy)
-	if err == nil && noteAuthor != nil {
-		preview := note.Message
-		if len(preview) > 100 {
-			preview = preview[:100] + "..."
+	// Store the Like and its count together; the notification is best effort
+	err = database.WithTx(func(tx Tx) error {
+		if err := tx.CreateLike(like); err != nil {
+			return fmt.Errorf("failed to store Like: %w", err)
 		}
-		notification := &domain.Notification{
-			Id:               uuid.New(),
-			AccountId:        noteAuthor.Id,
-			NotificationType: domain.NotificationLike,
-			ActorId:          remoteAcc.Id,
-			ActorUsername:    remoteAcc.Username,
-			ActorDomain:      remoteAcc.Domain,
-			NoteId:           note.Id,
-			NoteURI:          note.ObjectURI,
-			NotePreview:      preview,
-			Read:             false,
-			CreatedAt:        time.Now(),
+
+		// Increment like count on the note
+		if err := tx.IncrementLikeCountByNoteId(note.Id); err != nil {
+			return fmt.Errorf("failed to increment like count: %w", err)
 		}
-		if err := database.CreateNotification(notification); err != nil {
-			log.Printf("Inbox: Failed to create like notification: %v", err)
+
+		// Create notification for the note author
+		noteAuthor, err := tx.ReadAccByUsername(note.CreatedBy)
+		if err == nil && noteAuthor != nil {
+			preview := note.Message
+			if len(preview) > 100 {
+				preview = preview[:100] + "..."
+			}
+			notification := &domain.Notification{
+				Id:               uuid.New(),
+				AccountId:        noteAuthor.Id,
+				NotificationType: domain.NotificationLike,
+				ActorId:          remoteAcc.Id,
+				ActorUsername:    remoteAcc.Username,
+				ActorDomain:      remoteAcc.Domain,
+				NoteId:           note.Id,
+				NoteURI:          note.ObjectURI,
+				NotePreview:      preview,
+				Read:             false,
+				CreatedAt:        time.Now(),
+			}
+			if err := tx.CreateNotification(notification); err != nil {
+				log.Printf("Inbox: Failed to create like notification: %v", err)
+			}
 		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
 	log.Printf("Inbox: Stored Like from %s on note %s", likeActivity.Actor, note.Id)
 	return nil
 }
 
+// maxReactionEmojiLength bounds the emoji of a reaction, in bytes. Unicode emoji sequences and
+// custom emoji shortcodes are far shorter.
+const maxReactionEmojiLength = 64
+
+// handleEmojiReactActivityWithDeps processes an EmojiReact activity (Pleroma/Akkoma).
+// This version accepts dependencies for testing.
+func handleEmojiReactActivityWithDeps(body []byte, username string, deps *InboxDeps) error {
+	log.Printf("Inbox: Processing EmojiReact activity for %s", username)
+
+	var react struct {
+		ID      string `json:"id"`
+		Actor   string `json:"actor"`
+		Object  string `json:"object"`  // URI of the reacted object (note)
+		Content string `json:"content"` // The emoji
+	}
+	if err := json.Unmarshal(body, &react); err != nil {
+		return fmt.Errorf("failed to parse EmojiReact activity: %w", err)
+	}
+
+	if react.ID == "" {
+		return fmt.Errorf("EmojiReact activity missing id")
+	}
+	if react.Actor == "" {
+		return fmt.Errorf("EmojiReact activity missing actor")
+	}
+	if react.Object == "" {
+		return fmt.Errorf("EmojiReact activity missing object")
+	}
+
+	return storeReactionWithDeps(react.ID, react.Actor, react.Object, react.Content, deps)
+}
+
+// storeReactionWithDeps records an emoji reaction on a local note. Reactions on unknown notes
+// and reactions without a usable emoji are ignored.
+func storeReactionWithDeps(activityID, actorURI, objectURI, emoji string, deps *InboxDeps) error {
+	emoji = strings.TrimSpace(emoji)
+	if emoji == "" || len(emoji) > maxReactionEmojiLength {
+		log.Printf("Inbox: Ignoring reaction from %s with invalid emoji %q", actorURI, emoji)
+		return nil
+	}
+
+	database := deps.Database
+
+	note, err := database.ReadNoteByURI(objectURI)
+	if err != nil || note == nil {
+		log.Printf("Inbox: Note not found for reaction object %s: %v", objectURI, err)
+		return nil // Not an error - the note might not exist locally
+	}
+
+	if _, err := GetOrFetchActorWithDeps(actorURI, deps.HTTPClient, database); err != nil {
+		log.Printf("Inbox: Could not fetch actor %s for reaction: %v", actorURI, err)
+		return nil // Not a fatal error
+	}
+
+	reaction := &domain.Reaction{
+		Id:        uuid.New(),
+		ObjectURI: note.ObjectURI,
+		Emoji:     emoji,
+		ActorURI:  actorURI,
+		URI:       activityID,
+		CreatedAt: time.Now(),
+	}
+	if err := database.CreateReaction(reaction); err != nil {
+		return fmt.Errorf("failed to store reaction: %w", err)
+	}
+
+	log.Printf("Inbox: Stored reaction %s from %s on note %s", emoji, actorURI, note.Id)
+	return nil
+}
+
 // handleAnnounceActivity processes an Announce (boost/reblog) activity
-func handleAnnounceActivity(body []byte, username string) error {
+func handleAnnounceActivity(body []byte, username string, conf *util.AppConfig) error {
 	deps := &InboxDeps{
 		Database:   NewDBWrapper(),
 		HTTPClient: defaultHTTPClient,
 	}
-	return handleAnnounceActivityWithDeps(body, username, deps)
+	return handleAnnounceActivityWithDeps(body, username, conf, deps)
 }
 
 // handleAnnounceActivityWithDeps processes an Announce (boost/reblog) activity.
 // This version accepts dependencies for testing.
-func handleAnnounceActivityWithDeps(body []byte, username string, deps *InboxDeps) error {
+func handleAnnounceActivityWithDeps(body []byte, username string, conf *util.AppConfig, deps *InboxDeps) error {
 	log.Printf("Inbox: Processing Announce activity for %s", username)
 
 	var announceActivity struct {
@@ -829,7 +1653,7 @@ func handleAnnounceActivityWithDeps(body []byte, username string, deps *InboxDep
 
 	// Check if this Announce is from a relay we're subscribed to
 	// First try exact match
-	err, relay := database.ReadRelayByActorURI(announceActivity.Actor)
+	relay, err := database.ReadRelayByActorURI(announceActivity.Actor)
 	isFromRelay := err == nil && relay != nil
 
 	// If not exact match, check if the actor is from any relay's domain
@@ -854,18 +1678,32 @@ func handleAnnounceActivityWithDeps(body []byte, username string, deps *InboxDep
 		return fmt.Errorf("Announce activity has invalid object format")
 	}
 
-	// If this is from a relay, check if paused before storing
+	// If this is from a relay, check if it is trusted and not paused before storing
 	if isFromRelay {
+		if conf != nil && !conf.IsTrustedRelay(announceActivity.Actor) {
+			log.Printf("Inbox: Relay Announce from %s rejected (relay is not trusted)", announceActivity.Actor)
+			return nil
+		}
 		relay := findRelayByActorDomain(announceActivity.Actor, deps.Database)
 		if relay != nil && relay.Paused {
 			log.Printf("Inbox: Relay Announce from %s skipped (relay %s is paused)", announceActivity.Actor, relay.ActorURI)
 			return nil
 		}
-		return handleRelayAnnounce(announceActivity.ID, objectURI, embeddedObject, deps)
+		var filter domain.RelayFilter
+		if relay != nil {
+			filter = relay.Filter
+		}
+		if err := handleRelayAnnounce(announceActivity.ID, objectURI, embeddedObject, filter, conf, deps); err != nil {
+			return err
+		}
+		if relay != nil {
+			recordRelayActivity(relay, database)
+		}
+		return nil
 	}
 
 	// Standard boost handling - find the note being boosted by its object_uri
-	err, note := database.ReadNoteByURI(objectURI)
+	note, err := database.ReadNoteByURI(objectURI)
 	if err != nil || note == nil {
 		// Check if this looks like a relay actor (contains /tag/ in path) but we're not subscribed
 		if strings.Contains(announceActivity.Actor, "/tag/") || strings.Contains(announceActivity.Actor, "/relay") {
@@ -902,13 +1740,45 @@ func handleAnnounceActivityWithDeps(body []byte, username string, deps *InboxDep
 		CreatedAt: time.Now(),
 	}
 
-	if err := database.CreateBoost(boost); err != nil {
-		return fmt.Errorf("failed to store Boost: %w", err)
-	}
+	// Store the Boost and its count together; the notification is best effort
+	err = database.WithTx(func(tx Tx) error {
+		if err := tx.CreateBoost(boost); err != nil {
+			return fmt.Errorf("failed to store Boost: %w", err)
+		}
 
-	// Increment boost count on the note
-	if err := database.IncrementBoostCountByNoteId(note.Id); err != nil {
-		log.Printf("Inbox: Failed to increment boost count: %v", err)
+		// Increment boost count on the note
+		if err := tx.IncrementBoostCountByNoteId(note.Id); err != nil {
+			return fmt.Errorf("failed to increment boost count: %w", err)
+		}
+
+		// Create notification for the note author
+		noteAuthor, err := tx.ReadAccByUsername(note.CreatedBy)
+		if err == nil && noteAuthor != nil {
+			preview := note.Message
+			if len(preview) > 100 {
+				preview = preview[:100] + "..."
+			}
+			notification := &domain.Notification{
+				Id:               uuid.New(),
+				AccountId:        noteAuthor.Id,
+				NotificationType: domain.NotificationBoost,
+				ActorId:          remoteAcc.Id,
+				ActorUsername:    remoteAcc.Username,
+				ActorDomain:      remoteAcc.Domain,
+				NoteId:           note.Id,
+				NoteURI:          note.ObjectURI,
+				NotePreview:      preview,
+				Read:             false,
+				CreatedAt:        time.Now(),
+			}
+			if err := tx.CreateNotification(notification); err != nil {
+				log.Printf("Inbox: Failed to create boost notification: %v", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
 	log.Printf("Inbox: Stored Boost from %s on note %s", announceActivity.Actor, note.Id)
@@ -916,18 +1786,19 @@ func handleAnnounceActivityWithDeps(body []byte, username string, deps *InboxDep
 }
 
 // handleRelayAnnounce processes an Announce from a relay, fetching and storing the announced content
-func handleRelayAnnounce(announceID, objectURI string, embeddedObject map[string]any, deps *InboxDeps) error {
+// if it passes the relay's filter
+func handleRelayAnnounce(announceID, objectURI string, embeddedObject map[string]any, filter domain.RelayFilter, conf *util.AppConfig, deps *InboxDeps) error {
 	database := deps.Database
 
 	// Check if we already have this announce activity (by activity_uri)
-	err, existingByAnnounce := database.ReadActivityByURI(announceID)
+	existingByAnnounce, err := database.ReadActivityByURI(announceID)
 	if err == nil && existingByAnnounce != nil {
 		log.Printf("Inbox: Relay-forwarded activity %s already exists (matched ID: %s), skipping", announceID, existingByAnnounce.ActivityURI)
 		return nil
 	}
 
 	// Check if we already have this object (by object_uri)
-	err, existingActivity := database.ReadActivityByObjectURI(objectURI)
+	existingActivity, err := database.ReadActivityByObjectURI(objectURI)
 	if err == nil && existingActivity != nil {
 		log.Printf("Inbox: Relay-forwarded object %s already exists (activity: %s), skipping", objectURI, existingActivity.ActivityURI)
 		return nil
@@ -968,11 +1839,27 @@ func handleRelayAnnounce(announceID, objectURI string, embeddedObject map[string
 
 	// Get the object type
 	objectType, _ := objectContent["type"].(string)
-	if objectType != "Note" && objectType != "Article" {
+	if objectType != "Note" && objectType != "Article" && objectType != "Page" {
 		log.Printf("Inbox: Relay-forwarded object %s is type %s, skipping", objectURI, objectType)
 		return nil
 	}
 
+	// Authors a local account follows deliver their posts directly, so a relay copy would duplicate them
+	if isFollowedAuthor(actorURI, database) {
+		log.Printf("Inbox: Relay-forwarded %s from followed author %s skipped (delivered directly)", objectURI, actorURI)
+		return nil
+	}
+
+	if reason := relayFilterRejection(filter, actorURI, objectContent); reason != "" {
+		log.Printf("Inbox: Relay-forwarded %s from %s filtered out (%s)", objectURI, actorURI, reason)
+		return nil
+	}
+
+	if content, _ := objectContent["content"].(string); exceedsInboundNoteChars(content, conf) {
+		log.Printf("Inbox: Relay-forwarded %s from %s skipped: longer than %d characters", objectURI, actorURI, conf.InboundNoteCharLimit())
+		return nil
+	}
+
 	// Fetch and cache the actor
 	_, err = GetOrFetchActorWithDeps(actorURI, deps.HTTPClient, database)
 	if err != nil {
@@ -1003,6 +1890,11 @@ func handleRelayAnnounce(announceID, objectURI string, embeddedObject map[string
 		Local:        false,
 		FromRelay:    true, // This is relay-forwarded content
 		CreatedAt:    time.Now(),
+		Language:     activityLanguage(rawJSON),
+		Restricted:   isRestrictedObject(objectContent, actorURI, database),
+	}
+	if activity.Restricted {
+		log.Printf("Inbox: Relay-forwarded %s from %s is %s, storing it restricted", objectURI, actorURI, objectVisibility(objectContent))
 	}
 
 	if err := database.CreateActivity(activity); err != nil {
@@ -1017,6 +1909,31 @@ func handleRelayAnnounce(announceID, objectURI string, embeddedObject map[string
 	return nil
 }
 
+// isFollowedAuthor checks whether any local account follows the author of relay-forwarded content.
+// Authors we have never cached cannot be followed, so no fetch is needed.
+func isFollowedAuthor(actorURI string, database Database) bool {
+	if extractDomainFromURI(actorURI) == "" {
+		return false
+	}
+	author, err := database.ReadRemoteAccountByActorURI(actorURI)
+	if err != nil || author == nil {
+		return false
+	}
+	followed, err := database.IsRemoteAccountFollowed(author.Id)
+	if err != nil {
+		log.Printf("Inbox: Failed to check follows of %s: %v", actorURI, err)
+		return false
+	}
+	return followed
+}
+
+// recordRelayActivity updates a relay's last activity time and count for health monitoring
+func recordRelayActivity(relay *domain.Relay, database Database) {
+	if err := database.RecordRelayActivity(relay.Id, time.Now()); err != nil {
+		log.Printf("Inbox: Failed to record activity for relay %s: %v", relay.ActorURI, err)
+	}
+}
+
 // isActorFromAnyRelay checks if an actor URI belongs to any relay domain we're subscribed to.
 // This handles cases where a relay like relay.fedi.buzz sends Announces from different tag actors
 // (e.g., /tag/prints) when we subscribed to /tag/music - they're all from the same relay domain.
@@ -1035,7 +1952,7 @@ func findRelayByActorDomain(actorURI string, database Database) *domain.Relay {
 	}
 
 	// Get all active relays
-	err, relays := database.ReadActiveRelays()
+	relays, err := database.ReadActiveRelays()
 	if err != nil || relays == nil {
 		log.Printf("Inbox: Error reading relays for domain check: %v", err)
 		return nil
@@ -1054,6 +1971,37 @@ func findRelayByActorDomain(actorURI string, database Database) *domain.Relay {
 	return nil
 }
 
+// activityLanguage returns the language of an activity's object, taken from the keys of its
+// contentMap, or "" if the object doesn't declare one (or the activity has no embedded object)
+func activityLanguage(body []byte) string {
+	var activity struct {
+		Object json.RawMessage `json:"object"`
+	}
+	if err := json.Unmarshal(body, &activity); err != nil || len(activity.Object) == 0 {
+		return ""
+	}
+
+	var object struct {
+		ContentMap map[string]string `json:"contentMap"`
+	}
+	if err := json.Unmarshal(activity.Object, &object); err != nil {
+		return ""
+	}
+
+	// Mastodon sends a single entry; sort so posts with several languages get a stable one
+	languages := make([]string, 0, len(object.ContentMap))
+	for tag := range object.ContentMap {
+		if lang := util.NormalizeLanguage(tag); lang != "" {
+			languages = append(languages, lang)
+		}
+	}
+	if len(languages) == 0 {
+		return ""
+	}
+	sort.Strings(languages)
+	return languages[0]
+}
+
 // extractDomainFromURI extracts the domain (host) from a URI
 func extractDomainFromURI(uri string) string {
 	// Simple extraction: find the domain between :// and the next /
@@ -1084,8 +2032,7 @@ func fetchActivityPubObject(uri string, client HTTPClient) (map[string]any, erro
 		return nil, err
 	}
 
-	req.Header.Set("Accept", "application/activity+json, application/ld+json")
-	req.Header.Set("User-Agent", "Stegodon/1.0")
+	SetRequestHeaders(req, ActivityAcceptHeader)
 
 	resp, err := client.Do(req)
 	if err != nil {
@@ -1097,8 +2044,13 @@ func fetchActivityPubObject(uri string, client HTTPClient) (map[string]any, erro
 		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
 	}
 
+	body, err := ReadJSONResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+
 	var result map[string]any
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+	if err := json.Unmarshal(body, &result); err != nil {
 		return nil, err
 	}
 
@@ -1114,41 +2066,64 @@ func handleAcceptActivity(body []byte, username string) error {
 	return handleAcceptActivityWithDeps(body, username, deps)
 }
 
-// handleAcceptActivityWithDeps processes an Accept activity (response to Follow).
-// This version accepts dependencies for testing.
-func handleAcceptActivityWithDeps(body []byte, username string, deps *InboxDeps) error {
-	var accept struct {
+// followResponse is an Accept or Reject of one of our Follows
+type followResponse struct {
+	Actor        string
+	FollowID     string // Our Follow's id, empty if the Follow was echoed without it
+	FollowObject string // Object of an echoed Follow, i.e. the followed actor
+}
+
+// parseFollowResponse extracts the Follow an Accept or Reject activity answers
+func parseFollowResponse(body []byte, activityType string) (*followResponse, error) {
+	var response struct {
 		Type   string `json:"type"`
 		Actor  string `json:"actor"`
 		Object any    `json:"object"`
 	}
 
-	if err := json.Unmarshal(body, &accept); err != nil {
-		return fmt.Errorf("failed to parse Accept activity: %w", err)
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse %s activity: %w", activityType, err)
 	}
 
 	// Extract Follow ID from object (can be string or object)
-	var followID string
-	switch obj := accept.Object.(type) {
+	result := &followResponse{Actor: response.Actor}
+	switch obj := response.Object.(type) {
 	case string:
 		// Object is a simple URI string (common in Accept responses)
-		followID = obj
+		result.FollowID = obj
 	case map[string]any:
 		// Object is a full Follow object
 		if id, ok := obj["id"].(string); ok {
-			followID = id
+			result.FollowID = id
+		}
+		// LitePub relays echo the Follow, whose object is the relay actor
+		if object, ok := obj["object"].(string); ok {
+			result.FollowObject = object
 		}
 	}
 
-	if followID == "" {
-		return fmt.Errorf("could not extract Follow ID from Accept object")
+	if result.FollowID == "" && result.FollowObject == "" {
+		return nil, fmt.Errorf("could not extract Follow ID from %s object", activityType)
+	}
+	return result, nil
+}
+
+// handleAcceptActivityWithDeps processes an Accept activity (response to Follow).
+// This version accepts dependencies for testing.
+func handleAcceptActivityWithDeps(body []byte, username string, deps *InboxDeps) error {
+	accept, err := parseFollowResponse(body, "Accept")
+	if err != nil {
+		return err
 	}
+	followID := accept.FollowID
 
 	database := deps.Database
 
-	// First check if this is an Accept for a relay subscription
-	err, relay := database.ReadRelayByActorURI(accept.Actor)
-	if err == nil && relay != nil {
+	// First check if this is an Accept for a relay subscription, matched by our Follow's URI
+	// (accepted by the relay's own server), then by a LitePub Follow's object, and otherwise
+	// by the relay's actor URI
+	relay := findRelayForResponse(accept, database)
+	if relay != nil {
 		// This is an Accept from a relay - update relay status to active
 		now := time.Now()
 		if err := database.UpdateRelayStatus(relay.Id, "active", &now); err != nil {
@@ -1159,6 +2134,9 @@ func handleAcceptActivityWithDeps(body []byte, username string, deps *InboxDeps)
 	}
 
 	// Not a relay - try updating a regular follow to accepted=true
+	if followID == "" {
+		return fmt.Errorf("could not extract Follow ID from Accept object")
+	}
 	if err := database.AcceptFollowByURI(followID); err != nil {
 		return fmt.Errorf("failed to accept follow: %w", err)
 	}
@@ -1167,18 +2145,86 @@ func handleAcceptActivityWithDeps(body []byte, username string, deps *InboxDeps)
 	return nil
 }
 
+// findRelayForResponse returns the relay subscription an Accept or Reject answers,
+// or nil if it is not for a relay
+func findRelayForResponse(response *followResponse, database Database) *domain.Relay {
+	relay, err := database.ReadRelayByFollowURI(response.FollowID)
+	if err == nil && relay != nil && extractDomainFromURI(relay.ActorURI) == extractDomainFromURI(response.Actor) {
+		return relay
+	}
+	if response.FollowObject != "" {
+		relay, err = database.ReadRelayByActorURI(response.FollowObject)
+		if err == nil && relay != nil && relay.Type == domain.RelayTypeLitePub {
+			return relay
+		}
+	}
+	relay, err = database.ReadRelayByActorURI(response.Actor)
+	if err == nil && relay != nil {
+		return relay
+	}
+	return nil
+}
+
+// handleRejectActivity processes a Reject activity (refusal of a Follow)
+func handleRejectActivity(body []byte, username string) error {
+	deps := &InboxDeps{
+		Database:   NewDBWrapper(),
+		HTTPClient: defaultHTTPClient,
+	}
+	return handleRejectActivityWithDeps(body, username, deps)
+}
+
+// handleRejectActivityWithDeps processes a Reject activity. A rejected relay subscription
+// is marked failed; a rejected follow of a remote account is removed.
+// This version accepts dependencies for testing.
+func handleRejectActivityWithDeps(body []byte, username string, deps *InboxDeps) error {
+	reject, err := parseFollowResponse(body, "Reject")
+	if err != nil {
+		return err
+	}
+
+	database := deps.Database
+
+	if relay := findRelayForResponse(reject, database); relay != nil {
+		if err := database.UpdateRelayStatus(relay.Id, "failed", nil); err != nil {
+			return fmt.Errorf("failed to update relay status: %w", err)
+		}
+		log.Printf("Inbox: Relay %s rejected our subscription", reject.Actor)
+		return nil
+	}
+
+	if reject.FollowID == "" {
+		return fmt.Errorf("could not extract Follow ID from Reject object")
+	}
+	follow, err := database.ReadFollowByURI(reject.FollowID)
+	if err != nil || follow == nil {
+		return fmt.Errorf("unknown follow %s", reject.FollowID)
+	}
+	// Only the followed actor may reject the follow
+	target, err := database.ReadRemoteAccountById(follow.TargetAccountId)
+	if err != nil || target == nil || target.ActorURI != reject.Actor {
+		return fmt.Errorf("reject of follow %s not sent by its target", reject.FollowID)
+	}
+	if err := database.DeleteFollowByURI(reject.FollowID); err != nil {
+		return fmt.Errorf("failed to delete follow: %w", err)
+	}
+
+	log.Printf("Inbox: Follow %s was rejected by %s", reject.FollowID, reject.Actor)
+	return nil
+}
+
 // handleUpdateActivity processes an Update activity (e.g., profile updates, post edits)
-func handleUpdateActivity(body []byte, username string) error {
+func handleUpdateActivity(body []byte, username string, conf *util.AppConfig) error {
 	deps := &InboxDeps{
 		Database:   NewDBWrapper(),
 		HTTPClient: defaultHTTPClient,
 	}
-	return handleUpdateActivityWithDeps(body, username, deps)
+	return handleUpdateActivityWithDeps(body, username, conf, deps)
 }
 
 // handleUpdateActivityWithDeps processes an Update activity (e.g., profile updates, post edits).
 // This version accepts dependencies for testing.
-func handleUpdateActivityWithDeps(body []byte, username string, deps *InboxDeps) error {
+func handleUpdateActivityWithDeps(body []byte, username string, conf *util.AppConfig, deps *InboxDeps) error {
 	var update struct {
 		ID     string          `json:"id"`
 		Type   string          `json:"type"`
@@ -1192,8 +2238,9 @@ func handleUpdateActivityWithDeps(body []byte, username string, deps *InboxDeps)
 
 	// Parse the object to determine what type it is
 	var objectType struct {
-		Type string `json:"type"`
-		ID   string `json:"id"`
+		Type    string `json:"type"`
+		ID      string `json:"id"`
+		Content string `json:"content"`
 	}
 	if err := json.Unmarshal(update.Object, &objectType); err != nil {
 		return fmt.Errorf("failed to parse Update object: %w", err)
@@ -1212,10 +2259,16 @@ func handleUpdateActivityWithDeps(body []byte, username string, deps *InboxDeps)
 		}
 		log.Printf("Inbox: Updated profile for %s@%s", remoteActor.Username, remoteActor.Domain)
 
-	case "Note", "Article":
+	case "Note", "Article", "Page":
+		// An edit that makes a post too long is rejected, keeping the previous version
+		if exceedsInboundNoteChars(objectType.Content, conf) {
+			log.Printf("Inbox: Rejecting update of %s from %s: longer than %d characters", objectType.ID, update.Actor, conf.InboundNoteCharLimit())
+			return fmt.Errorf("%w: post exceeds %d characters", ErrActivityRejected, conf.InboundNoteCharLimit())
+		}
+
 		// Post edit - find the existing activity that contains this Note/Article
 		// The activity is stored with the Create activity ID, but we need to find it by the Note ID
-		err, existingActivity := database.ReadActivityByObjectURI(objectType.ID)
+		existingActivity, err := database.ReadActivityByObjectURI(objectType.ID)
 		if err != nil || existingActivity == nil {
 			// No existing Create activity found - this can happen if:
 			// 1. We followed the user after the original post was created
@@ -1233,6 +2286,7 @@ func handleUpdateActivityWithDeps(body []byte, username string, deps *InboxDeps)
 				Processed:    true,
 				Local:        false,
 				CreatedAt:    time.Now(),
+				Language:     activityLanguage(body),
 			}
 
 			if err := database.CreateActivity(newActivity); err != nil {
@@ -1317,17 +2371,27 @@ func handleDeleteActivityWithDeps(body []byte, username string, deps *InboxDeps)
 		log.Printf("Inbox: Actor %s deleted their account", delete.Actor)
 
 		// Delete remote account
-		err, remoteAcc := database.ReadRemoteAccountByActorURI(objectURI)
+		remoteAcc, err := database.ReadRemoteAccountByActorURI(objectURI)
 		if err == nil && remoteAcc != nil {
-			// Delete all follows to/from this actor
-			database.DeleteFollowsByRemoteAccountId(remoteAcc.Id)
-			// Delete the remote account
-			database.DeleteRemoteAccount(remoteAcc.Id)
+			err := database.WithTx(func(tx Tx) error {
+				// Delete all follows to/from this actor
+				if err := tx.DeleteFollowsByRemoteAccountId(remoteAcc.Id); err != nil {
+					return fmt.Errorf("failed to delete follows of %s: %w", objectURI, err)
+				}
+				// Delete the remote account
+				if err := tx.DeleteRemoteAccount(remoteAcc.Id); err != nil {
+					return fmt.Errorf("failed to delete actor %s: %w", objectURI, err)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
 			log.Printf("Inbox: Removed actor %s and all associated data", objectURI)
 		}
 	} else {
 		// Object deletion (post, note, etc.) - find the activity containing this object
-		err, activity := database.ReadActivityByObjectURI(objectURI)
+		activity, err := database.ReadActivityByObjectURI(objectURI)
 		if err != nil || activity == nil {
 			log.Printf("Inbox: Activity with object %s not found for deletion, ignoring", objectURI)
 			return nil
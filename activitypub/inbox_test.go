@@ -5,13 +5,18 @@ import (
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
+	"unicode/utf8"
 
+	"code.superseriousbusiness.org/httpsig"
 	"github.com/deemkeen/stegodon/domain"
 	"github.com/deemkeen/stegodon/util"
 	"github.com/google/uuid"
@@ -464,8 +469,9 @@ func TestActivityTypes(t *testing.T) {
 func TestActivityContextVariants(t *testing.T) {
 	// Test different @context formats
 	tests := []struct {
-		name    string
-		context any
+		name         string
+		context      any
+		wantSecurity bool
 	}{
 		{
 			name:    "string context",
@@ -477,6 +483,7 @@ func TestActivityContextVariants(t *testing.T) {
 				"https://www.w3.org/ns/activitystreams",
 				"https://w3id.org/security/v1",
 			},
+			wantSecurity: true,
 		},
 	}
 
@@ -495,6 +502,12 @@ func TestActivityContextVariants(t *testing.T) {
 			if activity.Type != "Follow" {
 				t.Error("Activity type should be preserved regardless of context format")
 			}
+			if !activity.Context.HasActivityStreams() {
+				t.Error("Expected the ActivityStreams context to be detected")
+			}
+			if activity.Context.HasSecurityV1() != tt.wantSecurity {
+				t.Errorf("Expected HasSecurityV1() = %v", tt.wantSecurity)
+			}
 		})
 	}
 }
@@ -1300,9 +1313,14 @@ func TestHandleFollowActivityWithDeps_Success(t *testing.T) {
 		t.Errorf("Expected 1 follow, got %d", len(mockDB.Follows))
 	}
 
-	// Verify Accept was sent
-	if len(mockHTTP.Requests) != 1 {
-		t.Errorf("Expected 1 HTTP request (Accept), got %d", len(mockHTTP.Requests))
+	// Verify Accept was queued for bob's inbox
+	if len(mockDB.DeliveryQueue) != 1 {
+		t.Fatalf("Expected 1 queued delivery (Accept), got %d", len(mockDB.DeliveryQueue))
+	}
+	for _, item := range mockDB.DeliveryQueue {
+		if item.InboxURI != remoteActor.InboxURI || !strings.Contains(item.ActivityJSON, `"type":"Accept"`) {
+			t.Errorf("Expected an Accept queued for %s, got %s to %s", remoteActor.InboxURI, item.ActivityJSON, item.InboxURI)
+		}
 	}
 }
 
@@ -1369,9 +1387,162 @@ func TestHandleFollowActivityWithDeps_DuplicateFollow(t *testing.T) {
 		t.Errorf("Expected 1 follow (no duplicate), got %d", len(mockDB.Follows))
 	}
 
-	// Accept should still be sent
-	if len(mockHTTP.Requests) != 1 {
-		t.Errorf("Expected 1 HTTP request (Accept), got %d", len(mockHTTP.Requests))
+	// Accept should still be queued
+	if len(mockDB.DeliveryQueue) != 1 {
+		t.Errorf("Expected 1 queued delivery (Accept), got %d", len(mockDB.DeliveryQueue))
+	}
+}
+
+// TestHandleFollowActivityWithDeps_RollsBackOnQueueFailure tests that a failed Accept
+// enqueue leaves neither the follow nor its notification behind
+func TestHandleFollowActivityWithDeps_RollsBackOnQueueFailure(t *testing.T) {
+	mockDB, _, deps, _, remoteActor, conf := setupFollowTest(t)
+	mockDB.EnqueueError = errors.New("queue unavailable")
+
+	followBody := []byte(`{"id": "https://remote.example.com/activities/follow-789", "type": "Follow", "actor": "` + remoteActor.ActorURI + `", "object": "https://local.example.com/users/alice"}`)
+	if err := handleFollowActivityWithDeps(followBody, "alice", remoteActor, conf, deps); err == nil {
+		t.Fatal("Expected an error when the Accept cannot be queued")
+	}
+
+	if len(mockDB.Follows) != 0 || len(mockDB.FollowsByURI) != 0 {
+		t.Errorf("Expected the follow to be rolled back, got %d", len(mockDB.Follows))
+	}
+	if len(mockDB.Notifications) != 0 {
+		t.Errorf("Expected the notification to be rolled back, got %d", len(mockDB.Notifications))
+	}
+	if mockDB.RolledBackTxs != 1 {
+		t.Errorf("Expected 1 rolled back transaction, got %d", mockDB.RolledBackTxs)
+	}
+}
+
+// setupFollowTest returns deps with local account alice and remote actor bob, whose inbox accepts deliveries
+func setupFollowTest(t *testing.T) (*MockDatabase, *MockHTTPClient, *InboxDeps, *domain.Account, *domain.RemoteAccount, *util.AppConfig) {
+	t.Helper()
+	mockDB := NewMockDatabase()
+
+	keypair, err := GenerateTestKeyPair()
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+	localAccount := &domain.Account{
+		Id:            uuid.New(),
+		Username:      "alice",
+		WebPrivateKey: keypair.PrivatePEM,
+		WebPublicKey:  keypair.PublicPEM,
+	}
+	mockDB.AddAccount(localAccount)
+
+	remoteActor := &domain.RemoteAccount{
+		Id:       uuid.New(),
+		Username: "bob",
+		Domain:   "remote.example.com",
+		ActorURI: "https://remote.example.com/users/bob",
+		InboxURI: "https://remote.example.com/users/bob/inbox",
+	}
+	mockDB.AddRemoteAccount(remoteActor)
+
+	mockHTTP := NewMockHTTPClient()
+	mockHTTP.SetResponse(remoteActor.InboxURI, 202, nil)
+
+	conf := &util.AppConfig{}
+	conf.Conf.SslDomain = "local.example.com"
+
+	return mockDB, mockHTTP, &InboxDeps{Database: mockDB, HTTPClient: mockHTTP}, localAccount, remoteActor, conf
+}
+
+// sentActivityType returns the type of the activity sent in a captured request
+func sentActivityType(t *testing.T, req *http.Request) string {
+	t.Helper()
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		t.Fatalf("Failed to read request body: %v", err)
+	}
+	var activity Activity
+	if err := json.Unmarshal(body, &activity); err != nil {
+		t.Fatalf("Failed to parse sent activity: %v", err)
+	}
+	return activity.Type
+}
+
+const testFollowBody = `{
+	"@context": "https://www.w3.org/ns/activitystreams",
+	"id": "https://remote.example.com/activities/follow-789",
+	"type": "Follow",
+	"actor": "https://remote.example.com/users/bob",
+	"object": "https://local.example.com/users/alice"
+}`
+
+// TestHandleFollowActivityWithDeps_ManualApproval tests that follows of locked accounts stay pending
+func TestHandleFollowActivityWithDeps_ManualApproval(t *testing.T) {
+	mockDB, mockHTTP, deps, localAccount, remoteActor, conf := setupFollowTest(t)
+	localAccount.ManuallyApprovesFollowers = true
+
+	if err := handleFollowActivityWithDeps([]byte(testFollowBody), "alice", remoteActor, conf, deps); err != nil {
+		t.Fatalf("handleFollowActivityWithDeps failed: %v", err)
+	}
+
+	follow := mockDB.FollowsByURI["https://remote.example.com/activities/follow-789"]
+	if follow == nil {
+		t.Fatal("Expected a pending follow to be stored")
+	}
+	if follow.Accepted {
+		t.Error("Follow of an account that manually approves followers should be pending")
+	}
+	if len(mockHTTP.Requests) != 0 {
+		t.Errorf("Expected no Accept before approval, got %d requests", len(mockHTTP.Requests))
+	}
+	if len(mockDB.Notifications) != 1 || mockDB.Notifications[0].NotificationType != domain.NotificationFollowRequest {
+		t.Errorf("Expected a single follow request notification, got %d notifications", len(mockDB.Notifications))
+	}
+
+	// A re-sent Follow while the request is pending doesn't create a duplicate
+	resent := strings.Replace(testFollowBody, "follow-789", "follow-790", 1)
+	if err := handleFollowActivityWithDeps([]byte(resent), "alice", remoteActor, conf, deps); err != nil {
+		t.Fatalf("handleFollowActivityWithDeps failed for re-sent Follow: %v", err)
+	}
+	if len(mockDB.Follows) != 1 {
+		t.Errorf("Expected 1 pending follow after re-sent Follow, got %d", len(mockDB.Follows))
+	}
+	if len(mockDB.Notifications) != 1 {
+		t.Errorf("Expected no new notification for a re-sent Follow, got %d notifications", len(mockDB.Notifications))
+	}
+	if len(mockHTTP.Requests) != 0 {
+		t.Errorf("Expected no Accept for a re-sent pending Follow, got %d requests", len(mockHTTP.Requests))
+	}
+}
+
+// TestHandleFollowActivityWithDeps_BlockedDomain tests that follows from blocked servers are rejected
+func TestHandleFollowActivityWithDeps_BlockedDomain(t *testing.T) {
+	tests := []struct {
+		name           string
+		blockedDomains []string
+		blockedActors  []string
+	}{
+		{name: "blocked domain", blockedDomains: []string{"remote.example.com"}},
+		{name: "blocked parent domain", blockedDomains: []string{"example.com"}},
+		{name: "blocked actor", blockedActors: []string{"https://remote.example.com/users/bob"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, mockHTTP, deps, _, remoteActor, conf := setupFollowTest(t)
+			conf.Conf.BlockedDomains = tt.blockedDomains
+			conf.Conf.BlockedActors = tt.blockedActors
+
+			if err := handleFollowActivityWithDeps([]byte(testFollowBody), "alice", remoteActor, conf, deps); err != nil {
+				t.Fatalf("handleFollowActivityWithDeps failed: %v", err)
+			}
+
+			if len(mockDB.Follows) != 0 {
+				t.Errorf("Expected no follow from a blocked actor, got %d", len(mockDB.Follows))
+			}
+			if len(mockHTTP.Requests) != 1 {
+				t.Fatalf("Expected 1 HTTP request (Reject), got %d", len(mockHTTP.Requests))
+			}
+			if got := sentActivityType(t, mockHTTP.Requests[0]); got != "Reject" {
+				t.Errorf("Expected a Reject to be sent, got %s", got)
+			}
+		})
 	}
 }
 
@@ -1557,7 +1728,7 @@ func TestHandleAcceptActivityWithDeps_Success(t *testing.T) {
 	}
 
 	// Verify follow is now accepted
-	_, follow := mockDB.ReadFollowByURI(followURI)
+	follow, _ := mockDB.ReadFollowByURI(followURI)
 	if follow == nil {
 		t.Fatal("Follow not found after accept")
 	}
@@ -1567,6 +1738,141 @@ func TestHandleAcceptActivityWithDeps_Success(t *testing.T) {
 }
 
 // TestHandleAcceptActivityWithDeps_ObjectAsMap tests Accept with embedded Follow object
+func TestHandleAcceptActivityWithDeps_RelayByFollowURI(t *testing.T) {
+	mockDB := NewMockDatabase()
+
+	// The relay's actor URI differs from the actor that sends the Accept (e.g. per-tag relays)
+	relay := &domain.Relay{
+		Id:        uuid.New(),
+		ActorURI:  "https://relay.example.com/tag/music",
+		InboxURI:  "https://relay.example.com/tag/music/inbox",
+		FollowURI: "https://local.example.com/activities/relay-follow-2",
+		Status:    "pending",
+		CreatedAt: time.Now(),
+	}
+	mockDB.CreateRelay(relay)
+
+	deps := &InboxDeps{Database: mockDB, HTTPClient: NewMockHTTPClient()}
+
+	// An Accept for our Follow from another server must not activate the relay
+	forged := []byte(`{"type": "Accept", "actor": "https://evil.example.com/actor", "object": "https://local.example.com/activities/relay-follow-2"}`)
+	handleAcceptActivityWithDeps(forged, "alice", deps)
+	if relay.Status != "pending" {
+		t.Fatalf("Expected relay to stay pending after Accept from another server, got %s", relay.Status)
+	}
+
+	acceptBody := []byte(`{"type": "Accept", "actor": "https://relay.example.com/actor", "object": "https://local.example.com/activities/relay-follow-2"}`)
+	if err := handleAcceptActivityWithDeps(acceptBody, "alice", deps); err != nil {
+		t.Fatalf("handleAcceptActivityWithDeps failed: %v", err)
+	}
+
+	if relay.Status != "active" {
+		t.Errorf("Expected relay to be active, got %s", relay.Status)
+	}
+	if relay.AcceptedAt == nil {
+		t.Error("Expected accepted_at to be set")
+	}
+}
+
+func TestHandleAcceptActivityWithDeps_LitePubRelay(t *testing.T) {
+	mockDB := NewMockDatabase()
+
+	relay := &domain.Relay{
+		Id:        uuid.New(),
+		ActorURI:  "https://pleroma.example.com/relay",
+		InboxURI:  "https://pleroma.example.com/inbox",
+		FollowURI: "https://local.example.com/activities/relay-follow-3",
+		Status:    "pending",
+		Type:      domain.RelayTypeLitePub,
+		CreatedAt: time.Now(),
+	}
+	mockDB.CreateRelay(relay)
+
+	deps := &InboxDeps{Database: mockDB, HTTPClient: NewMockHTTPClient()}
+
+	// LitePub relays echo the Follow without its id, accepted by the instance actor
+	acceptBody := []byte(`{
+		"type": "Accept",
+		"actor": "https://pleroma.example.com/internal/fetch",
+		"object": {"type": "Follow", "actor": "https://local.example.com/users/admin", "object": "https://pleroma.example.com/relay"}
+	}`)
+	if err := handleAcceptActivityWithDeps(acceptBody, "admin", deps); err != nil {
+		t.Fatalf("handleAcceptActivityWithDeps failed: %v", err)
+	}
+
+	if relay.Status != "active" {
+		t.Errorf("Expected relay to be active, got %s", relay.Status)
+	}
+}
+
+func TestHandleRejectActivityWithDeps_Relay(t *testing.T) {
+	mockDB := NewMockDatabase()
+
+	relay := &domain.Relay{
+		Id:        uuid.New(),
+		ActorURI:  "https://relay.example.com/actor",
+		InboxURI:  "https://relay.example.com/inbox",
+		FollowURI: "https://local.example.com/activities/relay-follow-4",
+		Status:    "pending",
+		CreatedAt: time.Now(),
+	}
+	mockDB.CreateRelay(relay)
+
+	deps := &InboxDeps{Database: mockDB, HTTPClient: NewMockHTTPClient()}
+
+	rejectBody := []byte(`{"type": "Reject", "actor": "https://relay.example.com/actor", "object": "https://local.example.com/activities/relay-follow-4"}`)
+	if err := handleRejectActivityWithDeps(rejectBody, "alice", deps); err != nil {
+		t.Fatalf("handleRejectActivityWithDeps failed: %v", err)
+	}
+
+	if relay.Status != "failed" {
+		t.Errorf("Expected relay to be failed, got %s", relay.Status)
+	}
+}
+
+func TestHandleRejectActivityWithDeps_Follow(t *testing.T) {
+	mockDB := NewMockDatabase()
+
+	localAccount := &domain.Account{Id: uuid.New(), Username: "alice"}
+	mockDB.AddAccount(localAccount)
+	remoteActor := &domain.RemoteAccount{
+		Id:       uuid.New(),
+		Username: "bob",
+		Domain:   "remote.example.com",
+		ActorURI: "https://remote.example.com/users/bob",
+		InboxURI: "https://remote.example.com/users/bob/inbox",
+	}
+	mockDB.AddRemoteAccount(remoteActor)
+
+	followURI := "https://local.example.com/activities/follow-789"
+	mockDB.AddFollow(&domain.Follow{
+		Id:              uuid.New(),
+		AccountId:       localAccount.Id,
+		TargetAccountId: remoteActor.Id,
+		URI:             followURI,
+		CreatedAt:       time.Now(),
+	})
+
+	deps := &InboxDeps{Database: mockDB, HTTPClient: NewMockHTTPClient()}
+
+	// Only the followed actor may reject the follow
+	forged := []byte(`{"type": "Reject", "actor": "https://evil.example.com/users/mallory", "object": "` + followURI + `"}`)
+	if err := handleRejectActivityWithDeps(forged, "alice", deps); err == nil {
+		t.Error("Expected error for a Reject from another actor")
+	}
+	if _, ok := mockDB.FollowsByURI[followURI]; !ok {
+		t.Fatal("Expected follow to be kept after a forged Reject")
+	}
+
+	rejectBody := []byte(`{"type": "Reject", "actor": "https://remote.example.com/users/bob", "object": {"type": "Follow", "id": "` + followURI + `"}}`)
+	if err := handleRejectActivityWithDeps(rejectBody, "alice", deps); err != nil {
+		t.Fatalf("handleRejectActivityWithDeps failed: %v", err)
+	}
+	if _, ok := mockDB.FollowsByURI[followURI]; ok {
+		t.Error("Expected rejected follow to be deleted")
+	}
+}
+
 func TestHandleAcceptActivityWithDeps_ObjectAsMap(t *testing.T) {
 	mockDB := NewMockDatabase()
 
@@ -1617,7 +1923,7 @@ func TestHandleAcceptActivityWithDeps_ObjectAsMap(t *testing.T) {
 		t.Fatalf("handleAcceptActivityWithDeps failed: %v", err)
 	}
 
-	_, follow := mockDB.ReadFollowByURI(followURI)
+	follow, _ := mockDB.ReadFollowByURI(followURI)
 	if follow == nil || !follow.Accepted {
 		t.Error("Expected follow to be accepted")
 	}
@@ -1672,117 +1978,537 @@ func TestHandleCreateActivityWithDeps_Success(t *testing.T) {
 		}
 	}`)
 
-	err := handleCreateActivityWithDeps(createBody, "alice", false, deps)
+	err := handleCreateActivityWithDeps(createBody, "alice", false, nil, deps)
 	if err != nil {
 		t.Fatalf("handleCreateActivityWithDeps failed: %v", err)
 	}
 }
 
-// TestHandleCreateActivityWithDeps_NotFollowing tests rejection of Create from non-followed actor
-func TestHandleCreateActivityWithDeps_NotFollowing(t *testing.T) {
+// TestHandleCreateActivityWithDeps_TooLong tests that posts over MaxInboundNoteChars are rejected,
+// counting the plain-text characters rather than the HTML bytes
+func TestHandleCreateActivityWithDeps_TooLong(t *testing.T) {
 	mockDB := NewMockDatabase()
-
-	localAccount := &domain.Account{
-		Id:       uuid.New(),
-		Username: "alice",
-	}
+	localAccount := &domain.Account{Id: uuid.New(), Username: "alice"}
 	mockDB.AddAccount(localAccount)
-
 	remoteActor := &domain.RemoteAccount{
 		Id:       uuid.New(),
 		Username: "bob",
 		Domain:   "remote.example.com",
 		ActorURI: "https://remote.example.com/users/bob",
-		InboxURI: "https://remote.example.com/users/bob/inbox",
 	}
 	mockDB.AddRemoteAccount(remoteActor)
+	mockDB.AddFollow(&domain.Follow{Id: uuid.New(), AccountId: localAccount.Id, TargetAccountId: remoteActor.Id, Accepted: true})
 
-	// No follow relationship
+	deps := &InboxDeps{Database: mockDB, HTTPClient: NewMockHTTPClient()}
+	conf := &util.AppConfig{}
+	conf.Conf.MaxInboundNoteChars = 11
 
-	deps := &InboxDeps{
-		Database:   mockDB,
-		HTTPClient: NewMockHTTPClient(),
+	createBody := func(id, content string) []byte {
+		return []byte(`{
+			"id": "https://remote.example.com/activities/` + id + `",
+			"type": "Create",
+			"actor": "https://remote.example.com/users/bob",
+			"object": {
+				"id": "https://remote.example.com/notes/` + id + `",
+				"type": "Note",
+				"content": "` + content + `",
+				"attributedTo": "https://remote.example.com/users/bob"
+			}
+		}`)
 	}
 
-	createBody := []byte(`{
-		"@context": "https://www.w3.org/ns/activitystreams",
-		"id": "https://remote.example.com/activities/create-456",
-		"type": "Create",
-		"actor": "https://remote.example.com/users/bob",
-		"object": {
-			"id": "https://remote.example.com/notes/789",
-			"type": "Note",
-			"content": "Spam message!",
-			"published": "2025-01-01T00:00:00Z",
-			"attributedTo": "https://remote.example.com/users/bob"
-		}
-	}`)
-
-	err := handleCreateActivityWithDeps(createBody, "alice", false, deps)
-	if err == nil {
-		t.Fatal("Expected error for Create from non-followed actor")
+	// 11 characters once the markup is dropped, several of them multibyte
+	if err := handleCreateActivityWithDeps(createBody("fits", "<p><strong>héllo</strong> wörld</p>"), "alice", false, conf, deps); err != nil {
+		t.Fatalf("Expected a post at the limit to be accepted, got %v", err)
 	}
-
-	if !strings.Contains(err.Error(), "not following") {
-		t.Errorf("Expected 'not following' error, got: %v", err)
+	if err := handleCreateActivityWithDeps(createBody("long", "<p>héllo wörld!</p>"), "alice", false, conf, deps); err == nil {
+		t.Error("Expected a post over the limit to be rejected")
 	}
 }
 
-// TestHandleCreateActivityWithDeps_RelayBypassesFollowCheck tests that relay content is accepted without follow relationship
-func TestHandleCreateActivityWithDeps_RelayBypassesFollowCheck(t *testing.T) {
-	mockDB := NewMockDatabase()
-
-	localAccount := &domain.Account{
-		Id:       uuid.New(),
-		Username: "alice",
-	}
-	mockDB.AddAccount(localAccount)
-
-	remoteActor := &domain.RemoteAccount{
-		Id:       uuid.New(),
-		Username: "bob",
-		Domain:   "remote.example.com",
-		ActorURI: "https://remote.example.com/users/bob",
-		InboxURI: "https://remote.example.com/users/bob/inbox",
+func TestTruncateInboundContent(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		limit   int
+		want    string
+	}{
+		{"fits", "<p>hello</p>", 12, "<p>hello</p>"},
+		{"plain cut", "<p>hello world, this is long</p>", 30, "<p>hello world, th" + inboundContentTruncationMarker},
+		{"inside a tag", "<p>hello <strong>world</strong></p>", 27, "<p>hello " + inboundContentTruncationMarker},
+		{"inside an entity", "<p>fish &amp; chips and more</p>", 22, "<p>fish " + inboundContentTruncationMarker},
+		{"inside a character", "<p>héllo wörld and more</p>", 24, "<p>héllo w" + inboundContentTruncationMarker},
+		{"limit below the marker", "<p>hello world</p>", 4, inboundContentTruncationMarker},
 	}
-	mockDB.AddRemoteAccount(remoteActor)
-
-	// No follow relationship - but this is relay content
 
-	deps := &InboxDeps{
-		Database:   mockDB,
-		HTTPClient: NewMockHTTPClient(),
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncateInboundContent(tt.content, tt.limit)
+			if got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("Expected valid UTF-8, got %q", got)
+			}
+		})
 	}
+}
 
-	createBody := []byte(`{
-		"@context": "https://www.w3.org/ns/activitystreams",
-		"id": "https://remote.example.com/activities/create-456",
+func TestLimitInboundContent(t *testing.T) {
+	long := "<p>" + strings.Repeat("a", 100) + "</p>"
+	body := []byte(`{
 		"type": "Create",
 		"actor": "https://remote.example.com/users/bob",
 		"object": {
-			"id": "https://remote.example.com/notes/789",
+			"id": "https://remote.example.com/notes/1",
 			"type": "Note",
-			"content": "Hello from relay!",
-			"published": "2025-01-01T00:00:00Z",
-			"attributedTo": "https://remote.example.com/users/bob"
+			"content": "` + long + `",
+			"contentMap": {"en": "` + long + `"}
 		}
 	}`)
 
-	// isFromRelay=true should bypass the follow check
-	err := handleCreateActivityWithDeps(createBody, "alice", true, deps)
+	conf := &util.AppConfig{}
+	conf.Conf.MaxInboundContentBytes = 50
+
+	limited, err := limitInboundContent(body, conf)
 	if err != nil {
-		t.Fatalf("handleCreateActivityWithDeps with isFromRelay=true should succeed, got: %v", err)
+		t.Fatalf("Expected oversized content to be truncated, got %v", err)
+	}
+	var activity struct {
+		Object struct {
+			Content    string            `json:"content"`
+			ContentMap map[string]string `json:"contentMap"`
+		} `json:"object"`
+	}
+	if err := json.Unmarshal(limited, &activity); err != nil {
+		t.Fatalf("Failed to parse the truncated activity: %v", err)
+	}
+	for _, content := range []string{activity.Object.Content, activity.Object.ContentMap["en"]} {
+		if len(content) > 50 || !strings.HasSuffix(content, inboundContentTruncationMarker) {
+			t.Errorf("Expected at most 50 bytes ending in the marker, got %q", content)
+		}
 	}
 
-	// The activity was accepted (no error), validation passed
-	// Note: The actual activity storage happens in HandleInboxWithDeps, not in handleCreateActivityWithDeps
+	conf.Conf.MaxInboundContentBytes = len(long)
+	if unchanged, err := limitInboundContent(body, conf); err != nil || string(unchanged) != string(body) {
+		t.Errorf("Expected content at the limit to be left alone, got %v", err)
+	}
+
+	conf.Conf.MaxInboundContentBytes = 50
+	conf.Conf.InboundContentPolicy = util.InboundContentPolicyReject
+	if _, err := limitInboundContent(body, conf); err == nil {
+		t.Error("Expected oversized content to be rejected")
+	}
 }
 
-// TestHandleCreateActivityWithDeps_ReplyIncrementsCounts tests that replies increment reply count
-func TestHandleCreateActivityWithDeps_ReplyIncrementsCounts(t *testing.T) {
-	mockDB := NewMockDatabase()
+// TestHandleInboxWithDeps_TruncatesContent tests that the stored activity keeps the
+// truncated content rather than the body that was delivered
+func TestHandleInboxWithDeps_TruncatesContent(t *testing.T) {
+	mockDB, _, deps, localAccount, remoteActor, conf := setupFollowTest(t)
+	keypair, _ := GenerateTestKeyPair()
+	remoteActor.PublicKeyPem = keypair.PublicPEM
+	remoteActor.LastFetchedAt = time.Now()
+	mockDB.AddFollow(&domain.Follow{Id: uuid.New(), AccountId: localAccount.Id, TargetAccountId: remoteActor.Id, Accepted: true})
+	conf.Conf.MaxInboundContentBytes = 50
 
-	localAccount := &domain.Account{
+	body := []byte(`{"id": "https://remote.example.com/activities/create-long", "type": "Create", "actor": "https://remote.example.com/users/bob", "to": ["https://www.w3.org/ns/activitystreams#Public"], "object": {"id": "https://remote.example.com/notes/long", "type": "Note", "attributedTo": "https://remote.example.com/users/bob", "content": "<p>` + strings.Repeat("a", 100) + `</p>"}}`)
+	req := createSignedRequest(t, "POST", "/users/alice/inbox", body, keypair, "https://remote.example.com/users/bob#main-key")
+
+	rr := httptest.NewRecorder()
+	HandleInboxWithDeps(rr, req, "alice", conf, deps)
+
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202 Accepted, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if len(mockDB.Activities) != 1 {
+		t.Fatalf("Expected the post to be stored, got %d activities", len(mockDB.Activities))
+	}
+	for _, activity := range mockDB.Activities {
+		if strings.Contains(activity.RawJSON, strings.Repeat("a", 100)) || !strings.Contains(activity.RawJSON, "[…]") {
+			t.Errorf("Expected the stored activity to hold the truncated content, got %s", activity.RawJSON)
+		}
+	}
+}
+
+// TestHandleInboxWithDeps_RejectsLongContent tests that a post refused for its length is
+// answered with 422 rather than 500, so the sender doesn't keep retrying it
+func TestHandleInboxWithDeps_RejectsLongContent(t *testing.T) {
+	mockDB, _, deps, localAccount, remoteActor, conf := setupFollowTest(t)
+	keypair, _ := GenerateTestKeyPair()
+	remoteActor.PublicKeyPem = keypair.PublicPEM
+	remoteActor.LastFetchedAt = time.Now()
+	mockDB.AddFollow(&domain.Follow{Id: uuid.New(), AccountId: localAccount.Id, TargetAccountId: remoteActor.Id, Accepted: true})
+
+	tests := []struct {
+		name      string
+		configure func(conf *util.AppConfig)
+	}{
+		{"content bytes", func(conf *util.AppConfig) {
+			conf.Conf.MaxInboundContentBytes = 50
+			conf.Conf.InboundContentPolicy = util.InboundContentPolicyReject
+		}},
+		{"note characters", func(conf *util.AppConfig) {
+			conf.Conf.MaxInboundNoteChars = 50
+		}},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testConf := *conf
+			tt.configure(&testConf)
+
+			body := []byte(fmt.Sprintf(`{"id": "https://remote.example.com/activities/create-long-%d", "type": "Create", "actor": "https://remote.example.com/users/bob", "to": ["https://www.w3.org/ns/activitystreams#Public"], "object": {"id": "https://remote.example.com/notes/long-%d", "type": "Note", "attributedTo": "https://remote.example.com/users/bob", "content": "<p>%s</p>"}}`, i, i, strings.Repeat("a", 100)))
+			req := createSignedRequest(t, "POST", "/users/alice/inbox", body, keypair, "https://remote.example.com/users/bob#main-key")
+
+			rr := httptest.NewRecorder()
+			HandleInboxWithDeps(rr, req, "alice", &testConf, deps)
+
+			if rr.Code != http.StatusUnprocessableEntity {
+				t.Errorf("Expected status 422, got %d: %s", rr.Code, rr.Body.String())
+			}
+			if len(mockDB.Activities) != 0 {
+				t.Errorf("Expected nothing stored, got %d activities", len(mockDB.Activities))
+			}
+		})
+	}
+}
+
+// TestHandleCreateActivityWithDeps_SpoofedAttribution tests that a Create whose object claims
+// another author is rejected, unless a relay forwarded it
+func TestHandleCreateActivityWithDeps_SpoofedAttribution(t *testing.T) {
+	mockDB := NewMockDatabase()
+	localAccount := &domain.Account{Id: uuid.New(), Username: "alice"}
+	mockDB.AddAccount(localAccount)
+	remoteActor := &domain.RemoteAccount{
+		Id:       uuid.New(),
+		Username: "mallory",
+		Domain:   "remote.example.com",
+		ActorURI: "https://remote.example.com/users/mallory",
+	}
+	mockDB.AddRemoteAccount(remoteActor)
+	mockDB.AddFollow(&domain.Follow{Id: uuid.New(), AccountId: localAccount.Id, TargetAccountId: remoteActor.Id, Accepted: true})
+
+	deps := &InboxDeps{Database: mockDB, HTTPClient: NewMockHTTPClient()}
+
+	createBody := func(id, attributedTo string) []byte {
+		return []byte(`{
+			"id": "https://remote.example.com/activities/` + id + `",
+			"type": "Create",
+			"actor": "https://remote.example.com/users/mallory",
+			"object": {
+				"id": "https://remote.example.com/notes/` + id + `",
+				"type": "Note",
+				"content": "I am someone else",
+				"attributedTo": ` + attributedTo + `
+			}
+		}`)
+	}
+	spoofed := createBody("spoofed", `"https://other.example.com/users/bob"`)
+
+	if err := handleCreateActivityWithDeps(spoofed, "alice", false, nil, deps); !errors.Is(err, ErrAttributionMismatch) {
+		t.Errorf("Expected ErrAttributionMismatch for a spoofed author, got %v", err)
+	}
+
+	// A relay forwards posts signed by itself on behalf of their authors
+	if err := handleCreateActivityWithDeps(spoofed, "alice", true, nil, deps); err != nil {
+		t.Errorf("Expected relay-forwarded content to be accepted, got %v", err)
+	}
+
+	// The author may be given as an object or in a list
+	for _, attributedTo := range []string{
+		`{"type": "Person", "id": "https://remote.example.com/users/mallory"}`,
+		`[{"type": "Person", "id": "https://remote.example.com/users/mallory"}, {"type": "Group", "id": "https://remote.example.com/c/group"}]`,
+	} {
+		if err := handleCreateActivityWithDeps(createBody("object", attributedTo), "alice", false, nil, deps); err != nil {
+			t.Errorf("Expected attributedTo %s to match the actor, got %v", attributedTo, err)
+		}
+	}
+	if err := handleCreateActivityWithDeps(createBody("list", `["https://other.example.com/users/bob"]`), "alice", false, nil, deps); !errors.Is(err, ErrAttributionMismatch) {
+		t.Errorf("Expected ErrAttributionMismatch for a list of other authors, got %v", err)
+	}
+}
+
+// TestHandleCreateActivityWithDeps_NotFollowing tests rejection of Create from non-followed actor
+func TestHandleCreateActivityWithDeps_NotFollowing(t *testing.T) {
+	mockDB := NewMockDatabase()
+
+	localAccount := &domain.Account{
+		Id:       uuid.New(),
+		Username: "alice",
+	}
+	mockDB.AddAccount(localAccount)
+
+	remoteActor := &domain.RemoteAccount{
+		Id:       uuid.New(),
+		Username: "bob",
+		Domain:   "remote.example.com",
+		ActorURI: "https://remote.example.com/users/bob",
+		InboxURI: "https://remote.example.com/users/bob/inbox",
+	}
+	mockDB.AddRemoteAccount(remoteActor)
+
+	// No follow relationship
+
+	deps := &InboxDeps{
+		Database:   mockDB,
+		HTTPClient: NewMockHTTPClient(),
+	}
+
+	createBody := []byte(`{
+		"@context": "https://www.w3.org/ns/activitystreams",
+		"id": "https://remote.example.com/activities/create-456",
+		"type": "Create",
+		"actor": "https://remote.example.com/users/bob",
+		"object": {
+			"id": "https://remote.example.com/notes/789",
+			"type": "Note",
+			"content": "Spam message!",
+			"published": "2025-01-01T00:00:00Z",
+			"attributedTo": "https://remote.example.com/users/bob"
+		}
+	}`)
+
+	err := handleCreateActivityWithDeps(createBody, "alice", false, nil, deps)
+	if err == nil {
+		t.Fatal("Expected error for Create from non-followed actor")
+	}
+
+	if !strings.Contains(err.Error(), "not following") {
+		t.Errorf("Expected 'not following' error, got: %v", err)
+	}
+}
+
+// TestHandleCreateActivityWithDeps_NotifyOnPost tests that following with notifications turned on
+// creates a post notification for public top-level posts only
+func TestHandleCreateActivityWithDeps_NotifyOnPost(t *testing.T) {
+	tests := []struct {
+		name      string
+		notify    bool
+		inReplyTo string
+		to        string
+		wantType  domain.NotificationType
+	}{
+		{name: "public post", notify: true, to: `["https://www.w3.org/ns/activitystreams#Public"]`, wantType: domain.NotificationPost},
+		{name: "followers-only post", notify: true, to: `"https://remote.example.com/users/bob/followers"`, wantType: domain.NotificationPost},
+		{name: "notify off", notify: false, to: `["https://www.w3.org/ns/activitystreams#Public"]`},
+		{name: "reply", notify: true, inReplyTo: "https://elsewhere.example.com/notes/1", to: `["https://www.w3.org/ns/activitystreams#Public"]`},
+		{name: "direct message", notify: true, to: `["https://local.example.com/users/alice"]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, deps, conf := setupCreatePolicyTest(util.CreatePolicyReject)
+			alice, _ := mockDB.ReadAccByUsername("alice")
+			bob, _ := mockDB.ReadRemoteAccountByActorURI("https://remote.example.com/users/bob")
+			mockDB.AddFollow(&domain.Follow{
+				Id:              uuid.New(),
+				AccountId:       alice.Id,
+				TargetAccountId: bob.Id,
+				Accepted:        true,
+				Notify:          tt.notify,
+				CreatedAt:       time.Now(),
+			})
+
+			createBody := []byte(fmt.Sprintf(`{
+				"@context": "https://www.w3.org/ns/activitystreams",
+				"id": "https://remote.example.com/activities/create-456",
+				"type": "Create",
+				"actor": "https://remote.example.com/users/bob",
+				"object": {
+					"id": "https://remote.example.com/notes/789",
+					"type": "Note",
+					"content": "<p>Hello followers</p>",
+					"published": "2025-01-01T00:00:00Z",
+					"attributedTo": "https://remote.example.com/users/bob",
+					"inReplyTo": %q,
+					"to": %s
+				}
+			}`, tt.inReplyTo, tt.to))
+
+			if err := handleCreateActivityWithDeps(createBody, "alice", false, conf, deps); err != nil {
+				t.Fatalf("handleCreateActivityWithDeps failed: %v", err)
+			}
+
+			if tt.wantType == "" {
+				if len(mockDB.Notifications) != 0 {
+					t.Errorf("Expected no notification, got %d", len(mockDB.Notifications))
+				}
+				return
+			}
+			if len(mockDB.Notifications) != 1 {
+				t.Fatalf("Expected 1 notification, got %d", len(mockDB.Notifications))
+			}
+			n := mockDB.Notifications[0]
+			if n.NotificationType != tt.wantType || n.AccountId != alice.Id || n.ActorId != bob.Id {
+				t.Errorf("Unexpected notification %+v", n)
+			}
+			if n.NoteURI != "https://remote.example.com/notes/789" || n.NotePreview != "Hello followers" {
+				t.Errorf("Expected the note URI and a plain text preview, got %q and %q", n.NoteURI, n.NotePreview)
+			}
+		})
+	}
+}
+
+// setupCreatePolicyTest creates a local account and a remote actor it does not follow
+func setupCreatePolicyTest(policy string) (*MockDatabase, *InboxDeps, *util.AppConfig) {
+	mockDB := NewMockDatabase()
+	mockDB.AddAccount(&domain.Account{
+		Id:       uuid.New(),
+		Username: "alice",
+	})
+	mockDB.AddRemoteAccount(&domain.RemoteAccount{
+		Id:       uuid.New(),
+		Username: "bob",
+		Domain:   "remote.example.com",
+		ActorURI: "https://remote.example.com/users/bob",
+		InboxURI: "https://remote.example.com/users/bob/inbox",
+	})
+
+	conf := &util.AppConfig{}
+	conf.Conf.SslDomain = "local.example.com"
+	conf.Conf.InboundCreatePolicy = policy
+
+	deps := &InboxDeps{
+		Database:   mockDB,
+		HTTPClient: NewMockHTTPClient(),
+	}
+	return mockDB, deps, conf
+}
+
+// TestHandleCreateActivityWithDeps_StoreAllPolicy tests that store-all accepts posts from non-followed actors
+func TestHandleCreateActivityWithDeps_StoreAllPolicy(t *testing.T) {
+	_, deps, conf := setupCreatePolicyTest(util.CreatePolicyStoreAll)
+
+	createBody := []byte(`{
+		"@context": "https://www.w3.org/ns/activitystreams",
+		"id": "https://remote.example.com/activities/create-456",
+		"type": "Create",
+		"actor": "https://remote.example.com/users/bob",
+		"object": {
+			"id": "https://remote.example.com/notes/789",
+			"type": "Note",
+			"content": "Hello fediverse",
+			"published": "2025-01-01T00:00:00Z",
+			"attributedTo": "https://remote.example.com/users/bob"
+		}
+	}`)
+
+	if err := handleCreateActivityWithDeps(createBody, "alice", false, conf, deps); err != nil {
+		t.Fatalf("Expected Create to be accepted with store-all, got: %v", err)
+	}
+}
+
+// TestHandleCreateActivityWithDeps_StoreIfMentionedPolicy tests that store-if-mentioned accepts
+// posts mentioning a local account and still rejects everything else
+func TestHandleCreateActivityWithDeps_StoreIfMentionedPolicy(t *testing.T) {
+	mockDB, deps, conf := setupCreatePolicyTest(util.CreatePolicyStoreIfMentioned)
+	// The activity is stored by HandleInbox before the handler runs
+	mockDB.AddActivity(&domain.Activity{
+		Id:           uuid.New(),
+		ActivityURI:  "https://remote.example.com/activities/create-456",
+		ActivityType: "Create",
+		ActorURI:     "https://remote.example.com/users/bob",
+		ObjectURI:    "https://remote.example.com/notes/789",
+	})
+
+	mentionBody := []byte(`{
+		"@context": "https://www.w3.org/ns/activitystreams",
+		"id": "https://remote.example.com/activities/create-456",
+		"type": "Create",
+		"actor": "https://remote.example.com/users/bob",
+		"object": {
+			"id": "https://remote.example.com/notes/789",
+			"type": "Note",
+			"content": "Hi @alice",
+			"published": "2025-01-01T00:00:00Z",
+			"attributedTo": "https://remote.example.com/users/bob",
+			"tag": [{"type": "Mention", "href": "https://local.example.com/users/alice", "name": "@alice@local.example.com"}]
+		}
+	}`)
+
+	if err := handleCreateActivityWithDeps(mentionBody, "alice", false, conf, deps); err != nil {
+		t.Fatalf("Expected mentioning Create to be accepted, got: %v", err)
+	}
+	if len(mockDB.Notifications) != 1 || mockDB.Notifications[0].NotificationType != domain.NotificationMention {
+		t.Errorf("Expected a single mention notification, got %d notifications", len(mockDB.Notifications))
+	}
+
+	plainBody := []byte(`{
+		"@context": "https://www.w3.org/ns/activitystreams",
+		"id": "https://remote.example.com/activities/create-457",
+		"type": "Create",
+		"actor": "https://remote.example.com/users/bob",
+		"object": {
+			"id": "https://remote.example.com/notes/790",
+			"type": "Note",
+			"content": "Hi @carol",
+			"published": "2025-01-01T00:00:00Z",
+			"attributedTo": "https://remote.example.com/users/bob",
+			"tag": [{"type": "Mention", "href": "https://remote.example.com/users/carol", "name": "@carol@remote.example.com"}]
+		}
+	}`)
+
+	err := handleCreateActivityWithDeps(plainBody, "alice", false, conf, deps)
+	if err == nil || !strings.Contains(err.Error(), "not following") {
+		t.Errorf("Expected 'not following' error for Create without a local mention, got: %v", err)
+	}
+}
+
+// TestHandleCreateActivityWithDeps_RelayBypassesFollowCheck tests that relay content is accepted without follow relationship
+func TestHandleCreateActivityWithDeps_RelayBypassesFollowCheck(t *testing.T) {
+	mockDB := NewMockDatabase()
+
+	localAccount := &domain.Account{
+		Id:       uuid.New(),
+		Username: "alice",
+	}
+	mockDB.AddAccount(localAccount)
+
+	remoteActor := &domain.RemoteAccount{
+		Id:       uuid.New(),
+		Username: "bob",
+		Domain:   "remote.example.com",
+		ActorURI: "https://remote.example.com/users/bob",
+		InboxURI: "https://remote.example.com/users/bob/inbox",
+	}
+	mockDB.AddRemoteAccount(remoteActor)
+
+	// No follow relationship - but this is relay content
+
+	deps := &InboxDeps{
+		Database:   mockDB,
+		HTTPClient: NewMockHTTPClient(),
+	}
+
+	createBody := []byte(`{
+		"@context": "https://www.w3.org/ns/activitystreams",
+		"id": "https://remote.example.com/activities/create-456",
+		"type": "Create",
+		"actor": "https://remote.example.com/users/bob",
+		"object": {
+			"id": "https://remote.example.com/notes/789",
+			"type": "Note",
+			"content": "Hello from relay!",
+			"published": "2025-01-01T00:00:00Z",
+			"attributedTo": "https://remote.example.com/users/bob"
+		}
+	}`)
+
+	// isFromRelay=true should bypass the follow check
+	err := handleCreateActivityWithDeps(createBody, "alice", true, nil, deps)
+	if err != nil {
+		t.Fatalf("handleCreateActivityWithDeps with isFromRelay=true should succeed, got: %v", err)
+	}
+
+	// The activity was accepted (no error), validation passed
+	// Note: The actual activity storage happens in HandleInboxWithDeps, not in handleCreateActivityWithDeps
+}
+
+// TestHandleCreateActivityWithDeps_ReplyIncrementsCounts tests that replies increment reply count
+func TestHandleCreateActivityWithDeps_ReplyIncrementsCounts(t *testing.T) {
+	mockDB := NewMockDatabase()
+
+	localAccount := &domain.Account{
 		Id:       uuid.New(),
 		Username: "alice",
 	}
@@ -1831,7 +2557,7 @@ func TestHandleCreateActivityWithDeps_ReplyIncrementsCounts(t *testing.T) {
 		}
 	}`)
 
-	err := handleCreateActivityWithDeps(createBody, "alice", false, deps)
+	err := handleCreateActivityWithDeps(createBody, "alice", false, nil, deps)
 	if err != nil {
 		t.Fatalf("handleCreateActivityWithDeps failed: %v", err)
 	}
@@ -1911,7 +2637,7 @@ func TestHandleCreateActivityWithDeps_DuplicateSkipsReplyCount(t *testing.T) {
 		}
 	}`)
 
-	err := handleCreateActivityWithDeps(createBody, "alice", false, deps)
+	err := handleCreateActivityWithDeps(createBody, "alice", false, nil, deps)
 	if err != nil {
 		t.Fatalf("handleCreateActivityWithDeps failed: %v", err)
 	}
@@ -1973,7 +2699,7 @@ func TestHandleCreateActivityWithDeps_NoReplyNoIncrement(t *testing.T) {
 		}
 	}`)
 
-	err := handleCreateActivityWithDeps(createBody, "alice", false, deps)
+	err := handleCreateActivityWithDeps(createBody, "alice", false, nil, deps)
 	if err != nil {
 		t.Fatalf("handleCreateActivityWithDeps failed: %v", err)
 	}
@@ -2194,13 +2920,13 @@ func TestHandleUpdateActivityWithDeps_NoteUpdate(t *testing.T) {
 		}
 	}`)
 
-	err := handleUpdateActivityWithDeps(updateBody, "alice", deps)
+	err := handleUpdateActivityWithDeps(updateBody, "alice", nil, deps)
 	if err != nil {
 		t.Fatalf("handleUpdateActivityWithDeps failed: %v", err)
 	}
 
 	// Verify activity was updated
-	_, updatedActivity := mockDB.ReadActivityByObjectURI(noteURI)
+	updatedActivity, _ := mockDB.ReadActivityByObjectURI(noteURI)
 	if updatedActivity == nil {
 		t.Fatal("Activity not found after update")
 	}
@@ -2209,13 +2935,50 @@ func TestHandleUpdateActivityWithDeps_NoteUpdate(t *testing.T) {
 	}
 }
 
-// TestHandleLikeActivityWithDeps tests Like activity processing (placeholder)
-func TestHandleLikeActivityWithDeps(t *testing.T) {
+// TestHandleUpdateActivityWithDeps_NoteUpdateTooLong tests that an edit over MaxInboundNoteChars
+// is rejected and the previous version kept
+func TestHandleUpdateActivityWithDeps_NoteUpdateTooLong(t *testing.T) {
 	mockDB := NewMockDatabase()
+	mockDB.AddAccount(&domain.Account{Id: uuid.New(), Username: "alice"})
 
-	localAccount := &domain.Account{
-		Id:       uuid.New(),
-		Username: "alice",
+	noteURI := "https://remote.example.com/notes/123"
+	mockDB.AddActivity(&domain.Activity{
+		Id:           uuid.New(),
+		ActivityURI:  "https://remote.example.com/activities/create-456",
+		ActivityType: "Create",
+		ActorURI:     "https://remote.example.com/users/bob",
+		ObjectURI:    noteURI,
+		RawJSON:      `{"type":"Create","object":{"content":"Original content"}}`,
+		CreatedAt:    time.Now(),
+	})
+
+	deps := &InboxDeps{Database: mockDB, HTTPClient: NewMockHTTPClient()}
+	conf := &util.AppConfig{}
+	conf.Conf.MaxInboundNoteChars = 10
+
+	updateBody := []byte(`{
+		"id": "https://remote.example.com/activities/update-789",
+		"type": "Update",
+		"actor": "https://remote.example.com/users/bob",
+		"object": {"id": "https://remote.example.com/notes/123", "type": "Note", "content": "Updated content"}
+	}`)
+
+	if err := handleUpdateActivityWithDeps(updateBody, "alice", conf, deps); err == nil {
+		t.Error("Expected an update over the limit to be rejected")
+	}
+	activity, _ := mockDB.ReadActivityByObjectURI(noteURI)
+	if activity == nil || !strings.Contains(activity.RawJSON, "Original content") {
+		t.Error("Expected the original content to be kept")
+	}
+}
+
+// TestHandleLikeActivityWithDeps tests Like activity processing (placeholder)
+func TestHandleLikeActivityWithDeps(t *testing.T) {
+	mockDB := NewMockDatabase()
+
+	localAccount := &domain.Account{
+		Id:       uuid.New(),
+		Username: "alice",
 	}
 	mockDB.AddAccount(localAccount)
 
@@ -2563,6 +3326,167 @@ func TestHandleUndoLike_NoExistingLike(t *testing.T) {
 	}
 }
 
+// setupReactionTest creates a local note and a cached remote actor for reaction tests
+func setupReactionTest() (*MockDatabase, *InboxDeps, *domain.Note, *domain.RemoteAccount) {
+	mockDB := NewMockDatabase()
+	mockDB.AddAccount(&domain.Account{Id: uuid.New(), Username: "alice"})
+
+	noteId := uuid.New()
+	note := &domain.Note{
+		Id:        noteId,
+		CreatedBy: "alice",
+		Message:   "Hello world!",
+		ObjectURI: "https://local.example.com/notes/" + noteId.String(),
+	}
+	mockDB.AddNote(note)
+
+	remoteAccount := &domain.RemoteAccount{
+		Id:            uuid.New(),
+		Username:      "bob",
+		Domain:        "remote.example.com",
+		ActorURI:      "https://remote.example.com/users/bob",
+		InboxURI:      "https://remote.example.com/users/bob/inbox",
+		LastFetchedAt: time.Now(),
+	}
+	mockDB.AddRemoteAccount(remoteAccount)
+
+	deps := &InboxDeps{
+		Database:   mockDB,
+		HTTPClient: NewMockHTTPClient(),
+	}
+	return mockDB, deps, note, remoteAccount
+}
+
+func TestHandleEmojiReactions(t *testing.T) {
+	tests := []struct {
+		name          string
+		activity      string
+		expectedEmoji string // "" if no reaction should be stored
+		expectedLikes int
+	}{
+		{
+			name:          "EmojiReact",
+			activity:      `{"id": "https://remote.example.com/react/1", "type": "EmojiReact", "actor": "https://remote.example.com/users/bob", "object": "%s", "content": "🎉"}`,
+			expectedEmoji: "🎉",
+		},
+		{
+			name:          "Misskey Like with content",
+			activity:      `{"id": "https://remote.example.com/react/1", "type": "Like", "actor": "https://remote.example.com/users/bob", "object": "%s", "content": " 👍 "}`,
+			expectedEmoji: "👍",
+		},
+		{
+			name:          "plain Like stays a like",
+			activity:      `{"id": "https://remote.example.com/like/1", "type": "Like", "actor": "https://remote.example.com/users/bob", "object": "%s"}`,
+			expectedLikes: 1,
+		},
+		{
+			name:     "EmojiReact without emoji is ignored",
+			activity: `{"id": "https://remote.example.com/react/1", "type": "EmojiReact", "actor": "https://remote.example.com/users/bob", "object": "%s", "content": "  "}`,
+		},
+		{
+			name:     "EmojiReact on an unknown note is ignored",
+			activity: `{"id": "https://remote.example.com/react/1", "type": "EmojiReact", "actor": "https://remote.example.com/users/bob", "object": "https://local.example.com/notes/unknown%s", "content": "🎉"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, deps, note, _ := setupReactionTest()
+
+			body := []byte(fmt.Sprintf(tt.activity, note.ObjectURI))
+			var activity struct {
+				Type string `json:"type"`
+			}
+			if err := json.Unmarshal(body, &activity); err != nil {
+				t.Fatalf("invalid test activity: %v", err)
+			}
+			handler := DefaultInboxHandlers()[activity.Type]
+			if err := handler(&InboxRequest{Body: body, Username: "alice"}, deps); err != nil {
+				t.Fatalf("handler failed: %v", err)
+			}
+
+			if len(mockDB.Likes) != tt.expectedLikes {
+				t.Errorf("Expected %d likes, got %d", tt.expectedLikes, len(mockDB.Likes))
+			}
+			if tt.expectedEmoji == "" {
+				if len(mockDB.Reactions) != 0 {
+					t.Errorf("Expected no reactions, got %d", len(mockDB.Reactions))
+				}
+				return
+			}
+			if len(mockDB.Reactions) != 1 {
+				t.Fatalf("Expected 1 reaction, got %d", len(mockDB.Reactions))
+			}
+			reaction := mockDB.Reactions[0]
+			if reaction.Emoji != tt.expectedEmoji || reaction.ObjectURI != note.ObjectURI ||
+				reaction.ActorURI != "https://remote.example.com/users/bob" || reaction.URI != "https://remote.example.com/react/1" {
+				t.Errorf("Unexpected reaction stored: %+v", reaction)
+			}
+		})
+	}
+}
+
+func TestHandleUndoEmojiReaction(t *testing.T) {
+	tests := []struct {
+		name   string
+		object string
+	}{
+		{
+			name:   "Undo EmojiReact",
+			object: `{"id": "https://remote.example.com/react/1", "type": "EmojiReact", "object": "%s", "content": "🎉"}`,
+		},
+		{
+			name:   "Undo Misskey Like with content",
+			object: `{"id": "https://remote.example.com/react/1", "type": "Like", "object": "%s", "content": "🎉"}`,
+		},
+		{
+			name:   "Undo EmojiReact by activity id",
+			object: `{"id": "https://remote.example.com/react/1", "type": "EmojiReact", "object": "%s"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, deps, note, remoteAccount := setupReactionTest()
+			mockDB.Reactions = []*domain.Reaction{
+				{Id: uuid.New(), ObjectURI: note.ObjectURI, Emoji: "🎉", ActorURI: remoteAccount.ActorURI, URI: "https://remote.example.com/react/1"},
+				{Id: uuid.New(), ObjectURI: note.ObjectURI, Emoji: "👍", ActorURI: remoteAccount.ActorURI, URI: "https://remote.example.com/react/2"},
+			}
+			mockDB.Likes[uuid.New()] = &domain.Like{Id: uuid.New(), AccountId: remoteAccount.Id, NoteId: note.Id}
+
+			undoBody := []byte(`{"id": "https://remote.example.com/undo/1", "type": "Undo", "actor": "https://remote.example.com/users/bob", "object": ` +
+				fmt.Sprintf(tt.object, note.ObjectURI) + `}`)
+			if err := handleUndoActivityWithDeps(undoBody, "alice", remoteAccount, deps); err != nil {
+				t.Fatalf("handleUndoActivityWithDeps failed: %v", err)
+			}
+
+			// Only the undone emoji is removed; the other reaction and the plain like remain
+			if len(mockDB.Reactions) != 1 || mockDB.Reactions[0].Emoji != "👍" {
+				t.Errorf("Expected only the 👍 reaction to remain, got %+v", mockDB.Reactions)
+			}
+			if len(mockDB.Likes) != 1 {
+				t.Errorf("Expected the plain like to remain, got %d likes", len(mockDB.Likes))
+			}
+		})
+	}
+}
+
+func TestHandleUndoEmojiReaction_WrongActor(t *testing.T) {
+	mockDB, deps, note, remoteAccount := setupReactionTest()
+	mockDB.Reactions = []*domain.Reaction{
+		{Id: uuid.New(), ObjectURI: note.ObjectURI, Emoji: "🎉", ActorURI: remoteAccount.ActorURI},
+	}
+
+	undoBody := []byte(`{"id": "https://evil.example.com/undo/1", "type": "Undo", "actor": "https://evil.example.com/users/mallory",
+		"object": {"type": "EmojiReact", "object": "` + note.ObjectURI + `", "content": "🎉"}}`)
+	if err := handleUndoActivityWithDeps(undoBody, "alice", remoteAccount, deps); err == nil {
+		t.Error("Expected an error for an Undo by another actor")
+	}
+	if len(mockDB.Reactions) != 1 {
+		t.Errorf("Expected the reaction to remain, got %d", len(mockDB.Reactions))
+	}
+}
+
 // ============================================================================
 // Announce (Boost) Activity Tests
 // ============================================================================
@@ -2615,7 +3539,7 @@ func TestHandleAnnounceActivity_StoresBoostAndIncrementsCount(t *testing.T) {
 		"object": "` + note.ObjectURI + `"
 	}`)
 
-	err := handleAnnounceActivityWithDeps(announceBody, "alice", deps)
+	err := handleAnnounceActivityWithDeps(announceBody, "alice", nil, deps)
 	if err != nil {
 		t.Fatalf("handleAnnounceActivityWithDeps failed: %v", err)
 	}
@@ -2703,7 +3627,7 @@ func TestHandleAnnounceActivity_DuplicateBoostIgnored(t *testing.T) {
 		"object": "` + note.ObjectURI + `"
 	}`)
 
-	err := handleAnnounceActivityWithDeps(announceBody, "alice", deps)
+	err := handleAnnounceActivityWithDeps(announceBody, "alice", nil, deps)
 	if err != nil {
 		t.Fatalf("handleAnnounceActivityWithDeps should not error on duplicate: %v", err)
 	}
@@ -2745,7 +3669,7 @@ func TestHandleAnnounceActivity_NoteNotFound(t *testing.T) {
 	}`)
 
 	// Should not error - note simply doesn't exist locally
-	err := handleAnnounceActivityWithDeps(announceBody, "alice", deps)
+	err := handleAnnounceActivityWithDeps(announceBody, "alice", nil, deps)
 	if err != nil {
 		t.Fatalf("handleAnnounceActivityWithDeps should not error for missing note: %v", err)
 	}
@@ -2940,7 +3864,7 @@ func TestHandleAnnounceActivity_ObjectAsMap(t *testing.T) {
 		}
 	}`)
 
-	err := handleAnnounceActivityWithDeps(announceBody, "alice", deps)
+	err := handleAnnounceActivityWithDeps(announceBody, "alice", nil, deps)
 	if err != nil {
 		t.Fatalf("handleAnnounceActivityWithDeps failed with object as map: %v", err)
 	}
@@ -2977,6 +3901,212 @@ func createSignedRequest(t *testing.T, method, url string, body []byte, keypair
 	return req
 }
 
+// TestHandleInboxWithDeps_GzippedBody tests that a gzipped delivery is decoded, whether the
+// sender computed its Digest over the compressed or the decoded body
+func TestHandleInboxWithDeps_GzippedBody(t *testing.T) {
+	body := []byte(`{"id": "https://remote.example.com/activities/follow-gzip", "type": "Follow", "actor": "https://remote.example.com/users/bob", "object": "https://local.example.com/users/alice"}`)
+	sent := gzipBody(t, body)
+
+	tests := []struct {
+		name       string
+		encoding   string
+		digest     string
+		wantStatus int
+	}{
+		{"digest of the sent body", "gzip", calculateDigest(sent), http.StatusAccepted},
+		{"digest of the decoded body", "gzip", calculateDigest(body), http.StatusAccepted},
+		{"digest of another body", "gzip", calculateDigest([]byte("{}")), http.StatusUnauthorized},
+		{"unsupported encoding", "br", calculateDigest(sent), http.StatusUnsupportedMediaType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, _, deps, _, remoteActor, conf := setupFollowTest(t)
+			keypair, _ := GenerateTestKeyPair()
+			remoteActor.PublicKeyPem = keypair.PublicPEM
+			remoteActor.LastFetchedAt = time.Now()
+
+			req := httptest.NewRequest("POST", "/users/alice/inbox", bytes.NewReader(sent))
+			req.Header.Set("Content-Type", "application/activity+json")
+			req.Header.Set("Content-Encoding", tt.encoding)
+			req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+			req.Header.Set("Host", req.Host)
+			req.Header.Set("Digest", tt.digest)
+			if err := SignRequest(req, keypair.PrivateKey, "https://remote.example.com/users/bob#main-key"); err != nil {
+				t.Fatalf("Failed to sign request: %v", err)
+			}
+
+			rr := httptest.NewRecorder()
+			HandleInboxWithDeps(rr, req, "alice", conf, deps)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+			wantFollows := 0
+			if tt.wantStatus == http.StatusAccepted {
+				wantFollows = 1
+			}
+			if len(mockDB.Follows) != wantFollows {
+				t.Errorf("Expected %d follows, got %d", wantFollows, len(mockDB.Follows))
+			}
+			for _, activity := range mockDB.Activities {
+				if activity.RawJSON != string(body) {
+					t.Errorf("Expected the decoded body to be stored, got %q", activity.RawJSON)
+				}
+			}
+		})
+	}
+}
+
+// TestHandleInboxWithDeps_UnsignedDigest tests that deliveries whose signature doesn't cover a
+// Digest header are rejected, on the inbox of an account and on the shared inbox
+func TestHandleInboxWithDeps_UnsignedDigest(t *testing.T) {
+	body := []byte(`{"id": "https://remote.example.com/activities/follow-unsigned", "type": "Follow", "actor": "https://remote.example.com/users/bob", "object": "https://local.example.com/users/alice"}`)
+
+	tests := []struct {
+		name    string
+		digest  string
+		headers []string
+	}{
+		{"no digest", "", []string{"(request-target)", "host", "date"}},
+		{"digest not signed", calculateDigest(body), []string{"(request-target)", "host", "date"}},
+	}
+
+	for _, tt := range tests {
+		for _, shared := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s (shared inbox: %v)", tt.name, shared), func(t *testing.T) {
+				mockDB, _, deps, _, remoteActor, conf := setupFollowTest(t)
+				keypair, _ := GenerateTestKeyPair()
+				remoteActor.PublicKeyPem = keypair.PublicPEM
+				remoteActor.LastFetchedAt = time.Now()
+
+				req := httptest.NewRequest("POST", "/users/alice/inbox", bytes.NewReader(body))
+				req.Header.Set("Content-Type", "application/activity+json")
+				req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+				req.Header.Set("Host", req.Host)
+				if tt.digest != "" {
+					req.Header.Set("Digest", tt.digest)
+				}
+				signer, _, err := httpsig.NewSigner([]httpsig.Algorithm{httpsig.RSA_SHA256}, httpsig.DigestSha256, tt.headers, httpsig.Signature, 0)
+				if err != nil {
+					t.Fatalf("Failed to create signer: %v", err)
+				}
+				if err := signer.SignRequest(keypair.PrivateKey, "https://remote.example.com/users/bob#main-key", req, nil); err != nil {
+					t.Fatalf("Failed to sign request: %v", err)
+				}
+
+				rr := httptest.NewRecorder()
+				if shared {
+					HandleSharedInboxWithDeps(rr, req, conf, deps)
+				} else {
+					HandleInboxWithDeps(rr, req, "alice", conf, deps)
+				}
+
+				if rr.Code != http.StatusUnauthorized {
+					t.Errorf("Expected status 401, got %d: %s", rr.Code, rr.Body.String())
+				}
+				if len(mockDB.Follows) != 0 || len(mockDB.Activities) != 0 {
+					t.Errorf("Expected nothing stored, got %d follows and %d activities", len(mockDB.Follows), len(mockDB.Activities))
+				}
+			})
+		}
+	}
+}
+
+// TestHandleSharedInboxWithDeps_GzippedBody tests that shared inbox deliveries are decoded before they're routed
+func TestHandleSharedInboxWithDeps_GzippedBody(t *testing.T) {
+	body := []byte(`{"id": "https://remote.example.com/activities/follow-gzip", "type": "Follow", "actor": "https://remote.example.com/users/bob", "object": "https://local.example.com/users/alice"}`)
+	sent := gzipBody(t, body)
+
+	tests := []struct {
+		name       string
+		encoding   string
+		wantStatus int
+		wantFollow bool
+	}{
+		{"gzip", "gzip", http.StatusAccepted, true},
+		{"unsupported encoding", "br", http.StatusUnsupportedMediaType, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, _, deps, _, remoteActor, conf := setupFollowTest(t)
+			keypair, _ := GenerateTestKeyPair()
+			remoteActor.PublicKeyPem = keypair.PublicPEM
+			remoteActor.LastFetchedAt = time.Now()
+
+			req := httptest.NewRequest("POST", "/inbox", bytes.NewReader(sent))
+			req.Header.Set("Content-Type", "application/activity+json")
+			req.Header.Set("Content-Encoding", tt.encoding)
+			req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+			req.Header.Set("Host", req.Host)
+			req.Header.Set("Digest", calculateDigest(sent))
+			if err := SignRequest(req, keypair.PrivateKey, "https://remote.example.com/users/bob#main-key"); err != nil {
+				t.Fatalf("Failed to sign request: %v", err)
+			}
+
+			rr := httptest.NewRecorder()
+			HandleSharedInboxWithDeps(rr, req, conf, deps)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+			if got := len(mockDB.Follows) == 1; got != tt.wantFollow {
+				t.Errorf("Expected follow stored to be %v, got %d follows", tt.wantFollow, len(mockDB.Follows))
+			}
+			for _, activity := range mockDB.Activities {
+				if activity.RawJSON != string(body) {
+					t.Errorf("Expected the decoded body to be stored, got %q", activity.RawJSON)
+				}
+			}
+		})
+	}
+}
+
+// TestSharedInboxRecipient tests which local user a shared inbox delivery is routed to
+func TestSharedInboxRecipient(t *testing.T) {
+	mockDB, _, _, alice, remoteActor, conf := setupFollowTest(t)
+	carol := &domain.Account{Id: uuid.New(), Username: "carol", Disabled: true}
+	mockDB.AddAccount(carol)
+	mockDB.AddFollow(&domain.Follow{Id: uuid.New(), AccountId: carol.Id, TargetAccountId: remoteActor.Id, Accepted: true})
+	mockDB.AddFollow(&domain.Follow{Id: uuid.New(), AccountId: alice.Id, TargetAccountId: remoteActor.Id, Accepted: true})
+
+	tests := []struct {
+		name     string
+		activity map[string]any
+		want     string
+	}{
+		{
+			name:     "addressed to a user",
+			activity: map[string]any{"type": "Create", "to": []any{"https://local.example.com/users/alice"}},
+			want:     "alice",
+		},
+		{
+			name:     "addressed to a disabled user's followers",
+			activity: map[string]any{"type": "Create", "cc": []any{"https://local.example.com/users/carol/followers"}},
+			want:     "",
+		},
+		{
+			name:     "followed actor",
+			activity: map[string]any{"type": "Create", "actor": remoteActor.ActorURI, "cc": []any{"https://local.example.com/users/carol/followers"}},
+			want:     "alice",
+		},
+		{
+			name:     "unknown actor",
+			activity: map[string]any{"type": "Create", "actor": "https://elsewhere.example.com/users/dave"},
+			want:     "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sharedInboxRecipient(tt.activity, conf, mockDB, slog.Default()); got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
 // TestHandleInboxWithDeps_MissingSignature tests rejection of unsigned requests
 func TestHandleInboxWithDeps_MissingSignature(t *testing.T) {
 	mockDB := NewMockDatabase()
@@ -3007,6 +4137,35 @@ func TestHandleInboxWithDeps_MissingSignature(t *testing.T) {
 	}
 }
 
+// TestHandleInboxWithDeps_DisabledAccount tests that a disabled account's inbox rejects everything
+func TestHandleInboxWithDeps_DisabledAccount(t *testing.T) {
+	mockDB := NewMockDatabase()
+	mockDB.AddAccount(&domain.Account{Id: uuid.New(), Username: "alice", Disabled: true})
+
+	deps := &InboxDeps{
+		Database:   mockDB,
+		HTTPClient: NewMockHTTPClient(),
+	}
+
+	conf := &util.AppConfig{}
+	conf.Conf.SslDomain = "local.example.com"
+
+	body := []byte(`{"id":"https://remote.example.com/activities/1","type":"Follow","actor":"https://remote.example.com/users/bob"}`)
+	req := httptest.NewRequest("POST", "/users/alice/inbox", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/activity+json")
+	req.Header.Set("Signature", `keyId="https://remote.example.com/users/bob#main-key",signature="x"`)
+
+	rr := httptest.NewRecorder()
+	HandleInboxWithDeps(rr, req, "alice", conf, deps)
+
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 Forbidden, got %d", rr.Code)
+	}
+	if len(mockDB.Activities) != 0 {
+		t.Errorf("Expected no stored activities, got %d", len(mockDB.Activities))
+	}
+}
+
 // TestHandleInboxWithDeps_InvalidJSON tests rejection of invalid JSON
 func TestHandleInboxWithDeps_InvalidJSON(t *testing.T) {
 	mockDB := NewMockDatabase()
@@ -3214,47 +4373,174 @@ func TestHandleInboxWithDeps_FollowSuccess(t *testing.T) {
 	}
 }
 
-// TestHandleInboxWithDeps_AcceptSuccess tests successful Accept activity processing
-func TestHandleInboxWithDeps_AcceptSuccess(t *testing.T) {
-	mockDB := NewMockDatabase()
-	mockHTTP := NewMockHTTPClient()
-
+// TestHandleInboxWithDeps_FailedHandlerForgetsActivity tests that an activity whose handler
+// failed is not kept, so the sender's retry is processed rather than skipped as a duplicate
+func TestHandleInboxWithDeps_FailedHandlerForgetsActivity(t *testing.T) {
+	mockDB, mockHTTP, deps, _, remoteActor, conf := setupFollowTest(t)
 	keypair, _ := GenerateTestKeyPair()
+	remoteActor.PublicKeyPem = keypair.PublicPEM
+	remoteActor.LastFetchedAt = time.Now()
+	mockDB.EnqueueError = errors.New("queue unavailable")
 
-	// Local account that sent the follow
-	localAccount := &domain.Account{
-		Id:       uuid.New(),
-		Username: "alice",
+	body := []byte(`{"id": "https://remote.example.com/activities/follow-123", "type": "Follow", "actor": "https://remote.example.com/users/bob", "object": "https://local.example.com/users/alice"}`)
+	send := func() int {
+		req := createSignedRequest(t, "POST", "/users/alice/inbox", body, keypair, "https://remote.example.com/users/bob#main-key")
+		rr := httptest.NewRecorder()
+		HandleInboxWithDeps(rr, req, "alice", conf, deps)
+		return rr.Code
 	}
-	mockDB.AddAccount(localAccount)
 
-	// Remote actor accepting the follow
-	remoteActor := &domain.RemoteAccount{
-		Id:            uuid.New(),
-		Username:      "bob",
-		Domain:        "remote.example.com",
-		ActorURI:      "https://remote.example.com/users/bob",
-		InboxURI:      "https://remote.example.com/users/bob/inbox",
-		PublicKeyPem:  keypair.PublicPEM,
-		LastFetchedAt: time.Now(),
+	if code := send(); code != http.StatusInternalServerError {
+		t.Fatalf("Expected status 500 while the Accept cannot be queued, got %d", code)
 	}
-	mockDB.AddRemoteAccount(remoteActor)
-
-	// Pending follow to be accepted
-	followURI := "https://local.example.com/activities/follow-123"
-	pendingFollow := &domain.Follow{
-		Id:              uuid.New(),
-		AccountId:       localAccount.Id,
-		TargetAccountId: remoteActor.Id,
-		URI:             followURI,
-		Accepted:        false,
-		CreatedAt:       time.Now(),
+	if len(mockDB.Activities) != 0 || len(mockDB.Follows) != 0 {
+		t.Fatalf("Expected no activity or follow after the failure, got %d and %d", len(mockDB.Activities), len(mockDB.Follows))
 	}
-	mockDB.AddFollow(pendingFollow)
 
-	deps := &InboxDeps{
-		Database:   mockDB,
-		HTTPClient: mockHTTP,
+	// The retry is processed in full
+	mockDB.EnqueueError = nil
+	if code := send(); code != http.StatusAccepted {
+		t.Fatalf("Expected status 202 for the retry, got %d", code)
+	}
+	if len(mockDB.Follows) != 1 || len(mockDB.DeliveryQueue) != 1 {
+		t.Errorf("Expected the follow and its Accept after the retry, got %d and %d", len(mockDB.Follows), len(mockDB.DeliveryQueue))
+	}
+	if len(mockHTTP.Requests) != 0 {
+		t.Errorf("Expected the Accept to be queued rather than sent, got %d requests", len(mockHTTP.Requests))
+	}
+}
+
+// TestHandleInboxWithDeps_UntrustedRelayContent tests that content signed by a subscribed
+// relay that isn't trusted is acknowledged but not stored
+func TestHandleInboxWithDeps_UntrustedRelayContent(t *testing.T) {
+	mockDB := NewMockDatabase()
+	keypair, _ := GenerateTestKeyPair()
+	mockDB.AddRemoteAccount(&domain.RemoteAccount{
+		Id:            uuid.New(),
+		Username:      "relay",
+		Domain:        "relay.example.com",
+		ActorURI:      "https://relay.example.com/actor",
+		InboxURI:      "https://relay.example.com/inbox",
+		PublicKeyPem:  keypair.PublicPEM,
+		LastFetchedAt: time.Now(),
+	})
+	mockDB.CreateRelay(&domain.Relay{
+		Id:       uuid.New(),
+		ActorURI: "https://relay.example.com/actor",
+		InboxURI: "https://relay.example.com/inbox",
+		Status:   "active",
+	})
+	deps := &InboxDeps{Database: mockDB, HTTPClient: NewMockHTTPClient()}
+
+	conf := &util.AppConfig{}
+	conf.Conf.SslDomain = "local.example.com"
+	conf.Conf.TrustedRelays = []string{"other-relay.example.com"}
+
+	body := []byte(`{"id": "https://remote.example.com/activities/create-relayed", "type": "Create", "actor": "https://remote.example.com/users/bob", "to": ["https://www.w3.org/ns/activitystreams#Public"], "object": {"id": "https://remote.example.com/notes/relayed", "type": "Note", "attributedTo": "https://remote.example.com/users/bob", "content": "Hello"}}`)
+	req := createSignedRequest(t, "POST", "/inbox", body, keypair, "https://relay.example.com/actor#main-key")
+
+	rr := httptest.NewRecorder()
+	HandleInboxWithDeps(rr, req, "", conf, deps)
+
+	if rr.Code != http.StatusAccepted {
+		t.Errorf("Expected status 202 Accepted, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if len(mockDB.Activities) != 0 {
+		t.Errorf("Expected no activity from an untrusted relay, got %d", len(mockDB.Activities))
+	}
+}
+
+// TestHandleInboxWithDeps_CreateSignedByAnotherActor tests that a Create signed by someone
+// other than its actor is rejected unless the signer is a subscribed relay, even when the
+// object's attributedTo matches the actor
+func TestHandleInboxWithDeps_CreateSignedByAnotherActor(t *testing.T) {
+	mockDB := NewMockDatabase()
+	localAccount := &domain.Account{Id: uuid.New(), Username: "alice"}
+	mockDB.AddAccount(localAccount)
+
+	malloryKeys, _ := GenerateTestKeyPair()
+	bobKeys, _ := GenerateTestKeyPair()
+	for _, actor := range []struct {
+		name string
+		keys *TestKeyPair
+	}{{"mallory", malloryKeys}, {"bob", bobKeys}} {
+		remote := &domain.RemoteAccount{
+			Id:            uuid.New(),
+			Username:      actor.name,
+			Domain:        "remote.example.com",
+			ActorURI:      "https://remote.example.com/users/" + actor.name,
+			InboxURI:      "https://remote.example.com/users/" + actor.name + "/inbox",
+			PublicKeyPem:  actor.keys.PublicPEM,
+			LastFetchedAt: time.Now(),
+		}
+		mockDB.AddRemoteAccount(remote)
+		mockDB.AddFollow(&domain.Follow{Id: uuid.New(), AccountId: localAccount.Id, TargetAccountId: remote.Id, Accepted: true})
+	}
+	deps := &InboxDeps{Database: mockDB, HTTPClient: NewMockHTTPClient()}
+
+	body := []byte(`{"id": "https://remote.example.com/activities/create-bob", "type": "Create", "actor": "https://remote.example.com/users/bob", "object": {"id": "https://remote.example.com/notes/bob", "type": "Note", "attributedTo": "https://remote.example.com/users/bob", "content": "Hello"}}`)
+
+	rr := httptest.NewRecorder()
+	HandleInboxWithDeps(rr, createSignedRequest(t, "POST", "/users/alice/inbox", body, malloryKeys, "https://remote.example.com/users/mallory#main-key"), "alice", &util.AppConfig{}, deps)
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected a Create signed by mallory on behalf of bob to be rejected with 422 so it isn't retried, got %d", rr.Code)
+	}
+	if len(mockDB.Activities) != 0 {
+		t.Errorf("Expected nothing stored, got %d activities", len(mockDB.Activities))
+	}
+
+	// Signed by bob himself, the same Create is accepted
+	rr = httptest.NewRecorder()
+	HandleInboxWithDeps(rr, createSignedRequest(t, "POST", "/users/alice/inbox", body, bobKeys, "https://remote.example.com/users/bob#main-key"), "alice", &util.AppConfig{}, deps)
+	if rr.Code != http.StatusAccepted {
+		t.Errorf("Expected status 202 Accepted, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if len(mockDB.Activities) != 1 {
+		t.Errorf("Expected 1 activity, got %d", len(mockDB.Activities))
+	}
+}
+
+// TestHandleInboxWithDeps_AcceptSuccess tests successful Accept activity processing
+func TestHandleInboxWithDeps_AcceptSuccess(t *testing.T) {
+	mockDB := NewMockDatabase()
+	mockHTTP := NewMockHTTPClient()
+
+	keypair, _ := GenerateTestKeyPair()
+
+	// Local account that sent the follow
+	localAccount := &domain.Account{
+		Id:       uuid.New(),
+		Username: "alice",
+	}
+	mockDB.AddAccount(localAccount)
+
+	// Remote actor accepting the follow
+	remoteActor := &domain.RemoteAccount{
+		Id:            uuid.New(),
+		Username:      "bob",
+		Domain:        "remote.example.com",
+		ActorURI:      "https://remote.example.com/users/bob",
+		InboxURI:      "https://remote.example.com/users/bob/inbox",
+		PublicKeyPem:  keypair.PublicPEM,
+		LastFetchedAt: time.Now(),
+	}
+	mockDB.AddRemoteAccount(remoteActor)
+
+	// Pending follow to be accepted
+	followURI := "https://local.example.com/activities/follow-123"
+	pendingFollow := &domain.Follow{
+		Id:              uuid.New(),
+		AccountId:       localAccount.Id,
+		TargetAccountId: remoteActor.Id,
+		URI:             followURI,
+		Accepted:        false,
+		CreatedAt:       time.Now(),
+	}
+	mockDB.AddFollow(pendingFollow)
+
+	deps := &InboxDeps{
+		Database:   mockDB,
+		HTTPClient: mockHTTP,
 	}
 
 	conf := &util.AppConfig{}
@@ -3279,7 +4565,7 @@ func TestHandleInboxWithDeps_AcceptSuccess(t *testing.T) {
 	}
 
 	// Verify follow was accepted
-	_, follow := mockDB.ReadFollowByURI(followURI)
+	follow, _ := mockDB.ReadFollowByURI(followURI)
 	if follow == nil {
 		t.Fatal("Follow not found")
 	}
@@ -3417,6 +4703,7 @@ func TestHandleInboxWithDeps_DeleteSuccess(t *testing.T) {
 		"id": "https://remote.example.com/activities/delete-789",
 		"type": "Delete",
 		"actor": "https://remote.example.com/users/bob",
+		"to": ["https://www.w3.org/ns/activitystreams#Public"],
 		"object": "https://remote.example.com/notes/123"
 	}`)
 
@@ -3483,6 +4770,7 @@ func TestHandleInboxWithDeps_UnsupportedType(t *testing.T) {
 		"id": "https://remote.example.com/activities/question-123",
 		"type": "Question",
 		"actor": "https://remote.example.com/users/bob",
+		"to": ["https://www.w3.org/ns/activitystreams#Public"],
 		"object": "https://other.example.com/notes/456"
 	}`)
 
@@ -3547,6 +4835,7 @@ func TestHandleInboxWithDeps_ObjectURIExtraction(t *testing.T) {
 				"id": "https://remote.example.com/activities/1",
 				"type": "Announce",
 				"actor": "https://remote.example.com/users/bob",
+				"to": ["https://www.w3.org/ns/activitystreams#Public"],
 				"object": "https://example.com/notes/string-uri"
 			}`,
 			expectedObjURI: "https://example.com/notes/string-uri",
@@ -3558,6 +4847,7 @@ func TestHandleInboxWithDeps_ObjectURIExtraction(t *testing.T) {
 				"id": "https://remote.example.com/activities/2",
 				"type": "Announce",
 				"actor": "https://remote.example.com/users/bob",
+				"to": ["https://www.w3.org/ns/activitystreams#Public"],
 				"object": {
 					"id": "https://example.com/notes/map-uri",
 					"type": "Note"
@@ -3629,7 +4919,7 @@ func TestHandleAnnounceFromRelay(t *testing.T) {
 	mockClient.Responses["https://pixelfed.social/p/user/123"] = &http.Response{
 		StatusCode: 200,
 		Body:       io.NopCloser(strings.NewReader(noteJSON)),
-		Header:     make(http.Header),
+		Header:     http.Header{"Content-Type": []string{"application/activity+json"}},
 	}
 
 	// Set up response for actor fetch
@@ -3649,7 +4939,7 @@ func TestHandleAnnounceFromRelay(t *testing.T) {
 	mockClient.Responses["https://pixelfed.social/users/photographer"] = &http.Response{
 		StatusCode: 200,
 		Body:       io.NopCloser(strings.NewReader(actorJSON)),
-		Header:     make(http.Header),
+		Header:     http.Header{"Content-Type": []string{"application/activity+json"}},
 	}
 
 	deps := &InboxDeps{
@@ -3666,7 +4956,7 @@ func TestHandleAnnounceFromRelay(t *testing.T) {
 		"object": "https://pixelfed.social/p/user/123"
 	}`
 
-	err := handleAnnounceActivityWithDeps([]byte(announceBody), "alice", deps)
+	err := handleAnnounceActivityWithDeps([]byte(announceBody), "alice", nil, deps)
 	if err != nil {
 		t.Fatalf("handleAnnounceActivityWithDeps failed: %v", err)
 	}
@@ -3690,6 +4980,234 @@ func TestHandleAnnounceFromRelay(t *testing.T) {
 	}
 }
 
+// announceFromRelayWithObjectResponse runs a relay Announce whose object fetch returns resp
+// and reports how many activities were stored
+func announceFromRelayWithObjectResponse(t *testing.T, resp *http.Response) int {
+	t.Helper()
+	mockDB := NewMockDatabase()
+	mockDB.CreateRelay(&domain.Relay{
+		Id:       uuid.New(),
+		ActorURI: "https://relay.fedi.buzz/actor",
+		InboxURI: "https://relay.fedi.buzz/inbox",
+		Name:     "Test Relay",
+		Status:   "active",
+	})
+	mockDB.AddAccount(&domain.Account{Id: uuid.New(), Username: "alice"})
+
+	mockClient := NewMockHTTPClient()
+	mockClient.Responses["https://pixelfed.social/p/user/123"] = resp
+
+	announceBody := `{
+		"@context": "https://www.w3.org/ns/activitystreams",
+		"id": "https://relay.fedi.buzz/activities/announce-123",
+		"type": "Announce",
+		"actor": "https://relay.fedi.buzz/actor",
+		"object": "https://pixelfed.social/p/user/123"
+	}`
+	deps := &InboxDeps{Database: mockDB, HTTPClient: mockClient}
+	if err := handleAnnounceActivityWithDeps([]byte(announceBody), "alice", nil, deps); err != nil {
+		t.Fatalf("handleAnnounceActivityWithDeps failed: %v", err)
+	}
+	return len(mockDB.Activities)
+}
+
+// TestHandleAnnounceFromRelayFiltered tests that the relay's filter decides which
+// Announced posts are stored
+func TestHandleAnnounceFromRelayFiltered(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter domain.RelayFilter
+		stored int
+	}{
+		{"passes", domain.RelayFilter{RequiredTags: []string{"prints"}, MutedWords: []string{"crypto"}}, 1},
+		{"muted word", domain.RelayFilter{MutedWords: []string{"photo"}}, 0},
+		{"missing required tag", domain.RelayFilter{RequiredTags: []string{"music"}}, 0},
+		{"blocked author domain", domain.RelayFilter{BlockedDomains: []string{"pixelfed.social"}}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := NewMockDatabase()
+			mockDB.CreateRelay(&domain.Relay{
+				Id:       uuid.New(),
+				ActorURI: "https://relay.fedi.buzz/tag/prints",
+				InboxURI: "https://relay.fedi.buzz/inbox",
+				Status:   "active",
+				Filter:   tt.filter,
+			})
+			deps := &InboxDeps{Database: mockDB, HTTPClient: NewMockHTTPClient()}
+
+			announceBody := `{
+				"id": "https://relay.fedi.buzz/activities/announce-789",
+				"type": "Announce",
+				"actor": "https://relay.fedi.buzz/tag/prints",
+				"object": {
+					"id": "https://pixelfed.social/p/user/789",
+					"type": "Note",
+					"attributedTo": "https://pixelfed.social/users/photographer",
+					"content": "<p>A photo of my new <a href=\"https://pixelfed.social/discover/tags/prints\">#prints</a></p>"
+				}
+			}`
+			if err := handleAnnounceActivityWithDeps([]byte(announceBody), "alice", nil, deps); err != nil {
+				t.Fatalf("handleAnnounceActivityWithDeps failed: %v", err)
+			}
+			if len(mockDB.Activities) != tt.stored {
+				t.Errorf("Expected %d stored activities, got %d", tt.stored, len(mockDB.Activities))
+			}
+		})
+	}
+}
+
+// TestHandleAnnounceFromUntrustedRelay tests that Announces from subscribed relays that aren't
+// on the trusted list, or are denied, are dropped without fetching anything
+func TestHandleAnnounceFromUntrustedRelay(t *testing.T) {
+	tests := []struct {
+		name    string
+		actor   string
+		trusted []string
+		denied  []string
+	}{
+		{"other tag on a trusted relay domain", "https://relay.fedi.buzz/tag/prints", []string{"https://relay.fedi.buzz/tag/music"}, nil},
+		{"denied relay", "https://relay.fedi.buzz/tag/music", nil, []string{"relay.fedi.buzz"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := NewMockDatabase()
+			mockDB.CreateRelay(&domain.Relay{
+				Id:       uuid.New(),
+				ActorURI: "https://relay.fedi.buzz/tag/music",
+				InboxURI: "https://relay.fedi.buzz/inbox",
+				Status:   "active",
+			})
+			mockHTTP := NewMockHTTPClient()
+			deps := &InboxDeps{Database: mockDB, HTTPClient: mockHTTP}
+
+			conf := &util.AppConfig{}
+			conf.Conf.TrustedRelays = tt.trusted
+			conf.Conf.DeniedRelays = tt.denied
+
+			announceBody := `{
+				"id": "https://relay.fedi.buzz/activities/announce-456",
+				"type": "Announce",
+				"actor": "` + tt.actor + `",
+				"object": "https://pixelfed.social/p/user/123"
+			}`
+			if err := handleAnnounceActivityWithDeps([]byte(announceBody), "alice", conf, deps); err != nil {
+				t.Fatalf("handleAnnounceActivityWithDeps failed: %v", err)
+			}
+
+			if len(mockDB.Activities) != 0 {
+				t.Errorf("Expected no activity from an untrusted relay, got %d", len(mockDB.Activities))
+			}
+			if len(mockHTTP.Requests) != 0 {
+				t.Errorf("Expected no fetches for an untrusted relay, got %d", len(mockHTTP.Requests))
+			}
+		})
+	}
+}
+
+func TestHandleAnnounceFromRelayRejectsHTMLObject(t *testing.T) {
+	stored := announceFromRelayWithObjectResponse(t, &http.Response{
+		StatusCode: 200,
+		Body:       io.NopCloser(strings.NewReader(`{"id":"https://pixelfed.social/p/user/123","type":"Note","attributedTo":"https://pixelfed.social/users/photographer"}`)),
+		Header:     http.Header{"Content-Type": []string{"text/html; charset=utf-8"}},
+	})
+	if stored != 0 {
+		t.Errorf("Expected text/html object to be rejected, got %d stored activities", stored)
+	}
+}
+
+func TestHandleAnnounceFromRelayRejectsOversizedObject(t *testing.T) {
+	oversized := `{"id":"https://pixelfed.social/p/user/123","type":"Note","attributedTo":"https://pixelfed.social/users/photographer","content":"` +
+		strings.Repeat("a", defaultMaxFetchBytes) + `"}`
+	stored := announceFromRelayWithObjectResponse(t, &http.Response{
+		StatusCode:    200,
+		Body:          io.NopCloser(strings.NewReader(oversized)),
+		Header:        http.Header{"Content-Type": []string{"application/activity+json"}},
+		ContentLength: -1,
+	})
+	if stored != 0 {
+		t.Errorf("Expected oversized object to be rejected, got %d stored activities", stored)
+	}
+}
+
+func TestHandleAnnounceFromRelayFollowedAuthorSkipped(t *testing.T) {
+	mockDB := NewMockDatabase()
+
+	// Set up a relay
+	relay := &domain.Relay{
+		Id:       uuid.New(),
+		ActorURI: "https://relay.fedi.buzz/actor",
+		InboxURI: "https://relay.fedi.buzz/inbox",
+		Name:     "Test Relay",
+		Status:   "active",
+	}
+	mockDB.CreateRelay(relay)
+
+	// Set up a local account that follows the author
+	localAcct := &domain.Account{
+		Id:       uuid.New(),
+		Username: "alice",
+	}
+	mockDB.AddAccount(localAcct)
+
+	author := &domain.RemoteAccount{
+		Id:       uuid.New(),
+		Username: "photographer",
+		Domain:   "pixelfed.social",
+		ActorURI: "https://pixelfed.social/users/photographer",
+	}
+	mockDB.AddRemoteAccount(author)
+	mockDB.AddFollow(&domain.Follow{
+		Id:              uuid.New(),
+		AccountId:       localAcct.Id,
+		TargetAccountId: author.Id,
+		URI:             "https://local.example.com/activities/follow-photographer",
+		Accepted:        true,
+	})
+
+	// Create a mock HTTP client that returns a Note when fetching
+	mockClient := NewMockHTTPClient()
+	noteJSON := `{
+		"@context": "https://www.w3.org/ns/activitystreams",
+		"id": "https://pixelfed.social/p/user/123",
+		"type": "Note",
+		"attributedTo": "https://pixelfed.social/users/photographer",
+		"content": "Check out this photo!",
+		"published": "2025-01-01T12:00:00Z"
+	}`
+	mockClient.Responses["https://pixelfed.social/p/user/123"] = &http.Response{
+		StatusCode: 200,
+		Body:       io.NopCloser(strings.NewReader(noteJSON)),
+		Header:     http.Header{"Content-Type": []string{"application/activity+json"}},
+	}
+
+	deps := &InboxDeps{
+		Database:   mockDB,
+		HTTPClient: mockClient,
+	}
+
+	// Announce from relay of a post we also receive directly
+	announceBody := `{
+		"@context": "https://www.w3.org/ns/activitystreams",
+		"id": "https://relay.fedi.buzz/activities/announce-123",
+		"type": "Announce",
+		"actor": "https://relay.fedi.buzz/actor",
+		"object": "https://pixelfed.social/p/user/123"
+	}`
+
+	err := handleAnnounceActivityWithDeps([]byte(announceBody), "alice", nil, deps)
+	if err != nil {
+		t.Fatalf("handleAnnounceActivityWithDeps failed: %v", err)
+	}
+
+	// Verify no relay duplicate was stored
+	if len(mockDB.Activities) != 0 {
+		t.Errorf("Expected no relay copy of a followed author's post, got %d activities", len(mockDB.Activities))
+	}
+}
+
 func TestHandleAnnounceFromRelayWithEmbeddedObject(t *testing.T) {
 	mockDB := NewMockDatabase()
 
@@ -3729,7 +5247,7 @@ func TestHandleAnnounceFromRelayWithEmbeddedObject(t *testing.T) {
 	mockClient.Responses["https://mastodon.social/users/writer"] = &http.Response{
 		StatusCode: 200,
 		Body:       io.NopCloser(strings.NewReader(actorJSON)),
-		Header:     make(http.Header),
+		Header:     http.Header{"Content-Type": []string{"application/activity+json"}},
 	}
 
 	deps := &InboxDeps{
@@ -3752,7 +5270,7 @@ func TestHandleAnnounceFromRelayWithEmbeddedObject(t *testing.T) {
 		}
 	}`
 
-	err := handleAnnounceActivityWithDeps([]byte(announceBody), "alice", deps)
+	err := handleAnnounceActivityWithDeps([]byte(announceBody), "alice", nil, deps)
 	if err != nil {
 		t.Fatalf("handleAnnounceActivityWithDeps failed: %v", err)
 	}
@@ -3771,6 +5289,11 @@ func TestHandleAnnounceFromRelayWithEmbeddedObject(t *testing.T) {
 			t.Errorf("Expected ObjectURI from embedded object, got '%s'", act.ObjectURI)
 		}
 	}
+
+	// Relay health should reflect the delivery
+	if relay.ActivityCount != 1 || relay.LastActivityAt == nil {
+		t.Errorf("Expected relay activity to be recorded, got count %d at %v", relay.ActivityCount, relay.LastActivityAt)
+	}
 }
 
 func TestHandleAnnounceFromRelayDuplicateSkipped(t *testing.T) {
@@ -3813,7 +5336,7 @@ func TestHandleAnnounceFromRelayDuplicateSkipped(t *testing.T) {
 		"object": "https://mastodon.social/users/writer/statuses/existing"
 	}`
 
-	err := handleAnnounceActivityWithDeps([]byte(announceBody), "alice", deps)
+	err := handleAnnounceActivityWithDeps([]byte(announceBody), "alice", nil, deps)
 	if err != nil {
 		t.Fatalf("handleAnnounceActivityWithDeps failed: %v", err)
 	}
@@ -3864,7 +5387,7 @@ func TestHandleAnnounceFromRelayDuplicateByActivityURI(t *testing.T) {
 		"object": "https://mastodon.social/users/writer/statuses/different-object"
 	}`
 
-	err := handleAnnounceActivityWithDeps([]byte(announceBody), "alice", deps)
+	err := handleAnnounceActivityWithDeps([]byte(announceBody), "alice", nil, deps)
 	if err != nil {
 		t.Fatalf("handleAnnounceActivityWithDeps failed: %v", err)
 	}
@@ -3926,7 +5449,7 @@ func TestHandleAnnounceNotFromRelay(t *testing.T) {
 	mockClient.Responses["https://remote.example.com/users/bob"] = &http.Response{
 		StatusCode: 200,
 		Body:       io.NopCloser(strings.NewReader(actorJSON)),
-		Header:     make(http.Header),
+		Header:     http.Header{"Content-Type": []string{"application/activity+json"}},
 	}
 
 	deps := &InboxDeps{
@@ -3943,7 +5466,7 @@ func TestHandleAnnounceNotFromRelay(t *testing.T) {
 		"object": "https://example.com/notes/123"
 	}`
 
-	err := handleAnnounceActivityWithDeps([]byte(announceBody), "alice", deps)
+	err := handleAnnounceActivityWithDeps([]byte(announceBody), "alice", nil, deps)
 	if err != nil {
 		t.Fatalf("handleAnnounceActivityWithDeps failed: %v", err)
 	}
@@ -4120,7 +5643,7 @@ func TestHandleAnnounceFromRelayDifferentTag(t *testing.T) {
 	mockClient.Responses["https://mastodon.world/users/artist"] = &http.Response{
 		StatusCode: 200,
 		Body:       io.NopCloser(strings.NewReader(authorJSON)),
-		Header:     make(http.Header),
+		Header:     http.Header{"Content-Type": []string{"application/activity+json"}},
 	}
 
 	deps := &InboxDeps{
@@ -4144,7 +5667,7 @@ func TestHandleAnnounceFromRelayDifferentTag(t *testing.T) {
 		"published": "2024-01-15T10:30:00Z"
 	}`
 
-	err := handleAnnounceActivityWithDeps([]byte(announceBody), "alice", deps)
+	err := handleAnnounceActivityWithDeps([]byte(announceBody), "alice", nil, deps)
 	if err != nil {
 		t.Fatalf("handleAnnounceActivityWithDeps failed: %v", err)
 	}
@@ -4172,3 +5695,527 @@ func TestHandleAnnounceFromRelayDifferentTag(t *testing.T) {
 		t.Errorf("Expected 0 boosts (relay content, not boost), got %d", len(mockDB.Boosts))
 	}
 }
+
+func TestHandleCreateActivityWithDeps_StoresMentions(t *testing.T) {
+	t.Setenv("STEGODON_SSLDOMAIN", "local.example.com")
+	mockDB := NewMockDatabase()
+
+	localAccount := &domain.Account{
+		Id:       uuid.New(),
+		Username: "alice",
+	}
+	mockDB.AddAccount(localAccount)
+
+	remoteActor := &domain.RemoteAccount{
+		Id:       uuid.New(),
+		Username: "bob",
+		Domain:   "remote.example.com",
+		ActorURI: "https://remote.example.com/users/bob",
+		InboxURI: "https://remote.example.com/users/bob/inbox",
+	}
+	mockDB.AddRemoteAccount(remoteActor)
+
+	carol := &domain.RemoteAccount{
+		Id:       uuid.New(),
+		Username: "carol",
+		Domain:   "other.example.org",
+		ActorURI: "https://other.example.org/users/carol",
+	}
+	mockDB.AddRemoteAccount(carol)
+
+	mockDB.AddFollow(&domain.Follow{
+		Id:              uuid.New(),
+		AccountId:       localAccount.Id,
+		TargetAccountId: remoteActor.Id,
+		URI:             "https://local.example.com/activities/follow-123",
+		Accepted:        true,
+		CreatedAt:       time.Now(),
+	})
+
+	objectURI := "https://remote.example.com/notes/mentions"
+	// The activity is stored by HandleInbox before the handler runs
+	mockDB.AddActivity(&domain.Activity{
+		Id:           uuid.New(),
+		ActivityURI:  "https://remote.example.com/activities/create-mentions",
+		ActivityType: "Create",
+		ActorURI:     remoteActor.ActorURI,
+		ObjectURI:    objectURI,
+	})
+
+	deps := &InboxDeps{
+		Database:   mockDB,
+		HTTPClient: NewMockHTTPClient(),
+	}
+
+	createBody := []byte(`{
+		"@context": "https://www.w3.org/ns/activitystreams",
+		"id": "https://remote.example.com/activities/create-mentions",
+		"type": "Create",
+		"actor": "https://remote.example.com/users/bob",
+		"object": {
+			"id": "` + objectURI + `",
+			"type": "Note",
+			"content": "Hello @alice and @carol and @dave",
+			"published": "2025-01-01T00:00:00Z",
+			"attributedTo": "https://remote.example.com/users/bob",
+			"tag": [
+				{"type": "Mention", "href": "https://local.example.com/users/alice", "name": "@alice"},
+				{"type": "Mention", "href": "https://other.example.org/users/carol"},
+				{"type": "Mention", "href": "https://third.example.net/@dave"},
+				{"type": "Mention", "href": "https://local.example.com/users/alice", "name": "@alice@local.example.com"},
+				{"type": "Hashtag", "href": "https://remote.example.com/tags/test", "name": "#test"}
+			]
+		}
+	}`)
+
+	if err := handleCreateActivityWithDeps(createBody, "alice", false, nil, deps); err != nil {
+		t.Fatalf("handleCreateActivityWithDeps failed: %v", err)
+	}
+
+	if len(mockDB.Mentions) != 3 {
+		t.Fatalf("Expected 3 stored mentions (duplicate dropped), got %d", len(mockDB.Mentions))
+	}
+	expected := []struct{ username, domain string }{
+		{"alice", "local.example.com"},
+		{"carol", "other.example.org"},
+		{"dave", "third.example.net"},
+	}
+	for i, want := range expected {
+		got := mockDB.Mentions[i]
+		if got.MentionedUsername != want.username || got.MentionedDomain != want.domain {
+			t.Errorf("Mention %d: expected %s@%s, got %s@%s", i, want.username, want.domain, got.MentionedUsername, got.MentionedDomain)
+		}
+	}
+
+	mentionNotifications := 0
+	for _, n := range mockDB.Notifications {
+		if n.NotificationType == domain.NotificationMention {
+			mentionNotifications++
+			if n.AccountId != localAccount.Id {
+				t.Errorf("Expected mention notification for alice, got account %s", n.AccountId)
+			}
+		}
+	}
+	if mentionNotifications != 1 {
+		t.Errorf("Expected 1 mention notification, got %d", mentionNotifications)
+	}
+}
+
+func TestHandleCreateActivityWithDeps_LinksHashtags(t *testing.T) {
+	mockDB := NewMockDatabase()
+
+	localAccount := &domain.Account{Id: uuid.New(), Username: "alice"}
+	mockDB.AddAccount(localAccount)
+	remoteActor := &domain.RemoteAccount{
+		Id:       uuid.New(),
+		Username: "bob",
+		Domain:   "remote.example.com",
+		ActorURI: "https://remote.example.com/users/bob",
+		InboxURI: "https://remote.example.com/users/bob/inbox",
+	}
+	mockDB.AddRemoteAccount(remoteActor)
+	mockDB.AddFollow(&domain.Follow{Id: uuid.New(), AccountId: localAccount.Id, TargetAccountId: remoteActor.Id, Accepted: true, CreatedAt: time.Now()})
+
+	objectURI := "https://remote.example.com/notes/tagged"
+	activity := &domain.Activity{
+		Id:           uuid.New(),
+		ActivityURI:  "https://remote.example.com/activities/create-tagged",
+		ActivityType: "Create",
+		ActorURI:     remoteActor.ActorURI,
+		ObjectURI:    objectURI,
+	}
+	mockDB.AddActivity(activity)
+
+	createBody := []byte(`{
+		"id": "https://remote.example.com/activities/create-tagged",
+		"type": "Create",
+		"actor": "https://remote.example.com/users/bob",
+		"object": {
+			"id": "` + objectURI + `",
+			"type": "Note",
+			"content": "Tunes #Music #jazz",
+			"attributedTo": "https://remote.example.com/users/bob",
+			"tag": [
+				{"type": "Hashtag", "href": "https://remote.example.com/tags/music", "name": "#Music"},
+				{"type": "Hashtag", "href": "https://remote.example.com/tags/music", "name": "music"},
+				{"type": "Hashtag", "href": "https://remote.example.com/tags/jazz", "name": "#jazz"}
+			]
+		}
+	}`)
+
+	deps := &InboxDeps{Database: mockDB, HTTPClient: NewMockHTTPClient()}
+	if err := handleCreateActivityWithDeps(createBody, "alice", false, nil, deps); err != nil {
+		t.Fatalf("handleCreateActivityWithDeps failed: %v", err)
+	}
+
+	linked := mockDB.ActivityHashtags[activity.Id]
+	if strings.Join(linked, ",") != "music,jazz" {
+		t.Errorf("Expected hashtags [music jazz] linked to the activity, got %v", linked)
+	}
+}
+
+func TestResolveInboundMention(t *testing.T) {
+	mockDB := NewMockDatabase()
+	mockDB.AddRemoteAccount(&domain.RemoteAccount{
+		Id:       uuid.New(),
+		Username: "Carol",
+		Domain:   "other.example.org",
+		ActorURI: "https://other.example.org/ap/users/12345",
+	})
+
+	tests := []struct {
+		name, tagName, href      string
+		wantUsername, wantDomain string
+	}{
+		{"full name", "@bob@remote.example.com", "https://remote.example.com/users/bob", "bob", "remote.example.com"},
+		{"short name", "@bob", "https://remote.example.com/users/bob", "bob", "remote.example.com"},
+		{"local href wins", "@someone", "https://local.example.com/users/alice", "alice", "local.example.com"},
+		{"bare href known account", "", "https://other.example.org/ap/users/12345", "Carol", "other.example.org"},
+		{"bare href unknown account", "", "https://third.example.net/@dave", "dave", "third.example.net"},
+		{"nothing to resolve", "", "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			username, domainName := resolveInboundMention(tt.tagName, tt.href, "local.example.com", mockDB)
+			if username != tt.wantUsername || domainName != tt.wantDomain {
+				t.Errorf("Expected %s@%s, got %s@%s", tt.wantUsername, tt.wantDomain, username, domainName)
+			}
+		})
+	}
+}
+
+func TestActivityLanguage(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		expected string
+	}{
+		{
+			name:     "Single contentMap entry",
+			body:     `{"type":"Create","object":{"type":"Note","content":"Bonjour","contentMap":{"fr":"Bonjour"}}}`,
+			expected: "fr",
+		},
+		{
+			name:     "Region subtag is dropped",
+			body:     `{"type":"Create","object":{"type":"Note","contentMap":{"pt-BR":"Olá"}}}`,
+			expected: "pt",
+		},
+		{
+			name:     "Several languages pick the first code",
+			body:     `{"type":"Create","object":{"type":"Note","contentMap":{"en":"Hi","de":"Hallo"}}}`,
+			expected: "de",
+		},
+		{
+			name:     "No contentMap",
+			body:     `{"type":"Create","object":{"type":"Note","content":"Hi"}}`,
+			expected: "",
+		},
+		{
+			name:     "Object is a URI",
+			body:     `{"type":"Like","object":"https://example.com/notes/1"}`,
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := activityLanguage([]byte(tt.body)); result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestQuotedObjectURI(t *testing.T) {
+	tests := []struct {
+		name     string
+		object   string
+		expected string
+	}{
+		{"FEP-044f quote", `{"quote":"https://a.example/notes/1"}`, "https://a.example/notes/1"},
+		{"Fedibird quoteUri", `{"quoteUri":"https://a.example/notes/2"}`, "https://a.example/notes/2"},
+		{"Akkoma quoteUrl", `{"quoteUrl":"https://a.example/notes/3"}`, "https://a.example/notes/3"},
+		{"Misskey quote", `{"_misskey_quote":"https://a.example/notes/4"}`, "https://a.example/notes/4"},
+		{"Embedded quote object", `{"quote":{"id":"https://a.example/notes/5","type":"Note"}}`, "https://a.example/notes/5"},
+		{"FEP-e232 link tag", `{"tag":[{"type":"Hashtag","href":"https://a.example/tags/x"},{"type":"Link","mediaType":"application/ld+json; profile=\"https://www.w3.org/ns/activitystreams\"","href":"https://a.example/notes/6"}]}`, "https://a.example/notes/6"},
+		{"No quote", `{"content":"hi","tag":[{"type":"Link","mediaType":"text/html","href":"https://a.example/page"}]}`, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := []byte(`{"type":"Create","object":` + tt.object + `}`)
+			if result := quotedObjectURI(body); result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestHandleCreateActivityWithDeps_StoresQuote(t *testing.T) {
+	mockDB := NewMockDatabase()
+
+	localAccount := &domain.Account{Id: uuid.New(), Username: "alice"}
+	mockDB.AddAccount(localAccount)
+	remoteActor := &domain.RemoteAccount{
+		Id:       uuid.New(),
+		Username: "bob",
+		Domain:   "remote.example.com",
+		ActorURI: "https://remote.example.com/users/bob",
+		InboxURI: "https://remote.example.com/users/bob/inbox",
+	}
+	mockDB.AddRemoteAccount(remoteActor)
+	mockDB.AddFollow(&domain.Follow{Id: uuid.New(), AccountId: localAccount.Id, TargetAccountId: remoteActor.Id, Accepted: true, CreatedAt: time.Now()})
+
+	// The quoted post is already stored, so it is resolved without fetching
+	quotedURI := "https://remote.example.com/notes/original"
+	mockDB.AddActivity(&domain.Activity{
+		Id:           uuid.New(),
+		ActivityURI:  "https://remote.example.com/activities/create-original",
+		ActivityType: "Create",
+		ActorURI:     remoteActor.ActorURI,
+		ObjectURI:    quotedURI,
+		RawJSON:      `{"type":"Create","object":{"id":"` + quotedURI + `","content":"<p>The original</p>"}}`,
+	})
+
+	objectURI := "https://remote.example.com/notes/quote"
+	activity := &domain.Activity{
+		Id:           uuid.New(),
+		ActivityURI:  "https://remote.example.com/activities/create-quote",
+		ActivityType: "Create",
+		ActorURI:     remoteActor.ActorURI,
+		ObjectURI:    objectURI,
+	}
+	mockDB.AddActivity(activity)
+
+	// Misskey-style quote that also names the quoted post in inReplyTo
+	createBody := []byte(`{
+		"id": "https://remote.example.com/activities/create-quote",
+		"type": "Create",
+		"actor": "https://remote.example.com/users/bob",
+		"object": {
+			"id": "` + objectURI + `",
+			"type": "Note",
+			"content": "So true",
+			"attributedTo": "https://remote.example.com/users/bob",
+			"inReplyTo": "` + quotedURI + `",
+			"_misskey_quote": "` + quotedURI + `"
+		}
+	}`)
+
+	httpClient := NewMockHTTPClient()
+	deps := &InboxDeps{Database: mockDB, HTTPClient: httpClient}
+	if err := handleCreateActivityWithDeps(createBody, "alice", false, nil, deps); err != nil {
+		t.Fatalf("handleCreateActivityWithDeps failed: %v", err)
+	}
+
+	quote := mockDB.ActivityQuotes[activity.Id]
+	if len(quote) != 3 {
+		t.Fatalf("Expected the quote to be stored on the activity, got %v", quote)
+	}
+	if quote[0] != quotedURI || quote[1] != "@bob@remote.example.com" || quote[2] != "The original" {
+		t.Errorf("Unexpected stored quote: %v", quote)
+	}
+	if len(mockDB.IncrementReplyCountCalls) != 0 {
+		t.Errorf("Expected a quote not to count as a reply, got %v", mockDB.IncrementReplyCountCalls)
+	}
+	if len(httpClient.Requests) != 0 {
+		t.Errorf("Expected no fetch for a locally known quoted post, got %d requests", len(httpClient.Requests))
+	}
+}
+
+func TestResolveQuotedPost_Fetches(t *testing.T) {
+	mockDB := NewMockDatabase()
+	mockDB.AddRemoteAccount(&domain.RemoteAccount{
+		Id:            uuid.New(),
+		Username:      "carol",
+		Domain:        "other.example.org",
+		ActorURI:      "https://other.example.org/users/carol",
+		LastFetchedAt: time.Now(),
+	})
+
+	quotedURI := "https://other.example.org/notes/42"
+	httpClient := NewMockHTTPClient()
+	httpClient.SetJSONResponse(quotedURI, http.StatusOK, map[string]any{
+		"id":           quotedURI,
+		"type":         "Note",
+		"attributedTo": "https://other.example.org/users/carol",
+		"content":      "<p>Fetched post</p>",
+	})
+
+	author, content := resolveQuotedPost(quotedURI, &InboxDeps{Database: mockDB, HTTPClient: httpClient})
+	if author != "@carol@other.example.org" || content != "Fetched post" {
+		t.Errorf("Expected @carol@other.example.org / Fetched post, got %q / %q", author, content)
+	}
+}
+
+// TestHandleInboxWithDeps_RegisteredHandler tests that activities are dispatched to registered handlers
+func TestHandleInboxWithDeps_RegisteredHandler(t *testing.T) {
+	keypair, err := GenerateTestKeyPair()
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+	remoteActor := CreateTestRemoteAccount("https://remote.example.com", "bob", keypair.PublicPEM)
+
+	mockDB := NewMockDatabase()
+	mockDB.AddRemoteAccount(remoteActor)
+	deps := &InboxDeps{Database: mockDB, HTTPClient: NewMockHTTPClient()}
+
+	var handled *InboxRequest
+	deps.RegisterHandler("Flag", func(req *InboxRequest, deps *InboxDeps) error {
+		handled = req
+		return nil
+	})
+
+	body := []byte(`{
+		"@context": "https://www.w3.org/ns/activitystreams",
+		"id": "https://remote.example.com/activities/flag-1",
+		"type": "Flag",
+		"actor": "https://remote.example.com/users/bob",
+		"object": "https://local.example.com/notes/1"
+	}`)
+
+	w := httptest.NewRecorder()
+	HandleInboxWithDeps(w, createSignedRequest(t, "POST", "/users/alice/inbox", body, keypair, remoteActor.ActorURI+"#main-key"), "alice", &util.AppConfig{}, deps)
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d", w.Code)
+	}
+	if handled == nil {
+		t.Fatal("Registered handler was not called")
+	}
+	if handled.Username != "alice" || handled.RemoteActor == nil || handled.RemoteActor.ActorURI != remoteActor.ActorURI {
+		t.Errorf("Handler got unexpected request: username=%q actor=%v", handled.Username, handled.RemoteActor)
+	}
+	if handled.IsFromRelay {
+		t.Error("Directly signed activity should not be marked as relay content")
+	}
+
+	// The default handlers stay registered
+	if _, ok := deps.handler("Follow"); !ok {
+		t.Error("Registering a handler should keep the default handlers")
+	}
+
+	activity := mockDB.ActivitiesByURI["https://remote.example.com/activities/flag-1"]
+	if activity == nil || !activity.Processed {
+		t.Error("Activity should be stored and marked as processed")
+	}
+}
+
+// TestHandleInboxWithDeps_HandlerError tests that a failing handler makes the sender retry
+// and that the stored activity is rolled back together with the handler's writes
+func TestHandleInboxWithDeps_HandlerError(t *testing.T) {
+	keypair, err := GenerateTestKeyPair()
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+	remoteActor := CreateTestRemoteAccount("https://remote.example.com", "bob", keypair.PublicPEM)
+
+	mockDB := NewMockDatabase()
+	mockDB.AddRemoteAccount(remoteActor)
+	deps := &InboxDeps{Database: mockDB, HTTPClient: NewMockHTTPClient()}
+	deps.RegisterHandler("Like", func(req *InboxRequest, deps *InboxDeps) error {
+		return deps.Database.WithTx(func(tx Tx) error {
+			if err := tx.CreateNotification(&domain.Notification{Id: uuid.New()}); err != nil {
+				return err
+			}
+			return io.ErrUnexpectedEOF
+		})
+	})
+
+	body := []byte(`{
+		"id": "https://remote.example.com/activities/like-1",
+		"type": "Like",
+		"actor": "https://remote.example.com/users/bob",
+		"object": "https://local.example.com/notes/1"
+	}`)
+
+	w := httptest.NewRecorder()
+	HandleInboxWithDeps(w, createSignedRequest(t, "POST", "/users/alice/inbox", body, keypair, remoteActor.ActorURI+"#main-key"), "alice", &util.AppConfig{}, deps)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status 500, got %d", w.Code)
+	}
+	if len(mockDB.Activities) != 0 || len(mockDB.Notifications) != 0 {
+		t.Errorf("Expected the activity and the handler's writes to be rolled back, got %d activities and %d notifications", len(mockDB.Activities), len(mockDB.Notifications))
+	}
+	if mockDB.RolledBackTxs != 1 {
+		t.Errorf("Expected 1 rolled back transaction, got %d", mockDB.RolledBackTxs)
+	}
+}
+
+// TestHandleInboxWithDeps_HandlerTransaction tests that handlers run outside a transaction, so
+// they can fetch remote data without holding the database, and that the activity is stored
+// in the transaction they write in
+func TestHandleInboxWithDeps_HandlerTransaction(t *testing.T) {
+	keypair, err := GenerateTestKeyPair()
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+	remoteActor := CreateTestRemoteAccount("https://remote.example.com", "bob", keypair.PublicPEM)
+
+	mockDB := NewMockDatabase()
+	mockDB.AddRemoteAccount(remoteActor)
+	deps := &InboxDeps{Database: mockDB, HTTPClient: NewMockHTTPClient()}
+	deps.RegisterHandler("Like", func(req *InboxRequest, deps *InboxDeps) error {
+		if mockDB.OpenTxs != 0 {
+			t.Errorf("Expected the handler to start outside a transaction, %d open", mockDB.OpenTxs)
+		}
+		return deps.Database.WithTx(func(tx Tx) error {
+			if len(mockDB.Activities) != 1 {
+				t.Errorf("Expected the activity to be stored in the handler's transaction, got %d activities", len(mockDB.Activities))
+			}
+			return tx.CreateNotification(&domain.Notification{Id: uuid.New()})
+		})
+	})
+
+	body := []byte(`{
+		"id": "https://remote.example.com/activities/like-1",
+		"type": "Like",
+		"actor": "https://remote.example.com/users/bob",
+		"object": "https://local.example.com/notes/1"
+	}`)
+
+	w := httptest.NewRecorder()
+	HandleInboxWithDeps(w, createSignedRequest(t, "POST", "/users/alice/inbox", body, keypair, remoteActor.ActorURI+"#main-key"), "alice", &util.AppConfig{}, deps)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d", w.Code)
+	}
+	if len(mockDB.Activities) != 1 || len(mockDB.Notifications) != 1 {
+		t.Fatalf("Expected 1 activity and 1 notification, got %d and %d", len(mockDB.Activities), len(mockDB.Notifications))
+	}
+	for _, activity := range mockDB.Activities {
+		if !activity.Processed {
+			t.Error("Expected the activity to be marked processed")
+		}
+	}
+}
+
+// TestHandleInboxWithDeps_UnknownType tests that activities without a handler are stored and accepted
+func TestHandleInboxWithDeps_UnknownType(t *testing.T) {
+	keypair, err := GenerateTestKeyPair()
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+	remoteActor := CreateTestRemoteAccount("https://remote.example.com", "bob", keypair.PublicPEM)
+
+	mockDB := NewMockDatabase()
+	mockDB.AddRemoteAccount(remoteActor)
+	deps := &InboxDeps{Database: mockDB, HTTPClient: NewMockHTTPClient()}
+
+	body := []byte(`{
+		"id": "https://remote.example.com/activities/move-1",
+		"type": "Move",
+		"actor": "https://remote.example.com/users/bob",
+		"object": "https://remote.example.com/users/bob"
+	}`)
+
+	w := httptest.NewRecorder()
+	HandleInboxWithDeps(w, createSignedRequest(t, "POST", "/users/alice/inbox", body, keypair, remoteActor.ActorURI+"#main-key"), "alice", &util.AppConfig{}, deps)
+
+	if w.Code != http.StatusAccepted {
+		t.Errorf("Expected status 202, got %d", w.Code)
+	}
+	if mockDB.ActivitiesByURI["https://remote.example.com/activities/move-1"] == nil {
+		t.Error("Unsupported activity should still be stored")
+	}
+}
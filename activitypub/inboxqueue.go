@@ -0,0 +1,123 @@
+package activitypub
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"time"
+
+	"github.com/deemkeen/stegodon/domain"
+	"github.com/deemkeen/stegodon/util"
+)
+
+const (
+	// inboxQueueBatchSize is how many queued activities the inbox worker handles per run
+	inboxQueueBatchSize = 50
+	// maxInboxAttempts is how often a queued activity is handled before it is given up
+	maxInboxAttempts = 5
+)
+
+// inboxQueueWake tells the inbox worker that an activity was queued
+var inboxQueueWake = make(chan struct{}, 1)
+
+// wakeInboxWorker makes the inbox worker run now instead of at its next tick
+func wakeInboxWorker() {
+	select {
+	case inboxQueueWake <- struct{}{}:
+	default:
+	}
+}
+
+// StartInboxWorker starts a background worker that handles the activities queued by the inbox
+// in inboxQueue mode. It also drains activities left queued after switching back to handling
+// them during the request. Returns a stop function that can be called to stop the worker.
+func StartInboxWorker(conf *util.AppConfig) func() {
+	log.Println("Starting ActivityPub inbox worker...")
+
+	ticker := time.NewTicker(5 * time.Second)
+	stop := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				processInboxQueue(conf)
+			case <-inboxQueueWake:
+				processInboxQueue(conf)
+			case <-stop:
+				ticker.Stop()
+				log.Println("ActivityPub inbox worker stopped")
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+	}
+}
+
+// processInboxQueue handles queued inbox activities.
+// This is the production wrapper that uses the default database and HTTP client.
+func processInboxQueue(conf *util.AppConfig) {
+	deps := &InboxDeps{
+		Database:   NewDBWrapper(),
+		HTTPClient: defaultHTTPClient,
+	}
+	processInboxQueueWithDeps(conf, deps)
+}
+
+// processInboxQueueWithDeps handles queued inbox activities in the order they arrived.
+// Failed activities are retried with backoff and given up after maxInboxAttempts; rejected
+// ones are dropped right away.
+func processInboxQueueWithDeps(conf *util.AppConfig, deps *InboxDeps) {
+	database := deps.Database
+	logger := deps.logger().With("component", "inbox")
+
+	items, err := database.ReadPendingInboxActivities(inboxQueueBatchSize)
+	if err != nil {
+		logger.Error("InboxWorker: Failed to read queue", "error", err)
+		return
+	}
+
+	for _, item := range *items {
+		itemLogger := logger.With("username", item.Username, "signer", item.SignerURI, "queued", item.Id.String())
+		if err := handleQueuedInboxActivity(&item, conf, deps, itemLogger); errors.Is(err, ErrActivityRejected) {
+			// Refused by policy, a retry would be refused all the same
+			itemLogger.Warn("InboxWorker: Dropping rejected activity", "error", err)
+			database.DeleteInboxActivity(item.Id)
+			continue
+		} else if err != nil {
+			item.Attempts++
+			if item.Attempts >= maxInboxAttempts {
+				itemLogger.Error(fmt.Sprintf("InboxWorker: Giving up on activity after %d attempts", item.Attempts), "error", err, "attempts", item.Attempts)
+				database.DeleteInboxActivity(item.Id)
+				continue
+			}
+			delay := time.Duration([]int{1, 5, 15, 60}[min(item.Attempts-1, 3)]) * time.Minute
+			itemLogger.Warn(fmt.Sprintf("InboxWorker: Handling failed (attempt %d), retry in %s", item.Attempts, delay),
+				"error", err, "attempts", item.Attempts, "retry_in", delay.String())
+			database.UpdateInboxActivityAttempt(item.Id, item.Attempts, time.Now().Add(delay))
+			continue
+		}
+		database.DeleteInboxActivity(item.Id)
+	}
+}
+
+// handleQueuedInboxActivity handles an activity the inbox verified and queued
+func handleQueuedInboxActivity(item *domain.InboxQueueItem, conf *util.AppConfig, deps *InboxDeps, logger *slog.Logger) error {
+	body := []byte(item.ActivityJSON)
+	var activity Activity
+	if err := json.Unmarshal(body, &activity); err != nil {
+		return fmt.Errorf("failed to parse queued activity: %w", err)
+	}
+	logger = logger.With("actor", activity.Actor, "type", activity.Type, "activity", activity.ID)
+
+	signerActor, err := GetOrFetchActorWithDeps(item.SignerURI, deps.HTTPClient, deps.Database)
+	if err != nil {
+		return fmt.Errorf("failed to fetch signer %s: %w", item.SignerURI, err)
+	}
+	return processInboxActivityWithDeps(body, activity, item.Username, item.SignerURI, signerActor, conf, deps, logger)
+}
@@ -0,0 +1,160 @@
+package activitypub
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/deemkeen/stegodon/domain"
+	"github.com/deemkeen/stegodon/util"
+	"github.com/google/uuid"
+)
+
+// TestHandleInboxWithDeps_QueuedActivity tests that in inboxQueue mode a verified activity is
+// accepted right away and only handled by the inbox worker
+func TestHandleInboxWithDeps_QueuedActivity(t *testing.T) {
+	mockDB := NewMockDatabase()
+	keypair, _ := GenerateTestKeyPair()
+
+	localAccount := &domain.Account{Id: uuid.New(), Username: "alice"}
+	mockDB.AddAccount(localAccount)
+	remoteActor := &domain.RemoteAccount{
+		Id:            uuid.New(),
+		Username:      "bob",
+		Domain:        "remote.example.com",
+		ActorURI:      "https://remote.example.com/users/bob",
+		InboxURI:      "https://remote.example.com/users/bob/inbox",
+		PublicKeyPem:  keypair.PublicPEM,
+		LastFetchedAt: time.Now(),
+	}
+	mockDB.AddRemoteAccount(remoteActor)
+
+	followURI := "https://local.example.com/activities/follow-123"
+	mockDB.AddFollow(&domain.Follow{
+		Id:              uuid.New(),
+		AccountId:       localAccount.Id,
+		TargetAccountId: remoteActor.Id,
+		URI:             followURI,
+		CreatedAt:       time.Now(),
+	})
+
+	deps := &InboxDeps{Database: mockDB, HTTPClient: NewMockHTTPClient()}
+	conf := &util.AppConfig{}
+	conf.Conf.SslDomain = "local.example.com"
+	conf.Conf.InboxQueue = true
+
+	body := []byte(`{
+		"@context": "https://www.w3.org/ns/activitystreams",
+		"id": "https://remote.example.com/activities/accept-456",
+		"type": "Accept",
+		"actor": "https://remote.example.com/users/bob",
+		"object": "https://local.example.com/activities/follow-123"
+	}`)
+	req := createSignedRequest(t, "POST", "/users/alice/inbox", body, keypair, "https://remote.example.com/users/bob#main-key")
+
+	rr := httptest.NewRecorder()
+	HandleInboxWithDeps(rr, req, "alice", conf, deps)
+
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202 Accepted, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if len(mockDB.InboxQueue) != 1 {
+		t.Fatalf("Expected 1 queued activity, got %d", len(mockDB.InboxQueue))
+	}
+	if item := mockDB.InboxQueue[0]; item.Username != "alice" || item.SignerURI != remoteActor.ActorURI {
+		t.Errorf("Unexpected queued activity: %+v", item)
+	}
+	if follow, _ := mockDB.ReadFollowByURI(followURI); follow.Accepted {
+		t.Error("Follow should not be accepted before the worker ran")
+	}
+
+	processInboxQueueWithDeps(conf, deps)
+
+	if follow, _ := mockDB.ReadFollowByURI(followURI); !follow.Accepted {
+		t.Error("Follow should be accepted by the worker")
+	}
+	if len(mockDB.InboxQueue) != 0 {
+		t.Errorf("Expected the handled activity to be removed from the queue, got %d", len(mockDB.InboxQueue))
+	}
+}
+
+// TestProcessInboxQueueWithDeps_Retry tests that a failed activity is rescheduled and
+// eventually given up
+func TestProcessInboxQueueWithDeps_Retry(t *testing.T) {
+	mockDB := NewMockDatabase()
+	deps := &InboxDeps{Database: mockDB, HTTPClient: NewMockHTTPClient()}
+	conf := &util.AppConfig{}
+	conf.Conf.SslDomain = "local.example.com"
+
+	// The signer can't be fetched, so handling fails
+	item := &domain.InboxQueueItem{
+		Id:            uuid.New(),
+		Username:      "alice",
+		SignerURI:     "https://gone.example.com/users/bob",
+		ActivityJSON:  `{"id":"https://gone.example.com/activities/1","type":"Like","actor":"https://gone.example.com/users/bob","object":"https://local.example.com/notes/1"}`,
+		NextAttemptAt: time.Now(),
+		CreatedAt:     time.Now(),
+	}
+	mockDB.EnqueueInboxActivity(item)
+
+	processInboxQueueWithDeps(conf, deps)
+
+	if len(mockDB.InboxQueue) != 1 {
+		t.Fatalf("Expected the failed activity to stay queued, got %d", len(mockDB.InboxQueue))
+	}
+	if item.Attempts != 1 || !item.NextAttemptAt.After(time.Now()) {
+		t.Errorf("Expected 1 attempt and a later retry, got %d attempts at %v", item.Attempts, item.NextAttemptAt)
+	}
+
+	// Not due yet, so the next run leaves it alone
+	processInboxQueueWithDeps(conf, deps)
+	if item.Attempts != 1 {
+		t.Errorf("Expected the activity to wait for its retry, got %d attempts", item.Attempts)
+	}
+
+	item.Attempts = maxInboxAttempts - 1
+	item.NextAttemptAt = time.Now()
+	processInboxQueueWithDeps(conf, deps)
+	if len(mockDB.InboxQueue) != 0 {
+		t.Errorf("Expected the activity to be given up after %d attempts", maxInboxAttempts)
+	}
+}
+
+// TestProcessInboxQueueWithDeps_Rejected tests that an activity refused by policy is dropped
+// right away instead of being retried
+func TestProcessInboxQueueWithDeps_Rejected(t *testing.T) {
+	mockDB := NewMockDatabase()
+	deps := &InboxDeps{Database: mockDB, HTTPClient: NewMockHTTPClient()}
+	conf := &util.AppConfig{}
+	conf.Conf.SslDomain = "local.example.com"
+
+	mallory := &domain.RemoteAccount{
+		Id:            uuid.New(),
+		Username:      "mallory",
+		Domain:        "remote.example.com",
+		ActorURI:      "https://remote.example.com/users/mallory",
+		LastFetchedAt: time.Now(),
+	}
+	mockDB.AddRemoteAccount(mallory)
+
+	// Signed by mallory on behalf of bob, and mallory is no relay
+	item := &domain.InboxQueueItem{
+		Id:            uuid.New(),
+		Username:      "alice",
+		SignerURI:     mallory.ActorURI,
+		ActivityJSON:  `{"id":"https://remote.example.com/activities/create-bob","type":"Create","actor":"https://remote.example.com/users/bob","object":{"id":"https://remote.example.com/notes/bob","type":"Note","attributedTo":"https://remote.example.com/users/bob","content":"Hello"}}`,
+		NextAttemptAt: time.Now(),
+		CreatedAt:     time.Now(),
+	}
+	mockDB.EnqueueInboxActivity(item)
+
+	processInboxQueueWithDeps(conf, deps)
+
+	if len(mockDB.InboxQueue) != 0 {
+		t.Errorf("Expected the rejected activity to be dropped, got %d queued after %d attempts", len(mockDB.InboxQueue), item.Attempts)
+	}
+	if len(mockDB.Activities) != 0 {
+		t.Errorf("Expected nothing stored, got %d activities", len(mockDB.Activities))
+	}
+}
@@ -0,0 +1,59 @@
+package activitypub
+
+import (
+	"mime"
+	"strings"
+)
+
+const (
+	// ActivityStreamsProfile is the JSON-LD profile that marks application/ld+json as ActivityStreams
+	ActivityStreamsProfile = "https://www.w3.org/ns/activitystreams"
+	// ActivityJSONContentType is the content type of the ActivityPub documents we serve and send
+	ActivityJSONContentType = "application/activity+json"
+	// LDJSONContentType is the JSON-LD content type with the ActivityStreams profile
+	LDJSONContentType = `application/ld+json; profile="` + ActivityStreamsProfile + `"`
+	// ActivityAcceptHeader asks remote servers for either ActivityStreams representation
+	ActivityAcceptHeader = ActivityJSONContentType + ", " + LDJSONContentType
+)
+
+// IsActivityStreamsContentType reports whether a Content-Type header names an ActivityStreams
+// document: application/activity+json, or application/ld+json without a profile or with the
+// ActivityStreams one among its profiles. Parameters such as charset are ignored.
+func IsActivityStreamsContentType(contentType string) bool {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	switch mediaType {
+	case "application/activity+json":
+		return true
+	case "application/ld+json":
+		profile, ok := params["profile"]
+		if !ok {
+			return true
+		}
+		// The profile parameter is a space-separated list of URIs
+		for _, uri := range strings.Fields(profile) {
+			if strings.TrimSuffix(uri, "#") == ActivityStreamsProfile {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
+
+// isInboxContentType reports whether a POST to an inbox carries a content type we process.
+// Besides the ActivityStreams types, plain application/json and a missing header are
+// accepted, since some servers send those.
+func isInboxContentType(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	if IsActivityStreamsContentType(contentType) {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == "application/json"
+}
@@ -0,0 +1,96 @@
+package activitypub
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestIsActivityStreamsContentType(t *testing.T) {
+	tests := []struct {
+		contentType string
+		want        bool
+	}{
+		{"application/activity+json", true},
+		{"application/activity+json; charset=utf-8", true},
+		{`application/ld+json; profile="https://www.w3.org/ns/activitystreams"`, true},
+		{`application/ld+json;profile="https://www.w3.org/ns/activitystreams"`, true},
+		{`Application/LD+JSON; Profile="https://www.w3.org/ns/activitystreams"; charset=utf-8`, true},
+		{`application/ld+json; profile="https://www.w3.org/ns/activitystreams#"`, true},
+		{`application/ld+json; profile="https://w3id.org/security/v1 https://www.w3.org/ns/activitystreams"`, true},
+		{"application/ld+json", true},
+		{`application/ld+json; profile="https://example.com/other"`, false},
+		{"application/json", false},
+		{"text/html; charset=utf-8", false},
+		{"", false},
+		{"application/ld+json; profile=", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsActivityStreamsContentType(tt.contentType); got != tt.want {
+			t.Errorf("IsActivityStreamsContentType(%q) = %v, want %v", tt.contentType, got, tt.want)
+		}
+	}
+}
+
+func TestIsInboxContentType(t *testing.T) {
+	tests := []struct {
+		contentType string
+		want        bool
+	}{
+		{"", true},
+		{"application/activity+json", true},
+		{`application/ld+json; profile="https://www.w3.org/ns/activitystreams"`, true},
+		{"application/json; charset=utf-8", true},
+		{"text/plain", false},
+		{"application/x-www-form-urlencoded", false},
+	}
+
+	for _, tt := range tests {
+		if got := isInboxContentType(tt.contentType); got != tt.want {
+			t.Errorf("isInboxContentType(%q) = %v, want %v", tt.contentType, got, tt.want)
+		}
+	}
+}
+
+// TestHandleInboxWithDeps_ContentTypes tests that deliveries with the JSON-LD profile content
+// type are processed and ones that aren't JSON are rejected
+func TestHandleInboxWithDeps_ContentTypes(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		wantStatus  int
+	}{
+		{"ld json with profile", `application/ld+json; profile="https://www.w3.org/ns/activitystreams"`, http.StatusAccepted},
+		{"ld json with profile and charset", `application/ld+json;profile="https://www.w3.org/ns/activitystreams";charset=utf-8`, http.StatusAccepted},
+		{"plain text", "text/plain", http.StatusUnsupportedMediaType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB, _, deps, _, remoteActor, conf := setupFollowTest(t)
+			keypair, _ := GenerateTestKeyPair()
+			remoteActor.PublicKeyPem = keypair.PublicPEM
+			remoteActor.LastFetchedAt = time.Now()
+
+			body := []byte(`{"id": "https://remote.example.com/activities/follow-ld", "type": "Follow", "actor": "https://remote.example.com/users/bob", "object": "https://local.example.com/users/alice"}`)
+			req := createSignedRequest(t, "POST", "/users/alice/inbox", body, keypair, "https://remote.example.com/users/bob#main-key")
+			req.Header.Set("Content-Type", tt.contentType)
+
+			rr := httptest.NewRecorder()
+			HandleInboxWithDeps(rr, req, "alice", conf, deps)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+			wantFollows := 0
+			if tt.wantStatus == http.StatusAccepted {
+				wantFollows = 1
+			}
+			if len(mockDB.Follows) != wantFollows {
+				t.Errorf("Expected %d follows, got %d", wantFollows, len(mockDB.Follows))
+			}
+		})
+	}
+}
@@ -2,6 +2,9 @@ package activitypub
 
 import (
 	"database/sql"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -26,8 +29,11 @@ type MockDatabase struct {
 	ActivitiesByObj map[string]*domain.Activity
 	ActivitiesByURI map[string]*domain.Activity // Index by ActivityURI
 	DeliveryQueue   map[uuid.UUID]*domain.DeliveryQueueItem
+	deliverySeq     int64 // Last Seq assigned to a queued delivery
+	InboxQueue      []*domain.InboxQueueItem
 	Notes           map[uuid.UUID]*domain.Note
 	NotesByURI      map[string]*domain.Note
+	NoteObjects     map[uuid.UUID]string // Note JSON stored via UpdateNoteObjectJSON
 	Likes           map[uuid.UUID]*domain.Like
 	LikesByURI      map[string]*domain.Like
 	Boosts          map[uuid.UUID]*domain.Boost
@@ -35,35 +41,47 @@ type MockDatabase struct {
 	RelaysByURI     map[string]*domain.Relay
 
 	// Error injection for testing error handling
-	ForceError error
+	ForceError   error
+	EnqueueError error // Returned by EnqueueDelivery/EnqueueDeliveryBatch, to fail a transaction after earlier writes
 
 	// Call tracking for testing
 	IncrementReplyCountCalls []string    // URIs passed to IncrementReplyCountByURI
 	IncrementLikeCountCalls  []uuid.UUID // Note IDs passed to IncrementLikeCountByNoteId
 	IncrementBoostCountCalls []uuid.UUID // Note IDs passed to IncrementBoostCountByNoteId
+	EnqueueCalls             int         // Number of EnqueueDelivery/EnqueueDeliveryBatch calls (one transaction each)
+	RolledBackTxs            int         // Number of WithTx calls whose writes were rolled back
+	OpenTxs                  int         // Number of WithTx calls currently running
+	Mentions                 []*domain.NoteMention
+	ActivityHashtags         map[uuid.UUID][]string // Hashtags linked via LinkActivityHashtags
+	ActivityQuotes           map[uuid.UUID][]string // Quote URI, author and content set via UpdateActivityQuote
+	Notifications            []*domain.Notification
+	Reactions                []*domain.Reaction
 }
 
 // NewMockDatabase creates a new mock database with initialized maps
 func NewMockDatabase() *MockDatabase {
 	return &MockDatabase{
-		Accounts:        make(map[uuid.UUID]*domain.Account),
-		AccountsByUser:  make(map[string]*domain.Account),
-		RemoteAccounts:  make(map[uuid.UUID]*domain.RemoteAccount),
-		RemoteByURI:     make(map[string]*domain.RemoteAccount),
-		RemoteByActor:   make(map[string]*domain.RemoteAccount),
-		Follows:         make(map[uuid.UUID]*domain.Follow),
-		FollowsByURI:    make(map[string]*domain.Follow),
-		Activities:      make(map[uuid.UUID]*domain.Activity),
-		ActivitiesByObj: make(map[string]*domain.Activity),
-		ActivitiesByURI: make(map[string]*domain.Activity),
-		DeliveryQueue:   make(map[uuid.UUID]*domain.DeliveryQueueItem),
-		Notes:           make(map[uuid.UUID]*domain.Note),
-		NotesByURI:      make(map[string]*domain.Note),
-		Likes:           make(map[uuid.UUID]*domain.Like),
-		LikesByURI:      make(map[string]*domain.Like),
-		Boosts:          make(map[uuid.UUID]*domain.Boost),
-		Relays:          make(map[uuid.UUID]*domain.Relay),
-		RelaysByURI:     make(map[string]*domain.Relay),
+		Accounts:         make(map[uuid.UUID]*domain.Account),
+		AccountsByUser:   make(map[string]*domain.Account),
+		RemoteAccounts:   make(map[uuid.UUID]*domain.RemoteAccount),
+		RemoteByURI:      make(map[string]*domain.RemoteAccount),
+		RemoteByActor:    make(map[string]*domain.RemoteAccount),
+		Follows:          make(map[uuid.UUID]*domain.Follow),
+		FollowsByURI:     make(map[string]*domain.Follow),
+		Activities:       make(map[uuid.UUID]*domain.Activity),
+		ActivitiesByObj:  make(map[string]*domain.Activity),
+		ActivitiesByURI:  make(map[string]*domain.Activity),
+		DeliveryQueue:    make(map[uuid.UUID]*domain.DeliveryQueueItem),
+		Notes:            make(map[uuid.UUID]*domain.Note),
+		NotesByURI:       make(map[string]*domain.Note),
+		NoteObjects:      make(map[uuid.UUID]string),
+		Likes:            make(map[uuid.UUID]*domain.Like),
+		LikesByURI:       make(map[string]*domain.Like),
+		Boosts:           make(map[uuid.UUID]*domain.Boost),
+		Relays:           make(map[uuid.UUID]*domain.Relay),
+		RelaysByURI:      make(map[string]*domain.Relay),
+		ActivityHashtags: make(map[uuid.UUID][]string),
+		ActivityQuotes:   make(map[uuid.UUID][]string),
 	}
 }
 
@@ -120,71 +138,98 @@ func (m *MockDatabase) AddDeliveryQueueItem(item *domain.DeliveryQueueItem) {
 
 // Account operations
 
-func (m *MockDatabase) ReadAccByUsername(username string) (error, *domain.Account) {
+func (m *MockDatabase) ReadAccByUsername(username string) (*domain.Account, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 	if m.ForceError != nil {
-		return m.ForceError, nil
+		return nil, m.ForceError
 	}
 	acc, ok := m.AccountsByUser[username]
 	if !ok {
-		return sql.ErrNoRows, nil
+		return nil, sql.ErrNoRows
 	}
-	return nil, acc
+	return acc, nil
 }
 
-func (m *MockDatabase) ReadAccById(id uuid.UUID) (error, *domain.Account) {
+func (m *MockDatabase) ReadAccById(id uuid.UUID) (*domain.Account, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 	if m.ForceError != nil {
-		return m.ForceError, nil
+		return nil, m.ForceError
 	}
 	acc, ok := m.Accounts[id]
 	if !ok {
-		return sql.ErrNoRows, nil
+		return nil, sql.ErrNoRows
 	}
-	return nil, acc
+	return acc, nil
+}
+
+func (m *MockDatabase) ReadAllAccounts() (*[]domain.Account, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.ForceError != nil {
+		return nil, m.ForceError
+	}
+	accounts := make([]domain.Account, 0, len(m.Accounts))
+	for _, acc := range m.Accounts {
+		accounts = append(accounts, *acc)
+	}
+	return &accounts, nil
 }
 
 // Remote account operations
 
-func (m *MockDatabase) ReadRemoteAccountByURI(uri string) (error, *domain.RemoteAccount) {
+func (m *MockDatabase) ReadRemoteAccountByURI(uri string) (*domain.RemoteAccount, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 	if m.ForceError != nil {
-		return m.ForceError, nil
+		return nil, m.ForceError
 	}
 	acc, ok := m.RemoteByURI[uri]
 	if !ok {
-		return sql.ErrNoRows, nil
+		return nil, sql.ErrNoRows
 	}
-	return nil, acc
+	return acc, nil
 }
 
-func (m *MockDatabase) ReadRemoteAccountById(id uuid.UUID) (error, *domain.RemoteAccount) {
+func (m *MockDatabase) ReadRemoteAccountById(id uuid.UUID) (*domain.RemoteAccount, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 	if m.ForceError != nil {
-		return m.ForceError, nil
+		return nil, m.ForceError
 	}
 	acc, ok := m.RemoteAccounts[id]
 	if !ok {
-		return sql.ErrNoRows, nil
+		return nil, sql.ErrNoRows
 	}
-	return nil, acc
+	return acc, nil
 }
 
-func (m *MockDatabase) ReadRemoteAccountByActorURI(actorURI string) (error, *domain.RemoteAccount) {
+func (m *MockDatabase) ReadRemoteAccountByActorURI(actorURI string) (*domain.RemoteAccount, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 	if m.ForceError != nil {
-		return m.ForceError, nil
+		return nil, m.ForceError
 	}
 	acc, ok := m.RemoteByActor[actorURI]
 	if !ok {
 		return nil, nil
 	}
-	return nil, acc
+	return acc, nil
+}
+
+func (m *MockDatabase) ReadRemoteAccountByUsernameAndDomain(username, domainName string) (*domain.RemoteAccount, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.ForceError != nil {
+		return nil, m.ForceError
+	}
+	for _, acc := range m.RemoteAccounts {
+		if strings.EqualFold(acc.Username, username) && strings.EqualFold(acc.Domain, domainName) {
+			return acc, nil
+		}
+	}
+	return nil, sql.ErrNoRows
 }
 
 func (m *MockDatabase) CreateRemoteAccount(acc *domain.RemoteAccount) error {
@@ -240,31 +285,72 @@ func (m *MockDatabase) CreateFollow(follow *domain.Follow) error {
 	return nil
 }
 
-func (m *MockDatabase) ReadFollowByURI(uri string) (error, *domain.Follow) {
+func (m *MockDatabase) ReadFollowByURI(uri string) (*domain.Follow, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 	if m.ForceError != nil {
-		return m.ForceError, nil
+		return nil, m.ForceError
 	}
 	follow, ok := m.FollowsByURI[uri]
 	if !ok {
-		return sql.ErrNoRows, nil
+		return nil, sql.ErrNoRows
 	}
-	return nil, follow
+	return follow, nil
 }
 
-func (m *MockDatabase) ReadFollowByAccountIds(accountId, targetAccountId uuid.UUID) (error, *domain.Follow) {
+func (m *MockDatabase) ReadFollowByAccountIds(accountId, targetAccountId uuid.UUID) (*domain.Follow, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 	if m.ForceError != nil {
-		return m.ForceError, nil
+		return nil, m.ForceError
 	}
 	for _, follow := range m.Follows {
 		if follow.AccountId == accountId && follow.TargetAccountId == targetAccountId {
-			return nil, follow
+			return follow, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *MockDatabase) IsRemoteAccountFollowed(remoteAccountId uuid.UUID) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.ForceError != nil {
+		return false, m.ForceError
+	}
+	for _, follow := range m.Follows {
+		if follow.TargetAccountId == remoteAccountId && follow.Accepted {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *MockDatabase) ReadFollowingAddresses(accountId uuid.UUID, localDomain string) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.ForceError != nil {
+		return nil, m.ForceError
+	}
+	var follows []*domain.Follow
+	for _, follow := range m.Follows {
+		if follow.AccountId == accountId {
+			follows = append(follows, follow)
 		}
 	}
-	return sql.ErrNoRows, nil
+	sort.Slice(follows, func(i, j int) bool { return follows[i].CreatedAt.Before(follows[j].CreatedAt) })
+
+	var addresses []string
+	for _, follow := range follows {
+		if follow.IsLocal {
+			if acc, ok := m.Accounts[follow.TargetAccountId]; ok {
+				addresses = append(addresses, acc.Username+"@"+localDomain)
+			}
+		} else if remote, ok := m.RemoteAccounts[follow.TargetAccountId]; ok {
+			addresses = append(addresses, remote.Username+"@"+remote.Domain)
+		}
+	}
+	return addresses, nil
 }
 
 func (m *MockDatabase) DeleteFollowByURI(uri string) error {
@@ -292,11 +378,11 @@ func (m *MockDatabase) AcceptFollowByURI(uri string) error {
 	return nil
 }
 
-func (m *MockDatabase) ReadFollowersByAccountId(accountId uuid.UUID) (error, *[]domain.Follow) {
+func (m *MockDatabase) ReadFollowersByAccountId(accountId uuid.UUID) (*[]domain.Follow, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 	if m.ForceError != nil {
-		return m.ForceError, nil
+		return nil, m.ForceError
 	}
 	var followers []domain.Follow
 	for _, follow := range m.Follows {
@@ -304,7 +390,35 @@ func (m *MockDatabase) ReadFollowersByAccountId(accountId uuid.UUID) (error, *[]
 			followers = append(followers, *follow)
 		}
 	}
-	return nil, &followers
+	return &followers, nil
+}
+
+func (m *MockDatabase) ReadFollowerInboxURIs(accountId uuid.UUID) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.ForceError != nil {
+		return nil, m.ForceError
+	}
+	seen := make(map[string]bool)
+	var inboxes []string
+	for _, follow := range m.Follows {
+		if follow.TargetAccountId != accountId || !follow.Accepted || follow.IsLocal {
+			continue
+		}
+		remote, ok := m.RemoteAccounts[follow.AccountId]
+		if !ok || remote.InboxURI == "" {
+			continue
+		}
+		inboxURI := remote.InboxURI
+		if remote.SharedInboxURI != "" {
+			inboxURI = remote.SharedInboxURI
+		}
+		if !seen[inboxURI] {
+			seen[inboxURI] = true
+			inboxes = append(inboxes, inboxURI)
+		}
+	}
+	return inboxes, nil
 }
 
 func (m *MockDatabase) DeleteFollowsByRemoteAccountId(remoteAccountId uuid.UUID) error {
@@ -359,30 +473,30 @@ func (m *MockDatabase) UpdateActivity(activity *domain.Activity) error {
 	return nil
 }
 
-func (m *MockDatabase) ReadActivityByURI(uri string) (error, *domain.Activity) {
+func (m *MockDatabase) ReadActivityByURI(uri string) (*domain.Activity, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 	if m.ForceError != nil {
-		return m.ForceError, nil
+		return nil, m.ForceError
 	}
 	activity, ok := m.ActivitiesByURI[uri]
 	if !ok {
 		return nil, nil
 	}
-	return nil, activity
+	return activity, nil
 }
 
-func (m *MockDatabase) ReadActivityByObjectURI(objectURI string) (error, *domain.Activity) {
+func (m *MockDatabase) ReadActivityByObjectURI(objectURI string) (*domain.Activity, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 	if m.ForceError != nil {
-		return m.ForceError, nil
+		return nil, m.ForceError
 	}
 	activity, ok := m.ActivitiesByObj[objectURI]
 	if !ok {
 		return nil, nil
 	}
-	return nil, activity
+	return activity, nil
 }
 
 func (m *MockDatabase) DeleteActivity(id uuid.UUID) error {
@@ -407,32 +521,126 @@ func (m *MockDatabase) EnqueueDelivery(item *domain.DeliveryQueueItem) error {
 	if m.ForceError != nil {
 		return m.ForceError
 	}
+	if m.EnqueueError != nil {
+		return m.EnqueueError
+	}
+	m.EnqueueCalls++
+	m.deliverySeq++
+	item.Seq = m.deliverySeq
 	m.DeliveryQueue[item.Id] = item
 	return nil
 }
 
-func (m *MockDatabase) ReadPendingDeliveries(limit int) (error, *[]domain.DeliveryQueueItem) {
+func (m *MockDatabase) EnqueueDeliveryBatch(items []*domain.DeliveryQueueItem) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ForceError != nil {
+		return m.ForceError
+	}
+	if m.EnqueueError != nil {
+		return m.EnqueueError
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	m.EnqueueCalls++
+	for _, item := range items {
+		m.deliverySeq++
+		item.Seq = m.deliverySeq
+		m.DeliveryQueue[item.Id] = item
+	}
+	return nil
+}
+
+func (m *MockDatabase) ReadPendingDeliveries(limit int) (*[]domain.DeliveryQueueItem, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 	if m.ForceError != nil {
-		return m.ForceError, nil
+		return nil, m.ForceError
 	}
 	var items []domain.DeliveryQueueItem
 	now := time.Now()
-	count := 0
 	for _, item := range m.DeliveryQueue {
-		if item.NextRetryAt.Before(now) || item.NextRetryAt.Equal(now) {
+		if item.DeadLettered {
+			continue
+		}
+		if (item.NextRetryAt.Before(now) || item.NextRetryAt.Equal(now)) && !m.hasEarlierDelivery(item) {
 			items = append(items, *item)
-			count++
-			if count >= limit {
-				break
-			}
 		}
 	}
-	return nil, &items
+	// Oldest first, like the database
+	sort.Slice(items, func(i, j int) bool {
+		if !items[i].CreatedAt.Equal(items[j].CreatedAt) {
+			return items[i].CreatedAt.Before(items[j].CreatedAt)
+		}
+		return items[i].Seq < items[j].Seq
+	})
+	if len(items) > limit {
+		items = items[:limit]
+	}
+	return &items, nil
 }
 
-func (m *MockDatabase) UpdateDeliveryAttempt(id uuid.UUID, attempts int, nextRetry time.Time) error {
+func (m *MockDatabase) EnqueueInboxActivity(item *domain.InboxQueueItem) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ForceError != nil {
+		return m.ForceError
+	}
+	m.InboxQueue = append(m.InboxQueue, item)
+	return nil
+}
+
+func (m *MockDatabase) ReadPendingInboxActivities(limit int) (*[]domain.InboxQueueItem, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.ForceError != nil {
+		return nil, m.ForceError
+	}
+	var items []domain.InboxQueueItem
+	now := time.Now()
+	for _, item := range m.InboxQueue {
+		if !item.NextAttemptAt.After(now) && len(items) < limit {
+			items = append(items, *item)
+		}
+	}
+	return &items, nil
+}
+
+func (m *MockDatabase) UpdateInboxActivityAttempt(id uuid.UUID, attempts int, nextAttempt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, item := range m.InboxQueue {
+		if item.Id == id {
+			item.Attempts = attempts
+			item.NextAttemptAt = nextAttempt
+		}
+	}
+	return nil
+}
+
+func (m *MockDatabase) DeleteInboxActivity(id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.InboxQueue = slices.DeleteFunc(m.InboxQueue, func(item *domain.InboxQueueItem) bool { return item.Id == id })
+	return nil
+}
+
+// hasEarlierDelivery reports whether a delivery about the same object to the same inbox was
+// queued before item, which then has to wait like in the database
+func (m *MockDatabase) hasEarlierDelivery(item *domain.DeliveryQueueItem) bool {
+	if item.ObjectURI == "" {
+		return false
+	}
+	for _, other := range m.DeliveryQueue {
+		if other.ObjectURI == item.ObjectURI && other.InboxURI == item.InboxURI && other.Seq < item.Seq && !other.DeadLettered {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *MockDatabase) UpdateDeliveryAttempt(id uuid.UUID, attempts int, nextRetry time.Time, lastStatus int) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	if m.ForceError != nil {
@@ -441,6 +649,21 @@ func (m *MockDatabase) UpdateDeliveryAttempt(id uuid.UUID, attempts int, nextRet
 	if item, ok := m.DeliveryQueue[id]; ok {
 		item.Attempts = attempts
 		item.NextRetryAt = nextRetry
+		item.LastStatus = lastStatus
+	}
+	return nil
+}
+
+func (m *MockDatabase) DeadLetterDelivery(id uuid.UUID, attempts int, lastStatus int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ForceError != nil {
+		return m.ForceError
+	}
+	if item, ok := m.DeliveryQueue[id]; ok {
+		item.Attempts = attempts
+		item.LastStatus = lastStatus
+		item.DeadLettered = true
 	}
 	return nil
 }
@@ -457,17 +680,27 @@ func (m *MockDatabase) DeleteDelivery(id uuid.UUID) error {
 
 // Note operations
 
-func (m *MockDatabase) ReadNoteByURI(objectURI string) (error, *domain.Note) {
+func (m *MockDatabase) ReadNoteByURI(objectURI string) (*domain.Note, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 	if m.ForceError != nil {
-		return m.ForceError, nil
+		return nil, m.ForceError
 	}
 	note, ok := m.NotesByURI[objectURI]
 	if !ok {
-		return sql.ErrNoRows, nil
+		return nil, sql.ErrNoRows
+	}
+	return note, nil
+}
+
+func (m *MockDatabase) UpdateNoteObjectJSON(noteId uuid.UUID, objectJSON string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ForceError != nil {
+		return m.ForceError
 	}
-	return nil, note
+	m.NoteObjects[noteId] = objectJSON
+	return nil
 }
 
 // Mention operations
@@ -478,7 +711,29 @@ func (m *MockDatabase) CreateNoteMention(mention *domain.NoteMention) error {
 	if m.ForceError != nil {
 		return m.ForceError
 	}
-	// Just store it - we don't need to track mentions in tests unless specifically needed
+	m.Mentions = append(m.Mentions, mention)
+	return nil
+}
+
+// UpdateActivityQuote records the quoted post of an activity
+func (m *MockDatabase) UpdateActivityQuote(activityId uuid.UUID, quoteURI, quoteAuthor, quoteContent string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ForceError != nil {
+		return m.ForceError
+	}
+	m.ActivityQuotes[activityId] = []string{quoteURI, quoteAuthor, quoteContent}
+	return nil
+}
+
+// LinkActivityHashtags records the hashtags linked to an activity
+func (m *MockDatabase) LinkActivityHashtags(activityId uuid.UUID, names []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ForceError != nil {
+		return m.ForceError
+	}
+	m.ActivityHashtags[activityId] = append(m.ActivityHashtags[activityId], names...)
 	return nil
 }
 
@@ -529,18 +784,18 @@ func (m *MockDatabase) HasLike(accountId, noteId uuid.UUID) (bool, error) {
 	return false, nil
 }
 
-func (m *MockDatabase) ReadLikeByAccountAndNote(accountId, noteId uuid.UUID) (error, *domain.Like) {
+func (m *MockDatabase) ReadLikeByAccountAndNote(accountId, noteId uuid.UUID) (*domain.Like, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 	if m.ForceError != nil {
-		return m.ForceError, nil
+		return nil, m.ForceError
 	}
 	for _, like := range m.Likes {
 		if like.AccountId == accountId && like.NoteId == noteId {
-			return nil, like
+			return like, nil
 		}
 	}
-	return sql.ErrNoRows, nil
+	return nil, sql.ErrNoRows
 }
 
 func (m *MockDatabase) DeleteLikeByAccountAndNote(accountId, noteId uuid.UUID) error {
@@ -664,6 +919,47 @@ func (m *MockDatabase) DecrementBoostCountByNoteId(noteId uuid.UUID) error {
 	return nil
 }
 
+// Reaction operations
+
+func (m *MockDatabase) CreateReaction(reaction *domain.Reaction) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ForceError != nil {
+		return m.ForceError
+	}
+	for _, r := range m.Reactions {
+		if r.ObjectURI == reaction.ObjectURI && r.Emoji == reaction.Emoji && r.ActorURI == reaction.ActorURI {
+			return nil
+		}
+	}
+	m.Reactions = append(m.Reactions, reaction)
+	return nil
+}
+
+func (m *MockDatabase) DeleteReaction(actorURI, objectURI, emoji string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ForceError != nil {
+		return m.ForceError
+	}
+	m.Reactions = slices.DeleteFunc(m.Reactions, func(r *domain.Reaction) bool {
+		return r.ActorURI == actorURI && r.ObjectURI == objectURI && r.Emoji == emoji
+	})
+	return nil
+}
+
+func (m *MockDatabase) DeleteReactionByURI(actorURI, uri string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ForceError != nil {
+		return m.ForceError
+	}
+	m.Reactions = slices.DeleteFunc(m.Reactions, func(r *domain.Reaction) bool {
+		return r.ActorURI == actorURI && r.URI == uri
+	})
+	return nil
+}
+
 // Relay operations
 
 func (m *MockDatabase) CreateRelay(relay *domain.Relay) error {
@@ -677,11 +973,11 @@ func (m *MockDatabase) CreateRelay(relay *domain.Relay) error {
 	return nil
 }
 
-func (m *MockDatabase) ReadActiveRelays() (error, *[]domain.Relay) {
+func (m *MockDatabase) ReadActiveRelays() (*[]domain.Relay, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	if m.ForceError != nil {
-		return m.ForceError, nil
+		return nil, m.ForceError
 	}
 	relays := make([]domain.Relay, 0)
 	for _, r := range m.Relays {
@@ -689,14 +985,14 @@ func (m *MockDatabase) ReadActiveRelays() (error, *[]domain.Relay) {
 			relays = append(relays, *r)
 		}
 	}
-	return nil, &relays
+	return &relays, nil
 }
 
-func (m *MockDatabase) ReadActiveUnpausedRelays() (error, *[]domain.Relay) {
+func (m *MockDatabase) ReadActiveUnpausedRelays() (*[]domain.Relay, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	if m.ForceError != nil {
-		return m.ForceError, nil
+		return nil, m.ForceError
 	}
 	relays := make([]domain.Relay, 0)
 	for _, r := range m.Relays {
@@ -704,19 +1000,62 @@ func (m *MockDatabase) ReadActiveUnpausedRelays() (error, *[]domain.Relay) {
 			relays = append(relays, *r)
 		}
 	}
-	return nil, &relays
+	return &relays, nil
 }
 
-func (m *MockDatabase) ReadRelayByActorURI(actorURI string) (error, *domain.Relay) {
+func (m *MockDatabase) ReadRelayByActorURI(actorURI string) (*domain.Relay, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	if m.ForceError != nil {
-		return m.ForceError, nil
+		return nil, m.ForceError
 	}
 	if relay, ok := m.RelaysByURI[actorURI]; ok {
-		return nil, relay
+		return relay, nil
 	}
-	return sql.ErrNoRows, nil
+	return nil, sql.ErrNoRows
+}
+
+func (m *MockDatabase) ReadRelayByFollowURI(followURI string) (*domain.Relay, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ForceError != nil {
+		return nil, m.ForceError
+	}
+	for _, relay := range m.Relays {
+		if relay.FollowURI == followURI {
+			return relay, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (m *MockDatabase) ReadPendingRelays() (*[]domain.Relay, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ForceError != nil {
+		return nil, m.ForceError
+	}
+	relays := make([]domain.Relay, 0)
+	for _, r := range m.Relays {
+		if r.Status == "pending" {
+			relays = append(relays, *r)
+		}
+	}
+	return &relays, nil
+}
+
+func (m *MockDatabase) UpdateRelayFollowAttempt(id uuid.UUID, followURI string, attempts int, sentAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ForceError != nil {
+		return m.ForceError
+	}
+	if relay, ok := m.Relays[id]; ok {
+		relay.FollowURI = followURI
+		relay.FollowAttempts = attempts
+		relay.LastFollowAt = sentAt
+	}
+	return nil
 }
 
 func (m *MockDatabase) UpdateRelayStatus(id uuid.UUID, status string, acceptedAt *time.Time) error {
@@ -725,6 +1064,38 @@ func (m *MockDatabase) UpdateRelayStatus(id uuid.UUID, status string, acceptedAt
 	if m.ForceError != nil {
 		return m.ForceError
 	}
+	if relay, ok := m.Relays[id]; ok {
+		relay.Status = status
+		if acceptedAt != nil {
+			relay.AcceptedAt = acceptedAt
+		}
+	}
+	return nil
+}
+
+func (m *MockDatabase) UpdateRelayScheduledPause(id uuid.UUID, paused bool, state string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ForceError != nil {
+		return m.ForceError
+	}
+	if relay, ok := m.Relays[id]; ok {
+		relay.Paused = paused
+		relay.PauseScheduleState = state
+	}
+	return nil
+}
+
+func (m *MockDatabase) RecordRelayActivity(id uuid.UUID, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ForceError != nil {
+		return m.ForceError
+	}
+	if relay, ok := m.Relays[id]; ok {
+		relay.LastActivityAt = &at
+		relay.ActivityCount++
+	}
 	return nil
 }
 
@@ -744,9 +1115,87 @@ func (m *MockDatabase) CreateNotification(notification *domain.Notification) err
 	if m.ForceError != nil {
 		return m.ForceError
 	}
-	// No-op for now, can be extended if needed for testing
+	m.Notifications = append(m.Notifications, notification)
 	return nil
 }
 
+// Transactions
+
+// WithTx simulates a transaction: fn runs against the mock itself, and the stored data is
+// restored to its state before the call if fn returns an error. Call tracking is kept.
+func (m *MockDatabase) WithTx(fn func(tx Tx) error) error {
+	m.mu.Lock()
+	if m.ForceError != nil {
+		m.mu.Unlock()
+		return m.ForceError
+	}
+	restore := m.snapshot()
+	m.OpenTxs++
+	m.mu.Unlock()
+
+	err := fn(m)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.OpenTxs--
+	if err != nil {
+		restore()
+		m.RolledBackTxs++
+		return err
+	}
+	return nil
+}
+
+// snapshot records the stored data and returns a func that puts it back.
+// Records are restored in place, so all index maps keep sharing them.
+func (m *MockDatabase) snapshot() func() {
+	var restores []func()
+	restores = append(restores,
+		snapshotMap(&m.Accounts), snapshotMap(&m.AccountsByUser),
+		snapshotMap(&m.RemoteAccounts), snapshotMap(&m.RemoteByURI), snapshotMap(&m.RemoteByActor),
+		snapshotMap(&m.Follows), snapshotMap(&m.FollowsByURI),
+		snapshotMap(&m.Activities), snapshotMap(&m.ActivitiesByObj), snapshotMap(&m.ActivitiesByURI),
+		snapshotMap(&m.DeliveryQueue), snapshotSlice(&m.InboxQueue),
+		snapshotMap(&m.Notes), snapshotMap(&m.NotesByURI), snapshotValues(&m.NoteObjects),
+		snapshotMap(&m.Likes), snapshotMap(&m.LikesByURI),
+		snapshotMap(&m.Boosts),
+		snapshotMap(&m.Relays), snapshotMap(&m.RelaysByURI),
+		snapshotValues(&m.ActivityHashtags), snapshotValues(&m.ActivityQuotes),
+		snapshotSlice(&m.Mentions), snapshotSlice(&m.Notifications), snapshotSlice(&m.Reactions),
+	)
+	return func() {
+		for _, restore := range restores {
+			restore()
+		}
+	}
+}
+
+func snapshotMap[K comparable, V any](m *map[K]*V) func() {
+	saved := make(map[K]*V, len(*m))
+	records := make(map[*V]V, len(*m))
+	for k, v := range *m {
+		saved[k] = v
+		records[v] = *v
+	}
+	return func() {
+		*m = saved
+		for p, v := range records {
+			*p = v
+		}
+	}
+}
+
+func snapshotValues[K comparable, V any](m *map[K]V) func() {
+	saved := make(map[K]V, len(*m))
+	for k, v := range *m {
+		saved[k] = v
+	}
+	return func() { *m = saved }
+}
+
+func snapshotSlice[T any](s *[]T) func() {
+	saved := append([]T(nil), *s...)
+	return func() { *s = saved }
+}
+
 // Ensure MockDatabase implements Database interface
 var _ Database = (*MockDatabase)(nil)
@@ -1,13 +1,9 @@
 package activitypub
 
 import (
-	"bytes"
-	"crypto/sha256"
 	"database/sql"
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
 	"strings"
@@ -33,33 +29,17 @@ func SendActivityWithDeps(activity any, inboxURI string, localAccount *domain.Ac
 		return fmt.Errorf("failed to marshal activity: %w", err)
 	}
 
-	// Calculate digest for HTTP signature
-	hash := sha256.Sum256(activityJSON)
-	digest := "SHA-256=" + base64.StdEncoding.EncodeToString(hash[:])
-
-	// Create HTTP request
-	req, err := http.NewRequest("POST", inboxURI, bytes.NewReader(activityJSON))
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/activity+json")
-	req.Header.Set("Accept", "application/activity+json")
-	req.Header.Set("User-Agent", "stegodon/1.0 ActivityPub")
-	req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
-	req.Header.Set("Host", req.URL.Host)
-	req.Header.Set("Digest", digest)
-
 	// Parse private key for signing
 	privateKey, err := ParsePrivateKey(localAccount.WebPrivateKey)
 	if err != nil {
 		return fmt.Errorf("failed to parse private key: %w", err)
 	}
 
-	// Sign request
+	// Create the signed request
 	keyID := fmt.Sprintf("https://%s/users/%s#main-key", conf.Conf.SslDomain, localAccount.Username)
-	if err := SignRequest(req, privateKey, keyID); err != nil {
-		return fmt.Errorf("failed to sign request: %w", err)
+	req, err := NewSignedActivityRequest(inboxURI, activityJSON, privateKey, keyID)
+	if err != nil {
+		return err
 	}
 
 	// Send request
@@ -86,10 +66,26 @@ func SendAccept(localAccount *domain.Account, remoteActor *domain.RemoteAccount,
 // SendAcceptWithDeps sends an Accept activity in response to a Follow.
 // This version accepts dependencies for testing.
 func SendAcceptWithDeps(localAccount *domain.Account, remoteActor *domain.RemoteAccount, followID string, conf *util.AppConfig, client HTTPClient) error {
+	return SendActivityWithDeps(acceptActivity(localAccount, remoteActor, followID, conf), remoteActor.InboxURI, localAccount, conf, client)
+}
+
+// QueueAccept queues an Accept activity in response to a Follow in the delivery queue,
+// so it can be part of the transaction that stores the follow
+func QueueAccept(localAccount *domain.Account, remoteActor *domain.RemoteAccount, followID string, conf *util.AppConfig, database Database) error {
+	activityJSON, err := json.Marshal(acceptActivity(localAccount, remoteActor, followID, conf))
+	if err != nil {
+		return fmt.Errorf("failed to marshal Accept: %w", err)
+	}
+	_, err = enqueueDeliveries(map[string]bool{remoteActor.InboxURI: true}, string(activityJSON), database)
+	return err
+}
+
+// acceptActivity builds the Accept of a remote actor's Follow
+func acceptActivity(localAccount *domain.Account, remoteActor *domain.RemoteAccount, followID string, conf *util.AppConfig) map[string]any {
 	acceptID := fmt.Sprintf("https://%s/activities/%s", conf.Conf.SslDomain, uuid.New().String())
 	actorURI := fmt.Sprintf("https://%s/users/%s", conf.Conf.SslDomain, localAccount.Username)
 
-	accept := map[string]any{
+	return map[string]any{
 		"@context": "https://www.w3.org/ns/activitystreams",
 		"id":       acceptID,
 		"type":     "Accept",
@@ -101,8 +97,120 @@ func SendAcceptWithDeps(localAccount *domain.Account, remoteActor *domain.Remote
 			"object": actorURI,
 		},
 	}
+}
+
+// SendReject sends a Reject activity in response to a Follow.
+// This is the production wrapper that uses the default HTTP client.
+func SendReject(localAccount *domain.Account, remoteActor *domain.RemoteAccount, followID string, conf *util.AppConfig) error {
+	return SendRejectWithDeps(localAccount, remoteActor, followID, conf, defaultHTTPClient)
+}
+
+// SendRejectWithDeps sends a Reject activity in response to a Follow.
+// This version accepts dependencies for testing.
+func SendRejectWithDeps(localAccount *domain.Account, remoteActor *domain.RemoteAccount, followID string, conf *util.AppConfig, client HTTPClient) error {
+	rejectID := fmt.Sprintf("https://%s/activities/%s", conf.Conf.SslDomain, uuid.New().String())
+	actorURI := fmt.Sprintf("https://%s/users/%s", conf.Conf.SslDomain, localAccount.Username)
+
+	reject := map[string]any{
+		"@context": "https://www.w3.org/ns/activitystreams",
+		"id":       rejectID,
+		"type":     "Reject",
+		"actor":    actorURI,
+		"object": map[string]any{
+			"id":     followID,
+			"type":   "Follow",
+			"actor":  remoteActor.ActorURI,
+			"object": actorURI,
+		},
+	}
 
-	return SendActivityWithDeps(accept, remoteActor.InboxURI, localAccount, conf, client)
+	return SendActivityWithDeps(reject, remoteActor.InboxURI, localAccount, conf, client)
+}
+
+// AcceptPendingFollow approves a follow request of an account that manually approves followers.
+// This is the production wrapper that uses the default dependencies.
+func AcceptPendingFollow(localAccount *domain.Account, followURI string, conf *util.AppConfig) error {
+	return AcceptPendingFollowWithDeps(localAccount, followURI, conf, NewDBWrapper(), defaultHTTPClient)
+}
+
+// AcceptPendingFollowWithDeps marks a pending follow of localAccount as accepted and sends
+// the Accept to the follower. This version accepts dependencies for testing.
+func AcceptPendingFollowWithDeps(localAccount *domain.Account, followURI string, conf *util.AppConfig, database Database, client HTTPClient) error {
+	follow, follower, err := readPendingFollow(localAccount, followURI, database)
+	if err != nil {
+		return err
+	}
+
+	if err := database.AcceptFollowByURI(follow.URI); err != nil {
+		return fmt.Errorf("failed to accept follow: %w", err)
+	}
+
+	notification := &domain.Notification{
+		Id:               uuid.New(),
+		AccountId:        localAccount.Id,
+		NotificationType: domain.NotificationFollow,
+		ActorId:          follower.Id,
+		ActorUsername:    follower.Username,
+		ActorDomain:      follower.Domain,
+		Read:             false,
+		CreatedAt:        time.Now(),
+	}
+	if err := database.CreateNotification(notification); err != nil {
+		log.Printf("Outbox: Failed to create follow notification: %v", err)
+	}
+
+	if err := SendAcceptWithDeps(localAccount, follower, follow.URI, conf, client); err != nil {
+		return fmt.Errorf("failed to send Accept: %w", err)
+	}
+
+	log.Printf("Outbox: Approved follow request from %s@%s", follower.Username, follower.Domain)
+	return nil
+}
+
+// RejectPendingFollow declines a follow request of an account that manually approves followers.
+// This is the production wrapper that uses the default dependencies.
+func RejectPendingFollow(localAccount *domain.Account, followURI string, conf *util.AppConfig) error {
+	return RejectPendingFollowWithDeps(localAccount, followURI, conf, NewDBWrapper(), defaultHTTPClient)
+}
+
+// RejectPendingFollowWithDeps deletes a pending follow of localAccount and sends a Reject to
+// the follower. This version accepts dependencies for testing.
+func RejectPendingFollowWithDeps(localAccount *domain.Account, followURI string, conf *util.AppConfig, database Database, client HTTPClient) error {
+	follow, follower, err := readPendingFollow(localAccount, followURI, database)
+	if err != nil {
+		return err
+	}
+
+	if err := database.DeleteFollowByURI(follow.URI); err != nil {
+		return fmt.Errorf("failed to delete follow: %w", err)
+	}
+
+	if err := SendRejectWithDeps(localAccount, follower, follow.URI, conf, client); err != nil {
+		return fmt.Errorf("failed to send Reject: %w", err)
+	}
+
+	log.Printf("Outbox: Rejected follow request from %s@%s", follower.Username, follower.Domain)
+	return nil
+}
+
+// readPendingFollow returns a not yet accepted follow of localAccount and the remote follower
+func readPendingFollow(localAccount *domain.Account, followURI string, database Database) (*domain.Follow, *domain.RemoteAccount, error) {
+	follow, err := database.ReadFollowByURI(followURI)
+	if err != nil || follow == nil {
+		return nil, nil, fmt.Errorf("follow request not found: %s", followURI)
+	}
+	if follow.TargetAccountId != localAccount.Id {
+		return nil, nil, fmt.Errorf("follow request %s is not addressed to %s", followURI, localAccount.Username)
+	}
+	if follow.Accepted {
+		return nil, nil, fmt.Errorf("follow request %s was already accepted", followURI)
+	}
+
+	follower, err := database.ReadRemoteAccountById(follow.AccountId)
+	if err != nil || follower == nil {
+		return nil, nil, fmt.Errorf("follower of %s not found", followURI)
+	}
+	return follow, follower, nil
 }
 
 // SendCreate sends a Create activity for a new note.
@@ -114,16 +222,17 @@ func SendCreate(note *domain.Note, localAccount *domain.Account, conf *util.AppC
 // SendCreateWithDeps sends a Create activity for a new note.
 // This version accepts dependencies for testing.
 func SendCreateWithDeps(note *domain.Note, localAccount *domain.Account, conf *util.AppConfig, database Database) error {
+	// Local-only notes never leave this instance
+	if note.IsLocalOnly() {
+		log.Printf("Outbox: Note %s is local-only, not sending Create", note.Id)
+		return nil
+	}
+
 	actorURI := fmt.Sprintf("https://%s/users/%s", conf.Conf.SslDomain, localAccount.Username)
-	noteURI := fmt.Sprintf("https://%s/notes/%s", conf.Conf.SslDomain, note.Id.String())
+	noteURI := util.BuildNoteObjectURI(conf, note.Id)
 	createID := fmt.Sprintf("https://%s/activities/%s", conf.Conf.SslDomain, uuid.New().String())
 	baseURL := fmt.Sprintf("https://%s", conf.Conf.SslDomain)
 
-	// Convert Markdown links to HTML for ActivityPub content
-	contentHTML := util.MarkdownLinksToHTML(note.Message)
-	// Convert hashtags to ActivityPub-compliant HTML links
-	contentHTML = util.HashtagsToActivityPubHTML(contentHTML, baseURL)
-
 	// Build cc list - start with followers
 	ccList := []string{
 		fmt.Sprintf("https://%s/users/%s/followers", conf.Conf.SslDomain, localAccount.Username),
@@ -144,7 +253,6 @@ func SendCreateWithDeps(note *domain.Note, localAccount *domain.Account, conf *u
 		"id":           noteURI,
 		"type":         "Note",
 		"attributedTo": actorURI,
-		"content":      contentHTML,
 		"mediaType":    "text/html",
 		"published":    note.CreatedAt.Format(time.RFC3339),
 		"url":          fmt.Sprintf("https://%s/u/%s/%s", conf.Conf.SslDomain, localAccount.Username, note.Id.String()),
@@ -176,6 +284,7 @@ func SendCreateWithDeps(note *domain.Note, localAccount *domain.Account, conf *u
 	mentions := util.ParseMentions(note.Message)
 	mentionURIs := make(map[string]string)
 	mentionedActors := make([]string, 0)
+	mentionedInboxes := make([]string, 0)
 
 	for _, mention := range mentions {
 		// Skip local mentions (same domain) - they don't need federation
@@ -183,16 +292,21 @@ func SendCreateWithDeps(note *domain.Note, localAccount *domain.Account, conf *u
 			continue
 		}
 
-		// Resolve via WebFinger
-		actorURI, err := resolveMentionURI(mention.Username, mention.Domain)
+		mentionKey := fmt.Sprintf("@%s@%s", mention.Username, mention.Domain)
+		if _, seen := mentionURIs[mentionKey]; seen {
+			continue
+		}
+
+		// Resolve via WebFinger; unresolvable mentions stay plain text
+		actorURI, inboxURI, err := ResolveMentionWithDeps(mentionKey, defaultHTTPClient, database)
 		if err != nil {
-			log.Printf("Outbox: Failed to resolve mention @%s@%s: %v", mention.Username, mention.Domain, err)
+			log.Printf("Outbox: Failed to resolve mention %s, leaving as plain text: %v", mentionKey, err)
 			continue
 		}
 
-		mentionKey := fmt.Sprintf("@%s@%s", mention.Username, mention.Domain)
 		mentionURIs[mentionKey] = actorURI
 		mentionedActors = append(mentionedActors, actorURI)
+		mentionedInboxes = append(mentionedInboxes, inboxURI)
 
 		tags = append(tags, map[string]any{
 			"type": "Mention",
@@ -212,10 +326,11 @@ func SendCreateWithDeps(note *domain.Note, localAccount *domain.Account, conf *u
 	// Update noteObj cc with the expanded list
 	noteObj["cc"] = ccList
 
-	// Convert mentions to ActivityPub HTML (after we have resolved URIs)
-	if len(mentionURIs) > 0 {
-		contentHTML = util.MentionsToActivityPubHTML(contentHTML, mentionURIs)
-		noteObj["content"] = contentHTML
+	// Render the HTML content once mention URIs are resolved
+	contentHTML := util.MessageToActivityPubHTML(note.Message, baseURL, mentionURIs)
+	noteObj["content"] = contentHTML
+	if note.Language != "" {
+		noteObj["contentMap"] = map[string]string{note.Language: contentHTML}
 	}
 
 	// Build context - include Hashtag definition if we have hashtags
@@ -244,34 +359,28 @@ func SendCreateWithDeps(note *domain.Note, localAccount *domain.Account, conf *u
 		"object": noteObj,
 	}
 
+	// Keep a copy so the activity id can be dereferenced by remote servers
+	storeLocalActivity(create, noteURI, database)
+	storeNoteObject(note.Id, noteObj, context, database)
+
 	// Collect inboxes to deliver to (followers + parent author for replies)
 	inboxes := make(map[string]bool) // Use map to dedupe
 
-	// Get all followers
-	err, followers := database.ReadFollowersByAccountId(localAccount.Id)
+	// Get all follower inboxes (shared inboxes deduplicated per server)
+	followerInboxes, err := database.ReadFollowerInboxURIs(localAccount.Id)
 	if err != nil {
 		log.Printf("Outbox: Failed to get followers: %v", err)
-	} else if followers != nil {
-		for _, follower := range *followers {
-			// Skip local followers - they don't need federation delivery
-			if follower.IsLocal {
-				continue
-			}
-			err, remoteActor := database.ReadRemoteAccountById(follower.AccountId)
-			if err != nil {
-				log.Printf("Outbox: Failed to get remote actor %s: %v", follower.AccountId, err)
-				continue
-			}
-			inboxes[remoteActor.InboxURI] = true
-		}
+	}
+	for _, inboxURI := range followerInboxes {
+		inboxes[inboxURI] = true
 	}
 
 	// If this is a reply, also deliver to the parent author's inbox
 	if parentAuthorURI != "" && parentAuthorURI != actorURI {
 		// First try as remote account
-		err, parentAccount := database.ReadRemoteAccountByActorURI(parentAuthorURI)
+		parentAccount, err := database.ReadRemoteAccountByActorURI(parentAuthorURI)
 		if err == nil && parentAccount != nil {
-			inboxes[parentAccount.InboxURI] = true
+			inboxes[deliveryInbox(parentAccount)] = true
 			log.Printf("Outbox: Will also deliver reply to remote parent author %s@%s", parentAccount.Username, parentAccount.Domain)
 		} else {
 			// Try as local account - extract username from URI like https://domain/users/username
@@ -280,7 +389,7 @@ func SendCreateWithDeps(note *domain.Note, localAccount *domain.Account, conf *u
 				if len(parts) == 2 {
 					parentUsername := parts[1]
 					// Verify this local user exists
-					err, localParent := database.ReadAccByUsername(parentUsername)
+					localParent, err := database.ReadAccByUsername(parentUsername)
 					if err == nil && localParent != nil {
 						// Construct local inbox URI
 						localInboxURI := fmt.Sprintf("https://%s/users/%s/inbox", conf.Conf.SslDomain, parentUsername)
@@ -292,27 +401,14 @@ func SendCreateWithDeps(note *domain.Note, localAccount *domain.Account, conf *u
 		}
 	}
 
-	// Also deliver to mentioned actors' inboxes
-	for _, mentionActorURI := range mentionedActors {
-		// Look up the remote account to get their inbox
-		err, mentionedAccount := database.ReadRemoteAccountByActorURI(mentionActorURI)
-		if err == nil && mentionedAccount != nil {
-			inboxes[mentionedAccount.InboxURI] = true
-			log.Printf("Outbox: Will also deliver to mentioned user %s@%s", mentionedAccount.Username, mentionedAccount.Domain)
-		} else {
-			// Fetch the actor if not cached
-			mentionedAccount, err = FetchRemoteActorWithDeps(mentionActorURI, defaultHTTPClient, database)
-			if err == nil && mentionedAccount != nil {
-				inboxes[mentionedAccount.InboxURI] = true
-				log.Printf("Outbox: Will also deliver to mentioned user %s@%s (fetched)", mentionedAccount.Username, mentionedAccount.Domain)
-			} else {
-				log.Printf("Outbox: Could not resolve inbox for mentioned actor %s: %v", mentionActorURI, err)
-			}
-		}
+	// Also deliver to mentioned actors' inboxes (resolved together with the mention)
+	for i, mentionInboxURI := range mentionedInboxes {
+		inboxes[mentionInboxURI] = true
+		log.Printf("Outbox: Will also deliver to mentioned actor %s", mentionedActors[i])
 	}
 
 	// Get active relays and add their inboxes
-	err, relays := database.ReadActiveRelays()
+	relays, err := database.ReadActiveRelays()
 	if err == nil && relays != nil {
 		for _, relay := range *relays {
 			inboxes[relay.InboxURI] = true
@@ -325,20 +421,9 @@ func SendCreateWithDeps(note *domain.Note, localAccount *domain.Account, conf *u
 		return nil
 	}
 
-	// Queue delivery to each unique inbox
-	for inboxURI := range inboxes {
-		queueItem := &domain.DeliveryQueueItem{
-			Id:           uuid.New(),
-			InboxURI:     inboxURI,
-			ActivityJSON: mustMarshal(create),
-			Attempts:     0,
-			NextRetryAt:  time.Now(),
-			CreatedAt:    time.Now(),
-		}
-
-		if err := database.EnqueueDelivery(queueItem); err != nil {
-			log.Printf("Outbox: Failed to queue delivery to %s: %v", inboxURI, err)
-		}
+	// Queue delivery to every unique inbox in one transaction
+	if _, err := enqueueDeliveries(inboxes, mustMarshal(create), database); err != nil {
+		log.Printf("Outbox: Failed to queue deliveries: %v", err)
 	}
 
 	log.Printf("Outbox: Queued Create activity for note %s to %d inboxes", note.Id, len(inboxes))
@@ -354,8 +439,14 @@ func SendUpdate(note *domain.Note, localAccount *domain.Account, conf *util.AppC
 // SendUpdateWithDeps sends an Update activity to all followers when a note is edited.
 // This version accepts dependencies for testing.
 func SendUpdateWithDeps(note *domain.Note, localAccount *domain.Account, conf *util.AppConfig, database Database) error {
+	// Local-only notes never leave this instance
+	if note.IsLocalOnly() {
+		log.Printf("Outbox: Note %s is local-only, not sending Update", note.Id)
+		return nil
+	}
+
 	actorURI := fmt.Sprintf("https://%s/users/%s", conf.Conf.SslDomain, localAccount.Username)
-	noteURI := fmt.Sprintf("https://%s/notes/%s", conf.Conf.SslDomain, note.Id.String())
+	noteURI := util.BuildNoteObjectURI(conf, note.Id)
 	updateID := fmt.Sprintf("https://%s/activities/%s", conf.Conf.SslDomain, uuid.New().String())
 	baseURL := fmt.Sprintf("https://%s", conf.Conf.SslDomain)
 
@@ -365,11 +456,6 @@ func SendUpdateWithDeps(note *domain.Note, localAccount *domain.Account, conf *u
 		updatedTime = *note.EditedAt
 	}
 
-	// Convert Markdown links to HTML for ActivityPub content
-	contentHTML := util.MarkdownLinksToHTML(note.Message)
-	// Convert hashtags to ActivityPub-compliant HTML links
-	contentHTML = util.HashtagsToActivityPubHTML(contentHTML, baseURL)
-
 	// Build cc list - start with followers
 	ccList := []string{
 		fmt.Sprintf("https://%s/users/%s/followers", conf.Conf.SslDomain, localAccount.Username),
@@ -389,7 +475,6 @@ func SendUpdateWithDeps(note *domain.Note, localAccount *domain.Account, conf *u
 		"id":           noteURI,
 		"type":         "Note",
 		"attributedTo": actorURI,
-		"content":      contentHTML,
 		"mediaType":    "text/html",
 		"published":    note.CreatedAt.Format(time.RFC3339),
 		"updated":      updatedTime.Format(time.RFC3339),
@@ -421,6 +506,7 @@ func SendUpdateWithDeps(note *domain.Note, localAccount *domain.Account, conf *u
 	mentions := util.ParseMentions(note.Message)
 	mentionURIs := make(map[string]string)
 	mentionedActors := make([]string, 0)
+	mentionedInboxes := make([]string, 0)
 
 	for _, mention := range mentions {
 		// Skip local mentions (same domain) - they don't need federation
@@ -428,16 +514,21 @@ func SendUpdateWithDeps(note *domain.Note, localAccount *domain.Account, conf *u
 			continue
 		}
 
-		// Resolve via WebFinger
-		actorURI, err := resolveMentionURI(mention.Username, mention.Domain)
+		mentionKey := fmt.Sprintf("@%s@%s", mention.Username, mention.Domain)
+		if _, seen := mentionURIs[mentionKey]; seen {
+			continue
+		}
+
+		// Resolve via WebFinger; unresolvable mentions stay plain text
+		actorURI, inboxURI, err := ResolveMentionWithDeps(mentionKey, defaultHTTPClient, database)
 		if err != nil {
-			log.Printf("Outbox: Failed to resolve mention @%s@%s: %v", mention.Username, mention.Domain, err)
+			log.Printf("Outbox: Failed to resolve mention %s, leaving as plain text: %v", mentionKey, err)
 			continue
 		}
 
-		mentionKey := fmt.Sprintf("@%s@%s", mention.Username, mention.Domain)
 		mentionURIs[mentionKey] = actorURI
 		mentionedActors = append(mentionedActors, actorURI)
+		mentionedInboxes = append(mentionedInboxes, inboxURI)
 
 		tags = append(tags, map[string]any{
 			"type": "Mention",
@@ -457,10 +548,11 @@ func SendUpdateWithDeps(note *domain.Note, localAccount *domain.Account, conf *u
 	// Update noteObj cc with the expanded list
 	noteObj["cc"] = ccList
 
-	// Convert mentions to ActivityPub HTML (after we have resolved URIs)
-	if len(mentionURIs) > 0 {
-		contentHTML = util.MentionsToActivityPubHTML(contentHTML, mentionURIs)
-		noteObj["content"] = contentHTML
+	// Render the HTML content once mention URIs are resolved
+	contentHTML := util.MessageToActivityPubHTML(note.Message, baseURL, mentionURIs)
+	noteObj["content"] = contentHTML
+	if note.Language != "" {
+		noteObj["contentMap"] = map[string]string{note.Language: contentHTML}
 	}
 
 	// Build context - include Hashtag definition if we have hashtags
@@ -488,34 +580,27 @@ func SendUpdateWithDeps(note *domain.Note, localAccount *domain.Account, conf *u
 		"object": noteObj,
 	}
 
+	// The edited note is served from now on
+	storeNoteObject(note.Id, noteObj, context, database)
+
 	// Collect inboxes to deliver to (followers + parent author for replies)
 	inboxes := make(map[string]bool)
 
-	// Get all followers
-	err, followers := database.ReadFollowersByAccountId(localAccount.Id)
+	// Get all follower inboxes (shared inboxes deduplicated per server)
+	followerInboxes, err := database.ReadFollowerInboxURIs(localAccount.Id)
 	if err != nil {
 		log.Printf("Outbox: Failed to get followers for Update: %v", err)
-	} else if followers != nil {
-		for _, follower := range *followers {
-			// Skip local followers - they don't need federation delivery
-			if follower.IsLocal {
-				continue
-			}
-			err, remoteActor := database.ReadRemoteAccountById(follower.AccountId)
-			if err != nil {
-				log.Printf("Outbox: Failed to get remote actor %s: %v", follower.AccountId, err)
-				continue
-			}
-			inboxes[remoteActor.InboxURI] = true
-		}
+	}
+	for _, inboxURI := range followerInboxes {
+		inboxes[inboxURI] = true
 	}
 
 	// If this is a reply, also deliver to the parent author's inbox
 	if parentAuthorURI != "" && parentAuthorURI != actorURI {
 		// First try as remote account
-		err, parentAccount := database.ReadRemoteAccountByActorURI(parentAuthorURI)
+		parentAccount, err := database.ReadRemoteAccountByActorURI(parentAuthorURI)
 		if err == nil && parentAccount != nil {
-			inboxes[parentAccount.InboxURI] = true
+			inboxes[deliveryInbox(parentAccount)] = true
 		} else {
 			// Try as local account - extract username from URI like https://domain/users/username
 			if strings.Contains(parentAuthorURI, conf.Conf.SslDomain) {
@@ -523,7 +608,7 @@ func SendUpdateWithDeps(note *domain.Note, localAccount *domain.Account, conf *u
 				if len(parts) == 2 {
 					parentUsername := parts[1]
 					// Verify this local user exists
-					err, localParent := database.ReadAccByUsername(parentUsername)
+					localParent, err := database.ReadAccByUsername(parentUsername)
 					if err == nil && localParent != nil {
 						// Construct local inbox URI
 						localInboxURI := fmt.Sprintf("https://%s/users/%s/inbox", conf.Conf.SslDomain, parentUsername)
@@ -534,27 +619,14 @@ func SendUpdateWithDeps(note *domain.Note, localAccount *domain.Account, conf *u
 		}
 	}
 
-	// Also deliver to mentioned actors' inboxes
-	for _, mentionActorURI := range mentionedActors {
-		// Look up the remote account to get their inbox
-		err, mentionedAccount := database.ReadRemoteAccountByActorURI(mentionActorURI)
-		if err == nil && mentionedAccount != nil {
-			inboxes[mentionedAccount.InboxURI] = true
-			log.Printf("Outbox: Will also deliver Update to mentioned user %s@%s", mentionedAccount.Username, mentionedAccount.Domain)
-		} else {
-			// Fetch the actor if not cached
-			mentionedAccount, err = FetchRemoteActorWithDeps(mentionActorURI, defaultHTTPClient, database)
-			if err == nil && mentionedAccount != nil {
-				inboxes[mentionedAccount.InboxURI] = true
-				log.Printf("Outbox: Will also deliver Update to mentioned user %s@%s (fetched)", mentionedAccount.Username, mentionedAccount.Domain)
-			} else {
-				log.Printf("Outbox: Could not resolve inbox for mentioned actor %s: %v", mentionActorURI, err)
-			}
-		}
+	// Also deliver to mentioned actors' inboxes (resolved together with the mention)
+	for i, mentionInboxURI := range mentionedInboxes {
+		inboxes[mentionInboxURI] = true
+		log.Printf("Outbox: Will also deliver Update to mentioned actor %s", mentionedActors[i])
 	}
 
 	// Get active relays and add their inboxes
-	err, relays := database.ReadActiveRelays()
+	relays, err := database.ReadActiveRelays()
 	if err == nil && relays != nil {
 		for _, relay := range *relays {
 			inboxes[relay.InboxURI] = true
@@ -567,26 +639,64 @@ func SendUpdateWithDeps(note *domain.Note, localAccount *domain.Account, conf *u
 		return nil
 	}
 
-	// Queue delivery to each unique inbox
-	for inboxURI := range inboxes {
-		queueItem := &domain.DeliveryQueueItem{
-			Id:           uuid.New(),
-			InboxURI:     inboxURI,
-			ActivityJSON: mustMarshal(update),
-			Attempts:     0,
-			NextRetryAt:  time.Now(),
-			CreatedAt:    time.Now(),
-		}
-
-		if err := database.EnqueueDelivery(queueItem); err != nil {
-			log.Printf("Outbox: Failed to queue Update delivery to %s: %v", inboxURI, err)
-		}
+	// Queue delivery to every unique inbox in one transaction
+	if _, err := enqueueDeliveries(inboxes, mustMarshal(update), database); err != nil {
+		log.Printf("Outbox: Failed to queue Update deliveries: %v", err)
 	}
 
 	log.Printf("Outbox: Queued Update activity for note %s to %d inboxes", note.Id, len(inboxes))
 	return nil
 }
 
+// SendActorUpdate sends an Update of a local actor to all followers when its profile changed,
+// so their servers refresh the cached name, bio and avatar.
+// This is the production wrapper that uses the default database.
+func SendActorUpdate(localAccount *domain.Account, actor map[string]any, conf *util.AppConfig) error {
+	return SendActorUpdateWithDeps(localAccount, actor, conf, NewDBWrapper())
+}
+
+// SendActorUpdateWithDeps sends an Update of a local actor to all followers. actor is the
+// Person served at the actor's URI; its @context becomes the context of the Update.
+// This version accepts dependencies for testing.
+func SendActorUpdateWithDeps(localAccount *domain.Account, actor map[string]any, conf *util.AppConfig, database Database) error {
+	actorURI := fmt.Sprintf("https://%s/users/%s", conf.Conf.SslDomain, localAccount.Username)
+	updateID := fmt.Sprintf("https://%s/activities/%s", conf.Conf.SslDomain, uuid.New().String())
+
+	object := make(map[string]any, len(actor))
+	for key, value := range actor {
+		object[key] = value
+	}
+	context, ok := object["@context"]
+	if !ok {
+		context = "https://www.w3.org/ns/activitystreams"
+	}
+	delete(object, "@context")
+
+	update := map[string]any{
+		"@context":  context,
+		"id":        updateID,
+		"type":      "Update",
+		"actor":     actorURI,
+		"published": time.Now().Format(time.RFC3339),
+		"to": []string{
+			"https://www.w3.org/ns/activitystreams#Public",
+		},
+		"cc": []string{
+			fmt.Sprintf("https://%s/users/%s/followers", conf.Conf.SslDomain, localAccount.Username),
+		},
+		"object": object,
+	}
+
+	queued, err := DeliverToFollowersWithDeps(localAccount.Id, mustMarshal(update), database)
+	if err != nil {
+		log.Printf("Outbox: Failed to queue actor Update deliveries: %v", err)
+		return err
+	}
+
+	log.Printf("Outbox: Queued Update of actor %s to %d inboxes", actorURI, queued)
+	return nil
+}
+
 // SendDelete sends a Delete activity to all followers when a note is deleted.
 // This is the production wrapper that uses the default database.
 func SendDelete(noteId uuid.UUID, localAccount *domain.Account, conf *util.AppConfig) error {
@@ -597,48 +707,48 @@ func SendDelete(noteId uuid.UUID, localAccount *domain.Account, conf *util.AppCo
 // This version accepts dependencies for testing.
 func SendDeleteWithDeps(noteId uuid.UUID, localAccount *domain.Account, conf *util.AppConfig, database Database) error {
 	actorURI := fmt.Sprintf("https://%s/users/%s", conf.Conf.SslDomain, localAccount.Username)
-	noteURI := fmt.Sprintf("https://%s/notes/%s", conf.Conf.SslDomain, noteId.String())
+	noteURI := util.BuildNoteObjectURI(conf, noteId)
 	deleteID := fmt.Sprintf("https://%s/activities/%s", conf.Conf.SslDomain, uuid.New().String())
+	now := time.Now()
+
+	// The deleted note is represented by a Tombstone so remote servers can replace
+	// their cached copy while keeping any replies to it
+	tombstone := map[string]any{
+		"id":         noteURI,
+		"type":       "Tombstone",
+		"formerType": "Note",
+		"deleted":    now.Format(time.RFC3339),
+	}
 
 	deleteActivity := map[string]any{
 		"@context":  "https://www.w3.org/ns/activitystreams",
 		"id":        deleteID,
 		"type":      "Delete",
 		"actor":     actorURI,
-		"published": time.Now().Format(time.RFC3339),
+		"published": now.Format(time.RFC3339),
 		"to": []string{
 			"https://www.w3.org/ns/activitystreams#Public",
 		},
 		"cc": []string{
 			fmt.Sprintf("https://%s/users/%s/followers", conf.Conf.SslDomain, localAccount.Username),
 		},
-		"object": noteURI,
+		"object": tombstone,
 	}
 
 	// Collect inboxes to deliver to
 	inboxes := make(map[string]bool)
 
-	// Get all followers
-	err, followers := database.ReadFollowersByAccountId(localAccount.Id)
+	// Get all follower inboxes (shared inboxes deduplicated per server)
+	followerInboxes, err := database.ReadFollowerInboxURIs(localAccount.Id)
 	if err != nil {
 		log.Printf("Outbox: Failed to get followers for Delete: %v", err)
-	} else if followers != nil {
-		for _, follower := range *followers {
-			// Skip local followers - they don't need federation delivery
-			if follower.IsLocal {
-				continue
-			}
-			err, remoteActor := database.ReadRemoteAccountById(follower.AccountId)
-			if err != nil {
-				log.Printf("Outbox: Failed to get remote actor %s: %v", follower.AccountId, err)
-				continue
-			}
-			inboxes[remoteActor.InboxURI] = true
-		}
+	}
+	for _, inboxURI := range followerInboxes {
+		inboxes[inboxURI] = true
 	}
 
 	// Get active relays and add their inboxes
-	err, relays := database.ReadActiveRelays()
+	relays, err := database.ReadActiveRelays()
 	if err == nil && relays != nil {
 		for _, relay := range *relays {
 			inboxes[relay.InboxURI] = true
@@ -651,24 +761,127 @@ func SendDeleteWithDeps(noteId uuid.UUID, localAccount *domain.Account, conf *ut
 		return nil
 	}
 
-	// Queue delivery to each unique inbox
+	// Queue delivery to every unique inbox in one transaction
+	if _, err := enqueueDeliveries(inboxes, mustMarshal(deleteActivity), database); err != nil {
+		log.Printf("Outbox: Failed to queue Delete deliveries: %v", err)
+	}
+
+	log.Printf("Outbox: Queued Delete activity for note %s to %d inboxes", noteId, len(inboxes))
+	return nil
+}
+
+// DeliverToFollowers queues an activity for every follower of a local account.
+// Followers on the same server share one delivery when their server advertises a shared inbox.
+// Returns the number of queued deliveries.
+func DeliverToFollowers(accountId uuid.UUID, activityJSON string) (int, error) {
+	return DeliverToFollowersWithDeps(accountId, activityJSON, NewDBWrapper())
+}
+
+// DeliverToFollowersWithDeps queues an activity for every follower using injected dependencies.
+func DeliverToFollowersWithDeps(accountId uuid.UUID, activityJSON string, database Database) (int, error) {
+	followerInboxes, err := database.ReadFollowerInboxURIs(accountId)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get follower inboxes: %w", err)
+	}
+
+	inboxes := make(map[string]bool, len(followerInboxes))
+	for _, inboxURI := range followerInboxes {
+		inboxes[inboxURI] = true
+	}
+	return enqueueDeliveries(inboxes, activityJSON, database)
+}
+
+// enqueueDeliveries queues one delivery per inbox in a single transaction
+func enqueueDeliveries(inboxes map[string]bool, activityJSON string, database Database) (int, error) {
+	now := time.Now()
+	objectURI := deliveryObjectURI(activityJSON)
+	items := make([]*domain.DeliveryQueueItem, 0, len(inboxes))
 	for inboxURI := range inboxes {
-		queueItem := &domain.DeliveryQueueItem{
+		if inboxURI == "" {
+			continue
+		}
+		items = append(items, &domain.DeliveryQueueItem{
 			Id:           uuid.New(),
 			InboxURI:     inboxURI,
-			ActivityJSON: mustMarshal(deleteActivity),
+			ActivityJSON: activityJSON,
 			Attempts:     0,
-			NextRetryAt:  time.Now(),
-			CreatedAt:    time.Now(),
-		}
+			NextRetryAt:  now,
+			CreatedAt:    now,
+			ObjectURI:    objectURI,
+		})
+	}
+	if err := database.EnqueueDeliveryBatch(items); err != nil {
+		return 0, err
+	}
+	return len(items), nil
+}
 
-		if err := database.EnqueueDelivery(queueItem); err != nil {
-			log.Printf("Outbox: Failed to queue Delete delivery to %s: %v", inboxURI, err)
+// deliveryObjectURI returns the id of the object an activity is about, by which deliveries of
+// e.g. a note's Create, Update and Delete are kept in order. An Undo is about the object of
+// the activity it reverses, so that it stays behind e.g. the Like or Follow it undoes.
+func deliveryObjectURI(activityJSON string) string {
+	var activity struct {
+		Type   string `json:"type"`
+		Object any    `json:"object"`
+	}
+	if err := json.Unmarshal([]byte(activityJSON), &activity); err != nil {
+		return ""
+	}
+	switch obj := activity.Object.(type) {
+	case string:
+		return obj
+	case map[string]any:
+		if undone := objectID(obj["object"]); activity.Type == "Undo" && undone != "" {
+			return undone
 		}
+		id, _ := obj["id"].(string)
+		return id
 	}
+	return ""
+}
 
-	log.Printf("Outbox: Queued Delete activity for note %s to %d inboxes", noteId, len(inboxes))
-	return nil
+// deliveryInbox returns the inbox to deliver to for a remote actor, preferring its shared inbox
+func deliveryInbox(remote *domain.RemoteAccount) string {
+	if remote.SharedInboxURI != "" {
+		return remote.SharedInboxURI
+	}
+	return remote.InboxURI
+}
+
+// storeLocalActivity saves an activity we emit (local = true) so it can be served at its id.
+// Failures are only logged; delivery does not depend on it.
+func storeLocalActivity(activity map[string]any, objectURI string, database Database) {
+	activityURI, _ := activity["id"].(string)
+	activityType, _ := activity["type"].(string)
+	actorURI, _ := activity["actor"].(string)
+
+	stored := &domain.Activity{
+		Id:           uuid.New(),
+		ActivityURI:  activityURI,
+		ActivityType: activityType,
+		ActorURI:     actorURI,
+		ObjectURI:    objectURI,
+		RawJSON:      mustMarshal(activity),
+		Processed:    true,
+		Local:        true,
+		CreatedAt:    time.Now(),
+	}
+	if err := database.CreateActivity(stored); err != nil {
+		log.Printf("Outbox: Failed to store local %s activity %s: %v", activityType, activityURI, err)
+	}
+}
+
+// storeNoteObject keeps a local note's Note object exactly as it was delivered, so
+// dereferencing the note id returns the same object remote servers received
+func storeNoteObject(noteId uuid.UUID, noteObj map[string]any, context any, database Database) {
+	object := make(map[string]any, len(noteObj)+1)
+	for key, value := range noteObj {
+		object[key] = value
+	}
+	object["@context"] = context
+	if err := database.UpdateNoteObjectJSON(noteId, mustMarshal(object)); err != nil {
+		log.Printf("Outbox: Failed to store Note object of %s: %v", noteId, err)
+	}
 }
 
 // SendFollow sends a Follow activity to a remote actor.
@@ -693,7 +906,7 @@ func SendFollowWithDeps(localAccount *domain.Account, remoteActorURI string, con
 	}
 
 	// Check if already following this user
-	err, existingFollow := database.ReadFollowByAccountIds(localAccount.Id, remoteActor.Id)
+	existingFollow, err := database.ReadFollowByAccountIds(localAccount.Id, remoteActor.Id)
 	if err != sql.ErrNoRows && err != nil {
 		// Database error (not "not found")
 		log.Printf("SendFollow: Error checking existing follow: %v", err)
@@ -810,6 +1023,8 @@ func SendLikeWithDeps(localAccount *domain.Account, noteURI string, conf *util.A
 		"object":   noteURI,
 	}
 
+	storeLocalActivity(like, noteURI, database)
+
 	log.Printf("Outbox: Sending Like from %s for note %s to %s@%s", localAccount.Username, noteURI, remoteActor.Username, remoteActor.Domain)
 	return SendActivityWithDeps(like, remoteActor.InboxURI, localAccount, conf, client)
 }
@@ -861,15 +1076,40 @@ func SendUndoLikeWithDeps(localAccount *domain.Account, noteURI string, likeURI
 	return SendActivityWithDeps(undo, remoteActor.InboxURI, localAccount, conf, client)
 }
 
-// SendRelayFollow subscribes to a relay by sending a Follow activity.
+// SendRelayFollow subscribes to a relay by sending a Follow activity, detecting the relay type from its actor URI.
 // This is the production wrapper that uses the default HTTP client and database.
 func SendRelayFollow(localAccount *domain.Account, relayActorURI string, conf *util.AppConfig) error {
 	return SendRelayFollowWithDeps(localAccount, relayActorURI, conf, defaultHTTPClient, NewDBWrapper())
 }
 
-// SendRelayFollowWithDeps subscribes to a relay by sending a Follow activity.
+// SendRelayFollowWithDeps subscribes to a relay by sending a Follow activity, detecting the relay type from its actor URI.
 // This version accepts dependencies for testing.
 func SendRelayFollowWithDeps(localAccount *domain.Account, relayActorURI string, conf *util.AppConfig, client HTTPClient, database Database) error {
+	return SubscribeToRelayWithDeps(localAccount, relayActorURI, DetectRelayType(relayActorURI), conf, client, database)
+}
+
+// DetectRelayType guesses the subscribe flow from a relay actor URI: Pleroma and Akkoma serve
+// their relay actor at /relay, while Mastodon-style relays use /actor or /inbox
+func DetectRelayType(relayActorURI string) string {
+	if strings.HasSuffix(strings.TrimSuffix(relayActorURI, "/"), "/relay") {
+		return domain.RelayTypeLitePub
+	}
+	return domain.RelayTypeMastodon
+}
+
+// SubscribeToRelay subscribes to a relay by sending the Follow its relay type expects.
+// This is the production wrapper that uses the default HTTP client and database.
+func SubscribeToRelay(localAccount *domain.Account, relayActorURI, relayType string, conf *util.AppConfig) error {
+	return SubscribeToRelayWithDeps(localAccount, relayActorURI, relayType, conf, defaultHTTPClient, NewDBWrapper())
+}
+
+// SubscribeToRelayWithDeps subscribes to a relay by sending the Follow its relay type expects.
+// This version accepts dependencies for testing.
+func SubscribeToRelayWithDeps(localAccount *domain.Account, relayActorURI, relayType string, conf *util.AppConfig, client HTTPClient, database Database) error {
+	if relayType != domain.RelayTypeMastodon && relayType != domain.RelayTypeLitePub {
+		return fmt.Errorf("unknown relay type %q", relayType)
+	}
+
 	// Fetch the relay actor to get inbox and validate it's a relay
 	relayActor, err := FetchRemoteActorWithDeps(relayActorURI, client, database)
 	if err != nil {
@@ -877,7 +1117,7 @@ func SendRelayFollowWithDeps(localAccount *domain.Account, relayActorURI string,
 	}
 
 	// Check if already subscribed
-	err, existingRelay := database.ReadRelayByActorURI(relayActorURI)
+	existingRelay, err := database.ReadRelayByActorURI(relayActorURI)
 	if err == nil && existingRelay != nil {
 		if existingRelay.Status == "active" {
 			return fmt.Errorf("already subscribed to relay %s", relayActorURI)
@@ -893,18 +1133,7 @@ func SendRelayFollowWithDeps(localAccount *domain.Account, relayActorURI string,
 
 	// Create follow activity
 	followID := fmt.Sprintf("https://%s/activities/%s", conf.Conf.SslDomain, uuid.New().String())
-	actorURI := fmt.Sprintf("https://%s/users/%s", conf.Conf.SslDomain, localAccount.Username)
-
-	// Use the public address as the object for relay follows
-	// This is compatible with both FediBuzz and YUKIMOCHI Activity-Relay
-	// YUKIMOCHI requires either object=Public or actor path ending in /relay
-	follow := map[string]any{
-		"@context": "https://www.w3.org/ns/activitystreams",
-		"id":       followID,
-		"type":     "Follow",
-		"actor":    actorURI,
-		"object":   "https://www.w3.org/ns/activitystreams#Public",
-	}
+	follow := newRelayFollow(followID, localAccount.Username, relayFollowObject(relayType, relayActorURI), conf)
 
 	// Store relay record as pending (include follow URI for later Undo)
 	relay := &domain.Relay{
@@ -914,7 +1143,9 @@ func SendRelayFollowWithDeps(localAccount *domain.Account, relayActorURI string,
 		FollowURI: followID,
 		Name:      relayActor.DisplayName,
 		Status:    "pending",
+		Type:      relayType,
 		CreatedAt: time.Now(),
+		AccountId: localAccount.Id,
 	}
 
 	if err := database.CreateRelay(relay); err != nil {
@@ -922,10 +1153,31 @@ func SendRelayFollowWithDeps(localAccount *domain.Account, relayActorURI string,
 	}
 
 	// Send Follow activity to relay
-	log.Printf("Outbox: Sending Follow to relay %s from %s", relayActorURI, localAccount.Username)
+	log.Printf("Outbox: Sending %s Follow to relay %s from %s", relayType, relayActorURI, localAccount.Username)
 	return SendActivityWithDeps(follow, relayActor.InboxURI, localAccount, conf, client)
 }
 
+// relayFollowObject returns the object of a relay Follow.
+// Mastodon-style relays (pub-relay, FediBuzz, YUKIMOCHI Activity-Relay) expect the Public
+// collection; LitePub relays (Pleroma, Akkoma) expect the relay actor itself
+func relayFollowObject(relayType, relayActorURI string) string {
+	if relayType == domain.RelayTypeLitePub {
+		return relayActorURI
+	}
+	return "https://www.w3.org/ns/activitystreams#Public"
+}
+
+// newRelayFollow builds a Follow activity for a relay subscription
+func newRelayFollow(followID, username, object string, conf *util.AppConfig) map[string]any {
+	return map[string]any{
+		"@context": "https://www.w3.org/ns/activitystreams",
+		"id":       followID,
+		"type":     "Follow",
+		"actor":    fmt.Sprintf("https://%s/users/%s", conf.Conf.SslDomain, username),
+		"object":   object,
+	}
+}
+
 // SendRelayUnfollow unsubscribes from a relay by sending an Undo Follow activity.
 // This is the production wrapper that uses the default HTTP client.
 func SendRelayUnfollow(localAccount *domain.Account, relay *domain.Relay, conf *util.AppConfig) error {
@@ -954,7 +1206,7 @@ func SendRelayUnfollowWithDeps(localAccount *domain.Account, relay *domain.Relay
 			"id":     followID,
 			"type":   "Follow",
 			"actor":  actorURI,
-			"object": "https://www.w3.org/ns/activitystreams#Public",
+			"object": relayFollowObject(relay.Type, relay.ActorURI),
 		},
 	}
 
@@ -975,13 +1227,13 @@ func mustMarshal(v any) string {
 // This is used to add the parent author to cc when creating a reply
 func extractAuthorFromURI(objectURI string, database Database, conf *util.AppConfig) string {
 	// First, check if we have a stored activity with this object
-	err, activity := database.ReadActivityByObjectURI(objectURI)
+	activity, err := database.ReadActivityByObjectURI(objectURI)
 	if err == nil && activity != nil {
 		return activity.ActorURI
 	}
 
 	// Try to check if it's a local note
-	err, localNote := database.ReadNoteByURI(objectURI)
+	localNote, err := database.ReadNoteByURI(objectURI)
 	if err == nil && localNote != nil {
 		// It's a local note - return the local author's actor URI
 		// This ensures replies to local users are delivered to their inbox
@@ -993,9 +1245,45 @@ func extractAuthorFromURI(objectURI string, database Database, conf *util.AppCon
 	return ""
 }
 
+// ResolveMention resolves an acct ("@bob@remote.example", "bob@remote.example" or
+// "acct:bob@remote.example") to the actor URI and inbox URI of the remote account.
+// The remote account is cached, so repeated mentions don't hit WebFinger again.
+// This is the production wrapper that uses the default HTTP client and database.
+func ResolveMention(acct string) (string, string, error) {
+	return ResolveMentionWithDeps(acct, defaultHTTPClient, NewDBWrapper())
+}
+
+// ResolveMentionWithDeps resolves an acct to an actor URI and inbox URI.
+// This version accepts dependencies for testing.
+func ResolveMentionWithDeps(acct string, client HTTPClient, database Database) (string, string, error) {
+	acct = strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(acct), "acct:"), "@")
+	parts := strings.SplitN(acct, "@", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("invalid mention %q: expected username@domain", acct)
+	}
+	username, domainName := parts[0], parts[1]
+
+	// Use the cached account while it's fresh
+	cached, err := database.ReadRemoteAccountByUsernameAndDomain(username, domainName)
+	if err == nil && cached != nil && cached.InboxURI != "" && time.Since(cached.LastFetchedAt) < 24*time.Hour {
+		return cached.ActorURI, cached.InboxURI, nil
+	}
+
+	actorURI, err := resolveMentionURI(username, domainName, client)
+	if err != nil {
+		return "", "", err
+	}
+
+	remoteAcc, err := GetOrFetchActorWithDeps(actorURI, client, database)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to fetch mentioned actor %s: %w", actorURI, err)
+	}
+	return remoteAcc.ActorURI, remoteAcc.InboxURI, nil
+}
+
 // resolveMentionURI resolves a @username@domain mention to an ActivityPub actor URI
 // using WebFinger lookup
-func resolveMentionURI(username, domain string) (string, error) {
+func resolveMentionURI(username, domain string, client HTTPClient) (string, error) {
 	webfingerURL := fmt.Sprintf("https://%s/.well-known/webfinger?resource=acct:%s@%s",
 		domain, username, domain)
 
@@ -1004,10 +1292,8 @@ func resolveMentionURI(username, domain string) (string, error) {
 		return "", fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Accept", "application/jrd+json")
-	req.Header.Set("User-Agent", "stegodon/1.0 ActivityPub")
+	SetRequestHeaders(req, "application/jrd+json")
 
-	client := &http.Client{Timeout: 5 * time.Second}
 	resp, err := client.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("webfinger request failed: %w", err)
@@ -1018,9 +1304,9 @@ func resolveMentionURI(username, domain string) (string, error) {
 		return "", fmt.Errorf("webfinger failed with status: %d", resp.StatusCode)
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	body, err := ReadJSONResponse(resp)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
+		return "", err
 	}
 
 	// WebFingerResponse structure for parsing
@@ -1042,8 +1328,7 @@ func resolveMentionURI(username, domain string) (string, error) {
 	// Find self link with ActivityPub-compatible type
 	for _, link := range result.Links {
 		if link.Rel == "self" {
-			if link.Type == "application/activity+json" ||
-				link.Type == "application/ld+json; profile=\"https://www.w3.org/ns/activitystreams\"" {
+			if IsActivityStreamsContentType(link.Type) {
 				return link.Href, nil
 			}
 		}
@@ -4,7 +4,9 @@ import (
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -482,20 +484,58 @@ func TestDeliveryQueueItemCreation(t *testing.T) {
 	}
 }
 
+// TestHTTPHeaders tests that outgoing actor and object fetches, webfinger lookups and
+// deliveries identify us with the configured User-Agent and ask for the right content type
 func TestHTTPHeaders(t *testing.T) {
-	// Test that we set proper headers for ActivityPub
-	contentType := "application/activity+json"
-	accept := "application/activity+json"
-	userAgent := "stegodon/1.0 ActivityPub"
+	defer func(original string) { userAgent = original }(userAgent)
+	conf := &util.AppConfig{}
+	conf.Conf.SslDomain = "local.example.com"
+	userAgent = conf.FederationUserAgent()
+	if userAgent != "stegodon/"+util.GetVersion()+" (+https://local.example.com)" {
+		t.Fatalf("Unexpected default User-Agent %q", userAgent)
+	}
+
+	mockHTTP := NewMockHTTPClient()
+	actorURI := "https://remote.example.com/users/bob"
+	objectURI := "https://remote.example.com/notes/1"
+	inboxURI := "https://remote.example.com/inbox"
+	mockHTTP.SetJSONResponse(actorURI, 200, map[string]any{
+		"id": actorURI, "type": "Person", "preferredUsername": "bob", "inbox": inboxURI,
+		"publicKey": map[string]any{"publicKeyPem": "key"},
+	})
+	mockHTTP.SetJSONResponse(objectURI, 200, map[string]any{"id": objectURI, "type": "Note"})
+	mockHTTP.SetJSONResponse("https://remote.example.com/.well-known/webfinger?resource=acct:bob@remote.example.com", 200, map[string]any{
+		"links": []any{map[string]any{"rel": "self", "type": "application/activity+json", "href": actorURI}},
+	})
+	mockHTTP.SetResponse(inboxURI, 202, []byte("Accepted"))
 
-	if contentType != "application/activity+json" {
-		t.Error("Content-Type should be application/activity+json")
+	keypair, _ := GenerateTestKeyPair()
+	account := CreateTestAccount("alice", keypair)
+	if _, err := FetchRemoteActorWithDeps(actorURI, mockHTTP, NewMockDatabase()); err != nil {
+		t.Fatalf("FetchRemoteActorWithDeps failed: %v", err)
 	}
-	if accept != "application/activity+json" {
-		t.Error("Accept should be application/activity+json")
+	if _, err := fetchActivityPubObject(objectURI, mockHTTP); err != nil {
+		t.Fatalf("fetchActivityPubObject failed: %v", err)
 	}
-	if !strings.Contains(userAgent, "stegodon") {
-		t.Error("User-Agent should identify as stegodon")
+	if _, err := resolveMentionURI("bob", "remote.example.com", mockHTTP); err != nil {
+		t.Fatalf("resolveMentionURI failed: %v", err)
+	}
+	activity := map[string]any{"type": "Follow", "actor": "https://local.example.com/users/alice", "object": actorURI}
+	if err := SendActivityWithDeps(activity, inboxURI, account, conf, mockHTTP); err != nil {
+		t.Fatalf("SendActivityWithDeps failed: %v", err)
+	}
+
+	wantAccept := []string{ActivityAcceptHeader, ActivityAcceptHeader, "application/jrd+json", ActivityJSONContentType}
+	if len(mockHTTP.Requests) != len(wantAccept) {
+		t.Fatalf("Expected %d requests, got %d", len(wantAccept), len(mockHTTP.Requests))
+	}
+	for i, req := range mockHTTP.Requests {
+		if got := req.Header.Get("User-Agent"); got != userAgent {
+			t.Errorf("%s %s: expected User-Agent %q, got %q", req.Method, req.URL, userAgent, got)
+		}
+		if got := req.Header.Get("Accept"); got != wantAccept[i] {
+			t.Errorf("%s %s: expected Accept %q, got %q", req.Method, req.URL, wantAccept[i], got)
+		}
 	}
 }
 
@@ -1653,6 +1693,101 @@ func TestSendCreateWithDeps_NoFollowers(t *testing.T) {
 	}
 }
 
+// TestSendCreateWithDeps_StoresLocalActivity tests that the emitted Create is kept so its id can be dereferenced
+func TestSendCreateWithDeps_StoresLocalActivity(t *testing.T) {
+	mockDB := NewMockDatabase()
+
+	keypair, _ := GenerateTestKeyPair()
+	account := CreateTestAccount("alice", keypair)
+	mockDB.AddAccount(account)
+
+	conf := &util.AppConfig{}
+	conf.Conf.SslDomain = "local.example.com"
+
+	note := &domain.Note{
+		Id:        uuid.New(),
+		CreatedBy: account.Username,
+		Message:   "Hello, world!",
+		CreatedAt: time.Now(),
+	}
+
+	if err := SendCreateWithDeps(note, account, conf, mockDB); err != nil {
+		t.Fatalf("SendCreateWithDeps failed: %v", err)
+	}
+
+	if len(mockDB.Activities) != 1 {
+		t.Fatalf("Expected 1 stored activity, got %d", len(mockDB.Activities))
+	}
+	for _, activity := range mockDB.Activities {
+		if !activity.Local || activity.ActivityType != "Create" {
+			t.Errorf("Expected local Create activity, got local=%v type=%s", activity.Local, activity.ActivityType)
+		}
+		if !strings.HasPrefix(activity.ActivityURI, "https://local.example.com/activities/") {
+			t.Errorf("Expected activity URI under /activities/, got %s", activity.ActivityURI)
+		}
+		if activity.ObjectURI != "https://local.example.com/notes/"+note.Id.String() {
+			t.Errorf("Expected object URI of the note, got %s", activity.ObjectURI)
+		}
+		if found, ok := mockDB.ActivitiesByURI[activity.ActivityURI]; !ok || found != activity {
+			t.Errorf("Expected stored activity to be retrievable by its URI")
+		}
+	}
+}
+
+// TestSendCreateWithDeps_StoresNoteObject tests that the delivered Note is stored for dereferencing
+func TestSendCreateWithDeps_StoresNoteObject(t *testing.T) {
+	mockDB := NewMockDatabase()
+
+	keypair, _ := GenerateTestKeyPair()
+	account := CreateTestAccount("alice", keypair)
+	mockDB.AddAccount(account)
+
+	conf := &util.AppConfig{}
+	conf.Conf.SslDomain = "local.example.com"
+
+	note := &domain.Note{
+		Id:        uuid.New(),
+		CreatedBy: account.Username,
+		Message:   "Hello #world",
+		CreatedAt: time.Now(),
+	}
+
+	if err := SendCreateWithDeps(note, account, conf, mockDB); err != nil {
+		t.Fatalf("SendCreateWithDeps failed: %v", err)
+	}
+
+	var create map[string]any
+	for _, activity := range mockDB.Activities {
+		if err := json.Unmarshal([]byte(activity.RawJSON), &create); err != nil {
+			t.Fatalf("Failed to parse stored Create: %v", err)
+		}
+	}
+	var stored map[string]any
+	if err := json.Unmarshal([]byte(mockDB.NoteObjects[note.Id]), &stored); err != nil {
+		t.Fatalf("Failed to parse stored Note object: %v", err)
+	}
+
+	// The stored object is the delivered one plus the Create's @context
+	if !reflect.DeepEqual(stored["@context"], create["@context"]) {
+		t.Errorf("Expected the Create's @context, got %v", stored["@context"])
+	}
+	delete(stored, "@context")
+	if !reflect.DeepEqual(stored, create["object"]) {
+		t.Errorf("Expected the stored Note to match the delivered one:\n%v\n%v", stored, create["object"])
+	}
+
+	// An edit replaces it
+	editedAt := time.Now()
+	note.Message = "Hello again"
+	note.EditedAt = &editedAt
+	if err := SendUpdateWithDeps(note, account, conf, mockDB); err != nil {
+		t.Fatalf("SendUpdateWithDeps failed: %v", err)
+	}
+	if !strings.Contains(mockDB.NoteObjects[note.Id], "Hello again") || !strings.Contains(mockDB.NoteObjects[note.Id], `"updated"`) {
+		t.Errorf("Expected the edited Note to be stored, got %s", mockDB.NoteObjects[note.Id])
+	}
+}
+
 // TestSendCreateWithDeps_WithFollowers tests creating a note that gets delivered to followers
 func TestSendCreateWithDeps_WithFollowers(t *testing.T) {
 	mockDB := NewMockDatabase()
@@ -1747,8 +1882,8 @@ func TestSendCreateWithDeps_WithFollowers(t *testing.T) {
 			t.Errorf("Expected object type 'Note', got %v", obj["type"])
 		}
 
-		if obj["content"] != "Hello, followers!" {
-			t.Errorf("Expected content 'Hello, followers!', got %v", obj["content"])
+		if obj["content"] != "<p>Hello, followers!</p>" {
+			t.Errorf("Expected content '<p>Hello, followers!</p>', got %v", obj["content"])
 		}
 	}
 
@@ -1864,6 +1999,70 @@ func TestSendUpdateWithDeps_WithFollowers(t *testing.T) {
 	}
 }
 
+// TestSendUpdateWithDeps_Reply tests that an edited reply keeps inReplyTo and the updated timestamp
+func TestSendUpdateWithDeps_Reply(t *testing.T) {
+	mockDB := NewMockDatabase()
+
+	keypair, _ := GenerateTestKeyPair()
+	account := CreateTestAccount("alice", keypair)
+	mockDB.AddAccount(account)
+
+	remoteActor := &domain.RemoteAccount{
+		Id:       uuid.New(),
+		Username: "bob",
+		Domain:   "remote.example.com",
+		ActorURI: "https://remote.example.com/users/bob",
+		InboxURI: "https://remote.example.com/users/bob/inbox",
+	}
+	mockDB.AddRemoteAccount(remoteActor)
+	mockDB.AddFollow(&domain.Follow{
+		Id:              uuid.New(),
+		AccountId:       remoteActor.Id,
+		TargetAccountId: account.Id,
+		URI:             "https://remote.example.com/follows/1",
+		Accepted:        true,
+		CreatedAt:       time.Now(),
+	})
+
+	conf := &util.AppConfig{}
+	conf.Conf.SslDomain = "local.example.com"
+
+	editedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	note := &domain.Note{
+		Id:           uuid.New(),
+		CreatedBy:    account.Username,
+		Message:      "Edited reply",
+		CreatedAt:    editedAt.Add(-1 * time.Hour),
+		EditedAt:     &editedAt,
+		InReplyToURI: "https://remote.example.com/notes/parent",
+	}
+
+	if err := SendUpdateWithDeps(note, account, conf, mockDB); err != nil {
+		t.Fatalf("SendUpdateWithDeps failed: %v", err)
+	}
+
+	if len(mockDB.DeliveryQueue) == 0 {
+		t.Fatal("Expected Update to be queued for delivery")
+	}
+
+	for _, item := range mockDB.DeliveryQueue {
+		var activity map[string]any
+		if err := json.Unmarshal([]byte(item.ActivityJSON), &activity); err != nil {
+			t.Fatalf("Failed to parse activity JSON: %v", err)
+		}
+		obj, ok := activity["object"].(map[string]any)
+		if !ok {
+			t.Fatal("Expected object to be a map")
+		}
+		if obj["inReplyTo"] != note.InReplyToURI {
+			t.Errorf("Expected inReplyTo %q, got %v", note.InReplyToURI, obj["inReplyTo"])
+		}
+		if obj["updated"] != editedAt.Format(time.RFC3339) {
+			t.Errorf("Expected updated %q, got %v", editedAt.Format(time.RFC3339), obj["updated"])
+		}
+	}
+}
+
 // TestSendUpdateWithDeps_NoEditedAt tests Update with nil EditedAt (uses CreatedAt)
 func TestSendUpdateWithDeps_NoEditedAt(t *testing.T) {
 	mockDB := NewMockDatabase()
@@ -2001,17 +2200,234 @@ func TestSendDeleteWithDeps_WithFollowers(t *testing.T) {
 			t.Errorf("Expected activity type 'Delete', got %v", activity["type"])
 		}
 
-		// For Delete, object is a URI string
-		objStr, ok := activity["object"].(string)
+		// For Delete, object is a Tombstone for the note URI
+		obj, ok := activity["object"].(map[string]any)
 		if !ok {
-			t.Error("Expected object to be a string URI for Delete activity")
+			t.Error("Expected object to be a Tombstone for Delete activity")
 			continue
 		}
 
+		if obj["type"] != "Tombstone" {
+			t.Errorf("Expected object type 'Tombstone', got %v", obj["type"])
+		}
+		if obj["formerType"] != "Note" {
+			t.Errorf("Expected formerType 'Note', got %v", obj["formerType"])
+		}
+
 		expectedNoteURI := "https://local.example.com/notes/" + noteId.String()
-		if objStr != expectedNoteURI {
-			t.Errorf("Expected object URI %s, got %s", expectedNoteURI, objStr)
+		if obj["id"] != expectedNoteURI {
+			t.Errorf("Expected object URI %s, got %v", expectedNoteURI, obj["id"])
+		}
+	}
+}
+
+func TestSendActorUpdateWithDeps(t *testing.T) {
+	mockDB := NewMockDatabase()
+
+	keypair, _ := GenerateTestKeyPair()
+	account := CreateTestAccount("alice", keypair)
+	mockDB.AddAccount(account)
+
+	remoteActor := &domain.RemoteAccount{
+		Id:       uuid.New(),
+		Username: "bob",
+		Domain:   "remote.example.com",
+		ActorURI: "https://remote.example.com/users/bob",
+		InboxURI: "https://remote.example.com/users/bob/inbox",
+	}
+	mockDB.AddRemoteAccount(remoteActor)
+	mockDB.AddFollow(&domain.Follow{
+		Id:              uuid.New(),
+		AccountId:       remoteActor.Id,
+		TargetAccountId: account.Id,
+		URI:             "https://remote.example.com/follows/1",
+		Accepted:        true,
+		CreatedAt:       time.Now(),
+	})
+
+	conf := &util.AppConfig{}
+	conf.Conf.SslDomain = "local.example.com"
+
+	actor := map[string]any{
+		"@context": []any{"https://www.w3.org/ns/activitystreams", "https://w3id.org/security/v1"},
+		"id":       "https://local.example.com/users/alice",
+		"type":     "Person",
+		"name":     "Alice Liddell",
+		"summary":  "new bio",
+	}
+	if err := SendActorUpdateWithDeps(account, actor, conf, mockDB); err != nil {
+		t.Fatalf("SendActorUpdateWithDeps failed: %v", err)
+	}
+
+	if len(mockDB.DeliveryQueue) != 1 {
+		t.Fatalf("Expected 1 delivery queue item, got %d", len(mockDB.DeliveryQueue))
+	}
+	for _, item := range mockDB.DeliveryQueue {
+		if item.InboxURI != remoteActor.InboxURI {
+			t.Errorf("Expected delivery to %s, got %s", remoteActor.InboxURI, item.InboxURI)
+		}
+		var activity map[string]any
+		if err := json.Unmarshal([]byte(item.ActivityJSON), &activity); err != nil {
+			t.Fatalf("Failed to parse activity JSON: %v", err)
+		}
+		if activity["type"] != "Update" || activity["actor"] != "https://local.example.com/users/alice" {
+			t.Errorf("Expected an Update by alice, got %v by %v", activity["type"], activity["actor"])
+		}
+		if _, ok := activity["@context"].([]any); !ok {
+			t.Errorf("Expected the actor's context on the Update, got %v", activity["@context"])
+		}
+		obj, _ := activity["object"].(map[string]any)
+		if obj["type"] != "Person" || obj["name"] != "Alice Liddell" || obj["summary"] != "new bio" {
+			t.Errorf("Expected the updated Person as object, got %v", obj)
 		}
+		if _, ok := obj["@context"]; ok {
+			t.Error("Expected the object to carry no @context of its own")
+		}
+	}
+
+	// The caller's actor is left untouched
+	if _, ok := actor["@context"]; !ok {
+		t.Error("Expected the actor passed in to keep its @context")
+	}
+}
+
+// addSharedInboxFollowers adds count remote followers spread over servers; every server
+// except the last advertises a shared inbox
+func addSharedInboxFollowers(mockDB *MockDatabase, target *domain.Account, count, servers int) {
+	for i := 0; i < count; i++ {
+		server := fmt.Sprintf("remote%d.example.com", i%servers)
+		remote := &domain.RemoteAccount{
+			Id:       uuid.New(),
+			Username: fmt.Sprintf("user%d", i),
+			Domain:   server,
+			ActorURI: fmt.Sprintf("https://%s/users/user%d", server, i),
+			InboxURI: fmt.Sprintf("https://%s/users/user%d/inbox", server, i),
+		}
+		if i%servers != servers-1 {
+			remote.SharedInboxURI = fmt.Sprintf("https://%s/inbox", server)
+		}
+		mockDB.AddRemoteAccount(remote)
+		mockDB.AddFollow(&domain.Follow{
+			Id:              uuid.New(),
+			AccountId:       remote.Id,
+			TargetAccountId: target.Id,
+			URI:             fmt.Sprintf("https://%s/follows/%d", server, i),
+			Accepted:        true,
+			CreatedAt:       time.Now(),
+		})
+	}
+}
+
+func TestDeliverToFollowersWithDeps_SharedInboxes(t *testing.T) {
+	mockDB := NewMockDatabase()
+	keypair, _ := GenerateTestKeyPair()
+	account := CreateTestAccount("alice", keypair)
+	mockDB.AddAccount(account)
+
+	// 1000 followers on 4 servers; 3 servers have a shared inbox, the last has 250 personal inboxes
+	addSharedInboxFollowers(mockDB, account, 1000, 4)
+
+	queued, err := DeliverToFollowersWithDeps(account.Id, `{"type":"Create"}`, mockDB)
+	if err != nil {
+		t.Fatalf("DeliverToFollowersWithDeps failed: %v", err)
+	}
+	if queued != 253 {
+		t.Errorf("Expected 253 deliveries (3 shared + 250 personal inboxes), got %d", queued)
+	}
+	if len(mockDB.DeliveryQueue) != 253 {
+		t.Errorf("Expected 253 queued items, got %d", len(mockDB.DeliveryQueue))
+	}
+	if mockDB.EnqueueCalls != 1 {
+		t.Errorf("Expected deliveries to be queued in 1 transaction, got %d", mockDB.EnqueueCalls)
+	}
+
+	inboxes := make(map[string]bool)
+	for _, item := range mockDB.DeliveryQueue {
+		if inboxes[item.InboxURI] {
+			t.Errorf("Inbox %s queued twice", item.InboxURI)
+		}
+		inboxes[item.InboxURI] = true
+	}
+	if !inboxes["https://remote0.example.com/inbox"] {
+		t.Error("Expected delivery to remote0 shared inbox")
+	}
+}
+
+// TestEnqueueDeliveries_UndoAfterLike tests that an Undo is held back behind the Like it undoes
+func TestEnqueueDeliveries_UndoAfterLike(t *testing.T) {
+	mockDB := NewMockDatabase()
+	inboxes := map[string]bool{"https://remote.example.com/users/bob/inbox": true}
+	noteURI := "https://remote.example.com/notes/1"
+
+	like := `{"id": "https://local.example.com/activities/like-1", "type": "Like", "object": "` + noteURI + `"}`
+	undo := `{"id": "https://local.example.com/activities/undo-1", "type": "Undo", "object": {"id": "https://local.example.com/activities/like-1", "type": "Like", "object": "` + noteURI + `"}}`
+	for _, activity := range []string{like, undo} {
+		if _, err := enqueueDeliveries(inboxes, activity, mockDB); err != nil {
+			t.Fatalf("enqueueDeliveries failed: %v", err)
+		}
+	}
+
+	for _, item := range mockDB.DeliveryQueue {
+		if item.ObjectURI != noteURI {
+			t.Errorf("Expected deliveries to be about %s, got %s", noteURI, item.ObjectURI)
+		}
+	}
+	pending, err := mockDB.ReadPendingDeliveries(10)
+	if err != nil {
+		t.Fatalf("ReadPendingDeliveries failed: %v", err)
+	}
+	if len(*pending) != 1 || (*pending)[0].ActivityJSON != like {
+		t.Fatalf("Expected only the Like to be pending, got %+v", *pending)
+	}
+
+	// Once the Like is delivered, the Undo follows
+	if err := mockDB.DeleteDelivery((*pending)[0].Id); err != nil {
+		t.Fatalf("DeleteDelivery failed: %v", err)
+	}
+	pending, err = mockDB.ReadPendingDeliveries(10)
+	if err != nil {
+		t.Fatalf("ReadPendingDeliveries failed: %v", err)
+	}
+	if len(*pending) != 1 || (*pending)[0].ActivityJSON != undo {
+		t.Errorf("Expected the Undo to be pending, got %+v", *pending)
+	}
+}
+
+func TestDeliverToFollowersWithDeps_DatabaseError(t *testing.T) {
+	mockDB := NewMockDatabase()
+	mockDB.ForceError = fmt.Errorf("database down")
+
+	if _, err := DeliverToFollowersWithDeps(uuid.New(), `{"type":"Create"}`, mockDB); err == nil {
+		t.Error("Expected error when follower inboxes cannot be read")
+	}
+}
+
+func TestSendCreateWithDeps_BatchesSharedInboxes(t *testing.T) {
+	mockDB := NewMockDatabase()
+	keypair, _ := GenerateTestKeyPair()
+	account := CreateTestAccount("alice", keypair)
+	mockDB.AddAccount(account)
+	addSharedInboxFollowers(mockDB, account, 100, 2)
+
+	conf := &util.AppConfig{}
+	conf.Conf.SslDomain = "local.example.com"
+	note := &domain.Note{
+		Id:        uuid.New(),
+		CreatedBy: account.Username,
+		Message:   "Hello, everyone!",
+		CreatedAt: time.Now(),
+	}
+
+	if err := SendCreateWithDeps(note, account, conf, mockDB); err != nil {
+		t.Fatalf("SendCreateWithDeps failed: %v", err)
+	}
+
+	// 1 shared inbox for remote0 + 50 personal inboxes on remote1
+	if len(mockDB.DeliveryQueue) != 51 {
+		t.Errorf("Expected 51 delivery queue items, got %d", len(mockDB.DeliveryQueue))
+	}
+	if mockDB.EnqueueCalls != 1 {
+		t.Errorf("Expected 1 enqueue transaction, got %d", mockDB.EnqueueCalls)
 	}
 }
 
@@ -2185,6 +2601,63 @@ func TestSendCreateWithDeps_Hashtags(t *testing.T) {
 }
 
 // TestSendCreateWithDeps_NoHashtags tests that notes without hashtags don't have tag array
+func TestSendCreateWithDeps_ContentMap(t *testing.T) {
+	mockDB := NewMockDatabase()
+
+	keypair, _ := GenerateTestKeyPair()
+	account := CreateTestAccount("alice", keypair)
+	mockDB.AddAccount(account)
+
+	remoteActor := &domain.RemoteAccount{
+		Id:       uuid.New(),
+		Username: "bob",
+		Domain:   "remote.example.com",
+		ActorURI: "https://remote.example.com/users/bob",
+		InboxURI: "https://remote.example.com/users/bob/inbox",
+	}
+	mockDB.AddRemoteAccount(remoteActor)
+	mockDB.AddFollow(&domain.Follow{
+		Id:              uuid.New(),
+		AccountId:       remoteActor.Id,
+		TargetAccountId: account.Id,
+		Accepted:        true,
+		CreatedAt:       time.Now(),
+	})
+
+	conf := &util.AppConfig{}
+	conf.Conf.SslDomain = "local.example.com"
+
+	note := &domain.Note{
+		Id:        uuid.New(),
+		CreatedBy: account.Username,
+		Message:   "Hallo Welt",
+		CreatedAt: time.Now(),
+		Language:  "de",
+	}
+
+	if err := SendCreateWithDeps(note, account, conf, mockDB); err != nil {
+		t.Fatalf("SendCreateWithDeps failed: %v", err)
+	}
+	if len(mockDB.DeliveryQueue) == 0 {
+		t.Fatal("Expected a queued delivery")
+	}
+
+	for _, item := range mockDB.DeliveryQueue {
+		var activity map[string]any
+		if err := json.Unmarshal([]byte(item.ActivityJSON), &activity); err != nil {
+			t.Fatalf("Failed to parse activity JSON: %v", err)
+		}
+		obj := activity["object"].(map[string]any)
+		contentMap, ok := obj["contentMap"].(map[string]any)
+		if !ok {
+			t.Fatalf("Expected contentMap on the note, got %v", obj)
+		}
+		if contentMap["de"] != obj["content"] {
+			t.Errorf("Expected contentMap[de] to equal content, got %v", contentMap)
+		}
+	}
+}
+
 func TestSendCreateWithDeps_NoHashtags(t *testing.T) {
 	mockDB := NewMockDatabase()
 
@@ -2684,3 +3157,306 @@ func TestSendUndoLikeWithDeps_RemoteNote(t *testing.T) {
 		t.Error("Expected Content-Type header to be set")
 	}
 }
+
+func TestResolveMentionWithDeps_WebFingerAndCache(t *testing.T) {
+	mockDB := NewMockDatabase()
+	mockHTTP := NewMockHTTPClient()
+
+	actorURI := "https://remote.example.com/users/bob"
+	webfingerURL := "https://remote.example.com/.well-known/webfinger?resource=acct:bob@remote.example.com"
+	if err := mockHTTP.SetJSONResponse(webfingerURL, 200, map[string]any{
+		"subject": "acct:bob@remote.example.com",
+		"links": []map[string]string{
+			{"rel": "self", "type": "application/activity+json", "href": actorURI},
+		},
+	}); err != nil {
+		t.Fatalf("Failed to set webfinger response: %v", err)
+	}
+
+	actorResponse := ActorResponse{
+		ID:                actorURI,
+		Type:              "Person",
+		PreferredUsername: "bob",
+		Inbox:             actorURI + "/inbox",
+	}
+	actorResponse.PublicKey.PublicKeyPem = "-----BEGIN PUBLIC KEY-----\nMIIBIjAN...\n-----END PUBLIC KEY-----"
+	if err := mockHTTP.SetJSONResponse(actorURI, 200, actorResponse); err != nil {
+		t.Fatalf("Failed to set actor response: %v", err)
+	}
+
+	gotActor, gotInbox, err := ResolveMentionWithDeps("@bob@remote.example.com", mockHTTP, mockDB)
+	if err != nil {
+		t.Fatalf("ResolveMentionWithDeps failed: %v", err)
+	}
+	if gotActor != actorURI || gotInbox != actorURI+"/inbox" {
+		t.Errorf("Expected %s / %s/inbox, got %s / %s", actorURI, actorURI, gotActor, gotInbox)
+	}
+	if len(mockDB.RemoteAccounts) != 1 {
+		t.Errorf("Expected resolved account to be cached, got %d accounts", len(mockDB.RemoteAccounts))
+	}
+
+	// Second lookup is served from the cache without any HTTP requests
+	requests := len(mockHTTP.Requests)
+	if _, _, err := ResolveMentionWithDeps("bob@remote.example.com", mockHTTP, mockDB); err != nil {
+		t.Fatalf("Cached ResolveMentionWithDeps failed: %v", err)
+	}
+	if len(mockHTTP.Requests) != requests {
+		t.Errorf("Expected cached lookup to make no requests, got %d", len(mockHTTP.Requests)-requests)
+	}
+}
+
+func TestResolveMentionWithDeps_Errors(t *testing.T) {
+	mockDB := NewMockDatabase()
+	mockHTTP := NewMockHTTPClient()
+
+	for _, acct := range []string{"", "bob", "@bob@", "@@remote.example.com"} {
+		if _, _, err := ResolveMentionWithDeps(acct, mockHTTP, mockDB); err == nil {
+			t.Errorf("Expected error for invalid acct %q", acct)
+		}
+	}
+
+	// WebFinger 404 (mock default) is an error, not a panic
+	if _, _, err := ResolveMentionWithDeps("@ghost@remote.example.com", mockHTTP, mockDB); err == nil {
+		t.Errorf("Expected error for unresolvable mention")
+	}
+}
+
+func TestSendCreateAndUpdateWithDeps_LocalOnlyNotDelivered(t *testing.T) {
+	mockDB := NewMockDatabase()
+
+	keypair, _ := GenerateTestKeyPair()
+	account := CreateTestAccount("alice", keypair)
+	mockDB.AddAccount(account)
+
+	remoteActor := &domain.RemoteAccount{
+		Id:       uuid.New(),
+		Username: "bob",
+		Domain:   "remote1.example.com",
+		ActorURI: "https://remote1.example.com/users/bob",
+		InboxURI: "https://remote1.example.com/users/bob/inbox",
+	}
+	mockDB.AddRemoteAccount(remoteActor)
+	mockDB.AddFollow(&domain.Follow{
+		Id:              uuid.New(),
+		AccountId:       remoteActor.Id,
+		TargetAccountId: account.Id,
+		URI:             "https://remote1.example.com/follows/1",
+		Accepted:        true,
+		CreatedAt:       time.Now(),
+	})
+
+	conf := &util.AppConfig{}
+	conf.Conf.SslDomain = "local.example.com"
+
+	note := &domain.Note{
+		Id:         uuid.New(),
+		CreatedBy:  account.Username,
+		Message:    "Only for this instance",
+		CreatedAt:  time.Now(),
+		Visibility: domain.VisibilityLocal,
+	}
+
+	if err := SendCreateWithDeps(note, account, conf, mockDB); err != nil {
+		t.Errorf("SendCreateWithDeps failed: %v", err)
+	}
+	if err := SendUpdateWithDeps(note, account, conf, mockDB); err != nil {
+		t.Errorf("SendUpdateWithDeps failed: %v", err)
+	}
+	if len(mockDB.DeliveryQueue) != 0 {
+		t.Errorf("Expected local-only note not to be delivered, got %d queue items", len(mockDB.DeliveryQueue))
+	}
+}
+
+// sentRelayActivity returns the JSON body of the activity POSTed to a relay inbox
+func sentRelayActivity(t *testing.T, mockHTTP *MockHTTPClient, inboxURI string) map[string]any {
+	t.Helper()
+	for _, req := range mockHTTP.Requests {
+		if req.Method == "POST" && req.URL.String() == inboxURI {
+			body := make([]byte, req.ContentLength)
+			req.Body.Read(body)
+			var activity map[string]any
+			if err := json.Unmarshal(body, &activity); err != nil {
+				t.Fatalf("Failed to parse sent activity: %v", err)
+			}
+			return activity
+		}
+	}
+	t.Fatalf("Expected POST to %s", inboxURI)
+	return nil
+}
+
+func TestSubscribeToRelayWithDeps_FollowTarget(t *testing.T) {
+	tests := []struct {
+		name         string
+		relayType    string
+		actorURI     string
+		expectObject string
+	}{
+		{"mastodon follows Public", domain.RelayTypeMastodon, "https://relay.example.com/actor", "https://www.w3.org/ns/activitystreams#Public"},
+		{"litepub follows relay actor", domain.RelayTypeLitePub, "https://pleroma.example.com/relay", "https://pleroma.example.com/relay"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := NewMockDatabase()
+			mockHTTP := NewMockHTTPClient()
+
+			keypair, _ := GenerateTestKeyPair()
+			account := CreateTestAccount("admin", keypair)
+			mockDB.AddAccount(account)
+
+			inboxURI := tt.actorURI + "/inbox"
+			actorResponse := ActorResponse{ID: tt.actorURI, Type: "Application", Inbox: inboxURI}
+			actorResponse.PublicKey.PublicKeyPem = "-----BEGIN PUBLIC KEY-----\ntest\n-----END PUBLIC KEY-----"
+			mockHTTP.SetJSONResponse(tt.actorURI, 200, actorResponse)
+			mockHTTP.SetResponse(inboxURI, 202, nil)
+
+			conf := &util.AppConfig{}
+			conf.Conf.SslDomain = "local.example.com"
+
+			if err := SubscribeToRelayWithDeps(account, tt.actorURI, tt.relayType, conf, mockHTTP, mockDB); err != nil {
+				t.Fatalf("SubscribeToRelayWithDeps failed: %v", err)
+			}
+
+			relay := mockDB.RelaysByURI[tt.actorURI]
+			if relay == nil || relay.Type != tt.relayType || relay.Status != "pending" {
+				t.Fatalf("Expected pending %s relay to be stored, got %+v", tt.relayType, relay)
+			}
+
+			follow := sentRelayActivity(t, mockHTTP, inboxURI)
+			if follow["type"] != "Follow" || follow["id"] != relay.FollowURI || follow["object"] != tt.expectObject {
+				t.Errorf("Expected Follow of %s, got %v", tt.expectObject, follow)
+			}
+		})
+	}
+}
+
+func TestSubscribeToRelayWithDeps_UnknownType(t *testing.T) {
+	mockDB := NewMockDatabase()
+	mockHTTP := NewMockHTTPClient()
+	conf := &util.AppConfig{}
+
+	err := SubscribeToRelayWithDeps(&domain.Account{Username: "admin"}, "https://relay.example.com/actor", "activitypub", conf, mockHTTP, mockDB)
+	if err == nil {
+		t.Error("Expected error for unknown relay type")
+	}
+	if len(mockHTTP.Requests) != 0 || len(mockDB.Relays) != 0 {
+		t.Error("Expected nothing to be fetched or stored for unknown relay type")
+	}
+}
+
+func TestDetectRelayType(t *testing.T) {
+	tests := map[string]string{
+		"https://relay.fedi.buzz/tag/music":    domain.RelayTypeMastodon,
+		"https://relay.example.com/actor":      domain.RelayTypeMastodon,
+		"https://pleroma.example.com/relay":    domain.RelayTypeLitePub,
+		"https://akkoma.example.com/relay/":    domain.RelayTypeLitePub,
+		"https://relay.example.com/relay/tags": domain.RelayTypeMastodon,
+	}
+	for actorURI, expected := range tests {
+		if got := DetectRelayType(actorURI); got != expected {
+			t.Errorf("DetectRelayType(%s) = %s, want %s", actorURI, got, expected)
+		}
+	}
+}
+
+func TestSendRelayUnfollowWithDeps_LitePub(t *testing.T) {
+	mockHTTP := NewMockHTTPClient()
+	mockHTTP.SetResponse("https://pleroma.example.com/inbox", 202, nil)
+
+	keypair, _ := GenerateTestKeyPair()
+	account := CreateTestAccount("admin", keypair)
+
+	relay := &domain.Relay{
+		Id:        uuid.New(),
+		ActorURI:  "https://pleroma.example.com/relay",
+		InboxURI:  "https://pleroma.example.com/inbox",
+		FollowURI: "https://local.example.com/activities/relay-follow",
+		Type:      domain.RelayTypeLitePub,
+	}
+	conf := &util.AppConfig{}
+	conf.Conf.SslDomain = "local.example.com"
+
+	if err := SendRelayUnfollowWithDeps(account, relay, conf, mockHTTP); err != nil {
+		t.Fatalf("SendRelayUnfollowWithDeps failed: %v", err)
+	}
+
+	undo := sentRelayActivity(t, mockHTTP, relay.InboxURI)
+	follow, _ := undo["object"].(map[string]any)
+	if follow["id"] != relay.FollowURI || follow["object"] != relay.ActorURI {
+		t.Errorf("Expected Undo of the Follow of the relay actor, got %v", undo["object"])
+	}
+}
+
+// addPendingFollow stores a follow request from remoteActor to localAccount that awaits approval
+func addPendingFollow(mockDB *MockDatabase, localAccount *domain.Account, remoteActor *domain.RemoteAccount) *domain.Follow {
+	follow := &domain.Follow{
+		Id:              uuid.New(),
+		AccountId:       remoteActor.Id,
+		TargetAccountId: localAccount.Id,
+		URI:             "https://remote.example.com/activities/follow-pending",
+		Accepted:        false,
+		CreatedAt:       time.Now(),
+	}
+	mockDB.AddFollow(follow)
+	return follow
+}
+
+func TestAcceptPendingFollowWithDeps(t *testing.T) {
+	mockDB, mockHTTP, _, localAccount, remoteActor, conf := setupFollowTest(t)
+	follow := addPendingFollow(mockDB, localAccount, remoteActor)
+
+	if err := AcceptPendingFollowWithDeps(localAccount, follow.URI, conf, mockDB, mockHTTP); err != nil {
+		t.Fatalf("AcceptPendingFollowWithDeps failed: %v", err)
+	}
+
+	if !mockDB.FollowsByURI[follow.URI].Accepted {
+		t.Error("Expected follow to be accepted")
+	}
+	if len(mockHTTP.Requests) != 1 {
+		t.Fatalf("Expected 1 HTTP request (Accept), got %d", len(mockHTTP.Requests))
+	}
+	if got := sentActivityType(t, mockHTTP.Requests[0]); got != "Accept" {
+		t.Errorf("Expected an Accept to be sent, got %s", got)
+	}
+	if len(mockDB.Notifications) != 1 || mockDB.Notifications[0].NotificationType != domain.NotificationFollow {
+		t.Error("Expected a follow notification after approval")
+	}
+
+	// An accepted follow can't be approved twice
+	if err := AcceptPendingFollowWithDeps(localAccount, follow.URI, conf, mockDB, mockHTTP); err == nil {
+		t.Error("Expected an error when accepting an already accepted follow")
+	}
+}
+
+func TestRejectPendingFollowWithDeps(t *testing.T) {
+	mockDB, mockHTTP, _, localAccount, remoteActor, conf := setupFollowTest(t)
+	follow := addPendingFollow(mockDB, localAccount, remoteActor)
+
+	if err := RejectPendingFollowWithDeps(localAccount, follow.URI, conf, mockDB, mockHTTP); err != nil {
+		t.Fatalf("RejectPendingFollowWithDeps failed: %v", err)
+	}
+
+	if len(mockDB.Follows) != 0 {
+		t.Errorf("Expected rejected follow to be deleted, got %d follows", len(mockDB.Follows))
+	}
+	if len(mockHTTP.Requests) != 1 {
+		t.Fatalf("Expected 1 HTTP request (Reject), got %d", len(mockHTTP.Requests))
+	}
+	if got := sentActivityType(t, mockHTTP.Requests[0]); got != "Reject" {
+		t.Errorf("Expected a Reject to be sent, got %s", got)
+	}
+}
+
+func TestRejectPendingFollowWithDeps_OtherAccount(t *testing.T) {
+	mockDB, mockHTTP, _, localAccount, remoteActor, conf := setupFollowTest(t)
+	follow := addPendingFollow(mockDB, localAccount, remoteActor)
+
+	other := &domain.Account{Id: uuid.New(), Username: "mallory"}
+	if err := RejectPendingFollowWithDeps(other, follow.URI, conf, mockDB, mockHTTP); err == nil {
+		t.Error("Expected an error when rejecting another account's follow request")
+	}
+	if len(mockDB.Follows) != 1 {
+		t.Error("Follow request of another account should be kept")
+	}
+}
@@ -0,0 +1,63 @@
+package activitypub
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/deemkeen/stegodon/domain"
+	"github.com/deemkeen/stegodon/util"
+)
+
+// relayFilterRejection checks a relay-forwarded post against the relay's filter and returns
+// why it is dropped, or "" if it may be stored
+func relayFilterRejection(filter domain.RelayFilter, actorURI string, object map[string]any) string {
+	if filter.IsEmpty() {
+		return ""
+	}
+
+	if parsed, err := url.Parse(actorURI); err == nil {
+		host := strings.ToLower(parsed.Hostname())
+		for _, blocked := range filter.BlockedDomains {
+			if host == blocked || strings.HasSuffix(host, "."+blocked) {
+				return fmt.Sprintf("author domain %s is blocked", host)
+			}
+		}
+	}
+
+	content, _ := object["content"].(string)
+	summary, _ := object["summary"].(string)
+	text := strings.ToLower(util.StripHTMLTags(summary + " " + content))
+	for _, word := range filter.MutedWords {
+		if strings.Contains(text, word) {
+			return fmt.Sprintf("contains muted word %q", word)
+		}
+	}
+
+	if len(filter.RequiredTags) > 0 {
+		tags := objectHashtags(object, text)
+		if !slices.ContainsFunc(filter.RequiredTags, func(tag string) bool { return slices.Contains(tags, tag) }) {
+			return "has none of the required hashtags"
+		}
+	}
+	return ""
+}
+
+// objectHashtags returns the lowercased hashtags of an object, from its Hashtag tags
+// and from the text for servers that don't send them
+func objectHashtags(object map[string]any, text string) []string {
+	var names []string
+	if tags, ok := object["tag"].([]any); ok {
+		for _, t := range tags {
+			tag, ok := t.(map[string]any)
+			if !ok || tag["type"] != "Hashtag" {
+				continue
+			}
+			if name, ok := tag["name"].(string); ok {
+				names = append(names, "#"+strings.TrimPrefix(name, "#"))
+			}
+		}
+	}
+	return util.ParseHashtags(strings.Join(names, " ") + " " + text)
+}
@@ -0,0 +1,44 @@
+package activitypub
+
+import (
+	"testing"
+
+	"github.com/deemkeen/stegodon/domain"
+)
+
+func TestRelayFilterRejection(t *testing.T) {
+	note := map[string]any{
+		"type":    "Note",
+		"content": "<p>New print in the <a href=\"https://art.example/tags/shop\">#Shop</a> today</p>",
+		"tag": []any{
+			map[string]any{"type": "Hashtag", "name": "#PrintMaking"},
+			map[string]any{"type": "Mention", "name": "@alice@example.com"},
+		},
+	}
+	author := "https://art.example/users/printer"
+
+	tests := []struct {
+		name     string
+		filter   domain.RelayFilter
+		rejected bool
+	}{
+		{"empty filter", domain.RelayFilter{}, false},
+		{"blocked domain", domain.RelayFilter{BlockedDomains: []string{"art.example"}}, true},
+		{"blocked parent domain", domain.RelayFilter{BlockedDomains: []string{"example"}}, true},
+		{"other blocked domain", domain.RelayFilter{BlockedDomains: []string{"spam.example"}}, false},
+		{"muted word", domain.RelayFilter{MutedWords: []string{"new print"}}, true},
+		{"muted word in markup only", domain.RelayFilter{MutedWords: []string{"href"}}, false},
+		{"required tag from tag array", domain.RelayFilter{RequiredTags: []string{"printmaking"}}, false},
+		{"required tag from content", domain.RelayFilter{RequiredTags: []string{"music", "shop"}}, false},
+		{"missing required tag", domain.RelayFilter{RequiredTags: []string{"music"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason := relayFilterRejection(tt.filter, author, note)
+			if (reason != "") != tt.rejected {
+				t.Errorf("Expected rejected=%v, got reason %q", tt.rejected, reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package activitypub
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/deemkeen/stegodon/domain"
+	"github.com/deemkeen/stegodon/util"
+)
+
+// relayScheduleInterval is how often relays are paused or resumed by their schedules
+const relayScheduleInterval = time.Minute
+
+// PauseSchedule is a relay's daily quiet hours, from Start to End in its timezone. Windows
+// whose end is before their start run past midnight, e.g. 22:00-06:00.
+type PauseSchedule struct {
+	Start    time.Duration // Time of day the window starts
+	End      time.Duration // Time of day the window ends
+	Location *time.Location
+}
+
+// ParsePauseSchedule parses quiet hours written as "22:00-06:00", optionally followed by an
+// IANA timezone like "Europe/Berlin". Without a timezone the hours are UTC, like stored
+// timestamps. An empty schedule returns nil.
+func ParsePauseSchedule(schedule string) (*PauseSchedule, error) {
+	fields := strings.Fields(schedule)
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	if len(fields) > 2 {
+		return nil, fmt.Errorf("expected hours like 22:00-06:00 and an optional timezone, got %q", schedule)
+	}
+
+	from, to, ok := strings.Cut(fields[0], "-")
+	if !ok {
+		return nil, fmt.Errorf("expected hours like 22:00-06:00, got %q", fields[0])
+	}
+	start, err := parseTimeOfDay(from)
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseTimeOfDay(to)
+	if err != nil {
+		return nil, err
+	}
+	if start == end {
+		return nil, fmt.Errorf("quiet hours %s start and end at the same time", fields[0])
+	}
+
+	timezone := ""
+	if len(fields) == 2 {
+		timezone = fields[1]
+	}
+	location, err := util.LoadTimezone(timezone)
+	if err != nil {
+		return nil, err
+	}
+	return &PauseSchedule{Start: start, End: end, Location: location}, nil
+}
+
+// parseTimeOfDay parses a 24-hour time like 06:00 into the time since midnight
+func parseTimeOfDay(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Active reports whether the quiet hours are on at the given time
+func (s *PauseSchedule) Active(now time.Time) bool {
+	local := now.In(s.Location)
+	timeOfDay := time.Duration(local.Hour())*time.Hour + time.Duration(local.Minute())*time.Minute
+	if s.Start < s.End {
+		return timeOfDay >= s.Start && timeOfDay < s.End
+	}
+	return timeOfDay >= s.Start || timeOfDay < s.End
+}
+
+// String formats the schedule as it is parsed, leaving out the timezone when it is UTC
+func (s *PauseSchedule) String() string {
+	hours := formatTimeOfDay(s.Start) + "-" + formatTimeOfDay(s.End)
+	if s.Location == time.UTC {
+		return hours
+	}
+	return hours + " " + s.Location.String()
+}
+
+func formatTimeOfDay(d time.Duration) string {
+	return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+}
+
+// StartRelayScheduleWorker starts a background worker that pauses relays during their quiet
+// hours and resumes them afterwards.
+// Returns a stop function that can be called to gracefully stop the worker.
+func StartRelayScheduleWorker(conf *util.AppConfig) func() {
+	log.Println("Starting ActivityPub relay schedule worker...")
+
+	ticker := time.NewTicker(relayScheduleInterval)
+	stop := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				processRelaySchedulesWithDeps(&DeliveryDeps{Database: NewDBWrapper()}, time.Now())
+			case <-stop:
+				ticker.Stop()
+				log.Println("ActivityPub relay schedule worker stopped")
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+	}
+}
+
+// processRelaySchedulesWithDeps pauses active relays whose quiet hours began and resumes
+// those the schedule paused once the hours are over. A relay that was already paused when
+// its window began, or that was paused or resumed by hand during it, is left alone until
+// the window ends, so the schedule never undoes an admin's choice.
+// This version accepts dependencies and the current time for testing.
+func processRelaySchedulesWithDeps(deps *DeliveryDeps, now time.Time) {
+	database := deps.Database
+
+	relays, err := database.ReadActiveRelays()
+	if err != nil {
+		log.Printf("RelaySchedule: Failed to read active relays: %v", err)
+		return
+	}
+	if relays == nil {
+		return
+	}
+
+	for _, relay := range *relays {
+		schedule, err := ParsePauseSchedule(relay.PauseSchedule)
+		if err != nil {
+			log.Printf("RelaySchedule: Ignoring invalid pause schedule of relay %s: %v", relay.ActorURI, err)
+		}
+		// Removing or breaking the schedule ends its window
+		inWindow := schedule != nil && schedule.Active(now)
+
+		switch {
+		case inWindow && relay.PauseScheduleState == domain.RelayScheduleIdle:
+			if relay.Paused {
+				err = database.UpdateRelayScheduledPause(relay.Id, true, domain.RelayScheduleSkipped)
+			} else {
+				log.Printf("RelaySchedule: Pausing relay %s for its quiet hours %s", relay.ActorURI, schedule)
+				err = database.UpdateRelayScheduledPause(relay.Id, true, domain.RelaySchedulePaused)
+			}
+		case !inWindow && relay.PauseScheduleState == domain.RelaySchedulePaused:
+			log.Printf("RelaySchedule: Resuming relay %s after its quiet hours", relay.ActorURI)
+			err = database.UpdateRelayScheduledPause(relay.Id, false, domain.RelayScheduleIdle)
+		case !inWindow && relay.PauseScheduleState == domain.RelayScheduleSkipped:
+			err = database.UpdateRelayScheduledPause(relay.Id, relay.Paused, domain.RelayScheduleIdle)
+		default:
+			continue
+		}
+		if err != nil {
+			log.Printf("RelaySchedule: Failed to update relay %s: %v", relay.ActorURI, err)
+		}
+	}
+}
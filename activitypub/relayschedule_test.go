@@ -0,0 +1,151 @@
+package activitypub
+
+import (
+	"testing"
+	"time"
+
+	"github.com/deemkeen/stegodon/domain"
+	"github.com/google/uuid"
+)
+
+func TestParsePauseSchedule(t *testing.T) {
+	tests := []struct {
+		schedule string
+		want     string // formatted schedule, "" for none
+		wantErr  bool
+	}{
+		{"", "", false},
+		{"   ", "", false},
+		{"22:00-06:00", "22:00-06:00", false},
+		{" 01:30-04:15 UTC ", "01:30-04:15", false},
+		{"22:00-06:00 Europe/Berlin", "22:00-06:00 Europe/Berlin", false},
+		{"22:00", "", true},
+		{"22:00-25:00", "", true},
+		{"6-8", "", true},
+		{"22:00-22:00", "", true},
+		{"22:00-06:00 Mars/Olympus", "", true},
+		{"22:00-06:00 Local", "", true},
+		{"22:00 - 06:00", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.schedule, func(t *testing.T) {
+			schedule, err := ParsePauseSchedule(tt.schedule)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected an error, got %v", schedule)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			got := ""
+			if schedule != nil {
+				got = schedule.String()
+			}
+			if got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestPauseScheduleActive(t *testing.T) {
+	overnight, _ := ParsePauseSchedule("22:00-06:00")
+	daytime, _ := ParsePauseSchedule("09:00-17:30")
+	berlin, _ := ParsePauseSchedule("22:00-06:00 Europe/Berlin")
+	day := time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		schedule *PauseSchedule
+		at       time.Duration
+		want     bool
+	}{
+		{"overnight before start", overnight, 21*time.Hour + 59*time.Minute, false},
+		{"overnight at start", overnight, 22 * time.Hour, true},
+		{"overnight after midnight", overnight, 3 * time.Hour, true},
+		{"overnight at end", overnight, 6 * time.Hour, false},
+		{"daytime inside", daytime, 12 * time.Hour, true},
+		{"daytime at end", daytime, 17*time.Hour + 30*time.Minute, false},
+		{"daytime at night", daytime, 23 * time.Hour, false},
+		// 21:30 UTC is 22:30 in Berlin in winter; 05:30 UTC is 06:30 there
+		{"timezone inside", berlin, 21*time.Hour + 30*time.Minute, true},
+		{"timezone after end", berlin, 5*time.Hour + 30*time.Minute, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.schedule.Active(day.Add(tt.at)); got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+// newScheduledRelay adds an active relay with the given quiet hours
+func newScheduledRelay(mockDB *MockDatabase, schedule string, paused bool, state string) *domain.Relay {
+	relay := &domain.Relay{
+		Id:                 uuid.New(),
+		ActorURI:           "https://relay.example.com/actor-" + uuid.NewString(),
+		InboxURI:           "https://relay.example.com/inbox",
+		Status:             "active",
+		Paused:             paused,
+		PauseSchedule:      schedule,
+		PauseScheduleState: state,
+	}
+	mockDB.CreateRelay(relay)
+	return relay
+}
+
+func TestProcessRelaySchedules(t *testing.T) {
+	night := time.Date(2026, 1, 15, 23, 0, 0, 0, time.UTC)
+	morning := time.Date(2026, 1, 16, 7, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		schedule   string
+		paused     bool
+		state      string
+		now        time.Time
+		wantPaused bool
+		wantState  string
+	}{
+		{"window begins", "22:00-06:00", false, domain.RelayScheduleIdle, night, true, domain.RelaySchedulePaused},
+		{"window ends", "22:00-06:00", true, domain.RelaySchedulePaused, morning, false, domain.RelayScheduleIdle},
+		{"already paused by hand", "22:00-06:00", true, domain.RelayScheduleIdle, night, true, domain.RelayScheduleSkipped},
+		{"manual pause outlives the window", "22:00-06:00", true, domain.RelayScheduleSkipped, morning, true, domain.RelayScheduleIdle},
+		{"resumed by hand during the window", "22:00-06:00", false, domain.RelayScheduleSkipped, night, false, domain.RelayScheduleSkipped},
+		{"outside the window", "22:00-06:00", false, domain.RelayScheduleIdle, morning, false, domain.RelayScheduleIdle},
+		{"no schedule", "", false, domain.RelayScheduleIdle, night, false, domain.RelayScheduleIdle},
+		{"schedule removed while paused", "", true, domain.RelaySchedulePaused, night, false, domain.RelayScheduleIdle},
+		{"invalid schedule", "late", false, domain.RelayScheduleIdle, night, false, domain.RelayScheduleIdle},
+		{"schedule timezone", "22:00-06:00 America/New_York", false, domain.RelayScheduleIdle, night, false, domain.RelayScheduleIdle},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDB := NewMockDatabase()
+			relay := newScheduledRelay(mockDB, tt.schedule, tt.paused, tt.state)
+
+			processRelaySchedulesWithDeps(&DeliveryDeps{Database: mockDB}, tt.now)
+
+			if relay.Paused != tt.wantPaused || relay.PauseScheduleState != tt.wantState {
+				t.Errorf("Expected paused=%v state=%q, got paused=%v state=%q", tt.wantPaused, tt.wantState, relay.Paused, relay.PauseScheduleState)
+			}
+		})
+	}
+}
+
+func TestProcessRelaySchedules_IgnoresInactiveRelays(t *testing.T) {
+	mockDB := NewMockDatabase()
+	relay := newScheduledRelay(mockDB, "22:00-06:00", false, domain.RelayScheduleIdle)
+	relay.Status = "pending"
+
+	processRelaySchedulesWithDeps(&DeliveryDeps{Database: mockDB}, time.Date(2026, 1, 15, 23, 0, 0, 0, time.UTC))
+
+	if relay.Paused {
+		t.Error("Expected a pending relay not to be paused by its schedule")
+	}
+}
@@ -0,0 +1,114 @@
+package activitypub
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/deemkeen/stegodon/util"
+	"github.com/google/uuid"
+)
+
+const (
+	// relayFollowTimeout is how long a relay Follow may stay unanswered before it is sent again
+	relayFollowTimeout = 30 * time.Minute
+	// MaxRelayFollowAttempts is how many Follows are sent to a relay before it is marked failed
+	MaxRelayFollowAttempts = 5
+	// defaultRelayStaleHours is how long an active relay may go without delivering before it is stale
+	defaultRelayStaleHours = 24
+)
+
+// RelayStaleWindow returns how long an active relay may deliver nothing before it is considered stale
+func RelayStaleWindow(conf *util.AppConfig) time.Duration {
+	if conf != nil && conf.Conf.RelayStaleHours > 0 {
+		return time.Duration(conf.Conf.RelayStaleHours) * time.Hour
+	}
+	return defaultRelayStaleHours * time.Hour
+}
+
+// StartRelayWorker starts a background worker that re-sends Follows to relays that have not
+// accepted them and marks relays failed after MaxRelayFollowAttempts.
+// Returns a stop function that can be called to gracefully stop the worker.
+func StartRelayWorker(conf *util.AppConfig) func() {
+	log.Println("Starting ActivityPub relay worker...")
+
+	ticker := time.NewTicker(5 * time.Minute)
+	stop := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				processPendingRelays(conf)
+			case <-stop:
+				ticker.Stop()
+				log.Println("ActivityPub relay worker stopped")
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+	}
+}
+
+// processPendingRelays retries pending relay subscriptions.
+// This is the production wrapper that uses the default database and HTTP client.
+func processPendingRelays(conf *util.AppConfig) {
+	deps := &DeliveryDeps{
+		Database:   NewDBWrapper(),
+		HTTPClient: defaultHTTPClient,
+	}
+	processPendingRelaysWithDeps(conf, deps, time.Now())
+}
+
+// processPendingRelaysWithDeps retries pending relay subscriptions whose Follow timed out.
+// This version accepts dependencies and the current time for testing.
+func processPendingRelaysWithDeps(conf *util.AppConfig, deps *DeliveryDeps, now time.Time) {
+	database := deps.Database
+
+	relays, err := database.ReadPendingRelays()
+	if err != nil {
+		log.Printf("RelayWorker: Failed to read pending relays: %v", err)
+		return
+	}
+	if relays == nil {
+		return
+	}
+
+	for _, relay := range *relays {
+		if now.Sub(relay.LastFollowAt) < relayFollowTimeout {
+			continue
+		}
+
+		if relay.FollowAttempts >= MaxRelayFollowAttempts {
+			log.Printf("RelayWorker: Relay %s did not accept after %d Follows, marking failed", relay.ActorURI, relay.FollowAttempts)
+			database.UpdateRelayStatus(relay.Id, "failed", nil)
+			continue
+		}
+
+		// Relays subscribed before the account was recorded cannot be re-signed
+		account, err := database.ReadAccById(relay.AccountId)
+		if relay.AccountId == uuid.Nil || err != nil || account == nil {
+			log.Printf("RelayWorker: No local account to re-send Follow to relay %s, marking failed", relay.ActorURI)
+			database.UpdateRelayStatus(relay.Id, "failed", nil)
+			continue
+		}
+
+		followID := fmt.Sprintf("https://%s/activities/%s", conf.Conf.SslDomain, uuid.New().String())
+		follow := newRelayFollow(followID, account.Username, relayFollowObject(relay.Type, relay.ActorURI), conf)
+		attempts := relay.FollowAttempts + 1
+
+		// Record the attempt first so an Accept for the new Follow can be matched
+		if err := database.UpdateRelayFollowAttempt(relay.Id, followID, attempts, now); err != nil {
+			log.Printf("RelayWorker: Failed to record Follow attempt for relay %s: %v", relay.ActorURI, err)
+			continue
+		}
+
+		log.Printf("RelayWorker: Re-sending Follow to relay %s (attempt %d/%d)", relay.ActorURI, attempts, MaxRelayFollowAttempts)
+		if err := SendActivityWithDeps(follow, relay.InboxURI, account, conf, deps.HTTPClient); err != nil {
+			log.Printf("RelayWorker: Failed to re-send Follow to relay %s: %v", relay.ActorURI, err)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package activitypub
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/deemkeen/stegodon/domain"
+	"github.com/deemkeen/stegodon/util"
+	"github.com/google/uuid"
+)
+
+// newPendingRelay adds a pending relay subscription whose last Follow was sent at lastFollowAt
+func newPendingRelay(mockDB *MockDatabase, accountId uuid.UUID, attempts int, lastFollowAt time.Time) *domain.Relay {
+	relay := &domain.Relay{
+		Id:             uuid.New(),
+		ActorURI:       "https://relay.example.com/actor",
+		InboxURI:       "https://relay.example.com/inbox",
+		FollowURI:      "https://local.example.com/activities/first-follow",
+		Status:         "pending",
+		CreatedAt:      lastFollowAt,
+		AccountId:      accountId,
+		FollowAttempts: attempts,
+		LastFollowAt:   lastFollowAt,
+	}
+	mockDB.CreateRelay(relay)
+	return relay
+}
+
+func newRelayWorkerTest(t *testing.T) (*MockDatabase, *MockHTTPClient, *domain.Account, *util.AppConfig) {
+	t.Helper()
+	mockDB := NewMockDatabase()
+	mockHTTP := NewMockHTTPClient()
+	mockHTTP.SetResponse("https://relay.example.com/inbox", 202, nil)
+
+	keypair, _ := GenerateTestKeyPair()
+	account := CreateTestAccount("admin", keypair)
+	mockDB.AddAccount(account)
+
+	conf := &util.AppConfig{}
+	conf.Conf.SslDomain = "local.example.com"
+	return mockDB, mockHTTP, account, conf
+}
+
+func TestProcessPendingRelays_ResendsTimedOutFollow(t *testing.T) {
+	mockDB, mockHTTP, account, conf := newRelayWorkerTest(t)
+	now := time.Now()
+	relay := newPendingRelay(mockDB, account.Id, 1, now.Add(-relayFollowTimeout-time.Minute))
+
+	processPendingRelaysWithDeps(conf, &DeliveryDeps{Database: mockDB, HTTPClient: mockHTTP}, now)
+
+	if len(mockHTTP.Requests) != 1 {
+		t.Fatalf("Expected Follow to be re-sent, got %d requests", len(mockHTTP.Requests))
+	}
+	if relay.FollowAttempts != 2 {
+		t.Errorf("Expected 2 Follow attempts, got %d", relay.FollowAttempts)
+	}
+	if !relay.LastFollowAt.Equal(now) {
+		t.Errorf("Expected last Follow time to be updated")
+	}
+	if relay.FollowURI == "https://local.example.com/activities/first-follow" {
+		t.Error("Expected the re-sent Follow to get a new URI")
+	}
+
+	var follow map[string]any
+	body := make([]byte, mockHTTP.Requests[0].ContentLength)
+	mockHTTP.Requests[0].Body.Read(body)
+	if err := json.Unmarshal(body, &follow); err != nil {
+		t.Fatalf("Failed to parse sent Follow: %v", err)
+	}
+	if follow["id"] != relay.FollowURI || follow["object"] != "https://www.w3.org/ns/activitystreams#Public" {
+		t.Errorf("Unexpected Follow sent: %v", follow)
+	}
+	if relay.Status != "pending" {
+		t.Errorf("Expected relay to stay pending, got %s", relay.Status)
+	}
+}
+
+func TestProcessPendingRelays_WaitsForTimeout(t *testing.T) {
+	mockDB, mockHTTP, account, conf := newRelayWorkerTest(t)
+	now := time.Now()
+	relay := newPendingRelay(mockDB, account.Id, 1, now.Add(-time.Minute))
+
+	processPendingRelaysWithDeps(conf, &DeliveryDeps{Database: mockDB, HTTPClient: mockHTTP}, now)
+
+	if len(mockHTTP.Requests) != 0 {
+		t.Errorf("Expected no Follow before the timeout, got %d requests", len(mockHTTP.Requests))
+	}
+	if relay.FollowAttempts != 1 {
+		t.Errorf("Expected attempts unchanged, got %d", relay.FollowAttempts)
+	}
+}
+
+func TestProcessPendingRelays_MarksFailedAfterMaxAttempts(t *testing.T) {
+	mockDB, mockHTTP, account, conf := newRelayWorkerTest(t)
+	now := time.Now()
+	relay := newPendingRelay(mockDB, account.Id, MaxRelayFollowAttempts, now.Add(-relayFollowTimeout-time.Minute))
+
+	processPendingRelaysWithDeps(conf, &DeliveryDeps{Database: mockDB, HTTPClient: mockHTTP}, now)
+
+	if relay.Status != "failed" {
+		t.Errorf("Expected relay to be failed after %d attempts, got %s", MaxRelayFollowAttempts, relay.Status)
+	}
+	if len(mockHTTP.Requests) != 0 {
+		t.Errorf("Expected no further Follow, got %d requests", len(mockHTTP.Requests))
+	}
+}
+
+func TestProcessPendingRelays_NoAccountMarksFailed(t *testing.T) {
+	mockDB, mockHTTP, _, conf := newRelayWorkerTest(t)
+	now := time.Now()
+	relay := newPendingRelay(mockDB, uuid.Nil, 1, now.Add(-relayFollowTimeout-time.Minute))
+
+	processPendingRelaysWithDeps(conf, &DeliveryDeps{Database: mockDB, HTTPClient: mockHTTP}, now)
+
+	if relay.Status != "failed" {
+		t.Errorf("Expected relay without a local account to be failed, got %s", relay.Status)
+	}
+}
+
+func TestRelayStaleWindow(t *testing.T) {
+	conf := &util.AppConfig{}
+	if got := RelayStaleWindow(conf); got != 24*time.Hour {
+		t.Errorf("Expected default window of 24h, got %v", got)
+	}
+
+	conf.Conf.RelayStaleHours = 6
+	if got := RelayStaleWindow(conf); got != 6*time.Hour {
+		t.Errorf("Expected configured window of 6h, got %v", got)
+	}
+}
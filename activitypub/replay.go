@@ -0,0 +1,146 @@
+package activitypub
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/deemkeen/stegodon/util"
+)
+
+const (
+	// defaultClockSkew is how far a signed request's dates may be from our clock
+	defaultClockSkew = 5 * time.Minute
+	// defaultReplayCacheTTL is how long a delivered activity id is remembered
+	defaultReplayCacheTTL = time.Hour
+	// defaultReplayCacheSize bounds the number of remembered deliveries (LRU)
+	defaultReplayCacheSize = 10000
+)
+
+var (
+	// ErrMissingDate is returned when a request has neither a Date header nor a created parameter
+	ErrMissingDate = errors.New("missing Date header")
+	// ErrStaleDate is returned when a request's date is outside the allowed skew window
+	ErrStaleDate = errors.New("request date outside the allowed window")
+	// ErrSignatureExpired is returned when a signature's expires parameter has passed
+	ErrSignatureExpired = errors.New("signature expired")
+	// ErrUnsignedDate is returned when the signature doesn't cover the date a request is checked by
+	ErrUnsignedDate = errors.New("signature does not cover the request date")
+)
+
+// clockSkew is the tolerance for the dates of signed requests: how far the Date or created
+// time may be from now, and how long after its expires time a signature is still accepted.
+// Remote clocks drift, so it must not be too tight (<= 0 disables the checks).
+var clockSkew = defaultClockSkew
+
+// seenDeliveries remembers the signature each recently delivered activity arrived with, keyed
+// by inbox and activity id, so that a captured request replayed verbatim can be rejected
+var seenDeliveries = newSignatureCache(defaultReplayCacheSize, defaultReplayCacheTTL)
+
+// requestDate returns when a signed request was made: the signature's created parameter
+// if present, otherwise the Date header. Either must be signed, as (created) or date, or a
+// captured request could be replayed with a fresh date.
+func requestDate(req *http.Request, params *signatureParams) (time.Time, error) {
+	// Without a headers parameter only the Date header is signed
+	signed := []string{"date"}
+	if params != nil && params.Headers != nil {
+		signed = params.Headers
+	}
+
+	if params != nil && params.Created != "" {
+		if !slices.Contains(signed, "(created)") {
+			return time.Time{}, ErrUnsignedDate
+		}
+		created, err := strconv.ParseInt(params.Created, 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid created parameter: %w", err)
+		}
+		return time.Unix(created, 0), nil
+	}
+
+	date := req.Header.Get("Date")
+	if date == "" {
+		return time.Time{}, ErrMissingDate
+	}
+	if !slices.Contains(signed, "date") {
+		return time.Time{}, ErrUnsignedDate
+	}
+	t, err := http.ParseTime(date)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid Date header: %w", err)
+	}
+	return t, nil
+}
+
+// checkRequestDate rejects a request dated more than skew before or after now, and one
+// whose signature expired more than skew ago
+func checkRequestDate(req *http.Request, params *signatureParams, skew time.Duration, now time.Time) error {
+	if skew <= 0 {
+		return nil
+	}
+	date, err := requestDate(req, params)
+	if err != nil {
+		return err
+	}
+	if date.Before(now.Add(-skew)) || date.After(now.Add(skew)) {
+		return fmt.Errorf("%w: %s", ErrStaleDate, date.UTC().Format(http.TimeFormat))
+	}
+
+	if params != nil && params.Expires != "" {
+		expires, err := strconv.ParseInt(params.Expires, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid expires parameter: %w", err)
+		}
+		if now.Add(-skew).After(time.Unix(expires, 0)) {
+			return fmt.Errorf("%w: %s", ErrSignatureExpired, time.Unix(expires, 0).UTC().Format(http.TimeFormat))
+		}
+	}
+	return nil
+}
+
+// deliveryKey identifies an activity delivered to a specific inbox
+func deliveryKey(username, activityID string) string {
+	return username + " " + activityID
+}
+
+// isReplayedDelivery reports whether the activity was already delivered to this inbox with the
+// very same signature. A sender re-delivering an activity signs it again, so only a verbatim
+// copy of an earlier request matches.
+func isReplayedDelivery(username, activityID, signature string) bool {
+	if activityID == "" || signature == "" {
+		return false
+	}
+	seen, ok := seenDeliveries.get(deliveryKey(username, activityID))
+	return ok && seen == signature
+}
+
+// rememberDelivery records the signature an activity was delivered to this inbox with
+func rememberDelivery(username, activityID, signature string) {
+	if activityID == "" || signature == "" {
+		return
+	}
+	seenDeliveries.add(deliveryKey(username, activityID), signature)
+}
+
+// ConfigureReplayProtection applies the configured clock skew and replay cache TTL.
+// A value of 0 keeps the default; a negative value disables the check.
+func ConfigureReplayProtection(conf *util.AppConfig) {
+	skew := defaultClockSkew
+	if conf.Conf.ClockSkew > 0 {
+		skew = time.Duration(conf.Conf.ClockSkew) * time.Second
+	} else if conf.Conf.ClockSkew < 0 {
+		skew = 0
+	}
+	clockSkew = skew
+
+	ttl := defaultReplayCacheTTL
+	if conf.Conf.ReplayCacheTTL > 0 {
+		ttl = time.Duration(conf.Conf.ReplayCacheTTL) * time.Second
+	} else if conf.Conf.ReplayCacheTTL < 0 {
+		ttl = 0
+	}
+	seenDeliveries.setTTL(ttl)
+}
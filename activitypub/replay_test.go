@@ -0,0 +1,231 @@
+package activitypub
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// createSignedRequestAt signs an inbox request to alice with the given Date
+func createSignedRequestAt(t *testing.T, body []byte, keypair *TestKeyPair, keyID string, date time.Time) *http.Request {
+	t.Helper()
+
+	req := httptest.NewRequest("POST", "/users/alice/inbox", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/activity+json")
+	req.Header.Set("Date", date.UTC().Format(http.TimeFormat))
+	req.Header.Set("Host", req.Host)
+	req.Header.Set("Digest", calculateDigest(body))
+	if err := SignRequest(req, keypair.PrivateKey, keyID); err != nil {
+		t.Fatalf("Failed to sign request: %v", err)
+	}
+	return req
+}
+
+func TestCheckRequestDate(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	skew := 5 * time.Minute
+
+	dateSigned := []string{"(request-target)", "host", "date", "digest"}
+	createdSigned := []string{"(request-target)", "(created)", "digest"}
+
+	tests := []struct {
+		name    string
+		date    string
+		created string
+		headers []string
+		wantErr error
+	}{
+		{"current date", now.Format(http.TimeFormat), "", dateSigned, nil},
+		{"slightly behind", now.Add(-4 * time.Minute).Format(http.TimeFormat), "", dateSigned, nil},
+		{"slightly ahead", now.Add(4 * time.Minute).Format(http.TimeFormat), "", dateSigned, nil},
+		{"too old", now.Add(-10 * time.Minute).Format(http.TimeFormat), "", dateSigned, ErrStaleDate},
+		{"too far ahead", now.Add(10 * time.Minute).Format(http.TimeFormat), "", dateSigned, ErrStaleDate},
+		{"missing", "", "", dateSigned, ErrMissingDate},
+		{"date signed by default", now.Format(http.TimeFormat), "", nil, nil},
+		{"unsigned date", now.Format(http.TimeFormat), "", []string{"(request-target)", "host", "digest"}, ErrUnsignedDate},
+		{"created within window", "", strconv.FormatInt(now.Add(-time.Minute).Unix(), 10), createdSigned, nil},
+		{"created wins over Date", now.Add(-time.Hour).Format(http.TimeFormat), strconv.FormatInt(now.Unix(), 10), createdSigned, nil},
+		{"stale created", now.Format(http.TimeFormat), strconv.FormatInt(now.Add(-time.Hour).Unix(), 10), createdSigned, ErrStaleDate},
+		{"unsigned created", now.Format(http.TimeFormat), strconv.FormatInt(now.Unix(), 10), dateSigned, ErrUnsignedDate},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/users/alice/inbox", nil)
+			if tt.date != "" {
+				req.Header.Set("Date", tt.date)
+			}
+			params := &signatureParams{KeyId: "https://remote.example.com/users/bob#main-key", Signature: "abc", Headers: tt.headers, Created: tt.created}
+
+			err := checkRequestDate(req, params, skew, now)
+			if tt.wantErr == nil && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	t.Run("expires", func(t *testing.T) {
+		for _, tt := range []struct {
+			name    string
+			expires time.Time
+			wantErr error
+		}{
+			{"not yet expired", now.Add(time.Minute), nil},
+			{"expired within the skew", now.Add(-4 * time.Minute), nil},
+			{"expired", now.Add(-10 * time.Minute), ErrSignatureExpired},
+		} {
+			req := httptest.NewRequest("POST", "/users/alice/inbox", nil)
+			params := &signatureParams{Headers: []string{"(created)"}, Created: strconv.FormatInt(now.Unix(), 10), Expires: strconv.FormatInt(tt.expires.Unix(), 10)}
+			if err := checkRequestDate(req, params, skew, now); !errors.Is(err, tt.wantErr) {
+				t.Errorf("%s: expected %v, got %v", tt.name, tt.wantErr, err)
+			}
+		}
+	})
+
+	t.Run("invalid date", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/users/alice/inbox", nil)
+		req.Header.Set("Date", "yesterday")
+		if err := checkRequestDate(req, &signatureParams{}, skew, now); err == nil {
+			t.Error("Expected an error for an unparsable Date header")
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/users/alice/inbox", nil)
+		if err := checkRequestDate(req, &signatureParams{}, 0, now); err != nil {
+			t.Errorf("Expected no error with the check disabled, got %v", err)
+		}
+	})
+}
+
+func TestIsReplayedDelivery(t *testing.T) {
+	t.Cleanup(func() { seenDeliveries.setTTL(defaultReplayCacheTTL) })
+	seenDeliveries.setTTL(time.Minute)
+
+	activityID := "https://remote.example.com/activities/replay-1"
+	if isReplayedDelivery("alice", activityID, "sig-1") {
+		t.Fatal("Expected an unseen activity not to be a replay")
+	}
+
+	rememberDelivery("alice", activityID, "sig-1")
+	if !isReplayedDelivery("alice", activityID, "sig-1") {
+		t.Error("Expected the same activity with the same signature to be a replay")
+	}
+	if isReplayedDelivery("alice", activityID, "sig-2") {
+		t.Error("Expected a re-signed re-delivery not to be a replay")
+	}
+	if isReplayedDelivery("carol", activityID, "sig-1") {
+		t.Error("Expected a delivery to another inbox not to be a replay")
+	}
+
+	// Activities without an id can't be tracked
+	rememberDelivery("alice", "", "sig-1")
+	if isReplayedDelivery("alice", "", "sig-1") {
+		t.Error("Expected an activity without id never to be a replay")
+	}
+
+	// Disabling the cache forgets everything
+	seenDeliveries.setTTL(0)
+	rememberDelivery("alice", activityID, "sig-1")
+	if isReplayedDelivery("alice", activityID, "sig-1") {
+		t.Error("Expected no replay detection with the cache disabled")
+	}
+}
+
+// TestHandleInboxWithDeps_RejectsReplays tests that a verbatim copy of a processed request is
+// rejected, while a re-signed delivery of the same activity still gets a normal response
+func TestHandleInboxWithDeps_RejectsReplays(t *testing.T) {
+	t.Cleanup(func() { seenDeliveries.setTTL(defaultReplayCacheTTL) })
+	seenDeliveries.setTTL(time.Minute)
+
+	mockDB, _, deps, _, remoteActor, conf := setupFollowTest(t)
+	keypair, _ := GenerateTestKeyPair()
+	remoteActor.PublicKeyPem = keypair.PublicPEM
+	remoteActor.LastFetchedAt = time.Now()
+
+	body := []byte(`{"id": "https://remote.example.com/activities/follow-replay", "type": "Follow", "actor": "https://remote.example.com/users/bob", "object": "https://local.example.com/users/alice"}`)
+	keyID := "https://remote.example.com/users/bob#main-key"
+	send := func(req *http.Request) int {
+		rr := httptest.NewRecorder()
+		HandleInboxWithDeps(rr, req, "alice", conf, deps)
+		return rr.Code
+	}
+
+	original := createSignedRequest(t, "POST", "/users/alice/inbox", body, keypair, keyID)
+	replay := original.Clone(original.Context())
+	replay.Body = io.NopCloser(bytes.NewReader(body))
+
+	if code := send(original); code != http.StatusAccepted {
+		t.Fatalf("Expected status 202 for the first delivery, got %d", code)
+	}
+
+	if code := send(replay); code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 for the replayed request, got %d", code)
+	}
+
+	// The sender re-delivering the activity signs a new request
+	redelivery := createSignedRequestAt(t, body, keypair, keyID, time.Now().Add(-time.Minute))
+	if code := send(redelivery); code != http.StatusAccepted {
+		t.Errorf("Expected status 202 for the re-delivery, got %d", code)
+	}
+	if len(mockDB.Follows) != 1 {
+		t.Errorf("Expected 1 follow, got %d", len(mockDB.Follows))
+	}
+}
+
+// TestHandleInboxWithDeps_RejectsStaleDate tests that a request dated outside the window is rejected
+func TestHandleInboxWithDeps_RejectsStaleDate(t *testing.T) {
+	mockDB, _, deps, _, remoteActor, conf := setupFollowTest(t)
+	keypair, _ := GenerateTestKeyPair()
+	remoteActor.PublicKeyPem = keypair.PublicPEM
+	remoteActor.LastFetchedAt = time.Now()
+
+	body := []byte(`{"id": "https://remote.example.com/activities/follow-stale", "type": "Follow", "actor": "https://remote.example.com/users/bob", "object": "https://local.example.com/users/alice"}`)
+	req := createSignedRequestAt(t, body, keypair, "https://remote.example.com/users/bob#main-key", time.Now().Add(-2*defaultClockSkew))
+
+	rr := httptest.NewRecorder()
+	HandleInboxWithDeps(rr, req, "alice", conf, deps)
+
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 for a stale request, got %d", rr.Code)
+	}
+	if len(mockDB.Follows) != 0 || len(mockDB.Activities) != 0 {
+		t.Errorf("Expected nothing stored for a stale request, got %d follows and %d activities", len(mockDB.Follows), len(mockDB.Activities))
+	}
+}
+
+// TestHandleInboxWithDeps_ClockSkew tests that the clock skew of InboxDeps is used for the date check
+func TestHandleInboxWithDeps_ClockSkew(t *testing.T) {
+	mockDB, _, deps, _, remoteActor, conf := setupFollowTest(t)
+	keypair, _ := GenerateTestKeyPair()
+	remoteActor.PublicKeyPem = keypair.PublicPEM
+	remoteActor.LastFetchedAt = time.Now()
+
+	// Ten minutes behind is outside the default skew but within an hour
+	body := []byte(`{"id": "https://remote.example.com/activities/follow-drift", "type": "Follow", "actor": "https://remote.example.com/users/bob", "object": "https://local.example.com/users/alice"}`)
+	date := time.Now().Add(-10 * time.Minute)
+
+	rr := httptest.NewRecorder()
+	HandleInboxWithDeps(rr, createSignedRequestAt(t, body, keypair, "https://remote.example.com/users/bob#main-key", date), "alice", conf, deps)
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 with the default skew, got %d", rr.Code)
+	}
+
+	deps.ClockSkew = time.Hour
+	rr = httptest.NewRecorder()
+	HandleInboxWithDeps(rr, createSignedRequestAt(t, body, keypair, "https://remote.example.com/users/bob#main-key", date), "alice", conf, deps)
+	if rr.Code != http.StatusAccepted {
+		t.Errorf("Expected status 202 with a skew of an hour, got %d", rr.Code)
+	}
+	if len(mockDB.Follows) != 1 {
+		t.Errorf("Expected 1 follow, got %d", len(mockDB.Follows))
+	}
+}
@@ -0,0 +1,162 @@
+package activitypub
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/deemkeen/stegodon/util"
+)
+
+// HandleSharedInbox processes activities delivered to the instance's shared inbox
+func HandleSharedInbox(w http.ResponseWriter, r *http.Request, conf *util.AppConfig) {
+	deps := &InboxDeps{
+		Database:   NewDBWrapper(),
+		HTTPClient: defaultHTTPClient,
+	}
+	HandleSharedInboxWithDeps(w, r, conf, deps)
+}
+
+// HandleSharedInboxWithDeps processes activities delivered to the shared inbox by routing
+// them to the inbox of a local user they concern. The body is decoded once, before routing,
+// and handed on as is.
+// This version accepts dependencies for testing.
+func HandleSharedInboxWithDeps(w http.ResponseWriter, r *http.Request, conf *util.AppConfig, deps *InboxDeps) {
+	logger := deps.logger().With("component", "inbox", "inbox", "shared")
+
+	// The body is only signed through its digest, so nothing is read without a signed one
+	sigParams, _ := parseSignatureParams(r.Header.Get("Signature"))
+	if err := checkSignedDigest(r, sigParams); err != nil {
+		logger.Warn("Shared inbox: Digest not signed", "error", err, "status", http.StatusUnauthorized)
+		http.Error(w, "Invalid digest", http.StatusUnauthorized)
+		return
+	}
+
+	body, ok := readInboxBody(w, r, logger)
+	if !ok {
+		return
+	}
+
+	var activity map[string]any
+	if err := json.Unmarshal(body.decoded, &activity); err != nil {
+		logger.Warn("Shared inbox: Failed to parse activity", "error", err, "status", http.StatusBadRequest)
+		http.Error(w, "Invalid activity", http.StatusBadRequest)
+		return
+	}
+
+	username := sharedInboxRecipient(activity, conf, deps.Database, logger)
+	if username == "" {
+		logger.Info(fmt.Sprintf("Shared inbox: Could not determine target username from activity type %v", activity["type"]), "status", http.StatusAccepted)
+		w.WriteHeader(http.StatusAccepted) // Accept anyway to be nice
+		return
+	}
+
+	logger.Info(fmt.Sprintf("Shared inbox: Routing to user %s", username))
+	handleInboxWithDeps(w, r, username, body, conf, deps)
+}
+
+// sharedInboxRecipient picks the local user whose inbox handles an activity delivered to
+// the shared inbox: the first enabled user it's addressed to, the target of a Follow, a
+// user following its actor, or for relay content any enabled user. It returns "" if none.
+func sharedInboxRecipient(activity map[string]any, conf *util.AppConfig, database Database, logger *slog.Logger) string {
+	// Helper function to extract username from URI
+	extractUsername := func(uri string) string {
+		// Check if it's one of our users: https://domain/users/username
+		if strings.Contains(uri, conf.Conf.SslDomain) && strings.Contains(uri, "/users/") {
+			parts := strings.Split(uri, "/")
+			for i, part := range parts {
+				if part == "users" && i+1 < len(parts) {
+					// Extract just the username, handle /followers suffix
+					username := parts[i+1]
+					// Remove /followers or /following if present
+					if slashIdx := strings.Index(username, "/"); slashIdx > 0 {
+						username = username[:slashIdx]
+					}
+					return username
+				}
+			}
+		}
+		return ""
+	}
+
+	// Disabled accounts reject everything in their inbox, so addressing them doesn't route
+	extractEnabledUsername := func(uri string) string {
+		username := extractUsername(uri)
+		if username == "" {
+			return ""
+		}
+		if account, err := database.ReadAccByUsername(username); err == nil && account != nil && account.Disabled {
+			return ""
+		}
+		return username
+	}
+
+	// Try to find target in "to" field first, then in "cc" (followers collections)
+	for _, field := range []string{"to", "cc"} {
+		if addressees, ok := activity[field].([]any); ok {
+			for _, addressee := range addressees {
+				if uri, ok := addressee.(string); ok {
+					if username := extractEnabledUsername(uri); username != "" {
+						return username
+					}
+				}
+			}
+		}
+	}
+
+	// For Follow activities, check the object field
+	if objStr, ok := activity["object"].(string); ok {
+		if username := extractUsername(objStr); username != "" {
+			return username
+		}
+	}
+
+	// For Create/Update/Delete activities, find which local user(s) follow this actor
+	if actorURI, _ := activity["actor"].(string); actorURI != "" {
+		// Get the remote actor
+		remoteActor, err := database.ReadRemoteAccountByActorURI(actorURI)
+		if err == nil && remoteActor != nil {
+			// Find followers of this remote actor (local users who follow them)
+			followers, err := database.ReadFollowersByAccountId(remoteActor.Id)
+			if err == nil && followers != nil && len(*followers) > 0 {
+				// Get the first local user who follows this actor and isn't disabled
+				for _, follower := range *followers {
+					localAccount, err := database.ReadAccById(follower.AccountId)
+					if err == nil && localAccount != nil && !localAccount.Disabled {
+						logger.Info(fmt.Sprintf("Shared inbox: Routing to follower %s of %s", localAccount.Username, actorURI))
+						return localAccount.Username
+					}
+				}
+			} else {
+				logger.Info(fmt.Sprintf("Shared inbox: No local followers found for %s", actorURI))
+			}
+		} else {
+			logger.Info(fmt.Sprintf("Shared inbox: Remote actor %s not found in cache", actorURI))
+		}
+	}
+
+	// Check if this is relay content (activity from an active relay)
+	// Relays send to shared inbox, not individual user inboxes
+	activityType, _ := activity["type"].(string)
+	if activityType == "Create" || activityType == "Announce" {
+		// Check if we have any active relays - if so, process relay content
+		relays, err := database.ReadActiveRelays()
+		if err == nil && relays != nil && len(*relays) > 0 {
+			// Get any local user to process this (relay content is instance-wide)
+			accounts, err := database.ReadAllAccounts()
+			if err == nil && accounts != nil {
+				for _, account := range *accounts {
+					// Disabled accounts reject everything in their inbox
+					if !account.Disabled {
+						logger.Info(fmt.Sprintf("Shared inbox: Routing relay content to %s", account.Username))
+						return account.Username
+					}
+				}
+			}
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,168 @@
+package activitypub
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/deemkeen/stegodon/util"
+)
+
+const (
+	// defaultSignatureCacheTTL is how long a successful verification is remembered
+	defaultSignatureCacheTTL = 60 * time.Second
+	// defaultSignatureCacheSize bounds the number of remembered verifications (LRU)
+	defaultSignatureCacheSize = 1024
+)
+
+// verifiedSignatures remembers recently verified inbox signatures so that immediate
+// re-deliveries of an identical signed request skip the RSA verify
+var verifiedSignatures = newSignatureCache(defaultSignatureCacheSize, defaultSignatureCacheTTL)
+
+// signatureCache is a size-bounded LRU of successful signature verifications with a TTL.
+// Only successes are stored; a failed verification is never cached.
+type signatureCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	maxSize int
+	entries map[string]*list.Element
+	order   *list.List // front = most recently used
+	now     func() time.Time
+}
+
+type signatureCacheEntry struct {
+	key      string
+	actorURI string
+	expires  time.Time
+}
+
+func newSignatureCache(maxSize int, ttl time.Duration) *signatureCache {
+	return &signatureCache{
+		ttl:     ttl,
+		maxSize: maxSize,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+		now:     time.Now,
+	}
+}
+
+// get returns the actor URI of a cached, unexpired verification
+func (c *signatureCache) get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return "", false
+	}
+	entry := elem.Value.(*signatureCacheEntry)
+	if c.now().After(entry.expires) {
+		c.order.Remove(elem)
+		delete(c.entries, key)
+		return "", false
+	}
+	c.order.MoveToFront(elem)
+	return entry.actorURI, true
+}
+
+// add records a successful verification, evicting the least recently used entry when full
+func (c *signatureCache) add(key, actorURI string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ttl <= 0 || c.maxSize <= 0 {
+		return
+	}
+
+	expires := c.now().Add(c.ttl)
+	if elem, ok := c.entries[key]; ok {
+		entry := elem.Value.(*signatureCacheEntry)
+		entry.actorURI = actorURI
+		entry.expires = expires
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&signatureCacheEntry{key: key, actorURI: actorURI, expires: expires})
+	for c.order.Len() > c.maxSize {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*signatureCacheEntry).key)
+	}
+}
+
+// setTTL changes the TTL and drops all cached entries (ttl <= 0 disables caching)
+func (c *signatureCache) setTTL(ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.ttl = ttl
+	c.entries = make(map[string]*list.Element)
+	c.order.Init()
+}
+
+// signatureCacheKey hashes everything that makes a signed request identical: the keyId and
+// signature, the digest, where the request was sent, and the key it was verified with.
+// Returns "" if the request is not signed.
+func signatureCacheKey(req *http.Request, publicKeyPem string) string {
+	signature := req.Header.Get("Signature")
+	if signature == "" {
+		return ""
+	}
+
+	h := sha256.New()
+	for _, part := range []string{
+		extractKeyIdFromSignature(signature),
+		signature,
+		req.Header.Get("Digest"),
+		req.Method,
+		req.URL.RequestURI(),
+		req.Host,
+		publicKeyPem,
+	} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// VerifyRequestCached verifies the HTTP signature on an incoming request like VerifyRequest,
+// but skips the RSA verify for an identical request that was verified within the cache TTL
+func VerifyRequestCached(req *http.Request, publicKeyPem string) (string, error) {
+	return verifyRequestWithCache(req, publicKeyPem, verifiedSignatures, VerifyRequest)
+}
+
+// verifyRequestWithCache is VerifyRequestCached with the cache and verify function injected for testing
+func verifyRequestWithCache(req *http.Request, publicKeyPem string, cache *signatureCache, verify func(*http.Request, string) (string, error)) (string, error) {
+	key := signatureCacheKey(req, publicKeyPem)
+	if key != "" {
+		if actorURI, ok := cache.get(key); ok {
+			return actorURI, nil
+		}
+	}
+
+	actorURI, err := verify(req, publicKeyPem)
+	if err != nil {
+		return "", err
+	}
+
+	if key != "" {
+		cache.add(key, actorURI)
+	}
+	return actorURI, nil
+}
+
+// ConfigureSignatureCache applies the configured TTL to the inbox signature cache.
+// A TTL of 0 keeps the default; a negative TTL disables caching.
+func ConfigureSignatureCache(conf *util.AppConfig) {
+	ttl := defaultSignatureCacheTTL
+	if conf.Conf.SigCacheTTL > 0 {
+		ttl = time.Duration(conf.Conf.SigCacheTTL) * time.Second
+	} else if conf.Conf.SigCacheTTL < 0 {
+		ttl = 0
+	}
+	verifiedSignatures.setTTL(ttl)
+}
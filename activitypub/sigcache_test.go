@@ -0,0 +1,165 @@
+package activitypub
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// newSignedTestRequest returns a factory that produces copies of one signed inbox request
+func newSignedTestRequest(t testing.TB, url string) (func() *http.Request, string) {
+	privateKey, publicKey, err := generateTestKeyPair()
+	if err != nil {
+		t.Fatalf("Failed to generate key pair: %v", err)
+	}
+	publicPEM, err := publicKeyToPEM(publicKey)
+	if err != nil {
+		t.Fatalf("Failed to convert public key to PEM: %v", err)
+	}
+
+	body := []byte(`{"type":"Create"}`)
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/activity+json")
+	req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	req.Header.Set("Host", req.URL.Host)
+	req.Header.Set("Digest", calculateDigest(body))
+	if err := SignRequest(req, privateKey, "https://remote.example.com/users/bob#main-key"); err != nil {
+		t.Fatalf("SignRequest failed: %v", err)
+	}
+
+	newRequest := func() *http.Request {
+		r, _ := http.NewRequest("POST", url, bytes.NewReader(body))
+		r.Header = req.Header.Clone()
+		return r
+	}
+	return newRequest, publicPEM
+}
+
+// countingVerify wraps VerifyRequest and counts how often the RSA verify actually runs
+func countingVerify(calls *int) func(*http.Request, string) (string, error) {
+	return func(req *http.Request, publicKeyPem string) (string, error) {
+		*calls++
+		return VerifyRequest(req, publicKeyPem)
+	}
+}
+
+func TestVerifyRequestWithCache_SkipsDuplicateVerification(t *testing.T) {
+	newRequest, publicPEM := newSignedTestRequest(t, "https://local.example.com/users/alice/inbox")
+	cache := newSignatureCache(10, time.Minute)
+	calls := 0
+
+	for i := 0; i < 3; i++ {
+		actorURI, err := verifyRequestWithCache(newRequest(), publicPEM, cache, countingVerify(&calls))
+		if err != nil {
+			t.Fatalf("Verification %d failed: %v", i+1, err)
+		}
+		if actorURI != "https://remote.example.com/users/bob" {
+			t.Errorf("Expected actor URI of signer, got %s", actorURI)
+		}
+	}
+
+	if calls != 1 {
+		t.Errorf("Expected 1 RSA verify for 3 identical deliveries, got %d", calls)
+	}
+}
+
+func TestVerifyRequestWithCache_FailureNotCached(t *testing.T) {
+	newRequest, _ := newSignedTestRequest(t, "https://local.example.com/users/alice/inbox")
+	_, otherPublicKey, _ := generateTestKeyPair()
+	wrongPEM, _ := publicKeyToPEM(otherPublicKey)
+
+	cache := newSignatureCache(10, time.Minute)
+	calls := 0
+
+	for i := 0; i < 2; i++ {
+		if _, err := verifyRequestWithCache(newRequest(), wrongPEM, cache, countingVerify(&calls)); err == nil {
+			t.Fatalf("Expected verification %d with the wrong key to fail", i+1)
+		}
+	}
+
+	if calls != 2 {
+		t.Errorf("Expected failed verifications to be retried, got %d verify calls", calls)
+	}
+	if len(cache.entries) != 0 {
+		t.Errorf("Expected no cached entries after failures, got %d", len(cache.entries))
+	}
+}
+
+func TestVerifyRequestWithCache_DifferentTargetMisses(t *testing.T) {
+	newRequest, publicPEM := newSignedTestRequest(t, "https://local.example.com/users/alice/inbox")
+	cache := newSignatureCache(10, time.Minute)
+	calls := 0
+
+	if _, err := verifyRequestWithCache(newRequest(), publicPEM, cache, countingVerify(&calls)); err != nil {
+		t.Fatalf("Verification failed: %v", err)
+	}
+
+	// Same headers replayed against another inbox must be verified (and rejected) again
+	replayed := newRequest()
+	replayed.URL.Path = "/users/carol/inbox"
+	if _, err := verifyRequestWithCache(replayed, publicPEM, cache, countingVerify(&calls)); err == nil {
+		t.Error("Expected replayed request to a different inbox to fail verification")
+	}
+	if calls != 2 {
+		t.Errorf("Expected 2 verify calls, got %d", calls)
+	}
+}
+
+func TestSignatureCache_TTLAndLRU(t *testing.T) {
+	now := time.Now()
+	cache := newSignatureCache(2, time.Minute)
+	cache.now = func() time.Time { return now }
+
+	cache.add("a", "actor-a")
+	cache.add("b", "actor-b")
+	if _, ok := cache.get("a"); !ok {
+		t.Fatal("Expected a to be cached")
+	}
+
+	// a was used most recently, so adding c evicts b
+	cache.add("c", "actor-c")
+	if _, ok := cache.get("b"); ok {
+		t.Error("Expected b to be evicted as least recently used")
+	}
+	if actorURI, ok := cache.get("a"); !ok || actorURI != "actor-a" {
+		t.Errorf("Expected a to survive eviction, got %q %v", actorURI, ok)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, ok := cache.get("c"); ok {
+		t.Error("Expected c to expire after the TTL")
+	}
+
+	cache.setTTL(0)
+	cache.add("d", "actor-d")
+	if _, ok := cache.get("d"); ok {
+		t.Error("Expected caching to be disabled with a zero TTL")
+	}
+}
+
+// BenchmarkVerifyRequest_DuplicateDeliveries compares RSA verify calls for repeated deliveries
+// of the same signed request with and without the signature cache
+func BenchmarkVerifyRequest_DuplicateDeliveries(b *testing.B) {
+	newRequest, publicPEM := newSignedTestRequest(b, "https://local.example.com/users/alice/inbox")
+
+	for _, size := range []int{0, defaultSignatureCacheSize} {
+		b.Run(fmt.Sprintf("cache=%d", size), func(b *testing.B) {
+			cache := newSignatureCache(size, time.Minute)
+			calls := 0
+			verify := countingVerify(&calls)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := verifyRequestWithCache(newRequest(), publicPEM, cache, verify); err != nil {
+					b.Fatalf("Verification failed: %v", err)
+				}
+			}
+			b.ReportMetric(float64(calls)/float64(b.N), "verifies/op")
+		})
+	}
+}
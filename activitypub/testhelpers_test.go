@@ -10,6 +10,7 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"time"
 
 	"github.com/deemkeen/stegodon/domain"
@@ -191,14 +192,12 @@ func CreateTestActorResponse(serverURL, username string, publicKeyPEM string) *A
 		Summary:           "A test user",
 		Inbox:             serverURL + "/users/" + username + "/inbox",
 		Outbox:            serverURL + "/users/" + username + "/outbox",
-		PublicKey: struct {
-			ID           string `json:"id"`
-			Owner        string `json:"owner"`
-			PublicKeyPem string `json:"publicKeyPem"`
-		}{
-			ID:           serverURL + "/users/" + username + "#main-key",
-			Owner:        serverURL + "/users/" + username,
-			PublicKeyPem: publicKeyPEM,
+		PublicKey: actorPublicKeys{
+			actorPublicKey: actorPublicKey{
+				ID:           serverURL + "/users/" + username + "#main-key",
+				Owner:        serverURL + "/users/" + username,
+				PublicKeyPem: publicKeyPEM,
+			},
 		},
 	}
 }
@@ -385,6 +384,8 @@ type MockHTTPClient struct {
 	DefaultResponse *http.Response
 	// DefaultError is returned when no specific error is configured
 	DefaultError error
+
+	mu sync.Mutex // Guards Requests for concurrent deliveries
 }
 
 // NewMockHTTPClient creates a new mock HTTP client
@@ -398,7 +399,9 @@ func NewMockHTTPClient() *MockHTTPClient {
 
 // Do implements the HTTPClient interface
 func (c *MockHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	c.mu.Lock()
 	c.Requests = append(c.Requests, req)
+	c.mu.Unlock()
 
 	url := req.URL.String()
 
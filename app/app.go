@@ -22,11 +22,16 @@ import (
 
 // App represents the main application with all its servers and dependencies
 type App struct {
-	config              *util.AppConfig
-	sshServer           *ssh.Server
-	httpServer          *http.Server
-	done                chan os.Signal
-	stopDeliveryWorker  func() // Stop function for ActivityPub delivery worker
+	config                  *util.AppConfig
+	sshServer               *ssh.Server
+	httpServer              *http.Server
+	done                    chan os.Signal
+	stopDeliveryWorker      func() // Stop function for ActivityPub delivery worker
+	stopRelayWorker         func() // Stop function for ActivityPub relay worker
+	stopRelayScheduleWorker func() // Stop function for the relay quiet hours worker
+	stopInboxWorker         func() // Stop function for the queued inbox activity worker
+	stopMaintenanceWorker   func() // Stop function for the tombstone and remote account cleanup
+	stopCheckpointWorker    func() // Stop function for the periodic WAL checkpoint
 }
 
 // New creates a new App instance with the given configuration
@@ -42,6 +47,7 @@ func (a *App) Initialize() error {
 	// Run database migrations
 	log.Println("Running database migrations...")
 	database := db.GetDB()
+	database.SetConfig(a.config)
 	if err := database.RunActivityPubMigrations(); err != nil {
 		log.Printf("Warning: Migration errors (may be normal if tables exist): %v", err)
 	}
@@ -115,8 +121,16 @@ func (a *App) Initialize() error {
 func (a *App) Start() error {
 	// Start ActivityPub delivery worker if enabled
 	if a.config.Conf.WithAp {
+		activitypub.ConfigureSignatureCache(a.config)
+		activitypub.ConfigureReplayProtection(a.config)
+		activitypub.ConfigureHTTPClient(a.config)
 		a.stopDeliveryWorker = activitypub.StartDeliveryWorker(a.config)
+		a.stopRelayWorker = activitypub.StartRelayWorker(a.config)
+		a.stopRelayScheduleWorker = activitypub.StartRelayScheduleWorker(a.config)
+		a.stopInboxWorker = activitypub.StartInboxWorker(a.config)
 	}
+	a.stopMaintenanceWorker = startMaintenanceWorker(a.config)
+	a.stopCheckpointWorker = startCheckpointWorker(a.config)
 
 	// Setup signal handling
 	signal.Notify(a.done, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
@@ -158,6 +172,26 @@ func (a *App) Shutdown() error {
 		log.Println("Stopping ActivityPub delivery worker...")
 		a.stopDeliveryWorker()
 	}
+	if a.stopRelayWorker != nil {
+		log.Println("Stopping ActivityPub relay worker...")
+		a.stopRelayWorker()
+	}
+	if a.stopRelayScheduleWorker != nil {
+		log.Println("Stopping ActivityPub relay schedule worker...")
+		a.stopRelayScheduleWorker()
+	}
+	if a.stopInboxWorker != nil {
+		log.Println("Stopping ActivityPub inbox worker...")
+		a.stopInboxWorker()
+	}
+	if a.stopMaintenanceWorker != nil {
+		log.Println("Stopping maintenance worker...")
+		a.stopMaintenanceWorker()
+	}
+	if a.stopCheckpointWorker != nil {
+		log.Println("Stopping WAL checkpoint worker...")
+		a.stopCheckpointWorker()
+	}
 
 	// Shutdown HTTP server (stop accepting new requests)
 	log.Println("Stopping HTTP server...")
@@ -0,0 +1,53 @@
+package app
+
+import (
+	"log"
+	"time"
+
+	"github.com/deemkeen/stegodon/db"
+	"github.com/deemkeen/stegodon/util"
+)
+
+// startCheckpointWorker starts a background worker that runs a passive WAL checkpoint at the
+// configured interval, so the WAL doesn't grow unbounded while federation keeps writing.
+// Returns a stop function, which does nothing if checkpoints are turned off.
+func startCheckpointWorker(conf *util.AppConfig) func() {
+	interval := conf.DbCheckpointInterval()
+	if interval <= 0 {
+		log.Println("WAL checkpoint worker disabled")
+		return func() {}
+	}
+	log.Printf("Starting WAL checkpoint worker (every %s)...", interval)
+
+	ticker := time.NewTicker(interval)
+	stop := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				checkpointWAL()
+			case <-stop:
+				ticker.Stop()
+				log.Println("WAL checkpoint worker stopped")
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+	}
+}
+
+// checkpointWAL runs a passive checkpoint once
+func checkpointWAL() {
+	walFrames, checkpointed, err := db.GetDB().CheckpointWAL()
+	if err != nil {
+		log.Printf("Checkpoint: Failed to checkpoint WAL: %v", err)
+		return
+	}
+	if walFrames > 0 && checkpointed < walFrames {
+		log.Printf("Checkpoint: %d of %d WAL frames checkpointed, the rest are still in use", checkpointed, walFrames)
+	}
+}
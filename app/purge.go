@@ -0,0 +1,109 @@
+package app
+
+import (
+	"log"
+	"time"
+
+	"github.com/deemkeen/stegodon/db"
+	"github.com/deemkeen/stegodon/util"
+)
+
+const (
+	// defaultTombstoneRetentionDays is how long deleted notes are kept as tombstones
+	defaultTombstoneRetentionDays = 30
+	// defaultRemoteAccountRetentionDays is how long an unreferenced remote account stays cached after its last fetch
+	defaultRemoteAccountRetentionDays = 90
+	// maintenanceInterval is how often expired tombstones, mutes and remote accounts are purged
+	maintenanceInterval = time.Hour
+)
+
+// tombstoneRetention returns how long a deleted note is kept as a tombstone before it is purged
+func tombstoneRetention(conf *util.AppConfig) time.Duration {
+	if conf != nil && conf.Conf.TombstoneRetentionDays > 0 {
+		return time.Duration(conf.Conf.TombstoneRetentionDays) * 24 * time.Hour
+	}
+	return defaultTombstoneRetentionDays * 24 * time.Hour
+}
+
+// remoteAccountRetention returns how long an unreferenced remote account is kept after it was last fetched
+func remoteAccountRetention(conf *util.AppConfig) time.Duration {
+	if conf != nil && conf.Conf.RemoteAccountRetentionDays > 0 {
+		return time.Duration(conf.Conf.RemoteAccountRetentionDays) * 24 * time.Hour
+	}
+	return defaultRemoteAccountRetentionDays * 24 * time.Hour
+}
+
+// startMaintenanceWorker starts a background worker that hard-deletes the tombstones of notes
+// deleted longer ago than the retention window and, if enabled, prunes remote accounts nothing
+// refers to anymore. Returns a stop function.
+func startMaintenanceWorker(conf *util.AppConfig) func() {
+	log.Println("Starting maintenance worker...")
+
+	ticker := time.NewTicker(maintenanceInterval)
+	stop := make(chan struct{})
+
+	go func() {
+		runMaintenance(conf)
+		for {
+			select {
+			case <-ticker.C:
+				runMaintenance(conf)
+			case <-stop:
+				ticker.Stop()
+				log.Println("Maintenance worker stopped")
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+	}
+}
+
+// runMaintenance runs every cleanup task once
+func runMaintenance(conf *util.AppConfig) {
+	purgeTombstones(conf)
+	expireMutes()
+	if conf != nil && conf.Conf.PruneRemoteAccounts {
+		pruneRemoteAccounts(conf)
+	}
+}
+
+// purgeTombstones removes the tombstones that are past the retention window
+func purgeTombstones(conf *util.AppConfig) {
+	purged, err := db.GetDB().PurgeDeletedNotes(time.Now().Add(-tombstoneRetention(conf)))
+	if err != nil {
+		log.Printf("Maintenance: Failed to purge deleted notes: %v", err)
+		return
+	}
+	if purged > 0 {
+		log.Printf("Maintenance: Purged %d deleted notes", purged)
+	}
+}
+
+// expireMutes removes timed mutes of remote accounts that have ended. Expired mutes are
+// already ignored by the timeline and notification queries; this keeps the table small.
+func expireMutes() {
+	deleted, err := db.GetDB().DeleteExpiredMutedAccounts(time.Now())
+	if err != nil {
+		log.Printf("Maintenance: Failed to remove expired mutes: %v", err)
+		return
+	}
+	if deleted > 0 {
+		log.Printf("Maintenance: Removed %d expired mutes", deleted)
+	}
+}
+
+// pruneRemoteAccounts removes cached remote accounts that are past the retention window
+// and no longer referenced by follows, activities, likes, boosts or notifications
+func pruneRemoteAccounts(conf *util.AppConfig) {
+	pruned, err := db.GetDB().DeleteUnreferencedRemoteAccounts(time.Now().Add(-remoteAccountRetention(conf)))
+	if err != nil {
+		log.Printf("Maintenance: Failed to prune remote accounts: %v", err)
+		return
+	}
+	if pruned > 0 {
+		log.Printf("Maintenance: Pruned %d unreferenced remote accounts", pruned)
+	}
+}
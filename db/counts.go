@@ -0,0 +1,287 @@
+package db
+
+import (
+	"database/sql"
+	"log"
+	"strings"
+)
+
+// recountBatchSize is how many rows RecalculateCounts checks per transaction, so the write
+// lock is only held briefly and the instance keeps running during a repair
+const recountBatchSize = 500
+
+// Recount queries. Like and boost counts are recomputed from the likes and boosts tables for a
+// rowid range, touching only the rows whose stored count differs.
+const (
+	sqlRecountNoteLikes = `UPDATE notes SET like_count = (SELECT COUNT(*) FROM likes WHERE likes.note_id = notes.id)
+		WHERE rowid > ? AND rowid <= ? AND COALESCE(like_count, 0) != (SELECT COUNT(*) FROM likes WHERE likes.note_id = notes.id)`
+	sqlRecountNoteBoosts = `UPDATE notes SET boost_count = (SELECT COUNT(*) FROM boosts WHERE boosts.note_id = notes.id)
+		WHERE rowid > ? AND rowid <= ? AND COALESCE(boost_count, 0) != (SELECT COUNT(*) FROM boosts WHERE boosts.note_id = notes.id)`
+	// Likes of remote posts are stored with the post's object URI
+	sqlRecountActivityLikes = `UPDATE activities SET like_count = (SELECT COUNT(*) FROM likes WHERE likes.object_uri = activities.object_uri)
+		WHERE rowid > ? AND rowid <= ? AND object_uri IS NOT NULL AND object_uri != ''
+		AND COALESCE(like_count, 0) != (SELECT COUNT(*) FROM likes WHERE likes.object_uri = activities.object_uri)`
+
+	// Reply counts are only replaced if they didn't change since they were read
+	sqlUpdateNoteReplyCountIfUnchanged     = `UPDATE notes SET reply_count = ? WHERE id = ? AND COALESCE(reply_count, 0) = ?`
+	sqlUpdateActivityReplyCountIfUnchanged = `UPDATE activities SET reply_count = ? WHERE id = ? AND COALESCE(reply_count, 0) = ?`
+)
+
+// RecountResult reports how many stored counts RecalculateCounts corrected
+type RecountResult struct {
+	LikeCounts  int
+	BoostCounts int
+	ReplyCounts int
+}
+
+// RecalculateCounts repairs the denormalized like_count, boost_count and reply_count of all
+// notes and activities from the likes, boosts and reply rows they count. It works in batches
+// of short transactions and returns how many counts were wrong. Activities only get like
+// counts: boosts of remote posts aren't stored. Use RecalculateReplyCounts to also repair
+// thread roots.
+func (db *DB) RecalculateCounts() (RecountResult, error) {
+	var result RecountResult
+
+	for _, recount := range []struct {
+		table     string
+		query     string
+		corrected *int
+	}{
+		{"notes", sqlRecountNoteLikes, &result.LikeCounts},
+		{"notes", sqlRecountNoteBoosts, &result.BoostCounts},
+		{"activities", sqlRecountActivityLikes, &result.LikeCounts},
+	} {
+		corrected, err := db.recountInBatches(recount.table, recount.query)
+		*recount.corrected += corrected
+		if err != nil {
+			return result, err
+		}
+	}
+
+	corrected, err := db.recalculateStoredReplyCounts()
+	result.ReplyCounts = corrected
+	if err != nil {
+		return result, err
+	}
+
+	log.Printf("Recalculated counts: corrected %d like, %d boost and %d reply counts",
+		result.LikeCounts, result.BoostCounts, result.ReplyCounts)
+	return result, nil
+}
+
+// recountInBatches runs a recount query over all rows of table, one rowid range per
+// transaction, and returns the number of rows it changed
+func (db *DB) recountInBatches(table, query string) (int, error) {
+	var maxRowid int64
+	if err := db.conn().QueryRow(`SELECT COALESCE(MAX(rowid), 0) FROM ` + table).Scan(&maxRowid); err != nil {
+		return 0, err
+	}
+
+	corrected := 0
+	for from := int64(0); from < maxRowid; from += recountBatchSize {
+		err := db.wrapTransaction(func(tx *sql.Tx) error {
+			res, err := tx.Exec(query, from, from+recountBatchSize)
+			if err != nil {
+				return err
+			}
+			changed, err := res.RowsAffected()
+			corrected += int(changed)
+			return err
+		})
+		if err != nil {
+			return corrected, err
+		}
+	}
+	return corrected, nil
+}
+
+// replyCountFix is a stored reply count that differs from the counted replies
+type replyCountFix struct {
+	query    string
+	id       string
+	stored   int
+	expected int
+}
+
+// recalculateStoredReplyCounts sets every reply_count that differs from expectedReplyCounts,
+// in batches, and returns how many were corrected
+func (db *DB) recalculateStoredReplyCounts() (int, error) {
+	noteCounts, activityCounts, err := db.expectedReplyCounts()
+	if err != nil {
+		return 0, err
+	}
+
+	var fixes []replyCountFix
+	rows, err := db.conn().Query(`SELECT id, COALESCE(reply_count, 0) FROM notes`)
+	if err != nil {
+		return 0, err
+	}
+	for rows.Next() {
+		var id string
+		var stored int
+		if err := rows.Scan(&id, &stored); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if expected := noteCounts[id]; stored != expected {
+			fixes = append(fixes, replyCountFix{sqlUpdateNoteReplyCountIfUnchanged, id, stored, expected})
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	rows, err = db.conn().Query(`SELECT id, COALESCE(object_uri, ''), COALESCE(reply_count, 0) FROM activities`)
+	if err != nil {
+		return 0, err
+	}
+	for rows.Next() {
+		var id, objectURI string
+		var stored int
+		if err := rows.Scan(&id, &objectURI, &stored); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if expected := activityCounts[objectURI]; objectURI != "" && stored != expected {
+			fixes = append(fixes, replyCountFix{sqlUpdateActivityReplyCountIfUnchanged, id, stored, expected})
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	corrected := 0
+	for start := 0; start < len(fixes); start += recountBatchSize {
+		batch := fixes[start:min(start+recountBatchSize, len(fixes))]
+		err := db.wrapTransaction(func(tx *sql.Tx) error {
+			for _, fix := range batch {
+				// A count that changed since it was read is left to the code that changed it
+				res, err := tx.Exec(fix.query, fix.expected, fix.id, fix.stored)
+				if err != nil {
+					return err
+				}
+				changed, _ := res.RowsAffected()
+				corrected += int(changed)
+			}
+			return nil
+		})
+		if err != nil {
+			return corrected, err
+		}
+	}
+	return corrected, nil
+}
+
+// expectedReplyCounts counts every stored reply on each of its ancestors, the way
+// recountReplies does, without writing anything. It returns the expected reply_count of
+// notes by id and of activities by object URI.
+func (db *DB) expectedReplyCounts() (map[string]int, map[string]int, error) {
+	noteParents := make(map[string]string) // note id -> in-reply-to URI
+	notesByURI := make(map[string][]string)
+	var replyParents []string
+
+	rows, err := db.conn().Query(`SELECT id, COALESCE(object_uri, ''), COALESCE(in_reply_to_uri, '') FROM notes`)
+	if err != nil {
+		return nil, nil, err
+	}
+	for rows.Next() {
+		var id, objectURI, parentURI string
+		if err := rows.Scan(&id, &objectURI, &parentURI); err != nil {
+			rows.Close()
+			return nil, nil, err
+		}
+		noteParents[id] = parentURI
+		if objectURI != "" {
+			notesByURI[objectURI] = append(notesByURI[objectURI], id)
+		}
+		if parentURI != "" {
+			replyParents = append(replyParents, parentURI)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	// The parent of an activity is taken from the first activity stored for its object
+	activityParents := make(map[string]string) // object URI -> in-reply-to URI
+	rows, err = db.conn().Query(`SELECT object_uri, CASE WHEN raw_json LIKE '%"inReplyTo":"http%' THEN raw_json ELSE '' END
+		FROM activities WHERE object_uri IS NOT NULL AND object_uri != '' ORDER BY rowid`)
+	if err != nil {
+		return nil, nil, err
+	}
+	for rows.Next() {
+		var objectURI, rawJSON string
+		if err := rows.Scan(&objectURI, &rawJSON); err != nil {
+			rows.Close()
+			return nil, nil, err
+		}
+		if _, seen := activityParents[objectURI]; !seen {
+			activityParents[objectURI] = extractInReplyToFromJSON(rawJSON)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	// Remote replies, skipping copies of local notes as recountReplies does
+	rows, err = db.conn().Query(`SELECT a.raw_json FROM activities a
+		WHERE a.activity_type = 'Create'
+		AND a.raw_json LIKE '%"inReplyTo":"http%'
+		AND NOT EXISTS (
+			SELECT 1 FROM notes n
+			WHERE (n.object_uri = a.object_uri AND n.object_uri IS NOT NULL AND n.object_uri != '')
+			   OR (a.object_uri LIKE '%/notes/' || n.id || '%')
+		)`)
+	if err != nil {
+		return nil, nil, err
+	}
+	for rows.Next() {
+		var rawJSON string
+		if err := rows.Scan(&rawJSON); err != nil {
+			rows.Close()
+			return nil, nil, err
+		}
+		if parentURI := extractInReplyToFromJSON(rawJSON); parentURI != "" {
+			replyParents = append(replyParents, parentURI)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	noteCounts := make(map[string]int)
+	activityCounts := make(map[string]int)
+	for _, uri := range replyParents {
+		// Walk up the ancestors like incrementReplyCountRecursive
+		visited := make(map[string]bool)
+		for uri != "" && !visited[uri] {
+			visited[uri] = true
+			if noteId, ok := strings.CutPrefix(uri, "local:"); ok {
+				if parentURI, found := noteParents[noteId]; found {
+					noteCounts[noteId]++
+					uri = parentURI
+					continue
+				}
+			}
+			if ids := notesByURI[uri]; len(ids) > 0 {
+				for _, id := range ids {
+					noteCounts[id]++
+				}
+				uri = noteParents[ids[0]]
+				continue
+			}
+			parentURI, found := activityParents[uri]
+			if !found {
+				break
+			}
+			activityCounts[uri]++
+			uri = parentURI
+		}
+	}
+	return noteCounts, activityCounts, nil
+}
@@ -4,7 +4,10 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
 
@@ -19,14 +22,55 @@ import (
 	sqlitelib "modernc.org/sqlite/lib"
 )
 
+// ErrDatabaseBusy is returned when a write still finds the database locked after all retries
+var ErrDatabaseBusy = errors.New("database is busy")
+
+// Retries of writes that find the database locked by another connection
+const (
+	maxTxAttempts  = 5                     // Attempts in all before ErrDatabaseBusy is returned
+	txRetryBackoff = 10 * time.Millisecond // Pause before the first retry, doubled for each further one
+)
+
+// ErrAccountNotPending is returned when approving or denying an account that isn't pending
+var ErrAccountNotPending = errors.New("account is not pending approval")
+
+// ErrKeyInUse is returned when adding an SSH key that already belongs to an account
+var ErrKeyInUse = errors.New("key is already used by an account")
+
+// ErrPrimaryKey is returned when removing the key an account was created with
+var ErrPrimaryKey = errors.New("the primary key can't be removed")
+
+// ErrNotConfigured is returned when creating a local note before SetConfig, as its object
+// URI can't be built without the instance's domain
+var ErrNotConfigured = errors.New("database has no config to build object URIs from")
+
 // DB is the database struct.
 type DB struct {
-	db *sql.DB
+	db          *sql.DB
+	tx          *sql.Tx         // Set on the copy handed to a WithTx callback
+	conf        *util.AppConfig // Set by SetConfig; used to build the object URIs of local notes
+	afterCommit []func()        // Run by WithTx once its transaction committed
+}
+
+// querier is the part of *sql.DB and *sql.Tx the queries run on
+type querier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// conn returns the transaction of a WithTx copy, or the connection pool otherwise
+func (db *DB) conn() querier {
+	if db.tx != nil {
+		return db.tx
+	}
+	return db.db
 }
 
 var (
 	dbInstance *DB
 	dbOnce     sync.Once
+	dbConf     *util.AppConfig // Set by Configure; the database tuning GetDB opens the database with
 )
 
 const (
@@ -45,9 +89,36 @@ const (
 	sqlInsertUser            = `INSERT INTO accounts(id, username, publickey, web_public_key, web_private_key, created_at) VALUES (?, ?, ?, ?, ?, ?)`
 	sqlUpdateLoginUser       = `UPDATE accounts SET first_time_login = 0, username = ?, display_name = ?, summary = ? WHERE publickey = ?`
 	sqlUpdateLoginUserById   = `UPDATE accounts SET first_time_login = 0, username = ?, display_name = ?, summary = ? WHERE id = ?`
-	sqlSelectUserByPublicKey = `SELECT id, username, publickey, created_at, first_time_login, web_public_key, web_private_key, display_name, summary, avatar_url, is_admin, muted FROM accounts WHERE publickey = ?`
-	sqlSelectUserById        = `SELECT id, username, publickey, created_at, first_time_login, web_public_key, web_private_key, display_name, summary, avatar_url, is_admin, muted FROM accounts WHERE id = ?`
-	sqlSelectUserByUsername  = `SELECT id, username, publickey, created_at, first_time_login, web_public_key, web_private_key, display_name, summary, avatar_url, is_admin, muted FROM accounts WHERE username = ?`
+	sqlSelectUserByPublicKey = `SELECT id, username, publickey, created_at, first_time_login, web_public_key, web_private_key, display_name, summary, avatar_url, is_admin, muted, manually_approves_followers, pending, disabled, discoverable FROM accounts WHERE publickey = ? OR id = (SELECT account_id FROM account_keys WHERE pk_hash = ?)`
+	sqlSelectUserById        = `SELECT id, username, publickey, created_at, first_time_login, web_public_key, web_private_key, display_name, summary, avatar_url, is_admin, muted, manually_approves_followers, pending, disabled, discoverable FROM accounts WHERE id = ?`
+	sqlSelectUserByUsername  = `SELECT id, username, publickey, created_at, first_time_login, web_public_key, web_private_key, display_name, summary, avatar_url, is_admin, muted, manually_approves_followers, pending, disabled, discoverable FROM accounts WHERE username = ?`
+
+	// SSH keys added to an account besides its primary key (accounts.publickey)
+	sqlInsertAccountKey             = `INSERT INTO account_keys(id, account_id, pk_hash, label, added_at) VALUES (?, ?, ?, ?, ?)`
+	sqlSelectAccountKeysByAccountId = `SELECT id, account_id, pk_hash, label, added_at FROM account_keys WHERE account_id = ? ORDER BY added_at ASC`
+	sqlDeleteAccountKey             = `DELETE FROM account_keys WHERE id = ? AND account_id = ?`
+	sqlCountKeyUses                 = `SELECT (SELECT COUNT(*) FROM accounts WHERE publickey = ?) + (SELECT COUNT(*) FROM account_keys WHERE pk_hash = ?)`
+
+	// HTTP API tokens (only the token's hash is stored)
+	sqlInsertAPIToken             = `INSERT INTO api_tokens(id, account_id, token_hash, scopes, created_at) VALUES (?, ?, ?, ?, ?)`
+	sqlSelectAPITokenByHash       = `SELECT id, account_id, token_hash, scopes, created_at FROM api_tokens WHERE token_hash = ?`
+	sqlSelectAPITokensByAccountId = `SELECT id, account_id, token_hash, scopes, created_at FROM api_tokens WHERE account_id = ? ORDER BY created_at DESC`
+	sqlDeleteAPIToken             = `DELETE FROM api_tokens WHERE id = ? AND account_id = ?`
+	sqlDeleteAPITokensByAccountId = `DELETE FROM api_tokens WHERE account_id = ?`
+
+	// Language preferences (filter_languages is a comma-separated list of language codes)
+	sqlSelectLanguageSettings = `SELECT COALESCE(default_language, ''), COALESCE(filter_languages, '') FROM accounts WHERE id = ?`
+	sqlUpdateLanguageSettings = `UPDATE accounts SET default_language = ?, filter_languages = ? WHERE id = ?`
+
+	sqlSelectTimezone = `SELECT COALESCE(timezone, '') FROM accounts WHERE id = ?`
+	sqlUpdateTimezone = `UPDATE accounts SET timezone = NULLIF(?, '') WHERE id = ?`
+
+	sqlUpdateManuallyApprovesFollowers = `UPDATE accounts SET manually_approves_followers = ? WHERE id = ?`
+
+	sqlUpdateDiscoverable = `UPDATE accounts SET discoverable = ? WHERE id = ?`
+
+	sqlSelectShowRepliesInHome = `SELECT COALESCE(show_replies_in_home, 0) FROM accounts WHERE id = ?`
+	sqlUpdateShowRepliesInHome = `UPDATE accounts SET show_replies_in_home = ? WHERE id = ?`
 
 	//Notes
 	sqlCreateNotesTable = `CREATE TABLE IF NOT EXISTS notes(
@@ -56,69 +127,97 @@ const (
                         message varchar(2000),
                         created_at timestamp default current_timestamp
                         )`
-	sqlInsertNote     = `INSERT INTO notes(id, user_id, message, created_at) VALUES (?, ?, ?, ?)`
-	sqlUpdateNote     = `UPDATE notes SET message = ?, edited_at = ? WHERE id = ?`
-	sqlDeleteNote     = `DELETE FROM notes WHERE id = ?`
-	sqlSelectNoteById = `SELECT notes.id, accounts.username, notes.message, notes.created_at, notes.edited_at, COALESCE(notes.like_count, 0), COALESCE(notes.boost_count, 0) FROM notes
+	sqlInsertNote     = `INSERT INTO notes(id, user_id, message, created_at, in_reply_to_uri, thread_root_uri, object_uri, visibility, language) VALUES (?, ?, ?, ?, NULLIF(?, ''), NULLIF(?, ''), ?, ?, (SELECT default_language FROM accounts WHERE id = ?))`
+	sqlUpdateNote     = `UPDATE notes SET message = ?, edited_at = ?, object_json = NULL WHERE id = ?`
+	sqlTombstoneNote  = `UPDATE notes SET message = '', content_warning = NULL, object_json = NULL, deleted_at = ? WHERE id = ?`
+	sqlPurgeNotes     = `DELETE FROM notes WHERE deleted_at IS NOT NULL AND deleted_at < ?`
+	sqlSelectNoteById = `SELECT notes.id, accounts.username, CASE WHEN notes.deleted_at IS NULL THEN notes.message ELSE '[deleted]' END, notes.created_at, notes.edited_at, COALESCE(notes.like_count, 0), COALESCE(notes.boost_count, 0), COALESCE(notes.visibility, 'public'), notes.deleted_at FROM notes
     														INNER JOIN accounts ON accounts.id = notes.user_id
                                                             WHERE notes.id = ?`
-	sqlSelectNotesByUserId = `SELECT notes.id, accounts.username, notes.message, notes.created_at, notes.edited_at, notes.in_reply_to_uri, notes.like_count, notes.boost_count FROM notes
+	sqlSelectNotesByUserId = `SELECT notes.id, accounts.username, notes.message, notes.created_at, notes.edited_at, notes.in_reply_to_uri, notes.like_count, notes.boost_count, COALESCE(notes.visibility, 'public') FROM notes
     														INNER JOIN accounts ON accounts.id = notes.user_id
-                                                            WHERE notes.user_id = ?
+                                                            WHERE notes.user_id = ? AND notes.deleted_at IS NULL
                                                             ORDER BY notes.created_at DESC`
 	sqlSelectNotesByUsername = `SELECT notes.id, accounts.username, notes.message, notes.created_at, notes.edited_at, notes.in_reply_to_uri FROM notes
     														INNER JOIN accounts ON accounts.id = notes.user_id
-                                                            WHERE accounts.username = ?
+                                                            WHERE accounts.username = ? AND COALESCE(notes.visibility, 'public') != 'local' AND notes.deleted_at IS NULL
                                                             ORDER BY notes.created_at DESC`
+	// Profile posts, newest first with the id as tie-breaker so pages don't overlap.
+	// The ? = 1 parameter includes replies.
+	sqlSelectNotesByUsernamePaged = `SELECT notes.id, accounts.username, notes.message, notes.created_at, notes.edited_at, notes.in_reply_to_uri, COALESCE(notes.like_count, 0), COALESCE(notes.boost_count, 0) FROM notes
+		INNER JOIN accounts ON accounts.id = notes.user_id
+		WHERE accounts.username = ? AND COALESCE(notes.visibility, 'public') != 'local' AND notes.deleted_at IS NULL
+		AND (? = 1 OR notes.in_reply_to_uri IS NULL OR notes.in_reply_to_uri = '')
+		ORDER BY notes.created_at DESC, notes.id DESC LIMIT ? OFFSET ?`
+	sqlCountNotesByUsername = `SELECT COUNT(*) FROM notes
+		INNER JOIN accounts ON accounts.id = notes.user_id
+		WHERE accounts.username = ? AND COALESCE(notes.visibility, 'public') != 'local' AND notes.deleted_at IS NULL
+		AND (? = 1 OR notes.in_reply_to_uri IS NULL OR notes.in_reply_to_uri = '')`
 	sqlSelectAllNotes = `SELECT notes.id, accounts.username, notes.message, notes.created_at, notes.edited_at, notes.in_reply_to_uri, COALESCE(notes.like_count, 0), COALESCE(notes.boost_count, 0) FROM notes
     														INNER JOIN accounts ON accounts.id = notes.user_id
+                                                            WHERE COALESCE(notes.visibility, 'public') != 'local' AND notes.deleted_at IS NULL
+                                                            AND COALESCE(accounts.discoverable, ?) = 1
                                                             ORDER BY notes.created_at DESC`
 
 	// Local users and local timeline queries
-	sqlSelectAllAccounts        = `SELECT id, username, publickey, created_at, first_time_login, web_public_key, web_private_key, display_name, summary, avatar_url, is_admin, muted FROM accounts WHERE first_time_login = 0 ORDER BY username ASC`
-	sqlSelectAllAccountsAdmin   = `SELECT id, username, publickey, created_at, first_time_login, web_public_key, web_private_key, display_name, summary, avatar_url, is_admin, muted FROM accounts ORDER BY created_at ASC`
+	sqlSelectAllAccounts        = `SELECT id, username, publickey, created_at, first_time_login, web_public_key, web_private_key, display_name, summary, avatar_url, is_admin, muted, manually_approves_followers, pending, disabled, discoverable FROM accounts WHERE first_time_login = 0 ORDER BY username ASC`
+	sqlSelectAllAccountsAdmin   = `SELECT id, username, publickey, created_at, first_time_login, web_public_key, web_private_key, display_name, summary, avatar_url, is_admin, muted, manually_approves_followers, pending, disabled, discoverable FROM accounts ORDER BY created_at ASC`
 	sqlCountAccounts            = `SELECT COUNT(*) FROM accounts`
-	sqlCountLocalPosts          = `SELECT COUNT(*) FROM notes`
-	sqlCountActiveUsersMonth    = `SELECT COUNT(DISTINCT user_id) FROM notes WHERE created_at >= datetime('now', '-30 days')`
-	sqlCountActiveUsersHalfYear = `SELECT COUNT(DISTINCT user_id) FROM notes WHERE created_at >= datetime('now', '-180 days')`
+	sqlCountLocalPosts          = `SELECT COUNT(*) FROM notes WHERE deleted_at IS NULL`
+	sqlCountActiveUsersMonth    = `SELECT COUNT(DISTINCT user_id) FROM notes WHERE created_at >= datetime('now', '-30 days') AND deleted_at IS NULL`
+	sqlCountActiveUsersHalfYear = `SELECT COUNT(DISTINCT user_id) FROM notes WHERE created_at >= datetime('now', '-180 days') AND deleted_at IS NULL`
+	sqlCountRemoteAccounts      = `SELECT COUNT(*) FROM remote_accounts`
+	sqlCountRemoteDomains       = `SELECT COUNT(DISTINCT LOWER(domain)) FROM remote_accounts`
+	sqlCountActivities          = `SELECT COUNT(*) FROM activities`
 	sqlSelectLocalTimelineNotes = `SELECT notes.id, accounts.username, notes.message, notes.created_at, notes.edited_at FROM notes
 														INNER JOIN accounts ON accounts.id = notes.user_id
+														WHERE notes.deleted_at IS NULL
 														ORDER BY notes.created_at DESC LIMIT ?`
 	sqlSelectLocalTimelineNotesByFollows = `SELECT notes.id, accounts.username, notes.message, notes.created_at, notes.edited_at FROM notes
 														INNER JOIN accounts ON accounts.id = notes.user_id
-														WHERE (notes.in_reply_to_uri IS NULL OR notes.in_reply_to_uri = '')
+														WHERE notes.deleted_at IS NULL
 														AND (notes.user_id = ? OR notes.user_id IN (
 															SELECT target_account_id FROM follows
 															WHERE account_id = ? AND accepted = 1 AND is_local = 1
 														))
+														AND (notes.user_id = ? OR ? = '' OR COALESCE(notes.language, '') = '' OR instr(',' || ? || ',', ',' || notes.language || ',') > 0)
+														AND (notes.in_reply_to_uri IS NULL OR notes.in_reply_to_uri = '' OR (? = 1 AND ` + sqlNoteRepliesToFollowed + `))
 														ORDER BY notes.created_at DESC LIMIT ?`
 
+	// Account archive query - returns one page of all of a user's notes, including non-public ones
+	sqlSelectNotesPageByUserId = `SELECT notes.id, accounts.username, notes.message, notes.created_at, notes.edited_at, notes.in_reply_to_uri, COALESCE(notes.visibility, 'public'), notes.object_uri, COALESCE(notes.language, '')
+														FROM notes
+														INNER JOIN accounts ON accounts.id = notes.user_id
+														WHERE notes.user_id = ? AND notes.deleted_at IS NULL
+														ORDER BY notes.created_at DESC
+														LIMIT ? OFFSET ?`
+
 	// Outbox collection query - returns public notes for ActivityPub outbox
-	sqlSelectPublicNotesByUsername = `SELECT notes.id, notes.user_id, notes.message, notes.created_at, notes.edited_at, notes.visibility, notes.object_uri
+	sqlSelectPublicNotesByUsername = `SELECT notes.id, notes.user_id, notes.message, notes.created_at, notes.edited_at, notes.visibility, notes.object_uri, COALESCE(notes.language, '')
 														FROM notes
 														INNER JOIN accounts ON accounts.id = notes.user_id
-														WHERE accounts.username = ? AND notes.visibility = 'public'
+														WHERE accounts.username = ? AND notes.visibility = 'public' AND notes.deleted_at IS NULL
 														ORDER BY notes.created_at DESC
 														LIMIT ? OFFSET ?`
 )
 
-func (db *DB) CreateAccount(s ssh.Session, username string) (error, bool) {
-	err, found := db.ReadAccBySession(s)
+func (db *DB) CreateAccount(s ssh.Session, username string) (bool, error) {
+	found, err := db.ReadAccBySession(s)
 	if err != nil {
 		log.Printf("No records for %s found, creating new user..", username)
 	}
 
 	if found != nil {
-		return nil, true
+		return true, nil
 	}
 
 	keypair := util.GeneratePemKeypair()
 	err2 := db.CreateAccByUsername(s, username, keypair)
 	if err2 != nil {
 		log.Println("Creating new user failed: ", err2)
-		return err2, false
+		return false, err2
 	}
-	return nil, true
+	return true, nil
 }
 
 func (db *DB) CreateAccByUsername(s ssh.Session, username string, webKeyPair *util.RsaKeyPair) error {
@@ -137,9 +236,19 @@ func (db *DB) CreateNote(userId uuid.UUID, message string) (uuid.UUID, error) {
 
 // CreateNoteWithReply creates a note with an optional inReplyToURI for replies
 func (db *DB) CreateNoteWithReply(userId uuid.UUID, message string, inReplyToURI string) (uuid.UUID, error) {
+	return db.CreateNoteWithVisibility(userId, message, inReplyToURI, domain.VisibilityPublic)
+}
+
+// CreateNoteWithVisibility creates a note with an optional inReplyToURI and the given visibility
+// (empty means public). Local-only notes (domain.VisibilityLocal) are never federated.
+// Notes longer than the configured MaxNoteChars are rejected with util.ErrNoteTooLong.
+func (db *DB) CreateNoteWithVisibility(userId uuid.UUID, message string, inReplyToURI string, visibility string) (uuid.UUID, error) {
+	if err := util.ValidateNoteChars(message, db.conf.NoteCharLimit()); err != nil {
+		return uuid.Nil, err
+	}
 	var noteId uuid.UUID
 	err := db.wrapTransaction(func(tx *sql.Tx) error {
-		id, err := db.insertNoteWithReply(tx, userId, message, inReplyToURI)
+		id, err := db.insertNoteWithReply(tx, userId, message, inReplyToURI, visibility)
 		if err != nil {
 			return err
 		}
@@ -149,7 +258,11 @@ func (db *DB) CreateNoteWithReply(userId uuid.UUID, message string, inReplyToURI
 	return noteId, err
 }
 
+// UpdateNote replaces the message of a note, enforcing the same length limit as CreateNoteWithVisibility
 func (db *DB) UpdateNote(noteId uuid.UUID, message string) error {
+	if err := util.ValidateNoteChars(message, db.conf.NoteCharLimit()); err != nil {
+		return err
+	}
 	return db.wrapTransaction(func(tx *sql.Tx) error {
 		err := db.updateNote(tx, noteId, message)
 		if err != nil {
@@ -169,6 +282,21 @@ func (db *DB) DeleteNoteById(noteId uuid.UUID) error {
 	})
 }
 
+// PurgeDeletedNotes hard-deletes the tombstones of notes deleted before the given time
+// and returns how many were removed
+func (db *DB) PurgeDeletedNotes(before time.Time) (int64, error) {
+	var purged int64
+	err := db.wrapTransaction(func(tx *sql.Tx) error {
+		result, err := tx.Exec(sqlPurgeNotes, formatTimestamp(before))
+		if err != nil {
+			return err
+		}
+		purged, err = result.RowsAffected()
+		return err
+	})
+	return purged, err
+}
+
 func (db *DB) UpdateLoginByPkHash(username string, displayName string, summary string, pkHash string) error {
 	return db.wrapTransaction(func(tx *sql.Tx) error {
 		err := db.updateLoginUser(tx, username, displayName, summary, pkHash)
@@ -181,7 +309,7 @@ func (db *DB) UpdateLoginByPkHash(username string, displayName string, summary s
 
 func (db *DB) UpdateLoginById(username string, displayName string, summary string, id uuid.UUID) error {
 	// Check if username is already taken by another user (before transaction)
-	err, existingAcc := db.ReadAccByUsername(username)
+	existingAcc, err := db.ReadAccByUsername(username)
 	if err == nil && existingAcc != nil && existingAcc.Id != id {
 		return fmt.Errorf("username '%s' is already taken", username)
 	}
@@ -195,95 +323,429 @@ func (db *DB) UpdateLoginById(username string, displayName string, summary strin
 	})
 }
 
-func (db *DB) ReadAccBySession(s ssh.Session) (error, *domain.Account) {
+// AddKey lets an account log in with another SSH key, given as the hash of the key in
+// authorized_keys format (see util.AuthorizedKeyHash). A key can only belong to one account.
+func (db *DB) AddKey(accountId uuid.UUID, pkHash string, label string) (*domain.AccountKey, error) {
+	key := &domain.AccountKey{
+		Id:        uuid.New(),
+		AccountId: accountId,
+		PkHash:    pkHash,
+		Label:     label,
+		AddedAt:   time.Now(),
+	}
+	err := db.wrapTransaction(func(tx *sql.Tx) error {
+		var uses int
+		if err := tx.QueryRow(sqlCountKeyUses, pkHash, pkHash).Scan(&uses); err != nil {
+			return err
+		}
+		if uses > 0 {
+			return ErrKeyInUse
+		}
+		_, err := tx.Exec(sqlInsertAccountKey, key.Id.String(), accountId.String(), pkHash, label, formatTimestamp(key.AddedAt))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// RemoveKey removes one of the keys added to an account. The primary key stays.
+func (db *DB) RemoveKey(accountId, keyId uuid.UUID) error {
+	if keyId == uuid.Nil {
+		return ErrPrimaryKey
+	}
+	return db.wrapTransaction(func(tx *sql.Tx) error {
+		_, err := tx.Exec(sqlDeleteAccountKey, keyId.String(), accountId.String())
+		return err
+	})
+}
+
+// ListKeys returns the SSH keys an account can log in with, its primary key first and the
+// added keys in the order they were added
+func (db *DB) ListKeys(accountId uuid.UUID) ([]domain.AccountKey, error) {
+	acc, err := db.ReadAccById(accountId)
+	if err != nil {
+		return nil, err
+	}
+	keys := []domain.AccountKey{{
+		AccountId: accountId,
+		PkHash:    acc.Publickey,
+		Label:     "primary",
+		AddedAt:   acc.CreatedAt,
+		Primary:   true,
+	}}
+
+	rows, err := db.conn().Query(sqlSelectAccountKeysByAccountId, accountId.String())
+	if err != nil {
+		return keys, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key domain.AccountKey
+		var idStr, accountIdStr string
+		if err := rows.Scan(&idStr, &accountIdStr, &key.PkHash, &key.Label, &key.AddedAt); err != nil {
+			return keys, err
+		}
+		key.Id, _ = uuid.Parse(idStr)
+		key.AccountId, _ = uuid.Parse(accountIdStr)
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// CreateAPIToken stores a new HTTP API token
+func (db *DB) CreateAPIToken(token *domain.APIToken) error {
+	return db.wrapTransaction(func(tx *sql.Tx) error {
+		_, err := tx.Exec(sqlInsertAPIToken, token.Id.String(), token.AccountId.String(), token.TokenHash, strings.Join(token.Scopes, ","), formatTimestamp(token.CreatedAt))
+		return err
+	})
+}
+
+// ReadAPITokenByHash returns the API token with the given hash, used to validate bearer tokens
+func (db *DB) ReadAPITokenByHash(tokenHash string) (*domain.APIToken, error) {
+	token, err := scanAPIToken(db.conn().QueryRow(sqlSelectAPITokenByHash, tokenHash))
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// ReadAPITokensByAccountId returns an account's API tokens, newest first
+func (db *DB) ReadAPITokensByAccountId(accountId uuid.UUID) (*[]domain.APIToken, error) {
+	rows, err := db.conn().Query(sqlSelectAPITokensByAccountId, accountId.String())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []domain.APIToken
+	for rows.Next() {
+		token, err := scanAPIToken(rows)
+		if err != nil {
+			return &tokens, err
+		}
+		tokens = append(tokens, *token)
+	}
+	if err = rows.Err(); err != nil {
+		return &tokens, err
+	}
+	return &tokens, nil
+}
+
+// DeleteAPIToken revokes one of an account's API tokens
+func (db *DB) DeleteAPIToken(accountId, tokenId uuid.UUID) error {
+	return db.wrapTransaction(func(tx *sql.Tx) error {
+		_, err := tx.Exec(sqlDeleteAPIToken, tokenId.String(), accountId.String())
+		return err
+	})
+}
+
+// DeleteAPITokensByAccountId revokes all of an account's API tokens
+func (db *DB) DeleteAPITokensByAccountId(accountId uuid.UUID) error {
+	return db.wrapTransaction(func(tx *sql.Tx) error {
+		_, err := tx.Exec(sqlDeleteAPITokensByAccountId, accountId.String())
+		return err
+	})
+}
+
+// scanAPIToken scans an api_tokens row
+func scanAPIToken(row interface{ Scan(...any) error }) (*domain.APIToken, error) {
+	var token domain.APIToken
+	var idStr, accountIdStr, scopes string
+	if err := row.Scan(&idStr, &accountIdStr, &token.TokenHash, &scopes, &token.CreatedAt); err != nil {
+		return nil, err
+	}
+	token.Id, _ = uuid.Parse(idStr)
+	token.AccountId, _ = uuid.Parse(accountIdStr)
+	if scopes != "" {
+		token.Scopes = strings.Split(scopes, ",")
+	}
+	return &token, nil
+}
+
+// ReadLanguageSettings returns an account's default post language and the languages it wants to see
+func (db *DB) ReadLanguageSettings(accountId uuid.UUID) (*domain.LanguageSettings, error) {
+	var defaultLanguage, languages string
+	err := db.conn().QueryRow(sqlSelectLanguageSettings, accountId.String()).Scan(&defaultLanguage, &languages)
+	if err != nil {
+		return nil, err
+	}
+	return &domain.LanguageSettings{
+		DefaultLanguage: defaultLanguage,
+		Languages:       util.ParseLanguages(languages),
+	}, nil
+}
+
+// UpdateLanguageSettings stores an account's language preferences. Languages are normalized
+// to ISO 639 codes; an empty list shows posts in every language.
+func (db *DB) UpdateLanguageSettings(accountId uuid.UUID, settings *domain.LanguageSettings) error {
+	languages := util.ParseLanguages(strings.Join(settings.Languages, ","))
+	return db.wrapTransaction(func(tx *sql.Tx) error {
+		_, err := tx.Exec(sqlUpdateLanguageSettings, util.NormalizeLanguage(settings.DefaultLanguage), strings.Join(languages, ","), accountId.String())
+		return err
+	})
+}
+
+// ReadTimezone returns the IANA name of the timezone an account's timestamps are shown in,
+// or "" for UTC
+func (db *DB) ReadTimezone(accountId uuid.UUID) (string, error) {
+	var timezone string
+	err := db.conn().QueryRow(sqlSelectTimezone, accountId.String()).Scan(&timezone)
+	return timezone, err
+}
+
+// SetTimezone stores the timezone an account's timestamps are shown in. Timestamps stay
+// stored in UTC; an empty name resets the account to UTC.
+func (db *DB) SetTimezone(accountId uuid.UUID, timezone string) error {
+	timezone = strings.TrimSpace(timezone)
+	if _, err := util.LoadTimezone(timezone); err != nil {
+		return err
+	}
+	return db.wrapTransaction(func(tx *sql.Tx) error {
+		_, err := tx.Exec(sqlUpdateTimezone, timezone, accountId.String())
+		return err
+	})
+}
+
+// UpdateManuallyApprovesFollowers sets whether new followers of an account have to be
+// approved before their follow is accepted
+func (db *DB) UpdateManuallyApprovesFollowers(accountId uuid.UUID, enabled bool) error {
+	value := 0
+	if enabled {
+		value = 1
+	}
+	return db.wrapTransaction(func(tx *sql.Tx) error {
+		_, err := tx.Exec(sqlUpdateManuallyApprovesFollowers, value, accountId.String())
+		return err
+	})
+}
+
+// UpdateDiscoverable sets whether an account's posts are listed in the public timeline
+func (db *DB) UpdateDiscoverable(accountId uuid.UUID, enabled bool) error {
+	value := 0
+	if enabled {
+		value = 1
+	}
+	return db.wrapTransaction(func(tx *sql.Tx) error {
+		_, err := tx.Exec(sqlUpdateDiscoverable, value, accountId.String())
+		return err
+	})
+}
+
+// isDiscoverable resolves an account's discoverable column, which is NULL until the user
+// chooses, against the instance default
+func (db *DB) isDiscoverable(discoverable sql.NullInt64) bool {
+	if !discoverable.Valid {
+		return db.discoverableByDefault() == 1
+	}
+	return discoverable.Int64 == 1
+}
+
+// discoverableByDefault returns 1 if accounts that haven't chosen are listed in the public
+// timeline, 0 if the instance makes the public timeline opt-in
+func (db *DB) discoverableByDefault() int {
+	if db.conf != nil && db.conf.Conf.OptInPublicTimeline {
+		return 0
+	}
+	return 1
+}
+
+// ReadShowRepliesInHome reports whether an account shows replies to the accounts it follows
+// in its home timeline
+func (db *DB) ReadShowRepliesInHome(accountId uuid.UUID) (bool, error) {
+	var show int
+	if err := db.conn().QueryRow(sqlSelectShowRepliesInHome, accountId.String()).Scan(&show); err != nil {
+		return false, err
+	}
+	return show == 1, nil
+}
+
+// UpdateShowRepliesInHome sets whether an account's home timeline shows replies to the
+// accounts it follows
+func (db *DB) UpdateShowRepliesInHome(accountId uuid.UUID, enabled bool) error {
+	value := 0
+	if enabled {
+		value = 1
+	}
+	return db.wrapTransaction(func(tx *sql.Tx) error {
+		_, err := tx.Exec(sqlUpdateShowRepliesInHome, value, accountId.String())
+		return err
+	})
+}
+
+// homeFilter holds the preferences of an account that the home timeline queries apply
+type homeFilter struct {
+	languages   string // Comma-separated languages to show, "" for all
+	showReplies bool   // Replies to followed accounts are shown
+}
+
+// readHomeFilter reads an account's home timeline preferences, the defaults for unknown accounts
+func (db *DB) readHomeFilter(accountId uuid.UUID) (homeFilter, error) {
+	languages, err := db.readFilterLanguages(accountId)
+	if err != nil {
+		return homeFilter{}, err
+	}
+	showReplies, err := db.ReadShowRepliesInHome(accountId)
+	if err != nil && err != sql.ErrNoRows {
+		return homeFilter{}, err
+	}
+	return homeFilter{languages: languages, showReplies: showReplies}, nil
+}
+
+// localNotesArgs are the arguments of sqlSelectHomeLocalNotes and sqlSelectLocalTimelineNotesByFollows
+func (f homeFilter) localNotesArgs(accountId uuid.UUID, limit int) []any {
+	id := accountId.String()
+	return []any{id, id, id, f.languages, f.languages, f.showReplies, id, id, id, limit}
+}
+
+// remoteActivitiesArgs are the arguments of sqlSelectHomeRemoteActivities
+func (f homeFilter) remoteActivitiesArgs(accountId uuid.UUID, limit int) []any {
+	id := accountId.String()
+	return []any{id, f.languages, f.languages, f.showReplies, id, id, id, limit}
+}
+
+// readFilterLanguages returns the comma-separated languages an account wants to see in its
+// timelines, or "" to show all of them (also for unknown accounts)
+func (db *DB) readFilterLanguages(accountId uuid.UUID) (string, error) {
+	settings, err := db.ReadLanguageSettings(accountId)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(settings.Languages, ","), nil
+}
+
+func (db *DB) ReadAccBySession(s ssh.Session) (*domain.Account, error) {
 	publicKeyToString := util.PublicKeyToString(s.PublicKey())
 	var tempAcc domain.Account
 	var displayName, summary, avatarURL sql.NullString
-	var isAdmin, muted sql.NullInt64
-	row := db.db.QueryRow(sqlSelectUserByPublicKey, util.PkToHash(publicKeyToString))
-	err := row.Scan(&tempAcc.Id, &tempAcc.Username, &tempAcc.Publickey, &tempAcc.CreatedAt, &tempAcc.FirstTimeLogin, &tempAcc.WebPublicKey, &tempAcc.WebPrivateKey, &displayName, &summary, &avatarURL, &isAdmin, &muted)
+	var isAdmin, muted, locked, pending, disabled, discoverable sql.NullInt64
+	pkHash := util.PkToHash(publicKeyToString)
+	row := db.conn().QueryRow(sqlSelectUserByPublicKey, pkHash, pkHash)
+	err := row.Scan(&tempAcc.Id, &tempAcc.Username, &tempAcc.Publickey, &tempAcc.CreatedAt, &tempAcc.FirstTimeLogin, &tempAcc.WebPublicKey, &tempAcc.WebPrivateKey, &displayName, &summary, &avatarURL, &isAdmin, &muted, &locked, &pending, &disabled, &discoverable)
 	if err == sql.ErrNoRows {
-		return err, nil
+		return nil, err
 	}
 	tempAcc.DisplayName = displayName.String
 	tempAcc.Summary = summary.String
 	tempAcc.AvatarURL = avatarURL.String
 	tempAcc.IsAdmin = isAdmin.Int64 == 1
 	tempAcc.Muted = muted.Int64 == 1
-	return err, &tempAcc
+	tempAcc.ManuallyApprovesFollowers = locked.Int64 == 1
+	tempAcc.Pending = pending.Int64 == 1
+	tempAcc.Disabled = disabled.Int64 == 1
+	tempAcc.Discoverable = db.isDiscoverable(discoverable)
+	return &tempAcc, err
 }
 
-func (db *DB) ReadAccByPkHash(pkHash string) (error, *domain.Account) {
-	row := db.db.QueryRow(sqlSelectUserByPublicKey, pkHash)
+func (db *DB) ReadAccByPkHash(pkHash string) (*domain.Account, error) {
+	row := db.conn().QueryRow(sqlSelectUserByPublicKey, pkHash, pkHash)
 	var tempAcc domain.Account
 	var displayName, summary, avatarURL sql.NullString
-	var isAdmin, muted sql.NullInt64
-	err := row.Scan(&tempAcc.Id, &tempAcc.Username, &tempAcc.Publickey, &tempAcc.CreatedAt, &tempAcc.FirstTimeLogin, &tempAcc.WebPublicKey, &tempAcc.WebPrivateKey, &displayName, &summary, &avatarURL, &isAdmin, &muted)
+	var isAdmin, muted, locked, pending, disabled, discoverable sql.NullInt64
+	err := row.Scan(&tempAcc.Id, &tempAcc.Username, &tempAcc.Publickey, &tempAcc.CreatedAt, &tempAcc.FirstTimeLogin, &tempAcc.WebPublicKey, &tempAcc.WebPrivateKey, &displayName, &summary, &avatarURL, &isAdmin, &muted, &locked, &pending, &disabled, &discoverable)
 	if err == sql.ErrNoRows {
-		return err, nil
+		return nil, err
 	}
 	tempAcc.DisplayName = displayName.String
 	tempAcc.Summary = summary.String
 	tempAcc.AvatarURL = avatarURL.String
 	tempAcc.IsAdmin = isAdmin.Int64 == 1
 	tempAcc.Muted = muted.Int64 == 1
-	return err, &tempAcc
+	tempAcc.ManuallyApprovesFollowers = locked.Int64 == 1
+	tempAcc.Pending = pending.Int64 == 1
+	tempAcc.Disabled = disabled.Int64 == 1
+	tempAcc.Discoverable = db.isDiscoverable(discoverable)
+	return &tempAcc, err
 }
 
-func (db *DB) ReadAccById(id uuid.UUID) (error, *domain.Account) {
-	row := db.db.QueryRow(sqlSelectUserById, id)
+func (db *DB) ReadAccById(id uuid.UUID) (*domain.Account, error) {
+	row := db.conn().QueryRow(sqlSelectUserById, id)
 	var tempAcc domain.Account
 	var displayName, summary, avatarURL sql.NullString
-	var isAdmin, muted sql.NullInt64
-	err := row.Scan(&tempAcc.Id, &tempAcc.Username, &tempAcc.Publickey, &tempAcc.CreatedAt, &tempAcc.FirstTimeLogin, &tempAcc.WebPublicKey, &tempAcc.WebPrivateKey, &displayName, &summary, &avatarURL, &isAdmin, &muted)
+	var isAdmin, muted, locked, pending, disabled, discoverable sql.NullInt64
+	err := row.Scan(&tempAcc.Id, &tempAcc.Username, &tempAcc.Publickey, &tempAcc.CreatedAt, &tempAcc.FirstTimeLogin, &tempAcc.WebPublicKey, &tempAcc.WebPrivateKey, &displayName, &summary, &avatarURL, &isAdmin, &muted, &locked, &pending, &disabled, &discoverable)
 	if err == sql.ErrNoRows {
-		return err, nil
+		return nil, err
 	}
 	tempAcc.DisplayName = displayName.String
 	tempAcc.Summary = summary.String
 	tempAcc.AvatarURL = avatarURL.String
 	tempAcc.IsAdmin = isAdmin.Int64 == 1
 	tempAcc.Muted = muted.Int64 == 1
-	return err, &tempAcc
+	tempAcc.ManuallyApprovesFollowers = locked.Int64 == 1
+	tempAcc.Pending = pending.Int64 == 1
+	tempAcc.Disabled = disabled.Int64 == 1
+	tempAcc.Discoverable = db.isDiscoverable(discoverable)
+	return &tempAcc, err
 }
 
-func (db *DB) ReadAccByUsername(username string) (error, *domain.Account) {
-	row := db.db.QueryRow(sqlSelectUserByUsername, username)
+func (db *DB) ReadAccByUsername(username string) (*domain.Account, error) {
+	row := db.conn().QueryRow(sqlSelectUserByUsername, username)
 	var tempAcc domain.Account
 	var displayName, summary, avatarURL sql.NullString
-	var isAdmin, muted sql.NullInt64
-	err := row.Scan(&tempAcc.Id, &tempAcc.Username, &tempAcc.Publickey, &tempAcc.CreatedAt, &tempAcc.FirstTimeLogin, &tempAcc.WebPublicKey, &tempAcc.WebPrivateKey, &displayName, &summary, &avatarURL, &isAdmin, &muted)
+	var isAdmin, muted, locked, pending, disabled, discoverable sql.NullInt64
+	err := row.Scan(&tempAcc.Id, &tempAcc.Username, &tempAcc.Publickey, &tempAcc.CreatedAt, &tempAcc.FirstTimeLogin, &tempAcc.WebPublicKey, &tempAcc.WebPrivateKey, &displayName, &summary, &avatarURL, &isAdmin, &muted, &locked, &pending, &disabled, &discoverable)
 	if err == sql.ErrNoRows {
-		return err, nil
+		return nil, err
 	}
 	tempAcc.DisplayName = displayName.String
 	tempAcc.Summary = summary.String
 	tempAcc.AvatarURL = avatarURL.String
 	tempAcc.IsAdmin = isAdmin.Int64 == 1
 	tempAcc.Muted = muted.Int64 == 1
-	return err, &tempAcc
+	tempAcc.ManuallyApprovesFollowers = locked.Int64 == 1
+	tempAcc.Pending = pending.Int64 == 1
+	tempAcc.Disabled = disabled.Int64 == 1
+	tempAcc.Discoverable = db.isDiscoverable(discoverable)
+	return &tempAcc, err
+}
+
+// timestampFormat is how timestamps are stored: in UTC and in the form CURRENT_TIMESTAMP and
+// datetime('now') produce, plus fractional seconds, so stored timestamps sort and compare
+// correctly as text
+const timestampFormat = "2006-01-02 15:04:05.999999999"
+
+// timestampLayouts are the layouts parseTimestamp accepts, in the order they are tried
+var timestampLayouts = []string{
+	time.RFC3339Nano,                          // how the driver returns TIMESTAMP columns
+	timestampFormat,                           // as read from untyped expressions
+	"2006-01-02 15:04:05.999999999-07:00",     // the driver's own time format
+	"2006-01-02 15:04:05.999999999 -0700 MST", // time.Time.String(), which time parameters used to be stored as
 }
 
-// parseTimestamp parses a timestamp string from SQLite, handling both ISO 8601 and space-separated formats
-// SQLite driver returns timestamps with Z suffix even though they're stored in local time
+// formatTimestamp returns a time as it is stored in the database
+func formatTimestamp(t time.Time) string {
+	return t.UTC().Format(timestampFormat)
+}
+
+// parseTimestamp parses a timestamp read from the database. Timestamps without an offset are
+// UTC; the result is always in UTC.
 func parseTimestamp(timestampStr string) (time.Time, error) {
 	if timestampStr == "" {
 		return time.Time{}, fmt.Errorf("empty timestamp")
 	}
 
-	// Remove Z suffix and convert T to space for ISO 8601 format
-	if strings.HasSuffix(timestampStr, "Z") {
-		timestampStr = strings.TrimSuffix(timestampStr, "Z")
-		timestampStr = strings.Replace(timestampStr, "T", " ", 1)
+	// time.Time.String() appends the monotonic clock reading
+	if i := strings.Index(timestampStr, " m="); i >= 0 {
+		timestampStr = timestampStr[:i]
 	}
-
-	return time.ParseInLocation("2006-01-02 15:04:05", timestampStr, time.Local)
+	for _, layout := range timestampLayouts {
+		if t, err := time.Parse(layout, timestampStr); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unknown timestamp format %q", timestampStr)
 }
 
-func (db *DB) ReadNotesByUserId(userId uuid.UUID) (error, *[]domain.Note) {
-	rows, err := db.db.Query(sqlSelectNotesByUserId, userId)
+func (db *DB) ReadNotesByUserId(userId uuid.UUID) (*[]domain.Note, error) {
+	rows, err := db.conn().Query(sqlSelectNotesByUserId, userId)
 	if err != nil {
-		return err, nil
+		return nil, err
 	}
 	defer rows.Close()
 
@@ -294,8 +756,8 @@ func (db *DB) ReadNotesByUserId(userId uuid.UUID) (error, *[]domain.Note) {
 		var createdAtStr string
 		var editedAtStr sql.NullString
 		var inReplyToURI sql.NullString
-		if err := rows.Scan(&note.Id, &note.CreatedBy, &note.Message, &createdAtStr, &editedAtStr, &inReplyToURI, &note.LikeCount, &note.BoostCount); err != nil {
-			return err, &notes
+		if err := rows.Scan(&note.Id, &note.CreatedBy, &note.Message, &createdAtStr, &editedAtStr, &inReplyToURI, &note.LikeCount, &note.BoostCount, &note.Visibility); err != nil {
+			return &notes, err
 		}
 
 		if parsedTime, err := parseTimestamp(createdAtStr); err == nil {
@@ -315,16 +777,54 @@ func (db *DB) ReadNotesByUserId(userId uuid.UUID) (error, *[]domain.Note) {
 		notes = append(notes, note)
 	}
 	if err = rows.Err(); err != nil {
-		return err, &notes
+		return &notes, err
+	}
+
+	return &notes, nil
+}
+
+// ReadNotesPageByUserId returns one page of a user's notes of every visibility, newest first,
+// so callers can walk a prolific account without loading all of its notes at once
+func (db *DB) ReadNotesPageByUserId(userId uuid.UUID, limit, offset int) (*[]domain.Note, error) {
+	rows, err := db.conn().Query(sqlSelectNotesPageByUserId, userId.String(), limit, offset)
+	if err != nil {
+		return nil, err
 	}
+	defer rows.Close()
+
+	var notes []domain.Note
+	for rows.Next() {
+		var note domain.Note
+		var createdAtStr string
+		var editedAtStr, inReplyToURI, objectURI sql.NullString
+		if err := rows.Scan(&note.Id, &note.CreatedBy, &note.Message, &createdAtStr, &editedAtStr, &inReplyToURI, &note.Visibility, &objectURI, &note.Language); err != nil {
+			return &notes, err
+		}
+
+		if parsedTime, err := parseTimestamp(createdAtStr); err == nil {
+			note.CreatedAt = parsedTime
+		}
+		if editedAtStr.Valid {
+			if parsedTime, err := parseTimestamp(editedAtStr.String); err == nil {
+				note.EditedAt = &parsedTime
+			}
+		}
+		note.InReplyToURI = inReplyToURI.String
+		note.ObjectURI = objectURI.String
 
-	return nil, &notes
+		notes = append(notes, note)
+	}
+	if err = rows.Err(); err != nil {
+		return &notes, err
+	}
+	return &notes, nil
 }
 
-func (db *DB) ReadNotesByUsername(username string) (error, *[]domain.Note) {
-	rows, err := db.db.Query(sqlSelectNotesByUsername, username)
+// ReadNotesByUsername returns a user's notes for public feeds, excluding local-only notes
+func (db *DB) ReadNotesByUsername(username string) (*[]domain.Note, error) {
+	rows, err := db.conn().Query(sqlSelectNotesByUsername, username)
 	if err != nil {
-		return err, nil
+		return nil, err
 	}
 	defer rows.Close()
 
@@ -336,7 +836,7 @@ func (db *DB) ReadNotesByUsername(username string) (error, *[]domain.Note) {
 		var editedAtStr sql.NullString
 		var inReplyToURI sql.NullString
 		if err := rows.Scan(&note.Id, &note.CreatedBy, &note.Message, &createdAtStr, &editedAtStr, &inReplyToURI); err != nil {
-			return err, &notes
+			return &notes, err
 		}
 
 		if parsedTime, err := parseTimestamp(createdAtStr); err == nil {
@@ -356,29 +856,75 @@ func (db *DB) ReadNotesByUsername(username string) (error, *[]domain.Note) {
 		notes = append(notes, note)
 	}
 	if err = rows.Err(); err != nil {
-		return err, &notes
+		return &notes, err
+	}
+
+	return &notes, nil
+}
+
+// ReadNotesByUsernamePaged returns a page of a user's notes for their profile, newest first,
+// excluding local-only notes. Replies are left out unless includeReplies is set, like the
+// Posts and "Posts and replies" tabs of a Mastodon profile.
+func (db *DB) ReadNotesByUsernamePaged(username string, limit, offset int, includeReplies bool) (*[]domain.Note, error) {
+	rows, err := db.conn().Query(sqlSelectNotesByUsernamePaged, username, includeReplies, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notes []domain.Note
+	for rows.Next() {
+		var note domain.Note
+		var createdAtStr string
+		var editedAtStr sql.NullString
+		var inReplyToURI sql.NullString
+		if err := rows.Scan(&note.Id, &note.CreatedBy, &note.Message, &createdAtStr, &editedAtStr, &inReplyToURI, &note.LikeCount, &note.BoostCount); err != nil {
+			return &notes, err
+		}
+
+		if parsedTime, err := parseTimestamp(createdAtStr); err == nil {
+			note.CreatedAt = parsedTime
+		}
+		if editedAtStr.Valid {
+			if parsedTime, err := parseTimestamp(editedAtStr.String); err == nil {
+				note.EditedAt = &parsedTime
+			}
+		}
+		note.InReplyToURI = inReplyToURI.String
+
+		notes = append(notes, note)
+	}
+	if err = rows.Err(); err != nil {
+		return &notes, err
 	}
 
-	return nil, &notes
+	return &notes, nil
+}
+
+// CountNotesByUsername returns the number of notes ReadNotesByUsernamePaged pages through
+func (db *DB) CountNotesByUsername(username string, includeReplies bool) (int, error) {
+	var count int
+	err := db.conn().QueryRow(sqlCountNotesByUsername, username, includeReplies).Scan(&count)
+	return count, err
 }
 
-func (db *DB) ReadNoteId(id uuid.UUID) (error, *domain.Note) {
-	row := db.db.QueryRow(sqlSelectNoteById, id)
+func (db *DB) ReadNoteId(id uuid.UUID) (*domain.Note, error) {
+	row := db.conn().QueryRow(sqlSelectNoteById, id)
 	var note domain.Note
 	var createdAtStr string
-	var editedAtStr sql.NullString
-	err := row.Scan(&note.Id, &note.CreatedBy, &note.Message, &createdAtStr, &editedAtStr, &note.LikeCount, &note.BoostCount)
+	var editedAtStr, deletedAtStr sql.NullString
+	err := row.Scan(&note.Id, &note.CreatedBy, &note.Message, &createdAtStr, &editedAtStr, &note.LikeCount, &note.BoostCount, &note.Visibility, &deletedAtStr)
 	if err == sql.ErrNoRows {
-		return err, nil
+		return nil, err
 	}
 	if err != nil {
-		return err, nil
+		return nil, err
 	}
 
 	// Parse created_at timestamp
 	note.CreatedAt, err = parseTimestamp(createdAtStr)
 	if err != nil {
-		return err, nil
+		return nil, err
 	}
 
 	// Parse edited_at if present
@@ -387,13 +933,28 @@ func (db *DB) ReadNoteId(id uuid.UUID) (error, *domain.Note) {
 			note.EditedAt = &parsedTime
 		}
 	}
-	return nil, &note
+	note.DeletedAt = parseDeletedAt(deletedAtStr)
+	return &note, nil
 }
 
-func (db *DB) ReadAllNotes() (error, *[]domain.Note) {
-	rows, err := db.db.Query(sqlSelectAllNotes)
+// parseDeletedAt returns the deletion time of a tombstoned note, or nil for a live one
+func parseDeletedAt(deletedAtStr sql.NullString) *time.Time {
+	if !deletedAtStr.Valid {
+		return nil
+	}
+	deletedAt, err := parseTimestamp(deletedAtStr.String)
+	if err != nil {
+		return nil
+	}
+	return &deletedAt
+}
+
+// ReadAllNotes returns all notes for public feeds, excluding local-only notes and the notes of
+// accounts that aren't discoverable
+func (db *DB) ReadAllNotes() (*[]domain.Note, error) {
+	rows, err := db.conn().Query(sqlSelectAllNotes, db.discoverableByDefault())
 	if err != nil {
-		return err, nil
+		return nil, err
 	}
 	defer rows.Close()
 
@@ -405,7 +966,7 @@ func (db *DB) ReadAllNotes() (error, *[]domain.Note) {
 		var editedAtStr sql.NullString
 		var inReplyToURI sql.NullString
 		if err := rows.Scan(&note.Id, &note.CreatedBy, &note.Message, &createdAtStr, &editedAtStr, &inReplyToURI, &note.LikeCount, &note.BoostCount); err != nil {
-			return err, &notes
+			return &notes, err
 		}
 
 		if parsedTime, err := parseTimestamp(createdAtStr); err == nil {
@@ -425,10 +986,16 @@ func (db *DB) ReadAllNotes() (error, *[]domain.Note) {
 		notes = append(notes, note)
 	}
 	if err = rows.Err(); err != nil {
-		return err, &notes
+		return &notes, err
 	}
 
-	return nil, &notes
+	return &notes, nil
+}
+
+// Configure sets the configuration GetDB takes the database tuning from. It must be called
+// before the first GetDB; otherwise the defaults are used.
+func Configure(conf *util.AppConfig) {
+	dbConf = conf
 }
 
 func GetDB() *DB {
@@ -437,8 +1004,9 @@ func GetDB() *DB {
 		dbPath := util.ResolveFilePath("database.db")
 		log.Printf("Using database at: %s", dbPath)
 
-		// Open database connection
-		db, err := sql.Open("sqlite", dbPath)
+		// Open database connection; synchronous, cache_size and busy_timeout are
+		// set on every connection through the DSN
+		db, err := sql.Open("sqlite", pragmaDSN(dbPath, dbConf))
 		if err != nil {
 			panic(err)
 		}
@@ -464,17 +1032,14 @@ func GetDB() *DB {
 		}
 
 		// Optimize PRAGMAs for concurrent ActivityPub workload
-		// These need to be set as connection defaults
-		db.Exec("PRAGMA synchronous = NORMAL")      // Reduces fsync calls
-		db.Exec("PRAGMA cache_size = -64000")       // 64MB cache per connection
 		db.Exec("PRAGMA temp_store = MEMORY")       // Store temp tables in RAM
-		db.Exec("PRAGMA busy_timeout = 5000")       // Wait up to 5s for locks
 		db.Exec("PRAGMA foreign_keys = ON")         // Enable FK constraints
 		db.Exec("PRAGMA auto_vacuum = INCREMENTAL") // Better performance than FULL
 
 		log.Printf("Database initialized with connection pooling (max 25 connections)")
 
 		dbInstance = &DB{db: db}
+		dbInstance.logPragmas(dbConf)
 
 		// Run initial schema setup
 		err2 := dbInstance.CreateDB()
@@ -486,6 +1051,22 @@ func GetDB() *DB {
 	return dbInstance
 }
 
+// SetConfig sets the app configuration the database derives local object URIs from.
+// It must be called before RunMigrations so existing notes can be backfilled, and before
+// any note is created.
+func (db *DB) SetConfig(conf *util.AppConfig) {
+	db.conf = conf
+}
+
+// noteObjectURI returns the object URI to store for a new local note. It fails with
+// ErrNotConfigured if no configuration was set.
+func (db *DB) noteObjectURI(noteId uuid.UUID) (string, error) {
+	if db.conf == nil {
+		return "", ErrNotConfigured
+	}
+	return util.BuildNoteObjectURI(db.conf, noteId), nil
+}
+
 // CreateDB creates the database.
 func (db *DB) CreateDB() error {
 	return db.wrapTransaction(func(tx *sql.Tx) error {
@@ -534,60 +1115,96 @@ func (db *DB) insertUser(tx *sql.Tx, username string, publicKey string, webKeyPa
 		log.Println("Creating first user as admin:", username)
 	}
 
-	_, err = tx.Exec(sqlInsertUser, uuid.New(), username, util.PkToHash(publicKey), webKeyPair.Public, webKeyPair.Private, time.Now())
+	// Under the approval policy everyone but the first user waits for an admin
+	pending := 0
+	if count > 0 && db.conf.RegistrationPolicy() == util.RegistrationPolicyApproval {
+		pending = 1
+		log.Println("Creating pending user awaiting approval:", username)
+	}
+
+	_, err = tx.Exec(sqlInsertUser, uuid.New(), username, util.PkToHash(publicKey), webKeyPair.Public, webKeyPair.Private, formatTimestamp(time.Now()))
 	if err != nil {
 		return err
 	}
 
-	// Update is_admin for the newly created user
-	_, err = tx.Exec("UPDATE accounts SET is_admin = ? WHERE username = ?", isAdmin, username)
+	// Update is_admin and pending for the newly created user
+	_, err = tx.Exec("UPDATE accounts SET is_admin = ?, pending = ? WHERE username = ?", isAdmin, pending, username)
 	return err
 }
 
 func (db *DB) insertNote(tx *sql.Tx, userId uuid.UUID, message string) (uuid.UUID, error) {
-	return db.insertNoteWithReply(tx, userId, message, "")
+	return db.insertNoteWithReply(tx, userId, message, "", domain.VisibilityPublic)
 }
 
-func (db *DB) insertNoteWithReply(tx *sql.Tx, userId uuid.UUID, message string, inReplyToURI string) (uuid.UUID, error) {
+func (db *DB) insertNoteWithReply(tx *sql.Tx, userId uuid.UUID, message string, inReplyToURI string, visibility string) (uuid.UUID, error) {
 	noteId := uuid.New()
-	if inReplyToURI == "" {
-		_, err := tx.Exec(sqlInsertNote, noteId, userId, message, time.Now().Format("2006-01-02 15:04:05"))
-		return noteId, err
+	if visibility == "" {
+		visibility = domain.VisibilityPublic
+	}
+	threadRootURI := ""
+	if inReplyToURI != "" {
+		threadRootURI = db.threadRootURI(tx, inReplyToURI)
 	}
-	// Insert note with inReplyToURI
-	_, err := tx.Exec(`INSERT INTO notes(id, user_id, message, created_at, in_reply_to_uri) VALUES (?, ?, ?, ?, ?)`,
-		noteId, userId, message, time.Now().Format("2006-01-02 15:04:05"), inReplyToURI)
+	objectURI, err := db.noteObjectURI(noteId)
 	if err != nil {
 		return noteId, err
 	}
+	_, err = tx.Exec(sqlInsertNote, noteId, userId, message, formatTimestamp(time.Now()), inReplyToURI, threadRootURI, objectURI, visibility, userId)
+	if err != nil || inReplyToURI == "" {
+		return noteId, err
+	}
 
 	// Increment reply count on the parent (handles both notes and activities)
-	db.incrementReplyCount(tx, inReplyToURI)
+	if !db.threadReplyCounts() {
+		db.incrementReplyCount(tx, inReplyToURI)
+	}
 
 	return noteId, nil
 }
 
 func (db *DB) updateNote(tx *sql.Tx, noteId uuid.UUID, message string) error {
-	_, err := tx.Exec(sqlUpdateNote, message, time.Now().Format("2006-01-02 15:04:05"), noteId)
+	_, err := tx.Exec(sqlUpdateNote, message, formatTimestamp(time.Now()), noteId)
 	return err
 }
 
+// deleteNote turns a note into a tombstone: its content is blanked and deleted_at is set
 func (db *DB) deleteNote(tx *sql.Tx, noteId uuid.UUID) error {
 	// First, get the note's in_reply_to_uri so we can decrement the parent's count
-	var inReplyToURI sql.NullString
-	err := tx.QueryRow(`SELECT in_reply_to_uri FROM notes WHERE id = ?`, noteId.String()).Scan(&inReplyToURI)
+	var inReplyToURI, deletedAt sql.NullString
+	err := tx.QueryRow(`SELECT in_reply_to_uri, deleted_at FROM notes WHERE id = ?`, noteId.String()).Scan(&inReplyToURI, &deletedAt)
 	if err != nil && err != sql.ErrNoRows {
 		return err
 	}
+	if deletedAt.Valid {
+		// Already a tombstone
+		return nil
+	}
+
+	// Keep the row as a tombstone so replies can still resolve their parent;
+	// PurgeDeletedNotes removes it for good once the retention window has passed
+	_, err = tx.Exec(sqlTombstoneNote, formatTimestamp(time.Now()), noteId)
+	if err != nil {
+		return err
+	}
+
+	// A tombstone neither shows up under hashtags nor mentions anyone
+	_, err = tx.Exec(`DELETE FROM note_hashtags WHERE note_id = ?`, noteId.String())
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(sqlDeleteMentionsByNoteId, noteId.String())
+	if err != nil {
+		return err
+	}
 
-	// Delete the note
-	_, err = tx.Exec(sqlDeleteNote, noteId)
+	// Drop the stored outgoing Create for this note so /activities/{id} stops serving it
+	_, err = tx.Exec(sqlDeleteLocalActivitiesByNoteId, "%/notes/"+noteId.String())
 	if err != nil {
 		return err
 	}
 
 	// Decrement reply count on the parent if this was a reply
-	if inReplyToURI.Valid && inReplyToURI.String != "" {
+	if inReplyToURI.Valid && inReplyToURI.String != "" && !db.threadReplyCounts() {
 		db.decrementReplyCount(tx, inReplyToURI.String)
 	}
 
@@ -604,8 +1221,32 @@ func (db *DB) updateLoginUserById(tx *sql.Tx, username string, displayName strin
 	return err
 }
 
-// wrapTransaction runs the given function within a transaction.
+// wrapTransaction runs the given function within a transaction. A transaction that fails
+// with SQLITE_BUSY is rolled back and run again after a growing pause, at most
+// maxTxAttempts times in all; after that ErrDatabaseBusy is returned.
 func (db *DB) wrapTransaction(f func(tx *sql.Tx) error) error {
+	if db.tx != nil {
+		// Already inside WithTx: join its transaction, which commits or rolls back as a whole
+		return f(db.tx)
+	}
+	backoff := txRetryBackoff
+	for attempt := 1; ; attempt++ {
+		err := db.runTransaction(f)
+		if err == nil || !isBusy(err) {
+			return err
+		}
+		if attempt == maxTxAttempts {
+			log.Printf("error in transaction: still busy after %d attempts: %s", attempt, err)
+			return fmt.Errorf("%w after %d attempts: %v", ErrDatabaseBusy, attempt, err)
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// runTransaction runs f in a new transaction, which is committed if f succeeds and rolled
+// back otherwise
+func (db *DB) runTransaction(f func(tx *sql.Tx) error) error {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
 	defer cancel()
 	tx, err := db.db.BeginTx(ctx, nil)
@@ -613,32 +1254,85 @@ func (db *DB) wrapTransaction(f func(tx *sql.Tx) error) error {
 		log.Printf("error starting transaction: %s", err)
 		return err
 	}
-	for {
-		err = f(tx)
-		if err != nil {
-			serr, ok := err.(*sqlite.Error)
-			if ok && serr.Code() == sqlitelib.SQLITE_BUSY {
-				continue
-			}
+	if err := f(tx); err != nil {
+		tx.Rollback()
+		if !isBusy(err) {
 			log.Printf("error in transaction: %s", err)
-			return err
 		}
-		err = tx.Commit()
-		if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		if !isBusy(err) {
 			log.Printf("error committing transaction: %s", err)
-			return err
 		}
-		break
+		return err
+	}
+	return nil
+}
+
+// isBusy reports whether err is SQLITE_BUSY, including its extended result codes
+func isBusy(err error) bool {
+	var serr *sqlite.Error
+	return errors.As(err, &serr) && serr.Code()&0xff == sqlitelib.SQLITE_BUSY
+}
+
+// WithTx runs fn with a copy of the database whose operations all run in one transaction.
+// The transaction commits if fn returns nil and is rolled back otherwise, so a multi-step
+// write either happens completely or not at all. Calls nested in fn join the transaction.
+func (db *DB) WithTx(fn func(tx *DB) error) error {
+	if db.tx != nil {
+		return fn(db)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("error starting transaction: %s", err)
+		return err
+	}
+	txDB := &DB{db: db.db, tx: tx, conf: db.conf}
+	if err := fn(txDB); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("error committing transaction: %s", err)
+		return err
+	}
+	for _, f := range txDB.afterCommit {
+		f()
 	}
 	return nil
 }
 
+// onCommit runs f once the current write is committed: right away outside WithTx, after
+// the commit inside it, and never if the transaction is rolled back
+func (db *DB) onCommit(f func()) {
+	if db.tx == nil {
+		f()
+		return
+	}
+	db.afterCommit = append(db.afterCommit, f)
+}
+
 // Remote Accounts queries
 const (
-	sqlInsertRemoteAccount      = `INSERT INTO remote_accounts(id, username, domain, actor_uri, display_name, summary, inbox_uri, outbox_uri, public_key_pem, avatar_url, last_fetched_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
-	sqlSelectRemoteAccountByURI = `SELECT id, username, domain, actor_uri, display_name, summary, inbox_uri, outbox_uri, public_key_pem, avatar_url, last_fetched_at FROM remote_accounts WHERE actor_uri = ?`
-	sqlSelectRemoteAccountById  = `SELECT id, username, domain, actor_uri, display_name, summary, inbox_uri, outbox_uri, public_key_pem, avatar_url, last_fetched_at FROM remote_accounts WHERE id = ?`
-	sqlUpdateRemoteAccount      = `UPDATE remote_accounts SET display_name = ?, summary = ?, inbox_uri = ?, outbox_uri = ?, public_key_pem = ?, avatar_url = ?, last_fetched_at = ? WHERE actor_uri = ?`
+	sqlInsertRemoteAccount       = `INSERT INTO remote_accounts(id, username, domain, actor_uri, display_name, summary, inbox_uri, shared_inbox_uri, outbox_uri, public_key_pem, avatar_url, last_fetched_at, public_keys) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, NULLIF(?, ''))`
+	sqlSelectRemoteAccountByURI  = `SELECT id, username, domain, actor_uri, display_name, summary, inbox_uri, COALESCE(shared_inbox_uri, ''), outbox_uri, public_key_pem, avatar_url, last_fetched_at, COALESCE(public_keys, '') FROM remote_accounts WHERE actor_uri = ?`
+	sqlSelectRemoteAccountById   = `SELECT id, username, domain, actor_uri, display_name, summary, inbox_uri, COALESCE(shared_inbox_uri, ''), outbox_uri, public_key_pem, avatar_url, last_fetched_at, COALESCE(public_keys, '') FROM remote_accounts WHERE id = ?`
+	sqlSelectRemoteAccountByAcct = `SELECT id, username, domain, actor_uri, display_name, summary, inbox_uri, COALESCE(shared_inbox_uri, ''), outbox_uri, public_key_pem, avatar_url, last_fetched_at, COALESCE(public_keys, '') FROM remote_accounts WHERE username = ? COLLATE NOCASE AND domain = ? COLLATE NOCASE`
+	sqlUpdateRemoteAccount       = `UPDATE remote_accounts SET display_name = ?, summary = ?, inbox_uri = ?, shared_inbox_uri = ?, outbox_uri = ?, public_key_pem = ?, avatar_url = ?, last_fetched_at = ?, public_keys = NULLIF(?, '') WHERE actor_uri = ?`
+	// Remote accounts nothing points to: no follows either way, activities, likes, boosts,
+	// notifications, mentions or relay subscription
+	sqlDeleteUnreferencedRemoteAccounts = `DELETE FROM remote_accounts
+		WHERE last_fetched_at < ?
+		AND NOT EXISTS (SELECT 1 FROM follows f WHERE f.account_id = remote_accounts.id OR f.target_account_id = remote_accounts.id)
+		AND NOT EXISTS (SELECT 1 FROM activities a WHERE a.actor_uri = remote_accounts.actor_uri)
+		AND NOT EXISTS (SELECT 1 FROM likes l WHERE l.account_id = remote_accounts.id)
+		AND NOT EXISTS (SELECT 1 FROM boosts b WHERE b.account_id = remote_accounts.id)
+		AND NOT EXISTS (SELECT 1 FROM notifications n WHERE n.actor_id = remote_accounts.id)
+		AND NOT EXISTS (SELECT 1 FROM note_mentions m WHERE m.mentioned_actor_uri = remote_accounts.actor_uri)
+		AND NOT EXISTS (SELECT 1 FROM relays r WHERE r.actor_uri = remote_accounts.actor_uri)`
 )
 
 func (db *DB) CreateRemoteAccount(acc *domain.RemoteAccount) error {
@@ -651,19 +1345,21 @@ func (db *DB) CreateRemoteAccount(acc *domain.RemoteAccount) error {
 			acc.DisplayName,
 			acc.Summary,
 			acc.InboxURI,
+			acc.SharedInboxURI,
 			acc.OutboxURI,
 			acc.PublicKeyPem,
 			acc.AvatarURL,
-			acc.LastFetchedAt,
+			formatTimestamp(acc.LastFetchedAt),
+			encodePublicKeys(acc.PublicKeys),
 		)
 		return err
 	})
 }
 
-func (db *DB) ReadRemoteAccountByURI(uri string) (error, *domain.RemoteAccount) {
-	row := db.db.QueryRow(sqlSelectRemoteAccountByURI, uri)
+func (db *DB) ReadRemoteAccountByURI(uri string) (*domain.RemoteAccount, error) {
+	row := db.conn().QueryRow(sqlSelectRemoteAccountByURI, uri)
 	var acc domain.RemoteAccount
-	var idStr string
+	var idStr, publicKeys string
 	err := row.Scan(
 		&idStr,
 		&acc.Username,
@@ -672,25 +1368,28 @@ func (db *DB) ReadRemoteAccountByURI(uri string) (error, *domain.RemoteAccount)
 		&acc.DisplayName,
 		&acc.Summary,
 		&acc.InboxURI,
+		&acc.SharedInboxURI,
 		&acc.OutboxURI,
 		&acc.PublicKeyPem,
 		&acc.AvatarURL,
 		&acc.LastFetchedAt,
+		&publicKeys,
 	)
 	if err == sql.ErrNoRows {
-		return err, nil
+		return nil, err
 	}
 	if err != nil {
-		return err, nil
+		return nil, err
 	}
 	acc.Id, _ = uuid.Parse(idStr)
-	return nil, &acc
+	acc.PublicKeys = decodePublicKeys(publicKeys)
+	return &acc, nil
 }
 
-func (db *DB) ReadRemoteAccountById(id uuid.UUID) (error, *domain.RemoteAccount) {
-	row := db.db.QueryRow(sqlSelectRemoteAccountById, id.String())
+func (db *DB) ReadRemoteAccountById(id uuid.UUID) (*domain.RemoteAccount, error) {
+	row := db.conn().QueryRow(sqlSelectRemoteAccountById, id.String())
 	var acc domain.RemoteAccount
-	var idStr string
+	var idStr, publicKeys string
 	err := row.Scan(
 		&idStr,
 		&acc.Username,
@@ -699,19 +1398,87 @@ func (db *DB) ReadRemoteAccountById(id uuid.UUID) (error, *domain.RemoteAccount)
 		&acc.DisplayName,
 		&acc.Summary,
 		&acc.InboxURI,
+		&acc.SharedInboxURI,
 		&acc.OutboxURI,
 		&acc.PublicKeyPem,
 		&acc.AvatarURL,
 		&acc.LastFetchedAt,
+		&publicKeys,
 	)
 	if err == sql.ErrNoRows {
-		return err, nil
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
 	}
+	acc.Id, _ = uuid.Parse(idStr)
+	acc.PublicKeys = decodePublicKeys(publicKeys)
+	return &acc, nil
+}
+
+// ReadRemoteAccountByUsernameAndDomain finds a cached remote account by its acct (username@domain)
+func (db *DB) ReadRemoteAccountByUsernameAndDomain(username, domainName string) (*domain.RemoteAccount, error) {
+	row := db.conn().QueryRow(sqlSelectRemoteAccountByAcct, username, domainName)
+	var acc domain.RemoteAccount
+	var idStr, publicKeys string
+	err := row.Scan(
+		&idStr,
+		&acc.Username,
+		&acc.Domain,
+		&acc.ActorURI,
+		&acc.DisplayName,
+		&acc.Summary,
+		&acc.InboxURI,
+		&acc.SharedInboxURI,
+		&acc.OutboxURI,
+		&acc.PublicKeyPem,
+		&acc.AvatarURL,
+		&acc.LastFetchedAt,
+		&publicKeys,
+	)
 	if err != nil {
-		return err, nil
+		return nil, err
 	}
 	acc.Id, _ = uuid.Parse(idStr)
-	return nil, &acc
+	acc.PublicKeys = decodePublicKeys(publicKeys)
+	return &acc, nil
+}
+
+// Account search: local accounts first, then cached remote accounts, each by username.
+// LIKE is case-insensitive for ASCII; the ESCAPE clause keeps % and _ in queries literal.
+const sqlSearchAccounts = `SELECT id, username, '' AS domain, COALESCE(display_name, ''), '' AS actor_uri, 0 AS remote FROM accounts
+		WHERE first_time_login = 0 AND (username LIKE ? ESCAPE '\' OR display_name LIKE ? ESCAPE '\')
+	UNION ALL
+	SELECT id, username, domain, COALESCE(display_name, ''), actor_uri, 1 AS remote FROM remote_accounts
+		WHERE username LIKE ? ESCAPE '\' OR display_name LIKE ? ESCAPE '\'
+	ORDER BY remote ASC, username COLLATE NOCASE ASC, domain ASC
+	LIMIT ?`
+
+// SearchAccounts finds local accounts and cached remote accounts whose username or display
+// name contains query. Local accounts are listed first.
+func (db *DB) SearchAccounts(query string, limit int) (*[]domain.AccountSearchResult, error) {
+	pattern := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(query) + "%"
+	rows, err := db.conn().Query(sqlSearchAccounts, pattern, pattern, pattern, pattern, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []domain.AccountSearchResult
+	for rows.Next() {
+		var result domain.AccountSearchResult
+		var idStr string
+		var remote int
+		if err := rows.Scan(&idStr, &result.Username, &result.Domain, &result.DisplayName, &result.ActorURI, &remote); err != nil {
+			return &results, err
+		}
+		result.Id, _ = uuid.Parse(idStr)
+		results = append(results, result)
+	}
+	if err = rows.Err(); err != nil {
+		return &results, err
+	}
+	return &results, nil
 }
 
 func (db *DB) UpdateRemoteAccount(acc *domain.RemoteAccount) error {
@@ -720,21 +1487,48 @@ func (db *DB) UpdateRemoteAccount(acc *domain.RemoteAccount) error {
 			acc.DisplayName,
 			acc.Summary,
 			acc.InboxURI,
+			acc.SharedInboxURI,
 			acc.OutboxURI,
 			acc.PublicKeyPem,
 			acc.AvatarURL,
-			acc.LastFetchedAt,
+			formatTimestamp(acc.LastFetchedAt),
+			encodePublicKeys(acc.PublicKeys),
 			acc.ActorURI,
 		)
 		return err
 	})
 }
 
+// encodePublicKeys stores the keys of an actor with several keys as JSON, or "" for none
+func encodePublicKeys(keys map[string]string) string {
+	if len(keys) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(keys)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// decodePublicKeys reads keys stored by encodePublicKeys
+func decodePublicKeys(data string) map[string]string {
+	if data == "" {
+		return nil
+	}
+	var keys map[string]string
+	if err := json.Unmarshal([]byte(data), &keys); err != nil {
+		log.Printf("Failed to decode stored public keys: %v", err)
+		return nil
+	}
+	return keys
+}
+
 // ReadAllRemoteAccounts returns all cached remote accounts for autocomplete
-func (db *DB) ReadAllRemoteAccounts() (error, []domain.RemoteAccount) {
-	rows, err := db.db.Query(`SELECT id, username, domain, actor_uri, display_name, summary, inbox_uri, outbox_uri, public_key_pem, avatar_url, last_fetched_at FROM remote_accounts ORDER BY username`)
+func (db *DB) ReadAllRemoteAccounts() ([]domain.RemoteAccount, error) {
+	rows, err := db.conn().Query(`SELECT id, username, domain, actor_uri, display_name, summary, inbox_uri, outbox_uri, public_key_pem, avatar_url, last_fetched_at, COALESCE(shared_inbox_uri, '') FROM remote_accounts ORDER BY username`)
 	if err != nil {
-		return err, nil
+		return nil, err
 	}
 	defer rows.Close()
 
@@ -754,14 +1548,15 @@ func (db *DB) ReadAllRemoteAccounts() (error, []domain.RemoteAccount) {
 			&acc.PublicKeyPem,
 			&acc.AvatarURL,
 			&acc.LastFetchedAt,
+			&acc.SharedInboxURI,
 		)
 		if err != nil {
-			return err, nil
+			return nil, err
 		}
 		acc.Id, _ = uuid.Parse(idStr)
 		accounts = append(accounts, acc)
 	}
-	return nil, accounts
+	return accounts, nil
 }
 
 // Follow queries
@@ -772,7 +1567,9 @@ const (
 	sqlSelectLocalFollowsByAccountId = `SELECT id, account_id, target_account_id, uri, accepted, created_at FROM follows WHERE account_id = ? AND is_local = 1 AND accepted = 1`
 	sqlDeleteLocalFollow             = `DELETE FROM follows WHERE account_id = ? AND target_account_id = ? AND is_local = 1`
 	sqlCheckLocalFollow              = `SELECT COUNT(*) FROM follows WHERE account_id = ? AND target_account_id = ? AND is_local = 1`
-	sqlSelectFollowByAccountIds      = `SELECT id, account_id, target_account_id, uri, accepted, created_at FROM follows WHERE account_id = ? AND target_account_id = ?`
+	sqlSelectFollowByAccountIds      = `SELECT id, account_id, target_account_id, uri, accepted, created_at, COALESCE(notify, 0) FROM follows WHERE account_id = ? AND target_account_id = ?`
+	sqlCheckRemoteAccountFollowed    = `SELECT COUNT(*) FROM follows WHERE target_account_id = ? AND accepted = 1`
+	sqlUpdateFollowNotify            = `UPDATE follows SET notify = ? WHERE account_id = ? AND target_account_id = ?`
 )
 
 func (db *DB) CreateFollow(follow *domain.Follow) error {
@@ -787,15 +1584,15 @@ func (db *DB) CreateFollow(follow *domain.Follow) error {
 			follow.TargetAccountId.String(),
 			follow.URI,
 			follow.Accepted,
-			follow.CreatedAt,
+			formatTimestamp(follow.CreatedAt),
 			isLocal,
 		)
 		return err
 	})
 }
 
-func (db *DB) ReadFollowByURI(uri string) (error, *domain.Follow) {
-	row := db.db.QueryRow(sqlSelectFollowByURI, uri)
+func (db *DB) ReadFollowByURI(uri string) (*domain.Follow, error) {
+	row := db.conn().QueryRow(sqlSelectFollowByURI, uri)
 	var follow domain.Follow
 	var idStr, accountIdStr, targetIdStr string
 	err := row.Scan(
@@ -807,19 +1604,19 @@ func (db *DB) ReadFollowByURI(uri string) (error, *domain.Follow) {
 		&follow.CreatedAt,
 	)
 	if err == sql.ErrNoRows {
-		return err, nil
+		return nil, err
 	}
 	if err != nil {
-		return err, nil
+		return nil, err
 	}
 	follow.Id, _ = uuid.Parse(idStr)
 	follow.AccountId, _ = uuid.Parse(accountIdStr)
 	follow.TargetAccountId, _ = uuid.Parse(targetIdStr)
-	return nil, &follow
+	return &follow, nil
 }
 
-func (db *DB) ReadFollowByAccountIds(accountId, targetAccountId uuid.UUID) (error, *domain.Follow) {
-	row := db.db.QueryRow(sqlSelectFollowByAccountIds, accountId.String(), targetAccountId.String())
+func (db *DB) ReadFollowByAccountIds(accountId, targetAccountId uuid.UUID) (*domain.Follow, error) {
+	row := db.conn().QueryRow(sqlSelectFollowByAccountIds, accountId.String(), targetAccountId.String())
 	var follow domain.Follow
 	var idStr, accountIdStr, targetIdStr string
 	err := row.Scan(
@@ -829,17 +1626,41 @@ func (db *DB) ReadFollowByAccountIds(accountId, targetAccountId uuid.UUID) (erro
 		&follow.URI,
 		&follow.Accepted,
 		&follow.CreatedAt,
+		&follow.Notify,
 	)
 	if err == sql.ErrNoRows {
-		return err, nil
+		return nil, err
 	}
 	if err != nil {
-		return err, nil
+		return nil, err
 	}
 	follow.Id, _ = uuid.Parse(idStr)
 	follow.AccountId, _ = uuid.Parse(accountIdStr)
 	follow.TargetAccountId, _ = uuid.Parse(targetIdStr)
-	return nil, &follow
+	return &follow, nil
+}
+
+// UpdateFollowNotify turns notifications for every top-level post of the followed account
+// on or off
+func (db *DB) UpdateFollowNotify(accountId, targetAccountId uuid.UUID, notify bool) error {
+	notifyInt := 0
+	if notify {
+		notifyInt = 1
+	}
+	return db.wrapTransaction(func(tx *sql.Tx) error {
+		_, err := tx.Exec(sqlUpdateFollowNotify, notifyInt, accountId.String(), targetAccountId.String())
+		return err
+	})
+}
+
+// IsRemoteAccountFollowed checks if any local account has an accepted follow of the remote account
+func (db *DB) IsRemoteAccountFollowed(remoteAccountId uuid.UUID) (bool, error) {
+	var count int
+	err := db.conn().QueryRow(sqlCheckRemoteAccountFollowed, remoteAccountId.String()).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
 }
 
 func (db *DB) DeleteFollowByURI(uri string) error {
@@ -894,12 +1715,15 @@ func (db *DB) CleanupOrphanedFollows() error {
 
 // Activity queries
 const (
-	sqlInsertActivity      = `INSERT INTO activities(id, activity_uri, activity_type, actor_uri, object_uri, raw_json, processed, local, created_at, from_relay) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
-	sqlUpdateActivity      = `UPDATE activities SET raw_json = ?, processed = ?, object_uri = ? WHERE id = ?`
-	sqlSelectActivityByURI = `SELECT id, activity_uri, activity_type, actor_uri, object_uri, raw_json, processed, local, created_at FROM activities WHERE activity_uri = ?`
+	sqlInsertActivity                = `INSERT INTO activities(id, activity_uri, activity_type, actor_uri, object_uri, raw_json, processed, local, created_at, from_relay, language, thread_root_uri, content_html, content_text, title, restricted) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, ''), ?)`
+	sqlUpdateActivity                = `UPDATE activities SET raw_json = ?, processed = ?, object_uri = ?, content_html = NULLIF(?, ''), content_text = NULLIF(?, ''), title = NULLIF(?, '') WHERE id = ?`
+	sqlSelectActivityByURI           = `SELECT id, activity_uri, activity_type, actor_uri, object_uri, raw_json, processed, local, created_at FROM activities WHERE activity_uri = ?`
+	sqlDeleteLocalActivitiesByNoteId = `DELETE FROM activities WHERE local = 1 AND activity_type = 'Create' AND object_uri LIKE ?`
+	sqlUpdateActivityQuote           = `UPDATE activities SET quote_uri = ?, quote_author = ?, quote_content = ? WHERE id = ?`
 )
 
 func (db *DB) CreateActivity(activity *domain.Activity) error {
+	contentHTML, contentText, title := activityContent(activity.RawJSON)
 	return db.wrapTransaction(func(tx *sql.Tx) error {
 		_, err := tx.Exec(sqlInsertActivity,
 			activity.Id.String(),
@@ -910,27 +1734,78 @@ func (db *DB) CreateActivity(activity *domain.Activity) error {
 			activity.RawJSON,
 			activity.Processed,
 			activity.Local,
-			activity.CreatedAt.Format("2006-01-02 15:04:05"),
+			formatTimestamp(activity.CreatedAt),
 			activity.FromRelay,
+			activity.Language,
+			db.activityThreadRootURI(tx, activity),
+			contentHTML,
+			contentText,
+			title,
+			activity.Restricted,
 		)
 		return err
 	})
 }
 
+// activityContent returns the sanitized HTML, the plain text and the title of the object of
+// an incoming activity (see util.RemotePostBody), or "" for those it doesn't have. Only the
+// sanitized HTML is ever rendered; the plain text starts with the title, so searches find it.
+func activityContent(rawJSON string) (contentHTML, contentText, title string) {
+	title, body := util.RemotePostBody(rawJSON)
+	if body != "" {
+		contentHTML, contentText = util.SanitizeHTML(body), util.HTMLToPlainText(body)
+	}
+	if title != "" {
+		contentText = strings.TrimSpace(title + "\n\n" + contentText)
+	}
+	return contentHTML, contentText, title
+}
+
+// activityThreadRootURI returns the thread root of a remote reply, or "" for activities that
+// are not replies or that are copies of local notes (our own posts coming back)
+func (db *DB) activityThreadRootURI(tx *sql.Tx, activity *domain.Activity) string {
+	if activity.ActivityType != "Create" || activity.Local {
+		return ""
+	}
+	inReplyTo := extractInReplyToFromJSON(activity.RawJSON)
+	if inReplyTo == "" {
+		return ""
+	}
+	var localCopies int
+	tx.QueryRow(`SELECT COUNT(*) FROM notes WHERE object_uri = ?`, activity.ObjectURI).Scan(&localCopies)
+	if localCopies > 0 {
+		return ""
+	}
+	return db.threadRootURI(tx, inReplyTo)
+}
+
+// UpdateActivityQuote records the post a quote post quotes: its object URI and, when it could
+// be resolved, its author (@user@domain) and plain-text content for display
+func (db *DB) UpdateActivityQuote(activityId uuid.UUID, quoteURI, quoteAuthor, quoteContent string) error {
+	return db.wrapTransaction(func(tx *sql.Tx) error {
+		_, err := tx.Exec(sqlUpdateActivityQuote, quoteURI, quoteAuthor, quoteContent, activityId.String())
+		return err
+	})
+}
+
 func (db *DB) UpdateActivity(activity *domain.Activity) error {
+	contentHTML, contentText, title := activityContent(activity.RawJSON)
 	return db.wrapTransaction(func(tx *sql.Tx) error {
 		_, err := tx.Exec(sqlUpdateActivity,
 			activity.RawJSON,
 			activity.Processed,
 			activity.ObjectURI,
+			contentHTML,
+			contentText,
+			title,
 			activity.Id.String(),
 		)
 		return err
 	})
 }
 
-func (db *DB) ReadActivityByURI(uri string) (error, *domain.Activity) {
-	row := db.db.QueryRow(sqlSelectActivityByURI, uri)
+func (db *DB) ReadActivityByURI(uri string) (*domain.Activity, error) {
+	row := db.conn().QueryRow(sqlSelectActivityByURI, uri)
 	var activity domain.Activity
 	var idStr string
 	err := row.Scan(
@@ -945,26 +1820,26 @@ func (db *DB) ReadActivityByURI(uri string) (error, *domain.Activity) {
 		&activity.CreatedAt,
 	)
 	if err == sql.ErrNoRows {
-		return err, nil
+		return nil, err
 	}
 	if err != nil {
-		return err, nil
+		return nil, err
 	}
 	activity.Id, _ = uuid.Parse(idStr)
-	return nil, &activity
+	return &activity, nil
 }
 
 // ReadActivityByObjectURI reads an activity by the object URI
 // First tries exact match on object_uri column, falls back to searching raw_json for older activities
-func (db *DB) ReadActivityByObjectURI(objectURI string) (error, *domain.Activity) {
+func (db *DB) ReadActivityByObjectURI(objectURI string) (*domain.Activity, error) {
 	var activity domain.Activity
 	var idStr, actorURIStr string
 
 	// First try exact match on object_uri column (faster and more reliable)
-	err := db.db.QueryRow(
+	err := db.conn().QueryRow(
 		`SELECT id, activity_uri, activity_type, actor_uri, raw_json, processed, local, created_at, COALESCE(like_count, 0), COALESCE(boost_count, 0)
 		 FROM activities
-		 WHERE activity_type = 'Create' AND object_uri = ?
+		 WHERE activity_type = 'Create' AND local = 0 AND object_uri = ?
 		 ORDER BY created_at DESC
 		 LIMIT 1`,
 		objectURI,
@@ -975,12 +1850,12 @@ func (db *DB) ReadActivityByObjectURI(objectURI string) (error, *domain.Activity
 		activity.Id, _ = uuid.Parse(idStr)
 		activity.ActorURI = actorURIStr
 		activity.ObjectURI = objectURI
-		return nil, &activity
+		return &activity, nil
 	}
 
 	// If not found by column, fall back to LIKE search in raw_json for older activities
 	if err.Error() != "sql: no rows in result set" {
-		return err, nil
+		return nil, err
 	}
 
 	// Escape LIKE special characters to prevent wildcard injection
@@ -990,10 +1865,10 @@ func (db *DB) ReadActivityByObjectURI(objectURI string) (error, *domain.Activity
 
 	// Search for CREATE activities where the raw JSON contains the object URI
 	// Filter by activity_type='Create' to avoid finding Update/Delete activities
-	err = db.db.QueryRow(
+	err = db.conn().QueryRow(
 		`SELECT id, activity_uri, activity_type, actor_uri, raw_json, processed, local, created_at, COALESCE(like_count, 0), COALESCE(boost_count, 0)
 		 FROM activities
-		 WHERE activity_type = 'Create' AND raw_json LIKE ? ESCAPE '\'
+		 WHERE activity_type = 'Create' AND local = 0 AND raw_json LIKE ? ESCAPE '\'
 		 ORDER BY created_at DESC
 		 LIMIT 1`,
 		"%\"id\":\""+escapedURI+"\"%",
@@ -1004,30 +1879,30 @@ func (db *DB) ReadActivityByObjectURI(objectURI string) (error, *domain.Activity
 		if err.Error() == "sql: no rows in result set" {
 			return nil, nil
 		}
-		return err, nil
+		return nil, err
 	}
 
 	activity.Id, _ = uuid.Parse(idStr)
 	activity.ActorURI = actorURIStr
 
-	return nil, &activity
+	return &activity, nil
 }
 
 // ReadFederatedActivities returns recent Create activities from remote actors
 const (
-	sqlSelectFederatedActivities          = `SELECT id, activity_uri, activity_type, actor_uri, object_uri, raw_json, processed, local, created_at FROM activities WHERE activity_type = 'Create' AND local = 0 ORDER BY created_at DESC LIMIT ?`
+	sqlSelectFederatedActivities          = `SELECT id, activity_uri, activity_type, actor_uri, object_uri, raw_json, processed, local, created_at FROM activities WHERE activity_type = 'Create' AND local = 0 AND COALESCE(restricted, 0) = 0 ORDER BY created_at DESC LIMIT ?`
 	sqlSelectFederatedActivitiesByFollows = `SELECT a.id, a.activity_uri, a.activity_type, a.actor_uri, a.object_uri, a.raw_json, a.processed, a.local, a.created_at
 		FROM activities a
 		INNER JOIN remote_accounts ra ON ra.actor_uri = a.actor_uri
 		INNER JOIN follows f ON f.target_account_id = ra.id
-		WHERE a.activity_type = 'Create' AND a.local = 0 AND f.account_id = ? AND f.accepted = 1 AND f.is_local = 0
+		WHERE a.activity_type = 'Create' AND a.local = 0 AND COALESCE(a.restricted, 0) = 0 AND f.account_id = ? AND f.accepted = 1 AND f.is_local = 0
 		ORDER BY a.created_at DESC LIMIT ?`
 )
 
-func (db *DB) ReadFederatedActivities(accountId uuid.UUID, limit int) (error, *[]domain.Activity) {
-	rows, err := db.db.Query(sqlSelectFederatedActivitiesByFollows, accountId.String(), limit)
+func (db *DB) ReadFederatedActivities(accountId uuid.UUID, limit int) (*[]domain.Activity, error) {
+	rows, err := db.conn().Query(sqlSelectFederatedActivitiesByFollows, accountId.String(), limit)
 	if err != nil {
-		return err, nil
+		return nil, err
 	}
 	defer rows.Close()
 
@@ -1037,7 +1912,7 @@ func (db *DB) ReadFederatedActivities(accountId uuid.UUID, limit int) (error, *[
 		var idStr string
 		var createdAtStr string
 		if err := rows.Scan(&idStr, &activity.ActivityURI, &activity.ActivityType, &activity.ActorURI, &activity.ObjectURI, &activity.RawJSON, &activity.Processed, &activity.Local, &createdAtStr); err != nil {
-			return err, &activities
+			return &activities, err
 		}
 		activity.Id, _ = uuid.Parse(idStr)
 
@@ -1048,45 +1923,248 @@ func (db *DB) ReadFederatedActivities(accountId uuid.UUID, limit int) (error, *[
 		activities = append(activities, activity)
 	}
 	if err = rows.Err(); err != nil {
-		return err, &activities
+		return &activities, err
 	}
-	return nil, &activities
+	return &activities, nil
 }
 
 // Home Timeline queries - combines local notes and remote activities
 const (
-	// Local notes for home timeline: own posts + posts from followed local users (excluding replies)
+	// Replies are only shown to accounts that enabled show_replies_in_home, and only when the
+	// post replied to is by the account itself or someone it follows, so no reply shows up
+	// without its context. Both take the account id three times; local parents are referenced
+	// either by object URI or as local:<note id>.
+	sqlNoteRepliesToFollowed = `EXISTS (SELECT 1 FROM notes p
+			WHERE (p.object_uri = notes.in_reply_to_uri OR (notes.in_reply_to_uri LIKE 'local:%' AND p.id = substr(notes.in_reply_to_uri, 7, 36)))
+			AND (p.user_id = ? OR p.user_id IN (SELECT target_account_id FROM follows WHERE account_id = ? AND accepted = 1 AND is_local = 1))
+		UNION ALL SELECT 1 FROM activities p
+			INNER JOIN remote_accounts pra ON pra.actor_uri = p.actor_uri
+			INNER JOIN follows pf ON pf.target_account_id = pra.id
+			WHERE p.object_uri = notes.in_reply_to_uri AND p.activity_type = 'Create' AND pf.account_id = ? AND pf.accepted = 1 AND pf.is_local = 0)`
+	sqlActivityRepliesToFollowed = `EXISTS (SELECT 1 FROM notes p
+			WHERE p.object_uri = json_extract(a.raw_json, '$.object.inReplyTo')
+			AND (p.user_id = ? OR p.user_id IN (SELECT target_account_id FROM follows WHERE account_id = ? AND accepted = 1 AND is_local = 1))
+		UNION ALL SELECT 1 FROM activities p
+			INNER JOIN remote_accounts pra ON pra.actor_uri = p.actor_uri
+			INNER JOIN follows pf ON pf.target_account_id = pra.id
+			WHERE p.object_uri = json_extract(a.raw_json, '$.object.inReplyTo') AND p.activity_type = 'Create' AND pf.account_id = ? AND pf.accepted = 1 AND pf.is_local = 0)`
+
+	// Self-threads, replies whose chain of parents leads to a top-level post with every post
+	// by the same author, are shown whether or not replies are; the timeline collapses them
+	// into the thread's first post (see collapseSelfThreads). Neither takes parameters.
+	sqlNoteSelfThread = `EXISTS (WITH RECURSIVE chain(parent_uri) AS (
+			SELECT notes.in_reply_to_uri
+			UNION
+			SELECT p.in_reply_to_uri FROM notes p
+				INNER JOIN chain ON p.object_uri = chain.parent_uri OR (chain.parent_uri LIKE 'local:%' AND p.id = substr(chain.parent_uri, 7, 36))
+				WHERE p.user_id = notes.user_id)
+		SELECT 1 FROM chain WHERE parent_uri IS NULL OR parent_uri = '')`
+	sqlActivitySelfThread = `EXISTS (WITH RECURSIVE chain(parent_uri) AS (
+			SELECT json_extract(a.raw_json, '$.object.inReplyTo')
+			UNION
+			SELECT json_extract(p.raw_json, '$.object.inReplyTo') FROM activities p
+				INNER JOIN chain ON p.object_uri = chain.parent_uri
+				WHERE p.activity_type = 'Create' AND p.actor_uri = a.actor_uri AND json_valid(p.raw_json))
+		SELECT 1 FROM chain WHERE parent_uri IS NULL OR parent_uri = '')`
+
+	// Local notes for home timeline: own posts + posts from followed local users (excluding replies
+	// other than self-threads unless the account shows them, see sqlNoteRepliesToFollowed)
 	// Includes reply_count, like_count, and boost_count for denormalized counts
-	sqlSelectHomeLocalNotes = `SELECT notes.id, accounts.username, notes.message, notes.created_at, notes.object_uri, COALESCE(notes.reply_count, 0), COALESCE(notes.like_count, 0), COALESCE(notes.boost_count, 0) FROM notes
+	// Deleted notes with replies stay in the timeline as "[deleted]" so their threads can still be opened
+	sqlSelectHomeLocalNotes = `SELECT notes.id, accounts.username, CASE WHEN notes.deleted_at IS NULL THEN notes.message ELSE '[deleted]' END, notes.created_at, notes.object_uri, COALESCE(notes.reply_count, 0), COALESCE(notes.like_count, 0), COALESCE(notes.boost_count, 0),
+		COALESCE(notes.in_reply_to_uri, '') FROM notes
 		INNER JOIN accounts ON accounts.id = notes.user_id
-		WHERE (notes.in_reply_to_uri IS NULL OR notes.in_reply_to_uri = '')
+		WHERE (notes.deleted_at IS NULL OR COALESCE(notes.reply_count, 0) > 0)
 		AND (notes.user_id = ? OR notes.user_id IN (
 			SELECT target_account_id FROM follows
 			WHERE account_id = ? AND accepted = 1 AND is_local = 1
 		))
+		AND (notes.user_id = ? OR ? = '' OR COALESCE(notes.language, '') = '' OR instr(',' || ? || ',', ',' || notes.language || ',') > 0)
+		AND (notes.in_reply_to_uri IS NULL OR notes.in_reply_to_uri = '' OR ` + sqlNoteSelfThread + ` OR (? = 1 AND ` + sqlNoteRepliesToFollowed + `))
 		ORDER BY notes.created_at DESC LIMIT ?`
 
-	// Remote activities for home timeline: posts from followed remote users
-	// Excludes replies (activities where inReplyTo has a URL value, not null)
+	// Remote activities for home timeline: posts from followed remote users that aren't muted or restricted
+	// Excludes replies (activities where inReplyTo has a URL value, not null) other than
+	// self-threads unless the account shows them, see sqlActivityRepliesToFollowed
 	// Top-level posts have "inReplyTo":null, replies have "inReplyTo":"https://..."
 	// Includes reply_count for denormalized reply counting
-	sqlSelectHomeRemoteActivities = `SELECT a.id, a.actor_uri, a.object_uri, a.raw_json, a.created_at, ra.username, ra.domain, COALESCE(a.reply_count, 0), COALESCE(a.like_count, 0), COALESCE(a.boost_count, 0)
+	sqlSelectHomeRemoteActivities = `SELECT a.id, a.actor_uri, a.object_uri, a.raw_json, a.created_at, ra.username, ra.domain, COALESCE(a.reply_count, 0), COALESCE(a.like_count, 0), COALESCE(a.boost_count, 0),
+		COALESCE(a.quote_uri, ''), COALESCE(a.quote_author, ''), COALESCE(a.quote_content, ''), COALESCE(a.content_html, ''), COALESCE(a.title, '')
 		FROM activities a
 		INNER JOIN remote_accounts ra ON ra.actor_uri = a.actor_uri
 		INNER JOIN follows f ON f.target_account_id = ra.id
-		WHERE a.activity_type = 'Create' AND a.local = 0 AND f.account_id = ? AND f.accepted = 1 AND f.is_local = 0
+		WHERE a.activity_type = 'Create' AND a.local = 0 AND COALESCE(a.restricted, 0) = 0 AND f.account_id = ? AND f.accepted = 1 AND f.is_local = 0
+		AND NOT EXISTS (SELECT 1 FROM muted_accounts m WHERE m.account_id = f.account_id AND m.target_actor_uri = a.actor_uri
+			AND (m.expires_at IS NULL OR m.expires_at > datetime('now')))
+		AND (? = '' OR COALESCE(a.language, '') = '' OR instr(',' || ? || ',', ',' || a.language || ',') > 0)
+		AND (a.raw_json NOT LIKE '%"inReplyTo":"http%' OR (json_valid(a.raw_json) AND (` + sqlActivitySelfThread + ` OR (? = 1 AND ` + sqlActivityRepliesToFollowed + `))))
+		ORDER BY a.created_at DESC LIMIT ?`
+
+	// Relay-forwarded activities for home timeline (marked with from_relay = 1), excluding replies
+	sqlSelectHomeRelayActivities = `SELECT a.id, a.actor_uri, a.object_uri, a.raw_json, a.created_at, COALESCE(a.reply_count, 0), COALESCE(a.like_count, 0), COALESCE(a.boost_count, 0),
+		COALESCE(a.quote_uri, ''), COALESCE(a.quote_author, ''), COALESCE(a.quote_content, ''), COALESCE(a.content_html, ''), COALESCE(a.title, '')
+		FROM activities a
+		WHERE a.activity_type = 'Create' AND a.local = 0 AND a.from_relay = 1 AND COALESCE(a.restricted, 0) = 0
 		AND a.raw_json NOT LIKE '%"inReplyTo":"http%'
+		AND (? = '' OR COALESCE(a.language, '') = '' OR instr(',' || ? || ',', ',' || a.language || ',') > 0)
 		ORDER BY a.created_at DESC LIMIT ?`
+
+	// All three sources in one query for the union home timeline query strategy. Each source
+	// keeps its own filters and limit; the combined rows are ordered and limited once more, so
+	// only the posts that make the page are returned. Columns are named through the CTEs as
+	// the source queries leave some unnamed.
+	sqlSelectHomeTimelineUnion = `WITH
+		local(id, username, message, created_at, object_uri, reply_count, like_count, boost_count, in_reply_to_uri) AS (%s),
+		remote(id, actor_uri, object_uri, raw_json, created_at, username, domain, reply_count, like_count, boost_count, quote_uri, quote_author, quote_content, content_html, title) AS (%s),
+		relay(id, actor_uri, object_uri, raw_json, created_at, reply_count, like_count, boost_count, quote_uri, quote_author, quote_content, content_html, title) AS (%s)
+		SELECT 'local' AS source, id, username, '' AS domain, '' AS actor_uri, message, '' AS raw_json, created_at, COALESCE(object_uri, '') AS object_uri, reply_count, like_count, boost_count, '' AS quote_uri, '' AS quote_author, '' AS quote_content, '' AS content_html, '' AS title, in_reply_to_uri FROM local
+		UNION ALL
+		SELECT 'remote', id, username, domain, actor_uri, '', raw_json, created_at, object_uri, reply_count, like_count, boost_count, quote_uri, quote_author, quote_content, content_html, title, '' FROM remote
+		UNION ALL
+		SELECT 'relay', id, '', '', actor_uri, '', raw_json, created_at, object_uri, reply_count, like_count, boost_count, quote_uri, quote_author, quote_content, content_html, title, '' FROM relay
+		ORDER BY created_at DESC LIMIT ?`
+
+	// Boosts of local notes by followed, unmuted remote users, one row per boost (newest first).
+	// Rows are grouped per note in readHomeBoostedPosts.
+	sqlSelectHomeBoostedNotes = `SELECT notes.id, accounts.username, notes.message, notes.object_uri, COALESCE(notes.reply_count, 0), COALESCE(notes.like_count, 0), COALESCE(notes.boost_count, 0),
+		'@' || ra.username || '@' || ra.domain, b.created_at
+		FROM boosts b
+		INNER JOIN remote_accounts ra ON ra.id = b.account_id
+		INNER JOIN follows f ON f.target_account_id = ra.id
+		INNER JOIN notes ON notes.id = b.note_id
+		INNER JOIN accounts ON accounts.id = notes.user_id
+		WHERE f.account_id = ? AND f.accepted = 1 AND f.is_local = 0
+		AND NOT EXISTS (SELECT 1 FROM muted_accounts m WHERE m.account_id = f.account_id AND m.target_actor_uri = ra.actor_uri
+			AND (m.expires_at IS NULL OR m.expires_at > datetime('now')))
+		AND notes.deleted_at IS NULL
+		AND (notes.in_reply_to_uri IS NULL OR notes.in_reply_to_uri = '')
+		AND (? = '' OR COALESCE(notes.language, '') = '' OR instr(',' || ? || ',', ',' || notes.language || ',') > 0)
+		ORDER BY b.created_at DESC LIMIT ?`
 )
 
 // ReadHomeTimelinePosts returns a unified home timeline combining local and remote posts
-func (db *DB) ReadHomeTimelinePosts(accountId uuid.UUID, limit int) (error, *[]domain.HomePost) {
+func (db *DB) ReadHomeTimelinePosts(accountId uuid.UUID, limit int) (*[]domain.HomePost, error) {
+	// Posts in languages the account doesn't want to see are skipped; untagged posts always show.
+	// Replies only show if the account asked for them.
+	filter, err := db.readHomeFilter(accountId)
+	if err != nil {
+		return nil, err
+	}
+
+	var posts []domain.HomePost
+	if db.unionHomeTimeline() {
+		posts, err = db.readHomeDirectPostsUnion(accountId, filter, limit)
+	} else {
+		posts, err = db.readHomeDirectPosts(accountId, filter, limit)
+	}
+	if err != nil {
+		return &posts, err
+	}
+
+	// Boosts by followed users are collapsed per post and merged with the direct posts
+	boosted, err := db.readHomeBoostedPosts(accountId, filter.languages, limit)
+	if err != nil {
+		return &posts, err
+	}
+	if db.unionHomeTimeline() {
+		if boosted, err = db.dropBoostsOfTruncatedPosts(accountId, filter, limit, posts, boosted); err != nil {
+			return &posts, err
+		}
+	}
+	posts = mergeBoostedPosts(posts, boosted)
+
+	// Sort combined posts by time (newest first)
+	sortPostsByTime(posts)
+
+	// Limit to requested amount
+	if len(posts) > limit {
+		posts = posts[:limit]
+	}
+
+	// Self-threads are collapsed after limiting, so both query strategies collapse the same page
+	posts = collapseSelfThreads(posts)
+
+	if err := db.markLikedPosts(accountId, posts); err != nil {
+		return &posts, err
+	}
+
+	// Reactions are only received for local posts, so only those are looked up
+	for i := range posts {
+		if posts[i].NoteID == uuid.Nil || posts[i].ObjectURI == "" {
+			continue
+		}
+		if reactions, err := db.ReadReactionsByObjectURI(posts[i].ObjectURI); err == nil {
+			posts[i].Reactions = *reactions
+		}
+	}
+
+	return &posts, nil
+}
+
+// markLikedPosts sets LikedByMe on the posts the account liked, looking up the whole page at
+// once. Local notes are liked by note id, remote posts by their object URI.
+func (db *DB) markLikedPosts(accountId uuid.UUID, posts []domain.HomePost) error {
+	if len(posts) == 0 {
+		return nil
+	}
+	var noteIds, objectURIs []any
+	for _, post := range posts {
+		if post.NoteID != uuid.Nil {
+			noteIds = append(noteIds, post.NoteID.String())
+		}
+		if post.ObjectURI != "" {
+			objectURIs = append(objectURIs, post.ObjectURI)
+		}
+	}
+
+	query := `SELECT note_id, COALESCE(object_uri, '') FROM likes WHERE account_id = ? AND (note_id IN (` + sqlPlaceholders(len(noteIds)) + `) OR object_uri IN (` + sqlPlaceholders(len(objectURIs)) + `))`
+	args := append([]any{accountId.String()}, noteIds...)
+	rows, err := db.conn().Query(query, append(args, objectURIs...)...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	liked := make(map[string]bool)
+	for rows.Next() {
+		var noteId, objectURI string
+		if err := rows.Scan(&noteId, &objectURI); err != nil {
+			return err
+		}
+		liked[noteId] = true
+		if objectURI != "" {
+			liked[objectURI] = true
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i := range posts {
+		posts[i].LikedByMe = (posts[i].NoteID != uuid.Nil && liked[posts[i].NoteID.String()]) ||
+			(posts[i].ObjectURI != "" && liked[posts[i].ObjectURI])
+	}
+	return nil
+}
+
+// sqlPlaceholders returns n comma-separated ? placeholders for an IN list. An empty list
+// yields NULL, which matches nothing.
+func sqlPlaceholders(n int) string {
+	if n == 0 {
+		return "NULL"
+	}
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
+
+// readHomeDirectPosts reads up to limit posts from each home timeline source: local notes,
+// followed remote users and relays. The caller merges, sorts and limits them.
+func (db *DB) readHomeDirectPosts(accountId uuid.UUID, filter homeFilter, limit int) ([]domain.HomePost, error) {
 	var posts []domain.HomePost
 
-	// Fetch local notes (already excludes replies via sqlSelectHomeLocalNotes WHERE clause)
-	localRows, err := db.db.Query(sqlSelectHomeLocalNotes, accountId.String(), accountId.String(), limit)
+	// Fetch local notes (replies are filtered by sqlSelectHomeLocalNotes WHERE clause)
+	localRows, err := db.conn().Query(db.replyCountQuery(sqlSelectHomeLocalNotes, sqlNoteReplyCount, "notes.object_uri"), filter.localNotesArgs(accountId, limit)...)
 	if err != nil {
-		return err, nil
+		return nil, err
 	}
 	defer localRows.Close()
 
@@ -1099,9 +2177,10 @@ func (db *DB) ReadHomeTimelinePosts(accountId uuid.UUID, limit int) (error, *[]d
 		var replyCount int
 		var likeCount int
 		var boostCount int
+		var inReplyToURI string
 
-		if err := localRows.Scan(&idStr, &username, &message, &createdAtStr, &objectURI, &replyCount, &likeCount, &boostCount); err != nil {
-			return err, &posts
+		if err := localRows.Scan(&idStr, &username, &message, &createdAtStr, &objectURI, &replyCount, &likeCount, &boostCount, &inReplyToURI); err != nil {
+			return posts, err
 		}
 
 		noteId, _ := uuid.Parse(idStr)
@@ -1113,26 +2192,27 @@ func (db *DB) ReadHomeTimelinePosts(accountId uuid.UUID, limit int) (error, *[]d
 		}
 
 		posts = append(posts, domain.HomePost{
-			ID:         noteId,
-			Author:     username,
-			Content:    message,
-			Time:       parsedTime,
-			ObjectURI:  uri,
-			IsLocal:    true,
-			NoteID:     noteId,
-			ReplyCount: replyCount,
-			LikeCount:  likeCount,
-			BoostCount: boostCount,
+			ID:           noteId,
+			Author:       username,
+			Content:      message,
+			Time:         parsedTime,
+			ObjectURI:    uri,
+			InReplyToURI: inReplyToURI,
+			IsLocal:      true,
+			NoteID:       noteId,
+			ReplyCount:   replyCount,
+			LikeCount:    likeCount,
+			BoostCount:   boostCount,
 		})
 	}
 	if err = localRows.Err(); err != nil {
-		return err, &posts
+		return posts, err
 	}
 
-	// Fetch remote activities (query excludes all replies - only top-level posts)
-	remoteRows, err := db.db.Query(sqlSelectHomeRemoteActivities, accountId.String(), limit)
+	// Fetch remote activities (top-level posts, plus replies to followed accounts if shown)
+	remoteRows, err := db.conn().Query(db.replyCountQuery(sqlSelectHomeRemoteActivities, sqlActivityReplyCount, "a.object_uri"), filter.remoteActivitiesArgs(accountId, limit)...)
 	if err != nil {
-		return err, &posts
+		return posts, err
 	}
 	defer remoteRows.Close()
 
@@ -1147,44 +2227,44 @@ func (db *DB) ReadHomeTimelinePosts(accountId uuid.UUID, limit int) (error, *[]d
 		var replyCount int
 		var likeCount int
 		var boostCount int
+		var quoteURI, quoteAuthor, quoteContent, contentHTML, title string
 
-		if err := remoteRows.Scan(&idStr, &actorURI, &objectURI, &rawJSON, &createdAtStr, &username, &remDomain, &replyCount, &likeCount, &boostCount); err != nil {
-			return err, &posts
+		if err := remoteRows.Scan(&idStr, &actorURI, &objectURI, &rawJSON, &createdAtStr, &username, &remDomain, &replyCount, &likeCount, &boostCount, &quoteURI, &quoteAuthor, &quoteContent, &contentHTML, &title); err != nil {
+			return posts, err
 		}
 
 		activityId, _ := uuid.Parse(idStr)
 		parsedTime, _ := parseTimestamp(createdAtStr)
 
-		// Extract content from raw JSON
-		content := extractContentFromJSON(rawJSON)
+		content := remotePostContent(contentHTML, rawJSON)
 
 		posts = append(posts, domain.HomePost{
-			ID:         activityId,
-			Author:     "@" + username + "@" + remDomain,
-			Content:    content,
-			Time:       parsedTime,
-			ObjectURI:  objectURI,
-			IsLocal:    false,
-			NoteID:     uuid.Nil,
-			ReplyCount: replyCount,
-			LikeCount:  likeCount,
-			BoostCount: boostCount,
+			ID:           activityId,
+			Author:       "@" + username + "@" + remDomain,
+			Title:        title,
+			Content:      content,
+			Time:         parsedTime,
+			ObjectURI:    objectURI,
+			InReplyToURI: extractInReplyToFromJSON(rawJSON),
+			IsLocal:      false,
+			NoteID:       uuid.Nil,
+			ReplyCount:   replyCount,
+			LikeCount:    likeCount,
+			BoostCount:   boostCount,
+			QuoteURI:     quoteURI,
+			QuoteAuthor:  quoteAuthor,
+			QuoteContent: quoteContent,
 		})
 	}
 	if err = remoteRows.Err(); err != nil {
-		return err, &posts
+		return posts, err
 	}
 
 	// Fetch relay-forwarded activities (marked with from_relay = 1)
 	// These come from both FediBuzz (Announce-wrapped) and YUKIMOCHI (raw Create) relays
-	relayRows, err := db.db.Query(`
-		SELECT a.id, a.actor_uri, a.object_uri, a.raw_json, a.created_at, COALESCE(a.reply_count, 0), COALESCE(a.like_count, 0), COALESCE(a.boost_count, 0)
-		FROM activities a
-		WHERE a.activity_type = 'Create' AND a.local = 0 AND a.from_relay = 1
-		AND a.raw_json NOT LIKE '%"inReplyTo":"http%'
-		ORDER BY a.created_at DESC LIMIT ?`, limit)
+	relayRows, err := db.conn().Query(db.replyCountQuery(sqlSelectHomeRelayActivities, sqlActivityReplyCount, "a.object_uri"), filter.languages, filter.languages, limit)
 	if err != nil {
-		return err, &posts
+		return posts, err
 	}
 	defer relayRows.Close()
 
@@ -1197,75 +2277,309 @@ func (db *DB) ReadHomeTimelinePosts(accountId uuid.UUID, limit int) (error, *[]d
 		var replyCount int
 		var likeCount int
 		var boostCount int
+		var quoteURI, quoteAuthor, quoteContent, contentHTML, title string
 
-		if err := relayRows.Scan(&idStr, &actorURI, &objectURI, &rawJSON, &createdAtStr, &replyCount, &likeCount, &boostCount); err != nil {
-			return err, &posts
+		if err := relayRows.Scan(&idStr, &actorURI, &objectURI, &rawJSON, &createdAtStr, &replyCount, &likeCount, &boostCount, &quoteURI, &quoteAuthor, &quoteContent, &contentHTML, &title); err != nil {
+			return posts, err
 		}
 
 		activityId, _ := uuid.Parse(idStr)
 		parsedTime, _ := parseTimestamp(createdAtStr)
 
-		// Extract content from raw JSON
-		content := extractContentFromJSON(rawJSON)
+		content := remotePostContent(contentHTML, rawJSON)
 
 		// Extract author info from actorURI (format: https://domain/users/username)
 		author := extractAuthorFromActorURI(actorURI)
 
 		posts = append(posts, domain.HomePost{
-			ID:         activityId,
-			Author:     author,
-			Content:    content,
-			Time:       parsedTime,
-			ObjectURI:  objectURI,
-			IsLocal:    false,
-			NoteID:     uuid.Nil,
-			ReplyCount: replyCount,
-			LikeCount:  likeCount,
-			BoostCount: boostCount,
+			ID:           activityId,
+			Author:       author,
+			Title:        title,
+			Content:      content,
+			Time:         parsedTime,
+			ObjectURI:    objectURI,
+			IsLocal:      false,
+			NoteID:       uuid.Nil,
+			ReplyCount:   replyCount,
+			LikeCount:    likeCount,
+			BoostCount:   boostCount,
+			QuoteURI:     quoteURI,
+			QuoteAuthor:  quoteAuthor,
+			QuoteContent: quoteContent,
 		})
 	}
 	if err = relayRows.Err(); err != nil {
-		return err, &posts
-	}
-
-	// Sort combined posts by time (newest first)
-	sortPostsByTime(posts)
-
-	// Limit to requested amount
-	if len(posts) > limit {
-		posts = posts[:limit]
+		return posts, err
 	}
 
-	return nil, &posts
+	return posts, nil
 }
 
-// extractContentFromJSON extracts content from ActivityPub Create activity JSON
-func extractContentFromJSON(rawJSON string) string {
-	// Properly unmarshal JSON to extract content
-	var activityWrapper struct {
-		Type   string `json:"type"`
-		Object struct {
-			ID      string `json:"id"`
-			Content string `json:"content"`
-		} `json:"object"`
-	}
+// unionHomeTimeline reports whether the home timeline is read with a single UNION query
+// (util.HomeTimelineQueryUnion) instead of one query per source merged in Go
+func (db *DB) unionHomeTimeline() bool {
+	return db.conf != nil && db.conf.Conf.HomeTimelineQuery == util.HomeTimelineQueryUnion
+}
 
-	if err := json.Unmarshal([]byte(rawJSON), &activityWrapper); err != nil {
-		// Fallback to simple string parsing if JSON unmarshal fails
-		if idx := strings.Index(rawJSON, `"content":"`); idx >= 0 {
-			start := idx + len(`"content":"`)
-			end := strings.Index(rawJSON[start:], `"`)
-			if end > 0 {
-				content := rawJSON[start : start+end]
-				return util.StripHTMLTags(content)
-			}
-		}
-		return ""
+// readHomeDirectPostsUnion reads the same posts as readHomeDirectPosts in a single query,
+// already ordered and limited by SQLite, so at most limit rows are returned instead of up
+// to limit per source
+func (db *DB) readHomeDirectPostsUnion(accountId uuid.UUID, filter homeFilter, limit int) ([]domain.HomePost, error) {
+	query := fmt.Sprintf(sqlSelectHomeTimelineUnion,
+		db.replyCountQuery(sqlSelectHomeLocalNotes, sqlNoteReplyCount, "notes.object_uri"),
+		db.replyCountQuery(sqlSelectHomeRemoteActivities, sqlActivityReplyCount, "a.object_uri"),
+		db.replyCountQuery(sqlSelectHomeRelayActivities, sqlActivityReplyCount, "a.object_uri"))
+	args := append(filter.localNotesArgs(accountId, limit), filter.remoteActivitiesArgs(accountId, limit)...)
+	args = append(args, filter.languages, filter.languages, limit, limit)
+	rows, err := db.conn().Query(query, args...)
+	if err != nil {
+		return nil, err
 	}
+	defer rows.Close()
 
-	// Skip if content is empty
-	if activityWrapper.Object.Content == "" {
-		return ""
+	var posts []domain.HomePost
+	for rows.Next() {
+		var source string
+		var idStr string
+		var username, remDomain, actorURI string
+		var message, rawJSON string
+		var createdAtStr string
+		var objectURI string
+		var replyCount int
+		var likeCount int
+		var boostCount int
+		var quoteURI, quoteAuthor, quoteContent, contentHTML, title string
+		var inReplyToURI string
+
+		if err := rows.Scan(&source, &idStr, &username, &remDomain, &actorURI, &message, &rawJSON, &createdAtStr, &objectURI,
+			&replyCount, &likeCount, &boostCount, &quoteURI, &quoteAuthor, &quoteContent, &contentHTML, &title, &inReplyToURI); err != nil {
+			return posts, err
+		}
+
+		id, _ := uuid.Parse(idStr)
+		parsedTime, _ := parseTimestamp(createdAtStr)
+
+		post := domain.HomePost{
+			ID:           id,
+			Time:         parsedTime,
+			ObjectURI:    objectURI,
+			ReplyCount:   replyCount,
+			LikeCount:    likeCount,
+			BoostCount:   boostCount,
+			QuoteURI:     quoteURI,
+			QuoteAuthor:  quoteAuthor,
+			QuoteContent: quoteContent,
+		}
+		switch source {
+		case "local":
+			post.Author = username
+			post.Content = message
+			post.IsLocal = true
+			post.NoteID = id
+			post.InReplyToURI = inReplyToURI
+		case "remote":
+			post.Author = "@" + username + "@" + remDomain
+			post.Title = title
+			post.Content = remotePostContent(contentHTML, rawJSON)
+			post.InReplyToURI = extractInReplyToFromJSON(rawJSON)
+		default:
+			post.Author = extractAuthorFromActorURI(actorURI)
+			post.Title = title
+			post.Content = remotePostContent(contentHTML, rawJSON)
+		}
+		posts = append(posts, post)
+	}
+	return posts, rows.Err()
+}
+
+// dropBoostsOfTruncatedPosts keeps the union query's timeline identical to the merged one.
+// When merging, a boost of a local note that was read as a direct post but falls outside the
+// limit is folded into that post and cut with it; the union query never returns such notes,
+// so their boosts are dropped here instead of showing up on their own. Only a full page with
+// a boost newer than its oldest post can be affected, so the local notes are rarely re-read.
+func (db *DB) dropBoostsOfTruncatedPosts(accountId uuid.UUID, filter homeFilter, limit int, posts, boosted []domain.HomePost) ([]domain.HomePost, error) {
+	if len(posts) < limit {
+		return boosted, nil
+	}
+	oldest := posts[len(posts)-1].Time
+	listed := func(b domain.HomePost) bool {
+		return slices.ContainsFunc(posts, func(p domain.HomePost) bool {
+			return p.ID == b.ID || (b.ObjectURI != "" && p.ObjectURI == b.ObjectURI)
+		})
+	}
+	if !slices.ContainsFunc(boosted, func(b domain.HomePost) bool { return !b.Time.Before(oldest) && !listed(b) }) {
+		return boosted, nil
+	}
+
+	rows, err := db.conn().Query(`SELECT id FROM (`+db.replyCountQuery(sqlSelectHomeLocalNotes, sqlNoteReplyCount, "notes.object_uri")+`)`,
+		filter.localNotesArgs(accountId, limit)...)
+	if err != nil {
+		return boosted, err
+	}
+	defer rows.Close()
+
+	truncated := make(map[uuid.UUID]bool)
+	for rows.Next() {
+		var idStr string
+		if err := rows.Scan(&idStr); err != nil {
+			return boosted, err
+		}
+		noteId, _ := uuid.Parse(idStr)
+		truncated[noteId] = true
+	}
+	if err := rows.Err(); err != nil {
+		return boosted, err
+	}
+	return slices.DeleteFunc(boosted, func(b domain.HomePost) bool { return truncated[b.ID] && !listed(b) }), nil
+}
+
+// readHomeBoostedPosts returns the local notes boosted by accounts the user follows, one entry per
+// note listing all its boosters. Each entry is timed by its most recent boost.
+func (db *DB) readHomeBoostedPosts(accountId uuid.UUID, languages string, limit int) ([]domain.HomePost, error) {
+	rows, err := db.conn().Query(db.replyCountQuery(sqlSelectHomeBoostedNotes, sqlNoteReplyCount, "notes.object_uri"), accountId.String(), languages, languages, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var posts []domain.HomePost
+	byNote := make(map[uuid.UUID]int)
+	for rows.Next() {
+		var idStr string
+		var username string
+		var message string
+		var objectURI sql.NullString
+		var replyCount int
+		var likeCount int
+		var boostCount int
+		var booster string
+		var boostedAt time.Time
+
+		if err := rows.Scan(&idStr, &username, &message, &objectURI, &replyCount, &likeCount, &boostCount, &booster, &boostedAt); err != nil {
+			return posts, err
+		}
+
+		noteId, _ := uuid.Parse(idStr)
+
+		// Rows are newest first, so the first boost of a note sets its time
+		if i, ok := byNote[noteId]; ok {
+			posts[i].BoostedBy = append(posts[i].BoostedBy, booster)
+			continue
+		}
+		byNote[noteId] = len(posts)
+		posts = append(posts, domain.HomePost{
+			ID:         noteId,
+			Author:     username,
+			Content:    message,
+			Time:       boostedAt,
+			ObjectURI:  objectURI.String,
+			IsLocal:    true,
+			NoteID:     noteId,
+			ReplyCount: replyCount,
+			LikeCount:  likeCount,
+			BoostCount: boostCount,
+			BoostedBy:  []string{booster},
+		})
+	}
+	return posts, rows.Err()
+}
+
+// mergeBoostedPosts adds boosted posts to the timeline. A boosted post that is already on the
+// timeline as a direct post is not repeated: the direct post keeps its place and lists the boosters.
+func mergeBoostedPosts(posts, boosted []domain.HomePost) []domain.HomePost {
+	for _, b := range boosted {
+		i := slices.IndexFunc(posts, func(p domain.HomePost) bool {
+			return p.ID == b.ID || (b.ObjectURI != "" && p.ObjectURI == b.ObjectURI)
+		})
+		if i < 0 {
+			posts = append(posts, b)
+			continue
+		}
+		for _, booster := range b.BoostedBy {
+			if !slices.Contains(posts[i].BoostedBy, booster) {
+				posts[i].BoostedBy = append(posts[i].BoostedBy, booster)
+			}
+		}
+	}
+	return posts
+}
+
+// collapseSelfThreads folds the later posts of each self-thread, an author's replies to their
+// own posts, into the entry of the thread's first post in the page, oldest first. Parts whose
+// parent isn't in the page start an entry of their own. posts must be sorted newest first.
+func collapseSelfThreads(posts []domain.HomePost) []domain.HomePost {
+	// Local parents are referenced by object URI or as local:<note id>
+	byURI := make(map[string]int, len(posts))
+	for i, post := range posts {
+		if post.ObjectURI != "" {
+			byURI[post.ObjectURI] = i
+		}
+		if post.IsLocal && post.NoteID != uuid.Nil {
+			byURI["local:"+post.NoteID.String()] = i
+		}
+	}
+
+	// Each post's thread entry: itself, or the topmost post of its self-thread in the page
+	entry := make([]int, len(posts))
+	for i := len(posts) - 1; i >= 0; i-- {
+		entry[i] = i
+		parent, ok := byURI[posts[i].InReplyToURI]
+		if posts[i].InReplyToURI != "" && ok && parent > i && posts[parent].Author == posts[i].Author {
+			entry[i] = entry[parent]
+		}
+	}
+
+	collapsed := make([]domain.HomePost, 0, len(posts))
+	index := make(map[int]int, len(posts))
+	for i := len(posts) - 1; i >= 0; i-- {
+		if entry[i] == i {
+			index[i] = len(collapsed)
+			collapsed = append(collapsed, posts[i])
+			continue
+		}
+		root := &collapsed[index[entry[i]]]
+		root.SelfThread = append(root.SelfThread, posts[i])
+	}
+	slices.Reverse(collapsed)
+	return collapsed
+}
+
+// remotePostContent returns a remote post's content as Markdown from its sanitized HTML.
+// Activities stored before the HTML was sanitized on arrival fall back to the raw JSON as plain text.
+func remotePostContent(contentHTML, rawJSON string) string {
+	if contentHTML != "" {
+		return util.HTMLToMarkdown(contentHTML)
+	}
+	return extractContentFromJSON(rawJSON)
+}
+
+// extractContentFromJSON extracts content from ActivityPub Create activity JSON
+func extractContentFromJSON(rawJSON string) string {
+	// Properly unmarshal JSON to extract content
+	var activityWrapper struct {
+		Type   string `json:"type"`
+		Object struct {
+			ID      string `json:"id"`
+			Content string `json:"content"`
+		} `json:"object"`
+	}
+
+	if err := json.Unmarshal([]byte(rawJSON), &activityWrapper); err != nil {
+		// Fallback to simple string parsing if JSON unmarshal fails
+		if idx := strings.Index(rawJSON, `"content":"`); idx >= 0 {
+			start := idx + len(`"content":"`)
+			end := strings.Index(rawJSON[start:], `"`)
+			if end > 0 {
+				content := rawJSON[start : start+end]
+				return util.StripHTMLTags(content)
+			}
+		}
+		return ""
+	}
+
+	// Skip if content is empty
+	if activityWrapper.Object.Content == "" {
+		return ""
 	}
 
 	// Strip HTML tags from content
@@ -1323,10 +2637,25 @@ func extractAuthorFromActorURI(actorURI string) string {
 
 // Delivery Queue queries
 const (
-	sqlInsertDeliveryQueue     = `INSERT INTO delivery_queue(id, inbox_uri, activity_json, attempts, next_retry_at, created_at) VALUES (?, ?, ?, ?, ?, ?)`
-	sqlSelectPendingDeliveries = `SELECT id, inbox_uri, activity_json, attempts, next_retry_at, created_at FROM delivery_queue WHERE next_retry_at <= ? ORDER BY created_at ASC LIMIT ?`
-	sqlUpdateDeliveryAttempt   = `UPDATE delivery_queue SET attempts = ?, next_retry_at = ? WHERE id = ?`
-	sqlDeleteDelivery          = `DELETE FROM delivery_queue WHERE id = ?`
+	sqlInsertDeliveryQueue = `INSERT INTO delivery_queue(id, inbox_uri, activity_json, attempts, next_retry_at, created_at, object_uri, seq)
+		VALUES (?, ?, ?, ?, ?, ?, NULLIF(?, ''), (SELECT COALESCE(MAX(seq), 0) + 1 FROM delivery_queue))`
+	// A delivery waits while an earlier one about the same object to the same inbox is queued,
+	// so that e.g. a Delete being retried can't overtake its Create
+	sqlSelectPendingDeliveries = `SELECT id, inbox_uri, activity_json, attempts, next_retry_at, created_at, COALESCE(last_status, 0), COALESCE(object_uri, ''), COALESCE(seq, 0)
+		FROM delivery_queue d
+		WHERE next_retry_at <= ? AND COALESCE(dead_lettered, 0) = 0
+		AND NOT EXISTS (
+			SELECT 1 FROM delivery_queue earlier
+			WHERE earlier.object_uri = d.object_uri AND earlier.inbox_uri = d.inbox_uri
+			AND earlier.seq < d.seq AND COALESCE(earlier.dead_lettered, 0) = 0
+		)
+		ORDER BY created_at ASC, seq ASC LIMIT ?`
+
+	sqlUpdateDeliveryAttempt = `UPDATE delivery_queue SET attempts = ?, next_retry_at = ?, last_status = ? WHERE id = ?`
+	sqlDeadLetterDelivery    = `UPDATE delivery_queue SET attempts = ?, last_status = ?, dead_lettered = 1 WHERE id = ?`
+	sqlDeleteDelivery        = `DELETE FROM delivery_queue WHERE id = ?`
+	sqlCountQueuedDeliveries = `SELECT COUNT(*) FROM delivery_queue WHERE COALESCE(dead_lettered, 0) = 0`
+	sqlCountDeadLetters      = `SELECT COUNT(*) FROM delivery_queue WHERE dead_lettered = 1`
 )
 
 func (db *DB) EnqueueDelivery(item *domain.DeliveryQueueItem) error {
@@ -1336,17 +2665,48 @@ func (db *DB) EnqueueDelivery(item *domain.DeliveryQueueItem) error {
 			item.InboxURI,
 			item.ActivityJSON,
 			item.Attempts,
-			item.NextRetryAt,
-			item.CreatedAt,
+			formatTimestamp(item.NextRetryAt),
+			formatTimestamp(item.CreatedAt),
+			item.ObjectURI,
 		)
 		return err
 	})
 }
 
-func (db *DB) ReadPendingDeliveries(limit int) (error, *[]domain.DeliveryQueueItem) {
-	rows, err := db.db.Query(sqlSelectPendingDeliveries, time.Now(), limit)
+// EnqueueDeliveryBatch queues many deliveries in a single transaction (one per inbox for a fan-out)
+func (db *DB) EnqueueDeliveryBatch(items []*domain.DeliveryQueueItem) error {
+	if len(items) == 0 {
+		return nil
+	}
+	return db.wrapTransaction(func(tx *sql.Tx) error {
+		stmt, err := tx.Prepare(sqlInsertDeliveryQueue)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		for _, item := range items {
+			_, err := stmt.Exec(
+				item.Id.String(),
+				item.InboxURI,
+				item.ActivityJSON,
+				item.Attempts,
+				formatTimestamp(item.NextRetryAt),
+				formatTimestamp(item.CreatedAt),
+				item.ObjectURI,
+			)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (db *DB) ReadPendingDeliveries(limit int) (*[]domain.DeliveryQueueItem, error) {
+	rows, err := db.conn().Query(sqlSelectPendingDeliveries, formatTimestamp(time.Now()), limit)
 	if err != nil {
-		return err, nil
+		return nil, err
 	}
 	defer rows.Close()
 
@@ -1354,21 +2714,30 @@ func (db *DB) ReadPendingDeliveries(limit int) (error, *[]domain.DeliveryQueueIt
 	for rows.Next() {
 		var item domain.DeliveryQueueItem
 		var idStr string
-		if err := rows.Scan(&idStr, &item.InboxURI, &item.ActivityJSON, &item.Attempts, &item.NextRetryAt, &item.CreatedAt); err != nil {
-			return err, &items
+		if err := rows.Scan(&idStr, &item.InboxURI, &item.ActivityJSON, &item.Attempts, &item.NextRetryAt, &item.CreatedAt, &item.LastStatus, &item.ObjectURI, &item.Seq); err != nil {
+			return &items, err
 		}
 		item.Id, _ = uuid.Parse(idStr)
 		items = append(items, item)
 	}
 	if err = rows.Err(); err != nil {
-		return err, &items
+		return &items, err
 	}
-	return nil, &items
+	return &items, nil
+}
+
+// UpdateDeliveryAttempt reschedules a failed delivery and records the HTTP status it got (0 = no response)
+func (db *DB) UpdateDeliveryAttempt(id uuid.UUID, attempts int, nextRetry time.Time, lastStatus int) error {
+	return db.wrapTransaction(func(tx *sql.Tx) error {
+		_, err := tx.Exec(sqlUpdateDeliveryAttempt, attempts, formatTimestamp(nextRetry), lastStatus, id.String())
+		return err
+	})
 }
 
-func (db *DB) UpdateDeliveryAttempt(id uuid.UUID, attempts int, nextRetry time.Time) error {
+// DeadLetterDelivery stops retrying a delivery but keeps the row (with its last status) for inspection
+func (db *DB) DeadLetterDelivery(id uuid.UUID, attempts int, lastStatus int) error {
 	return db.wrapTransaction(func(tx *sql.Tx) error {
-		_, err := tx.Exec(sqlUpdateDeliveryAttempt, attempts, nextRetry, id.String())
+		_, err := tx.Exec(sqlDeadLetterDelivery, attempts, lastStatus, id.String())
 		return err
 	})
 }
@@ -1380,23 +2749,105 @@ func (db *DB) DeleteDelivery(id uuid.UUID) error {
 	})
 }
 
+// CountQueuedDeliveries returns the number of deliveries still waiting to be sent or retried
+func (db *DB) CountQueuedDeliveries() (int, error) {
+	var count int
+	err := db.conn().QueryRow(sqlCountQueuedDeliveries).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// CountDeadLetters returns the number of deliveries that gave up retrying
+func (db *DB) CountDeadLetters() (int, error) {
+	var count int
+	err := db.conn().QueryRow(sqlCountDeadLetters).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
 // Follower queries
 const (
-	sqlSelectFollowersByAccountId = `SELECT id, account_id, target_account_id, uri, accepted, created_at, is_local FROM follows WHERE target_account_id = ? AND accepted = 1`
+	sqlSelectFollowersByAccountId  = `SELECT id, account_id, target_account_id, uri, accepted, created_at, is_local FROM follows WHERE target_account_id = ? AND accepted = 1`
+	sqlSelectPendingFollowRequests = `SELECT f.id, f.account_id, f.target_account_id, f.uri, f.created_at, ra.username, ra.domain, ra.actor_uri
+		FROM follows f
+		INNER JOIN remote_accounts ra ON ra.id = f.account_id
+		WHERE f.target_account_id = ? AND f.accepted = 0 AND f.is_local = 0
+		ORDER BY f.created_at ASC`
+	sqlSelectFollowerInboxURIs = `SELECT DISTINCT COALESCE(NULLIF(ra.shared_inbox_uri, ''), ra.inbox_uri)
+		FROM follows f
+		INNER JOIN remote_accounts ra ON ra.id = f.account_id
+		WHERE f.target_account_id = ? AND f.accepted = 1 AND f.is_local = 0 AND ra.inbox_uri != ''`
 	// Select following with LEFT JOIN to filter out orphaned remote follows
 	sqlSelectFollowingByAccountId = `
-		SELECT f.id, f.account_id, f.target_account_id, f.uri, f.accepted, f.created_at, f.is_local
+		SELECT f.id, f.account_id, f.target_account_id, f.uri, f.accepted, f.created_at, f.is_local, COALESCE(f.notify, 0)
 		FROM follows f
 		LEFT JOIN remote_accounts ra ON f.target_account_id = ra.id AND f.is_local = 0
 		WHERE f.account_id = ?
 		AND (f.is_local = 1 OR ra.id IS NOT NULL)
 	`
+	sqlSelectFollowingAddresses = `
+		SELECT CASE WHEN f.is_local = 1 THEN a.username || '@' || ? ELSE ra.username || '@' || ra.domain END
+		FROM follows f
+		LEFT JOIN remote_accounts ra ON f.target_account_id = ra.id AND f.is_local = 0
+		LEFT JOIN accounts a ON f.target_account_id = a.id AND f.is_local = 1
+		WHERE f.account_id = ?
+		AND (ra.id IS NOT NULL OR a.id IS NOT NULL)
+		ORDER BY f.created_at
+	`
 )
 
-func (db *DB) ReadFollowersByAccountId(accountId uuid.UUID) (error, *[]domain.Follow) {
-	rows, err := db.db.Query(sqlSelectFollowersByAccountId, accountId.String())
+// ReadPendingFollowRequests returns the follows of an account by remote actors that wait for
+// the account's approval, oldest first
+func (db *DB) ReadPendingFollowRequests(accountId uuid.UUID) ([]domain.FollowRequest, error) {
+	rows, err := db.conn().Query(sqlSelectPendingFollowRequests, accountId.String())
 	if err != nil {
-		return err, nil
+		return nil, err
+	}
+	defer rows.Close()
+
+	var requests []domain.FollowRequest
+	for rows.Next() {
+		var request domain.FollowRequest
+		var idStr, accountIdStr, targetIdStr string
+		if err := rows.Scan(&idStr, &accountIdStr, &targetIdStr, &request.URI, &request.CreatedAt, &request.Username, &request.Domain, &request.ActorURI); err != nil {
+			return requests, err
+		}
+		request.Id, _ = uuid.Parse(idStr)
+		request.AccountId, _ = uuid.Parse(accountIdStr)
+		request.TargetAccountId, _ = uuid.Parse(targetIdStr)
+		requests = append(requests, request)
+	}
+	return requests, rows.Err()
+}
+
+// ReadFollowerInboxURIs returns the distinct inboxes of an account's accepted remote followers,
+// using each follower's shared inbox when its server has one so a server is delivered to once
+func (db *DB) ReadFollowerInboxURIs(accountId uuid.UUID) ([]string, error) {
+	rows, err := db.conn().Query(sqlSelectFollowerInboxURIs, accountId.String())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var inboxes []string
+	for rows.Next() {
+		var inboxURI string
+		if err := rows.Scan(&inboxURI); err != nil {
+			return inboxes, err
+		}
+		inboxes = append(inboxes, inboxURI)
+	}
+	return inboxes, rows.Err()
+}
+
+func (db *DB) ReadFollowersByAccountId(accountId uuid.UUID) (*[]domain.Follow, error) {
+	rows, err := db.conn().Query(sqlSelectFollowersByAccountId, accountId.String())
+	if err != nil {
+		return nil, err
 	}
 	defer rows.Close()
 
@@ -1406,7 +2857,7 @@ func (db *DB) ReadFollowersByAccountId(accountId uuid.UUID) (error, *[]domain.Fo
 		var idStr, accountIdStr, targetIdStr string
 		var isLocal int
 		if err := rows.Scan(&idStr, &accountIdStr, &targetIdStr, &follow.URI, &follow.Accepted, &follow.CreatedAt, &isLocal); err != nil {
-			return err, &followers
+			return &followers, err
 		}
 		follow.Id, _ = uuid.Parse(idStr)
 		follow.AccountId, _ = uuid.Parse(accountIdStr)
@@ -1415,16 +2866,16 @@ func (db *DB) ReadFollowersByAccountId(accountId uuid.UUID) (error, *[]domain.Fo
 		followers = append(followers, follow)
 	}
 	if err = rows.Err(); err != nil {
-		return err, &followers
+		return &followers, err
 	}
-	return nil, &followers
+	return &followers, nil
 }
 
 // ReadFollowingByAccountId returns all accounts that the given account is following (remote accounts)
-func (db *DB) ReadFollowingByAccountId(accountId uuid.UUID) (error, *[]domain.Follow) {
-	rows, err := db.db.Query(sqlSelectFollowingByAccountId, accountId.String())
+func (db *DB) ReadFollowingByAccountId(accountId uuid.UUID) (*[]domain.Follow, error) {
+	rows, err := db.conn().Query(sqlSelectFollowingByAccountId, accountId.String())
 	if err != nil {
-		return err, nil
+		return nil, err
 	}
 	defer rows.Close()
 
@@ -1433,8 +2884,8 @@ func (db *DB) ReadFollowingByAccountId(accountId uuid.UUID) (error, *[]domain.Fo
 		var follow domain.Follow
 		var idStr, accountIdStr, targetIdStr string
 		var isLocal int
-		if err := rows.Scan(&idStr, &accountIdStr, &targetIdStr, &follow.URI, &follow.Accepted, &follow.CreatedAt, &isLocal); err != nil {
-			return err, &following
+		if err := rows.Scan(&idStr, &accountIdStr, &targetIdStr, &follow.URI, &follow.Accepted, &follow.CreatedAt, &isLocal, &follow.Notify); err != nil {
+			return &following, err
 		}
 		follow.Id, _ = uuid.Parse(idStr)
 		follow.AccountId, _ = uuid.Parse(accountIdStr)
@@ -1443,16 +2894,39 @@ func (db *DB) ReadFollowingByAccountId(accountId uuid.UUID) (error, *[]domain.Fo
 		following = append(following, follow)
 	}
 	if err = rows.Err(); err != nil {
-		return err, &following
+		return &following, err
 	}
-	return nil, &following
+	return &following, nil
+}
+
+// ReadFollowingAddresses returns the user@domain address of every account the given account follows,
+// oldest follow first. Local accounts are addressed with localDomain.
+func (db *DB) ReadFollowingAddresses(accountId uuid.UUID, localDomain string) ([]string, error) {
+	rows, err := db.conn().Query(sqlSelectFollowingAddresses, localDomain, accountId.String())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var addresses []string
+	for rows.Next() {
+		var address string
+		if err := rows.Scan(&address); err != nil {
+			return addresses, err
+		}
+		addresses = append(addresses, address)
+	}
+	if err = rows.Err(); err != nil {
+		return addresses, err
+	}
+	return addresses, nil
 }
 
 // ReadAllAccounts returns all local user accounts (excluding first-time login users)
-func (db *DB) ReadAllAccounts() (error, *[]domain.Account) {
-	rows, err := db.db.Query(sqlSelectAllAccounts)
+func (db *DB) ReadAllAccounts() (*[]domain.Account, error) {
+	rows, err := db.conn().Query(sqlSelectAllAccounts)
 	if err != nil {
-		return err, nil
+		return nil, err
 	}
 	defer rows.Close()
 
@@ -1460,28 +2934,32 @@ func (db *DB) ReadAllAccounts() (error, *[]domain.Account) {
 	for rows.Next() {
 		var acc domain.Account
 		var displayName, summary, avatarURL sql.NullString
-		var isAdmin, muted sql.NullInt64
-		if err := rows.Scan(&acc.Id, &acc.Username, &acc.Publickey, &acc.CreatedAt, &acc.FirstTimeLogin, &acc.WebPublicKey, &acc.WebPrivateKey, &displayName, &summary, &avatarURL, &isAdmin, &muted); err != nil {
-			return err, &accounts
+		var isAdmin, muted, locked, pending, disabled, discoverable sql.NullInt64
+		if err := rows.Scan(&acc.Id, &acc.Username, &acc.Publickey, &acc.CreatedAt, &acc.FirstTimeLogin, &acc.WebPublicKey, &acc.WebPrivateKey, &displayName, &summary, &avatarURL, &isAdmin, &muted, &locked, &pending, &disabled, &discoverable); err != nil {
+			return &accounts, err
 		}
 		acc.DisplayName = displayName.String
 		acc.Summary = summary.String
 		acc.AvatarURL = avatarURL.String
 		acc.IsAdmin = isAdmin.Int64 == 1
 		acc.Muted = muted.Int64 == 1
+		acc.ManuallyApprovesFollowers = locked.Int64 == 1
+		acc.Pending = pending.Int64 == 1
+		acc.Disabled = disabled.Int64 == 1
+		acc.Discoverable = db.isDiscoverable(discoverable)
 		accounts = append(accounts, acc)
 	}
 	if err = rows.Err(); err != nil {
-		return err, &accounts
+		return &accounts, err
 	}
-	return nil, &accounts
+	return &accounts, nil
 }
 
 // ReadAllAccountsAdmin returns all local user accounts including first-time login users (for admin panel)
-func (db *DB) ReadAllAccountsAdmin() (error, *[]domain.Account) {
-	rows, err := db.db.Query(sqlSelectAllAccountsAdmin)
+func (db *DB) ReadAllAccountsAdmin() (*[]domain.Account, error) {
+	rows, err := db.conn().Query(sqlSelectAllAccountsAdmin)
 	if err != nil {
-		return err, nil
+		return nil, err
 	}
 	defer rows.Close()
 
@@ -1489,27 +2967,31 @@ func (db *DB) ReadAllAccountsAdmin() (error, *[]domain.Account) {
 	for rows.Next() {
 		var acc domain.Account
 		var displayName, summary, avatarURL sql.NullString
-		var isAdmin, muted sql.NullInt64
-		if err := rows.Scan(&acc.Id, &acc.Username, &acc.Publickey, &acc.CreatedAt, &acc.FirstTimeLogin, &acc.WebPublicKey, &acc.WebPrivateKey, &displayName, &summary, &avatarURL, &isAdmin, &muted); err != nil {
-			return err, &accounts
+		var isAdmin, muted, locked, pending, disabled, discoverable sql.NullInt64
+		if err := rows.Scan(&acc.Id, &acc.Username, &acc.Publickey, &acc.CreatedAt, &acc.FirstTimeLogin, &acc.WebPublicKey, &acc.WebPrivateKey, &displayName, &summary, &avatarURL, &isAdmin, &muted, &locked, &pending, &disabled, &discoverable); err != nil {
+			return &accounts, err
 		}
 		acc.DisplayName = displayName.String
 		acc.Summary = summary.String
 		acc.AvatarURL = avatarURL.String
 		acc.IsAdmin = isAdmin.Int64 == 1
 		acc.Muted = muted.Int64 == 1
+		acc.ManuallyApprovesFollowers = locked.Int64 == 1
+		acc.Pending = pending.Int64 == 1
+		acc.Disabled = disabled.Int64 == 1
+		acc.Discoverable = db.isDiscoverable(discoverable)
 		accounts = append(accounts, acc)
 	}
 	if err = rows.Err(); err != nil {
-		return err, &accounts
+		return &accounts, err
 	}
-	return nil, &accounts
+	return &accounts, nil
 }
 
 // CountAccounts returns the total number of accounts in the database
 func (db *DB) CountAccounts() (int, error) {
 	var count int
-	err := db.db.QueryRow(sqlCountAccounts).Scan(&count)
+	err := db.conn().QueryRow(sqlCountAccounts).Scan(&count)
 	if err != nil {
 		return 0, err
 	}
@@ -1519,7 +3001,7 @@ func (db *DB) CountAccounts() (int, error) {
 // CountLocalPosts returns the total number of local posts (notes) in the database
 func (db *DB) CountLocalPosts() (int, error) {
 	var count int
-	err := db.db.QueryRow(sqlCountLocalPosts).Scan(&count)
+	err := db.conn().QueryRow(sqlCountLocalPosts).Scan(&count)
 	if err != nil {
 		return 0, err
 	}
@@ -1529,7 +3011,7 @@ func (db *DB) CountLocalPosts() (int, error) {
 // CountActiveUsersMonth returns the number of users who posted in the last 30 days
 func (db *DB) CountActiveUsersMonth() (int, error) {
 	var count int
-	err := db.db.QueryRow(sqlCountActiveUsersMonth).Scan(&count)
+	err := db.conn().QueryRow(sqlCountActiveUsersMonth).Scan(&count)
 	if err != nil {
 		return 0, err
 	}
@@ -1539,7 +3021,37 @@ func (db *DB) CountActiveUsersMonth() (int, error) {
 // CountActiveUsersHalfYear returns the number of users who posted in the last 180 days
 func (db *DB) CountActiveUsersHalfYear() (int, error) {
 	var count int
-	err := db.db.QueryRow(sqlCountActiveUsersHalfYear).Scan(&count)
+	err := db.conn().QueryRow(sqlCountActiveUsersHalfYear).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// CountRemoteAccounts returns the number of cached remote actors
+func (db *DB) CountRemoteAccounts() (int, error) {
+	var count int
+	err := db.conn().QueryRow(sqlCountRemoteAccounts).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// CountRemoteDomains returns the number of remote servers with cached actors
+func (db *DB) CountRemoteDomains() (int, error) {
+	var count int
+	err := db.conn().QueryRow(sqlCountRemoteDomains).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// CountActivities returns the number of stored incoming activities
+func (db *DB) CountActivities() (int, error) {
+	var count int
+	err := db.conn().QueryRow(sqlCountActivities).Scan(&count)
 	if err != nil {
 		return 0, err
 	}
@@ -1575,6 +3087,24 @@ func (db *DB) DeleteAccount(accountId uuid.UUID) error {
 			log.Printf("Warning: failed to delete delivery queue items (table may not exist): %v", err)
 		}
 
+		// Revoke all API tokens of this user (if table exists)
+		_, err = tx.Exec(sqlDeleteAPITokensByAccountId, accountId.String())
+		if err != nil {
+			log.Printf("Warning: failed to delete API tokens (table may not exist): %v", err)
+		}
+
+		// Remove the user's added SSH keys (if table exists)
+		_, err = tx.Exec("DELETE FROM account_keys WHERE account_id = ?", accountId.String())
+		if err != nil {
+			log.Printf("Warning: failed to delete account keys (table may not exist): %v", err)
+		}
+
+		// Remove the user's mutes of remote accounts (if table exists)
+		_, err = tx.Exec("DELETE FROM muted_accounts WHERE account_id = ?", accountId.String())
+		if err != nil {
+			log.Printf("Warning: failed to delete muted accounts (table may not exist): %v", err)
+		}
+
 		// Note: We don't delete activities because they're linked by actor_uri (string) not account_id
 		// Activities will remain as a historical record even after account deletion
 		// This matches ActivityPub behavior where activities persist after account deletion
@@ -1621,11 +3151,85 @@ func (db *DB) UnmuteUser(accountId uuid.UUID) error {
 	})
 }
 
-// ReadLocalTimelineNotes returns recent notes from local users that the given account follows (plus their own posts)
-func (db *DB) ReadLocalTimelineNotes(accountId uuid.UUID, limit int) (error, *[]domain.Note) {
-	rows, err := db.db.Query(sqlSelectLocalTimelineNotesByFollows, accountId.String(), accountId.String(), limit)
+// DisableAccount suspends an account: it can't log in and its actor and inbox stop
+// federating, but unlike MuteUser its posts are kept
+func (db *DB) DisableAccount(accountId uuid.UUID) error {
+	return db.wrapTransaction(func(tx *sql.Tx) error {
+		_, err := tx.Exec("UPDATE accounts SET disabled = 1 WHERE id = ?", accountId.String())
+		if err != nil {
+			return fmt.Errorf("failed to disable account: %w", err)
+		}
+		log.Printf("Disabled account %s", accountId.String())
+		return nil
+	})
+}
+
+// EnableAccount reverts DisableAccount
+func (db *DB) EnableAccount(accountId uuid.UUID) error {
+	return db.wrapTransaction(func(tx *sql.Tx) error {
+		_, err := tx.Exec("UPDATE accounts SET disabled = 0 WHERE id = ?", accountId.String())
+		if err != nil {
+			return fmt.Errorf("failed to enable account: %w", err)
+		}
+		log.Printf("Enabled account %s", accountId.String())
+		return nil
+	})
+}
+
+// ApproveAccount lets a pending account log in, post and federate
+func (db *DB) ApproveAccount(accountId uuid.UUID) error {
+	var changed int64
+	err := db.wrapTransaction(func(tx *sql.Tx) error {
+		result, err := tx.Exec("UPDATE accounts SET pending = 0 WHERE id = ? AND pending = 1", accountId.String())
+		if err != nil {
+			return fmt.Errorf("failed to approve account: %w", err)
+		}
+		changed, _ = result.RowsAffected()
+		return nil
+	})
 	if err != nil {
-		return err, nil
+		return err
+	}
+	if changed == 0 {
+		return ErrAccountNotPending
+	}
+	log.Printf("Approved account %s", accountId.String())
+	return nil
+}
+
+// DenyAccount deletes a pending account. Pending accounts can't post, so there is nothing
+// else to clean up; accounts that were already approved are kept.
+func (db *DB) DenyAccount(accountId uuid.UUID) error {
+	var changed int64
+	err := db.wrapTransaction(func(tx *sql.Tx) error {
+		result, err := tx.Exec("DELETE FROM accounts WHERE id = ? AND pending = 1", accountId.String())
+		if err != nil {
+			return fmt.Errorf("failed to deny account: %w", err)
+		}
+		changed, _ = result.RowsAffected()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if changed == 0 {
+		return ErrAccountNotPending
+	}
+	log.Printf("Denied and deleted pending account %s", accountId.String())
+	return nil
+}
+
+// ReadLocalTimelineNotes returns recent notes from local users that the given account follows (plus their own posts).
+// Replies are left out unless the account shows replies in its home timeline.
+func (db *DB) ReadLocalTimelineNotes(accountId uuid.UUID, limit int) (*[]domain.Note, error) {
+	filter, err := db.readHomeFilter(accountId)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.conn().Query(sqlSelectLocalTimelineNotesByFollows, filter.localNotesArgs(accountId, limit)...)
+	if err != nil {
+		return nil, err
 	}
 	defer rows.Close()
 
@@ -1635,7 +3239,7 @@ func (db *DB) ReadLocalTimelineNotes(accountId uuid.UUID, limit int) (error, *[]
 		var createdAtStr string
 		var editedAtStr sql.NullString
 		if err := rows.Scan(&note.Id, &note.CreatedBy, &note.Message, &createdAtStr, &editedAtStr); err != nil {
-			return err, &notes
+			return &notes, err
 		}
 
 		if parsedTime, err := parseTimestamp(createdAtStr); err == nil {
@@ -1651,9 +3255,9 @@ func (db *DB) ReadLocalTimelineNotes(accountId uuid.UUID, limit int) (error, *[]
 		notes = append(notes, note)
 	}
 	if err = rows.Err(); err != nil {
-		return err, &notes
+		return &notes, err
 	}
-	return nil, &notes
+	return &notes, nil
 }
 
 // CreateLocalFollow creates a local-only follow relationship
@@ -1680,10 +3284,10 @@ func (db *DB) DeleteLocalFollow(followerAccountId, targetAccountId uuid.UUID) er
 
 // ReadPublicNotesByUsername returns public notes for a user's ActivityPub outbox with pagination
 // Returns notes with full metadata including object_uri for ActivityPub compatibility
-func (db *DB) ReadPublicNotesByUsername(username string, limit, offset int) (error, *[]domain.Note) {
-	rows, err := db.db.Query(sqlSelectPublicNotesByUsername, username, limit, offset)
+func (db *DB) ReadPublicNotesByUsername(username string, limit, offset int) (*[]domain.Note, error) {
+	rows, err := db.conn().Query(sqlSelectPublicNotesByUsername, username, limit, offset)
 	if err != nil {
-		return err, nil
+		return nil, err
 	}
 	defer rows.Close()
 
@@ -1693,9 +3297,9 @@ func (db *DB) ReadPublicNotesByUsername(username string, limit, offset int) (err
 		var userId, visibility, objectURI sql.NullString
 		var editedAt sql.NullTime
 
-		err := rows.Scan(&note.Id, &userId, &note.Message, &note.CreatedAt, &editedAt, &visibility, &objectURI)
+		err := rows.Scan(&note.Id, &userId, &note.Message, &note.CreatedAt, &editedAt, &visibility, &objectURI, &note.Language)
 		if err != nil {
-			return err, &notes
+			return &notes, err
 		}
 
 		// Set username for note (we already know it from the query parameter)
@@ -1711,15 +3315,15 @@ func (db *DB) ReadPublicNotesByUsername(username string, limit, offset int) (err
 		notes = append(notes, note)
 	}
 	if err = rows.Err(); err != nil {
-		return err, &notes
+		return &notes, err
 	}
-	return nil, &notes
+	return &notes, nil
 }
 
 // IsFollowingLocal checks if a user is following another local user
 func (db *DB) IsFollowingLocal(followerAccountId, targetAccountId uuid.UUID) (bool, error) {
 	var count int
-	err := db.db.QueryRow(sqlCheckLocalFollow, followerAccountId.String(), targetAccountId.String()).Scan(&count)
+	err := db.conn().QueryRow(sqlCheckLocalFollow, followerAccountId.String(), targetAccountId.String()).Scan(&count)
 	if err != nil {
 		return false, err
 	}
@@ -1727,10 +3331,10 @@ func (db *DB) IsFollowingLocal(followerAccountId, targetAccountId uuid.UUID) (bo
 }
 
 // ReadLocalFollowsByAccountId returns all local users that an account is following
-func (db *DB) ReadLocalFollowsByAccountId(accountId uuid.UUID) (error, *[]domain.Follow) {
-	rows, err := db.db.Query(sqlSelectLocalFollowsByAccountId, accountId.String())
+func (db *DB) ReadLocalFollowsByAccountId(accountId uuid.UUID) (*[]domain.Follow, error) {
+	rows, err := db.conn().Query(sqlSelectLocalFollowsByAccountId, accountId.String())
 	if err != nil {
-		return err, nil
+		return nil, err
 	}
 	defer rows.Close()
 
@@ -1739,7 +3343,7 @@ func (db *DB) ReadLocalFollowsByAccountId(accountId uuid.UUID) (error, *[]domain
 		var follow domain.Follow
 		var idStr, accountIdStr, targetIdStr string
 		if err := rows.Scan(&idStr, &accountIdStr, &targetIdStr, &follow.URI, &follow.Accepted, &follow.CreatedAt); err != nil {
-			return err, &follows
+			return &follows, err
 		}
 		follow.Id, _ = uuid.Parse(idStr)
 		follow.AccountId, _ = uuid.Parse(accountIdStr)
@@ -1748,14 +3352,14 @@ func (db *DB) ReadLocalFollowsByAccountId(accountId uuid.UUID) (error, *[]domain
 		follows = append(follows, follow)
 	}
 	if err = rows.Err(); err != nil {
-		return err, &follows
+		return &follows, err
 	}
-	return nil, &follows
+	return &follows, nil
 }
 
 // DeleteActivity deletes an activity by ID
 func (db *DB) DeleteActivity(id uuid.UUID) error {
-	_, err := db.db.Exec("DELETE FROM activities WHERE id = ?", id.String())
+	_, err := db.conn().Exec("DELETE FROM activities WHERE id = ?", id.String())
 	if err != nil {
 		return fmt.Errorf("failed to delete activity: %w", err)
 	}
@@ -1763,19 +3367,19 @@ func (db *DB) DeleteActivity(id uuid.UUID) error {
 }
 
 // ReadRemoteAccountByActorURI reads a remote account by its ActivityPub actor URI
-func (db *DB) ReadRemoteAccountByActorURI(actorURI string) (error, *domain.RemoteAccount) {
+func (db *DB) ReadRemoteAccountByActorURI(actorURI string) (*domain.RemoteAccount, error) {
 	var account domain.RemoteAccount
 	var idStr string
 
-	err := db.db.QueryRow(
+	err := db.conn().QueryRow(
 		`SELECT id, actor_uri, username, domain, display_name, summary, avatar_url,
-		 public_key_pem, inbox_uri, outbox_uri, last_fetched_at
+		 public_key_pem, inbox_uri, COALESCE(shared_inbox_uri, ''), outbox_uri, last_fetched_at
 		 FROM remote_accounts WHERE actor_uri = ?`,
 		actorURI,
 	).Scan(
 		&idStr, &account.ActorURI, &account.Username, &account.Domain,
 		&account.DisplayName, &account.Summary, &account.AvatarURL,
-		&account.PublicKeyPem, &account.InboxURI, &account.OutboxURI,
+		&account.PublicKeyPem, &account.InboxURI, &account.SharedInboxURI, &account.OutboxURI,
 		&account.LastFetchedAt,
 	)
 
@@ -1783,26 +3387,42 @@ func (db *DB) ReadRemoteAccountByActorURI(actorURI string) (error, *domain.Remot
 		if err.Error() == "sql: no rows in result set" {
 			return nil, nil
 		}
-		return err, nil
+		return nil, err
 	}
 
 	account.Id, _ = uuid.Parse(idStr)
-	return nil, &account
+	return &account, nil
 }
 
 // DeleteRemoteAccount deletes a remote account by ID
 func (db *DB) DeleteRemoteAccount(id uuid.UUID) error {
-	_, err := db.db.Exec("DELETE FROM remote_accounts WHERE id = ?", id.String())
+	_, err := db.conn().Exec("DELETE FROM remote_accounts WHERE id = ?", id.String())
 	if err != nil {
 		return fmt.Errorf("failed to delete remote account: %w", err)
 	}
 	return nil
 }
 
+// DeleteUnreferencedRemoteAccounts deletes cached remote accounts last fetched before the
+// given time that no follow, activity, like, boost, notification, mention or relay refers to,
+// and returns how many were removed
+func (db *DB) DeleteUnreferencedRemoteAccounts(fetchedBefore time.Time) (int64, error) {
+	var deleted int64
+	err := db.wrapTransaction(func(tx *sql.Tx) error {
+		result, err := tx.Exec(sqlDeleteUnreferencedRemoteAccounts, formatTimestamp(fetchedBefore))
+		if err != nil {
+			return err
+		}
+		deleted, err = result.RowsAffected()
+		return err
+	})
+	return deleted, err
+}
+
 // DeleteFollowsByRemoteAccountId deletes all follows to/from a remote account
 func (db *DB) DeleteFollowsByRemoteAccountId(remoteAccountId uuid.UUID) error {
 	// Delete follows where this account is the follower (account_id)
-	_, err := db.db.Exec("DELETE FROM follows WHERE account_id = ? OR target_account_id = ?",
+	_, err := db.conn().Exec("DELETE FROM follows WHERE account_id = ? OR target_account_id = ?",
 		remoteAccountId.String(), remoteAccountId.String())
 	if err != nil {
 		return fmt.Errorf("failed to delete follows: %w", err)
@@ -1816,7 +3436,7 @@ func (db *DB) MigrateKeysToPKCS8() error {
 	log.Println("Starting PKCS#1 to PKCS#8 key migration...")
 
 	// Get all accounts
-	rows, err := db.db.Query("SELECT id, username, web_private_key, web_public_key FROM accounts WHERE web_private_key IS NOT NULL")
+	rows, err := db.conn().Query("SELECT id, username, web_private_key, web_public_key FROM accounts WHERE web_private_key IS NOT NULL")
 	if err != nil {
 		return fmt.Errorf("failed to query accounts: %w", err)
 	}
@@ -1858,7 +3478,7 @@ func (db *DB) MigrateKeysToPKCS8() error {
 		}
 
 		// Update database
-		_, err = db.db.Exec("UPDATE accounts SET web_private_key = ?, web_public_key = ? WHERE id = ?",
+		_, err = db.conn().Exec("UPDATE accounts SET web_private_key = ?, web_public_key = ? WHERE id = ?",
 			newPrivateKey, newPublicKey, idStr)
 		if err != nil {
 			log.Printf("Failed to update keys for user %s: %v", username, err)
@@ -1891,7 +3511,7 @@ func (db *DB) MigrateDuplicateFollows() error {
 
 	// First, check if we already have the UNIQUE constraint
 	// If the constraint exists, we can skip this migration
-	rows, err := db.db.Query(`SELECT sql FROM sqlite_master WHERE type='table' AND name='follows'`)
+	rows, err := db.conn().Query(`SELECT sql FROM sqlite_master WHERE type='table' AND name='follows'`)
 	if err != nil {
 		return fmt.Errorf("failed to check table schema: %w", err)
 	}
@@ -1916,7 +3536,7 @@ func (db *DB) MigrateDuplicateFollows() error {
 		GROUP BY account_id, target_account_id
 		HAVING count > 1
 	`
-	dupRows, err := db.db.Query(duplicateQuery)
+	dupRows, err := db.conn().Query(duplicateQuery)
 	if err != nil {
 		return fmt.Errorf("failed to query duplicates: %w", err)
 	}
@@ -1957,7 +3577,7 @@ func (db *DB) MigrateDuplicateFollows() error {
 					LIMIT 1
 				)
 			`
-			result, err := db.db.Exec(deleteQuery, dp.accountId, dp.targetAccountId, dp.accountId, dp.targetAccountId)
+			result, err := db.conn().Exec(deleteQuery, dp.accountId, dp.targetAccountId, dp.accountId, dp.targetAccountId)
 			if err != nil {
 				log.Printf("Failed to delete duplicates for %s -> %s: %v", dp.accountId, dp.targetAccountId, err)
 				continue
@@ -2055,7 +3675,7 @@ func (db *DB) MigrateLocalReplyCounts() error {
 			WHERE r.in_reply_to_uri LIKE 'local:' || n.id || '%'
 		)
 	`
-	rows, err := db.db.Query(query)
+	rows, err := db.conn().Query(query)
 	if err != nil {
 		return fmt.Errorf("failed to query notes with local replies: %w", err)
 	}
@@ -2094,14 +3714,14 @@ func (db *DB) MigrateLocalReplyCounts() error {
 			SELECT COUNT(*) FROM reply_chain
 		`
 		var replyCount int
-		err := db.db.QueryRow(countQuery, noteId).Scan(&replyCount)
+		err := db.conn().QueryRow(countQuery, noteId).Scan(&replyCount)
 		if err != nil {
 			log.Printf("Failed to count replies for note %s: %v", noteId, err)
 			continue
 		}
 
 		if replyCount > 0 {
-			_, err = db.db.Exec(`UPDATE notes SET reply_count = ? WHERE id = ?`, replyCount, noteId)
+			_, err = db.conn().Exec(`UPDATE notes SET reply_count = ? WHERE id = ?`, replyCount, noteId)
 			if err != nil {
 				log.Printf("Failed to update reply_count for note %s: %v", noteId, err)
 				continue
@@ -2126,10 +3746,29 @@ const (
 								INNER JOIN accounts a ON a.id = n.user_id
 								INNER JOIN note_hashtags nh ON nh.note_id = n.id
 								INNER JOIN hashtags h ON h.id = nh.hashtag_id
-								WHERE h.name = ?
+								WHERE h.name = ? AND COALESCE(n.visibility, 'public') != 'local'
 								ORDER BY n.created_at DESC
 								LIMIT ? OFFSET ?`
-	sqlCountNotesByHashtag = `SELECT COUNT(*) FROM note_hashtags nh INNER JOIN hashtags h ON h.id = nh.hashtag_id WHERE h.name = ?`
+	sqlCountNotesByHashtag        = `SELECT COUNT(*) FROM note_hashtags nh INNER JOIN hashtags h ON h.id = nh.hashtag_id INNER JOIN notes n ON n.id = nh.note_id WHERE h.name = ? AND COALESCE(n.visibility, 'public') != 'local'`
+	sqlInsertActivityHashtag      = `INSERT OR IGNORE INTO activity_hashtags(activity_id, hashtag_id) VALUES (?, ?)`
+	sqlSelectRemotePostsByHashtag = `SELECT a.id, a.actor_uri, a.object_uri, a.raw_json, a.created_at, ra.username, ra.domain,
+								COALESCE(a.reply_count, 0), COALESCE(a.like_count, 0), COALESCE(a.boost_count, 0), COALESCE(a.content_html, ''), COALESCE(a.title, '')
+								FROM activities a
+								INNER JOIN activity_hashtags ah ON ah.activity_id = a.id
+								INNER JOIN hashtags h ON h.id = ah.hashtag_id
+								LEFT JOIN remote_accounts ra ON ra.actor_uri = a.actor_uri
+								WHERE h.name = ? AND a.activity_type = 'Create' AND a.local = 0 AND COALESCE(a.restricted, 0) = 0
+								ORDER BY a.created_at DESC
+								LIMIT ?`
+	sqlCountRemotePostsByHashtag = `SELECT COUNT(*) FROM activity_hashtags ah INNER JOIN hashtags h ON h.id = ah.hashtag_id INNER JOIN activities a ON a.id = ah.activity_id WHERE h.name = ? AND a.activity_type = 'Create' AND a.local = 0 AND COALESCE(a.restricted, 0) = 0`
+	sqlSelectTrendingHashtags    = `SELECT h.name, COUNT(*) AS uses, COUNT(DISTINCT n.user_id) AS accounts, MAX(n.created_at) AS last_used
+								FROM note_hashtags nh
+								INNER JOIN hashtags h ON h.id = nh.hashtag_id
+								INNER JOIN notes n ON n.id = nh.note_id
+								WHERE n.created_at >= ? AND COALESCE(n.visibility, 'public') = 'public'
+								GROUP BY h.id
+								ORDER BY accounts DESC, uses DESC, last_used DESC
+								LIMIT ?`
 )
 
 // CreateOrUpdateHashtag creates a new hashtag or increments usage count if it exists
@@ -2160,10 +3799,10 @@ func (db *DB) LinkNoteHashtags(noteId uuid.UUID, hashtagIds []int64) error {
 }
 
 // ReadHashtagsByNoteId returns all hashtag names for a given note
-func (db *DB) ReadHashtagsByNoteId(noteId uuid.UUID) (error, []string) {
-	rows, err := db.db.Query(sqlSelectHashtagsByNoteId, noteId.String())
+func (db *DB) ReadHashtagsByNoteId(noteId uuid.UUID) ([]string, error) {
+	rows, err := db.conn().Query(sqlSelectHashtagsByNoteId, noteId.String())
 	if err != nil {
-		return err, nil
+		return nil, err
 	}
 	defer rows.Close()
 
@@ -2171,59 +3810,191 @@ func (db *DB) ReadHashtagsByNoteId(noteId uuid.UUID) (error, []string) {
 	for rows.Next() {
 		var name string
 		if err := rows.Scan(&name); err != nil {
-			return err, hashtags
+			return hashtags, err
 		}
 		hashtags = append(hashtags, name)
 	}
 	if err = rows.Err(); err != nil {
-		return err, hashtags
+		return hashtags, err
+	}
+	return hashtags, nil
+}
+
+// ReadNotesByHashtag returns notes that contain a specific hashtag with pagination, excluding local-only notes
+func (db *DB) ReadNotesByHashtag(tag string, limit, offset int) (*[]domain.Note, error) {
+	rows, err := db.conn().Query(sqlSelectNotesByHashtag, strings.ToLower(tag), limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notes []domain.Note
+	for rows.Next() {
+		var note domain.Note
+		var createdAtStr string
+		var editedAtStr sql.NullString
+		if err := rows.Scan(&note.Id, &note.CreatedBy, &note.Message, &createdAtStr, &editedAtStr, &note.LikeCount, &note.BoostCount); err != nil {
+			return &notes, err
+		}
+
+		if parsedTime, err := parseTimestamp(createdAtStr); err == nil {
+			note.CreatedAt = parsedTime
+		}
+
+		if editedAtStr.Valid {
+			if parsedTime, err := parseTimestamp(editedAtStr.String); err == nil {
+				note.EditedAt = &parsedTime
+			}
+		}
+
+		notes = append(notes, note)
+	}
+	if err = rows.Err(); err != nil {
+		return &notes, err
+	}
+	return &notes, nil
+}
+
+// CountNotesByHashtag returns the total count of notes with a specific hashtag
+func (db *DB) CountNotesByHashtag(tag string) (int, error) {
+	var count int
+	err := db.conn().QueryRow(sqlCountNotesByHashtag, strings.ToLower(tag)).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// LinkActivityHashtags records the hashtags of an inbound remote post, creating any
+// hashtag not seen before, so the post shows up in federated hashtag timelines
+func (db *DB) LinkActivityHashtags(activityId uuid.UUID, names []string) error {
+	return db.wrapTransaction(func(tx *sql.Tx) error {
+		for _, name := range names {
+			var hashtagId int64
+			if err := tx.QueryRow(sqlInsertHashtag, strings.ToLower(name)).Scan(&hashtagId); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(sqlInsertActivityHashtag, activityId.String(), hashtagId); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ReadFederatedNotesByHashtag returns local notes and inbound remote posts with a hashtag,
+// merged newest first, with the same limit/offset pagination as ReadNotesByHashtag
+func (db *DB) ReadFederatedNotesByHashtag(tag string, limit, offset int) (*[]domain.HomePost, error) {
+	// Each source is read up to the end of the requested page, then both are merged and cut
+	notes, err := db.ReadNotesByHashtag(tag, limit+offset, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var posts []domain.HomePost
+	for _, note := range *notes {
+		posts = append(posts, domain.HomePost{
+			ID:         note.Id,
+			Author:     note.CreatedBy,
+			Content:    note.Message,
+			Time:       note.CreatedAt,
+			IsLocal:    true,
+			NoteID:     note.Id,
+			LikeCount:  note.LikeCount,
+			BoostCount: note.BoostCount,
+		})
 	}
-	return nil, hashtags
-}
 
-// ReadNotesByHashtag returns notes that contain a specific hashtag with pagination
-func (db *DB) ReadNotesByHashtag(tag string, limit, offset int) (error, *[]domain.Note) {
-	rows, err := db.db.Query(sqlSelectNotesByHashtag, strings.ToLower(tag), limit, offset)
+	rows, err := db.conn().Query(db.replyCountQuery(sqlSelectRemotePostsByHashtag, sqlActivityReplyCount, "a.object_uri"), strings.ToLower(tag), limit+offset)
 	if err != nil {
-		return err, nil
+		return &posts, err
 	}
 	defer rows.Close()
 
-	var notes []domain.Note
 	for rows.Next() {
-		var note domain.Note
-		var createdAtStr string
-		var editedAtStr sql.NullString
-		if err := rows.Scan(&note.Id, &note.CreatedBy, &note.Message, &createdAtStr, &editedAtStr, &note.LikeCount, &note.BoostCount); err != nil {
-			return err, &notes
+		var idStr, actorURI, rawJSON, createdAtStr, contentHTML, title string
+		var objectURI, username, remDomain sql.NullString
+		var replyCount, likeCount, boostCount int
+		if err := rows.Scan(&idStr, &actorURI, &objectURI, &rawJSON, &createdAtStr, &username, &remDomain, &replyCount, &likeCount, &boostCount, &contentHTML, &title); err != nil {
+			return &posts, err
 		}
 
-		if parsedTime, err := parseTimestamp(createdAtStr); err == nil {
-			note.CreatedAt = parsedTime
-		}
+		activityId, _ := uuid.Parse(idStr)
+		parsedTime, _ := parseTimestamp(createdAtStr)
 
-		if editedAtStr.Valid {
-			if parsedTime, err := parseTimestamp(editedAtStr.String); err == nil {
-				note.EditedAt = &parsedTime
-			}
+		// Relay-forwarded authors may not be cached as remote accounts
+		author := extractAuthorFromActorURI(actorURI)
+		if username.Valid && remDomain.Valid {
+			author = "@" + username.String + "@" + remDomain.String
 		}
 
-		notes = append(notes, note)
+		posts = append(posts, domain.HomePost{
+			ID:         activityId,
+			Author:     author,
+			Title:      title,
+			Content:    remotePostContent(contentHTML, rawJSON),
+			Time:       parsedTime,
+			ObjectURI:  objectURI.String,
+			IsLocal:    false,
+			NoteID:     uuid.Nil,
+			ReplyCount: replyCount,
+			LikeCount:  likeCount,
+			BoostCount: boostCount,
+		})
 	}
 	if err = rows.Err(); err != nil {
-		return err, &notes
+		return &posts, err
 	}
-	return nil, &notes
+
+	sortPostsByTime(posts)
+	if offset >= len(posts) {
+		posts = []domain.HomePost{}
+	} else {
+		posts = posts[offset:min(offset+limit, len(posts))]
+	}
+	return &posts, nil
 }
 
-// CountNotesByHashtag returns the total count of notes with a specific hashtag
-func (db *DB) CountNotesByHashtag(tag string) (int, error) {
-	var count int
-	err := db.db.QueryRow(sqlCountNotesByHashtag, strings.ToLower(tag)).Scan(&count)
+// CountFederatedNotesByHashtag returns the number of local notes and inbound remote posts with a hashtag
+func (db *DB) CountFederatedNotesByHashtag(tag string) (int, error) {
+	localCount, err := db.CountNotesByHashtag(tag)
 	if err != nil {
 		return 0, err
 	}
-	return count, nil
+	var remoteCount int
+	if err := db.conn().QueryRow(sqlCountRemotePostsByHashtag, strings.ToLower(tag)).Scan(&remoteCount); err != nil {
+		return 0, err
+	}
+	return localCount + remoteCount, nil
+}
+
+// ReadTrendingHashtags ranks hashtags by their use in public notes created within window.
+// Tags are ordered by distinct authors first, so one account repeating a tag cannot push it
+// past tags used by many people, then by number of uses.
+func (db *DB) ReadTrendingHashtags(window time.Duration, limit int) ([]domain.HashtagTrend, error) {
+	cutoff := formatTimestamp(time.Now().Add(-window))
+	rows, err := db.conn().Query(sqlSelectTrendingHashtags, cutoff, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var trends []domain.HashtagTrend
+	for rows.Next() {
+		var trend domain.HashtagTrend
+		var lastUsedStr string
+		if err := rows.Scan(&trend.Name, &trend.Uses, &trend.Accounts, &lastUsedStr); err != nil {
+			return trends, err
+		}
+		if parsedTime, err := parseTimestamp(lastUsedStr); err == nil {
+			trend.LastUsedAt = parsedTime
+		}
+		trends = append(trends, trend)
+	}
+	if err = rows.Err(); err != nil {
+		return trends, err
+	}
+	return trends, nil
 }
 
 // Mention queries
@@ -2244,7 +4015,7 @@ func (db *DB) CreateNoteMention(mention *domain.NoteMention) error {
 			mention.MentionedActorURI,
 			strings.ToLower(mention.MentionedUsername),
 			strings.ToLower(mention.MentionedDomain),
-			mention.CreatedAt)
+			formatTimestamp(mention.CreatedAt))
 		return err
 	})
 }
@@ -2259,7 +4030,7 @@ func (db *DB) LinkNoteMentions(noteId uuid.UUID, mentions []domain.NoteMention)
 				mention.MentionedActorURI,
 				strings.ToLower(mention.MentionedUsername),
 				strings.ToLower(mention.MentionedDomain),
-				time.Now())
+				formatTimestamp(time.Now()))
 			if err != nil {
 				return err
 			}
@@ -2269,10 +4040,10 @@ func (db *DB) LinkNoteMentions(noteId uuid.UUID, mentions []domain.NoteMention)
 }
 
 // ReadMentionsByNoteId returns all mentions for a given note
-func (db *DB) ReadMentionsByNoteId(noteId uuid.UUID) (error, []domain.NoteMention) {
-	rows, err := db.db.Query(sqlSelectMentionsByNoteId, noteId.String())
+func (db *DB) ReadMentionsByNoteId(noteId uuid.UUID) ([]domain.NoteMention, error) {
+	rows, err := db.conn().Query(sqlSelectMentionsByNoteId, noteId.String())
 	if err != nil {
-		return err, nil
+		return nil, err
 	}
 	defer rows.Close()
 
@@ -2281,7 +4052,7 @@ func (db *DB) ReadMentionsByNoteId(noteId uuid.UUID) (error, []domain.NoteMentio
 		var mention domain.NoteMention
 		var idStr, noteIdStr, createdAtStr string
 		if err := rows.Scan(&idStr, &noteIdStr, &mention.MentionedActorURI, &mention.MentionedUsername, &mention.MentionedDomain, &createdAtStr); err != nil {
-			return err, mentions
+			return mentions, err
 		}
 		mention.Id = uuid.MustParse(idStr)
 		mention.NoteId = uuid.MustParse(noteIdStr)
@@ -2291,9 +4062,9 @@ func (db *DB) ReadMentionsByNoteId(noteId uuid.UUID) (error, []domain.NoteMentio
 		mentions = append(mentions, mention)
 	}
 	if err = rows.Err(); err != nil {
-		return err, mentions
+		return mentions, err
 	}
-	return nil, mentions
+	return mentions, nil
 }
 
 // DeleteMentionsByNoteId removes all mentions for a specific note
@@ -2315,8 +4086,25 @@ const (
 	sqlDeleteLikeByURI         = `DELETE FROM likes WHERE uri = ?`
 	sqlDeleteLikeByAccountNote = `DELETE FROM likes WHERE account_id = ? AND note_id = ?`
 	sqlUpdateNoteLikeCount     = `UPDATE notes SET like_count = ? WHERE id = ?`
+	sqlSelectLikedObjectURIs   = `SELECT COALESCE(NULLIF(object_uri, ''), 'https://' || ? || '/notes/' || note_id) FROM likes WHERE account_id = ? ORDER BY created_at`
+
+	// Toggling: the like is deleted if it exists, returning its URI for the Undo
+	sqlDeleteLikeByAccountNoteReturning      = `DELETE FROM likes WHERE account_id = ? AND note_id = ? RETURNING uri`
+	sqlDeleteLikeByAccountObjectURIReturning = `DELETE FROM likes WHERE account_id = ? AND object_uri = ? RETURNING uri`
+	sqlInsertLikeByObjectURI                 = `INSERT INTO likes(id, account_id, note_id, uri, object_uri, created_at) VALUES (?, ?, ?, ?, ?, ?)`
+	sqlAddNoteLikeCount                      = `UPDATE notes SET like_count = MAX(COALESCE(like_count, 0) + ?, 0) WHERE id = ?`
+	sqlAddActivityLikeCount                  = `UPDATE activities SET like_count = MAX(COALESCE(like_count, 0) + ?, 0) WHERE object_uri = ?`
+	sqlSelectNoteLikeCount                   = `SELECT COALESCE(like_count, 0) FROM notes WHERE id = ?`
+	sqlSelectActivityLikeCount               = `SELECT COALESCE(MAX(like_count), 0) FROM activities WHERE object_uri = ?`
 )
 
+// ToggleResult is the state of a post after ToggleLike or ToggleBoost
+type ToggleResult struct {
+	Active bool   // The account likes (boosts) the post now
+	Count  int    // The post's like (boost) count afterwards
+	URI    string // Activity URI of the new like (boost), or of the removed one for its Undo
+}
+
 // CreateLike creates a new like record
 func (db *DB) CreateLike(like *domain.Like) error {
 	return db.wrapTransaction(func(tx *sql.Tx) error {
@@ -2325,7 +4113,7 @@ func (db *DB) CreateLike(like *domain.Like) error {
 			like.AccountId.String(),
 			like.NoteId.String(),
 			like.URI,
-			like.CreatedAt)
+			formatTimestamp(like.CreatedAt))
 		return err
 	})
 }
@@ -2333,7 +4121,7 @@ func (db *DB) CreateLike(like *domain.Like) error {
 // HasLike checks if a like already exists for this account and note
 func (db *DB) HasLike(accountId, noteId uuid.UUID) (bool, error) {
 	var count int
-	err := db.db.QueryRow(sqlSelectLikeExists, accountId.String(), noteId.String()).Scan(&count)
+	err := db.conn().QueryRow(sqlSelectLikeExists, accountId.String(), noteId.String()).Scan(&count)
 	if err != nil {
 		return false, err
 	}
@@ -2344,7 +4132,7 @@ func (db *DB) HasLike(accountId, noteId uuid.UUID) (bool, error) {
 func (db *DB) HasLikeByURI(uri string) (bool, error) {
 	var like domain.Like
 	var idStr, accountIdStr, noteIdStr, createdAtStr string
-	err := db.db.QueryRow(sqlSelectLikeByURI, uri).Scan(&idStr, &accountIdStr, &noteIdStr, &like.URI, &createdAtStr)
+	err := db.conn().QueryRow(sqlSelectLikeByURI, uri).Scan(&idStr, &accountIdStr, &noteIdStr, &like.URI, &createdAtStr)
 	if err == sql.ErrNoRows {
 		return false, nil
 	}
@@ -2355,10 +4143,10 @@ func (db *DB) HasLikeByURI(uri string) (bool, error) {
 }
 
 // ReadLikesByNoteId returns all likes for a given note
-func (db *DB) ReadLikesByNoteId(noteId uuid.UUID) (error, []domain.Like) {
-	rows, err := db.db.Query(sqlSelectLikesByNoteId, noteId.String())
+func (db *DB) ReadLikesByNoteId(noteId uuid.UUID) ([]domain.Like, error) {
+	rows, err := db.conn().Query(sqlSelectLikesByNoteId, noteId.String())
 	if err != nil {
-		return err, nil
+		return nil, err
 	}
 	defer rows.Close()
 
@@ -2367,7 +4155,7 @@ func (db *DB) ReadLikesByNoteId(noteId uuid.UUID) (error, []domain.Like) {
 		var like domain.Like
 		var idStr, accountIdStr, noteIdStr, createdAtStr string
 		if err := rows.Scan(&idStr, &accountIdStr, &noteIdStr, &like.URI, &createdAtStr); err != nil {
-			return err, likes
+			return likes, err
 		}
 		like.Id = uuid.MustParse(idStr)
 		like.AccountId = uuid.MustParse(accountIdStr)
@@ -2378,15 +4166,38 @@ func (db *DB) ReadLikesByNoteId(noteId uuid.UUID) (error, []domain.Like) {
 		likes = append(likes, like)
 	}
 	if err = rows.Err(); err != nil {
-		return err, likes
+		return likes, err
+	}
+	return likes, nil
+}
+
+// ReadLikedObjectURIs returns the URIs of every note the given account liked, oldest like first.
+// Likes of local notes have no stored object URI, so theirs is built from localDomain.
+func (db *DB) ReadLikedObjectURIs(accountId uuid.UUID, localDomain string) ([]string, error) {
+	rows, err := db.conn().Query(sqlSelectLikedObjectURIs, localDomain, accountId.String())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var uris []string
+	for rows.Next() {
+		var uri string
+		if err := rows.Scan(&uri); err != nil {
+			return uris, err
+		}
+		uris = append(uris, uri)
+	}
+	if err = rows.Err(); err != nil {
+		return uris, err
 	}
-	return nil, likes
+	return uris, nil
 }
 
 // CountLikesByNoteId returns the number of likes for a given note
 func (db *DB) CountLikesByNoteId(noteId uuid.UUID) (int, error) {
 	var count int
-	err := db.db.QueryRow(sqlCountLikesByNoteId, noteId.String()).Scan(&count)
+	err := db.conn().QueryRow(sqlCountLikesByNoteId, noteId.String()).Scan(&count)
 	if err != nil {
 		return 0, err
 	}
@@ -2426,16 +4237,16 @@ func (db *DB) DecrementLikeCountByNoteId(noteId uuid.UUID) error {
 }
 
 // ReadLikeByAccountAndNote finds a like by the account that created it and the note it's on
-func (db *DB) ReadLikeByAccountAndNote(accountId, noteId uuid.UUID) (error, *domain.Like) {
+func (db *DB) ReadLikeByAccountAndNote(accountId, noteId uuid.UUID) (*domain.Like, error) {
 	var like domain.Like
 	var idStr, accountIdStr, noteIdStr, createdAtStr string
-	err := db.db.QueryRow(sqlSelectLikeByAccountNote, accountId.String(), noteId.String()).Scan(
+	err := db.conn().QueryRow(sqlSelectLikeByAccountNote, accountId.String(), noteId.String()).Scan(
 		&idStr, &accountIdStr, &noteIdStr, &like.URI, &createdAtStr)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 	if err != nil {
-		return err, nil
+		return nil, err
 	}
 	like.Id = uuid.MustParse(idStr)
 	like.AccountId = uuid.MustParse(accountIdStr)
@@ -2443,7 +4254,7 @@ func (db *DB) ReadLikeByAccountAndNote(accountId, noteId uuid.UUID) (error, *dom
 	if parsedTime, err := parseTimestamp(createdAtStr); err == nil {
 		like.CreatedAt = parsedTime
 	}
-	return nil, &like
+	return &like, nil
 }
 
 // DeleteLikeByAccountAndNote removes a like by the account and note IDs
@@ -2473,7 +4284,7 @@ func (db *DB) DecrementLikeCountByObjectURI(objectURI string) error {
 // HasLikeByObjectURI checks if an account has liked a post by its object URI
 func (db *DB) HasLikeByObjectURI(accountId uuid.UUID, objectURI string) (bool, error) {
 	var count int
-	err := db.db.QueryRow(`SELECT COUNT(*) FROM likes WHERE account_id = ? AND object_uri = ?`, accountId.String(), objectURI).Scan(&count)
+	err := db.conn().QueryRow(`SELECT COUNT(*) FROM likes WHERE account_id = ? AND object_uri = ?`, accountId.String(), objectURI).Scan(&count)
 	if err != nil {
 		return false, err
 	}
@@ -2486,29 +4297,29 @@ func (db *DB) CreateLikeByObjectURI(like *domain.Like, objectURI string) error {
 		// Use a deterministic UUID derived from the object_uri as the note_id placeholder
 		// This ensures the unique constraint (account_id, note_id) works correctly for remote posts
 		placeholderNoteId := uuid.NewSHA1(uuid.NameSpaceURL, []byte(objectURI))
-		_, err := tx.Exec(`INSERT INTO likes(id, account_id, note_id, uri, object_uri, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		_, err := tx.Exec(sqlInsertLikeByObjectURI,
 			like.Id.String(),
 			like.AccountId.String(),
 			placeholderNoteId.String(), // Deterministic placeholder based on object_uri
 			like.URI,
 			objectURI,
-			like.CreatedAt.Format(time.RFC3339))
+			formatTimestamp(like.CreatedAt))
 		return err
 	})
 }
 
 // ReadLikeByAccountAndObjectURI finds a like by account ID and object URI
-func (db *DB) ReadLikeByAccountAndObjectURI(accountId uuid.UUID, objectURI string) (error, *domain.Like) {
+func (db *DB) ReadLikeByAccountAndObjectURI(accountId uuid.UUID, objectURI string) (*domain.Like, error) {
 	var like domain.Like
 	var idStr, accountIdStr, noteIdStr, createdAtStr string
 	var objURI sql.NullString
-	err := db.db.QueryRow(`SELECT id, account_id, note_id, uri, object_uri, created_at FROM likes WHERE account_id = ? AND object_uri = ?`,
+	err := db.conn().QueryRow(`SELECT id, account_id, note_id, uri, object_uri, created_at FROM likes WHERE account_id = ? AND object_uri = ?`,
 		accountId.String(), objectURI).Scan(&idStr, &accountIdStr, &noteIdStr, &like.URI, &objURI, &createdAtStr)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 	if err != nil {
-		return err, nil
+		return nil, err
 	}
 	like.Id = uuid.MustParse(idStr)
 	like.AccountId = uuid.MustParse(accountIdStr)
@@ -2518,7 +4329,7 @@ func (db *DB) ReadLikeByAccountAndObjectURI(accountId uuid.UUID, objectURI strin
 	if parsedTime, err := parseTimestamp(createdAtStr); err == nil {
 		like.CreatedAt = parsedTime
 	}
-	return nil, &like
+	return &like, nil
 }
 
 // DeleteLikeByAccountAndObjectURI removes a like by account ID and object URI
@@ -2529,12 +4340,73 @@ func (db *DB) DeleteLikeByAccountAndObjectURI(accountId uuid.UUID, objectURI str
 	})
 }
 
+// ToggleLike likes a post for like.AccountId, or removes the like if there already is one.
+// The like and the post's like count change in one transaction, so they can't disagree after
+// a crash or concurrent toggles. Local notes are identified by like.NoteId; remote posts have
+// no note id and are identified by objectURI instead.
+func (db *DB) ToggleLike(like *domain.Like, objectURI string) (ToggleResult, error) {
+	var result ToggleResult
+	err := db.wrapTransaction(func(tx *sql.Tx) error {
+		local := like.NoteId != uuid.Nil
+		var removedURI string
+		var err error
+		if local {
+			err = tx.QueryRow(sqlDeleteLikeByAccountNoteReturning, like.AccountId.String(), like.NoteId.String()).Scan(&removedURI)
+		} else {
+			err = tx.QueryRow(sqlDeleteLikeByAccountObjectURIReturning, like.AccountId.String(), objectURI).Scan(&removedURI)
+		}
+
+		delta := -1
+		result = ToggleResult{URI: removedURI}
+		switch {
+		case err == sql.ErrNoRows:
+			// Not liked yet, so it is liked now
+			delta = 1
+			result = ToggleResult{Active: true, URI: like.URI}
+			if local {
+				_, err = tx.Exec(sqlInsertLike, like.Id.String(), like.AccountId.String(), like.NoteId.String(), like.URI, formatTimestamp(like.CreatedAt))
+			} else {
+				// The placeholder note id keeps likes of a remote post unique per account, as in CreateLikeByObjectURI
+				_, err = tx.Exec(sqlInsertLikeByObjectURI, like.Id.String(), like.AccountId.String(),
+					uuid.NewSHA1(uuid.NameSpaceURL, []byte(objectURI)).String(), like.URI, objectURI, formatTimestamp(like.CreatedAt))
+			}
+			if err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		}
+
+		if local {
+			if _, err := tx.Exec(sqlAddNoteLikeCount, delta, like.NoteId.String()); err != nil {
+				return err
+			}
+			err = tx.QueryRow(sqlSelectNoteLikeCount, like.NoteId.String()).Scan(&result.Count)
+		} else {
+			if _, err := tx.Exec(sqlAddActivityLikeCount, delta, objectURI); err != nil {
+				return err
+			}
+			err = tx.QueryRow(sqlSelectActivityLikeCount, objectURI).Scan(&result.Count)
+		}
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return err
+	})
+	return result, err
+}
+
 // Boost queries
 const (
 	sqlInsertBoost              = `INSERT INTO boosts(id, account_id, note_id, uri, created_at) VALUES (?, ?, ?, ?, ?)`
 	sqlSelectBoostExists        = `SELECT COUNT(*) FROM boosts WHERE account_id = ? AND note_id = ?`
 	sqlSelectBoostByAccountNote = `SELECT id, account_id, note_id, uri, created_at FROM boosts WHERE account_id = ? AND note_id = ?`
 	sqlDeleteBoostByAccountNote = `DELETE FROM boosts WHERE account_id = ? AND note_id = ?`
+
+	// Toggling: the boost is deleted if it exists, returning its URI for the Undo
+	sqlDeleteBoostByAccountNoteReturning = `DELETE FROM boosts WHERE account_id = ? AND note_id = ? RETURNING uri`
+	sqlAddNoteBoostCount                 = `UPDATE notes SET boost_count = MAX(COALESCE(boost_count, 0) + ?, 0) WHERE id = ?`
+	sqlSelectNoteBoostCount              = `SELECT COALESCE(boost_count, 0) FROM notes WHERE id = ?`
 )
 
 // CreateBoost creates a new boost record
@@ -2545,7 +4417,7 @@ func (db *DB) CreateBoost(boost *domain.Boost) error {
 			boost.AccountId.String(),
 			boost.NoteId.String(),
 			boost.URI,
-			boost.CreatedAt)
+			formatTimestamp(boost.CreatedAt))
 		return err
 	})
 }
@@ -2553,7 +4425,7 @@ func (db *DB) CreateBoost(boost *domain.Boost) error {
 // HasBoost checks if a boost already exists for this account and note
 func (db *DB) HasBoost(accountId, noteId uuid.UUID) (bool, error) {
 	var count int
-	err := db.db.QueryRow(sqlSelectBoostExists, accountId.String(), noteId.String()).Scan(&count)
+	err := db.conn().QueryRow(sqlSelectBoostExists, accountId.String(), noteId.String()).Scan(&count)
 	if err != nil {
 		return false, err
 	}
@@ -2584,15 +4456,109 @@ func (db *DB) DecrementBoostCountByNoteId(noteId uuid.UUID) error {
 	})
 }
 
+// ToggleBoost boosts a note for boost.AccountId, or removes the boost if there already is one.
+// The boost and the note's boost count change in one transaction, like ToggleLike. Boosts are
+// only stored for local notes, identified by boost.NoteId.
+func (db *DB) ToggleBoost(boost *domain.Boost) (ToggleResult, error) {
+	var result ToggleResult
+	err := db.wrapTransaction(func(tx *sql.Tx) error {
+		var removedURI string
+		err := tx.QueryRow(sqlDeleteBoostByAccountNoteReturning, boost.AccountId.String(), boost.NoteId.String()).Scan(&removedURI)
+
+		delta := -1
+		result = ToggleResult{URI: removedURI}
+		switch {
+		case err == sql.ErrNoRows:
+			delta = 1
+			result = ToggleResult{Active: true, URI: boost.URI}
+			if _, err := tx.Exec(sqlInsertBoost, boost.Id.String(), boost.AccountId.String(), boost.NoteId.String(), boost.URI, formatTimestamp(boost.CreatedAt)); err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		}
+
+		if _, err := tx.Exec(sqlAddNoteBoostCount, delta, boost.NoteId.String()); err != nil {
+			return err
+		}
+		err = tx.QueryRow(sqlSelectNoteBoostCount, boost.NoteId.String()).Scan(&result.Count)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return err
+	})
+	return result, err
+}
+
+// Reaction queries
+const (
+	sqlInsertReaction             = `INSERT OR IGNORE INTO reactions(id, object_uri, emoji, actor_uri, uri, created_at) VALUES (?, ?, ?, ?, ?, ?)`
+	sqlDeleteReaction             = `DELETE FROM reactions WHERE actor_uri = ? AND object_uri = ? AND emoji = ?`
+	sqlDeleteReactionByURI        = `DELETE FROM reactions WHERE actor_uri = ? AND uri = ?`
+	sqlSelectReactionsByObjectURI = `SELECT emoji, COUNT(*) FROM reactions WHERE object_uri = ? GROUP BY emoji ORDER BY COUNT(*) DESC, MIN(created_at) ASC`
+)
+
+// CreateReaction stores an emoji reaction. Repeating the same reaction is ignored.
+func (db *DB) CreateReaction(reaction *domain.Reaction) error {
+	return db.wrapTransaction(func(tx *sql.Tx) error {
+		_, err := tx.Exec(sqlInsertReaction,
+			reaction.Id.String(),
+			reaction.ObjectURI,
+			reaction.Emoji,
+			reaction.ActorURI,
+			reaction.URI,
+			formatTimestamp(reaction.CreatedAt))
+		return err
+	})
+}
+
+// DeleteReaction removes an actor's reaction with the given emoji from a post
+func (db *DB) DeleteReaction(actorURI, objectURI, emoji string) error {
+	return db.wrapTransaction(func(tx *sql.Tx) error {
+		_, err := tx.Exec(sqlDeleteReaction, actorURI, objectURI, emoji)
+		return err
+	})
+}
+
+// DeleteReactionByURI removes an actor's reaction by its activity URI, for Undos that don't repeat the emoji
+func (db *DB) DeleteReactionByURI(actorURI, uri string) error {
+	return db.wrapTransaction(func(tx *sql.Tx) error {
+		_, err := tx.Exec(sqlDeleteReactionByURI, actorURI, uri)
+		return err
+	})
+}
+
+// ReadReactionsByObjectURI returns the number of reactions per emoji on a post, most used first
+func (db *DB) ReadReactionsByObjectURI(objectURI string) (*[]domain.ReactionCount, error) {
+	rows, err := db.conn().Query(sqlSelectReactionsByObjectURI, objectURI)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reactions []domain.ReactionCount
+	for rows.Next() {
+		var reaction domain.ReactionCount
+		if err := rows.Scan(&reaction.Emoji, &reaction.Count); err != nil {
+			return &reactions, err
+		}
+		reactions = append(reactions, reaction)
+	}
+	if err = rows.Err(); err != nil {
+		return &reactions, err
+	}
+	return &reactions, nil
+}
+
 // Reply query methods
 
 // ReadRepliesByNoteId returns all direct replies to a local note by its UUID
-func (db *DB) ReadRepliesByNoteId(noteId uuid.UUID) (error, *[]domain.Note) {
+func (db *DB) ReadRepliesByNoteId(noteId uuid.UUID) (*[]domain.Note, error) {
 	// Build the object_uri for this note to find replies
 	// First get the note to check if it has an object_uri
-	err, note := db.ReadNoteId(noteId)
+	note, err := db.ReadNoteId(noteId)
 	if err != nil || note == nil {
-		return err, nil
+		return nil, err
 	}
 
 	// If the note has an object_uri, search by that
@@ -2601,15 +4567,15 @@ func (db *DB) ReadRepliesByNoteId(noteId uuid.UUID) (error, *[]domain.Note) {
 	}
 
 	// Otherwise search by the note ID in the in_reply_to_uri (for local notes without object_uri)
-	rows, err := db.db.Query(`
-		SELECT n.id, a.username, n.message, n.created_at, n.edited_at, n.in_reply_to_uri, n.object_uri, COALESCE(n.like_count, 0), COALESCE(n.boost_count, 0)
+	rows, err := db.conn().Query(`
+		SELECT n.id, a.username, CASE WHEN n.deleted_at IS NULL THEN n.message ELSE '[deleted]' END, n.created_at, n.edited_at, n.in_reply_to_uri, n.object_uri, COALESCE(n.like_count, 0), COALESCE(n.boost_count, 0), COALESCE(n.visibility, 'public'), n.deleted_at
 		FROM notes n
 		INNER JOIN accounts a ON a.id = n.user_id
 		WHERE n.in_reply_to_uri LIKE ?
 		ORDER BY n.created_at ASC`,
 		"%"+noteId.String()+"%")
 	if err != nil {
-		return err, nil
+		return nil, err
 	}
 	defer rows.Close()
 
@@ -2617,16 +4583,16 @@ func (db *DB) ReadRepliesByNoteId(noteId uuid.UUID) (error, *[]domain.Note) {
 }
 
 // ReadRepliesByURI returns all direct replies to a note by its ActivityPub URI
-func (db *DB) ReadRepliesByURI(objectURI string) (error, *[]domain.Note) {
-	rows, err := db.db.Query(`
-		SELECT n.id, a.username, n.message, n.created_at, n.edited_at, n.in_reply_to_uri, n.object_uri, COALESCE(n.like_count, 0), COALESCE(n.boost_count, 0)
+func (db *DB) ReadRepliesByURI(objectURI string) (*[]domain.Note, error) {
+	rows, err := db.conn().Query(`
+		SELECT n.id, a.username, CASE WHEN n.deleted_at IS NULL THEN n.message ELSE '[deleted]' END, n.created_at, n.edited_at, n.in_reply_to_uri, n.object_uri, COALESCE(n.like_count, 0), COALESCE(n.boost_count, 0), COALESCE(n.visibility, 'public'), n.deleted_at
 		FROM notes n
 		INNER JOIN accounts a ON a.id = n.user_id
 		WHERE n.in_reply_to_uri = ?
 		ORDER BY n.created_at ASC`,
 		objectURI)
 	if err != nil {
-		return err, nil
+		return nil, err
 	}
 	defer rows.Close()
 
@@ -2636,7 +4602,7 @@ func (db *DB) ReadRepliesByURI(objectURI string) (error, *[]domain.Note) {
 // CountRepliesByNoteId counts the number of direct replies to a local note
 func (db *DB) CountRepliesByNoteId(noteId uuid.UUID) (int, error) {
 	// First get the note's object_uri
-	err, note := db.ReadNoteId(noteId)
+	note, err := db.ReadNoteId(noteId)
 	if err != nil || note == nil {
 		return 0, err
 	}
@@ -2647,7 +4613,7 @@ func (db *DB) CountRepliesByNoteId(noteId uuid.UUID) (int, error) {
 
 	// Count by note ID in in_reply_to_uri
 	var count int
-	err = db.db.QueryRow(`SELECT COUNT(*) FROM notes WHERE in_reply_to_uri LIKE ?`,
+	err = db.conn().QueryRow(`SELECT COUNT(*) FROM notes WHERE in_reply_to_uri LIKE ?`,
 		"%"+noteId.String()+"%").Scan(&count)
 	if err != nil {
 		return 0, err
@@ -2658,13 +4624,148 @@ func (db *DB) CountRepliesByNoteId(noteId uuid.UUID) (int, error) {
 // CountRepliesByURI counts the number of direct replies to a note by URI
 func (db *DB) CountRepliesByURI(objectURI string) (int, error) {
 	var count int
-	err := db.db.QueryRow(`SELECT COUNT(*) FROM notes WHERE in_reply_to_uri = ?`, objectURI).Scan(&count)
+	err := db.conn().QueryRow(`SELECT COUNT(*) FROM notes WHERE in_reply_to_uri = ?`, objectURI).Scan(&count)
 	if err != nil {
 		return 0, err
 	}
 	return count, nil
 }
 
+// threadReplyCounts reports whether replies are counted per thread when read
+// (util.ReplyCountModeThread) instead of being maintained on every ancestor
+func (db *DB) threadReplyCounts() bool {
+	return db.conf != nil && db.conf.Conf.ReplyCountMode == util.ReplyCountModeThread
+}
+
+// threadRootURI returns the root of the thread a reply to parentURI belongs to: the parent's
+// own thread root if it is a stored reply, otherwise the parent itself
+func (db *DB) threadRootURI(tx *sql.Tx, parentURI string) string {
+	var rootURI string
+	if noteIdStr, ok := strings.CutPrefix(parentURI, "local:"); ok {
+		// A local: parent is resolved to its object_uri so the thread shares one root
+		tx.QueryRow(`SELECT COALESCE(NULLIF(thread_root_uri, ''), object_uri, '') FROM notes WHERE id = ?`, noteIdStr).Scan(&rootURI)
+	} else {
+		tx.QueryRow(`SELECT COALESCE(thread_root_uri, '') FROM notes WHERE object_uri = ?
+			UNION ALL SELECT COALESCE(thread_root_uri, '') FROM activities WHERE object_uri = ? AND activity_type = 'Create'
+			LIMIT 1`, parentURI, parentURI).Scan(&rootURI)
+	}
+	if rootURI == "" {
+		return parentURI
+	}
+	return rootURI
+}
+
+// Reply count expressions of the timeline queries. In the thread reply count mode the stored
+// counters are swapped for an indexed count of the posts whose thread root is the post itself.
+const (
+	sqlNoteReplyCount     = `COALESCE(notes.reply_count, 0)`
+	sqlActivityReplyCount = `COALESCE(a.reply_count, 0)`
+	sqlThreadReplyCount   = `((SELECT COUNT(*) FROM notes r WHERE r.thread_root_uri = %[1]s AND r.deleted_at IS NULL) +
+		(SELECT COUNT(*) FROM activities r WHERE r.thread_root_uri = %[1]s AND r.activity_type = 'Create' AND r.local = 0))`
+)
+
+// replyCountQuery adapts a query selecting storedExpr to the configured reply count mode.
+// objectURIColumn is the column holding the object URI of the counted post.
+func (db *DB) replyCountQuery(query, storedExpr, objectURIColumn string) string {
+	if !db.threadReplyCounts() {
+		return query
+	}
+	return strings.ReplaceAll(query, storedExpr, fmt.Sprintf(sqlThreadReplyCount, objectURIColumn))
+}
+
+// CountThreadReplies counts all replies in the thread rooted at rootURI with a single
+// indexed lookup of thread_root_uri
+func (db *DB) CountThreadReplies(rootURI string) (int, error) {
+	var count int
+	err := db.conn().QueryRow(`SELECT `+fmt.Sprintf(sqlThreadReplyCount, "?"), rootURI, rootURI).Scan(&count)
+	return count, err
+}
+
+// RecalculateReplyCounts repairs reply counts that drifted: it reassigns the thread root of
+// every reply and recomputes the stored reply_count of all notes and activities
+func (db *DB) RecalculateReplyCounts() error {
+	return db.wrapTransaction(func(tx *sql.Tx) error {
+		if err := db.assignThreadRoots(tx); err != nil {
+			return err
+		}
+		return db.recountReplies(tx)
+	})
+}
+
+// assignThreadRoots sets thread_root_uri on every stored reply by following its
+// in-reply-to chain up to the first post that is not a stored reply
+func (db *DB) assignThreadRoots(tx *sql.Tx) error {
+	type reply struct {
+		table     string
+		id        string
+		parentURI string
+	}
+	var replies []reply
+	parents := make(map[string]string)  // object URI -> parent URI
+	noteURIs := make(map[string]string) // note id -> object URI, to resolve local: parents
+
+	noteRows, err := tx.Query(`SELECT id, COALESCE(object_uri, ''), COALESCE(in_reply_to_uri, '') FROM notes`)
+	if err != nil {
+		return err
+	}
+	for noteRows.Next() {
+		var id, objectURI, parentURI string
+		if err := noteRows.Scan(&id, &objectURI, &parentURI); err != nil {
+			continue
+		}
+		noteURIs[id] = objectURI
+		if parentURI != "" {
+			replies = append(replies, reply{table: "notes", id: id, parentURI: parentURI})
+			if objectURI != "" {
+				parents[objectURI] = parentURI
+			}
+		}
+	}
+	noteRows.Close()
+
+	activityRows, err := tx.Query(`SELECT a.id, COALESCE(a.object_uri, ''), a.raw_json FROM activities a
+		WHERE a.activity_type = 'Create' AND a.local = 0
+		AND NOT EXISTS (SELECT 1 FROM notes n WHERE n.object_uri = a.object_uri)`)
+	if err != nil {
+		return err
+	}
+	for activityRows.Next() {
+		var id, objectURI, rawJSON string
+		if err := activityRows.Scan(&id, &objectURI, &rawJSON); err != nil {
+			continue
+		}
+		if parentURI := extractInReplyToFromJSON(rawJSON); parentURI != "" {
+			replies = append(replies, reply{table: "activities", id: id, parentURI: parentURI})
+			if objectURI != "" {
+				parents[objectURI] = parentURI
+			}
+		}
+	}
+	activityRows.Close()
+
+	resolve := func(uri string) string {
+		if noteId, ok := strings.CutPrefix(uri, "local:"); ok && noteURIs[noteId] != "" {
+			return noteURIs[noteId]
+		}
+		return uri
+	}
+
+	tx.Exec(`UPDATE notes SET thread_root_uri = NULL`)
+	tx.Exec(`UPDATE activities SET thread_root_uri = NULL`)
+	for _, r := range replies {
+		rootURI := resolve(r.parentURI)
+		visited := map[string]bool{}
+		for parents[rootURI] != "" && !visited[rootURI] {
+			visited[rootURI] = true
+			rootURI = resolve(parents[rootURI])
+		}
+		if _, err := tx.Exec(`UPDATE `+r.table+` SET thread_root_uri = ? WHERE id = ?`, rootURI, r.id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // incrementReplyCount increments the reply_count on the parent note or activity AND all ancestors
 // This ensures that root-level posts show the total count of all nested replies
 func (db *DB) incrementReplyCount(tx *sql.Tx, parentURI string) {
@@ -2701,25 +4802,6 @@ func (db *DB) incrementReplyCountRecursive(tx *sql.Tx, uri string, visited map[s
 		return
 	}
 
-	// Try to increment on notes table (by note ID in URI pattern like /notes/{uuid})
-	if strings.Contains(uri, "/notes/") {
-		parts := strings.Split(uri, "/notes/")
-		if len(parts) == 2 {
-			noteIdStr := parts[1]
-			// Remove any trailing path
-			if idx := strings.Index(noteIdStr, "/"); idx > 0 {
-				noteIdStr = noteIdStr[:idx]
-			}
-			result, _ = tx.Exec(`UPDATE notes SET reply_count = reply_count + 1 WHERE id = ?`, noteIdStr)
-			if rowsAffected, _ := result.RowsAffected(); rowsAffected > 0 {
-				// Found and updated a note, get its parent
-				tx.QueryRow(`SELECT COALESCE(in_reply_to_uri, '') FROM notes WHERE id = ?`, noteIdStr).Scan(&nextParentURI)
-				db.incrementReplyCountRecursive(tx, nextParentURI, visited)
-				return
-			}
-		}
-	}
-
 	// Try to increment on activities table (for remote posts)
 	result, _ = tx.Exec(`UPDATE activities SET reply_count = reply_count + 1 WHERE object_uri = ?`, uri)
 	if rowsAffected, _ := result.RowsAffected(); rowsAffected > 0 {
@@ -2785,23 +4867,6 @@ func (db *DB) decrementReplyCountRecursive(tx *sql.Tx, uri string, visited map[s
 		return
 	}
 
-	// Try to decrement on notes table (by note ID in URI pattern)
-	if strings.Contains(uri, "/notes/") {
-		parts := strings.Split(uri, "/notes/")
-		if len(parts) == 2 {
-			noteIdStr := parts[1]
-			if idx := strings.Index(noteIdStr, "/"); idx > 0 {
-				noteIdStr = noteIdStr[:idx]
-			}
-			result, _ = tx.Exec(`UPDATE notes SET reply_count = MAX(0, reply_count - 1) WHERE id = ?`, noteIdStr)
-			if rowsAffected, _ := result.RowsAffected(); rowsAffected > 0 {
-				tx.QueryRow(`SELECT COALESCE(in_reply_to_uri, '') FROM notes WHERE id = ?`, noteIdStr).Scan(&nextParentURI)
-				db.decrementReplyCountRecursive(tx, nextParentURI, visited)
-				return
-			}
-		}
-	}
-
 	// Try to decrement on activities table
 	result, _ = tx.Exec(`UPDATE activities SET reply_count = MAX(0, reply_count - 1) WHERE object_uri = ?`, uri)
 	if rowsAffected, _ := result.RowsAffected(); rowsAffected > 0 {
@@ -2815,6 +4880,10 @@ func (db *DB) decrementReplyCountRecursive(tx *sql.Tx, uri string, visited map[s
 // IncrementReplyCountByURI increments the reply_count on a note or activity by URI
 // This is used when receiving remote replies via ActivityPub inbox
 func (db *DB) IncrementReplyCountByURI(parentURI string) error {
+	if db.threadReplyCounts() {
+		// Thread counts are computed when read; the reply's thread_root_uri is all they need
+		return nil
+	}
 	return db.wrapTransaction(func(tx *sql.Tx) error {
 		db.incrementReplyCount(tx, parentURI)
 		return nil
@@ -2824,169 +4893,67 @@ func (db *DB) IncrementReplyCountByURI(parentURI string) error {
 // CountTotalRepliesByNoteId counts all replies (recursively) to a local note
 // This includes direct replies and all nested replies in the thread
 func (db *DB) CountTotalRepliesByNoteId(noteId uuid.UUID) (int, error) {
-	// First get the note's object_uri
-	err, note := db.ReadNoteId(noteId)
-	if err != nil || note == nil {
+	var objectURI string
+	err := db.conn().QueryRow(`SELECT COALESCE(object_uri, '') FROM notes WHERE id = ?`, noteId.String()).Scan(&objectURI)
+	if err != nil || objectURI == "" {
 		return 0, err
 	}
-
-	if note.ObjectURI != "" {
-		return db.CountTotalRepliesByURI(note.ObjectURI)
-	}
-
-	// For notes without object_uri, use the note ID pattern
-	localPattern := "%" + noteId.String() + "%"
-	return db.countTotalRepliesRecursive(localPattern, "")
+	return db.CountTotalRepliesByURI(objectURI)
 }
 
 // CountTotalRepliesByURI counts all replies (recursively) to a note by URI
 // This includes direct replies, remote replies, and all nested replies
 func (db *DB) CountTotalRepliesByURI(objectURI string) (int, error) {
-	return db.countTotalRepliesRecursive("", objectURI)
+	return db.countTotalRepliesRecursive(objectURI, make(map[string]bool))
 }
 
 // countTotalRepliesRecursive recursively counts all replies in a thread
 // It handles both local notes (by in_reply_to_uri) and remote activities (by inReplyTo in raw_json)
-func (db *DB) countTotalRepliesRecursive(localPattern string, objectURI string) (int, error) {
-	totalCount := 0
-
-	// Get direct local replies
-	var localReplies []struct {
-		ID        uuid.UUID
-		ObjectURI string
-	}
-
-	var rows *sql.Rows
-	var err error
-
-	if objectURI != "" {
-		// Search by exact object_uri match
-		rows, err = db.db.Query(`
-			SELECT n.id, COALESCE(n.object_uri, '') as object_uri
-			FROM notes n
-			WHERE n.in_reply_to_uri = ?`,
-			objectURI)
-	} else if localPattern != "" {
-		// Search by note ID pattern in in_reply_to_uri
-		rows, err = db.db.Query(`
-			SELECT n.id, COALESCE(n.object_uri, '') as object_uri
-			FROM notes n
-			WHERE n.in_reply_to_uri LIKE ?`,
-			localPattern)
-	} else {
+func (db *DB) countTotalRepliesRecursive(objectURI string, visited map[string]bool) (int, error) {
+	if objectURI == "" || visited[objectURI] {
 		return 0, nil
 	}
+	visited[objectURI] = true
 
+	// Get direct local replies
+	rows, err := db.conn().Query(`SELECT COALESCE(object_uri, '') FROM notes WHERE in_reply_to_uri = ?`, objectURI)
 	if err != nil {
 		return 0, err
 	}
-	defer rows.Close()
-
+	var replyURIs []string
 	for rows.Next() {
-		var idStr string
 		var uri string
-		if err := rows.Scan(&idStr, &uri); err != nil {
+		if err := rows.Scan(&uri); err != nil {
 			continue
 		}
-		id, _ := uuid.Parse(idStr)
-		localReplies = append(localReplies, struct {
-			ID        uuid.UUID
-			ObjectURI string
-		}{ID: id, ObjectURI: uri})
-	}
-
-	// Count direct local replies
-	totalCount += len(localReplies)
-
-	// Count direct remote replies (only if we have an objectURI)
-	if objectURI != "" {
-		remoteCount, _ := db.CountActivitiesByInReplyTo(objectURI)
-		totalCount += remoteCount
-
-		// Get remote reply URIs for recursive counting
-		err, remoteActivities := db.ReadActivitiesByInReplyTo(objectURI)
-		if err == nil && remoteActivities != nil {
-			for _, activity := range *remoteActivities {
-				if activity.ObjectURI != "" {
-					// Recursively count replies to remote replies
-					subCount, _ := db.countTotalRepliesRecursive("", activity.ObjectURI)
-					totalCount += subCount
-				}
-			}
+		replyURIs = append(replyURIs, uri)
+	}
+	rows.Close()
+	totalCount := len(replyURIs)
+
+	// Get direct remote replies
+	remoteCount, _ := db.CountActivitiesByInReplyTo(objectURI)
+	totalCount += remoteCount
+	remoteActivities, err := db.ReadActivitiesByInReplyTo(objectURI)
+	if err == nil && remoteActivities != nil {
+		for _, activity := range *remoteActivities {
+			replyURIs = append(replyURIs, activity.ObjectURI)
 		}
 	}
 
-	// Recursively count replies to local replies
-	for _, reply := range localReplies {
-		if reply.ObjectURI != "" {
-			subCount, _ := db.countTotalRepliesRecursive("", reply.ObjectURI)
-			totalCount += subCount
-		} else {
-			// For notes without object_uri, we need to check both:
-			// 1. Local notes that reply using the note ID pattern
-			// 2. Remote activities that reply using the full constructed URI
-
-			// Check local replies by note ID pattern
-			subPattern := "%" + reply.ID.String() + "%"
-			subCount, _ := db.countTotalRepliesRecursive(subPattern, "")
-			totalCount += subCount
-
-			// Also check remote activities that might use the full URI pattern
-			// Remote servers use URIs like: https://domain/notes/{uuid}
-			// We search for /notes/{uuid} pattern in raw_json directly
-			noteURIPattern := "/notes/" + reply.ID.String()
-			remoteReplyCount, _ := db.countRemoteRepliesByURIPattern(noteURIPattern)
-			totalCount += remoteReplyCount
-		}
+	// Recursively count replies to the replies
+	for _, uri := range replyURIs {
+		subCount, _ := db.countTotalRepliesRecursive(uri, visited)
+		totalCount += subCount
 	}
 
 	return totalCount, nil
 }
 
-// countRemoteRepliesByURIPattern counts remote activities where inReplyTo contains the given URI pattern
-// and recursively counts replies to those activities
-func (db *DB) countRemoteRepliesByURIPattern(uriPattern string) (int, error) {
-	// Find activities where inReplyTo contains this pattern
-	rows, err := db.db.Query(`
-		SELECT object_uri FROM activities
-		WHERE activity_type = 'Create'
-		AND raw_json LIKE ?`,
-		`%"inReplyTo":"%`+uriPattern+`%`)
-	if err != nil {
-		return 0, err
-	}
-	defer rows.Close()
-
-	var count int
-	var remoteObjectURIs []string
-
-	for rows.Next() {
-		var objectURI string
-		if err := rows.Scan(&objectURI); err != nil {
-			continue
-		}
-		count++
-		if objectURI != "" {
-			remoteObjectURIs = append(remoteObjectURIs, objectURI)
-		}
-	}
-
-	// Recursively count replies to these remote activities
-	for _, uri := range remoteObjectURIs {
-		subCount, _ := db.countTotalRepliesRecursive("", uri)
-		count += subCount
-	}
-
-	return count, nil
-}
-
 // ReadNoteByURI finds a local note by its ActivityPub object_uri
-// It first tries an exact match on the object_uri column, then falls back
-// to extracting the UUID from the URI pattern /notes/{uuid}
-func (db *DB) ReadNoteByURI(objectURI string) (error, *domain.Note) {
-	// First try exact match on object_uri column
-	row := db.db.QueryRow(`
-		SELECT n.id, a.username, n.message, n.created_at, n.edited_at, n.in_reply_to_uri, n.object_uri, COALESCE(n.like_count, 0), COALESCE(n.boost_count, 0)
+func (db *DB) ReadNoteByURI(objectURI string) (*domain.Note, error) {
+	row := db.conn().QueryRow(`
+		SELECT n.id, a.username, CASE WHEN n.deleted_at IS NULL THEN n.message ELSE '[deleted]' END, n.created_at, n.edited_at, n.in_reply_to_uri, n.object_uri, COALESCE(n.like_count, 0), COALESCE(n.boost_count, 0), COALESCE(n.visibility, 'public'), n.deleted_at
 		FROM notes n
 		INNER JOIN accounts a ON a.id = n.user_id
 		WHERE n.object_uri = ?`,
@@ -2994,45 +4961,49 @@ func (db *DB) ReadNoteByURI(objectURI string) (error, *domain.Note) {
 
 	var note domain.Note
 	var createdAtStr string
-	var editedAtStr, inReplyToURI, noteObjectURI sql.NullString
-	err := row.Scan(&note.Id, &note.CreatedBy, &note.Message, &createdAtStr, &editedAtStr, &inReplyToURI, &noteObjectURI, &note.LikeCount, &note.BoostCount)
-	if err == nil {
-		note.CreatedAt, _ = parseTimestamp(createdAtStr)
-		if editedAtStr.Valid {
-			if parsedTime, err := parseTimestamp(editedAtStr.String); err == nil {
-				note.EditedAt = &parsedTime
-			}
+	var editedAtStr, inReplyToURI, noteObjectURI, deletedAtStr sql.NullString
+	err := row.Scan(&note.Id, &note.CreatedBy, &note.Message, &createdAtStr, &editedAtStr, &inReplyToURI, &noteObjectURI, &note.LikeCount, &note.BoostCount, &note.Visibility, &deletedAtStr)
+	if err != nil {
+		return nil, err
+	}
+
+	note.CreatedAt, _ = parseTimestamp(createdAtStr)
+	if editedAtStr.Valid {
+		if parsedTime, err := parseTimestamp(editedAtStr.String); err == nil {
+			note.EditedAt = &parsedTime
 		}
-		note.InReplyToURI = inReplyToURI.String
-		note.ObjectURI = noteObjectURI.String
-		return nil, &note
 	}
+	note.InReplyToURI = inReplyToURI.String
+	note.ObjectURI = noteObjectURI.String
+	note.DeletedAt = parseDeletedAt(deletedAtStr)
+	return &note, nil
+}
 
-	// If not found by object_uri, try extracting UUID from URI pattern /notes/{uuid}
-	// This handles cases where object_uri wasn't stored in the database
-	if err == sql.ErrNoRows {
-		// Extract UUID from URI like "https://domain/notes/414b193d-0b53-4657-b1bf-eb3a6091d672"
-		parts := strings.Split(objectURI, "/notes/")
-		if len(parts) == 2 {
-			noteIdStr := parts[1]
-			// Remove any trailing path segments
-			if idx := strings.Index(noteIdStr, "/"); idx > 0 {
-				noteIdStr = noteIdStr[:idx]
-			}
-			if noteId, parseErr := uuid.Parse(noteIdStr); parseErr == nil {
-				// Found a valid UUID, look up by ID
-				return db.ReadNoteIdWithReplyInfo(noteId)
-			}
-		}
-	}
+// UpdateNoteObjectJSON stores the Note object of a local note as it was federated. Editing
+// or deleting the note drops it until the note's Update stores the new one, so a stale copy
+// is never served.
+func (db *DB) UpdateNoteObjectJSON(noteId uuid.UUID, objectJSON string) error {
+	return db.wrapTransaction(func(tx *sql.Tx) error {
+		_, err := tx.Exec(`UPDATE notes SET object_json = ? WHERE id = ?`, objectJSON, noteId.String())
+		return err
+	})
+}
 
-	return err, nil
+// ReadNoteObjectJSON returns the stored Note object of a local note, or an empty string if
+// it was never federated
+func (db *DB) ReadNoteObjectJSON(noteId uuid.UUID) (string, error) {
+	var objectJSON sql.NullString
+	err := db.conn().QueryRow(`SELECT object_json FROM notes WHERE id = ?`, noteId.String()).Scan(&objectJSON)
+	if err != nil {
+		return "", err
+	}
+	return objectJSON.String, nil
 }
 
 // ReadNoteIdWithReplyInfo returns a note with full reply information
-func (db *DB) ReadNoteIdWithReplyInfo(id uuid.UUID) (error, *domain.Note) {
-	row := db.db.QueryRow(`
-		SELECT n.id, a.username, n.message, n.created_at, n.edited_at, n.in_reply_to_uri, n.object_uri, COALESCE(n.like_count, 0), COALESCE(n.boost_count, 0)
+func (db *DB) ReadNoteIdWithReplyInfo(id uuid.UUID) (*domain.Note, error) {
+	row := db.conn().QueryRow(`
+		SELECT n.id, a.username, CASE WHEN n.deleted_at IS NULL THEN n.message ELSE '[deleted]' END, n.created_at, n.edited_at, n.in_reply_to_uri, n.object_uri, COALESCE(n.like_count, 0), COALESCE(n.boost_count, 0), COALESCE(n.visibility, 'public'), COALESCE(n.language, ''), n.deleted_at
 		FROM notes n
 		INNER JOIN accounts a ON a.id = n.user_id
 		WHERE n.id = ?`,
@@ -3040,13 +5011,13 @@ func (db *DB) ReadNoteIdWithReplyInfo(id uuid.UUID) (error, *domain.Note) {
 
 	var note domain.Note
 	var createdAtStr string
-	var editedAtStr, inReplyToURI, objectURI sql.NullString
-	err := row.Scan(&note.Id, &note.CreatedBy, &note.Message, &createdAtStr, &editedAtStr, &inReplyToURI, &objectURI, &note.LikeCount, &note.BoostCount)
+	var editedAtStr, inReplyToURI, objectURI, deletedAtStr sql.NullString
+	err := row.Scan(&note.Id, &note.CreatedBy, &note.Message, &createdAtStr, &editedAtStr, &inReplyToURI, &objectURI, &note.LikeCount, &note.BoostCount, &note.Visibility, &note.Language, &deletedAtStr)
 	if err == sql.ErrNoRows {
-		return err, nil
+		return nil, err
 	}
 	if err != nil {
-		return err, nil
+		return nil, err
 	}
 
 	note.CreatedAt, _ = parseTimestamp(createdAtStr)
@@ -3057,19 +5028,20 @@ func (db *DB) ReadNoteIdWithReplyInfo(id uuid.UUID) (error, *domain.Note) {
 	}
 	note.InReplyToURI = inReplyToURI.String
 	note.ObjectURI = objectURI.String
+	note.DeletedAt = parseDeletedAt(deletedAtStr)
 
-	return nil, &note
+	return &note, nil
 }
 
 // scanNotesWithReplyInfo is a helper to scan notes rows including reply info
-func (db *DB) scanNotesWithReplyInfo(rows *sql.Rows) (error, *[]domain.Note) {
+func (db *DB) scanNotesWithReplyInfo(rows *sql.Rows) (*[]domain.Note, error) {
 	var notes []domain.Note
 	for rows.Next() {
 		var note domain.Note
 		var createdAtStr string
-		var editedAtStr, inReplyToURI, objectURI sql.NullString
-		if err := rows.Scan(&note.Id, &note.CreatedBy, &note.Message, &createdAtStr, &editedAtStr, &inReplyToURI, &objectURI, &note.LikeCount, &note.BoostCount); err != nil {
-			return err, &notes
+		var editedAtStr, inReplyToURI, objectURI, deletedAtStr sql.NullString
+		if err := rows.Scan(&note.Id, &note.CreatedBy, &note.Message, &createdAtStr, &editedAtStr, &inReplyToURI, &objectURI, &note.LikeCount, &note.BoostCount, &note.Visibility, &deletedAtStr); err != nil {
+			return &notes, err
 		}
 
 		if parsedTime, err := parseTimestamp(createdAtStr); err == nil {
@@ -3082,31 +5054,32 @@ func (db *DB) scanNotesWithReplyInfo(rows *sql.Rows) (error, *[]domain.Note) {
 		}
 		note.InReplyToURI = inReplyToURI.String
 		note.ObjectURI = objectURI.String
+		note.DeletedAt = parseDeletedAt(deletedAtStr)
 
 		notes = append(notes, note)
 	}
 	if err := rows.Err(); err != nil {
-		return err, &notes
+		return &notes, err
 	}
-	return nil, &notes
+	return &notes, nil
 }
 
 // ReadActivitiesByInReplyTo finds all Create activities that are replies to the given URI
 // This searches the raw_json field for "inReplyTo":"<uri>" patterns
 // It supports both exact URI matches and partial matches (for notes without stored object_uri)
-func (db *DB) ReadActivitiesByInReplyTo(parentURI string) (error, *[]domain.Activity) {
+func (db *DB) ReadActivitiesByInReplyTo(parentURI string) (*[]domain.Activity, error) {
 	// Search for activities where the inReplyTo field matches the parentURI
 	// We search in raw_json since inReplyTo is nested in the object
-	rows, err := db.db.Query(`
+	rows, err := db.conn().Query(`
 		SELECT id, activity_uri, activity_type, actor_uri, object_uri, raw_json, processed, local, created_at, COALESCE(like_count, 0), COALESCE(boost_count, 0)
 		FROM activities
-		WHERE activity_type = 'Create'
+		WHERE activity_type = 'Create' AND local = 0
 		AND (raw_json LIKE ? OR raw_json LIKE ?)
 		ORDER BY created_at ASC`,
 		`%"inReplyTo":"`+parentURI+`"%`,
 		`%"inReplyTo": "`+parentURI+`"%`) // Handle optional space after colon
 	if err != nil {
-		return err, nil
+		return nil, err
 	}
 	defer rows.Close()
 
@@ -3121,7 +5094,7 @@ func (db *DB) ReadActivitiesByInReplyTo(parentURI string) (error, *[]domain.Acti
 		a.Id, _ = uuid.Parse(idStr)
 		activities = append(activities, a)
 	}
-	return nil, &activities
+	return &activities, nil
 }
 
 // CountActivitiesByInReplyTo counts Create activities that are replies to the given URI
@@ -3129,10 +5102,10 @@ func (db *DB) CountActivitiesByInReplyTo(parentURI string) (int, error) {
 	var count int
 	// Count activities that reply to parentURI, excluding duplicates of local notes
 	// A duplicate is an activity whose object_uri matches a local note (by object_uri or /notes/{uuid} pattern)
-	err := db.db.QueryRow(`
+	err := db.conn().QueryRow(`
 		SELECT COUNT(*)
 		FROM activities a
-		WHERE a.activity_type = 'Create'
+		WHERE a.activity_type = 'Create' AND a.local = 0
 		AND (a.raw_json LIKE ? OR a.raw_json LIKE ?)
 		AND NOT EXISTS (
 			SELECT 1 FROM notes n
@@ -3147,40 +5120,261 @@ func (db *DB) CountActivitiesByInReplyTo(parentURI string) (int, error) {
 	return count, nil
 }
 
+// ========== Conversation Functions ==========
+
+const (
+	// conversationMaxNodes caps the number of posts loaded for a single conversation
+	conversationMaxNodes = 500
+	// conversationMaxDepth caps how far we walk up the ancestor chain and down the reply tree
+	conversationMaxDepth = 32
+)
+
+// ReadConversation returns the post identified by objectURI together with all of its
+// ancestors (via inReplyTo) and all descendant replies, local notes and remote activities
+// alike, assembled into a tree. The tree is bounded by conversationMaxNodes and
+// conversationMaxDepth; Truncated is set when either cap was hit.
+func (db *DB) ReadConversation(objectURI string) (*domain.Conversation, error) {
+	focus := db.readConversationNode(objectURI)
+	if focus == nil {
+		return nil, sql.ErrNoRows
+	}
+
+	conv := &domain.Conversation{Focus: focus}
+	visited := map[string]bool{objectURI: true, focus.ObjectURI: true}
+	nodeCount := 1
+
+	// Walk up the ancestor chain, stopping at the first parent we don't have stored
+	var ancestors []*domain.ConversationNode
+	current := focus
+	for current.InReplyToURI != "" {
+		if visited[current.InReplyToURI] {
+			break
+		}
+		if len(ancestors) >= conversationMaxDepth || nodeCount >= conversationMaxNodes {
+			conv.Truncated = true
+			break
+		}
+		parent := db.readConversationNode(current.InReplyToURI)
+		if parent == nil {
+			conv.MissingParentURI = current.InReplyToURI
+			break
+		}
+		visited[current.InReplyToURI] = true
+		visited[parent.ObjectURI] = true
+		nodeCount++
+		ancestors = append(ancestors, parent)
+		current = parent
+	}
+
+	// Link the chain from the root down to the focus post
+	for i, j := 0, len(ancestors)-1; i < j; i, j = i+1, j-1 {
+		ancestors[i], ancestors[j] = ancestors[j], ancestors[i]
+	}
+	for i, ancestor := range ancestors {
+		ancestor.Depth = i
+		if i > 0 {
+			ancestors[i-1].Children = []*domain.ConversationNode{ancestor}
+		}
+	}
+	if len(ancestors) > 0 {
+		ancestors[len(ancestors)-1].Children = []*domain.ConversationNode{focus}
+		conv.Root = ancestors[0]
+	} else {
+		conv.Root = focus
+	}
+	focus.Depth = len(ancestors)
+	conv.Ancestors = ancestors
+
+	// Breadth-first walk down the reply tree so the caps cut the deepest branches first
+	queue := []*domain.ConversationNode{focus}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+
+		replies := db.readConversationReplies(node, visited)
+		if len(replies) == 0 {
+			continue
+		}
+		if node.Depth-focus.Depth >= conversationMaxDepth {
+			conv.Truncated = true
+			continue
+		}
+		for _, reply := range replies {
+			if nodeCount >= conversationMaxNodes {
+				conv.Truncated = true
+				break
+			}
+			visited[reply.ObjectURI] = true
+			nodeCount++
+			reply.Depth = node.Depth + 1
+			node.Children = append(node.Children, reply)
+			queue = append(queue, reply)
+		}
+	}
+
+	return conv, nil
+}
+
+// readConversationNode resolves a URI to a local note or, failing that, a stored remote activity
+func (db *DB) readConversationNode(uri string) *domain.ConversationNode {
+	var note *domain.Note
+	var err error
+	// Handle local: prefix for local-only mode (e.g., "local:414b193d-0b53-4657-b1bf-eb3a6091d672")
+	if strings.HasPrefix(uri, "local:") {
+		if noteId, parseErr := uuid.Parse(strings.TrimPrefix(uri, "local:")); parseErr == nil {
+			note, err = db.ReadNoteIdWithReplyInfo(noteId)
+		}
+	} else {
+		note, err = db.ReadNoteByURI(uri)
+	}
+	if err == nil && note != nil {
+		return localConversationNode(note, uri)
+	}
+
+	activity, err := db.ReadActivityByObjectURI(uri)
+	if err == nil && activity != nil {
+		return remoteConversationNode(activity)
+	}
+	return nil
+}
+
+// readConversationReplies returns the unvisited direct replies to a node, oldest first
+func (db *DB) readConversationReplies(node *domain.ConversationNode, visited map[string]bool) []*domain.ConversationNode {
+	var replies []*domain.ConversationNode
+
+	// Local replies (ReadRepliesByNoteId also covers local notes without an object_uri)
+	var err error
+	var notes *[]domain.Note
+	if node.Note != nil {
+		notes, err = db.ReadRepliesByNoteId(node.Note.Id)
+	} else {
+		notes, err = db.ReadRepliesByURI(node.ObjectURI)
+	}
+	if err == nil && notes != nil {
+		for i := range *notes {
+			note := &(*notes)[i]
+			reply := localConversationNode(note, "local:"+note.Id.String())
+			if visited[reply.ObjectURI] {
+				continue
+			}
+			replies = append(replies, reply)
+		}
+	}
+
+	// Remote replies, skipping federated copies of local notes
+	activities, err := db.ReadActivitiesByInReplyTo(node.ObjectURI)
+	if err == nil && activities != nil {
+		for i := range *activities {
+			activity := &(*activities)[i]
+			if activity.ObjectURI == "" || visited[activity.ObjectURI] {
+				continue
+			}
+			if existingNote, dupErr := db.ReadNoteByURI(activity.ObjectURI); dupErr == nil && existingNote != nil {
+				continue
+			}
+			replies = append(replies, remoteConversationNode(activity))
+		}
+	}
+
+	sort.SliceStable(replies, func(i, j int) bool {
+		return replies[i].CreatedAt.Before(replies[j].CreatedAt)
+	})
+	return replies
+}
+
+// localConversationNode wraps a local note; fallbackURI is used when the note has no object_uri
+func localConversationNode(note *domain.Note, fallbackURI string) *domain.ConversationNode {
+	objectURI := note.ObjectURI
+	if objectURI == "" {
+		objectURI = fallbackURI
+	}
+	return &domain.ConversationNode{
+		ObjectURI:    objectURI,
+		InReplyToURI: note.InReplyToURI,
+		CreatedAt:    note.CreatedAt,
+		IsLocal:      true,
+		Note:         note,
+	}
+}
+
+// remoteConversationNode wraps a stored remote Create activity
+func remoteConversationNode(activity *domain.Activity) *domain.ConversationNode {
+	return &domain.ConversationNode{
+		ObjectURI:    activity.ObjectURI,
+		InReplyToURI: extractInReplyToFromJSON(activity.RawJSON),
+		CreatedAt:    activity.CreatedAt,
+		IsLocal:      false,
+		Activity:     activity,
+	}
+}
+
 // ========== Relay Functions ==========
 
+const (
+	// Per-relay filter lists, stored comma-separated
+	sqlRelayFilterColumns = `COALESCE(filter_blocked_domains, ''), COALESCE(filter_muted_words, ''), COALESCE(filter_required_tags, '')`
+	sqlUpdateRelayFilter  = `UPDATE relays SET filter_blocked_domains = ?, filter_muted_words = ?, filter_required_tags = ? WHERE id = ?`
+
+	// Quiet hours a relay is paused in
+	sqlRelayScheduleColumns      = `COALESCE(pause_schedule, ''), COALESCE(pause_schedule_state, '')`
+	sqlUpdateRelayPauseSchedule  = `UPDATE relays SET pause_schedule = ? WHERE id = ?`
+	sqlUpdateRelayScheduledPause = `UPDATE relays SET paused = ?, pause_schedule_state = ? WHERE id = ?`
+	sqlUpdateRelayPausedByHand   = `UPDATE relays SET paused = ?, pause_schedule_state = CASE WHEN pause_schedule_state = 'paused' THEN 'skipped' ELSE pause_schedule_state END WHERE id = ?`
+)
+
 // CreateRelay creates a new relay subscription
 func (db *DB) CreateRelay(relay *domain.Relay) error {
 	return db.wrapTransaction(func(tx *sql.Tx) error {
-		_, err := tx.Exec(`INSERT INTO relays(id, actor_uri, inbox_uri, follow_uri, name, status, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		_, err := tx.Exec(`INSERT INTO relays(id, actor_uri, inbox_uri, follow_uri, name, status, created_at, account_id, follow_attempts, last_follow_at, relay_type) VALUES (?, ?, ?, ?, ?, ?, ?, ?, 1, ?, ?)`,
 			relay.Id.String(),
 			relay.ActorURI,
 			relay.InboxURI,
 			relay.FollowURI,
 			relay.Name,
 			relay.Status,
-			relay.CreatedAt.Format(time.RFC3339))
+			formatTimestamp(relay.CreatedAt),
+			relay.AccountId.String(),
+			formatTimestamp(relay.CreatedAt),
+			relay.Type)
 		return err
 	})
 }
 
 // ReadAllRelays returns all relay subscriptions
-func (db *DB) ReadAllRelays() (error, *[]domain.Relay) {
-	rows, err := db.db.Query(`SELECT id, actor_uri, inbox_uri, COALESCE(follow_uri, ''), name, status, COALESCE(paused, 0), created_at, accepted_at FROM relays ORDER BY created_at DESC`)
+func (db *DB) ReadAllRelays() (*[]domain.Relay, error) {
+	return db.readRelaysWithAttempts(`SELECT id, actor_uri, inbox_uri, COALESCE(follow_uri, ''), name, status, COALESCE(paused, 0), created_at, accepted_at,
+		COALESCE(account_id, ''), COALESCE(follow_attempts, 1), COALESCE(last_follow_at, created_at), last_activity_at, COALESCE(activity_count, 0), COALESCE(NULLIF(relay_type, ''), 'mastodon'),
+		` + sqlRelayFilterColumns + `, ` + sqlRelayScheduleColumns + ` FROM relays ORDER BY created_at DESC`)
+}
+
+// ReadPendingRelays returns relay subscriptions still waiting for an Accept, oldest first
+func (db *DB) ReadPendingRelays() (*[]domain.Relay, error) {
+	return db.readRelaysWithAttempts(`SELECT id, actor_uri, inbox_uri, COALESCE(follow_uri, ''), name, status, COALESCE(paused, 0), created_at, accepted_at,
+		COALESCE(account_id, ''), COALESCE(follow_attempts, 1), COALESCE(last_follow_at, created_at), last_activity_at, COALESCE(activity_count, 0), COALESCE(NULLIF(relay_type, ''), 'mastodon'),
+		` + sqlRelayFilterColumns + `, ` + sqlRelayScheduleColumns + ` FROM relays WHERE status = 'pending' ORDER BY created_at ASC`)
+}
+
+// readRelaysWithAttempts scans relays including the Follow retry and activity columns
+func (db *DB) readRelaysWithAttempts(query string) (*[]domain.Relay, error) {
+	rows, err := db.conn().Query(query)
 	if err != nil {
-		return err, nil
+		return nil, err
 	}
 	defer rows.Close()
 
 	var relays []domain.Relay
 	for rows.Next() {
 		var relay domain.Relay
-		var idStr, createdAtStr string
-		var acceptedAtStr sql.NullString
+		var idStr, createdAtStr, accountIdStr, lastFollowAtStr string
+		var acceptedAtStr, lastActivityAtStr sql.NullString
+		var blockedDomains, mutedWords, requiredTags string
 		var paused int
-		if err := rows.Scan(&idStr, &relay.ActorURI, &relay.InboxURI, &relay.FollowURI, &relay.Name, &relay.Status, &paused, &createdAtStr, &acceptedAtStr); err != nil {
-			return err, nil
+		if err := rows.Scan(&idStr, &relay.ActorURI, &relay.InboxURI, &relay.FollowURI, &relay.Name, &relay.Status, &paused, &createdAtStr, &acceptedAtStr,
+			&accountIdStr, &relay.FollowAttempts, &lastFollowAtStr, &lastActivityAtStr, &relay.ActivityCount, &relay.Type,
+			&blockedDomains, &mutedWords, &requiredTags, &relay.PauseSchedule, &relay.PauseScheduleState); err != nil {
+			return nil, err
 		}
+		relay.Filter = makeRelayFilter(blockedDomains, mutedWords, requiredTags)
 		relay.Id, _ = uuid.Parse(idStr)
 		relay.Paused = paused == 1
 		relay.CreatedAt, _ = parseTimestamp(createdAtStr)
@@ -3188,16 +5382,23 @@ func (db *DB) ReadAllRelays() (error, *[]domain.Relay) {
 			t, _ := parseTimestamp(acceptedAtStr.String)
 			relay.AcceptedAt = &t
 		}
+		relay.AccountId, _ = uuid.Parse(accountIdStr)
+		relay.LastFollowAt, _ = parseTimestamp(lastFollowAtStr)
+		if lastActivityAtStr.Valid {
+			t, _ := parseTimestamp(lastActivityAtStr.String)
+			relay.LastActivityAt = &t
+		}
 		relays = append(relays, relay)
 	}
-	return nil, &relays
+	return &relays, nil
 }
 
 // ReadActiveRelays returns all relay subscriptions with status='active'
-func (db *DB) ReadActiveRelays() (error, *[]domain.Relay) {
-	rows, err := db.db.Query(`SELECT id, actor_uri, inbox_uri, COALESCE(follow_uri, ''), name, status, COALESCE(paused, 0), created_at, accepted_at FROM relays WHERE status = 'active'`)
+func (db *DB) ReadActiveRelays() (*[]domain.Relay, error) {
+	rows, err := db.conn().Query(`SELECT id, actor_uri, inbox_uri, COALESCE(follow_uri, ''), name, status, COALESCE(paused, 0), created_at, accepted_at,
+		` + sqlRelayFilterColumns + `, ` + sqlRelayScheduleColumns + ` FROM relays WHERE status = 'active'`)
 	if err != nil {
-		return err, nil
+		return nil, err
 	}
 	defer rows.Close()
 
@@ -3206,10 +5407,13 @@ func (db *DB) ReadActiveRelays() (error, *[]domain.Relay) {
 		var relay domain.Relay
 		var idStr, createdAtStr string
 		var acceptedAtStr sql.NullString
+		var blockedDomains, mutedWords, requiredTags string
 		var paused int
-		if err := rows.Scan(&idStr, &relay.ActorURI, &relay.InboxURI, &relay.FollowURI, &relay.Name, &relay.Status, &paused, &createdAtStr, &acceptedAtStr); err != nil {
-			return err, nil
+		if err := rows.Scan(&idStr, &relay.ActorURI, &relay.InboxURI, &relay.FollowURI, &relay.Name, &relay.Status, &paused, &createdAtStr, &acceptedAtStr,
+			&blockedDomains, &mutedWords, &requiredTags, &relay.PauseSchedule, &relay.PauseScheduleState); err != nil {
+			return nil, err
 		}
+		relay.Filter = makeRelayFilter(blockedDomains, mutedWords, requiredTags)
 		relay.Id, _ = uuid.Parse(idStr)
 		relay.Paused = paused == 1
 		relay.CreatedAt, _ = parseTimestamp(createdAtStr)
@@ -3219,14 +5423,14 @@ func (db *DB) ReadActiveRelays() (error, *[]domain.Relay) {
 		}
 		relays = append(relays, relay)
 	}
-	return nil, &relays
+	return &relays, nil
 }
 
 // ReadActiveUnpausedRelays returns all relay subscriptions with status='active' and paused=0
-func (db *DB) ReadActiveUnpausedRelays() (error, *[]domain.Relay) {
-	rows, err := db.db.Query(`SELECT id, actor_uri, inbox_uri, COALESCE(follow_uri, ''), name, status, COALESCE(paused, 0), created_at, accepted_at FROM relays WHERE status = 'active' AND COALESCE(paused, 0) = 0`)
+func (db *DB) ReadActiveUnpausedRelays() (*[]domain.Relay, error) {
+	rows, err := db.conn().Query(`SELECT id, actor_uri, inbox_uri, COALESCE(follow_uri, ''), name, status, COALESCE(paused, 0), created_at, accepted_at FROM relays WHERE status = 'active' AND COALESCE(paused, 0) = 0`)
 	if err != nil {
-		return err, nil
+		return nil, err
 	}
 	defer rows.Close()
 
@@ -3237,7 +5441,7 @@ func (db *DB) ReadActiveUnpausedRelays() (error, *[]domain.Relay) {
 		var acceptedAtStr sql.NullString
 		var paused int
 		if err := rows.Scan(&idStr, &relay.ActorURI, &relay.InboxURI, &relay.FollowURI, &relay.Name, &relay.Status, &paused, &createdAtStr, &acceptedAtStr); err != nil {
-			return err, nil
+			return nil, err
 		}
 		relay.Id, _ = uuid.Parse(idStr)
 		relay.Paused = paused == 1
@@ -3248,20 +5452,20 @@ func (db *DB) ReadActiveUnpausedRelays() (error, *[]domain.Relay) {
 		}
 		relays = append(relays, relay)
 	}
-	return nil, &relays
+	return &relays, nil
 }
 
 // ReadRelayByActorURI returns a relay by its actor URI
-func (db *DB) ReadRelayByActorURI(actorURI string) (error, *domain.Relay) {
+func (db *DB) ReadRelayByActorURI(actorURI string) (*domain.Relay, error) {
 	var relay domain.Relay
 	var idStr, createdAtStr string
 	var acceptedAtStr, followURI sql.NullString
 	var paused int
 
-	err := db.db.QueryRow(`SELECT id, actor_uri, inbox_uri, follow_uri, name, status, COALESCE(paused, 0), created_at, accepted_at FROM relays WHERE actor_uri = ?`, actorURI).
-		Scan(&idStr, &relay.ActorURI, &relay.InboxURI, &followURI, &relay.Name, &relay.Status, &paused, &createdAtStr, &acceptedAtStr)
+	err := db.conn().QueryRow(`SELECT id, actor_uri, inbox_uri, follow_uri, name, status, COALESCE(paused, 0), created_at, accepted_at, COALESCE(NULLIF(relay_type, ''), 'mastodon') FROM relays WHERE actor_uri = ?`, actorURI).
+		Scan(&idStr, &relay.ActorURI, &relay.InboxURI, &followURI, &relay.Name, &relay.Status, &paused, &createdAtStr, &acceptedAtStr, &relay.Type)
 	if err != nil {
-		return err, nil
+		return nil, err
 	}
 
 	relay.Id, _ = uuid.Parse(idStr)
@@ -3274,20 +5478,20 @@ func (db *DB) ReadRelayByActorURI(actorURI string) (error, *domain.Relay) {
 	if followURI.Valid {
 		relay.FollowURI = followURI.String
 	}
-	return nil, &relay
+	return &relay, nil
 }
 
 // ReadRelayById returns a relay by its ID
-func (db *DB) ReadRelayById(id uuid.UUID) (error, *domain.Relay) {
+func (db *DB) ReadRelayById(id uuid.UUID) (*domain.Relay, error) {
 	var relay domain.Relay
 	var idStr, createdAtStr string
 	var acceptedAtStr, followURI sql.NullString
 	var paused int
 
-	err := db.db.QueryRow(`SELECT id, actor_uri, inbox_uri, follow_uri, name, status, COALESCE(paused, 0), created_at, accepted_at FROM relays WHERE id = ?`, id.String()).
-		Scan(&idStr, &relay.ActorURI, &relay.InboxURI, &followURI, &relay.Name, &relay.Status, &paused, &createdAtStr, &acceptedAtStr)
+	err := db.conn().QueryRow(`SELECT id, actor_uri, inbox_uri, follow_uri, name, status, COALESCE(paused, 0), created_at, accepted_at, COALESCE(NULLIF(relay_type, ''), 'mastodon') FROM relays WHERE id = ?`, id.String()).
+		Scan(&idStr, &relay.ActorURI, &relay.InboxURI, &followURI, &relay.Name, &relay.Status, &paused, &createdAtStr, &acceptedAtStr, &relay.Type)
 	if err != nil {
-		return err, nil
+		return nil, err
 	}
 
 	relay.Id, _ = uuid.Parse(idStr)
@@ -3300,7 +5504,39 @@ func (db *DB) ReadRelayById(id uuid.UUID) (error, *domain.Relay) {
 	if followURI.Valid {
 		relay.FollowURI = followURI.String
 	}
-	return nil, &relay
+	return &relay, nil
+}
+
+// ReadRelayByFollowURI returns the relay whose pending Follow has the given activity URI
+func (db *DB) ReadRelayByFollowURI(followURI string) (*domain.Relay, error) {
+	var relay domain.Relay
+	var idStr, createdAtStr string
+	var acceptedAtStr sql.NullString
+	var paused int
+
+	err := db.conn().QueryRow(`SELECT id, actor_uri, inbox_uri, follow_uri, name, status, COALESCE(paused, 0), created_at, accepted_at, COALESCE(NULLIF(relay_type, ''), 'mastodon') FROM relays WHERE follow_uri = ?`, followURI).
+		Scan(&idStr, &relay.ActorURI, &relay.InboxURI, &relay.FollowURI, &relay.Name, &relay.Status, &paused, &createdAtStr, &acceptedAtStr, &relay.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	relay.Id, _ = uuid.Parse(idStr)
+	relay.Paused = paused == 1
+	relay.CreatedAt, _ = parseTimestamp(createdAtStr)
+	if acceptedAtStr.Valid {
+		t, _ := parseTimestamp(acceptedAtStr.String)
+		relay.AcceptedAt = &t
+	}
+	return &relay, nil
+}
+
+// UpdateRelayFollowAttempt records a re-sent Follow: its new URI, the attempt count and when it was sent
+func (db *DB) UpdateRelayFollowAttempt(id uuid.UUID, followURI string, attempts int, sentAt time.Time) error {
+	return db.wrapTransaction(func(tx *sql.Tx) error {
+		_, err := tx.Exec(`UPDATE relays SET follow_uri = ?, follow_attempts = ?, last_follow_at = ? WHERE id = ?`,
+			followURI, attempts, formatTimestamp(sentAt), id.String())
+		return err
+	})
 }
 
 // UpdateRelayStatus updates a relay's status and optionally sets accepted_at
@@ -3308,7 +5544,7 @@ func (db *DB) UpdateRelayStatus(id uuid.UUID, status string, acceptedAt *time.Ti
 	return db.wrapTransaction(func(tx *sql.Tx) error {
 		if acceptedAt != nil {
 			_, err := tx.Exec(`UPDATE relays SET status = ?, accepted_at = ? WHERE id = ?`,
-				status, acceptedAt.Format(time.RFC3339), id.String())
+				status, formatTimestamp(*acceptedAt), id.String())
 			return err
 		}
 		_, err := tx.Exec(`UPDATE relays SET status = ? WHERE id = ?`, status, id.String())
@@ -3324,18 +5560,112 @@ func (db *DB) DeleteRelay(id uuid.UUID) error {
 	})
 }
 
-// UpdateRelayPaused updates a relay's paused status
+// UpdateRelayPaused updates a relay's paused status. A pause by the relay's schedule is
+// overridden: the schedule leaves the relay alone until its current window ends.
 func (db *DB) UpdateRelayPaused(id uuid.UUID, paused bool) error {
 	return db.wrapTransaction(func(tx *sql.Tx) error {
 		pausedInt := 0
 		if paused {
 			pausedInt = 1
 		}
-		_, err := tx.Exec(`UPDATE relays SET paused = ? WHERE id = ?`, pausedInt, id.String())
+		_, err := tx.Exec(sqlUpdateRelayPausedByHand, pausedInt, id.String())
+		return err
+	})
+}
+
+// UpdateRelayPauseSchedule stores the daily quiet hours a relay is paused in; empty removes them
+func (db *DB) UpdateRelayPauseSchedule(id uuid.UUID, schedule string) error {
+	return db.wrapTransaction(func(tx *sql.Tx) error {
+		_, err := tx.Exec(sqlUpdateRelayPauseSchedule, schedule, id.String())
+		return err
+	})
+}
+
+// UpdateRelayScheduledPause pauses or resumes a relay on behalf of its schedule and records
+// what the schedule did in the current window
+func (db *DB) UpdateRelayScheduledPause(id uuid.UUID, paused bool, state string) error {
+	return db.wrapTransaction(func(tx *sql.Tx) error {
+		pausedInt := 0
+		if paused {
+			pausedInt = 1
+		}
+		_, err := tx.Exec(sqlUpdateRelayScheduledPause, pausedInt, state, id.String())
+		return err
+	})
+}
+
+// UpdateRelayFilter stores the rules a relay's forwarded posts must pass; entries are normalized
+func (db *DB) UpdateRelayFilter(id uuid.UUID, filter domain.RelayFilter) error {
+	filter = filter.Normalized()
+	return db.wrapTransaction(func(tx *sql.Tx) error {
+		_, err := tx.Exec(sqlUpdateRelayFilter,
+			strings.Join(filter.BlockedDomains, ","),
+			strings.Join(filter.MutedWords, ","),
+			strings.Join(filter.RequiredTags, ","),
+			id.String())
+		return err
+	})
+}
+
+// makeRelayFilter builds a relay filter from its comma-separated columns
+func makeRelayFilter(blockedDomains, mutedWords, requiredTags string) domain.RelayFilter {
+	return domain.RelayFilter{
+		BlockedDomains: splitFilterColumn(blockedDomains),
+		MutedWords:     splitFilterColumn(mutedWords),
+		RequiredTags:   splitFilterColumn(requiredTags),
+	}
+}
+
+// splitFilterColumn splits a comma-separated filter column, returning nil when it is empty
+func splitFilterColumn(column string) []string {
+	if column == "" {
+		return nil
+	}
+	return strings.Split(column, ",")
+}
+
+// RecordRelayActivity notes that a relay forwarded an activity at the given time
+func (db *DB) RecordRelayActivity(id uuid.UUID, at time.Time) error {
+	return db.wrapTransaction(func(tx *sql.Tx) error {
+		_, err := tx.Exec(`UPDATE relays SET last_activity_at = ?, activity_count = COALESCE(activity_count, 0) + 1 WHERE id = ?`,
+			formatTimestamp(at), id.String())
 		return err
 	})
 }
 
+// ReadRelayHealth returns each relay's last activity and a rough inbound rate
+func (db *DB) ReadRelayHealth() (*[]domain.RelayHealth, error) {
+	relays, err := db.ReadAllRelays()
+	if err != nil {
+		return nil, err
+	}
+
+	health := make([]domain.RelayHealth, 0, len(*relays))
+	for _, relay := range *relays {
+		h := domain.RelayHealth{
+			RelayId:        relay.Id,
+			ActorURI:       relay.ActorURI,
+			Name:           relay.Name,
+			Status:         relay.Status,
+			Paused:         relay.Paused,
+			Since:          relay.CreatedAt,
+			LastActivityAt: relay.LastActivityAt,
+			ActivityCount:  relay.ActivityCount,
+		}
+		if relay.AcceptedAt != nil {
+			h.Since = *relay.AcceptedAt
+		}
+		// Average over at least an hour so a freshly accepted relay doesn't report a huge rate
+		hours := time.Since(h.Since).Hours()
+		if hours < 1 {
+			hours = 1
+		}
+		h.PerHour = float64(h.ActivityCount) / hours
+		health = append(health, h)
+	}
+	return &health, nil
+}
+
 // DeleteRelayActivities deletes all activities that were forwarded by relays (from_relay=1)
 func (db *DB) DeleteRelayActivities() (int64, error) {
 	var count int64
@@ -3357,25 +5687,35 @@ func (db *DB) DeleteRelayActivities() (int64, error) {
 const (
 	sqlInsertNotification = `INSERT INTO notifications(id, account_id, notification_type, actor_id, actor_username, actor_domain, note_id, note_uri, note_preview, read, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
+	// Notifications from remote accounts the recipient muted with hide_notifications are left out
+	sqlNotificationActorNotMuted = ` AND NOT EXISTS (SELECT 1 FROM muted_accounts m INNER JOIN remote_accounts ra ON ra.actor_uri = m.target_actor_uri
+		WHERE m.account_id = notifications.account_id AND ra.id = notifications.actor_id AND m.hide_notifications = 1
+		AND (m.expires_at IS NULL OR m.expires_at > datetime('now')))`
+
 	sqlSelectNotificationsByAccountId = `SELECT id, account_id, notification_type, actor_id, actor_username, actor_domain, note_id, note_uri, note_preview, read, created_at
 		FROM notifications
-		WHERE account_id = ?
+		WHERE account_id = ?` + sqlNotificationActorNotMuted + `
 		ORDER BY created_at DESC
 		LIMIT ?`
 
-	sqlSelectUnreadCountByAccountId = `SELECT COUNT(*) FROM notifications WHERE account_id = ? AND read = 0`
+	sqlSelectUnreadCountByAccountId = `SELECT COUNT(*) FROM notifications WHERE account_id = ? AND read = 0` + sqlNotificationActorNotMuted
+	sqlCountUnreadNotifications     = `SELECT COUNT(*) FROM notifications WHERE read = 0`
 
 	sqlMarkNotificationRead = `UPDATE notifications SET read = 1 WHERE id = ?`
 
 	sqlMarkAllNotificationsRead = `UPDATE notifications SET read = 1 WHERE account_id = ?`
 
+	sqlMarkNotificationsReadUpTo = `UPDATE notifications SET read = 1 WHERE account_id = ? AND read = 0 AND created_at <= ?`
+
 	sqlDeleteNotification     = `DELETE FROM notifications WHERE id = ?`
 	sqlDeleteAllNotifications = `DELETE FROM notifications WHERE account_id = ?`
 )
 
-// CreateNotification creates a new notification
+// CreateNotification creates a new notification and, once it is committed, publishes the
+// account's new unread count to its sessions
 func (db *DB) CreateNotification(notification *domain.Notification) error {
-	return db.wrapTransaction(func(tx *sql.Tx) error {
+	var unreadCount int
+	err := db.wrapTransaction(func(tx *sql.Tx) error {
 		readInt := 0
 		if notification.Read {
 			readInt = 1
@@ -3414,16 +5754,27 @@ func (db *DB) CreateNotification(notification *domain.Notification) error {
 			noteURI,
 			notePreview,
 			readInt,
-			notification.CreatedAt.Format(time.RFC3339))
+			formatTimestamp(notification.CreatedAt))
+		if err != nil {
+			return err
+		}
+		return tx.QueryRow(sqlSelectUnreadCountByAccountId, notification.AccountId.String()).Scan(&unreadCount)
+	})
+	if err != nil {
 		return err
+	}
+
+	db.onCommit(func() {
+		util.GetEventBus().PublishNotification(notification.AccountId, unreadCount)
 	})
+	return nil
 }
 
 // ReadNotificationsByAccountId retrieves notifications for an account
-func (db *DB) ReadNotificationsByAccountId(accountId uuid.UUID, limit int) (error, *[]domain.Notification) {
-	rows, err := db.db.Query(sqlSelectNotificationsByAccountId, accountId.String(), limit)
+func (db *DB) ReadNotificationsByAccountId(accountId uuid.UUID, limit int) (*[]domain.Notification, error) {
+	rows, err := db.conn().Query(sqlSelectNotificationsByAccountId, accountId.String(), limit)
 	if err != nil {
-		return err, nil
+		return nil, err
 	}
 	defer rows.Close()
 
@@ -3438,7 +5789,7 @@ func (db *DB) ReadNotificationsByAccountId(accountId uuid.UUID, limit int) (erro
 		if err := rows.Scan(&idStr, &accountIdStr, &notificationTypeStr, &actorIdStr,
 			&actorUsername, &actorDomain, &noteIdStr, &noteURI, &notePreview,
 			&readInt, &createdAtStr); err != nil {
-			return err, &notifications
+			return &notifications, err
 		}
 
 		// Parse UUIDs
@@ -3457,7 +5808,7 @@ func (db *DB) ReadNotificationsByAccountId(accountId uuid.UUID, limit int) (erro
 		n.Read = readInt == 1
 
 		// Parse timestamp
-		createdAt, err := time.Parse(time.RFC3339, createdAtStr)
+		createdAt, err := parseTimestamp(createdAtStr)
 		if err != nil {
 			log.Printf("Failed to parse notification created_at: %v", err)
 			createdAt = time.Now()
@@ -3468,16 +5819,98 @@ func (db *DB) ReadNotificationsByAccountId(accountId uuid.UUID, limit int) (erro
 	}
 
 	if err = rows.Err(); err != nil {
-		return err, &notifications
+		return &notifications, err
+	}
+
+	return &notifications, nil
+}
+
+// Notification grouping settings
+const (
+	notificationGroupWindow      = 24 * time.Hour // Max age difference between newest and oldest notification in a group
+	notificationGroupMaxActors   = 3              // Number of recent actor handles kept per group
+	notificationGroupFetchFactor = 10             // Raw rows fetched per requested group
+)
+
+// ReadGroupedNotifications retrieves notifications for an account with repeated
+// like/boost notifications for the same note collapsed into a single group.
+// Other notification types are returned as single-entry groups.
+func (db *DB) ReadGroupedNotifications(accountId uuid.UUID, limit int) (*[]domain.NotificationGroup, error) {
+	notifications, err := db.ReadNotificationsByAccountId(accountId, limit*notificationGroupFetchFactor)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := groupNotifications(*notifications, notificationGroupWindow, notificationGroupMaxActors)
+	if len(groups) > limit {
+		groups = groups[:limit]
+	}
+	return &groups, nil
+}
+
+// groupNotifications collapses like/boost notifications keyed on (type, note) within
+// the given time window. Input must be ordered newest first; output keeps that order.
+func groupNotifications(notifications []domain.Notification, window time.Duration, maxActors int) []domain.NotificationGroup {
+	var groups []domain.NotificationGroup
+	openGroups := make(map[string]int) // group key -> index into groups
+
+	for _, n := range notifications {
+		groupable := n.NotificationType == domain.NotificationLike || n.NotificationType == domain.NotificationBoost
+		noteKey := n.NoteURI
+		if n.NoteId != uuid.Nil {
+			noteKey = n.NoteId.String()
+		}
+
+		if groupable && noteKey != "" {
+			key := string(n.NotificationType) + "|" + noteKey
+			if idx, ok := openGroups[key]; ok && groups[idx].Latest.CreatedAt.Sub(n.CreatedAt) <= window {
+				group := &groups[idx]
+				group.Count++
+				group.Notifications = append(group.Notifications, n)
+				handle := n.ActorHandle()
+				if len(group.Actors) < maxActors && !containsString(group.Actors, handle) {
+					group.Actors = append(group.Actors, handle)
+				}
+				continue
+			}
+			openGroups[key] = len(groups)
+		}
+
+		groups = append(groups, domain.NotificationGroup{
+			Latest:        n,
+			Count:         1,
+			Actors:        []string{n.ActorHandle()},
+			Notifications: []domain.Notification{n},
+		})
 	}
 
-	return nil, &notifications
+	return groups
+}
+
+// containsString reports whether the slice contains the given string
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
 }
 
 // ReadUnreadNotificationCount returns the count of unread notifications for an account
 func (db *DB) ReadUnreadNotificationCount(accountId uuid.UUID) (int, error) {
 	var count int
-	err := db.db.QueryRow(sqlSelectUnreadCountByAccountId, accountId.String()).Scan(&count)
+	err := db.conn().QueryRow(sqlSelectUnreadCountByAccountId, accountId.String()).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// CountUnreadNotifications returns the number of unread notifications across all accounts
+func (db *DB) CountUnreadNotifications() (int, error) {
+	var count int
+	err := db.conn().QueryRow(sqlCountUnreadNotifications).Scan(&count)
 	if err != nil {
 		return 0, err
 	}
@@ -3500,6 +5933,40 @@ func (db *DB) MarkAllNotificationsRead(accountId uuid.UUID) error {
 	})
 }
 
+// MarkNotificationsReadUpTo marks all notifications created at or before upTo as read
+// and returns the remaining unread count. Both steps run in one transaction so that
+// notifications written concurrently by the inbox are neither marked read unseen nor
+// missing from the returned count.
+func (db *DB) MarkNotificationsReadUpTo(accountId uuid.UUID, upTo time.Time) (int, error) {
+	var unread int
+	err := db.wrapTransaction(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(sqlMarkNotificationsReadUpTo, accountId.String(), formatTimestamp(upTo)); err != nil {
+			return err
+		}
+		return tx.QueryRow(sqlSelectUnreadCountByAccountId, accountId.String()).Scan(&unread)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return unread, nil
+}
+
+// MarkNotificationReadForAccount marks a single notification owned by the account as read
+// and returns the remaining unread count from the same transaction
+func (db *DB) MarkNotificationReadForAccount(accountId, notificationId uuid.UUID) (int, error) {
+	var unread int
+	err := db.wrapTransaction(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`UPDATE notifications SET read = 1 WHERE id = ? AND account_id = ?`, notificationId.String(), accountId.String()); err != nil {
+			return err
+		}
+		return tx.QueryRow(sqlSelectUnreadCountByAccountId, accountId.String()).Scan(&unread)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return unread, nil
+}
+
 // DeleteNotification deletes a notification
 func (db *DB) DeleteNotification(notificationId uuid.UUID) error {
 	return db.wrapTransaction(func(tx *sql.Tx) error {
@@ -3515,3 +5982,103 @@ func (db *DB) DeleteAllNotifications(accountId uuid.UUID) error {
 		return err
 	})
 }
+
+// ========== Muted Account Functions ==========
+
+const (
+	sqlUpsertMutedAccount = `INSERT INTO muted_accounts(id, account_id, target_actor_uri, hide_notifications, expires_at, created_at) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(account_id, target_actor_uri) DO UPDATE SET hide_notifications = excluded.hide_notifications, expires_at = excluded.expires_at`
+	sqlSelectMutedAccountsByAccountId = `SELECT id, account_id, target_actor_uri, COALESCE(hide_notifications, 1), expires_at, created_at FROM muted_accounts
+		WHERE account_id = ? AND (expires_at IS NULL OR expires_at > datetime('now'))
+		ORDER BY created_at DESC`
+	sqlSelectMutedAccount = `SELECT id, account_id, target_actor_uri, COALESCE(hide_notifications, 1), expires_at, created_at FROM muted_accounts
+		WHERE account_id = ? AND target_actor_uri = ? AND (expires_at IS NULL OR expires_at > datetime('now'))`
+	sqlDeleteMutedAccount         = `DELETE FROM muted_accounts WHERE account_id = ? AND target_actor_uri = ?`
+	sqlDeleteExpiredMutedAccounts = `DELETE FROM muted_accounts WHERE expires_at IS NOT NULL AND expires_at <= ?`
+)
+
+// CreateMutedAccount mutes a remote account for a local account. Muting an account again
+// replaces the earlier mute's settings and expiry.
+func (db *DB) CreateMutedAccount(mute *domain.MutedAccount) error {
+	hideNotifications := 0
+	if mute.HideNotifications {
+		hideNotifications = 1
+	}
+	var expiresAt any
+	if mute.ExpiresAt != nil {
+		expiresAt = formatTimestamp(*mute.ExpiresAt)
+	}
+	return db.wrapTransaction(func(tx *sql.Tx) error {
+		_, err := tx.Exec(sqlUpsertMutedAccount, mute.Id.String(), mute.AccountId.String(), mute.TargetActorURI,
+			hideNotifications, expiresAt, formatTimestamp(mute.CreatedAt))
+		return err
+	})
+}
+
+// ReadMutedAccountsByAccountId returns the active mutes of an account, newest first
+func (db *DB) ReadMutedAccountsByAccountId(accountId uuid.UUID) (*[]domain.MutedAccount, error) {
+	rows, err := db.conn().Query(sqlSelectMutedAccountsByAccountId, accountId.String())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var mutes []domain.MutedAccount
+	for rows.Next() {
+		mute, err := scanMutedAccount(rows)
+		if err != nil {
+			return &mutes, err
+		}
+		mutes = append(mutes, *mute)
+	}
+	if err = rows.Err(); err != nil {
+		return &mutes, err
+	}
+	return &mutes, nil
+}
+
+// ReadMutedAccount returns an account's active mute of a remote actor, or sql.ErrNoRows
+func (db *DB) ReadMutedAccount(accountId uuid.UUID, targetActorURI string) (*domain.MutedAccount, error) {
+	return scanMutedAccount(db.conn().QueryRow(sqlSelectMutedAccount, accountId.String(), targetActorURI))
+}
+
+// DeleteMutedAccount unmutes a remote account
+func (db *DB) DeleteMutedAccount(accountId uuid.UUID, targetActorURI string) error {
+	return db.wrapTransaction(func(tx *sql.Tx) error {
+		_, err := tx.Exec(sqlDeleteMutedAccount, accountId.String(), targetActorURI)
+		return err
+	})
+}
+
+// DeleteExpiredMutedAccounts removes timed mutes that ended before the given time and
+// returns how many were removed
+func (db *DB) DeleteExpiredMutedAccounts(before time.Time) (int64, error) {
+	var deleted int64
+	err := db.wrapTransaction(func(tx *sql.Tx) error {
+		result, err := tx.Exec(sqlDeleteExpiredMutedAccounts, formatTimestamp(before))
+		if err != nil {
+			return err
+		}
+		deleted, err = result.RowsAffected()
+		return err
+	})
+	return deleted, err
+}
+
+// scanMutedAccount scans a muted_accounts row
+func scanMutedAccount(row interface{ Scan(...any) error }) (*domain.MutedAccount, error) {
+	var mute domain.MutedAccount
+	var idStr, accountIdStr string
+	var hideNotifications int
+	var expiresAt sql.NullTime
+	if err := row.Scan(&idStr, &accountIdStr, &mute.TargetActorURI, &hideNotifications, &expiresAt, &mute.CreatedAt); err != nil {
+		return nil, err
+	}
+	mute.Id, _ = uuid.Parse(idStr)
+	mute.AccountId, _ = uuid.Parse(accountIdStr)
+	mute.HideNotifications = hideNotifications == 1
+	if expiresAt.Valid {
+		mute.ExpiresAt = &expiresAt.Time
+	}
+	return &mute, nil
+}
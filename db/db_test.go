@@ -2,23 +2,34 @@ package db
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/deemkeen/stegodon/domain"
+	"github.com/deemkeen/stegodon/util"
 	"github.com/google/uuid"
 	_ "modernc.org/sqlite"
 )
 
 // setupTestDB creates an in-memory SQLite database for testing
-func setupTestDB(t *testing.T) *DB {
+func setupTestDB(t testing.TB) *DB {
 	sqlDB, err := sql.Open("sqlite", ":memory:")
 	if err != nil {
 		t.Fatalf("Failed to open in-memory database: %v", err)
 	}
 
-	db := &DB{db: sqlDB}
+	// Local notes get their object URIs from the configured domain
+	conf := &util.AppConfig{}
+	conf.Conf.SslDomain = "example.com"
+	db := &DB{db: sqlDB, conf: conf}
 
 	// Create tables
 	if _, err := db.db.Exec(sqlCreateUserTable); err != nil {
@@ -42,6 +53,10 @@ func setupTestDB(t *testing.T) *DB {
 	db.db.Exec(`ALTER TABLE notes ADD COLUMN reply_count INTEGER DEFAULT 0`)
 	db.db.Exec(`ALTER TABLE notes ADD COLUMN like_count INTEGER DEFAULT 0`)
 	db.db.Exec(`ALTER TABLE notes ADD COLUMN boost_count INTEGER DEFAULT 0`)
+	db.db.Exec(`ALTER TABLE notes ADD COLUMN language TEXT`)
+	db.db.Exec(`ALTER TABLE notes ADD COLUMN deleted_at TIMESTAMP`)
+	db.db.Exec(`ALTER TABLE notes ADD COLUMN thread_root_uri TEXT`)
+	db.db.Exec(`ALTER TABLE notes ADD COLUMN object_json TEXT`)
 
 	// Add ActivityPub profile fields to accounts table
 	db.db.Exec(`ALTER TABLE accounts ADD COLUMN display_name varchar(255)`)
@@ -52,6 +67,16 @@ func setupTestDB(t *testing.T) *DB {
 	db.db.Exec(`ALTER TABLE accounts ADD COLUMN is_admin INTEGER DEFAULT 0`)
 	db.db.Exec(`ALTER TABLE accounts ADD COLUMN muted INTEGER DEFAULT 0`)
 
+	// Add language preferences to accounts table
+	db.db.Exec(`ALTER TABLE accounts ADD COLUMN default_language TEXT`)
+	db.db.Exec(`ALTER TABLE accounts ADD COLUMN filter_languages TEXT`)
+	db.db.Exec(`ALTER TABLE accounts ADD COLUMN manually_approves_followers INTEGER DEFAULT 0`)
+	db.db.Exec(`ALTER TABLE accounts ADD COLUMN pending INTEGER DEFAULT 0`)
+	db.db.Exec(`ALTER TABLE accounts ADD COLUMN disabled INTEGER DEFAULT 0`)
+	db.db.Exec(`ALTER TABLE accounts ADD COLUMN show_replies_in_home INTEGER DEFAULT 0`)
+	db.db.Exec(`ALTER TABLE accounts ADD COLUMN discoverable INTEGER`)
+	db.db.Exec(`ALTER TABLE accounts ADD COLUMN timezone TEXT`)
+
 	// Create ActivityPub tables
 	db.db.Exec(`CREATE TABLE IF NOT EXISTS remote_accounts(
 		id uuid NOT NULL PRIMARY KEY,
@@ -65,6 +90,8 @@ func setupTestDB(t *testing.T) *DB {
 		public_key_pem text,
 		avatar_url varchar(500),
 		last_fetched_at timestamp default current_timestamp,
+		shared_inbox_uri TEXT,
+		public_keys TEXT,
 		UNIQUE(username, domain)
 	)`)
 
@@ -76,6 +103,7 @@ func setupTestDB(t *testing.T) *DB {
 		created_at timestamp default current_timestamp,
 		accepted int default 0,
 		is_local int default 0,
+		notify int default 0,
 		UNIQUE(account_id, target_account_id)
 	)`)
 
@@ -92,7 +120,16 @@ func setupTestDB(t *testing.T) *DB {
 		from_relay int default 0,
 		reply_count INTEGER DEFAULT 0,
 		like_count INTEGER DEFAULT 0,
-		boost_count INTEGER DEFAULT 0
+		boost_count INTEGER DEFAULT 0,
+		language TEXT,
+		quote_uri TEXT,
+		quote_author TEXT,
+		quote_content TEXT,
+		thread_root_uri TEXT,
+		content_html TEXT,
+		content_text TEXT,
+		title TEXT,
+		restricted INTEGER DEFAULT 0
 	)`)
 
 	db.db.Exec(`CREATE TABLE IF NOT EXISTS likes(
@@ -105,6 +142,8 @@ func setupTestDB(t *testing.T) *DB {
 		UNIQUE(account_id, note_id)
 	)`)
 
+	db.db.Exec(sqlCreateBoostsTable)
+
 	db.db.Exec(`CREATE TABLE IF NOT EXISTS delivery_queue(
 		id uuid NOT NULL PRIMARY KEY,
 		inbox_uri varchar(500) NOT NULL,
@@ -112,7 +151,11 @@ func setupTestDB(t *testing.T) *DB {
 		attempts int default 0,
 		next_retry_at timestamp default current_timestamp,
 		created_at timestamp default current_timestamp,
-		account_id TEXT
+		account_id TEXT,
+		last_status INTEGER DEFAULT 0,
+		dead_lettered INTEGER DEFAULT 0,
+		object_uri TEXT,
+		seq INTEGER DEFAULT 0
 	)`)
 
 	// Create hashtag tables
@@ -129,6 +172,14 @@ func setupTestDB(t *testing.T) *DB {
 		PRIMARY KEY (note_id, hashtag_id)
 	)`)
 
+	db.db.Exec(sqlCreateNoteMentionsTable)
+
+	db.db.Exec(`CREATE TABLE IF NOT EXISTS activity_hashtags (
+		activity_id TEXT NOT NULL,
+		hashtag_id INTEGER NOT NULL,
+		PRIMARY KEY (activity_id, hashtag_id)
+	)`)
+
 	// Create relays table
 	db.db.Exec(`CREATE TABLE IF NOT EXISTS relays (
 		id TEXT NOT NULL PRIMARY KEY,
@@ -139,20 +190,166 @@ func setupTestDB(t *testing.T) *DB {
 		status TEXT DEFAULT 'pending',
 		paused INTEGER DEFAULT 0,
 		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		accepted_at TIMESTAMP
+		accepted_at TIMESTAMP,
+		account_id TEXT,
+		follow_attempts INTEGER DEFAULT 1,
+		last_follow_at TIMESTAMP,
+		last_activity_at TIMESTAMP,
+		activity_count INTEGER DEFAULT 0,
+		relay_type TEXT DEFAULT 'mastodon',
+		filter_blocked_domains TEXT,
+		filter_muted_words TEXT,
+		filter_required_tags TEXT,
+		pause_schedule TEXT,
+		pause_schedule_state TEXT
 	)`)
 
+	// Create notifications table
+	db.db.Exec(sqlCreateNotificationsTable)
+
+	// Create API tokens table
+	db.db.Exec(sqlCreateAPITokensTable)
+
+	// Create muted accounts table
+	db.db.Exec(sqlCreateMutedAccountsTable)
+	db.db.Exec(sqlCreateInboxQueueTable)
+	db.db.Exec(sqlCreateAccountKeysTable)
+
+	// Create reactions table
+	db.db.Exec(sqlCreateReactionsTable)
+
 	return db
 }
 
 // createTestAccount is a helper to create accounts directly via SQL
-func createTestAccount(t *testing.T, db *DB, id uuid.UUID, username, pubkey, webPubKey, webPrivKey string) {
+func createTestAccount(t testing.TB, db *DB, id uuid.UUID, username, pubkey, webPubKey, webPrivKey string) {
 	_, err := db.db.Exec(sqlInsertUser, id, username, pubkey, webPubKey, webPrivKey, time.Now())
 	if err != nil {
 		t.Fatalf("Failed to create test account: %v", err)
 	}
 }
 
+func TestAPITokens(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	accountId := uuid.New()
+	createTestAccount(t, db, accountId, "apiuser", "ssh-rsa AAAAB3...", "webpub", "webpriv")
+
+	// Unknown hashes are not valid
+	if _, err := db.ReadAPITokenByHash("hash1"); err != sql.ErrNoRows {
+		t.Errorf("Expected sql.ErrNoRows for an unknown token, got %v", err)
+	}
+
+	readToken := &domain.APIToken{Id: uuid.New(), AccountId: accountId, TokenHash: "hash1", Scopes: []string{domain.ScopeRead}, CreatedAt: time.Now().Add(-time.Minute)}
+	fullToken := &domain.APIToken{Id: uuid.New(), AccountId: accountId, TokenHash: "hash2", Scopes: []string{domain.ScopeRead, domain.ScopeWrite, domain.ScopeFollow}, CreatedAt: time.Now()}
+	for _, token := range []*domain.APIToken{readToken, fullToken} {
+		if err := db.CreateAPIToken(token); err != nil {
+			t.Fatalf("CreateAPIToken failed: %v", err)
+		}
+	}
+
+	token, err := db.ReadAPITokenByHash("hash1")
+	if err != nil {
+		t.Fatalf("ReadAPITokenByHash failed: %v", err)
+	}
+	if token.Id != readToken.Id || token.AccountId != accountId {
+		t.Errorf("Expected token %s of account %s, got %s of %s", readToken.Id, accountId, token.Id, token.AccountId)
+	}
+	if !token.HasScope(domain.ScopeRead) || token.HasScope(domain.ScopeWrite) {
+		t.Errorf("Expected read-only scopes, got %v", token.Scopes)
+	}
+
+	tokens, err := db.ReadAPITokensByAccountId(accountId)
+	if err != nil {
+		t.Fatalf("ReadAPITokensByAccountId failed: %v", err)
+	}
+	if len(*tokens) != 2 || (*tokens)[0].Id != fullToken.Id {
+		t.Fatalf("Expected 2 tokens, newest first, got %+v", *tokens)
+	}
+	if len((*tokens)[0].Scopes) != 3 {
+		t.Errorf("Expected 3 scopes, got %v", (*tokens)[0].Scopes)
+	}
+
+	// Revoking only works for the owner
+	if err := db.DeleteAPIToken(uuid.New(), readToken.Id); err != nil {
+		t.Fatalf("DeleteAPIToken failed: %v", err)
+	}
+	if _, err := db.ReadAPITokenByHash("hash1"); err != nil {
+		t.Errorf("Expected token to survive revocation by another account, got %v", err)
+	}
+	if err := db.DeleteAPIToken(accountId, readToken.Id); err != nil {
+		t.Fatalf("DeleteAPIToken failed: %v", err)
+	}
+	if _, err := db.ReadAPITokenByHash("hash1"); err != sql.ErrNoRows {
+		t.Errorf("Expected revoked token to be invalid, got %v", err)
+	}
+
+	if err := db.DeleteAPITokensByAccountId(accountId); err != nil {
+		t.Fatalf("DeleteAPITokensByAccountId failed: %v", err)
+	}
+	if tokens, err := db.ReadAPITokensByAccountId(accountId); err != nil || len(*tokens) != 0 {
+		t.Errorf("Expected no tokens left, got %v (err %v)", tokens, err)
+	}
+}
+
+func TestAccountKeys(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	aliceId, bobId := uuid.New(), uuid.New()
+	createTestAccount(t, db, aliceId, "alice", "alicehash", "webpub", "webpriv")
+	createTestAccount(t, db, bobId, "bob", "bobhash", "webpub", "webpriv")
+
+	key, err := db.AddKey(aliceId, "laptophash", "laptop")
+	if err != nil {
+		t.Fatalf("AddKey failed: %v", err)
+	}
+
+	// Both keys log in to alice
+	for _, pkHash := range []string{"alicehash", "laptophash"} {
+		acc, err := db.ReadAccByPkHash(pkHash)
+		if err != nil || acc.Id != aliceId {
+			t.Errorf("Expected %s to log in to alice, got %v (%v)", pkHash, acc, err)
+		}
+	}
+
+	// A key can only belong to one account
+	if _, err := db.AddKey(bobId, "laptophash", "stolen"); err != ErrKeyInUse {
+		t.Errorf("Expected ErrKeyInUse for an added key, got %v", err)
+	}
+	if _, err := db.AddKey(aliceId, "bobhash", "bob's"); err != ErrKeyInUse {
+		t.Errorf("Expected ErrKeyInUse for another account's primary key, got %v", err)
+	}
+
+	keys, err := db.ListKeys(aliceId)
+	if err != nil {
+		t.Fatalf("ListKeys failed: %v", err)
+	}
+	if len(keys) != 2 || !keys[0].Primary || keys[0].PkHash != "alicehash" || keys[1].Id != key.Id || keys[1].Label != "laptop" {
+		t.Fatalf("Expected the primary key and the laptop key, got %+v", keys)
+	}
+
+	if err := db.RemoveKey(aliceId, uuid.Nil); err != ErrPrimaryKey {
+		t.Errorf("Expected ErrPrimaryKey, got %v", err)
+	}
+	// Keys of other accounts can't be removed
+	db.RemoveKey(bobId, key.Id)
+	if _, err := db.ReadAccByPkHash("laptophash"); err != nil {
+		t.Errorf("Expected bob not to remove alice's key, got %v", err)
+	}
+
+	if err := db.RemoveKey(aliceId, key.Id); err != nil {
+		t.Fatalf("RemoveKey failed: %v", err)
+	}
+	if _, err := db.ReadAccByPkHash("laptophash"); err != sql.ErrNoRows {
+		t.Errorf("Expected the removed key not to log in, got %v", err)
+	}
+	if keys, _ := db.ListKeys(aliceId); len(keys) != 1 {
+		t.Errorf("Expected only the primary key to be left, got %d", len(keys))
+	}
+}
+
 func TestReadAccById(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.db.Close()
@@ -164,7 +361,7 @@ func TestReadAccById(t *testing.T) {
 	createTestAccount(t, db, id, username, pubkey, "webpub", "webpriv")
 
 	// Read account
-	err, acc := db.ReadAccById(id)
+	acc, err := db.ReadAccById(id)
 	if err != nil {
 		t.Fatalf("ReadAccById failed: %v", err)
 	}
@@ -186,7 +383,7 @@ func TestReadAccByIdNotFound(t *testing.T) {
 
 	// Test non-existent account
 	randomId := uuid.New()
-	err, acc := db.ReadAccById(randomId)
+	acc, err := db.ReadAccById(randomId)
 	if err == nil {
 		t.Error("Expected error for non-existent account")
 	}
@@ -205,7 +402,7 @@ func TestReadAccByUsername(t *testing.T) {
 	createTestAccount(t, db, id, username, "pubkey", "webpub", "webpriv")
 
 	// Read by username
-	err, acc := db.ReadAccByUsername(username)
+	acc, err := db.ReadAccByUsername(username)
 	if err != nil {
 		t.Fatalf("ReadAccByUsername failed: %v", err)
 	}
@@ -222,7 +419,7 @@ func TestReadAccByUsernameNotFound(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.db.Close()
 
-	err, acc := db.ReadAccByUsername("nonexistent")
+	acc, err := db.ReadAccByUsername("nonexistent")
 	if err == nil {
 		t.Error("Expected error for non-existent username")
 	}
@@ -249,7 +446,7 @@ func TestUpdateLoginById(t *testing.T) {
 	}
 
 	// Verify update
-	err, acc := db.ReadAccById(id)
+	acc, err := db.ReadAccById(id)
 	if err != nil {
 		t.Fatalf("ReadAccById failed: %v", err)
 	}
@@ -282,7 +479,7 @@ func TestCreateNote(t *testing.T) {
 	}
 
 	// Verify note exists
-	err, note := db.ReadNoteId(noteId)
+	note, err := db.ReadNoteId(noteId)
 	if err != nil {
 		t.Fatalf("ReadNoteId failed: %v", err)
 	}
@@ -301,7 +498,7 @@ func TestReadNoteIdNotFound(t *testing.T) {
 
 	// Test non-existent note
 	randomId := uuid.New()
-	err, note := db.ReadNoteId(randomId)
+	note, err := db.ReadNoteId(randomId)
 	if err == nil {
 		t.Error("Expected error for non-existent note")
 	}
@@ -325,7 +522,7 @@ func TestReadNoteIdTimestampParsing(t *testing.T) {
 	}
 
 	// Read the note back
-	err, note := db.ReadNoteId(noteId)
+	note, err := db.ReadNoteId(noteId)
 	if err != nil {
 		t.Fatalf("ReadNoteId failed: %v", err)
 	}
@@ -370,7 +567,7 @@ func TestReadNotesByUserId(t *testing.T) {
 	}
 
 	// Read notes
-	err, notes := db.ReadNotesByUserId(userId)
+	notes, err := db.ReadNotesByUserId(userId)
 	if err != nil {
 		t.Fatalf("ReadNotesByUserId failed: %v", err)
 	}
@@ -380,6 +577,47 @@ func TestReadNotesByUserId(t *testing.T) {
 	}
 }
 
+func TestReadNotesPageByUserId(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	userId := uuid.New()
+	createTestAccount(t, db, userId, "testuser", "pubkey", "webpub", "webpriv")
+
+	if _, err := db.CreateNoteWithVisibility(userId, "Public note", "", domain.VisibilityPublic); err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+	if _, err := db.CreateNoteWithVisibility(userId, "Local note", "", domain.VisibilityLocal); err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+	if _, err := db.CreateNoteWithVisibility(userId, "Followers note", "", domain.VisibilityFollowers); err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+
+	firstPage, err := db.ReadNotesPageByUserId(userId, 2, 0)
+	if err != nil {
+		t.Fatalf("ReadNotesPageByUserId failed: %v", err)
+	}
+	secondPage, err := db.ReadNotesPageByUserId(userId, 2, 2)
+	if err != nil {
+		t.Fatalf("ReadNotesPageByUserId failed: %v", err)
+	}
+	if len(*firstPage) != 2 || len(*secondPage) != 1 {
+		t.Fatalf("Expected pages of 2 and 1 notes, got %d and %d", len(*firstPage), len(*secondPage))
+	}
+
+	// Every visibility is included, unlike the public outbox query
+	visibilities := map[string]bool{}
+	for _, note := range append(*firstPage, *secondPage...) {
+		visibilities[note.Visibility] = true
+	}
+	for _, visibility := range []string{domain.VisibilityPublic, domain.VisibilityLocal, domain.VisibilityFollowers} {
+		if !visibilities[visibility] {
+			t.Errorf("Expected a %s note in the pages", visibility)
+		}
+	}
+}
+
 func TestReadNotesByUsername(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.db.Close()
@@ -394,7 +632,7 @@ func TestReadNotesByUsername(t *testing.T) {
 	db.CreateNote(userId, "Alice's note")
 
 	// Read notes by username
-	err, notes := db.ReadNotesByUsername(username)
+	notes, err := db.ReadNotesByUsername(username)
 	if err != nil {
 		t.Fatalf("ReadNotesByUsername failed: %v", err)
 	}
@@ -408,6 +646,74 @@ func TestReadNotesByUsername(t *testing.T) {
 	}
 }
 
+func TestReadNotesByUsernamePaged(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	userId := uuid.New()
+	createTestAccount(t, db, userId, "alice", "pubkey", "webpub", "webpriv")
+
+	for i := 0; i < 5; i++ {
+		if _, err := db.CreateNote(userId, fmt.Sprintf("Post %d", i)); err != nil {
+			t.Fatalf("CreateNote failed: %v", err)
+		}
+	}
+	if _, err := db.CreateNoteWithReply(userId, "A reply", "https://remote.example.com/notes/1"); err != nil {
+		t.Fatalf("CreateNoteWithReply failed: %v", err)
+	}
+	if _, err := db.CreateNoteWithVisibility(userId, "Local only", "", "local"); err != nil {
+		t.Fatalf("CreateNoteWithVisibility failed: %v", err)
+	}
+	// Identical timestamps must still page in a stable order
+	if _, err := db.db.Exec(`UPDATE notes SET created_at = ?`, time.Now().UTC().Format("2006-01-02 15:04:05")); err != nil {
+		t.Fatalf("Failed to set created_at: %v", err)
+	}
+
+	for _, includeReplies := range []bool{false, true} {
+		expected := 5
+		if includeReplies {
+			expected = 6
+		}
+		count, err := db.CountNotesByUsername("alice", includeReplies)
+		if err != nil {
+			t.Fatalf("CountNotesByUsername failed: %v", err)
+		}
+		if count != expected {
+			t.Errorf("Expected %d notes (replies: %v), got %d", expected, includeReplies, count)
+		}
+
+		seen := make(map[uuid.UUID]bool)
+		replies := 0
+		for offset := 0; ; offset += 2 {
+			page, err := db.ReadNotesByUsernamePaged("alice", 2, offset, includeReplies)
+			if err != nil {
+				t.Fatalf("ReadNotesByUsernamePaged failed: %v", err)
+			}
+			if len(*page) == 0 {
+				break
+			}
+			for _, note := range *page {
+				if seen[note.Id] {
+					t.Errorf("Note %s returned on more than one page", note.Id)
+				}
+				seen[note.Id] = true
+				if note.InReplyToURI != "" {
+					replies++
+				}
+				if note.Message == "Local only" {
+					t.Error("Expected local-only notes to be excluded")
+				}
+			}
+		}
+		if len(seen) != expected {
+			t.Errorf("Expected %d notes across pages (replies: %v), got %d", expected, includeReplies, len(seen))
+		}
+		if !includeReplies && replies != 0 {
+			t.Errorf("Expected replies to be excluded, got %d", replies)
+		}
+	}
+}
+
 func TestReadAllNotes(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.db.Close()
@@ -423,7 +729,7 @@ func TestReadAllNotes(t *testing.T) {
 	db.CreateNote(user2Id, "User2 note")
 
 	// Read all notes
-	err, notes := db.ReadAllNotes()
+	notes, err := db.ReadAllNotes()
 	if err != nil {
 		t.Fatalf("ReadAllNotes failed: %v", err)
 	}
@@ -454,7 +760,7 @@ func TestUpdateNote(t *testing.T) {
 	}
 
 	// Verify update
-	err, note := db.ReadNoteId(noteId)
+	note, err := db.ReadNoteId(noteId)
 	if err != nil {
 		t.Fatalf("ReadNoteId failed: %v", err)
 	}
@@ -468,2988 +774,6742 @@ func TestUpdateNote(t *testing.T) {
 	}
 }
 
-func TestDeleteNoteById(t *testing.T) {
+func TestNoteObjectJSON(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.db.Close()
 
-	// Create user and note
 	userId := uuid.New()
 	createTestAccount(t, db, userId, "testuser", "pubkey", "webpub", "webpriv")
-
-	noteId, err := db.CreateNote(userId, "To be deleted")
+	noteId, err := db.CreateNote(userId, "Original message")
 	if err != nil {
 		t.Fatalf("Failed to create note: %v", err)
 	}
 
-	// Delete note
-	err = db.DeleteNoteById(noteId)
-	if err != nil {
-		t.Fatalf("DeleteNoteById failed: %v", err)
+	// Notes that were never federated have no stored object
+	if objectJSON, err := db.ReadNoteObjectJSON(noteId); err != nil || objectJSON != "" {
+		t.Errorf("Expected no stored object, got %q, %v", objectJSON, err)
 	}
 
-	// Verify deletion
-	err, note := db.ReadNoteId(noteId)
-	if err == nil {
-		t.Error("Expected error when reading deleted note")
+	stored := `{"type":"Note","content":"<p>Original message</p>"}`
+	if err := db.UpdateNoteObjectJSON(noteId, stored); err != nil {
+		t.Fatalf("UpdateNoteObjectJSON failed: %v", err)
 	}
-	if note != nil {
-		t.Error("Expected nil note after deletion")
+	if objectJSON, _ := db.ReadNoteObjectJSON(noteId); objectJSON != stored {
+		t.Errorf("Expected the stored object, got %q", objectJSON)
 	}
-}
-
-func TestReadAllAccounts(t *testing.T) {
-	db := setupTestDB(t)
-	defer db.db.Close()
-
-	// Create accounts
-	user1Id := uuid.New()
-	user2Id := uuid.New()
-
-	createTestAccount(t, db, user1Id, "alice", "pubkey1", "webpub1", "webpriv1")
-	createTestAccount(t, db, user2Id, "bob", "pubkey2", "webpub2", "webpriv2")
-
-	// Update to set first_time_login = 0
-	db.UpdateLoginById("alice", "Alice", "Alice's bio", user1Id)
-	db.UpdateLoginById("bob", "Bob", "Bob's bio", user2Id)
 
-	// Read all accounts
-	err, accounts := db.ReadAllAccounts()
-	if err != nil {
-		t.Fatalf("ReadAllAccounts failed: %v", err)
+	// Editing drops the stale object until the Update stores the new one
+	if err := db.UpdateNote(noteId, "Updated message"); err != nil {
+		t.Fatalf("UpdateNote failed: %v", err)
+	}
+	if objectJSON, _ := db.ReadNoteObjectJSON(noteId); objectJSON != "" {
+		t.Errorf("Expected the object to be dropped on edit, got %q", objectJSON)
 	}
 
-	if len(*accounts) < 2 {
-		t.Errorf("Expected at least 2 accounts, got %d", len(*accounts))
+	db.UpdateNoteObjectJSON(noteId, stored)
+	if err := db.DeleteNoteById(noteId); err != nil {
+		t.Fatalf("DeleteNoteById failed: %v", err)
+	}
+	if objectJSON, _ := db.ReadNoteObjectJSON(noteId); objectJSON != "" {
+		t.Errorf("Expected the object to be dropped on delete, got %q", objectJSON)
 	}
 }
 
-func TestNoteTimestamps(t *testing.T) {
+func TestCreateAndUpdateNote_EnforceMaxNoteChars(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.db.Close()
 
-	// Create user and note
+	conf := &util.AppConfig{}
+	conf.Conf.MaxNoteChars = 5
+	db.SetConfig(conf)
+
 	userId := uuid.New()
 	createTestAccount(t, db, userId, "testuser", "pubkey", "webpub", "webpriv")
 
-	noteId, err := db.CreateNote(userId, "Timestamp test")
+	// Five multibyte characters fit, a link only counts its text
+	noteId, err := db.CreateNote(userId, "héllö")
 	if err != nil {
-		t.Fatalf("Failed to create note: %v", err)
+		t.Fatalf("Expected a note at the limit to be created, got %v", err)
 	}
-
-	// Verify timestamp
-	err, note := db.ReadNoteId(noteId)
-	if err != nil {
-		t.Fatalf("ReadNoteId failed: %v", err)
+	if _, err := db.CreateNote(userId, "[link](https://example.com/a/long/path)"); err != nil {
+		t.Errorf("Expected a link to count only its text, got %v", err)
 	}
 
-	// Just verify that CreatedAt is set (not zero)
-	if note.CreatedAt.IsZero() {
-		t.Error("CreatedAt should not be zero")
+	if _, err := db.CreateNote(userId, "héllö!"); !errors.Is(err, util.ErrNoteTooLong) {
+		t.Errorf("Expected ErrNoteTooLong on create, got %v", err)
+	}
+	if err := db.UpdateNote(noteId, "héllö!"); !errors.Is(err, util.ErrNoteTooLong) {
+		t.Errorf("Expected ErrNoteTooLong on update, got %v", err)
 	}
 
-	if note.EditedAt != nil {
-		t.Error("EditedAt should be nil for new note")
+	note, err := db.ReadNoteId(noteId)
+	if err != nil || note.Message != "héllö" {
+		t.Errorf("Expected the note to keep its message, got %+v, %v", note, err)
 	}
 }
 
-func TestAccountFirstTimeLogin(t *testing.T) {
-	db := setupTestDB(t)
-	defer db.db.Close()
-
-	id := uuid.New()
-	username := "newuser"
-
-	// Create account
-	createTestAccount(t, db, id, username, "pubkey", "webpub", "webpriv")
-
-	// Check initial state
-	err, acc := db.ReadAccById(id)
+// setupContendedDB opens a file database without a busy timeout and holds its write lock
+// on a separate connection, as a concurrent writer would. The returned func releases it.
+func setupContendedDB(t *testing.T) (*DB, func()) {
+	sqlDB, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "contended.db"))
 	if err != nil {
-		t.Fatalf("ReadAccById failed: %v", err)
+		t.Fatalf("Failed to open database: %v", err)
 	}
-
-	if acc.FirstTimeLogin != domain.TRUE {
-		t.Error("Expected FirstTimeLogin to be TRUE for new account")
+	t.Cleanup(func() { sqlDB.Close() })
+	if _, err := sqlDB.Exec("CREATE TABLE counters(id INTEGER PRIMARY KEY, n INTEGER)"); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
 	}
+	sqlDB.Exec("INSERT INTO counters VALUES (1, 0)")
 
-	// Update username (which sets FirstTimeLogin to FALSE)
-	err = db.UpdateLoginById("updateduser", "Updated User", "Updated bio", id)
+	holder, err := sqlDB.Conn(t.Context())
 	if err != nil {
-		t.Fatalf("UpdateLoginById failed: %v", err)
+		t.Fatalf("Failed to get a connection: %v", err)
 	}
-
-	// Verify FirstTimeLogin is now FALSE
-	err, acc = db.ReadAccById(id)
-	if err != nil {
-		t.Fatalf("ReadAccById failed: %v", err)
+	if _, err := holder.ExecContext(t.Context(), "BEGIN IMMEDIATE"); err != nil {
+		t.Fatalf("Failed to take the write lock: %v", err)
 	}
-
-	if acc.FirstTimeLogin != domain.FALSE {
-		t.Error("Expected FirstTimeLogin to be FALSE after update")
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			holder.ExecContext(t.Context(), "ROLLBACK")
+			holder.Close()
+		})
 	}
+	t.Cleanup(release)
+	return &DB{db: sqlDB}, release
 }
 
-// ActivityPub-related tests
+func incrementCounter(tx *sql.Tx) error {
+	_, err := tx.Exec("UPDATE counters SET n = n + 1 WHERE id = 1")
+	return err
+}
 
-func TestCreateRemoteAccount(t *testing.T) {
-	db := setupTestDB(t)
-	defer db.db.Close()
+func TestWrapTransaction_RetriesWhileBusy(t *testing.T) {
+	db, release := setupContendedDB(t)
 
-	remoteAcc := &domain.RemoteAccount{
-		Id:            uuid.New(),
-		Username:      "bob",
-		Domain:        "example.com",
-		ActorURI:      "https://example.com/users/bob",
-		DisplayName:   "Bob Smith",
-		Summary:       "Test user",
-		InboxURI:      "https://example.com/users/bob/inbox",
-		OutboxURI:     "https://example.com/users/bob/outbox",
-		PublicKeyPem:  "-----BEGIN PUBLIC KEY-----",
-		AvatarURL:     "https://example.com/avatar.png",
-		LastFetchedAt: time.Now(),
+	// The other writer finishes while the transaction is backing off
+	time.AfterFunc(txRetryBackoff, release)
+	start := time.Now()
+	if err := db.wrapTransaction(incrementCounter); err != nil {
+		t.Fatalf("Expected the write to succeed once the lock was released, got %v", err)
 	}
-
-	err := db.CreateRemoteAccount(remoteAcc)
-	if err != nil {
-		t.Fatalf("CreateRemoteAccount failed: %v", err)
+	if time.Since(start) < txRetryBackoff {
+		t.Error("Expected the retry to wait before running again")
 	}
 
-	// Verify
-	err, acc := db.ReadRemoteAccountByURI(remoteAcc.ActorURI)
-	if err != nil {
-		t.Fatalf("ReadRemoteAccountByURI failed: %v", err)
+	var n int
+	db.db.QueryRow("SELECT n FROM counters WHERE id = 1").Scan(&n)
+	if n != 1 {
+		t.Errorf("Expected the counter to be incremented once, got %d", n)
 	}
+}
 
-	if acc.Username != remoteAcc.Username {
-		t.Errorf("Expected username %s, got %s", remoteAcc.Username, acc.Username)
+func TestWrapTransaction_GivesUpWhenBusy(t *testing.T) {
+	db, _ := setupContendedDB(t)
+
+	attempts := 0
+	err := db.wrapTransaction(func(tx *sql.Tx) error {
+		attempts++
+		return incrementCounter(tx)
+	})
+	if !errors.Is(err, ErrDatabaseBusy) {
+		t.Fatalf("Expected ErrDatabaseBusy, got %v", err)
+	}
+	if attempts != maxTxAttempts {
+		t.Errorf("Expected %d attempts, got %d", maxTxAttempts, attempts)
 	}
 }
 
-func TestCreateLocalFollow(t *testing.T) {
+func TestWithTx(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.db.Close()
 
-	// Create two local accounts
-	follower := uuid.New()
-	target := uuid.New()
-	createTestAccount(t, db, follower, "alice", "pubkey1", "webpub1", "webpriv1")
-	createTestAccount(t, db, target, "bob", "pubkey2", "webpub2", "webpriv2")
-
-	// Create local follow
-	err := db.CreateLocalFollow(follower, target)
-	if err != nil {
-		t.Fatalf("CreateLocalFollow failed: %v", err)
-	}
+	userId := uuid.New()
+	createTestAccount(t, db, userId, "testuser", "pubkey", "webpub", "webpriv")
 
-	// Verify follow exists
-	isFollowing, err := db.IsFollowingLocal(follower, target)
+	// A failing callback rolls back every write made through the tx copy
+	failure := fmt.Errorf("handler failed")
+	var rolledBackId uuid.UUID
+	err := db.WithTx(func(tx *DB) error {
+		var err error
+		if rolledBackId, err = tx.CreateNote(userId, "Rolled back"); err != nil {
+			return err
+		}
+		if note, err := tx.ReadNoteId(rolledBackId); err != nil || note == nil {
+			t.Errorf("Expected the note to be readable inside the transaction: %v", err)
+		}
+		return failure
+	})
+	if err != failure {
+		t.Fatalf("Expected the callback error, got %v", err)
+	}
+	if note, err := db.ReadNoteId(rolledBackId); err == nil && note != nil {
+		t.Error("Expected the note to be rolled back")
+	}
+
+	// A successful callback commits, including nested WithTx calls
+	var committedId uuid.UUID
+	err = db.WithTx(func(tx *DB) error {
+		return tx.WithTx(func(nested *DB) error {
+			var err error
+			committedId, err = nested.CreateNote(userId, "Committed")
+			return err
+		})
+	})
 	if err != nil {
-		t.Fatalf("IsFollowingLocal failed: %v", err)
+		t.Fatalf("WithTx failed: %v", err)
 	}
-
-	if !isFollowing {
-		t.Error("Expected isFollowing to be true")
+	if note, err := db.ReadNoteId(committedId); err != nil || note == nil {
+		t.Errorf("Expected the note to be committed: %v", err)
 	}
 }
 
-func TestDeleteLocalFollow(t *testing.T) {
+func TestDeleteNoteById(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.db.Close()
 
-	// Create two local accounts
-	follower := uuid.New()
-	target := uuid.New()
-	createTestAccount(t, db, follower, "alice", "pubkey1", "webpub1", "webpriv1")
-	createTestAccount(t, db, target, "bob", "pubkey2", "webpub2", "webpriv2")
+	// Create user and note
+	userId := uuid.New()
+	createTestAccount(t, db, userId, "testuser", "pubkey", "webpub", "webpriv")
 
-	// Create and then delete follow
-	db.CreateLocalFollow(follower, target)
-
-	err := db.DeleteLocalFollow(follower, target)
+	noteId, err := db.CreateNote(userId, "To be deleted")
 	if err != nil {
-		t.Fatalf("DeleteLocalFollow failed: %v", err)
+		t.Fatalf("Failed to create note: %v", err)
 	}
 
-	// Verify follow doesn't exist
-	isFollowing, err := db.IsFollowingLocal(follower, target)
+	// Delete note
+	err = db.DeleteNoteById(noteId)
 	if err != nil {
-		t.Fatalf("IsFollowingLocal failed: %v", err)
+		t.Fatalf("DeleteNoteById failed: %v", err)
 	}
 
-	if isFollowing {
-		t.Error("Expected isFollowing to be false after deletion")
+	// The note is kept as a tombstone
+	note, err := db.ReadNoteId(noteId)
+	if err != nil || note == nil {
+		t.Fatalf("Expected tombstone to be readable after deletion: %v", err)
+	}
+	if !note.IsDeleted() {
+		t.Error("Expected note to be marked as deleted")
+	}
+	if note.Message != domain.DeletedNotePlaceholder {
+		t.Errorf("Expected message %q, got %q", domain.DeletedNotePlaceholder, note.Message)
+	}
+
+	var message string
+	if err := db.db.QueryRow(`SELECT message FROM notes WHERE id = ?`, noteId.String()).Scan(&message); err != nil {
+		t.Fatalf("Failed to read stored message: %v", err)
+	}
+	if message != "" {
+		t.Errorf("Expected stored message to be blanked, got %q", message)
+	}
+
+	// and no longer listed with the user's posts
+	notes, err := db.ReadNotesByUserId(userId)
+	if err != nil {
+		t.Fatalf("ReadNotesByUserId failed: %v", err)
+	}
+	if len(*notes) != 0 {
+		t.Errorf("Expected deleted note to be hidden from the user's posts, got %d notes", len(*notes))
 	}
 }
 
-func TestCreateActivity(t *testing.T) {
+func TestDeleteNoteById_RepliesResolveTombstone(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.db.Close()
 
-	activity := &domain.Activity{
-		Id:           uuid.New(),
-		ActivityURI:  "https://example.com/activities/123",
-		ActivityType: "Create",
-		ActorURI:     "https://example.com/users/bob",
-		ObjectURI:    "https://example.com/notes/456",
-		RawJSON:      `{"type":"Create"}`,
-		Processed:    false,
-		CreatedAt:    time.Now(),
-		Local:        false,
-	}
+	userId := uuid.New()
+	createTestAccount(t, db, userId, "testuser", "pubkey", "webpub", "webpriv")
 
-	err := db.CreateActivity(activity)
+	parentId, err := db.CreateNote(userId, "Parent")
 	if err != nil {
-		t.Fatalf("CreateActivity failed: %v", err)
+		t.Fatalf("Failed to create parent note: %v", err)
+	}
+	parentURI := "https://local.example.com/notes/" + parentId.String()
+	if _, err := db.db.Exec(`UPDATE notes SET object_uri = ? WHERE id = ?`, parentURI, parentId.String()); err != nil {
+		t.Fatalf("Failed to set object_uri: %v", err)
+	}
+	if _, err := db.CreateNoteWithReply(userId, "Reply", parentURI); err != nil {
+		t.Fatalf("Failed to create reply: %v", err)
 	}
 
-	// Verify
-	err, act := db.ReadActivityByURI(activity.ActivityURI)
-	if err != nil {
-		t.Fatalf("ReadActivityByURI failed: %v", err)
+	if err := db.DeleteNoteById(parentId); err != nil {
+		t.Fatalf("DeleteNoteById failed: %v", err)
 	}
 
-	if act.ActivityType != activity.ActivityType {
-		t.Errorf("Expected ActivityType %s, got %s", activity.ActivityType, act.ActivityType)
+	parent, err := db.ReadNoteByURI(parentURI)
+	if err != nil || parent == nil {
+		t.Fatalf("Expected reply parent to resolve to the tombstone: %v", err)
+	}
+	if !parent.IsDeleted() || parent.Message != domain.DeletedNotePlaceholder {
+		t.Errorf("Expected deleted placeholder, got deleted=%v message=%q", parent.IsDeleted(), parent.Message)
+	}
+
+	replies, err := db.ReadRepliesByURI(parentURI)
+	if err != nil {
+		t.Fatalf("ReadRepliesByURI failed: %v", err)
+	}
+	if len(*replies) != 1 {
+		t.Errorf("Expected the reply to stay attached, got %d replies", len(*replies))
 	}
 }
 
-func TestReadLocalTimelineNotes(t *testing.T) {
+func TestPurgeDeletedNotes(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.db.Close()
 
-	// Create user and notes
 	userId := uuid.New()
-	createTestAccount(t, db, userId, "alice", "pubkey", "webpub", "webpriv")
+	createTestAccount(t, db, userId, "testuser", "pubkey", "webpub", "webpriv")
 
-	// Create some notes
-	for i := range 5 {
-		db.CreateNote(userId, "Note "+string(rune('A'+i)))
+	oldId, _ := db.CreateNote(userId, "Old")
+	recentId, _ := db.CreateNote(userId, "Recent")
+	liveId, _ := db.CreateNote(userId, "Live")
+	for _, id := range []uuid.UUID{oldId, recentId} {
+		if err := db.DeleteNoteById(id); err != nil {
+			t.Fatalf("DeleteNoteById failed: %v", err)
+		}
+	}
+	oldDeletedAt := time.Now().UTC().Add(-48 * time.Hour).Format("2006-01-02 15:04:05")
+	if _, err := db.db.Exec(`UPDATE notes SET deleted_at = ? WHERE id = ?`, oldDeletedAt, oldId.String()); err != nil {
+		t.Fatalf("Failed to backdate tombstone: %v", err)
 	}
 
-	// Read timeline with limit
-	err, notes := db.ReadLocalTimelineNotes(userId, 3)
+	purged, err := db.PurgeDeletedNotes(time.Now().Add(-24 * time.Hour))
 	if err != nil {
-		t.Fatalf("ReadLocalTimelineNotes failed: %v", err)
+		t.Fatalf("PurgeDeletedNotes failed: %v", err)
 	}
-
-	if len(*notes) != 3 {
-		t.Errorf("Expected 3 notes (limited), got %d", len(*notes))
+	if purged != 1 {
+		t.Errorf("Expected 1 purged tombstone, got %d", purged)
+	}
+	if _, err := db.ReadNoteId(oldId); err == nil {
+		t.Error("Expected old tombstone to be purged")
+	}
+	if note, err := db.ReadNoteId(recentId); err != nil || note == nil {
+		t.Error("Expected recent tombstone to be kept")
+	}
+	if note, err := db.ReadNoteId(liveId); err != nil || note == nil {
+		t.Error("Expected live note to be kept")
 	}
 }
 
-func TestDeleteAccount(t *testing.T) {
+func TestDeleteNoteById_Idempotent(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.db.Close()
 
-	// Create a test account
 	userId := uuid.New()
-	createTestAccount(t, db, userId, "alice", "pubkey", "webpub", "webpriv")
+	createTestAccount(t, db, userId, "testuser", "pubkey", "webpub", "webpriv")
 
-	// Create notes for the user
-	_, err := db.CreateNote(userId, "Test note 1")
+	parentId, err := db.CreateNote(userId, "Parent")
 	if err != nil {
-		t.Fatalf("Failed to create note 1: %v", err)
+		t.Fatalf("Failed to create parent note: %v", err)
 	}
-	_, err = db.CreateNote(userId, "Test note 2")
+	parentURI := "local:" + parentId.String()
+	replyId, err := db.CreateNoteWithReply(userId, "Reply 1", parentURI)
 	if err != nil {
-		t.Fatalf("Failed to create note 2: %v", err)
+		t.Fatalf("Failed to create reply: %v", err)
+	}
+	if _, err := db.CreateNoteWithReply(userId, "Reply 2", parentURI); err != nil {
+		t.Fatalf("Failed to create reply: %v", err)
 	}
 
-	// Create a second user for follow relationships
-	user2Id := uuid.New()
-	createTestAccount(t, db, user2Id, "bob", "pubkey2", "webpub2", "webpriv2")
+	// Deleting the same note twice must only decrement the parent once
+	for i := 0; i < 2; i++ {
+		if err := db.DeleteNoteById(replyId); err != nil {
+			t.Fatalf("DeleteNoteById attempt %d failed: %v", i+1, err)
+		}
+	}
 
-	// Create local follow relationships (alice follows bob, bob follows alice)
-	err = db.CreateLocalFollow(userId, user2Id)
-	if err != nil {
-		t.Fatalf("Failed to create follow: %v", err)
+	var replyCount int
+	if err := db.db.QueryRow(`SELECT reply_count FROM notes WHERE id = ?`, parentId.String()).Scan(&replyCount); err != nil {
+		t.Fatalf("Failed to read reply count: %v", err)
 	}
-	err = db.CreateLocalFollow(user2Id, userId)
+	if replyCount != 1 {
+		t.Errorf("Expected parent reply count 1 after duplicate delete, got %d", replyCount)
+	}
+}
+
+func TestDeleteNoteById_KeepsRemoteReplies(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	userId := uuid.New()
+	createTestAccount(t, db, userId, "testuser", "pubkey", "webpub", "webpriv")
+
+	parentId := uuid.New()
+	parentURI := "https://local.example.com/notes/" + parentId.String()
+	_, err := db.db.Exec(`INSERT INTO notes (id, user_id, message, created_at, object_uri) VALUES (?, ?, ?, ?, ?)`,
+		parentId.String(), userId.String(), "Parent", time.Now().Add(-time.Hour), parentURI)
 	if err != nil {
-		t.Fatalf("Failed to create reverse follow: %v", err)
+		t.Fatalf("Failed to create parent note: %v", err)
 	}
 
-	// Create an activity for the user
-	activity := &domain.Activity{
+	replyURI := "https://remote.example.com/notes/reply"
+	reply := &domain.Activity{
 		Id:           uuid.New(),
-		ActivityURI:  "https://example.com/activities/alice",
+		ActivityURI:  "https://remote.example.com/activities/1",
 		ActivityType: "Create",
-		ActorURI:     "https://example.com/users/alice",
-		ObjectURI:    "https://example.com/notes/123",
-		RawJSON:      `{"type":"Create"}`,
+		ActorURI:     "https://remote.example.com/users/bob",
+		ObjectURI:    replyURI,
+		RawJSON:      `{"type":"Create","object":{"id":"` + replyURI + `","type":"Note","content":"Reply","inReplyTo":"` + parentURI + `"}}`,
 		Processed:    true,
 		CreatedAt:    time.Now(),
-		Local:        true,
 	}
-	err = db.CreateActivity(activity)
-	if err != nil {
+	if err := db.CreateActivity(reply); err != nil {
 		t.Fatalf("Failed to create activity: %v", err)
 	}
 
-	// Verify data exists before deletion
-	err, acc := db.ReadAccById(userId)
-	if err != nil || acc == nil {
-		t.Fatalf("Account should exist before deletion")
+	if err := db.DeleteNoteById(parentId); err != nil {
+		t.Fatalf("DeleteNoteById failed: %v", err)
 	}
 
-	err, notes := db.ReadNotesByUserId(userId)
-	if err != nil || len(*notes) != 2 {
-		t.Fatalf("Expected 2 notes before deletion, got %d", len(*notes))
+	// The remote reply survives and its thread still starts at the deleted parent's tombstone
+	conv, err := db.ReadConversation(replyURI)
+	if err != nil {
+		t.Fatalf("Expected remote reply to survive parent deletion: %v", err)
+	}
+	if conv.MissingParentURI != "" {
+		t.Errorf("Expected parent to resolve, got MissingParentURI %s", conv.MissingParentURI)
 	}
+	if conv.Root == nil || conv.Root.Note == nil || !conv.Root.Note.IsDeleted() {
+		t.Errorf("Expected conversation root to be the deleted parent's tombstone")
+	}
+}
 
-	// Delete the account
-	err = db.DeleteAccount(userId)
+func TestLocalActivities_HiddenFromRepliesAndRemovedWithNote(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	userId := uuid.New()
+	createTestAccount(t, db, userId, "testuser", "pubkey", "webpub", "webpriv")
+
+	parentURI := "https://remote.example.com/notes/parent"
+	noteId, err := db.CreateNoteWithReply(userId, "Local reply", parentURI)
 	if err != nil {
-		t.Fatalf("DeleteAccount failed: %v", err)
+		t.Fatalf("Failed to create note: %v", err)
 	}
 
-	// Verify account was deleted
-	err, acc = db.ReadAccById(userId)
-	if err != sql.ErrNoRows {
-		t.Errorf("Account should not exist after deletion, got: %v", acc)
+	noteURI := "https://local.example.com/notes/" + noteId.String()
+	activityURI := "https://local.example.com/activities/" + uuid.New().String()
+	create := &domain.Activity{
+		Id:           uuid.New(),
+		ActivityURI:  activityURI,
+		ActivityType: "Create",
+		ActorURI:     "https://local.example.com/users/testuser",
+		ObjectURI:    noteURI,
+		RawJSON:      `{"type":"Create","object":{"id":"` + noteURI + `","type":"Note","inReplyTo":"` + parentURI + `"}}`,
+		Processed:    true,
+		Local:        true,
+		CreatedAt:    time.Now(),
 	}
-
-	// Verify notes were deleted
-	err, notes = db.ReadNotesByUserId(userId)
-	if err != nil || len(*notes) != 0 {
-		t.Errorf("Expected 0 notes after deletion, got %d", len(*notes))
+	if err := db.CreateActivity(create); err != nil {
+		t.Fatalf("Failed to store local activity: %v", err)
 	}
 
-	// Verify follows were deleted (both directions)
-	err, following := db.ReadFollowingByAccountId(userId)
-	if err != nil || len(*following) != 0 {
-		t.Errorf("Expected 0 following relationships after deletion, got %d", len(*following))
+	// Our own Create must not show up as a remote reply or remote copy
+	if count, _ := db.CountActivitiesByInReplyTo(parentURI); count != 0 {
+		t.Errorf("Expected local activity not to count as a remote reply, got %d", count)
 	}
-
-	err, followers := db.ReadFollowersByAccountId(userId)
-	if err != nil || len(*followers) != 0 {
-		t.Errorf("Expected 0 follower relationships after deletion, got %d", len(*followers))
+	if activity, err := db.ReadActivityByObjectURI(noteURI); err == nil && activity != nil {
+		t.Errorf("Expected local activity not to be returned by ReadActivityByObjectURI")
 	}
 
-	// Note: Activities are NOT deleted (they remain as historical record)
-	// This matches ActivityPub behavior
+	// But it can be dereferenced by its id
+	stored, err := db.ReadActivityByURI(activityURI)
+	if err != nil || stored == nil || !stored.Local {
+		t.Fatalf("Expected local activity to be readable by URI, got err=%v", err)
+	}
 
-	// Verify bob's account still exists (shouldn't be affected)
-	err, bob := db.ReadAccById(user2Id)
-	if err != nil || bob == nil {
-		t.Errorf("Bob's account should still exist after alice deletion")
+	if err := db.DeleteNoteById(noteId); err != nil {
+		t.Fatalf("DeleteNoteById failed: %v", err)
+	}
+	if _, err := db.ReadActivityByURI(activityURI); err == nil {
+		t.Errorf("Expected local Create to be removed with its note")
 	}
 }
 
-func TestDeleteAccountNonExistent(t *testing.T) {
+func TestReadAllAccounts(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.db.Close()
 
-	// Try to delete a non-existent account
-	nonExistentId := uuid.New()
-	err := db.DeleteAccount(nonExistentId)
-	// Should not error even if account doesn't exist (idempotent delete)
+	// Create accounts
+	user1Id := uuid.New()
+	user2Id := uuid.New()
+
+	createTestAccount(t, db, user1Id, "alice", "pubkey1", "webpub1", "webpriv1")
+	createTestAccount(t, db, user2Id, "bob", "pubkey2", "webpub2", "webpriv2")
+
+	// Update to set first_time_login = 0
+	db.UpdateLoginById("alice", "Alice", "Alice's bio", user1Id)
+	db.UpdateLoginById("bob", "Bob", "Bob's bio", user2Id)
+
+	// Read all accounts
+	accounts, err := db.ReadAllAccounts()
 	if err != nil {
-		t.Errorf("Deleting non-existent account should not error: %v", err)
+		t.Fatalf("ReadAllAccounts failed: %v", err)
+	}
+
+	if len(*accounts) < 2 {
+		t.Errorf("Expected at least 2 accounts, got %d", len(*accounts))
 	}
 }
 
-func TestDeleteAccountWithNoData(t *testing.T) {
+func TestNoteTimestamps(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.db.Close()
 
-	// Create a user with no notes, follows, activities, etc.
+	// Create user and note
 	userId := uuid.New()
-	createTestAccount(t, db, userId, "lonely", "pubkey", "webpub", "webpriv")
+	createTestAccount(t, db, userId, "testuser", "pubkey", "webpub", "webpriv")
 
-	// Delete the account
-	err := db.DeleteAccount(userId)
+	noteId, err := db.CreateNote(userId, "Timestamp test")
 	if err != nil {
-		t.Fatalf("DeleteAccount failed for account with no data: %v", err)
+		t.Fatalf("Failed to create note: %v", err)
 	}
 
-	// Verify account was deleted
-	err, acc := db.ReadAccById(userId)
-	if err != sql.ErrNoRows {
-		t.Errorf("Account should not exist after deletion, got: %v", acc)
+	// Verify timestamp
+	note, err := db.ReadNoteId(noteId)
+	if err != nil {
+		t.Fatalf("ReadNoteId failed: %v", err)
 	}
-}
 
-// Admin functionality tests
+	// Just verify that CreatedAt is set (not zero)
+	if note.CreatedAt.IsZero() {
+		t.Error("CreatedAt should not be zero")
+	}
 
-func TestFirstUserIsAdmin(t *testing.T) {
+	if note.EditedAt != nil {
+		t.Error("EditedAt should be nil for new note")
+	}
+}
+
+func TestAccountFirstTimeLogin(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.db.Close()
 
-	// Create first user
-	user1Id := uuid.New()
-	_, err := db.db.Exec(sqlInsertUser, user1Id, "firstuser", "hash1", "webpub1", "webpriv1", time.Now())
-	if err != nil {
-		t.Fatalf("Failed to insert first user: %v", err)
-	}
+	id := uuid.New()
+	username := "newuser"
 
-	// Set is_admin for first user
-	_, err = db.db.Exec("UPDATE accounts SET is_admin = 1 WHERE id = ?", user1Id.String())
+	// Create account
+	createTestAccount(t, db, id, username, "pubkey", "webpub", "webpriv")
+
+	// Check initial state
+	acc, err := db.ReadAccById(id)
 	if err != nil {
-		t.Fatalf("Failed to set is_admin: %v", err)
+		t.Fatalf("ReadAccById failed: %v", err)
 	}
 
-	// Create second user
-	user2Id := uuid.New()
-	_, err = db.db.Exec(sqlInsertUser, user2Id, "seconduser", "hash2", "webpub2", "webpriv2", time.Now())
-	if err != nil {
-		t.Fatalf("Failed to insert second user: %v", err)
+	if acc.FirstTimeLogin != domain.TRUE {
+		t.Error("Expected FirstTimeLogin to be TRUE for new account")
 	}
 
-	// Verify first user is admin
-	err, acc1 := db.ReadAccById(user1Id)
+	// Update username (which sets FirstTimeLogin to FALSE)
+	err = db.UpdateLoginById("updateduser", "Updated User", "Updated bio", id)
 	if err != nil {
-		t.Fatalf("ReadAccById failed for first user: %v", err)
-	}
-	if !acc1.IsAdmin {
-		t.Errorf("First user should be admin, got IsAdmin = %v", acc1.IsAdmin)
+		t.Fatalf("UpdateLoginById failed: %v", err)
 	}
 
-	// Verify second user is not admin
-	err, acc2 := db.ReadAccById(user2Id)
+	// Verify FirstTimeLogin is now FALSE
+	acc, err = db.ReadAccById(id)
 	if err != nil {
-		t.Fatalf("ReadAccById failed for second user: %v", err)
+		t.Fatalf("ReadAccById failed: %v", err)
 	}
-	if acc2.IsAdmin {
-		t.Errorf("Second user should not be admin, got IsAdmin = %v", acc2.IsAdmin)
+
+	if acc.FirstTimeLogin != domain.FALSE {
+		t.Error("Expected FirstTimeLogin to be FALSE after update")
 	}
 }
 
-func TestMuteUser(t *testing.T) {
-	db := setupTestDB(t)
+// ActivityPub-related tests
+
+func TestCreateRemoteAccount(t *testing.T) {
+	db := setupTestDB(t)
 	defer db.db.Close()
 
-	// Create user with notes
-	userId := uuid.New()
-	createTestAccount(t, db, userId, "testuser", "pubkey", "webpub", "webpriv")
+	remoteAcc := &domain.RemoteAccount{
+		Id:            uuid.New(),
+		Username:      "bob",
+		Domain:        "example.com",
+		ActorURI:      "https://example.com/users/bob",
+		DisplayName:   "Bob Smith",
+		Summary:       "Test user",
+		InboxURI:      "https://example.com/users/bob/inbox",
+		OutboxURI:     "https://example.com/users/bob/outbox",
+		PublicKeyPem:  "-----BEGIN PUBLIC KEY-----",
+		AvatarURL:     "https://example.com/avatar.png",
+		LastFetchedAt: time.Now(),
+	}
 
-	// Create some notes
-	noteId1 := uuid.New()
-	noteId2 := uuid.New()
-	_, err := db.db.Exec("INSERT INTO notes (id, user_id, message, created_at) VALUES (?, ?, ?, ?)",
-		noteId1, userId.String(), "Note 1", time.Now())
+	err := db.CreateRemoteAccount(remoteAcc)
 	if err != nil {
-		t.Fatalf("Failed to create note 1: %v", err)
+		t.Fatalf("CreateRemoteAccount failed: %v", err)
 	}
-	_, err = db.db.Exec("INSERT INTO notes (id, user_id, message, created_at) VALUES (?, ?, ?, ?)",
-		noteId2, userId.String(), "Note 2", time.Now())
+
+	// Verify
+	acc, err := db.ReadRemoteAccountByURI(remoteAcc.ActorURI)
 	if err != nil {
-		t.Fatalf("Failed to create note 2: %v", err)
+		t.Fatalf("ReadRemoteAccountByURI failed: %v", err)
 	}
 
-	// Verify notes exist
-	err, notes := db.ReadNotesByUserId(userId)
-	if err != nil || len(*notes) != 2 {
-		t.Fatalf("Expected 2 notes before mute, got %d", len(*notes))
+	if acc.Username != remoteAcc.Username {
+		t.Errorf("Expected username %s, got %s", remoteAcc.Username, acc.Username)
 	}
+}
 
-	// Mute the user
-	err = db.MuteUser(userId)
-	if err != nil {
-		t.Fatalf("MuteUser failed: %v", err)
+func TestRemoteAccount_SharedInboxRoundTrip(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	remoteAcc := &domain.RemoteAccount{
+		Id:             uuid.New(),
+		Username:       "bob",
+		Domain:         "example.com",
+		ActorURI:       "https://example.com/users/bob",
+		InboxURI:       "https://example.com/users/bob/inbox",
+		SharedInboxURI: "https://example.com/inbox",
+		LastFetchedAt:  time.Now(),
+	}
+	if err := db.CreateRemoteAccount(remoteAcc); err != nil {
+		t.Fatalf("CreateRemoteAccount failed: %v", err)
 	}
 
-	// Verify user is muted
-	err, acc := db.ReadAccById(userId)
+	acc, err := db.ReadRemoteAccountByActorURI(remoteAcc.ActorURI)
 	if err != nil {
-		t.Fatalf("ReadAccById failed: %v", err)
+		t.Fatalf("ReadRemoteAccountByActorURI failed: %v", err)
 	}
-	if !acc.Muted {
-		t.Errorf("User should be muted, got Muted = %v", acc.Muted)
+	if acc.SharedInboxURI != "https://example.com/inbox" {
+		t.Errorf("Expected shared inbox to be stored, got %q", acc.SharedInboxURI)
 	}
 
-	// Verify notes were deleted
-	err, notes = db.ReadNotesByUserId(userId)
-	if err != nil || len(*notes) != 0 {
-		t.Errorf("Expected 0 notes after mute, got %d", len(*notes))
+	// The actor stops advertising a shared inbox
+	remoteAcc.SharedInboxURI = ""
+	if err := db.UpdateRemoteAccount(remoteAcc); err != nil {
+		t.Fatalf("UpdateRemoteAccount failed: %v", err)
+	}
+	accounts, err := db.ReadAllRemoteAccounts()
+	if err != nil {
+		t.Fatalf("ReadAllRemoteAccounts failed: %v", err)
+	}
+	if len(accounts) != 1 || accounts[0].SharedInboxURI != "" {
+		t.Errorf("Expected shared inbox to be cleared, got %+v", accounts)
 	}
 }
 
-func TestUnmuteUser(t *testing.T) {
+func TestRemoteAccount_PublicKeysRoundTrip(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.db.Close()
 
-	// Create user
-	userId := uuid.New()
-	createTestAccount(t, db, userId, "testuser", "pubkey", "webpub", "webpriv")
-
-	// Mute the user
-	err := db.MuteUser(userId)
-	if err != nil {
-		t.Fatalf("MuteUser failed: %v", err)
+	remoteAcc := &domain.RemoteAccount{
+		Id:           uuid.New(),
+		Username:     "bob",
+		Domain:       "example.com",
+		ActorURI:     "https://example.com/users/bob",
+		InboxURI:     "https://example.com/users/bob/inbox",
+		PublicKeyPem: "main",
+		PublicKeys: map[string]string{
+			"https://example.com/users/bob#main-key":  "main",
+			"https://example.com/users/bob#other-key": "other",
+		},
+		LastFetchedAt: time.Now(),
+	}
+	if err := db.CreateRemoteAccount(remoteAcc); err != nil {
+		t.Fatalf("CreateRemoteAccount failed: %v", err)
 	}
 
-	// Verify user is muted
-	err, acc := db.ReadAccById(userId)
+	acc, err := db.ReadRemoteAccountByURI(remoteAcc.ActorURI)
 	if err != nil {
-		t.Fatalf("ReadAccById failed: %v", err)
+		t.Fatalf("ReadRemoteAccountByURI failed: %v", err)
 	}
-	if !acc.Muted {
-		t.Fatalf("User should be muted")
+	if acc.PublicKeyFor("https://example.com/users/bob#other-key") != "other" {
+		t.Errorf("Expected the second key to be stored, got %v", acc.PublicKeys)
 	}
 
-	// Unmute the user
-	err = db.UnmuteUser(userId)
-	if err != nil {
-		t.Fatalf("UnmuteUser failed: %v", err)
+	// The actor goes back to a single key
+	remoteAcc.PublicKeys = nil
+	if err := db.UpdateRemoteAccount(remoteAcc); err != nil {
+		t.Fatalf("UpdateRemoteAccount failed: %v", err)
 	}
-
-	// Verify user is not muted
-	err, acc = db.ReadAccById(userId)
+	acc, err = db.ReadRemoteAccountByURI(remoteAcc.ActorURI)
 	if err != nil {
-		t.Fatalf("ReadAccById failed: %v", err)
+		t.Fatalf("ReadRemoteAccountByURI failed: %v", err)
 	}
-	if acc.Muted {
-		t.Errorf("User should not be muted after unmute, got Muted = %v", acc.Muted)
+	if acc.PublicKeys != nil || acc.PublicKeyFor("https://example.com/users/bob#other-key") != "main" {
+		t.Errorf("Expected only the main key to remain, got %v", acc.PublicKeys)
 	}
 }
 
-func TestReadAllAccountsAdmin(t *testing.T) {
+func TestDeleteUnreferencedRemoteAccounts(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.db.Close()
 
-	// Create users with different first_time_login states
-	user1Id := uuid.New()
-	user2Id := uuid.New()
-	user3Id := uuid.New()
-
-	createTestAccount(t, db, user1Id, "user1", "pubkey1", "webpub1", "webpriv1")
-	createTestAccount(t, db, user2Id, "user2", "pubkey2", "webpub2", "webpriv2")
-	createTestAccount(t, db, user3Id, "user3", "pubkey3", "webpub3", "webpriv3")
+	localId := uuid.New()
+	createTestAccount(t, db, localId, "alice", "pubkey1", "webpub1", "webpriv1")
 
-	// Set first_time_login = 0 for user1 and user2 (completed registration)
-	_, err := db.db.Exec("UPDATE accounts SET first_time_login = 0 WHERE id IN (?, ?)", user1Id.String(), user2Id.String())
-	if err != nil {
-		t.Fatalf("Failed to update first_time_login: %v", err)
+	stale := time.Now().Add(-100 * 24 * time.Hour)
+	remote := make(map[string]*domain.RemoteAccount)
+	for _, name := range []string{"follower", "poster", "liker", "booster", "notifier", "orphan", "fresh"} {
+		acc := &domain.RemoteAccount{
+			Id:            uuid.New(),
+			Username:      name,
+			Domain:        "remote.example",
+			ActorURI:      "https://remote.example/users/" + name,
+			InboxURI:      "https://remote.example/users/" + name + "/inbox",
+			LastFetchedAt: stale,
+		}
+		if name == "fresh" {
+			acc.LastFetchedAt = time.Now()
+		}
+		if err := db.CreateRemoteAccount(acc); err != nil {
+			t.Fatalf("CreateRemoteAccount failed: %v", err)
+		}
+		remote[name] = acc
 	}
 
-	// user3 keeps first_time_login = 1 (default from sqlInsertUser)
-
-	// ReadAllAccounts should only return users with first_time_login = 0
-	err, accounts := db.ReadAllAccounts()
-	if err != nil {
-		t.Fatalf("ReadAllAccounts failed: %v", err)
+	noteId := uuid.New()
+	if err := db.CreateFollow(&domain.Follow{Id: uuid.New(), AccountId: remote["follower"].Id, TargetAccountId: localId, URI: "https://remote.example/follows/1", CreatedAt: time.Now(), Accepted: true}); err != nil {
+		t.Fatalf("CreateFollow failed: %v", err)
 	}
-	if len(*accounts) != 2 {
-		t.Errorf("ReadAllAccounts: expected 2 users, got %d", len(*accounts))
+	if err := db.CreateActivity(&domain.Activity{Id: uuid.New(), ActivityURI: "https://remote.example/activities/1", ActivityType: "Create", ActorURI: remote["poster"].ActorURI, CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("CreateActivity failed: %v", err)
+	}
+	if err := db.CreateLike(&domain.Like{Id: uuid.New(), AccountId: remote["liker"].Id, NoteId: noteId, URI: "https://remote.example/likes/1", CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("CreateLike failed: %v", err)
+	}
+	if err := db.CreateBoost(&domain.Boost{Id: uuid.New(), AccountId: remote["booster"].Id, NoteId: noteId, URI: "https://remote.example/boosts/1", CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("CreateBoost failed: %v", err)
+	}
+	createTestNotification(t, db, localId, domain.NotificationMention, "notifier", noteId, time.Now())
+	if _, err := db.db.Exec(`UPDATE notifications SET actor_id = ?`, remote["notifier"].Id.String()); err != nil {
+		t.Fatalf("Failed to set notification actor: %v", err)
 	}
 
-	// ReadAllAccountsAdmin should return ALL users
-	err, accountsAdmin := db.ReadAllAccountsAdmin()
+	deleted, err := db.DeleteUnreferencedRemoteAccounts(time.Now().Add(-30 * 24 * time.Hour))
 	if err != nil {
-		t.Fatalf("ReadAllAccountsAdmin failed: %v", err)
+		t.Fatalf("DeleteUnreferencedRemoteAccounts failed: %v", err)
 	}
-	if len(*accountsAdmin) != 3 {
-		t.Errorf("ReadAllAccountsAdmin: expected 3 users, got %d", len(*accountsAdmin))
+	if deleted != 1 {
+		t.Errorf("Expected 1 deleted remote account, got %d", deleted)
 	}
 
-	// Verify admin query includes the first-time login user
-	foundNewUser := false
-	for _, acc := range *accountsAdmin {
-		if acc.Id == user3Id {
-			foundNewUser = true
-			if acc.FirstTimeLogin != 1 {
-				t.Errorf("User3 should have first_time_login = 1, got %d", acc.FirstTimeLogin)
-			}
+	for name, acc := range remote {
+		_, err := db.ReadRemoteAccountById(acc.Id)
+		if name == "orphan" && err == nil {
+			t.Error("Expected the unreferenced stale account to be deleted")
+		} else if name != "orphan" && err != nil {
+			t.Errorf("Expected %s to be kept, got: %v", name, err)
 		}
 	}
-	if !foundNewUser {
-		t.Errorf("ReadAllAccountsAdmin should include first-time login user")
-	}
 }
 
-func TestReadPublicNotesByUsername(t *testing.T) {
+func TestCreateLocalFollow(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.db.Close()
 
-	// Create user
-	userId := uuid.New()
-	createTestAccount(t, db, userId, "testuser", "pubkey", "webpub", "webpriv")
-
-	// Create notes with different visibility
-	publicNote1 := uuid.New()
-	publicNote2 := uuid.New()
-	privateNote := uuid.New()
+	// Create two local accounts
+	follower := uuid.New()
+	target := uuid.New()
+	createTestAccount(t, db, follower, "alice", "pubkey1", "webpub1", "webpriv1")
+	createTestAccount(t, db, target, "bob", "pubkey2", "webpub2", "webpriv2")
 
-	// Insert public notes
-	_, err := db.db.Exec("INSERT INTO notes (id, user_id, message, created_at, visibility) VALUES (?, ?, ?, ?, ?)",
-		publicNote1, userId.String(), "Public Note 1", time.Now().Add(-2*time.Hour), "public")
+	// Create local follow
+	err := db.CreateLocalFollow(follower, target)
 	if err != nil {
-		t.Fatalf("Failed to create public note 1: %v", err)
+		t.Fatalf("CreateLocalFollow failed: %v", err)
 	}
 
-	_, err = db.db.Exec("INSERT INTO notes (id, user_id, message, created_at, visibility) VALUES (?, ?, ?, ?, ?)",
-		publicNote2, userId.String(), "Public Note 2", time.Now().Add(-1*time.Hour), "public")
+	// Verify follow exists
+	isFollowing, err := db.IsFollowingLocal(follower, target)
 	if err != nil {
-		t.Fatalf("Failed to create public note 2: %v", err)
+		t.Fatalf("IsFollowingLocal failed: %v", err)
 	}
 
-	// Insert private note (should not appear in outbox)
-	_, err = db.db.Exec("INSERT INTO notes (id, user_id, message, created_at, visibility) VALUES (?, ?, ?, ?, ?)",
-		privateNote, userId.String(), "Private Note", time.Now(), "followers")
-	if err != nil {
-		t.Fatalf("Failed to create private note: %v", err)
+	if !isFollowing {
+		t.Error("Expected isFollowing to be true")
 	}
+}
 
-	// Test: Should return only public notes
-	err, notes := db.ReadPublicNotesByUsername("testuser", 10, 0)
-	if err != nil {
-		t.Fatalf("ReadPublicNotesByUsername failed: %v", err)
-	}
+func TestDeleteLocalFollow(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
 
-	if len(*notes) != 2 {
-		t.Errorf("Expected 2 public notes, got %d", len(*notes))
-	}
+	// Create two local accounts
+	follower := uuid.New()
+	target := uuid.New()
+	createTestAccount(t, db, follower, "alice", "pubkey1", "webpub1", "webpriv1")
+	createTestAccount(t, db, target, "bob", "pubkey2", "webpub2", "webpriv2")
 
-	// Verify notes are ordered by created_at DESC (newest first)
-	if len(*notes) >= 2 {
-		if (*notes)[0].CreatedAt.Before((*notes)[1].CreatedAt) {
-			t.Errorf("Notes should be ordered by created_at DESC")
-		}
-	}
+	// Create and then delete follow
+	db.CreateLocalFollow(follower, target)
 
-	// Test: Pagination with limit
-	err, notesPage1 := db.ReadPublicNotesByUsername("testuser", 1, 0)
+	err := db.DeleteLocalFollow(follower, target)
 	if err != nil {
-		t.Fatalf("ReadPublicNotesByUsername with limit failed: %v", err)
-	}
-	if len(*notesPage1) != 1 {
-		t.Errorf("Expected 1 note with limit=1, got %d", len(*notesPage1))
+		t.Fatalf("DeleteLocalFollow failed: %v", err)
 	}
 
-	// Test: Pagination with offset
-	err, notesPage2 := db.ReadPublicNotesByUsername("testuser", 1, 1)
+	// Verify follow doesn't exist
+	isFollowing, err := db.IsFollowingLocal(follower, target)
 	if err != nil {
-		t.Fatalf("ReadPublicNotesByUsername with offset failed: %v", err)
-	}
-	if len(*notesPage2) != 1 {
-		t.Errorf("Expected 1 note with offset=1, got %d", len(*notesPage2))
-	}
-
-	// Verify pages return different notes
-	if len(*notesPage1) > 0 && len(*notesPage2) > 0 {
-		if (*notesPage1)[0].Id == (*notesPage2)[0].Id {
-			t.Errorf("Pagination should return different notes")
-		}
+		t.Fatalf("IsFollowingLocal failed: %v", err)
 	}
 
-	// Test: Non-existent user
-	err, notesNone := db.ReadPublicNotesByUsername("nonexistent", 10, 0)
-	if err != nil {
-		t.Fatalf("ReadPublicNotesByUsername for non-existent user should not error: %v", err)
-	}
-	if len(*notesNone) != 0 {
-		t.Errorf("Expected 0 notes for non-existent user, got %d", len(*notesNone))
+	if isFollowing {
+		t.Error("Expected isFollowing to be false after deletion")
 	}
 }
 
-func TestCountAccounts(t *testing.T) {
+func TestCreateActivity(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.db.Close()
 
-	// Test: Empty database
-	count, err := db.CountAccounts()
-	if err != nil {
-		t.Fatalf("CountAccounts failed: %v", err)
-	}
-	if count != 0 {
-		t.Errorf("Expected 0 accounts in empty database, got %d", count)
+	activity := &domain.Activity{
+		Id:           uuid.New(),
+		ActivityURI:  "https://example.com/activities/123",
+		ActivityType: "Create",
+		ActorURI:     "https://example.com/users/bob",
+		ObjectURI:    "https://example.com/notes/456",
+		RawJSON:      `{"type":"Create"}`,
+		Processed:    false,
+		CreatedAt:    time.Now(),
+		Local:        false,
 	}
 
-	// Test: Add one account
-	userId1 := uuid.New()
-	createTestAccount(t, db, userId1, "user1", "pubkey1", "webpub1", "webpriv1")
-
-	count, err = db.CountAccounts()
+	err := db.CreateActivity(activity)
 	if err != nil {
-		t.Fatalf("CountAccounts failed: %v", err)
-	}
-	if count != 1 {
-		t.Errorf("Expected 1 account, got %d", count)
+		t.Fatalf("CreateActivity failed: %v", err)
 	}
 
-	// Test: Add second account
-	userId2 := uuid.New()
-	createTestAccount(t, db, userId2, "user2", "pubkey2", "webpub2", "webpriv2")
-
-	count, err = db.CountAccounts()
+	// Verify
+	act, err := db.ReadActivityByURI(activity.ActivityURI)
 	if err != nil {
-		t.Fatalf("CountAccounts failed: %v", err)
+		t.Fatalf("ReadActivityByURI failed: %v", err)
 	}
-	if count != 2 {
-		t.Errorf("Expected 2 accounts, got %d", count)
+
+	if act.ActivityType != activity.ActivityType {
+		t.Errorf("Expected ActivityType %s, got %s", activity.ActivityType, act.ActivityType)
 	}
+}
 
-	// Test: Add third account
-	userId3 := uuid.New()
-	createTestAccount(t, db, userId3, "user3", "pubkey3", "webpub3", "webpriv3")
+func TestReadLocalTimelineNotes(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
 
-	count, err = db.CountAccounts()
+	// Create user and notes
+	userId := uuid.New()
+	createTestAccount(t, db, userId, "alice", "pubkey", "webpub", "webpriv")
+
+	// Create some notes
+	for i := range 5 {
+		db.CreateNote(userId, "Note "+string(rune('A'+i)))
+	}
+
+	// Read timeline with limit
+	notes, err := db.ReadLocalTimelineNotes(userId, 3)
 	if err != nil {
-		t.Fatalf("CountAccounts failed: %v", err)
+		t.Fatalf("ReadLocalTimelineNotes failed: %v", err)
 	}
-	if count != 3 {
-		t.Errorf("Expected 3 accounts, got %d", count)
+
+	if len(*notes) != 3 {
+		t.Errorf("Expected 3 notes (limited), got %d", len(*notes))
 	}
 }
 
-func TestUpdateLoginById_UsernameUniqueness(t *testing.T) {
+func TestDeleteAccount(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.db.Close()
 
-	// Create two test accounts
-	userId1 := uuid.New()
-	userId2 := uuid.New()
-	createTestAccount(t, db, userId1, "alice", "pubkey1", "webpub1", "webpriv1")
-	createTestAccount(t, db, userId2, "bob", "pubkey2", "webpub2", "webpriv2")
+	// Create a test account
+	userId := uuid.New()
+	createTestAccount(t, db, userId, "alice", "pubkey", "webpub", "webpriv")
 
-	// Test 1: Update user's own username (should succeed)
-	err := db.UpdateLoginById("alice_updated", "Alice Updated", "Bio", userId1)
+	// Create notes for the user
+	_, err := db.CreateNote(userId, "Test note 1")
 	if err != nil {
-		t.Errorf("UpdateLoginById should succeed when updating own username: %v", err)
+		t.Fatalf("Failed to create note 1: %v", err)
+	}
+	_, err = db.CreateNote(userId, "Test note 2")
+	if err != nil {
+		t.Fatalf("Failed to create note 2: %v", err)
 	}
 
-	// Verify update worked
-	err, acc := db.ReadAccByUsername("alice_updated")
+	// Create a second user for follow relationships
+	user2Id := uuid.New()
+	createTestAccount(t, db, user2Id, "bob", "pubkey2", "webpub2", "webpriv2")
+
+	// Create local follow relationships (alice follows bob, bob follows alice)
+	err = db.CreateLocalFollow(userId, user2Id)
 	if err != nil {
-		t.Fatalf("ReadAccByUsername failed: %v", err)
+		t.Fatalf("Failed to create follow: %v", err)
 	}
-	if acc == nil {
-		t.Fatal("Expected to find updated account")
+	err = db.CreateLocalFollow(user2Id, userId)
+	if err != nil {
+		t.Fatalf("Failed to create reverse follow: %v", err)
 	}
-	if acc.DisplayName != "Alice Updated" {
-		t.Errorf("Expected display name 'Alice Updated', got '%s'", acc.DisplayName)
+
+	// Create an activity for the user
+	activity := &domain.Activity{
+		Id:           uuid.New(),
+		ActivityURI:  "https://example.com/activities/alice",
+		ActivityType: "Create",
+		ActorURI:     "https://example.com/users/alice",
+		ObjectURI:    "https://example.com/notes/123",
+		RawJSON:      `{"type":"Create"}`,
+		Processed:    true,
+		CreatedAt:    time.Now(),
+		Local:        true,
+	}
+	err = db.CreateActivity(activity)
+	if err != nil {
+		t.Fatalf("Failed to create activity: %v", err)
 	}
 
-	// Test 2: Try to update to an existing username (should fail)
-	err = db.UpdateLoginById("bob", "Alice Trying Bob", "Bio", userId1)
-	if err == nil {
-		t.Error("UpdateLoginById should fail when username is already taken by another user")
+	// Verify data exists before deletion
+	acc, err := db.ReadAccById(userId)
+	if err != nil || acc == nil {
+		t.Fatalf("Account should exist before deletion")
 	}
-	if err != nil && err.Error() != "username 'bob' is already taken" {
-		t.Errorf("Expected error message 'username 'bob' is already taken', got '%s'", err.Error())
+
+	notes, err := db.ReadNotesByUserId(userId)
+	if err != nil || len(*notes) != 2 {
+		t.Fatalf("Expected 2 notes before deletion, got %d", len(*notes))
 	}
 
-	// Test 3: Verify first user's username wasn't changed after failed update
-	err, acc = db.ReadAccByUsername("alice_updated")
+	// Delete the account
+	err = db.DeleteAccount(userId)
 	if err != nil {
-		t.Fatalf("ReadAccByUsername failed: %v", err)
+		t.Fatalf("DeleteAccount failed: %v", err)
 	}
-	if acc == nil {
-		t.Fatal("Expected original account to still exist")
+
+	// Verify account was deleted
+	acc, err = db.ReadAccById(userId)
+	if err != sql.ErrNoRows {
+		t.Errorf("Account should not exist after deletion, got: %v", acc)
 	}
 
-	// Test 4: Update display name and bio without changing username (should succeed)
-	err = db.UpdateLoginById("alice_updated", "Alice New Display", "New Bio", userId1)
-	if err != nil {
-		t.Errorf("UpdateLoginById should succeed when keeping same username: %v", err)
+	// Verify notes were deleted
+	notes, err = db.ReadNotesByUserId(userId)
+	if err != nil || len(*notes) != 0 {
+		t.Errorf("Expected 0 notes after deletion, got %d", len(*notes))
 	}
 
-	// Verify update worked
-	err, acc = db.ReadAccByUsername("alice_updated")
-	if err != nil {
-		t.Fatalf("ReadAccByUsername failed: %v", err)
+	// Verify follows were deleted (both directions)
+	following, err := db.ReadFollowingByAccountId(userId)
+	if err != nil || len(*following) != 0 {
+		t.Errorf("Expected 0 following relationships after deletion, got %d", len(*following))
 	}
-	if acc.DisplayName != "Alice New Display" {
-		t.Errorf("Expected display name 'Alice New Display', got '%s'", acc.DisplayName)
+
+	followers, err := db.ReadFollowersByAccountId(userId)
+	if err != nil || len(*followers) != 0 {
+		t.Errorf("Expected 0 follower relationships after deletion, got %d", len(*followers))
 	}
-	if acc.Summary != "New Bio" {
-		t.Errorf("Expected summary 'New Bio', got '%s'", acc.Summary)
+
+	// Note: Activities are NOT deleted (they remain as historical record)
+	// This matches ActivityPub behavior
+
+	// Verify bob's account still exists (shouldn't be affected)
+	bob, err := db.ReadAccById(user2Id)
+	if err != nil || bob == nil {
+		t.Errorf("Bob's account should still exist after alice deletion")
 	}
 }
 
-func TestUpdateLoginById_NonExistentUser(t *testing.T) {
+func TestDeleteAccountNonExistent(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.db.Close()
 
-	// Create one test account
-	userId1 := uuid.New()
-	createTestAccount(t, db, userId1, "alice", "pubkey1", "webpub1", "webpriv1")
-
-	// Try to update a non-existent user
+	// Try to delete a non-existent account
 	nonExistentId := uuid.New()
-	err := db.UpdateLoginById("newusername", "Display", "Bio", nonExistentId)
-	// This should not error because UPDATE will just affect 0 rows
-	// But the username check should pass since nobody has "newusername"
+	err := db.DeleteAccount(nonExistentId)
+	// Should not error even if account doesn't exist (idempotent delete)
 	if err != nil {
-		t.Errorf("UpdateLoginById with non-existent user should not error: %v", err)
+		t.Errorf("Deleting non-existent account should not error: %v", err)
 	}
 }
 
-func TestUpdateLoginById_CaseInsensitiveUsername(t *testing.T) {
+func TestDeleteAccountWithNoData(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.db.Close()
 
-	// Create two test accounts
-	userId1 := uuid.New()
-	userId2 := uuid.New()
-	createTestAccount(t, db, userId1, "alice", "pubkey1", "webpub1", "webpriv1")
-	createTestAccount(t, db, userId2, "bob", "pubkey2", "webpub2", "webpriv2")
-
-	// SQLite UNIQUE constraint is case-insensitive by default
-	// Try to update to "ALICE" (different case but same username)
-	err := db.UpdateLoginById("Alice", "Alice Upper", "Bio", userId2)
+	// Create a user with no notes, follows, activities, etc.
+	userId := uuid.New()
+	createTestAccount(t, db, userId, "lonely", "pubkey", "webpub", "webpriv")
 
-	// This might fail or succeed depending on SQLite collation settings
-	// For this test, we just verify that if it fails, it's a constraint error
+	// Delete the account
+	err := db.DeleteAccount(userId)
 	if err != nil {
-		// Should fail with constraint error or our custom error
-		t.Logf("Case-insensitive check result: %v", err)
+		t.Fatalf("DeleteAccount failed for account with no data: %v", err)
+	}
+
+	// Verify account was deleted
+	acc, err := db.ReadAccById(userId)
+	if err != sql.ErrNoRows {
+		t.Errorf("Account should not exist after deletion, got: %v", acc)
 	}
 }
 
-// Tests for duplicate follow prevention
+// Admin functionality tests
 
-func TestReadFollowByAccountIds(t *testing.T) {
+func TestFirstUserIsAdmin(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.db.Close()
 
-	// Create test accounts
-	followerId := uuid.New()
-	targetId := uuid.New()
-	createTestAccount(t, db, followerId, "alice", "pubkey1", "webpub1", "webpriv1")
-	createTestAccount(t, db, targetId, "bob", "pubkey2", "webpub2", "webpriv2")
+	// Create first user
+	user1Id := uuid.New()
+	_, err := db.db.Exec(sqlInsertUser, user1Id, "firstuser", "hash1", "webpub1", "webpriv1", time.Now())
+	if err != nil {
+		t.Fatalf("Failed to insert first user: %v", err)
+	}
 
-	// Create a follow relationship
-	follow := &domain.Follow{
-		Id:              uuid.New(),
-		AccountId:       followerId,
-		TargetAccountId: targetId,
-		URI:             "https://example.com/follows/123",
-		Accepted:        true,
-		CreatedAt:       time.Now(),
+	// Set is_admin for first user
+	_, err = db.db.Exec("UPDATE accounts SET is_admin = 1 WHERE id = ?", user1Id.String())
+	if err != nil {
+		t.Fatalf("Failed to set is_admin: %v", err)
 	}
-	err := db.CreateFollow(follow)
+
+	// Create second user
+	user2Id := uuid.New()
+	_, err = db.db.Exec(sqlInsertUser, user2Id, "seconduser", "hash2", "webpub2", "webpriv2", time.Now())
 	if err != nil {
-		t.Fatalf("Failed to create follow: %v", err)
+		t.Fatalf("Failed to insert second user: %v", err)
 	}
 
-	// Test: Read existing follow relationship
-	err, existingFollow := db.ReadFollowByAccountIds(followerId, targetId)
+	// Verify first user is admin
+	acc1, err := db.ReadAccById(user1Id)
 	if err != nil {
-		t.Fatalf("ReadFollowByAccountIds failed: %v", err)
+		t.Fatalf("ReadAccById failed for first user: %v", err)
 	}
-	if existingFollow == nil {
-		t.Fatal("Expected to find follow relationship but got nil")
+	if !acc1.IsAdmin {
+		t.Errorf("First user should be admin, got IsAdmin = %v", acc1.IsAdmin)
 	}
-	if existingFollow.AccountId != followerId {
-		t.Errorf("Expected follower ID %s, got %s", followerId, existingFollow.AccountId)
+
+	// Verify second user is not admin
+	acc2, err := db.ReadAccById(user2Id)
+	if err != nil {
+		t.Fatalf("ReadAccById failed for second user: %v", err)
 	}
-	if existingFollow.TargetAccountId != targetId {
-		t.Errorf("Expected target ID %s, got %s", targetId, existingFollow.TargetAccountId)
+	if acc2.IsAdmin {
+		t.Errorf("Second user should not be admin, got IsAdmin = %v", acc2.IsAdmin)
 	}
+}
 
-	// Test: Read non-existent follow relationship
-	nonExistentId := uuid.New()
-	err, notFound := db.ReadFollowByAccountIds(followerId, nonExistentId)
-	if err != sql.ErrNoRows {
-		t.Errorf("Expected sql.ErrNoRows for non-existent follow, got: %v", err)
+func TestRegistrationApproval(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	conf := &util.AppConfig{}
+	conf.Conf.RegistrationPolicy = util.RegistrationPolicyApproval
+	db.SetConfig(conf)
+
+	keys := &util.RsaKeyPair{Public: "webpub", Private: "webpriv"}
+	for _, username := range []string{"first", "second", "third"} {
+		if err := db.wrapTransaction(func(tx *sql.Tx) error {
+			return db.insertUser(tx, username, "key-"+username, keys)
+		}); err != nil {
+			t.Fatalf("insertUser %s failed: %v", username, err)
+		}
 	}
-	if notFound != nil {
-		t.Error("Expected nil for non-existent follow relationship")
+
+	// The first user becomes admin right away, everyone else waits for approval
+	first, _ := db.ReadAccByUsername("first")
+	second, _ := db.ReadAccByUsername("second")
+	third, _ := db.ReadAccByUsername("third")
+	if first.Pending || !first.IsAdmin {
+		t.Errorf("Expected the first user to be an approved admin, got %+v", first)
+	}
+	if !second.Pending || !third.Pending {
+		t.Fatalf("Expected later users to be pending, got %v and %v", second.Pending, third.Pending)
 	}
 
-	// Test: Read pending (not yet accepted) follow relationship
-	pendingTargetId := uuid.New()
-	createTestAccount(t, db, pendingTargetId, "charlie", "pubkey3", "webpub3", "webpriv3")
-	pendingFollow := &domain.Follow{
-		Id:              uuid.New(),
-		AccountId:       followerId,
-		TargetAccountId: pendingTargetId,
-		URI:             "https://example.com/follows/456",
-		Accepted:        false, // Pending, not yet accepted
-		CreatedAt:       time.Now(),
+	if err := db.ApproveAccount(second.Id); err != nil {
+		t.Fatalf("ApproveAccount failed: %v", err)
 	}
-	err = db.CreateFollow(pendingFollow)
-	if err != nil {
-		t.Fatalf("Failed to create pending follow: %v", err)
+	if acc, _ := db.ReadAccById(second.Id); acc.Pending {
+		t.Error("Expected the approved account not to be pending")
+	}
+	if err := db.ApproveAccount(second.Id); !errors.Is(err, ErrAccountNotPending) {
+		t.Errorf("Expected ErrAccountNotPending approving twice, got %v", err)
 	}
 
-	// Should find pending follow (even though accepted = false)
-	err, foundPending := db.ReadFollowByAccountIds(followerId, pendingTargetId)
-	if err != nil {
-		t.Fatalf("ReadFollowByAccountIds failed to find pending follow: %v", err)
+	if err := db.DenyAccount(third.Id); err != nil {
+		t.Fatalf("DenyAccount failed: %v", err)
 	}
-	if foundPending == nil {
-		t.Fatal("Expected to find pending follow relationship but got nil")
+	if _, err := db.ReadAccById(third.Id); err != sql.ErrNoRows {
+		t.Errorf("Expected the denied account to be deleted, got %v", err)
 	}
-	if foundPending.Accepted {
-		t.Error("Expected pending follow to have Accepted=false")
+	if err := db.DenyAccount(first.Id); !errors.Is(err, ErrAccountNotPending) {
+		t.Errorf("Expected approved accounts not to be denied, got %v", err)
 	}
 }
 
-func TestCreateFollow_DuplicatePrevention(t *testing.T) {
+func TestMuteUser(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.db.Close()
 
-	// Create test accounts
-	followerId := uuid.New()
-	targetId := uuid.New()
-	createTestAccount(t, db, followerId, "alice", "pubkey1", "webpub1", "webpriv1")
-	createTestAccount(t, db, targetId, "bob", "pubkey2", "webpub2", "webpriv2")
+	// Create user with notes
+	userId := uuid.New()
+	createTestAccount(t, db, userId, "testuser", "pubkey", "webpub", "webpriv")
 
-	// Create first follow relationship (pending)
-	follow1 := &domain.Follow{
-		Id:              uuid.New(),
-		AccountId:       followerId,
-		TargetAccountId: targetId,
-		URI:             "https://example.com/follows/123",
-		Accepted:        false, // Pending follow
-		CreatedAt:       time.Now(),
-	}
-	err := db.CreateFollow(follow1)
+	// Create some notes
+	noteId1 := uuid.New()
+	noteId2 := uuid.New()
+	_, err := db.db.Exec("INSERT INTO notes (id, user_id, message, created_at) VALUES (?, ?, ?, ?)",
+		noteId1, userId.String(), "Note 1", time.Now())
 	if err != nil {
-		t.Fatalf("Failed to create first follow: %v", err)
+		t.Fatalf("Failed to create note 1: %v", err)
 	}
-
-	// Verify ReadFollowByAccountIds can detect the pending follow
-	err, existingFollow := db.ReadFollowByAccountIds(followerId, targetId)
+	_, err = db.db.Exec("INSERT INTO notes (id, user_id, message, created_at) VALUES (?, ?, ?, ?)",
+		noteId2, userId.String(), "Note 2", time.Now())
 	if err != nil {
-		t.Fatalf("ReadFollowByAccountIds should find pending follow: %v", err)
+		t.Fatalf("Failed to create note 2: %v", err)
 	}
-	if existingFollow == nil {
-		t.Fatal("Pending follow should be found by ReadFollowByAccountIds")
+
+	// Verify notes exist
+	notes, err := db.ReadNotesByUserId(userId)
+	if err != nil || len(*notes) != 2 {
+		t.Fatalf("Expected 2 notes before mute, got %d", len(*notes))
 	}
-	if existingFollow.Accepted {
-		t.Error("Expected pending follow to have Accepted=false")
+
+	// Mute the user
+	err = db.MuteUser(userId)
+	if err != nil {
+		t.Fatalf("MuteUser failed: %v", err)
 	}
 
-	// Test: Attempting to create duplicate follow should fail with UNIQUE constraint error
-	follow2 := &domain.Follow{
-		Id:              uuid.New(),
-		AccountId:       followerId,
-		TargetAccountId: targetId,
-		URI:             "https://example.com/follows/456",
-		Accepted:        false,
-		CreatedAt:       time.Now(),
+	// Verify user is muted
+	acc, err := db.ReadAccById(userId)
+	if err != nil {
+		t.Fatalf("ReadAccById failed: %v", err)
 	}
-	err = db.CreateFollow(follow2)
-	if err == nil {
-		t.Fatal("Expected UNIQUE constraint error when creating duplicate follow")
+	if !acc.Muted {
+		t.Errorf("User should be muted, got Muted = %v", acc.Muted)
 	}
-	if !strings.Contains(err.Error(), "UNIQUE") && !strings.Contains(err.Error(), "constraint") {
-		t.Errorf("Expected UNIQUE constraint error, got: %v", err)
+
+	// Verify notes were deleted
+	notes, err = db.ReadNotesByUserId(userId)
+	if err != nil || len(*notes) != 0 {
+		t.Errorf("Expected 0 notes after mute, got %d", len(*notes))
 	}
 }
 
-func TestFollowersListNoDuplicates(t *testing.T) {
+func TestDisableAccount(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.db.Close()
 
-	// Create test accounts
-	follower1Id := uuid.New()
-	follower2Id := uuid.New()
-	targetId := uuid.New()
-	createTestAccount(t, db, follower1Id, "alice", "pubkey1", "webpub1", "webpriv1")
-	createTestAccount(t, db, follower2Id, "charlie", "pubkey2", "webpub2", "webpriv2")
-	createTestAccount(t, db, targetId, "bob", "pubkey3", "webpub3", "webpriv3")
-
-	// Create two different followers for the same target
-	follow1 := &domain.Follow{
-		Id:              uuid.New(),
-		AccountId:       follower1Id,
-		TargetAccountId: targetId,
-		URI:             "https://example.com/follows/1",
-		Accepted:        true,
-		IsLocal:         true, // Local follow between local accounts
-		CreatedAt:       time.Now(),
-	}
-	err := db.CreateFollow(follow1)
+	userId := uuid.New()
+	createTestAccount(t, db, userId, "testuser", "pubkey", "webpub", "webpriv")
+	_, err := db.db.Exec("INSERT INTO notes (id, user_id, message, created_at) VALUES (?, ?, ?, ?)",
+		uuid.New(), userId.String(), "Note 1", time.Now())
 	if err != nil {
-		t.Fatalf("Failed to create follow1: %v", err)
+		t.Fatalf("Failed to create note: %v", err)
 	}
 
-	follow2 := &domain.Follow{
-		Id:              uuid.New(),
-		AccountId:       follower2Id,
-		TargetAccountId: targetId,
-		URI:             "https://example.com/follows/2",
-		Accepted:        true,
-		IsLocal:         true, // Local follow between local accounts
-		CreatedAt:       time.Now(),
-	}
-	err = db.CreateFollow(follow2)
-	if err != nil {
-		t.Fatalf("Failed to create follow2: %v", err)
+	if err := db.DisableAccount(userId); err != nil {
+		t.Fatalf("DisableAccount failed: %v", err)
 	}
-
-	// Read followers
-	err, followers := db.ReadFollowersByAccountId(targetId)
+	acc, err := db.ReadAccByUsername("testuser")
 	if err != nil {
-		t.Fatalf("Failed to read followers: %v", err)
+		t.Fatalf("ReadAccByUsername failed: %v", err)
 	}
-	if followers == nil {
-		t.Fatal("Followers list should not be nil")
+	if !acc.Disabled {
+		t.Error("User should be disabled")
 	}
 
-	// Should have exactly 2 followers
-	if len(*followers) != 2 {
-		t.Errorf("Expected 2 followers, got %d", len(*followers))
+	// Unlike muting, disabling keeps the user's posts
+	notes, err := db.ReadNotesByUserId(userId)
+	if err != nil || len(*notes) != 1 {
+		t.Errorf("Expected the note to be kept, got %v, %v", notes, err)
 	}
 
-	// Verify no duplicates by checking unique AccountIds
-	seenFollowers := make(map[uuid.UUID]int)
-	for _, f := range *followers {
-		seenFollowers[f.AccountId]++
-		if seenFollowers[f.AccountId] > 1 {
-			t.Errorf("Duplicate follower detected: %s appears %d times", f.AccountId, seenFollowers[f.AccountId])
-		}
+	if err := db.EnableAccount(userId); err != nil {
+		t.Fatalf("EnableAccount failed: %v", err)
+	}
+	if acc, _ := db.ReadAccById(userId); acc.Disabled {
+		t.Error("User should be enabled again")
 	}
 }
 
-func TestCountLocalPosts(t *testing.T) {
+func TestUnmuteUser(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.db.Close()
 
-	// Test: Empty database
-	count, err := db.CountLocalPosts()
-	if err != nil {
-		t.Fatalf("CountLocalPosts failed: %v", err)
-	}
-	if count != 0 {
-		t.Errorf("Expected 0 posts in empty database, got %d", count)
-	}
-
-	// Test: Add user and notes
+	// Create user
 	userId := uuid.New()
-	createTestAccount(t, db, userId, "user1", "pubkey1", "webpub1", "webpriv1")
-
-	// Add first note
-	_, err = db.CreateNote(userId, "First note")
-	if err != nil {
-		t.Fatalf("Failed to create note: %v", err)
-	}
-
-	count, err = db.CountLocalPosts()
-	if err != nil {
-		t.Fatalf("CountLocalPosts failed: %v", err)
-	}
-	if count != 1 {
-		t.Errorf("Expected 1 post, got %d", count)
-	}
+	createTestAccount(t, db, userId, "testuser", "pubkey", "webpub", "webpriv")
 
-	// Add second note
-	_, err = db.CreateNote(userId, "Second note")
+	// Mute the user
+	err := db.MuteUser(userId)
 	if err != nil {
-		t.Fatalf("Failed to create note: %v", err)
+		t.Fatalf("MuteUser failed: %v", err)
 	}
 
-	count, err = db.CountLocalPosts()
+	// Verify user is muted
+	acc, err := db.ReadAccById(userId)
 	if err != nil {
-		t.Fatalf("CountLocalPosts failed: %v", err)
+		t.Fatalf("ReadAccById failed: %v", err)
 	}
-	if count != 2 {
-		t.Errorf("Expected 2 posts, got %d", count)
+	if !acc.Muted {
+		t.Fatalf("User should be muted")
 	}
 
-	// Add third note from same user
-	_, err = db.CreateNote(userId, "Third note")
+	// Unmute the user
+	err = db.UnmuteUser(userId)
 	if err != nil {
-		t.Fatalf("Failed to create note: %v", err)
+		t.Fatalf("UnmuteUser failed: %v", err)
 	}
 
-	count, err = db.CountLocalPosts()
+	// Verify user is not muted
+	acc, err = db.ReadAccById(userId)
 	if err != nil {
-		t.Fatalf("CountLocalPosts failed: %v", err)
+		t.Fatalf("ReadAccById failed: %v", err)
 	}
-	if count != 3 {
-		t.Errorf("Expected 3 posts, got %d", count)
+	if acc.Muted {
+		t.Errorf("User should not be muted after unmute, got Muted = %v", acc.Muted)
 	}
 }
 
-func TestCountActiveUsersMonth(t *testing.T) {
+func TestReadAllAccountsAdmin(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.db.Close()
 
-	// Test: Empty database
-	count, err := db.CountActiveUsersMonth()
-	if err != nil {
-		t.Fatalf("CountActiveUsersMonth failed: %v", err)
-	}
-	if count != 0 {
-		t.Errorf("Expected 0 active users in empty database, got %d", count)
-	}
+	// Create users with different first_time_login states
+	user1Id := uuid.New()
+	user2Id := uuid.New()
+	user3Id := uuid.New()
 
-	// Test: Add users and notes
-	userId1 := uuid.New()
-	userId2 := uuid.New()
-	createTestAccount(t, db, userId1, "user1", "pubkey1", "webpub1", "webpriv1")
-	createTestAccount(t, db, userId2, "user2", "pubkey2", "webpub2", "webpriv2")
+	createTestAccount(t, db, user1Id, "user1", "pubkey1", "webpub1", "webpriv1")
+	createTestAccount(t, db, user2Id, "user2", "pubkey2", "webpub2", "webpriv2")
+	createTestAccount(t, db, user3Id, "user3", "pubkey3", "webpub3", "webpriv3")
 
-	// User 1 posts (should be counted as active)
-	_, err = db.CreateNote(userId1, "Note from user1")
+	// Set first_time_login = 0 for user1 and user2 (completed registration)
+	_, err := db.db.Exec("UPDATE accounts SET first_time_login = 0 WHERE id IN (?, ?)", user1Id.String(), user2Id.String())
 	if err != nil {
-		t.Fatalf("Failed to create note: %v", err)
+		t.Fatalf("Failed to update first_time_login: %v", err)
 	}
 
-	count, err = db.CountActiveUsersMonth()
+	// user3 keeps first_time_login = 1 (default from sqlInsertUser)
+
+	// ReadAllAccounts should only return users with first_time_login = 0
+	accounts, err := db.ReadAllAccounts()
 	if err != nil {
-		t.Fatalf("CountActiveUsersMonth failed: %v", err)
+		t.Fatalf("ReadAllAccounts failed: %v", err)
 	}
-	if count != 1 {
-		t.Errorf("Expected 1 active user, got %d", count)
+	if len(*accounts) != 2 {
+		t.Errorf("ReadAllAccounts: expected 2 users, got %d", len(*accounts))
 	}
 
-	// User 2 posts (should be counted as active)
-	_, err = db.CreateNote(userId2, "Note from user2")
+	// ReadAllAccountsAdmin should return ALL users
+	accountsAdmin, err := db.ReadAllAccountsAdmin()
 	if err != nil {
-		t.Fatalf("Failed to create note: %v", err)
+		t.Fatalf("ReadAllAccountsAdmin failed: %v", err)
+	}
+	if len(*accountsAdmin) != 3 {
+		t.Errorf("ReadAllAccountsAdmin: expected 3 users, got %d", len(*accountsAdmin))
 	}
 
-	count, err = db.CountActiveUsersMonth()
-	if err != nil {
-		t.Fatalf("CountActiveUsersMonth failed: %v", err)
+	// Verify admin query includes the first-time login user
+	foundNewUser := false
+	for _, acc := range *accountsAdmin {
+		if acc.Id == user3Id {
+			foundNewUser = true
+			if acc.FirstTimeLogin != 1 {
+				t.Errorf("User3 should have first_time_login = 1, got %d", acc.FirstTimeLogin)
+			}
+		}
 	}
-	if count != 2 {
-		t.Errorf("Expected 2 active users, got %d", count)
+	if !foundNewUser {
+		t.Errorf("ReadAllAccountsAdmin should include first-time login user")
 	}
+}
 
-	// User 1 posts again (should still be 2 unique users)
-	_, err = db.CreateNote(userId1, "Another note from user1")
-	if err != nil {
-		t.Fatalf("Failed to create note: %v", err)
-	}
-
-	count, err = db.CountActiveUsersMonth()
-	if err != nil {
-		t.Fatalf("CountActiveUsersMonth failed: %v", err)
-	}
-	if count != 2 {
-		t.Errorf("Expected 2 active users (should count distinct users), got %d", count)
-	}
-}
-
-func TestCountActiveUsersHalfYear(t *testing.T) {
+func TestReadPublicNotesByUsername(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.db.Close()
 
-	// Test: Empty database
-	count, err := db.CountActiveUsersHalfYear()
+	// Create user
+	userId := uuid.New()
+	createTestAccount(t, db, userId, "testuser", "pubkey", "webpub", "webpriv")
+
+	// Create notes with different visibility
+	publicNote1 := uuid.New()
+	publicNote2 := uuid.New()
+	privateNote := uuid.New()
+
+	// Insert public notes
+	_, err := db.db.Exec("INSERT INTO notes (id, user_id, message, created_at, visibility) VALUES (?, ?, ?, ?, ?)",
+		publicNote1, userId.String(), "Public Note 1", time.Now().Add(-2*time.Hour), "public")
 	if err != nil {
-		t.Fatalf("CountActiveUsersHalfYear failed: %v", err)
-	}
-	if count != 0 {
-		t.Errorf("Expected 0 active users in empty database, got %d", count)
+		t.Fatalf("Failed to create public note 1: %v", err)
 	}
 
-	// Test: Add users and notes
-	userId1 := uuid.New()
-	userId2 := uuid.New()
-	userId3 := uuid.New()
-	createTestAccount(t, db, userId1, "user1", "pubkey1", "webpub1", "webpriv1")
-	createTestAccount(t, db, userId2, "user2", "pubkey2", "webpub2", "webpriv2")
-	createTestAccount(t, db, userId3, "user3", "pubkey3", "webpub3", "webpriv3")
+	_, err = db.db.Exec("INSERT INTO notes (id, user_id, message, created_at, visibility) VALUES (?, ?, ?, ?, ?)",
+		publicNote2, userId.String(), "Public Note 2", time.Now().Add(-1*time.Hour), "public")
+	if err != nil {
+		t.Fatalf("Failed to create public note 2: %v", err)
+	}
 
-	// User 1 posts
-	_, err = db.CreateNote(userId1, "Note from user1")
+	// Insert private note (should not appear in outbox)
+	_, err = db.db.Exec("INSERT INTO notes (id, user_id, message, created_at, visibility) VALUES (?, ?, ?, ?, ?)",
+		privateNote, userId.String(), "Private Note", time.Now(), "followers")
 	if err != nil {
-		t.Fatalf("Failed to create note: %v", err)
+		t.Fatalf("Failed to create private note: %v", err)
 	}
 
-	count, err = db.CountActiveUsersHalfYear()
+	// Test: Should return only public notes
+	notes, err := db.ReadPublicNotesByUsername("testuser", 10, 0)
 	if err != nil {
-		t.Fatalf("CountActiveUsersHalfYear failed: %v", err)
+		t.Fatalf("ReadPublicNotesByUsername failed: %v", err)
 	}
-	if count != 1 {
-		t.Errorf("Expected 1 active user, got %d", count)
+
+	if len(*notes) != 2 {
+		t.Errorf("Expected 2 public notes, got %d", len(*notes))
 	}
 
-	// User 2 posts
-	_, err = db.CreateNote(userId2, "Note from user2")
-	if err != nil {
-		t.Fatalf("Failed to create note: %v", err)
+	// Verify notes are ordered by created_at DESC (newest first)
+	if len(*notes) >= 2 {
+		if (*notes)[0].CreatedAt.Before((*notes)[1].CreatedAt) {
+			t.Errorf("Notes should be ordered by created_at DESC")
+		}
 	}
 
-	count, err = db.CountActiveUsersHalfYear()
+	// Test: Pagination with limit
+	notesPage1, err := db.ReadPublicNotesByUsername("testuser", 1, 0)
 	if err != nil {
-		t.Fatalf("CountActiveUsersHalfYear failed: %v", err)
+		t.Fatalf("ReadPublicNotesByUsername with limit failed: %v", err)
 	}
-	if count != 2 {
-		t.Errorf("Expected 2 active users, got %d", count)
+	if len(*notesPage1) != 1 {
+		t.Errorf("Expected 1 note with limit=1, got %d", len(*notesPage1))
 	}
 
-	// User 3 posts
-	_, err = db.CreateNote(userId3, "Note from user3")
+	// Test: Pagination with offset
+	notesPage2, err := db.ReadPublicNotesByUsername("testuser", 1, 1)
 	if err != nil {
-		t.Fatalf("Failed to create note: %v", err)
+		t.Fatalf("ReadPublicNotesByUsername with offset failed: %v", err)
+	}
+	if len(*notesPage2) != 1 {
+		t.Errorf("Expected 1 note with offset=1, got %d", len(*notesPage2))
 	}
 
-	count, err = db.CountActiveUsersHalfYear()
+	// Verify pages return different notes
+	if len(*notesPage1) > 0 && len(*notesPage2) > 0 {
+		if (*notesPage1)[0].Id == (*notesPage2)[0].Id {
+			t.Errorf("Pagination should return different notes")
+		}
+	}
+
+	// Test: Non-existent user
+	notesNone, err := db.ReadPublicNotesByUsername("nonexistent", 10, 0)
 	if err != nil {
-		t.Fatalf("CountActiveUsersHalfYear failed: %v", err)
+		t.Fatalf("ReadPublicNotesByUsername for non-existent user should not error: %v", err)
 	}
-	if count != 3 {
-		t.Errorf("Expected 3 active users, got %d", count)
+	if len(*notesNone) != 0 {
+		t.Errorf("Expected 0 notes for non-existent user, got %d", len(*notesNone))
 	}
 }
 
-func TestCountActiveUsers_Consistency(t *testing.T) {
+func TestCountAccounts(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.db.Close()
 
-	// Create users and have them post
-	userId1 := uuid.New()
-	userId2 := uuid.New()
-	createTestAccount(t, db, userId1, "user1", "pubkey1", "webpub1", "webpriv1")
-	createTestAccount(t, db, userId2, "user2", "pubkey2", "webpub2", "webpriv2")
-
-	_, err := db.CreateNote(userId1, "Note 1")
+	// Test: Empty database
+	count, err := db.CountAccounts()
 	if err != nil {
-		t.Fatalf("Failed to create note: %v", err)
+		t.Fatalf("CountAccounts failed: %v", err)
 	}
-	_, err = db.CreateNote(userId2, "Note 2")
-	if err != nil {
-		t.Fatalf("Failed to create note: %v", err)
+	if count != 0 {
+		t.Errorf("Expected 0 accounts in empty database, got %d", count)
 	}
 
-	// Get all counts
-	activeMonth, err := db.CountActiveUsersMonth()
-	if err != nil {
-		t.Fatalf("CountActiveUsersMonth failed: %v", err)
-	}
+	// Test: Add one account
+	userId1 := uuid.New()
+	createTestAccount(t, db, userId1, "user1", "pubkey1", "webpub1", "webpriv1")
 
-	activeHalfYear, err := db.CountActiveUsersHalfYear()
+	count, err = db.CountAccounts()
 	if err != nil {
-		t.Fatalf("CountActiveUsersHalfYear failed: %v", err)
+		t.Fatalf("CountAccounts failed: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 account, got %d", count)
 	}
 
-	totalUsers, err := db.CountAccounts()
+	// Test: Add second account
+	userId2 := uuid.New()
+	createTestAccount(t, db, userId2, "user2", "pubkey2", "webpub2", "webpriv2")
+
+	count, err = db.CountAccounts()
 	if err != nil {
 		t.Fatalf("CountAccounts failed: %v", err)
 	}
-
-	// Verify logical consistency
-	// ActiveMonth should not exceed ActiveHalfYear
-	if activeMonth > activeHalfYear {
-		t.Errorf("ActiveMonth (%d) should not exceed ActiveHalfYear (%d)", activeMonth, activeHalfYear)
+	if count != 2 {
+		t.Errorf("Expected 2 accounts, got %d", count)
 	}
 
-	// ActiveHalfYear should not exceed TotalUsers
-	if activeHalfYear > totalUsers {
-		t.Errorf("ActiveHalfYear (%d) should not exceed TotalUsers (%d)", activeHalfYear, totalUsers)
-	}
+	// Test: Add third account
+	userId3 := uuid.New()
+	createTestAccount(t, db, userId3, "user3", "pubkey3", "webpub3", "webpriv3")
 
-	// ActiveMonth should not exceed TotalUsers
-	if activeMonth > totalUsers {
-		t.Errorf("ActiveMonth (%d) should not exceed TotalUsers (%d)", activeMonth, totalUsers)
+	count, err = db.CountAccounts()
+	if err != nil {
+		t.Fatalf("CountAccounts failed: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("Expected 3 accounts, got %d", count)
 	}
 }
 
-// TestReadActivityByObjectURI_WildcardEscaping tests that SQL wildcards are properly escaped
-func TestReadActivityByObjectURI_WildcardEscaping(t *testing.T) {
+func TestUpdateLoginById_UsernameUniqueness(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.db.Close()
 
-	// Create activities with URIs containing wildcards
-	activities := []struct {
-		uri     string
-		content string
-	}{
-		{"https://example.com/posts/123", "Normal post"},
-		{"https://example.com/posts/12%", "Post with percent"},
-		{"https://example.com/posts/12_", "Post with underscore"},
-		{"https://example.com/posts/12\\", "Post with backslash"},
-	}
+	// Create two test accounts
+	userId1 := uuid.New()
+	userId2 := uuid.New()
+	createTestAccount(t, db, userId1, "alice", "pubkey1", "webpub1", "webpriv1")
+	createTestAccount(t, db, userId2, "bob", "pubkey2", "webpub2", "webpriv2")
 
-	for _, act := range activities {
-		activity := &domain.Activity{
-			Id:           uuid.New(),
-			ActivityURI:  "https://example.com/activities/" + uuid.New().String(),
-			ActivityType: "Create",
-			ActorURI:     "https://example.com/users/alice",
-			ObjectURI:    act.uri,
-			RawJSON:      `{"id":"` + act.uri + `","type":"Note","content":"` + act.content + `"}`,
-			Processed:    true,
-			Local:        false,
-			CreatedAt:    time.Now(),
-		}
-		if err := db.CreateActivity(activity); err != nil {
-			t.Fatalf("Failed to create activity: %v", err)
-		}
+	// Test 1: Update user's own username (should succeed)
+	err := db.UpdateLoginById("alice_updated", "Alice Updated", "Bio", userId1)
+	if err != nil {
+		t.Errorf("UpdateLoginById should succeed when updating own username: %v", err)
 	}
 
-	// Test exact match for URI with percent (should not match as wildcard)
-	err, found := db.ReadActivityByObjectURI("https://example.com/posts/12%")
+	// Verify update worked
+	acc, err := db.ReadAccByUsername("alice_updated")
 	if err != nil {
-		t.Fatalf("ReadActivityByObjectURI failed: %v", err)
+		t.Fatalf("ReadAccByUsername failed: %v", err)
 	}
-	if found == nil {
-		t.Fatal("Expected to find activity with percent in URI")
+	if acc == nil {
+		t.Fatal("Expected to find updated account")
 	}
-	if !strings.Contains(found.RawJSON, "Post with percent") {
-		t.Error("Found wrong activity - percent was treated as wildcard")
+	if acc.DisplayName != "Alice Updated" {
+		t.Errorf("Expected display name 'Alice Updated', got '%s'", acc.DisplayName)
 	}
 
-	// Test exact match for URI with underscore (should not match as wildcard)
-	err, found = db.ReadActivityByObjectURI("https://example.com/posts/12_")
+	// Test 2: Try to update to an existing username (should fail)
+	err = db.UpdateLoginById("bob", "Alice Trying Bob", "Bio", userId1)
+	if err == nil {
+		t.Error("UpdateLoginById should fail when username is already taken by another user")
+	}
+	if err != nil && err.Error() != "username 'bob' is already taken" {
+		t.Errorf("Expected error message 'username 'bob' is already taken', got '%s'", err.Error())
+	}
+
+	// Test 3: Verify first user's username wasn't changed after failed update
+	acc, err = db.ReadAccByUsername("alice_updated")
 	if err != nil {
-		t.Fatalf("ReadActivityByObjectURI failed: %v", err)
+		t.Fatalf("ReadAccByUsername failed: %v", err)
 	}
-	if found == nil {
-		t.Fatal("Expected to find activity with underscore in URI")
+	if acc == nil {
+		t.Fatal("Expected original account to still exist")
 	}
-	if !strings.Contains(found.RawJSON, "Post with underscore") {
-		t.Error("Found wrong activity - underscore was treated as wildcard")
+
+	// Test 4: Update display name and bio without changing username (should succeed)
+	err = db.UpdateLoginById("alice_updated", "Alice New Display", "New Bio", userId1)
+	if err != nil {
+		t.Errorf("UpdateLoginById should succeed when keeping same username: %v", err)
 	}
 
-	// Test exact match for URI with backslash
-	err, found = db.ReadActivityByObjectURI("https://example.com/posts/12\\")
+	// Verify update worked
+	acc, err = db.ReadAccByUsername("alice_updated")
 	if err != nil {
-		t.Fatalf("ReadActivityByObjectURI failed: %v", err)
+		t.Fatalf("ReadAccByUsername failed: %v", err)
 	}
-	if found == nil {
-		t.Fatal("Expected to find activity with backslash in URI")
+	if acc.DisplayName != "Alice New Display" {
+		t.Errorf("Expected display name 'Alice New Display', got '%s'", acc.DisplayName)
 	}
-	if !strings.Contains(found.RawJSON, "Post with backslash") {
-		t.Error("Found wrong activity - backslash escaping failed")
+	if acc.Summary != "New Bio" {
+		t.Errorf("Expected summary 'New Bio', got '%s'", acc.Summary)
 	}
 }
 
-// TestCleanupOrphanedFollows_BothDirections tests cleanup of orphaned follows
-func TestCleanupOrphanedFollows_BothDirections(t *testing.T) {
+func TestUpdateLoginById_NonExistentUser(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.db.Close()
 
-	// Create local account
-	localId := uuid.New()
-	createTestAccount(t, db, localId, "alice", "pubkey1", "webpub1", "webpriv1")
+	// Create one test account
+	userId1 := uuid.New()
+	createTestAccount(t, db, userId1, "alice", "pubkey1", "webpub1", "webpriv1")
 
-	// Create remote accounts
-	remote1Id := uuid.New()
-	remote2Id := uuid.New()
-	remoteAcc1 := &domain.RemoteAccount{
-		Id:            remote1Id,
+	// Try to update a non-existent user
+	nonExistentId := uuid.New()
+	err := db.UpdateLoginById("newusername", "Display", "Bio", nonExistentId)
+	// This should not error because UPDATE will just affect 0 rows
+	// But the username check should pass since nobody has "newusername"
+	if err != nil {
+		t.Errorf("UpdateLoginById with non-existent user should not error: %v", err)
+	}
+}
+
+func TestUpdateLoginById_CaseInsensitiveUsername(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	// Create two test accounts
+	userId1 := uuid.New()
+	userId2 := uuid.New()
+	createTestAccount(t, db, userId1, "alice", "pubkey1", "webpub1", "webpriv1")
+	createTestAccount(t, db, userId2, "bob", "pubkey2", "webpub2", "webpriv2")
+
+	// SQLite UNIQUE constraint is case-insensitive by default
+	// Try to update to "ALICE" (different case but same username)
+	err := db.UpdateLoginById("Alice", "Alice Upper", "Bio", userId2)
+
+	// This might fail or succeed depending on SQLite collation settings
+	// For this test, we just verify that if it fails, it's a constraint error
+	if err != nil {
+		// Should fail with constraint error or our custom error
+		t.Logf("Case-insensitive check result: %v", err)
+	}
+}
+
+// Tests for duplicate follow prevention
+
+func TestIsRemoteAccountFollowed(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	localId := uuid.New()
+	followedId := uuid.New()
+	pendingId := uuid.New()
+	for i, targetId := range []uuid.UUID{followedId, pendingId} {
+		follow := &domain.Follow{
+			Id:              uuid.New(),
+			AccountId:       localId,
+			TargetAccountId: targetId,
+			URI:             fmt.Sprintf("https://example.com/follows/%d", i),
+			Accepted:        targetId == followedId,
+			CreatedAt:       time.Now(),
+		}
+		if err := db.CreateFollow(follow); err != nil {
+			t.Fatalf("Failed to create follow: %v", err)
+		}
+	}
+
+	tests := map[uuid.UUID]bool{followedId: true, pendingId: false, uuid.New(): false}
+	for remoteId, expected := range tests {
+		followed, err := db.IsRemoteAccountFollowed(remoteId)
+		if err != nil {
+			t.Fatalf("IsRemoteAccountFollowed failed: %v", err)
+		}
+		if followed != expected {
+			t.Errorf("IsRemoteAccountFollowed(%s) = %v, want %v", remoteId, followed, expected)
+		}
+	}
+}
+
+func TestReadFollowingAddresses(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	aliceId := uuid.New()
+	carolId := uuid.New()
+	createTestAccount(t, db, aliceId, "alice", "pubkey1", "webpub1", "webpriv1")
+	createTestAccount(t, db, carolId, "carol", "pubkey2", "webpub2", "webpriv2")
+
+	bob := &domain.RemoteAccount{
+		Id:            uuid.New(),
 		Username:      "bob",
-		Domain:        "remote.com",
-		ActorURI:      "https://remote.com/users/bob",
-		InboxURI:      "https://remote.com/users/bob/inbox",
-		PublicKeyPem:  "pubkey2",
+		Domain:        "remote.example.com",
+		ActorURI:      "https://remote.example.com/users/bob",
+		InboxURI:      "https://remote.example.com/users/bob/inbox",
 		LastFetchedAt: time.Now(),
 	}
-	remoteAcc2 := &domain.RemoteAccount{
-		Id:            remote2Id,
-		Username:      "charlie",
-		Domain:        "remote.com",
-		ActorURI:      "https://remote.com/users/charlie",
-		InboxURI:      "https://remote.com/users/charlie/inbox",
-		PublicKeyPem:  "pubkey3",
-		LastFetchedAt: time.Now(),
+	if err := db.CreateRemoteAccount(bob); err != nil {
+		t.Fatalf("CreateRemoteAccount failed: %v", err)
 	}
-	if err := db.CreateRemoteAccount(remoteAcc1); err != nil {
-		t.Fatalf("Failed to create remote account 1: %v", err)
+	follow := &domain.Follow{
+		Id:              uuid.New(),
+		AccountId:       aliceId,
+		TargetAccountId: bob.Id,
+		URI:             "https://local.example.com/follows/bob",
+		CreatedAt:       time.Now().Add(-time.Hour),
 	}
-	if err := db.CreateRemoteAccount(remoteAcc2); err != nil {
-		t.Fatalf("Failed to create remote account 2: %v", err)
+	if err := db.CreateFollow(follow); err != nil {
+		t.Fatalf("Failed to create follow: %v", err)
+	}
+	if err := db.CreateLocalFollow(aliceId, carolId); err != nil {
+		t.Fatalf("CreateLocalFollow failed: %v", err)
 	}
 
-	// Create follows:
-	// 1. Local follows remote (following)
-	follow1 := &domain.Follow{
+	addresses, err := db.ReadFollowingAddresses(aliceId, "local.example.com")
+	if err != nil {
+		t.Fatalf("ReadFollowingAddresses failed: %v", err)
+	}
+	expected := []string{"bob@remote.example.com", "carol@local.example.com"}
+	if strings.Join(addresses, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected %v, got %v", expected, addresses)
+	}
+}
+
+func TestUpdateFollowNotify(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	followerId := uuid.New()
+	targetId := uuid.New()
+	createTestAccount(t, db, followerId, "alice", "pubkey1", "webpub1", "webpriv1")
+	createTestAccount(t, db, targetId, "bob", "pubkey2", "webpub2", "webpriv2")
+
+	follow := &domain.Follow{
 		Id:              uuid.New(),
-		AccountId:       localId,
-		TargetAccountId: remote1Id,
-		URI:             "https://example.com/follows/1",
+		AccountId:       followerId,
+		TargetAccountId: targetId,
+		URI:             "https://example.com/follows/notify",
 		Accepted:        true,
-		IsLocal:         false,
+		IsLocal:         true,
 		CreatedAt:       time.Now(),
 	}
-	// 2. Remote follows local (follower)
-	follow2 := &domain.Follow{
+	if err := db.CreateFollow(follow); err != nil {
+		t.Fatalf("Failed to create follow: %v", err)
+	}
+
+	// Notifications are off by default
+	stored, err := db.ReadFollowByAccountIds(followerId, targetId)
+	if err != nil {
+		t.Fatalf("ReadFollowByAccountIds failed: %v", err)
+	}
+	if stored.Notify {
+		t.Error("Expected notify to be off for a new follow")
+	}
+
+	if err := db.UpdateFollowNotify(followerId, targetId, true); err != nil {
+		t.Fatalf("UpdateFollowNotify failed: %v", err)
+	}
+	stored, err = db.ReadFollowByAccountIds(followerId, targetId)
+	if err != nil {
+		t.Fatalf("ReadFollowByAccountIds failed: %v", err)
+	}
+	if !stored.Notify {
+		t.Error("Expected notify to be on")
+	}
+
+	following, err := db.ReadFollowingByAccountId(followerId)
+	if err != nil {
+		t.Fatalf("ReadFollowingByAccountId failed: %v", err)
+	}
+	if len(*following) != 1 || !(*following)[0].Notify {
+		t.Errorf("Expected the following list to show notify, got %+v", *following)
+	}
+
+	if err := db.UpdateFollowNotify(followerId, targetId, false); err != nil {
+		t.Fatalf("UpdateFollowNotify failed: %v", err)
+	}
+	stored, _ = db.ReadFollowByAccountIds(followerId, targetId)
+	if stored.Notify {
+		t.Error("Expected notify to be off again")
+	}
+}
+
+func TestReadFollowByAccountIds(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	// Create test accounts
+	followerId := uuid.New()
+	targetId := uuid.New()
+	createTestAccount(t, db, followerId, "alice", "pubkey1", "webpub1", "webpriv1")
+	createTestAccount(t, db, targetId, "bob", "pubkey2", "webpub2", "webpriv2")
+
+	// Create a follow relationship
+	follow := &domain.Follow{
 		Id:              uuid.New(),
-		AccountId:       remote2Id,
-		TargetAccountId: localId,
-		URI:             "https://remote.com/follows/1",
+		AccountId:       followerId,
+		TargetAccountId: targetId,
+		URI:             "https://example.com/follows/123",
 		Accepted:        true,
-		IsLocal:         false,
 		CreatedAt:       time.Now(),
 	}
-	// 3. Orphaned follow (remote account doesn't exist)
-	orphanedId := uuid.New()
-	follow3 := &domain.Follow{
+	err := db.CreateFollow(follow)
+	if err != nil {
+		t.Fatalf("Failed to create follow: %v", err)
+	}
+
+	// Test: Read existing follow relationship
+	existingFollow, err := db.ReadFollowByAccountIds(followerId, targetId)
+	if err != nil {
+		t.Fatalf("ReadFollowByAccountIds failed: %v", err)
+	}
+	if existingFollow == nil {
+		t.Fatal("Expected to find follow relationship but got nil")
+	}
+	if existingFollow.AccountId != followerId {
+		t.Errorf("Expected follower ID %s, got %s", followerId, existingFollow.AccountId)
+	}
+	if existingFollow.TargetAccountId != targetId {
+		t.Errorf("Expected target ID %s, got %s", targetId, existingFollow.TargetAccountId)
+	}
+
+	// Test: Read non-existent follow relationship
+	nonExistentId := uuid.New()
+	notFound, err := db.ReadFollowByAccountIds(followerId, nonExistentId)
+	if err != sql.ErrNoRows {
+		t.Errorf("Expected sql.ErrNoRows for non-existent follow, got: %v", err)
+	}
+	if notFound != nil {
+		t.Error("Expected nil for non-existent follow relationship")
+	}
+
+	// Test: Read pending (not yet accepted) follow relationship
+	pendingTargetId := uuid.New()
+	createTestAccount(t, db, pendingTargetId, "charlie", "pubkey3", "webpub3", "webpriv3")
+	pendingFollow := &domain.Follow{
 		Id:              uuid.New(),
-		AccountId:       localId,
-		TargetAccountId: orphanedId, // This account doesn't exist
-		URI:             "https://example.com/follows/orphaned",
-		Accepted:        true,
-		IsLocal:         false,
+		AccountId:       followerId,
+		TargetAccountId: pendingTargetId,
+		URI:             "https://example.com/follows/456",
+		Accepted:        false, // Pending, not yet accepted
+		CreatedAt:       time.Now(),
+	}
+	err = db.CreateFollow(pendingFollow)
+	if err != nil {
+		t.Fatalf("Failed to create pending follow: %v", err)
+	}
+
+	// Should find pending follow (even though accepted = false)
+	foundPending, err := db.ReadFollowByAccountIds(followerId, pendingTargetId)
+	if err != nil {
+		t.Fatalf("ReadFollowByAccountIds failed to find pending follow: %v", err)
+	}
+	if foundPending == nil {
+		t.Fatal("Expected to find pending follow relationship but got nil")
+	}
+	if foundPending.Accepted {
+		t.Error("Expected pending follow to have Accepted=false")
+	}
+}
+
+func TestCreateFollow_DuplicatePrevention(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	// Create test accounts
+	followerId := uuid.New()
+	targetId := uuid.New()
+	createTestAccount(t, db, followerId, "alice", "pubkey1", "webpub1", "webpriv1")
+	createTestAccount(t, db, targetId, "bob", "pubkey2", "webpub2", "webpriv2")
+
+	// Create first follow relationship (pending)
+	follow1 := &domain.Follow{
+		Id:              uuid.New(),
+		AccountId:       followerId,
+		TargetAccountId: targetId,
+		URI:             "https://example.com/follows/123",
+		Accepted:        false, // Pending follow
 		CreatedAt:       time.Now(),
 	}
+	err := db.CreateFollow(follow1)
+	if err != nil {
+		t.Fatalf("Failed to create first follow: %v", err)
+	}
 
-	for _, f := range []*domain.Follow{follow1, follow2, follow3} {
-		if err := db.CreateFollow(f); err != nil {
-			t.Fatalf("Failed to create follow: %v", err)
+	// Verify ReadFollowByAccountIds can detect the pending follow
+	existingFollow, err := db.ReadFollowByAccountIds(followerId, targetId)
+	if err != nil {
+		t.Fatalf("ReadFollowByAccountIds should find pending follow: %v", err)
+	}
+	if existingFollow == nil {
+		t.Fatal("Pending follow should be found by ReadFollowByAccountIds")
+	}
+	if existingFollow.Accepted {
+		t.Error("Expected pending follow to have Accepted=false")
+	}
+
+	// Test: Attempting to create duplicate follow should fail with UNIQUE constraint error
+	follow2 := &domain.Follow{
+		Id:              uuid.New(),
+		AccountId:       followerId,
+		TargetAccountId: targetId,
+		URI:             "https://example.com/follows/456",
+		Accepted:        false,
+		CreatedAt:       time.Now(),
+	}
+	err = db.CreateFollow(follow2)
+	if err == nil {
+		t.Fatal("Expected UNIQUE constraint error when creating duplicate follow")
+	}
+	if !strings.Contains(err.Error(), "UNIQUE") && !strings.Contains(err.Error(), "constraint") {
+		t.Errorf("Expected UNIQUE constraint error, got: %v", err)
+	}
+}
+
+func TestFollowersListNoDuplicates(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	// Create test accounts
+	follower1Id := uuid.New()
+	follower2Id := uuid.New()
+	targetId := uuid.New()
+	createTestAccount(t, db, follower1Id, "alice", "pubkey1", "webpub1", "webpriv1")
+	createTestAccount(t, db, follower2Id, "charlie", "pubkey2", "webpub2", "webpriv2")
+	createTestAccount(t, db, targetId, "bob", "pubkey3", "webpub3", "webpriv3")
+
+	// Create two different followers for the same target
+	follow1 := &domain.Follow{
+		Id:              uuid.New(),
+		AccountId:       follower1Id,
+		TargetAccountId: targetId,
+		URI:             "https://example.com/follows/1",
+		Accepted:        true,
+		IsLocal:         true, // Local follow between local accounts
+		CreatedAt:       time.Now(),
+	}
+	err := db.CreateFollow(follow1)
+	if err != nil {
+		t.Fatalf("Failed to create follow1: %v", err)
+	}
+
+	follow2 := &domain.Follow{
+		Id:              uuid.New(),
+		AccountId:       follower2Id,
+		TargetAccountId: targetId,
+		URI:             "https://example.com/follows/2",
+		Accepted:        true,
+		IsLocal:         true, // Local follow between local accounts
+		CreatedAt:       time.Now(),
+	}
+	err = db.CreateFollow(follow2)
+	if err != nil {
+		t.Fatalf("Failed to create follow2: %v", err)
+	}
+
+	// Read followers
+	followers, err := db.ReadFollowersByAccountId(targetId)
+	if err != nil {
+		t.Fatalf("Failed to read followers: %v", err)
+	}
+	if followers == nil {
+		t.Fatal("Followers list should not be nil")
+	}
+
+	// Should have exactly 2 followers
+	if len(*followers) != 2 {
+		t.Errorf("Expected 2 followers, got %d", len(*followers))
+	}
+
+	// Verify no duplicates by checking unique AccountIds
+	seenFollowers := make(map[uuid.UUID]int)
+	for _, f := range *followers {
+		seenFollowers[f.AccountId]++
+		if seenFollowers[f.AccountId] > 1 {
+			t.Errorf("Duplicate follower detected: %s appears %d times", f.AccountId, seenFollowers[f.AccountId])
+		}
+	}
+}
+
+func TestCountRemoteDomains(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	for i, handle := range [][2]string{{"alice", "one.example"}, {"bob", "one.example"}, {"carol", "two.example"}} {
+		acc := &domain.RemoteAccount{
+			Id:       uuid.New(),
+			Username: handle[0],
+			Domain:   handle[1],
+			ActorURI: fmt.Sprintf("https://%s/users/%s", handle[1], handle[0]),
+			InboxURI: fmt.Sprintf("https://%s/users/%s/inbox", handle[1], handle[0]),
+		}
+		if err := db.CreateRemoteAccount(acc); err != nil {
+			t.Fatalf("CreateRemoteAccount %d failed: %v", i, err)
+		}
+	}
+
+	count, err := db.CountRemoteDomains()
+	if err != nil {
+		t.Fatalf("CountRemoteDomains failed: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 remote domains, got %d", count)
+	}
+}
+
+func TestCountLocalPosts(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	// Test: Empty database
+	count, err := db.CountLocalPosts()
+	if err != nil {
+		t.Fatalf("CountLocalPosts failed: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("Expected 0 posts in empty database, got %d", count)
+	}
+
+	// Test: Add user and notes
+	userId := uuid.New()
+	createTestAccount(t, db, userId, "user1", "pubkey1", "webpub1", "webpriv1")
+
+	// Add first note
+	_, err = db.CreateNote(userId, "First note")
+	if err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+
+	count, err = db.CountLocalPosts()
+	if err != nil {
+		t.Fatalf("CountLocalPosts failed: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 post, got %d", count)
+	}
+
+	// Add second note
+	_, err = db.CreateNote(userId, "Second note")
+	if err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+
+	count, err = db.CountLocalPosts()
+	if err != nil {
+		t.Fatalf("CountLocalPosts failed: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 posts, got %d", count)
+	}
+
+	// Add third note from same user
+	_, err = db.CreateNote(userId, "Third note")
+	if err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+
+	count, err = db.CountLocalPosts()
+	if err != nil {
+		t.Fatalf("CountLocalPosts failed: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("Expected 3 posts, got %d", count)
+	}
+}
+
+func TestCountActiveUsersMonth(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	// Test: Empty database
+	count, err := db.CountActiveUsersMonth()
+	if err != nil {
+		t.Fatalf("CountActiveUsersMonth failed: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("Expected 0 active users in empty database, got %d", count)
+	}
+
+	// Test: Add users and notes
+	userId1 := uuid.New()
+	userId2 := uuid.New()
+	createTestAccount(t, db, userId1, "user1", "pubkey1", "webpub1", "webpriv1")
+	createTestAccount(t, db, userId2, "user2", "pubkey2", "webpub2", "webpriv2")
+
+	// User 1 posts (should be counted as active)
+	_, err = db.CreateNote(userId1, "Note from user1")
+	if err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+
+	count, err = db.CountActiveUsersMonth()
+	if err != nil {
+		t.Fatalf("CountActiveUsersMonth failed: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 active user, got %d", count)
+	}
+
+	// User 2 posts (should be counted as active)
+	_, err = db.CreateNote(userId2, "Note from user2")
+	if err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+
+	count, err = db.CountActiveUsersMonth()
+	if err != nil {
+		t.Fatalf("CountActiveUsersMonth failed: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 active users, got %d", count)
+	}
+
+	// User 1 posts again (should still be 2 unique users)
+	_, err = db.CreateNote(userId1, "Another note from user1")
+	if err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+
+	count, err = db.CountActiveUsersMonth()
+	if err != nil {
+		t.Fatalf("CountActiveUsersMonth failed: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 active users (should count distinct users), got %d", count)
+	}
+}
+
+func TestCountActiveUsersHalfYear(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	// Test: Empty database
+	count, err := db.CountActiveUsersHalfYear()
+	if err != nil {
+		t.Fatalf("CountActiveUsersHalfYear failed: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("Expected 0 active users in empty database, got %d", count)
+	}
+
+	// Test: Add users and notes
+	userId1 := uuid.New()
+	userId2 := uuid.New()
+	userId3 := uuid.New()
+	createTestAccount(t, db, userId1, "user1", "pubkey1", "webpub1", "webpriv1")
+	createTestAccount(t, db, userId2, "user2", "pubkey2", "webpub2", "webpriv2")
+	createTestAccount(t, db, userId3, "user3", "pubkey3", "webpub3", "webpriv3")
+
+	// User 1 posts
+	_, err = db.CreateNote(userId1, "Note from user1")
+	if err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+
+	count, err = db.CountActiveUsersHalfYear()
+	if err != nil {
+		t.Fatalf("CountActiveUsersHalfYear failed: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 active user, got %d", count)
+	}
+
+	// User 2 posts
+	_, err = db.CreateNote(userId2, "Note from user2")
+	if err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+
+	count, err = db.CountActiveUsersHalfYear()
+	if err != nil {
+		t.Fatalf("CountActiveUsersHalfYear failed: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 active users, got %d", count)
+	}
+
+	// User 3 posts
+	_, err = db.CreateNote(userId3, "Note from user3")
+	if err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+
+	count, err = db.CountActiveUsersHalfYear()
+	if err != nil {
+		t.Fatalf("CountActiveUsersHalfYear failed: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("Expected 3 active users, got %d", count)
+	}
+}
+
+func TestCountActiveUsers_Consistency(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	// Create users and have them post
+	userId1 := uuid.New()
+	userId2 := uuid.New()
+	createTestAccount(t, db, userId1, "user1", "pubkey1", "webpub1", "webpriv1")
+	createTestAccount(t, db, userId2, "user2", "pubkey2", "webpub2", "webpriv2")
+
+	_, err := db.CreateNote(userId1, "Note 1")
+	if err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+	_, err = db.CreateNote(userId2, "Note 2")
+	if err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+
+	// Get all counts
+	activeMonth, err := db.CountActiveUsersMonth()
+	if err != nil {
+		t.Fatalf("CountActiveUsersMonth failed: %v", err)
+	}
+
+	activeHalfYear, err := db.CountActiveUsersHalfYear()
+	if err != nil {
+		t.Fatalf("CountActiveUsersHalfYear failed: %v", err)
+	}
+
+	totalUsers, err := db.CountAccounts()
+	if err != nil {
+		t.Fatalf("CountAccounts failed: %v", err)
+	}
+
+	// Verify logical consistency
+	// ActiveMonth should not exceed ActiveHalfYear
+	if activeMonth > activeHalfYear {
+		t.Errorf("ActiveMonth (%d) should not exceed ActiveHalfYear (%d)", activeMonth, activeHalfYear)
+	}
+
+	// ActiveHalfYear should not exceed TotalUsers
+	if activeHalfYear > totalUsers {
+		t.Errorf("ActiveHalfYear (%d) should not exceed TotalUsers (%d)", activeHalfYear, totalUsers)
+	}
+
+	// ActiveMonth should not exceed TotalUsers
+	if activeMonth > totalUsers {
+		t.Errorf("ActiveMonth (%d) should not exceed TotalUsers (%d)", activeMonth, totalUsers)
+	}
+}
+
+// TestReadActivityByObjectURI_WildcardEscaping tests that SQL wildcards are properly escaped
+func TestReadActivityByObjectURI_WildcardEscaping(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	// Create activities with URIs containing wildcards
+	activities := []struct {
+		uri     string
+		content string
+	}{
+		{"https://example.com/posts/123", "Normal post"},
+		{"https://example.com/posts/12%", "Post with percent"},
+		{"https://example.com/posts/12_", "Post with underscore"},
+		{"https://example.com/posts/12\\", "Post with backslash"},
+	}
+
+	for _, act := range activities {
+		activity := &domain.Activity{
+			Id:           uuid.New(),
+			ActivityURI:  "https://example.com/activities/" + uuid.New().String(),
+			ActivityType: "Create",
+			ActorURI:     "https://example.com/users/alice",
+			ObjectURI:    act.uri,
+			RawJSON:      `{"id":"` + act.uri + `","type":"Note","content":"` + act.content + `"}`,
+			Processed:    true,
+			Local:        false,
+			CreatedAt:    time.Now(),
+		}
+		if err := db.CreateActivity(activity); err != nil {
+			t.Fatalf("Failed to create activity: %v", err)
+		}
+	}
+
+	// Test exact match for URI with percent (should not match as wildcard)
+	found, err := db.ReadActivityByObjectURI("https://example.com/posts/12%")
+	if err != nil {
+		t.Fatalf("ReadActivityByObjectURI failed: %v", err)
+	}
+	if found == nil {
+		t.Fatal("Expected to find activity with percent in URI")
+	}
+	if !strings.Contains(found.RawJSON, "Post with percent") {
+		t.Error("Found wrong activity - percent was treated as wildcard")
+	}
+
+	// Test exact match for URI with underscore (should not match as wildcard)
+	found, err = db.ReadActivityByObjectURI("https://example.com/posts/12_")
+	if err != nil {
+		t.Fatalf("ReadActivityByObjectURI failed: %v", err)
+	}
+	if found == nil {
+		t.Fatal("Expected to find activity with underscore in URI")
+	}
+	if !strings.Contains(found.RawJSON, "Post with underscore") {
+		t.Error("Found wrong activity - underscore was treated as wildcard")
+	}
+
+	// Test exact match for URI with backslash
+	found, err = db.ReadActivityByObjectURI("https://example.com/posts/12\\")
+	if err != nil {
+		t.Fatalf("ReadActivityByObjectURI failed: %v", err)
+	}
+	if found == nil {
+		t.Fatal("Expected to find activity with backslash in URI")
+	}
+	if !strings.Contains(found.RawJSON, "Post with backslash") {
+		t.Error("Found wrong activity - backslash escaping failed")
+	}
+}
+
+// TestCleanupOrphanedFollows_BothDirections tests cleanup of orphaned follows
+func TestCleanupOrphanedFollows_BothDirections(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	// Create local account
+	localId := uuid.New()
+	createTestAccount(t, db, localId, "alice", "pubkey1", "webpub1", "webpriv1")
+
+	// Create remote accounts
+	remote1Id := uuid.New()
+	remote2Id := uuid.New()
+	remoteAcc1 := &domain.RemoteAccount{
+		Id:            remote1Id,
+		Username:      "bob",
+		Domain:        "remote.com",
+		ActorURI:      "https://remote.com/users/bob",
+		InboxURI:      "https://remote.com/users/bob/inbox",
+		PublicKeyPem:  "pubkey2",
+		LastFetchedAt: time.Now(),
+	}
+	remoteAcc2 := &domain.RemoteAccount{
+		Id:            remote2Id,
+		Username:      "charlie",
+		Domain:        "remote.com",
+		ActorURI:      "https://remote.com/users/charlie",
+		InboxURI:      "https://remote.com/users/charlie/inbox",
+		PublicKeyPem:  "pubkey3",
+		LastFetchedAt: time.Now(),
+	}
+	if err := db.CreateRemoteAccount(remoteAcc1); err != nil {
+		t.Fatalf("Failed to create remote account 1: %v", err)
+	}
+	if err := db.CreateRemoteAccount(remoteAcc2); err != nil {
+		t.Fatalf("Failed to create remote account 2: %v", err)
+	}
+
+	// Create follows:
+	// 1. Local follows remote (following)
+	follow1 := &domain.Follow{
+		Id:              uuid.New(),
+		AccountId:       localId,
+		TargetAccountId: remote1Id,
+		URI:             "https://example.com/follows/1",
+		Accepted:        true,
+		IsLocal:         false,
+		CreatedAt:       time.Now(),
+	}
+	// 2. Remote follows local (follower)
+	follow2 := &domain.Follow{
+		Id:              uuid.New(),
+		AccountId:       remote2Id,
+		TargetAccountId: localId,
+		URI:             "https://remote.com/follows/1",
+		Accepted:        true,
+		IsLocal:         false,
+		CreatedAt:       time.Now(),
+	}
+	// 3. Orphaned follow (remote account doesn't exist)
+	orphanedId := uuid.New()
+	follow3 := &domain.Follow{
+		Id:              uuid.New(),
+		AccountId:       localId,
+		TargetAccountId: orphanedId, // This account doesn't exist
+		URI:             "https://example.com/follows/orphaned",
+		Accepted:        true,
+		IsLocal:         false,
+		CreatedAt:       time.Now(),
+	}
+
+	for _, f := range []*domain.Follow{follow1, follow2, follow3} {
+		if err := db.CreateFollow(f); err != nil {
+			t.Fatalf("Failed to create follow: %v", err)
+		}
+	}
+
+	// Run cleanup
+	if err := db.CleanupOrphanedFollows(); err != nil {
+		t.Fatalf("CleanupOrphanedFollows failed: %v", err)
+	}
+
+	// Verify: follow1 and follow2 should remain, follow3 should be deleted
+	f1, err := db.ReadFollowByURI(follow1.URI)
+	if err != nil || f1 == nil {
+		t.Error("Valid follow (local->remote) was incorrectly deleted")
+	}
+
+	f2, err := db.ReadFollowByURI(follow2.URI)
+	if err != nil || f2 == nil {
+		t.Error("Valid follow (remote->local) was incorrectly deleted")
+	}
+
+	f3, err := db.ReadFollowByURI(follow3.URI)
+	if err == nil && f3 != nil {
+		t.Error("Orphaned follow should have been deleted")
+	}
+
+	// Delete remote account and verify cleanup removes follows
+	if err := db.DeleteRemoteAccount(remote1Id); err != nil {
+		t.Fatalf("Failed to delete remote account: %v", err)
+	}
+
+	// Run cleanup again
+	if err := db.CleanupOrphanedFollows(); err != nil {
+		t.Fatalf("CleanupOrphanedFollows failed: %v", err)
+	}
+
+	// Now follow1 should be gone too
+	f1, err = db.ReadFollowByURI(follow1.URI)
+	if err == nil && f1 != nil {
+		t.Error("Follow should have been deleted after remote account deletion")
+	}
+}
+
+// Hashtag tests
+
+func TestCreateOrUpdateHashtag(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	// Create new hashtag
+	id1, err := db.CreateOrUpdateHashtag("golang")
+	if err != nil {
+		t.Fatalf("CreateOrUpdateHashtag failed: %v", err)
+	}
+	if id1 == 0 {
+		t.Error("Expected non-zero hashtag ID")
+	}
+
+	// Verify hashtag was created with usage_count = 1
+	var name string
+	var usageCount int
+	err = db.db.QueryRow("SELECT name, usage_count FROM hashtags WHERE id = ?", id1).Scan(&name, &usageCount)
+	if err != nil {
+		t.Fatalf("Failed to query hashtag: %v", err)
+	}
+	if name != "golang" {
+		t.Errorf("Expected name 'golang', got '%s'", name)
+	}
+	if usageCount != 1 {
+		t.Errorf("Expected usage_count 1, got %d", usageCount)
+	}
+
+	// Update existing hashtag (should increment usage_count)
+	id2, err := db.CreateOrUpdateHashtag("golang")
+	if err != nil {
+		t.Fatalf("CreateOrUpdateHashtag (update) failed: %v", err)
+	}
+	if id2 != id1 {
+		t.Errorf("Expected same ID on update, got %d vs %d", id1, id2)
+	}
+
+	// Verify usage_count incremented
+	err = db.db.QueryRow("SELECT usage_count FROM hashtags WHERE id = ?", id1).Scan(&usageCount)
+	if err != nil {
+		t.Fatalf("Failed to query hashtag: %v", err)
+	}
+	if usageCount != 2 {
+		t.Errorf("Expected usage_count 2 after update, got %d", usageCount)
+	}
+}
+
+func TestCreateOrUpdateHashtag_CaseInsensitive(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	// Create hashtag
+	id1, err := db.CreateOrUpdateHashtag("GoLang")
+	if err != nil {
+		t.Fatalf("CreateOrUpdateHashtag failed: %v", err)
+	}
+
+	// Verify it's stored as lowercase
+	var name string
+	err = db.db.QueryRow("SELECT name FROM hashtags WHERE id = ?", id1).Scan(&name)
+	if err != nil {
+		t.Fatalf("Failed to query hashtag: %v", err)
+	}
+	if name != "golang" {
+		t.Errorf("Expected lowercase 'golang', got '%s'", name)
+	}
+
+	// Update with different case should update the same record
+	id2, err := db.CreateOrUpdateHashtag("GOLANG")
+	if err != nil {
+		t.Fatalf("CreateOrUpdateHashtag (different case) failed: %v", err)
+	}
+	if id2 != id1 {
+		t.Errorf("Expected same ID for case-insensitive match, got %d vs %d", id1, id2)
+	}
+}
+
+func TestLinkNoteHashtags(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	// Create user and note
+	userId := uuid.New()
+	createTestAccount(t, db, userId, "testuser", "pubkey", "webpub", "webpriv")
+	noteId, err := db.CreateNote(userId, "Test note #golang #rust")
+	if err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+
+	// Create hashtags
+	golangId, _ := db.CreateOrUpdateHashtag("golang")
+	rustId, _ := db.CreateOrUpdateHashtag("rust")
+
+	// Link note to hashtags
+	err = db.LinkNoteHashtags(noteId, []int64{golangId, rustId})
+	if err != nil {
+		t.Fatalf("LinkNoteHashtags failed: %v", err)
+	}
+
+	// Verify links were created
+	var count int
+	err = db.db.QueryRow("SELECT COUNT(*) FROM note_hashtags WHERE note_id = ?", noteId.String()).Scan(&count)
+	if err != nil {
+		t.Fatalf("Failed to query note_hashtags: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 hashtag links, got %d", count)
+	}
+}
+
+func TestLinkNoteHashtags_Duplicate(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	// Create user and note
+	userId := uuid.New()
+	createTestAccount(t, db, userId, "testuser", "pubkey", "webpub", "webpriv")
+	noteId, err := db.CreateNote(userId, "Test note #golang")
+	if err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+
+	// Create hashtag
+	golangId, _ := db.CreateOrUpdateHashtag("golang")
+
+	// Link note to hashtag
+	err = db.LinkNoteHashtags(noteId, []int64{golangId})
+	if err != nil {
+		t.Fatalf("LinkNoteHashtags failed: %v", err)
+	}
+
+	// Try to link again (should be ignored due to INSERT OR IGNORE)
+	err = db.LinkNoteHashtags(noteId, []int64{golangId})
+	if err != nil {
+		t.Fatalf("LinkNoteHashtags (duplicate) should not error: %v", err)
+	}
+
+	// Verify only one link exists
+	var count int
+	err = db.db.QueryRow("SELECT COUNT(*) FROM note_hashtags WHERE note_id = ?", noteId.String()).Scan(&count)
+	if err != nil {
+		t.Fatalf("Failed to query note_hashtags: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 hashtag link after duplicate insert, got %d", count)
+	}
+}
+
+func TestReadHashtagsByNoteId(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	// Create user and note
+	userId := uuid.New()
+	createTestAccount(t, db, userId, "testuser", "pubkey", "webpub", "webpriv")
+	noteId, err := db.CreateNote(userId, "Test note #golang #rust #fediverse")
+	if err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+
+	// Create and link hashtags
+	golangId, _ := db.CreateOrUpdateHashtag("golang")
+	rustId, _ := db.CreateOrUpdateHashtag("rust")
+	fediverseId, _ := db.CreateOrUpdateHashtag("fediverse")
+	err = db.LinkNoteHashtags(noteId, []int64{golangId, rustId, fediverseId})
+	if err != nil {
+		t.Fatalf("LinkNoteHashtags failed: %v", err)
+	}
+
+	// Read hashtags
+	hashtags, err := db.ReadHashtagsByNoteId(noteId)
+	if err != nil {
+		t.Fatalf("ReadHashtagsByNoteId failed: %v", err)
+	}
+	if len(hashtags) != 3 {
+		t.Errorf("Expected 3 hashtags, got %d", len(hashtags))
+	}
+
+	// Verify all hashtags are present
+	hashtagMap := make(map[string]bool)
+	for _, h := range hashtags {
+		hashtagMap[h] = true
+	}
+	if !hashtagMap["golang"] {
+		t.Error("Expected 'golang' in hashtags")
+	}
+	if !hashtagMap["rust"] {
+		t.Error("Expected 'rust' in hashtags")
+	}
+	if !hashtagMap["fediverse"] {
+		t.Error("Expected 'fediverse' in hashtags")
+	}
+}
+
+func TestReadHashtagsByNoteId_NoHashtags(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	// Create user and note without hashtags
+	userId := uuid.New()
+	createTestAccount(t, db, userId, "testuser", "pubkey", "webpub", "webpriv")
+	noteId, err := db.CreateNote(userId, "Test note without hashtags")
+	if err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+
+	// Read hashtags (should return empty slice)
+	hashtags, err := db.ReadHashtagsByNoteId(noteId)
+	if err != nil {
+		t.Fatalf("ReadHashtagsByNoteId failed: %v", err)
+	}
+	if len(hashtags) != 0 {
+		t.Errorf("Expected 0 hashtags, got %d", len(hashtags))
+	}
+}
+
+func TestReadNotesByHashtag(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	// Create user
+	userId := uuid.New()
+	createTestAccount(t, db, userId, "testuser", "pubkey", "webpub", "webpriv")
+
+	// Create notes with hashtags
+	note1Id, _ := db.CreateNote(userId, "Note 1 #golang")
+	note2Id, _ := db.CreateNote(userId, "Note 2 #golang #rust")
+	note3Id, _ := db.CreateNote(userId, "Note 3 #rust")
+
+	// Create and link hashtags
+	golangId, _ := db.CreateOrUpdateHashtag("golang")
+	rustId, _ := db.CreateOrUpdateHashtag("rust")
+	db.LinkNoteHashtags(note1Id, []int64{golangId})
+	db.LinkNoteHashtags(note2Id, []int64{golangId, rustId})
+	db.LinkNoteHashtags(note3Id, []int64{rustId})
+
+	// Read notes by #golang
+	notes, err := db.ReadNotesByHashtag("golang", 10, 0)
+	if err != nil {
+		t.Fatalf("ReadNotesByHashtag failed: %v", err)
+	}
+	if len(*notes) != 2 {
+		t.Errorf("Expected 2 notes with #golang, got %d", len(*notes))
+	}
+
+	// Read notes by #rust
+	notes, err = db.ReadNotesByHashtag("rust", 10, 0)
+	if err != nil {
+		t.Fatalf("ReadNotesByHashtag failed: %v", err)
+	}
+	if len(*notes) != 2 {
+		t.Errorf("Expected 2 notes with #rust, got %d", len(*notes))
+	}
+
+	// Read notes by non-existent hashtag
+	notes, err = db.ReadNotesByHashtag("nonexistent", 10, 0)
+	if err != nil {
+		t.Fatalf("ReadNotesByHashtag failed: %v", err)
+	}
+	if len(*notes) != 0 {
+		t.Errorf("Expected 0 notes with #nonexistent, got %d", len(*notes))
+	}
+}
+
+func TestReadNotesByHashtag_Pagination(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	// Create user
+	userId := uuid.New()
+	createTestAccount(t, db, userId, "testuser", "pubkey", "webpub", "webpriv")
+
+	// Create 5 notes with #golang
+	golangId, _ := db.CreateOrUpdateHashtag("golang")
+	for i := 0; i < 5; i++ {
+		noteId, _ := db.CreateNote(userId, "Note #golang")
+		db.LinkNoteHashtags(noteId, []int64{golangId})
+	}
+
+	// Test limit
+	notes, err := db.ReadNotesByHashtag("golang", 3, 0)
+	if err != nil {
+		t.Fatalf("ReadNotesByHashtag failed: %v", err)
+	}
+	if len(*notes) != 3 {
+		t.Errorf("Expected 3 notes with limit=3, got %d", len(*notes))
+	}
+
+	// Test offset
+	notes, err = db.ReadNotesByHashtag("golang", 3, 3)
+	if err != nil {
+		t.Fatalf("ReadNotesByHashtag with offset failed: %v", err)
+	}
+	if len(*notes) != 2 {
+		t.Errorf("Expected 2 notes with limit=3 offset=3, got %d", len(*notes))
+	}
+}
+
+func TestReadNotesByHashtag_CaseInsensitive(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	// Create user and note
+	userId := uuid.New()
+	createTestAccount(t, db, userId, "testuser", "pubkey", "webpub", "webpriv")
+	noteId, _ := db.CreateNote(userId, "Note #GoLang")
+
+	// Create and link hashtag
+	golangId, _ := db.CreateOrUpdateHashtag("golang")
+	db.LinkNoteHashtags(noteId, []int64{golangId})
+
+	// Search with different case
+	notes, err := db.ReadNotesByHashtag("GOLANG", 10, 0)
+	if err != nil {
+		t.Fatalf("ReadNotesByHashtag failed: %v", err)
+	}
+	if len(*notes) != 1 {
+		t.Errorf("Expected 1 note with case-insensitive search, got %d", len(*notes))
+	}
+}
+
+func TestReadFederatedNotesByHashtag(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	userId := uuid.New()
+	createTestAccount(t, db, userId, "alice", "pubkey", "webpub", "webpriv")
+
+	musicId, _ := db.CreateOrUpdateHashtag("music")
+	for _, minutesAgo := range []int{30, 10} {
+		noteId, err := db.CreateNote(userId, "local #music")
+		if err != nil {
+			t.Fatalf("Failed to create note: %v", err)
+		}
+		createdAt := time.Now().Add(-time.Duration(minutesAgo) * time.Minute).Format("2006-01-02 15:04:05")
+		db.db.Exec(`UPDATE notes SET created_at = ? WHERE id = ?`, createdAt, noteId.String())
+		if err := db.LinkNoteHashtags(noteId, []int64{musicId}); err != nil {
+			t.Fatalf("LinkNoteHashtags failed: %v", err)
+		}
+	}
+
+	// Remote posts from 20 and 5 minutes ago interleave with the local notes
+	for i, minutesAgo := range []int{20, 5} {
+		activity := &domain.Activity{
+			Id:           uuid.New(),
+			ActivityURI:  fmt.Sprintf("https://remote.example.com/activities/%d", i),
+			ActivityType: "Create",
+			ActorURI:     "https://remote.example.com/users/bob",
+			ObjectURI:    fmt.Sprintf("https://remote.example.com/notes/%d", i),
+			RawJSON:      `{"type":"Create","object":{"content":"<p>remote #Music</p>"}}`,
+			CreatedAt:    time.Now().Add(-time.Duration(minutesAgo) * time.Minute),
+		}
+		if err := db.CreateActivity(activity); err != nil {
+			t.Fatalf("CreateActivity failed: %v", err)
+		}
+		if err := db.LinkActivityHashtags(activity.Id, []string{"Music", "remoteonly"}); err != nil {
+			t.Fatalf("LinkActivityHashtags failed: %v", err)
+		}
+	}
+
+	count, err := db.CountFederatedNotesByHashtag("music")
+	if err != nil {
+		t.Fatalf("CountFederatedNotesByHashtag failed: %v", err)
+	}
+	if count != 4 {
+		t.Errorf("Expected 4 posts with #music, got %d", count)
+	}
+
+	firstPage, err := db.ReadFederatedNotesByHashtag("music", 3, 0)
+	if err != nil {
+		t.Fatalf("ReadFederatedNotesByHashtag failed: %v", err)
+	}
+	secondPage, err := db.ReadFederatedNotesByHashtag("music", 3, 3)
+	if err != nil {
+		t.Fatalf("ReadFederatedNotesByHashtag failed: %v", err)
+	}
+
+	var order []bool
+	for _, post := range append(*firstPage, *secondPage...) {
+		order = append(order, post.IsLocal)
+	}
+	// Newest first: remote (5m), local (10m), remote (20m), local (30m)
+	if fmt.Sprint(order) != "[false true false true]" {
+		t.Errorf("Expected remote and local posts interleaved by time, got IsLocal %v", order)
+	}
+	if remote := (*firstPage)[0]; remote.Author != "@bob@remote.example.com" || remote.Content != "remote #Music" {
+		t.Errorf("Unexpected remote post: %+v", remote)
+	}
+
+	remoteOnly, err := db.ReadFederatedNotesByHashtag("remoteonly", 10, 0)
+	if err != nil || len(*remoteOnly) != 2 {
+		t.Errorf("Expected 2 remote-only posts, got %v (err %v)", remoteOnly, err)
+	}
+}
+
+func TestReadTrendingHashtags(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	aliceId := uuid.New()
+	bobId := uuid.New()
+	createTestAccount(t, db, aliceId, "alice", "pubkey1", "webpub1", "webpriv1")
+	createTestAccount(t, db, bobId, "bob", "pubkey2", "webpub2", "webpriv2")
+
+	golangId, _ := db.CreateOrUpdateHashtag("golang")
+	spamId, _ := db.CreateOrUpdateHashtag("spam")
+	oldId, _ := db.CreateOrUpdateHashtag("old")
+	hiddenId, _ := db.CreateOrUpdateHashtag("hidden")
+
+	link := func(userId uuid.UUID, visibility string, hashtagId int64) uuid.UUID {
+		noteId, err := db.CreateNoteWithVisibility(userId, "tagged", "", visibility)
+		if err != nil {
+			t.Fatalf("Failed to create note: %v", err)
+		}
+		if err := db.LinkNoteHashtags(noteId, []int64{hashtagId}); err != nil {
+			t.Fatalf("LinkNoteHashtags failed: %v", err)
+		}
+		return noteId
+	}
+
+	// #golang is used by two accounts, #spam three times by one account
+	link(aliceId, domain.VisibilityPublic, golangId)
+	link(bobId, domain.VisibilityPublic, golangId)
+	for range 3 {
+		link(aliceId, domain.VisibilityPublic, spamId)
+	}
+	// #old was only used before the window, #hidden only in non-public notes
+	oldNoteId := link(aliceId, domain.VisibilityPublic, oldId)
+	old := time.Now().Add(-48 * time.Hour).Format("2006-01-02 15:04:05")
+	if _, err := db.db.Exec(`UPDATE notes SET created_at = ? WHERE id = ?`, old, oldNoteId.String()); err != nil {
+		t.Fatalf("Failed to backdate note: %v", err)
+	}
+	link(aliceId, domain.VisibilityLocal, hiddenId)
+	link(bobId, domain.VisibilityFollowers, hiddenId)
+
+	trends, err := db.ReadTrendingHashtags(24*time.Hour, 10)
+	if err != nil {
+		t.Fatalf("ReadTrendingHashtags failed: %v", err)
+	}
+	if len(trends) != 2 {
+		t.Fatalf("Expected 2 trending hashtags, got %v", trends)
+	}
+	if trends[0].Name != "golang" || trends[0].Uses != 2 || trends[0].Accounts != 2 {
+		t.Errorf("Expected #golang first with 2 uses by 2 accounts, got %+v", trends[0])
+	}
+	if trends[1].Name != "spam" || trends[1].Uses != 3 || trends[1].Accounts != 1 {
+		t.Errorf("Expected #spam second with 3 uses by 1 account, got %+v", trends[1])
+	}
+	if trends[0].LastUsedAt.IsZero() {
+		t.Error("Expected LastUsedAt to be set")
+	}
+
+	trends, err = db.ReadTrendingHashtags(24*time.Hour, 1)
+	if err != nil {
+		t.Fatalf("ReadTrendingHashtags failed: %v", err)
+	}
+	if len(trends) != 1 {
+		t.Errorf("Expected limit to return 1 hashtag, got %d", len(trends))
+	}
+}
+
+func TestCountNotesByHashtag(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	// Create user
+	userId := uuid.New()
+	createTestAccount(t, db, userId, "testuser", "pubkey", "webpub", "webpriv")
+
+	// Create notes with hashtags
+	note1Id, _ := db.CreateNote(userId, "Note 1 #golang")
+	note2Id, _ := db.CreateNote(userId, "Note 2 #golang #rust")
+	note3Id, _ := db.CreateNote(userId, "Note 3 #golang")
+
+	// Create and link hashtags
+	golangId, _ := db.CreateOrUpdateHashtag("golang")
+	rustId, _ := db.CreateOrUpdateHashtag("rust")
+	db.LinkNoteHashtags(note1Id, []int64{golangId})
+	db.LinkNoteHashtags(note2Id, []int64{golangId, rustId})
+	db.LinkNoteHashtags(note3Id, []int64{golangId})
+
+	// Count notes by #golang
+	count, err := db.CountNotesByHashtag("golang")
+	if err != nil {
+		t.Fatalf("CountNotesByHashtag failed: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("Expected 3 notes with #golang, got %d", count)
+	}
+
+	// Count notes by #rust
+	count, err = db.CountNotesByHashtag("rust")
+	if err != nil {
+		t.Fatalf("CountNotesByHashtag failed: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 note with #rust, got %d", count)
+	}
+
+	// Count notes by non-existent hashtag
+	count, err = db.CountNotesByHashtag("nonexistent")
+	if err != nil {
+		t.Fatalf("CountNotesByHashtag failed: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("Expected 0 notes with #nonexistent, got %d", count)
+	}
+}
+
+// ============================================================================
+// Reply Count Tests
+// ============================================================================
+
+func TestExtractInReplyToFromJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		rawJSON  string
+		expected string
+	}{
+		{
+			name:     "valid inReplyTo string",
+			rawJSON:  `{"object":{"inReplyTo":"https://example.com/notes/123"}}`,
+			expected: "https://example.com/notes/123",
+		},
+		{
+			name:     "inReplyTo with spaces in JSON",
+			rawJSON:  `{"object": {"inReplyTo": "https://example.com/notes/456"}}`,
+			expected: "https://example.com/notes/456",
+		},
+		{
+			name:     "inReplyTo is null",
+			rawJSON:  `{"object":{"inReplyTo":null}}`,
+			expected: "",
+		},
+		{
+			name:     "missing inReplyTo field",
+			rawJSON:  `{"object":{"content":"Hello world"}}`,
+			expected: "",
+		},
+		{
+			name:     "missing object field",
+			rawJSON:  `{"type":"Create"}`,
+			expected: "",
+		},
+		{
+			name:     "empty JSON",
+			rawJSON:  "",
+			expected: "",
+		},
+		{
+			name:     "invalid JSON",
+			rawJSON:  `{invalid}`,
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := extractInReplyToFromJSON(tt.rawJSON)
+			if result != tt.expected {
+				t.Errorf("Expected '%s', got '%s'", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestIncrementReplyCountRecursive_Notes(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	// Create user
+	userId := uuid.New()
+	createTestAccount(t, db, userId, "testuser", "pubkey", "webpub", "webpriv")
+
+	// Create a thread: root -> reply1 -> reply2 (3 levels deep)
+	rootId := uuid.New()
+	reply1Id := uuid.New()
+	reply2Id := uuid.New()
+
+	rootURI := "https://example.com/notes/" + rootId.String()
+	reply1URI := "https://example.com/notes/" + reply1Id.String()
+
+	// Create root note
+	_, err := db.db.Exec(`INSERT INTO notes (id, user_id, message, created_at, object_uri, reply_count)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		rootId, userId.String(), "Root post", time.Now(), rootURI, 0)
+	if err != nil {
+		t.Fatalf("Failed to create root note: %v", err)
+	}
+
+	// Create reply1 (replies to root)
+	_, err = db.db.Exec(`INSERT INTO notes (id, user_id, message, created_at, object_uri, in_reply_to_uri, reply_count)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		reply1Id, userId.String(), "Reply 1", time.Now(), reply1URI, rootURI, 0)
+	if err != nil {
+		t.Fatalf("Failed to create reply1: %v", err)
+	}
+
+	// Create reply2 (replies to reply1)
+	_, err = db.db.Exec(`INSERT INTO notes (id, user_id, message, created_at, object_uri, in_reply_to_uri, reply_count)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		reply2Id, userId.String(), "Reply 2", time.Now(), "", reply1URI, 0)
+	if err != nil {
+		t.Fatalf("Failed to create reply2: %v", err)
+	}
+
+	// Increment reply count for reply1URI (simulates receiving reply2)
+	err = db.IncrementReplyCountByURI(reply1URI)
+	if err != nil {
+		t.Fatalf("IncrementReplyCountByURI failed: %v", err)
+	}
+
+	// Verify reply1 has reply_count = 1
+	var reply1Count int
+	err = db.db.QueryRow(`SELECT reply_count FROM notes WHERE id = ?`, reply1Id.String()).Scan(&reply1Count)
+	if err != nil {
+		t.Fatalf("Failed to query reply1: %v", err)
+	}
+	if reply1Count != 1 {
+		t.Errorf("Expected reply1 reply_count = 1, got %d", reply1Count)
+	}
+
+	// Verify root also has reply_count = 1 (recursive increment)
+	var rootCount int
+	err = db.db.QueryRow(`SELECT reply_count FROM notes WHERE id = ?`, rootId.String()).Scan(&rootCount)
+	if err != nil {
+		t.Fatalf("Failed to query root: %v", err)
+	}
+	if rootCount != 1 {
+		t.Errorf("Expected root reply_count = 1 (recursive), got %d", rootCount)
+	}
+}
+
+func TestIncrementReplyCountRecursive_Activities(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	// Create a chain of activities: remoteRoot -> remoteReply1 -> remoteReply2
+	rootURI := "https://remote.example.com/notes/root"
+	reply1URI := "https://remote.example.com/notes/reply1"
+
+	// Create root activity (no inReplyTo)
+	rootActivity := &domain.Activity{
+		Id:           uuid.New(),
+		ActivityURI:  "https://remote.example.com/activities/1",
+		ActivityType: "Create",
+		ActorURI:     "https://remote.example.com/users/alice",
+		ObjectURI:    rootURI,
+		RawJSON:      `{"type":"Create","object":{"id":"` + rootURI + `","type":"Note","content":"Root post"}}`,
+		Processed:    true,
+		CreatedAt:    time.Now(),
+	}
+	err := db.CreateActivity(rootActivity)
+	if err != nil {
+		t.Fatalf("Failed to create root activity: %v", err)
+	}
+
+	// Create reply1 activity (replies to root)
+	reply1Activity := &domain.Activity{
+		Id:           uuid.New(),
+		ActivityURI:  "https://remote.example.com/activities/2",
+		ActivityType: "Create",
+		ActorURI:     "https://remote.example.com/users/bob",
+		ObjectURI:    reply1URI,
+		RawJSON:      `{"type":"Create","object":{"id":"` + reply1URI + `","type":"Note","content":"Reply 1","inReplyTo":"` + rootURI + `"}}`,
+		Processed:    true,
+		CreatedAt:    time.Now(),
+	}
+	err = db.CreateActivity(reply1Activity)
+	if err != nil {
+		t.Fatalf("Failed to create reply1 activity: %v", err)
+	}
+
+	// Increment reply count for reply1URI (simulates receiving a new reply)
+	err = db.IncrementReplyCountByURI(reply1URI)
+	if err != nil {
+		t.Fatalf("IncrementReplyCountByURI failed: %v", err)
+	}
+
+	// Verify reply1 has reply_count = 1
+	var reply1Count int
+	err = db.db.QueryRow(`SELECT reply_count FROM activities WHERE object_uri = ?`, reply1URI).Scan(&reply1Count)
+	if err != nil {
+		t.Fatalf("Failed to query reply1: %v", err)
+	}
+	if reply1Count != 1 {
+		t.Errorf("Expected reply1 reply_count = 1, got %d", reply1Count)
+	}
+
+	// Verify root also has reply_count = 1 (recursive increment via inReplyTo in raw_json)
+	var rootCount int
+	err = db.db.QueryRow(`SELECT reply_count FROM activities WHERE object_uri = ?`, rootURI).Scan(&rootCount)
+	if err != nil {
+		t.Fatalf("Failed to query root: %v", err)
+	}
+	if rootCount != 1 {
+		t.Errorf("Expected root reply_count = 1 (recursive), got %d", rootCount)
+	}
+}
+
+func TestIncrementReplyCountRecursive_MixedChain(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	// Create user
+	userId := uuid.New()
+	createTestAccount(t, db, userId, "testuser", "pubkey", "webpub", "webpriv")
+
+	// Create a chain: local note -> remote activity reply
+	localNoteId := uuid.New()
+	localNoteURI := "https://example.com/notes/" + localNoteId.String()
+
+	// Create local note (root)
+	_, err := db.db.Exec(`INSERT INTO notes (id, user_id, message, created_at, object_uri, reply_count)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		localNoteId, userId.String(), "Local root post", time.Now(), localNoteURI, 0)
+	if err != nil {
+		t.Fatalf("Failed to create local note: %v", err)
+	}
+
+	// Create remote activity that replies to local note
+	remoteReplyURI := "https://remote.example.com/notes/remote-reply"
+	remoteActivity := &domain.Activity{
+		Id:           uuid.New(),
+		ActivityURI:  "https://remote.example.com/activities/1",
+		ActivityType: "Create",
+		ActorURI:     "https://remote.example.com/users/alice",
+		ObjectURI:    remoteReplyURI,
+		RawJSON:      `{"type":"Create","object":{"id":"` + remoteReplyURI + `","type":"Note","content":"Remote reply","inReplyTo":"` + localNoteURI + `"}}`,
+		Processed:    true,
+		CreatedAt:    time.Now(),
+	}
+	err = db.CreateActivity(remoteActivity)
+	if err != nil {
+		t.Fatalf("Failed to create remote activity: %v", err)
+	}
+
+	// Increment reply count for local note (simulates receiving the remote reply)
+	err = db.IncrementReplyCountByURI(localNoteURI)
+	if err != nil {
+		t.Fatalf("IncrementReplyCountByURI failed: %v", err)
+	}
+
+	// Verify local note has reply_count = 1
+	var localCount int
+	err = db.db.QueryRow(`SELECT reply_count FROM notes WHERE id = ?`, localNoteId.String()).Scan(&localCount)
+	if err != nil {
+		t.Fatalf("Failed to query local note: %v", err)
+	}
+	if localCount != 1 {
+		t.Errorf("Expected local note reply_count = 1, got %d", localCount)
+	}
+}
+
+func TestCreateNote_SetsObjectURI(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+	conf := &util.AppConfig{}
+	conf.Conf.SslDomain = "local.example.com"
+	db.SetConfig(conf)
+
+	userId := uuid.New()
+	createTestAccount(t, db, userId, "testuser", "pubkey", "webpub", "webpriv")
+
+	parentId, err := db.CreateNote(userId, "Parent")
+	if err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+	parentURI := util.BuildNoteObjectURI(conf, parentId)
+
+	parent, err := db.ReadNoteByURI(parentURI)
+	if err != nil || parent == nil || parent.Id != parentId {
+		t.Fatalf("Expected note to be found by its object URI %s: %v", parentURI, err)
+	}
+
+	// A reply to the object URI increments the parent through the column alone
+	if _, err := db.CreateNoteWithReply(userId, "Reply", parentURI); err != nil {
+		t.Fatalf("Failed to create reply: %v", err)
+	}
+	var count int
+	if err := db.db.QueryRow(`SELECT reply_count FROM notes WHERE id = ?`, parentId.String()).Scan(&count); err != nil {
+		t.Fatalf("Failed to query note: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected reply_count = 1, got %d", count)
+	}
+	if total, _ := db.CountTotalRepliesByNoteId(parentId); total != 1 {
+		t.Errorf("Expected 1 total reply, got %d", total)
+	}
+}
+
+func TestBackfillNoteObjectURIs(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	userId := uuid.New()
+	createTestAccount(t, db, userId, "testuser", "pubkey", "webpub", "webpriv")
+
+	// Notes created before object_uri was always set
+	noteId := uuid.New()
+	if _, err := db.db.Exec(`INSERT INTO notes (id, user_id, message, created_at) VALUES (?, ?, ?, ?)`,
+		noteId.String(), userId.String(), "Old note", time.Now()); err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+	keptURI := "https://old.example.com/notes/kept"
+	keptId := uuid.New()
+	if _, err := db.db.Exec(`INSERT INTO notes (id, user_id, message, created_at, object_uri) VALUES (?, ?, ?, ?, ?)`,
+		keptId.String(), userId.String(), "Kept", time.Now(), keptURI); err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+
+	conf := &util.AppConfig{}
+	conf.Conf.SslDomain = "local.example.com"
+	db.SetConfig(conf)
+	if err := db.wrapTransaction(db.backfillNoteObjectURIs); err != nil {
+		t.Fatalf("backfillNoteObjectURIs failed: %v", err)
+	}
+
+	for id, want := range map[uuid.UUID]string{noteId: util.BuildNoteObjectURI(conf, noteId), keptId: keptURI} {
+		var objectURI string
+		if err := db.db.QueryRow(`SELECT object_uri FROM notes WHERE id = ?`, id.String()).Scan(&objectURI); err != nil {
+			t.Fatalf("Failed to query note: %v", err)
+		}
+		if objectURI != want {
+			t.Errorf("Expected object_uri %s, got %s", want, objectURI)
+		}
+	}
+}
+
+func TestCreateNote_NotConfigured(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+	db.SetConfig(nil)
+
+	userId := uuid.New()
+	createTestAccount(t, db, userId, "testuser", "pubkey", "webpub", "webpriv")
+
+	// Without a config the note would be stored without an object URI
+	if _, err := db.CreateNote(userId, "Note"); !errors.Is(err, ErrNotConfigured) {
+		t.Fatalf("Expected ErrNotConfigured, got %v", err)
+	}
+	notes, err := db.ReadNotesByUserId(userId)
+	if err != nil {
+		t.Fatalf("Failed to read notes: %v", err)
+	}
+	if notes != nil && len(*notes) != 0 {
+		t.Errorf("Expected no note to be stored, got %d", len(*notes))
+	}
+}
+
+func TestThreadReplyCountMode(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+	conf := &util.AppConfig{}
+	conf.Conf.SslDomain = "local.example.com"
+	conf.Conf.ReplyCountMode = util.ReplyCountModeThread
+	db.SetConfig(conf)
+
+	userId := uuid.New()
+	createTestAccount(t, db, userId, "testuser", "pubkey", "webpub", "webpriv")
+
+	// root <- reply1 (via local: URI) <- reply2 <- remote reply
+	rootId, err := db.CreateNote(userId, "Root")
+	if err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+	rootURI := util.BuildNoteObjectURI(conf, rootId)
+	reply1Id, err := db.CreateNoteWithReply(userId, "Reply 1", "local:"+rootId.String())
+	if err != nil {
+		t.Fatalf("Failed to create reply: %v", err)
+	}
+	reply2Id, err := db.CreateNoteWithReply(userId, "Reply 2", util.BuildNoteObjectURI(conf, reply1Id))
+	if err != nil {
+		t.Fatalf("Failed to create reply: %v", err)
+	}
+	remoteURI := "https://remote.example.com/notes/1"
+	if err := db.CreateActivity(&domain.Activity{
+		Id:           uuid.New(),
+		ActivityURI:  "https://remote.example.com/activities/1",
+		ActivityType: "Create",
+		ActorURI:     "https://remote.example.com/users/bob",
+		ObjectURI:    remoteURI,
+		RawJSON:      `{"type":"Create","object":{"id":"` + remoteURI + `","type":"Note","inReplyTo":"` + util.BuildNoteObjectURI(conf, reply2Id) + `"}}`,
+		CreatedAt:    time.Now(),
+	}); err != nil {
+		t.Fatalf("Failed to create activity: %v", err)
+	}
+	if err := db.IncrementReplyCountByURI(util.BuildNoteObjectURI(conf, reply2Id)); err != nil {
+		t.Fatalf("IncrementReplyCountByURI failed: %v", err)
+	}
+
+	// Every reply points at the root; the stored counters are left alone
+	for _, id := range []uuid.UUID{reply1Id, reply2Id} {
+		var threadRootURI string
+		db.db.QueryRow(`SELECT thread_root_uri FROM notes WHERE id = ?`, id.String()).Scan(&threadRootURI)
+		if threadRootURI != rootURI {
+			t.Errorf("Expected thread root %s, got %s", rootURI, threadRootURI)
+		}
+	}
+	var activityRootURI string
+	db.db.QueryRow(`SELECT thread_root_uri FROM activities WHERE object_uri = ?`, remoteURI).Scan(&activityRootURI)
+	if activityRootURI != rootURI {
+		t.Errorf("Expected activity thread root %s, got %s", rootURI, activityRootURI)
+	}
+	var stored int
+	db.db.QueryRow(`SELECT reply_count FROM notes WHERE id = ?`, rootId.String()).Scan(&stored)
+	if stored != 0 {
+		t.Errorf("Expected stored reply_count to stay 0, got %d", stored)
+	}
+
+	if count, err := db.CountThreadReplies(rootURI); err != nil || count != 3 {
+		t.Errorf("Expected 3 thread replies, got %d (%v)", count, err)
+	}
+	posts, err := db.ReadHomeTimelinePosts(userId, 10)
+	if err != nil {
+		t.Fatalf("ReadHomeTimelinePosts failed: %v", err)
+	}
+	if len(*posts) != 1 || (*posts)[0].ReplyCount != 3 {
+		t.Fatalf("Expected the root with 3 replies on the home timeline, got %+v", *posts)
+	}
+
+	// Deleted replies drop out of the count
+	if err := db.DeleteNoteById(reply2Id); err != nil {
+		t.Fatalf("DeleteNoteById failed: %v", err)
+	}
+	if count, _ := db.CountThreadReplies(rootURI); count != 2 {
+		t.Errorf("Expected 2 thread replies after deleting one, got %d", count)
+	}
+}
+
+func TestRecalculateReplyCounts(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	userId := uuid.New()
+	createTestAccount(t, db, userId, "testuser", "pubkey", "webpub", "webpriv")
+
+	rootURI := "https://example.com/notes/root"
+	reply1URI := "https://example.com/notes/reply1"
+	rootId, reply1Id, reply2Id := uuid.New(), uuid.New(), uuid.New()
+	// Drifted counters and no thread roots, as left by an interrupted write
+	for _, n := range []struct {
+		id        uuid.UUID
+		objectURI string
+		inReplyTo string
+		count     int
+	}{
+		{rootId, rootURI, "", 7},
+		{reply1Id, reply1URI, rootURI, 0},
+		{reply2Id, "https://example.com/notes/reply2", reply1URI, 3},
+	} {
+		if _, err := db.db.Exec(`INSERT INTO notes (id, user_id, message, created_at, object_uri, in_reply_to_uri, reply_count)
+			VALUES (?, ?, ?, ?, ?, NULLIF(?, ''), ?)`,
+			n.id.String(), userId.String(), "Note", time.Now(), n.objectURI, n.inReplyTo, n.count); err != nil {
+			t.Fatalf("Failed to create note: %v", err)
+		}
+	}
+
+	if err := db.RecalculateReplyCounts(); err != nil {
+		t.Fatalf("RecalculateReplyCounts failed: %v", err)
+	}
+
+	for id, want := range map[uuid.UUID]int{rootId: 2, reply1Id: 1, reply2Id: 0} {
+		var count int
+		db.db.QueryRow(`SELECT reply_count FROM notes WHERE id = ?`, id.String()).Scan(&count)
+		if count != want {
+			t.Errorf("Expected reply_count %d for %s, got %d", want, id, count)
+		}
+	}
+	if count, _ := db.CountThreadReplies(rootURI); count != 2 {
+		t.Errorf("Expected 2 thread replies after recalculation, got %d", count)
+	}
+}
+
+func TestRecalculateCounts(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	userId := uuid.New()
+	createTestAccount(t, db, userId, "testuser", "pubkey", "webpub", "webpriv")
+
+	rootURI := "https://example.com/notes/root"
+	remoteURI := "https://remote.example/notes/1"
+	rootId, replyId := uuid.New(), uuid.New()
+	// Drifted counters: the root has one like, one boost and two replies (a local note and a
+	// remote reply to that note), the remote post one like and no replies
+	for _, n := range []struct {
+		id        uuid.UUID
+		objectURI string
+		inReplyTo string
+		count     int
+	}{
+		{rootId, rootURI, "", 5},
+		{replyId, "https://example.com/notes/reply", rootURI, 2},
+	} {
+		if _, err := db.db.Exec(`INSERT INTO notes (id, user_id, message, created_at, object_uri, in_reply_to_uri, reply_count, like_count, boost_count)
+			VALUES (?, ?, ?, ?, ?, NULLIF(?, ''), ?, ?, ?)`,
+			n.id.String(), userId.String(), "Note", time.Now(), n.objectURI, n.inReplyTo, n.count, n.count, n.count); err != nil {
+			t.Fatalf("Failed to create note: %v", err)
+		}
+	}
+	for _, a := range []struct {
+		objectURI string
+		rawJSON   string
+	}{
+		{remoteURI, `{"type":"Create","object":{"id":"` + remoteURI + `"}}`},
+		{"https://remote.example/notes/2", `{"type":"Create","object":{"id":"https://remote.example/notes/2","inReplyTo":"https://example.com/notes/reply"}}`},
+	} {
+		if _, err := db.db.Exec(`INSERT INTO activities (id, activity_uri, activity_type, actor_uri, object_uri, raw_json, reply_count, like_count)
+			VALUES (?, ?, 'Create', 'https://remote.example/users/bob', ?, ?, 4, 4)`,
+			uuid.New().String(), a.objectURI+"/activity", a.objectURI, a.rawJSON); err != nil {
+			t.Fatalf("Failed to create activity: %v", err)
+		}
+	}
+	db.db.Exec(`INSERT INTO likes (id, account_id, note_id, uri) VALUES (?, ?, ?, 'like-1')`, uuid.New().String(), userId.String(), rootId.String())
+	db.db.Exec(`INSERT INTO likes (id, account_id, note_id, uri, object_uri) VALUES (?, ?, ?, 'like-2', ?)`, uuid.New().String(), userId.String(), uuid.New().String(), remoteURI)
+	db.db.Exec(`INSERT INTO boosts (id, account_id, note_id, uri) VALUES (?, ?, ?, 'boost-1')`, uuid.New().String(), userId.String(), rootId.String())
+
+	result, err := db.RecalculateCounts()
+	if err != nil {
+		t.Fatalf("RecalculateCounts failed: %v", err)
+	}
+	// Both notes' like and boost counts, both activities' like counts, and all four reply counts
+	if result != (RecountResult{LikeCounts: 4, BoostCounts: 2, ReplyCounts: 4}) {
+		t.Errorf("Unexpected corrections: %+v", result)
+	}
+
+	for _, want := range []struct {
+		query string
+		arg   string
+		count int
+	}{
+		{`SELECT reply_count FROM notes WHERE id = ?`, rootId.String(), 2},
+		{`SELECT reply_count FROM notes WHERE id = ?`, replyId.String(), 1},
+		{`SELECT like_count FROM notes WHERE id = ?`, rootId.String(), 1},
+		{`SELECT like_count FROM notes WHERE id = ?`, replyId.String(), 0},
+		{`SELECT boost_count FROM notes WHERE id = ?`, rootId.String(), 1},
+		{`SELECT like_count FROM activities WHERE object_uri = ?`, remoteURI, 1},
+		{`SELECT reply_count FROM activities WHERE object_uri = ?`, remoteURI, 0},
+	} {
+		var count int
+		db.db.QueryRow(want.query, want.arg).Scan(&count)
+		if count != want.count {
+			t.Errorf("%s [%s]: expected %d, got %d", want.query, want.arg, want.count, count)
+		}
+	}
+
+	// Counts that are already right are left alone
+	if result, err := db.RecalculateCounts(); err != nil || result != (RecountResult{}) {
+		t.Errorf("Expected no corrections on a second run, got %+v, %v", result, err)
+	}
+}
+
+func TestIncrementReplyCount_CycleDetection(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	// Create user
+	userId := uuid.New()
+	createTestAccount(t, db, userId, "testuser", "pubkey", "webpub", "webpriv")
+
+	// Create a note that references itself (artificial cycle)
+	noteId := uuid.New()
+	noteURI := "https://example.com/notes/" + noteId.String()
+
+	_, err := db.db.Exec(`INSERT INTO notes (id, user_id, message, created_at, object_uri, in_reply_to_uri, reply_count)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		noteId, userId.String(), "Self-referencing note", time.Now(), noteURI, noteURI, 0)
+	if err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+
+	// This should not cause infinite recursion due to visited map
+	err = db.IncrementReplyCountByURI(noteURI)
+	if err != nil {
+		t.Fatalf("IncrementReplyCountByURI should handle cycles: %v", err)
+	}
+
+	// Verify it was only incremented once
+	var count int
+	err = db.db.QueryRow(`SELECT reply_count FROM notes WHERE id = ?`, noteId.String()).Scan(&count)
+	if err != nil {
+		t.Fatalf("Failed to query note: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected reply_count = 1 (not double-counted), got %d", count)
+	}
+}
+
+func TestCountActivitiesByInReplyTo_Basic(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	parentURI := "https://example.com/notes/parent"
+
+	// Create 3 activities that reply to the parent
+	for i := 0; i < 3; i++ {
+		activity := &domain.Activity{
+			Id:           uuid.New(),
+			ActivityURI:  "https://remote.example.com/activities/" + uuid.New().String(),
+			ActivityType: "Create",
+			ActorURI:     "https://remote.example.com/users/alice",
+			ObjectURI:    "https://remote.example.com/notes/" + uuid.New().String(),
+			RawJSON:      `{"type":"Create","object":{"inReplyTo":"` + parentURI + `"}}`,
+			Processed:    true,
+			CreatedAt:    time.Now(),
+		}
+		if err := db.CreateActivity(activity); err != nil {
+			t.Fatalf("Failed to create activity %d: %v", i, err)
+		}
+	}
+
+	// Count replies
+	count, err := db.CountActivitiesByInReplyTo(parentURI)
+	if err != nil {
+		t.Fatalf("CountActivitiesByInReplyTo failed: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("Expected 3 replies, got %d", count)
+	}
+}
+
+func TestCountActivitiesByInReplyTo_ExcludesDuplicates(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	// Create user for local note
+	userId := uuid.New()
+	createTestAccount(t, db, userId, "testuser", "pubkey", "webpub", "webpriv")
+
+	parentURI := "https://example.com/notes/parent"
+	localNoteId := uuid.New()
+	localNoteURI := "https://example.com/notes/" + localNoteId.String()
+
+	// Create a local note that replies to parent
+	_, err := db.db.Exec(`INSERT INTO notes (id, user_id, message, created_at, object_uri, in_reply_to_uri)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		localNoteId, userId.String(), "Local reply", time.Now(), localNoteURI, parentURI)
+	if err != nil {
+		t.Fatalf("Failed to create local note: %v", err)
+	}
+
+	// Create a remote activity that is a DUPLICATE of the local note
+	// (same object_uri - this happens when a local post federates back)
+	duplicateActivity := &domain.Activity{
+		Id:           uuid.New(),
+		ActivityURI:  "https://remote.example.com/activities/" + uuid.New().String(),
+		ActivityType: "Create",
+		ActorURI:     "https://remote.example.com/users/alice",
+		ObjectURI:    localNoteURI, // Same as local note!
+		RawJSON:      `{"type":"Create","object":{"id":"` + localNoteURI + `","inReplyTo":"` + parentURI + `"}}`,
+		Processed:    true,
+		CreatedAt:    time.Now(),
+	}
+	if err := db.CreateActivity(duplicateActivity); err != nil {
+		t.Fatalf("Failed to create duplicate activity: %v", err)
+	}
+
+	// Create a genuine remote activity (not a duplicate)
+	genuineActivity := &domain.Activity{
+		Id:           uuid.New(),
+		ActivityURI:  "https://other-remote.example.com/activities/" + uuid.New().String(),
+		ActivityType: "Create",
+		ActorURI:     "https://other-remote.example.com/users/bob",
+		ObjectURI:    "https://other-remote.example.com/notes/" + uuid.New().String(),
+		RawJSON:      `{"type":"Create","object":{"inReplyTo":"` + parentURI + `"}}`,
+		Processed:    true,
+		CreatedAt:    time.Now(),
+	}
+	if err := db.CreateActivity(genuineActivity); err != nil {
+		t.Fatalf("Failed to create genuine activity: %v", err)
+	}
+
+	// Count should be 1 (only the genuine activity, not the duplicate)
+	count, err := db.CountActivitiesByInReplyTo(parentURI)
+	if err != nil {
+		t.Fatalf("CountActivitiesByInReplyTo failed: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 reply (excluding duplicate), got %d", count)
+	}
+}
+
+func TestCountActivitiesByInReplyTo_ExcludesDuplicatesByNoteIdPattern(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	// Create user for local note
+	userId := uuid.New()
+	createTestAccount(t, db, userId, "testuser", "pubkey", "webpub", "webpriv")
+
+	parentURI := "https://example.com/notes/parent"
+	localNoteId := uuid.New()
+
+	// Create a local note WITHOUT object_uri (to test the /notes/{uuid} pattern matching)
+	_, err := db.db.Exec(`INSERT INTO notes (id, user_id, message, created_at, in_reply_to_uri)
+		VALUES (?, ?, ?, ?, ?)`,
+		localNoteId, userId.String(), "Local reply", time.Now(), parentURI)
+	if err != nil {
+		t.Fatalf("Failed to create local note: %v", err)
+	}
+
+	// Create a remote activity that references the local note by ID pattern
+	duplicateActivity := &domain.Activity{
+		Id:           uuid.New(),
+		ActivityURI:  "https://remote.example.com/activities/" + uuid.New().String(),
+		ActivityType: "Create",
+		ActorURI:     "https://remote.example.com/users/alice",
+		ObjectURI:    "https://example.com/notes/" + localNoteId.String(), // Contains the note ID
+		RawJSON:      `{"type":"Create","object":{"inReplyTo":"` + parentURI + `"}}`,
+		Processed:    true,
+		CreatedAt:    time.Now(),
+	}
+	if err := db.CreateActivity(duplicateActivity); err != nil {
+		t.Fatalf("Failed to create duplicate activity: %v", err)
+	}
+
+	// Create a genuine remote activity (not a duplicate)
+	genuineActivity := &domain.Activity{
+		Id:           uuid.New(),
+		ActivityURI:  "https://other-remote.example.com/activities/" + uuid.New().String(),
+		ActivityType: "Create",
+		ActorURI:     "https://other-remote.example.com/users/bob",
+		ObjectURI:    "https://other-remote.example.com/notes/" + uuid.New().String(),
+		RawJSON:      `{"type":"Create","object":{"inReplyTo":"` + parentURI + `"}}`,
+		Processed:    true,
+		CreatedAt:    time.Now(),
+	}
+	if err := db.CreateActivity(genuineActivity); err != nil {
+		t.Fatalf("Failed to create genuine activity: %v", err)
+	}
+
+	// Count should be 1 (only the genuine activity, not the duplicate)
+	count, err := db.CountActivitiesByInReplyTo(parentURI)
+	if err != nil {
+		t.Fatalf("CountActivitiesByInReplyTo failed: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 reply (excluding duplicate by ID pattern), got %d", count)
+	}
+}
+
+func TestCountActivitiesByInReplyTo_SpaceVariants(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	parentURI := "https://example.com/notes/parent"
+
+	// Create activity with "inReplyTo" (no space)
+	activity1 := &domain.Activity{
+		Id:           uuid.New(),
+		ActivityURI:  "https://remote.example.com/activities/" + uuid.New().String(),
+		ActivityType: "Create",
+		ActorURI:     "https://remote.example.com/users/alice",
+		ObjectURI:    "https://remote.example.com/notes/1",
+		RawJSON:      `{"type":"Create","object":{"inReplyTo":"` + parentURI + `"}}`,
+		Processed:    true,
+		CreatedAt:    time.Now(),
+	}
+	if err := db.CreateActivity(activity1); err != nil {
+		t.Fatalf("Failed to create activity1: %v", err)
+	}
+
+	// Create activity with "inReplyTo": (with space)
+	activity2 := &domain.Activity{
+		Id:           uuid.New(),
+		ActivityURI:  "https://remote.example.com/activities/" + uuid.New().String(),
+		ActivityType: "Create",
+		ActorURI:     "https://remote.example.com/users/bob",
+		ObjectURI:    "https://remote.example.com/notes/2",
+		RawJSON:      `{"type":"Create","object":{"inReplyTo": "` + parentURI + `"}}`,
+		Processed:    true,
+		CreatedAt:    time.Now(),
+	}
+	if err := db.CreateActivity(activity2); err != nil {
+		t.Fatalf("Failed to create activity2: %v", err)
+	}
+
+	// Both should be counted
+	count, err := db.CountActivitiesByInReplyTo(parentURI)
+	if err != nil {
+		t.Fatalf("CountActivitiesByInReplyTo failed: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 replies (both space variants), got %d", count)
+	}
+}
+
+func TestCountActivitiesByInReplyTo_OnlyCountsCreateType(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	parentURI := "https://example.com/notes/parent"
+
+	// Create a "Create" activity
+	createActivity := &domain.Activity{
+		Id:           uuid.New(),
+		ActivityURI:  "https://remote.example.com/activities/create",
+		ActivityType: "Create",
+		ActorURI:     "https://remote.example.com/users/alice",
+		ObjectURI:    "https://remote.example.com/notes/1",
+		RawJSON:      `{"type":"Create","object":{"inReplyTo":"` + parentURI + `"}}`,
+		Processed:    true,
+		CreatedAt:    time.Now(),
+	}
+	if err := db.CreateActivity(createActivity); err != nil {
+		t.Fatalf("Failed to create Create activity: %v", err)
+	}
+
+	// Create a "Like" activity (should not be counted)
+	likeActivity := &domain.Activity{
+		Id:           uuid.New(),
+		ActivityURI:  "https://remote.example.com/activities/like",
+		ActivityType: "Like",
+		ActorURI:     "https://remote.example.com/users/bob",
+		ObjectURI:    "https://remote.example.com/notes/2",
+		RawJSON:      `{"type":"Like","object":{"inReplyTo":"` + parentURI + `"}}`,
+		Processed:    true,
+		CreatedAt:    time.Now(),
+	}
+	if err := db.CreateActivity(likeActivity); err != nil {
+		t.Fatalf("Failed to create Like activity: %v", err)
+	}
+
+	// Only the Create should be counted
+	count, err := db.CountActivitiesByInReplyTo(parentURI)
+	if err != nil {
+		t.Fatalf("CountActivitiesByInReplyTo failed: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 reply (only Create type), got %d", count)
+	}
+}
+
+// Tests for remote post likes with object_uri
+
+func TestReadLikedObjectURIs(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	accountId := uuid.New()
+	createTestAccount(t, db, accountId, "alice", "pubkey", "webpub", "webpriv")
+	noteId, err := db.CreateNote(accountId, "Local note")
+	if err != nil {
+		t.Fatalf("Failed to create note: %v", err)
+	}
+
+	if err := db.CreateLike(&domain.Like{Id: uuid.New(), AccountId: accountId, NoteId: noteId, URI: "https://local.example.com/likes/1", CreatedAt: time.Now().Add(-time.Hour)}); err != nil {
+		t.Fatalf("CreateLike failed: %v", err)
+	}
+	remoteURI := "https://remote.example.com/notes/1"
+	if err := db.CreateLikeByObjectURI(&domain.Like{Id: uuid.New(), AccountId: accountId, URI: "https://local.example.com/likes/2", CreatedAt: time.Now()}, remoteURI); err != nil {
+		t.Fatalf("CreateLikeByObjectURI failed: %v", err)
+	}
+
+	uris, err := db.ReadLikedObjectURIs(accountId, "local.example.com")
+	if err != nil {
+		t.Fatalf("ReadLikedObjectURIs failed: %v", err)
+	}
+	expected := []string{"https://local.example.com/notes/" + noteId.String(), remoteURI}
+	if strings.Join(uris, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected %v, got %v", expected, uris)
+	}
+}
+
+func TestCreateLikeByObjectURI_DeterministicPlaceholder(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	accountId := uuid.New()
+	objectURI := "https://remote.example.com/notes/123"
+
+	like := &domain.Like{
+		Id:        uuid.New(),
+		AccountId: accountId,
+		URI:       "https://local.example.com/likes/1",
+		CreatedAt: time.Now(),
+	}
+
+	// Create the like
+	err := db.CreateLikeByObjectURI(like, objectURI)
+	if err != nil {
+		t.Fatalf("CreateLikeByObjectURI failed: %v", err)
+	}
+
+	// Verify it was created with the object_uri
+	hasLike, err := db.HasLikeByObjectURI(accountId, objectURI)
+	if err != nil {
+		t.Fatalf("HasLikeByObjectURI failed: %v", err)
+	}
+	if !hasLike {
+		t.Error("Expected HasLikeByObjectURI to return true")
+	}
+
+	// Verify the placeholder note_id is deterministic (same object_uri = same placeholder)
+	expectedPlaceholder := uuid.NewSHA1(uuid.NameSpaceURL, []byte(objectURI))
+	var storedNoteId string
+	err = db.db.QueryRow("SELECT note_id FROM likes WHERE object_uri = ?", objectURI).Scan(&storedNoteId)
+	if err != nil {
+		t.Fatalf("Failed to query note_id: %v", err)
+	}
+	if storedNoteId != expectedPlaceholder.String() {
+		t.Errorf("Expected placeholder note_id %s, got %s", expectedPlaceholder.String(), storedNoteId)
+	}
+}
+
+func TestCreateLikeByObjectURI_DifferentPostsDifferentPlaceholders(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	accountId := uuid.New()
+	objectURI1 := "https://remote.example.com/notes/123"
+	objectURI2 := "https://remote.example.com/notes/456"
+
+	like1 := &domain.Like{
+		Id:        uuid.New(),
+		AccountId: accountId,
+		URI:       "https://local.example.com/likes/1",
+		CreatedAt: time.Now(),
+	}
+	like2 := &domain.Like{
+		Id:        uuid.New(),
+		AccountId: accountId,
+		URI:       "https://local.example.com/likes/2",
+		CreatedAt: time.Now(),
+	}
+
+	// Create likes for two different remote posts
+	if err := db.CreateLikeByObjectURI(like1, objectURI1); err != nil {
+		t.Fatalf("CreateLikeByObjectURI for first post failed: %v", err)
+	}
+	if err := db.CreateLikeByObjectURI(like2, objectURI2); err != nil {
+		t.Fatalf("CreateLikeByObjectURI for second post failed: %v", err)
+	}
+
+	// Verify both likes exist
+	hasLike1, _ := db.HasLikeByObjectURI(accountId, objectURI1)
+	hasLike2, _ := db.HasLikeByObjectURI(accountId, objectURI2)
+	if !hasLike1 || !hasLike2 {
+		t.Error("Expected both likes to exist")
+	}
+
+	// Verify they have different placeholder note_ids
+	var noteId1, noteId2 string
+	db.db.QueryRow("SELECT note_id FROM likes WHERE object_uri = ?", objectURI1).Scan(&noteId1)
+	db.db.QueryRow("SELECT note_id FROM likes WHERE object_uri = ?", objectURI2).Scan(&noteId2)
+	if noteId1 == noteId2 {
+		t.Errorf("Expected different placeholder note_ids for different posts, both got %s", noteId1)
+	}
+}
+
+func TestCreateLikeByObjectURI_UniqueConstraintPreventsDoubleLike(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	accountId := uuid.New()
+	objectURI := "https://remote.example.com/notes/123"
+
+	like1 := &domain.Like{
+		Id:        uuid.New(),
+		AccountId: accountId,
+		URI:       "https://local.example.com/likes/1",
+		CreatedAt: time.Now(),
+	}
+	like2 := &domain.Like{
+		Id:        uuid.New(),
+		AccountId: accountId,
+		URI:       "https://local.example.com/likes/2",
+		CreatedAt: time.Now(),
+	}
+
+	// First like should succeed
+	if err := db.CreateLikeByObjectURI(like1, objectURI); err != nil {
+		t.Fatalf("First CreateLikeByObjectURI failed: %v", err)
+	}
+
+	// Second like to same post should fail (unique constraint)
+	err := db.CreateLikeByObjectURI(like2, objectURI)
+	if err == nil {
+		t.Error("Expected second like to same post to fail with unique constraint")
+	}
+}
+
+func TestDeleteLikeByAccountAndObjectURI(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	accountId := uuid.New()
+	objectURI := "https://remote.example.com/notes/123"
+
+	like := &domain.Like{
+		Id:        uuid.New(),
+		AccountId: accountId,
+		URI:       "https://local.example.com/likes/1",
+		CreatedAt: time.Now(),
+	}
+
+	// Create the like
+	if err := db.CreateLikeByObjectURI(like, objectURI); err != nil {
+		t.Fatalf("CreateLikeByObjectURI failed: %v", err)
+	}
+
+	// Verify it exists
+	hasLike, _ := db.HasLikeByObjectURI(accountId, objectURI)
+	if !hasLike {
+		t.Fatal("Like should exist before deletion")
+	}
+
+	// Delete it
+	if err := db.DeleteLikeByAccountAndObjectURI(accountId, objectURI); err != nil {
+		t.Fatalf("DeleteLikeByAccountAndObjectURI failed: %v", err)
+	}
+
+	// Verify it's gone
+	hasLike, _ = db.HasLikeByObjectURI(accountId, objectURI)
+	if hasLike {
+		t.Error("Like should not exist after deletion")
+	}
+}
+
+func TestReadLikeByAccountAndObjectURI(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	accountId := uuid.New()
+	objectURI := "https://remote.example.com/notes/123"
+	likeId := uuid.New()
+
+	like := &domain.Like{
+		Id:        likeId,
+		AccountId: accountId,
+		URI:       "https://local.example.com/likes/1",
+		CreatedAt: time.Now(),
+	}
+
+	// Create the like
+	if err := db.CreateLikeByObjectURI(like, objectURI); err != nil {
+		t.Fatalf("CreateLikeByObjectURI failed: %v", err)
+	}
+
+	// Read it back
+	readLike, err := db.ReadLikeByAccountAndObjectURI(accountId, objectURI)
+	if err != nil {
+		t.Fatalf("ReadLikeByAccountAndObjectURI failed: %v", err)
+	}
+	if readLike == nil {
+		t.Fatal("Expected to find like")
+	}
+	if readLike.Id != likeId {
+		t.Errorf("Expected like ID %s, got %s", likeId, readLike.Id)
+	}
+	if readLike.AccountId != accountId {
+		t.Errorf("Expected account ID %s, got %s", accountId, readLike.AccountId)
+	}
+}
+
+func TestIncrementDecrementLikeCountByObjectURI(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	objectURI := "https://remote.example.com/notes/123"
+
+	// Create an activity for this object
+	activity := &domain.Activity{
+		Id:           uuid.New(),
+		ActivityURI:  "https://remote.example.com/activities/create",
+		ActivityType: "Create",
+		ActorURI:     "https://remote.example.com/users/alice",
+		ObjectURI:    objectURI,
+		RawJSON:      `{"type":"Create","object":{"id":"` + objectURI + `","content":"Hello"}}`,
+		Processed:    true,
+		CreatedAt:    time.Now(),
+	}
+	if err := db.CreateActivity(activity); err != nil {
+		t.Fatalf("Failed to create activity: %v", err)
+	}
+
+	// Increment like count
+	if err := db.IncrementLikeCountByObjectURI(objectURI); err != nil {
+		t.Fatalf("IncrementLikeCountByObjectURI failed: %v", err)
+	}
+
+	// Verify count is 1
+	var likeCount int
+	db.db.QueryRow("SELECT like_count FROM activities WHERE object_uri = ?", objectURI).Scan(&likeCount)
+	if likeCount != 1 {
+		t.Errorf("Expected like_count 1, got %d", likeCount)
+	}
+
+	// Increment again
+	db.IncrementLikeCountByObjectURI(objectURI)
+	db.db.QueryRow("SELECT like_count FROM activities WHERE object_uri = ?", objectURI).Scan(&likeCount)
+	if likeCount != 2 {
+		t.Errorf("Expected like_count 2, got %d", likeCount)
+	}
+
+	// Decrement
+	if err := db.DecrementLikeCountByObjectURI(objectURI); err != nil {
+		t.Fatalf("DecrementLikeCountByObjectURI failed: %v", err)
+	}
+	db.db.QueryRow("SELECT like_count FROM activities WHERE object_uri = ?", objectURI).Scan(&likeCount)
+	if likeCount != 1 {
+		t.Errorf("Expected like_count 1 after decrement, got %d", likeCount)
+	}
+}
+
+func TestToggleLike(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	accountId := uuid.New()
+	createTestAccount(t, db, accountId, "liker", "ssh-key", "webpub", "webpriv")
+	noteId, err := db.CreateNote(accountId, "Like me")
+	if err != nil {
+		t.Fatalf("CreateNote failed: %v", err)
+	}
+
+	newLike := func(uri string) *domain.Like {
+		return &domain.Like{Id: uuid.New(), AccountId: accountId, NoteId: noteId, URI: uri, CreatedAt: time.Now()}
+	}
+
+	result, err := db.ToggleLike(newLike("https://local.example.com/likes/1"), "")
+	if err != nil {
+		t.Fatalf("ToggleLike failed: %v", err)
+	}
+	if result != (ToggleResult{Active: true, Count: 1, URI: "https://local.example.com/likes/1"}) {
+		t.Errorf("Expected the note to be liked once, got %+v", result)
+	}
+	if liked, _ := db.HasLike(accountId, noteId); !liked {
+		t.Error("Expected a like row")
+	}
+
+	// Toggling again removes the like and returns its URI for the Undo
+	result, err = db.ToggleLike(newLike("https://local.example.com/likes/2"), "")
+	if err != nil {
+		t.Fatalf("ToggleLike failed: %v", err)
+	}
+	if result != (ToggleResult{Active: false, Count: 0, URI: "https://local.example.com/likes/1"}) {
+		t.Errorf("Expected the like to be removed, got %+v", result)
+	}
+	if liked, _ := db.HasLike(accountId, noteId); liked {
+		t.Error("Expected the like row to be deleted")
+	}
+
+	// A drifted count never goes negative
+	if _, err := db.ToggleLike(newLike("https://local.example.com/likes/3"), ""); err != nil {
+		t.Fatalf("ToggleLike failed: %v", err)
+	}
+	db.db.Exec("UPDATE notes SET like_count = 0 WHERE id = ?", noteId.String())
+	if result, err = db.ToggleLike(newLike("https://local.example.com/likes/4"), ""); err != nil || result.Count != 0 {
+		t.Errorf("Expected the count to stay at 0, got %+v, %v", result, err)
+	}
+}
+
+func TestToggleLike_RemotePost(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	accountId := uuid.New()
+	createTestAccount(t, db, accountId, "liker", "ssh-key", "webpub", "webpriv")
+	objectURI := "https://remote.example.com/notes/123"
+	activity := &domain.Activity{
+		Id:           uuid.New(),
+		ActivityURI:  "https://remote.example.com/activities/create",
+		ActivityType: "Create",
+		ActorURI:     "https://remote.example.com/users/alice",
+		ObjectURI:    objectURI,
+		RawJSON:      `{"type":"Create","object":{"id":"` + objectURI + `","content":"Hello"}}`,
+		Processed:    true,
+		CreatedAt:    time.Now(),
+	}
+	if err := db.CreateActivity(activity); err != nil {
+		t.Fatalf("Failed to create activity: %v", err)
+	}
+
+	like := &domain.Like{Id: uuid.New(), AccountId: accountId, URI: "https://local.example.com/likes/1", CreatedAt: time.Now()}
+	result, err := db.ToggleLike(like, objectURI)
+	if err != nil || !result.Active || result.Count != 1 {
+		t.Fatalf("Expected the remote post to be liked once, got %+v, %v", result, err)
+	}
+	if liked, _ := db.HasLikeByObjectURI(accountId, objectURI); !liked {
+		t.Error("Expected a like row for the object URI")
+	}
+
+	like.Id = uuid.New()
+	result, err = db.ToggleLike(like, objectURI)
+	if err != nil || result.Active || result.Count != 0 || result.URI != "https://local.example.com/likes/1" {
+		t.Errorf("Expected the like to be removed, got %+v, %v", result, err)
+	}
+	if liked, _ := db.HasLikeByObjectURI(accountId, objectURI); liked {
+		t.Error("Expected the like row to be deleted")
+	}
+}
+
+func TestToggleBoost(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	accountId := uuid.New()
+	createTestAccount(t, db, accountId, "booster", "ssh-key", "webpub", "webpriv")
+	noteId, err := db.CreateNote(accountId, "Boost me")
+	if err != nil {
+		t.Fatalf("CreateNote failed: %v", err)
+	}
+
+	boost := &domain.Boost{Id: uuid.New(), AccountId: accountId, NoteId: noteId, URI: "https://local.example.com/boosts/1", CreatedAt: time.Now()}
+	result, err := db.ToggleBoost(boost)
+	if err != nil || result != (ToggleResult{Active: true, Count: 1, URI: "https://local.example.com/boosts/1"}) {
+		t.Fatalf("Expected the note to be boosted once, got %+v, %v", result, err)
+	}
+	if boosted, _ := db.HasBoost(accountId, noteId); !boosted {
+		t.Error("Expected a boost row")
+	}
+
+	boost.Id = uuid.New()
+	result, err = db.ToggleBoost(boost)
+	if err != nil || result != (ToggleResult{Active: false, Count: 0, URI: "https://local.example.com/boosts/1"}) {
+		t.Errorf("Expected the boost to be removed, got %+v, %v", result, err)
+	}
+	if boosted, _ := db.HasBoost(accountId, noteId); boosted {
+		t.Error("Expected the boost row to be deleted")
+	}
+}
+
+func TestReadActivitiesByInReplyTo_IncludesLikeAndBoostCounts(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	parentURI := "https://example.com/notes/parent"
+	replyURI := "https://remote.example.com/notes/reply1"
+
+	// Create a reply activity with like and boost counts
+	activity := &domain.Activity{
+		Id:           uuid.New(),
+		ActivityURI:  "https://remote.example.com/activities/create",
+		ActivityType: "Create",
+		ActorURI:     "https://remote.example.com/users/alice",
+		ObjectURI:    replyURI,
+		RawJSON:      `{"type":"Create","object":{"id":"` + replyURI + `","inReplyTo":"` + parentURI + `","content":"Reply"}}`,
+		Processed:    true,
+		CreatedAt:    time.Now(),
+	}
+	if err := db.CreateActivity(activity); err != nil {
+		t.Fatalf("Failed to create activity: %v", err)
+	}
+
+	// Set like and boost counts directly
+	db.db.Exec("UPDATE activities SET like_count = 5, boost_count = 3 WHERE object_uri = ?", replyURI)
+
+	// Read activities by in_reply_to
+	activities, err := db.ReadActivitiesByInReplyTo(parentURI)
+	if err != nil {
+		t.Fatalf("ReadActivitiesByInReplyTo failed: %v", err)
+	}
+	if activities == nil || len(*activities) != 1 {
+		t.Fatalf("Expected 1 activity, got %v", activities)
+	}
+
+	reply := (*activities)[0]
+	if reply.LikeCount != 5 {
+		t.Errorf("Expected LikeCount 5, got %d", reply.LikeCount)
+	}
+	if reply.BoostCount != 3 {
+		t.Errorf("Expected BoostCount 3, got %d", reply.BoostCount)
+	}
+}
+
+func TestReadActivityByObjectURI_IncludesLikeAndBoostCounts(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	objectURI := "https://remote.example.com/notes/123"
+
+	// Create an activity
+	activity := &domain.Activity{
+		Id:           uuid.New(),
+		ActivityURI:  "https://remote.example.com/activities/create",
+		ActivityType: "Create",
+		ActorURI:     "https://remote.example.com/users/alice",
+		ObjectURI:    objectURI,
+		RawJSON:      `{"type":"Create","object":{"id":"` + objectURI + `","content":"Hello"}}`,
+		Processed:    true,
+		CreatedAt:    time.Now(),
+	}
+	if err := db.CreateActivity(activity); err != nil {
+		t.Fatalf("Failed to create activity: %v", err)
+	}
+
+	// Set like and boost counts
+	db.db.Exec("UPDATE activities SET like_count = 10, boost_count = 7 WHERE object_uri = ?", objectURI)
+
+	// Read the activity by object URI
+	readActivity, err := db.ReadActivityByObjectURI(objectURI)
+	if err != nil {
+		t.Fatalf("ReadActivityByObjectURI failed: %v", err)
+	}
+	if readActivity == nil {
+		t.Fatal("Expected to find activity")
+	}
+
+	if readActivity.LikeCount != 10 {
+		t.Errorf("Expected LikeCount 10, got %d", readActivity.LikeCount)
+	}
+	if readActivity.BoostCount != 7 {
+		t.Errorf("Expected BoostCount 7, got %d", readActivity.BoostCount)
+	}
+}
+
+func TestReadHomeTimelinePosts_RemotePostsIncludeLikeAndBoostCounts(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	// Create a local account
+	localAccountId := uuid.New()
+	createTestAccount(t, db, localAccountId, "localuser", "ssh-key", "webpub", "webpriv")
+
+	// Create a remote account
+	remoteAccountId := uuid.New()
+	_, err := db.db.Exec(`INSERT INTO remote_accounts(id, username, domain, actor_uri, inbox_uri) VALUES (?, ?, ?, ?, ?)`,
+		remoteAccountId.String(), "remoteuser", "remote.example.com",
+		"https://remote.example.com/users/remoteuser",
+		"https://remote.example.com/users/remoteuser/inbox")
+	if err != nil {
+		t.Fatalf("Failed to create remote account: %v", err)
+	}
+
+	// Create a follow relationship
+	followId := uuid.New()
+	_, err = db.db.Exec(`INSERT INTO follows(id, account_id, target_account_id, accepted, is_local) VALUES (?, ?, ?, 1, 0)`,
+		followId.String(), localAccountId.String(), remoteAccountId.String())
+	if err != nil {
+		t.Fatalf("Failed to create follow: %v", err)
+	}
+
+	// Create an activity from the remote user
+	objectURI := "https://remote.example.com/notes/123"
+	activity := &domain.Activity{
+		Id:           uuid.New(),
+		ActivityURI:  "https://remote.example.com/activities/create",
+		ActivityType: "Create",
+		ActorURI:     "https://remote.example.com/users/remoteuser",
+		ObjectURI:    objectURI,
+		RawJSON:      `{"type":"Create","object":{"id":"` + objectURI + `","content":"Hello from remote","inReplyTo":null}}`,
+		Processed:    true,
+		CreatedAt:    time.Now(),
+		Local:        false,
+	}
+	if err := db.CreateActivity(activity); err != nil {
+		t.Fatalf("Failed to create activity: %v", err)
+	}
+
+	// Set like and boost counts
+	db.db.Exec("UPDATE activities SET like_count = 15, boost_count = 8 WHERE object_uri = ?", objectURI)
+
+	// Read home timeline
+	posts, err := db.ReadHomeTimelinePosts(localAccountId, 10)
+	if err != nil {
+		t.Fatalf("ReadHomeTimelinePosts failed: %v", err)
+	}
+
+	// Find the remote post
+	var remotePost *domain.HomePost
+	for i := range *posts {
+		if (*posts)[i].ObjectURI == objectURI {
+			remotePost = &(*posts)[i]
+			break
+		}
+	}
+
+	if remotePost == nil {
+		t.Fatal("Expected to find remote post in home timeline")
+	}
+
+	if remotePost.LikeCount != 15 {
+		t.Errorf("Expected LikeCount 15, got %d", remotePost.LikeCount)
+	}
+	if remotePost.BoostCount != 8 {
+		t.Errorf("Expected BoostCount 8, got %d", remotePost.BoostCount)
+	}
+}
+
+func TestLanguageSettings(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	accountId := uuid.New()
+	createTestAccount(t, db, accountId, "alice", "ssh-key", "webpub", "webpriv")
+
+	settings, err := db.ReadLanguageSettings(accountId)
+	if err != nil {
+		t.Fatalf("ReadLanguageSettings failed: %v", err)
+	}
+	if settings.DefaultLanguage != "" || len(settings.Languages) != 0 {
+		t.Errorf("Expected empty settings for a new account, got %+v", settings)
+	}
+
+	err = db.UpdateLanguageSettings(accountId, &domain.LanguageSettings{DefaultLanguage: "de-AT", Languages: []string{"EN", "de", "nope1", "en-GB"}})
+	if err != nil {
+		t.Fatalf("UpdateLanguageSettings failed: %v", err)
+	}
+
+	settings, err = db.ReadLanguageSettings(accountId)
+	if err != nil {
+		t.Fatalf("ReadLanguageSettings failed: %v", err)
+	}
+	if settings.DefaultLanguage != "de" {
+		t.Errorf("Expected default language de, got %q", settings.DefaultLanguage)
+	}
+	if strings.Join(settings.Languages, ",") != "en,de" {
+		t.Errorf("Expected languages [en de], got %v", settings.Languages)
+	}
+
+	// New notes are tagged with the author's default language
+	noteId, err := db.CreateNote(accountId, "Hallo Welt")
+	if err != nil {
+		t.Fatalf("CreateNote failed: %v", err)
+	}
+	note, err := db.ReadNoteIdWithReplyInfo(noteId)
+	if err != nil {
+		t.Fatalf("ReadNoteIdWithReplyInfo failed: %v", err)
+	}
+	if note.Language != "de" {
+		t.Errorf("Expected note language de, got %q", note.Language)
+	}
+}
+
+func TestTimezone(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	accountId := uuid.New()
+	createTestAccount(t, db, accountId, "alice", "ssh-key", "webpub", "webpriv")
+
+	timezone, err := db.ReadTimezone(accountId)
+	if err != nil {
+		t.Fatalf("ReadTimezone failed: %v", err)
+	}
+	if timezone != "" {
+		t.Errorf("Expected no timezone for a new account, got %q", timezone)
+	}
+
+	if err := db.SetTimezone(accountId, "America/New_York"); err != nil {
+		t.Fatalf("SetTimezone failed: %v", err)
+	}
+	if timezone, _ := db.ReadTimezone(accountId); timezone != "America/New_York" {
+		t.Errorf("Expected America/New_York, got %q", timezone)
+	}
+
+	// Unknown zones are rejected and leave the setting alone
+	if err := db.SetTimezone(accountId, "Nowhere/Special"); err == nil {
+		t.Error("Expected an unknown timezone to be rejected")
+	}
+	if timezone, _ := db.ReadTimezone(accountId); timezone != "America/New_York" {
+		t.Errorf("Expected the timezone to be unchanged, got %q", timezone)
+	}
+
+	if err := db.SetTimezone(accountId, ""); err != nil {
+		t.Fatalf("SetTimezone failed: %v", err)
+	}
+	if timezone, _ := db.ReadTimezone(accountId); timezone != "" {
+		t.Errorf("Expected the timezone to be reset, got %q", timezone)
+	}
+}
+
+func TestNoteTimestampsIgnoreServerTimezone(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	local := time.Local
+	time.Local = time.FixedZone("UTC+5", 5*60*60)
+	defer func() { time.Local = local }()
+
+	accountId := uuid.New()
+	createTestAccount(t, db, accountId, "alice", "ssh-key", "webpub", "webpriv")
+
+	noteId, err := db.CreateNote(accountId, "Hello")
+	if err != nil {
+		t.Fatalf("CreateNote failed: %v", err)
+	}
+	note, err := db.ReadNoteIdWithReplyInfo(noteId)
+	if err != nil {
+		t.Fatalf("ReadNoteIdWithReplyInfo failed: %v", err)
+	}
+	if d := time.Since(note.CreatedAt); d < -time.Minute || d > time.Minute {
+		t.Errorf("Expected the note to be created just now, got %v (%v ago)", note.CreatedAt, d)
+	}
+
+	for _, stored := range []string{"2024-03-01 12:30:00", "2024-03-01T12:30:00Z"} {
+		parsed, err := parseTimestamp(stored)
+		if err != nil {
+			t.Fatalf("parseTimestamp(%q) failed: %v", stored, err)
+		}
+		if !parsed.Equal(time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)) {
+			t.Errorf("Expected %q to be read as UTC, got %v", stored, parsed)
+		}
+	}
+}
+
+func TestUpdateManuallyApprovesFollowers(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	accountId := uuid.New()
+	createTestAccount(t, db, accountId, "alice", "ssh-key", "webpub", "webpriv")
+
+	acc, err := db.ReadAccById(accountId)
+	if err != nil {
+		t.Fatalf("ReadAccById failed: %v", err)
+	}
+	if acc.ManuallyApprovesFollowers {
+		t.Error("New accounts should accept followers automatically")
+	}
+
+	if err := db.UpdateManuallyApprovesFollowers(accountId, true); err != nil {
+		t.Fatalf("UpdateManuallyApprovesFollowers failed: %v", err)
+	}
+	acc, err = db.ReadAccByUsername("alice")
+	if err != nil {
+		t.Fatalf("ReadAccByUsername failed: %v", err)
+	}
+	if !acc.ManuallyApprovesFollowers {
+		t.Error("Expected account to manually approve followers")
+	}
+}
+
+func TestReadAllNotes_Discoverable(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	aliceId, bobId := uuid.New(), uuid.New()
+	createTestAccount(t, db, aliceId, "alice", "pubkey1", "webpub1", "webpriv1")
+	createTestAccount(t, db, bobId, "bob", "pubkey2", "webpub2", "webpriv2")
+	db.CreateNote(aliceId, "Alice note")
+	db.CreateNote(bobId, "Bob note")
+
+	authors := func() []string {
+		notes, err := db.ReadAllNotes()
+		if err != nil {
+			t.Fatalf("ReadAllNotes failed: %v", err)
+		}
+		var names []string
+		for _, note := range *notes {
+			names = append(names, note.CreatedBy)
+		}
+		slices.Sort(names)
+		return names
+	}
+
+	// Accounts are discoverable unless they opt out
+	if err := db.UpdateDiscoverable(bobId, false); err != nil {
+		t.Fatalf("UpdateDiscoverable failed: %v", err)
+	}
+	if got := authors(); !slices.Equal(got, []string{"alice"}) {
+		t.Errorf("Expected only alice's note, got %v", got)
+	}
+	if acc, _ := db.ReadAccById(bobId); acc.Discoverable {
+		t.Error("Expected bob not to be discoverable")
+	}
+
+	// With an opt-in public timeline, accounts that didn't choose are left out too
+	conf := &util.AppConfig{}
+	conf.Conf.OptInPublicTimeline = true
+	db.SetConfig(conf)
+	if got := authors(); len(got) != 0 {
+		t.Errorf("Expected no notes before anyone opts in, got %v", got)
+	}
+	if acc, _ := db.ReadAccByUsername("alice"); acc.Discoverable {
+		t.Error("Expected alice not to be discoverable by default")
+	}
+	if err := db.UpdateDiscoverable(aliceId, true); err != nil {
+		t.Fatalf("UpdateDiscoverable failed: %v", err)
+	}
+	if got := authors(); !slices.Equal(got, []string{"alice"}) {
+		t.Errorf("Expected alice's note after she opted in, got %v", got)
+	}
+}
+
+func TestReadHomeTimelinePosts_LanguageFilter(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	localAccountId := uuid.New()
+	createTestAccount(t, db, localAccountId, "localuser", "ssh-key", "webpub", "webpriv")
+
+	remoteAccountId := uuid.New()
+	_, err := db.db.Exec(`INSERT INTO remote_accounts(id, username, domain, actor_uri, inbox_uri) VALUES (?, ?, ?, ?, ?)`,
+		remoteAccountId.String(), "remoteuser", "remote.example.com",
+		"https://remote.example.com/users/remoteuser",
+		"https://remote.example.com/users/remoteuser/inbox")
+	if err != nil {
+		t.Fatalf("Failed to create remote account: %v", err)
+	}
+	_, err = db.db.Exec(`INSERT INTO follows(id, account_id, target_account_id, accepted, is_local) VALUES (?, ?, ?, 1, 0)`,
+		uuid.New().String(), localAccountId.String(), remoteAccountId.String())
+	if err != nil {
+		t.Fatalf("Failed to create follow: %v", err)
+	}
+
+	for i, lang := range []string{"en", "fr", ""} {
+		objectURI := fmt.Sprintf("https://remote.example.com/notes/%d", i)
+		activity := &domain.Activity{
+			Id:           uuid.New(),
+			ActivityURI:  fmt.Sprintf("https://remote.example.com/activities/%d", i),
+			ActivityType: "Create",
+			ActorURI:     "https://remote.example.com/users/remoteuser",
+			ObjectURI:    objectURI,
+			RawJSON:      `{"type":"Create","object":{"id":"` + objectURI + `","content":"post","inReplyTo":null}}`,
+			Processed:    true,
+			CreatedAt:    time.Now(),
+			Language:     lang,
+		}
+		if err := db.CreateActivity(activity); err != nil {
+			t.Fatalf("Failed to create activity: %v", err)
+		}
+	}
+
+	readURIs := func() string {
+		posts, err := db.ReadHomeTimelinePosts(localAccountId, 10)
+		if err != nil {
+			t.Fatalf("ReadHomeTimelinePosts failed: %v", err)
+		}
+		uris := make([]string, 0, len(*posts))
+		for _, post := range *posts {
+			uris = append(uris, strings.TrimPrefix(post.ObjectURI, "https://remote.example.com/notes/"))
+		}
+		sort.Strings(uris)
+		return strings.Join(uris, ",")
+	}
+
+	if got := readURIs(); got != "0,1,2" {
+		t.Errorf("Expected all posts without a language filter, got %s", got)
+	}
+
+	if err := db.UpdateLanguageSettings(localAccountId, &domain.LanguageSettings{Languages: []string{"en"}}); err != nil {
+		t.Fatalf("UpdateLanguageSettings failed: %v", err)
+	}
+
+	// The French post is hidden; the post without a language is never filtered out
+	if got := readURIs(); got != "0,2" {
+		t.Errorf("Expected English and untagged posts, got %s", got)
+	}
+}
+
+func TestReadHomeTimelinePosts_DeduplicatesBoosts(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	localAccountId := uuid.New()
+	createTestAccount(t, db, localAccountId, "localuser", "ssh-key", "webpub", "webpriv")
+	authorId := uuid.New()
+	createTestAccount(t, db, authorId, "author", "ssh-key2", "webpub2", "webpriv2")
+
+	// Three followed remote users boost the same note, then one of them boosts the user's own note
+	boostedNoteId, err := db.CreateNote(authorId, "Boosted three times")
+	if err != nil {
+		t.Fatalf("CreateNote failed: %v", err)
+	}
+	ownNoteId, err := db.CreateNote(localAccountId, "My own post")
+	if err != nil {
+		t.Fatalf("CreateNote failed: %v", err)
+	}
+
+	base := time.Now().Add(-time.Hour)
+	for i, name := range []string{"alice", "bob", "carol"} {
+		remoteId := uuid.New()
+		_, err := db.db.Exec(`INSERT INTO remote_accounts(id, username, domain, actor_uri, inbox_uri) VALUES (?, ?, ?, ?, ?)`,
+			remoteId.String(), name, "remote.example.com",
+			"https://remote.example.com/users/"+name,
+			"https://remote.example.com/users/"+name+"/inbox")
+		if err != nil {
+			t.Fatalf("Failed to create remote account: %v", err)
+		}
+		_, err = db.db.Exec(`INSERT INTO follows(id, account_id, target_account_id, accepted, is_local) VALUES (?, ?, ?, 1, 0)`,
+			uuid.New().String(), localAccountId.String(), remoteId.String())
+		if err != nil {
+			t.Fatalf("Failed to create follow: %v", err)
+		}
+		boost := &domain.Boost{Id: uuid.New(), AccountId: remoteId, NoteId: boostedNoteId, URI: "https://remote.example.com/boosts/" + name, CreatedAt: base.Add(time.Duration(i) * time.Minute)}
+		if err := db.CreateBoost(boost); err != nil {
+			t.Fatalf("CreateBoost failed: %v", err)
+		}
+		if name == "alice" {
+			own := &domain.Boost{Id: uuid.New(), AccountId: remoteId, NoteId: ownNoteId, URI: "https://remote.example.com/boosts/own", CreatedAt: base}
+			if err := db.CreateBoost(own); err != nil {
+				t.Fatalf("CreateBoost failed: %v", err)
+			}
+		}
+	}
+
+	posts, err := db.ReadHomeTimelinePosts(localAccountId, 10)
+	if err != nil {
+		t.Fatalf("ReadHomeTimelinePosts failed: %v", err)
+	}
+	if len(*posts) != 2 {
+		t.Fatalf("Expected 2 posts, got %d: %+v", len(*posts), *posts)
+	}
+
+	// The user's own post stays the direct post, annotated with its booster
+	own := (*posts)[0]
+	if own.NoteID != ownNoteId || own.Author != "localuser" {
+		t.Errorf("Expected the direct post first, got %+v", own)
+	}
+	if strings.Join(own.BoostedBy, ",") != "@alice@remote.example.com" {
+		t.Errorf("Expected the direct post to list alice as booster, got %v", own.BoostedBy)
+	}
+
+	// The thrice-boosted note appears once, timed by its latest boost
+	boosted := (*posts)[1]
+	if boosted.NoteID != boostedNoteId || boosted.Author != "author" {
+		t.Errorf("Expected the boosted note, got %+v", boosted)
+	}
+	if got := strings.Join(boosted.BoostedBy, ","); got != "@carol@remote.example.com,@bob@remote.example.com,@alice@remote.example.com" {
+		t.Errorf("Expected boosters newest first, got %s", got)
+	}
+	if want := base.Add(2 * time.Minute); !boosted.Time.Equal(want) {
+		t.Errorf("Expected time of the latest boost %v, got %v", want, boosted.Time)
+	}
+}
+
+func TestReadHomeTimelinePosts_LikedByMe(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	readerId := uuid.New()
+	createTestAccount(t, db, readerId, "reader", "ssh-key", "webpub", "webpriv")
+	remoteId := uuid.New()
+	if _, err := db.db.Exec(`INSERT INTO remote_accounts(id, username, domain, actor_uri, inbox_uri) VALUES (?, ?, ?, ?, ?)`,
+		remoteId.String(), "alice", "remote.example.com", "https://remote.example.com/users/alice", "https://remote.example.com/users/alice/inbox"); err != nil {
+		t.Fatalf("Failed to create remote account: %v", err)
+	}
+	if _, err := db.db.Exec(`INSERT INTO follows(id, account_id, target_account_id, accepted, is_local) VALUES (?, ?, ?, 1, 0)`,
+		uuid.New().String(), readerId.String(), remoteId.String()); err != nil {
+		t.Fatalf("Failed to create follow: %v", err)
+	}
+
+	likedNoteId, err := db.CreateNote(readerId, "Liked note")
+	if err != nil {
+		t.Fatalf("CreateNote failed: %v", err)
+	}
+	if _, err := db.CreateNote(readerId, "Other note"); err != nil {
+		t.Fatalf("CreateNote failed: %v", err)
+	}
+	if err := db.CreateLike(&domain.Like{Id: uuid.New(), AccountId: readerId, NoteId: likedNoteId, URI: "https://local.example.com/likes/1", CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("CreateLike failed: %v", err)
+	}
+
+	for _, id := range []string{"1", "2"} {
+		objectURI := "https://remote.example.com/notes/" + id
+		activity := &domain.Activity{
+			Id:           uuid.New(),
+			ActivityURI:  objectURI + "/activity",
+			ActivityType: "Create",
+			ActorURI:     "https://remote.example.com/users/alice",
+			ObjectURI:    objectURI,
+			RawJSON:      `{"type":"Create","object":{"id":"` + objectURI + `","content":"Remote ` + id + `","inReplyTo":null}}`,
+			Processed:    true,
+			CreatedAt:    time.Now(),
+		}
+		if err := db.CreateActivity(activity); err != nil {
+			t.Fatalf("Failed to create activity: %v", err)
+		}
+	}
+	like := &domain.Like{Id: uuid.New(), AccountId: readerId, URI: "https://local.example.com/likes/2", CreatedAt: time.Now()}
+	if err := db.CreateLikeByObjectURI(like, "https://remote.example.com/notes/1"); err != nil {
+		t.Fatalf("CreateLikeByObjectURI failed: %v", err)
+	}
+
+	posts, err := db.ReadHomeTimelinePosts(readerId, 10)
+	if err != nil {
+		t.Fatalf("ReadHomeTimelinePosts failed: %v", err)
+	}
+	var liked []string
+	for _, post := range *posts {
+		if post.LikedByMe {
+			liked = append(liked, post.Content)
+		}
+	}
+	sort.Strings(liked)
+	if got := strings.Join(liked, ","); got != "Liked note,Remote 1" {
+		t.Errorf("Expected the liked local and remote posts to be marked, got %s", got)
+	}
+
+	// Another account's view doesn't include the reader's likes
+	otherId := uuid.New()
+	createTestAccount(t, db, otherId, "other", "ssh-key2", "webpub2", "webpriv2")
+	if _, err := db.db.Exec(`INSERT INTO follows(id, account_id, target_account_id, accepted, is_local) VALUES (?, ?, ?, 1, 1)`,
+		uuid.New().String(), otherId.String(), readerId.String()); err != nil {
+		t.Fatalf("Failed to create follow: %v", err)
+	}
+	posts, err = db.ReadHomeTimelinePosts(otherId, 10)
+	if err != nil {
+		t.Fatalf("ReadHomeTimelinePosts failed: %v", err)
+	}
+	for _, post := range *posts {
+		if post.LikedByMe {
+			t.Errorf("Expected no liked posts for another account, got %q", post.Content)
+		}
+	}
+}
+
+func TestReadHomeTimelinePosts_ShowReplies(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	readerId := uuid.New()
+	createTestAccount(t, db, readerId, "reader", "ssh-key", "webpub", "webpriv")
+	friendId := uuid.New()
+	createTestAccount(t, db, friendId, "friend", "ssh-key2", "webpub2", "webpriv2")
+	strangerId := uuid.New()
+	createTestAccount(t, db, strangerId, "stranger", "ssh-key3", "webpub3", "webpriv3")
+	if _, err := db.db.Exec(`INSERT INTO follows(id, account_id, target_account_id, accepted, is_local) VALUES (?, ?, ?, 1, 1)`,
+		uuid.New().String(), readerId.String(), friendId.String()); err != nil {
+		t.Fatalf("Failed to create follow: %v", err)
+	}
+	remoteId := uuid.New()
+	if _, err := db.db.Exec(`INSERT INTO remote_accounts(id, username, domain, actor_uri, inbox_uri) VALUES (?, ?, ?, ?, ?)`,
+		remoteId.String(), "alice", "remote.example.com", "https://remote.example.com/users/alice", "https://remote.example.com/users/alice/inbox"); err != nil {
+		t.Fatalf("Failed to create remote account: %v", err)
+	}
+	if _, err := db.db.Exec(`INSERT INTO follows(id, account_id, target_account_id, accepted, is_local) VALUES (?, ?, ?, 1, 0)`,
+		uuid.New().String(), readerId.String(), remoteId.String()); err != nil {
+		t.Fatalf("Failed to create follow: %v", err)
+	}
+
+	ownNoteId, err := db.CreateNote(readerId, "My post")
+	if err != nil {
+		t.Fatalf("CreateNote failed: %v", err)
+	}
+	strangerNoteId, err := db.CreateNote(strangerId, "A stranger's post")
+	if err != nil {
+		t.Fatalf("CreateNote failed: %v", err)
+	}
+	if _, err := db.CreateNoteWithReply(friendId, "Reply to reader", "local:"+ownNoteId.String()); err != nil {
+		t.Fatalf("CreateNoteWithReply failed: %v", err)
+	}
+	if _, err := db.CreateNoteWithReply(friendId, "Reply to stranger", "local:"+strangerNoteId.String()); err != nil {
+		t.Fatalf("CreateNoteWithReply failed: %v", err)
+	}
+
+	remotePost := func(id, inReplyTo string) {
+		objectURI := "https://remote.example.com/notes/" + id
+		activity := &domain.Activity{
+			Id:           uuid.New(),
+			ActivityURI:  objectURI + "/activity",
+			ActivityType: "Create",
+			ActorURI:     "https://remote.example.com/users/alice",
+			ObjectURI:    objectURI,
+			RawJSON:      `{"type":"Create","object":{"id":"` + objectURI + `","content":"Post ` + id + `","inReplyTo":` + inReplyTo + `}}`,
+			Processed:    true,
+			CreatedAt:    time.Now(),
+		}
+		if err := db.CreateActivity(activity); err != nil {
+			t.Fatalf("Failed to create activity: %v", err)
+		}
+	}
+	remotePost("1", "null")
+	remotePost("2", `"https://remote.example.com/notes/1"`)
+	remotePost("3", `"https://elsewhere.example.com/notes/1"`)
+
+	contents := func() []string {
+		posts, err := db.ReadHomeTimelinePosts(readerId, 20)
+		if err != nil {
+			t.Fatalf("ReadHomeTimelinePosts failed: %v", err)
+		}
+		var contents []string
+		for _, post := range *posts {
+			contents = append(contents, post.Content)
+			for _, part := range post.SelfThread {
+				contents = append(contents, part.Content)
+			}
+		}
+		sort.Strings(contents)
+		return contents
+	}
+
+	// Replies are hidden by default, except for self-threads (alice's Post 2 replies to her Post 1)
+	if got := strings.Join(contents(), ","); got != "My post,Post 1,Post 2" {
+		t.Errorf("Expected only top-level posts and self-threads, got %s", got)
+	}
+
+	if err := db.UpdateShowRepliesInHome(readerId, true); err != nil {
+		t.Fatalf("UpdateShowRepliesInHome failed: %v", err)
+	}
+	if show, err := db.ReadShowRepliesInHome(readerId); err != nil || !show {
+		t.Fatalf("Expected replies to be shown, got %v, %v", show, err)
+	}
+
+	// Only replies to the reader and to followed accounts are added, with both query strategies
+	want := "My post,Post 1,Post 2,Reply to reader"
+	if got := strings.Join(contents(), ","); got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+	conf := &util.AppConfig{}
+	conf.Conf.HomeTimelineQuery = util.HomeTimelineQueryUnion
+	db.SetConfig(conf)
+	if got := strings.Join(contents(), ","); got != want {
+		t.Errorf("Expected %s with the union query, got %s", want, got)
+	}
+}
+
+func TestReadHomeTimelinePosts_SelfThreads(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	readerId := uuid.New()
+	createTestAccount(t, db, readerId, "reader", "ssh-key", "webpub", "webpriv")
+	friendId := uuid.New()
+	createTestAccount(t, db, friendId, "friend", "ssh-key2", "webpub2", "webpriv2")
+	if _, err := db.db.Exec(`INSERT INTO follows(id, account_id, target_account_id, accepted, is_local) VALUES (?, ?, ?, 1, 1)`,
+		uuid.New().String(), readerId.String(), friendId.String()); err != nil {
+		t.Fatalf("Failed to create follow: %v", err)
+	}
+	remoteId := uuid.New()
+	if _, err := db.db.Exec(`INSERT INTO remote_accounts(id, username, domain, actor_uri, inbox_uri) VALUES (?, ?, ?, ?, ?)`,
+		remoteId.String(), "alice", "remote.example.com", "https://remote.example.com/users/alice", "https://remote.example.com/users/alice/inbox"); err != nil {
+		t.Fatalf("Failed to create remote account: %v", err)
+	}
+	if _, err := db.db.Exec(`INSERT INTO follows(id, account_id, target_account_id, accepted, is_local) VALUES (?, ?, ?, 1, 0)`,
+		uuid.New().String(), readerId.String(), remoteId.String()); err != nil {
+		t.Fatalf("Failed to create follow: %v", err)
+	}
+
+	base := time.Now().Add(-time.Hour)
+	minute := 0
+	localPost := func(userId uuid.UUID, message, inReplyTo string) uuid.UUID {
+		noteId, err := db.CreateNoteWithReply(userId, message, inReplyTo)
+		if err != nil {
+			t.Fatalf("CreateNoteWithReply failed: %v", err)
+		}
+		minute++
+		db.db.Exec(`UPDATE notes SET created_at = ? WHERE id = ?`, formatTimestamp(base.Add(time.Duration(minute)*time.Minute)), noteId.String())
+		return noteId
+	}
+	remotePost := func(id, inReplyTo string) {
+		objectURI := "https://remote.example.com/notes/" + id
+		minute++
+		activity := &domain.Activity{
+			Id:           uuid.New(),
+			ActivityURI:  objectURI + "/activity",
+			ActivityType: "Create",
+			ActorURI:     "https://remote.example.com/users/alice",
+			ObjectURI:    objectURI,
+			RawJSON:      `{"type":"Create","object":{"id":"` + objectURI + `","content":"Alice ` + id + `","inReplyTo":` + inReplyTo + `}}`,
+			Processed:    true,
+			CreatedAt:    base.Add(time.Duration(minute) * time.Minute),
+		}
+		if err := db.CreateActivity(activity); err != nil {
+			t.Fatalf("Failed to create activity: %v", err)
+		}
+	}
+
+	// friend's thread: 1/3 -> 2/3 -> 3/3, plus the reader's reply and friend's answer to it
+	root := localPost(friendId, "Thread 1/3", "")
+	second := localPost(friendId, "Thread 2/3", "local:"+root.String())
+	localPost(friendId, "Thread 3/3", "local:"+second.String())
+	readerReply := localPost(readerId, "Reader's reply", "local:"+root.String())
+	localPost(friendId, "Answer to the reader", "local:"+readerReply.String())
+
+	// alice's thread, and her reply to someone else that she continues
+	remotePost("1", "null")
+	remotePost("2", `"https://remote.example.com/notes/1"`)
+	remotePost("3", `"https://elsewhere.example.com/notes/1"`)
+	remotePost("4", `"https://remote.example.com/notes/3"`)
+
+	for _, strategy := range []string{"", util.HomeTimelineQueryUnion} {
+		conf := &util.AppConfig{}
+		conf.Conf.HomeTimelineQuery = strategy
+		db.SetConfig(conf)
+
+		posts, err := db.ReadHomeTimelinePosts(readerId, 20)
+		if err != nil {
+			t.Fatalf("ReadHomeTimelinePosts failed: %v", err)
+		}
+		var entries []string
+		for _, post := range *posts {
+			entry := post.Content
+			for _, part := range post.SelfThread {
+				entry += " > " + part.Content
+			}
+			entries = append(entries, entry)
+		}
+
+		// Non-self replies and self-replies below them stay hidden
+		want := "Alice 1 > Alice 2|Thread 1/3 > Thread 2/3 > Thread 3/3"
+		if got := strings.Join(entries, "|"); got != want {
+			t.Errorf("Expected %s with query strategy %q, got %s", want, strategy, got)
+		}
+	}
+}
+
+func TestCollapseSelfThreads(t *testing.T) {
+	now := time.Now()
+	post := func(uri, author, inReplyTo string, age int) domain.HomePost {
+		return domain.HomePost{ID: uuid.New(), ObjectURI: uri, Author: author, InReplyToURI: inReplyTo, Time: now.Add(-time.Duration(age) * time.Minute)}
+	}
+	// Newest first; the thread's first post (a) fell off the page
+	posts := []domain.HomePost{
+		post("d", "@bob@example.com", "c", 1),
+		post("x", "@carol@example.com", "c", 2),
+		post("c", "@bob@example.com", "b", 3),
+		post("b", "@bob@example.com", "a", 4),
+	}
+
+	collapsed := collapseSelfThreads(posts)
+	if len(collapsed) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(collapsed))
+	}
+	if collapsed[0].ObjectURI != "x" || len(collapsed[0].SelfThread) != 0 {
+		t.Errorf("Expected carol's reply on its own, got %+v", collapsed[0])
+	}
+	thread := collapsed[1]
+	if thread.ObjectURI != "b" || len(thread.SelfThread) != 2 || thread.SelfThread[0].ObjectURI != "c" || thread.SelfThread[1].ObjectURI != "d" {
+		t.Errorf("Expected b to collect c and d oldest first, got %+v", thread)
+	}
+}
+
+// seedHomeTimeline fills the home timeline of a new account with posts per source: notes of a
+// followed local user, posts and replies of followed remote users and relay posts, interleaved
+// one minute apart. Followed remote users also boost some of the local notes.
+func seedHomeTimeline(t testing.TB, db *DB, perSource int) uuid.UUID {
+	accountId := uuid.New()
+	createTestAccount(t, db, accountId, "reader", "ssh-key", "webpub", "webpriv")
+	friendId := uuid.New()
+	createTestAccount(t, db, friendId, "friend", "ssh-key2", "webpub2", "webpriv2")
+	if _, err := db.db.Exec(`INSERT INTO follows(id, account_id, target_account_id, accepted, is_local) VALUES (?, ?, ?, 1, 1)`,
+		uuid.New().String(), accountId.String(), friendId.String()); err != nil {
+		t.Fatalf("Failed to create follow: %v", err)
+	}
+
+	var remoteIds []uuid.UUID
+	for _, name := range []string{"alice", "bob"} {
+		remoteId := uuid.New()
+		if _, err := db.db.Exec(`INSERT INTO remote_accounts(id, username, domain, actor_uri, inbox_uri) VALUES (?, ?, ?, ?, ?)`,
+			remoteId.String(), name, "remote.example.com",
+			"https://remote.example.com/users/"+name,
+			"https://remote.example.com/users/"+name+"/inbox"); err != nil {
+			t.Fatalf("Failed to create remote account: %v", err)
+		}
+		if _, err := db.db.Exec(`INSERT INTO follows(id, account_id, target_account_id, accepted, is_local) VALUES (?, ?, ?, 1, 0)`,
+			uuid.New().String(), accountId.String(), remoteId.String()); err != nil {
+			t.Fatalf("Failed to create follow: %v", err)
+		}
+		remoteIds = append(remoteIds, remoteId)
+	}
+
+	base := time.Now().Add(-24 * time.Hour).Truncate(time.Second)
+	var noteIds []uuid.UUID
+	for i := 0; i < perSource; i++ {
+		at := func(offset int) time.Time { return base.Add(time.Duration(3*i+offset) * time.Minute) }
+
+		noteId, err := db.CreateNote(friendId, fmt.Sprintf("Note %d", i))
+		if err != nil {
+			t.Fatalf("CreateNote failed: %v", err)
+		}
+		if _, err := db.db.Exec(`UPDATE notes SET created_at = ? WHERE id = ?`, at(0).Format("2006-01-02 15:04:05"), noteId.String()); err != nil {
+			t.Fatalf("Failed to date note: %v", err)
+		}
+		noteIds = append(noteIds, noteId)
+
+		name := []string{"alice", "bob"}[i%2]
+		inReplyTo := "null"
+		if i%5 == 4 {
+			inReplyTo = `"https://elsewhere.example.com/notes/1"`
+		}
+		for j, fromRelay := range []bool{false, true} {
+			actor := "https://remote.example.com/users/" + name
+			if fromRelay {
+				actor = "https://relayed.example.org/users/carol"
+			}
+			objectURI := fmt.Sprintf("%s/notes/%d", actor, i)
+			activity := &domain.Activity{
+				Id:           uuid.New(),
+				ActivityURI:  objectURI + "/activity",
+				ActivityType: "Create",
+				ActorURI:     actor,
+				ObjectURI:    objectURI,
+				RawJSON:      fmt.Sprintf(`{"type":"Create","object":{"id":"%s","content":"Post %d","inReplyTo":%s}}`, objectURI, i, inReplyTo),
+				Processed:    true,
+				FromRelay:    fromRelay,
+				CreatedAt:    at(j + 1),
+			}
+			if err := db.CreateActivity(activity); err != nil {
+				t.Fatalf("Failed to create activity: %v", err)
+			}
 		}
 	}
 
-	// Run cleanup
-	if err := db.CleanupOrphanedFollows(); err != nil {
-		t.Fatalf("CleanupOrphanedFollows failed: %v", err)
-	}
-
-	// Verify: follow1 and follow2 should remain, follow3 should be deleted
-	err, f1 := db.ReadFollowByURI(follow1.URI)
-	if err != nil || f1 == nil {
-		t.Error("Valid follow (local->remote) was incorrectly deleted")
+	// Boost the latest note, notes just below the newest page and the oldest note, all recently
+	boostedAt := base.Add(time.Duration(3*perSource) * time.Minute)
+	for i, noteId := range []uuid.UUID{noteIds[len(noteIds)-1], noteIds[len(noteIds)-4], noteIds[len(noteIds)-6], noteIds[0]} {
+		boost := &domain.Boost{Id: uuid.New(), AccountId: remoteIds[i%2], NoteId: noteId, URI: fmt.Sprintf("https://remote.example.com/boosts/%d", i), CreatedAt: boostedAt.Add(time.Duration(i) * time.Second)}
+		if err := db.CreateBoost(boost); err != nil {
+			t.Fatalf("CreateBoost failed: %v", err)
+		}
 	}
+	return accountId
+}
 
-	err, f2 := db.ReadFollowByURI(follow2.URI)
-	if err != nil || f2 == nil {
-		t.Error("Valid follow (remote->local) was incorrectly deleted")
-	}
+func TestReadHomeTimelinePosts_UnionMatchesMerge(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+	accountId := seedHomeTimeline(t, db, 20)
 
-	err, f3 := db.ReadFollowByURI(follow3.URI)
-	if err == nil && f3 != nil {
-		t.Error("Orphaned follow should have been deleted")
-	}
+	for _, limit := range []int{5, 10, 100} {
+		db.SetConfig(&util.AppConfig{})
+		merged, err := db.ReadHomeTimelinePosts(accountId, limit)
+		if err != nil {
+			t.Fatalf("ReadHomeTimelinePosts (merge) failed: %v", err)
+		}
 
-	// Delete remote account and verify cleanup removes follows
-	if err := db.DeleteRemoteAccount(remote1Id); err != nil {
-		t.Fatalf("Failed to delete remote account: %v", err)
-	}
+		conf := &util.AppConfig{}
+		conf.Conf.HomeTimelineQuery = util.HomeTimelineQueryUnion
+		db.SetConfig(conf)
+		union, err := db.ReadHomeTimelinePosts(accountId, limit)
+		if err != nil {
+			t.Fatalf("ReadHomeTimelinePosts (union) failed: %v", err)
+		}
 
-	// Run cleanup again
-	if err := db.CleanupOrphanedFollows(); err != nil {
-		t.Fatalf("CleanupOrphanedFollows failed: %v", err)
+		if len(*merged) != min(limit, 20+16+16) {
+			t.Errorf("limit %d: expected a full page, got %d posts", limit, len(*merged))
+		}
+		if !reflect.DeepEqual(*merged, *union) {
+			t.Errorf("limit %d: union timeline differs from merged timeline\nmerged: %+v\nunion:  %+v", limit, *merged, *union)
+		}
 	}
+}
 
-	// Now follow1 should be gone too
-	err, f1 = db.ReadFollowByURI(follow1.URI)
-	if err == nil && f1 != nil {
-		t.Error("Follow should have been deleted after remote account deletion")
+func BenchmarkReadHomeTimelinePosts(b *testing.B) {
+	for _, strategy := range []string{util.HomeTimelineQueryMerge, util.HomeTimelineQueryUnion} {
+		b.Run(strategy, func(b *testing.B) {
+			db := setupTestDB(b)
+			defer db.db.Close()
+			accountId := seedHomeTimeline(b, db, 2000)
+			conf := &util.AppConfig{}
+			conf.Conf.HomeTimelineQuery = strategy
+			db.SetConfig(conf)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := db.ReadHomeTimelinePosts(accountId, 50); err != nil {
+					b.Fatalf("ReadHomeTimelinePosts failed: %v", err)
+				}
+			}
+		})
 	}
 }
 
-// Hashtag tests
-
-func TestCreateOrUpdateHashtag(t *testing.T) {
+func TestUpdateActivityQuote(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.db.Close()
 
-	// Create new hashtag
-	id1, err := db.CreateOrUpdateHashtag("golang")
-	if err != nil {
-		t.Fatalf("CreateOrUpdateHashtag failed: %v", err)
-	}
-	if id1 == 0 {
-		t.Error("Expected non-zero hashtag ID")
-	}
+	localAccountId := uuid.New()
+	createTestAccount(t, db, localAccountId, "localuser", "ssh-key", "webpub", "webpriv")
 
-	// Verify hashtag was created with usage_count = 1
-	var name string
-	var usageCount int
-	err = db.db.QueryRow("SELECT name, usage_count FROM hashtags WHERE id = ?", id1).Scan(&name, &usageCount)
-	if err != nil {
-		t.Fatalf("Failed to query hashtag: %v", err)
-	}
-	if name != "golang" {
-		t.Errorf("Expected name 'golang', got '%s'", name)
+	activity := &domain.Activity{
+		Id:           uuid.New(),
+		ActivityURI:  "https://relay.example.com/announces/1",
+		ActivityType: "Create",
+		ActorURI:     "https://remote.example.com/users/remoteuser",
+		ObjectURI:    "https://remote.example.com/notes/quote",
+		RawJSON:      `{"type":"Create","object":{"id":"https://remote.example.com/notes/quote","content":"So true","inReplyTo":null}}`,
+		Processed:    true,
+		FromRelay:    true,
+		CreatedAt:    time.Now(),
 	}
-	if usageCount != 1 {
-		t.Errorf("Expected usage_count 1, got %d", usageCount)
+	if err := db.CreateActivity(activity); err != nil {
+		t.Fatalf("Failed to create activity: %v", err)
 	}
 
-	// Update existing hashtag (should increment usage_count)
-	id2, err := db.CreateOrUpdateHashtag("golang")
-	if err != nil {
-		t.Fatalf("CreateOrUpdateHashtag (update) failed: %v", err)
-	}
-	if id2 != id1 {
-		t.Errorf("Expected same ID on update, got %d vs %d", id1, id2)
+	if err := db.UpdateActivityQuote(activity.Id, "https://other.example.org/notes/1", "@carol@other.example.org", "The original"); err != nil {
+		t.Fatalf("UpdateActivityQuote failed: %v", err)
 	}
 
-	// Verify usage_count incremented
-	err = db.db.QueryRow("SELECT usage_count FROM hashtags WHERE id = ?", id1).Scan(&usageCount)
+	posts, err := db.ReadHomeTimelinePosts(localAccountId, 10)
 	if err != nil {
-		t.Fatalf("Failed to query hashtag: %v", err)
+		t.Fatalf("ReadHomeTimelinePosts failed: %v", err)
 	}
-	if usageCount != 2 {
-		t.Errorf("Expected usage_count 2 after update, got %d", usageCount)
+	if len(*posts) != 1 {
+		t.Fatalf("Expected 1 post, got %d", len(*posts))
+	}
+	post := (*posts)[0]
+	if post.QuoteURI != "https://other.example.org/notes/1" || post.QuoteAuthor != "@carol@other.example.org" || post.QuoteContent != "The original" {
+		t.Errorf("Unexpected quote on post: %q %q %q", post.QuoteURI, post.QuoteAuthor, post.QuoteContent)
 	}
 }
 
-func TestCreateOrUpdateHashtag_CaseInsensitive(t *testing.T) {
+func TestReadHomeTimelinePosts_ExcludesRestricted(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.db.Close()
 
-	// Create hashtag
-	id1, err := db.CreateOrUpdateHashtag("GoLang")
-	if err != nil {
-		t.Fatalf("CreateOrUpdateHashtag failed: %v", err)
-	}
+	localAccountId := uuid.New()
+	createTestAccount(t, db, localAccountId, "localuser", "ssh-key", "webpub", "webpriv")
 
-	// Verify it's stored as lowercase
-	var name string
-	err = db.db.QueryRow("SELECT name FROM hashtags WHERE id = ?", id1).Scan(&name)
-	if err != nil {
-		t.Fatalf("Failed to query hashtag: %v", err)
-	}
-	if name != "golang" {
-		t.Errorf("Expected lowercase 'golang', got '%s'", name)
+	for i, restricted := range []bool{false, true} {
+		objectURI := fmt.Sprintf("https://remote.example.com/notes/%d", i)
+		activity := &domain.Activity{
+			Id:           uuid.New(),
+			ActivityURI:  objectURI + "/announce",
+			ActivityType: "Create",
+			ActorURI:     "https://remote.example.com/users/remoteuser",
+			ObjectURI:    objectURI,
+			RawJSON:      `{"type":"Create","object":{"id":"` + objectURI + `","content":"post","inReplyTo":null}}`,
+			Processed:    true,
+			FromRelay:    true,
+			CreatedAt:    time.Now(),
+			Restricted:   restricted,
+		}
+		if err := db.CreateActivity(activity); err != nil {
+			t.Fatalf("Failed to create activity: %v", err)
+		}
 	}
 
-	// Update with different case should update the same record
-	id2, err := db.CreateOrUpdateHashtag("GOLANG")
-	if err != nil {
-		t.Fatalf("CreateOrUpdateHashtag (different case) failed: %v", err)
-	}
-	if id2 != id1 {
-		t.Errorf("Expected same ID for case-insensitive match, got %d vs %d", id1, id2)
+	for _, strategy := range []string{util.HomeTimelineQueryMerge, util.HomeTimelineQueryUnion} {
+		conf := &util.AppConfig{}
+		conf.Conf.HomeTimelineQuery = strategy
+		db.SetConfig(conf)
+		posts, err := db.ReadHomeTimelinePosts(localAccountId, 10)
+		if err != nil {
+			t.Fatalf("ReadHomeTimelinePosts (%s) failed: %v", strategy, err)
+		}
+		if len(*posts) != 1 || (*posts)[0].ObjectURI != "https://remote.example.com/notes/0" {
+			t.Errorf("%s: expected only the unrestricted post, got %+v", strategy, *posts)
+		}
 	}
 }
 
-func TestLinkNoteHashtags(t *testing.T) {
+// ============ Relay Tests ============
+
+func TestCreateRelay(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.db.Close()
 
-	// Create user and note
-	userId := uuid.New()
-	createTestAccount(t, db, userId, "testuser", "pubkey", "webpub", "webpriv")
-	noteId, err := db.CreateNote(userId, "Test note #golang #rust")
-	if err != nil {
-		t.Fatalf("Failed to create note: %v", err)
+	relay := &domain.Relay{
+		Id:        uuid.New(),
+		ActorURI:  "https://relay.example.com/actor",
+		InboxURI:  "https://relay.example.com/inbox",
+		Name:      "Test Relay",
+		Status:    "pending",
+		CreatedAt: time.Now(),
 	}
 
-	// Create hashtags
-	golangId, _ := db.CreateOrUpdateHashtag("golang")
-	rustId, _ := db.CreateOrUpdateHashtag("rust")
-
-	// Link note to hashtags
-	err = db.LinkNoteHashtags(noteId, []int64{golangId, rustId})
+	err := db.CreateRelay(relay)
 	if err != nil {
-		t.Fatalf("LinkNoteHashtags failed: %v", err)
+		t.Fatalf("CreateRelay failed: %v", err)
 	}
 
-	// Verify links were created
-	var count int
-	err = db.db.QueryRow("SELECT COUNT(*) FROM note_hashtags WHERE note_id = ?", noteId.String()).Scan(&count)
+	// Verify it was created
+	fetched, err := db.ReadRelayByActorURI(relay.ActorURI)
 	if err != nil {
-		t.Fatalf("Failed to query note_hashtags: %v", err)
+		t.Fatalf("ReadRelayByActorURI failed: %v", err)
 	}
-	if count != 2 {
-		t.Errorf("Expected 2 hashtag links, got %d", count)
+
+	if fetched.Name != relay.Name {
+		t.Errorf("Expected Name %s, got %s", relay.Name, fetched.Name)
+	}
+	if fetched.Status != "pending" {
+		t.Errorf("Expected Status 'pending', got %s", fetched.Status)
+	}
+	if fetched.Type != domain.RelayTypeMastodon {
+		t.Errorf("Expected relay without a type to default to %s, got %s", domain.RelayTypeMastodon, fetched.Type)
+	}
+
+	litepub := &domain.Relay{
+		Id:        uuid.New(),
+		ActorURI:  "https://pleroma.example.com/relay",
+		InboxURI:  "https://pleroma.example.com/inbox",
+		Status:    "pending",
+		Type:      domain.RelayTypeLitePub,
+		CreatedAt: time.Now(),
+	}
+	if err := db.CreateRelay(litepub); err != nil {
+		t.Fatalf("CreateRelay failed: %v", err)
+	}
+	fetched, err = db.ReadRelayById(litepub.Id)
+	if err != nil || fetched.Type != domain.RelayTypeLitePub {
+		t.Errorf("Expected %s relay type, got %v (err %v)", domain.RelayTypeLitePub, fetched, err)
 	}
 }
 
-func TestLinkNoteHashtags_Duplicate(t *testing.T) {
+func TestRelayFollowAttempts(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.db.Close()
 
-	// Create user and note
-	userId := uuid.New()
-	createTestAccount(t, db, userId, "testuser", "pubkey", "webpub", "webpriv")
-	noteId, err := db.CreateNote(userId, "Test note #golang")
-	if err != nil {
-		t.Fatalf("Failed to create note: %v", err)
+	accountId := uuid.New()
+	created := time.Now().Add(-time.Hour).Truncate(time.Second)
+	pending := &domain.Relay{
+		Id:        uuid.New(),
+		ActorURI:  "https://relay.example.com/actor",
+		InboxURI:  "https://relay.example.com/inbox",
+		FollowURI: "https://local.example.com/activities/follow-1",
+		Status:    "pending",
+		CreatedAt: created,
+		AccountId: accountId,
+	}
+	active := &domain.Relay{
+		Id:        uuid.New(),
+		ActorURI:  "https://other-relay.example.com/actor",
+		InboxURI:  "https://other-relay.example.com/inbox",
+		Status:    "active",
+		CreatedAt: created,
+	}
+	for _, relay := range []*domain.Relay{pending, active} {
+		if err := db.CreateRelay(relay); err != nil {
+			t.Fatalf("CreateRelay failed: %v", err)
+		}
 	}
 
-	// Create hashtag
-	golangId, _ := db.CreateOrUpdateHashtag("golang")
-
-	// Link note to hashtag
-	err = db.LinkNoteHashtags(noteId, []int64{golangId})
+	relays, err := db.ReadPendingRelays()
 	if err != nil {
-		t.Fatalf("LinkNoteHashtags failed: %v", err)
+		t.Fatalf("ReadPendingRelays failed: %v", err)
+	}
+	if len(*relays) != 1 {
+		t.Fatalf("Expected 1 pending relay, got %d", len(*relays))
+	}
+	got := (*relays)[0]
+	if got.AccountId != accountId || got.FollowAttempts != 1 || !got.LastFollowAt.Equal(created) {
+		t.Errorf("Expected account, 1 attempt and last Follow at creation, got %v %d %v", got.AccountId, got.FollowAttempts, got.LastFollowAt)
 	}
 
-	// Try to link again (should be ignored due to INSERT OR IGNORE)
-	err = db.LinkNoteHashtags(noteId, []int64{golangId})
+	sentAt := time.Now().Truncate(time.Second)
+	if err := db.UpdateRelayFollowAttempt(pending.Id, "https://local.example.com/activities/follow-2", 2, sentAt); err != nil {
+		t.Fatalf("UpdateRelayFollowAttempt failed: %v", err)
+	}
+
+	byFollow, err := db.ReadRelayByFollowURI("https://local.example.com/activities/follow-2")
 	if err != nil {
-		t.Fatalf("LinkNoteHashtags (duplicate) should not error: %v", err)
+		t.Fatalf("ReadRelayByFollowURI failed: %v", err)
+	}
+	if byFollow.Id != pending.Id {
+		t.Errorf("Expected relay %s, got %s", pending.Id, byFollow.Id)
 	}
 
-	// Verify only one link exists
-	var count int
-	err = db.db.QueryRow("SELECT COUNT(*) FROM note_hashtags WHERE note_id = ?", noteId.String()).Scan(&count)
+	relays, err = db.ReadAllRelays()
 	if err != nil {
-		t.Fatalf("Failed to query note_hashtags: %v", err)
+		t.Fatalf("ReadAllRelays failed: %v", err)
 	}
-	if count != 1 {
-		t.Errorf("Expected 1 hashtag link after duplicate insert, got %d", count)
+	for _, relay := range *relays {
+		if relay.Id == pending.Id && (relay.FollowAttempts != 2 || !relay.LastFollowAt.Equal(sentAt)) {
+			t.Errorf("Expected 2 attempts sent at %v, got %d at %v", sentAt, relay.FollowAttempts, relay.LastFollowAt)
+		}
 	}
 }
 
-func TestReadHashtagsByNoteId(t *testing.T) {
+func TestRelayHealth(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.db.Close()
 
-	// Create user and note
-	userId := uuid.New()
-	createTestAccount(t, db, userId, "testuser", "pubkey", "webpub", "webpriv")
-	noteId, err := db.CreateNote(userId, "Test note #golang #rust #fediverse")
-	if err != nil {
-		t.Fatalf("Failed to create note: %v", err)
-	}
-
-	// Create and link hashtags
-	golangId, _ := db.CreateOrUpdateHashtag("golang")
-	rustId, _ := db.CreateOrUpdateHashtag("rust")
-	fediverseId, _ := db.CreateOrUpdateHashtag("fediverse")
-	err = db.LinkNoteHashtags(noteId, []int64{golangId, rustId, fediverseId})
-	if err != nil {
-		t.Fatalf("LinkNoteHashtags failed: %v", err)
+	acceptedAt := time.Now().Add(-10 * time.Hour).Truncate(time.Second)
+	busy := &domain.Relay{
+		Id:        uuid.New(),
+		ActorURI:  "https://relay.example.com/actor",
+		InboxURI:  "https://relay.example.com/inbox",
+		Status:    "active",
+		CreatedAt: acceptedAt,
 	}
-
-	// Read hashtags
-	err, hashtags := db.ReadHashtagsByNoteId(noteId)
-	if err != nil {
-		t.Fatalf("ReadHashtagsByNoteId failed: %v", err)
+	quiet := &domain.Relay{
+		Id:        uuid.New(),
+		ActorURI:  "https://quiet-relay.example.com/actor",
+		InboxURI:  "https://quiet-relay.example.com/inbox",
+		Status:    "active",
+		CreatedAt: acceptedAt,
 	}
-	if len(hashtags) != 3 {
-		t.Errorf("Expected 3 hashtags, got %d", len(hashtags))
+	for _, relay := range []*domain.Relay{busy, quiet} {
+		if err := db.CreateRelay(relay); err != nil {
+			t.Fatalf("CreateRelay failed: %v", err)
+		}
+		if err := db.UpdateRelayStatus(relay.Id, "active", &acceptedAt); err != nil {
+			t.Fatalf("UpdateRelayStatus failed: %v", err)
+		}
 	}
 
-	// Verify all hashtags are present
-	hashtagMap := make(map[string]bool)
-	for _, h := range hashtags {
-		hashtagMap[h] = true
+	lastActivity := time.Now().Truncate(time.Second)
+	for i := 0; i < 20; i++ {
+		if err := db.RecordRelayActivity(busy.Id, lastActivity); err != nil {
+			t.Fatalf("RecordRelayActivity failed: %v", err)
+		}
 	}
-	if !hashtagMap["golang"] {
-		t.Error("Expected 'golang' in hashtags")
+
+	health, err := db.ReadRelayHealth()
+	if err != nil {
+		t.Fatalf("ReadRelayHealth failed: %v", err)
 	}
-	if !hashtagMap["rust"] {
-		t.Error("Expected 'rust' in hashtags")
+	if len(*health) != 2 {
+		t.Fatalf("Expected 2 relays, got %d", len(*health))
 	}
-	if !hashtagMap["fediverse"] {
-		t.Error("Expected 'fediverse' in hashtags")
+	for _, h := range *health {
+		switch h.RelayId {
+		case busy.Id:
+			if h.ActivityCount != 20 || h.LastActivityAt == nil || !h.LastActivityAt.Equal(lastActivity) {
+				t.Errorf("Expected 20 activities, last at %v, got %d at %v", lastActivity, h.ActivityCount, h.LastActivityAt)
+			}
+			// 20 activities over the 10 hours since acceptance
+			if h.PerHour < 1.9 || h.PerHour > 2.1 {
+				t.Errorf("Expected about 2 activities per hour, got %f", h.PerHour)
+			}
+			if h.IsStale(time.Hour, time.Now()) {
+				t.Error("Expected relay with recent activity not to be stale")
+			}
+		case quiet.Id:
+			if h.ActivityCount != 0 || h.LastActivityAt != nil || h.PerHour != 0 {
+				t.Errorf("Expected no activity, got %d at %v (%f/h)", h.ActivityCount, h.LastActivityAt, h.PerHour)
+			}
+			if !h.IsStale(time.Hour, time.Now()) {
+				t.Error("Expected relay silent since acceptance to be stale")
+			}
+			if h.IsStale(24*time.Hour, time.Now()) {
+				t.Error("Expected relay accepted within the window not to be stale")
+			}
+		}
 	}
 }
 
-func TestReadHashtagsByNoteId_NoHashtags(t *testing.T) {
+func TestReadAllRelays(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.db.Close()
 
-	// Create user and note without hashtags
-	userId := uuid.New()
-	createTestAccount(t, db, userId, "testuser", "pubkey", "webpub", "webpriv")
-	noteId, err := db.CreateNote(userId, "Test note without hashtags")
-	if err != nil {
-		t.Fatalf("Failed to create note: %v", err)
+	// Create multiple relays
+	relay1 := &domain.Relay{
+		Id:        uuid.New(),
+		ActorURI:  "https://relay1.example.com/actor",
+		InboxURI:  "https://relay1.example.com/inbox",
+		Name:      "Relay 1",
+		Status:    "active",
+		CreatedAt: time.Now(),
+	}
+	relay2 := &domain.Relay{
+		Id:        uuid.New(),
+		ActorURI:  "https://relay2.example.com/actor",
+		InboxURI:  "https://relay2.example.com/inbox",
+		Name:      "Relay 2",
+		Status:    "pending",
+		CreatedAt: time.Now(),
 	}
 
-	// Read hashtags (should return empty slice)
-	err, hashtags := db.ReadHashtagsByNoteId(noteId)
+	db.CreateRelay(relay1)
+	db.CreateRelay(relay2)
+
+	relays, err := db.ReadAllRelays()
 	if err != nil {
-		t.Fatalf("ReadHashtagsByNoteId failed: %v", err)
+		t.Fatalf("ReadAllRelays failed: %v", err)
 	}
-	if len(hashtags) != 0 {
-		t.Errorf("Expected 0 hashtags, got %d", len(hashtags))
+
+	if len(*relays) != 2 {
+		t.Errorf("Expected 2 relays, got %d", len(*relays))
 	}
 }
 
-func TestReadNotesByHashtag(t *testing.T) {
+func TestReadActiveRelays(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.db.Close()
 
-	// Create user
-	userId := uuid.New()
-	createTestAccount(t, db, userId, "testuser", "pubkey", "webpub", "webpriv")
-
-	// Create notes with hashtags
-	note1Id, _ := db.CreateNote(userId, "Note 1 #golang")
-	note2Id, _ := db.CreateNote(userId, "Note 2 #golang #rust")
-	note3Id, _ := db.CreateNote(userId, "Note 3 #rust")
-
-	// Create and link hashtags
-	golangId, _ := db.CreateOrUpdateHashtag("golang")
-	rustId, _ := db.CreateOrUpdateHashtag("rust")
-	db.LinkNoteHashtags(note1Id, []int64{golangId})
-	db.LinkNoteHashtags(note2Id, []int64{golangId, rustId})
-	db.LinkNoteHashtags(note3Id, []int64{rustId})
-
-	// Read notes by #golang
-	err, notes := db.ReadNotesByHashtag("golang", 10, 0)
-	if err != nil {
-		t.Fatalf("ReadNotesByHashtag failed: %v", err)
+	// Create active and pending relays
+	activeRelay := &domain.Relay{
+		Id:        uuid.New(),
+		ActorURI:  "https://active.relay.example.com/actor",
+		InboxURI:  "https://active.relay.example.com/inbox",
+		Name:      "Active Relay",
+		Status:    "active",
+		CreatedAt: time.Now(),
 	}
-	if len(*notes) != 2 {
-		t.Errorf("Expected 2 notes with #golang, got %d", len(*notes))
+	pendingRelay := &domain.Relay{
+		Id:        uuid.New(),
+		ActorURI:  "https://pending.relay.example.com/actor",
+		InboxURI:  "https://pending.relay.example.com/inbox",
+		Name:      "Pending Relay",
+		Status:    "pending",
+		CreatedAt: time.Now(),
 	}
 
-	// Read notes by #rust
-	err, notes = db.ReadNotesByHashtag("rust", 10, 0)
+	db.CreateRelay(activeRelay)
+	db.CreateRelay(pendingRelay)
+
+	relays, err := db.ReadActiveRelays()
 	if err != nil {
-		t.Fatalf("ReadNotesByHashtag failed: %v", err)
-	}
-	if len(*notes) != 2 {
-		t.Errorf("Expected 2 notes with #rust, got %d", len(*notes))
+		t.Fatalf("ReadActiveRelays failed: %v", err)
 	}
 
-	// Read notes by non-existent hashtag
-	err, notes = db.ReadNotesByHashtag("nonexistent", 10, 0)
-	if err != nil {
-		t.Fatalf("ReadNotesByHashtag failed: %v", err)
+	if len(*relays) != 1 {
+		t.Errorf("Expected 1 active relay, got %d", len(*relays))
 	}
-	if len(*notes) != 0 {
-		t.Errorf("Expected 0 notes with #nonexistent, got %d", len(*notes))
+	if (*relays)[0].Name != "Active Relay" {
+		t.Errorf("Expected 'Active Relay', got %s", (*relays)[0].Name)
 	}
 }
 
-func TestReadNotesByHashtag_Pagination(t *testing.T) {
+func TestUpdateRelayFilter(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.db.Close()
 
-	// Create user
-	userId := uuid.New()
-	createTestAccount(t, db, userId, "testuser", "pubkey", "webpub", "webpriv")
-
-	// Create 5 notes with #golang
-	golangId, _ := db.CreateOrUpdateHashtag("golang")
-	for i := 0; i < 5; i++ {
-		noteId, _ := db.CreateNote(userId, "Note #golang")
-		db.LinkNoteHashtags(noteId, []int64{golangId})
+	relay := &domain.Relay{
+		Id:        uuid.New(),
+		ActorURI:  "https://relay.example.com/actor",
+		InboxURI:  "https://relay.example.com/inbox",
+		Status:    "active",
+		CreatedAt: time.Now(),
 	}
+	db.CreateRelay(relay)
 
-	// Test limit
-	err, notes := db.ReadNotesByHashtag("golang", 3, 0)
+	relays, err := db.ReadActiveRelays()
 	if err != nil {
-		t.Fatalf("ReadNotesByHashtag failed: %v", err)
+		t.Fatalf("ReadActiveRelays failed: %v", err)
 	}
-	if len(*notes) != 3 {
-		t.Errorf("Expected 3 notes with limit=3, got %d", len(*notes))
+	if !(*relays)[0].Filter.IsEmpty() {
+		t.Errorf("Expected a new relay to have no filter, got %+v", (*relays)[0].Filter)
 	}
 
-	// Test offset
-	err, notes = db.ReadNotesByHashtag("golang", 3, 3)
+	err = db.UpdateRelayFilter(relay.Id, domain.RelayFilter{
+		BlockedDomains: []string{" *.Spam.Example "},
+		MutedWords:     []string{"Crypto", "crypto", ""},
+		RequiredTags:   []string{"#Music", "prints"},
+	})
 	if err != nil {
-		t.Fatalf("ReadNotesByHashtag with offset failed: %v", err)
-	}
-	if len(*notes) != 2 {
-		t.Errorf("Expected 2 notes with limit=3 offset=3, got %d", len(*notes))
+		t.Fatalf("UpdateRelayFilter failed: %v", err)
 	}
-}
-
-func TestReadNotesByHashtag_CaseInsensitive(t *testing.T) {
-	db := setupTestDB(t)
-	defer db.db.Close()
-
-	// Create user and note
-	userId := uuid.New()
-	createTestAccount(t, db, userId, "testuser", "pubkey", "webpub", "webpriv")
-	noteId, _ := db.CreateNote(userId, "Note #GoLang")
-
-	// Create and link hashtag
-	golangId, _ := db.CreateOrUpdateHashtag("golang")
-	db.LinkNoteHashtags(noteId, []int64{golangId})
 
-	// Search with different case
-	err, notes := db.ReadNotesByHashtag("GOLANG", 10, 0)
+	want := domain.RelayFilter{
+		BlockedDomains: []string{"spam.example"},
+		MutedWords:     []string{"crypto"},
+		RequiredTags:   []string{"music", "prints"},
+	}
+	relays, err = db.ReadActiveRelays()
 	if err != nil {
-		t.Fatalf("ReadNotesByHashtag failed: %v", err)
+		t.Fatalf("ReadActiveRelays failed: %v", err)
 	}
-	if len(*notes) != 1 {
-		t.Errorf("Expected 1 note with case-insensitive search, got %d", len(*notes))
+	if got := (*relays)[0].Filter; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected filter %+v from ReadActiveRelays, got %+v", want, got)
+	}
+	relays, err = db.ReadAllRelays()
+	if err != nil {
+		t.Fatalf("ReadAllRelays failed: %v", err)
+	}
+	if got := (*relays)[0].Filter; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected filter %+v from ReadAllRelays, got %+v", want, got)
+	}
+
+	// Clearing the lists removes the filter
+	if err := db.UpdateRelayFilter(relay.Id, domain.RelayFilter{}); err != nil {
+		t.Fatalf("UpdateRelayFilter failed: %v", err)
+	}
+	relays, _ = db.ReadAllRelays()
+	if !(*relays)[0].Filter.IsEmpty() {
+		t.Errorf("Expected the filter to be cleared, got %+v", (*relays)[0].Filter)
 	}
 }
 
-func TestCountNotesByHashtag(t *testing.T) {
+func TestUpdateRelayPauseSchedule(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.db.Close()
 
-	// Create user
-	userId := uuid.New()
-	createTestAccount(t, db, userId, "testuser", "pubkey", "webpub", "webpriv")
-
-	// Create notes with hashtags
-	note1Id, _ := db.CreateNote(userId, "Note 1 #golang")
-	note2Id, _ := db.CreateNote(userId, "Note 2 #golang #rust")
-	note3Id, _ := db.CreateNote(userId, "Note 3 #golang")
-
-	// Create and link hashtags
-	golangId, _ := db.CreateOrUpdateHashtag("golang")
-	rustId, _ := db.CreateOrUpdateHashtag("rust")
-	db.LinkNoteHashtags(note1Id, []int64{golangId})
-	db.LinkNoteHashtags(note2Id, []int64{golangId, rustId})
-	db.LinkNoteHashtags(note3Id, []int64{golangId})
+	relay := &domain.Relay{
+		Id:        uuid.New(),
+		ActorURI:  "https://relay.example.com/actor",
+		InboxURI:  "https://relay.example.com/inbox",
+		Status:    "active",
+		CreatedAt: time.Now(),
+	}
+	db.CreateRelay(relay)
 
-	// Count notes by #golang
-	count, err := db.CountNotesByHashtag("golang")
-	if err != nil {
-		t.Fatalf("CountNotesByHashtag failed: %v", err)
+	if err := db.UpdateRelayPauseSchedule(relay.Id, "22:00-06:00 Europe/Berlin"); err != nil {
+		t.Fatalf("UpdateRelayPauseSchedule failed: %v", err)
 	}
-	if count != 3 {
-		t.Errorf("Expected 3 notes with #golang, got %d", count)
+	if err := db.UpdateRelayScheduledPause(relay.Id, true, domain.RelaySchedulePaused); err != nil {
+		t.Fatalf("UpdateRelayScheduledPause failed: %v", err)
 	}
 
-	// Count notes by #rust
-	count, err = db.CountNotesByHashtag("rust")
+	relays, err := db.ReadActiveRelays()
 	if err != nil {
-		t.Fatalf("CountNotesByHashtag failed: %v", err)
+		t.Fatalf("ReadActiveRelays failed: %v", err)
 	}
-	if count != 1 {
-		t.Errorf("Expected 1 note with #rust, got %d", count)
+	got := (*relays)[0]
+	if got.PauseSchedule != "22:00-06:00 Europe/Berlin" || got.PauseScheduleState != domain.RelaySchedulePaused || !got.Paused {
+		t.Errorf("Expected the relay paused by its schedule, got %+v", got)
+	}
+	if unpaused, _ := db.ReadActiveUnpausedRelays(); len(*unpaused) != 0 {
+		t.Errorf("Expected no unpaused relays during quiet hours, got %d", len(*unpaused))
 	}
 
-	// Count notes by non-existent hashtag
-	count, err = db.CountNotesByHashtag("nonexistent")
-	if err != nil {
-		t.Fatalf("CountNotesByHashtag failed: %v", err)
+	// Resuming by hand overrides the schedule until its window ends
+	if err := db.UpdateRelayPaused(relay.Id, false); err != nil {
+		t.Fatalf("UpdateRelayPaused failed: %v", err)
 	}
-	if count != 0 {
-		t.Errorf("Expected 0 notes with #nonexistent, got %d", count)
+	relays, _ = db.ReadAllRelays()
+	if got := (*relays)[0]; got.Paused || got.PauseScheduleState != domain.RelayScheduleSkipped {
+		t.Errorf("Expected the relay resumed and the schedule skipped, got paused=%v state=%q", got.Paused, got.PauseScheduleState)
+	}
+
+	// Pausing a relay the schedule left alone doesn't change the schedule state
+	db.UpdateRelayScheduledPause(relay.Id, false, domain.RelayScheduleIdle)
+	db.UpdateRelayPaused(relay.Id, true)
+	relays, _ = db.ReadAllRelays()
+	if got := (*relays)[0]; !got.Paused || got.PauseScheduleState != domain.RelayScheduleIdle {
+		t.Errorf("Expected a manual pause outside the window, got paused=%v state=%q", got.Paused, got.PauseScheduleState)
 	}
 }
 
-// ============================================================================
-// Reply Count Tests
-// ============================================================================
+func TestUpdateRelayStatus(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
 
-func TestExtractInReplyToFromJSON(t *testing.T) {
-	tests := []struct {
-		name     string
-		rawJSON  string
-		expected string
-	}{
-		{
-			name:     "valid inReplyTo string",
-			rawJSON:  `{"object":{"inReplyTo":"https://example.com/notes/123"}}`,
-			expected: "https://example.com/notes/123",
-		},
-		{
-			name:     "inReplyTo with spaces in JSON",
-			rawJSON:  `{"object": {"inReplyTo": "https://example.com/notes/456"}}`,
-			expected: "https://example.com/notes/456",
-		},
-		{
-			name:     "inReplyTo is null",
-			rawJSON:  `{"object":{"inReplyTo":null}}`,
-			expected: "",
-		},
-		{
-			name:     "missing inReplyTo field",
-			rawJSON:  `{"object":{"content":"Hello world"}}`,
-			expected: "",
-		},
-		{
-			name:     "missing object field",
-			rawJSON:  `{"type":"Create"}`,
-			expected: "",
-		},
-		{
-			name:     "empty JSON",
-			rawJSON:  "",
-			expected: "",
-		},
-		{
-			name:     "invalid JSON",
-			rawJSON:  `{invalid}`,
-			expected: "",
-		},
+	relay := &domain.Relay{
+		Id:        uuid.New(),
+		ActorURI:  "https://relay.example.com/actor",
+		InboxURI:  "https://relay.example.com/inbox",
+		Name:      "Test Relay",
+		Status:    "pending",
+		CreatedAt: time.Now(),
+	}
+	db.CreateRelay(relay)
+
+	// Update to active
+	now := time.Now()
+	err := db.UpdateRelayStatus(relay.Id, "active", &now)
+	if err != nil {
+		t.Fatalf("UpdateRelayStatus failed: %v", err)
+	}
+
+	// Verify update
+	fetched, err := db.ReadRelayByActorURI(relay.ActorURI)
+	if err != nil {
+		t.Fatalf("ReadRelayByActorURI failed: %v", err)
 	}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result := extractInReplyToFromJSON(tt.rawJSON)
-			if result != tt.expected {
-				t.Errorf("Expected '%s', got '%s'", tt.expected, result)
-			}
-		})
+	if fetched.Status != "active" {
+		t.Errorf("Expected Status 'active', got %s", fetched.Status)
+	}
+	if fetched.AcceptedAt == nil {
+		t.Error("Expected AcceptedAt to be set")
 	}
 }
 
-func TestIncrementReplyCountRecursive_Notes(t *testing.T) {
+func TestDeleteRelay(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.db.Close()
 
-	// Create user
-	userId := uuid.New()
-	createTestAccount(t, db, userId, "testuser", "pubkey", "webpub", "webpriv")
-
-	// Create a thread: root -> reply1 -> reply2 (3 levels deep)
-	rootId := uuid.New()
-	reply1Id := uuid.New()
-	reply2Id := uuid.New()
-
-	rootURI := "https://example.com/notes/" + rootId.String()
-	reply1URI := "https://example.com/notes/" + reply1Id.String()
-
-	// Create root note
-	_, err := db.db.Exec(`INSERT INTO notes (id, user_id, message, created_at, object_uri, reply_count)
-		VALUES (?, ?, ?, ?, ?, ?)`,
-		rootId, userId.String(), "Root post", time.Now(), rootURI, 0)
-	if err != nil {
-		t.Fatalf("Failed to create root note: %v", err)
+	relay := &domain.Relay{
+		Id:        uuid.New(),
+		ActorURI:  "https://relay.example.com/actor",
+		InboxURI:  "https://relay.example.com/inbox",
+		Name:      "Test Relay",
+		Status:    "pending",
+		CreatedAt: time.Now(),
 	}
+	db.CreateRelay(relay)
 
-	// Create reply1 (replies to root)
-	_, err = db.db.Exec(`INSERT INTO notes (id, user_id, message, created_at, object_uri, in_reply_to_uri, reply_count)
-		VALUES (?, ?, ?, ?, ?, ?, ?)`,
-		reply1Id, userId.String(), "Reply 1", time.Now(), reply1URI, rootURI, 0)
+	// Delete relay
+	err := db.DeleteRelay(relay.Id)
 	if err != nil {
-		t.Fatalf("Failed to create reply1: %v", err)
+		t.Fatalf("DeleteRelay failed: %v", err)
 	}
 
-	// Create reply2 (replies to reply1)
-	_, err = db.db.Exec(`INSERT INTO notes (id, user_id, message, created_at, object_uri, in_reply_to_uri, reply_count)
-		VALUES (?, ?, ?, ?, ?, ?, ?)`,
-		reply2Id, userId.String(), "Reply 2", time.Now(), "", reply1URI, 0)
-	if err != nil {
-		t.Fatalf("Failed to create reply2: %v", err)
+	// Verify deletion
+	fetched, err := db.ReadRelayByActorURI(relay.ActorURI)
+	if err == nil && fetched != nil {
+		t.Error("Expected relay to be deleted")
 	}
+}
 
-	// Increment reply count for reply1URI (simulates receiving reply2)
-	err = db.IncrementReplyCountByURI(reply1URI)
-	if err != nil {
-		t.Fatalf("IncrementReplyCountByURI failed: %v", err)
-	}
+func TestReadRelayByActorURINotFound(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
 
-	// Verify reply1 has reply_count = 1
-	var reply1Count int
-	err = db.db.QueryRow(`SELECT reply_count FROM notes WHERE id = ?`, reply1Id.String()).Scan(&reply1Count)
-	if err != nil {
-		t.Fatalf("Failed to query reply1: %v", err)
+	relay, err := db.ReadRelayByActorURI("https://nonexistent.relay.com/actor")
+	if err == nil && relay != nil {
+		t.Error("Expected error for non-existent relay")
 	}
-	if reply1Count != 1 {
-		t.Errorf("Expected reply1 reply_count = 1, got %d", reply1Count)
+}
+
+func TestReadRelayById(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	relay := &domain.Relay{
+		Id:        uuid.New(),
+		ActorURI:  "https://relay.example.com/actor",
+		InboxURI:  "https://relay.example.com/inbox",
+		Name:      "Test Relay",
+		Status:    "pending",
+		CreatedAt: time.Now(),
 	}
+	db.CreateRelay(relay)
 
-	// Verify root also has reply_count = 1 (recursive increment)
-	var rootCount int
-	err = db.db.QueryRow(`SELECT reply_count FROM notes WHERE id = ?`, rootId.String()).Scan(&rootCount)
+	// Read by ID
+	fetched, err := db.ReadRelayById(relay.Id)
 	if err != nil {
-		t.Fatalf("Failed to query root: %v", err)
+		t.Fatalf("ReadRelayById failed: %v", err)
 	}
-	if rootCount != 1 {
-		t.Errorf("Expected root reply_count = 1 (recursive), got %d", rootCount)
+
+	if fetched.ActorURI != relay.ActorURI {
+		t.Errorf("Expected ActorURI %s, got %s", relay.ActorURI, fetched.ActorURI)
 	}
 }
 
-func TestIncrementReplyCountRecursive_Activities(t *testing.T) {
+// createTestNotification is a helper to insert a notification with a fixed timestamp
+func createTestNotification(t *testing.T, db *DB, accountId uuid.UUID, nType domain.NotificationType, actor string, noteId uuid.UUID, createdAt time.Time) *domain.Notification {
+	n := &domain.Notification{
+		Id:               uuid.New(),
+		AccountId:        accountId,
+		NotificationType: nType,
+		ActorId:          uuid.New(),
+		ActorUsername:    actor,
+		ActorDomain:      "remote.example",
+		NoteId:           noteId,
+		CreatedAt:        createdAt,
+	}
+	if err := db.CreateNotification(n); err != nil {
+		t.Fatalf("Failed to create notification: %v", err)
+	}
+	return n
+}
+
+func TestCreateNotificationPublishesUnreadCount(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.db.Close()
 
-	// Create a chain of activities: remoteRoot -> remoteReply1 -> remoteReply2
-	rootURI := "https://remote.example.com/notes/root"
-	reply1URI := "https://remote.example.com/notes/reply1"
+	accountId := uuid.New()
+	otherId := uuid.New()
+	bus := util.GetEventBus()
+	events := bus.Subscribe(accountId)
+	otherEvents := bus.Subscribe(otherId)
+	defer bus.Unsubscribe(accountId, events)
+	defer bus.Unsubscribe(otherId, otherEvents)
+
+	next := func(ch <-chan util.Event) (util.Event, bool) {
+		select {
+		case event := <-ch:
+			return event, true
+		default:
+			return util.Event{}, false
+		}
+	}
 
-	// Create root activity (no inReplyTo)
-	rootActivity := &domain.Activity{
-		Id:           uuid.New(),
-		ActivityURI:  "https://remote.example.com/activities/1",
-		ActivityType: "Create",
-		ActorURI:     "https://remote.example.com/users/alice",
-		ObjectURI:    rootURI,
-		RawJSON:      `{"type":"Create","object":{"id":"` + rootURI + `","type":"Note","content":"Root post"}}`,
-		Processed:    true,
-		CreatedAt:    time.Now(),
+	now := time.Now()
+	createTestNotification(t, db, accountId, domain.NotificationFollow, "alice", uuid.Nil, now)
+	createTestNotification(t, db, accountId, domain.NotificationLike, "bob", uuid.New(), now)
+	for _, want := range []int{1, 2} {
+		event, ok := next(events)
+		if !ok || event.Type != util.EventNotification || event.AccountId != accountId || event.UnreadCount != want {
+			t.Errorf("Expected a notification event with %d unread, got %+v (received: %v)", want, event, ok)
+		}
 	}
-	err := db.CreateActivity(rootActivity)
-	if err != nil {
-		t.Fatalf("Failed to create root activity: %v", err)
+	if event, ok := next(otherEvents); ok {
+		t.Errorf("Expected no event for another account, got %+v", event)
 	}
 
-	// Create reply1 activity (replies to root)
-	reply1Activity := &domain.Activity{
-		Id:           uuid.New(),
-		ActivityURI:  "https://remote.example.com/activities/2",
-		ActivityType: "Create",
-		ActorURI:     "https://remote.example.com/users/bob",
-		ObjectURI:    reply1URI,
-		RawJSON:      `{"type":"Create","object":{"id":"` + reply1URI + `","type":"Note","content":"Reply 1","inReplyTo":"` + rootURI + `"}}`,
-		Processed:    true,
-		CreatedAt:    time.Now(),
+	// Inside a transaction the event waits for the commit, and a rollback drops it
+	failure := fmt.Errorf("handler failed")
+	err := db.WithTx(func(tx *DB) error {
+		createTestNotification(t, tx, accountId, domain.NotificationLike, "carol", uuid.New(), now)
+		if event, ok := next(events); ok {
+			t.Errorf("Expected no event before the commit, got %+v", event)
+		}
+		return failure
+	})
+	if err != failure {
+		t.Fatalf("Expected the callback error, got %v", err)
 	}
-	err = db.CreateActivity(reply1Activity)
-	if err != nil {
-		t.Fatalf("Failed to create reply1 activity: %v", err)
+	if event, ok := next(events); ok {
+		t.Errorf("Expected no event after a rollback, got %+v", event)
 	}
 
-	// Increment reply count for reply1URI (simulates receiving a new reply)
-	err = db.IncrementReplyCountByURI(reply1URI)
+	err = db.WithTx(func(tx *DB) error {
+		createTestNotification(t, tx, accountId, domain.NotificationLike, "dave", uuid.New(), now)
+		return nil
+	})
 	if err != nil {
-		t.Fatalf("IncrementReplyCountByURI failed: %v", err)
+		t.Fatalf("WithTx failed: %v", err)
+	}
+	if event, ok := next(events); !ok || event.UnreadCount != 3 {
+		t.Errorf("Expected a notification event with 3 unread after the commit, got %+v (received: %v)", event, ok)
 	}
+}
 
-	// Verify reply1 has reply_count = 1
-	var reply1Count int
-	err = db.db.QueryRow(`SELECT reply_count FROM activities WHERE object_uri = ?`, reply1URI).Scan(&reply1Count)
+func TestReadGroupedNotifications_CollapsesLikesOnSameNote(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	accountId := uuid.New()
+	noteId := uuid.New()
+	otherNoteId := uuid.New()
+	now := time.Now().Truncate(time.Second)
+
+	createTestNotification(t, db, accountId, domain.NotificationLike, "eve", noteId, now.Add(-5*time.Minute))
+	createTestNotification(t, db, accountId, domain.NotificationLike, "dave", noteId, now.Add(-4*time.Minute))
+	createTestNotification(t, db, accountId, domain.NotificationFollow, "carol", uuid.Nil, now.Add(-3*time.Minute))
+	createTestNotification(t, db, accountId, domain.NotificationLike, "bob", noteId, now.Add(-2*time.Minute))
+	createTestNotification(t, db, accountId, domain.NotificationLike, "alice", otherNoteId, now.Add(-1*time.Minute))
+	createTestNotification(t, db, accountId, domain.NotificationLike, "zed", noteId, now)
+
+	groups, err := db.ReadGroupedNotifications(accountId, 10)
 	if err != nil {
-		t.Fatalf("Failed to query reply1: %v", err)
+		t.Fatalf("ReadGroupedNotifications failed: %v", err)
 	}
-	if reply1Count != 1 {
-		t.Errorf("Expected reply1 reply_count = 1, got %d", reply1Count)
+	if len(*groups) != 3 {
+		t.Fatalf("Expected 3 groups, got %d", len(*groups))
 	}
 
-	// Verify root also has reply_count = 1 (recursive increment via inReplyTo in raw_json)
-	var rootCount int
-	err = db.db.QueryRow(`SELECT reply_count FROM activities WHERE object_uri = ?`, rootURI).Scan(&rootCount)
-	if err != nil {
-		t.Fatalf("Failed to query root: %v", err)
+	first := (*groups)[0]
+	if first.Count != 4 {
+		t.Errorf("Expected 4 likes in first group, got %d", first.Count)
 	}
-	if rootCount != 1 {
-		t.Errorf("Expected root reply_count = 1 (recursive), got %d", rootCount)
+	if len(first.Notifications) != 4 {
+		t.Errorf("Expected raw notifications to be kept, got %d", len(first.Notifications))
+	}
+	if len(first.Actors) != 3 || first.Actors[0] != "@zed@remote.example" {
+		t.Errorf("Expected 3 most recent actors starting with @zed@remote.example, got %v", first.Actors)
+	}
+	if got := first.Summary(); !strings.Contains(got, "@zed@remote.example and 3 others liked your post") {
+		t.Errorf("Unexpected summary: %s", got)
+	}
+
+	if (*groups)[1].Count != 1 || (*groups)[1].Latest.ActorUsername != "alice" {
+		t.Errorf("Expected like on other note as its own group")
+	}
+	if (*groups)[2].Latest.NotificationType != domain.NotificationFollow {
+		t.Errorf("Expected follow notification to stay ungrouped")
 	}
 }
 
-func TestIncrementReplyCountRecursive_MixedChain(t *testing.T) {
+func TestReadGroupedNotifications_RespectsTimeWindow(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.db.Close()
 
-	// Create user
-	userId := uuid.New()
-	createTestAccount(t, db, userId, "testuser", "pubkey", "webpub", "webpriv")
+	accountId := uuid.New()
+	noteId := uuid.New()
+	now := time.Now().Truncate(time.Second)
 
-	// Create a chain: local note -> remote activity reply
-	localNoteId := uuid.New()
-	localNoteURI := "https://example.com/notes/" + localNoteId.String()
+	createTestNotification(t, db, accountId, domain.NotificationBoost, "old", noteId, now.Add(-2*notificationGroupWindow))
+	createTestNotification(t, db, accountId, domain.NotificationBoost, "new1", noteId, now.Add(-time.Minute))
+	createTestNotification(t, db, accountId, domain.NotificationBoost, "new2", noteId, now)
 
-	// Create local note (root)
-	_, err := db.db.Exec(`INSERT INTO notes (id, user_id, message, created_at, object_uri, reply_count)
-		VALUES (?, ?, ?, ?, ?, ?)`,
-		localNoteId, userId.String(), "Local root post", time.Now(), localNoteURI, 0)
+	groups, err := db.ReadGroupedNotifications(accountId, 10)
 	if err != nil {
-		t.Fatalf("Failed to create local note: %v", err)
+		t.Fatalf("ReadGroupedNotifications failed: %v", err)
 	}
-
-	// Create remote activity that replies to local note
-	remoteReplyURI := "https://remote.example.com/notes/remote-reply"
-	remoteActivity := &domain.Activity{
-		Id:           uuid.New(),
-		ActivityURI:  "https://remote.example.com/activities/1",
-		ActivityType: "Create",
-		ActorURI:     "https://remote.example.com/users/alice",
-		ObjectURI:    remoteReplyURI,
-		RawJSON:      `{"type":"Create","object":{"id":"` + remoteReplyURI + `","type":"Note","content":"Remote reply","inReplyTo":"` + localNoteURI + `"}}`,
-		Processed:    true,
-		CreatedAt:    time.Now(),
+	if len(*groups) != 2 {
+		t.Fatalf("Expected 2 groups (window split), got %d", len(*groups))
 	}
-	err = db.CreateActivity(remoteActivity)
-	if err != nil {
-		t.Fatalf("Failed to create remote activity: %v", err)
+	if (*groups)[0].Count != 2 {
+		t.Errorf("Expected recent boosts grouped together, got count %d", (*groups)[0].Count)
+	}
+	if (*groups)[1].Count != 1 {
+		t.Errorf("Expected old boost in its own group, got count %d", (*groups)[1].Count)
 	}
+}
 
-	// Increment reply count for local note (simulates receiving the remote reply)
-	err = db.IncrementReplyCountByURI(localNoteURI)
-	if err != nil {
-		t.Fatalf("IncrementReplyCountByURI failed: %v", err)
+func TestReadGroupedNotifications_Limit(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	accountId := uuid.New()
+	now := time.Now().Truncate(time.Second)
+	for i := 0; i < 5; i++ {
+		createTestNotification(t, db, accountId, domain.NotificationLike, "user", uuid.New(), now.Add(time.Duration(-i)*time.Minute))
 	}
 
-	// Verify local note has reply_count = 1
-	var localCount int
-	err = db.db.QueryRow(`SELECT reply_count FROM notes WHERE id = ?`, localNoteId.String()).Scan(&localCount)
+	groups, err := db.ReadGroupedNotifications(accountId, 3)
 	if err != nil {
-		t.Fatalf("Failed to query local note: %v", err)
+		t.Fatalf("ReadGroupedNotifications failed: %v", err)
 	}
-	if localCount != 1 {
-		t.Errorf("Expected local note reply_count = 1, got %d", localCount)
+	if len(*groups) != 3 {
+		t.Errorf("Expected 3 groups, got %d", len(*groups))
 	}
 }
 
-func TestIncrementReplyCount_ByNoteIdInURI(t *testing.T) {
+func TestMarkNotificationsReadUpTo(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.db.Close()
 
-	// Create user
-	userId := uuid.New()
-	createTestAccount(t, db, userId, "testuser", "pubkey", "webpub", "webpriv")
+	accountId := uuid.New()
+	now := time.Now().Truncate(time.Second)
+	createTestNotification(t, db, accountId, domain.NotificationLike, "a", uuid.New(), now.Add(-2*time.Hour))
+	createTestNotification(t, db, accountId, domain.NotificationLike, "b", uuid.New(), now.Add(-time.Hour))
+	createTestNotification(t, db, accountId, domain.NotificationLike, "c", uuid.New(), now)
 
-	// Create a note WITHOUT object_uri (to test ID-in-URI matching)
-	noteId := uuid.New()
-	_, err := db.db.Exec(`INSERT INTO notes (id, user_id, message, created_at, reply_count)
-		VALUES (?, ?, ?, ?, ?)`,
-		noteId, userId.String(), "Test note", time.Now(), 0)
+	unread, err := db.MarkNotificationsReadUpTo(accountId, now.Add(-time.Hour))
 	if err != nil {
-		t.Fatalf("Failed to create note: %v", err)
+		t.Fatalf("MarkNotificationsReadUpTo failed: %v", err)
 	}
-
-	// Increment using a URI that contains the note ID
-	noteURI := "https://example.com/notes/" + noteId.String()
-	err = db.IncrementReplyCountByURI(noteURI)
-	if err != nil {
-		t.Fatalf("IncrementReplyCountByURI failed: %v", err)
+	if unread != 1 {
+		t.Errorf("Expected 1 unread notification remaining, got %d", unread)
 	}
 
-	// Verify reply_count was incremented
-	var count int
-	err = db.db.QueryRow(`SELECT reply_count FROM notes WHERE id = ?`, noteId.String()).Scan(&count)
-	if err != nil {
-		t.Fatalf("Failed to query note: %v", err)
-	}
-	if count != 1 {
-		t.Errorf("Expected reply_count = 1, got %d", count)
+	count, _ := db.ReadUnreadNotificationCount(accountId)
+	if count != unread {
+		t.Errorf("Expected unread count %d to match returned count %d", count, unread)
 	}
 }
 
-func TestIncrementReplyCount_CycleDetection(t *testing.T) {
+func TestTimestampsAcrossDSTChange(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.db.Close()
 
-	// Create user
-	userId := uuid.New()
-	createTestAccount(t, db, userId, "testuser", "pubkey", "webpub", "webpriv")
-
-	// Create a note that references itself (artificial cycle)
-	noteId := uuid.New()
-	noteURI := "https://example.com/notes/" + noteId.String()
-
-	_, err := db.db.Exec(`INSERT INTO notes (id, user_id, message, created_at, object_uri, in_reply_to_uri, reply_count)
-		VALUES (?, ?, ?, ?, ?, ?, ?)`,
-		noteId, userId.String(), "Self-referencing note", time.Now(), noteURI, noteURI, 0)
+	berlin, err := time.LoadLocation("Europe/Berlin")
 	if err != nil {
-		t.Fatalf("Failed to create note: %v", err)
+		t.Fatalf("Failed to load Europe/Berlin: %v", err)
 	}
+	local := time.Local
+	time.Local = berlin
+	defer func() { time.Local = local }()
 
-	// This should not cause infinite recursion due to visited map
-	err = db.IncrementReplyCountByURI(noteURI)
+	// Berlin set its clocks back from 03:00 CEST to 02:00 CET at 01:00 UTC on 27 October
+	// 2024, so a notification at 02:50 CEST came before one at 02:10 CET
+	accountId := uuid.New()
+	early := time.Date(2024, 10, 27, 0, 50, 0, 0, time.UTC).In(berlin)
+	late := time.Date(2024, 10, 27, 1, 10, 0, 0, time.UTC).In(berlin)
+	createTestNotification(t, db, accountId, domain.NotificationLike, "early", uuid.New(), early)
+	createTestNotification(t, db, accountId, domain.NotificationLike, "late", uuid.New(), late)
+
+	notifications, err := db.ReadNotificationsByAccountId(accountId, 10)
 	if err != nil {
-		t.Fatalf("IncrementReplyCountByURI should handle cycles: %v", err)
+		t.Fatalf("ReadNotificationsByAccountId failed: %v", err)
+	}
+	if len(*notifications) != 2 {
+		t.Fatalf("Expected 2 notifications, got %d", len(*notifications))
+	}
+	if first := (*notifications)[0]; first.ActorUsername != "late" || !first.CreatedAt.Equal(late) {
+		t.Errorf("Expected the notification after the change first at %v, got %s at %v", late, first.ActorUsername, first.CreatedAt)
+	}
+	if second := (*notifications)[1]; second.ActorUsername != "early" || !second.CreatedAt.Equal(early) {
+		t.Errorf("Expected the notification before the change last at %v, got %s at %v", early, second.ActorUsername, second.CreatedAt)
 	}
 
-	// Verify it was only incremented once
-	var count int
-	err = db.db.QueryRow(`SELECT reply_count FROM notes WHERE id = ?`, noteId.String()).Scan(&count)
+	// Marking read up to the earlier one leaves the later one unread
+	unread, err := db.MarkNotificationsReadUpTo(accountId, early)
 	if err != nil {
-		t.Fatalf("Failed to query note: %v", err)
+		t.Fatalf("MarkNotificationsReadUpTo failed: %v", err)
 	}
-	if count != 1 {
-		t.Errorf("Expected reply_count = 1 (not double-counted), got %d", count)
+	if unread != 1 {
+		t.Errorf("Expected 1 unread notification, got %d", unread)
 	}
 }
 
-func TestCountActivitiesByInReplyTo_Basic(t *testing.T) {
+func TestNormalizeTimestamps(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.db.Close()
 
-	parentURI := "https://example.com/notes/parent"
-
-	// Create 3 activities that reply to the parent
-	for i := 0; i < 3; i++ {
-		activity := &domain.Activity{
-			Id:           uuid.New(),
-			ActivityURI:  "https://remote.example.com/activities/" + uuid.New().String(),
-			ActivityType: "Create",
-			ActorURI:     "https://remote.example.com/users/alice",
-			ObjectURI:    "https://remote.example.com/notes/" + uuid.New().String(),
-			RawJSON:      `{"type":"Create","object":{"inReplyTo":"` + parentURI + `"}}`,
-			Processed:    true,
-			CreatedAt:    time.Now(),
+	accountId := uuid.New()
+	n := createTestNotification(t, db, accountId, domain.NotificationLike, "alice", uuid.New(), time.Now())
+
+	// Formats timestamps were stored in before they were normalized, all 00:50 UTC
+	for _, stored := range []string{
+		"2024-10-27T02:50:00+02:00",
+		"2024-10-27 02:50:00 +0200 CEST m=+0.012345",
+		"2024-10-27 00:50:00",
+	} {
+		if _, err := db.db.Exec(`UPDATE notifications SET created_at = ? WHERE id = ?`, stored, n.Id.String()); err != nil {
+			t.Fatalf("Failed to store %q: %v", stored, err)
 		}
-		if err := db.CreateActivity(activity); err != nil {
-			t.Fatalf("Failed to create activity %d: %v", i, err)
+		if err := db.wrapTransaction(db.normalizeTimestamps); err != nil {
+			t.Fatalf("normalizeTimestamps failed: %v", err)
+		}
+		var normalized string
+		db.db.QueryRow(`SELECT CAST(created_at AS TEXT) FROM notifications WHERE id = ?`, n.Id.String()).Scan(&normalized)
+		if normalized != "2024-10-27 00:50:00" {
+			t.Errorf("Expected %q to be normalized to 2024-10-27 00:50:00, got %q", stored, normalized)
 		}
 	}
+}
 
-	// Count replies
-	count, err := db.CountActivitiesByInReplyTo(parentURI)
+func TestMarkNotificationReadForAccount(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	accountId := uuid.New()
+	otherAccountId := uuid.New()
+	n := createTestNotification(t, db, accountId, domain.NotificationLike, "a", uuid.New(), time.Now())
+	createTestNotification(t, db, accountId, domain.NotificationLike, "b", uuid.New(), time.Now())
+
+	// Another account cannot mark this notification read
+	if _, err := db.MarkNotificationReadForAccount(otherAccountId, n.Id); err != nil {
+		t.Fatalf("MarkNotificationReadForAccount failed: %v", err)
+	}
+	if count, _ := db.ReadUnreadNotificationCount(accountId); count != 2 {
+		t.Errorf("Expected 2 unread after foreign mark, got %d", count)
+	}
+
+	unread, err := db.MarkNotificationReadForAccount(accountId, n.Id)
 	if err != nil {
-		t.Fatalf("CountActivitiesByInReplyTo failed: %v", err)
+		t.Fatalf("MarkNotificationReadForAccount failed: %v", err)
 	}
-	if count != 3 {
-		t.Errorf("Expected 3 replies, got %d", count)
+	if unread != 1 {
+		t.Errorf("Expected 1 unread, got %d", unread)
+	}
+
+	// The instance-wide backlog counts every account's unread notifications
+	createTestNotification(t, db, otherAccountId, domain.NotificationLike, "c", uuid.New(), time.Now())
+	if total, err := db.CountUnreadNotifications(); err != nil || total != 2 {
+		t.Errorf("Expected 2 unread notifications across accounts, got %d (err %v)", total, err)
 	}
 }
 
-func TestCountActivitiesByInReplyTo_ExcludesDuplicates(t *testing.T) {
+func TestReadConversation_MixedLocalAndRemote(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.db.Close()
 
-	// Create user for local note
 	userId := uuid.New()
 	createTestAccount(t, db, userId, "testuser", "pubkey", "webpub", "webpriv")
 
-	parentURI := "https://example.com/notes/parent"
-	localNoteId := uuid.New()
-	localNoteURI := "https://example.com/notes/" + localNoteId.String()
+	// Thread: remoteRoot -> localReply -> (remoteReply, localReply2 -> localReply3)
+	rootURI := "https://remote.example.com/notes/root"
+	localReplyId := uuid.New()
+	localReplyURI := "https://example.com/notes/" + localReplyId.String()
+	remoteReplyURI := "https://remote.example.com/notes/reply"
+	localReply2Id := uuid.New()
+	localReply2URI := "https://example.com/notes/" + localReply2Id.String()
+	localReply3Id := uuid.New()
+	now := time.Now()
+
+	rootActivity := &domain.Activity{
+		Id:           uuid.New(),
+		ActivityURI:  "https://remote.example.com/activities/1",
+		ActivityType: "Create",
+		ActorURI:     "https://remote.example.com/users/alice",
+		ObjectURI:    rootURI,
+		RawJSON:      `{"type":"Create","object":{"id":"` + rootURI + `","type":"Note","content":"Root post"}}`,
+		Processed:    true,
+		CreatedAt:    now.Add(-time.Hour),
+	}
+	if err := db.CreateActivity(rootActivity); err != nil {
+		t.Fatalf("Failed to create root activity: %v", err)
+	}
 
-	// Create a local note that replies to parent
 	_, err := db.db.Exec(`INSERT INTO notes (id, user_id, message, created_at, object_uri, in_reply_to_uri)
 		VALUES (?, ?, ?, ?, ?, ?)`,
-		localNoteId, userId.String(), "Local reply", time.Now(), localNoteURI, parentURI)
+		localReplyId, userId.String(), "Local reply", now.Add(-50*time.Minute), localReplyURI, rootURI)
 	if err != nil {
-		t.Fatalf("Failed to create local note: %v", err)
+		t.Fatalf("Failed to create local reply: %v", err)
 	}
 
-	// Create a remote activity that is a DUPLICATE of the local note
-	// (same object_uri - this happens when a local post federates back)
-	duplicateActivity := &domain.Activity{
+	remoteReply := &domain.Activity{
 		Id:           uuid.New(),
-		ActivityURI:  "https://remote.example.com/activities/" + uuid.New().String(),
+		ActivityURI:  "https://remote.example.com/activities/2",
 		ActivityType: "Create",
-		ActorURI:     "https://remote.example.com/users/alice",
-		ObjectURI:    localNoteURI, // Same as local note!
-		RawJSON:      `{"type":"Create","object":{"id":"` + localNoteURI + `","inReplyTo":"` + parentURI + `"}}`,
+		ActorURI:     "https://remote.example.com/users/bob",
+		ObjectURI:    remoteReplyURI,
+		RawJSON:      `{"type":"Create","object":{"id":"` + remoteReplyURI + `","type":"Note","content":"Remote reply","inReplyTo":"` + localReplyURI + `"}}`,
 		Processed:    true,
-		CreatedAt:    time.Now(),
+		CreatedAt:    now.Add(-40 * time.Minute),
 	}
-	if err := db.CreateActivity(duplicateActivity); err != nil {
-		t.Fatalf("Failed to create duplicate activity: %v", err)
+	if err := db.CreateActivity(remoteReply); err != nil {
+		t.Fatalf("Failed to create remote reply: %v", err)
 	}
 
-	// Create a genuine remote activity (not a duplicate)
-	genuineActivity := &domain.Activity{
+	// Federated copy of a local note must not show up twice
+	localCopy := &domain.Activity{
 		Id:           uuid.New(),
-		ActivityURI:  "https://other-remote.example.com/activities/" + uuid.New().String(),
+		ActivityURI:  "https://example.com/activities/3",
 		ActivityType: "Create",
-		ActorURI:     "https://other-remote.example.com/users/bob",
-		ObjectURI:    "https://other-remote.example.com/notes/" + uuid.New().String(),
-		RawJSON:      `{"type":"Create","object":{"inReplyTo":"` + parentURI + `"}}`,
+		ActorURI:     "https://example.com/users/testuser",
+		ObjectURI:    localReply2URI,
+		RawJSON:      `{"type":"Create","object":{"id":"` + localReply2URI + `","type":"Note","content":"Local reply 2","inReplyTo":"` + localReplyURI + `"}}`,
 		Processed:    true,
-		CreatedAt:    time.Now(),
+		CreatedAt:    now.Add(-30 * time.Minute),
 	}
-	if err := db.CreateActivity(genuineActivity); err != nil {
-		t.Fatalf("Failed to create genuine activity: %v", err)
+	if err := db.CreateActivity(localCopy); err != nil {
+		t.Fatalf("Failed to create local copy activity: %v", err)
 	}
 
-	// Count should be 1 (only the genuine activity, not the duplicate)
-	count, err := db.CountActivitiesByInReplyTo(parentURI)
+	_, err = db.db.Exec(`INSERT INTO notes (id, user_id, message, created_at, object_uri, in_reply_to_uri)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		localReply2Id, userId.String(), "Local reply 2", now.Add(-30*time.Minute), localReply2URI, localReplyURI)
 	if err != nil {
-		t.Fatalf("CountActivitiesByInReplyTo failed: %v", err)
-	}
-	if count != 1 {
-		t.Errorf("Expected 1 reply (excluding duplicate), got %d", count)
+		t.Fatalf("Failed to create local reply 2: %v", err)
 	}
-}
-
-func TestCountActivitiesByInReplyTo_ExcludesDuplicatesByNoteIdPattern(t *testing.T) {
-	db := setupTestDB(t)
-	defer db.db.Close()
-
-	// Create user for local note
-	userId := uuid.New()
-	createTestAccount(t, db, userId, "testuser", "pubkey", "webpub", "webpriv")
 
-	parentURI := "https://example.com/notes/parent"
-	localNoteId := uuid.New()
+	_, err = db.db.Exec(`INSERT INTO notes (id, user_id, message, created_at, object_uri, in_reply_to_uri)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		localReply3Id, userId.String(), "Local reply 3", now.Add(-20*time.Minute), "", localReply2URI)
+	if err != nil {
+		t.Fatalf("Failed to create local reply 3: %v", err)
+	}
 
-	// Create a local note WITHOUT object_uri (to test the /notes/{uuid} pattern matching)
-	_, err := db.db.Exec(`INSERT INTO notes (id, user_id, message, created_at, in_reply_to_uri)
-		VALUES (?, ?, ?, ?, ?)`,
-		localNoteId, userId.String(), "Local reply", time.Now(), parentURI)
+	conv, err := db.ReadConversation(localReplyURI)
 	if err != nil {
-		t.Fatalf("Failed to create local note: %v", err)
+		t.Fatalf("ReadConversation failed: %v", err)
 	}
 
-	// Create a remote activity that references the local note by ID pattern
-	duplicateActivity := &domain.Activity{
-		Id:           uuid.New(),
-		ActivityURI:  "https://remote.example.com/activities/" + uuid.New().String(),
-		ActivityType: "Create",
-		ActorURI:     "https://remote.example.com/users/alice",
-		ObjectURI:    "https://example.com/notes/" + localNoteId.String(), // Contains the note ID
-		RawJSON:      `{"type":"Create","object":{"inReplyTo":"` + parentURI + `"}}`,
-		Processed:    true,
-		CreatedAt:    time.Now(),
+	if conv.Root == nil || conv.Root.ObjectURI != rootURI || conv.Root.IsLocal {
+		t.Fatalf("Expected remote root %s, got %+v", rootURI, conv.Root)
 	}
-	if err := db.CreateActivity(duplicateActivity); err != nil {
-		t.Fatalf("Failed to create duplicate activity: %v", err)
+	if len(conv.Ancestors) != 1 {
+		t.Errorf("Expected 1 ancestor, got %d", len(conv.Ancestors))
+	}
+	if !conv.Focus.IsLocal || conv.Focus.Note == nil || conv.Focus.Note.Id != localReplyId {
+		t.Errorf("Expected focus to be the local reply")
+	}
+	if conv.Focus.Depth != 1 {
+		t.Errorf("Expected focus depth 1, got %d", conv.Focus.Depth)
+	}
+	if len(conv.Focus.Children) != 2 {
+		t.Fatalf("Expected 2 direct replies to focus, got %d", len(conv.Focus.Children))
+	}
+	if conv.Focus.Children[0].ObjectURI != remoteReplyURI || conv.Focus.Children[0].IsLocal {
+		t.Errorf("Expected first reply to be the remote reply, got %s", conv.Focus.Children[0].ObjectURI)
+	}
+	second := conv.Focus.Children[1]
+	if !second.IsLocal || second.Depth != 2 {
+		t.Errorf("Expected second reply to be local at depth 2, got local=%v depth=%d", second.IsLocal, second.Depth)
+	}
+	if len(second.Children) != 1 || second.Children[0].Depth != 3 || second.Children[0].Note.Id != localReply3Id {
+		t.Errorf("Expected local reply 3 nested under local reply 2 at depth 3")
 	}
 
-	// Create a genuine remote activity (not a duplicate)
-	genuineActivity := &domain.Activity{
+	if nodes := conv.Flatten(); len(nodes) != 5 {
+		t.Errorf("Expected 5 nodes in conversation, got %d", len(nodes))
+	}
+	if conv.Truncated {
+		t.Errorf("Expected conversation not to be truncated")
+	}
+}
+
+func TestReadConversation_MissingParent(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	missingURI := "https://remote.example.com/notes/gone"
+	replyURI := "https://remote.example.com/notes/reply"
+	reply := &domain.Activity{
 		Id:           uuid.New(),
-		ActivityURI:  "https://other-remote.example.com/activities/" + uuid.New().String(),
+		ActivityURI:  "https://remote.example.com/activities/1",
 		ActivityType: "Create",
-		ActorURI:     "https://other-remote.example.com/users/bob",
-		ObjectURI:    "https://other-remote.example.com/notes/" + uuid.New().String(),
-		RawJSON:      `{"type":"Create","object":{"inReplyTo":"` + parentURI + `"}}`,
+		ActorURI:     "https://remote.example.com/users/bob",
+		ObjectURI:    replyURI,
+		RawJSON:      `{"type":"Create","object":{"id":"` + replyURI + `","type":"Note","content":"Reply","inReplyTo":"` + missingURI + `"}}`,
 		Processed:    true,
 		CreatedAt:    time.Now(),
 	}
-	if err := db.CreateActivity(genuineActivity); err != nil {
-		t.Fatalf("Failed to create genuine activity: %v", err)
+	if err := db.CreateActivity(reply); err != nil {
+		t.Fatalf("Failed to create activity: %v", err)
 	}
 
-	// Count should be 1 (only the genuine activity, not the duplicate)
-	count, err := db.CountActivitiesByInReplyTo(parentURI)
+	conv, err := db.ReadConversation(replyURI)
 	if err != nil {
-		t.Fatalf("CountActivitiesByInReplyTo failed: %v", err)
+		t.Fatalf("ReadConversation failed: %v", err)
 	}
-	if count != 1 {
-		t.Errorf("Expected 1 reply (excluding duplicate by ID pattern), got %d", count)
+	if conv.Root != conv.Focus {
+		t.Errorf("Expected focus to be the root when the parent is missing")
+	}
+	if conv.MissingParentURI != missingURI {
+		t.Errorf("Expected MissingParentURI %s, got %s", missingURI, conv.MissingParentURI)
 	}
 }
 
-func TestCountActivitiesByInReplyTo_SpaceVariants(t *testing.T) {
+func TestReadConversation_NotFound(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.db.Close()
 
-	parentURI := "https://example.com/notes/parent"
+	conv, err := db.ReadConversation("https://remote.example.com/notes/nope")
+	if err == nil || conv != nil {
+		t.Errorf("Expected error for unknown post, got conv=%v", conv)
+	}
+}
 
-	// Create activity with "inReplyTo" (no space)
-	activity1 := &domain.Activity{
-		Id:           uuid.New(),
-		ActivityURI:  "https://remote.example.com/activities/" + uuid.New().String(),
-		ActivityType: "Create",
-		ActorURI:     "https://remote.example.com/users/alice",
-		ObjectURI:    "https://remote.example.com/notes/1",
-		RawJSON:      `{"type":"Create","object":{"inReplyTo":"` + parentURI + `"}}`,
-		Processed:    true,
-		CreatedAt:    time.Now(),
+func TestCreateNoteWithVisibility_LocalOnly(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+
+	userId := uuid.New()
+	createTestAccount(t, db, userId, "testuser", "pubkey", "webpub", "webpriv")
+
+	publicId, err := db.CreateNote(userId, "Public note")
+	if err != nil {
+		t.Fatalf("CreateNote failed: %v", err)
 	}
-	if err := db.CreateActivity(activity1); err != nil {
-		t.Fatalf("Failed to create activity1: %v", err)
+	localId, err := db.CreateNoteWithVisibility(userId, "Local-only note", "", domain.VisibilityLocal)
+	if err != nil {
+		t.Fatalf("CreateNoteWithVisibility failed: %v", err)
 	}
 
-	// Create activity with "inReplyTo": (with space)
-	activity2 := &domain.Activity{
-		Id:           uuid.New(),
-		ActivityURI:  "https://remote.example.com/activities/" + uuid.New().String(),
-		ActivityType: "Create",
-		ActorURI:     "https://remote.example.com/users/bob",
-		ObjectURI:    "https://remote.example.com/notes/2",
-		RawJSON:      `{"type":"Create","object":{"inReplyTo": "` + parentURI + `"}}`,
-		Processed:    true,
-		CreatedAt:    time.Now(),
+	note, err := db.ReadNoteId(localId)
+	if err != nil {
+		t.Fatalf("ReadNoteId failed: %v", err)
 	}
-	if err := db.CreateActivity(activity2); err != nil {
-		t.Fatalf("Failed to create activity2: %v", err)
+	if !note.IsLocalOnly() {
+		t.Errorf("Expected visibility %q, got %q", domain.VisibilityLocal, note.Visibility)
+	}
+	note, err = db.ReadNoteIdWithReplyInfo(publicId)
+	if err != nil {
+		t.Fatalf("ReadNoteIdWithReplyInfo failed: %v", err)
+	}
+	if note.Visibility != domain.VisibilityPublic {
+		t.Errorf("Expected visibility %q, got %q", domain.VisibilityPublic, note.Visibility)
 	}
 
-	// Both should be counted
-	count, err := db.CountActivitiesByInReplyTo(parentURI)
+	// Excluded from the outbox and public feeds
+	outbox, err := db.ReadPublicNotesByUsername("testuser", 10, 0)
 	if err != nil {
-		t.Fatalf("CountActivitiesByInReplyTo failed: %v", err)
+		t.Fatalf("ReadPublicNotesByUsername failed: %v", err)
 	}
-	if count != 2 {
-		t.Errorf("Expected 2 replies (both space variants), got %d", count)
+	if len(*outbox) != 1 || (*outbox)[0].Id != publicId {
+		t.Errorf("Expected only the public note in the outbox, got %d notes", len(*outbox))
+	}
+	all, err := db.ReadAllNotes()
+	if err != nil {
+		t.Fatalf("ReadAllNotes failed: %v", err)
+	}
+	if len(*all) != 1 {
+		t.Errorf("Expected local-only note to be excluded from ReadAllNotes, got %d notes", len(*all))
+	}
+
+	// Still visible on this instance
+	local, err := db.ReadLocalTimelineNotes(userId, 10)
+	if err != nil {
+		t.Fatalf("ReadLocalTimelineNotes failed: %v", err)
+	}
+	if len(*local) != 2 {
+		t.Errorf("Expected both notes in the local timeline, got %d", len(*local))
+	}
+	own, err := db.ReadNotesByUserId(userId)
+	if err != nil {
+		t.Fatalf("ReadNotesByUserId failed: %v", err)
+	}
+	if len(*own) != 2 {
+		t.Errorf("Expected both notes in the author's own list, got %d", len(*own))
 	}
 }
 
-func TestCountActivitiesByInReplyTo_OnlyCountsCreateType(t *testing.T) {
+// newTestDeliveries builds count queue items addressed to distinct inboxes
+func newTestDeliveries(count int) []*domain.DeliveryQueueItem {
+	now := time.Now()
+	items := make([]*domain.DeliveryQueueItem, count)
+	for i := range items {
+		items[i] = &domain.DeliveryQueueItem{
+			Id:           uuid.New(),
+			InboxURI:     fmt.Sprintf("https://remote%d.example.com/inbox", i),
+			ActivityJSON: `{"type":"Create"}`,
+			NextRetryAt:  now.Add(-time.Second),
+			CreatedAt:    now,
+		}
+	}
+	return items
+}
+
+func TestEnqueueDeliveryBatch(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.db.Close()
 
-	parentURI := "https://example.com/notes/parent"
-
-	// Create a "Create" activity
-	createActivity := &domain.Activity{
-		Id:           uuid.New(),
-		ActivityURI:  "https://remote.example.com/activities/create",
-		ActivityType: "Create",
-		ActorURI:     "https://remote.example.com/users/alice",
-		ObjectURI:    "https://remote.example.com/notes/1",
-		RawJSON:      `{"type":"Create","object":{"inReplyTo":"` + parentURI + `"}}`,
-		Processed:    true,
-		CreatedAt:    time.Now(),
+	if err := db.EnqueueDeliveryBatch(nil); err != nil {
+		t.Fatalf("EnqueueDeliveryBatch with no items failed: %v", err)
 	}
-	if err := db.CreateActivity(createActivity); err != nil {
-		t.Fatalf("Failed to create Create activity: %v", err)
+
+	if err := db.EnqueueDeliveryBatch(newTestDeliveries(25)); err != nil {
+		t.Fatalf("EnqueueDeliveryBatch failed: %v", err)
 	}
 
-	// Create a "Like" activity (should not be counted)
-	likeActivity := &domain.Activity{
-		Id:           uuid.New(),
-		ActivityURI:  "https://remote.example.com/activities/like",
-		ActivityType: "Like",
-		ActorURI:     "https://remote.example.com/users/bob",
-		ObjectURI:    "https://remote.example.com/notes/2",
-		RawJSON:      `{"type":"Like","object":{"inReplyTo":"` + parentURI + `"}}`,
-		Processed:    true,
-		CreatedAt:    time.Now(),
+	pending, err := db.ReadPendingDeliveries(100)
+	if err != nil {
+		t.Fatalf("ReadPendingDeliveries failed: %v", err)
 	}
-	if err := db.CreateActivity(likeActivity); err != nil {
-		t.Fatalf("Failed to create Like activity: %v", err)
+	if len(*pending) != 25 {
+		t.Errorf("Expected 25 pending deliveries, got %d", len(*pending))
+	}
+}
+
+func TestEnqueueDeliveryBatch_RollsBackOnError(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.db.Close()
+	// Keep the in-memory database on one connection so the rollback is observable
+	db.db.SetMaxOpenConns(1)
+
+	items := newTestDeliveries(3)
+	items[2].Id = items[0].Id // duplicate primary key fails the last insert
+
+	if err := db.EnqueueDeliveryBatch(items); err == nil {
+		t.Fatal("Expected EnqueueDeliveryBatch to fail on duplicate id")
 	}
 
-	// Only the Create should be counted
-	count, err := db.CountActivitiesByInReplyTo(parentURI)
+	pending, err := db.ReadPendingDeliveries(100)
 	if err != nil {
-		t.Fatalf("CountActivitiesByInReplyTo failed: %v", err)
+		t.Fatalf("ReadPendingDeliveries failed: %v", err)
 	}
-	if count != 1 {
-		t.Errorf("Expected 1 reply (only Create type), got %d", count)
+	if len(*pending) != 0 {
+		t.Errorf("Expected the whole batch to be rolled back, got %d pending deliveries", len(*pending))
 	}
 }
 
-// Tests for remote post likes with object_uri
-
-func TestCreateLikeByObjectURI_DeterministicPlaceholder(t *testing.T) {
+func TestReadFollowerInboxURIs_PrefersSharedInbox(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.db.Close()
 
-	accountId := uuid.New()
-	objectURI := "https://remote.example.com/notes/123"
-
-	like := &domain.Like{
-		Id:        uuid.New(),
-		AccountId: accountId,
-		URI:       "https://local.example.com/likes/1",
-		CreatedAt: time.Now(),
+	localId := uuid.New()
+	followers := []*domain.RemoteAccount{
+		{Username: "bob", Domain: "shared.example.com", SharedInboxURI: "https://shared.example.com/inbox"},
+		{Username: "carol", Domain: "shared.example.com", SharedInboxURI: "https://shared.example.com/inbox"},
+		{Username: "dave", Domain: "personal.example.com"},
+	}
+	for i, remote := range followers {
+		remote.Id = uuid.New()
+		remote.ActorURI = fmt.Sprintf("https://%s/users/%s", remote.Domain, remote.Username)
+		remote.InboxURI = remote.ActorURI + "/inbox"
+		remote.LastFetchedAt = time.Now()
+		if err := db.CreateRemoteAccount(remote); err != nil {
+			t.Fatalf("CreateRemoteAccount failed: %v", err)
+		}
+		follow := &domain.Follow{
+			Id:              uuid.New(),
+			AccountId:       remote.Id,
+			TargetAccountId: localId,
+			URI:             fmt.Sprintf("https://%s/follows/%d", remote.Domain, i),
+			Accepted:        true,
+			CreatedAt:       time.Now(),
+		}
+		if err := db.CreateFollow(follow); err != nil {
+			t.Fatalf("CreateFollow failed: %v", err)
+		}
 	}
 
-	// Create the like
-	err := db.CreateLikeByObjectURI(like, objectURI)
-	if err != nil {
-		t.Fatalf("CreateLikeByObjectURI failed: %v", err)
+	// A pending follow must not receive deliveries
+	pending := &domain.RemoteAccount{
+		Id:            uuid.New(),
+		Username:      "eve",
+		Domain:        "pending.example.com",
+		ActorURI:      "https://pending.example.com/users/eve",
+		InboxURI:      "https://pending.example.com/users/eve/inbox",
+		LastFetchedAt: time.Now(),
 	}
+	db.CreateRemoteAccount(pending)
+	db.CreateFollow(&domain.Follow{Id: uuid.New(), AccountId: pending.Id, TargetAccountId: localId, CreatedAt: time.Now()})
 
-	// Verify it was created with the object_uri
-	hasLike, err := db.HasLikeByObjectURI(accountId, objectURI)
+	inboxes, err := db.ReadFollowerInboxURIs(localId)
 	if err != nil {
-		t.Fatalf("HasLikeByObjectURI failed: %v", err)
+		t.Fatalf("ReadFollowerInboxURIs failed: %v", err)
 	}
-	if !hasLike {
-		t.Error("Expected HasLikeByObjectURI to return true")
+	if len(inboxes) != 2 {
+		t.Fatalf("Expected 2 inboxes, got %d: %v", len(inboxes), inboxes)
 	}
 
-	// Verify the placeholder note_id is deterministic (same object_uri = same placeholder)
-	expectedPlaceholder := uuid.NewSHA1(uuid.NameSpaceURL, []byte(objectURI))
-	var storedNoteId string
-	err = db.db.QueryRow("SELECT note_id FROM likes WHERE object_uri = ?", objectURI).Scan(&storedNoteId)
-	if err != nil {
-		t.Fatalf("Failed to query note_id: %v", err)
+	got := strings.Join(inboxes, " ")
+	if !strings.Contains(got, "https://shared.example.com/inbox") {
+		t.Errorf("Expected shared inbox in %v", inboxes)
 	}
-	if storedNoteId != expectedPlaceholder.String() {
-		t.Errorf("Expected placeholder note_id %s, got %s", expectedPlaceholder.String(), storedNoteId)
+	if !strings.Contains(got, "https://personal.example.com/users/dave/inbox") {
+		t.Errorf("Expected personal inbox fallback in %v", inboxes)
 	}
 }
 
-func TestCreateLikeByObjectURI_DifferentPostsDifferentPlaceholders(t *testing.T) {
+// BenchmarkEnqueueDeliveries compares queueing 1k follower deliveries one transaction
+// per item against a single batch transaction
+func BenchmarkEnqueueDeliveries(b *testing.B) {
+	const followers = 1000
+
+	b.Run("per-item", func(b *testing.B) {
+		db := setupTestDB(b)
+		defer db.db.Close()
+		for i := 0; i < b.N; i++ {
+			for _, item := range newTestDeliveries(followers) {
+				if err := db.EnqueueDelivery(item); err != nil {
+					b.Fatalf("EnqueueDelivery failed: %v", err)
+				}
+			}
+		}
+		b.ReportMetric(followers, "tx/op")
+	})
+
+	b.Run("batch", func(b *testing.B) {
+		db := setupTestDB(b)
+		defer db.db.Close()
+		for i := 0; i < b.N; i++ {
+			if err := db.EnqueueDeliveryBatch(newTestDeliveries(followers)); err != nil {
+				b.Fatalf("EnqueueDeliveryBatch failed: %v", err)
+			}
+		}
+		b.ReportMetric(1, "tx/op")
+	})
+}
+
+func TestDeadLetterDelivery(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.db.Close()
 
-	accountId := uuid.New()
-	objectURI1 := "https://remote.example.com/notes/123"
-	objectURI2 := "https://remote.example.com/notes/456"
+	items := newTestDeliveries(2)
+	if err := db.EnqueueDeliveryBatch(items); err != nil {
+		t.Fatalf("EnqueueDeliveryBatch failed: %v", err)
+	}
 
-	like1 := &domain.Like{
-		Id:        uuid.New(),
-		AccountId: accountId,
-		URI:       "https://local.example.com/likes/1",
-		CreatedAt: time.Now(),
+	if err := db.UpdateDeliveryAttempt(items[0].Id, 1, time.Now().Add(-time.Second), 429); err != nil {
+		t.Fatalf("UpdateDeliveryAttempt failed: %v", err)
 	}
-	like2 := &domain.Like{
-		Id:        uuid.New(),
-		AccountId: accountId,
-		URI:       "https://local.example.com/likes/2",
-		CreatedAt: time.Now(),
+	if err := db.DeadLetterDelivery(items[1].Id, 3, 401); err != nil {
+		t.Fatalf("DeadLetterDelivery failed: %v", err)
 	}
 
-	// Create likes for two different remote posts
-	if err := db.CreateLikeByObjectURI(like1, objectURI1); err != nil {
-		t.Fatalf("CreateLikeByObjectURI for first post failed: %v", err)
+	pending, err := db.ReadPendingDeliveries(10)
+	if err != nil {
+		t.Fatalf("ReadPendingDeliveries failed: %v", err)
 	}
-	if err := db.CreateLikeByObjectURI(like2, objectURI2); err != nil {
-		t.Fatalf("CreateLikeByObjectURI for second post failed: %v", err)
+	if len(*pending) != 1 {
+		t.Fatalf("Expected dead-lettered delivery to be skipped, got %d pending", len(*pending))
+	}
+	if (*pending)[0].Id != items[0].Id || (*pending)[0].LastStatus != 429 {
+		t.Errorf("Expected pending delivery with last status 429, got %+v", (*pending)[0])
 	}
 
-	// Verify both likes exist
-	hasLike1, _ := db.HasLikeByObjectURI(accountId, objectURI1)
-	hasLike2, _ := db.HasLikeByObjectURI(accountId, objectURI2)
-	if !hasLike1 || !hasLike2 {
-		t.Error("Expected both likes to exist")
+	var lastStatus, deadLettered int
+	if err := db.db.QueryRow(`SELECT last_status, dead_lettered FROM delivery_queue WHERE id = ?`, items[1].Id.String()).Scan(&lastStatus, &deadLettered); err != nil {
+		t.Fatalf("Expected dead-lettered row to be kept: %v", err)
+	}
+	if lastStatus != 401 || deadLettered != 1 {
+		t.Errorf("Expected last_status 401 and dead_lettered 1, got %d and %d", lastStatus, deadLettered)
 	}
 
-	// Verify they have different placeholder note_ids
-	var noteId1, noteId2 string
-	db.db.QueryRow("SELECT note_id FROM likes WHERE object_uri = ?", objectURI1).Scan(&noteId1)
-	db.db.QueryRow("SELECT note_id FROM likes WHERE object_uri = ?", objectURI2).Scan(&noteId2)
-	if noteId1 == noteId2 {
-		t.Errorf("Expected different placeholder note_ids for different posts, both got %s", noteId1)
+	queued, err := db.CountQueuedDeliveries()
+	if err != nil || queued != 1 {
+		t.Errorf("Expected 1 queued delivery, got %d (err %v)", queued, err)
+	}
+	deadLetters, err := db.CountDeadLetters()
+	if err != nil || deadLetters != 1 {
+		t.Errorf("Expected 1 dead letter, got %d (err %v)", deadLetters, err)
 	}
 }
 
-func TestCreateLikeByObjectURI_UniqueConstraintPreventsDoubleLike(t *testing.T) {
+func TestReadPendingDeliveries_ObjectOrder(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.db.Close()
 
-	accountId := uuid.New()
-	objectURI := "https://remote.example.com/notes/123"
-
-	like1 := &domain.Like{
-		Id:        uuid.New(),
-		AccountId: accountId,
-		URI:       "https://local.example.com/likes/1",
-		CreatedAt: time.Now(),
+	noteURI := "https://local.example.com/notes/1"
+	create := newTestDeliveries(2) // Create to two inboxes
+	for _, item := range create {
+		item.ObjectURI = noteURI
 	}
-	like2 := &domain.Like{
-		Id:        uuid.New(),
-		AccountId: accountId,
-		URI:       "https://local.example.com/likes/2",
-		CreatedAt: time.Now(),
+	if err := db.EnqueueDeliveryBatch(create); err != nil {
+		t.Fatalf("EnqueueDeliveryBatch failed: %v", err)
+	}
+	del := newTestDeliveries(1) // Delete to the first inbox only
+	del[0].ActivityJSON = `{"type":"Delete"}`
+	del[0].ObjectURI = noteURI
+	if err := db.EnqueueDeliveryBatch(del); err != nil {
+		t.Fatalf("EnqueueDeliveryBatch failed: %v", err)
 	}
 
-	// First like should succeed
-	if err := db.CreateLikeByObjectURI(like1, objectURI); err != nil {
-		t.Fatalf("First CreateLikeByObjectURI failed: %v", err)
+	// The Create to the first inbox is being retried; its Delete waits for it
+	if err := db.UpdateDeliveryAttempt(create[0].Id, 1, time.Now().Add(time.Minute), 500); err != nil {
+		t.Fatalf("UpdateDeliveryAttempt failed: %v", err)
+	}
+	pending, err := db.ReadPendingDeliveries(10)
+	if err != nil {
+		t.Fatalf("ReadPendingDeliveries failed: %v", err)
+	}
+	if len(*pending) != 1 || (*pending)[0].Id != create[1].Id {
+		t.Fatalf("Expected only the Create to the second inbox, got %+v", *pending)
 	}
 
-	// Second like to same post should fail (unique constraint)
-	err := db.CreateLikeByObjectURI(like2, objectURI)
-	if err == nil {
-		t.Error("Expected second like to same post to fail with unique constraint")
+	// Once the Create is delivered, the Delete follows
+	if err := db.DeleteDelivery(create[0].Id); err != nil {
+		t.Fatalf("DeleteDelivery failed: %v", err)
+	}
+	pending, err = db.ReadPendingDeliveries(10)
+	if err != nil {
+		t.Fatalf("ReadPendingDeliveries failed: %v", err)
+	}
+	if len(*pending) != 2 || (*pending)[1].Id != del[0].Id || (*pending)[1].Seq <= (*pending)[0].Seq {
+		t.Errorf("Expected the Delete after the remaining Create, got %+v", *pending)
 	}
 }
 
-func TestDeleteLikeByAccountAndObjectURI(t *testing.T) {
+func TestInboxQueue(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.db.Close()
 
-	accountId := uuid.New()
-	objectURI := "https://remote.example.com/notes/123"
-
-	like := &domain.Like{
-		Id:        uuid.New(),
-		AccountId: accountId,
-		URI:       "https://local.example.com/likes/1",
-		CreatedAt: time.Now(),
+	now := time.Now()
+	first := &domain.InboxQueueItem{Id: uuid.New(), Username: "alice", SignerURI: "https://remote.example.com/users/bob",
+		ActivityJSON: `{"type":"Follow"}`, NextAttemptAt: now, CreatedAt: now.Add(-time.Minute)}
+	second := &domain.InboxQueueItem{Id: uuid.New(), Username: "alice", SignerURI: "https://remote.example.com/users/bob",
+		ActivityJSON: `{"type":"Undo"}`, NextAttemptAt: now, CreatedAt: now}
+	for _, item := range []*domain.InboxQueueItem{second, first} {
+		if err := db.EnqueueInboxActivity(item); err != nil {
+			t.Fatalf("EnqueueInboxActivity failed: %v", err)
+		}
 	}
 
-	// Create the like
-	if err := db.CreateLikeByObjectURI(like, objectURI); err != nil {
-		t.Fatalf("CreateLikeByObjectURI failed: %v", err)
+	pending, err := db.ReadPendingInboxActivities(10)
+	if err != nil {
+		t.Fatalf("ReadPendingInboxActivities failed: %v", err)
 	}
-
-	// Verify it exists
-	hasLike, _ := db.HasLikeByObjectURI(accountId, objectURI)
-	if !hasLike {
-		t.Fatal("Like should exist before deletion")
+	if len(*pending) != 2 || (*pending)[0].Id != first.Id || (*pending)[0].ActivityJSON != first.ActivityJSON {
+		t.Fatalf("Expected both activities, oldest first, got %+v", *pending)
 	}
 
-	// Delete it
-	if err := db.DeleteLikeByAccountAndObjectURI(accountId, objectURI); err != nil {
-		t.Fatalf("DeleteLikeByAccountAndObjectURI failed: %v", err)
+	// A rescheduled activity isn't due until its next attempt
+	if err := db.UpdateInboxActivityAttempt(first.Id, 1, now.Add(time.Minute)); err != nil {
+		t.Fatalf("UpdateInboxActivityAttempt failed: %v", err)
+	}
+	pending, err = db.ReadPendingInboxActivities(10)
+	if err != nil {
+		t.Fatalf("ReadPendingInboxActivities failed: %v", err)
+	}
+	if len(*pending) != 1 || (*pending)[0].Id != second.Id {
+		t.Errorf("Expected only the second activity to be due, got %+v", *pending)
 	}
 
-	// Verify it's gone
-	hasLike, _ = db.HasLikeByObjectURI(accountId, objectURI)
-	if hasLike {
-		t.Error("Like should not exist after deletion")
+	if err := db.DeleteInboxActivity(second.Id); err != nil {
+		t.Fatalf("DeleteInboxActivity failed: %v", err)
+	}
+	count, err := db.CountQueuedInboxActivities()
+	if err != nil || count != 1 {
+		t.Errorf("Expected 1 queued activity, got %d (%v)", count, err)
 	}
 }
 
-func TestReadLikeByAccountAndObjectURI(t *testing.T) {
+func TestReadPendingFollowRequests(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.db.Close()
 
-	accountId := uuid.New()
-	objectURI := "https://remote.example.com/notes/123"
-	likeId := uuid.New()
-
-	like := &domain.Like{
-		Id:        likeId,
-		AccountId: accountId,
-		URI:       "https://local.example.com/likes/1",
-		CreatedAt: time.Now(),
+	localId := uuid.New()
+	otherLocalId := uuid.New()
+	remotes := make([]*domain.RemoteAccount, 3)
+	for i, username := range []string{"bob", "carol", "dave"} {
+		remotes[i] = &domain.RemoteAccount{
+			Id:            uuid.New(),
+			Username:      username,
+			Domain:        "remote.example.com",
+			ActorURI:      "https://remote.example.com/users/" + username,
+			InboxURI:      "https://remote.example.com/users/" + username + "/inbox",
+			LastFetchedAt: time.Now(),
+		}
+		if err := db.CreateRemoteAccount(remotes[i]); err != nil {
+			t.Fatalf("CreateRemoteAccount failed: %v", err)
+		}
 	}
 
-	// Create the like
-	if err := db.CreateLikeByObjectURI(like, objectURI); err != nil {
-		t.Fatalf("CreateLikeByObjectURI failed: %v", err)
+	follows := []*domain.Follow{
+		{AccountId: remotes[0].Id, TargetAccountId: localId, Accepted: false},      // pending
+		{AccountId: remotes[1].Id, TargetAccountId: localId, Accepted: true},       // accepted follower
+		{AccountId: remotes[2].Id, TargetAccountId: otherLocalId, Accepted: false}, // pending for another account
+	}
+	for i, follow := range follows {
+		follow.Id = uuid.New()
+		follow.URI = fmt.Sprintf("https://remote.example.com/follows/%d", i)
+		follow.CreatedAt = time.Now()
+		if err := db.CreateFollow(follow); err != nil {
+			t.Fatalf("CreateFollow failed: %v", err)
+		}
 	}
 
-	// Read it back
-	err, readLike := db.ReadLikeByAccountAndObjectURI(accountId, objectURI)
+	requests, err := db.ReadPendingFollowRequests(localId)
 	if err != nil {
-		t.Fatalf("ReadLikeByAccountAndObjectURI failed: %v", err)
+		t.Fatalf("ReadPendingFollowRequests failed: %v", err)
 	}
-	if readLike == nil {
-		t.Fatal("Expected to find like")
+	if len(requests) != 1 {
+		t.Fatalf("Expected 1 follow request, got %d", len(requests))
 	}
-	if readLike.Id != likeId {
-		t.Errorf("Expected like ID %s, got %s", likeId, readLike.Id)
+	if requests[0].URI != follows[0].URI || requests[0].Handle() != "@bob@remote.example.com" {
+		t.Errorf("Unexpected follow request %s from %s", requests[0].URI, requests[0].Handle())
 	}
-	if readLike.AccountId != accountId {
-		t.Errorf("Expected account ID %s, got %s", accountId, readLike.AccountId)
+	if requests[0].ActorURI != remotes[0].ActorURI {
+		t.Errorf("Expected actor URI %s, got %s", remotes[0].ActorURI, requests[0].ActorURI)
+	}
+
+	// Approving the request removes it from the queue
+	if err := db.AcceptFollowByURI(follows[0].URI); err != nil {
+		t.Fatalf("AcceptFollowByURI failed: %v", err)
+	}
+	if requests, err = db.ReadPendingFollowRequests(localId); err != nil || len(requests) != 0 {
+		t.Errorf("Expected no follow requests after approval, got %d (err %v)", len(requests), err)
 	}
 }
 
-func TestIncrementDecrementLikeCountByObjectURI(t *testing.T) {
+func TestMutedAccounts(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.db.Close()
 
-	objectURI := "https://remote.example.com/notes/123"
+	accountId := uuid.New()
+	createTestAccount(t, db, accountId, "alice", "pubkey1", "webpub1", "webpriv1")
 
-	// Create an activity for this object
-	activity := &domain.Activity{
-		Id:           uuid.New(),
-		ActivityURI:  "https://remote.example.com/activities/create",
-		ActivityType: "Create",
-		ActorURI:     "https://remote.example.com/users/alice",
-		ObjectURI:    objectURI,
-		RawJSON:      `{"type":"Create","object":{"id":"` + objectURI + `","content":"Hello"}}`,
-		Processed:    true,
-		CreatedAt:    time.Now(),
+	bob := "https://remote.example.com/users/bob"
+	carol := "https://remote.example.com/users/carol"
+	if _, err := db.ReadMutedAccount(accountId, bob); err != sql.ErrNoRows {
+		t.Errorf("Expected sql.ErrNoRows before muting, got %v", err)
 	}
-	if err := db.CreateActivity(activity); err != nil {
-		t.Fatalf("Failed to create activity: %v", err)
+
+	expired := time.Now().Add(-time.Hour)
+	later := time.Now().Add(time.Hour)
+	mutes := []*domain.MutedAccount{
+		{Id: uuid.New(), AccountId: accountId, TargetActorURI: bob, HideNotifications: true, CreatedAt: time.Now()},
+		{Id: uuid.New(), AccountId: accountId, TargetActorURI: carol, ExpiresAt: &expired, CreatedAt: time.Now()},
+	}
+	for _, mute := range mutes {
+		if err := db.CreateMutedAccount(mute); err != nil {
+			t.Fatalf("CreateMutedAccount failed: %v", err)
+		}
 	}
 
-	// Increment like count
-	if err := db.IncrementLikeCountByObjectURI(objectURI); err != nil {
-		t.Fatalf("IncrementLikeCountByObjectURI failed: %v", err)
+	// The expired mute no longer applies
+	active, err := db.ReadMutedAccountsByAccountId(accountId)
+	if err != nil {
+		t.Fatalf("ReadMutedAccountsByAccountId failed: %v", err)
+	}
+	if len(*active) != 1 || (*active)[0].TargetActorURI != bob || !(*active)[0].HideNotifications || (*active)[0].ExpiresAt != nil {
+		t.Errorf("Expected only the mute of bob, got %+v", *active)
 	}
 
-	// Verify count is 1
-	var likeCount int
-	db.db.QueryRow("SELECT like_count FROM activities WHERE object_uri = ?", objectURI).Scan(&likeCount)
-	if likeCount != 1 {
-		t.Errorf("Expected like_count 1, got %d", likeCount)
+	// Muting again replaces the settings
+	if err := db.CreateMutedAccount(&domain.MutedAccount{Id: uuid.New(), AccountId: accountId, TargetActorURI: carol, ExpiresAt: &later, CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("CreateMutedAccount failed: %v", err)
+	}
+	mute, err := db.ReadMutedAccount(accountId, carol)
+	if err != nil {
+		t.Fatalf("ReadMutedAccount failed: %v", err)
+	}
+	if mute.HideNotifications || mute.ExpiresAt == nil || mute.ExpiresAt.Sub(later).Abs() > time.Second {
+		t.Errorf("Expected a timed mute ending at %v, got %+v", later, mute)
 	}
 
-	// Increment again
-	db.IncrementLikeCountByObjectURI(objectURI)
-	db.db.QueryRow("SELECT like_count FROM activities WHERE object_uri = ?", objectURI).Scan(&likeCount)
-	if likeCount != 2 {
-		t.Errorf("Expected like_count 2, got %d", likeCount)
+	if err := db.DeleteMutedAccount(accountId, bob); err != nil {
+		t.Fatalf("DeleteMutedAccount failed: %v", err)
+	}
+	if _, err := db.ReadMutedAccount(accountId, bob); err != sql.ErrNoRows {
+		t.Errorf("Expected bob to be unmuted, got %v", err)
 	}
 
-	// Decrement
-	if err := db.DecrementLikeCountByObjectURI(objectURI); err != nil {
-		t.Fatalf("DecrementLikeCountByObjectURI failed: %v", err)
+	// Cleanup removes mutes that have ended
+	deleted, err := db.DeleteExpiredMutedAccounts(later.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("DeleteExpiredMutedAccounts failed: %v", err)
 	}
-	db.db.QueryRow("SELECT like_count FROM activities WHERE object_uri = ?", objectURI).Scan(&likeCount)
-	if likeCount != 1 {
-		t.Errorf("Expected like_count 1 after decrement, got %d", likeCount)
+	if deleted != 1 {
+		t.Errorf("Expected 1 expired mute to be removed, got %d", deleted)
 	}
 }
 
-func TestReadActivitiesByInReplyTo_IncludesLikeAndBoostCounts(t *testing.T) {
+func TestMutedAccountsHiddenFromTimelineAndNotifications(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.db.Close()
 
-	parentURI := "https://example.com/notes/parent"
-	replyURI := "https://remote.example.com/notes/reply1"
+	localAccountId := uuid.New()
+	createTestAccount(t, db, localAccountId, "localuser", "ssh-key", "webpub", "webpriv")
 
-	// Create a reply activity with like and boost counts
-	activity := &domain.Activity{
-		Id:           uuid.New(),
-		ActivityURI:  "https://remote.example.com/activities/create",
-		ActivityType: "Create",
-		ActorURI:     "https://remote.example.com/users/alice",
-		ObjectURI:    replyURI,
-		RawJSON:      `{"type":"Create","object":{"id":"` + replyURI + `","inReplyTo":"` + parentURI + `","content":"Reply"}}`,
-		Processed:    true,
-		CreatedAt:    time.Now(),
-	}
-	if err := db.CreateActivity(activity); err != nil {
-		t.Fatalf("Failed to create activity: %v", err)
-	}
+	remoteIds := map[string]uuid.UUID{}
+	for _, name := range []string{"bob", "carol"} {
+		remoteId := uuid.New()
+		remoteIds[name] = remoteId
+		_, err := db.db.Exec(`INSERT INTO remote_accounts(id, username, domain, actor_uri, inbox_uri) VALUES (?, ?, ?, ?, ?)`,
+			remoteId.String(), name, "remote.example.com",
+			"https://remote.example.com/users/"+name, "https://remote.example.com/users/"+name+"/inbox")
+		if err != nil {
+			t.Fatalf("Failed to create remote account: %v", err)
+		}
+		_, err = db.db.Exec(`INSERT INTO follows(id, account_id, target_account_id, accepted, is_local) VALUES (?, ?, ?, 1, 0)`,
+			uuid.New().String(), localAccountId.String(), remoteId.String())
+		if err != nil {
+			t.Fatalf("Failed to create follow: %v", err)
+		}
 
-	// Set like and boost counts directly
-	db.db.Exec("UPDATE activities SET like_count = 5, boost_count = 3 WHERE object_uri = ?", replyURI)
+		objectURI := "https://remote.example.com/notes/" + name
+		activity := &domain.Activity{
+			Id:           uuid.New(),
+			ActivityURI:  "https://remote.example.com/activities/" + name,
+			ActivityType: "Create",
+			ActorURI:     "https://remote.example.com/users/" + name,
+			ObjectURI:    objectURI,
+			RawJSON:      `{"type":"Create","object":{"id":"` + objectURI + `","content":"post","inReplyTo":null}}`,
+			Processed:    true,
+			CreatedAt:    time.Now(),
+		}
+		if err := db.CreateActivity(activity); err != nil {
+			t.Fatalf("Failed to create activity: %v", err)
+		}
 
-	// Read activities by in_reply_to
-	err, activities := db.ReadActivitiesByInReplyTo(parentURI)
-	if err != nil {
-		t.Fatalf("ReadActivitiesByInReplyTo failed: %v", err)
-	}
-	if activities == nil || len(*activities) != 1 {
-		t.Fatalf("Expected 1 activity, got %v", activities)
+		notification := &domain.Notification{
+			Id:               uuid.New(),
+			AccountId:        localAccountId,
+			NotificationType: domain.NotificationLike,
+			ActorId:          remoteId,
+			ActorUsername:    name,
+			ActorDomain:      "remote.example.com",
+			NoteURI:          objectURI,
+			CreatedAt:        time.Now(),
+		}
+		if err := db.CreateNotification(notification); err != nil {
+			t.Fatalf("Failed to create notification: %v", err)
+		}
 	}
 
-	reply := (*activities)[0]
-	if reply.LikeCount != 5 {
-		t.Errorf("Expected LikeCount 5, got %d", reply.LikeCount)
+	readTimeline := func() string {
+		posts, err := db.ReadHomeTimelinePosts(localAccountId, 10)
+		if err != nil {
+			t.Fatalf("ReadHomeTimelinePosts failed: %v", err)
+		}
+		var authors []string
+		for _, post := range *posts {
+			authors = append(authors, post.Author)
+		}
+		sort.Strings(authors)
+		return strings.Join(authors, ",")
 	}
-	if reply.BoostCount != 3 {
-		t.Errorf("Expected BoostCount 3, got %d", reply.BoostCount)
+	readNotifications := func() (string, int) {
+		notifications, err := db.ReadNotificationsByAccountId(localAccountId, 10)
+		if err != nil {
+			t.Fatalf("ReadNotificationsByAccountId failed: %v", err)
+		}
+		var actors []string
+		for _, n := range *notifications {
+			actors = append(actors, n.ActorUsername)
+		}
+		sort.Strings(actors)
+		unread, err := db.ReadUnreadNotificationCount(localAccountId)
+		if err != nil {
+			t.Fatalf("ReadUnreadNotificationCount failed: %v", err)
+		}
+		return strings.Join(actors, ","), unread
 	}
-}
-
-func TestReadActivityByObjectURI_IncludesLikeAndBoostCounts(t *testing.T) {
-	db := setupTestDB(t)
-	defer db.db.Close()
-
-	objectURI := "https://remote.example.com/notes/123"
 
-	// Create an activity
-	activity := &domain.Activity{
-		Id:           uuid.New(),
-		ActivityURI:  "https://remote.example.com/activities/create",
-		ActivityType: "Create",
-		ActorURI:     "https://remote.example.com/users/alice",
-		ObjectURI:    objectURI,
-		RawJSON:      `{"type":"Create","object":{"id":"` + objectURI + `","content":"Hello"}}`,
-		Processed:    true,
-		CreatedAt:    time.Now(),
+	// Muting bob's posts keeps his notifications and the follow
+	mute := &domain.MutedAccount{Id: uuid.New(), AccountId: localAccountId, TargetActorURI: "https://remote.example.com/users/bob", CreatedAt: time.Now()}
+	if err := db.CreateMutedAccount(mute); err != nil {
+		t.Fatalf("CreateMutedAccount failed: %v", err)
 	}
-	if err := db.CreateActivity(activity); err != nil {
-		t.Fatalf("Failed to create activity: %v", err)
+	if got := readTimeline(); got != "@carol@remote.example.com" {
+		t.Errorf("Expected only carol's post, got %s", got)
+	}
+	if got, unread := readNotifications(); got != "bob,carol" || unread != 2 {
+		t.Errorf("Expected both notifications, got %s (%d unread)", got, unread)
+	}
+	if followed, err := db.IsRemoteAccountFollowed(remoteIds["bob"]); err != nil || !followed {
+		t.Errorf("Expected the follow to be kept, got %v", err)
 	}
 
-	// Set like and boost counts
-	db.db.Exec("UPDATE activities SET like_count = 10, boost_count = 7 WHERE object_uri = ?", objectURI)
-
-	// Read the activity by object URI
-	err, readActivity := db.ReadActivityByObjectURI(objectURI)
-	if err != nil {
-		t.Fatalf("ReadActivityByObjectURI failed: %v", err)
+	// Hiding notifications too leaves them out of the list and the unread count
+	mute.HideNotifications = true
+	if err := db.CreateMutedAccount(mute); err != nil {
+		t.Fatalf("CreateMutedAccount failed: %v", err)
 	}
-	if readActivity == nil {
-		t.Fatal("Expected to find activity")
+	if got, unread := readNotifications(); got != "carol" || unread != 1 {
+		t.Errorf("Expected only carol's notification, got %s (%d unread)", got, unread)
 	}
 
-	if readActivity.LikeCount != 10 {
-		t.Errorf("Expected LikeCount 10, got %d", readActivity.LikeCount)
+	// An expired mute shows everything again
+	expired := time.Now().Add(-time.Minute)
+	mute.ExpiresAt = &expired
+	if err := db.CreateMutedAccount(mute); err != nil {
+		t.Fatalf("CreateMutedAccount failed: %v", err)
 	}
-	if readActivity.BoostCount != 7 {
-		t.Errorf("Expected BoostCount 7, got %d", readActivity.BoostCount)
+	if got := readTimeline(); got != "@bob@remote.example.com,@carol@remote.example.com" {
+		t.Errorf("Expected both posts after the mute expired, got %s", got)
+	}
+	if got, unread := readNotifications(); got != "bob,carol" || unread != 2 {
+		t.Errorf("Expected both notifications after the mute expired, got %s (%d unread)", got, unread)
 	}
 }
 
-func TestReadHomeTimelinePosts_RemotePostsIncludeLikeAndBoostCounts(t *testing.T) {
+func TestCreateActivityStoresSanitizedContent(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.db.Close()
 
-	// Create a local account
 	localAccountId := uuid.New()
 	createTestAccount(t, db, localAccountId, "localuser", "ssh-key", "webpub", "webpriv")
-
-	// Create a remote account
 	remoteAccountId := uuid.New()
 	_, err := db.db.Exec(`INSERT INTO remote_accounts(id, username, domain, actor_uri, inbox_uri) VALUES (?, ?, ?, ?, ?)`,
 		remoteAccountId.String(), "remoteuser", "remote.example.com",
-		"https://remote.example.com/users/remoteuser",
-		"https://remote.example.com/users/remoteuser/inbox")
+		"https://remote.example.com/users/remoteuser", "https://remote.example.com/users/remoteuser/inbox")
 	if err != nil {
 		t.Fatalf("Failed to create remote account: %v", err)
 	}
-
-	// Create a follow relationship
-	followId := uuid.New()
 	_, err = db.db.Exec(`INSERT INTO follows(id, account_id, target_account_id, accepted, is_local) VALUES (?, ?, ?, 1, 0)`,
-		followId.String(), localAccountId.String(), remoteAccountId.String())
+		uuid.New().String(), localAccountId.String(), remoteAccountId.String())
 	if err != nil {
 		t.Fatalf("Failed to create follow: %v", err)
 	}
 
-	// Create an activity from the remote user
-	objectURI := "https://remote.example.com/notes/123"
 	activity := &domain.Activity{
 		Id:           uuid.New(),
-		ActivityURI:  "https://remote.example.com/activities/create",
+		ActivityURI:  "https://remote.example.com/activities/html",
 		ActivityType: "Create",
 		ActorURI:     "https://remote.example.com/users/remoteuser",
-		ObjectURI:    objectURI,
-		RawJSON:      `{"type":"Create","object":{"id":"` + objectURI + `","content":"Hello from remote","inReplyTo":null}}`,
-		Processed:    true,
-		CreatedAt:    time.Now(),
-		Local:        false,
+		ObjectURI:    "https://remote.example.com/notes/html",
+		RawJSON: `{"type":"Create","object":{"id":"https://remote.example.com/notes/html","inReplyTo":null,` +
+			`"content":"<p onclick=\"evil()\">Read <a href=\"https://example.com/post\" class=\"x\">this</a><script>alert(1)</script></p>"}}`,
+		Processed: true,
+		CreatedAt: time.Now(),
 	}
 	if err := db.CreateActivity(activity); err != nil {
 		t.Fatalf("Failed to create activity: %v", err)
 	}
 
-	// Set like and boost counts
-	db.db.Exec("UPDATE activities SET like_count = 15, boost_count = 8 WHERE object_uri = ?", objectURI)
-
-	// Read home timeline
-	err, posts := db.ReadHomeTimelinePosts(localAccountId, 10)
-	if err != nil {
-		t.Fatalf("ReadHomeTimelinePosts failed: %v", err)
-	}
-
-	// Find the remote post
-	var remotePost *domain.HomePost
-	for i := range *posts {
-		if (*posts)[i].ObjectURI == objectURI {
-			remotePost = &(*posts)[i]
-			break
+	readContent := func() (string, string) {
+		var contentHTML, contentText string
+		err := db.db.QueryRow(`SELECT content_html, content_text FROM activities WHERE id = ?`, activity.Id.String()).Scan(&contentHTML, &contentText)
+		if err != nil {
+			t.Fatalf("Failed to read content: %v", err)
 		}
+		return contentHTML, contentText
 	}
-
-	if remotePost == nil {
-		t.Fatal("Expected to find remote post in home timeline")
-	}
-
-	if remotePost.LikeCount != 15 {
-		t.Errorf("Expected LikeCount 15, got %d", remotePost.LikeCount)
-	}
-	if remotePost.BoostCount != 8 {
-		t.Errorf("Expected BoostCount 8, got %d", remotePost.BoostCount)
+	contentHTML, contentText := readContent()
+	if contentHTML != `<p>Read <a href="https://example.com/post">this</a></p>` {
+		t.Errorf("Unexpected sanitized HTML %q", contentHTML)
 	}
-}
-
-// ============ Relay Tests ============
-
-func TestCreateRelay(t *testing.T) {
-	db := setupTestDB(t)
-	defer db.db.Close()
-
-	relay := &domain.Relay{
-		Id:        uuid.New(),
-		ActorURI:  "https://relay.example.com/actor",
-		InboxURI:  "https://relay.example.com/inbox",
-		Name:      "Test Relay",
-		Status:    "pending",
-		CreatedAt: time.Now(),
+	if contentText != "Read this" {
+		t.Errorf("Unexpected plain text %q", contentText)
 	}
 
-	err := db.CreateRelay(relay)
+	// The home timeline renders the sanitized HTML as Markdown
+	posts, err := db.ReadHomeTimelinePosts(localAccountId, 10)
 	if err != nil {
-		t.Fatalf("CreateRelay failed: %v", err)
+		t.Fatalf("ReadHomeTimelinePosts failed: %v", err)
 	}
-
-	// Verify it was created
-	err, fetched := db.ReadRelayByActorURI(relay.ActorURI)
-	if err != nil {
-		t.Fatalf("ReadRelayByActorURI failed: %v", err)
+	if len(*posts) != 1 || (*posts)[0].Content != "Read [this](https://example.com/post)" {
+		t.Errorf("Expected the post as Markdown, got %+v", *posts)
 	}
 
-	if fetched.Name != relay.Name {
-		t.Errorf("Expected Name %s, got %s", relay.Name, fetched.Name)
+	// Edits replace the stored content
+	activity.RawJSON = `{"type":"Create","object":{"id":"https://remote.example.com/notes/html","inReplyTo":null,"content":"<p>edited <em>post</em></p>"}}`
+	if err := db.UpdateActivity(activity); err != nil {
+		t.Fatalf("UpdateActivity failed: %v", err)
 	}
-	if fetched.Status != "pending" {
-		t.Errorf("Expected Status 'pending', got %s", fetched.Status)
+	if contentHTML, contentText = readContent(); contentHTML != "<p>edited <em>post</em></p>" || contentText != "edited post" {
+		t.Errorf("Expected the edited content, got %q and %q", contentHTML, contentText)
 	}
 }
 
-func TestReadAllRelays(t *testing.T) {
+func TestCreateActivityStoresArticleTitle(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.db.Close()
 
-	// Create multiple relays
-	relay1 := &domain.Relay{
-		Id:        uuid.New(),
-		ActorURI:  "https://relay1.example.com/actor",
-		InboxURI:  "https://relay1.example.com/inbox",
-		Name:      "Relay 1",
-		Status:    "active",
+	localAccountId := uuid.New()
+	createTestAccount(t, db, localAccountId, "localuser", "ssh-key", "webpub", "webpriv")
+	remoteAccountId := uuid.New()
+	actorURI := "https://blog.example.com/users/writer"
+	db.db.Exec(`INSERT INTO remote_accounts(id, username, domain, actor_uri, inbox_uri) VALUES (?, 'writer', 'blog.example.com', ?, ?)`,
+		remoteAccountId.String(), actorURI, actorURI+"/inbox")
+	db.db.Exec(`INSERT INTO follows(id, account_id, target_account_id, accepted, is_local) VALUES (?, ?, ?, 1, 0)`,
+		uuid.New().String(), localAccountId.String(), remoteAccountId.String())
+
+	activity := &domain.Activity{
+		Id:           uuid.New(),
+		ActivityURI:  "https://blog.example.com/activities/1",
+		ActivityType: "Create",
+		ActorURI:     actorURI,
+		ObjectURI:    "https://blog.example.com/posts/1",
+		RawJSON: `{"type":"Create","object":{"type":"Article","id":"https://blog.example.com/posts/1","inReplyTo":null,` +
+			`"name":"Why SQLite","content":"<p>It is small.</p><p>And fast.</p>"}}`,
+		Processed: true,
 		CreatedAt: time.Now(),
 	}
-	relay2 := &domain.Relay{
-		Id:        uuid.New(),
-		ActorURI:  "https://relay2.example.com/actor",
-		InboxURI:  "https://relay2.example.com/inbox",
-		Name:      "Relay 2",
-		Status:    "pending",
-		CreatedAt: time.Now(),
+	if err := db.CreateActivity(activity); err != nil {
+		t.Fatalf("Failed to create activity: %v", err)
 	}
 
-	db.CreateRelay(relay1)
-	db.CreateRelay(relay2)
+	var title, contentText string
+	db.db.QueryRow(`SELECT title, content_text FROM activities WHERE id = ?`, activity.Id.String()).Scan(&title, &contentText)
+	if title != "Why SQLite" || contentText != "Why SQLite\n\nIt is small.\n\nAnd fast." {
+		t.Errorf("Unexpected stored title %q and text %q", title, contentText)
+	}
 
-	err, relays := db.ReadAllRelays()
+	posts, err := db.ReadHomeTimelinePosts(localAccountId, 10)
 	if err != nil {
-		t.Fatalf("ReadAllRelays failed: %v", err)
+		t.Fatalf("ReadHomeTimelinePosts failed: %v", err)
 	}
-
-	if len(*relays) != 2 {
-		t.Errorf("Expected 2 relays, got %d", len(*relays))
+	if len(*posts) != 1 || (*posts)[0].DisplayContent() != "Why SQLite — It is small. And fast." {
+		t.Errorf("Expected the article as title and excerpt, got %+v", *posts)
 	}
 }
 
-func TestReadActiveRelays(t *testing.T) {
+func TestReactions(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.db.Close()
 
-	// Create active and pending relays
-	activeRelay := &domain.Relay{
-		Id:        uuid.New(),
-		ActorURI:  "https://active.relay.example.com/actor",
-		InboxURI:  "https://active.relay.example.com/inbox",
-		Name:      "Active Relay",
-		Status:    "active",
-		CreatedAt: time.Now(),
+	accountId := uuid.New()
+	createTestAccount(t, db, accountId, "alice", "pubkey1", "webpub1", "webpriv1")
+	noteId, err := db.CreateNote(accountId, "React to me")
+	if err != nil {
+		t.Fatalf("CreateNote failed: %v", err)
 	}
-	pendingRelay := &domain.Relay{
-		Id:        uuid.New(),
-		ActorURI:  "https://pending.relay.example.com/actor",
-		InboxURI:  "https://pending.relay.example.com/inbox",
-		Name:      "Pending Relay",
-		Status:    "pending",
-		CreatedAt: time.Now(),
+	objectURI := "https://example.com/notes/" + noteId.String()
+	if _, err := db.db.Exec(`UPDATE notes SET object_uri = ? WHERE id = ?`, objectURI, noteId.String()); err != nil {
+		t.Fatalf("Failed to set object_uri: %v", err)
 	}
 
-	db.CreateRelay(activeRelay)
-	db.CreateRelay(pendingRelay)
+	bob := "https://remote.example.com/users/bob"
+	carol := "https://remote.example.com/users/carol"
+	base := time.Now().Add(-time.Hour)
+	reactions := []*domain.Reaction{
+		{Id: uuid.New(), ObjectURI: objectURI, Emoji: "🎉", ActorURI: bob, URI: "https://remote.example.com/react/1", CreatedAt: base},
+		{Id: uuid.New(), ObjectURI: objectURI, Emoji: "👍", ActorURI: bob, URI: "https://remote.example.com/react/2", CreatedAt: base.Add(time.Minute)},
+		{Id: uuid.New(), ObjectURI: objectURI, Emoji: "👍", ActorURI: carol, URI: "https://remote.example.com/react/3", CreatedAt: base.Add(2 * time.Minute)},
+		// Repeating a reaction is ignored
+		{Id: uuid.New(), ObjectURI: objectURI, Emoji: "👍", ActorURI: carol, URI: "https://remote.example.com/react/4", CreatedAt: base.Add(3 * time.Minute)},
+	}
+	for _, reaction := range reactions {
+		if err := db.CreateReaction(reaction); err != nil {
+			t.Fatalf("CreateReaction failed: %v", err)
+		}
+	}
 
-	err, relays := db.ReadActiveRelays()
+	counts, err := db.ReadReactionsByObjectURI(objectURI)
 	if err != nil {
-		t.Fatalf("ReadActiveRelays failed: %v", err)
+		t.Fatalf("ReadReactionsByObjectURI failed: %v", err)
+	}
+	if got := domain.FormatReactions(*counts); got != "👍 2 🎉 1" {
+		t.Errorf("Expected \"👍 2 🎉 1\", got %q", got)
 	}
 
-	if len(*relays) != 1 {
-		t.Errorf("Expected 1 active relay, got %d", len(*relays))
+	// The home timeline carries the counts of local posts
+	posts, err := db.ReadHomeTimelinePosts(accountId, 10)
+	if err != nil {
+		t.Fatalf("ReadHomeTimelinePosts failed: %v", err)
 	}
-	if (*relays)[0].Name != "Active Relay" {
-		t.Errorf("Expected 'Active Relay', got %s", (*relays)[0].Name)
+	if len(*posts) != 1 || domain.FormatReactions((*posts)[0].Reactions) != "👍 2 🎉 1" {
+		t.Errorf("Expected the post with its reactions, got %+v", *posts)
 	}
-}
-
-func TestUpdateRelayStatus(t *testing.T) {
-	db := setupTestDB(t)
-	defer db.db.Close()
 
-	relay := &domain.Relay{
-		Id:        uuid.New(),
-		ActorURI:  "https://relay.example.com/actor",
-		InboxURI:  "https://relay.example.com/inbox",
-		Name:      "Test Relay",
-		Status:    "pending",
-		CreatedAt: time.Now(),
+	// Undoing removes only the given emoji, by emoji or by activity URI
+	if err := db.DeleteReaction(carol, objectURI, "👍"); err != nil {
+		t.Fatalf("DeleteReaction failed: %v", err)
 	}
-	db.CreateRelay(relay)
-
-	// Update to active
-	now := time.Now()
-	err := db.UpdateRelayStatus(relay.Id, "active", &now)
-	if err != nil {
-		t.Fatalf("UpdateRelayStatus failed: %v", err)
+	if err := db.DeleteReactionByURI(bob, "https://remote.example.com/react/1"); err != nil {
+		t.Fatalf("DeleteReactionByURI failed: %v", err)
 	}
-
-	// Verify update
-	err, fetched := db.ReadRelayByActorURI(relay.ActorURI)
+	counts, err = db.ReadReactionsByObjectURI(objectURI)
 	if err != nil {
-		t.Fatalf("ReadRelayByActorURI failed: %v", err)
-	}
-
-	if fetched.Status != "active" {
-		t.Errorf("Expected Status 'active', got %s", fetched.Status)
+		t.Fatalf("ReadReactionsByObjectURI failed: %v", err)
 	}
-	if fetched.AcceptedAt == nil {
-		t.Error("Expected AcceptedAt to be set")
+	if got := domain.FormatReactions(*counts); got != "👍 1" {
+		t.Errorf("Expected \"👍 1\", got %q", got)
 	}
 }
 
-func TestDeleteRelay(t *testing.T) {
+func TestSearchAccounts(t *testing.T) {
 	db := setupTestDB(t)
 	defer db.db.Close()
 
-	relay := &domain.Relay{
-		Id:        uuid.New(),
-		ActorURI:  "https://relay.example.com/actor",
-		InboxURI:  "https://relay.example.com/inbox",
-		Name:      "Test Relay",
-		Status:    "pending",
-		CreatedAt: time.Now(),
+	createTestAccount(t, db, uuid.New(), "alice", "pubkey1", "webpub1", "webpriv1")
+	createTestAccount(t, db, uuid.New(), "bob", "pubkey2", "webpub2", "webpriv2")
+	createTestAccount(t, db, uuid.New(), "alicenew", "pubkey3", "webpub3", "webpriv3")
+	// Accounts that haven't chosen their username yet aren't listed
+	if _, err := db.db.Exec(`UPDATE accounts SET first_time_login = 0 WHERE username != 'alicenew'`); err != nil {
+		t.Fatalf("Failed to complete first login: %v", err)
+	}
+	for _, remote := range []*domain.RemoteAccount{
+		{Id: uuid.New(), Username: "Alicia", Domain: "mastodon.social", ActorURI: "https://mastodon.social/users/Alicia", DisplayName: "Alicia K"},
+		{Id: uuid.New(), Username: "carol", Domain: "example.com", ActorURI: "https://example.com/users/carol", DisplayName: "Carol from Alice's team"},
+		{Id: uuid.New(), Username: "dave_100", Domain: "example.com", ActorURI: "https://example.com/users/dave_100"},
+	} {
+		if err := db.CreateRemoteAccount(remote); err != nil {
+			t.Fatalf("CreateRemoteAccount failed: %v", err)
+		}
 	}
-	db.CreateRelay(relay)
 
-	// Delete relay
-	err := db.DeleteRelay(relay.Id)
+	results, err := db.SearchAccounts("ALI", 10)
 	if err != nil {
-		t.Fatalf("DeleteRelay failed: %v", err)
+		t.Fatalf("SearchAccounts failed: %v", err)
 	}
-
-	// Verify deletion
-	err, fetched := db.ReadRelayByActorURI(relay.ActorURI)
-	if err == nil && fetched != nil {
-		t.Error("Expected relay to be deleted")
+	var handles []string
+	for _, result := range *results {
+		handles = append(handles, result.Handle())
 	}
-}
-
-func TestReadRelayByActorURINotFound(t *testing.T) {
-	db := setupTestDB(t)
-	defer db.db.Close()
-
-	err, relay := db.ReadRelayByActorURI("https://nonexistent.relay.com/actor")
-	if err == nil && relay != nil {
-		t.Error("Expected error for non-existent relay")
+	// Local accounts come first, and display names match too
+	expected := []string{"@alice", "@Alicia@mastodon.social", "@carol@example.com"}
+	if fmt.Sprint(handles) != fmt.Sprint(expected) {
+		t.Errorf("Expected %v, got %v", expected, handles)
 	}
-}
-
-func TestReadRelayById(t *testing.T) {
-	db := setupTestDB(t)
-	defer db.db.Close()
-
-	relay := &domain.Relay{
-		Id:        uuid.New(),
-		ActorURI:  "https://relay.example.com/actor",
-		InboxURI:  "https://relay.example.com/inbox",
-		Name:      "Test Relay",
-		Status:    "pending",
-		CreatedAt: time.Now(),
+	if (*results)[1].ActorURI != "https://mastodon.social/users/Alicia" || (*results)[0].IsLocal() != true {
+		t.Errorf("Unexpected results: %+v", *results)
 	}
-	db.CreateRelay(relay)
 
-	// Read by ID
-	err, fetched := db.ReadRelayById(relay.Id)
+	// LIKE wildcards in the query are literal
+	results, err = db.SearchAccounts("_1", 10)
 	if err != nil {
-		t.Fatalf("ReadRelayById failed: %v", err)
+		t.Fatalf("SearchAccounts failed: %v", err)
+	}
+	if len(*results) != 1 || (*results)[0].Username != "dave_100" {
+		t.Errorf("Expected only dave_100, got %+v", *results)
 	}
 
-	if fetched.ActorURI != relay.ActorURI {
-		t.Errorf("Expected ActorURI %s, got %s", relay.ActorURI, fetched.ActorURI)
+	results, err = db.SearchAccounts("a", 2)
+	if err != nil {
+		t.Fatalf("SearchAccounts failed: %v", err)
+	}
+	if len(*results) != 2 {
+		t.Errorf("Expected the limit to apply, got %d results", len(*results))
 	}
 }
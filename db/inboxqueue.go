@@ -0,0 +1,85 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/deemkeen/stegodon/domain"
+	"github.com/google/uuid"
+)
+
+// Inbox queue queries
+const (
+	sqlInsertInboxQueue = `INSERT INTO inbox_queue(id, username, signer_uri, activity_json, attempts, next_attempt_at, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)`
+	// Oldest first, so activities are handled in the order they arrived
+	sqlSelectPendingInboxActivities = `SELECT id, username, signer_uri, activity_json, attempts, next_attempt_at, created_at FROM inbox_queue
+		WHERE next_attempt_at <= ? ORDER BY created_at ASC, rowid ASC LIMIT ?`
+	sqlUpdateInboxActivityAttempt = `UPDATE inbox_queue SET attempts = ?, next_attempt_at = ? WHERE id = ?`
+	sqlDeleteInboxActivity        = `DELETE FROM inbox_queue WHERE id = ?`
+	sqlCountQueuedInboxActivities = `SELECT COUNT(*) FROM inbox_queue`
+)
+
+// EnqueueInboxActivity queues a received activity for the inbox worker
+func (db *DB) EnqueueInboxActivity(item *domain.InboxQueueItem) error {
+	return db.wrapTransaction(func(tx *sql.Tx) error {
+		_, err := tx.Exec(sqlInsertInboxQueue,
+			item.Id.String(),
+			item.Username,
+			item.SignerURI,
+			item.ActivityJSON,
+			item.Attempts,
+			formatTimestamp(item.NextAttemptAt),
+			formatTimestamp(item.CreatedAt),
+		)
+		return err
+	})
+}
+
+// ReadPendingInboxActivities returns up to limit queued activities that are due, oldest first
+func (db *DB) ReadPendingInboxActivities(limit int) (*[]domain.InboxQueueItem, error) {
+	rows, err := db.conn().Query(sqlSelectPendingInboxActivities, formatTimestamp(time.Now()), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []domain.InboxQueueItem
+	for rows.Next() {
+		var item domain.InboxQueueItem
+		var idStr string
+		if err := rows.Scan(&idStr, &item.Username, &item.SignerURI, &item.ActivityJSON, &item.Attempts, &item.NextAttemptAt, &item.CreatedAt); err != nil {
+			return &items, err
+		}
+		item.Id, _ = uuid.Parse(idStr)
+		items = append(items, item)
+	}
+	if err = rows.Err(); err != nil {
+		return &items, err
+	}
+	return &items, nil
+}
+
+// UpdateInboxActivityAttempt reschedules a queued activity whose handling failed
+func (db *DB) UpdateInboxActivityAttempt(id uuid.UUID, attempts int, nextAttempt time.Time) error {
+	return db.wrapTransaction(func(tx *sql.Tx) error {
+		_, err := tx.Exec(sqlUpdateInboxActivityAttempt, attempts, formatTimestamp(nextAttempt), id.String())
+		return err
+	})
+}
+
+// DeleteInboxActivity removes a handled or abandoned activity from the queue
+func (db *DB) DeleteInboxActivity(id uuid.UUID) error {
+	return db.wrapTransaction(func(tx *sql.Tx) error {
+		_, err := tx.Exec(sqlDeleteInboxActivity, id.String())
+		return err
+	})
+}
+
+// CountQueuedInboxActivities returns the number of received activities waiting to be handled
+func (db *DB) CountQueuedInboxActivities() (int, error) {
+	var count int
+	if err := db.conn().QueryRow(sqlCountQueuedInboxActivities).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
@@ -99,6 +99,22 @@ const (
 		CREATE INDEX IF NOT EXISTS idx_boosts_account_id ON boosts(account_id);
 	`
 
+	// Emoji reactions on posts, one row per actor and emoji
+	sqlCreateReactionsTable = `CREATE TABLE IF NOT EXISTS reactions (
+		id TEXT NOT NULL PRIMARY KEY,
+		object_uri TEXT NOT NULL,
+		emoji TEXT NOT NULL,
+		actor_uri TEXT NOT NULL,
+		uri TEXT,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(object_uri, emoji, actor_uri)
+	)`
+
+	sqlCreateReactionsIndices = `
+		CREATE INDEX IF NOT EXISTS idx_reactions_object_uri ON reactions(object_uri);
+		CREATE INDEX IF NOT EXISTS idx_reactions_uri ON reactions(uri);
+	`
+
 	// Delivery queue table
 	sqlCreateDeliveryQueueTable = `CREATE TABLE IF NOT EXISTS delivery_queue (
 		id TEXT NOT NULL PRIMARY KEY,
@@ -140,6 +156,19 @@ const (
 		CREATE INDEX IF NOT EXISTS idx_note_hashtags_hashtag_id ON note_hashtags(hashtag_id);
 	`
 
+	// Activity-hashtag relationship table (hashtags of inbound remote posts)
+	sqlCreateActivityHashtagsTable = `CREATE TABLE IF NOT EXISTS activity_hashtags (
+		activity_id TEXT NOT NULL,
+		hashtag_id INTEGER NOT NULL,
+		PRIMARY KEY (activity_id, hashtag_id),
+		FOREIGN KEY (activity_id) REFERENCES activities(id) ON DELETE CASCADE,
+		FOREIGN KEY (hashtag_id) REFERENCES hashtags(id) ON DELETE CASCADE
+	)`
+
+	sqlCreateActivityHashtagsIndices = `
+		CREATE INDEX IF NOT EXISTS idx_activity_hashtags_hashtag_id ON activity_hashtags(hashtag_id);
+	`
+
 	// Note-mention relationship table (stores @user@domain mentions in notes)
 	sqlCreateNoteMentionsTable = `CREATE TABLE IF NOT EXISTS note_mentions (
 		id TEXT PRIMARY KEY,
@@ -194,6 +223,65 @@ const (
 		CREATE INDEX IF NOT EXISTS idx_notifications_account_read ON notifications(account_id, read);
 	`
 
+	// HTTP API tokens (only the hash of each token is stored; scopes is a comma-separated list)
+	sqlCreateAPITokensTable = `CREATE TABLE IF NOT EXISTS api_tokens (
+		id TEXT NOT NULL PRIMARY KEY,
+		account_id TEXT NOT NULL,
+		token_hash TEXT UNIQUE NOT NULL,
+		scopes TEXT NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (account_id) REFERENCES accounts(id) ON DELETE CASCADE
+	)`
+
+	sqlCreateAPITokensIndices = `
+		CREATE INDEX IF NOT EXISTS idx_api_tokens_account_id ON api_tokens(account_id);
+	`
+
+	// SSH keys added to accounts besides the primary key kept in accounts.publickey
+	sqlCreateAccountKeysTable = `CREATE TABLE IF NOT EXISTS account_keys (
+		id TEXT NOT NULL PRIMARY KEY,
+		account_id TEXT NOT NULL,
+		pk_hash TEXT UNIQUE NOT NULL,
+		label TEXT NOT NULL DEFAULT '',
+		added_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (account_id) REFERENCES accounts(id) ON DELETE CASCADE
+	)`
+
+	sqlCreateAccountKeysIndices = `
+		CREATE INDEX IF NOT EXISTS idx_account_keys_account_id ON account_keys(account_id);
+	`
+
+	// Remote accounts muted by local accounts; expires_at is NULL for mutes without an end
+	sqlCreateMutedAccountsTable = `CREATE TABLE IF NOT EXISTS muted_accounts (
+		id TEXT NOT NULL PRIMARY KEY,
+		account_id TEXT NOT NULL,
+		target_actor_uri TEXT NOT NULL,
+		hide_notifications INTEGER DEFAULT 1,
+		expires_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(account_id, target_actor_uri),
+		FOREIGN KEY (account_id) REFERENCES accounts(id) ON DELETE CASCADE
+	)`
+
+	sqlCreateMutedAccountsIndices = `
+		CREATE INDEX IF NOT EXISTS idx_muted_accounts_expires_at ON muted_accounts(expires_at);
+	`
+
+	// Inbox queue: activities accepted by the inbox and handled by a worker (inboxQueue mode)
+	sqlCreateInboxQueueTable = `CREATE TABLE IF NOT EXISTS inbox_queue (
+		id TEXT NOT NULL PRIMARY KEY,
+		username TEXT NOT NULL,
+		signer_uri TEXT NOT NULL,
+		activity_json TEXT NOT NULL,
+		attempts INTEGER DEFAULT 0,
+		next_attempt_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`
+
+	sqlCreateInboxQueueIndices = `
+		CREATE INDEX IF NOT EXISTS idx_inbox_queue_next_attempt ON inbox_queue(next_attempt_at);
+	`
+
 	// Extend existing tables with new columns
 	sqlExtendAccountsTable = `
 		ALTER TABLE accounts ADD COLUMN display_name TEXT;
@@ -237,6 +325,9 @@ func (db *DB) RunMigrations() error {
 		if err := db.createTableIfNotExists(tx, sqlCreateBoostsTable, "boosts"); err != nil {
 			return err
 		}
+		if err := db.createTableIfNotExists(tx, sqlCreateReactionsTable, "reactions"); err != nil {
+			return err
+		}
 		if err := db.createTableIfNotExists(tx, sqlCreateDeliveryQueueTable, "delivery_queue"); err != nil {
 			return err
 		}
@@ -246,6 +337,9 @@ func (db *DB) RunMigrations() error {
 		if err := db.createTableIfNotExists(tx, sqlCreateNoteHashtagsTable, "note_hashtags"); err != nil {
 			return err
 		}
+		if err := db.createTableIfNotExists(tx, sqlCreateActivityHashtagsTable, "activity_hashtags"); err != nil {
+			return err
+		}
 		if err := db.createTableIfNotExists(tx, sqlCreateNoteMentionsTable, "note_mentions"); err != nil {
 			return err
 		}
@@ -255,6 +349,18 @@ func (db *DB) RunMigrations() error {
 		if err := db.createTableIfNotExists(tx, sqlCreateNotificationsTable, "notifications"); err != nil {
 			return err
 		}
+		if err := db.createTableIfNotExists(tx, sqlCreateAPITokensTable, "api_tokens"); err != nil {
+			return err
+		}
+		if err := db.createTableIfNotExists(tx, sqlCreateMutedAccountsTable, "muted_accounts"); err != nil {
+			return err
+		}
+		if err := db.createTableIfNotExists(tx, sqlCreateInboxQueueTable, "inbox_queue"); err != nil {
+			return err
+		}
+		if err := db.createTableIfNotExists(tx, sqlCreateAccountKeysTable, "account_keys"); err != nil {
+			return err
+		}
 
 		// Create indices
 		if _, err := tx.Exec(sqlCreateFollowsIndices); err != nil {
@@ -272,6 +378,9 @@ func (db *DB) RunMigrations() error {
 		if _, err := tx.Exec(sqlCreateBoostsIndices); err != nil {
 			log.Printf("Warning: Failed to create boosts indices: %v", err)
 		}
+		if _, err := tx.Exec(sqlCreateReactionsIndices); err != nil {
+			log.Printf("Warning: Failed to create reactions indices: %v", err)
+		}
 		if _, err := tx.Exec(sqlCreateDeliveryQueueIndices); err != nil {
 			log.Printf("Warning: Failed to create delivery_queue indices: %v", err)
 		}
@@ -281,6 +390,9 @@ func (db *DB) RunMigrations() error {
 		if _, err := tx.Exec(sqlCreateNoteHashtagsIndices); err != nil {
 			log.Printf("Warning: Failed to create note_hashtags indices: %v", err)
 		}
+		if _, err := tx.Exec(sqlCreateActivityHashtagsIndices); err != nil {
+			log.Printf("Warning: Failed to create activity_hashtags indices: %v", err)
+		}
 		if _, err := tx.Exec(sqlCreateNoteMentionsIndices); err != nil {
 			log.Printf("Warning: Failed to create note_mentions indices: %v", err)
 		}
@@ -293,15 +405,37 @@ func (db *DB) RunMigrations() error {
 		if _, err := tx.Exec(sqlCreateNotesIndices); err != nil {
 			log.Printf("Warning: Failed to create notes indices: %v", err)
 		}
+		if _, err := tx.Exec(sqlCreateAPITokensIndices); err != nil {
+			log.Printf("Warning: Failed to create api_tokens indices: %v", err)
+		}
+		if _, err := tx.Exec(sqlCreateMutedAccountsIndices); err != nil {
+			log.Printf("Warning: Failed to create muted_accounts indices: %v", err)
+		}
+		if _, err := tx.Exec(sqlCreateInboxQueueIndices); err != nil {
+			log.Printf("Warning: Failed to create inbox_queue indices: %v", err)
+		}
+		if _, err := tx.Exec(sqlCreateAccountKeysIndices); err != nil {
+			log.Printf("Warning: Failed to create account_keys indices: %v", err)
+		}
 
 		// Extend existing tables (ignore errors if columns already exist)
 		db.extendExistingTables(tx)
 
+		// Rewrite timestamps stored before all of them were written in UTC timestampFormat
+		if err := db.normalizeTimestamps(tx); err != nil {
+			log.Printf("Warning: Failed to normalize timestamps: %v", err)
+		}
+
 		// Backfill object_uri for existing activities
 		if err := db.backfillActivityObjectURIs(tx); err != nil {
 			log.Printf("Warning: Failed to backfill activity object_uri: %v", err)
 		}
 
+		// Backfill object_uri for local notes created before it was always set
+		if err := db.backfillNoteObjectURIs(tx); err != nil {
+			log.Printf("Warning: Failed to backfill note object_uri: %v", err)
+		}
+
 		// Add username uniqueness constraint (handles duplicates gracefully)
 		if err := db.addUsernameUniqueConstraint(tx); err != nil {
 			log.Printf("Warning: Failed to add username unique constraint: %v", err)
@@ -312,6 +446,11 @@ func (db *DB) RunMigrations() error {
 			log.Printf("Warning: Failed to backfill reply counts: %v", err)
 		}
 
+		// Assign thread roots to replies stored before thread_root_uri existed
+		if err := db.backfillThreadRoots(tx); err != nil {
+			log.Printf("Warning: Failed to backfill thread roots: %v", err)
+		}
+
 		// Fix orphaned Update activities (convert to Create so they show in timeline)
 		if err := db.fixOrphanedUpdateActivities(tx); err != nil {
 			log.Printf("Warning: Failed to fix orphaned Update activities: %v", err)
@@ -380,9 +519,121 @@ func (db *DB) extendExistingTables(tx *sql.Tx) {
 	// Add from_relay column to activities table to track relay-forwarded content
 	tx.Exec("ALTER TABLE activities ADD COLUMN from_relay INTEGER DEFAULT 0")
 
+	// Add shared inbox to remote_accounts (used to fan out deliveries once per server)
+	tx.Exec("ALTER TABLE remote_accounts ADD COLUMN shared_inbox_uri TEXT")
+
+	// Track relay Follow re-sends so pending subscriptions can be retried and eventually failed
+	tx.Exec("ALTER TABLE relays ADD COLUMN account_id TEXT")
+	tx.Exec("ALTER TABLE relays ADD COLUMN follow_attempts INTEGER DEFAULT 1")
+	tx.Exec("ALTER TABLE relays ADD COLUMN last_follow_at TIMESTAMP")
+
+	// Track when each relay last forwarded content, for relay health monitoring
+	tx.Exec("ALTER TABLE relays ADD COLUMN last_activity_at TIMESTAMP")
+	tx.Exec("ALTER TABLE relays ADD COLUMN activity_count INTEGER DEFAULT 0")
+
+	// Add relay_type to relays so re-sends and Undo use the Follow target the relay software expects
+	tx.Exec("ALTER TABLE relays ADD COLUMN relay_type TEXT DEFAULT 'mastodon'")
+
+	// Add last HTTP status and dead-letter flag to delivery_queue for failed deliveries
+	tx.Exec("ALTER TABLE delivery_queue ADD COLUMN last_status INTEGER DEFAULT 0")
+	tx.Exec("ALTER TABLE delivery_queue ADD COLUMN dead_lettered INTEGER DEFAULT 0")
+
+	// Add post languages and per-account language preferences for timeline filtering
+	tx.Exec("ALTER TABLE notes ADD COLUMN language TEXT")
+	tx.Exec("ALTER TABLE activities ADD COLUMN language TEXT")
+	tx.Exec("ALTER TABLE accounts ADD COLUMN default_language TEXT")
+	tx.Exec("ALTER TABLE accounts ADD COLUMN filter_languages TEXT")
+
+	// Add the quoted post of incoming quote posts (URI plus author and text snapshot for display)
+	tx.Exec("ALTER TABLE activities ADD COLUMN quote_uri TEXT")
+	tx.Exec("ALTER TABLE activities ADD COLUMN quote_author TEXT")
+	tx.Exec("ALTER TABLE activities ADD COLUMN quote_content TEXT")
+
+	// Add manual follower approval; follows of such accounts stay pending (accepted = 0) until approved
+	tx.Exec("ALTER TABLE accounts ADD COLUMN manually_approves_followers INTEGER DEFAULT 0")
+
+	// Add tombstones for deleted notes; they are purged after the retention window
+	tx.Exec("ALTER TABLE notes ADD COLUMN deleted_at TIMESTAMP")
+
+	// Per-relay filters for forwarded posts (comma-separated lists)
+	tx.Exec("ALTER TABLE relays ADD COLUMN filter_blocked_domains TEXT")
+	tx.Exec("ALTER TABLE relays ADD COLUMN filter_muted_words TEXT")
+	tx.Exec("ALTER TABLE relays ADD COLUMN filter_required_tags TEXT")
+
+	// Daily quiet hours a relay is paused in, and what the schedule did in the current window
+	tx.Exec("ALTER TABLE relays ADD COLUMN pause_schedule TEXT")
+	tx.Exec("ALTER TABLE relays ADD COLUMN pause_schedule_state TEXT")
+
+	// Followers-only and direct posts fetched for accounts that don't follow their author
+	tx.Exec("ALTER TABLE activities ADD COLUMN restricted INTEGER DEFAULT 0")
+
+	// Thread root of replies, counted on read in the thread reply count mode
+	tx.Exec("ALTER TABLE notes ADD COLUMN thread_root_uri TEXT")
+	tx.Exec("ALTER TABLE activities ADD COLUMN thread_root_uri TEXT")
+	tx.Exec("CREATE INDEX IF NOT EXISTS idx_notes_thread_root_uri ON notes(thread_root_uri)")
+	tx.Exec("CREATE INDEX IF NOT EXISTS idx_activities_thread_root_uri ON activities(thread_root_uri)")
+
+	// Notify the follower of every top-level post by the followed account
+	tx.Exec("ALTER TABLE follows ADD COLUMN notify INTEGER DEFAULT 0")
+
+	// Sanitized HTML and plain text of incoming posts, derived from raw_json when stored
+	tx.Exec("ALTER TABLE activities ADD COLUMN content_html TEXT")
+	tx.Exec("ALTER TABLE activities ADD COLUMN content_text TEXT")
+
+	// Title of incoming long-form posts (Article, Page) and other titled objects
+	tx.Exec("ALTER TABLE activities ADD COLUMN title TEXT")
+
+	// Accounts created under the approval registration policy stay pending until an admin approves them
+	tx.Exec("ALTER TABLE accounts ADD COLUMN pending INTEGER DEFAULT 0")
+
+	// The Note object of a local note as it was last federated, served on dereference
+	tx.Exec("ALTER TABLE notes ADD COLUMN object_json TEXT")
+
+	// Disabled accounts are suspended by an admin without deleting their content
+	tx.Exec("ALTER TABLE accounts ADD COLUMN disabled INTEGER DEFAULT 0")
+
+	// Whether replies to followed accounts are shown in the home timeline (hidden by default)
+	tx.Exec("ALTER TABLE accounts ADD COLUMN show_replies_in_home INTEGER DEFAULT 0")
+
+	// Deliveries about the same object are sent to an inbox in the order they were queued
+	tx.Exec("ALTER TABLE delivery_queue ADD COLUMN object_uri TEXT")
+	tx.Exec("ALTER TABLE delivery_queue ADD COLUMN seq INTEGER DEFAULT 0")
+	tx.Exec("CREATE INDEX IF NOT EXISTS idx_delivery_queue_object_uri ON delivery_queue(object_uri, inbox_uri)")
+	tx.Exec("CREATE INDEX IF NOT EXISTS idx_delivery_queue_seq ON delivery_queue(seq)")
+
+	// All keys of actors that publish several, as a JSON object of key id to key
+	tx.Exec("ALTER TABLE remote_accounts ADD COLUMN public_keys TEXT")
+
+	// Whether an account's posts are listed in the public timeline (NULL = instance default)
+	tx.Exec("ALTER TABLE accounts ADD COLUMN discoverable INTEGER")
+
+	// The timezone an account's timestamps are shown in (NULL = UTC). Notes and activities
+	// used to be stamped in the server's local time; they move to UTC along with the column.
+	if _, err := tx.Exec("ALTER TABLE accounts ADD COLUMN timezone TEXT"); err == nil {
+		tx.Exec("UPDATE notes SET created_at = COALESCE(datetime(created_at, 'utc'), created_at), edited_at = COALESCE(datetime(edited_at, 'utc'), edited_at)")
+		tx.Exec("UPDATE activities SET created_at = COALESCE(datetime(created_at, 'utc'), created_at)")
+	}
+
 	log.Println("Extended existing tables with new columns")
 }
 
+// backfillNoteObjectURIs sets object_uri for local notes that are missing it, built from
+// the configured SSL domain and the note id. Without a config (SetConfig) it does nothing.
+func (db *DB) backfillNoteObjectURIs(tx *sql.Tx) error {
+	if db.conf == nil {
+		return nil
+	}
+	result, err := tx.Exec(`UPDATE notes SET object_uri = 'https://' || ? || '/notes/' || id WHERE object_uri IS NULL OR object_uri = ''`,
+		db.conf.Conf.SslDomain)
+	if err != nil {
+		return err
+	}
+	if count, _ := result.RowsAffected(); count > 0 {
+		log.Printf("Backfilled object_uri for %d notes", count)
+	}
+	return nil
+}
+
 // backfillActivityObjectURIs extracts object_uri from raw_json for activities that are missing it
 func (db *DB) backfillActivityObjectURIs(tx *sql.Tx) error {
 	// Find activities with empty object_uri
@@ -543,7 +794,14 @@ func (db *DB) backfillReplyCounts(tx *sql.Tx) error {
 	}
 
 	log.Println("Backfilling reply counts for notes and activities (recursive)...")
+	db.recountReplies(tx)
+	log.Println("Completed backfilling reply counts")
+	return nil
+}
 
+// recountReplies resets reply_count on all notes and activities and counts every stored
+// reply again on all of its ancestors
+func (db *DB) recountReplies(tx *sql.Tx) error {
 	// Reset all counts to 0
 	tx.Exec(`UPDATE notes SET reply_count = 0`)
 	tx.Exec(`UPDATE activities SET reply_count = 0`)
@@ -593,8 +851,96 @@ func (db *DB) backfillReplyCounts(tx *sql.Tx) error {
 			}
 		}
 	}
+	return nil
+}
 
-	log.Println("Completed backfilling reply counts")
+// backfillThreadRoots assigns thread_root_uri to existing replies once,
+// when no reply has a thread root yet
+func (db *DB) backfillThreadRoots(tx *sql.Tx) error {
+	var assigned, replies int
+	tx.QueryRow(`SELECT (SELECT COUNT(*) FROM notes WHERE thread_root_uri IS NOT NULL) +
+		(SELECT COUNT(*) FROM activities WHERE thread_root_uri IS NOT NULL)`).Scan(&assigned)
+	tx.QueryRow(`SELECT (SELECT COUNT(*) FROM notes WHERE in_reply_to_uri IS NOT NULL AND in_reply_to_uri != '') +
+		(SELECT COUNT(*) FROM activities WHERE activity_type = 'Create' AND raw_json LIKE '%"inReplyTo":"http%')`).Scan(&replies)
+	if assigned > 0 || replies == 0 {
+		return nil
+	}
+
+	log.Println("Backfilling thread roots for replies...")
+	if err := db.assignThreadRoots(tx); err != nil {
+		return err
+	}
+	log.Println("Completed backfilling thread roots")
+	return nil
+}
+
+// timestampColumns are the columns holding timestamps, as table and column
+var timestampColumns = [][2]string{
+	{"accounts", "created_at"},
+	{"notes", "created_at"}, {"notes", "edited_at"}, {"notes", "deleted_at"},
+	{"follows", "created_at"},
+	{"remote_accounts", "last_fetched_at"},
+	{"activities", "created_at"},
+	{"likes", "created_at"},
+	{"boosts", "created_at"},
+	{"reactions", "created_at"},
+	{"delivery_queue", "next_retry_at"}, {"delivery_queue", "created_at"},
+	{"hashtags", "last_used_at"},
+	{"note_mentions", "created_at"},
+	{"relays", "created_at"}, {"relays", "accepted_at"}, {"relays", "last_follow_at"}, {"relays", "last_activity_at"},
+	{"notifications", "created_at"},
+	{"api_tokens", "created_at"},
+	{"account_keys", "added_at"},
+	{"muted_accounts", "expires_at"}, {"muted_accounts", "created_at"},
+	{"inbox_queue", "next_attempt_at"}, {"inbox_queue", "created_at"},
+}
+
+// normalizeTimestamps rewrites timestamps that aren't in timestampFormat. Time parameters used
+// to be stored as time.Time.String() and some timestamps as RFC3339, both in the server's
+// offset, which neither sort nor compare correctly with UTC timestamps, e.g. across DST.
+func (db *DB) normalizeTimestamps(tx *sql.Tx) error {
+	type fix struct {
+		rowid  int64
+		stored string
+	}
+
+	normalized := 0
+	for _, column := range timestampColumns {
+		table, name := column[0], column[1]
+		// CAST keeps the driver from parsing the value itself
+		rows, err := tx.Query(fmt.Sprintf(`SELECT rowid, CAST(%[2]s AS TEXT) FROM %[1]s
+			WHERE %[2]s IS NOT NULL AND %[2]s NOT GLOB '[0-9][0-9][0-9][0-9]-[0-9][0-9]-[0-9][0-9] [0-9][0-9]:[0-9][0-9]:[0-9][0-9]'
+			AND %[2]s NOT GLOB '[0-9][0-9][0-9][0-9]-[0-9][0-9]-[0-9][0-9] [0-9][0-9]:[0-9][0-9]:[0-9][0-9].[0-9]*'`, table, name))
+		if err != nil {
+			return fmt.Errorf("failed to query %s.%s: %w", table, name, err)
+		}
+		var fixes []fix
+		for rows.Next() {
+			var f fix
+			if err := rows.Scan(&f.rowid, &f.stored); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan %s.%s: %w", table, name, err)
+			}
+			fixes = append(fixes, f)
+		}
+		rows.Close()
+
+		for _, f := range fixes {
+			t, err := parseTimestamp(f.stored)
+			if err != nil {
+				log.Printf("Warning: Leaving %s.%s of row %d as is: %v", table, name, f.rowid, err)
+				continue
+			}
+			if _, err := tx.Exec(fmt.Sprintf(`UPDATE %s SET %s = ? WHERE rowid = ?`, table, name), formatTimestamp(t), f.rowid); err != nil {
+				return fmt.Errorf("failed to update %s.%s: %w", table, name, err)
+			}
+			normalized++
+		}
+	}
+
+	if normalized > 0 {
+		log.Printf("Normalized %d timestamps to UTC", normalized)
+	}
 	return nil
 }
 
@@ -657,19 +1003,19 @@ func (db *DB) MigratePerformanceIndexes() error {
 	log.Println("Checking for missing performance indexes...")
 
 	// Add index on notes.in_reply_to_uri for faster threading queries
-	_, err := db.db.Exec(`CREATE INDEX IF NOT EXISTS idx_notes_in_reply_to_uri ON notes(in_reply_to_uri)`)
+	_, err := db.conn().Exec(`CREATE INDEX IF NOT EXISTS idx_notes_in_reply_to_uri ON notes(in_reply_to_uri)`)
 	if err != nil {
 		log.Printf("Warning: Failed to create idx_notes_in_reply_to_uri: %v", err)
 	}
 
 	// Add index on activities.object_uri for faster deduplication checks
-	_, err = db.db.Exec(`CREATE INDEX IF NOT EXISTS idx_activities_object_uri ON activities(object_uri)`)
+	_, err = db.conn().Exec(`CREATE INDEX IF NOT EXISTS idx_activities_object_uri ON activities(object_uri)`)
 	if err != nil {
 		log.Printf("Warning: Failed to create idx_activities_object_uri: %v", err)
 	}
 
 	// Add index on activities.from_relay for faster relay content filtering
-	_, err = db.db.Exec(`CREATE INDEX IF NOT EXISTS idx_activities_from_relay ON activities(from_relay)`)
+	_, err = db.conn().Exec(`CREATE INDEX IF NOT EXISTS idx_activities_from_relay ON activities(from_relay)`)
 	if err != nil {
 		log.Printf("Warning: Failed to create idx_activities_from_relay: %v", err)
 	}
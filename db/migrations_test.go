@@ -32,7 +32,11 @@ func setupMigrationTestDB(t *testing.T) *DB {
 		summary TEXT,
 		avatar_url TEXT,
 		is_admin INTEGER DEFAULT 0,
-		muted INTEGER DEFAULT 0
+		muted INTEGER DEFAULT 0,
+		manually_approves_followers INTEGER DEFAULT 0,
+		pending INTEGER DEFAULT 0,
+		disabled INTEGER DEFAULT 0,
+		discoverable INTEGER
 	)`)
 	if err != nil {
 		t.Fatalf("Failed to create accounts table: %v", err)
@@ -59,7 +63,7 @@ func TestAddUsernameUniqueConstraint_NoDuplicates(t *testing.T) {
 		t.Fatalf("Migration failed: %v", err)
 	}
 
-	err1, a1 := db.ReadAccById(id1)
+	a1, err1 := db.ReadAccById(id1)
 	if err1 != nil {
 		t.Fatalf("Failed to read alice: %v", err1)
 	}
@@ -67,7 +71,7 @@ func TestAddUsernameUniqueConstraint_NoDuplicates(t *testing.T) {
 		t.Errorf("Expected alice, got %s", a1.Username)
 	}
 
-	err2, a2 := db.ReadAccById(id2)
+	a2, err2 := db.ReadAccById(id2)
 	if err2 != nil {
 		t.Fatalf("Failed to read bob: %v", err2)
 	}
@@ -75,7 +79,7 @@ func TestAddUsernameUniqueConstraint_NoDuplicates(t *testing.T) {
 		t.Errorf("Expected bob, got %s", a2.Username)
 	}
 
-	err3, a3 := db.ReadAccById(id3)
+	a3, err3 := db.ReadAccById(id3)
 	if err3 != nil {
 		t.Fatalf("Failed to read charlie: %v", err3)
 	}
@@ -131,7 +135,7 @@ func TestAddUsernameUniqueConstraint_WithDuplicates(t *testing.T) {
 		t.Fatalf("Migration failed: %v", err)
 	}
 
-	err1, acc1 := db.ReadAccById(id1)
+	acc1, err1 := db.ReadAccById(id1)
 	if err1 != nil {
 		t.Fatalf("Failed to read id1: %v", err1)
 	}
@@ -139,7 +143,7 @@ func TestAddUsernameUniqueConstraint_WithDuplicates(t *testing.T) {
 		t.Errorf("Expected 'alice', got '%s'", acc1.Username)
 	}
 
-	err2, acc2 := db.ReadAccById(id2)
+	acc2, err2 := db.ReadAccById(id2)
 	if err2 != nil {
 		t.Fatalf("Failed to read id2: %v", err2)
 	}
@@ -147,7 +151,7 @@ func TestAddUsernameUniqueConstraint_WithDuplicates(t *testing.T) {
 		t.Errorf("Expected 'alice_2', got '%s'", acc2.Username)
 	}
 
-	err3, acc3 := db.ReadAccById(id3)
+	acc3, err3 := db.ReadAccById(id3)
 	if err3 != nil {
 		t.Fatalf("Failed to read id3: %v", err3)
 	}
@@ -203,7 +207,7 @@ func TestAddUsernameUniqueConstraint_CaseInsensitive(t *testing.T) {
 		t.Fatalf("Migration failed: %v", err)
 	}
 
-	err1, acc1 := db.ReadAccById(id1)
+	acc1, err1 := db.ReadAccById(id1)
 	if err1 != nil {
 		t.Fatalf("Failed to read id1: %v", err1)
 	}
@@ -211,7 +215,7 @@ func TestAddUsernameUniqueConstraint_CaseInsensitive(t *testing.T) {
 		t.Errorf("Expected 'Alice', got '%s'", acc1.Username)
 	}
 
-	err2, acc2 := db.ReadAccById(id2)
+	acc2, err2 := db.ReadAccById(id2)
 	if err2 != nil {
 		t.Fatalf("Failed to read id2: %v", err2)
 	}
@@ -219,7 +223,7 @@ func TestAddUsernameUniqueConstraint_CaseInsensitive(t *testing.T) {
 		t.Errorf("Expected 'ALICE_2', got '%s'", acc2.Username)
 	}
 
-	err3, acc3 := db.ReadAccById(id3)
+	acc3, err3 := db.ReadAccById(id3)
 	if err3 != nil {
 		t.Fatalf("Failed to read id3: %v", err3)
 	}
@@ -278,29 +282,29 @@ func TestAddUsernameUniqueConstraint_MultipleDuplicateSets(t *testing.T) {
 		t.Fatalf("Migration failed: %v", err)
 	}
 
-	err1, a1 := db.ReadAccById(alice1)
+	a1, err1 := db.ReadAccById(alice1)
 	if err1 != nil || a1.Username != "alice" {
 		t.Errorf("alice1: expected 'alice', got '%s'", a1.Username)
 	}
-	err2, a2 := db.ReadAccById(alice2)
+	a2, err2 := db.ReadAccById(alice2)
 	if err2 != nil || a2.Username != "alice_2" {
 		t.Errorf("alice2: expected 'alice_2', got '%s'", a2.Username)
 	}
-	err3, a3 := db.ReadAccById(alice3)
+	a3, err3 := db.ReadAccById(alice3)
 	if err3 != nil || a3.Username != "alice_3" {
 		t.Errorf("alice3: expected 'alice_3', got '%s'", a3.Username)
 	}
 
-	err4, b1 := db.ReadAccById(bob1)
+	b1, err4 := db.ReadAccById(bob1)
 	if err4 != nil || b1.Username != "bob" {
 		t.Errorf("bob1: expected 'bob', got '%s'", b1.Username)
 	}
-	err5, b2 := db.ReadAccById(bob2)
+	b2, err5 := db.ReadAccById(bob2)
 	if err5 != nil || b2.Username != "bob_2" {
 		t.Errorf("bob2: expected 'bob_2', got '%s'", b2.Username)
 	}
 
-	err6, c1 := db.ReadAccById(charlie1)
+	c1, err6 := db.ReadAccById(charlie1)
 	if err6 != nil || c1.Username != "charlie" {
 		t.Errorf("charlie: expected 'charlie', got '%s'", c1.Username)
 	}
@@ -0,0 +1,64 @@
+package db
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+
+	"github.com/deemkeen/stegodon/util"
+)
+
+// synchronousModes names the values PRAGMA synchronous reports
+var synchronousModes = []string{"off", "normal", "full", "extra"}
+
+// pragmaDSN returns the data source name that opens path with the configured pragmas.
+// They are passed in the DSN so the driver applies them to every pooled connection,
+// not only to the one a PRAGMA statement happens to run on.
+func pragmaDSN(path string, conf *util.AppConfig) string {
+	params := url.Values{}
+	params.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", conf.DbBusyTimeout()))
+	// A negative cache_size is in KiB rather than pages
+	params.Add("_pragma", fmt.Sprintf("cache_size(%d)", -conf.DbCacheSizeKB()))
+	params.Add("_pragma", fmt.Sprintf("synchronous(%s)", strings.ToUpper(conf.DbSynchronous())))
+	return path + "?" + params.Encode()
+}
+
+// logPragmas logs the pragmas a connection actually ended up with
+func (db *DB) logPragmas(conf *util.AppConfig) {
+	var journalMode string
+	var synchronous, cacheSize, busyTimeout int
+	for _, pragma := range []struct {
+		name string
+		dst  any
+	}{
+		{"journal_mode", &journalMode},
+		{"synchronous", &synchronous},
+		{"cache_size", &cacheSize},
+		{"busy_timeout", &busyTimeout},
+	} {
+		if err := db.db.QueryRow("PRAGMA " + pragma.name).Scan(pragma.dst); err != nil {
+			log.Printf("Warning: Failed to read PRAGMA %s: %v", pragma.name, err)
+		}
+	}
+
+	synchronousMode := fmt.Sprint(synchronous)
+	if synchronous >= 0 && synchronous < len(synchronousModes) {
+		synchronousMode = synchronousModes[synchronous]
+	}
+	checkpoint := "off"
+	if interval := conf.DbCheckpointInterval(); interval > 0 {
+		checkpoint = interval.String()
+	}
+	log.Printf("Database pragmas: journal_mode=%s synchronous=%s cache_size=%d busy_timeout=%dms, WAL checkpoint every %s",
+		journalMode, synchronousMode, cacheSize, busyTimeout, checkpoint)
+}
+
+// CheckpointWAL copies the committed WAL frames back into the database file without waiting
+// for readers or writers, so the WAL doesn't keep growing under a steady write load. It
+// returns the frames in the WAL and how many of them were checkpointed.
+func (db *DB) CheckpointWAL() (walFrames, checkpointed int, err error) {
+	var busy int
+	err = db.db.QueryRow("PRAGMA wal_checkpoint(PASSIVE)").Scan(&busy, &walFrames, &checkpointed)
+	return walFrames, checkpointed, err
+}
@@ -0,0 +1,59 @@
+package db
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/deemkeen/stegodon/util"
+)
+
+func TestPragmaDSN_AppliesToEveryConnection(t *testing.T) {
+	conf := &util.AppConfig{}
+	conf.Conf.DbCacheSizeKB = 2000
+	conf.Conf.DbBusyTimeout = 1234
+	conf.Conf.DbSynchronous = "full"
+
+	sqlDB, err := sql.Open("sqlite", pragmaDSN(filepath.Join(t.TempDir(), "test.db"), conf))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer sqlDB.Close()
+
+	// Both connections are held at once, so they are distinct
+	for range 2 {
+		conn, err := sqlDB.Conn(t.Context())
+		if err != nil {
+			t.Fatalf("Failed to get a connection: %v", err)
+		}
+		defer conn.Close()
+
+		var cacheSize, busyTimeout, synchronous int
+		conn.QueryRowContext(t.Context(), "PRAGMA cache_size").Scan(&cacheSize)
+		conn.QueryRowContext(t.Context(), "PRAGMA busy_timeout").Scan(&busyTimeout)
+		conn.QueryRowContext(t.Context(), "PRAGMA synchronous").Scan(&synchronous)
+		if cacheSize != -2000 || busyTimeout != 1234 || synchronousModes[synchronous] != "full" {
+			t.Errorf("Expected the configured pragmas, got cache_size=%d busy_timeout=%d synchronous=%d", cacheSize, busyTimeout, synchronous)
+		}
+	}
+}
+
+func TestCheckpointWAL(t *testing.T) {
+	sqlDB, err := sql.Open("sqlite", pragmaDSN(filepath.Join(t.TempDir(), "test.db"), nil))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer sqlDB.Close()
+	sqlDB.Exec("PRAGMA journal_mode=WAL")
+	sqlDB.Exec("CREATE TABLE t(v INTEGER)")
+	sqlDB.Exec("INSERT INTO t VALUES (1)")
+
+	db := &DB{db: sqlDB}
+	walFrames, checkpointed, err := db.CheckpointWAL()
+	if err != nil {
+		t.Fatalf("CheckpointWAL failed: %v", err)
+	}
+	if walFrames == 0 || checkpointed != walFrames {
+		t.Errorf("Expected all WAL frames to be checkpointed, got %d of %d", checkpointed, walFrames)
+	}
+}
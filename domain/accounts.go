@@ -3,6 +3,7 @@ package domain
 import (
 	"fmt"
 	"github.com/google/uuid"
+	"slices"
 	"time"
 )
 
@@ -28,8 +29,79 @@ type Account struct {
 	// Admin fields
 	IsAdmin bool
 	Muted   bool
+	// ManuallyApprovesFollowers keeps incoming follows pending until the user approves them
+	ManuallyApprovesFollowers bool
+	// Pending accounts were created under the "approval" registration policy and can't be used
+	// until an admin approves them
+	Pending bool
+	// Disabled accounts can't log in or federate, but keep all their content until re-enabled
+	Disabled bool
+	// Discoverable accounts have their posts listed in the public timeline and are advertised
+	// as discoverable to remote directories
+	Discoverable bool
+}
+
+// LanguageSettings are an account's language preferences
+type LanguageSettings struct {
+	DefaultLanguage string   // Language new notes are tagged with ("" leaves them untagged)
+	Languages       []string // Languages shown in timelines (empty shows all)
 }
 
 func (acc *Account) ToString() string {
 	return fmt.Sprintf("\n\tId: %s \n\tUsername: %s \n\tPublickey: %s \n\tCREATED_AT: %s)", acc.Id, acc.Username, acc.Publickey, acc.CreatedAt)
 }
+
+// API token scopes
+const (
+	ScopeRead   = "read"   // Read timelines and account data
+	ScopeWrite  = "write"  // Post, edit and delete notes
+	ScopeFollow = "follow" // Follow and unfollow accounts
+)
+
+// APIToken is a bearer token for the HTTP API. Only the token's hash is stored.
+type APIToken struct {
+	Id        uuid.UUID
+	AccountId uuid.UUID
+	TokenHash string
+	Scopes    []string
+	CreatedAt time.Time
+}
+
+// AccountKey is an SSH public key an account can log in with. The key an account was
+// created with is its primary key and is kept in the account itself; further keys, e.g. of
+// other devices, are added by the user.
+type AccountKey struct {
+	Id        uuid.UUID // uuid.Nil for the primary key
+	AccountId uuid.UUID
+	PkHash    string
+	Label     string
+	AddedAt   time.Time
+	Primary   bool
+}
+
+// HasScope reports whether the token grants the given scope
+func (token *APIToken) HasScope(scope string) bool {
+	return slices.Contains(token.Scopes, scope)
+}
+
+// AccountSearchResult is a local account or a cached remote account matching a search
+type AccountSearchResult struct {
+	Id          uuid.UUID
+	Username    string
+	Domain      string // "" for local accounts
+	DisplayName string
+	ActorURI    string // "" for local accounts
+}
+
+// IsLocal reports whether the result is an account on this server
+func (r *AccountSearchResult) IsLocal() bool {
+	return r.Domain == ""
+}
+
+// Handle returns "@user" for local accounts and "@user@domain" for remote ones
+func (r *AccountSearchResult) Handle() string {
+	if r.IsLocal() {
+		return "@" + r.Username
+	}
+	return "@" + r.Username + "@" + r.Domain
+}
@@ -1,23 +1,37 @@
 package domain
 
 import (
-	"github.com/google/uuid"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/google/uuid"
 )
 
 // RemoteAccount represents a cached federated user
 type RemoteAccount struct {
-	Id            uuid.UUID
-	Username      string
-	Domain        string
-	ActorURI      string
-	DisplayName   string
-	Summary       string
-	InboxURI      string
-	OutboxURI     string
-	PublicKeyPem  string
-	AvatarURL     string
-	LastFetchedAt time.Time
+	Id             uuid.UUID
+	Username       string
+	Domain         string
+	ActorURI       string
+	DisplayName    string
+	Summary        string
+	InboxURI       string
+	SharedInboxURI string // endpoints.sharedInbox, empty if the server has none
+	OutboxURI      string
+	PublicKeyPem   string
+	PublicKeys     map[string]string // All published keys by key id, for actors with several keys
+	AvatarURL      string
+	LastFetchedAt  time.Time
+}
+
+// PublicKeyFor returns the key a signature with the given keyId was made with. Actors that
+// publish several keys are matched by key id; otherwise PublicKeyPem is the actor's key.
+func (r *RemoteAccount) PublicKeyFor(keyID string) string {
+	if key, ok := r.PublicKeys[keyID]; ok {
+		return key
+	}
+	return r.PublicKeyPem
 }
 
 // Follow represents a follow relationship
@@ -29,6 +43,36 @@ type Follow struct {
 	CreatedAt       time.Time
 	Accepted        bool
 	IsLocal         bool // true if this is a local-only follow
+	Notify          bool // notify the follower of every top-level post by the target
+}
+
+// MutedAccount hides a remote account's posts from an account's home timeline without
+// unfollowing it, and optionally its notifications too
+type MutedAccount struct {
+	Id                uuid.UUID
+	AccountId         uuid.UUID // The local account that muted
+	TargetActorURI    string
+	HideNotifications bool
+	ExpiresAt         *time.Time // nil for a mute that lasts until it is removed
+	CreatedAt         time.Time
+}
+
+// IsActive reports whether the mute applies at the given time
+func (m *MutedAccount) IsActive(now time.Time) bool {
+	return m.ExpiresAt == nil || m.ExpiresAt.After(now)
+}
+
+// FollowRequest is a follow of a local account by a remote actor that awaits approval
+type FollowRequest struct {
+	Follow
+	Username string // Remote follower's username
+	Domain   string // Remote follower's server
+	ActorURI string
+}
+
+// Handle returns the follower as @user@domain
+func (r *FollowRequest) Handle() string {
+	return "@" + r.Username + "@" + r.Domain
 }
 
 // Like represents a like/favorite on a note
@@ -49,6 +93,32 @@ type Boost struct {
 	CreatedAt time.Time
 }
 
+// Reaction is an emoji reaction on a post, received as an EmojiReact or as a Like with
+// content (Misskey). Plain Likes without content are stored as Like.
+type Reaction struct {
+	Id        uuid.UUID
+	ObjectURI string // The post reacted to
+	Emoji     string // A Unicode emoji or a custom emoji shortcode such as :blobcat:
+	ActorURI  string // Who reacted
+	URI       string // ActivityPub activity URI
+	CreatedAt time.Time
+}
+
+// ReactionCount is the number of reactions with one emoji on a post
+type ReactionCount struct {
+	Emoji string
+	Count int
+}
+
+// FormatReactions returns reaction counts as "👍 3 🎉 1", or "" if there are none
+func FormatReactions(reactions []ReactionCount) string {
+	parts := make([]string, 0, len(reactions))
+	for _, reaction := range reactions {
+		parts = append(parts, reaction.Emoji+" "+strconv.Itoa(reaction.Count))
+	}
+	return strings.Join(parts, " ")
+}
+
 // Activity represents an ActivityPub activity (for logging/deduplication)
 type Activity struct {
 	Id           uuid.UUID
@@ -59,10 +129,12 @@ type Activity struct {
 	RawJSON      string
 	Processed    bool
 	CreatedAt    time.Time
-	Local        bool // true if originated from this server
-	FromRelay    bool // true if forwarded by a relay
-	LikeCount    int  // Denormalized like count
-	BoostCount   int  // Denormalized boost count
+	Local        bool   // true if originated from this server
+	FromRelay    bool   // true if forwarded by a relay
+	LikeCount    int    // Denormalized like count
+	BoostCount   int    // Denormalized boost count
+	Language     string // ISO 639 language code from the object's contentMap ("" if unknown)
+	Restricted   bool   // Followers-only or direct post fetched without a local follower of its author; kept out of timelines
 }
 
 // DeliveryQueueItem represents an item in the delivery queue
@@ -73,6 +145,21 @@ type DeliveryQueueItem struct {
 	Attempts     int
 	NextRetryAt  time.Time
 	CreatedAt    time.Time
+	LastStatus   int    // HTTP status of the last failed attempt (0 = no response)
+	DeadLettered bool   // Kept for inspection but no longer retried
+	ObjectURI    string // Object the activity is about; deliveries about it go to an inbox in Seq order
+	Seq          int64  // Position in the queue, assigned when the delivery is queued
+}
+
+// InboxQueueItem is a received activity with a verified signature, waiting to be handled
+type InboxQueueItem struct {
+	Id            uuid.UUID
+	Username      string // Local account whose inbox received the activity
+	SignerURI     string // Actor that signed the request, e.g. a relay forwarding the activity
+	ActivityJSON  string
+	Attempts      int
+	NextAttemptAt time.Time
+	CreatedAt     time.Time
 }
 
 // NoteMention represents a @user@domain mention in a note
@@ -85,6 +172,19 @@ type NoteMention struct {
 	CreatedAt         time.Time
 }
 
+// Relay subscription flows, chosen by the software the relay runs
+const (
+	RelayTypeMastodon = "mastodon" // Follow of the Public collection (pub-relay, Activity-Relay, FediBuzz)
+	RelayTypeLitePub  = "litepub"  // Follow of the relay actor itself (Pleroma/Akkoma relays)
+)
+
+// What the pause schedule of a relay did in its current window
+const (
+	RelayScheduleIdle    = ""        // Outside the window, or no schedule
+	RelaySchedulePaused  = "paused"  // The schedule paused the relay and resumes it when the window ends
+	RelayScheduleSkipped = "skipped" // Left alone until the window ends: already paused, or resumed by hand
+)
+
 // Relay represents an ActivityPub relay subscription
 type Relay struct {
 	Id         uuid.UUID
@@ -93,7 +193,83 @@ type Relay struct {
 	FollowURI  string // The URI of our Follow activity (needed for Undo)
 	Name       string // Display name from relay actor profile
 	Status     string // pending, active, failed
+	Type       string // RelayTypeMastodon or RelayTypeLitePub
 	Paused     bool   // If true, incoming notes are logged but not saved
 	CreatedAt  time.Time
 	AcceptedAt *time.Time // When the relay accepted our Follow request
+
+	AccountId      uuid.UUID // Local account that sent the Follow (signs re-sends)
+	FollowAttempts int       // Follows sent so far while pending
+	LastFollowAt   time.Time // When the most recent Follow was sent
+
+	LastActivityAt *time.Time // When the relay last forwarded an Announce or Create
+	ActivityCount  int        // Relay-forwarded activities processed since subscribing
+
+	Filter RelayFilter // Rules forwarded posts must pass before they are stored
+
+	PauseSchedule      string // Daily quiet hours the relay is paused in, e.g. "22:00-06:00 Europe/Berlin" (empty = none)
+	PauseScheduleState string // RelayScheduleIdle, RelaySchedulePaused or RelayScheduleSkipped
+}
+
+// RelayFilter holds the rules a relay's forwarded posts must pass before they are stored.
+// Direct deliveries from followed accounts are never filtered.
+type RelayFilter struct {
+	BlockedDomains []string // Author servers (and their subdomains) whose posts are dropped
+	MutedWords     []string // Posts whose text contains any of these (case-insensitive) are dropped
+	RequiredTags   []string // If set, posts need at least one of these hashtags
+}
+
+// IsEmpty reports whether the filter lets every post through
+func (f *RelayFilter) IsEmpty() bool {
+	return len(f.BlockedDomains) == 0 && len(f.MutedWords) == 0 && len(f.RequiredTags) == 0
+}
+
+// Normalized returns the filter with lowercased, trimmed and deduplicated entries.
+// Domains lose a leading "*." and tags a leading "#".
+func (f RelayFilter) Normalized() RelayFilter {
+	return RelayFilter{
+		BlockedDomains: normalizeFilterList(f.BlockedDomains, "*."),
+		MutedWords:     normalizeFilterList(f.MutedWords, ""),
+		RequiredTags:   normalizeFilterList(f.RequiredTags, "#"),
+	}
+}
+
+func normalizeFilterList(entries []string, prefix string) []string {
+	var normalized []string
+	seen := make(map[string]bool)
+	for _, e := range entries {
+		e = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(e)), prefix)
+		if e == "" || seen[e] {
+			continue
+		}
+		seen[e] = true
+		normalized = append(normalized, e)
+	}
+	return normalized
+}
+
+// RelayHealth summarises how much content a relay has been delivering
+type RelayHealth struct {
+	RelayId        uuid.UUID
+	ActorURI       string
+	Name           string
+	Status         string
+	Paused         bool
+	Since          time.Time  // Start of the measured period: accepted_at, or created_at if never accepted
+	LastActivityAt *time.Time // nil if the relay has never delivered anything
+	ActivityCount  int
+	PerHour        float64 // Rough inbound rate: ActivityCount averaged over the hours since Since
+}
+
+// IsStale reports whether an active, unpaused relay has delivered nothing within window.
+// A relay that has never delivered anything is measured from when it was accepted.
+func (h *RelayHealth) IsStale(window time.Duration, now time.Time) bool {
+	if h.Status != "active" || h.Paused {
+		return false
+	}
+	last := h.Since
+	if h.LastActivityAt != nil && h.LastActivityAt.After(last) {
+		last = *h.LastActivityAt
+	}
+	return now.Sub(last) > window
 }
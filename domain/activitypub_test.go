@@ -215,3 +215,13 @@ func TestDeliveryQueueWithZeroAttempts(t *testing.T) {
 		t.Errorf("Expected Attempts 0, got %d", item.Attempts)
 	}
 }
+
+func TestFormatReactions(t *testing.T) {
+	if got := FormatReactions(nil); got != "" {
+		t.Errorf("Expected no reactions to format as \"\", got %q", got)
+	}
+	reactions := []ReactionCount{{Emoji: "👍", Count: 3}, {Emoji: ":blobcat:", Count: 1}}
+	if got := FormatReactions(reactions); got != "👍 3 :blobcat: 1" {
+		t.Errorf("Expected \"👍 3 :blobcat: 1\", got %q", got)
+	}
+}
@@ -3,13 +3,24 @@ package domain
 import (
 	"fmt"
 	"github.com/google/uuid"
+	"strings"
 	"time"
 )
 
+// Note visibility values stored in notes.visibility
+const (
+	VisibilityPublic    = "public"
+	VisibilityUnlisted  = "unlisted"
+	VisibilityFollowers = "followers"
+	VisibilityDirect    = "direct"
+	VisibilityLocal     = "local" // Never federated, only visible on this instance
+)
+
 type SaveNote struct {
 	UserId       uuid.UUID
 	Message      string
 	InReplyToURI string // URI of parent post (empty for top-level posts)
+	Visibility   string // One of the Visibility* values (empty means public)
 }
 
 type Note struct {
@@ -19,26 +30,51 @@ type Note struct {
 	CreatedAt time.Time
 	EditedAt  *time.Time // When the note was last edited (nil if never edited)
 	// ActivityPub fields
-	Visibility     string // "public", "unlisted", "followers", "direct"
+	Visibility     string // "public", "unlisted", "followers", "direct", "local"
 	InReplyToURI   string // URI of the note this is replying to
 	ObjectURI      string // ActivityPub object URI
 	Federated      bool   // Whether to federate this note
 	Sensitive      bool   // Contains sensitive content
 	ContentWarning string // Content warning text
+	Language       string // ISO 639 language code ("" if unknown)
 	// Engagement counters
 	ReplyCount int // Number of replies
 	LikeCount  int // Number of likes
 	BoostCount int // Number of boosts
+	// DeletedAt is set for deleted notes kept as tombstones so their replies stay attached
+	DeletedAt *time.Time
+}
+
+// DeletedNotePlaceholder is shown in place of the message of a deleted note
+const DeletedNotePlaceholder = "[deleted]"
+
+// IsLocalOnly returns true if the note must never leave this instance
+func (note *Note) IsLocalOnly() bool {
+	return note.Visibility == VisibilityLocal
+}
+
+// IsDeleted returns true if the note was deleted and only its tombstone is left
+func (note *Note) IsDeleted() bool {
+	return note.DeletedAt != nil
 }
 
 func (note *Note) ToString() string {
 	return fmt.Sprintf("\n\tId: %s \n\tCreatedBy: %s \n\tMessage: %s \n\tCreatedAt: %s)", note.Id, note.CreatedBy, note.Message, note.CreatedAt)
 }
 
+// HashtagTrend is a hashtag's recent usage, as ranked by ReadTrendingHashtags
+type HashtagTrend struct {
+	Name       string
+	Uses       int       // notes using the tag within the window
+	Accounts   int       // distinct authors of those notes
+	LastUsedAt time.Time // newest note using the tag
+}
+
 // HomePost represents a unified post in the home timeline (either local or remote)
 type HomePost struct {
 	ID         uuid.UUID
 	Author     string // @user (local) or @user@domain (remote)
+	Title      string // title of remote long-form posts (Article, Page), "" for notes
 	Content    string
 	Time       time.Time
 	ObjectURI  string
@@ -47,4 +83,82 @@ type HomePost struct {
 	ReplyCount int       // number of replies to this post
 	LikeCount  int       // number of likes on this post
 	BoostCount int       // number of boosts on this post
+	LikedByMe  bool      // the viewing account liked this post
+	// Quoted post (remote quote posts only)
+	QuoteURI     string // object URI of the quoted post ("" if the post quotes nothing)
+	QuoteAuthor  string // @user@domain of the quoted post's author ("" if unresolved)
+	QuoteContent string // plain text of the quoted post ("" if unresolved)
+	// Followed accounts that boosted the post, newest first. Several boosts of the same post
+	// are collapsed into one entry, ordered by the most recent boost.
+	BoostedBy []string
+	// Emoji reactions on the post, most used first (local posts only)
+	Reactions []ReactionCount
+	// Post replied to ("" for top-level posts)
+	InReplyToURI string
+	// Later posts of a self-thread, the author's replies to their own posts, oldest first.
+	// They are collapsed into the entry of the thread's first post.
+	SelfThread []HomePost
+}
+
+// DisplayContent is the content shown in timelines. Posts with a title show as
+// "Title — excerpt", the excerpt being the content on a single line.
+func (p *HomePost) DisplayContent() string {
+	if p.Title == "" {
+		return p.Content
+	}
+	excerpt := strings.Join(strings.Fields(p.Content), " ")
+	if excerpt == "" {
+		return p.Title
+	}
+	return p.Title + " — " + excerpt
+}
+
+// BoostedByLabel returns "boosted by @alice, @bob, +1", or "" if no followed account boosted the post
+func (p *HomePost) BoostedByLabel() string {
+	if len(p.BoostedBy) == 0 {
+		return ""
+	}
+	const shown = 2
+	if len(p.BoostedBy) <= shown {
+		return "boosted by " + strings.Join(p.BoostedBy, ", ")
+	}
+	return fmt.Sprintf("boosted by %s, +%d", strings.Join(p.BoostedBy[:shown], ", "), len(p.BoostedBy)-shown)
+}
+
+// ConversationNode is a single post in a conversation tree (either local note or remote activity)
+type ConversationNode struct {
+	ObjectURI    string
+	InReplyToURI string
+	CreatedAt    time.Time
+	IsLocal      bool      // true = local note, false = remote activity
+	Note         *Note     // only set for local posts
+	Activity     *Activity // only set for remote posts
+	Depth        int       // distance from the conversation root (root = 0)
+	Children     []*ConversationNode
+}
+
+// Conversation is a post together with its ancestors and all descendant replies
+type Conversation struct {
+	Root      *ConversationNode   // topmost post that could be resolved
+	Ancestors []*ConversationNode // chain from Root down to the parent of Focus (empty if Focus is the root)
+	Focus     *ConversationNode   // the post the conversation was requested for
+	// MissingParentURI is set when the ancestor chain ends at a post we don't have stored
+	MissingParentURI string
+	Truncated        bool // true if the node or depth cap cut the tree short
+}
+
+// Flatten returns every node of the conversation tree in display order (depth-first)
+func (c *Conversation) Flatten() []*ConversationNode {
+	var nodes []*ConversationNode
+	var walk func(n *ConversationNode)
+	walk = func(n *ConversationNode) {
+		nodes = append(nodes, n)
+		for _, child := range n.Children {
+			walk(child)
+		}
+	}
+	if c.Root != nil {
+		walk(c.Root)
+	}
+	return nodes
 }
@@ -113,3 +113,42 @@ func TestNoteWithNilEditedAt(t *testing.T) {
 		t.Error("Expected EditedAt to be nil")
 	}
 }
+
+func TestHomePostDisplayContent(t *testing.T) {
+	tests := []struct {
+		title    string
+		content  string
+		expected string
+	}{
+		{"", "Just a note", "Just a note"},
+		{"My essay", "First paragraph.\n\nSecond  paragraph.", "My essay — First paragraph. Second paragraph."},
+		{"Only a title", "", "Only a title"},
+	}
+
+	for _, tt := range tests {
+		post := HomePost{Title: tt.title, Content: tt.content}
+		if result := post.DisplayContent(); result != tt.expected {
+			t.Errorf("DisplayContent(%q, %q) = %q, want %q", tt.title, tt.content, result, tt.expected)
+		}
+	}
+}
+
+func TestHomePostBoostedByLabel(t *testing.T) {
+	tests := []struct {
+		boostedBy []string
+		expected  string
+	}{
+		{nil, ""},
+		{[]string{"@alice"}, "boosted by @alice"},
+		{[]string{"@alice", "@bob"}, "boosted by @alice, @bob"},
+		{[]string{"@alice", "@bob", "@carol"}, "boosted by @alice, @bob, +1"},
+		{[]string{"@alice", "@bob", "@carol", "@dave@remote.example"}, "boosted by @alice, @bob, +2"},
+	}
+
+	for _, tt := range tests {
+		post := HomePost{BoostedBy: tt.boostedBy}
+		if result := post.BoostedByLabel(); result != tt.expected {
+			t.Errorf("BoostedByLabel(%v) = %q, want %q", tt.boostedBy, result, tt.expected)
+		}
+	}
+}
@@ -11,17 +11,20 @@ import (
 type NotificationType string
 
 const (
-	NotificationFollow  NotificationType = "follow"
-	NotificationLike    NotificationType = "like"
-	NotificationReply   NotificationType = "reply"
-	NotificationMention NotificationType = "mention"
+	NotificationFollow        NotificationType = "follow"
+	NotificationFollowRequest NotificationType = "follow_request"
+	NotificationLike          NotificationType = "like"
+	NotificationReply         NotificationType = "reply"
+	NotificationMention       NotificationType = "mention"
+	NotificationBoost         NotificationType = "boost"
+	NotificationPost          NotificationType = "post" // new post by a follow with notifications on
 )
 
 // Notification represents a user notification
 type Notification struct {
 	Id               uuid.UUID
 	AccountId        uuid.UUID        // The local user receiving the notification
-	NotificationType NotificationType // follow, like, reply, mention
+	NotificationType NotificationType // follow, follow_request, like, reply, mention, boost, post
 	ActorId          uuid.UUID        // The account that triggered the notification (local or remote)
 	ActorUsername    string           // Denormalized for display (e.g., "alice")
 	ActorDomain      string           // Denormalized for display (e.g., "mastodon.social", empty for local)
@@ -45,12 +48,18 @@ func (n *Notification) TypeLabel() string {
 	switch n.NotificationType {
 	case NotificationFollow:
 		return "followed you"
+	case NotificationFollowRequest:
+		return "requested to follow you"
 	case NotificationLike:
 		return "liked your post"
 	case NotificationReply:
 		return "replied to your post"
 	case NotificationMention:
 		return "mentioned you"
+	case NotificationBoost:
+		return "boosted your post"
+	case NotificationPost:
+		return "posted"
 	default:
 		return ""
 	}
@@ -61,12 +70,18 @@ func (n *Notification) TypeIcon() string {
 	switch n.NotificationType {
 	case NotificationFollow:
 		return "👤"
+	case NotificationFollowRequest:
+		return "🔒"
 	case NotificationLike:
 		return "❤️"
 	case NotificationReply:
 		return "💬"
 	case NotificationMention:
 		return "@"
+	case NotificationBoost:
+		return "🔁"
+	case NotificationPost:
+		return "📝"
 	default:
 		return "•"
 	}
@@ -76,3 +91,45 @@ func (n *Notification) TypeIcon() string {
 func (n *Notification) Summary() string {
 	return fmt.Sprintf("%s %s %s", n.TypeIcon(), n.ActorHandle(), n.TypeLabel())
 }
+
+// NotificationGroup collapses repeated like/boost notifications for the same note
+// into a single entry, e.g. "@alice and 4 others liked your post"
+type NotificationGroup struct {
+	Latest        Notification   // Most recent notification in the group
+	Count         int            // Total number of notifications in the group
+	Actors        []string       // Handles of the most recent actors (newest first)
+	Notifications []Notification // Raw notifications for the detail view (newest first)
+}
+
+// Unread returns true if any notification in the group is unread
+func (g *NotificationGroup) Unread() bool {
+	for _, n := range g.Notifications {
+		if !n.Read {
+			return true
+		}
+	}
+	return false
+}
+
+// ActorsLabel returns "@alice", "@alice and @bob" or "@alice and 4 others"
+func (g *NotificationGroup) ActorsLabel() string {
+	if len(g.Actors) == 0 {
+		return g.Latest.ActorHandle()
+	}
+	switch g.Count {
+	case 1:
+		return g.Actors[0]
+	case 2:
+		if len(g.Actors) >= 2 {
+			return g.Actors[0] + " and " + g.Actors[1]
+		}
+		return g.Actors[0] + " and 1 other"
+	default:
+		return fmt.Sprintf("%s and %d others", g.Actors[0], g.Count-1)
+	}
+}
+
+// Summary returns a one-line summary of the grouped notification
+func (g *NotificationGroup) Summary() string {
+	return fmt.Sprintf("%s %s %s", g.Latest.TypeIcon(), g.ActorsLabel(), g.Latest.TypeLabel())
+}
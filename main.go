@@ -9,12 +9,16 @@ import (
 	"os"
 
 	"github.com/deemkeen/stegodon/app"
+	"github.com/deemkeen/stegodon/db"
 	"github.com/deemkeen/stegodon/util"
 )
 
 func main() {
 	// Parse command line flags
 	versionFlag := flag.Bool("v", false, "Print version information")
+	checkConfigFlag := flag.Bool("check-config", false, "Validate the configuration and exit")
+	recalculateRepliesFlag := flag.Bool("recalculate-reply-counts", false, "Recalculate thread roots and reply counts, then exit")
+	recountFlag := flag.Bool("recount", false, "Recalculate like, boost and reply counts from the stored rows, then exit")
 	flag.Parse()
 
 	// Handle version flag
@@ -29,8 +33,50 @@ func main() {
 		log.Fatalln(err)
 	}
 
-	// Setup logging (journald if enabled, otherwise standard logging)
-	util.SetupLogging(conf.Conf.WithJournald)
+	// Fail fast on a bad configuration instead of running into it later
+	conf.Normalize()
+	if err := conf.Validate(); err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
+	if *checkConfigFlag {
+		fmt.Println("Configuration OK")
+		os.Exit(0)
+	}
+
+	// Setup logging (journald if enabled, otherwise standard logging; JSON lines if configured)
+	util.SetupLogging(conf.Conf.WithJournald, conf.Conf.LogFormat)
+
+	// The database is opened with the configured tuning on first use
+	db.Configure(conf)
+
+	// Repair drifted reply counts without starting the servers
+	if *recalculateRepliesFlag {
+		database := db.GetDB()
+		database.SetConfig(conf)
+		if err := database.RunActivityPubMigrations(); err != nil {
+			log.Printf("Warning: Migration errors (may be normal if tables exist): %v", err)
+		}
+		if err := database.RecalculateReplyCounts(); err != nil {
+			log.Fatalf("Failed to recalculate reply counts: %v", err)
+		}
+		fmt.Println("Reply counts recalculated")
+		os.Exit(0)
+	}
+
+	if *recountFlag {
+		database := db.GetDB()
+		database.SetConfig(conf)
+		if err := database.RunActivityPubMigrations(); err != nil {
+			log.Printf("Warning: Migration errors (may be normal if tables exist): %v", err)
+		}
+		result, err := database.RecalculateCounts()
+		if err != nil {
+			log.Fatalf("Failed to recalculate counts: %v", err)
+		}
+		fmt.Printf("Counts recalculated: %d like, %d boost and %d reply counts corrected\n",
+			result.LikeCounts, result.BoostCounts, result.ReplyCounts)
+		os.Exit(0)
+	}
 
 	log.Printf("stegodon v%s", util.GetVersion())
 	log.Println("Configuration: ")
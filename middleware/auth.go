@@ -9,11 +9,14 @@ import (
 	"github.com/deemkeen/stegodon/util"
 )
 
+// pendingApprovalMessage is shown to accounts created under the approval registration policy
+const pendingApprovalMessage = "Your account is awaiting approval by an administrator. Please try again later.\n"
+
 func AuthMiddleware(conf *util.AppConfig) wish.Middleware {
 	return func(h ssh.Handler) ssh.Handler {
 		return func(s ssh.Session) {
 			database := db.GetDB()
-			found, acc := database.ReadAccBySession(s)
+			acc, found := database.ReadAccBySession(s)
 
 			switch {
 			case found == nil:
@@ -24,10 +27,22 @@ func AuthMiddleware(conf *util.AppConfig) wish.Middleware {
 					s.Close()
 					return
 				}
+				if acc != nil && acc.Disabled {
+					log.Printf("Blocked login attempt from disabled user: %s", acc.Username)
+					s.Write([]byte("Your account has been disabled by an administrator.\n"))
+					s.Close()
+					return
+				}
+				if acc != nil && acc.Pending {
+					log.Printf("Blocked login attempt from pending user: %s", acc.Username)
+					s.Write([]byte(pendingApprovalMessage))
+					s.Close()
+					return
+				}
 				util.LogPublicKey(s)
 			default:
 				// User not found - check if registration is closed
-				if conf.Conf.Closed {
+				if conf.RegistrationPolicy() == util.RegistrationPolicyClosed {
 					log.Printf("Rejected new user registration - registration is closed")
 					s.Write([]byte("Registration is closed, but you can host your own stegodon!\n"))
 					s.Write([]byte("More on: https://github.com/deemkeen/stegodon\n"))
@@ -55,7 +70,7 @@ func AuthMiddleware(conf *util.AppConfig) wish.Middleware {
 
 				// Create new account
 				database := db.GetDB()
-				err, created := database.CreateAccount(s, util.RandomString(10))
+				created, err := database.CreateAccount(s, util.RandomString(10))
 				if err != nil {
 					log.Println("Could not create a user: ", err)
 				}
@@ -66,6 +81,14 @@ func AuthMiddleware(conf *util.AppConfig) wish.Middleware {
 					log.Println("The user is still empty!")
 				}
 
+				// Accounts awaiting approval can't be used yet
+				if acc, err := database.ReadAccBySession(s); err == nil && acc != nil && acc.Pending {
+					log.Printf("Created pending account %s, awaiting approval", acc.Username)
+					s.Write([]byte(pendingApprovalMessage))
+					s.Close()
+					return
+				}
+
 			}
 			h(s)
 		}
@@ -10,6 +10,7 @@ import (
 	bm "github.com/charmbracelet/wish/bubbletea"
 	"github.com/deemkeen/stegodon/db"
 	"github.com/deemkeen/stegodon/ui"
+	"github.com/deemkeen/stegodon/util"
 	"github.com/muesli/termenv"
 )
 
@@ -22,7 +23,7 @@ func MainTui() wish.Middleware {
 			return nil
 		}
 
-		err, acc := db.GetDB().ReadAccBySession(s)
+		acc, err := db.GetDB().ReadAccBySession(s)
 		if err != nil {
 			log.Println("Could not retrieve the user:", err)
 			return nil
@@ -31,7 +32,28 @@ func MainTui() wish.Middleware {
 		// Set the global color profile to ANSI256 for Docker compatibility
 		lipgloss.SetColorProfile(termenv.ANSI256)
 
-		m := ui.NewModel(*acc, pty.Window.Width, pty.Window.Height)
+		// Live updates for this session; the subscription ends with the SSH session
+		bus := util.GetEventBus()
+		events := bus.Subscribe(acc.Id)
+		go func() {
+			<-s.Context().Done()
+			bus.Unsubscribe(acc.Id, events)
+		}()
+
+		// Listed in the user's sessions until it ends; revoking it closes the connection
+		sessions := util.GetSessionRegistry()
+		sessionId := sessions.Register(acc.Id, s.RemoteAddr().String(), s.Context().ClientVersion(), func() error {
+			if conn, ok := s.Context().Value(ssh.ContextKeyConn).(interface{ Close() error }); ok {
+				return conn.Close()
+			}
+			return s.Close()
+		})
+		go func() {
+			<-s.Context().Done()
+			sessions.Unregister(sessionId)
+		}()
+
+		m := ui.NewLiveModel(*acc, pty.Window.Width, pty.Window.Height, events, sessionId)
 		return tea.NewProgram(m, tea.WithFPS(60), tea.WithInput(s), tea.WithOutput(s), tea.WithAltScreen())
 	}
 	return bm.MiddlewareWithProgramHandler(teaHandler, termenv.ANSI256)
@@ -10,6 +10,8 @@ import (
 	"github.com/deemkeen/stegodon/db"
 	"github.com/deemkeen/stegodon/domain"
 	"github.com/deemkeen/stegodon/ui/common"
+	"github.com/deemkeen/stegodon/util"
+	"github.com/deemkeen/stegodon/web"
 	"github.com/google/uuid"
 )
 
@@ -53,10 +55,27 @@ type kickUserMsg struct {
 	userId uuid.UUID
 }
 
+// approvalMsg reports the result of approving or denying a pending account
+type approvalMsg struct {
+	approved bool
+	err      error
+}
+
+// disableUserMsg reports the result of disabling or re-enabling an account
+type disableUserMsg struct {
+	disabled bool
+	err      error
+}
+
+type exportUserMsg struct {
+	path string
+	err  error
+}
+
 func loadUsers() tea.Cmd {
 	return func() tea.Msg {
 		database := db.GetDB()
-		err, users := database.ReadAllAccountsAdmin()
+		users, err := database.ReadAllAccountsAdmin()
 		if err != nil {
 			log.Printf("Failed to load users: %v", err)
 			return usersLoadedMsg{users: []domain.Account{}}
@@ -91,6 +110,60 @@ func kickUser(userId uuid.UUID) tea.Cmd {
 	}
 }
 
+// approveUser lets a pending account log in
+func approveUser(userId uuid.UUID) tea.Cmd {
+	return func() tea.Msg {
+		err := db.GetDB().ApproveAccount(userId)
+		if err != nil {
+			log.Printf("Failed to approve user: %v", err)
+		}
+		return approvalMsg{approved: true, err: err}
+	}
+}
+
+// denyUser deletes a pending account
+func denyUser(userId uuid.UUID) tea.Cmd {
+	return func() tea.Msg {
+		err := db.GetDB().DenyAccount(userId)
+		if err != nil {
+			log.Printf("Failed to deny user: %v", err)
+		}
+		return approvalMsg{approved: false, err: err}
+	}
+}
+
+// setUserDisabled disables or re-enables an account, keeping its content either way
+func setUserDisabled(userId uuid.UUID, disabled bool) tea.Cmd {
+	return func() tea.Msg {
+		database := db.GetDB()
+		var err error
+		if disabled {
+			err = database.DisableAccount(userId)
+		} else {
+			err = database.EnableAccount(userId)
+		}
+		if err != nil {
+			log.Printf("Failed to update disabled state of user: %v", err)
+		}
+		return disableUserMsg{disabled: disabled, err: err}
+	}
+}
+
+// exportUser writes the user's data archive to the exports directory
+func exportUser(userId uuid.UUID) tea.Cmd {
+	return func() tea.Msg {
+		conf, err := util.ReadConf()
+		if err != nil {
+			return exportUserMsg{err: fmt.Errorf("failed to read config: %w", err)}
+		}
+		path, err := web.ExportAccountDataToFile(userId, conf)
+		if err != nil {
+			log.Printf("Failed to export user: %v", err)
+		}
+		return exportUserMsg{path: path, err: err}
+	}
+}
+
 func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case usersLoadedMsg:
@@ -112,6 +185,44 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 		m.Error = ""
 		return m, loadUsers()
 
+	case approvalMsg:
+		if msg.err != nil {
+			m.Status = ""
+			m.Error = msg.err.Error()
+			return m, nil
+		}
+		if msg.approved {
+			m.Status = "User approved"
+		} else {
+			m.Status = "User denied and deleted"
+		}
+		m.Error = ""
+		return m, loadUsers()
+
+	case disableUserMsg:
+		if msg.err != nil {
+			m.Status = ""
+			m.Error = msg.err.Error()
+			return m, nil
+		}
+		if msg.disabled {
+			m.Status = "User disabled"
+		} else {
+			m.Status = "User enabled"
+		}
+		m.Error = ""
+		return m, loadUsers()
+
+	case exportUserMsg:
+		if msg.err != nil {
+			m.Status = ""
+			m.Error = msg.err.Error()
+			return m, nil
+		}
+		m.Status = "Exported to " + msg.path
+		m.Error = ""
+		return m, nil
+
 	case tea.KeyMsg:
 		m.Status = ""
 		m.Error = ""
@@ -167,6 +278,40 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 				}
 				return m, kickUser(selectedUser.Id)
 			}
+		case "a", "D":
+			// Approve or deny (capital D, as it deletes the account) a pending user
+			if len(m.Users) > 0 && m.Selected < len(m.Users) {
+				selectedUser := m.Users[m.Selected]
+				if !selectedUser.Pending {
+					m.Error = "User is not awaiting approval"
+					return m, nil
+				}
+				if msg.String() == "a" {
+					return m, approveUser(selectedUser.Id)
+				}
+				return m, denyUser(selectedUser.Id)
+			}
+		case "d":
+			// Disable or re-enable the selected user
+			if len(m.Users) > 0 && m.Selected < len(m.Users) {
+				selectedUser := m.Users[m.Selected]
+				// Can't disable admin or yourself
+				if selectedUser.IsAdmin {
+					m.Error = "Cannot disable admin user"
+					return m, nil
+				}
+				if selectedUser.Id == m.AdminId {
+					m.Error = "Cannot disable yourself"
+					return m, nil
+				}
+				return m, setUserDisabled(selectedUser.Id, !selectedUser.Disabled)
+			}
+		case "e":
+			// Export selected user's data archive
+			if len(m.Users) > 0 && m.Selected < len(m.Users) {
+				m.Status = "Exporting @" + m.Users[m.Selected].Username + "..."
+				return m, exportUser(m.Users[m.Selected].Id)
+			}
 		}
 	}
 
@@ -206,6 +351,12 @@ func (m Model) View() string {
 		if user.Muted {
 			badges = append(badges, "[MUTED]")
 		}
+		if user.Pending {
+			badges = append(badges, "[PENDING]")
+		}
+		if user.Disabled {
+			badges = append(badges, "[DISABLED]")
+		}
 
 		badge := ""
 		if len(badges) > 0 {
@@ -216,8 +367,8 @@ func (m Model) View() string {
 			// Selected item with arrow prefix
 			text := common.ListItemSelectedStyle.Render(username + badge)
 			s.WriteString(common.ListSelectedPrefix + text)
-		} else if user.Muted {
-			// Muted users shown in error/red color
+		} else if user.Muted || user.Disabled {
+			// Muted and disabled users shown in error/red color
 			text := username + common.ListBadgeMutedStyle.Render(badge)
 			s.WriteString(common.ListUnselectedPrefix + common.ListItemStyle.Render(text))
 		} else {
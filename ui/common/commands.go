@@ -3,6 +3,7 @@ package common
 import (
 	"time"
 
+	"github.com/deemkeen/stegodon/util"
 	"github.com/google/uuid"
 )
 
@@ -23,6 +24,7 @@ const (
 	DeleteAccountView   // Delete account with confirmation
 	ThreadView          // View thread with parent and replies
 	NotificationsView   // View notifications
+	SettingsView        // SSH sessions, API tokens, SSH keys and data export
 )
 
 // EditNoteMsg is sent when user wants to edit an existing note
@@ -43,6 +45,12 @@ type ActivateViewMsg struct{}
 // DeactivateViewMsg is sent when a view becomes inactive (hidden)
 type DeactivateViewMsg struct{}
 
+// LiveEventMsg is sent when the session's event bus subscription delivers an event
+type LiveEventMsg struct {
+	Type        util.EventType
+	UnreadCount int // the account's unread notifications, set for util.EventNotification
+}
+
 // ReplyToNoteMsg is sent when user presses 'r' to reply to a post
 type ReplyToNoteMsg struct {
 	NoteURI string // ActivityPub object URI of the note being replied to
@@ -58,6 +66,9 @@ type ViewThreadMsg struct {
 	Author    string    // Author name for display
 	Content   string    // Full content
 	CreatedAt time.Time // Timestamp
+	// LoadMoreURI is set when the conversation was only partly imported; importing from it
+	// loads more of the thread
+	LoadMoreURI string
 }
 
 // LikeNoteMsg is sent when user presses 'l' to like/unlike a post
@@ -66,3 +77,9 @@ type LikeNoteMsg struct {
 	NoteID  uuid.UUID // Local UUID (if local note)
 	IsLocal bool      // Whether this is a local note
 }
+
+// TimezoneChangedMsg is sent when the account's timezone is loaded or changed, so views
+// render timestamps in it
+type TimezoneChangedMsg struct {
+	Location *time.Location
+}
@@ -78,6 +78,10 @@ const (
 
 	// HoursPerDay is used for time formatting calculations
 	HoursPerDay = 24
+
+	// RelativeTimeDays is how many days timestamps are shown relative to now ("3d ago")
+	// before they are shown as a date
+	RelativeTimeDays = 7
 )
 
 // VerticalLayoutOffset returns the total vertical space taken by header, footer, and margins
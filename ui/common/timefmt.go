@@ -0,0 +1,34 @@
+package common
+
+import (
+	"fmt"
+	"time"
+)
+
+// FormatTime renders a timestamp relative to now ("3m ago") and older ones as a date in
+// the viewer's timezone. Timestamps are stored in UTC, which a nil location falls back to.
+func FormatTime(t time.Time, loc *time.Location) string {
+	duration := time.Since(t)
+
+	if duration < time.Minute {
+		return "just now"
+	} else if duration < time.Hour {
+		mins := int(duration.Minutes())
+		return fmt.Sprintf("%dm ago", mins)
+	} else if duration < HoursPerDay*time.Hour {
+		hours := int(duration.Hours())
+		return fmt.Sprintf("%dh ago", hours)
+	} else if duration < RelativeTimeDays*HoursPerDay*time.Hour {
+		days := int(duration.Hours() / HoursPerDay)
+		return fmt.Sprintf("%dd ago", days)
+	}
+
+	if loc == nil {
+		loc = time.UTC
+	}
+	t = t.In(loc)
+	if t.Year() == time.Now().In(loc).Year() {
+		return t.Format("Jan 2 15:04")
+	}
+	return t.Format("Jan 2 2006")
+}
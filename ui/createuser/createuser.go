@@ -58,7 +58,7 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 				}
 
 				// Check if username already exists
-				err, existingAcc := db.GetDB().ReadAccByUsername(username)
+				existingAcc, err := db.GetDB().ReadAccByUsername(username)
 				if err == nil && existingAcc != nil {
 					m.Error = fmt.Sprintf("Username '%s' is already taken", username)
 					return m, nil
@@ -3,27 +3,37 @@ package followers
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/deemkeen/stegodon/activitypub"
 	"github.com/deemkeen/stegodon/db"
 	"github.com/deemkeen/stegodon/domain"
 	"github.com/deemkeen/stegodon/ui/common"
+	"github.com/deemkeen/stegodon/util"
+	"github.com/deemkeen/stegodon/web"
 	"github.com/google/uuid"
 	"log"
 )
 
 type Model struct {
 	AccountId uuid.UUID
+	Requests  []domain.FollowRequest // Pending follow requests, listed before the followers
 	Followers []domain.Follow
+	Locked    bool // New followers have to be approved
+	Hidden    bool // Posts are left out of the public timeline (not discoverable)
 	Selected  int
 	Offset    int // Pagination offset
 	Width     int
 	Height    int
+	Status    string
+	Error     string
 }
 
 func InitialModel(accountId uuid.UUID, width, height int) Model {
 	return Model{
 		AccountId: accountId,
+		Requests:  []domain.FollowRequest{},
 		Followers: []domain.Follow{},
 		Selected:  0,
 		Offset:    0,
@@ -39,11 +49,56 @@ func (m Model) Init() tea.Cmd {
 func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case followersLoadedMsg:
+		m.Requests = msg.requests
 		m.Followers = msg.followers
+		m.Locked = msg.locked
+		m.Hidden = !msg.discoverable
 		m.Offset = 0
 		m.Selected = 0
 		return m, nil
 
+	case clearStatusMsg:
+		m.Status = ""
+		m.Error = ""
+		return m, nil
+
+	case followRequestHandledMsg:
+		if msg.err != nil {
+			m.Error = fmt.Sprintf("Failed to %s %s: %v", msg.action, msg.handle, msg.err)
+			return m, tea.Batch(loadFollowers(m.AccountId), clearStatusAfter(3*time.Second))
+		}
+		m.Status = fmt.Sprintf("Follow request from %s %sed", msg.handle, msg.action)
+		m.Error = ""
+		return m, tea.Batch(loadFollowers(m.AccountId), clearStatusAfter(2*time.Second))
+
+	case lockedToggledMsg:
+		if msg.err != nil {
+			m.Error = fmt.Sprintf("Failed to update follower approval: %v", msg.err)
+			return m, clearStatusAfter(3 * time.Second)
+		}
+		m.Locked = msg.locked
+		if m.Locked {
+			m.Status = "New followers now need your approval"
+		} else {
+			m.Status = "New followers are accepted automatically"
+		}
+		m.Error = ""
+		return m, clearStatusAfter(2 * time.Second)
+
+	case discoverableToggledMsg:
+		if msg.err != nil {
+			m.Error = fmt.Sprintf("Failed to update discoverability: %v", msg.err)
+			return m, clearStatusAfter(3 * time.Second)
+		}
+		m.Hidden = !msg.discoverable
+		if m.Hidden {
+			m.Status = "Your posts are no longer listed in the public timeline"
+		} else {
+			m.Status = "Your posts are listed in the public timeline"
+		}
+		m.Error = ""
+		return m, clearStatusAfter(2 * time.Second)
+
 	case tea.KeyMsg:
 		switch msg.String() {
 		case "up", "k":
@@ -55,56 +110,92 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 				}
 			}
 		case "down", "j":
-			if m.Selected < len(m.Followers)-1 {
+			if m.Selected < m.itemCount()-1 {
 				m.Selected++
 				// Scroll down if needed
 				if m.Selected >= m.Offset+common.DefaultItemsPerPage {
 					m.Offset = m.Selected - common.DefaultItemsPerPage + 1
 				}
 			}
+		case "a", "r":
+			// Approve or reject the selected follow request
+			if m.Selected < len(m.Requests) {
+				request := m.Requests[m.Selected]
+				m.Requests = append(m.Requests[:m.Selected:m.Selected], m.Requests[m.Selected+1:]...)
+				if m.Selected >= m.itemCount() && m.Selected > 0 {
+					m.Selected--
+				}
+				return m, handleFollowRequestCmd(m.AccountId, request, msg.String() == "a")
+			}
+		case "l":
+			return m, toggleLockedCmd(m.AccountId, !m.Locked)
+		case "d":
+			return m, toggleDiscoverableCmd(m.AccountId, m.Hidden)
 		}
 	}
 	return m, nil
 }
 
+// itemCount returns the number of listed entries: follow requests first, then followers
+func (m Model) itemCount() int {
+	return len(m.Requests) + len(m.Followers)
+}
+
 func (m Model) View() string {
 	var s strings.Builder
 
-	s.WriteString(common.CaptionStyle.Render(fmt.Sprintf("followers (%d)", len(m.Followers))))
+	caption := fmt.Sprintf("followers (%d)", len(m.Followers))
+	if len(m.Requests) > 0 {
+		caption += fmt.Sprintf(" • requests (%d)", len(m.Requests))
+	}
+	if m.Locked {
+		caption += " • approval required"
+	}
+	if m.Hidden {
+		caption += " • not discoverable"
+	}
+	s.WriteString(common.CaptionStyle.Render(caption))
 	s.WriteString("\n\n")
 
-	if len(m.Followers) == 0 {
+	if m.itemCount() == 0 {
 		s.WriteString(common.ListEmptyStyle.Render("No followers yet. Share your profile to get followers!"))
+		s.WriteString("\n\n")
+		m.writeStatus(&s)
 		return s.String()
 	}
 
 	start := m.Offset
-	end := min(start+common.DefaultItemsPerPage, len(m.Followers))
+	end := min(start+common.DefaultItemsPerPage, m.itemCount())
 
 	for i := start; i < end; i++ {
-		follow := m.Followers[i]
-		database := db.GetDB()
-
 		var username, badge string
 
-		if follow.IsLocal {
-			// Local follower - look up in accounts table
-			err, localAcc := database.ReadAccById(follow.AccountId)
-			if err != nil {
-				log.Printf("Failed to read local account: %v", err)
-				continue
-			}
-			username = "@" + localAcc.Username
-			badge = " [local]"
+		if i < len(m.Requests) {
+			username = m.Requests[i].Handle()
+			badge = " [request]"
 		} else {
-			// Remote follower - look up in remote_accounts table
-			err, remoteAcc := database.ReadRemoteAccountById(follow.AccountId)
-			if err != nil {
-				log.Printf("Failed to read remote account: %v", err)
-				continue
+			follow := m.Followers[i-len(m.Requests)]
+			database := db.GetDB()
+
+			if follow.IsLocal {
+				// Local follower - look up in accounts table
+				localAcc, err := database.ReadAccById(follow.AccountId)
+				if err != nil {
+					log.Printf("Failed to read local account: %v", err)
+					continue
+				}
+				username = "@" + localAcc.Username
+				badge = " [local]"
+			} else {
+				// Remote follower - look up in remote_accounts table
+				remoteAcc, err := database.ReadRemoteAccountById(follow.AccountId)
+				if err != nil {
+					log.Printf("Failed to read remote account: %v", err)
+					continue
+				}
+				username = fmt.Sprintf("@%s@%s", remoteAcc.Username, remoteAcc.Domain)
+				badge = ""
 			}
-			username = fmt.Sprintf("@%s@%s", remoteAcc.Username, remoteAcc.Domain)
-			badge = ""
 		}
 
 		if i == m.Selected {
@@ -120,18 +211,125 @@ func (m Model) View() string {
 	}
 
 	// Show pagination info if there are more items
-	if len(m.Followers) > common.DefaultItemsPerPage {
+	if m.itemCount() > common.DefaultItemsPerPage {
 		s.WriteString("\n")
-		paginationText := fmt.Sprintf("showing %d-%d of %d", start+1, end, len(m.Followers))
+		paginationText := fmt.Sprintf("showing %d-%d of %d", start+1, end, m.itemCount())
 		s.WriteString(common.ListBadgeStyle.Render(paginationText))
 	}
 
+	s.WriteString("\n")
+	m.writeStatus(&s)
+
 	return s.String()
 }
 
+// writeStatus renders the status and error messages
+func (m Model) writeStatus(s *strings.Builder) {
+	if m.Status != "" {
+		s.WriteString(common.ListStatusStyle.Render(m.Status))
+		s.WriteString("\n")
+	}
+
+	if m.Error != "" {
+		s.WriteString(common.ListErrorStyle.Render(m.Error))
+		s.WriteString("\n")
+	}
+}
+
 // followersLoadedMsg is sent when followers are loaded
 type followersLoadedMsg struct {
-	followers []domain.Follow
+	requests     []domain.FollowRequest
+	followers    []domain.Follow
+	locked       bool
+	discoverable bool
+}
+
+// clearStatusMsg is sent after a delay to clear status/error messages
+type clearStatusMsg struct{}
+
+// clearStatusAfter returns a command that sends clearStatusMsg after a duration
+func clearStatusAfter(d time.Duration) tea.Cmd {
+	return tea.Tick(d, func(t time.Time) tea.Msg {
+		return clearStatusMsg{}
+	})
+}
+
+// followRequestHandledMsg is sent when a follow request was approved or rejected
+type followRequestHandledMsg struct {
+	handle string
+	action string // "approve" or "reject"
+	err    error
+}
+
+// lockedToggledMsg is sent when manual follower approval was turned on or off
+type lockedToggledMsg struct {
+	locked bool
+	err    error
+}
+
+// discoverableToggledMsg is sent when the account was made discoverable or hidden from the
+// public timeline
+type discoverableToggledMsg struct {
+	discoverable bool
+	err          error
+}
+
+// handleFollowRequestCmd approves or rejects a follow request, sending the Accept or Reject to the follower
+func handleFollowRequestCmd(accountId uuid.UUID, request domain.FollowRequest, approve bool) tea.Cmd {
+	return func() tea.Msg {
+		action := "reject"
+		if approve {
+			action = "approve"
+		}
+
+		account, err := db.GetDB().ReadAccById(accountId)
+		if err != nil {
+			return followRequestHandledMsg{handle: request.Handle(), action: action, err: err}
+		}
+		conf, err := util.ReadConf()
+		if err != nil {
+			return followRequestHandledMsg{handle: request.Handle(), action: action, err: err}
+		}
+
+		if approve {
+			err = activitypub.AcceptPendingFollow(account, request.URI, conf)
+		} else {
+			err = activitypub.RejectPendingFollow(account, request.URI, conf)
+		}
+		return followRequestHandledMsg{handle: request.Handle(), action: action, err: err}
+	}
+}
+
+// toggleLockedCmd sets whether new followers of the account have to be approved
+func toggleLockedCmd(accountId uuid.UUID, locked bool) tea.Cmd {
+	return func() tea.Msg {
+		err := db.GetDB().UpdateManuallyApprovesFollowers(accountId, locked)
+		if err == nil {
+			federateProfile(accountId)
+		}
+		return lockedToggledMsg{locked: locked, err: err}
+	}
+}
+
+// toggleDiscoverableCmd sets whether the account's posts are listed in the public timeline
+func toggleDiscoverableCmd(accountId uuid.UUID, discoverable bool) tea.Cmd {
+	return func() tea.Msg {
+		err := db.GetDB().UpdateDiscoverable(accountId, discoverable)
+		if err == nil {
+			federateProfile(accountId)
+		}
+		return discoverableToggledMsg{discoverable: discoverable, err: err}
+	}
+}
+
+// federateProfile tells the account's followers that its actor changed
+func federateProfile(accountId uuid.UUID) {
+	conf, err := util.ReadConf()
+	if err != nil {
+		log.Printf("Failed to read config for profile federation: %v", err)
+		return
+	}
+	web.FederateProfile(accountId, conf)
 }
 
 // loadFollowers loads the followers for the given account
@@ -144,16 +342,30 @@ func loadFollowers(accountId uuid.UUID) tea.Cmd {
 			log.Printf("Warning: Failed to cleanup orphaned follows: %v", err)
 		}
 
-		err, followers := database.ReadFollowersByAccountId(accountId)
+		msg := followersLoadedMsg{requests: []domain.FollowRequest{}, followers: []domain.Follow{}}
+
+		if account, err := database.ReadAccById(accountId); err == nil {
+			msg.locked = account.ManuallyApprovesFollowers
+			msg.discoverable = account.Discoverable
+		}
+
+		requests, err := database.ReadPendingFollowRequests(accountId)
+		if err != nil {
+			log.Printf("Failed to load follow requests: %v", err)
+		} else if requests != nil {
+			msg.requests = requests
+		}
+
+		followers, err := database.ReadFollowersByAccountId(accountId)
 		if err != nil {
 			log.Printf("Failed to load followers: %v", err)
-			return followersLoadedMsg{followers: []domain.Follow{}}
+			return msg
 		}
 
-		if followers == nil {
-			return followersLoadedMsg{followers: []domain.Follow{}}
+		if followers != nil {
+			msg.followers = *followers
 		}
 
-		return followersLoadedMsg{followers: *followers}
+		return msg
 	}
 }
@@ -0,0 +1,116 @@
+package followers
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/deemkeen/stegodon/domain"
+	"github.com/google/uuid"
+)
+
+func testRequest(username string) domain.FollowRequest {
+	return domain.FollowRequest{
+		Follow:   domain.Follow{Id: uuid.New(), URI: "https://remote.example.com/follows/" + username},
+		Username: username,
+		Domain:   "remote.example.com",
+		ActorURI: "https://remote.example.com/users/" + username,
+	}
+}
+
+func TestUpdate_FollowersLoaded(t *testing.T) {
+	model := InitialModel(uuid.New(), 100, 40)
+	model.Selected = 2
+
+	model, _ = model.Update(followersLoadedMsg{
+		requests:  []domain.FollowRequest{testRequest("bob")},
+		followers: []domain.Follow{{Id: uuid.New()}, {Id: uuid.New()}},
+		locked:    true,
+	})
+
+	if len(model.Requests) != 1 || len(model.Followers) != 2 {
+		t.Errorf("Expected 1 request and 2 followers, got %d and %d", len(model.Requests), len(model.Followers))
+	}
+	if !model.Locked {
+		t.Error("Expected model to be locked")
+	}
+	if model.Selected != 0 {
+		t.Errorf("Expected selection reset to 0, got %d", model.Selected)
+	}
+}
+
+func TestUpdate_ApproveRequest(t *testing.T) {
+	model := InitialModel(uuid.New(), 100, 40)
+	model.Requests = []domain.FollowRequest{testRequest("bob"), testRequest("carol")}
+	model.Followers = []domain.Follow{{Id: uuid.New()}}
+
+	model, cmd := model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'a'}})
+
+	if cmd == nil {
+		t.Fatal("Expected a command to approve the request")
+	}
+	if len(model.Requests) != 1 || model.Requests[0].Username != "carol" {
+		t.Errorf("Expected only carol's request to be left, got %v", model.Requests)
+	}
+}
+
+func TestUpdate_RejectIgnoredOnFollower(t *testing.T) {
+	model := InitialModel(uuid.New(), 100, 40)
+	model.Requests = []domain.FollowRequest{testRequest("bob")}
+	model.Followers = []domain.Follow{{Id: uuid.New()}}
+	model.Selected = 1 // the follower
+
+	model, cmd := model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'r'}})
+
+	if cmd != nil {
+		t.Error("Expected no command when a follower is selected")
+	}
+	if len(model.Requests) != 1 {
+		t.Errorf("Expected the request to be kept, got %d requests", len(model.Requests))
+	}
+}
+
+func TestUpdate_NavigationSpansRequestsAndFollowers(t *testing.T) {
+	model := InitialModel(uuid.New(), 100, 40)
+	model.Requests = []domain.FollowRequest{testRequest("bob")}
+	model.Followers = []domain.Follow{{Id: uuid.New()}}
+
+	for range 3 {
+		model, _ = model.Update(tea.KeyMsg{Type: tea.KeyDown})
+	}
+	if model.Selected != 1 {
+		t.Errorf("Expected selection to stop at the last entry (1), got %d", model.Selected)
+	}
+}
+
+func TestUpdate_DiscoverableToggled(t *testing.T) {
+	model := InitialModel(uuid.New(), 100, 40)
+
+	if _, cmd := model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'d'}}); cmd == nil {
+		t.Error("Expected a command to toggle discoverability")
+	}
+
+	model, _ = model.Update(discoverableToggledMsg{discoverable: false})
+	if !model.Hidden {
+		t.Error("Expected model to be hidden from the public timeline")
+	}
+	if !strings.Contains(model.View(), "not discoverable") {
+		t.Error("Expected the caption to show that the account isn't discoverable")
+	}
+}
+
+func TestUpdate_LockedToggled(t *testing.T) {
+	model := InitialModel(uuid.New(), 100, 40)
+
+	if _, cmd := model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'l'}}); cmd == nil {
+		t.Error("Expected a command to toggle follower approval")
+	}
+
+	model, _ = model.Update(lockedToggledMsg{locked: true})
+	if !model.Locked {
+		t.Error("Expected model to be locked")
+	}
+	if model.Status == "" {
+		t.Error("Expected a status message")
+	}
+}
@@ -2,6 +2,7 @@ package following
 
 import (
 	"fmt"
+	"os"
 	"strings"
 	"time"
 
@@ -56,6 +57,54 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 		m.Error = ""
 		return m, nil
 
+	case followingExportedMsg:
+		if msg.err != nil {
+			m.Error = fmt.Sprintf("Export failed: %v", msg.err)
+			return m, clearStatusAfter(3 * time.Second)
+		}
+		m.Status = fmt.Sprintf("Exported follows to %s", msg.path)
+		m.Error = ""
+		return m, clearStatusAfter(5 * time.Second)
+
+	case followingImportedMsg:
+		if msg.err != nil {
+			m.Error = fmt.Sprintf("Import failed: %v", msg.err)
+			return m, clearStatusAfter(3 * time.Second)
+		}
+		m.Status = fmt.Sprintf("Imported follows: %d followed, %d already followed", msg.result.Followed, msg.result.Skipped)
+		m.Error = ""
+		if len(msg.result.Errors) > 0 {
+			// Show the first few failed rows; the rest are in the log
+			var lines []string
+			for i, rowErr := range msg.result.Errors {
+				if i == 3 {
+					lines = append(lines, fmt.Sprintf("...and %d more (see log)", len(msg.result.Errors)-i))
+					break
+				}
+				lines = append(lines, rowErr.Error())
+			}
+			m.Error = fmt.Sprintf("%d rows skipped:\n%s", len(msg.result.Errors), strings.Join(lines, "\n"))
+		}
+		return m, loadFollowing(m.AccountId)
+
+	case followNotifyToggledMsg:
+		if msg.err != nil {
+			m.Error = fmt.Sprintf("Failed to change notifications: %v", msg.err)
+			return m, clearStatusAfter(3 * time.Second)
+		}
+		for i := range m.Following {
+			if m.Following[i].TargetAccountId == msg.targetAccountId {
+				m.Following[i].Notify = msg.notify
+			}
+		}
+		if msg.notify {
+			m.Status = "Notifying you of new posts"
+		} else {
+			m.Status = "Stopped notifying you of new posts"
+		}
+		m.Error = ""
+		return m, clearStatusAfter(2 * time.Second)
+
 	case tea.KeyMsg:
 		switch msg.String() {
 		case "up", "k":
@@ -74,6 +123,20 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 					m.Offset = m.Selected - common.DefaultItemsPerPage + 1
 				}
 			}
+		case "x":
+			m.Status = "Exporting follows..."
+			m.Error = ""
+			return m, exportFollowingCmd(m.AccountId)
+		case "i":
+			m.Status = "Importing follows..."
+			m.Error = ""
+			return m, importFollowingCmd(m.AccountId)
+		case "n":
+			// Toggle notifications for new posts of the selected account
+			if len(m.Following) > 0 && m.Selected < len(m.Following) {
+				selectedFollow := m.Following[m.Selected]
+				return m, toggleFollowNotifyCmd(m.AccountId, selectedFollow.TargetAccountId, !selectedFollow.Notify)
+			}
 		case "u", "enter":
 			// Unfollow the selected account
 			if len(m.Following) > 0 && m.Selected < len(m.Following) {
@@ -84,7 +147,7 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 
 				if selectedFollow.IsLocal {
 					// Local follow - get local account details
-					err, localAcc := database.ReadAccById(selectedFollow.TargetAccountId)
+					localAcc, err := database.ReadAccById(selectedFollow.TargetAccountId)
 					if err == nil && localAcc != nil {
 						displayName = "@" + localAcc.Username
 					} else {
@@ -92,7 +155,7 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 					}
 				} else {
 					// Remote follow - get remote account details
-					err, remoteAcc := database.ReadRemoteAccountById(selectedFollow.TargetAccountId)
+					remoteAcc, err := database.ReadRemoteAccountById(selectedFollow.TargetAccountId)
 					if err == nil && remoteAcc != nil {
 						displayName = fmt.Sprintf("@%s@%s", remoteAcc.Username, remoteAcc.Domain)
 					} else {
@@ -109,14 +172,14 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 					} else {
 						// For remote follows, send Undo activity first, then delete
 						// Get local account
-						localAccErr, localAccount := database.ReadAccById(m.AccountId)
+						localAccount, localAccErr := database.ReadAccById(m.AccountId)
 						if localAccErr != nil {
 							log.Printf("Unfollow failed: failed to get local account: %v", localAccErr)
 							return
 						}
 
 						// Get remote account
-						remoteAccErr, remoteAccount := database.ReadRemoteAccountById(selectedFollow.TargetAccountId)
+						remoteAccount, remoteAccErr := database.ReadRemoteAccountById(selectedFollow.TargetAccountId)
 						if remoteAccErr != nil {
 							log.Printf("Unfollow failed: failed to get remote account: %v", remoteAccErr)
 							return
@@ -168,6 +231,8 @@ func (m Model) View() string {
 
 	if len(m.Following) == 0 {
 		s.WriteString(common.ListEmptyStyle.Render("You're not following anyone yet.\nUse the follow user view to start following!"))
+		s.WriteString("\n\n")
+		m.writeStatus(&s)
 		return s.String()
 	}
 
@@ -182,7 +247,7 @@ func (m Model) View() string {
 
 		if follow.IsLocal {
 			// Local follow - look up in accounts table
-			err, localAcc := database.ReadAccById(follow.TargetAccountId)
+			localAcc, err := database.ReadAccById(follow.TargetAccountId)
 			if err != nil {
 				log.Printf("Failed to read local account: %v", err)
 				continue
@@ -194,7 +259,7 @@ func (m Model) View() string {
 			}
 		} else {
 			// Remote follow - look up in remote_accounts table
-			err, remoteAcc := database.ReadRemoteAccountById(follow.TargetAccountId)
+			remoteAcc, err := database.ReadRemoteAccountById(follow.TargetAccountId)
 			if err != nil {
 				log.Printf("Failed to read remote account: %v", err)
 				continue
@@ -206,6 +271,10 @@ func (m Model) View() string {
 			}
 		}
 
+		if follow.Notify {
+			badge += " [notify]"
+		}
+
 		if i == m.Selected {
 			// Selected item with arrow prefix
 			text := common.ListItemSelectedStyle.Render(username + badge)
@@ -226,7 +295,13 @@ func (m Model) View() string {
 	}
 
 	s.WriteString("\n")
+	m.writeStatus(&s)
 
+	return s.String()
+}
+
+// writeStatus renders the status and error messages
+func (m Model) writeStatus(s *strings.Builder) {
 	if m.Status != "" {
 		s.WriteString(common.ListStatusStyle.Render(m.Status))
 		s.WriteString("\n")
@@ -236,8 +311,6 @@ func (m Model) View() string {
 		s.WriteString(common.ListErrorStyle.Render(m.Error))
 		s.WriteString("\n")
 	}
-
-	return s.String()
 }
 
 // followingLoadedMsg is sent when following list is loaded
@@ -255,6 +328,95 @@ func clearStatusAfter(d time.Duration) tea.Cmd {
 	})
 }
 
+// followNotifyToggledMsg is sent when notifications for a follow were turned on or off
+type followNotifyToggledMsg struct {
+	targetAccountId uuid.UUID
+	notify          bool
+	err             error
+}
+
+// toggleFollowNotifyCmd turns notifications for new posts of a followed account on or off
+func toggleFollowNotifyCmd(accountId, targetAccountId uuid.UUID, notify bool) tea.Cmd {
+	return func() tea.Msg {
+		err := db.GetDB().UpdateFollowNotify(accountId, targetAccountId, notify)
+		if err != nil {
+			log.Printf("Failed to update follow notifications: %v", err)
+		}
+		return followNotifyToggledMsg{targetAccountId: targetAccountId, notify: notify, err: err}
+	}
+}
+
+// followingExportedMsg is sent when the following CSV export completes
+type followingExportedMsg struct {
+	path string
+	err  error
+}
+
+// followingImportedMsg is sent when the following CSV import completes
+type followingImportedMsg struct {
+	result *activitypub.FollowImportResult
+	err    error
+}
+
+// followingCSVPath returns where a user's following_accounts.csv is exported to or imported from
+func followingCSVPath(subdir, username string) string {
+	return util.ResolveFilePathWithSubdir(subdir, fmt.Sprintf("%s_following_accounts.csv", username))
+}
+
+// exportFollowingCmd writes the user's follows to exports/<username>_following_accounts.csv
+func exportFollowingCmd(accountId uuid.UUID) tea.Cmd {
+	return func() tea.Msg {
+		account, err := db.GetDB().ReadAccById(accountId)
+		if err != nil {
+			return followingExportedMsg{err: fmt.Errorf("failed to get account: %w", err)}
+		}
+		conf, err := util.ReadConf()
+		if err != nil {
+			return followingExportedMsg{err: fmt.Errorf("failed to read config: %w", err)}
+		}
+
+		path := followingCSVPath("exports", account.Username)
+		file, err := os.Create(path)
+		if err != nil {
+			return followingExportedMsg{err: err}
+		}
+		defer file.Close()
+
+		if err := activitypub.ExportFollowingCSV(file, account, conf); err != nil {
+			return followingExportedMsg{err: err}
+		}
+		log.Printf("Exported follows of %s to %s", account.Username, path)
+		return followingExportedMsg{path: path}
+	}
+}
+
+// importFollowingCmd follows every account in imports/<username>_following_accounts.csv
+func importFollowingCmd(accountId uuid.UUID) tea.Cmd {
+	return func() tea.Msg {
+		account, err := db.GetDB().ReadAccById(accountId)
+		if err != nil {
+			return followingImportedMsg{err: fmt.Errorf("failed to get account: %w", err)}
+		}
+		conf, err := util.ReadConf()
+		if err != nil {
+			return followingImportedMsg{err: fmt.Errorf("failed to read config: %w", err)}
+		}
+		if !conf.Conf.WithAp {
+			return followingImportedMsg{err: fmt.Errorf("federation is disabled")}
+		}
+
+		path := followingCSVPath("imports", account.Username)
+		file, err := os.Open(path)
+		if err != nil {
+			return followingImportedMsg{err: fmt.Errorf("place the CSV at %s", path)}
+		}
+		defer file.Close()
+
+		result, err := activitypub.ImportFollowingCSV(file, account, conf)
+		return followingImportedMsg{result: result, err: err}
+	}
+}
+
 // loadFollowing loads the accounts that the user is following
 func loadFollowing(accountId uuid.UUID) tea.Cmd {
 	return func() tea.Msg {
@@ -265,7 +427,7 @@ func loadFollowing(accountId uuid.UUID) tea.Cmd {
 			log.Printf("Warning: Failed to cleanup orphaned follows: %v", err)
 		}
 
-		err, following := database.ReadFollowingByAccountId(accountId)
+		following, err := database.ReadFollowingByAccountId(accountId)
 		if err != nil {
 			log.Printf("Failed to load following: %v", err)
 			return followingLoadedMsg{following: []domain.Follow{}}
@@ -12,6 +12,7 @@ import (
 	"github.com/charmbracelet/lipgloss"
 	"github.com/deemkeen/stegodon/activitypub"
 	"github.com/deemkeen/stegodon/db"
+	"github.com/deemkeen/stegodon/domain"
 	"github.com/deemkeen/stegodon/ui/common"
 	"github.com/deemkeen/stegodon/util"
 	"github.com/deemkeen/stegodon/web"
@@ -25,19 +26,24 @@ var (
 		BorderForeground(lipgloss.Color(common.COLOR_DIM))
 )
 
+// maxSearchResults is the number of accounts listed for a search
+const maxSearchResults = 10
+
 type Model struct {
 	TextInput textinput.Model
 	AccountId uuid.UUID
 	Status    string
 	Error     string
+	Results   []domain.AccountSearchResult // Accounts matching the last search
+	Selected  int                          // Index of the selected result, -1 if none
 }
 
 func InitialModel(accountId uuid.UUID) Model {
 	ti := textinput.New()
-	ti.Placeholder = "user@domain or @user@domain"
+	ti.Placeholder = "user@domain, @user@domain, a name to search or a post URL"
 	ti.Prompt = common.ListSelectedPrefix
 	ti.Focus()
-	ti.CharLimit = 100
+	ti.CharLimit = 300
 	ti.Width = 50
 
 	return Model{
@@ -45,6 +51,7 @@ func InitialModel(accountId uuid.UUID) Model {
 		AccountId: accountId,
 		Status:    "",
 		Error:     "",
+		Selected:  -1,
 	}
 }
 
@@ -84,29 +91,78 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 		}
 		return m, clearStatusAfter(2 * time.Second)
 
+	case searchResultMsg:
+		if msg.err != nil {
+			m.Error = fmt.Sprintf("Search failed: %v", msg.err)
+			m.Status = ""
+			return m, clearStatusAfter(2 * time.Second)
+		}
+		m.Results = msg.results
+		m.Selected = -1
+		if len(m.Results) == 0 {
+			m.Status = fmt.Sprintf("ℹ No known accounts match %q. Enter user@domain to look one up.", msg.query)
+		} else {
+			m.Status = ""
+		}
+		m.Error = ""
+		return m, nil
+
+	case importResultMsg:
+		if msg.err != nil {
+			m.Error = fmt.Sprintf("Import failed: %v", msg.err)
+			m.Status = ""
+			return m, clearStatusAfter(2 * time.Second)
+		}
+		m.Status = fmt.Sprintf("✓ Imported %d posts of the conversation", msg.result.Imported)
+		if msg.result.Truncated {
+			m.Status += " (too large, partially imported)"
+		}
+		m.Error = ""
+		rootURI, continueURI := msg.result.RootURI, msg.result.ContinueURI
+		return m, tea.Batch(clearStatusAfter(2*time.Second), func() tea.Msg {
+			return common.ViewThreadMsg{NoteURI: rootURI, LoadMoreURI: continueURI}
+		})
+
 	case tea.KeyMsg:
 		switch msg.String() {
+		case "up", "down":
+			// Pick a search result; its handle becomes the input to follow
+			if len(m.Results) == 0 {
+				return m, nil
+			}
+			if msg.String() == "up" {
+				m.Selected = max(m.Selected-1, 0)
+			} else {
+				m.Selected = min(m.Selected+1, len(m.Results)-1)
+			}
+			m.TextInput.SetValue(m.followHandle(m.Results[m.Selected]))
+			m.TextInput.CursorEnd()
+			return m, nil
 		case "enter":
-			// Parse user@domain format
 			input := strings.TrimSpace(m.TextInput.Value())
 			if input == "" {
-				m.Error = "Please enter a user@domain"
+				m.Error = "Please enter a user@domain or a name to search"
 				return m, clearStatusAfter(2 * time.Second)
 			}
 
-			// Remove leading @ if present
-			input = strings.TrimPrefix(input, "@")
-
-			parts := strings.Split(input, "@")
-			if len(parts) != 2 {
-				m.Error = "Invalid format. Use: user@domain.com or @user@domain.com"
-				return m, clearStatusAfter(2 * time.Second)
+			// A post URL imports the conversation it belongs to
+			if strings.HasPrefix(input, "https://") || strings.HasPrefix(input, "http://") {
+				m.Status = "Fetching conversation..."
+				m.Error = ""
+				m.Results = nil
+				m.Selected = -1
+				return m, importConversationCmd(input)
 			}
 
-			username := parts[0]
-			domain := parts[1]
+			// Anything that isn't a handle is searched among known accounts
+			if !strings.Contains(strings.TrimPrefix(input, "@"), "@") {
+				m.Status = fmt.Sprintf("Searching for %q...", input)
+				m.Error = ""
+				return m, searchAccountsCmd(input)
+			}
 
-			if username == "" || domain == "" {
+			username, domain, err := web.ParseHandle(input)
+			if err != nil {
 				m.Error = "Invalid format. Use: user@domain.com or @user@domain.com"
 				return m, clearStatusAfter(2 * time.Second)
 			}
@@ -127,6 +183,8 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 			m.TextInput.SetValue("")
 			m.Status = ""
 			m.Error = ""
+			m.Results = nil
+			m.Selected = -1
 			return m, nil
 		}
 	}
@@ -141,10 +199,31 @@ func (m Model) View() string {
 	s.WriteString(common.CaptionStyle.Render("follow remote user"))
 	s.WriteString("\n\n")
 	s.WriteString("Enter ActivityPub address:\n")
-	s.WriteString("(e.g., user@mastodon.social or @user@mastodon.social)\n\n")
+	s.WriteString("(e.g., user@mastodon.social or @user@mastodon.social)\n")
+	s.WriteString("or search known accounts by name,\n")
+	s.WriteString("or paste a post URL to fetch its conversation\n\n")
 	s.WriteString(m.TextInput.View())
 	s.WriteString("\n\n")
 
+	if len(m.Results) > 0 {
+		for i, result := range m.Results {
+			line := result.Handle()
+			if result.DisplayName != "" {
+				line += " (" + result.DisplayName + ")"
+			}
+			if result.IsLocal() {
+				line += " [local]"
+			}
+			if i == m.Selected {
+				s.WriteString(common.ListItemSelectedStyle.Render(common.ListSelectedPrefix + line))
+			} else {
+				s.WriteString(common.ListItemStyle.Render(common.ListUnselectedPrefix + line))
+			}
+			s.WriteString("\n")
+		}
+		s.WriteString("\n")
+	}
+
 	if m.Status != "" {
 		s.WriteString(lipgloss.NewStyle().Foreground(lipgloss.Color(common.COLOR_SUCCESS)).Render(m.Status))
 		s.WriteString("\n")
@@ -167,6 +246,54 @@ type followResultMsg struct {
 	err      error
 }
 
+// importResultMsg is sent when a conversation import completes
+type importResultMsg struct {
+	result *activitypub.ConversationImport
+	err    error
+}
+
+// searchResultMsg is sent when an account search completes
+type searchResultMsg struct {
+	query   string
+	results []domain.AccountSearchResult
+	err     error
+}
+
+// followHandle returns the handle entered to follow a search result. Local accounts get the
+// server's domain, which is then refused with a hint to follow them directly.
+func (m Model) followHandle(result domain.AccountSearchResult) string {
+	if !result.IsLocal() {
+		return result.Username + "@" + result.Domain
+	}
+	if conf, err := util.ReadConf(); err == nil {
+		return result.Username + "@" + conf.Conf.SslDomain
+	}
+	return result.Username
+}
+
+// searchAccountsCmd returns a command that searches local and cached remote accounts
+func searchAccountsCmd(query string) tea.Cmd {
+	return func() tea.Msg {
+		results, err := db.GetDB().SearchAccounts(strings.TrimPrefix(query, "@"), maxSearchResults)
+		if err != nil {
+			return searchResultMsg{query: query, err: err}
+		}
+		return searchResultMsg{query: query, results: *results}
+	}
+}
+
+// importConversationCmd returns a command that imports the conversation of a remote post
+func importConversationCmd(url string) tea.Cmd {
+	return func() tea.Msg {
+		conf, err := util.ReadConf()
+		if err != nil {
+			return importResultMsg{err: fmt.Errorf("failed to read config: %w", err)}
+		}
+		result, err := activitypub.ImportConversation(url, conf)
+		return importResultMsg{result: result, err: err}
+	}
+}
+
 // clearStatusAfter returns a command that sends clearStatusMsg after a duration
 func clearStatusAfter(d time.Duration) tea.Cmd {
 	return tea.Tick(d, func(t time.Time) tea.Msg {
@@ -189,16 +316,17 @@ func followRemoteUserCmd(accountId uuid.UUID, username, domain, fullUsername str
 func followRemoteUser(accountId uuid.UUID, username, domain string) error {
 	// Get local account
 	database := db.GetDB()
-	err, localAccount := database.ReadAccById(accountId)
+	localAccount, err := database.ReadAccById(accountId)
 	if err != nil {
 		return fmt.Errorf("failed to get local account: %w", err)
 	}
 
-	// Resolve WebFinger to get actor URI
-	actorURI, err := web.ResolveWebFinger(username, domain)
+	// Resolve the handle to the remote actor
+	remoteAccount, err := web.ResolveAccount(username + "@" + domain)
 	if err != nil {
-		return fmt.Errorf("webfinger resolution failed: %w", err)
+		return err
 	}
+	actorURI := remoteAccount.ActorURI
 
 	// Get config
 	conf, err := util.ReadConf()
@@ -6,6 +6,9 @@ import (
 	"strings"
 	"testing"
 
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/deemkeen/stegodon/activitypub"
+	"github.com/deemkeen/stegodon/domain"
 	"github.com/google/uuid"
 )
 
@@ -201,6 +204,81 @@ func TestClearStatusMsg(t *testing.T) {
 	}
 }
 
+func TestSearchResultMsg(t *testing.T) {
+	model := InitialModel(uuid.New())
+	results := []domain.AccountSearchResult{
+		{Id: uuid.New(), Username: "alice", Domain: "mastodon.social", DisplayName: "Alice"},
+		{Id: uuid.New(), Username: "alicia", Domain: "example.com"},
+	}
+
+	updatedModel, _ := model.Update(searchResultMsg{query: "ali", results: results})
+	if len(updatedModel.Results) != 2 || updatedModel.Selected != -1 {
+		t.Fatalf("Expected 2 results without a selection, got %d (selected %d)", len(updatedModel.Results), updatedModel.Selected)
+	}
+	view := updatedModel.View()
+	if !strings.Contains(view, "@alice@mastodon.social (Alice)") || !strings.Contains(view, "@alicia@example.com") {
+		t.Errorf("Expected the results to be listed, got:\n%s", view)
+	}
+
+	// Picking a result puts its handle into the input
+	updatedModel, _ = updatedModel.Update(tea.KeyMsg{Type: tea.KeyDown})
+	updatedModel, _ = updatedModel.Update(tea.KeyMsg{Type: tea.KeyDown})
+	updatedModel, _ = updatedModel.Update(tea.KeyMsg{Type: tea.KeyDown})
+	if updatedModel.Selected != 1 || updatedModel.TextInput.Value() != "alicia@example.com" {
+		t.Errorf("Expected the last result to be picked, got %d with input %q", updatedModel.Selected, updatedModel.TextInput.Value())
+	}
+	updatedModel, _ = updatedModel.Update(tea.KeyMsg{Type: tea.KeyUp})
+	if updatedModel.Selected != 0 || updatedModel.TextInput.Value() != "alice@mastodon.social" {
+		t.Errorf("Expected the first result to be picked, got %d with input %q", updatedModel.Selected, updatedModel.TextInput.Value())
+	}
+
+	// Escape clears the search
+	updatedModel, _ = updatedModel.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if len(updatedModel.Results) != 0 || updatedModel.Selected != -1 {
+		t.Errorf("Expected escape to clear the results")
+	}
+}
+
+func TestSearchResultMsg_NoResults(t *testing.T) {
+	model := InitialModel(uuid.New())
+	updatedModel, _ := model.Update(searchResultMsg{query: "nobody"})
+	if !strings.Contains(updatedModel.Status, "No known accounts") {
+		t.Errorf("Expected a hint to look the account up, got %q", updatedModel.Status)
+	}
+}
+
+func TestEnterWithoutDomainSearches(t *testing.T) {
+	model := InitialModel(uuid.New())
+	model.TextInput.SetValue("@alice")
+	updatedModel, cmd := model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if cmd == nil || !strings.Contains(updatedModel.Status, "Searching") {
+		t.Errorf("Expected a search to start, got status %q", updatedModel.Status)
+	}
+}
+
+func TestImportResultMsg(t *testing.T) {
+	model := InitialModel(uuid.New())
+	model.TextInput.SetValue("https://remote.example.com/notes/2")
+	updatedModel, cmd := model.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if cmd == nil || !strings.Contains(updatedModel.Status, "Fetching conversation") {
+		t.Errorf("Expected an import to start, got status %q", updatedModel.Status)
+	}
+
+	result := &activitypub.ConversationImport{RootURI: "https://remote.example.com/notes/1", Imported: 3}
+	updatedModel, cmd = updatedModel.Update(importResultMsg{result: result})
+	if !strings.Contains(updatedModel.Status, "Imported 3 posts") {
+		t.Errorf("Expected the number of imported posts, got %q", updatedModel.Status)
+	}
+	if cmd == nil {
+		t.Fatal("Expected a command to open the thread")
+	}
+
+	updatedModel, _ = model.Update(importResultMsg{err: activitypub.ErrNotAPost})
+	if !strings.Contains(updatedModel.Error, "Import failed") {
+		t.Errorf("Expected the import error, got %q", updatedModel.Error)
+	}
+}
+
 func TestFollowResultMsg_SelfFollow(t *testing.T) {
 	// Create a model
 	accountId := uuid.New()
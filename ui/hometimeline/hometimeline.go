@@ -6,6 +6,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/deemkeen/stegodon/db"
@@ -60,22 +61,55 @@ type Model struct {
 	Selected    int // Currently selected post index
 	Width       int
 	Height      int
-	isActive    bool   // Track if this view is currently visible (prevents ticker leaks)
-	showingURL  bool   // Track if URL is displayed instead of content for selected post
-	LocalDomain string // Cached local domain for mention highlighting
+	isActive    bool               // Track if this view is currently visible (prevents ticker leaks)
+	showingURL  bool               // Track if URL is displayed instead of content for selected post
+	LocalDomain string             // Cached local domain for mention highlighting
+	Live        bool               // Reload on live events instead of polling
+	Status      string             // Result of the last settings change
+	Copied      string             // Permalink put into the terminal clipboard with the status
+	ShowReplies bool               // Replies to followed accounts are shown in the timeline
+	Location    *time.Location     // Timezone timestamps are shown in
+	Expanded    map[uuid.UUID]bool // Self-threads shown in full, by the id of their first post
+	// Language settings prompt
+	EditingLanguages bool
+	LanguageStep     int             // 0 = default post language, 1 = languages to see
+	DefaultLanguage  textinput.Model // Language new notes are tagged with
+	Languages        textinput.Model // Languages shown in the timeline
+	// Timezone prompt
+	EditingTimezone bool
+	Timezone        textinput.Model // IANA timezone name, empty for UTC
 }
 
 func InitialModel(accountId uuid.UUID, width, height int, localDomain string) Model {
+	defaultLanguage := textinput.New()
+	defaultLanguage.Placeholder = "en"
+	defaultLanguage.CharLimit = 10
+	defaultLanguage.Width = 20
+
+	languages := textinput.New()
+	languages.Placeholder = "en, de (empty shows all languages)"
+	languages.CharLimit = 100
+	languages.Width = 40
+
+	timezone := textinput.New()
+	timezone.Placeholder = "Europe/Berlin (empty for UTC)"
+	timezone.CharLimit = 64
+	timezone.Width = 40
+
 	return Model{
-		AccountId:   accountId,
-		Posts:       []domain.HomePost{},
-		Offset:      0,
-		Selected:    0,
-		Width:       width,
-		Height:      height,
-		isActive:    false, // Start inactive, will be activated when view is shown
-		showingURL:  false, // Start in content mode
-		LocalDomain: localDomain,
+		AccountId:       accountId,
+		Posts:           []domain.HomePost{},
+		Offset:          0,
+		Selected:        0,
+		Width:           width,
+		Height:          height,
+		isActive:        false, // Start inactive, will be activated when view is shown
+		showingURL:      false, // Start in content mode
+		LocalDomain:     localDomain,
+		Expanded:        map[uuid.UUID]bool{},
+		DefaultLanguage: defaultLanguage,
+		Languages:       languages,
+		Timezone:        timezone,
 	}
 }
 
@@ -109,6 +143,8 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 		m.Selected = 0
 		m.Offset = 0
 		m.showingURL = false
+		m.EditingLanguages = false
+		m.EditingTimezone = false
 		// Load data first, tick will be scheduled when data arrives
 		return m, loadHomePosts(m.AccountId)
 
@@ -121,6 +157,13 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 		}
 		return m, nil
 
+	case common.LiveEventMsg:
+		// New posts reload the timeline whether or not it is visible, like UpdateNoteList
+		if msg.Type == util.EventNewPost {
+			return m, loadHomePosts(m.AccountId)
+		}
+		return m, nil
+
 	case refreshTickMsg:
 		// Only schedule next refresh if view is still active
 		if m.isActive {
@@ -131,6 +174,7 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 
 	case postsLoadedMsg:
 		m.Posts = msg.posts
+		m.ShowReplies = msg.showReplies
 		// Keep selection within bounds after reload
 		if m.Selected >= len(m.Posts) {
 			m.Selected = max(0, len(m.Posts)-1)
@@ -138,14 +182,118 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 		// Keep Offset in sync
 		m.Offset = m.Selected
 
-		// Schedule next tick AFTER data loads (only if still active)
-		if m.isActive {
+		// Schedule next tick AFTER data loads (only if still active and not live)
+		if m.isActive && !m.Live {
 			return m, tickRefresh()
 		}
 		return m, nil
 
+	case languageSettingsLoadedMsg:
+		m.EditingLanguages = true
+		m.LanguageStep = 0
+		m.DefaultLanguage.SetValue(msg.settings.DefaultLanguage)
+		m.Languages.SetValue(strings.Join(msg.settings.Languages, ", "))
+		m.DefaultLanguage.Focus()
+		m.Languages.Blur()
+		return m, textinput.Blink
+
+	case languageSettingsSavedMsg:
+		if msg.err != nil {
+			m.Status = fmt.Sprintf("Failed to save language settings: %v", msg.err)
+			return m, nil
+		}
+		m.Status = "Language settings saved"
+		return m, loadHomePosts(m.AccountId)
+
+	case timezoneLoadedMsg:
+		m.EditingTimezone = true
+		m.Timezone.SetValue(msg.timezone)
+		m.Timezone.Focus()
+		return m, textinput.Blink
+
+	case timezoneSavedMsg:
+		if msg.err != nil {
+			m.Status = fmt.Sprintf("Failed to set timezone: %v", msg.err)
+			return m, nil
+		}
+		m.Status = fmt.Sprintf("Showing times in %s", msg.location)
+		return m, func() tea.Msg {
+			return common.TimezoneChangedMsg{Location: msg.location}
+		}
+
+	case showRepliesToggledMsg:
+		if msg.err != nil {
+			m.Status = fmt.Sprintf("Failed to change reply setting: %v", msg.err)
+			return m, nil
+		}
+		if msg.enabled {
+			m.Status = "Showing replies to people you follow"
+		} else {
+			m.Status = "Hiding replies"
+		}
+		return m, loadHomePosts(m.AccountId)
+
 	case tea.KeyMsg:
+		// In the timezone prompt, keys go to the input
+		if m.EditingTimezone {
+			switch msg.String() {
+			case "esc":
+				m.EditingTimezone = false
+				m.Timezone.Blur()
+				return m, nil
+			case "enter":
+				m.EditingTimezone = false
+				m.Timezone.Blur()
+				return m, saveTimezone(m.AccountId, m.Timezone.Value())
+			}
+			var cmd tea.Cmd
+			m.Timezone, cmd = m.Timezone.Update(msg)
+			return m, cmd
+		}
+
+		// In the language settings prompt, keys go to the inputs
+		if m.EditingLanguages {
+			var cmd tea.Cmd
+			switch msg.String() {
+			case "esc":
+				m.EditingLanguages = false
+				m.DefaultLanguage.Blur()
+				m.Languages.Blur()
+				return m, nil
+			case "enter":
+				if m.LanguageStep == 0 {
+					m.LanguageStep = 1
+					m.DefaultLanguage.Blur()
+					m.Languages.Focus()
+					return m, nil
+				}
+				m.EditingLanguages = false
+				m.Languages.Blur()
+				return m, saveLanguageSettings(m.AccountId, &domain.LanguageSettings{
+					DefaultLanguage: m.DefaultLanguage.Value(),
+					Languages:       util.ParseLanguages(m.Languages.Value()),
+				})
+			}
+			if m.LanguageStep == 0 {
+				m.DefaultLanguage, cmd = m.DefaultLanguage.Update(msg)
+			} else {
+				m.Languages, cmd = m.Languages.Update(msg)
+			}
+			return m, cmd
+		}
+
+		m.Status = ""
+		m.Copied = ""
 		switch msg.String() {
+		case "L":
+			// Edit default post language and the languages shown in timelines
+			return m, loadLanguageSettings(m.AccountId)
+		case "T":
+			// Set the timezone timestamps are shown in
+			return m, loadTimezone(m.AccountId)
+		case "R":
+			// Toggle replies to followed accounts in the timeline
+			return m, toggleShowRepliesCmd(m.AccountId, !m.ShowReplies)
 		case "up", "k":
 			if m.Selected > 0 {
 				m.Selected--
@@ -166,6 +314,25 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 					m.showingURL = !m.showingURL
 				}
 			}
+		case "e":
+			// Expand or collapse the selected self-thread
+			if len(m.Posts) > 0 && m.Selected < len(m.Posts) && len(m.Posts[m.Selected].SelfThread) > 0 {
+				id := m.Posts[m.Selected].ID
+				if m.Expanded == nil {
+					m.Expanded = map[uuid.UUID]bool{}
+				}
+				m.Expanded[id] = !m.Expanded[id]
+			}
+		case "c":
+			// Show the selected post's permalink and copy it to the clipboard
+			if len(m.Posts) > 0 && m.Selected < len(m.Posts) {
+				if link := permalink(m.Posts[m.Selected], m.LocalDomain); link != "" {
+					m.Status = "Link copied: " + link
+					m.Copied = link
+				} else {
+					m.Status = "This post has no link to share"
+				}
+			}
 		case "r":
 			// Reply to selected post
 			if len(m.Posts) > 0 && m.Selected < len(m.Posts) {
@@ -262,6 +429,37 @@ func (m Model) View() string {
 	s.WriteString(common.CaptionStyle.Render(fmt.Sprintf("home (%d posts)", len(m.Posts))))
 	s.WriteString("\n\n")
 
+	if m.EditingTimezone {
+		s.WriteString("Show times in this timezone (e.g. America/New_York):\n")
+		s.WriteString(m.Timezone.View())
+		s.WriteString("\n\n")
+		s.WriteString(common.HelpStyle.Render("enter: save | esc: cancel"))
+		return s.String()
+	}
+
+	if m.EditingLanguages {
+		if m.LanguageStep == 0 {
+			s.WriteString("Default language of your posts (e.g. en):\n")
+			s.WriteString(m.DefaultLanguage.View())
+			s.WriteString("\n\n")
+			s.WriteString(common.HelpStyle.Render("enter: next | esc: cancel"))
+		} else {
+			s.WriteString("Only show posts in these languages (posts without a language are always shown):\n")
+			s.WriteString(m.Languages.View())
+			s.WriteString("\n\n")
+			s.WriteString(common.HelpStyle.Render("enter: save | esc: cancel"))
+		}
+		return s.String()
+	}
+
+	if m.Status != "" {
+		if m.Copied != "" {
+			s.WriteString(util.FormatClipboardCopy(m.Copied))
+		}
+		s.WriteString(common.HelpStyle.Render(m.Status))
+		s.WriteString("\n\n")
+	}
+
 	if len(m.Posts) == 0 {
 		s.WriteString(emptyStyle.Render("No posts yet.\nFollow some accounts to see their posts here!"))
 	} else {
@@ -281,18 +479,24 @@ func (m Model) View() string {
 			post := m.Posts[i]
 
 			// Format timestamp with engagement indicators
-			timeStr := formatTime(post.Time)
+			timeStr := common.FormatTime(post.Time, m.Location)
 			if post.ReplyCount == 1 {
 				timeStr = fmt.Sprintf("%s · 1 reply", timeStr)
 			} else if post.ReplyCount > 1 {
 				timeStr = fmt.Sprintf("%s · %d replies", timeStr, post.ReplyCount)
 			}
-			if post.LikeCount > 0 {
+			if post.LikedByMe {
+				// The viewer's own like is marked so it can be told apart from others'
+				timeStr = fmt.Sprintf("%s · ⭐ %d (liked)", timeStr, post.LikeCount)
+			} else if post.LikeCount > 0 {
 				timeStr = fmt.Sprintf("%s · ⭐ %d", timeStr, post.LikeCount)
 			}
 			if post.BoostCount > 0 {
 				timeStr = fmt.Sprintf("%s · 🔁 %d", timeStr, post.BoostCount)
 			}
+			if reactions := domain.FormatReactions(post.Reactions); reactions != "" {
+				timeStr = fmt.Sprintf("%s · %s", timeStr, reactions)
+			}
 
 			// Format author with @ prefix for all users
 			author := post.Author
@@ -309,6 +513,9 @@ func (m Model) View() string {
 
 				timeFormatted := selectedBg.Render(selectedTimeStyle.Render(timeStr))
 				authorFormatted := selectedBg.Render(selectedAuthorStyle.Render(author))
+				if boostedBy := post.BoostedByLabel(); boostedBy != "" {
+					timeFormatted += "\n" + selectedBg.Render(selectedTimeStyle.Render("🔁 "+boostedBy))
+				}
 
 				// Toggle between content and URL
 				if m.showingURL && post.ObjectURI != "" {
@@ -325,11 +532,9 @@ func (m Model) View() string {
 					s.WriteString(authorFormatted + "\n")
 					s.WriteString(contentFormatted)
 				} else {
-					// Convert Markdown links first, then highlight hashtags (same order as myposts)
-					processedContent := post.Content
-					if post.IsLocal {
-						processedContent = util.MarkdownLinksToTerminal(processedContent)
-					}
+					// Convert Markdown links first, then highlight hashtags (same order as myposts).
+					// Remote posts are Markdown converted from their sanitized HTML.
+					processedContent := util.MarkdownLinksToTerminal(post.DisplayContent())
 					highlightedContent := util.HighlightHashtagsTerminal(processedContent)
 					highlightedContent = util.HighlightMentionsTerminal(highlightedContent, m.LocalDomain)
 
@@ -337,16 +542,19 @@ func (m Model) View() string {
 					s.WriteString(timeFormatted + "\n")
 					s.WriteString(authorFormatted + "\n")
 					s.WriteString(contentFormatted)
+					if quote := formatQuote(post); quote != "" {
+						s.WriteString("\n" + selectedBg.Render(selectedTimeStyle.Render(quote)))
+					}
+					for _, line := range m.formatSelfThread(post) {
+						s.WriteString("\n" + selectedBg.Render(selectedTimeStyle.Render(line)))
+					}
 				}
 			} else {
 				unselectedStyle := lipgloss.NewStyle().
 					Width(contentWidth)
 
 				// Convert Markdown links first, then highlight hashtags (same order as myposts)
-				processedContent := post.Content
-				if post.IsLocal {
-					processedContent = util.MarkdownLinksToTerminal(processedContent)
-				}
+				processedContent := util.MarkdownLinksToTerminal(post.DisplayContent())
 				highlightedContent := util.HighlightHashtagsTerminal(processedContent)
 				highlightedContent = util.HighlightMentionsTerminal(highlightedContent, m.LocalDomain)
 
@@ -359,11 +567,20 @@ func (m Model) View() string {
 				}
 
 				timeFormatted := unselectedStyle.Render(timeStyle.Render(timeStr))
+				if boostedBy := post.BoostedByLabel(); boostedBy != "" {
+					timeFormatted += "\n" + unselectedStyle.Render(timeStyle.Render("🔁 "+boostedBy))
+				}
 				contentFormatted := unselectedStyle.Render(contentStyle.Render(util.TruncateVisibleLength(highlightedContent, common.MaxContentTruncateWidth)))
 
 				s.WriteString(timeFormatted + "\n")
 				s.WriteString(authorFormatted + "\n")
 				s.WriteString(contentFormatted)
+				if quote := formatQuote(post); quote != "" {
+					s.WriteString("\n" + unselectedStyle.Render(timeStyle.Render(quote)))
+				}
+				for _, line := range m.formatSelfThread(post) {
+					s.WriteString("\n" + unselectedStyle.Render(timeStyle.Render(line)))
+				}
 			}
 
 			s.WriteString("\n\n")
@@ -373,26 +590,163 @@ func (m Model) View() string {
 	return s.String()
 }
 
+// formatQuote renders the post a quote post quotes as "quoting @user: text", falling back
+// to the quoted URI when the quoted post couldn't be resolved. Returns "" for other posts.
+func formatQuote(post domain.HomePost) string {
+	if post.QuoteURI == "" {
+		return ""
+	}
+	if post.QuoteContent == "" {
+		return util.TruncateVisibleLength("quoting "+post.QuoteURI, common.MaxContentTruncateWidth)
+	}
+	quote := strings.Join(strings.Fields(post.QuoteContent), " ")
+	if post.QuoteAuthor != "" {
+		quote = post.QuoteAuthor + ": " + quote
+	}
+	return util.TruncateVisibleLength("quoting "+quote, common.MaxContentTruncateWidth)
+}
+
+// formatSelfThread renders the later posts of a self-thread: a count while the thread is
+// collapsed, and one line per post once it is expanded. Returns nil for other posts.
+func (m Model) formatSelfThread(post domain.HomePost) []string {
+	if len(post.SelfThread) == 0 {
+		return nil
+	}
+	if !m.Expanded[post.ID] {
+		more := "1 more post"
+		if len(post.SelfThread) > 1 {
+			more = fmt.Sprintf("%d more posts", len(post.SelfThread))
+		}
+		return []string{fmt.Sprintf("🧵 %s in this thread (e: expand)", more)}
+	}
+	lines := make([]string, 0, len(post.SelfThread))
+	for _, part := range post.SelfThread {
+		text := strings.Join(strings.Fields(part.DisplayContent()), " ")
+		lines = append(lines, util.TruncateVisibleLength("↳ "+common.FormatTime(part.Time, m.Location)+" · "+text, common.MaxContentTruncateWidth))
+	}
+	return lines
+}
+
+// permalink returns the URL a post can be shared with off-instance: the object URI of remote
+// and relay-forwarded posts, and the note URL of local ones. Returns "" if there is none.
+func permalink(post domain.HomePost, localDomain string) string {
+	if util.IsURL(post.ObjectURI) {
+		return post.ObjectURI
+	}
+	if post.IsLocal && post.NoteID != uuid.Nil && localDomain != "" {
+		return fmt.Sprintf("https://%s/notes/%s", localDomain, post.NoteID)
+	}
+	return ""
+}
+
 // postsLoadedMsg is sent when posts are loaded
 type postsLoadedMsg struct {
-	posts []domain.HomePost
+	posts       []domain.HomePost
+	showReplies bool
 }
 
 // loadHomePosts loads the unified home timeline
 func loadHomePosts(accountId uuid.UUID) tea.Cmd {
 	return func() tea.Msg {
 		database := db.GetDB()
-		err, posts := database.ReadHomeTimelinePosts(accountId, common.HomeTimelinePostLimit)
+		showReplies, _ := database.ReadShowRepliesInHome(accountId)
+		posts, err := database.ReadHomeTimelinePosts(accountId, common.HomeTimelinePostLimit)
 		if err != nil {
 			log.Printf("Failed to load home timeline: %v", err)
-			return postsLoadedMsg{posts: []domain.HomePost{}}
+			return postsLoadedMsg{posts: []domain.HomePost{}, showReplies: showReplies}
 		}
 
 		if posts == nil {
-			return postsLoadedMsg{posts: []domain.HomePost{}}
+			return postsLoadedMsg{posts: []domain.HomePost{}, showReplies: showReplies}
 		}
 
-		return postsLoadedMsg{posts: *posts}
+		return postsLoadedMsg{posts: *posts, showReplies: showReplies}
+	}
+}
+
+// languageSettingsLoadedMsg opens the language settings prompt with the current settings
+type languageSettingsLoadedMsg struct {
+	settings domain.LanguageSettings
+}
+
+// languageSettingsSavedMsg reports the result of saving language settings
+type languageSettingsSavedMsg struct {
+	err error
+}
+
+// loadLanguageSettings reads the account's language settings for the settings prompt
+func loadLanguageSettings(accountId uuid.UUID) tea.Cmd {
+	return func() tea.Msg {
+		settings, err := db.GetDB().ReadLanguageSettings(accountId)
+		if err != nil {
+			log.Printf("Failed to load language settings: %v", err)
+			return languageSettingsLoadedMsg{}
+		}
+		return languageSettingsLoadedMsg{settings: *settings}
+	}
+}
+
+// saveLanguageSettings stores the account's language settings
+func saveLanguageSettings(accountId uuid.UUID, settings *domain.LanguageSettings) tea.Cmd {
+	return func() tea.Msg {
+		err := db.GetDB().UpdateLanguageSettings(accountId, settings)
+		if err != nil {
+			log.Printf("Failed to save language settings: %v", err)
+		}
+		return languageSettingsSavedMsg{err: err}
+	}
+}
+
+// timezoneLoadedMsg opens the timezone prompt with the current timezone
+type timezoneLoadedMsg struct {
+	timezone string
+}
+
+// timezoneSavedMsg reports the result of setting the timezone
+type timezoneSavedMsg struct {
+	location *time.Location
+	err      error
+}
+
+// loadTimezone reads the account's timezone for the timezone prompt
+func loadTimezone(accountId uuid.UUID) tea.Cmd {
+	return func() tea.Msg {
+		timezone, err := db.GetDB().ReadTimezone(accountId)
+		if err != nil {
+			log.Printf("Failed to load timezone: %v", err)
+		}
+		return timezoneLoadedMsg{timezone: timezone}
+	}
+}
+
+// saveTimezone stores the account's timezone
+func saveTimezone(accountId uuid.UUID, timezone string) tea.Cmd {
+	return func() tea.Msg {
+		location, err := util.LoadTimezone(timezone)
+		if err == nil {
+			err = db.GetDB().SetTimezone(accountId, timezone)
+		}
+		if err != nil {
+			log.Printf("Failed to save timezone: %v", err)
+		}
+		return timezoneSavedMsg{location: location, err: err}
+	}
+}
+
+// showRepliesToggledMsg reports the result of changing whether replies are shown
+type showRepliesToggledMsg struct {
+	enabled bool
+	err     error
+}
+
+// toggleShowRepliesCmd sets whether the timeline shows replies to followed accounts
+func toggleShowRepliesCmd(accountId uuid.UUID, enabled bool) tea.Cmd {
+	return func() tea.Msg {
+		err := db.GetDB().UpdateShowRepliesInHome(accountId, enabled)
+		if err != nil {
+			log.Printf("Failed to save reply setting: %v", err)
+		}
+		return showRepliesToggledMsg{enabled: enabled, err: err}
 	}
 }
 
@@ -409,20 +763,3 @@ func max(a, b int) int {
 	}
 	return b
 }
-
-func formatTime(t time.Time) string {
-	duration := time.Since(t)
-
-	if duration < time.Minute {
-		return "just now"
-	} else if duration < time.Hour {
-		mins := int(duration.Minutes())
-		return fmt.Sprintf("%dm ago", mins)
-	} else if duration < common.HoursPerDay*time.Hour {
-		hours := int(duration.Hours())
-		return fmt.Sprintf("%dh ago", hours)
-	} else {
-		days := int(duration.Hours() / common.HoursPerDay)
-		return fmt.Sprintf("%dd ago", days)
-	}
-}
@@ -8,6 +8,7 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/deemkeen/stegodon/domain"
 	"github.com/deemkeen/stegodon/ui/common"
+	"github.com/deemkeen/stegodon/util"
 	"github.com/google/uuid"
 )
 
@@ -141,6 +142,30 @@ func TestUpdate_RefreshTick_Inactive(t *testing.T) {
 	}
 }
 
+func TestUpdate_PostsLoaded_LiveNoTick(t *testing.T) {
+	m := InitialModel(uuid.New(), 120, 40, "")
+	m.isActive = true
+	m.Live = true
+
+	_, cmd := m.Update(postsLoadedMsg{posts: []domain.HomePost{{NoteID: uuid.New(), Author: "test", Content: "Test"}}})
+
+	if cmd != nil {
+		t.Error("Expected no tick command when updates are live")
+	}
+}
+
+func TestUpdate_LiveEvent(t *testing.T) {
+	m := InitialModel(uuid.New(), 120, 40, "")
+	m.Live = true
+
+	if _, cmd := m.Update(common.LiveEventMsg{Type: util.EventNewPost}); cmd == nil {
+		t.Error("Expected loadHomePosts command on a new post event")
+	}
+	if _, cmd := m.Update(common.LiveEventMsg{Type: util.EventFollow}); cmd != nil {
+		t.Error("Expected no command for events that don't change the timeline")
+	}
+}
+
 func TestUpdate_UpdateNoteList(t *testing.T) {
 	m := InitialModel(uuid.New(), 120, 40, "")
 
@@ -436,6 +461,23 @@ func TestView_EmptyPosts(t *testing.T) {
 	}
 }
 
+func TestView_LikedByMe(t *testing.T) {
+	m := InitialModel(uuid.New(), 120, 40, "")
+	m.Posts = []domain.HomePost{
+		{NoteID: uuid.New(), Author: "user1", Content: "Liked", Time: time.Now(), LikeCount: 2, LikedByMe: true},
+		{NoteID: uuid.New(), Author: "user2", Content: "Not liked", Time: time.Now(), LikeCount: 1},
+	}
+
+	view := m.View()
+
+	if !strings.Contains(view, "⭐ 2 (liked)") {
+		t.Error("Expected the viewer's like to be marked")
+	}
+	if strings.Count(view, "(liked)") != 1 {
+		t.Error("Expected only the liked post to be marked")
+	}
+}
+
 func TestView_PostCount(t *testing.T) {
 	m := InitialModel(uuid.New(), 120, 40, "")
 	m.Posts = []domain.HomePost{
@@ -521,7 +563,7 @@ func TestFormatTime(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := formatTime(tt.time)
+			result := common.FormatTime(tt.time, time.UTC)
 			if result != tt.expected {
 				t.Errorf("Expected '%s', got '%s'", tt.expected, result)
 			}
@@ -597,3 +639,213 @@ func TestUpdate_EmptyPosts_NoCrash(t *testing.T) {
 
 	// Should complete without panic
 }
+
+func TestUpdate_LanguageSettingsPrompt(t *testing.T) {
+	m := InitialModel(uuid.New(), 120, 40, "")
+
+	m, _ = m.Update(languageSettingsLoadedMsg{settings: domain.LanguageSettings{DefaultLanguage: "en", Languages: []string{"en", "de"}}})
+	if !m.EditingLanguages || m.LanguageStep != 0 {
+		t.Fatal("Expected language prompt to open at the default language step")
+	}
+	if m.DefaultLanguage.Value() != "en" || m.Languages.Value() != "en, de" {
+		t.Errorf("Expected prompt to be prefilled, got %q and %q", m.DefaultLanguage.Value(), m.Languages.Value())
+	}
+	if !strings.Contains(m.View(), "Default language") {
+		t.Error("Expected default language prompt in view")
+	}
+
+	// Keys go to the input instead of triggering timeline actions
+	m, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("r")})
+	if cmd != nil {
+		if _, ok := cmd().(common.ReplyToNoteMsg); ok {
+			t.Error("Expected 'r' to be typed into the prompt, not to reply")
+		}
+	}
+
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if m.LanguageStep != 1 {
+		t.Errorf("Expected enter to move to the languages step, got %d", m.LanguageStep)
+	}
+
+	m, cmd = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if m.EditingLanguages {
+		t.Error("Expected prompt to close on save")
+	}
+	if cmd == nil {
+		t.Error("Expected a save command")
+	}
+
+	m, _ = m.Update(languageSettingsSavedMsg{})
+	if m.Status != "Language settings saved" {
+		t.Errorf("Expected saved status, got %q", m.Status)
+	}
+}
+
+func TestUpdate_LanguageSettingsPromptCancel(t *testing.T) {
+	m := InitialModel(uuid.New(), 120, 40, "")
+	m, _ = m.Update(languageSettingsLoadedMsg{})
+
+	m, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if m.EditingLanguages {
+		t.Error("Expected esc to close the language prompt")
+	}
+	if cmd != nil {
+		t.Error("Expected no command when cancelling")
+	}
+}
+
+func TestUpdate_TimezonePrompt(t *testing.T) {
+	m := InitialModel(uuid.New(), 120, 40, "")
+
+	m, _ = m.Update(timezoneLoadedMsg{timezone: "Europe/Berlin"})
+	if !m.EditingTimezone || m.Timezone.Value() != "Europe/Berlin" {
+		t.Fatalf("Expected the timezone prompt to open prefilled, got %q", m.Timezone.Value())
+	}
+	if !strings.Contains(m.View(), "timezone") {
+		t.Error("Expected timezone prompt in view")
+	}
+
+	m, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if m.EditingTimezone || cmd == nil {
+		t.Error("Expected enter to close the prompt and save")
+	}
+
+	tokyo, _ := time.LoadLocation("Asia/Tokyo")
+	m, cmd = m.Update(timezoneSavedMsg{location: tokyo})
+	if m.Status != "Showing times in Asia/Tokyo" {
+		t.Errorf("Expected saved status, got %q", m.Status)
+	}
+	if cmd == nil {
+		t.Fatal("Expected the new timezone to be announced")
+	}
+	if msg, ok := cmd().(common.TimezoneChangedMsg); !ok || msg.Location != tokyo {
+		t.Errorf("Expected TimezoneChangedMsg for Asia/Tokyo, got %#v", cmd())
+	}
+}
+
+func TestFormatTime_OldPostsInTimezone(t *testing.T) {
+	tokyo, _ := time.LoadLocation("Asia/Tokyo")
+	old := time.Date(2020, 12, 31, 20, 0, 0, 0, time.UTC)
+
+	// 20:00 UTC on new year's eve is already the next year in Tokyo
+	if result := common.FormatTime(old, tokyo); result != "Jan 1 2021" {
+		t.Errorf("Expected 'Jan 1 2021', got '%s'", result)
+	}
+	if result := common.FormatTime(old, nil); result != "Dec 31 2020" {
+		t.Errorf("Expected 'Dec 31 2020', got '%s'", result)
+	}
+}
+
+func TestUpdate_ToggleShowReplies(t *testing.T) {
+	m := InitialModel(uuid.New(), 120, 40, "")
+	m, _ = m.Update(postsLoadedMsg{posts: []domain.HomePost{}, showReplies: true})
+	if !m.ShowReplies {
+		t.Fatal("Expected the reply setting to be taken from the loaded posts")
+	}
+
+	_, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'R'}})
+	if cmd == nil {
+		t.Fatal("Expected 'R' to return a toggle command")
+	}
+
+	m, cmd = m.Update(showRepliesToggledMsg{enabled: false})
+	if m.Status != "Hiding replies" {
+		t.Errorf("Expected hiding status, got %q", m.Status)
+	}
+	if cmd == nil {
+		t.Error("Expected the timeline to reload")
+	}
+}
+
+func TestUpdate_CopyPermalink(t *testing.T) {
+	noteId := uuid.New()
+	m := InitialModel(uuid.New(), 120, 40, "example.com")
+	m.Posts = []domain.HomePost{
+		{Author: "@bob@relay.example.net", Content: "via relay", ObjectURI: "https://remote.example.net/notes/1"},
+		{Author: "alice", Content: "local", IsLocal: true, NoteID: noteId},
+		{Author: "@carol@remote.example.net", Content: "no link"},
+	}
+	copyKey := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'c'}}
+	down := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'j'}}
+
+	// Remote and relay-forwarded posts are shared with their original object URI
+	m, _ = m.Update(copyKey)
+	if m.Copied != "https://remote.example.net/notes/1" {
+		t.Errorf("Expected the remote object URI to be copied, got %q", m.Copied)
+	}
+	if view := m.View(); !strings.Contains(view, util.FormatClipboardCopy(m.Copied)) || !strings.Contains(view, "Link copied: https://remote.example.net/notes/1") {
+		t.Error("Expected the view to show the link and copy it to the clipboard")
+	}
+
+	// Local notes without a stored object URI still get their note URL
+	m, _ = m.Update(down)
+	if m.Copied != "" || m.Status != "" {
+		t.Error("Expected the next key to clear the copied link")
+	}
+	m, _ = m.Update(copyKey)
+	if want := "https://example.com/notes/" + noteId.String(); m.Copied != want {
+		t.Errorf("Expected %s, got %q", want, m.Copied)
+	}
+
+	m, _ = m.Update(down)
+	m, _ = m.Update(copyKey)
+	if m.Copied != "" || m.Status != "This post has no link to share" {
+		t.Errorf("Expected no link for a post without object URI, got %q (%q)", m.Copied, m.Status)
+	}
+}
+
+func TestUpdate_ExpandSelfThread(t *testing.T) {
+	m := InitialModel(uuid.New(), 120, 40, "example.com")
+	m.Posts = []domain.HomePost{
+		{ID: uuid.New(), Author: "alice", Content: "Thread 1/3", IsLocal: true, SelfThread: []domain.HomePost{
+			{Author: "alice", Content: "Thread 2/3", IsLocal: true},
+			{Author: "alice", Content: "Thread 3/3", IsLocal: true},
+		}},
+		{ID: uuid.New(), Author: "bob", Content: "Single post", IsLocal: true},
+	}
+	expandKey := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'e'}}
+
+	// Collapsed threads only show how many posts follow
+	view := m.View()
+	if !strings.Contains(view, "2 more posts in this thread") || strings.Contains(view, "Thread 2/3") {
+		t.Error("Expected the thread to be collapsed")
+	}
+
+	m, _ = m.Update(expandKey)
+	view = m.View()
+	if !strings.Contains(view, "Thread 2/3") || !strings.Contains(view, "Thread 3/3") || strings.Contains(view, "more posts in this thread") {
+		t.Error("Expected the thread to be expanded")
+	}
+
+	m, _ = m.Update(expandKey)
+	if strings.Contains(m.View(), "Thread 2/3") {
+		t.Error("Expected the thread to collapse again")
+	}
+
+	// Posts that aren't threads can't be expanded
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'j'}})
+	m, _ = m.Update(expandKey)
+	if m.Expanded[m.Posts[1].ID] {
+		t.Error("Expected a single post not to be expanded")
+	}
+}
+
+func TestFormatQuote(t *testing.T) {
+	tests := []struct {
+		name     string
+		post     domain.HomePost
+		expected string
+	}{
+		{"no quote", domain.HomePost{Content: "hi"}, ""},
+		{"resolved quote", domain.HomePost{QuoteURI: "https://a.example/notes/1", QuoteAuthor: "@bob@a.example", QuoteContent: "first\nline"}, "quoting @bob@a.example: first line"},
+		{"unresolved quote", domain.HomePost{QuoteURI: "https://a.example/notes/1"}, "quoting https://a.example/notes/1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := formatQuote(tt.post); result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/deemkeen/stegodon/db"
 	"github.com/deemkeen/stegodon/domain"
 	"github.com/deemkeen/stegodon/ui/common"
+	"github.com/deemkeen/stegodon/util"
 	"github.com/google/uuid"
 	"log"
 )
@@ -99,7 +100,7 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 							log.Printf("Follow failed: %v", err)
 						} else {
 							// Create notification for the followed user
-							err, follower := database.ReadAccById(m.AccountId)
+							follower, err := database.ReadAccById(m.AccountId)
 							if err == nil && follower != nil {
 								notification := &domain.Notification{
 									Id:               uuid.New(),
@@ -115,6 +116,7 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 									log.Printf("Failed to create follow notification: %v", err)
 								}
 							}
+							util.GetEventBus().Publish(selectedUser.Id, util.EventFollow)
 						}
 					}
 				}()
@@ -243,7 +245,7 @@ func loadLocalUsers(accountId uuid.UUID) tea.Cmd {
 		database := db.GetDB()
 
 		// Load all local users
-		err, users := database.ReadAllAccounts()
+		users, err := database.ReadAllAccounts()
 		if err != nil {
 			log.Printf("Failed to load local users: %v", err)
 			return usersLoadedMsg{users: []domain.Account{}, following: make(map[uuid.UUID]bool)}
@@ -254,7 +256,7 @@ func loadLocalUsers(accountId uuid.UUID) tea.Cmd {
 		}
 
 		// Load local follows to see who we're following
-		err, follows := database.ReadLocalFollowsByAccountId(accountId)
+		follows, err := database.ReadLocalFollowsByAccountId(accountId)
 		following := make(map[uuid.UUID]bool)
 		if err == nil && follows != nil {
 			for _, follow := range *follows {
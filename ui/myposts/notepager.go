@@ -59,9 +59,10 @@ type Model struct {
 	Width            int
 	Height           int
 	userId           uuid.UUID
-	confirmingDelete bool      // True when showing delete confirmation
-	deleteTargetId   uuid.UUID // ID of note pending deletion
-	LocalDomain      string    // Cached local domain for mention highlighting
+	confirmingDelete bool           // True when showing delete confirmation
+	deleteTargetId   uuid.UUID      // ID of note pending deletion
+	LocalDomain      string         // Cached local domain for mention highlighting
+	Location         *time.Location // Timezone timestamps are shown in
 }
 
 func (m Model) Init() tea.Cmd {
@@ -195,7 +196,7 @@ func (m Model) View() string {
 			note := m.Notes[i]
 
 			// Format timestamp with edited indicator
-			timeStr := formatTime(note.CreatedAt)
+			timeStr := common.FormatTime(note.CreatedAt, m.Location)
 			if note.EditedAt != nil {
 				timeStr += " (edited)"
 			}
@@ -265,7 +266,7 @@ type notesLoadedMsg struct {
 func loadNotes(userId uuid.UUID) tea.Cmd {
 	return func() tea.Msg {
 		database := db.GetDB()
-		err, notes := database.ReadNotesByUserId(userId)
+		notes, err := database.ReadNotesByUserId(userId)
 		if err != nil {
 			log.Printf("Failed to load notes: %v", err)
 			return notesLoadedMsg{notes: []domain.Note{}}
@@ -285,9 +286,10 @@ func deleteNoteCmd(noteId uuid.UUID) tea.Cmd {
 		database := db.GetDB()
 
 		// Get note details before deletion for federation
-		err, note := database.ReadNoteId(noteId)
+		note, err := database.ReadNoteId(noteId)
 		var accountUsername string
-		if err == nil && note != nil {
+		if err == nil && note != nil && !note.IsLocalOnly() {
+			// Local-only notes were never federated, so there is nothing to delete remotely
 			accountUsername = note.CreatedBy
 		}
 
@@ -302,7 +304,7 @@ func deleteNoteCmd(noteId uuid.UUID) tea.Cmd {
 		if accountUsername != "" {
 			go func() {
 				// Get the account
-				err, account := database.ReadAccByUsername(accountUsername)
+				account, err := database.ReadAccByUsername(accountUsername)
 				if err != nil {
 					log.Printf("Failed to get account for delete federation: %v", err)
 					return
@@ -333,23 +335,6 @@ func deleteNoteCmd(noteId uuid.UUID) tea.Cmd {
 	}
 }
 
-func formatTime(t time.Time) string {
-	duration := time.Since(t)
-
-	if duration < time.Minute {
-		return "just now"
-	} else if duration < time.Hour {
-		mins := int(duration.Minutes())
-		return fmt.Sprintf("%dm ago", mins)
-	} else if duration < 24*time.Hour {
-		hours := int(duration.Hours())
-		return fmt.Sprintf("%dh ago", hours)
-	} else {
-		days := int(duration.Hours() / 24)
-		return fmt.Sprintf("%dd ago", days)
-	}
-}
-
 func truncate(s string, maxLen int) string {
 	if len(s) <= maxLen {
 		return s
@@ -11,12 +11,16 @@ import (
 	"github.com/deemkeen/stegodon/db"
 	"github.com/deemkeen/stegodon/domain"
 	"github.com/deemkeen/stegodon/ui/common"
+	"github.com/deemkeen/stegodon/util"
 	"github.com/google/uuid"
 )
 
 const (
 	notificationsLimit = 50
 	refreshInterval    = 30 * time.Second
+	// reconcileInterval is how often live sessions re-read the unread count, correcting
+	// the badge if an event was dropped
+	reconcileInterval = 5 * time.Minute
 )
 
 type Model struct {
@@ -28,6 +32,8 @@ type Model struct {
 	Height        int
 	isActive      bool
 	UnreadCount   int
+	Live          bool // Reload on live events instead of polling
+	reconciling   bool // Whether the reconcile tick chain of a live session was started
 }
 
 type notificationsLoadedMsg struct {
@@ -37,6 +43,14 @@ type notificationsLoadedMsg struct {
 
 type refreshTickMsg struct{}
 
+// reconcileTickMsg corrects the badge of a live session from the database
+type reconcileTickMsg struct{}
+
+// notificationsMarkedReadMsg carries the authoritative unread count after a mark-read write
+type notificationsMarkedReadMsg struct {
+	unreadCount int
+}
+
 func InitialModel(accountId uuid.UUID, width, height int) Model {
 	return Model{
 		AccountId:     accountId,
@@ -60,12 +74,26 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 		// Notifications model is always active for badge updates
 		// Just load data when view becomes focused
 		m.isActive = true
+		// Live sessions start a single slow reconcile chain in case an event was missed
+		if m.Live && !m.reconciling {
+			m.reconciling = true
+			return m, tea.Batch(loadNotifications(m.AccountId), tickReconcile())
+		}
 		return m, loadNotifications(m.AccountId)
 
 	case common.DeactivateViewMsg:
 		// Don't actually deactivate - keep refreshing for badge
 		// Just mark as not actively viewing
 		m.isActive = false
+		// Everything that was on screen has been seen - mark it read up to the newest
+		// loaded notification, leaving anything that arrived after the last load unread
+		if m.hasUnread() {
+			upTo := m.Notifications[0].CreatedAt
+			for i := range m.Notifications {
+				m.Notifications[i].Read = true
+			}
+			return m, markNotificationsReadUpTo(m.AccountId, upTo)
+		}
 		return m, nil
 
 	case notificationsLoadedMsg:
@@ -79,8 +107,38 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 			m.Selected = 0
 		}
 		// Schedule next tick to keep badge updated
+		if m.isActive {
+			var markCmd tea.Cmd
+			m, markCmd = m.markSelectedRead()
+			if m.Live {
+				return m, markCmd
+			}
+			return m, tea.Batch(markCmd, tickRefresh())
+		}
+		if m.Live {
+			return m, nil
+		}
 		return m, tickRefresh()
 
+	case notificationsMarkedReadMsg:
+		m.UnreadCount = msg.unreadCount
+		return m, nil
+
+	case common.LiveEventMsg:
+		// The event carries the new unread count, so the badge updates without a query;
+		// the list is only reloaded while it is on screen
+		if msg.Type != util.EventNotification {
+			return m, nil
+		}
+		m.UnreadCount = msg.UnreadCount
+		if m.isActive {
+			return m, loadNotifications(m.AccountId)
+		}
+		return m, nil
+
+	case reconcileTickMsg:
+		return m, tea.Batch(loadNotifications(m.AccountId), tickReconcile())
+
 	case refreshTickMsg:
 		// Always refresh to keep badge count updated
 		return m, loadNotifications(m.AccountId)
@@ -94,6 +152,7 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 				if m.Selected < m.Offset {
 					m.Offset = m.Selected
 				}
+				return m.markSelectedRead()
 			}
 		case "down", "j":
 			if m.Selected < len(m.Notifications)-1 {
@@ -103,6 +162,7 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 				if m.Selected >= m.Offset+itemsPerPage {
 					m.Offset = m.Selected - itemsPerPage + 1
 				}
+				return m.markSelectedRead()
 			}
 		case "enter":
 			// Delete notification (mark as read by removing it)
@@ -118,6 +178,28 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 	return m, nil
 }
 
+// markSelectedRead marks the currently selected notification as read once it has been viewed
+func (m Model) markSelectedRead() (Model, tea.Cmd) {
+	if m.Selected < 0 || m.Selected >= len(m.Notifications) || m.Notifications[m.Selected].Read {
+		return m, nil
+	}
+	m.Notifications[m.Selected].Read = true
+	if m.UnreadCount > 0 {
+		m.UnreadCount--
+	}
+	return m, markNotificationRead(m.AccountId, m.Notifications[m.Selected].Id)
+}
+
+// hasUnread returns true if any loaded notification is still unread
+func (m Model) hasUnread() bool {
+	for _, n := range m.Notifications {
+		if !n.Read {
+			return true
+		}
+	}
+	return false
+}
+
 func (m Model) View() string {
 	var s strings.Builder
 
@@ -194,7 +276,7 @@ func (m Model) View() string {
 func loadNotifications(accountId uuid.UUID) tea.Cmd {
 	return func() tea.Msg {
 		database := db.GetDB()
-		err, notifications := database.ReadNotificationsByAccountId(accountId, notificationsLimit)
+		notifications, err := database.ReadNotificationsByAccountId(accountId, notificationsLimit)
 		if err != nil {
 			log.Printf("Failed to load notifications: %v", err)
 			return notificationsLoadedMsg{notifications: []domain.Notification{}, unreadCount: 0}
@@ -214,6 +296,30 @@ func loadNotifications(accountId uuid.UUID) tea.Cmd {
 	}
 }
 
+// markNotificationRead marks a single notification as read
+func markNotificationRead(accountId, notificationId uuid.UUID) tea.Cmd {
+	return func() tea.Msg {
+		unreadCount, err := db.GetDB().MarkNotificationReadForAccount(accountId, notificationId)
+		if err != nil {
+			log.Printf("Failed to mark notification read: %v", err)
+			return nil
+		}
+		return notificationsMarkedReadMsg{unreadCount: unreadCount}
+	}
+}
+
+// markNotificationsReadUpTo marks every notification up to the given time as read
+func markNotificationsReadUpTo(accountId uuid.UUID, upTo time.Time) tea.Cmd {
+	return func() tea.Msg {
+		unreadCount, err := db.GetDB().MarkNotificationsReadUpTo(accountId, upTo)
+		if err != nil {
+			log.Printf("Failed to mark notifications read: %v", err)
+			return nil
+		}
+		return notificationsMarkedReadMsg{unreadCount: unreadCount}
+	}
+}
+
 // deleteNotification deletes a single notification
 func deleteNotification(notificationId uuid.UUID, accountId uuid.UUID) tea.Cmd {
 	return func() tea.Msg {
@@ -245,6 +351,13 @@ func tickRefresh() tea.Cmd {
 	})
 }
 
+// tickReconcile returns a command that triggers a reconcile after reconcileInterval
+func tickReconcile() tea.Cmd {
+	return tea.Tick(reconcileInterval, func(t time.Time) tea.Msg {
+		return reconcileTickMsg{}
+	})
+}
+
 // formatTimeAgo formats a time as a relative string (e.g., "2h ago")
 func formatTimeAgo(t time.Time) string {
 	duration := time.Since(t)
@@ -7,6 +7,7 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/deemkeen/stegodon/domain"
 	"github.com/deemkeen/stegodon/ui/common"
+	"github.com/deemkeen/stegodon/util"
 	"github.com/google/uuid"
 )
 
@@ -221,3 +222,107 @@ func TestView_WithNotifications(t *testing.T) {
 		t.Errorf("View should not be empty with notifications")
 	}
 }
+
+func TestUpdate_NavigationMarksSelectedRead(t *testing.T) {
+	model := InitialModel(uuid.New(), 100, 40)
+	model.Notifications = []domain.Notification{
+		{Id: uuid.New(), ActorUsername: "user1", Read: true},
+		{Id: uuid.New(), ActorUsername: "user2", Read: false},
+	}
+	model.UnreadCount = 1
+
+	newModel, cmd := model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'j'}})
+	if !newModel.Notifications[1].Read {
+		t.Errorf("Expected selected notification to be marked read")
+	}
+	if newModel.UnreadCount != 0 {
+		t.Errorf("Expected unread count 0, got %d", newModel.UnreadCount)
+	}
+	if cmd == nil {
+		t.Errorf("Expected cmd to persist read state")
+	}
+
+	// Moving back onto an already-read notification does nothing
+	_, cmd = newModel.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'k'}})
+	if cmd != nil {
+		t.Errorf("Expected no cmd when selecting an already-read notification")
+	}
+}
+
+func TestUpdate_DeactivateMarksLoadedRead(t *testing.T) {
+	model := InitialModel(uuid.New(), 100, 40)
+	model.isActive = true
+	model.Notifications = []domain.Notification{
+		{Id: uuid.New(), ActorUsername: "user1", CreatedAt: time.Now()},
+		{Id: uuid.New(), ActorUsername: "user2", CreatedAt: time.Now().Add(-time.Hour)},
+	}
+
+	newModel, cmd := model.Update(common.DeactivateViewMsg{})
+	if cmd == nil {
+		t.Errorf("Expected cmd to mark notifications read up to newest")
+	}
+	for i, n := range newModel.Notifications {
+		if !n.Read {
+			t.Errorf("Expected notification %d to be marked read", i)
+		}
+	}
+}
+
+func TestUpdate_NotificationsMarkedReadMsg(t *testing.T) {
+	model := InitialModel(uuid.New(), 100, 40)
+	model.UnreadCount = 5
+
+	newModel, cmd := model.Update(notificationsMarkedReadMsg{unreadCount: 2})
+	if newModel.UnreadCount != 2 {
+		t.Errorf("Expected unread count 2, got %d", newModel.UnreadCount)
+	}
+	if cmd != nil {
+		t.Errorf("Expected no cmd after mark-read result")
+	}
+}
+
+func TestUpdate_LiveNotificationEvent(t *testing.T) {
+	model := InitialModel(uuid.New(), 100, 40)
+	model.Live = true
+	model.UnreadCount = 1
+
+	// The badge takes the count from the event without reloading
+	newModel, cmd := model.Update(common.LiveEventMsg{Type: util.EventNotification, UnreadCount: 4})
+	if newModel.UnreadCount != 4 {
+		t.Errorf("Expected UnreadCount 4, got %d", newModel.UnreadCount)
+	}
+	if cmd != nil {
+		t.Error("Expected no reload while the list isn't shown")
+	}
+
+	// While the list is shown it is reloaded to show the new notification
+	newModel.isActive = true
+	if _, cmd := newModel.Update(common.LiveEventMsg{Type: util.EventNotification, UnreadCount: 5}); cmd == nil {
+		t.Error("Expected a reload while the list is shown")
+	}
+
+	// Other events don't concern notifications
+	if _, cmd := newModel.Update(common.LiveEventMsg{Type: util.EventNewPost}); cmd != nil {
+		t.Error("Expected no command for a new post event")
+	}
+}
+
+func TestUpdate_LiveReconcile(t *testing.T) {
+	model := InitialModel(uuid.New(), 100, 40)
+	model.Live = true
+
+	newModel, _ := model.Update(common.ActivateViewMsg{})
+	if !newModel.reconciling {
+		t.Fatal("Expected the first activation to start the reconcile chain")
+	}
+
+	// A loaded list doesn't schedule polling in a live session
+	newModel.isActive = false
+	if _, cmd := newModel.Update(notificationsLoadedMsg{}); cmd != nil {
+		t.Error("Expected no refresh tick in a live session")
+	}
+
+	if _, cmd := newModel.Update(reconcileTickMsg{}); cmd == nil {
+		t.Error("Expected the reconcile tick to reload and schedule the next one")
+	}
+}
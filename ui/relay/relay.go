@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
@@ -20,6 +21,7 @@ type Model struct {
 	AdminAcct *domain.Account
 	Config    *util.AppConfig
 	Relays    []domain.Relay
+	Health    map[uuid.UUID]domain.RelayHealth // Last activity and inbound rate per relay
 	Selected  int
 	Offset    int // Pagination offset
 	Width     int
@@ -28,19 +30,45 @@ type Model struct {
 	Error     string
 	Input     textinput.Model // For entering relay URL
 	Adding    bool            // Input mode for adding relay
+
+	// Filter prompt for the selected relay's forwarded posts
+	EditingFilter bool
+	FilterStep    int                // Index into FilterInputs of the focused input
+	FilterInputs  [3]textinput.Model // Blocked domains, muted words, required hashtags
+
+	// Quiet hours prompt for the selected relay
+	EditingSchedule bool
+	ScheduleInput   textinput.Model
 }
 
+// filterLabels name the filter inputs in the prompt
+var filterLabels = [3]string{"Blocked author domains", "Muted words", "Required hashtags"}
+
 func InitialModel(adminId uuid.UUID, adminAcct *domain.Account, config *util.AppConfig, width, height int) Model {
 	ti := textinput.New()
 	ti.Placeholder = "relay.example.com or https://relay.example.com/actor"
 	ti.CharLimit = 256
 	ti.Width = 60
 
+	var filterInputs [3]textinput.Model
+	for i, placeholder := range []string{"spam.example, bad.example", "crypto, giveaway", "music, prints (empty allows all)"} {
+		filterInputs[i] = textinput.New()
+		filterInputs[i].Placeholder = placeholder
+		filterInputs[i].CharLimit = 512
+		filterInputs[i].Width = 60
+	}
+
+	scheduleInput := textinput.New()
+	scheduleInput.Placeholder = "22:00-06:00 Europe/Berlin (empty removes the schedule)"
+	scheduleInput.CharLimit = 64
+	scheduleInput.Width = 60
+
 	return Model{
 		AdminId:   adminId,
 		AdminAcct: adminAcct,
 		Config:    config,
 		Relays:    []domain.Relay{},
+		Health:    map[uuid.UUID]domain.RelayHealth{},
 		Selected:  0,
 		Offset:    0,
 		Width:     width,
@@ -49,6 +77,9 @@ func InitialModel(adminId uuid.UUID, adminAcct *domain.Account, config *util.App
 		Error:     "",
 		Input:     ti,
 		Adding:    false,
+
+		FilterInputs:  filterInputs,
+		ScheduleInput: scheduleInput,
 	}
 }
 
@@ -60,6 +91,7 @@ func (m Model) Init() tea.Cmd {
 
 type relaysLoadedMsg struct {
 	relays []domain.Relay
+	health []domain.RelayHealth
 }
 
 type relayAddedMsg struct {
@@ -81,6 +113,15 @@ type relayPausedMsg struct {
 	err    error
 }
 
+type relayFilterSavedMsg struct {
+	err error
+}
+
+type relayScheduleSavedMsg struct {
+	schedule string
+	err      error
+}
+
 type relayContentDeletedMsg struct {
 	count int64
 	err   error
@@ -91,7 +132,7 @@ type relayContentDeletedMsg struct {
 func loadRelays() tea.Cmd {
 	return func() tea.Msg {
 		database := db.GetDB()
-		err, relays := database.ReadAllRelays()
+		relays, err := database.ReadAllRelays()
 		if err != nil {
 			log.Printf("Relay panel: Failed to load relays: %v", err)
 			return relaysLoadedMsg{relays: []domain.Relay{}}
@@ -101,7 +142,14 @@ func loadRelays() tea.Cmd {
 			return relaysLoadedMsg{relays: []domain.Relay{}}
 		}
 		log.Printf("Relay panel: Loaded %d relays", len(*relays))
-		return relaysLoadedMsg{relays: *relays}
+
+		// Health is only used for the stale badge and rate, so the list still loads without it
+		health, err := database.ReadRelayHealth()
+		if err != nil || health == nil {
+			log.Printf("Relay panel: Failed to load relay health: %v", err)
+			return relaysLoadedMsg{relays: *relays}
+		}
+		return relaysLoadedMsg{relays: *relays, health: *health}
 	}
 }
 
@@ -110,7 +158,7 @@ func subscribeToRelay(adminAcct *domain.Account, relayURL string, config *util.A
 		// Normalize relay URL to actor URI
 		actorURI := normalizeRelayURL(relayURL)
 
-		err := activitypub.SendRelayFollow(adminAcct, actorURI, config)
+		err := activitypub.SubscribeToRelay(adminAcct, actorURI, activitypub.DetectRelayType(actorURI), config)
 		if err != nil {
 			log.Printf("Relay panel: Failed to subscribe to relay %s: %v", actorURI, err)
 			return relayAddedMsg{err: err}
@@ -146,7 +194,11 @@ func retryRelay(adminAcct *domain.Account, relay *domain.Relay, config *util.App
 		database := db.GetDB()
 		database.DeleteRelay(relay.Id)
 
-		err := activitypub.SendRelayFollow(adminAcct, relay.ActorURI, config)
+		relayType := relay.Type
+		if relayType == "" {
+			relayType = activitypub.DetectRelayType(relay.ActorURI)
+		}
+		err := activitypub.SubscribeToRelay(adminAcct, relay.ActorURI, relayType, config)
 		if err != nil {
 			log.Printf("Relay panel: Failed to retry relay %s: %v", relay.ActorURI, err)
 			return relayRetryMsg{err: err}
@@ -169,6 +221,26 @@ func toggleRelayPause(relayId uuid.UUID, paused bool) tea.Cmd {
 	}
 }
 
+func saveRelayFilter(relayId uuid.UUID, filter domain.RelayFilter) tea.Cmd {
+	return func() tea.Msg {
+		err := db.GetDB().UpdateRelayFilter(relayId, filter)
+		if err != nil {
+			log.Printf("Relay panel: Failed to update relay filter: %v", err)
+		}
+		return relayFilterSavedMsg{err: err}
+	}
+}
+
+func saveRelaySchedule(relayId uuid.UUID, schedule string) tea.Cmd {
+	return func() tea.Msg {
+		err := db.GetDB().UpdateRelayPauseSchedule(relayId, schedule)
+		if err != nil {
+			log.Printf("Relay panel: Failed to update relay pause schedule: %v", err)
+		}
+		return relayScheduleSavedMsg{schedule: schedule, err: err}
+	}
+}
+
 func deleteRelayContent() tea.Cmd {
 	return func() tea.Msg {
 		database := db.GetDB()
@@ -203,6 +275,10 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 	case relaysLoadedMsg:
 		log.Printf("Relay panel: Received relaysLoadedMsg with %d relays", len(msg.relays))
 		m.Relays = msg.relays
+		m.Health = make(map[uuid.UUID]domain.RelayHealth, len(msg.health))
+		for _, h := range msg.health {
+			m.Health[h.RelayId] = h
+		}
 		m.Selected = 0
 		m.Offset = 0
 		if m.Selected >= len(m.Relays) {
@@ -257,6 +333,30 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 		}
 		return m, loadRelays()
 
+	case relayFilterSavedMsg:
+		if msg.err != nil {
+			m.Error = msg.err.Error()
+			m.Status = ""
+		} else {
+			m.Status = "Relay filter saved"
+			m.Error = ""
+		}
+		return m, loadRelays()
+
+	case relayScheduleSavedMsg:
+		if msg.err != nil {
+			m.Error = msg.err.Error()
+			m.Status = ""
+		} else {
+			if msg.schedule == "" {
+				m.Status = "Quiet hours removed"
+			} else {
+				m.Status = "Relay pauses daily " + msg.schedule
+			}
+			m.Error = ""
+		}
+		return m, loadRelays()
+
 	case relayContentDeletedMsg:
 		if msg.err != nil {
 			m.Error = msg.err.Error()
@@ -292,6 +392,66 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 			}
 		}
 
+		// In the filter prompt, keys go to the focused input
+		if m.EditingFilter {
+			switch msg.String() {
+			case "esc":
+				m.EditingFilter = false
+				m.FilterInputs[m.FilterStep].Blur()
+				return m, nil
+			case "enter":
+				m.FilterInputs[m.FilterStep].Blur()
+				if m.FilterStep < len(m.FilterInputs)-1 {
+					m.FilterStep++
+					m.FilterInputs[m.FilterStep].Focus()
+					return m, nil
+				}
+				m.EditingFilter = false
+				if m.Selected >= len(m.Relays) {
+					return m, nil
+				}
+				m.Status = "Saving relay filter..."
+				return m, saveRelayFilter(m.Relays[m.Selected].Id, domain.RelayFilter{
+					BlockedDomains: strings.Split(m.FilterInputs[0].Value(), ","),
+					MutedWords:     strings.Split(m.FilterInputs[1].Value(), ","),
+					RequiredTags:   strings.Split(m.FilterInputs[2].Value(), ","),
+				})
+			}
+			m.FilterInputs[m.FilterStep], cmd = m.FilterInputs[m.FilterStep].Update(msg)
+			return m, cmd
+		}
+
+		// In the quiet hours prompt, keys go to the input
+		if m.EditingSchedule {
+			switch msg.String() {
+			case "esc":
+				m.EditingSchedule = false
+				m.ScheduleInput.Blur()
+				m.Error = ""
+				return m, nil
+			case "enter":
+				schedule, err := activitypub.ParsePauseSchedule(m.ScheduleInput.Value())
+				if err != nil {
+					m.Error = err.Error()
+					return m, nil
+				}
+				m.EditingSchedule = false
+				m.ScheduleInput.Blur()
+				m.Error = ""
+				if m.Selected >= len(m.Relays) {
+					return m, nil
+				}
+				value := ""
+				if schedule != nil {
+					value = schedule.String()
+				}
+				m.Status = "Saving quiet hours..."
+				return m, saveRelaySchedule(m.Relays[m.Selected].Id, value)
+			}
+			m.ScheduleInput, cmd = m.ScheduleInput.Update(msg)
+			return m, cmd
+		}
+
 		// Normal mode
 		m.Status = ""
 		m.Error = ""
@@ -352,6 +512,26 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 					m.Error = "Only active relays can be paused/resumed"
 				}
 			}
+		case "f":
+			// Edit the filter for the selected relay's forwarded posts
+			if len(m.Relays) > 0 && m.Selected < len(m.Relays) {
+				filter := m.Relays[m.Selected].Filter
+				for i, list := range [][]string{filter.BlockedDomains, filter.MutedWords, filter.RequiredTags} {
+					m.FilterInputs[i].SetValue(strings.Join(list, ", "))
+				}
+				m.EditingFilter = true
+				m.FilterStep = 0
+				m.FilterInputs[0].Focus()
+				return m, textinput.Blink
+			}
+		case "s":
+			// Set the daily quiet hours the selected relay is paused in
+			if len(m.Relays) > 0 && m.Selected < len(m.Relays) {
+				m.ScheduleInput.SetValue(m.Relays[m.Selected].PauseSchedule)
+				m.EditingSchedule = true
+				m.ScheduleInput.Focus()
+				return m, textinput.Blink
+			}
 		case "x":
 			// Delete all relay content from timeline
 			m.Status = "Deleting relay content..."
@@ -384,6 +564,31 @@ func (m Model) View() string {
 		return s.String()
 	}
 
+	// Filter prompt for the selected relay
+	if m.EditingFilter && m.Selected < len(m.Relays) {
+		s.WriteString(fmt.Sprintf("Filter for posts forwarded by %s (comma-separated):\n\n", m.Relays[m.Selected].ActorURI))
+		for i, input := range m.FilterInputs {
+			s.WriteString(filterLabels[i] + ":\n")
+			s.WriteString(input.View())
+			s.WriteString("\n\n")
+		}
+		s.WriteString(common.HelpStyle.Render("enter: next/save | esc: cancel"))
+		return s.String()
+	}
+
+	// Quiet hours prompt for the selected relay
+	if m.EditingSchedule && m.Selected < len(m.Relays) {
+		s.WriteString(fmt.Sprintf("Daily quiet hours %s is paused in (HH:MM-HH:MM, optional timezone, UTC by default):\n", m.Relays[m.Selected].ActorURI))
+		s.WriteString(m.ScheduleInput.View())
+		s.WriteString("\n\n")
+		if m.Error != "" {
+			s.WriteString(common.ListErrorStyle.Render("Error: " + m.Error))
+			s.WriteString("\n\n")
+		}
+		s.WriteString(common.HelpStyle.Render("enter: save | esc: cancel"))
+		return s.String()
+	}
+
 	if len(m.Relays) == 0 {
 		s.WriteString(common.ListEmptyStyle.Render("No relays configured."))
 		s.WriteString("\n\n")
@@ -412,15 +617,35 @@ func (m Model) View() string {
 					statusBadge = common.ListBadgeMutedStyle.Render("[paused]")
 				} else {
 					statusBadge = common.ListBadgeStyle.Render("[active]")
+					if health, ok := m.Health[relay.Id]; ok {
+						if health.IsStale(activitypub.RelayStaleWindow(m.Config), time.Now()) {
+							statusBadge += " " + common.ListErrorStyle.Render("[stale]")
+						}
+						statusBadge += " " + common.ListBadgeMutedStyle.Render(fmt.Sprintf("~%.1f/h", health.PerHour))
+					}
 				}
 			case "pending":
-				statusBadge = common.ListBadgeMutedStyle.Render("[pending]")
+				// Show how many Follows were sent while waiting for the relay's Accept
+				statusBadge = common.ListBadgeMutedStyle.Render(fmt.Sprintf("[pending %d/%d]", max(relay.FollowAttempts, 1), activitypub.MaxRelayFollowAttempts))
 			case "failed":
 				statusBadge = common.ListErrorStyle.Render("[failed]")
 			default:
 				statusBadge = common.ListBadgeMutedStyle.Render("[" + relay.Status + "]")
 			}
 
+			if relay.PauseSchedule != "" {
+				statusBadge += " " + common.ListBadgeMutedStyle.Render("[quiet "+relay.PauseSchedule+"]")
+			}
+
+			if !relay.Filter.IsEmpty() {
+				statusBadge += " " + common.ListBadgeMutedStyle.Render("[filtered]")
+			}
+
+			// Content from relays the config doesn't trust is dropped even while subscribed
+			if m.Config != nil && !m.Config.IsTrustedRelay(relay.ActorURI) {
+				statusBadge += " " + common.ListErrorStyle.Render("[untrusted]")
+			}
+
 			if i == m.Selected {
 				// Selected item with arrow prefix
 				text := common.ListItemSelectedStyle.Render(displayName + " " + statusBadge)
@@ -455,7 +680,7 @@ func (m Model) View() string {
 
 	// Footer with available keys
 	s.WriteString("\n")
-	s.WriteString(common.HelpStyle.Render("keys: a add | d delete | p pause/resume | r retry | x clear content"))
+	s.WriteString(common.HelpStyle.Render("keys: a add | d delete | p pause/resume | r retry | f filter | s quiet hours | x clear content"))
 
 	return s.String()
 }
@@ -0,0 +1,430 @@
+package settings
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/deemkeen/stegodon/db"
+	"github.com/deemkeen/stegodon/domain"
+	"github.com/deemkeen/stegodon/ui/common"
+	"github.com/deemkeen/stegodon/util"
+	"github.com/deemkeen/stegodon/web"
+	"github.com/google/uuid"
+)
+
+var (
+	sectionStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color(common.COLOR_USERNAME)).
+			Bold(true)
+
+	emptyStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color(common.COLOR_DIM)).
+			Italic(true)
+)
+
+// Model manages the account's security: open SSH sessions, HTTP API tokens, SSH keys and
+// the export of the account's data
+type Model struct {
+	userId       uuid.UUID
+	Status       string              // Result of the last action
+	Sessions     []util.Session      // Open SSH sessions of the user
+	Tokens       []domain.APIToken   // API tokens of the user
+	Keys         []domain.AccountKey // SSH keys the user can log in with, primary first
+	Selected     int                 // Index into Sessions, then Tokens, then Keys
+	SessionId    uuid.UUID           // SSH session this view is shown in
+	issuingToken bool                // True when choosing the scopes of a new API token
+	tokenScopes  []string            // Scopes selected for the new API token
+	addingKey    bool                // True when pasting a public key to add
+	keyInput     textinput.Model     // Public key being added, in authorized_keys format
+	Location     *time.Location      // Timezone timestamps are shown in
+}
+
+func InitialModel(userId uuid.UUID) Model {
+	return Model{
+		userId: userId,
+	}
+}
+
+func (m Model) Init() tea.Cmd {
+	return loadSessionsCmd(m.userId)
+}
+
+func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case sessionsLoadedMsg:
+		if msg.err != nil {
+			m.Status = "Loading tokens failed: " + msg.err.Error()
+		}
+		m.Sessions = msg.sessions
+		m.Tokens = msg.tokens
+		m.Keys = msg.keys
+		m.Selected = max(0, min(m.Selected, m.items()-1))
+		return m, nil
+
+	case archiveExportedMsg:
+		if msg.err != nil {
+			m.Status = "Export failed: " + msg.err.Error()
+		} else {
+			m.Status = "Exported to " + msg.path
+		}
+		return m, nil
+
+	case apiTokenIssuedMsg:
+		if msg.err != nil {
+			m.Status = "Token creation failed: " + msg.err.Error()
+		} else {
+			m.Status = fmt.Sprintf("API token with %s scopes (shown only once): %s", strings.Join(msg.scopes, ", "), msg.token)
+		}
+		return m, loadSessionsCmd(m.userId)
+
+	case apiTokensRevokedMsg:
+		if msg.err != nil {
+			m.Status = "Revoking tokens failed: " + msg.err.Error()
+		} else {
+			m.Status = "All API tokens revoked"
+		}
+		return m, loadSessionsCmd(m.userId)
+
+	case keyAddedMsg:
+		if msg.err != nil {
+			m.Status = "Adding key failed: " + msg.err.Error()
+		} else {
+			m.Status = "Key added"
+		}
+		return m, loadSessionsCmd(m.userId)
+
+	case revokedMsg:
+		if msg.err != nil {
+			m.Status = "Revoking failed: " + msg.err.Error()
+		} else {
+			m.Status = msg.what + " revoked"
+		}
+		return m, loadSessionsCmd(m.userId)
+
+	case tea.KeyMsg:
+		// If choosing token scopes, only handle scope toggles, issue, revoke and cancel
+		if m.issuingToken {
+			switch msg.String() {
+			case "r":
+				m.tokenScopes = toggleScope(m.tokenScopes, domain.ScopeRead)
+			case "w":
+				m.tokenScopes = toggleScope(m.tokenScopes, domain.ScopeWrite)
+			case "f":
+				m.tokenScopes = toggleScope(m.tokenScopes, domain.ScopeFollow)
+			case "enter":
+				if len(m.tokenScopes) > 0 {
+					m.issuingToken = false
+					return m, issueAPITokenCmd(m.userId, m.tokenScopes)
+				}
+			case "R":
+				m.issuingToken = false
+				return m, revokeAPITokensCmd(m.userId)
+			case "esc":
+				m.issuingToken = false
+			}
+			return m, nil
+		}
+
+		// If pasting a key, the input gets all keys but enter and escape
+		if m.addingKey {
+			switch msg.String() {
+			case "enter":
+				m.addingKey = false
+				return m, addKeyCmd(m.userId, m.keyInput.Value())
+			case "esc":
+				m.addingKey = false
+				return m, nil
+			}
+			var cmd tea.Cmd
+			m.keyInput, cmd = m.keyInput.Update(msg)
+			return m, cmd
+		}
+
+		switch msg.String() {
+		case "up", "k":
+			m.Selected = max(m.Selected-1, 0)
+		case "down", "j":
+			m.Selected = max(0, min(m.Selected+1, m.items()-1))
+		case "x":
+			// Revoke the selected session or token, or remove the selected key
+			if m.Selected < len(m.Sessions) {
+				return m, revokeSessionCmd(m.userId, m.Sessions[m.Selected].Id)
+			}
+			if i := m.Selected - len(m.Sessions); i < len(m.Tokens) {
+				return m, revokeAPITokenCmd(m.userId, m.Tokens[i].Id)
+			}
+			if i := m.Selected - len(m.Sessions) - len(m.Tokens); i < len(m.Keys) {
+				return m, removeKeyCmd(m.userId, m.Keys[i].Id)
+			}
+		case "a":
+			// Paste the public key of another device, e.g. the contents of ~/.ssh/id_ed25519.pub
+			m.addingKey = true
+			m.keyInput = textinput.New()
+			m.keyInput.Placeholder = "ssh-ed25519 AAAA... laptop"
+			m.keyInput.Prompt = common.ListSelectedPrefix
+			m.keyInput.CharLimit = 2000
+			m.keyInput.Width = 60
+			m.keyInput.Focus()
+			m.Status = ""
+			return m, textinput.Blink
+		case "t":
+			// Choose the scopes of a new HTTP API token (read-only by default)
+			m.issuingToken = true
+			m.tokenScopes = []string{domain.ScopeRead}
+			m.Status = ""
+		case "e":
+			// Export all of the user's data as a zip archive
+			m.Status = "Exporting..."
+			return m, exportArchiveCmd(m.userId)
+		}
+	}
+	return m, nil
+}
+
+func (m Model) View() string {
+	var s strings.Builder
+
+	s.WriteString(common.CaptionStyle.Render("settings"))
+	s.WriteString("\n\n")
+
+	if m.Status != "" {
+		s.WriteString(common.ListStatusStyle.Render(m.Status))
+		s.WriteString("\n\n")
+	}
+
+	if m.issuingToken {
+		s.WriteString(common.ListStatusStyle.Render(fmt.Sprintf("New API token scopes: %s", strings.Join(m.tokenScopes, ", "))))
+		s.WriteString("\n")
+		s.WriteString(emptyStyle.Render("r: read • w: write • f: follow • enter: issue • R: revoke all tokens • esc: cancel"))
+		s.WriteString("\n\n")
+	}
+
+	line := func(i int, text string) {
+		if i == m.Selected {
+			s.WriteString(common.ListItemSelectedStyle.Render(common.ListSelectedPrefix + text))
+		} else {
+			s.WriteString(common.ListItemStyle.Render(common.ListUnselectedPrefix + text))
+		}
+		s.WriteString("\n")
+	}
+
+	s.WriteString(sectionStyle.Render(fmt.Sprintf("SSH sessions (%d)", len(m.Sessions))))
+	s.WriteString("\n")
+	for i, session := range m.Sessions {
+		text := fmt.Sprintf("%s • %s • connected %s", session.RemoteAddr, session.Client, common.FormatTime(session.ConnectedAt, m.Location))
+		if session.Id == m.SessionId {
+			// Revoking it closes this very connection
+			text += " • this session"
+		}
+		line(i, text)
+	}
+	s.WriteString("\n")
+
+	s.WriteString(sectionStyle.Render(fmt.Sprintf("API tokens (%d)", len(m.Tokens))))
+	s.WriteString("\n")
+	if len(m.Tokens) == 0 {
+		s.WriteString(emptyStyle.Render("No API tokens."))
+		s.WriteString("\n")
+	}
+	for i, token := range m.Tokens {
+		line(len(m.Sessions)+i, fmt.Sprintf("%s • created %s", strings.Join(token.Scopes, ", "), common.FormatTime(token.CreatedAt, m.Location)))
+	}
+	s.WriteString("\n")
+
+	s.WriteString(sectionStyle.Render(fmt.Sprintf("SSH keys (%d)", len(m.Keys))))
+	s.WriteString("\n")
+	for i, key := range m.Keys {
+		text := fmt.Sprintf("%s • %s • added %s", key.Label, truncate(key.PkHash, 15), common.FormatTime(key.AddedAt, m.Location))
+		if key.Primary {
+			text += " • can't be removed"
+		}
+		line(len(m.Sessions)+len(m.Tokens)+i, text)
+	}
+
+	if m.addingKey {
+		s.WriteString("\n")
+		s.WriteString("Paste a public key in authorized_keys format:\n")
+		s.WriteString(m.keyInput.View())
+		s.WriteString("\n\n")
+		s.WriteString(emptyStyle.Render("enter: add • esc: cancel"))
+	}
+	return s.String()
+}
+
+// items returns the number of selectable sessions, tokens and keys
+func (m Model) items() int {
+	return len(m.Sessions) + len(m.Tokens) + len(m.Keys)
+}
+
+// sessionsLoadedMsg is sent when the user's sessions, API tokens and SSH keys have been loaded
+type sessionsLoadedMsg struct {
+	sessions []util.Session
+	tokens   []domain.APIToken
+	keys     []domain.AccountKey
+	err      error
+}
+
+// archiveExportedMsg is sent when the account archive export completes
+type archiveExportedMsg struct {
+	path string
+	err  error
+}
+
+// apiTokenIssuedMsg is sent when a new HTTP API token has been issued
+type apiTokenIssuedMsg struct {
+	token  string
+	scopes []string
+	err    error
+}
+
+// apiTokensRevokedMsg is sent when all of the user's HTTP API tokens have been revoked
+type apiTokensRevokedMsg struct {
+	err error
+}
+
+// keyAddedMsg is sent when an SSH key has been added
+type keyAddedMsg struct {
+	err error
+}
+
+// revokedMsg is sent when a session or an API token has been revoked, or an SSH key removed
+type revokedMsg struct {
+	what string
+	err  error
+}
+
+// loadSessionsCmd loads the user's open SSH sessions, API tokens and SSH keys
+func loadSessionsCmd(userId uuid.UUID) tea.Cmd {
+	return func() tea.Msg {
+		database := db.GetDB()
+		msg := sessionsLoadedMsg{sessions: util.GetSessionRegistry().ListSessions(userId)}
+		tokens, err := database.ReadAPITokensByAccountId(userId)
+		if err != nil {
+			log.Printf("Failed to load API tokens: %v", err)
+			msg.err = err
+		} else if tokens != nil {
+			msg.tokens = *tokens
+		}
+		keys, err := database.ListKeys(userId)
+		if err != nil {
+			log.Printf("Failed to load SSH keys: %v", err)
+			msg.err = err
+		}
+		msg.keys = keys
+		return msg
+	}
+}
+
+// exportArchiveCmd writes the user's notes, follows and likes to exports/<username>_archive.zip
+func exportArchiveCmd(userId uuid.UUID) tea.Cmd {
+	return func() tea.Msg {
+		conf, err := util.ReadConf()
+		if err != nil {
+			return archiveExportedMsg{err: fmt.Errorf("failed to read config: %w", err)}
+		}
+		path, err := web.ExportAccountDataToFile(userId, conf)
+		if err != nil {
+			log.Printf("Failed to export archive: %v", err)
+		}
+		return archiveExportedMsg{path: path, err: err}
+	}
+}
+
+// issueAPITokenCmd issues a new HTTP API token with the given scopes. Only its hash is
+// stored, so the token itself can only be shown this once.
+func issueAPITokenCmd(userId uuid.UUID, scopes []string) tea.Cmd {
+	return func() tea.Msg {
+		token, err := web.IssueAPIToken(userId, scopes)
+		if err != nil {
+			log.Printf("Failed to issue API token: %v", err)
+		}
+		return apiTokenIssuedMsg{token: token, scopes: scopes, err: err}
+	}
+}
+
+// revokeAPITokensCmd revokes all of the user's HTTP API tokens
+func revokeAPITokensCmd(userId uuid.UUID) tea.Cmd {
+	return func() tea.Msg {
+		err := db.GetDB().DeleteAPITokensByAccountId(userId)
+		if err != nil {
+			log.Printf("Failed to revoke API tokens: %v", err)
+		}
+		return apiTokensRevokedMsg{err: err}
+	}
+}
+
+// addKeyCmd lets the user log in with another SSH key, labelled with the key's comment
+func addKeyCmd(userId uuid.UUID, line string) tea.Cmd {
+	return func() tea.Msg {
+		pkHash, comment, err := util.AuthorizedKeyHash(line)
+		if err != nil {
+			return keyAddedMsg{err: fmt.Errorf("not a public key: %w", err)}
+		}
+		if comment == "" {
+			comment = "key"
+		}
+		if _, err := db.GetDB().AddKey(userId, pkHash, comment); err != nil {
+			log.Printf("Failed to add SSH key: %v", err)
+			return keyAddedMsg{err: err}
+		}
+		return keyAddedMsg{}
+	}
+}
+
+// removeKeyCmd removes one of the SSH keys added to the user's account
+func removeKeyCmd(userId, keyId uuid.UUID) tea.Cmd {
+	return func() tea.Msg {
+		err := db.GetDB().RemoveKey(userId, keyId)
+		if err != nil {
+			log.Printf("Failed to remove SSH key %s: %v", keyId, err)
+		}
+		return revokedMsg{what: "SSH key", err: err}
+	}
+}
+
+// revokeSessionCmd closes one of the user's SSH sessions
+func revokeSessionCmd(userId, sessionId uuid.UUID) tea.Cmd {
+	return func() tea.Msg {
+		err := util.GetSessionRegistry().RevokeSession(userId, sessionId)
+		if err != nil {
+			log.Printf("Failed to revoke session %s: %v", sessionId, err)
+		}
+		return revokedMsg{what: "Session", err: err}
+	}
+}
+
+// revokeAPITokenCmd revokes one of the user's HTTP API tokens
+func revokeAPITokenCmd(userId, tokenId uuid.UUID) tea.Cmd {
+	return func() tea.Msg {
+		err := db.GetDB().DeleteAPIToken(userId, tokenId)
+		if err != nil {
+			log.Printf("Failed to revoke API token %s: %v", tokenId, err)
+		}
+		return revokedMsg{what: "API token", err: err}
+	}
+}
+
+// toggleScope adds scope to scopes, or removes it if already present, keeping
+// scopes in read, write, follow order
+func toggleScope(scopes []string, scope string) []string {
+	var toggled []string
+	for _, s := range []string{domain.ScopeRead, domain.ScopeWrite, domain.ScopeFollow} {
+		if slices.Contains(scopes, s) != (s == scope) {
+			toggled = append(toggled, s)
+		}
+	}
+	return toggled
+}
+
+func truncate(s string, maxLen int) string {
+	if len(s) <= maxLen {
+		return s
+	}
+	return s[:maxLen-3] + "..."
+}
@@ -0,0 +1,217 @@
+package settings
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/deemkeen/stegodon/domain"
+	"github.com/deemkeen/stegodon/util"
+	"github.com/google/uuid"
+)
+
+func TestInitialModel(t *testing.T) {
+	userId := uuid.New()
+	m := InitialModel(userId)
+
+	if m.userId != userId {
+		t.Errorf("Expected userId %s, got %s", userId, m.userId)
+	}
+	if m.Selected != 0 || m.issuingToken || m.addingKey {
+		t.Error("Expected nothing selected and no token or key being added")
+	}
+	if m.Init() == nil {
+		t.Error("Expected Init to load the sessions, tokens and keys")
+	}
+}
+
+func TestUpdate_APITokenScopes(t *testing.T) {
+	m := InitialModel(uuid.New())
+	m, _ = m.Update(sessionsLoadedMsg{sessions: []util.Session{{Id: uuid.New()}, {Id: uuid.New()}}})
+
+	// Press 't' to choose the scopes of a new token, read-only by default
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'t'}})
+	if !m.issuingToken {
+		t.Fatal("Expected issuingToken true after 't'")
+	}
+	if strings.Join(m.tokenScopes, ",") != "read" {
+		t.Errorf("Expected default scopes [read], got %v", m.tokenScopes)
+	}
+
+	// Toggle follow on, then read off
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'f'}})
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'w'}})
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'r'}})
+	if strings.Join(m.tokenScopes, ",") != "write,follow" {
+		t.Errorf("Expected scopes [write follow], got %v", m.tokenScopes)
+	}
+
+	// Navigation is blocked while choosing scopes
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	if m.Selected != 0 {
+		t.Errorf("Expected navigation blocked while choosing scopes, Selected changed to %d", m.Selected)
+	}
+
+	// A token needs at least one scope
+	m.tokenScopes = nil
+	m, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if cmd != nil || !m.issuingToken {
+		t.Error("Expected enter to do nothing without scopes")
+	}
+
+	m, cmd = m.Update(tea.KeyMsg{Type: tea.KeyEscape})
+	if m.issuingToken || cmd != nil {
+		t.Error("Expected escape to cancel token creation")
+	}
+}
+
+func TestUpdate_APITokenIssued(t *testing.T) {
+	m := InitialModel(uuid.New())
+
+	// The token is shown once and the list reloaded to include it
+	m, cmd := m.Update(apiTokenIssuedMsg{token: "secret", scopes: []string{"read"}})
+	if !strings.Contains(m.Status, "secret") || cmd == nil {
+		t.Errorf("Expected the token to be shown and the list reloaded, got %q", m.Status)
+	}
+
+	m, _ = m.Update(apiTokenIssuedMsg{err: fmt.Errorf("boom")})
+	if !strings.Contains(m.Status, "Token creation failed") {
+		t.Errorf("Expected the failure to be reported, got %q", m.Status)
+	}
+}
+
+func TestUpdate_ManageSessions(t *testing.T) {
+	m := InitialModel(uuid.New())
+
+	sessions := []util.Session{{Id: uuid.New(), RemoteAddr: "192.0.2.1:50000", Client: "SSH-2.0-OpenSSH_9.6", ConnectedAt: time.Now()}}
+	tokens := []domain.APIToken{{Id: uuid.New(), Scopes: []string{"read", "write"}, CreatedAt: time.Now()}}
+	m, _ = m.Update(sessionsLoadedMsg{sessions: sessions, tokens: tokens})
+	view := m.View()
+	if !strings.Contains(view, "192.0.2.1:50000") || !strings.Contains(view, "read, write") {
+		t.Errorf("Expected the session and token to be listed, got:\n%s", view)
+	}
+	if strings.Contains(view, "this session") {
+		t.Errorf("Expected another session not to be marked as this one, got:\n%s", view)
+	}
+
+	// The session the view is shown in is marked, as revoking it disconnects
+	m.SessionId = sessions[0].Id
+	if view := m.View(); !strings.Contains(view, "this session") {
+		t.Errorf("Expected the current session to be marked, got:\n%s", view)
+	}
+
+	// Selection moves from the sessions to the tokens and stops at the last one
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	if m.Selected != 1 {
+		t.Errorf("Expected the token to be selected, got %d", m.Selected)
+	}
+	if _, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'x'}}); cmd == nil {
+		t.Error("Expected 'x' to revoke the selected token")
+	}
+
+	// A revoked token reloads the list
+	m, cmd := m.Update(revokedMsg{what: "API token"})
+	if m.Status != "API token revoked" || cmd == nil {
+		t.Errorf("Expected the revocation to be reported and the list reloaded, got %q", m.Status)
+	}
+
+	// A shorter list keeps the selection within bounds
+	m, _ = m.Update(sessionsLoadedMsg{sessions: sessions})
+	if m.Selected != 0 {
+		t.Errorf("Expected the selection clamped to the remaining session, got %d", m.Selected)
+	}
+}
+
+func TestUpdate_ManageKeys(t *testing.T) {
+	m := InitialModel(uuid.New())
+
+	keys := []domain.AccountKey{
+		{PkHash: "primaryhash", Label: "primary", Primary: true, AddedAt: time.Now()},
+		{Id: uuid.New(), PkHash: "laptophash", Label: "alice@laptop", AddedAt: time.Now()},
+	}
+	m, _ = m.Update(sessionsLoadedMsg{keys: keys})
+	view := m.View()
+	if !strings.Contains(view, "SSH keys (2)") || !strings.Contains(view, "alice@laptop") || !strings.Contains(view, "can't be removed") {
+		t.Errorf("Expected the keys to be listed, got:\n%s", view)
+	}
+
+	// Keys follow the sessions and tokens in the selection
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	if _, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'x'}}); m.Selected != 1 || cmd == nil {
+		t.Errorf("Expected 'x' to remove the selected key, selected %d", m.Selected)
+	}
+
+	// 'a' opens the input for a public key, which takes all typed keys
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'a'}})
+	if !m.addingKey {
+		t.Fatal("Expected 'a' to open the key input")
+	}
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("ssh-ed25519 x")})
+	if m.keyInput.Value() != "ssh-ed25519 x" {
+		t.Errorf("Expected the key to be typed into the input, got %q", m.keyInput.Value())
+	}
+	m, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if m.addingKey || cmd == nil {
+		t.Error("Expected enter to add the key")
+	}
+
+	m, _ = m.Update(keyAddedMsg{err: fmt.Errorf("not a public key")})
+	if !strings.Contains(m.Status, "Adding key failed") {
+		t.Errorf("Expected the failure to be reported, got %q", m.Status)
+	}
+}
+
+func TestUpdate_Export(t *testing.T) {
+	m := InitialModel(uuid.New())
+
+	m, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'e'}})
+	if m.Status != "Exporting..." || cmd == nil {
+		t.Errorf("Expected 'e' to start the export, got %q", m.Status)
+	}
+
+	m, _ = m.Update(archiveExportedMsg{path: "/tmp/alice_archive.zip"})
+	if m.Status != "Exported to /tmp/alice_archive.zip" {
+		t.Errorf("Expected the archive path to be reported, got %q", m.Status)
+	}
+}
+
+func TestUpdate_EmptyList_NoCrash(t *testing.T) {
+	m := InitialModel(uuid.New())
+
+	for _, key := range []tea.KeyMsg{
+		{Type: tea.KeyUp},
+		{Type: tea.KeyDown},
+		{Type: tea.KeyRunes, Runes: []rune{'x'}},
+	} {
+		var cmd tea.Cmd
+		m, cmd = m.Update(key)
+		if cmd != nil {
+			t.Errorf("Expected %q to do nothing without sessions, tokens or keys", key.String())
+		}
+	}
+	if m.Selected != 0 {
+		t.Errorf("Expected Selected 0, got %d", m.Selected)
+	}
+}
+
+func TestToggleScope(t *testing.T) {
+	tests := []struct {
+		scopes   []string
+		scope    string
+		expected string
+	}{
+		{nil, "read", "read"},
+		{[]string{"read"}, "read", ""},
+		{[]string{"follow"}, "read", "read,follow"},
+		{[]string{"read", "write", "follow"}, "write", "read,follow"},
+	}
+
+	for _, tt := range tests {
+		if got := strings.Join(toggleScope(tt.scopes, tt.scope), ","); got != tt.expected {
+			t.Errorf("toggleScope(%v, %s) = %q, expected %q", tt.scopes, tt.scope, got, tt.expected)
+		}
+	}
+}
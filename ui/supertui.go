@@ -24,9 +24,11 @@ import (
 	"github.com/deemkeen/stegodon/ui/myposts"
 	"github.com/deemkeen/stegodon/ui/notifications"
 	"github.com/deemkeen/stegodon/ui/relay"
+	"github.com/deemkeen/stegodon/ui/settings"
 	"github.com/deemkeen/stegodon/ui/threadview"
 	"github.com/deemkeen/stegodon/ui/writenote"
 	"github.com/deemkeen/stegodon/util"
+	"github.com/deemkeen/stegodon/web"
 	"github.com/google/uuid"
 )
 
@@ -57,9 +59,28 @@ type MainModel struct {
 	localUsersModel    localusers.Model
 	adminModel         admin.Model
 	relayModel         relay.Model
+	settingsModel      settings.Model
 	deleteAccountModel deleteaccount.Model
 	threadViewModel    threadview.Model
 	notificationsModel notifications.Model
+	events             <-chan util.Event // live updates for this session, nil when polling
+}
+
+// loadTimezoneCmd reads the timezone the account's timestamps are shown in
+func loadTimezoneCmd(accountId uuid.UUID) tea.Cmd {
+	return func() tea.Msg {
+		timezone, err := db.GetDB().ReadTimezone(accountId)
+		if err != nil {
+			log.Printf("Failed to read timezone: %v", err)
+			return nil
+		}
+		location, err := util.LoadTimezone(timezone)
+		if err != nil {
+			log.Printf("Unknown timezone %s: %v", timezone, err)
+			return nil
+		}
+		return common.TimezoneChangedMsg{Location: location}
+	}
 }
 
 type userUpdateErrorMsg struct {
@@ -74,6 +95,10 @@ func updateUserModelCmd(acc *domain.Account) tea.Cmd {
 			log.Printf("User %s could not be updated: %v", acc.Username, err)
 			return userUpdateErrorMsg{err: err}
 		}
+		// Followers of a renamed or re-described account refresh their copy of it
+		if conf, err := util.ReadConf(); err == nil {
+			web.FederateProfile(acc.Id, conf)
+		}
 		return nil
 	}
 }
@@ -105,6 +130,7 @@ func NewModel(acc domain.Account, width int, height int) MainModel {
 	localUsersModel := localusers.InitialModel(acc.Id, width, height)
 	adminModel := admin.InitialModel(acc.Id, width, height)
 	relayModel := relay.InitialModel(acc.Id, &acc, config, width, height)
+	settingsModel := settings.InitialModel(acc.Id)
 	deleteAccountModel := deleteaccount.InitialModel(&acc)
 	threadViewModel := threadview.InitialModel(acc.Id, width, height, localDomain)
 	notificationsModel := notifications.InitialModel(acc.Id, width, height)
@@ -121,6 +147,7 @@ func NewModel(acc domain.Account, width int, height int) MainModel {
 	m.localUsersModel = localUsersModel
 	m.adminModel = adminModel
 	m.relayModel = relayModel
+	m.settingsModel = settingsModel
 	m.deleteAccountModel = deleteAccountModel
 	m.threadViewModel = threadViewModel
 	m.notificationsModel = notificationsModel
@@ -131,12 +158,44 @@ func NewModel(acc domain.Account, width int, height int) MainModel {
 	return m
 }
 
+// NewLiveModel creates a MainModel whose timeline and notifications reload when events
+// arrive on the session's event bus subscription instead of polling the database. sessionId
+// is the SSH session's id in the session registry, marked in the settings view.
+func NewLiveModel(acc domain.Account, width int, height int, events <-chan util.Event, sessionId uuid.UUID) MainModel {
+	m := NewModel(acc, width, height)
+	m.events = events
+	m.settingsModel.SessionId = sessionId
+	m.homeTimelineModel.Live = true
+	m.notificationsModel.Live = true
+	return m
+}
+
+// waitForEvent blocks until the next event and turns it into a message. It returns nil once
+// the subscription is closed at the end of the session.
+func waitForEvent(events <-chan util.Event) tea.Cmd {
+	return func() tea.Msg {
+		event, ok := <-events
+		if !ok {
+			return nil
+		}
+		return common.LiveEventMsg{Type: event.Type, UnreadCount: event.UnreadCount}
+	}
+}
+
 func (m MainModel) Init() tea.Cmd {
 	var cmds []tea.Cmd
 
+	// Listen for live updates
+	if m.events != nil {
+		cmds = append(cmds, waitForEvent(m.events))
+	}
+
 	// Load my posts list on startup
 	cmds = append(cmds, m.myPostsModel.Init())
 
+	// Show timestamps in the account's timezone
+	cmds = append(cmds, loadTimezoneCmd(m.account.Id))
+
 	// Load home timeline on startup (shown in right panel)
 	// Also activates notifications model to start badge refresh
 	cmds = append(cmds, func() tea.Msg { return common.ActivateViewMsg{} })
@@ -161,6 +220,18 @@ func (m MainModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmds []tea.Cmd
 
 	switch msg := msg.(type) {
+	case common.LiveEventMsg:
+		// Keep listening; the event itself is routed to the timeline and notifications below
+		cmds = append(cmds, waitForEvent(m.events))
+
+	case common.TimezoneChangedMsg:
+		// Every view rendering timestamps switches to the new timezone
+		m.homeTimelineModel.Location = msg.Location
+		m.myPostsModel.Location = msg.Location
+		m.threadViewModel.Location = msg.Location
+		m.settingsModel.Location = msg.Location
+		return m, nil
+
 	case userUpdateErrorMsg:
 		// Handle username validation error
 		if m.state == common.CreateUserView {
@@ -230,6 +301,8 @@ func (m MainModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.state = common.FollowingView
 		case common.LocalUsersView:
 			m.state = common.LocalUsersView
+		case common.SettingsView:
+			m.state = common.SettingsView
 		case common.DeleteAccountView:
 			m.state = common.DeleteAccountView
 		case common.ThreadView:
@@ -294,7 +367,7 @@ func (m MainModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		case "tab":
 			// Cycle through main views (excluding create user)
-			// Order: write -> home -> my posts -> [follow] -> followers -> following -> users -> [admin -> relay] -> delete
+			// Order: write -> home -> my posts -> [follow] -> followers -> following -> users -> [admin -> relay] -> settings -> delete
 			// AP-only views: follow remote user, relay management
 			if m.state == common.CreateUserView {
 				return m, nil
@@ -321,15 +394,17 @@ func (m MainModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				if m.account.IsAdmin {
 					m.state = common.AdminPanelView
 				} else {
-					m.state = common.DeleteAccountView
+					m.state = common.SettingsView
 				}
 			case common.AdminPanelView:
 				if m.config.Conf.WithAp {
 					m.state = common.RelayManagementView
 				} else {
-					m.state = common.DeleteAccountView
+					m.state = common.SettingsView
 				}
 			case common.RelayManagementView:
+				m.state = common.SettingsView
+			case common.SettingsView:
 				m.state = common.DeleteAccountView
 			case common.DeleteAccountView:
 				m.state = common.NotificationsView
@@ -397,6 +472,8 @@ func (m MainModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			case common.RelayManagementView:
 				m.state = common.AdminPanelView
 			case common.DeleteAccountView:
+				m.state = common.SettingsView
+			case common.SettingsView:
 				if m.account.IsAdmin {
 					if m.config.Conf.WithAp {
 						m.state = common.RelayManagementView
@@ -500,6 +577,8 @@ func (m MainModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		cmds = append(cmds, cmd)
 		m.deleteAccountModel, cmd = m.deleteAccountModel.Update(msg)
 		cmds = append(cmds, cmd)
+		m.settingsModel, cmd = m.settingsModel.Update(msg)
+		cmds = append(cmds, cmd)
 		m.followersModel, cmd = m.followersModel.Update(msg)
 		cmds = append(cmds, cmd)
 		m.followingModel, cmd = m.followingModel.Update(msg)
@@ -551,6 +630,8 @@ func (m MainModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.adminModel, cmd = m.adminModel.Update(msg)
 		case common.RelayManagementView:
 			m.relayModel, cmd = m.relayModel.Update(msg)
+		case common.SettingsView:
+			m.settingsModel, cmd = m.settingsModel.Update(msg)
 		case common.DeleteAccountView:
 			m.deleteAccountModel, cmd = m.deleteAccountModel.Update(msg)
 		case common.ThreadView:
@@ -687,6 +768,14 @@ func (m MainModel) View() string {
 		Margin(1).
 		Render(m.relayModel.View())
 
+	settingsStyleStr := lipgloss.NewStyle().
+		MaxHeight(availableHeight).
+		Height(availableHeight).
+		Width(rightPanelWidth).
+		MaxWidth(rightPanelWidth).
+		Margin(1).
+		Render(m.settingsModel.View())
+
 	deleteAccountStyleStr := lipgloss.NewStyle().
 		MaxHeight(availableHeight).
 		Height(availableHeight).
@@ -758,6 +847,10 @@ func (m MainModel) View() string {
 			s += lipgloss.JoinHorizontal(lipgloss.Top,
 				modelStyle.Render(createStyleStr),
 				focusedModelStyle.Render(relayStyleStr))
+		case common.SettingsView:
+			s += lipgloss.JoinHorizontal(lipgloss.Top,
+				modelStyle.Render(createStyleStr),
+				focusedModelStyle.Render(settingsStyleStr))
 		case common.DeleteAccountView:
 			s += lipgloss.JoinHorizontal(lipgloss.Top,
 				modelStyle.Render(createStyleStr),
@@ -776,21 +869,23 @@ func (m MainModel) View() string {
 		var viewCommands string
 		switch m.state {
 		case common.HomeTimelineView:
-			viewCommands = "↑/↓ • enter: thread • r: reply • l: ⭐ • o: link"
+			viewCommands = "↑/↓ • enter: thread • e: expand • r: reply • l: ⭐ • o: link • c: copy link • L: languages • T: timezone • R: replies"
 		case common.MyPostsView:
 			viewCommands = "↑/↓ • u: edit • d: delete • l: ⭐"
 		case common.FollowUserView:
-			viewCommands = "enter: follow"
+			viewCommands = "enter: follow/search • ↑/↓: pick result"
 		case common.FollowersView:
-			viewCommands = "↑/↓: scroll"
+			viewCommands = "↑/↓ • a: approve • r: reject • l: require approval • d: discoverable"
 		case common.FollowingView:
-			viewCommands = "↑/↓ • u/enter: unfollow"
+			viewCommands = "↑/↓ • u/enter: unfollow • n: notify • x: export • i: import"
 		case common.LocalUsersView:
 			viewCommands = "↑/↓ • enter: toggle follow"
 		case common.AdminPanelView:
-			viewCommands = "↑/↓ • m: mute • k: kick"
+			viewCommands = "↑/↓ • m: mute • k: kick • d: disable • e: export • a: approve • D: deny"
 		case common.RelayManagementView:
 			viewCommands = "↑/↓ • a: add • d: delete • r: retry"
+		case common.SettingsView:
+			viewCommands = "↑/↓ • x: revoke • a: add key • t: api token • e: export"
 		case common.DeleteAccountView:
 			viewCommands = "y: confirm • n/esc: cancel"
 		case common.ThreadView:
@@ -853,6 +948,8 @@ func (m MainModel) currentFocusedModel() string {
 		return "admin"
 	case common.RelayManagementView:
 		return "relays"
+	case common.SettingsView:
+		return "settings"
 	case common.DeleteAccountView:
 		return "delete"
 	case common.ThreadView:
@@ -885,6 +982,8 @@ func getViewInitCmd(state common.SessionState, m *MainModel) tea.Cmd {
 		return m.adminModel.Init()
 	case common.RelayManagementView:
 		return m.relayModel.Init()
+	case common.SettingsView:
+		return m.settingsModel.Init()
 	case common.ThreadView:
 		// Thread view activation message
 		return func() tea.Msg { return common.ActivateViewMsg{} }
@@ -909,7 +1008,7 @@ func likeNoteCmd(accountId uuid.UUID, noteURI string, noteID uuid.UUID, isLocal
 		if isLocal && noteID != uuid.Nil {
 			actualNoteID = noteID
 			// Get the note's ObjectURI for federation
-			err, note := database.ReadNoteId(noteID)
+			note, err := database.ReadNoteId(noteID)
 			if err != nil {
 				log.Printf("Failed to read note for like: %v", err)
 				return common.UpdateNoteList
@@ -917,7 +1016,7 @@ func likeNoteCmd(accountId uuid.UUID, noteURI string, noteID uuid.UUID, isLocal
 			actualNoteURI = note.ObjectURI
 		} else if noteURI != "" && !strings.HasPrefix(noteURI, "local:") {
 			// Remote note - find it by ObjectURI
-			err, activity := database.ReadActivityByObjectURI(noteURI)
+			activity, err := database.ReadActivityByObjectURI(noteURI)
 			if err != nil || activity == nil {
 				log.Printf("Failed to find activity for like: %v", err)
 				return common.UpdateNoteList
@@ -925,7 +1024,7 @@ func likeNoteCmd(accountId uuid.UUID, noteURI string, noteID uuid.UUID, isLocal
 			actualNoteURI = noteURI
 			isRemotePost = true
 			// Try to find a local note with this URI (federated back)
-			err, localNote := database.ReadNoteByURI(noteURI)
+			localNote, err := database.ReadNoteByURI(noteURI)
 			if err == nil && localNote != nil {
 				actualNoteID = localNote.Id
 				isRemotePost = false // It's actually a local post that was federated back
@@ -940,7 +1039,7 @@ func likeNoteCmd(accountId uuid.UUID, noteURI string, noteID uuid.UUID, isLocal
 			}
 			actualNoteID = parsedID
 			// Get the note's ObjectURI for federation
-			err, note := database.ReadNoteId(parsedID)
+			note, err := database.ReadNoteId(parsedID)
 			if err != nil {
 				log.Printf("Failed to read note for like: %v", err)
 				return common.UpdateNoteList
@@ -948,57 +1047,30 @@ func likeNoteCmd(accountId uuid.UUID, noteURI string, noteID uuid.UUID, isLocal
 			actualNoteURI = note.ObjectURI
 		}
 
-		// Check if we already liked this post
-		var hasLike bool
-		var err error
-		if isRemotePost {
-			hasLike, err = database.HasLikeByObjectURI(accountId, actualNoteURI)
-		} else {
-			hasLike, err = database.HasLike(accountId, actualNoteID)
+		// Like the post, or remove the like if there is one; the like count changes with it
+		likeURI := ""
+		conf, err := util.ReadConf()
+		if err == nil && conf.Conf.WithAp {
+			likeURI = fmt.Sprintf("https://%s/activities/%s", conf.Conf.SslDomain, uuid.New().String())
+		}
+		like := &domain.Like{
+			Id:        uuid.New(),
+			AccountId: accountId,
+			NoteId:    actualNoteID, // Will be uuid.Nil for remote posts
+			URI:       likeURI,
+			CreatedAt: time.Now(),
 		}
+		result, err := database.ToggleLike(like, actualNoteURI)
 		if err != nil {
-			log.Printf("Failed to check existing like: %v", err)
+			log.Printf("Failed to toggle like: %v", err)
 			return common.UpdateNoteList
 		}
 
-		if hasLike {
-			// Unlike - remove the like
-			var existingLike *domain.Like
-			if isRemotePost {
-				err, existingLike = database.ReadLikeByAccountAndObjectURI(accountId, actualNoteURI)
-			} else {
-				err, existingLike = database.ReadLikeByAccountAndNote(accountId, actualNoteID)
-			}
-			if err != nil {
-				log.Printf("Failed to read existing like: %v", err)
-				return common.UpdateNoteList
-			}
-
-			// Delete the like
-			if isRemotePost {
-				if err := database.DeleteLikeByAccountAndObjectURI(accountId, actualNoteURI); err != nil {
-					log.Printf("Failed to delete like: %v", err)
-					return common.UpdateNoteList
-				}
-				// Decrement like count on the activity
-				if err := database.DecrementLikeCountByObjectURI(actualNoteURI); err != nil {
-					log.Printf("Failed to decrement activity like count: %v", err)
-				}
-			} else {
-				if err := database.DeleteLikeByAccountAndNote(accountId, actualNoteID); err != nil {
-					log.Printf("Failed to delete like: %v", err)
-					return common.UpdateNoteList
-				}
-				// Decrement like count on the note
-				if err := database.DecrementLikeCountByNoteId(actualNoteID); err != nil {
-					log.Printf("Failed to decrement like count: %v", err)
-				}
-			}
-
+		if !result.Active {
 			log.Printf("Unliked post %s", actualNoteURI)
 
 			// Send Undo Like to remote server (background task)
-			if actualNoteURI != "" && existingLike != nil {
+			if actualNoteURI != "" {
 				go func() {
 					conf, err := util.ReadConf()
 					if err != nil {
@@ -1010,7 +1082,7 @@ func likeNoteCmd(accountId uuid.UUID, noteURI string, noteID uuid.UUID, isLocal
 						return
 					}
 
-					if err := activitypub.SendUndoLike(account, actualNoteURI, existingLike.URI, conf); err != nil {
+					if err := activitypub.SendUndoLike(account, actualNoteURI, result.URI, conf); err != nil {
 						log.Printf("Failed to federate unlike: %v", err)
 					} else {
 						log.Printf("Unlike federated successfully")
@@ -1018,45 +1090,11 @@ func likeNoteCmd(accountId uuid.UUID, noteURI string, noteID uuid.UUID, isLocal
 				}()
 			}
 		} else {
-			// Like - create a new like
-			likeURI := ""
-			conf, err := util.ReadConf()
-			if err == nil && conf.Conf.WithAp {
-				likeURI = fmt.Sprintf("https://%s/activities/%s", conf.Conf.SslDomain, uuid.New().String())
-			}
-
-			like := &domain.Like{
-				Id:        uuid.New(),
-				AccountId: accountId,
-				NoteId:    actualNoteID, // Will be uuid.Nil for remote posts
-				URI:       likeURI,
-				CreatedAt: time.Now(),
-			}
-
-			// Create the like
-			if isRemotePost {
-				if err := database.CreateLikeByObjectURI(like, actualNoteURI); err != nil {
-					log.Printf("Failed to create like: %v", err)
-					return common.UpdateNoteList
-				}
-				// Increment like count on the activity
-				if err := database.IncrementLikeCountByObjectURI(actualNoteURI); err != nil {
-					log.Printf("Failed to increment activity like count: %v", err)
-				}
-			} else {
-				if err := database.CreateLike(like); err != nil {
-					log.Printf("Failed to create like: %v", err)
-					return common.UpdateNoteList
-				}
-				// Increment like count on the note
-				if err := database.IncrementLikeCountByNoteId(actualNoteID); err != nil {
-					log.Printf("Failed to increment like count: %v", err)
-				}
-
+			if !isRemotePost {
 				// Create notification for local note author
-				err, note := database.ReadNoteId(actualNoteID)
+				note, err := database.ReadNoteId(actualNoteID)
 				if err == nil && note != nil {
-					err, noteAuthor := database.ReadAccByUsername(note.CreatedBy)
+					noteAuthor, err := database.ReadAccByUsername(note.CreatedBy)
 					if err == nil && noteAuthor != nil && noteAuthor.Id != accountId {
 						// Only notify if liker is not the author
 						preview := note.Message
@@ -2,10 +2,12 @@ package ui
 
 import (
 	"testing"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/deemkeen/stegodon/domain"
 	"github.com/deemkeen/stegodon/ui/common"
+	"github.com/deemkeen/stegodon/util"
 	"github.com/google/uuid"
 )
 
@@ -255,6 +257,7 @@ func TestMessageRoutingPreventsPanicsAcrossAllViews(t *testing.T) {
 		common.FollowingView,
 		common.LocalUsersView,
 		common.AdminPanelView,
+		common.SettingsView,
 		common.DeleteAccountView,
 	}
 
@@ -535,6 +538,43 @@ func TestShiftTabNavigationWithTimelineActivation(t *testing.T) {
 	// The important thing is no panic occurred
 }
 
+// TestTabNavigationThroughSettings verifies that settings sit between the user list (or the
+// admin views) and account deletion in both directions
+func TestTabNavigationThroughSettings(t *testing.T) {
+	account := domain.Account{
+		Id:       uuid.New(),
+		Username: "testuser",
+	}
+
+	model := NewModel(account, 100, 30)
+	model.config = &util.AppConfig{}
+	model.state = common.LocalUsersView
+
+	updatedModel, cmd := model.Update(tea.KeyMsg{Type: tea.KeyTab})
+	mainModel := updatedModel.(MainModel)
+	if mainModel.state != common.SettingsView || cmd == nil {
+		t.Errorf("Expected state SettingsView with its data loading after tab from LocalUsers, got %v", mainModel.state)
+	}
+
+	updatedModel, _ = mainModel.Update(tea.KeyMsg{Type: tea.KeyTab})
+	mainModel = updatedModel.(MainModel)
+	if mainModel.state != common.DeleteAccountView {
+		t.Errorf("Expected state DeleteAccountView after tab from Settings, got %v", mainModel.state)
+	}
+
+	updatedModel, _ = mainModel.Update(tea.KeyMsg{Type: tea.KeyShiftTab})
+	mainModel = updatedModel.(MainModel)
+	if mainModel.state != common.SettingsView {
+		t.Errorf("Expected state SettingsView after shift-tab from DeleteAccount, got %v", mainModel.state)
+	}
+
+	updatedModel, _ = mainModel.Update(tea.KeyMsg{Type: tea.KeyShiftTab})
+	mainModel = updatedModel.(MainModel)
+	if mainModel.state != common.LocalUsersView {
+		t.Errorf("Expected state LocalUsersView after shift-tab from Settings, got %v", mainModel.state)
+	}
+}
+
 // TestNKeyNavigationToNotifications verifies 'n' key navigates to notifications
 // and handles timeline deactivation correctly
 func TestNKeyNavigationToNotifications(t *testing.T) {
@@ -594,3 +634,21 @@ func TestActivateDeactivateCycle(t *testing.T) {
 		model = mainModel
 	}
 }
+
+// TestTimezoneChangedMsg verifies a new timezone reaches every view showing timestamps
+func TestTimezoneChangedMsg(t *testing.T) {
+	account := domain.Account{
+		Id:       uuid.New(),
+		Username: "testuser",
+	}
+
+	model := NewModel(account, 100, 30)
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+
+	updatedModel, _ := model.Update(common.TimezoneChangedMsg{Location: berlin})
+	mainModel := updatedModel.(MainModel)
+
+	if mainModel.homeTimelineModel.Location != berlin || mainModel.myPostsModel.Location != berlin || mainModel.threadViewModel.Location != berlin || mainModel.settingsModel.Location != berlin {
+		t.Error("Expected all views to show times in Europe/Berlin")
+	}
+}
@@ -1,7 +1,6 @@
 package threadview
 
 import (
-	"encoding/json"
 	"fmt"
 	"log"
 	"sort"
@@ -10,6 +9,7 @@ import (
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/deemkeen/stegodon/activitypub"
 	"github.com/deemkeen/stegodon/db"
 	"github.com/deemkeen/stegodon/domain"
 	"github.com/deemkeen/stegodon/ui/common"
@@ -104,9 +104,13 @@ type Model struct {
 	parentContent   string    // Original content (for reload)
 	parentCreatedAt time.Time // Original timestamp (for reload)
 	// Fields to restore selection after reload
-	pendingSelection int    // Selection to restore after reload (-2 means no pending restore)
-	pendingOffset    int    // Offset to restore after reload
-	LocalDomain      string // Cached local domain for mention highlighting
+	pendingSelection int            // Selection to restore after reload (-2 means no pending restore)
+	pendingOffset    int            // Offset to restore after reload
+	LocalDomain      string         // Cached local domain for mention highlighting
+	Location         *time.Location // Timezone timestamps are shown in
+	// Partly imported conversations
+	LoadMoreURI string // Post to continue the import from, "" if the thread is complete
+	loadingMore bool
 }
 
 // InitialModel creates a new thread view model
@@ -151,6 +155,25 @@ type threadLoadedMsg struct {
 	err     error
 }
 
+// moreImportedMsg reports the result of importing more of a partly imported conversation
+type moreImportedMsg struct {
+	from   string // post the import continued from
+	result *activitypub.ConversationImport
+	err    error
+}
+
+// importMoreCmd continues importing a conversation from the given post
+func importMoreCmd(uri string) tea.Cmd {
+	return func() tea.Msg {
+		conf, err := util.ReadConf()
+		if err != nil {
+			return moreImportedMsg{from: uri, err: fmt.Errorf("failed to read config: %w", err)}
+		}
+		result, err := activitypub.ImportConversation(uri, conf)
+		return moreImportedMsg{from: uri, result: result, err: err}
+	}
+}
+
 // loadThread loads the parent post and its replies
 func loadThread(parentURI string) tea.Cmd {
 	return func() tea.Msg {
@@ -168,7 +191,7 @@ func loadThread(parentURI string) tea.Cmd {
 
 		// Try to find the parent post
 		// First check if it's a local note by URI
-		err, localNote := database.ReadNoteByURI(parentURI)
+		localNote, err := database.ReadNoteByURI(parentURI)
 		if err == nil && localNote != nil {
 			replyCount, _ := database.CountRepliesByNoteId(localNote.Id)
 			parent = &ThreadPost{
@@ -185,7 +208,7 @@ func loadThread(parentURI string) tea.Cmd {
 			}
 		} else {
 			// Check if it's a stored activity (federated post)
-			err, activity := database.ReadActivityByObjectURI(parentURI)
+			activity, err := database.ReadActivityByObjectURI(parentURI)
 			if err == nil && activity != nil {
 				// Parse activity to get content
 				content, author := parseActivityContent(activity)
@@ -207,7 +230,7 @@ func loadThread(parentURI string) tea.Cmd {
 		}
 
 		// Load replies using the parentURI (which matches in_reply_to_uri)
-		err, localReplies := database.ReadRepliesByURI(parentURI)
+		localReplies, err := database.ReadRepliesByURI(parentURI)
 		if err == nil && localReplies != nil {
 			for _, note := range *localReplies {
 				// Count local replies for each reply
@@ -236,7 +259,7 @@ func loadThread(parentURI string) tea.Cmd {
 		}
 
 		// Also load remote replies from activities table
-		err, remoteReplies := database.ReadActivitiesByInReplyTo(parentURI)
+		remoteReplies, err := database.ReadActivitiesByInReplyTo(parentURI)
 		if err == nil && remoteReplies != nil {
 			// Build local actor prefix to filter out local users
 			localActorPrefix := ""
@@ -251,7 +274,7 @@ func loadThread(parentURI string) tea.Cmd {
 				}
 				// Skip if this activity is a duplicate of a local note (federated copy of local post)
 				if activity.ObjectURI != "" {
-					dupErr, existingNote := database.ReadNoteByURI(activity.ObjectURI)
+					existingNote, dupErr := database.ReadNoteByURI(activity.ObjectURI)
 					if dupErr == nil && existingNote != nil {
 						// This is a duplicate, skip it
 						continue
@@ -330,7 +353,7 @@ func loadThreadByID(noteID uuid.UUID, noteURI string, author string, content str
 		// Get like count and boost count from database
 		parentLikeCount := 0
 		parentBoostCount := 0
-		if err, note := database.ReadNoteId(noteID); err == nil && note != nil {
+		if note, err := database.ReadNoteId(noteID); err == nil && note != nil {
 			parentLikeCount = note.LikeCount
 			parentBoostCount = note.BoostCount
 		}
@@ -352,7 +375,7 @@ func loadThreadByID(noteID uuid.UUID, noteURI string, author string, content str
 		// Load local replies using the note ID - this searches for any in_reply_to_uri
 		// that contains the note ID (handles various URI formats)
 		var replies []ThreadPost
-		err, localReplies := database.ReadRepliesByNoteId(noteID)
+		localReplies, err := database.ReadRepliesByNoteId(noteID)
 		if err == nil && localReplies != nil {
 			for _, note := range *localReplies {
 				// Count local replies for each reply
@@ -388,7 +411,7 @@ func loadThreadByID(noteID uuid.UUID, noteURI string, author string, content str
 			localActorPrefix := fmt.Sprintf("https://%s/users/", domain)
 
 			// Search for remote activities that reply to this note
-			err, remoteReplies := database.ReadActivitiesByInReplyTo(canonicalURI)
+			remoteReplies, err := database.ReadActivitiesByInReplyTo(canonicalURI)
 			if err == nil && remoteReplies != nil {
 				for _, activity := range *remoteReplies {
 					// Skip if this is from a local user (already shown as local reply)
@@ -397,7 +420,7 @@ func loadThreadByID(noteID uuid.UUID, noteURI string, author string, content str
 					}
 					// Skip if this activity is a duplicate of a local note (federated copy of local post)
 					if activity.ObjectURI != "" {
-						dupErr, existingNote := database.ReadNoteByURI(activity.ObjectURI)
+						existingNote, dupErr := database.ReadNoteByURI(activity.ObjectURI)
 						if dupErr == nil && existingNote != nil {
 							// This is a duplicate, skip it
 							continue
@@ -445,23 +468,17 @@ func parseActivityContent(activity *domain.Activity) (string, string) {
 
 	// Try to get a better author name from the database
 	database := db.GetDB()
-	err, remoteAcc := database.ReadRemoteAccountByActorURI(activity.ActorURI)
+	remoteAcc, err := database.ReadRemoteAccountByActorURI(activity.ActorURI)
 	if err == nil && remoteAcc != nil {
 		author = "@" + remoteAcc.Username + "@" + remoteAcc.Domain
 	}
 
-	// Parse the raw JSON to extract content using proper JSON unmarshaling
+	// Long-form posts are shown in full, under their title
 	if activity.RawJSON != "" {
-		var activityWrapper struct {
-			Type   string `json:"type"`
-			Object struct {
-				ID      string `json:"id"`
-				Content string `json:"content"`
-			} `json:"object"`
-		}
-
-		if err := json.Unmarshal([]byte(activity.RawJSON), &activityWrapper); err == nil {
-			content = util.StripHTMLTags(activityWrapper.Object.Content)
+		title, contentHTML := util.RemotePostBody(activity.RawJSON)
+		content = util.HTMLToMarkdown(contentHTML)
+		if title != "" {
+			content = strings.TrimSpace(title + "\n\n" + content)
 		}
 	}
 
@@ -504,6 +521,8 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 		m.parentAuthor = msg.Author
 		m.parentContent = msg.Content
 		m.parentCreatedAt = msg.CreatedAt
+		m.LoadMoreURI = msg.LoadMoreURI
+		m.loadingMore = false
 		// For local notes, use loadThreadByID which doesn't rely on object_uri in DB
 		if msg.IsLocal && msg.NoteID != uuid.Nil {
 			return m, loadThreadByID(msg.NoteID, msg.NoteURI, msg.Author, msg.Content, msg.CreatedAt)
@@ -539,8 +558,32 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 		}
 		return m, nil
 
+	case moreImportedMsg:
+		m.loadingMore = false
+		if msg.err != nil {
+			m.errorMessage = msg.err.Error()
+			return m, nil
+		}
+		// More ancestors make the thread start higher up; otherwise more replies are shown
+		if msg.from == m.ParentURI && msg.result.RootURI != m.ParentURI {
+			rootURI, continueURI := msg.result.RootURI, msg.result.ContinueURI
+			return m, func() tea.Msg {
+				return common.ViewThreadMsg{NoteURI: rootURI, LoadMoreURI: continueURI}
+			}
+		}
+		m.LoadMoreURI = msg.result.ContinueURI
+		m.pendingSelection = m.Selected
+		m.pendingOffset = m.Offset
+		return m, loadThread(m.ParentURI)
+
 	case tea.KeyMsg:
 		switch msg.String() {
+		case "m":
+			// Continue a partly imported conversation
+			if m.LoadMoreURI != "" && !m.loadingMore {
+				m.loadingMore = true
+				return m, importMoreCmd(m.LoadMoreURI)
+			}
 		case "up", "k":
 			if m.Selected > -1 {
 				m.Selected--
@@ -705,6 +748,14 @@ func (m Model) View() string {
 		return s.String()
 	}
 
+	if m.loadingMore {
+		s.WriteString(common.HelpStyle.Render("Loading more of the conversation..."))
+		s.WriteString("\n\n")
+	} else if m.LoadMoreURI != "" {
+		s.WriteString(common.HelpStyle.Render("Partially loaded · m: load more"))
+		s.WriteString("\n\n")
+	}
+
 	if m.errorMessage != "" {
 		s.WriteString(emptyStyle.Render("Error: " + m.errorMessage))
 		s.WriteString("\n\n")
@@ -761,7 +812,7 @@ func (m Model) View() string {
 		}
 
 		// Format timestamp with engagement indicators
-		timeStr := formatTime(post.Time)
+		timeStr := common.FormatTime(post.Time, m.Location)
 		if post.ReplyCount == 1 {
 			timeStr = fmt.Sprintf("%s · 1 reply", timeStr)
 		} else if post.ReplyCount > 1 {
@@ -781,10 +832,8 @@ func (m Model) View() string {
 		}
 
 		// Format content - Convert Markdown links first, then highlight hashtags and mentions (same order as myposts)
-		processedContent := post.Content
-		if post.IsLocal {
-			processedContent = util.MarkdownLinksToTerminal(processedContent)
-		}
+		// Remote posts are Markdown converted from their sanitized HTML
+		processedContent := util.MarkdownLinksToTerminal(post.Content)
 		highlightedContent := util.HighlightHashtagsTerminal(processedContent)
 		highlightedContent = util.HighlightMentionsTerminal(highlightedContent, m.LocalDomain)
 
@@ -856,20 +905,3 @@ func min(a, b int) int {
 	}
 	return b
 }
-
-func formatTime(t time.Time) string {
-	duration := time.Since(t)
-
-	if duration < time.Minute {
-		return "just now"
-	} else if duration < time.Hour {
-		mins := int(duration.Minutes())
-		return fmt.Sprintf("%dm ago", mins)
-	} else if duration < common.HoursPerDay*time.Hour {
-		hours := int(duration.Hours())
-		return fmt.Sprintf("%dh ago", hours)
-	} else {
-		days := int(duration.Hours() / common.HoursPerDay)
-		return fmt.Sprintf("%dd ago", days)
-	}
-}
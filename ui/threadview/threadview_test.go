@@ -7,6 +7,7 @@ import (
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/deemkeen/stegodon/activitypub"
 	"github.com/deemkeen/stegodon/ui/common"
 	"github.com/deemkeen/stegodon/util"
 	"github.com/google/uuid"
@@ -508,7 +509,7 @@ func TestFormatTime(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := formatTime(tt.time)
+			result := common.FormatTime(tt.time, time.UTC)
 			if result != tt.expected {
 				t.Errorf("Expected '%s', got '%s'", tt.expected, result)
 			}
@@ -590,6 +591,43 @@ func TestView_PluralReplies(t *testing.T) {
 	}
 }
 
+func TestUpdate_LoadMore(t *testing.T) {
+	m := InitialModel(uuid.New(), 120, 40, "")
+	root := "https://remote.example.com/notes/2"
+	m, _ = m.Update(common.ViewThreadMsg{NoteURI: root, LoadMoreURI: root})
+	m.loading = false
+	m.ParentPost = &ThreadPost{ID: uuid.New(), Author: "bob", Content: "Test", Time: time.Now(), IsParent: true}
+
+	if !strings.Contains(m.View(), "m: load more") {
+		t.Error("Expected a partly loaded thread to offer loading more")
+	}
+	m, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'m'}})
+	if cmd == nil || !m.loadingMore {
+		t.Fatal("Expected 'm' to start importing more")
+	}
+	if _, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'m'}}); cmd != nil {
+		t.Error("Expected no second import while one is running")
+	}
+
+	// Ancestors were found: the thread now starts at the new root
+	result := &activitypub.ConversationImport{RootURI: "https://remote.example.com/notes/1"}
+	m, cmd = m.Update(moreImportedMsg{from: root, result: result})
+	if cmd == nil {
+		t.Fatal("Expected the thread to be opened at its new root")
+	}
+	if msg, ok := cmd().(common.ViewThreadMsg); !ok || msg.NoteURI != result.RootURI || msg.LoadMoreURI != "" {
+		t.Errorf("Expected ViewThreadMsg for %s without more to load, got %#v", result.RootURI, cmd())
+	}
+
+	// More replies were found: the thread is reloaded in place
+	m.LoadMoreURI = "https://remote.example.com/notes/3"
+	result = &activitypub.ConversationImport{RootURI: root, ContinueURI: "https://remote.example.com/notes/4"}
+	m, cmd = m.Update(moreImportedMsg{from: "https://remote.example.com/notes/3", result: result})
+	if cmd == nil || m.LoadMoreURI != result.ContinueURI {
+		t.Errorf("Expected a reload and %s to continue from, got %q", result.ContinueURI, m.LoadMoreURI)
+	}
+}
+
 func TestThreadPost_Fields(t *testing.T) {
 	id := uuid.New()
 	now := time.Now()
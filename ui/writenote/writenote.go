@@ -18,7 +18,8 @@ import (
 	"github.com/google/uuid"
 )
 
-const MaxLetters = 300
+// MaxLetters is the visible character limit used when the config doesn't set MaxNoteChars
+const MaxLetters = util.DefaultMaxNoteChars
 const maxAutocompleteSuggestions = 5
 
 // MentionCandidate represents a user that can be mentioned
@@ -45,6 +46,7 @@ type Model struct {
 	Error             string // Error message to display
 	userId            uuid.UUID
 	lettersLeft       int
+	maxLetters        int // Visible character limit from the config
 	width             int
 	isEditing         bool      // True when editing an existing note
 	editingNoteId     uuid.UUID // ID of note being edited
@@ -54,6 +56,7 @@ type Model struct {
 	replyToURI     string // URI of the post being replied to
 	replyToAuthor  string // Author of the post being replied to
 	replyToPreview string // Preview of the post being replied to
+	localOnly      bool   // True when the next post should stay on this instance
 	// Autocomplete fields
 	showAutocomplete       bool               // True when autocomplete popup is visible
 	autocompleteCandidates []MentionCandidate // All available candidates
@@ -75,8 +78,10 @@ func InitialNote(contentWidth int, userId uuid.UUID) Model {
 
 	// Get local domain for autocomplete
 	localDomain := "example.com"
+	maxLetters := MaxLetters
 	if conf, err := util.ReadConf(); err == nil {
 		localDomain = conf.Conf.SslDomain
+		maxLetters = conf.NoteCharLimit()
 	}
 
 	// Load autocomplete candidates
@@ -87,7 +92,8 @@ func InitialNote(contentWidth int, userId uuid.UUID) Model {
 		Err:                    nil,
 		Error:                  "",
 		userId:                 userId,
-		lettersLeft:            MaxLetters,
+		lettersLeft:            maxLetters,
+		maxLetters:             maxLetters,
 		width:                  width,
 		isEditing:              false,
 		editingNoteId:          uuid.Nil,
@@ -111,7 +117,7 @@ func loadAutocompleteCandidates(localDomain string) []MentionCandidate {
 	database := db.GetDB()
 
 	// Load local accounts
-	err, localAccounts := database.ReadAllAccounts()
+	localAccounts, err := database.ReadAllAccounts()
 	if err == nil && localAccounts != nil {
 		for _, acc := range *localAccounts {
 			candidates = append(candidates, MentionCandidate{
@@ -123,7 +129,7 @@ func loadAutocompleteCandidates(localDomain string) []MentionCandidate {
 	}
 
 	// Load remote accounts
-	err, remoteAccounts := database.ReadAllRemoteAccounts()
+	remoteAccounts, err := database.ReadAllRemoteAccounts()
 	if err == nil && remoteAccounts != nil {
 		for _, acc := range remoteAccounts {
 			candidates = append(candidates, MentionCandidate{
@@ -142,8 +148,8 @@ func createNoteModelCmd(note *domain.SaveNote) tea.Cmd {
 		database := db.GetDB()
 
 		// Create note in database and get the created note ID
-		// Use CreateNoteWithReply to support replies
-		noteId, err := database.CreateNoteWithReply(note.UserId, note.Message, note.InReplyToURI)
+		// Use CreateNoteWithVisibility to support replies and local-only posts
+		noteId, err := database.CreateNoteWithVisibility(note.UserId, note.Message, note.InReplyToURI, note.Visibility)
 		if err != nil {
 			log.Println("Note could not be saved!")
 			return common.UpdateNoteList
@@ -161,18 +167,18 @@ func createNoteModelCmd(note *domain.SaveNote) tea.Cmd {
 					noteIdStr := strings.TrimPrefix(note.InReplyToURI, "local:")
 					parentNoteId, parseErr := uuid.Parse(noteIdStr)
 					if parseErr == nil {
-						readErr, parentNote = database.ReadNoteId(parentNoteId)
+						parentNote, readErr = database.ReadNoteId(parentNoteId)
 					}
 				} else {
-					readErr, parentNote = database.ReadNoteByURI(note.InReplyToURI)
+					parentNote, readErr = database.ReadNoteByURI(note.InReplyToURI)
 				}
 
 				// Create notification if parent note exists and is local
 				if readErr == nil && parentNote != nil {
-					readErr, parentAuthor := database.ReadAccByUsername(parentNote.CreatedBy)
+					parentAuthor, readErr := database.ReadAccByUsername(parentNote.CreatedBy)
 					if readErr == nil && parentAuthor != nil && parentAuthor.Id != note.UserId {
 						// Only notify if replier is not the parent author
-						readErr, replier := database.ReadAccById(note.UserId)
+						replier, readErr := database.ReadAccById(note.UserId)
 						if readErr == nil && replier != nil {
 							preview := util.StripHTMLTags(note.Message)
 							if len(preview) > 100 {
@@ -203,7 +209,7 @@ func createNoteModelCmd(note *domain.SaveNote) tea.Cmd {
 		if len(mentions) > 0 {
 			conf, confErr := util.ReadConf()
 			if confErr == nil && conf != nil {
-				readErr, author := database.ReadAccById(note.UserId)
+				author, readErr := database.ReadAccById(note.UserId)
 				if readErr == nil && author != nil {
 					preview := util.StripHTMLTags(note.Message)
 					if len(preview) > 100 {
@@ -213,7 +219,7 @@ func createNoteModelCmd(note *domain.SaveNote) tea.Cmd {
 					for _, mention := range mentions {
 						// Check if this is a local user
 						if mention.Domain == "" || mention.Domain == conf.Conf.SslDomain {
-							readErr, mentionedUser := database.ReadAccByUsername(mention.Username)
+							mentionedUser, readErr := database.ReadAccByUsername(mention.Username)
 							if readErr == nil && mentionedUser != nil && mentionedUser.Id != note.UserId {
 								// Only notify if mentioner is not the mentioned user
 								notification := &domain.Notification{
@@ -256,17 +262,25 @@ func createNoteModelCmd(note *domain.SaveNote) tea.Cmd {
 			}
 		}
 
+		// Sessions showing this note in a timeline reload it right away
+		util.GetEventBus().Broadcast(util.EventNewPost)
+
+		// Local-only notes never leave this instance
+		if note.Visibility == domain.VisibilityLocal {
+			return common.UpdateNoteList
+		}
+
 		// Federate the note via ActivityPub (background task)
 		go func() {
 			// Get the created note from database with actual ID, timestamps, and reply info
-			err, createdNote := database.ReadNoteIdWithReplyInfo(noteId)
+			createdNote, err := database.ReadNoteIdWithReplyInfo(noteId)
 			if err != nil {
 				log.Printf("Failed to read created note for federation: %v", err)
 				return
 			}
 
 			// Get the account
-			err, account := database.ReadAccById(note.UserId)
+			account, err := database.ReadAccById(note.UserId)
 			if err != nil {
 				log.Printf("Failed to get account for federation: %v", err)
 				return
@@ -333,15 +347,15 @@ func updateNoteModelCmd(noteId uuid.UUID, message string) tea.Cmd {
 
 		// Federate the update via ActivityPub (background task)
 		go func() {
-			// Get the updated note
-			err, note := database.ReadNoteId(noteId)
+			// Get the updated note (with reply info so the Update keeps inReplyTo)
+			note, err := database.ReadNoteIdWithReplyInfo(noteId)
 			if err != nil {
 				log.Printf("Failed to get note for federation: %v", err)
 				return
 			}
 
 			// Get the account
-			err, account := database.ReadAccByUsername(note.CreatedBy)
+			account, err := database.ReadAccByUsername(note.CreatedBy)
 			if err != nil {
 				log.Printf("Failed to get account for federation: %v", err)
 				return
@@ -459,6 +473,12 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 			if m.Textarea.Focused() {
 				m.Textarea.Blur()
 			}
+		case tea.KeyCtrlL:
+			// Toggle local-only (not federated); visibility can't change when editing
+			if !m.isEditing {
+				m.localOnly = !m.localOnly
+			}
+			return m, nil
 		case tea.KeyCtrlS:
 			rawValue := m.Textarea.Value()
 
@@ -468,16 +488,10 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 				return m, nil
 			}
 
-			// Validate that visible characters don't exceed 150
-			visibleChars := util.CountVisibleChars(rawValue)
-			if visibleChars > MaxLetters {
-				m.Error = fmt.Sprintf("Note too long (%d visible characters, max %d)", visibleChars, MaxLetters)
-				return m, nil
-			}
-
-			// Validate that full message (including markdown) doesn't exceed MaxNoteDBLength
+			// Validate the visible characters against the configured limit and the full message
+			// (including markdown) against MaxNoteDBLength.
 			// Check BEFORE normalizing, as normalization might change length
-			if err := util.ValidateNoteLength(rawValue); err != nil {
+			if err := util.ValidateNoteChars(rawValue, m.letterLimit()); err != nil {
 				m.Error = err.Error()
 				return m, nil
 			}
@@ -516,6 +530,7 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 					UserId:       m.userId,
 					Message:      value,
 					InReplyToURI: replyURI,
+					Visibility:   m.visibility(),
 				}
 				m.Textarea.SetValue("")
 				m.Error = ""
@@ -528,8 +543,9 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 			} else {
 				// Create new note
 				note := domain.SaveNote{
-					UserId:  m.userId,
-					Message: value,
+					UserId:     m.userId,
+					Message:    value,
+					Visibility: m.visibility(),
 				}
 				m.Textarea.SetValue("")
 				m.Error = ""
@@ -573,9 +589,9 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 
 	m.Textarea, cmd = m.Textarea.Update(msg)
 
-	// Check if visible character count exceeds 150
+	// Check if visible character count exceeds the limit
 	visibleChars := util.CountVisibleChars(m.Textarea.Value())
-	if visibleChars > MaxLetters {
+	if visibleChars > m.letterLimit() {
 		// Revert the last change by not allowing more visible chars
 		// Note: This is a simple check, ideally we'd prevent the input
 		// For now, the character counter will show negative and save will fail
@@ -720,10 +736,27 @@ func (m *Model) insertAutocompleteSuggestion() {
 	m.lettersLeft = m.CharCount()
 }
 
+// visibility returns the visibility for a new note based on the local-only toggle
+func (m Model) visibility() string {
+	if m.localOnly {
+		return domain.VisibilityLocal
+	}
+	return domain.VisibilityPublic
+}
+
 func (m Model) CharCount() int {
 	// Use CountVisibleChars to only count visible text, not markdown URLs
 	visibleChars := util.CountVisibleChars(m.Textarea.Value())
-	return MaxLetters - visibleChars
+	return m.letterLimit() - visibleChars
+}
+
+// letterLimit returns the visible character limit, falling back to MaxLetters for models
+// that weren't created by InitialNote
+func (m Model) letterLimit() int {
+	if m.maxLetters > 0 {
+		return m.maxLetters
+	}
+	return MaxLetters
 }
 
 func (m Model) View() string {
@@ -754,6 +787,13 @@ func (m Model) View() string {
 	} else if m.isReplying {
 		helpText = "post reply: ctrl+s\ncancel: esc"
 	}
+	if !m.isEditing {
+		if m.localOnly {
+			helpText += "\nlocal-only (not federated): on, toggle: ctrl+l"
+		} else {
+			helpText += "\nlocal-only (not federated): off, toggle: ctrl+l"
+		}
+	}
 	if m.showAutocomplete {
 		helpText += "\n↑/↓: navigate, enter: select, esc: close"
 	}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/deemkeen/stegodon/domain"
 	"github.com/deemkeen/stegodon/ui/common"
 	"github.com/deemkeen/stegodon/util"
 	"github.com/google/uuid"
@@ -543,3 +544,24 @@ func TestMentionCandidateDisplayVsFullMention(t *testing.T) {
 		t.Error("Remote user's DisplayMention should equal FullMention")
 	}
 }
+
+func TestLocalOnlyToggle(t *testing.T) {
+	model := InitialNote(100, uuid.New())
+
+	if model.visibility() != domain.VisibilityPublic {
+		t.Errorf("Expected public visibility by default, got %q", model.visibility())
+	}
+
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyCtrlL})
+	if model.visibility() != domain.VisibilityLocal {
+		t.Errorf("Expected local visibility after ctrl+l, got %q", model.visibility())
+	}
+	if !strings.Contains(model.View(), "local-only (not federated): on") {
+		t.Errorf("Expected view to show local-only is on")
+	}
+
+	model, _ = model.Update(tea.KeyMsg{Type: tea.KeyCtrlL})
+	if model.visibility() != domain.VisibilityPublic {
+		t.Errorf("Expected public visibility after second ctrl+l, got %q", model.visibility())
+	}
+}
@@ -2,16 +2,92 @@ package util
 
 import (
 	_ "embed"
+	"errors"
 	"fmt"
 	"gopkg.in/yaml.v3"
 	"log"
+	"net"
+	"net/url"
 	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
 )
 
 const Name = "stegodon"
 const ConfigFileName = "config.yaml"
 
+// Reply count modes
+const (
+	ReplyCountModeStored = "stored" // Counters on every ancestor, updated when a reply arrives (default)
+	ReplyCountModeThread = "thread" // Replies are counted per thread root when timelines are read
+)
+
+// Home timeline query strategies
+const (
+	HomeTimelineQueryMerge = "merge" // One query per source, merged and limited in Go (default)
+	HomeTimelineQueryUnion = "union" // A single UNION query ordered and limited by SQLite, for very active instances
+)
+
+// Policies for Create activities from actors no local account follows
+const (
+	CreatePolicyReject           = "reject"             // Dropped unless they reply to a local post (default)
+	CreatePolicyStoreIfMentioned = "store-if-mentioned" // Also stored when they mention a local account
+	CreatePolicyStoreAll         = "store-all"          // Always stored, for open instances
+)
+
+// Note length limits used when the config leaves them at 0
+const (
+	DefaultMaxNoteChars        = 300   // Visible characters in a local note
+	DefaultMaxInboundNoteChars = 20000 // Plain-text characters in a remote post
+)
+
+// DefaultMaxInboundContentBytes is the size of a remote post's HTML content used when the config leaves it at 0
+const DefaultMaxInboundContentBytes = 64 * 1024
+
+// Policies for remote posts whose content is larger than maxInboundContentBytes
+const (
+	InboundContentPolicyTruncate = "truncate" // Cut to the limit and marked as truncated (default)
+	InboundContentPolicyReject   = "reject"   // Dropped
+)
+
+// Registration policies for new SSH keys
+const (
+	RegistrationPolicyOpen     = "open"     // New keys get an account right away (default)
+	RegistrationPolicyApproval = "approval" // New keys get a pending account an admin has to approve
+	RegistrationPolicyClosed   = "closed"   // New keys are rejected
+)
+
+// SQLite tuning used when the config leaves it at 0 or empty
+const (
+	DefaultDbCacheSizeKB        = 64000    // Page cache per connection
+	DefaultDbBusyTimeout        = 5000     // Milliseconds to wait for a locked database
+	DefaultDbSynchronous        = "normal" // Fewer fsyncs than "full", still safe in WAL mode
+	DefaultDbCheckpointInterval = 300      // Seconds between passive WAL checkpoints
+)
+
+// Conversation import limits used when the config leaves them at 0
+const (
+	DefaultMaxThreadDepth      = 16  // Posts up the ancestor chain and levels down the reply tree
+	DefaultMaxThreadFetches    = 200 // Posts and reply pages fetched by one import
+	DefaultThreadImportTimeout = 60  // Seconds one import may take
+)
+
+// DefaultDeliveryConcurrency is how many servers the delivery worker sends to at once when the
+// config leaves it at 0
+const DefaultDeliveryConcurrency = 4
+
+// Instance metadata used when the config leaves it empty
+const (
+	DefaultNodeName        = "Stegodon"
+	DefaultNodeDescription = "A SSH-first federated microblog"
+)
+
 //go:embed config_default.yaml
 var embeddedConfig []byte
 
@@ -23,10 +99,80 @@ type AppConfig struct {
 		SslDomain       string `yaml:"sslDomain"`
 		WithAp          bool   `yaml:"withAp"`
 		Single          bool   `yaml:"single"`
-		Closed          bool   `yaml:"closed"`
+		Closed          bool   `yaml:"closed"` // Same as registrationPolicy "closed"
 		NodeDescription string `yaml:"nodeDescription"`
 		WithJournald    bool   `yaml:"withJournald"`
 		WithPprof       bool   `yaml:"withPprof"`
+		LogFormat       string `yaml:"logFormat"`      // "text" (default) or "json" for structured logs
+		SigCacheTTL     int    `yaml:"sigCacheTtl"`    // Seconds to cache verified inbox signatures (0 = default, <0 = off)
+		ClockSkew       int    `yaml:"clockSkew"`      // Seconds a signed request's Date, created or expires may be off from our clock (0 = default, <0 = off)
+		InboxDateSkew   int    `yaml:"inboxDateSkew"`  // Deprecated: old name of clockSkew, used when clockSkew is unset
+		ReplayCacheTTL  int    `yaml:"replayCacheTtl"` // Seconds to remember delivered activity ids (0 = default, <0 = off)
+		InboxQueue      bool   `yaml:"inboxQueue"`     // Queue verified inbox activities and handle them in a worker instead of during the request
+
+		// Federation HTTP client (0 = default)
+		HttpTimeout             int `yaml:"httpTimeout"`             // Seconds for a whole outgoing request, including reading the response
+		HttpDialTimeout         int `yaml:"httpDialTimeout"`         // Seconds to establish a TCP connection
+		HttpTLSHandshakeTimeout int `yaml:"httpTlsHandshakeTimeout"` // Seconds to complete the TLS handshake
+		HttpMaxIdleConnsPerHost int `yaml:"httpMaxIdleConnsPerHost"` // Idle keep-alive connections kept per remote server
+		DeliveryConcurrency     int `yaml:"deliveryConcurrency"`     // Servers the delivery worker sends to at once
+
+		HttpDisableCompression bool `yaml:"httpDisableCompression"` // Don't ask remote servers for gzip-compressed responses
+
+		RelayStaleHours int `yaml:"relayStaleHours"` // Hours without relay deliveries before a relay is shown as stale (0 = default)
+
+		TombstoneRetentionDays int    `yaml:"tombstoneRetentionDays"` // Days deleted notes are kept as tombstones before they are purged (0 = default)
+		ReplyCountMode         string `yaml:"replyCountMode"`         // "stored" (default) or "thread" to count replies per thread on read
+		HomeTimelineQuery      string `yaml:"homeTimelineQuery"`      // "merge" (default) or "union" to read the home timeline in one query
+
+		InboundCreatePolicy string `yaml:"inboundCreatePolicy"` // Posts from non-followed actors: "reject" (default), "store-if-mentioned" or "store-all"
+		RegistrationPolicy  string `yaml:"registrationPolicy"`  // New SSH keys: "open" (default), "approval" or "closed"
+		OptInPublicTimeline bool   `yaml:"optInPublicTimeline"` // Only list posts of accounts that chose to be discoverable in the public timeline
+
+		// Note length, counted in characters (runes) rather than bytes (0 = default)
+		MaxNoteChars        int `yaml:"maxNoteChars"`        // Visible characters in a local note, at most 1000 (the database limit)
+		MaxInboundNoteChars int `yaml:"maxInboundNoteChars"` // Remote posts with longer plain text are rejected
+
+		// Size of a remote post's HTML content in bytes, checked apart from the 1MB inbox body limit
+		MaxInboundContentBytes int    `yaml:"maxInboundContentBytes"` // Largest content stored (0 = default)
+		InboundContentPolicy   string `yaml:"inboundContentPolicy"`   // Larger content: "truncate" (default) or "reject"
+
+		// Conversation imports stop early, keeping what they fetched, at these limits (0 = default)
+		MaxThreadDepth      int `yaml:"maxThreadDepth"`      // Posts up the ancestor chain and levels down the reply tree
+		MaxThreadFetches    int `yaml:"maxThreadFetches"`    // Posts and reply pages fetched by one import
+		ThreadImportTimeout int `yaml:"threadImportTimeout"` // Seconds one import may take
+
+		// Instance metadata served by /api/v1/instance (nodeName and nodeDescription also by NodeInfo)
+		NodeName       string   `yaml:"nodeName"`       // Instance title (empty = "Stegodon")
+		ContactAccount string   `yaml:"contactAccount"` // Username of the local account to contact about this instance
+		ContactEmail   string   `yaml:"contactEmail"`   // E-mail address to contact about this instance
+		Languages      []string `yaml:"languages"`      // ISO 639 codes of the languages the instance is meant for
+		Rules          []string `yaml:"rules"`          // Server rules, in the order they are listed
+
+		// Cached remote accounts nothing refers to anymore
+		PruneRemoteAccounts        bool `yaml:"pruneRemoteAccounts"`        // Periodically delete them
+		RemoteAccountRetentionDays int  `yaml:"remoteAccountRetentionDays"` // Days since they were last fetched before they are deleted (0 = default)
+
+		// Moderation
+		BlockedDomains []string `yaml:"blockedDomains"` // Servers (and their subdomains) whose follows are rejected
+		BlockedActors  []string `yaml:"blockedActors"`  // Actor URIs whose follows are rejected
+		SecureMode     bool     `yaml:"secureMode"`     // Require signed GETs for actors, objects and collections (authorized fetch)
+
+		// Relay content, by relay actor URI or by domain (and its subdomains)
+		TrustedRelays []string `yaml:"trustedRelays"` // Only accept relay content from these (empty = any subscribed relay)
+		DeniedRelays  []string `yaml:"deniedRelays"`  // Never accept relay content from these, even when subscribed
+
+		// Outgoing federation requests only go to public https URLs unless these are set
+		AllowPrivateFetch bool   `yaml:"allowPrivateFetch"` // Allow requests to loopback, private and link-local addresses
+		AllowHttpFetch    bool   `yaml:"allowHttpFetch"`    // Allow plain http:// requests
+		MaxFetchBytes     int64  `yaml:"maxFetchBytes"`     // Largest remote actor or object document read (0 = default)
+		UserAgent         string `yaml:"userAgent"`         // User-Agent of outgoing federation requests (empty = stegodon/<version> (+https://<sslDomain>))
+
+		// SQLite tuning (0 or empty = default)
+		DbCacheSizeKB        int    `yaml:"dbCacheSizeKb"`        // Page cache per connection, in KiB
+		DbBusyTimeout        int    `yaml:"dbBusyTimeout"`        // Milliseconds a query waits for a locked database
+		DbSynchronous        string `yaml:"dbSynchronous"`        // "off", "normal", "full" or "extra"
+		DbCheckpointInterval int    `yaml:"dbCheckpointInterval"` // Seconds between passive WAL checkpoints (<0 = off)
 	}
 }
 
@@ -73,8 +219,53 @@ func ReadConf() (*AppConfig, error) {
 	envSingle := os.Getenv("STEGODON_SINGLE")
 	envClosed := os.Getenv("STEGODON_CLOSED")
 	envNodeDescription := os.Getenv("STEGODON_NODE_DESCRIPTION")
+	envNodeName := os.Getenv("STEGODON_NODE_NAME")
+	envContactAccount := os.Getenv("STEGODON_CONTACT_ACCOUNT")
+	envContactEmail := os.Getenv("STEGODON_CONTACT_EMAIL")
+	envLanguages := os.Getenv("STEGODON_LANGUAGES")
 	envWithJournald := os.Getenv("STEGODON_WITH_JOURNALD")
 	envWithPprof := os.Getenv("STEGODON_WITH_PPROF")
+	envLogFormat := os.Getenv("STEGODON_LOG_FORMAT")
+	envSigCacheTTL := os.Getenv("STEGODON_SIG_CACHE_TTL")
+	envClockSkew := os.Getenv("STEGODON_CLOCK_SKEW")
+	envInboxDateSkew := os.Getenv("STEGODON_INBOX_DATE_SKEW")
+	envReplayCacheTTL := os.Getenv("STEGODON_REPLAY_CACHE_TTL")
+	envInboxQueue := os.Getenv("STEGODON_INBOX_QUEUE")
+	envHttpTimeout := os.Getenv("STEGODON_HTTP_TIMEOUT")
+	envHttpDialTimeout := os.Getenv("STEGODON_HTTP_DIAL_TIMEOUT")
+	envHttpTLSHandshakeTimeout := os.Getenv("STEGODON_HTTP_TLS_HANDSHAKE_TIMEOUT")
+	envHttpMaxIdleConnsPerHost := os.Getenv("STEGODON_HTTP_MAX_IDLE_CONNS_PER_HOST")
+	envHttpDisableCompression := os.Getenv("STEGODON_HTTP_DISABLE_COMPRESSION")
+	envDeliveryConcurrency := os.Getenv("STEGODON_DELIVERY_CONCURRENCY")
+	envRelayStaleHours := os.Getenv("STEGODON_RELAY_STALE_HOURS")
+	envBlockedDomains := os.Getenv("STEGODON_BLOCKED_DOMAINS")
+	envBlockedActors := os.Getenv("STEGODON_BLOCKED_ACTORS")
+	envSecureMode := os.Getenv("STEGODON_SECURE_MODE")
+	envTrustedRelays := os.Getenv("STEGODON_TRUSTED_RELAYS")
+	envDeniedRelays := os.Getenv("STEGODON_DENIED_RELAYS")
+	envTombstoneRetentionDays := os.Getenv("STEGODON_TOMBSTONE_RETENTION_DAYS")
+	envReplyCountMode := os.Getenv("STEGODON_REPLY_COUNT_MODE")
+	envHomeTimelineQuery := os.Getenv("STEGODON_HOME_TIMELINE_QUERY")
+	envInboundCreatePolicy := os.Getenv("STEGODON_INBOUND_CREATE_POLICY")
+	envRegistrationPolicy := os.Getenv("STEGODON_REGISTRATION_POLICY")
+	envOptInPublicTimeline := os.Getenv("STEGODON_OPT_IN_PUBLIC_TIMELINE")
+	envMaxNoteChars := os.Getenv("STEGODON_MAX_NOTE_CHARS")
+	envMaxInboundNoteChars := os.Getenv("STEGODON_MAX_INBOUND_NOTE_CHARS")
+	envMaxInboundContentBytes := os.Getenv("STEGODON_MAX_INBOUND_CONTENT_BYTES")
+	envInboundContentPolicy := os.Getenv("STEGODON_INBOUND_CONTENT_POLICY")
+	envMaxThreadDepth := os.Getenv("STEGODON_MAX_THREAD_DEPTH")
+	envMaxThreadFetches := os.Getenv("STEGODON_MAX_THREAD_FETCHES")
+	envThreadImportTimeout := os.Getenv("STEGODON_THREAD_IMPORT_TIMEOUT")
+	envPruneRemoteAccounts := os.Getenv("STEGODON_PRUNE_REMOTE_ACCOUNTS")
+	envRemoteAccountRetentionDays := os.Getenv("STEGODON_REMOTE_ACCOUNT_RETENTION_DAYS")
+	envAllowPrivateFetch := os.Getenv("STEGODON_ALLOW_PRIVATE_FETCH")
+	envAllowHttpFetch := os.Getenv("STEGODON_ALLOW_HTTP_FETCH")
+	envMaxFetchBytes := os.Getenv("STEGODON_MAX_FETCH_BYTES")
+	envUserAgent := os.Getenv("STEGODON_USER_AGENT")
+	envDbCacheSizeKB := os.Getenv("STEGODON_DB_CACHE_SIZE_KB")
+	envDbBusyTimeout := os.Getenv("STEGODON_DB_BUSY_TIMEOUT")
+	envDbSynchronous := os.Getenv("STEGODON_DB_SYNCHRONOUS")
+	envDbCheckpointInterval := os.Getenv("STEGODON_DB_CHECKPOINT_INTERVAL")
 
 	if envHost != "" {
 		c.Conf.Host = envHost
@@ -116,6 +307,22 @@ func ReadConf() (*AppConfig, error) {
 		c.Conf.NodeDescription = envNodeDescription
 	}
 
+	if envNodeName != "" {
+		c.Conf.NodeName = envNodeName
+	}
+
+	if envContactAccount != "" {
+		c.Conf.ContactAccount = envContactAccount
+	}
+
+	if envContactEmail != "" {
+		c.Conf.ContactEmail = envContactEmail
+	}
+
+	if envLanguages != "" {
+		c.Conf.Languages = strings.Split(envLanguages, ",")
+	}
+
 	if envWithJournald == "true" {
 		c.Conf.WithJournald = true
 	}
@@ -124,5 +331,725 @@ func ReadConf() (*AppConfig, error) {
 		c.Conf.WithPprof = true
 	}
 
+	if envLogFormat != "" {
+		c.Conf.LogFormat = envLogFormat
+	}
+
+	if envSigCacheTTL != "" {
+		v, err := strconv.Atoi(envSigCacheTTL)
+		if err != nil {
+			log.Printf("Error parsing STEGODON_SIG_CACHE_TTL: %v", err)
+		}
+		c.Conf.SigCacheTTL = v
+	}
+
+	if envClockSkew != "" {
+		v, err := strconv.Atoi(envClockSkew)
+		if err != nil {
+			log.Printf("Error parsing STEGODON_CLOCK_SKEW: %v", err)
+		}
+		c.Conf.ClockSkew = v
+	}
+
+	if envInboxDateSkew != "" {
+		v, err := strconv.Atoi(envInboxDateSkew)
+		if err != nil {
+			log.Printf("Error parsing STEGODON_INBOX_DATE_SKEW: %v", err)
+		}
+		c.Conf.InboxDateSkew = v
+	}
+
+	if envReplayCacheTTL != "" {
+		v, err := strconv.Atoi(envReplayCacheTTL)
+		if err != nil {
+			log.Printf("Error parsing STEGODON_REPLAY_CACHE_TTL: %v", err)
+		}
+		c.Conf.ReplayCacheTTL = v
+	}
+
+	if envInboxQueue == "true" {
+		c.Conf.InboxQueue = true
+	}
+
+	if envHttpTimeout != "" {
+		v, err := strconv.Atoi(envHttpTimeout)
+		if err != nil {
+			log.Printf("Error parsing STEGODON_HTTP_TIMEOUT: %v", err)
+		}
+		c.Conf.HttpTimeout = v
+	}
+
+	if envHttpDialTimeout != "" {
+		v, err := strconv.Atoi(envHttpDialTimeout)
+		if err != nil {
+			log.Printf("Error parsing STEGODON_HTTP_DIAL_TIMEOUT: %v", err)
+		}
+		c.Conf.HttpDialTimeout = v
+	}
+
+	if envHttpTLSHandshakeTimeout != "" {
+		v, err := strconv.Atoi(envHttpTLSHandshakeTimeout)
+		if err != nil {
+			log.Printf("Error parsing STEGODON_HTTP_TLS_HANDSHAKE_TIMEOUT: %v", err)
+		}
+		c.Conf.HttpTLSHandshakeTimeout = v
+	}
+
+	if envHttpMaxIdleConnsPerHost != "" {
+		v, err := strconv.Atoi(envHttpMaxIdleConnsPerHost)
+		if err != nil {
+			log.Printf("Error parsing STEGODON_HTTP_MAX_IDLE_CONNS_PER_HOST: %v", err)
+		}
+		c.Conf.HttpMaxIdleConnsPerHost = v
+	}
+
+	if envHttpDisableCompression == "true" {
+		c.Conf.HttpDisableCompression = true
+	}
+
+	if envDeliveryConcurrency != "" {
+		v, err := strconv.Atoi(envDeliveryConcurrency)
+		if err != nil {
+			log.Printf("Error parsing STEGODON_DELIVERY_CONCURRENCY: %v", err)
+		}
+		c.Conf.DeliveryConcurrency = v
+	}
+
+	if envRelayStaleHours != "" {
+		v, err := strconv.Atoi(envRelayStaleHours)
+		if err != nil {
+			log.Printf("Error parsing STEGODON_RELAY_STALE_HOURS: %v", err)
+		}
+		c.Conf.RelayStaleHours = v
+	}
+
+	if envTombstoneRetentionDays != "" {
+		v, err := strconv.Atoi(envTombstoneRetentionDays)
+		if err != nil {
+			log.Printf("Error parsing STEGODON_TOMBSTONE_RETENTION_DAYS: %v", err)
+		}
+		c.Conf.TombstoneRetentionDays = v
+	}
+
+	if envReplyCountMode != "" {
+		c.Conf.ReplyCountMode = envReplyCountMode
+	}
+
+	if envHomeTimelineQuery != "" {
+		c.Conf.HomeTimelineQuery = envHomeTimelineQuery
+	}
+
+	if envInboundCreatePolicy != "" {
+		c.Conf.InboundCreatePolicy = envInboundCreatePolicy
+	}
+
+	if envRegistrationPolicy != "" {
+		c.Conf.RegistrationPolicy = envRegistrationPolicy
+	}
+
+	if envOptInPublicTimeline == "true" {
+		c.Conf.OptInPublicTimeline = true
+	}
+
+	if envPruneRemoteAccounts == "true" {
+		c.Conf.PruneRemoteAccounts = true
+	}
+
+	if envRemoteAccountRetentionDays != "" {
+		v, err := strconv.Atoi(envRemoteAccountRetentionDays)
+		if err != nil {
+			log.Printf("Error parsing STEGODON_REMOTE_ACCOUNT_RETENTION_DAYS: %v", err)
+		}
+		c.Conf.RemoteAccountRetentionDays = v
+	}
+
+	if envMaxNoteChars != "" {
+		v, err := strconv.Atoi(envMaxNoteChars)
+		if err != nil {
+			log.Printf("Error parsing STEGODON_MAX_NOTE_CHARS: %v", err)
+		}
+		c.Conf.MaxNoteChars = v
+	}
+
+	if envMaxInboundNoteChars != "" {
+		v, err := strconv.Atoi(envMaxInboundNoteChars)
+		if err != nil {
+			log.Printf("Error parsing STEGODON_MAX_INBOUND_NOTE_CHARS: %v", err)
+		}
+		c.Conf.MaxInboundNoteChars = v
+	}
+
+	if envMaxInboundContentBytes != "" {
+		v, err := strconv.Atoi(envMaxInboundContentBytes)
+		if err != nil {
+			log.Printf("Error parsing STEGODON_MAX_INBOUND_CONTENT_BYTES: %v", err)
+		}
+		c.Conf.MaxInboundContentBytes = v
+	}
+
+	if envInboundContentPolicy != "" {
+		c.Conf.InboundContentPolicy = envInboundContentPolicy
+	}
+
+	if envMaxThreadDepth != "" {
+		v, err := strconv.Atoi(envMaxThreadDepth)
+		if err != nil {
+			log.Printf("Error parsing STEGODON_MAX_THREAD_DEPTH: %v", err)
+		}
+		c.Conf.MaxThreadDepth = v
+	}
+
+	if envMaxThreadFetches != "" {
+		v, err := strconv.Atoi(envMaxThreadFetches)
+		if err != nil {
+			log.Printf("Error parsing STEGODON_MAX_THREAD_FETCHES: %v", err)
+		}
+		c.Conf.MaxThreadFetches = v
+	}
+
+	if envThreadImportTimeout != "" {
+		v, err := strconv.Atoi(envThreadImportTimeout)
+		if err != nil {
+			log.Printf("Error parsing STEGODON_THREAD_IMPORT_TIMEOUT: %v", err)
+		}
+		c.Conf.ThreadImportTimeout = v
+	}
+
+	if envAllowPrivateFetch == "true" {
+		c.Conf.AllowPrivateFetch = true
+	}
+
+	if envAllowHttpFetch == "true" {
+		c.Conf.AllowHttpFetch = true
+	}
+
+	if envMaxFetchBytes != "" {
+		v, err := strconv.ParseInt(envMaxFetchBytes, 10, 64)
+		if err != nil {
+			log.Printf("Error parsing STEGODON_MAX_FETCH_BYTES: %v", err)
+		}
+		c.Conf.MaxFetchBytes = v
+	}
+
+	if envUserAgent != "" {
+		c.Conf.UserAgent = envUserAgent
+	}
+
+	if envDbCacheSizeKB != "" {
+		v, err := strconv.Atoi(envDbCacheSizeKB)
+		if err != nil {
+			log.Printf("Error parsing STEGODON_DB_CACHE_SIZE_KB: %v", err)
+		}
+		c.Conf.DbCacheSizeKB = v
+	}
+
+	if envDbBusyTimeout != "" {
+		v, err := strconv.Atoi(envDbBusyTimeout)
+		if err != nil {
+			log.Printf("Error parsing STEGODON_DB_BUSY_TIMEOUT: %v", err)
+		}
+		c.Conf.DbBusyTimeout = v
+	}
+
+	if envDbSynchronous != "" {
+		c.Conf.DbSynchronous = envDbSynchronous
+	}
+
+	if envDbCheckpointInterval != "" {
+		v, err := strconv.Atoi(envDbCheckpointInterval)
+		if err != nil {
+			log.Printf("Error parsing STEGODON_DB_CHECKPOINT_INTERVAL: %v", err)
+		}
+		c.Conf.DbCheckpointInterval = v
+	}
+
+	if envBlockedDomains != "" {
+		c.Conf.BlockedDomains = strings.Split(envBlockedDomains, ",")
+	}
+
+	if envBlockedActors != "" {
+		c.Conf.BlockedActors = strings.Split(envBlockedActors, ",")
+	}
+
+	if envSecureMode == "true" {
+		c.Conf.SecureMode = true
+	}
+
+	if envTrustedRelays != "" {
+		c.Conf.TrustedRelays = strings.Split(envTrustedRelays, ",")
+	}
+
+	if envDeniedRelays != "" {
+		c.Conf.DeniedRelays = strings.Split(envDeniedRelays, ",")
+	}
+
+	// inboxDateSkew is the deprecated name of clockSkew
+	if c.Conf.ClockSkew == 0 {
+		c.Conf.ClockSkew = c.Conf.InboxDateSkew
+	}
+
 	return c, nil
 }
+
+// hostnameLabelRegex matches a single DNS label (letters, digits and inner hyphens)
+var hostnameLabelRegex = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// Normalize cleans up values that are commonly written in a slightly wrong form,
+// such as an SslDomain given as a URL ("https://Example.com/") instead of a hostname
+func (c *AppConfig) Normalize() {
+	c.Conf.Host = strings.TrimSpace(c.Conf.Host)
+
+	domain := strings.ToLower(strings.TrimSpace(c.Conf.SslDomain))
+	domain = strings.TrimPrefix(domain, "https://")
+	domain = strings.TrimPrefix(domain, "http://")
+	c.Conf.SslDomain = strings.TrimSuffix(domain, "/")
+
+	c.Conf.LogFormat = strings.ToLower(strings.TrimSpace(c.Conf.LogFormat))
+	c.Conf.ReplyCountMode = strings.ToLower(strings.TrimSpace(c.Conf.ReplyCountMode))
+	c.Conf.HomeTimelineQuery = strings.ToLower(strings.TrimSpace(c.Conf.HomeTimelineQuery))
+	c.Conf.InboundCreatePolicy = strings.ToLower(strings.TrimSpace(c.Conf.InboundCreatePolicy))
+	c.Conf.InboundContentPolicy = strings.ToLower(strings.TrimSpace(c.Conf.InboundContentPolicy))
+	c.Conf.RegistrationPolicy = strings.ToLower(strings.TrimSpace(c.Conf.RegistrationPolicy))
+	c.Conf.DbSynchronous = strings.ToLower(strings.TrimSpace(c.Conf.DbSynchronous))
+
+	c.Conf.NodeName = strings.TrimSpace(c.Conf.NodeName)
+	c.Conf.ContactAccount = strings.TrimPrefix(strings.TrimSpace(c.Conf.ContactAccount), "@")
+	c.Conf.ContactEmail = strings.TrimSpace(c.Conf.ContactEmail)
+
+	// Tags such as "en-US" become their language code; anything else is left for Validate to report
+	languages := c.Conf.Languages[:0]
+	for _, l := range c.Conf.Languages {
+		if l = strings.TrimSpace(l); l == "" {
+			continue
+		}
+		if code := NormalizeLanguage(l); code != "" {
+			l = code
+		}
+		if !slices.Contains(languages, l) {
+			languages = append(languages, l)
+		}
+	}
+	c.Conf.Languages = languages
+
+	rules := c.Conf.Rules[:0]
+	for _, r := range c.Conf.Rules {
+		if r = strings.TrimSpace(r); r != "" {
+			rules = append(rules, r)
+		}
+	}
+	c.Conf.Rules = rules
+
+	blockedDomains := c.Conf.BlockedDomains[:0]
+	for _, d := range c.Conf.BlockedDomains {
+		if d = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(d)), "*."); d != "" {
+			blockedDomains = append(blockedDomains, d)
+		}
+	}
+	c.Conf.BlockedDomains = blockedDomains
+
+	blockedActors := c.Conf.BlockedActors[:0]
+	for _, a := range c.Conf.BlockedActors {
+		if a = strings.TrimSpace(a); a != "" {
+			blockedActors = append(blockedActors, a)
+		}
+	}
+	c.Conf.BlockedActors = blockedActors
+
+	c.Conf.TrustedRelays = normalizeRelayList(c.Conf.TrustedRelays)
+	c.Conf.DeniedRelays = normalizeRelayList(c.Conf.DeniedRelays)
+}
+
+// normalizeRelayList trims relay entries, drops empty ones and lowercases domains.
+// Actor URIs keep their case but lose a trailing slash.
+func normalizeRelayList(entries []string) []string {
+	normalized := entries[:0]
+	for _, e := range entries {
+		e = strings.TrimSpace(e)
+		if strings.Contains(e, "://") {
+			e = strings.TrimSuffix(e, "/")
+		} else {
+			e = strings.TrimPrefix(strings.ToLower(e), "*.")
+		}
+		if e != "" {
+			normalized = append(normalized, e)
+		}
+	}
+	return normalized
+}
+
+// IsBlockedActor reports whether an actor is blocked, either by its URI or because
+// its server (or a parent domain of it) is a blocked domain
+func (c *AppConfig) IsBlockedActor(actorURI string) bool {
+	if slices.Contains(c.Conf.BlockedActors, actorURI) {
+		return true
+	}
+	parsed, err := url.Parse(actorURI)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(parsed.Hostname())
+	for _, blocked := range c.Conf.BlockedDomains {
+		if host == blocked || strings.HasSuffix(host, "."+blocked) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsTrustedRelay reports whether content from a relay actor is accepted. Denied relays are
+// never trusted; otherwise a relay is trusted if no allowlist is configured or it is on it.
+func (c *AppConfig) IsTrustedRelay(actorURI string) bool {
+	if matchesRelayList(c.Conf.DeniedRelays, actorURI) {
+		return false
+	}
+	return len(c.Conf.TrustedRelays) == 0 || matchesRelayList(c.Conf.TrustedRelays, actorURI)
+}
+
+// matchesRelayList reports whether an actor is listed by its URI, or its server
+// (or a parent domain of it) is listed by domain
+func matchesRelayList(entries []string, actorURI string) bool {
+	actorURI = strings.TrimSuffix(actorURI, "/")
+	parsed, err := url.Parse(actorURI)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(parsed.Hostname())
+	for _, entry := range entries {
+		if strings.Contains(entry, "://") {
+			if entry == actorURI {
+				return true
+			}
+		} else if host != "" && (host == entry || strings.HasSuffix(host, "."+entry)) {
+			return true
+		}
+	}
+	return false
+}
+
+// NoteCharLimit returns the maximum number of visible characters in a local note.
+// A nil config uses the default.
+func (c *AppConfig) NoteCharLimit() int {
+	if c != nil && c.Conf.MaxNoteChars > 0 {
+		return c.Conf.MaxNoteChars
+	}
+	return DefaultMaxNoteChars
+}
+
+// InboundNoteCharLimit returns the maximum number of plain-text characters in a remote post.
+// A nil config uses the default.
+func (c *AppConfig) InboundNoteCharLimit() int {
+	if c != nil && c.Conf.MaxInboundNoteChars > 0 {
+		return c.Conf.MaxInboundNoteChars
+	}
+	return DefaultMaxInboundNoteChars
+}
+
+// InboundContentByteLimit returns the maximum size in bytes of a remote post's HTML content.
+// A nil config uses the default.
+func (c *AppConfig) InboundContentByteLimit() int {
+	if c != nil && c.Conf.MaxInboundContentBytes > 0 {
+		return c.Conf.MaxInboundContentBytes
+	}
+	return DefaultMaxInboundContentBytes
+}
+
+// InboundContentOverLimitPolicy returns how remote posts with larger content are handled.
+// A nil config uses the default.
+func (c *AppConfig) InboundContentOverLimitPolicy() string {
+	if c == nil || c.Conf.InboundContentPolicy == "" {
+		return InboundContentPolicyTruncate
+	}
+	return c.Conf.InboundContentPolicy
+}
+
+// ThreadDepthLimit returns how far a conversation import walks up the ancestor chain and
+// down the reply tree. A nil config uses the default, as do the other import limits.
+func (c *AppConfig) ThreadDepthLimit() int {
+	if c != nil && c.Conf.MaxThreadDepth > 0 {
+		return c.Conf.MaxThreadDepth
+	}
+	return DefaultMaxThreadDepth
+}
+
+// ThreadFetchLimit returns how many posts and reply pages a conversation import fetches
+func (c *AppConfig) ThreadFetchLimit() int {
+	if c != nil && c.Conf.MaxThreadFetches > 0 {
+		return c.Conf.MaxThreadFetches
+	}
+	return DefaultMaxThreadFetches
+}
+
+// ThreadImportDeadline returns how long a conversation import may take
+func (c *AppConfig) ThreadImportDeadline() time.Duration {
+	if c != nil && c.Conf.ThreadImportTimeout > 0 {
+		return time.Duration(c.Conf.ThreadImportTimeout) * time.Second
+	}
+	return DefaultThreadImportTimeout * time.Second
+}
+
+// RegistrationPolicy returns how new SSH keys are handled: RegistrationPolicyClosed if
+// closed is set, otherwise the configured policy, RegistrationPolicyOpen by default
+func (c *AppConfig) RegistrationPolicy() string {
+	if c == nil {
+		return RegistrationPolicyOpen
+	}
+	if c.Conf.Closed {
+		return RegistrationPolicyClosed
+	}
+	if c.Conf.RegistrationPolicy == "" {
+		return RegistrationPolicyOpen
+	}
+	return c.Conf.RegistrationPolicy
+}
+
+// NodeName returns the instance title, falling back to DefaultNodeName
+func (c *AppConfig) NodeName() string {
+	if c != nil && c.Conf.NodeName != "" {
+		return c.Conf.NodeName
+	}
+	return DefaultNodeName
+}
+
+// NodeDescription returns the instance description, falling back to DefaultNodeDescription
+func (c *AppConfig) NodeDescription() string {
+	if c != nil && c.Conf.NodeDescription != "" {
+		return c.Conf.NodeDescription
+	}
+	return DefaultNodeDescription
+}
+
+// FederationUserAgent returns the User-Agent of outgoing federation requests: the configured
+// one, or the stegodon version with a link to the instance so remote admins can identify it
+func (c *AppConfig) FederationUserAgent() string {
+	if c != nil && c.Conf.UserAgent != "" {
+		return c.Conf.UserAgent
+	}
+	userAgent := "stegodon/" + GetVersion()
+	if c != nil && c.Conf.SslDomain != "" {
+		userAgent += " (+https://" + c.Conf.SslDomain + ")"
+	}
+	return userAgent
+}
+
+// DeliveryConcurrency returns how many servers the delivery worker sends to at once.
+// A nil config uses the default.
+func (c *AppConfig) DeliveryConcurrency() int {
+	if c != nil && c.Conf.DeliveryConcurrency > 0 {
+		return c.Conf.DeliveryConcurrency
+	}
+	return DefaultDeliveryConcurrency
+}
+
+// DbCacheSizeKB returns the SQLite page cache per connection in KiB.
+// A nil config uses the default, as do the other database settings.
+func (c *AppConfig) DbCacheSizeKB() int {
+	if c != nil && c.Conf.DbCacheSizeKB > 0 {
+		return c.Conf.DbCacheSizeKB
+	}
+	return DefaultDbCacheSizeKB
+}
+
+// DbBusyTimeout returns how many milliseconds a query waits for a locked database
+func (c *AppConfig) DbBusyTimeout() int {
+	if c != nil && c.Conf.DbBusyTimeout > 0 {
+		return c.Conf.DbBusyTimeout
+	}
+	return DefaultDbBusyTimeout
+}
+
+// DbSynchronous returns the SQLite synchronous mode
+func (c *AppConfig) DbSynchronous() string {
+	if c != nil && c.Conf.DbSynchronous != "" {
+		return c.Conf.DbSynchronous
+	}
+	return DefaultDbSynchronous
+}
+
+// DbCheckpointInterval returns the time between passive WAL checkpoints, or 0 if they are off
+func (c *AppConfig) DbCheckpointInterval() time.Duration {
+	switch {
+	case c == nil || c.Conf.DbCheckpointInterval == 0:
+		return DefaultDbCheckpointInterval * time.Second
+	case c.Conf.DbCheckpointInterval < 0:
+		return 0
+	}
+	return time.Duration(c.Conf.DbCheckpointInterval) * time.Second
+}
+
+// BuildNoteObjectURI returns the ActivityPub object URI of a local note
+func BuildNoteObjectURI(conf *AppConfig, noteId uuid.UUID) string {
+	return fmt.Sprintf("https://%s/notes/%s", conf.Conf.SslDomain, noteId)
+}
+
+// Validate checks the configuration for problems that would otherwise only show up later,
+// e.g. a bad SslDomain breaking federation. It returns every problem found at once,
+// joined into a single error, or nil if the configuration is usable.
+func (c *AppConfig) Validate() error {
+	var errs []error
+
+	if err := validateDomain(c.Conf.SslDomain); err != nil {
+		errs = append(errs, fmt.Errorf("sslDomain: %w", err))
+	}
+	if c.Conf.Host != "" && net.ParseIP(c.Conf.Host) == nil && validateHostname(c.Conf.Host) != nil {
+		errs = append(errs, fmt.Errorf("host: %q is neither an IP address nor a hostname", c.Conf.Host))
+	}
+
+	if c.Conf.LogFormat != "" && c.Conf.LogFormat != LogFormatText && c.Conf.LogFormat != LogFormatJSON {
+		errs = append(errs, fmt.Errorf("logFormat: must be %q or %q, got %q", LogFormatText, LogFormatJSON, c.Conf.LogFormat))
+	}
+
+	if c.Conf.ReplyCountMode != "" && c.Conf.ReplyCountMode != ReplyCountModeStored && c.Conf.ReplyCountMode != ReplyCountModeThread {
+		errs = append(errs, fmt.Errorf("replyCountMode: must be %q or %q, got %q", ReplyCountModeStored, ReplyCountModeThread, c.Conf.ReplyCountMode))
+	}
+
+	if c.Conf.HomeTimelineQuery != "" && c.Conf.HomeTimelineQuery != HomeTimelineQueryMerge && c.Conf.HomeTimelineQuery != HomeTimelineQueryUnion {
+		errs = append(errs, fmt.Errorf("homeTimelineQuery: must be %q or %q, got %q", HomeTimelineQueryMerge, HomeTimelineQueryUnion, c.Conf.HomeTimelineQuery))
+	}
+
+	switch c.Conf.InboundCreatePolicy {
+	case "", CreatePolicyReject, CreatePolicyStoreIfMentioned, CreatePolicyStoreAll:
+	default:
+		errs = append(errs, fmt.Errorf("inboundCreatePolicy: must be %q, %q or %q, got %q",
+			CreatePolicyReject, CreatePolicyStoreIfMentioned, CreatePolicyStoreAll, c.Conf.InboundCreatePolicy))
+	}
+
+	switch c.Conf.InboundContentPolicy {
+	case "", InboundContentPolicyTruncate, InboundContentPolicyReject:
+	default:
+		errs = append(errs, fmt.Errorf("inboundContentPolicy: must be %q or %q, got %q",
+			InboundContentPolicyTruncate, InboundContentPolicyReject, c.Conf.InboundContentPolicy))
+	}
+
+	switch c.Conf.RegistrationPolicy {
+	case "", RegistrationPolicyOpen, RegistrationPolicyApproval, RegistrationPolicyClosed:
+	default:
+		errs = append(errs, fmt.Errorf("registrationPolicy: must be %q, %q or %q, got %q",
+			RegistrationPolicyOpen, RegistrationPolicyApproval, RegistrationPolicyClosed, c.Conf.RegistrationPolicy))
+	}
+
+	switch c.Conf.DbSynchronous {
+	case "", "off", "normal", "full", "extra":
+	default:
+		errs = append(errs, fmt.Errorf("dbSynchronous: must be \"off\", \"normal\", \"full\" or \"extra\", got %q", c.Conf.DbSynchronous))
+	}
+
+	if err := validatePort(c.Conf.SshPort); err != nil {
+		errs = append(errs, fmt.Errorf("sshPort: %w", err))
+	}
+	if err := validatePort(c.Conf.HttpPort); err != nil {
+		errs = append(errs, fmt.Errorf("httpPort: %w", err))
+	}
+	if c.Conf.SshPort == c.Conf.HttpPort {
+		errs = append(errs, fmt.Errorf("sshPort and httpPort must differ, both are %d", c.Conf.SshPort))
+	}
+
+	// 0 selects the default for all of these, negative values are never meaningful
+	for _, setting := range []struct {
+		name  string
+		value int
+	}{
+		{"httpTimeout", c.Conf.HttpTimeout},
+		{"httpDialTimeout", c.Conf.HttpDialTimeout},
+		{"httpTlsHandshakeTimeout", c.Conf.HttpTLSHandshakeTimeout},
+		{"httpMaxIdleConnsPerHost", c.Conf.HttpMaxIdleConnsPerHost},
+		{"deliveryConcurrency", c.Conf.DeliveryConcurrency},
+		{"relayStaleHours", c.Conf.RelayStaleHours},
+		{"tombstoneRetentionDays", c.Conf.TombstoneRetentionDays},
+		{"remoteAccountRetentionDays", c.Conf.RemoteAccountRetentionDays},
+		{"maxNoteChars", c.Conf.MaxNoteChars},
+		{"maxInboundNoteChars", c.Conf.MaxInboundNoteChars},
+		{"maxInboundContentBytes", c.Conf.MaxInboundContentBytes},
+		{"maxThreadDepth", c.Conf.MaxThreadDepth},
+		{"maxThreadFetches", c.Conf.MaxThreadFetches},
+		{"threadImportTimeout", c.Conf.ThreadImportTimeout},
+		{"dbCacheSizeKb", c.Conf.DbCacheSizeKB},
+		{"dbBusyTimeout", c.Conf.DbBusyTimeout},
+	} {
+		if setting.value < 0 {
+			errs = append(errs, fmt.Errorf("%s: must not be negative (0 uses the default), got %d", setting.name, setting.value))
+		}
+	}
+
+	for _, l := range c.Conf.Languages {
+		if NormalizeLanguage(l) != l {
+			errs = append(errs, fmt.Errorf("languages: %q is not an ISO 639 language code", l))
+		}
+	}
+	if c.Conf.ContactAccount != "" && strings.Contains(c.Conf.ContactAccount, "@") {
+		errs = append(errs, fmt.Errorf("contactAccount: must be the username of a local account, got %q", c.Conf.ContactAccount))
+	}
+
+	if c.Conf.MaxNoteChars > MaxNoteDBLength {
+		errs = append(errs, fmt.Errorf("maxNoteChars: must be at most %d (the database limit), got %d", MaxNoteDBLength, c.Conf.MaxNoteChars))
+	}
+
+	if c.Conf.MaxFetchBytes < 0 {
+		errs = append(errs, fmt.Errorf("maxFetchBytes: must not be negative (0 uses the default), got %d", c.Conf.MaxFetchBytes))
+	}
+
+	// The database and the SSH host key are created on first start
+	for _, path := range []string{ResolveFilePath("database.db"), ResolveFilePathWithSubdir(".ssh", "stegodonhostkey")} {
+		if err := checkWritableDir(filepath.Dir(path)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateDomain checks that domain is a hostname with an optional port, as used in actor URIs
+func validateDomain(domain string) error {
+	if domain == "" {
+		return errors.New("must be set to the domain this server is reachable at")
+	}
+	host := domain
+	if h, port, err := net.SplitHostPort(domain); err == nil {
+		if err := validatePortString(port); err != nil {
+			return fmt.Errorf("%q: %w", domain, err)
+		}
+		host = h
+	}
+	if err := validateHostname(host); err != nil {
+		return fmt.Errorf("%q: %w", domain, err)
+	}
+	return nil
+}
+
+// validateHostname checks that host is a syntactically valid DNS hostname
+func validateHostname(host string) error {
+	if len(host) > 253 {
+		return errors.New("hostname is longer than 253 characters")
+	}
+	for _, label := range strings.Split(strings.ToLower(host), ".") {
+		if !hostnameLabelRegex.MatchString(label) {
+			return errors.New("not a valid hostname (expected e.g. example.com, without scheme or path)")
+		}
+	}
+	return nil
+}
+
+func validatePort(port int) error {
+	if port < 1 || port > 65535 {
+		return fmt.Errorf("must be between 1 and 65535, got %d", port)
+	}
+	return nil
+}
+
+func validatePortString(port string) error {
+	v, err := strconv.Atoi(port)
+	if err != nil {
+		return fmt.Errorf("invalid port %q", port)
+	}
+	return validatePort(v)
+}
+
+// checkWritableDir checks that files can be created in dir
+func checkWritableDir(dir string) error {
+	f, err := os.CreateTemp(dir, ".stegodon-write-check-*")
+	if err != nil {
+		return fmt.Errorf("directory %s is not writable: %w", dir, err)
+	}
+	f.Close()
+	os.Remove(f.Name())
+	return nil
+}
@@ -2,7 +2,10 @@ package util
 
 import (
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestConfigConstants(t *testing.T) {
@@ -115,6 +118,137 @@ conf:
 	}
 }
 
+func TestReadConfHttpClientEnv(t *testing.T) {
+	os.Setenv("STEGODON_HTTP_TIMEOUT", "20")
+	os.Setenv("STEGODON_HTTP_DIAL_TIMEOUT", "3")
+	os.Setenv("STEGODON_HTTP_TLS_HANDSHAKE_TIMEOUT", "4")
+	os.Setenv("STEGODON_HTTP_MAX_IDLE_CONNS_PER_HOST", "8")
+	os.Setenv("STEGODON_DELIVERY_CONCURRENCY", "2")
+	defer func() {
+		os.Unsetenv("STEGODON_HTTP_TIMEOUT")
+		os.Unsetenv("STEGODON_HTTP_DIAL_TIMEOUT")
+		os.Unsetenv("STEGODON_HTTP_TLS_HANDSHAKE_TIMEOUT")
+		os.Unsetenv("STEGODON_HTTP_MAX_IDLE_CONNS_PER_HOST")
+		os.Unsetenv("STEGODON_DELIVERY_CONCURRENCY")
+	}()
+
+	config, err := ReadConf()
+	if err != nil {
+		t.Fatalf("ReadConf failed: %v", err)
+	}
+
+	if config.Conf.HttpTimeout != 20 {
+		t.Errorf("Expected HttpTimeout 20 from env, got %d", config.Conf.HttpTimeout)
+	}
+	if config.Conf.HttpDialTimeout != 3 {
+		t.Errorf("Expected HttpDialTimeout 3 from env, got %d", config.Conf.HttpDialTimeout)
+	}
+	if config.Conf.HttpTLSHandshakeTimeout != 4 {
+		t.Errorf("Expected HttpTLSHandshakeTimeout 4 from env, got %d", config.Conf.HttpTLSHandshakeTimeout)
+	}
+	if config.Conf.HttpMaxIdleConnsPerHost != 8 {
+		t.Errorf("Expected HttpMaxIdleConnsPerHost 8 from env, got %d", config.Conf.HttpMaxIdleConnsPerHost)
+	}
+	if config.DeliveryConcurrency() != 2 {
+		t.Errorf("Expected DeliveryConcurrency 2 from env, got %d", config.DeliveryConcurrency())
+	}
+	if (&AppConfig{}).DeliveryConcurrency() != DefaultDeliveryConcurrency {
+		t.Error("Expected the default delivery concurrency when unset")
+	}
+}
+
+func TestReadConfInboxQueueEnv(t *testing.T) {
+	t.Setenv("STEGODON_INBOX_QUEUE", "true")
+
+	config, err := ReadConf()
+	if err != nil {
+		t.Fatalf("ReadConf failed: %v", err)
+	}
+	if !config.Conf.InboxQueue {
+		t.Error("Expected InboxQueue to be true from env")
+	}
+}
+
+func TestReadConfRelayStaleHoursEnv(t *testing.T) {
+	os.Setenv("STEGODON_RELAY_STALE_HOURS", "6")
+	defer os.Unsetenv("STEGODON_RELAY_STALE_HOURS")
+
+	config, err := ReadConf()
+	if err != nil {
+		t.Fatalf("ReadConf failed: %v", err)
+	}
+
+	if config.Conf.RelayStaleHours != 6 {
+		t.Errorf("Expected RelayStaleHours 6 from env, got %d", config.Conf.RelayStaleHours)
+	}
+}
+
+func TestReadConfClockSkewEnv(t *testing.T) {
+	t.Setenv("STEGODON_INBOX_DATE_SKEW", "600")
+
+	config, err := ReadConf()
+	if err != nil {
+		t.Fatalf("ReadConf failed: %v", err)
+	}
+	if config.Conf.ClockSkew != 600 {
+		t.Errorf("Expected the deprecated inbox date skew to set ClockSkew 600, got %d", config.Conf.ClockSkew)
+	}
+
+	t.Setenv("STEGODON_CLOCK_SKEW", "120")
+	config, err = ReadConf()
+	if err != nil {
+		t.Fatalf("ReadConf failed: %v", err)
+	}
+	if config.Conf.ClockSkew != 120 {
+		t.Errorf("Expected ClockSkew 120 from env, got %d", config.Conf.ClockSkew)
+	}
+}
+
+func TestReadConfNoteCharsEnv(t *testing.T) {
+	t.Setenv("STEGODON_MAX_NOTE_CHARS", "500")
+	t.Setenv("STEGODON_MAX_INBOUND_NOTE_CHARS", "5000")
+
+	config, err := ReadConf()
+	if err != nil {
+		t.Fatalf("ReadConf failed: %v", err)
+	}
+
+	if config.NoteCharLimit() != 500 || config.InboundNoteCharLimit() != 5000 {
+		t.Errorf("Expected note limits 500 and 5000 from env, got %d and %d", config.NoteCharLimit(), config.InboundNoteCharLimit())
+	}
+}
+
+func TestNoteCharLimitDefaults(t *testing.T) {
+	var nilConf *AppConfig
+	if nilConf.NoteCharLimit() != DefaultMaxNoteChars || nilConf.InboundNoteCharLimit() != DefaultMaxInboundNoteChars {
+		t.Errorf("Expected the defaults for a nil config, got %d and %d", nilConf.NoteCharLimit(), nilConf.InboundNoteCharLimit())
+	}
+	if c := (&AppConfig{}); c.NoteCharLimit() != DefaultMaxNoteChars || c.InboundNoteCharLimit() != DefaultMaxInboundNoteChars {
+		t.Errorf("Expected the defaults for unset limits, got %d and %d", c.NoteCharLimit(), c.InboundNoteCharLimit())
+	}
+}
+
+func TestReadConfInboundContentEnv(t *testing.T) {
+	t.Setenv("STEGODON_MAX_INBOUND_CONTENT_BYTES", "10000")
+	t.Setenv("STEGODON_INBOUND_CONTENT_POLICY", "reject")
+
+	config, err := ReadConf()
+	if err != nil {
+		t.Fatalf("ReadConf failed: %v", err)
+	}
+
+	if config.InboundContentByteLimit() != 10000 || config.InboundContentOverLimitPolicy() != InboundContentPolicyReject {
+		t.Errorf("Expected 10000 bytes and %q from env, got %d and %q", InboundContentPolicyReject, config.InboundContentByteLimit(), config.InboundContentOverLimitPolicy())
+	}
+}
+
+func TestInboundContentDefaults(t *testing.T) {
+	var nilConf *AppConfig
+	if nilConf.InboundContentByteLimit() != DefaultMaxInboundContentBytes || nilConf.InboundContentOverLimitPolicy() != InboundContentPolicyTruncate {
+		t.Errorf("Expected the defaults for a nil config, got %d and %q", nilConf.InboundContentByteLimit(), nilConf.InboundContentOverLimitPolicy())
+	}
+}
+
 func TestReadConfMissingFile(t *testing.T) {
 	// Ensure config.yaml doesn't exist in current directory
 	os.Remove("config.yaml")
@@ -242,3 +376,277 @@ func TestAppConfigStruct(t *testing.T) {
 		t.Error("Expected WithAp to be true")
 	}
 }
+
+func validTestConfig() *AppConfig {
+	c := &AppConfig{}
+	c.Conf.Host = "127.0.0.1"
+	c.Conf.SshPort = 23232
+	c.Conf.HttpPort = 9999
+	c.Conf.SslDomain = "example.com"
+	return c
+}
+
+func TestNormalizeConfig(t *testing.T) {
+	c := validTestConfig()
+	c.Conf.Host = " 0.0.0.0 "
+	c.Conf.SslDomain = " https://Social.Example.com/ "
+	c.Normalize()
+
+	if c.Conf.Host != "0.0.0.0" {
+		t.Errorf("Expected Host '0.0.0.0', got '%s'", c.Conf.Host)
+	}
+	if c.Conf.SslDomain != "social.example.com" {
+		t.Errorf("Expected SslDomain 'social.example.com', got '%s'", c.Conf.SslDomain)
+	}
+}
+
+func TestNormalizeInstanceMetadata(t *testing.T) {
+	c := validTestConfig()
+	c.Conf.ContactAccount = " @admin "
+	c.Conf.Languages = []string{" en-US", "EN", "", "de_DE", "klingon"}
+	c.Conf.Rules = []string{" Be nice ", "  "}
+	c.Normalize()
+
+	if c.Conf.ContactAccount != "admin" {
+		t.Errorf("Expected ContactAccount 'admin', got '%s'", c.Conf.ContactAccount)
+	}
+	if strings.Join(c.Conf.Languages, ",") != "en,de,klingon" {
+		t.Errorf("Expected languages en,de,klingon, got %v", c.Conf.Languages)
+	}
+	if len(c.Conf.Rules) != 1 || c.Conf.Rules[0] != "Be nice" {
+		t.Errorf("Expected the rule 'Be nice', got %v", c.Conf.Rules)
+	}
+
+	t.Setenv("HOME", t.TempDir())
+	if err := c.Validate(); err == nil || !strings.Contains(err.Error(), `"klingon"`) {
+		t.Errorf("Expected an error for the language klingon, got: %v", err)
+	}
+}
+
+func TestRegistrationPolicy(t *testing.T) {
+	c := &AppConfig{}
+	if c.RegistrationPolicy() != RegistrationPolicyOpen {
+		t.Errorf("Expected the open policy by default, got %q", c.RegistrationPolicy())
+	}
+	c.Conf.RegistrationPolicy = RegistrationPolicyApproval
+	if c.RegistrationPolicy() != RegistrationPolicyApproval {
+		t.Errorf("Expected the approval policy, got %q", c.RegistrationPolicy())
+	}
+	c.Conf.Closed = true
+	if c.RegistrationPolicy() != RegistrationPolicyClosed {
+		t.Errorf("Expected closed to override the policy, got %q", c.RegistrationPolicy())
+	}
+
+	t.Setenv("HOME", t.TempDir())
+	v := validTestConfig()
+	v.Conf.RegistrationPolicy = "invite-only"
+	if err := v.Validate(); err == nil || !strings.Contains(err.Error(), "registrationPolicy") {
+		t.Errorf("Expected a registrationPolicy error, got: %v", err)
+	}
+}
+
+func TestDatabaseTuning(t *testing.T) {
+	var c *AppConfig
+	if c.DbCacheSizeKB() != DefaultDbCacheSizeKB || c.DbBusyTimeout() != DefaultDbBusyTimeout ||
+		c.DbSynchronous() != DefaultDbSynchronous || c.DbCheckpointInterval() != DefaultDbCheckpointInterval*time.Second {
+		t.Error("Expected the defaults for a nil config")
+	}
+
+	c = validTestConfig()
+	c.Conf.DbSynchronous = " FULL "
+	c.Conf.DbCheckpointInterval = -1
+	c.Normalize()
+	if c.DbSynchronous() != "full" || c.DbCheckpointInterval() != 0 {
+		t.Errorf("Expected full and no checkpoints, got %q and %s", c.DbSynchronous(), c.DbCheckpointInterval())
+	}
+
+	t.Setenv("HOME", t.TempDir())
+	c.Conf.DbSynchronous = "sometimes"
+	c.Conf.DbBusyTimeout = -5
+	err := c.Validate()
+	if err == nil || !strings.Contains(err.Error(), "dbSynchronous") || !strings.Contains(err.Error(), "dbBusyTimeout") {
+		t.Errorf("Expected dbSynchronous and dbBusyTimeout errors, got: %v", err)
+	}
+}
+
+func TestNodeNameDefaults(t *testing.T) {
+	c := &AppConfig{}
+	if c.NodeName() != DefaultNodeName || c.NodeDescription() != DefaultNodeDescription {
+		t.Errorf("Expected the defaults, got %q and %q", c.NodeName(), c.NodeDescription())
+	}
+	c.Conf.NodeName = "My node"
+	if c.NodeName() != "My node" {
+		t.Errorf("Expected the configured name, got %q", c.NodeName())
+	}
+}
+
+func TestFederationUserAgent(t *testing.T) {
+	var nilConf *AppConfig
+	if got := nilConf.FederationUserAgent(); got != "stegodon/"+GetVersion() {
+		t.Errorf("Expected the version for a nil config, got %q", got)
+	}
+	c := &AppConfig{}
+	c.Conf.SslDomain = "example.com"
+	if got := c.FederationUserAgent(); got != "stegodon/"+GetVersion()+" (+https://example.com)" {
+		t.Errorf("Expected the version and instance URL, got %q", got)
+	}
+
+	t.Setenv("STEGODON_USER_AGENT", "custom/2.0")
+	config, err := ReadConf()
+	if err != nil {
+		t.Fatalf("ReadConf failed: %v", err)
+	}
+	if got := config.FederationUserAgent(); got != "custom/2.0" {
+		t.Errorf("Expected the User-Agent from env, got %q", got)
+	}
+}
+
+func TestIsBlockedActor(t *testing.T) {
+	c := validTestConfig()
+	c.Conf.BlockedDomains = []string{" Spam.Example ", "*.bad.example", ""}
+	c.Conf.BlockedActors = []string{"https://ok.example/users/troll"}
+	c.Normalize()
+
+	tests := []struct {
+		actorURI string
+		blocked  bool
+	}{
+		{"https://spam.example/users/alice", true},
+		{"https://SPAM.example/users/alice", true},
+		{"https://sub.spam.example/users/alice", true},
+		{"https://mail.bad.example/users/alice", true},
+		{"https://notspam.example/users/alice", false},
+		{"https://ok.example/users/troll", true},
+		{"https://ok.example/users/alice", false},
+	}
+	for _, tt := range tests {
+		if got := c.IsBlockedActor(tt.actorURI); got != tt.blocked {
+			t.Errorf("IsBlockedActor(%q) = %v, want %v", tt.actorURI, got, tt.blocked)
+		}
+	}
+}
+
+func TestIsTrustedRelay(t *testing.T) {
+	c := validTestConfig()
+	if !c.IsTrustedRelay("https://relay.example/actor") {
+		t.Error("Expected any relay to be trusted without a list")
+	}
+
+	c.Conf.TrustedRelays = []string{" Relay.Example ", "https://relay.fedi.buzz/tag/music/", ""}
+	c.Conf.DeniedRelays = []string{"bad.relay.example"}
+	c.Normalize()
+
+	tests := []struct {
+		actorURI string
+		trusted  bool
+	}{
+		{"https://relay.example/actor", true},
+		{"https://sub.relay.example/actor", true},
+		{"https://bad.relay.example/actor", false},
+		{"https://relay.fedi.buzz/tag/music", true},
+		{"https://relay.fedi.buzz/tag/prints", false},
+		{"https://other.example/actor", false},
+	}
+	for _, tt := range tests {
+		if got := c.IsTrustedRelay(tt.actorURI); got != tt.trusted {
+			t.Errorf("IsTrustedRelay(%q) = %v, want %v", tt.actorURI, got, tt.trusted)
+		}
+	}
+
+	// The denylist applies without an allowlist too
+	c.Conf.TrustedRelays = nil
+	if c.IsTrustedRelay("https://bad.relay.example/actor") {
+		t.Error("Expected a denied relay not to be trusted")
+	}
+	if !c.IsTrustedRelay("https://relay.fedi.buzz/tag/prints") {
+		t.Error("Expected a relay not on the denylist to be trusted")
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	if err := validTestConfig().Validate(); err != nil {
+		t.Errorf("Expected valid config, got: %v", err)
+	}
+
+	for _, domain := range []string{"localhost:9999", "abc123.ngrok-free.app", "xn--bcher-kva.example"} {
+		c := validTestConfig()
+		c.Conf.SslDomain = domain
+		if err := c.Validate(); err != nil {
+			t.Errorf("Expected sslDomain %q to be valid, got: %v", domain, err)
+		}
+	}
+
+	for _, domain := range []string{"", "https://example.com", "example.com/path", "-bad.example.com", "exa mple.com", "example.com:99999"} {
+		c := validTestConfig()
+		c.Conf.SslDomain = domain
+		if err := c.Validate(); err == nil || !strings.Contains(err.Error(), "sslDomain") {
+			t.Errorf("Expected sslDomain error for %q, got: %v", domain, err)
+		}
+	}
+}
+
+func TestValidateConfigReportsAllProblems(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	c := validTestConfig()
+	c.Conf.SslDomain = "not a domain"
+	c.Conf.Host = "bad host!"
+	c.Conf.SshPort = 0
+	c.Conf.HttpPort = 70000
+	c.Conf.HttpTimeout = -1
+	c.Conf.RelayStaleHours = -5
+	c.Conf.LogFormat = "xml"
+	c.Conf.InboundCreatePolicy = "accept"
+	c.Conf.InboundContentPolicy = "drop"
+	c.Conf.MaxInboundContentBytes = -1
+
+	err := c.Validate()
+	if err == nil {
+		t.Fatal("Expected validation errors")
+	}
+	for _, want := range []string{"sslDomain", "host", "sshPort", "httpPort", "httpTimeout", "relayStaleHours", "logFormat", "inboundCreatePolicy", "inboundContentPolicy", "maxInboundContentBytes"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error to mention %s, got: %v", want, err)
+		}
+	}
+}
+
+func TestValidateConfigNoteChars(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	c := validTestConfig()
+	c.Conf.MaxNoteChars = MaxNoteDBLength
+	if err := c.Validate(); err != nil {
+		t.Errorf("Expected maxNoteChars at the database limit to be valid, got: %v", err)
+	}
+
+	c.Conf.MaxNoteChars = MaxNoteDBLength + 1
+	c.Conf.MaxInboundNoteChars = -1
+	err := c.Validate()
+	for _, want := range []string{"maxNoteChars", "maxInboundNoteChars"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error to mention %s, got: %v", want, err)
+		}
+	}
+}
+
+func TestValidateConfigSamePorts(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	c := validTestConfig()
+	c.Conf.HttpPort = c.Conf.SshPort
+	if err := c.Validate(); err == nil || !strings.Contains(err.Error(), "must differ") {
+		t.Errorf("Expected error for equal ports, got: %v", err)
+	}
+}
+
+func TestCheckWritableDir(t *testing.T) {
+	if err := checkWritableDir(t.TempDir()); err != nil {
+		t.Errorf("Expected temp dir to be writable, got: %v", err)
+	}
+	if err := checkWritableDir(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("Expected error for a missing directory")
+	}
+}
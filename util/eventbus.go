@@ -0,0 +1,147 @@
+package util
+
+import (
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// EventType identifies what changed
+type EventType int
+
+const (
+	// EventNewPost is published when a post is created or received
+	EventNewPost EventType = iota
+	// EventNotification is published when a notification is created for an account
+	EventNotification
+	// EventFollow is published when an account gains a follower or a follow request
+	EventFollow
+)
+
+// eventBufferSize is the number of undelivered events a subscriber may have before
+// further events are dropped
+const eventBufferSize = 16
+
+// Event tells a subscriber that data shown to an account changed. Besides the type, only
+// notification events carry a payload, the unread count; otherwise subscribers reload what
+// they display.
+type Event struct {
+	Type        EventType
+	AccountId   uuid.UUID // recipient; uuid.Nil for events broadcast to all accounts
+	UnreadCount int       // the recipient's unread notifications, set on EventNotification
+}
+
+// EventBus is an in-process publish/subscribe hub that lets SSH sessions update their
+// views as soon as something happens instead of polling the database. Publishing never
+// blocks: an event for a subscriber whose buffer is full is dropped, which is harmless
+// because the next event triggers the same reload.
+type EventBus struct {
+	mu          sync.Mutex
+	subscribers map[uuid.UUID][]chan Event
+}
+
+var (
+	eventBus     *EventBus
+	eventBusOnce sync.Once
+)
+
+// GetEventBus returns the process-wide event bus
+func GetEventBus() *EventBus {
+	eventBusOnce.Do(func() {
+		eventBus = NewEventBus()
+	})
+	return eventBus
+}
+
+// NewEventBus creates an event bus without subscribers
+func NewEventBus() *EventBus {
+	return &EventBus{subscribers: make(map[uuid.UUID][]chan Event)}
+}
+
+// Subscribe returns a channel receiving the events for an account. An account may have
+// several subscriptions (one per SSH session). Every subscription must be ended with
+// Unsubscribe, which closes the channel.
+func (b *EventBus) Subscribe(accountId uuid.UUID) <-chan Event {
+	ch := make(chan Event, eventBufferSize)
+	b.mu.Lock()
+	b.subscribers[accountId] = append(b.subscribers[accountId], ch)
+	b.mu.Unlock()
+	return ch
+}
+
+// Unsubscribe removes a subscription and closes its channel. Unknown channels are ignored,
+// so it is safe to call more than once.
+func (b *EventBus) Unsubscribe(accountId uuid.UUID, sub <-chan Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	subs := b.subscribers[accountId]
+	for i, ch := range subs {
+		if ch == sub {
+			close(ch)
+			subs = append(subs[:i], subs[i+1:]...)
+			break
+		}
+	}
+	if len(subs) == 0 {
+		delete(b.subscribers, accountId)
+	} else {
+		b.subscribers[accountId] = subs
+	}
+}
+
+// Publish sends an event to all subscriptions of an account
+func (b *EventBus) Publish(accountId uuid.UUID, eventType EventType) {
+	b.publish(Event{Type: eventType, AccountId: accountId})
+}
+
+// PublishNotification tells the sessions of an account that it got a notification and how
+// many unread notifications it has now, so they can update the badge without a query
+func (b *EventBus) PublishNotification(accountId uuid.UUID, unreadCount int) {
+	b.publish(Event{Type: EventNotification, AccountId: accountId, UnreadCount: unreadCount})
+}
+
+// publish sends an event to the subscriptions of its account only
+func (b *EventBus) publish(event Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, ch := range b.subscribers[event.AccountId] {
+		sendEvent(ch, event)
+	}
+}
+
+// Broadcast sends an event to every subscription, for changes that may concern any
+// account such as a new post showing up in followers' timelines
+func (b *EventBus) Broadcast(eventType EventType) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	event := Event{Type: eventType}
+	for _, subs := range b.subscribers {
+		for _, ch := range subs {
+			sendEvent(ch, event)
+		}
+	}
+}
+
+// SubscriberCount returns the number of open subscriptions
+func (b *EventBus) SubscriberCount() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	count := 0
+	for _, subs := range b.subscribers {
+		count += len(subs)
+	}
+	return count
+}
+
+// sendEvent delivers an event without blocking; the caller holds the bus lock, so the channel
+// can't be closed concurrently
+func sendEvent(ch chan Event, event Event) {
+	select {
+	case ch <- event:
+	default:
+	}
+}
@@ -0,0 +1,112 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// receive returns the next buffered event, failing if there is none
+func receive(t *testing.T, ch <-chan Event) Event {
+	t.Helper()
+	select {
+	case event := <-ch:
+		return event
+	default:
+		t.Fatal("Expected an event")
+		return Event{}
+	}
+}
+
+// expectNone fails if an event is buffered
+func expectNone(t *testing.T, ch <-chan Event) {
+	t.Helper()
+	select {
+	case event := <-ch:
+		t.Fatalf("Expected no event, got %+v", event)
+	default:
+	}
+}
+
+func TestEventBusPublish(t *testing.T) {
+	bus := NewEventBus()
+	alice, bob := uuid.New(), uuid.New()
+
+	aliceSession1 := bus.Subscribe(alice)
+	aliceSession2 := bus.Subscribe(alice)
+	bobSession := bus.Subscribe(bob)
+
+	bus.Publish(alice, EventFollow)
+
+	for _, ch := range []<-chan Event{aliceSession1, aliceSession2} {
+		event := receive(t, ch)
+		if event.Type != EventFollow || event.AccountId != alice {
+			t.Errorf("Expected a follow event for alice, got %+v", event)
+		}
+	}
+	expectNone(t, bobSession)
+
+	bus.PublishNotification(bob, 3)
+	if event := receive(t, bobSession); event.Type != EventNotification || event.UnreadCount != 3 {
+		t.Errorf("Expected a notification event with 3 unread, got %+v", event)
+	}
+	expectNone(t, aliceSession1)
+
+	bus.Broadcast(EventNewPost)
+	for _, ch := range []<-chan Event{aliceSession1, aliceSession2, bobSession} {
+		if event := receive(t, ch); event.Type != EventNewPost || event.AccountId != uuid.Nil {
+			t.Errorf("Expected a broadcast new post event, got %+v", event)
+		}
+	}
+}
+
+func TestEventBusUnsubscribe(t *testing.T) {
+	bus := NewEventBus()
+	alice := uuid.New()
+
+	first := bus.Subscribe(alice)
+	second := bus.Subscribe(alice)
+	if count := bus.SubscriberCount(); count != 2 {
+		t.Fatalf("Expected 2 subscribers, got %d", count)
+	}
+
+	bus.Unsubscribe(alice, first)
+	if _, ok := <-first; ok {
+		t.Error("Expected the channel to be closed")
+	}
+	if count := bus.SubscriberCount(); count != 1 {
+		t.Errorf("Expected 1 subscriber, got %d", count)
+	}
+
+	// Unsubscribing twice is harmless, and the remaining session still gets events
+	bus.Unsubscribe(alice, first)
+	bus.Publish(alice, EventNotification)
+	receive(t, second)
+
+	bus.Unsubscribe(alice, second)
+	if count := bus.SubscriberCount(); count != 0 {
+		t.Errorf("Expected no subscribers, got %d", count)
+	}
+	if _, ok := bus.subscribers[alice]; ok {
+		t.Error("Expected the account to be removed from the bus")
+	}
+
+	// Publishing without subscribers doesn't block
+	bus.Publish(alice, EventNotification)
+	bus.Broadcast(EventNewPost)
+}
+
+func TestEventBusDropsWhenFull(t *testing.T) {
+	bus := NewEventBus()
+	alice := uuid.New()
+	ch := bus.Subscribe(alice)
+
+	// A subscriber that doesn't read never blocks publishers
+	for i := 0; i < eventBufferSize*2; i++ {
+		bus.Publish(alice, EventNotification)
+	}
+	if len(ch) != eventBufferSize {
+		t.Errorf("Expected %d buffered events, got %d", eventBufferSize, len(ch))
+	}
+	bus.Unsubscribe(alice, ch)
+}
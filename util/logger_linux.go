@@ -41,8 +41,10 @@ func GetLogWriter() io.Writer {
 	return logWriter
 }
 
-// SetupLogging configures the logging system based on the journald flag
-func SetupLogging(withJournald bool) {
+// SetupLogging configures the logging system based on the journald flag and log format
+func SetupLogging(withJournald bool, logFormat string) {
+	defer setupLogFormat(logFormat)
+
 	if withJournald {
 		// Check if journald is available
 		if !journal.Enabled() {
@@ -16,12 +16,13 @@ func GetLogWriter() io.Writer {
 	return logWriter
 }
 
-// SetupLogging configures the logging system based on the journald flag
+// SetupLogging configures the logging system based on the journald flag and log format
 // On non-Linux systems, journald is not available, so we use standard logging
-func SetupLogging(withJournald bool) {
+func SetupLogging(withJournald bool, logFormat string) {
 	if withJournald {
 		log.Println("Warning: Journald logging is not supported on this operating system")
 		log.Println("Falling back to standard logging (stdout/stderr)")
 	}
 	// Use default logging regardless (stdout/stderr with timestamps)
+	setupLogFormat(logFormat)
 }
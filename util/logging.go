@@ -0,0 +1,46 @@
+package util
+
+import (
+	"context"
+	"log"
+	"log/slog"
+	"strings"
+)
+
+// Log output formats (config logFormat)
+const (
+	LogFormatText = "text" // Human-readable lines (default)
+	LogFormatJSON = "json" // One JSON object per line: level, ts, msg and any fields
+)
+
+// setupLogFormat switches all logging to JSON lines when logFormat is "json". Both slog and the
+// standard log package then write through the JSON handler, so existing log.Printf calls show up
+// as INFO entries. Other formats keep the human-readable output.
+func setupLogFormat(logFormat string) {
+	if logFormat != LogFormatJSON {
+		return
+	}
+
+	handler := slog.NewJSONHandler(logWriter, &slog.HandlerOptions{ReplaceAttr: renameTimeKey})
+	slog.SetDefault(slog.New(handler))
+
+	// Writers handed out to other packages (e.g. gin's request log) must produce JSON too
+	logWriter = slogLineWriter{}
+	log.Println("Logging initialized with JSON output")
+}
+
+// renameTimeKey names the timestamp "ts", as expected by common log shippers
+func renameTimeKey(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) == 0 && a.Key == slog.TimeKey {
+		a.Key = "ts"
+	}
+	return a
+}
+
+// slogLineWriter logs each write as an INFO entry with the default slog logger
+type slogLineWriter struct{}
+
+func (slogLineWriter) Write(p []byte) (int, error) {
+	slog.Default().Log(context.Background(), slog.LevelInfo, strings.TrimRight(string(p), "\n"))
+	return len(p), nil
+}
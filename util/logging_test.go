@@ -0,0 +1,73 @@
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSetupLogFormatJSON(t *testing.T) {
+	oldWriter, oldSlog := logWriter, slog.Default()
+	oldFlags := log.Flags()
+	t.Cleanup(func() {
+		logWriter = oldWriter
+		slog.SetDefault(oldSlog)
+		log.SetOutput(os.Stderr)
+		log.SetFlags(oldFlags)
+	})
+
+	var buf bytes.Buffer
+	logWriter = &buf
+	setupLogFormat(LogFormatJSON)
+
+	log.Printf("plain %s", "message")
+	slog.Warn("structured", "actor", "https://remote.example/users/bob", "status", 401)
+	io.WriteString(GetLogWriter(), "[GIN] 200 | GET /\n")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	var entries []map[string]any
+	for _, line := range lines {
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("Log line is not JSON: %s", line)
+		}
+		if _, ok := entry["ts"]; !ok {
+			t.Errorf("Expected ts field in %s", line)
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) != 4 { // setup message, log.Printf, slog.Warn, writer
+		t.Fatalf("Expected 4 log entries, got %d: %s", len(entries), buf.String())
+	}
+
+	if entries[1]["msg"] != "plain message" || entries[1]["level"] != "INFO" {
+		t.Errorf("Expected standard log line as INFO entry, got %v", entries[1])
+	}
+	if entries[2]["level"] != "WARN" || entries[2]["actor"] != "https://remote.example/users/bob" || entries[2]["status"] != float64(401) {
+		t.Errorf("Expected WARN entry with fields, got %v", entries[2])
+	}
+	if entries[3]["msg"] != "[GIN] 200 | GET /" {
+		t.Errorf("Expected log writer output as entry, got %v", entries[3])
+	}
+}
+
+func TestSetupLogFormatText(t *testing.T) {
+	oldWriter, oldSlog := logWriter, slog.Default()
+	t.Cleanup(func() {
+		logWriter = oldWriter
+		slog.SetDefault(oldSlog)
+	})
+
+	var buf bytes.Buffer
+	logWriter = &buf
+	setupLogFormat(LogFormatText)
+
+	if slog.Default() != oldSlog || logWriter != &buf {
+		t.Error("Expected text format to leave logging unchanged")
+	}
+}
@@ -0,0 +1,392 @@
+package util
+
+import (
+	"encoding/json"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+
+	htmlparser "golang.org/x/net/html"
+)
+
+// allowedHTMLTags are the elements kept by SanitizeHTML. b and i are kept as strong and em.
+var allowedHTMLTags = map[string]string{
+	"p": "p", "br": "br", "a": "a", "strong": "strong", "b": "strong", "em": "em", "i": "em",
+	"code": "code", "blockquote": "blockquote", "ul": "ul", "ol": "ol", "li": "li",
+}
+
+// droppedHTMLTags are removed together with their content
+var droppedHTMLTags = map[string]bool{
+	"script": true, "style": true, "iframe": true, "object": true, "embed": true,
+	"template": true, "noscript": true, "textarea": true, "title": true, "svg": true, "math": true,
+}
+
+// allowedLinkSchemes are the URL schemes kept in href attributes
+var allowedLinkSchemes = []string{"http://", "https://", "mailto:"}
+
+var (
+	whitespaceRegex     = regexp.MustCompile(`\s+`)
+	trailingSpacesRegex = regexp.MustCompile(` +\n`)
+	extraNewlinesRegex  = regexp.MustCompile(`\n{3,}`)
+)
+
+// SanitizeHTML reduces inbound post HTML to an allowlist of formatting elements
+// (p, br, a, strong, em, code, blockquote, ul, ol, li). Scripts, styles and similar elements
+// are removed with their content, other elements are unwrapped, and all attributes are
+// dropped except an http(s) or mailto href on links. Unclosed elements are closed and
+// stray end tags are dropped, so the result is well-formed and safe to render.
+func SanitizeHTML(content string) string {
+	var out strings.Builder
+	var open []string // allowed elements currently open
+	skipping := ""    // dropped element whose content is being skipped
+	skipDepth := 0
+
+	tokenizer := htmlparser.NewTokenizer(strings.NewReader(content))
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == htmlparser.ErrorToken {
+			break
+		}
+		token := tokenizer.Token()
+
+		if skipping != "" {
+			switch {
+			case tokenType == htmlparser.StartTagToken && token.Data == skipping:
+				skipDepth++
+			case tokenType == htmlparser.EndTagToken && token.Data == skipping:
+				skipDepth--
+				if skipDepth == 0 {
+					skipping = ""
+				}
+			}
+			continue
+		}
+
+		switch tokenType {
+		case htmlparser.TextToken:
+			out.WriteString(html.EscapeString(token.Data))
+
+		case htmlparser.StartTagToken, htmlparser.SelfClosingTagToken:
+			if droppedHTMLTags[token.Data] {
+				if tokenType == htmlparser.StartTagToken {
+					skipping, skipDepth = token.Data, 1
+				}
+				continue
+			}
+			tag, ok := allowedHTMLTags[token.Data]
+			if !ok {
+				continue
+			}
+			if tag == "br" {
+				out.WriteString("<br>")
+				continue
+			}
+			if tokenType == htmlparser.SelfClosingTagToken {
+				continue
+			}
+			if tag == "a" {
+				if href := safeLinkHref(token.Attr); href != "" {
+					out.WriteString(`<a href="` + html.EscapeString(href) + `">`)
+				} else {
+					out.WriteString("<a>")
+				}
+			} else {
+				out.WriteString("<" + tag + ">")
+			}
+			open = append(open, tag)
+
+		case htmlparser.EndTagToken:
+			tag, ok := allowedHTMLTags[token.Data]
+			if !ok || tag == "br" {
+				continue
+			}
+			// Close the element and anything left open inside it; ignore stray end tags
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i] != tag {
+					continue
+				}
+				for j := len(open) - 1; j >= i; j-- {
+					out.WriteString("</" + open[j] + ">")
+				}
+				open = open[:i]
+				break
+			}
+		}
+	}
+
+	for i := len(open) - 1; i >= 0; i-- {
+		out.WriteString("</" + open[i] + ">")
+	}
+	return strings.TrimSpace(out.String())
+}
+
+// safeLinkHref returns the href attribute if it uses an allowed scheme, or ""
+func safeLinkHref(attrs []htmlparser.Attribute) string {
+	for _, attr := range attrs {
+		if attr.Key != "href" {
+			continue
+		}
+		href := strings.TrimSpace(attr.Val)
+		lower := strings.ToLower(href)
+		for _, scheme := range allowedLinkSchemes {
+			if strings.HasPrefix(lower, scheme) {
+				return href
+			}
+		}
+	}
+	return ""
+}
+
+// HTMLToMarkdown converts inbound post HTML to the Markdown the TUI renders: links become
+// [text](url), emphasis and code keep their Markdown markers, quotes are prefixed with "> "
+// and list items with "- " or "1. ". Links whose text is a @mention or #hashtag, or the URL
+// itself, are kept as plain text so they are highlighted like in local posts.
+func HTMLToMarkdown(content string) string {
+	return convertSanitizedHTML(SanitizeHTML(content), true)
+}
+
+// HTMLToPlainText converts inbound post HTML to plain text, keeping paragraph and line
+// breaks and list bullets but no other markup. It is the fallback where Markdown can't be shown.
+func HTMLToPlainText(content string) string {
+	return convertSanitizedHTML(SanitizeHTML(content), false)
+}
+
+// RemotePostBody returns the title and the (unsanitized) HTML content of the object of an
+// incoming Create or Update. Notes have no title; long-form Article and Page objects, and
+// any other object type, are titled by their name. Objects without content fall back to
+// their summary and then to a link to their url, so unknown types still render.
+func RemotePostBody(rawJSON string) (title, contentHTML string) {
+	var activity struct {
+		Object struct {
+			Type    string `json:"type"`
+			Name    string `json:"name"`
+			Summary string `json:"summary"`
+			Content string `json:"content"`
+			URL     any    `json:"url"`
+		} `json:"object"`
+	}
+	if err := json.Unmarshal([]byte(rawJSON), &activity); err != nil {
+		return "", ""
+	}
+	object := activity.Object
+
+	if object.Type != "Note" {
+		title = strings.Join(strings.Fields(HTMLToPlainText(object.Name)), " ")
+	}
+	contentHTML = object.Content
+	if contentHTML == "" && object.Type != "Note" {
+		contentHTML = object.Summary
+	}
+	if contentHTML == "" && object.Type != "Note" {
+		if url := objectURL(object.URL); url != "" {
+			contentHTML = `<p><a href="` + html.EscapeString(url) + `">` + html.EscapeString(url) + `</a></p>`
+		}
+	}
+	return title, contentHTML
+}
+
+// objectURL returns the first link of an ActivityStreams url property, which may be a
+// string, a Link object with an href, or an array of either
+func objectURL(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case map[string]any:
+		href, _ := v["href"].(string)
+		return href
+	case []any:
+		for _, item := range v {
+			if url := objectURL(item); url != "" {
+				return url
+			}
+		}
+	}
+	return ""
+}
+
+// markdownFrame collects the output of an element that is post-processed when it closes
+type markdownFrame struct {
+	tag  string
+	href string
+	buf  strings.Builder
+}
+
+// markdownList tracks an open list and the number of its next item
+type markdownList struct {
+	ordered bool
+	next    int
+}
+
+// convertSanitizedHTML renders the output of SanitizeHTML as Markdown or as plain text
+func convertSanitizedHTML(sanitized string, markdown bool) string {
+	frames := []*markdownFrame{{}}
+	var lists []markdownList
+	current := func() *strings.Builder { return &frames[len(frames)-1].buf }
+	// blockBreak separates a block from what precedes it in the current frame, keeping a
+	// wider break that is already there
+	blockBreak := func(separator string) {
+		buf := current()
+		text := strings.TrimRight(buf.String(), " ")
+		if text == "" {
+			return
+		}
+		trimmed := strings.TrimRight(text, "\n")
+		buf.Reset()
+		if len(text)-len(trimmed) >= len(separator) {
+			buf.WriteString(text)
+		} else {
+			buf.WriteString(trimmed + separator)
+		}
+	}
+	marker := func(m string) {
+		if markdown {
+			current().WriteString(m)
+		}
+	}
+
+	tokenizer := htmlparser.NewTokenizer(strings.NewReader(sanitized))
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == htmlparser.ErrorToken {
+			break
+		}
+		token := tokenizer.Token()
+
+		switch tokenType {
+		case htmlparser.TextToken:
+			// Whitespace in HTML source is insignificant beyond a single space
+			text := whitespaceRegex.ReplaceAllString(token.Data, " ")
+			buf := current()
+			if written := buf.String(); written == "" || strings.HasSuffix(written, " ") || strings.HasSuffix(written, "\n") {
+				text = strings.TrimLeft(text, " ")
+			}
+			buf.WriteString(text)
+
+		case htmlparser.StartTagToken:
+			switch token.Data {
+			case "br":
+				buf := current()
+				text := strings.TrimRight(buf.String(), " ")
+				buf.Reset()
+				buf.WriteString(text + "\n")
+			case "p":
+				blockBreak("\n\n")
+			case "strong":
+				marker("**")
+			case "em":
+				marker("*")
+			case "code":
+				marker("`")
+			case "ul", "ol":
+				if len(lists) == 0 {
+					blockBreak("\n\n")
+				}
+				lists = append(lists, markdownList{ordered: token.Data == "ol", next: 1})
+			case "li":
+				blockBreak("\n")
+				indent := ""
+				if len(lists) > 1 {
+					indent = strings.Repeat("  ", len(lists)-1)
+				}
+				bullet := "- "
+				if len(lists) > 0 && lists[len(lists)-1].ordered {
+					bullet = strconv.Itoa(lists[len(lists)-1].next) + ". "
+					lists[len(lists)-1].next++
+				}
+				current().WriteString(indent + bullet)
+			case "a", "blockquote":
+				blockquote := token.Data == "blockquote"
+				if blockquote {
+					blockBreak("\n\n")
+				}
+				frame := &markdownFrame{tag: token.Data}
+				for _, attr := range token.Attr {
+					if attr.Key == "href" {
+						frame.href = attr.Val
+					}
+				}
+				frames = append(frames, frame)
+			}
+
+		case htmlparser.SelfClosingTagToken:
+			if token.Data == "br" {
+				current().WriteString("\n")
+			}
+
+		case htmlparser.EndTagToken:
+			switch token.Data {
+			case "p":
+				blockBreak("\n\n")
+			case "strong":
+				marker("**")
+			case "em":
+				marker("*")
+			case "code":
+				marker("`")
+			case "ul", "ol":
+				if len(lists) > 0 {
+					lists = lists[:len(lists)-1]
+				}
+				if len(lists) == 0 {
+					blockBreak("\n\n")
+				}
+			case "a", "blockquote":
+				if len(frames) < 2 || frames[len(frames)-1].tag != token.Data {
+					continue
+				}
+				frame := frames[len(frames)-1]
+				frames = frames[:len(frames)-1]
+				if token.Data == "a" {
+					current().WriteString(formatMarkdownLink(strings.TrimSpace(frame.buf.String()), frame.href, markdown))
+				} else {
+					current().WriteString(quoteLines(frame.buf.String(), markdown))
+					blockBreak("\n\n")
+				}
+			}
+		}
+	}
+
+	// Flush elements left open (SanitizeHTML closes everything, so this is only defensive)
+	for len(frames) > 1 {
+		frame := frames[len(frames)-1]
+		frames = frames[:len(frames)-1]
+		current().WriteString(frame.buf.String())
+	}
+
+	text := trailingSpacesRegex.ReplaceAllString(frames[0].buf.String(), "\n")
+	return strings.TrimSpace(extraNewlinesRegex.ReplaceAllString(text, "\n\n"))
+}
+
+// formatMarkdownLink renders a link as [text](url), or as its text when it is a mention,
+// a hashtag or the URL itself, or when rendering plain text
+func formatMarkdownLink(text, href string, markdown bool) string {
+	if text == "" {
+		text = href
+	}
+	if !markdown || href == "" || strings.HasPrefix(text, "@") || strings.HasPrefix(text, "#") ||
+		strings.TrimSuffix(text, "/") == strings.TrimSuffix(href, "/") {
+		return text
+	}
+	// Keep the text and URL from ending the Markdown link early
+	text = strings.NewReplacer("[", "(", "]", ")").Replace(text)
+	href = strings.NewReplacer("(", "%28", ")", "%29", " ", "%20").Replace(href)
+	return "[" + text + "](" + href + ")"
+}
+
+// quoteLines prefixes every line of a quoted block with "> " in Markdown
+func quoteLines(text string, markdown bool) string {
+	text = strings.TrimSpace(text)
+	if !markdown || text == "" {
+		return text
+	}
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if line == "" {
+			lines[i] = ">"
+		} else {
+			lines[i] = "> " + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,168 @@
+package util
+
+import "testing"
+
+func TestSanitizeHTML(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "allowed formatting is kept without attributes",
+			input:    `<p class="x" style="color:red">Hello <strong onclick="evil()">bold</strong> <em>it</em> <code>x</code></p>`,
+			expected: `<p>Hello <strong>bold</strong> <em>it</em> <code>x</code></p>`,
+		},
+		{
+			name:     "b and i become strong and em",
+			input:    `<b>bold</b> <i>it</i>`,
+			expected: `<strong>bold</strong> <em>it</em>`,
+		},
+		{
+			name:     "links keep only a safe href",
+			input:    `<a href="https://example.com/a?b=1&amp;c=2" target="_blank" rel="nofollow">link</a>`,
+			expected: `<a href="https://example.com/a?b=1&amp;c=2">link</a>`,
+		},
+		{
+			name:     "javascript links lose their href",
+			input:    `<a href=" javascript:alert(1)">click</a><a href="data:text/html,x">data</a>`,
+			expected: `<a>click</a><a>data</a>`,
+		},
+		{
+			name:     "scripts and styles are removed with their content",
+			input:    `<p>before<script>alert("x")</script><style>p{}</style>after</p>`,
+			expected: `<p>beforeafter</p>`,
+		},
+		{
+			name:     "unknown elements are unwrapped",
+			input:    `<div><span class="h-card"><a href="https://remote.example/@bob">@<span>bob</span></a></span> hi</div><img src="x" onerror="evil()">`,
+			expected: `<a href="https://remote.example/@bob">@bob</a> hi`,
+		},
+		{
+			name:     "unclosed elements are closed and stray end tags dropped",
+			input:    `<blockquote><p>quoted <strong>text</p></em>`,
+			expected: `<blockquote><p>quoted <strong>text</strong></p></blockquote>`,
+		},
+		{
+			name:     "text is escaped",
+			input:    `1 &lt; 2 &amp; "quotes"`,
+			expected: `1 &lt; 2 &amp; &#34;quotes&#34;`,
+		},
+		{
+			name:     "lists and line breaks",
+			input:    `<ul><li>one<br/>two</li></ul>`,
+			expected: `<ul><li>one<br>two</li></ul>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := SanitizeHTML(tt.input); result != tt.expected {
+				t.Errorf("SanitizeHTML(%q) = %q, want %q", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestHTMLToMarkdown(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "paragraphs and line breaks",
+			input:    "<p>first line<br>second line</p>\n<p>next paragraph</p>",
+			expected: "first line\nsecond line\n\nnext paragraph",
+		},
+		{
+			name:     "emphasis and code",
+			input:    `<p><strong>bold</strong>, <em>italic</em> and <code>code</code></p>`,
+			expected: "**bold**, *italic* and `code`",
+		},
+		{
+			name:     "links become markdown links",
+			input:    `<p>read <a href="https://example.com/post">this post</a>!</p>`,
+			expected: "read [this post](https://example.com/post)!",
+		},
+		{
+			name: "mentions, hashtags and bare URLs stay text",
+			input: `<p><span class="h-card"><a href="https://remote.example/@bob" class="u-url mention">@<span>bob</span></a></span> ` +
+				`<a href="https://remote.example/tags/go" class="mention hashtag" rel="tag">#<span>go</span></a> ` +
+				`<a href="https://example.com/long/path"><span class="invisible">https://</span><span class="ellipsis">example.com/long</span><span class="invisible">/path</span></a></p>`,
+			expected: "@bob #go https://example.com/long/path",
+		},
+		{
+			name:     "unsafe links lose their target",
+			input:    `<a href="javascript:alert(1)">click</a>`,
+			expected: "click",
+		},
+		{
+			name:     "link text can't break out of the markdown link",
+			input:    `<a href="https://example.com/a_(b)">see [this]</a>`,
+			expected: "[see (this)](https://example.com/a_%28b%29)",
+		},
+		{
+			name:     "lists",
+			input:    `<p>todo:</p><ul><li>one</li><li>two</li></ul><ol><li>first</li><li>second</li></ol>`,
+			expected: "todo:\n\n- one\n- two\n\n1. first\n2. second",
+		},
+		{
+			name:     "blockquotes",
+			input:    `<p>they said</p><blockquote><p>hello</p><p>world</p></blockquote><p>indeed</p>`,
+			expected: "they said\n\n> hello\n>\n> world\n\nindeed",
+		},
+		{
+			name:     "scripts are dropped",
+			input:    `<p>safe</p><script>alert(1)</script>`,
+			expected: "safe",
+		},
+		{
+			name:     "entities are decoded",
+			input:    `<p>1 &lt; 2 &amp;&amp; 3 &gt; 2</p>`,
+			expected: "1 < 2 && 3 > 2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := HTMLToMarkdown(tt.input); result != tt.expected {
+				t.Errorf("HTMLToMarkdown(%q) = %q, want %q", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestHTMLToPlainText(t *testing.T) {
+	input := `<p>Hello <strong>world</strong>, see <a href="https://example.com">my site</a></p><blockquote><p>quoted</p></blockquote><ul><li>item</li></ul>`
+	expected := "Hello world, see my site\n\nquoted\n\n- item"
+	if result := HTMLToPlainText(input); result != expected {
+		t.Errorf("HTMLToPlainText(%q) = %q, want %q", input, result, expected)
+	}
+}
+
+func TestRemotePostBody(t *testing.T) {
+	tests := []struct {
+		name        string
+		rawJSON     string
+		title       string
+		contentHTML string
+	}{
+		{"note", `{"type":"Create","object":{"type":"Note","name":"ignored","content":"<p>Hi</p>"}}`, "", "<p>Hi</p>"},
+		{"article", `{"type":"Create","object":{"type":"Article","name":"My  essay","content":"<p>Long text</p>"}}`, "My essay", "<p>Long text</p>"},
+		{"page", `{"type":"Create","object":{"type":"Page","name":"About","summary":"<p>Summary</p>","content":"<p>Page</p>"}}`, "About", "<p>Page</p>"},
+		{"summary fallback", `{"type":"Create","object":{"type":"Video","name":"Clip","summary":"<p>A clip</p>"}}`, "Clip", "<p>A clip</p>"},
+		{"url fallback", `{"type":"Create","object":{"type":"Event","name":"Meetup","url":[{"type":"Link","href":"https://example.com/e/1"}]}}`,
+			"Meetup", `<p><a href="https://example.com/e/1">https://example.com/e/1</a></p>`},
+		{"object reference", `{"type":"Like","object":"https://example.com/notes/1"}`, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			title, contentHTML := RemotePostBody(tt.rawJSON)
+			if title != tt.title || contentHTML != tt.contentHTML {
+				t.Errorf("RemotePostBody() = %q, %q, want %q, %q", title, contentHTML, tt.title, tt.contentHTML)
+			}
+		})
+	}
+}
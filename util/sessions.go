@@ -0,0 +1,109 @@
+package util
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrSessionNotFound is returned by RevokeSession for sessions that ended or belong to
+// another account
+var ErrSessionNotFound = errors.New("session not found")
+
+// Session is an open SSH session of an account
+type Session struct {
+	Id          uuid.UUID
+	AccountId   uuid.UUID
+	ConnectedAt time.Time
+	RemoteAddr  string // client address and port
+	Client      string // SSH client version, e.g. SSH-2.0-OpenSSH_9.6
+	close       func() error
+}
+
+// SessionRegistry keeps track of the open SSH sessions so users can see where they are
+// logged in and end sessions they don't recognise. Sessions only live in memory; a restart
+// ends them all anyway.
+type SessionRegistry struct {
+	mu       sync.Mutex
+	sessions map[uuid.UUID]*Session
+}
+
+var (
+	sessionRegistry     *SessionRegistry
+	sessionRegistryOnce sync.Once
+)
+
+// GetSessionRegistry returns the process-wide session registry
+func GetSessionRegistry() *SessionRegistry {
+	sessionRegistryOnce.Do(func() {
+		sessionRegistry = NewSessionRegistry()
+	})
+	return sessionRegistry
+}
+
+// NewSessionRegistry creates an empty session registry
+func NewSessionRegistry() *SessionRegistry {
+	return &SessionRegistry{sessions: make(map[uuid.UUID]*Session)}
+}
+
+// Register records an open session and returns its id. close is called to end the session
+// when it is revoked; every session must be removed with Unregister when it ends.
+func (r *SessionRegistry) Register(accountId uuid.UUID, remoteAddr, client string, close func() error) uuid.UUID {
+	session := &Session{
+		Id:          uuid.New(),
+		AccountId:   accountId,
+		ConnectedAt: time.Now(),
+		RemoteAddr:  remoteAddr,
+		Client:      client,
+		close:       close,
+	}
+	r.mu.Lock()
+	r.sessions[session.Id] = session
+	r.mu.Unlock()
+	return session.Id
+}
+
+// Unregister removes a session. Unknown ids are ignored, so it is safe to call more than once.
+func (r *SessionRegistry) Unregister(id uuid.UUID) {
+	r.mu.Lock()
+	delete(r.sessions, id)
+	r.mu.Unlock()
+}
+
+// ListSessions returns the open sessions of an account, oldest first
+func (r *SessionRegistry) ListSessions(accountId uuid.UUID) []Session {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var sessions []Session
+	for _, session := range r.sessions {
+		if session.AccountId == accountId {
+			sessions = append(sessions, *session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].ConnectedAt.Before(sessions[j].ConnectedAt)
+	})
+	return sessions
+}
+
+// RevokeSession closes the connection of one of an account's sessions and removes it
+func (r *SessionRegistry) RevokeSession(accountId, id uuid.UUID) error {
+	r.mu.Lock()
+	session, ok := r.sessions[id]
+	if !ok || session.AccountId != accountId {
+		r.mu.Unlock()
+		return ErrSessionNotFound
+	}
+	delete(r.sessions, id)
+	r.mu.Unlock()
+
+	// Closed outside the lock: closing may end the session's handler, which unregisters it
+	if session.close != nil {
+		return session.close()
+	}
+	return nil
+}
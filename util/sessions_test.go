@@ -0,0 +1,53 @@
+package util
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestSessionRegistry(t *testing.T) {
+	registry := NewSessionRegistry()
+	alice, bob := uuid.New(), uuid.New()
+
+	closed := 0
+	first := registry.Register(alice, "192.0.2.1:50000", "SSH-2.0-OpenSSH_9.6", func() error { closed++; return nil })
+	second := registry.Register(alice, "192.0.2.2:50001", "SSH-2.0-PuTTY", func() error { closed++; return nil })
+	bobSession := registry.Register(bob, "198.51.100.1:40000", "SSH-2.0-OpenSSH_9.6", nil)
+
+	sessions := registry.ListSessions(alice)
+	if len(sessions) != 2 || sessions[0].Id != first || sessions[1].Id != second {
+		t.Fatalf("Expected alice's two sessions oldest first, got %+v", sessions)
+	}
+	if sessions[1].RemoteAddr != "192.0.2.2:50001" || sessions[1].Client != "SSH-2.0-PuTTY" {
+		t.Errorf("Expected the client info to be kept, got %+v", sessions[1])
+	}
+
+	// Sessions of other accounts can't be revoked
+	if err := registry.RevokeSession(alice, bobSession); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Expected ErrSessionNotFound for bob's session, got %v", err)
+	}
+
+	if err := registry.RevokeSession(alice, first); err != nil {
+		t.Fatalf("RevokeSession failed: %v", err)
+	}
+	if closed != 1 {
+		t.Errorf("Expected the revoked session to be closed, got %d closes", closed)
+	}
+	if sessions := registry.ListSessions(alice); len(sessions) != 1 || sessions[0].Id != second {
+		t.Errorf("Expected only the second session to be left, got %+v", sessions)
+	}
+	if err := registry.RevokeSession(alice, first); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Expected a revoked session to be gone, got %v", err)
+	}
+
+	registry.Unregister(second)
+	registry.Unregister(second)
+	if sessions := registry.ListSessions(alice); len(sessions) != 0 {
+		t.Errorf("Expected no sessions after unregistering, got %d", len(sessions))
+	}
+	if sessions := registry.ListSessions(bob); len(sessions) != 1 {
+		t.Errorf("Expected bob's session to be untouched, got %d", len(sessions))
+	}
+}
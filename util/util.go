@@ -6,14 +6,18 @@ import (
 	"crypto/sha256"
 	"crypto/x509"
 	_ "embed"
+	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"html"
 	"log"
 	"regexp"
 	"strings"
+	"time"
+	_ "time/tzdata" // timezones of accounts work without zoneinfo on the host
 	"unicode/utf8"
 
 	"github.com/charmbracelet/ssh"
@@ -29,6 +33,9 @@ var hashtagRegex = regexp.MustCompile(`#([a-zA-Z][a-zA-Z0-9_]*)`)
 var mentionRegex = regexp.MustCompile(`@([a-zA-Z0-9_]+)@([a-zA-Z0-9.-]+\.[a-zA-Z]{2,})`)
 var markdownLinkRegex = regexp.MustCompile(`\[([^\]]+)\]\(([^)]+)\)`)
 var htmlTagRegex = regexp.MustCompile(`<[^>]*>`)
+var bareURLRegex = regexp.MustCompile(`https?://[^\s<>"]+`)
+var paragraphBreakRegex = regexp.MustCompile(`\n[ \t]*\n`)
+var languageCodeRegex = regexp.MustCompile(`^[a-z]{2,3}$`)
 
 // ANSI color codes for terminal highlighting (must match ui/common/styles.go values)
 const (
@@ -59,6 +66,21 @@ func PkToHash(pk string) string {
 	return hex.EncodeToString(h.Sum(nil))
 }
 
+// AuthorizedKeyHash parses an SSH public key in authorized_keys format, as pasted from a
+// .pub file, and returns the hash accounts are looked up by and the key's comment
+func AuthorizedKeyHash(line string) (string, string, error) {
+	key, comment, _, _, err := gossh.ParseAuthorizedKey([]byte(strings.TrimSpace(line)))
+	if err != nil {
+		return "", "", err
+	}
+	return PkToHash(PublicKeyToString(key)), comment, nil
+}
+
+// HashAPIToken returns the hash stored for an HTTP API token; raw tokens are never persisted
+func HashAPIToken(token string) string {
+	return PkToHash(token)
+}
+
 func GetVersion() string {
 	return strings.TrimSpace(embeddedVersion)
 }
@@ -288,6 +310,13 @@ func FormatClickableURL(url string, maxWidth int, prefix string) string {
 		url, truncatedLinkText)
 }
 
+// FormatClipboardCopy creates an OSC 52 sequence that puts text into the terminal's clipboard.
+// Like OSC 8 links it is written as part of the view; it takes no space on screen and
+// terminals that don't support it ignore it.
+func FormatClipboardCopy(text string) string {
+	return "\033]52;c;" + base64.StdEncoding.EncodeToString([]byte(text)) + "\a"
+}
+
 // GetMarkdownLinkCount returns the number of valid markdown links in the text
 func GetMarkdownLinkCount(text string) int {
 	return len(markdownLinkRegex.FindAllString(text, -1))
@@ -318,19 +347,32 @@ func CountVisibleChars(text string) int {
 	return utf8.RuneCountInString(result)
 }
 
+// MaxNoteDBLength is the size of the notes.message column, in characters
+const MaxNoteDBLength = 1000 // Must match common.MaxNoteDBLength
+
+// ErrNoteTooLong is returned when a note exceeds the visible character or database limit
+var ErrNoteTooLong = errors.New("Note too long")
+
 // ValidateNoteLength checks if the full note text (including markdown syntax)
-// exceeds the database limit.
+// exceeds the database limit. Characters are counted as runes, not bytes.
 // Returns an error if the text is too long.
 func ValidateNoteLength(text string) error {
-	const maxDBLength = 1000 // Must match common.MaxNoteDBLength
-
-	if len(text) > maxDBLength {
-		return fmt.Errorf("Note too long (max %d characters including links)", maxDBLength)
+	if utf8.RuneCountInString(text) > MaxNoteDBLength {
+		return fmt.Errorf("%w (max %d characters including links)", ErrNoteTooLong, MaxNoteDBLength)
 	}
 
 	return nil
 }
 
+// ValidateNoteChars checks a note against the configured visible character limit
+// (see CountVisibleChars) and against the database limit
+func ValidateNoteChars(text string, maxChars int) error {
+	if visibleChars := CountVisibleChars(text); visibleChars > maxChars {
+		return fmt.Errorf("%w (%d visible characters, max %d)", ErrNoteTooLong, visibleChars, maxChars)
+	}
+	return ValidateNoteLength(text)
+}
+
 // TruncateVisibleLength truncates a string based on visible character count,
 // ignoring ANSI escape sequences and OSC 8 hyperlinks.
 // This ensures proper truncation for strings containing terminal formatting.
@@ -441,6 +483,101 @@ func HashtagsToActivityPubHTML(text string, baseURL string) string {
 	})
 }
 
+// MessageToActivityPubHTML renders a plain-text note as the HTML content of a federated Note.
+// All user text is escaped. Markdown links and bare http(s) URLs become links, hashtags link to
+// baseURL/tags/{tag}, and mentions with an actor URI in mentionURIs (keyed "@user@domain")
+// become h-card links; unresolved mentions stay plain text. Blank lines separate <p> paragraphs
+// and single newlines become <br>.
+func MessageToActivityPubHTML(text string, baseURL string, mentionURIs map[string]string) string {
+	resolved := make(map[string]string, len(mentionURIs))
+	for key, actorURI := range mentionURIs {
+		resolved[strings.ToLower(key)] = actorURI
+	}
+
+	var out strings.Builder
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	for _, paragraph := range paragraphBreakRegex.Split(text, -1) {
+		paragraph = strings.TrimSpace(paragraph)
+		if paragraph == "" {
+			continue
+		}
+		lines := strings.Split(paragraph, "\n")
+		for i, line := range lines {
+			lines[i] = inlineToActivityPubHTML(line, baseURL, resolved)
+		}
+		out.WriteString("<p>" + strings.Join(lines, "<br>") + "</p>")
+	}
+	return out.String()
+}
+
+// inlineToActivityPubHTML renders one line of a note, linking Markdown links and bare URLs
+// and passing the text between them to plainTextToActivityPubHTML
+func inlineToActivityPubHTML(text string, baseURL string, mentionURIs map[string]string) string {
+	var out strings.Builder
+	for text != "" {
+		mdLoc := markdownLinkRegex.FindStringSubmatchIndex(text)
+		urlLoc := bareURLRegex.FindStringIndex(text)
+
+		if mdLoc != nil && (urlLoc == nil || mdLoc[0] <= urlLoc[0]) {
+			out.WriteString(plainTextToActivityPubHTML(text[:mdLoc[0]], baseURL, mentionURIs))
+			linkText, linkURL := text[mdLoc[2]:mdLoc[3]], text[mdLoc[4]:mdLoc[5]]
+			if IsURL(linkURL) {
+				out.WriteString(fmt.Sprintf(`<a href="%s" target="_blank" rel="noopener noreferrer">%s</a>`, html.EscapeString(linkURL), html.EscapeString(linkText)))
+			} else {
+				// Only http(s) targets are linked, so javascript: and similar URLs stay inert text
+				out.WriteString(plainTextToActivityPubHTML(text[mdLoc[0]:mdLoc[1]], baseURL, mentionURIs))
+			}
+			text = text[mdLoc[1]:]
+			continue
+		}
+
+		if urlLoc != nil {
+			out.WriteString(plainTextToActivityPubHTML(text[:urlLoc[0]], baseURL, mentionURIs))
+			linkURL := trimURLPunctuation(text[urlLoc[0]:urlLoc[1]])
+			out.WriteString(fmt.Sprintf(`<a href="%s" target="_blank" rel="noopener noreferrer">%s</a>`, html.EscapeString(linkURL), html.EscapeString(linkURL)))
+			text = text[urlLoc[0]+len(linkURL):]
+			continue
+		}
+
+		out.WriteString(plainTextToActivityPubHTML(text, baseURL, mentionURIs))
+		break
+	}
+	return out.String()
+}
+
+// plainTextToActivityPubHTML escapes text and links its hashtags and resolved mentions
+func plainTextToActivityPubHTML(text string, baseURL string, mentionURIs map[string]string) string {
+	// Escaping leaves '#', '@' and word characters alone, so the patterns still match afterwards
+	escaped := HashtagsToActivityPubHTML(html.EscapeString(text), baseURL)
+	return mentionRegex.ReplaceAllStringFunc(escaped, func(match string) string {
+		submatches := mentionRegex.FindStringSubmatch(match)
+		username := strings.ToLower(submatches[1])
+		actorURI, ok := mentionURIs["@"+username+"@"+strings.ToLower(submatches[2])]
+		if !ok {
+			return match
+		}
+		return fmt.Sprintf(`<span class="h-card"><a href="%s" class="u-url mention">@<span>%s</span></a></span>`, html.EscapeString(actorURI), username)
+	})
+}
+
+// trimURLPunctuation drops sentence punctuation that follows a bare URL, such as the
+// period in "see https://example.com." or the parenthesis in "(https://example.com)"
+func trimURLPunctuation(url string) string {
+	for url != "" {
+		last := url[len(url)-1]
+		if strings.IndexByte(".,;:!?'", last) >= 0 {
+			url = url[:len(url)-1]
+			continue
+		}
+		if last == ')' && strings.Count(url, "(") < strings.Count(url, ")") {
+			url = url[:len(url)-1]
+			continue
+		}
+		break
+	}
+	return url
+}
+
 // Mention represents a parsed @username@domain mention
 type Mention struct {
 	Username string
@@ -544,3 +681,50 @@ func MentionsToActivityPubHTML(text string, mentionURIs map[string]string) strin
 		return match
 	})
 }
+
+// NormalizeLanguage reduces a BCP 47 language tag (e.g. "en-US", "pt_BR") to its
+// lowercase ISO 639 primary subtag ("en", "pt"). Returns "" if the tag is not a language code.
+func NormalizeLanguage(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(tag, "-_"); i >= 0 {
+		tag = tag[:i]
+	}
+	if !languageCodeRegex.MatchString(tag) {
+		return ""
+	}
+	return tag
+}
+
+// ParseLanguages parses a comma or space separated list of language tags into normalized,
+// de-duplicated language codes, skipping anything that is not a language code
+func ParseLanguages(text string) []string {
+	fields := strings.FieldsFunc(text, func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+
+	languages := make([]string, 0, len(fields))
+	seen := make(map[string]bool)
+	for _, field := range fields {
+		lang := NormalizeLanguage(field)
+		if lang == "" || seen[lang] {
+			continue
+		}
+		seen[lang] = true
+		languages = append(languages, lang)
+	}
+	return languages
+}
+
+// LoadTimezone returns the location of an IANA timezone name (e.g. "Europe/Berlin") that
+// timestamps are shown in. An empty name is UTC; "Local" is rejected since it would be the
+// server's zone rather than the user's.
+func LoadTimezone(name string) (*time.Location, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || name == "UTC" {
+		return time.UTC, nil
+	}
+	if name == "Local" {
+		return nil, fmt.Errorf("unknown time zone %s", name)
+	}
+	return time.LoadLocation(name)
+}
@@ -1,13 +1,18 @@
 package util
 
 import (
+	"crypto/ed25519"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"strings"
 	"testing"
+	"time"
+
+	gossh "golang.org/x/crypto/ssh"
 )
 
 func TestPublicKeyToString(t *testing.T) {
@@ -515,6 +520,38 @@ func TestValidateNoteLength(t *testing.T) {
 	}
 }
 
+func TestValidateNoteChars(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		maxChars int
+		wantErr  bool
+	}{
+		{"under the limit", "Hello", 10, false},
+		{"multibyte characters count once", "日本語のテキスト", 8, false},
+		{"over the limit", "日本語のテキスト!", 8, true},
+		{"links count their text", "[docs](https://example.com/a/very/long/path)", 4, false},
+		{"visible characters fit but the database limit is exceeded", "[x](https://example.com/" + strings.Repeat("a", MaxNoteDBLength) + ")", 300, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateNoteChars(tt.input, tt.maxChars)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateNoteChars() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrNoteTooLong) {
+				t.Errorf("Expected ErrNoteTooLong, got %v", err)
+			}
+		})
+	}
+
+	// The database limit counts characters, not bytes
+	if err := ValidateNoteLength(strings.Repeat("ü", MaxNoteDBLength)); err != nil {
+		t.Errorf("Expected %d two-byte characters to fit, got %v", MaxNoteDBLength, err)
+	}
+}
+
 func TestIsURLEdgeCases(t *testing.T) {
 	tests := []struct {
 		name  string
@@ -1541,3 +1578,118 @@ func TestHighlightMentionsHTML_WithNewlines(t *testing.T) {
 		t.Errorf("Expected 2 newlines to be preserved, got %d", strings.Count(result, "\n"))
 	}
 }
+
+func TestMessageToActivityPubHTML(t *testing.T) {
+	mentionURIs := map[string]string{
+		"@Alice@mastodon.social": "https://mastodon.social/users/alice",
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"plain text", "Hello world", `<p>Hello world</p>`},
+		{"empty", "", ``},
+		{"escapes html", `<script>alert("x")</script> & co`, `<p>&lt;script&gt;alert(&#34;x&#34;)&lt;/script&gt; &amp; co</p>`},
+		{"paragraphs and line breaks", "first\nline\n\n\nsecond", `<p>first<br>line</p><p>second</p>`},
+		{"bare url with trailing period", "See https://example.com/a?b=1&c=2.", `<p>See <a href="https://example.com/a?b=1&amp;c=2" target="_blank" rel="noopener noreferrer">https://example.com/a?b=1&amp;c=2</a>.</p>`},
+		{"bare url in parentheses", "(https://example.com/page)", `<p>(<a href="https://example.com/page" target="_blank" rel="noopener noreferrer">https://example.com/page</a>)</p>`},
+		{"url fragment is not a hashtag", "https://example.com/page#section", `<p><a href="https://example.com/page#section" target="_blank" rel="noopener noreferrer">https://example.com/page#section</a></p>`},
+		{"markdown link", "[my <site>](https://example.com)", `<p><a href="https://example.com" target="_blank" rel="noopener noreferrer">my &lt;site&gt;</a></p>`},
+		{"non-http markdown link stays text", "[click](javascript:alert(1))", `<p>[click](javascript:alert(1))</p>`},
+		{"hashtag", "Hi #GoLang", `<p>Hi <a href="https://example.com/tags/golang" class="hashtag" rel="tag">#<span>golang</span></a></p>`},
+		{"resolved mention", "cc @alice@Mastodon.social", `<p>cc <span class="h-card"><a href="https://mastodon.social/users/alice" class="u-url mention">@<span>alice</span></a></span></p>`},
+		{"unresolved mention stays text", "cc @bob@pixelfed.social", `<p>cc @bob@pixelfed.social</p>`},
+		{"mention in url is not linked", "https://mastodon.social/@alice@mastodon.social", `<p><a href="https://mastodon.social/@alice@mastodon.social" target="_blank" rel="noopener noreferrer">https://mastodon.social/@alice@mastodon.social</a></p>`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := MessageToActivityPubHTML(tt.input, "https://example.com", mentionURIs)
+			if result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestNormalizeLanguage(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"en", "en"},
+		{"EN-us", "en"},
+		{"pt_BR", "pt"},
+		{" de ", "de"},
+		{"yue", "yue"},
+		{"und1", ""},
+		{"english", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if result := NormalizeLanguage(tt.input); result != tt.expected {
+			t.Errorf("NormalizeLanguage(%q): expected %q, got %q", tt.input, tt.expected, result)
+		}
+	}
+}
+
+func TestParseLanguages(t *testing.T) {
+	result := ParseLanguages("en, de-AT fr,,EN x1")
+	expected := []string{"en", "de", "fr"}
+	if strings.Join(result, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected %v, got %v", expected, result)
+	}
+
+	if result := ParseLanguages(""); len(result) != 0 {
+		t.Errorf("Expected no languages, got %v", result)
+	}
+}
+
+func TestLoadTimezone(t *testing.T) {
+	loc, err := LoadTimezone("")
+	if err != nil || loc != time.UTC {
+		t.Errorf("Expected UTC for an empty timezone, got %v, %v", loc, err)
+	}
+
+	loc, err = LoadTimezone(" Europe/Berlin ")
+	if err != nil || loc.String() != "Europe/Berlin" {
+		t.Errorf("Expected Europe/Berlin, got %v, %v", loc, err)
+	}
+
+	for _, name := range []string{"Local", "Mars/Olympus_Mons"} {
+		if _, err := LoadTimezone(name); err == nil {
+			t.Errorf("Expected %q to be rejected", name)
+		}
+	}
+}
+
+func TestAuthorizedKeyHash(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	sshKey, err := gossh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatalf("Failed to convert key: %v", err)
+	}
+
+	// The hash matches the one of the same key presented in an SSH session
+	line := strings.TrimSpace(string(gossh.MarshalAuthorizedKey(sshKey))) + " alice@laptop\n"
+	hash, comment, err := AuthorizedKeyHash(line)
+	if err != nil {
+		t.Fatalf("AuthorizedKeyHash failed: %v", err)
+	}
+	if hash != PkToHash(PublicKeyToString(sshKey)) {
+		t.Errorf("Expected the session key hash, got %s", hash)
+	}
+	if comment != "alice@laptop" {
+		t.Errorf("Expected comment alice@laptop, got %q", comment)
+	}
+
+	if _, _, err := AuthorizedKeyHash("not a key"); err == nil {
+		t.Error("Expected an error for an invalid key")
+	}
+}
@@ -0,0 +1,66 @@
+package web
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/deemkeen/stegodon/activitypub"
+	"github.com/deemkeen/stegodon/db"
+	"github.com/deemkeen/stegodon/domain"
+	"github.com/deemkeen/stegodon/util"
+	"github.com/google/uuid"
+)
+
+// GetLocalActivity returns an activity this server emitted, looked up by its /activities/{id} URI.
+// Activities received from other servers are never served here.
+func GetLocalActivity(activityId uuid.UUID, conf *util.AppConfig) (error, *domain.Activity) {
+	activityURI := fmt.Sprintf("https://%s/activities/%s", conf.Conf.SslDomain, activityId.String())
+	activity, err := db.GetDB().ReadActivityByURI(activityURI)
+	if err != nil {
+		return err, nil
+	}
+	if !activity.Local {
+		return fmt.Errorf("activity %s is not local", activityURI), nil
+	}
+	return nil, activity
+}
+
+// wantsActivityJSON reports whether the Accept header asks for an ActivityPub representation
+func wantsActivityJSON(accept string) bool {
+	accept = strings.ToLower(accept)
+	return strings.Contains(accept, "application/activity+json") ||
+		strings.Contains(accept, "application/ld+json")
+}
+
+// activityContentType returns the Content-Type for an ActivityPub response: the JSON-LD form
+// with the ActivityStreams profile if the client asked only for that, application/activity+json otherwise
+func activityContentType(accept string) string {
+	accept = strings.ToLower(accept)
+	if strings.Contains(accept, "application/ld+json") && !strings.Contains(accept, "application/activity+json") {
+		return activitypub.LDJSONContentType + "; charset=utf-8"
+	}
+	return activitypub.ActivityJSONContentType + "; charset=utf-8"
+}
+
+// wantsHTML reports whether the Accept header prefers a human-readable page.
+// Requests without an explicit text/html preference (e.g. */* or no Accept header)
+// are treated as ActivityPub fetches.
+func wantsHTML(accept string) bool {
+	return strings.Contains(strings.ToLower(accept), "text/html") && !wantsActivityJSON(accept)
+}
+
+// activityHTMLURL returns the page a browser should see for an activity: the web page of
+// the local post for Creates, otherwise the activity's object itself
+func activityHTMLURL(activity *domain.Activity, conf *util.AppConfig) string {
+	localNotePrefix := fmt.Sprintf("https://%s/notes/", conf.Conf.SslDomain)
+	localActorPrefix := fmt.Sprintf("https://%s/users/", conf.Conf.SslDomain)
+	if strings.HasPrefix(activity.ObjectURI, localNotePrefix) && strings.HasPrefix(activity.ActorURI, localActorPrefix) {
+		noteId := strings.TrimPrefix(activity.ObjectURI, localNotePrefix)
+		username := strings.TrimPrefix(activity.ActorURI, localActorPrefix)
+		return fmt.Sprintf("/u/%s/%s", username, noteId)
+	}
+	if activity.ObjectURI != "" {
+		return activity.ObjectURI
+	}
+	return "/"
+}
@@ -0,0 +1,87 @@
+package web
+
+import (
+	"testing"
+
+	"github.com/deemkeen/stegodon/domain"
+	"github.com/deemkeen/stegodon/util"
+)
+
+func TestWantsActivityJSON(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   bool
+	}{
+		{"application/activity+json", true},
+		{`application/ld+json; profile="https://www.w3.org/ns/activitystreams"`, true},
+		{"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := wantsActivityJSON(tt.accept); got != tt.want {
+			t.Errorf("wantsActivityJSON(%q) = %v, want %v", tt.accept, got, tt.want)
+		}
+	}
+}
+
+func TestActivityContentType(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"application/activity+json", "application/activity+json; charset=utf-8"},
+		{`application/ld+json; profile="https://www.w3.org/ns/activitystreams"`, `application/ld+json; profile="https://www.w3.org/ns/activitystreams"; charset=utf-8`},
+		{`application/activity+json, application/ld+json; profile="https://www.w3.org/ns/activitystreams"`, "application/activity+json; charset=utf-8"},
+		{"*/*", "application/activity+json; charset=utf-8"},
+		{"", "application/activity+json; charset=utf-8"},
+	}
+
+	for _, tt := range tests {
+		if got := activityContentType(tt.accept); got != tt.want {
+			t.Errorf("activityContentType(%q) = %q, want %q", tt.accept, got, tt.want)
+		}
+	}
+}
+
+func TestWantsHTML(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   bool
+	}{
+		{"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", true},
+		{"application/activity+json", false},
+		{"application/activity+json, text/html;q=0.1", false},
+		{"*/*", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := wantsHTML(tt.accept); got != tt.want {
+			t.Errorf("wantsHTML(%q) = %v, want %v", tt.accept, got, tt.want)
+		}
+	}
+}
+
+func TestActivityHTMLURL(t *testing.T) {
+	conf := &util.AppConfig{}
+	conf.Conf.SslDomain = "example.com"
+
+	create := &domain.Activity{
+		ActivityType: "Create",
+		ActorURI:     "https://example.com/users/alice",
+		ObjectURI:    "https://example.com/notes/123",
+	}
+	if got := activityHTMLURL(create, conf); got != "/u/alice/123" {
+		t.Errorf("Expected local post page for Create, got %s", got)
+	}
+
+	like := &domain.Activity{
+		ActivityType: "Like",
+		ActorURI:     "https://example.com/users/alice",
+		ObjectURI:    "https://remote.example/notes/456",
+	}
+	if got := activityHTMLURL(like, conf); got != like.ObjectURI {
+		t.Errorf("Expected liked object URI for Like, got %s", got)
+	}
+}
@@ -1,13 +1,17 @@
 package web
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"time"
 
 	"strings"
 
 	"github.com/deemkeen/stegodon/db"
+	"github.com/deemkeen/stegodon/domain"
 	"github.com/deemkeen/stegodon/util"
 	"github.com/google/uuid"
 )
@@ -23,12 +27,65 @@ const (
 	sharedInbox
 )
 
+// ErrAccountDisabled is returned by GetActor for accounts an admin has disabled
+var ErrAccountDisabled = errors.New("account disabled")
+
 func GetActor(actor string, conf *util.AppConfig) (error, string) {
-	err, acc := db.GetDB().ReadAccByUsername(actor)
+	acc, errJSON, err := readFederatedAccount(actor)
+	if err != nil {
+		return err, errJSON
+	}
+	return nil, actorJSON(acc, conf)
+}
+
+// GetActorKey returns an actor with only its id, inbox and public key. In secure mode it is
+// served to unsigned fetches, so servers that don't sign them can still verify our deliveries.
+func GetActorKey(actor string, conf *util.AppConfig) (error, string) {
+	acc, errJSON, err := readFederatedAccount(actor)
+	if err != nil {
+		return err, errJSON
+	}
+	actorURI := getIRI(conf.Conf.SslDomain, acc.Username, id)
+	jsonBytes, err := json.Marshal(map[string]any{
+		"@context": []string{
+			"https://www.w3.org/ns/activitystreams",
+			"https://w3id.org/security/v1",
+		},
+		"id":                actorURI,
+		"type":              "Person",
+		"preferredUsername": acc.Username,
+		"inbox":             getIRI(conf.Conf.SslDomain, acc.Username, inbox),
+		"publicKey": map[string]string{
+			"id":           actorURI + "#main-key",
+			"owner":        actorURI,
+			"publicKeyPem": acc.WebPublicKey,
+		},
+	})
 	if err != nil {
 		return err, "{}"
 	}
+	return nil, string(jsonBytes)
+}
+
+// readFederatedAccount reads a local account that is served to other servers, returning the
+// JSON error body to respond with if it isn't
+func readFederatedAccount(actor string) (*domain.Account, string, error) {
+	acc, err := db.GetDB().ReadAccByUsername(actor)
+	if err != nil {
+		return nil, "{}", err
+	}
+	// Accounts awaiting approval don't federate
+	if acc.Pending {
+		return nil, "{}", sql.ErrNoRows
+	}
+	if acc.Disabled {
+		return nil, `{"error":"Account disabled"}`, ErrAccountDisabled
+	}
+	return acc, "", nil
+}
 
+// actorJSON renders an account as an ActivityPub Person
+func actorJSON(acc *domain.Account, conf *util.AppConfig) string {
 	username := acc.Username
 	pubKey := strings.ReplaceAll(acc.WebPublicKey, "\n", "\\n")
 
@@ -45,7 +102,7 @@ func GetActor(actor string, conf *util.AppConfig) (error, string) {
 	// Use default logo for all users
 	logoURL := fmt.Sprintf("https://%s/static/stegologo.png", conf.Conf.SslDomain)
 
-	return nil, fmt.Sprintf(
+	return fmt.Sprintf(
 		`{
 					"@context": [
 						"https://www.w3.org/ns/activitystreams",
@@ -62,8 +119,9 @@ func GetActor(actor string, conf *util.AppConfig) (error, string) {
 					"followers": "%s",
 					"following": "%s",
 					"url": "%s",
-  					"manuallyApprovesFollowers": false,
-					"discoverable": true,
+  					"manuallyApprovesFollowers": %t,
+					"locked": %t,
+					"discoverable": %t,
 					"icon": {
 						"type": "Image",
 						"mediaType": "image/png",
@@ -85,6 +143,7 @@ func GetActor(actor string, conf *util.AppConfig) (error, string) {
 		getIRI(conf.Conf.SslDomain, username, followers),
 		getIRI(conf.Conf.SslDomain, username, following),
 		fmt.Sprintf("https://%s/u/%s", conf.Conf.SslDomain, username),
+		acc.ManuallyApprovesFollowers, acc.ManuallyApprovesFollowers, acc.Discoverable,
 		logoURL,
 		getIRI(conf.Conf.SslDomain, username, sharedInbox),
 		getIRI(conf.Conf.SslDomain, username, id),
@@ -112,27 +171,60 @@ func getIRI(domain string, username string, action action) string {
 	}
 }
 
-// GetNoteObject returns a Note object as ActivityPub JSON
+// ErrNoteDeleted is returned by GetNoteObject for notes that only exist as a tombstone
+var ErrNoteDeleted = errors.New("note deleted")
+
+// GetNoteObject returns a Note object as ActivityPub JSON. For deleted notes it returns
+// ErrNoteDeleted together with the note's Tombstone.
 func GetNoteObject(noteId uuid.UUID, conf *util.AppConfig) (error, string) {
 	database := db.GetDB()
-	err, note := database.ReadNoteId(noteId)
+	note, err := database.ReadNoteIdWithReplyInfo(noteId)
 	if err != nil {
 		return err, "{}"
 	}
+	// Local-only notes are never exposed to other servers
+	if note.IsLocalOnly() {
+		return fmt.Errorf("note %s is local-only", noteId), "{}"
+	}
+	if note.IsDeleted() {
+		tombstone := map[string]any{
+			"@context":   "https://www.w3.org/ns/activitystreams",
+			"id":         util.BuildNoteObjectURI(conf, note.Id),
+			"type":       "Tombstone",
+			"formerType": "Note",
+			"deleted":    note.DeletedAt.UTC().Format(time.RFC3339),
+		}
+		jsonBytes, err := json.Marshal(tombstone)
+		if err != nil {
+			return err, "{}"
+		}
+		return ErrNoteDeleted, string(jsonBytes)
+	}
+
+	// Federated notes are served as they were delivered, so the dereferenced object matches
+	// the one in the Create or Update; only the interaction counts are current
+	if objectJSON, err := database.ReadNoteObjectJSON(noteId); err == nil && objectJSON != "" {
+		var noteObj map[string]any
+		if err := json.Unmarshal([]byte(objectJSON), &noteObj); err == nil {
+			replyCount, _ := database.CountTotalRepliesByNoteId(noteId)
+			addNoteCollections(noteObj, util.BuildNoteObjectURI(conf, note.Id), replyCount, note.LikeCount, note.BoostCount)
+			if jsonBytes, err := json.Marshal(noteObj); err == nil {
+				return nil, string(jsonBytes)
+			}
+		}
+		log.Printf("GetNoteObject: Stored object of note %s is invalid, rebuilding it", noteId)
+	}
 
 	// Get the account to build actor URI
-	err, account := database.ReadAccByUsername(note.CreatedBy)
+	account, err := database.ReadAccByUsername(note.CreatedBy)
 	if err != nil {
 		return err, "{}"
 	}
 
 	actorURI := fmt.Sprintf("https://%s/users/%s", conf.Conf.SslDomain, account.Username)
-	noteURI := fmt.Sprintf("https://%s/notes/%s", conf.Conf.SslDomain, note.Id.String())
+	noteURI := util.BuildNoteObjectURI(conf, note.Id)
 	baseURL := fmt.Sprintf("https://%s", conf.Conf.SslDomain)
 
-	// Convert Markdown links to HTML for ActivityPub content
-	contentHTML := util.MarkdownLinksToHTML(note.Message)
-
 	// Build cc list - start with followers
 	ccList := []string{
 		fmt.Sprintf("https://%s/users/%s/followers", conf.Conf.SslDomain, account.Username),
@@ -150,15 +242,12 @@ func GetNoteObject(noteId uuid.UUID, conf *util.AppConfig) (error, string) {
 		})
 	}
 
-	// Convert hashtags to ActivityPub HTML
-	contentHTML = util.HashtagsToActivityPubHTML(contentHTML, baseURL)
-
 	// Extract mentions and try to resolve from stored data or WebFinger
 	mentions := util.ParseMentions(note.Message)
 	mentionURIs := make(map[string]string)
 
 	// First try to get stored mentions from database
-	err, storedMentions := database.ReadMentionsByNoteId(noteId)
+	storedMentions, err := database.ReadMentionsByNoteId(noteId)
 	if err == nil && len(storedMentions) > 0 {
 		for _, stored := range storedMentions {
 			mentionKey := fmt.Sprintf("@%s@%s", stored.MentionedUsername, stored.MentionedDomain)
@@ -195,10 +284,8 @@ func GetNoteObject(noteId uuid.UUID, conf *util.AppConfig) (error, string) {
 		}
 	}
 
-	// Convert mentions to ActivityPub HTML
-	if len(mentionURIs) > 0 {
-		contentHTML = util.MentionsToActivityPubHTML(contentHTML, mentionURIs)
-	}
+	// Render the HTML content once mention URIs are resolved
+	contentHTML := util.MessageToActivityPubHTML(note.Message, baseURL, mentionURIs)
 
 	// Build the Note object
 	noteObj := map[string]any{
@@ -216,11 +303,24 @@ func GetNoteObject(noteId uuid.UUID, conf *util.AppConfig) (error, string) {
 		"cc": ccList,
 	}
 
+	if note.Language != "" {
+		noteObj["contentMap"] = map[string]string{note.Language: contentHTML}
+	}
+
 	// Add tag array if we have hashtags or mentions
 	if len(tags) > 0 {
 		noteObj["tag"] = tags
 	}
 
+	// Add inReplyTo for replies
+	if note.InReplyToURI != "" {
+		noteObj["inReplyTo"] = note.InReplyToURI
+	}
+
+	// Add interaction counts (likes/shares come from the denormalized counters)
+	replyCount, _ := database.CountTotalRepliesByNoteId(noteId)
+	addNoteCollections(noteObj, noteURI, replyCount, note.LikeCount, note.BoostCount)
+
 	// Add updated field if note was edited
 	if note.EditedAt != nil {
 		noteObj["updated"] = note.EditedAt.Format(time.RFC3339)
@@ -234,6 +334,25 @@ func GetNoteObject(noteId uuid.UUID, conf *util.AppConfig) (error, string) {
 	return nil, string(jsonBytes)
 }
 
+// addNoteCollections adds Mastodon-style replies/likes/shares collections with totalItems to a Note object
+func addNoteCollections(noteObj map[string]any, noteURI string, replyCount, likeCount, boostCount int) {
+	noteObj["replies"] = map[string]any{
+		"id":         noteURI + "/replies",
+		"type":       "Collection",
+		"totalItems": replyCount,
+	}
+	noteObj["likes"] = map[string]any{
+		"id":         noteURI + "/likes",
+		"type":       "Collection",
+		"totalItems": likeCount,
+	}
+	noteObj["shares"] = map[string]any{
+		"id":         noteURI + "/shares",
+		"type":       "Collection",
+		"totalItems": boostCount,
+	}
+}
+
 // GetFollowersCollection returns an ActivityPub OrderedCollection of followers
 // Always uses paging for compatibility with Mastodon and other servers
 func GetFollowersCollection(actor string, conf *util.AppConfig, followerURIs []string) string {
@@ -368,3 +368,44 @@ func TestGetFollowingPage(t *testing.T) {
 		}
 	}
 }
+
+func TestAddNoteCollections(t *testing.T) {
+	noteURI := "https://example.com/notes/123"
+	noteObj := map[string]any{"id": noteURI, "type": "Note"}
+
+	addNoteCollections(noteObj, noteURI, 3, 5, 2)
+
+	// Round-trip through JSON like a remote server would see it
+	jsonBytes, err := json.Marshal(noteObj)
+	if err != nil {
+		t.Fatalf("Failed to marshal note: %v", err)
+	}
+	var parsed map[string]any
+	if err := json.Unmarshal(jsonBytes, &parsed); err != nil {
+		t.Fatalf("Failed to parse JSON: %v", err)
+	}
+
+	tests := []struct {
+		field string
+		want  float64
+	}{
+		{"replies", 3},
+		{"likes", 5},
+		{"shares", 2},
+	}
+	for _, tt := range tests {
+		collection, ok := parsed[tt.field].(map[string]any)
+		if !ok {
+			t.Fatalf("Expected %s to be a collection, got %v", tt.field, parsed[tt.field])
+		}
+		if collection["type"] != "Collection" {
+			t.Errorf("Expected %s type Collection, got %v", tt.field, collection["type"])
+		}
+		if collection["id"] != noteURI+"/"+tt.field {
+			t.Errorf("Expected %s id %s/%s, got %v", tt.field, noteURI, tt.field, collection["id"])
+		}
+		if collection["totalItems"] != tt.want {
+			t.Errorf("Expected %s totalItems %v, got %v", tt.field, tt.want, collection["totalItems"])
+		}
+	}
+}
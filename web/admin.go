@@ -0,0 +1,129 @@
+package web
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/deemkeen/stegodon/activitypub"
+	"github.com/deemkeen/stegodon/db"
+	"github.com/deemkeen/stegodon/domain"
+	"github.com/deemkeen/stegodon/util"
+	"github.com/gin-gonic/gin"
+)
+
+// AdminStats is the instance overview returned by /admin/stats
+type AdminStats struct {
+	Users               AdminUserStats     `json:"users"`
+	LocalPosts          int                `json:"local_posts"`
+	RemoteAccounts      int                `json:"remote_accounts"`
+	Activities          int                `json:"activities"`
+	Deliveries          AdminDeliveryStats `json:"deliveries"`
+	UnreadNotifications int                `json:"unread_notifications"`
+	Relays              []AdminRelayStats  `json:"relays"`
+}
+
+// AdminUserStats counts local accounts and how many of them posted recently
+type AdminUserStats struct {
+	Total          int `json:"total"`
+	ActiveMonth    int `json:"active_month"`
+	ActiveHalfyear int `json:"active_halfyear"`
+}
+
+// AdminDeliveryStats describes the outgoing delivery queue
+type AdminDeliveryStats struct {
+	Queued      int `json:"queued"`       // Waiting to be sent or retried
+	DeadLetters int `json:"dead_letters"` // Gave up retrying, kept for inspection
+}
+
+// AdminRelayStats is the status of a subscribed relay
+type AdminRelayStats struct {
+	ActorURI       string     `json:"actor_uri"`
+	Name           string     `json:"name"`
+	Status         string     `json:"status"`
+	Paused         bool       `json:"paused"`
+	Stale          bool       `json:"stale"`
+	Trusted        bool       `json:"trusted"` // False if trustedRelays/deniedRelays reject its content
+	LastActivityAt *time.Time `json:"last_activity_at,omitempty"`
+	ActivityCount  int        `json:"activity_count"`
+	PerHour        float64    `json:"per_hour"`
+}
+
+// AdminOnlyMiddleware rejects API requests whose account isn't an admin.
+// It runs after APIAuthMiddleware, which stores the authenticated account.
+func AdminOnlyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		account, ok := c.Get(apiAccountKey)
+		if acc, isAccount := account.(*domain.Account); !ok || !isAccount || !acc.IsAdmin {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// GetAdminStats returns the instance counters, delivery queue depth and relay statuses as JSON
+func GetAdminStats(conf *util.AppConfig) (error, string) {
+	database := db.GetDB()
+	var stats AdminStats
+
+	counters := []struct {
+		name  string
+		count func() (int, error)
+		dst   *int
+	}{
+		{"accounts", database.CountAccounts, &stats.Users.Total},
+		{"active users (month)", database.CountActiveUsersMonth, &stats.Users.ActiveMonth},
+		{"active users (half year)", database.CountActiveUsersHalfYear, &stats.Users.ActiveHalfyear},
+		{"local posts", database.CountLocalPosts, &stats.LocalPosts},
+		{"remote accounts", database.CountRemoteAccounts, &stats.RemoteAccounts},
+		{"activities", database.CountActivities, &stats.Activities},
+		{"queued deliveries", database.CountQueuedDeliveries, &stats.Deliveries.Queued},
+		{"dead letters", database.CountDeadLetters, &stats.Deliveries.DeadLetters},
+		{"unread notifications", database.CountUnreadNotifications, &stats.UnreadNotifications},
+	}
+	for _, counter := range counters {
+		count, err := counter.count()
+		if err != nil {
+			log.Printf("GetAdminStats: Failed to count %s: %v", counter.name, err)
+			return err, `{"error":"Failed to read stats"}`
+		}
+		*counter.dst = count
+	}
+
+	health, err := database.ReadRelayHealth()
+	if err != nil {
+		log.Printf("GetAdminStats: Failed to read relays: %v", err)
+		return err, `{"error":"Failed to read stats"}`
+	}
+	stats.Relays = makeAdminRelayStats(*health, conf, time.Now())
+
+	jsonData, err := json.Marshal(stats)
+	if err != nil {
+		log.Printf("GetAdminStats: Failed to marshal stats: %v", err)
+		return err, `{"error":"Failed to encode stats"}`
+	}
+	return nil, string(jsonData)
+}
+
+// makeAdminRelayStats converts relay health into the stats entries, marking stale and untrusted relays
+func makeAdminRelayStats(health []domain.RelayHealth, conf *util.AppConfig, now time.Time) []AdminRelayStats {
+	staleWindow := activitypub.RelayStaleWindow(conf)
+	relays := make([]AdminRelayStats, 0, len(health))
+	for _, h := range health {
+		relays = append(relays, AdminRelayStats{
+			ActorURI:       h.ActorURI,
+			Name:           h.Name,
+			Status:         h.Status,
+			Paused:         h.Paused,
+			Stale:          h.IsStale(staleWindow, now),
+			Trusted:        conf.IsTrustedRelay(h.ActorURI),
+			LastActivityAt: h.LastActivityAt,
+			ActivityCount:  h.ActivityCount,
+			PerHour:        h.PerHour,
+		})
+	}
+	return relays
+}
@@ -0,0 +1,78 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/deemkeen/stegodon/domain"
+	"github.com/deemkeen/stegodon/util"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func TestAdminOnlyMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name     string
+		account  *domain.Account
+		wantCode int
+	}{
+		{"no account", nil, http.StatusForbidden},
+		{"regular user", &domain.Account{Id: uuid.New(), Username: "bob"}, http.StatusForbidden},
+		{"admin", &domain.Account{Id: uuid.New(), Username: "alice", IsAdmin: true}, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/admin/stats", func(c *gin.Context) {
+				if tt.account != nil {
+					c.Set(apiAccountKey, tt.account)
+				}
+				c.Next()
+			}, AdminOnlyMiddleware(), func(c *gin.Context) {
+				c.String(http.StatusOK, "ok")
+			})
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", "/admin/stats", nil))
+			if w.Code != tt.wantCode {
+				t.Errorf("Expected status %d, got %d", tt.wantCode, w.Code)
+			}
+		})
+	}
+}
+
+func TestMakeAdminRelayStats(t *testing.T) {
+	now := time.Now()
+	recent := now.Add(-time.Hour)
+	health := []domain.RelayHealth{
+		{ActorURI: "https://relay.example.com/actor", Status: "active", Since: now.Add(-72 * time.Hour), LastActivityAt: &recent, ActivityCount: 42},
+		{ActorURI: "https://quiet.example.com/actor", Status: "active", Since: now.Add(-72 * time.Hour)},
+		{ActorURI: "https://paused.example.com/actor", Status: "active", Paused: true, Since: now.Add(-72 * time.Hour)},
+	}
+
+	conf := &util.AppConfig{}
+	conf.Conf.RelayStaleHours = 24
+	conf.Conf.DeniedRelays = []string{"quiet.example.com"}
+
+	relays := makeAdminRelayStats(health, conf, now)
+	if len(relays) != 3 {
+		t.Fatalf("Expected 3 relays, got %d", len(relays))
+	}
+	if relays[0].Stale || relays[0].ActivityCount != 42 {
+		t.Errorf("Expected a fresh relay with 42 activities, got %+v", relays[0])
+	}
+	if !relays[1].Stale {
+		t.Error("Expected a relay silent for 72h to be stale")
+	}
+	if relays[2].Stale {
+		t.Error("Expected a paused relay not to be stale")
+	}
+	if !relays[0].Trusted || relays[1].Trusted {
+		t.Errorf("Expected only the denied relay to be untrusted, got %v and %v", relays[0].Trusted, relays[1].Trusted)
+	}
+}
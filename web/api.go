@@ -0,0 +1,398 @@
+package web
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/deemkeen/stegodon/db"
+	"github.com/deemkeen/stegodon/domain"
+	"github.com/deemkeen/stegodon/util"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/render"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultAPITimelineLimit = 20
+	maxAPITimelineLimit     = 40
+
+	// apiTimelineWindow is how many of the newest posts a timeline is paginated over
+	apiTimelineWindow = 1000
+
+	// apiAccountKey is the gin context key holding the account an API request is authenticated as
+	apiAccountKey = "apiAccount"
+)
+
+// ErrInvalidCursor is returned for timeline cursors that weren't issued by this server
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// APIPost is a post in a timeline returned by the JSON API
+type APIPost struct {
+	ID           string    `json:"id"`
+	Author       string    `json:"author"`
+	Content      string    `json:"content"`
+	CreatedAt    time.Time `json:"created_at"`
+	URL          string    `json:"url"`
+	Local        bool      `json:"local"`
+	RepliesCount int       `json:"replies_count"`
+	LikesCount   int       `json:"likes_count"`
+	BoostsCount  int       `json:"boosts_count"`
+	Quote        *APIQuote `json:"quote,omitempty"`
+	BoostedBy    []string  `json:"boosted_by,omitempty"`
+	Thread       []APIPost `json:"thread,omitempty"` // Later posts of the author's self-thread, oldest first
+}
+
+// APIQuote is the post quoted by an APIPost
+type APIQuote struct {
+	URL     string `json:"url"`
+	Author  string `json:"author"`
+	Content string `json:"content"`
+}
+
+// APITimeline is a page of a timeline. NextCursor is passed back as the cursor query
+// parameter to get the following (older) page; it is empty on the last page.
+type APITimeline struct {
+	Posts      []APIPost `json:"posts"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+}
+
+// APIProfilePosts is a page of a user's profile posts. NextPage is passed back as the page
+// query parameter to get the following (older) page; it is omitted on the last page.
+type APIProfilePosts struct {
+	Posts    []APIPost `json:"posts"`
+	Page     int       `json:"page"`
+	NextPage int       `json:"next_page,omitempty"`
+}
+
+// ErrProfileNotFound is returned for profile requests of unknown users
+var ErrProfileNotFound = errors.New("profile not found")
+
+// apiTokenLookups are the database reads of APIAuthMiddleware, as fields so tests can replace them
+type apiTokenLookups struct {
+	readToken   func(tokenHash string) (*domain.APIToken, error)
+	readAccount func(id uuid.UUID) (*domain.Account, error)
+}
+
+var defaultAPITokenLookups = apiTokenLookups{
+	readToken: func(tokenHash string) (*domain.APIToken, error) {
+		return db.GetDB().ReadAPITokenByHash(tokenHash)
+	},
+	readAccount: func(id uuid.UUID) (*domain.Account, error) {
+		return db.GetDB().ReadAccById(id)
+	},
+}
+
+// APIAuthMiddleware authenticates API requests by their "Authorization: Bearer <token>" header
+// and requires the token to grant scope. Tokens are issued per account from the TUI; the
+// authenticated account is stored in the context. Tokens of disabled accounts are refused.
+func APIAuthMiddleware(scope string) gin.HandlerFunc {
+	return apiAuthMiddleware(scope, defaultAPITokenLookups)
+}
+
+func apiAuthMiddleware(scope string, lookups apiTokenLookups) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rawToken, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		rawToken = strings.TrimSpace(rawToken)
+		if !ok || rawToken == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Missing bearer token"})
+			c.Abort()
+			return
+		}
+
+		token, err := lookups.readToken(util.HashAPIToken(rawToken))
+		if err != nil || token == nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			c.Abort()
+			return
+		}
+		if !token.HasScope(scope) {
+			c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("Token lacks the %s scope", scope)})
+			c.Abort()
+			return
+		}
+
+		account, err := lookups.readAccount(token.AccountId)
+		if err != nil || account == nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			c.Abort()
+			return
+		}
+		if account.Disabled {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Account disabled"})
+			c.Abort()
+			return
+		}
+
+		c.Set(apiAccountKey, account)
+		c.Next()
+	}
+}
+
+// IssueAPIToken creates a new API token with the given scopes for an account and returns
+// the raw token. Only its hash is stored, so the raw token can't be shown again later.
+func IssueAPIToken(accountId uuid.UUID, scopes []string) (string, error) {
+	if len(scopes) == 0 {
+		return "", fmt.Errorf("a token needs at least one scope")
+	}
+	rawToken := util.RandomString(64)
+	token := &domain.APIToken{
+		Id:        uuid.New(),
+		AccountId: accountId,
+		TokenHash: util.HashAPIToken(rawToken),
+		Scopes:    scopes,
+		CreatedAt: time.Now(),
+	}
+	if err := db.GetDB().CreateAPIToken(token); err != nil {
+		return "", fmt.Errorf("failed to store token: %w", err)
+	}
+	return rawToken, nil
+}
+
+// ParseAPITimelineLimit parses the limit query parameter, falling back to the default
+// for missing or invalid values and capping it at maxAPITimelineLimit
+func ParseAPITimelineLimit(limitStr string) int {
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit < 1 {
+		return defaultAPITimelineLimit
+	}
+	return min(limit, maxAPITimelineLimit)
+}
+
+// ParsePage parses the page query parameter, falling back to the first page for missing or invalid values
+func ParsePage(pageStr string) int {
+	page, err := strconv.Atoi(pageStr)
+	if err != nil || page < 1 {
+		return 1
+	}
+	return page
+}
+
+// ParseWithReplies reports whether the with_replies query parameter asks for replies to be included
+func ParseWithReplies(value string) bool {
+	withReplies, err := strconv.ParseBool(value)
+	return err == nil && withReplies
+}
+
+// GetProfilePosts returns a page of a local user's posts as JSON, newest first. Replies are
+// only included with withReplies, like the "Posts and replies" tab of a Mastodon profile.
+func GetProfilePosts(username string, page, limit int, withReplies bool, conf *util.AppConfig) (error, string) {
+	database := db.GetDB()
+	account, err := database.ReadAccByUsername(username)
+	if err != nil || account == nil {
+		return ErrProfileNotFound, `{"error":"User not found"}`
+	}
+
+	// One extra note tells whether there is a next page
+	notes, err := database.ReadNotesByUsernamePaged(account.Username, limit+1, (page-1)*limit, withReplies)
+	if err != nil {
+		log.Printf("GetProfilePosts: Failed to read notes of %s: %v", username, err)
+		return err, `{"error":"Failed to read posts"}`
+	}
+
+	jsonData, err := json.Marshal(makeAPIProfilePosts(*notes, page, limit, conf))
+	if err != nil {
+		log.Printf("GetProfilePosts: Failed to marshal posts: %v", err)
+		return err, `{"error":"Failed to encode posts"}`
+	}
+	return nil, string(jsonData)
+}
+
+// makeAPIProfilePosts converts up to limit notes to a page of profile posts; a note beyond
+// limit means there is a next page
+func makeAPIProfilePosts(notes []domain.Note, page, limit int, conf *util.AppConfig) APIProfilePosts {
+	result := APIProfilePosts{Posts: make([]APIPost, 0, min(len(notes), limit)), Page: page}
+	if len(notes) > limit {
+		notes = notes[:limit]
+		result.NextPage = page + 1
+	}
+	for _, note := range notes {
+		result.Posts = append(result.Posts, makeAPIPost(domain.HomePost{
+			ID:         note.Id,
+			Author:     note.CreatedBy,
+			Content:    note.Message,
+			Time:       note.CreatedAt,
+			IsLocal:    true,
+			NoteID:     note.Id,
+			LikeCount:  note.LikeCount,
+			BoostCount: note.BoostCount,
+		}, conf))
+	}
+	return result
+}
+
+// GetHomeTimeline returns a page of the account's home timeline (followed local and remote posts) as JSON
+func GetHomeTimeline(account *domain.Account, cursor string, limit int, conf *util.AppConfig) (error, string) {
+	posts, err := db.GetDB().ReadHomeTimelinePosts(account.Id, apiTimelineWindow)
+	if err != nil {
+		log.Printf("GetHomeTimeline: Failed to read home timeline of %s: %v", account.Username, err)
+		return err, `{"error":"Failed to read timeline"}`
+	}
+	return marshalTimeline(*posts, cursor, limit, conf)
+}
+
+// GetPublicTimeline returns a page of the top-level posts of all local users as JSON
+func GetPublicTimeline(cursor string, limit int, conf *util.AppConfig) (error, string) {
+	notes, err := db.GetDB().ReadAllNotes()
+	if err != nil {
+		log.Printf("GetPublicTimeline: Failed to read notes: %v", err)
+		return err, `{"error":"Failed to read timeline"}`
+	}
+
+	var posts []domain.HomePost
+	for _, note := range *notes {
+		if note.InReplyToURI != "" {
+			continue
+		}
+		posts = append(posts, domain.HomePost{
+			ID:         note.Id,
+			Author:     note.CreatedBy,
+			Content:    note.Message,
+			Time:       note.CreatedAt,
+			IsLocal:    true,
+			NoteID:     note.Id,
+			LikeCount:  note.LikeCount,
+			BoostCount: note.BoostCount,
+		})
+	}
+	return marshalTimeline(posts, cursor, limit, conf)
+}
+
+// GetTagTimeline returns a page of the local and federated posts with a hashtag as JSON
+func GetTagTimeline(tag string, cursor string, limit int, conf *util.AppConfig) (error, string) {
+	posts, err := db.GetDB().ReadFederatedNotesByHashtag(strings.ToLower(tag), apiTimelineWindow, 0)
+	if err != nil {
+		log.Printf("GetTagTimeline: Failed to read posts tagged #%s: %v", tag, err)
+		return err, `{"error":"Failed to read timeline"}`
+	}
+	return marshalTimeline(*posts, cursor, limit, conf)
+}
+
+// marshalTimeline encodes the page of posts that follows cursor
+func marshalTimeline(posts []domain.HomePost, cursor string, limit int, conf *util.AppConfig) (error, string) {
+	page, nextCursor, err := paginateTimeline(posts, cursor, limit)
+	if err != nil {
+		return err, `{"error":"Invalid cursor"}`
+	}
+
+	timeline := APITimeline{Posts: make([]APIPost, 0, len(page)), NextCursor: nextCursor}
+	for _, post := range page {
+		timeline.Posts = append(timeline.Posts, makeAPIPost(post, conf))
+	}
+
+	jsonData, err := json.Marshal(timeline)
+	if err != nil {
+		log.Printf("marshalTimeline: Failed to marshal timeline: %v", err)
+		return err, `{"error":"Failed to encode timeline"}`
+	}
+	return nil, string(jsonData)
+}
+
+// paginateTimeline orders posts newest first and returns up to limit posts after cursor,
+// plus the cursor of the next page. Cursors identify a post rather than an offset, so
+// pages stay stable while new posts arrive.
+func paginateTimeline(posts []domain.HomePost, cursor string, limit int) ([]domain.HomePost, string, error) {
+	sorted := make([]domain.HomePost, len(posts))
+	copy(sorted, posts)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return timelineBefore(sorted[i].Time, sorted[i].ID, sorted[j].Time, sorted[j].ID)
+	})
+
+	start := 0
+	if cursor != "" {
+		cursorTime, cursorId, err := decodeTimelineCursor(cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		start = sort.Search(len(sorted), func(i int) bool {
+			return timelineBefore(cursorTime, cursorId, sorted[i].Time, sorted[i].ID)
+		})
+	}
+
+	end := min(start+limit, len(sorted))
+	page := sorted[start:end]
+
+	nextCursor := ""
+	if end < len(sorted) && len(page) > 0 {
+		last := page[len(page)-1]
+		nextCursor = encodeTimelineCursor(last.Time, last.ID)
+	}
+	return page, nextCursor, nil
+}
+
+// timelineBefore reports whether the post (t1, id1) is listed before (t2, id2): newest first, ties by id
+func timelineBefore(t1 time.Time, id1 uuid.UUID, t2 time.Time, id2 uuid.UUID) bool {
+	if !t1.Equal(t2) {
+		return t1.After(t2)
+	}
+	return id1.String() > id2.String()
+}
+
+// encodeTimelineCursor returns the opaque cursor pointing at a post
+func encodeTimelineCursor(t time.Time, id uuid.UUID) string {
+	return base64.RawURLEncoding.EncodeToString(fmt.Appendf(nil, "%d_%s", t.UnixNano(), id))
+}
+
+// decodeTimelineCursor returns the time and id of the post a cursor points at
+func decodeTimelineCursor(cursor string) (time.Time, uuid.UUID, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, uuid.Nil, ErrInvalidCursor
+	}
+	nanosStr, idStr, _ := strings.Cut(string(raw), "_")
+	nanos, err := strconv.ParseInt(nanosStr, 10, 64)
+	if err != nil {
+		return time.Time{}, uuid.Nil, ErrInvalidCursor
+	}
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return time.Time{}, uuid.Nil, ErrInvalidCursor
+	}
+	return time.Unix(0, nanos), id, nil
+}
+
+// makeAPIPost converts a timeline post to its JSON API shape
+func makeAPIPost(post domain.HomePost, conf *util.AppConfig) APIPost {
+	apiPost := APIPost{
+		ID:           post.ID.String(),
+		Author:       post.Author,
+		Content:      post.Content,
+		CreatedAt:    post.Time.UTC(),
+		URL:          post.ObjectURI,
+		Local:        post.IsLocal,
+		RepliesCount: post.ReplyCount,
+		LikesCount:   post.LikeCount,
+		BoostsCount:  post.BoostCount,
+		BoostedBy:    post.BoostedBy,
+	}
+	if post.IsLocal {
+		apiPost.URL = fmt.Sprintf("https://%s/u/%s/%s", conf.Conf.SslDomain, strings.TrimPrefix(post.Author, "@"), post.NoteID)
+	}
+	if post.QuoteURI != "" {
+		apiPost.Quote = &APIQuote{URL: post.QuoteURI, Author: post.QuoteAuthor, Content: post.QuoteContent}
+	}
+	for _, part := range post.SelfThread {
+		apiPost.Thread = append(apiPost.Thread, makeAPIPost(part, conf))
+	}
+	return apiPost
+}
+
+// renderTimeline writes a timeline returned by one of the Get*Timeline functions
+func renderTimeline(c *gin.Context, err error, timeline string) {
+	c.Header("Content-Type", "application/json; charset=utf-8")
+	switch {
+	case errors.Is(err, ErrInvalidCursor):
+		c.Render(http.StatusBadRequest, render.String{Format: timeline})
+	case err != nil:
+		c.Render(http.StatusInternalServerError, render.String{Format: timeline})
+	default:
+		c.Render(http.StatusOK, render.String{Format: timeline})
+	}
+}
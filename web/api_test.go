@@ -0,0 +1,216 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/deemkeen/stegodon/domain"
+	"github.com/deemkeen/stegodon/util"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func TestParseAPITimelineLimit(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int
+	}{
+		{"", defaultAPITimelineLimit},
+		{"abc", defaultAPITimelineLimit},
+		{"-1", defaultAPITimelineLimit},
+		{"5", 5},
+		{"1000", maxAPITimelineLimit},
+	}
+
+	for _, tt := range tests {
+		if result := ParseAPITimelineLimit(tt.input); result != tt.expected {
+			t.Errorf("ParseAPITimelineLimit(%q) = %d, want %d", tt.input, result, tt.expected)
+		}
+	}
+}
+
+func TestAPIAuthMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	active := &domain.Account{Id: uuid.New(), Username: "alice"}
+	disabled := &domain.Account{Id: uuid.New(), Username: "bob", Disabled: true}
+	tokens := map[string]*domain.APIToken{
+		util.HashAPIToken("alice-token"): {Id: uuid.New(), AccountId: active.Id, Scopes: []string{"read"}},
+		util.HashAPIToken("bob-token"):   {Id: uuid.New(), AccountId: disabled.Id, Scopes: []string{"read"}},
+	}
+	lookups := apiTokenLookups{
+		readToken: func(tokenHash string) (*domain.APIToken, error) {
+			if token, ok := tokens[tokenHash]; ok {
+				return token, nil
+			}
+			return nil, errors.New("not found")
+		},
+		readAccount: func(id uuid.UUID) (*domain.Account, error) {
+			for _, account := range []*domain.Account{active, disabled} {
+				if account.Id == id {
+					return account, nil
+				}
+			}
+			return nil, errors.New("not found")
+		},
+	}
+
+	tests := []struct {
+		name     string
+		header   string
+		scope    string
+		wantCode int
+	}{
+		{"no token", "", "read", http.StatusUnauthorized},
+		{"unknown token", "Bearer other-token", "read", http.StatusUnauthorized},
+		{"missing scope", "Bearer alice-token", "write", http.StatusForbidden},
+		{"disabled account", "Bearer bob-token", "read", http.StatusForbidden},
+		{"valid token", "Bearer alice-token", "read", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/api/timeline", apiAuthMiddleware(tt.scope, lookups), func(c *gin.Context) {
+				c.String(http.StatusOK, c.MustGet(apiAccountKey).(*domain.Account).Username)
+			})
+
+			req := httptest.NewRequest("GET", "/api/timeline", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.wantCode {
+				t.Errorf("Expected status %d, got %d: %s", tt.wantCode, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestPaginateTimeline(t *testing.T) {
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	var posts []domain.HomePost
+	for i := range 5 {
+		posts = append(posts, domain.HomePost{ID: uuid.New(), Time: base.Add(time.Duration(i) * time.Minute)})
+	}
+	// Two posts at the same time must still be paged through without loss or repeats
+	posts = append(posts, domain.HomePost{ID: uuid.New(), Time: base.Add(2 * time.Minute)})
+
+	seen := map[uuid.UUID]bool{}
+	var previous time.Time
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > len(posts) {
+			t.Fatal("Pagination did not terminate")
+		}
+		page, next, err := paginateTimeline(posts, cursor, 2)
+		if err != nil {
+			t.Fatalf("paginateTimeline failed: %v", err)
+		}
+		for _, post := range page {
+			if seen[post.ID] {
+				t.Errorf("Post %s returned twice", post.ID)
+			}
+			if !previous.IsZero() && post.Time.After(previous) {
+				t.Errorf("Posts not ordered newest first")
+			}
+			seen[post.ID] = true
+			previous = post.Time
+		}
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	if len(seen) != len(posts) {
+		t.Errorf("Expected %d posts across all pages, got %d", len(posts), len(seen))
+	}
+
+	// New posts don't shift pages that were already handed out
+	page, _, _ := paginateTimeline(posts, "", 2)
+	cursor = encodeTimelineCursor(page[1].Time, page[1].ID)
+	before, _, _ := paginateTimeline(posts, cursor, 2)
+	withNew := append([]domain.HomePost{{ID: uuid.New(), Time: base.Add(time.Hour)}}, posts...)
+	after, _, _ := paginateTimeline(withNew, cursor, 2)
+	if before[0].ID != after[0].ID || before[1].ID != after[1].ID {
+		t.Errorf("Expected page after cursor to be unchanged by new posts")
+	}
+}
+
+func TestPaginateTimelineInvalidCursor(t *testing.T) {
+	for _, cursor := range []string{"!!!", "bm90LWEtY3Vyc29y", encodeTimelineCursor(time.Now(), uuid.Nil)[:4]} {
+		if _, _, err := paginateTimeline(nil, cursor, 20); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("Expected ErrInvalidCursor for %q, got %v", cursor, err)
+		}
+	}
+}
+
+func TestMakeAPIPost(t *testing.T) {
+	conf := &util.AppConfig{}
+	conf.Conf.SslDomain = "example.com"
+	noteId := uuid.MustParse("11111111-1111-1111-1111-111111111111")
+	createdAt := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	local := makeAPIPost(domain.HomePost{ID: noteId, NoteID: noteId, Author: "alice", Content: "hello", Time: createdAt, IsLocal: true, LikeCount: 2}, conf)
+	jsonData, err := json.Marshal(local)
+	if err != nil {
+		t.Fatalf("Failed to marshal post: %v", err)
+	}
+	expected := `{"id":"11111111-1111-1111-1111-111111111111","author":"alice","content":"hello","created_at":"2025-01-01T12:00:00Z","url":"https://example.com/u/alice/11111111-1111-1111-1111-111111111111","local":true,"replies_count":0,"likes_count":2,"boosts_count":0}`
+	if string(jsonData) != expected {
+		t.Errorf("Expected %s, got %s", expected, jsonData)
+	}
+
+	remote := makeAPIPost(domain.HomePost{ID: noteId, Author: "@bob@remote.example", ObjectURI: "https://remote.example/notes/1", QuoteURI: "https://remote.example/notes/0", QuoteAuthor: "@carol@remote.example", QuoteContent: "quoted"}, conf)
+	if remote.URL != "https://remote.example/notes/1" {
+		t.Errorf("Expected remote post URL to be its object URI, got %s", remote.URL)
+	}
+	if remote.Quote == nil || remote.Quote.Author != "@carol@remote.example" {
+		t.Errorf("Expected quote to be included, got %+v", remote.Quote)
+	}
+}
+
+func TestParseWithReplies(t *testing.T) {
+	for input, expected := range map[string]bool{"": false, "0": false, "false": false, "yes": false, "1": true, "true": true} {
+		if result := ParseWithReplies(input); result != expected {
+			t.Errorf("ParseWithReplies(%q) = %v, want %v", input, result, expected)
+		}
+	}
+}
+
+func TestMakeAPIProfilePosts(t *testing.T) {
+	conf := &util.AppConfig{}
+	conf.Conf.SslDomain = "example.com"
+	notes := []domain.Note{
+		{Id: uuid.New(), CreatedBy: "alice", Message: "third"},
+		{Id: uuid.New(), CreatedBy: "alice", Message: "second"},
+		{Id: uuid.New(), CreatedBy: "alice", Message: "first"},
+	}
+
+	// A note beyond the limit means there is a next page
+	page := makeAPIProfilePosts(notes, 2, 2, conf)
+	if len(page.Posts) != 2 || page.Posts[1].Content != "second" || page.Page != 2 || page.NextPage != 3 {
+		t.Errorf("Unexpected page: %+v", page)
+	}
+	if page.Posts[0].URL != "https://example.com/u/alice/"+notes[0].Id.String() {
+		t.Errorf("Expected the local post URL, got %s", page.Posts[0].URL)
+	}
+
+	last := makeAPIProfilePosts(notes, 1, 3, conf)
+	if len(last.Posts) != 3 || last.NextPage != 0 {
+		t.Errorf("Expected the last page without a next page, got %+v", last)
+	}
+
+	jsonData, err := json.Marshal(makeAPIProfilePosts(nil, 1, 20, conf))
+	if err != nil {
+		t.Fatalf("Failed to marshal posts: %v", err)
+	}
+	if string(jsonData) != `{"posts":[],"page":1}` {
+		t.Errorf("Expected an empty page, got %s", jsonData)
+	}
+}
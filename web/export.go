@@ -0,0 +1,244 @@
+package web
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/deemkeen/stegodon/db"
+	"github.com/deemkeen/stegodon/domain"
+	"github.com/deemkeen/stegodon/util"
+	"github.com/google/uuid"
+)
+
+// archivePageSize is how many notes are read and written at a time while exporting an outbox
+const archivePageSize = 100
+
+// ExportAccountData streams a zip archive of an account's data to w:
+// actor.json, outbox.json (every note as a Create activity), following.json,
+// followers.json and likes.json. Notes are read a page at a time, so the archive
+// of a prolific account is never held in memory as a whole.
+func ExportAccountData(w io.Writer, accountId uuid.UUID, conf *util.AppConfig) error {
+	database := db.GetDB()
+	account, err := database.ReadAccById(accountId)
+	if err != nil {
+		return fmt.Errorf("failed to get account: %w", err)
+	}
+
+	actorURI := getIRI(conf.Conf.SslDomain, account.Username, id)
+	archive := zip.NewWriter(w)
+
+	// Built from the account directly, so disabled accounts can still be exported
+	entry, err := archive.Create("actor.json")
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(entry, actorJSON(account, conf)); err != nil {
+		return err
+	}
+
+	entry, err = archive.Create("outbox.json")
+	if err != nil {
+		return err
+	}
+	followersURI := getIRI(conf.Conf.SslDomain, account.Username, followers)
+	offset := 0
+	err = writeOrderedCollection(entry, getIRI(conf.Conf.SslDomain, account.Username, outbox), func() ([]any, error) {
+		notes, err := database.ReadNotesPageByUserId(account.Id, archivePageSize, offset)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read notes: %w", err)
+		}
+		offset += len(*notes)
+		activities := makeNoteActivities(*notes, account.Username, conf)
+		for i, note := range *notes {
+			archiveNoteActivity(activities[i], note, followersURI)
+		}
+		return activities, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	followingList, err := database.ReadFollowingByAccountId(account.Id)
+	if err != nil {
+		return fmt.Errorf("failed to read following: %w", err)
+	}
+	var followingURIs []any
+	if followingList != nil {
+		for _, follow := range *followingList {
+			if uri := accountActorURI(follow.TargetAccountId, conf); uri != "" {
+				followingURIs = append(followingURIs, uri)
+			}
+		}
+	}
+	if err := writeArchiveCollection(archive, "following.json", getIRI(conf.Conf.SslDomain, account.Username, following), followingURIs); err != nil {
+		return err
+	}
+
+	followerList, err := database.ReadFollowersByAccountId(account.Id)
+	if err != nil {
+		return fmt.Errorf("failed to read followers: %w", err)
+	}
+	var followerURIs []any
+	if followerList != nil {
+		for _, follower := range *followerList {
+			if uri := accountActorURI(follower.AccountId, conf); uri != "" {
+				followerURIs = append(followerURIs, uri)
+			}
+		}
+	}
+	if err := writeArchiveCollection(archive, "followers.json", followersURI, followerURIs); err != nil {
+		return err
+	}
+
+	liked, err := database.ReadLikedObjectURIs(account.Id, conf.Conf.SslDomain)
+	if err != nil {
+		return fmt.Errorf("failed to read likes: %w", err)
+	}
+	likedURIs := make([]any, 0, len(liked))
+	for _, uri := range liked {
+		likedURIs = append(likedURIs, uri)
+	}
+	if err := writeArchiveCollection(archive, "likes.json", actorURI+"/liked", likedURIs); err != nil {
+		return err
+	}
+
+	return archive.Close()
+}
+
+// ExportAccountDataToFile writes an account's archive to exports/<username>_archive.zip
+// and returns the path written. A partially written archive is removed on failure.
+func ExportAccountDataToFile(accountId uuid.UUID, conf *util.AppConfig) (string, error) {
+	account, err := db.GetDB().ReadAccById(accountId)
+	if err != nil {
+		return "", fmt.Errorf("failed to get account: %w", err)
+	}
+
+	path := util.ResolveFilePathWithSubdir("exports", fmt.Sprintf("%s_archive.zip", account.Username))
+	file, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+
+	err = ExportAccountData(file, accountId, conf)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", err
+	}
+
+	log.Printf("Exported account data of %s to %s", account.Username, path)
+	return path, nil
+}
+
+// accountActorURI returns the actor URI of a remote or local account, or "" if it no longer exists
+func accountActorURI(accountId uuid.UUID, conf *util.AppConfig) string {
+	database := db.GetDB()
+	if remoteActor, err := database.ReadRemoteAccountById(accountId); err == nil && remoteActor != nil {
+		return remoteActor.ActorURI
+	}
+	if localAcc, err := database.ReadAccById(accountId); err == nil && localAcc != nil {
+		return getIRI(conf.Conf.SslDomain, localAcc.Username, id)
+	}
+	log.Printf("Account export: could not find account %s", accountId)
+	return ""
+}
+
+// archiveNoteActivity adjusts a Create activity from makeNoteActivities for the archive:
+// notes that were never public are addressed to followers only (unlisted notes keep
+// Public in cc), and replies get their inReplyTo.
+func archiveNoteActivity(activity any, note domain.Note, followersURI string) {
+	create, ok := activity.(map[string]any)
+	if !ok {
+		return
+	}
+	noteObj, _ := create["object"].(map[string]any)
+
+	if note.InReplyToURI != "" && noteObj != nil {
+		noteObj["inReplyTo"] = note.InReplyToURI
+	}
+
+	if note.Visibility == "" || note.Visibility == domain.VisibilityPublic {
+		return
+	}
+
+	// makeNoteActivities always starts cc with the followers collection
+	cc, _ := create["cc"].([]string)
+	if len(cc) > 0 && cc[0] == followersURI {
+		cc = cc[1:]
+	}
+	if note.Visibility == domain.VisibilityUnlisted {
+		cc = append([]string{"https://www.w3.org/ns/activitystreams#Public"}, cc...)
+	}
+	to := []string{followersURI}
+
+	create["to"] = to
+	create["cc"] = cc
+	if noteObj != nil {
+		noteObj["to"] = to
+		noteObj["cc"] = cc
+	}
+}
+
+// writeArchiveCollection adds a zip entry holding an OrderedCollection of the given items
+func writeArchiveCollection(archive *zip.Writer, name, id string, items []any) error {
+	entry, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	done := false
+	return writeOrderedCollection(entry, id, func() ([]any, error) {
+		if done {
+			return nil, nil
+		}
+		done = true
+		return items, nil
+	})
+}
+
+// writeOrderedCollection streams an OrderedCollection to w, calling next for each
+// batch of items until it returns none. totalItems is written after the items,
+// since the count is only known once every batch has been written.
+func writeOrderedCollection(w io.Writer, id string, next func() ([]any, error)) error {
+	header, err := json.Marshal(id)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, `{"@context":"https://www.w3.org/ns/activitystreams","id":%s,"type":"OrderedCollection","orderedItems":[`, header); err != nil {
+		return err
+	}
+
+	total := 0
+	for {
+		items, err := next()
+		if err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			break
+		}
+		for _, item := range items {
+			itemJSON, err := json.Marshal(item)
+			if err != nil {
+				return err
+			}
+			if total > 0 {
+				if _, err := io.WriteString(w, ","); err != nil {
+					return err
+				}
+			}
+			if _, err := w.Write(itemJSON); err != nil {
+				return err
+			}
+			total++
+		}
+	}
+
+	_, err = fmt.Fprintf(w, `],"totalItems":%d}`, total)
+	return err
+}
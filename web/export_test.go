@@ -0,0 +1,102 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/deemkeen/stegodon/domain"
+	"github.com/deemkeen/stegodon/util"
+	"github.com/google/uuid"
+)
+
+func TestWriteOrderedCollection(t *testing.T) {
+	batches := [][]any{{"a", "b"}, {map[string]any{"type": "Create"}}}
+
+	var buf bytes.Buffer
+	err := writeOrderedCollection(&buf, "https://example.com/users/alice/outbox", func() ([]any, error) {
+		if len(batches) == 0 {
+			return nil, nil
+		}
+		batch := batches[0]
+		batches = batches[1:]
+		return batch, nil
+	})
+	if err != nil {
+		t.Fatalf("writeOrderedCollection failed: %v", err)
+	}
+
+	var collection map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &collection); err != nil {
+		t.Fatalf("Collection is not valid JSON: %v\n%s", err, buf.String())
+	}
+	if collection["type"] != "OrderedCollection" || collection["id"] != "https://example.com/users/alice/outbox" {
+		t.Errorf("Unexpected collection header: %v", collection)
+	}
+	if collection["totalItems"] != float64(3) {
+		t.Errorf("Expected totalItems 3, got %v", collection["totalItems"])
+	}
+	if items, _ := collection["orderedItems"].([]any); len(items) != 3 {
+		t.Errorf("Expected 3 orderedItems, got %v", collection["orderedItems"])
+	}
+}
+
+func TestWriteOrderedCollection_Empty(t *testing.T) {
+	var buf bytes.Buffer
+	err := writeOrderedCollection(&buf, "https://example.com/users/alice/liked", func() ([]any, error) {
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatalf("writeOrderedCollection failed: %v", err)
+	}
+
+	var collection map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &collection); err != nil {
+		t.Fatalf("Collection is not valid JSON: %v\n%s", err, buf.String())
+	}
+	if collection["totalItems"] != float64(0) {
+		t.Errorf("Expected totalItems 0, got %v", collection["totalItems"])
+	}
+}
+
+func TestArchiveNoteActivity(t *testing.T) {
+	conf := &util.AppConfig{}
+	conf.Conf.SslDomain = "example.com"
+	followersURI := "https://example.com/users/alice/followers"
+	public := "https://www.w3.org/ns/activitystreams#Public"
+
+	tests := []struct {
+		visibility string
+		wantTo     string
+		wantCc     []string
+	}{
+		{domain.VisibilityPublic, public, []string{followersURI}},
+		{domain.VisibilityUnlisted, followersURI, []string{public}},
+		{domain.VisibilityFollowers, followersURI, []string{}},
+		{domain.VisibilityLocal, followersURI, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.visibility, func(t *testing.T) {
+			note := domain.Note{Id: uuid.New(), Message: "hello", Visibility: tt.visibility, InReplyToURI: "https://remote.example.com/notes/1"}
+			activity := makeNoteActivities([]domain.Note{note}, "alice", conf)[0]
+			archiveNoteActivity(activity, note, followersURI)
+
+			create := activity.(map[string]any)
+			noteObj := create["object"].(map[string]any)
+			for _, obj := range []map[string]any{create, noteObj} {
+				to := obj["to"].([]string)
+				cc := obj["cc"].([]string)
+				if len(to) != 1 || to[0] != tt.wantTo {
+					t.Errorf("Expected to [%s], got %v", tt.wantTo, to)
+				}
+				if len(cc) != len(tt.wantCc) || (len(cc) > 0 && cc[0] != tt.wantCc[0]) {
+					t.Errorf("Expected cc %v, got %v", tt.wantCc, cc)
+				}
+			}
+			if noteObj["inReplyTo"] != note.InReplyToURI {
+				t.Errorf("Expected inReplyTo %s, got %v", note.InReplyToURI, noteObj["inReplyTo"])
+			}
+		})
+	}
+}
@@ -0,0 +1,282 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/deemkeen/stegodon/db"
+	"github.com/deemkeen/stegodon/domain"
+	"github.com/deemkeen/stegodon/util"
+)
+
+// sourceURL is where the stegodon source code is published
+const sourceURL = "https://github.com/deemkeen/stegodon"
+
+// InstanceV1 is a Mastodon-compatible Instance entity, as returned by /api/v1/instance
+type InstanceV1 struct {
+	URI              string                `json:"uri"`
+	Title            string                `json:"title"`
+	ShortDescription string                `json:"short_description"`
+	Description      string                `json:"description"`
+	Email            string                `json:"email"`
+	Version          string                `json:"version"`
+	Stats            InstanceStats         `json:"stats"`
+	Thumbnail        string                `json:"thumbnail"`
+	Languages        []string              `json:"languages"`
+	Registrations    bool                  `json:"registrations"`
+	ApprovalRequired bool                  `json:"approval_required"`
+	InvitesEnabled   bool                  `json:"invites_enabled"`
+	Configuration    InstanceConfiguration `json:"configuration"`
+	ContactAccount   *InstanceAccount      `json:"contact_account"`
+	Rules            []InstanceRule        `json:"rules"`
+}
+
+// InstanceStats are the counters of an InstanceV1
+type InstanceStats struct {
+	UserCount   int `json:"user_count"`
+	StatusCount int `json:"status_count"`
+	DomainCount int `json:"domain_count"` // Remote servers with known accounts
+}
+
+// InstanceV2 is a Mastodon-compatible Instance entity, as returned by /api/v2/instance
+type InstanceV2 struct {
+	Domain        string                `json:"domain"`
+	Title         string                `json:"title"`
+	Version       string                `json:"version"`
+	SourceURL     string                `json:"source_url"`
+	Description   string                `json:"description"`
+	Usage         InstanceUsage         `json:"usage"`
+	Thumbnail     InstanceThumbnail     `json:"thumbnail"`
+	Languages     []string              `json:"languages"`
+	Configuration InstanceConfiguration `json:"configuration"`
+	Registrations InstanceRegistrations `json:"registrations"`
+	Contact       InstanceContact       `json:"contact"`
+	Rules         []InstanceRule        `json:"rules"`
+}
+
+// InstanceUsage holds the active users of an InstanceV2
+type InstanceUsage struct {
+	Users struct {
+		ActiveMonth int `json:"active_month"`
+	} `json:"users"`
+}
+
+// InstanceThumbnail is the image representing the instance
+type InstanceThumbnail struct {
+	URL string `json:"url"`
+}
+
+// InstanceRegistrations describes whether and how new users can sign up
+type InstanceRegistrations struct {
+	Enabled          bool    `json:"enabled"`
+	ApprovalRequired bool    `json:"approval_required"`
+	Message          *string `json:"message"`
+}
+
+// InstanceContact is who to contact about the instance
+type InstanceContact struct {
+	Email   string           `json:"email"`
+	Account *InstanceAccount `json:"account"`
+}
+
+// InstanceConfiguration holds the limits clients should respect. Stegodon has no media
+// attachments, so MaxMediaAttachments is always 0.
+type InstanceConfiguration struct {
+	Statuses struct {
+		MaxCharacters       int `json:"max_characters"`
+		MaxMediaAttachments int `json:"max_media_attachments"`
+	} `json:"statuses"`
+}
+
+// InstanceRule is a server rule; ids are the rule's position, starting at "1"
+type InstanceRule struct {
+	ID   string `json:"id"`
+	Text string `json:"text"`
+}
+
+// InstanceAccount is the subset of a Mastodon Account entity describing the contact account
+type InstanceAccount struct {
+	ID          string    `json:"id"`
+	Username    string    `json:"username"`
+	Acct        string    `json:"acct"`
+	DisplayName string    `json:"display_name"`
+	Note        string    `json:"note"`
+	URL         string    `json:"url"`
+	Avatar      string    `json:"avatar"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// instanceStats are the database counters shared by both instance entities
+type instanceStats struct {
+	users       int
+	posts       int
+	domains     int
+	activeMonth int
+	contact     *domain.Account // nil if no contact account is configured or it doesn't exist
+}
+
+// GetInstanceV1 returns the /api/v1/instance entity as JSON
+func GetInstanceV1(conf *util.AppConfig) (error, string) {
+	stats, err := readInstanceStats(conf)
+	if err != nil {
+		return err, `{"error":"Failed to read instance"}`
+	}
+	return marshalInstance(makeInstanceV1(stats, conf))
+}
+
+// GetInstanceV2 returns the /api/v2/instance entity as JSON
+func GetInstanceV2(conf *util.AppConfig) (error, string) {
+	stats, err := readInstanceStats(conf)
+	if err != nil {
+		return err, `{"error":"Failed to read instance"}`
+	}
+	return marshalInstance(makeInstanceV2(stats, conf))
+}
+
+func marshalInstance(instance any) (error, string) {
+	jsonData, err := json.Marshal(instance)
+	if err != nil {
+		log.Printf("GetInstance: Failed to marshal instance: %v", err)
+		return err, `{"error":"Failed to encode instance"}`
+	}
+	return nil, string(jsonData)
+}
+
+// readInstanceStats reads the counters and the configured contact account
+func readInstanceStats(conf *util.AppConfig) (instanceStats, error) {
+	database := db.GetDB()
+	var stats instanceStats
+
+	counters := []struct {
+		name  string
+		count func() (int, error)
+		dst   *int
+	}{
+		{"accounts", database.CountAccounts, &stats.users},
+		{"local posts", database.CountLocalPosts, &stats.posts},
+		{"remote domains", database.CountRemoteDomains, &stats.domains},
+		{"active users (month)", database.CountActiveUsersMonth, &stats.activeMonth},
+	}
+	for _, counter := range counters {
+		count, err := counter.count()
+		if err != nil {
+			log.Printf("GetInstance: Failed to count %s: %v", counter.name, err)
+			return stats, err
+		}
+		*counter.dst = count
+	}
+
+	if conf.Conf.ContactAccount != "" {
+		account, err := database.ReadAccByUsername(conf.Conf.ContactAccount)
+		if err != nil || account == nil {
+			log.Printf("GetInstance: Contact account %s not found: %v", conf.Conf.ContactAccount, err)
+		} else {
+			stats.contact = account
+		}
+	}
+	return stats, nil
+}
+
+// instanceVersion is reported as a Mastodon version first, since clients decide which API
+// features to use from it, followed by the actual stegodon version
+func instanceVersion() string {
+	return fmt.Sprintf("4.0.0 (compatible; stegodon %s)", util.GetVersion())
+}
+
+func makeInstanceV1(stats instanceStats, conf *util.AppConfig) InstanceV1 {
+	return InstanceV1{
+		URI:              conf.Conf.SslDomain,
+		Title:            conf.NodeName(),
+		ShortDescription: conf.NodeDescription(),
+		Description:      conf.NodeDescription(),
+		Email:            conf.Conf.ContactEmail,
+		Version:          instanceVersion(),
+		Stats: InstanceStats{
+			UserCount:   stats.users,
+			StatusCount: stats.posts,
+			DomainCount: stats.domains,
+		},
+		Thumbnail:        instanceThumbnailURL(conf),
+		Languages:        instanceLanguages(conf),
+		Registrations:    registrationsOpen(conf, stats.users),
+		ApprovalRequired: conf.RegistrationPolicy() == util.RegistrationPolicyApproval,
+		Configuration:    makeInstanceConfiguration(conf),
+		ContactAccount:   makeInstanceAccount(stats.contact, conf),
+		Rules:            makeInstanceRules(conf),
+	}
+}
+
+func makeInstanceV2(stats instanceStats, conf *util.AppConfig) InstanceV2 {
+	instance := InstanceV2{
+		Domain:        conf.Conf.SslDomain,
+		Title:         conf.NodeName(),
+		Version:       instanceVersion(),
+		SourceURL:     sourceURL,
+		Description:   conf.NodeDescription(),
+		Thumbnail:     InstanceThumbnail{URL: instanceThumbnailURL(conf)},
+		Languages:     instanceLanguages(conf),
+		Configuration: makeInstanceConfiguration(conf),
+		Registrations: InstanceRegistrations{
+			Enabled:          registrationsOpen(conf, stats.users),
+			ApprovalRequired: conf.RegistrationPolicy() == util.RegistrationPolicyApproval,
+		},
+		Contact: InstanceContact{
+			Email:   conf.Conf.ContactEmail,
+			Account: makeInstanceAccount(stats.contact, conf),
+		},
+		Rules: makeInstanceRules(conf),
+	}
+	instance.Usage.Users.ActiveMonth = stats.activeMonth
+	return instance
+}
+
+// instanceThumbnailURL is the stegodon logo served by the web UI
+func instanceThumbnailURL(conf *util.AppConfig) string {
+	return fmt.Sprintf("https://%s/static/stegologo.png", conf.Conf.SslDomain)
+}
+
+// instanceLanguages returns the configured languages, as an empty list rather than null
+func instanceLanguages(conf *util.AppConfig) []string {
+	if len(conf.Conf.Languages) == 0 {
+		return []string{}
+	}
+	return conf.Conf.Languages
+}
+
+func makeInstanceConfiguration(conf *util.AppConfig) InstanceConfiguration {
+	var configuration InstanceConfiguration
+	configuration.Statuses.MaxCharacters = conf.NoteCharLimit()
+	return configuration
+}
+
+func makeInstanceRules(conf *util.AppConfig) []InstanceRule {
+	rules := make([]InstanceRule, 0, len(conf.Conf.Rules))
+	for i, text := range conf.Conf.Rules {
+		rules = append(rules, InstanceRule{ID: strconv.Itoa(i + 1), Text: text})
+	}
+	return rules
+}
+
+// makeInstanceAccount describes a local account, or returns nil for none
+func makeInstanceAccount(account *domain.Account, conf *util.AppConfig) *InstanceAccount {
+	if account == nil {
+		return nil
+	}
+	displayName := account.DisplayName
+	if displayName == "" {
+		displayName = account.Username
+	}
+	return &InstanceAccount{
+		ID:          account.Id.String(),
+		Username:    account.Username,
+		Acct:        account.Username,
+		DisplayName: displayName,
+		Note:        account.Summary,
+		URL:         fmt.Sprintf("https://%s/u/%s", conf.Conf.SslDomain, account.Username),
+		Avatar:      account.AvatarURL,
+		CreatedAt:   account.CreatedAt,
+	}
+}
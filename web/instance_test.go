@@ -0,0 +1,102 @@
+package web
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deemkeen/stegodon/domain"
+	"github.com/deemkeen/stegodon/util"
+	"github.com/google/uuid"
+)
+
+func testInstanceConfig() *util.AppConfig {
+	conf := &util.AppConfig{}
+	conf.Conf.SslDomain = "example.com"
+	conf.Conf.NodeName = "Example"
+	conf.Conf.NodeDescription = "A test instance"
+	conf.Conf.ContactEmail = "admin@example.com"
+	conf.Conf.MaxNoteChars = 500
+	conf.Conf.Languages = []string{"en", "de"}
+	conf.Conf.Rules = []string{"Be nice", "No spam"}
+	return conf
+}
+
+func TestMakeInstanceV1(t *testing.T) {
+	conf := testInstanceConfig()
+	contact := &domain.Account{Id: uuid.New(), Username: "admin", CreatedAt: time.Now()}
+	stats := instanceStats{users: 3, posts: 42, domains: 7, activeMonth: 2, contact: contact}
+
+	instance := makeInstanceV1(stats, conf)
+
+	if instance.URI != "example.com" || instance.Title != "Example" || instance.Description != "A test instance" {
+		t.Errorf("Unexpected instance identity: %+v", instance)
+	}
+	if !strings.Contains(instance.Version, util.GetVersion()) {
+		t.Errorf("Expected version to contain %s, got %s", util.GetVersion(), instance.Version)
+	}
+	if instance.Stats != (InstanceStats{UserCount: 3, StatusCount: 42, DomainCount: 7}) {
+		t.Errorf("Unexpected stats: %+v", instance.Stats)
+	}
+	if instance.Configuration.Statuses.MaxCharacters != 500 {
+		t.Errorf("Expected max_characters 500, got %d", instance.Configuration.Statuses.MaxCharacters)
+	}
+	if !instance.Registrations {
+		t.Error("Expected open registrations")
+	}
+	if instance.ContactAccount == nil || instance.ContactAccount.Username != "admin" ||
+		instance.ContactAccount.URL != "https://example.com/u/admin" || instance.ContactAccount.DisplayName != "admin" {
+		t.Errorf("Unexpected contact account: %+v", instance.ContactAccount)
+	}
+	if len(instance.Rules) != 2 || instance.Rules[1] != (InstanceRule{ID: "2", Text: "No spam"}) {
+		t.Errorf("Unexpected rules: %+v", instance.Rules)
+	}
+	if instance.Thumbnail != "https://example.com/static/stegologo.png" {
+		t.Errorf("Unexpected thumbnail: %s", instance.Thumbnail)
+	}
+}
+
+func TestMakeInstanceV2(t *testing.T) {
+	conf := testInstanceConfig()
+	conf.Conf.Single = true
+	stats := instanceStats{users: 1, activeMonth: 1}
+
+	instance := makeInstanceV2(stats, conf)
+
+	if instance.Domain != "example.com" || instance.SourceURL != sourceURL || instance.Usage.Users.ActiveMonth != 1 {
+		t.Errorf("Unexpected instance: %+v", instance)
+	}
+	if instance.Registrations.Enabled {
+		t.Error("Expected registrations closed for a single-user instance with a user")
+	}
+	if instance.Contact.Email != "admin@example.com" || instance.Contact.Account != nil {
+		t.Errorf("Unexpected contact: %+v", instance.Contact)
+	}
+	if instance.Thumbnail.URL != "https://example.com/static/stegologo.png" {
+		t.Errorf("Unexpected thumbnail: %s", instance.Thumbnail.URL)
+	}
+}
+
+func TestMakeInstance_Defaults(t *testing.T) {
+	conf := &util.AppConfig{}
+	conf.Conf.SslDomain = "example.com"
+
+	jsonData, err := json.Marshal(makeInstanceV1(instanceStats{}, conf))
+	if err != nil {
+		t.Fatalf("Failed to marshal instance: %v", err)
+	}
+
+	// Unset lists encode as empty arrays and a missing contact account as null
+	for _, want := range []string{
+		`"title":"Stegodon"`,
+		`"languages":[]`,
+		`"rules":[]`,
+		`"contact_account":null`,
+		`"max_characters":300`,
+	} {
+		if !strings.Contains(string(jsonData), want) {
+			t.Errorf("Expected %s in %s", want, jsonData)
+		}
+	}
+}
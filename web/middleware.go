@@ -1,10 +1,13 @@
 package web
 
 import (
+	"log"
 	"net/http"
 	"sync"
 	"time"
 
+	"github.com/deemkeen/stegodon/activitypub"
+	"github.com/deemkeen/stegodon/util"
 	"github.com/gin-gonic/gin"
 	"golang.org/x/time/rate"
 )
@@ -93,3 +96,44 @@ func MaxBytesMiddleware(maxBytes int64) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// SignedFetchMiddleware requires a valid HTTP signature on ActivityPub GETs when secure mode
+// (authorized fetch) is on, and forbids signers that are blocked. Browsers asking for HTML
+// pass through to be redirected to the web pages. If unsigned is set, it answers requests
+// without a signature instead of rejecting them.
+func SignedFetchMiddleware(conf *util.AppConfig, unsigned gin.HandlerFunc) gin.HandlerFunc {
+	return signedFetchMiddleware(conf, unsigned, activitypub.VerifyFetchSignature)
+}
+
+// signedFetchMiddleware is SignedFetchMiddleware with the signature verification injected for testing
+func signedFetchMiddleware(conf *util.AppConfig, unsigned gin.HandlerFunc, verify func(*http.Request) (string, error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !conf.Conf.SecureMode || wantsHTML(c.GetHeader("Accept")) {
+			c.Next()
+			return
+		}
+
+		if unsigned != nil && !activitypub.HasSignature(c.Request) {
+			unsigned(c)
+			c.Abort()
+			return
+		}
+
+		signer, err := verify(c.Request)
+		if err != nil {
+			log.Printf("Secure mode: Rejected unsigned or invalid fetch of %s: %v", c.Request.URL.Path, err)
+			c.Header("WWW-Authenticate", `Signature headers="(request-target) host date"`)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Valid HTTP signature required"})
+			c.Abort()
+			return
+		}
+		if conf.IsBlockedActor(signer) {
+			log.Printf("Secure mode: Blocked %s from fetching %s", signer, c.Request.URL.Path)
+			c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
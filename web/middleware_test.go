@@ -1,12 +1,14 @@
 package web
 
 import (
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/deemkeen/stegodon/util"
 	"github.com/gin-gonic/gin"
 	"golang.org/x/time/rate"
 )
@@ -361,3 +363,71 @@ func TestRateLimitMiddlewareRecovery(t *testing.T) {
 		t.Errorf("Request after waiting should succeed, got status %d", w3.Code)
 	}
 }
+
+func TestSignedFetchMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	verify := func(r *http.Request) (string, error) {
+		switch r.Header.Get("Signature") {
+		case "":
+			return "", errors.New("missing signature")
+		case "valid":
+			return "https://remote.example/actor", nil
+		case "blocked":
+			return "https://blocked.example/actor", nil
+		}
+		return "", errors.New("signature verification failed")
+	}
+
+	tests := []struct {
+		name           string
+		secureMode     bool
+		withUnsigned   bool
+		accept         string
+		signature      string
+		expectedStatus int
+	}{
+		{"secure mode off", false, false, "application/activity+json", "", http.StatusOK},
+		{"unsigned", true, false, "application/activity+json", "", http.StatusUnauthorized},
+		{"invalid signature", true, false, "application/activity+json", "forged", http.StatusUnauthorized},
+		{"valid signature", true, false, "application/activity+json", "valid", http.StatusOK},
+		{"blocked signer", true, false, "application/activity+json", "blocked", http.StatusForbidden},
+		{"browser", true, false, "text/html", "", http.StatusOK},
+		{"unsigned with fallback", true, true, "application/activity+json", "", http.StatusNonAuthoritativeInfo},
+		{"signed with fallback", true, true, "application/activity+json", "valid", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := &util.AppConfig{}
+			conf.Conf.SecureMode = tt.secureMode
+			conf.Conf.BlockedDomains = []string{"blocked.example"}
+
+			var unsigned gin.HandlerFunc
+			if tt.withUnsigned {
+				unsigned = func(c *gin.Context) {
+					c.Status(http.StatusNonAuthoritativeInfo)
+				}
+			}
+			router := gin.New()
+			router.GET("/users/alice/outbox", signedFetchMiddleware(conf, unsigned, verify), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/users/alice/outbox", nil)
+			req.Header.Set("Accept", tt.accept)
+			if tt.signature != "" {
+				req.Header.Set("Signature", tt.signature)
+			}
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("Expected a WWW-Authenticate header on 401")
+			}
+		})
+	}
+}
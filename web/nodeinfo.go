@@ -43,8 +43,11 @@ type NodeInfoUsers struct {
 }
 
 type NodeInfoMetadata struct {
-	NodeName        string `json:"nodeName"`
-	NodeDescription string `json:"nodeDescription"`
+	NodeName          string `json:"nodeName"`
+	NodeDescription   string `json:"nodeDescription"`
+	MaxNoteTextLength int    `json:"maxNoteTextLength"` // Visible characters per post, under the name Misskey uses
+
+	MaxInboundContentBytes int `json:"maxInboundContentBytes"` // Bytes of HTML content kept from remote posts
 }
 
 // WellKnownNodeInfo represents the /.well-known/nodeinfo response
@@ -86,18 +89,7 @@ func GetNodeInfo20(conf *util.AppConfig) string {
 		activeHalfyear = 0
 	}
 
-	// Determine if registrations are open
-	// Closed if: STEGODON_CLOSED=true OR (STEGODON_SINGLE=true AND user exists)
-	openRegistrations := !conf.Conf.Closed
-	if conf.Conf.Single && totalUsers >= 1 {
-		openRegistrations = false
-	}
-
-	// Get node description (use custom if set, otherwise default)
-	nodeDescription := conf.Conf.NodeDescription
-	if nodeDescription == "" {
-		nodeDescription = "A SSH-first federated microblog"
-	}
+	openRegistrations := registrationsOpen(conf, totalUsers)
 
 	// Build NodeInfo response using json.RawMessage to preserve field order
 	// We use a slice of key-value pairs to maintain exact order
@@ -122,8 +114,10 @@ func GetNodeInfo20(conf *util.AppConfig) string {
   },
   "openRegistrations": %t,
   "metadata": {
-    "nodeName": "Stegodon",
-    "nodeDescription": "%s"
+    "nodeName": %s,
+    "nodeDescription": %s,
+    "maxNoteTextLength": %d,
+    "maxInboundContentBytes": %d
   }
 }`,
 		util.GetVersion(),
@@ -132,12 +126,30 @@ func GetNodeInfo20(conf *util.AppConfig) string {
 		activeHalfyear,
 		localPosts,
 		openRegistrations,
-		nodeDescription,
+		jsonString(conf.NodeName()),
+		jsonString(conf.NodeDescription()),
+		conf.NoteCharLimit(),
+		conf.InboundContentByteLimit(),
 	)
 
 	return nodeInfoJSON
 }
 
+// registrationsOpen reports whether new users can sign up, possibly subject to approval.
+// Closed if: the registration policy is closed OR (STEGODON_SINGLE=true AND user exists)
+func registrationsOpen(conf *util.AppConfig, totalUsers int) bool {
+	return conf.RegistrationPolicy() != util.RegistrationPolicyClosed && !(conf.Conf.Single && totalUsers >= 1)
+}
+
+// jsonString encodes s as a quoted JSON string for the hand-built NodeInfo document
+func jsonString(s string) string {
+	encoded, err := json.Marshal(s)
+	if err != nil {
+		return `""`
+	}
+	return string(encoded)
+}
+
 // GetWellKnownNodeInfo returns the /.well-known/nodeinfo discovery document
 func GetWellKnownNodeInfo(conf *util.AppConfig) string {
 	wellKnown := WellKnownNodeInfo{
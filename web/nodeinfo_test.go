@@ -319,6 +319,38 @@ func TestNodeInfo20_Metadata(t *testing.T) {
 	if len(nodeInfo.Metadata.NodeDescription) > 500 {
 		t.Error("NodeDescription seems unreasonably long")
 	}
+
+	if nodeInfo.Metadata.MaxNoteTextLength != util.DefaultMaxNoteChars {
+		t.Errorf("Expected the default maxNoteTextLength %d, got %d", util.DefaultMaxNoteChars, nodeInfo.Metadata.MaxNoteTextLength)
+	}
+}
+
+func TestNodeInfo20_MaxNoteTextLength(t *testing.T) {
+	conf := &util.AppConfig{}
+	conf.Conf.SslDomain = "stegodon.example"
+	conf.Conf.MaxNoteChars = 500
+
+	var nodeInfo NodeInfo20
+	if err := json.Unmarshal([]byte(GetNodeInfo20(conf)), &nodeInfo); err != nil {
+		t.Fatalf("Failed to parse NodeInfo JSON: %v", err)
+	}
+	if nodeInfo.Metadata.MaxNoteTextLength != 500 {
+		t.Errorf("Expected maxNoteTextLength 500, got %d", nodeInfo.Metadata.MaxNoteTextLength)
+	}
+}
+
+func TestNodeInfo20_MaxInboundContentBytes(t *testing.T) {
+	conf := &util.AppConfig{}
+	conf.Conf.SslDomain = "stegodon.example"
+	conf.Conf.MaxInboundContentBytes = 10000
+
+	var nodeInfo NodeInfo20
+	if err := json.Unmarshal([]byte(GetNodeInfo20(conf)), &nodeInfo); err != nil {
+		t.Fatalf("Failed to parse NodeInfo JSON: %v", err)
+	}
+	if nodeInfo.Metadata.MaxInboundContentBytes != 10000 {
+		t.Errorf("Expected maxInboundContentBytes 10000, got %d", nodeInfo.Metadata.MaxInboundContentBytes)
+	}
 }
 
 func TestWellKnownNodeInfo_RelationFormat(t *testing.T) {
@@ -436,3 +468,23 @@ func TestNodeInfo20_OpenRegistrations_ClosedOverridesSingle(t *testing.T) {
 		t.Error("openRegistrations should be false when STEGODON_CLOSED=true regardless of other settings")
 	}
 }
+
+func TestRegistrationsOpen_Policy(t *testing.T) {
+	tests := []struct {
+		policy   string
+		expected bool
+	}{
+		{"", true},
+		{util.RegistrationPolicyOpen, true},
+		{util.RegistrationPolicyApproval, true}, // Sign-ups are accepted, just not usable right away
+		{util.RegistrationPolicyClosed, false},
+	}
+
+	for _, tt := range tests {
+		conf := &util.AppConfig{}
+		conf.Conf.RegistrationPolicy = tt.policy
+		if result := registrationsOpen(conf, 1); result != tt.expected {
+			t.Errorf("registrationsOpen with policy %q = %v, want %v", tt.policy, result, tt.expected)
+		}
+	}
+}
@@ -16,11 +16,14 @@ import (
 // This allows remote servers to discover posts without following the user
 func GetOutbox(actor string, page int, conf *util.AppConfig) (error, string) {
 	// Verify the account exists
-	err, _ := db.GetDB().ReadAccByUsername(actor)
+	acc, err := db.GetDB().ReadAccByUsername(actor)
 	if err != nil {
 		log.Printf("GetOutbox: User %s not found: %v", actor, err)
 		return err, "{}"
 	}
+	if acc.Disabled {
+		return ErrAccountDisabled, `{"error":"Account disabled"}`
+	}
 
 	baseURL := fmt.Sprintf("https://%s", conf.Conf.SslDomain)
 	outboxURL := fmt.Sprintf("%s/users/%s/outbox", baseURL, actor)
@@ -28,7 +31,7 @@ func GetOutbox(actor string, page int, conf *util.AppConfig) (error, string) {
 	// If no page parameter, return the collection metadata
 	if page == 0 {
 		// Count total public posts
-		err, notes := db.GetDB().ReadPublicNotesByUsername(actor, 999999, 0)
+		notes, err := db.GetDB().ReadPublicNotesByUsername(actor, 999999, 0)
 		if err != nil {
 			log.Printf("GetOutbox: Failed to count notes for %s: %v", actor, err)
 			return err, "{}"
@@ -63,7 +66,7 @@ func getOutboxPage(actor string, page int, conf *util.AppConfig) (error, string)
 	offset := (page - 1) * itemsPerPage
 
 	// Fetch notes for this page
-	err, notes := db.GetDB().ReadPublicNotesByUsername(actor, itemsPerPage+1, offset)
+	notes, err := db.GetDB().ReadPublicNotesByUsername(actor, itemsPerPage+1, offset)
 	if err != nil {
 		log.Printf("GetOutbox: Failed to fetch notes page %d for %s: %v", page, actor, err)
 		return err, "{}"
@@ -149,11 +152,6 @@ func makeNoteActivities(notes []domain.Note, actor string, conf *util.AppConfig)
 			objectURI = fmt.Sprintf("%s/notes/%s", baseURL, note.Id.String())
 		}
 
-		// Convert Markdown links to HTML for ActivityPub content
-		contentHTML := util.MarkdownLinksToHTML(note.Message)
-		// Convert hashtags to ActivityPub-compliant HTML links
-		contentHTML = util.HashtagsToActivityPubHTML(contentHTML, baseURL)
-
 		// Build cc list - start with followers
 		ccList := []string{
 			fmt.Sprintf("%s/users/%s/followers", baseURL, actor),
@@ -174,7 +172,7 @@ func makeNoteActivities(notes []domain.Note, actor string, conf *util.AppConfig)
 
 		// Extract mentions and try to resolve from stored data
 		mentionURIs := make(map[string]string)
-		err, storedMentions := database.ReadMentionsByNoteId(note.Id)
+		storedMentions, err := database.ReadMentionsByNoteId(note.Id)
 		if err == nil && len(storedMentions) > 0 {
 			for _, stored := range storedMentions {
 				mentionKey := fmt.Sprintf("@%s@%s", stored.MentionedUsername, stored.MentionedDomain)
@@ -212,10 +210,8 @@ func makeNoteActivities(notes []domain.Note, actor string, conf *util.AppConfig)
 			}
 		}
 
-		// Convert mentions to ActivityPub HTML
-		if len(mentionURIs) > 0 {
-			contentHTML = util.MentionsToActivityPubHTML(contentHTML, mentionURIs)
-		}
+		// Render the HTML content once mention URIs are resolved
+		contentHTML := util.MessageToActivityPubHTML(note.Message, baseURL, mentionURIs)
 
 		// Build the Note object
 		noteObj := map[string]any{
@@ -232,6 +228,10 @@ func makeNoteActivities(notes []domain.Note, actor string, conf *util.AppConfig)
 			"cc": ccList,
 		}
 
+		if note.Language != "" {
+			noteObj["contentMap"] = map[string]string{note.Language: contentHTML}
+		}
+
 		// Add updated field if note was edited
 		if note.EditedAt != nil {
 			noteObj["updated"] = note.EditedAt.Format("2006-01-02T15:04:05Z")
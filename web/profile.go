@@ -0,0 +1,101 @@
+package web
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/deemkeen/stegodon/activitypub"
+	"github.com/deemkeen/stegodon/db"
+	"github.com/deemkeen/stegodon/domain"
+	"github.com/deemkeen/stegodon/util"
+	"github.com/google/uuid"
+)
+
+// profileUpdateInterval is the least time between two profile Updates of an account. Edits
+// made in between are sent together in one Update at the end of the interval.
+const profileUpdateInterval = time.Minute
+
+// profileUpdateLimiter spaces out the profile Updates of each account
+type profileUpdateLimiter struct {
+	mu      sync.Mutex
+	last    map[uuid.UUID]time.Time // when the last Update was sent or is scheduled
+	pending map[uuid.UUID]bool      // true while a delayed Update is scheduled
+}
+
+var profileUpdates = &profileUpdateLimiter{
+	last:    make(map[uuid.UUID]time.Time),
+	pending: make(map[uuid.UUID]bool),
+}
+
+// schedule returns how long to wait before sending an Update for an account, and false if
+// a delayed Update is already scheduled, which will carry this edit as well
+func (l *profileUpdateLimiter) schedule(accountId uuid.UUID, now time.Time) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.pending[accountId] {
+		return 0, false
+	}
+	last, ok := l.last[accountId]
+	if !ok || now.Sub(last) >= profileUpdateInterval {
+		l.last[accountId] = now
+		return 0, true
+	}
+	next := last.Add(profileUpdateInterval)
+	l.last[accountId] = next
+	l.pending[accountId] = true
+	return next.Sub(now), true
+}
+
+// done marks the delayed Update of an account as sent
+func (l *profileUpdateLimiter) done(accountId uuid.UUID) {
+	l.mu.Lock()
+	delete(l.pending, accountId)
+	l.mu.Unlock()
+}
+
+// FederateProfile sends an Update of an account's actor to its followers after its profile
+// (name, bio, avatar, follow approval or discoverability) changed. Updates are rate-limited
+// per account; the actor is read when the Update is sent, so the latest profile goes out.
+func FederateProfile(accountId uuid.UUID, conf *util.AppConfig) {
+	if !conf.Conf.WithAp {
+		return
+	}
+	delay, ok := profileUpdates.schedule(accountId, time.Now())
+	if !ok {
+		return
+	}
+	if delay == 0 {
+		go sendProfileUpdate(accountId, conf)
+		return
+	}
+	log.Printf("FederateProfile: Delaying the profile Update of %s by %v", accountId, delay.Round(time.Second))
+	time.AfterFunc(delay, func() {
+		profileUpdates.done(accountId)
+		sendProfileUpdate(accountId, conf)
+	})
+}
+
+// sendProfileUpdate renders an account's actor as it is served and sends it in an Update
+func sendProfileUpdate(accountId uuid.UUID, conf *util.AppConfig) {
+	acc, err := db.GetDB().ReadAccById(accountId)
+	if err != nil {
+		log.Printf("FederateProfile: Failed to read account %s: %v", accountId, err)
+		return
+	}
+	// Accounts that can't federate have no actor to update
+	if acc.FirstTimeLogin == domain.TRUE || acc.Pending || acc.Disabled {
+		return
+	}
+
+	var actor map[string]any
+	if err := json.Unmarshal([]byte(actorJSON(acc, conf)), &actor); err != nil {
+		log.Printf("FederateProfile: Failed to render actor of %s: %v", acc.Username, err)
+		return
+	}
+	if err := activitypub.SendActorUpdate(acc, actor, conf); err != nil {
+		log.Printf("FederateProfile: Failed to send the profile Update of %s: %v", acc.Username, err)
+	}
+}
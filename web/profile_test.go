@@ -0,0 +1,45 @@
+package web
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestProfileUpdateLimiter(t *testing.T) {
+	limiter := &profileUpdateLimiter{last: make(map[uuid.UUID]time.Time), pending: make(map[uuid.UUID]bool)}
+	alice, bob := uuid.New(), uuid.New()
+	now := time.Now()
+
+	// The first edit is sent right away
+	if delay, ok := limiter.schedule(alice, now); !ok || delay != 0 {
+		t.Fatalf("Expected the first Update to be sent right away, got %v %v", delay, ok)
+	}
+
+	// A quick second edit waits for the end of the interval
+	delay, ok := limiter.schedule(alice, now.Add(10*time.Second))
+	if !ok || delay != profileUpdateInterval-10*time.Second {
+		t.Fatalf("Expected the Update to be delayed by %v, got %v %v", profileUpdateInterval-10*time.Second, delay, ok)
+	}
+
+	// Further edits are carried by the scheduled Update
+	if _, ok := limiter.schedule(alice, now.Add(20*time.Second)); ok {
+		t.Error("Expected no further Update while one is scheduled")
+	}
+
+	// Other accounts aren't held back
+	if delay, ok := limiter.schedule(bob, now.Add(20*time.Second)); !ok || delay != 0 {
+		t.Errorf("Expected bob's Update to be sent right away, got %v %v", delay, ok)
+	}
+
+	// After the scheduled Update went out, the next edit waits a full interval again
+	limiter.done(alice)
+	if delay, ok := limiter.schedule(alice, now.Add(profileUpdateInterval+time.Second)); !ok || delay != profileUpdateInterval-time.Second {
+		t.Errorf("Expected the Update to be delayed by %v, got %v %v", profileUpdateInterval-time.Second, delay, ok)
+	}
+	limiter.done(alice)
+	if delay, ok := limiter.schedule(alice, now.Add(3*profileUpdateInterval)); !ok || delay != 0 {
+		t.Errorf("Expected an Update after a quiet interval to be sent right away, got %v %v", delay, ok)
+	}
+}
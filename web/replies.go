@@ -0,0 +1,154 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+
+	"github.com/deemkeen/stegodon/db"
+	"github.com/deemkeen/stegodon/domain"
+	"github.com/deemkeen/stegodon/util"
+	"github.com/google/uuid"
+)
+
+// repliesPerPage is the number of reply URIs in a page of a note's replies collection
+const repliesPerPage = 20
+
+// publicAudience lists the ways the public collection is addressed
+var publicAudience = []string{"https://www.w3.org/ns/activitystreams#Public", "as:Public", "Public"}
+
+// GetNoteReplies returns the replies collection of a local note, which lets remote servers
+// discover the replies they haven't seen. Page 0 is the collection with the total number of
+// replies in the thread; its pages list the URIs of the public direct replies, local and remote.
+func GetNoteReplies(noteId uuid.UUID, page int, conf *util.AppConfig) (error, string) {
+	database := db.GetDB()
+	note, err := database.ReadNoteId(noteId)
+	if err != nil {
+		return err, "{}"
+	}
+	// Local-only notes are never exposed to other servers
+	if note.IsLocalOnly() {
+		return fmt.Errorf("note %s is local-only", noteId), "{}"
+	}
+
+	repliesURI := util.BuildNoteObjectURI(conf, noteId) + "/replies"
+
+	if page == 0 {
+		totalItems, err := database.CountTotalRepliesByNoteId(noteId)
+		if err != nil {
+			log.Printf("GetNoteReplies: Failed to count replies to %s: %v", noteId, err)
+		}
+		collection := map[string]any{
+			"@context":   "https://www.w3.org/ns/activitystreams",
+			"id":         repliesURI,
+			"type":       "Collection",
+			"totalItems": totalItems,
+			"first":      fmt.Sprintf("%s?page=1", repliesURI),
+		}
+		jsonData, err := json.Marshal(collection)
+		if err != nil {
+			return err, "{}"
+		}
+		return nil, string(jsonData)
+	}
+
+	objectURI := note.ObjectURI
+	if objectURI == "" {
+		objectURI = util.BuildNoteObjectURI(conf, noteId)
+	}
+	return nil, getRepliesPage(repliesURI, publicReplyURIs(objectURI, conf), page)
+}
+
+// publicReplyURIs returns the object URIs of the public direct replies to objectURI, local
+// replies first, each in the order they were posted
+func publicReplyURIs(objectURI string, conf *util.AppConfig) []string {
+	database := db.GetDB()
+	uris := []string{}
+	seen := make(map[string]bool)
+
+	if notes, err := database.ReadRepliesByURI(objectURI); err == nil {
+		for _, reply := range *notes {
+			if reply.IsDeleted() || reply.Visibility != domain.VisibilityPublic {
+				continue
+			}
+			uri := reply.ObjectURI
+			if uri == "" {
+				uri = util.BuildNoteObjectURI(conf, reply.Id)
+			}
+			if !seen[uri] {
+				seen[uri] = true
+				uris = append(uris, uri)
+			}
+		}
+	}
+
+	if activities, err := database.ReadActivitiesByInReplyTo(objectURI); err == nil {
+		for _, activity := range *activities {
+			if activity.ObjectURI == "" || seen[activity.ObjectURI] || !isPublicReply(activity.RawJSON) {
+				continue
+			}
+			seen[activity.ObjectURI] = true
+			uris = append(uris, activity.ObjectURI)
+		}
+	}
+	return uris
+}
+
+// isPublicReply reports whether a stored Create addresses its object to the public
+func isPublicReply(rawJSON string) bool {
+	var create struct {
+		Object struct {
+			To any `json:"to"`
+			Cc any `json:"cc"`
+		} `json:"object"`
+	}
+	if err := json.Unmarshal([]byte(rawJSON), &create); err != nil {
+		return false
+	}
+	for _, audience := range []any{create.Object.To, create.Object.Cc} {
+		var recipients []any
+		switch a := audience.(type) {
+		case string:
+			recipients = []any{a}
+		case []any:
+			recipients = a
+		}
+		for _, r := range recipients {
+			if recipient, ok := r.(string); ok && slices.Contains(publicAudience, recipient) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// getRepliesPage returns a page of a replies collection with the given reply URIs
+func getRepliesPage(repliesURI string, replyURIs []string, page int) string {
+	start := min((page-1)*repliesPerPage, len(replyURIs))
+	end := min(start+repliesPerPage, len(replyURIs))
+
+	collectionPage := map[string]any{
+		"@context": "https://www.w3.org/ns/activitystreams",
+		"id":       fmt.Sprintf("%s?page=%d", repliesURI, page),
+		"type":     "CollectionPage",
+		"partOf":   repliesURI,
+		"items":    replyURIs[start:end],
+	}
+
+	// Add next link if there are more pages
+	if end < len(replyURIs) {
+		collectionPage["next"] = fmt.Sprintf("%s?page=%d", repliesURI, page+1)
+	}
+
+	// Add prev link if not first page
+	if page > 1 {
+		collectionPage["prev"] = fmt.Sprintf("%s?page=%d", repliesURI, page-1)
+	}
+
+	jsonData, err := json.Marshal(collectionPage)
+	if err != nil {
+		return "{}"
+	}
+	return string(jsonData)
+}
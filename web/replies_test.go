@@ -0,0 +1,68 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestGetRepliesPage(t *testing.T) {
+	repliesURI := "https://example.com/notes/1/replies"
+	replyURIs := make([]string, repliesPerPage+5)
+	for i := range replyURIs {
+		replyURIs[i] = fmt.Sprintf("https://remote.example.com/notes/%d", i)
+	}
+
+	var first map[string]any
+	if err := json.Unmarshal([]byte(getRepliesPage(repliesURI, replyURIs, 1)), &first); err != nil {
+		t.Fatalf("getRepliesPage should return valid JSON: %v", err)
+	}
+	if first["type"] != "CollectionPage" || first["partOf"] != repliesURI {
+		t.Errorf("Unexpected page: %v", first)
+	}
+	if items := first["items"].([]any); len(items) != repliesPerPage || items[0] != replyURIs[0] {
+		t.Errorf("Expected the first %d replies, got %v", repliesPerPage, items)
+	}
+	if first["next"] != repliesURI+"?page=2" || first["prev"] != nil {
+		t.Errorf("Expected only a next link on the first page, got next=%v prev=%v", first["next"], first["prev"])
+	}
+
+	var last map[string]any
+	if err := json.Unmarshal([]byte(getRepliesPage(repliesURI, replyURIs, 2)), &last); err != nil {
+		t.Fatalf("getRepliesPage should return valid JSON: %v", err)
+	}
+	if items := last["items"].([]any); len(items) != 5 {
+		t.Errorf("Expected the remaining 5 replies, got %d", len(items))
+	}
+	if last["next"] != nil || last["prev"] != repliesURI+"?page=1" {
+		t.Errorf("Expected only a prev link on the last page, got next=%v prev=%v", last["next"], last["prev"])
+	}
+
+	// Pages past the end are empty
+	var beyond map[string]any
+	json.Unmarshal([]byte(getRepliesPage(repliesURI, replyURIs, 5)), &beyond)
+	if items := beyond["items"].([]any); len(items) != 0 {
+		t.Errorf("Expected no replies past the last page, got %d", len(items))
+	}
+}
+
+func TestIsPublicReply(t *testing.T) {
+	tests := []struct {
+		name    string
+		rawJSON string
+		want    bool
+	}{
+		{"public in to", `{"object":{"to":["https://www.w3.org/ns/activitystreams#Public"]}}`, true},
+		{"unlisted in cc", `{"object":{"to":"https://remote.example.com/users/bob/followers","cc":"as:Public"}}`, true},
+		{"followers only", `{"object":{"to":["https://remote.example.com/users/bob/followers"]}}`, false},
+		{"direct", `{"object":{"to":["https://example.com/users/alice"]}}`, false},
+		{"invalid", `not json`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isPublicReply(tt.rawJSON); got != tt.want {
+				t.Errorf("isPublicReply() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/deemkeen/stegodon/activitypub"
+	"github.com/deemkeen/stegodon/db"
+	"github.com/deemkeen/stegodon/domain"
+)
+
+const (
+	// failedLookupTTL is how long a handle that couldn't be resolved is answered from the
+	// cache, so typos in the search box don't hit the remote server's WebFinger on every key
+	failedLookupTTL = 2 * time.Minute
+	// cachedActorTTL matches the remote actor cache: fresher accounts skip WebFinger entirely
+	cachedActorTTL = 24 * time.Hour
+)
+
+var (
+	// ErrInvalidHandle is returned for handles that aren't of the form user@domain
+	ErrInvalidHandle = errors.New("invalid handle, use user@domain or @user@domain")
+	// ErrAccountNotFound is returned for handles that recently failed to resolve
+	ErrAccountNotFound = errors.New("account not found")
+)
+
+// accountResolver resolves user@domain handles to remote accounts. Its lookups are fields
+// so tests can replace the network and database.
+type accountResolver struct {
+	mu     sync.Mutex
+	failed map[string]time.Time // handle -> when the failed lookup may be retried
+	now    func() time.Time
+
+	readCached func(username, domain string) (*domain.RemoteAccount, error)
+	webfinger  func(username, domain string) (string, error)
+	fetchActor func(actorURI string) (*domain.RemoteAccount, error)
+}
+
+var defaultAccountResolver = &accountResolver{
+	failed: make(map[string]time.Time),
+	now:    time.Now,
+	readCached: func(username, domain string) (*domain.RemoteAccount, error) {
+		return db.GetDB().ReadRemoteAccountByUsernameAndDomain(username, domain)
+	},
+	webfinger:  ResolveWebFinger,
+	fetchActor: activitypub.GetOrFetchActor,
+}
+
+// ResolveAccount resolves a handle such as @user@domain to a remote account: it is
+// WebFinger-resolved and its actor fetched and cached, unless a fresh copy is cached already.
+// Handles that fail to resolve return ErrAccountNotFound for a while without another lookup.
+func ResolveAccount(acct string) (*domain.RemoteAccount, error) {
+	return defaultAccountResolver.resolve(acct)
+}
+
+// ParseHandle splits a handle of the form user@domain or @user@domain
+func ParseHandle(acct string) (username, domain string, err error) {
+	username, domain, found := strings.Cut(strings.TrimPrefix(strings.TrimSpace(acct), "@"), "@")
+	if !found || username == "" || domain == "" || strings.Contains(domain, "@") {
+		return "", "", ErrInvalidHandle
+	}
+	return username, strings.ToLower(domain), nil
+}
+
+func (r *accountResolver) resolve(acct string) (*domain.RemoteAccount, error) {
+	username, domainName, err := ParseHandle(acct)
+	if err != nil {
+		return nil, err
+	}
+	key := strings.ToLower(username) + "@" + domainName
+
+	if cached, err := r.readCached(username, domainName); err == nil && cached != nil &&
+		r.now().Sub(cached.LastFetchedAt) < cachedActorTTL {
+		return cached, nil
+	}
+
+	r.mu.Lock()
+	retryAt, failed := r.failed[key]
+	r.mu.Unlock()
+	if failed && r.now().Before(retryAt) {
+		return nil, ErrAccountNotFound
+	}
+
+	account, err := r.lookup(username, domainName)
+	if err != nil {
+		r.mu.Lock()
+		r.failed[key] = r.now().Add(failedLookupTTL)
+		// Drop expired entries so the cache doesn't grow with every typo
+		for handle, until := range r.failed {
+			if r.now().After(until) {
+				delete(r.failed, handle)
+			}
+		}
+		r.mu.Unlock()
+		return nil, err
+	}
+
+	r.mu.Lock()
+	delete(r.failed, key)
+	r.mu.Unlock()
+	return account, nil
+}
+
+// lookup WebFinger-resolves a handle and fetches its actor
+func (r *accountResolver) lookup(username, domainName string) (*domain.RemoteAccount, error) {
+	actorURI, err := r.webfinger(username, domainName)
+	if err != nil {
+		return nil, fmt.Errorf("webfinger resolution failed: %w", err)
+	}
+	account, err := r.fetchActor(actorURI)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch actor: %w", err)
+	}
+	return account, nil
+}
@@ -0,0 +1,117 @@
+package web
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/deemkeen/stegodon/domain"
+	"github.com/google/uuid"
+)
+
+func TestParseHandle(t *testing.T) {
+	tests := []struct {
+		input    string
+		username string
+		domain   string
+		valid    bool
+	}{
+		{"alice@example.com", "alice", "example.com", true},
+		{" @alice@Example.COM ", "alice", "example.com", true},
+		{"alice", "", "", false},
+		{"@alice", "", "", false},
+		{"alice@", "", "", false},
+		{"@example.com", "", "", false},
+		{"alice@example.com@other", "", "", false},
+	}
+
+	for _, tt := range tests {
+		username, domainName, err := ParseHandle(tt.input)
+		if tt.valid != (err == nil) {
+			t.Errorf("ParseHandle(%q) error = %v, want valid %v", tt.input, err, tt.valid)
+			continue
+		}
+		if username != tt.username || domainName != tt.domain {
+			t.Errorf("ParseHandle(%q) = %q, %q, want %q, %q", tt.input, username, domainName, tt.username, tt.domain)
+		}
+	}
+}
+
+// newTestResolver returns a resolver without cached accounts that counts WebFinger lookups
+func newTestResolver(webfingerErr error, lookups *int) (*accountResolver, *time.Time) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	resolver := &accountResolver{
+		failed: make(map[string]time.Time),
+		now:    func() time.Time { return now },
+		readCached: func(username, domain string) (*domain.RemoteAccount, error) {
+			return nil, errors.New("not cached")
+		},
+		webfinger: func(username, domain string) (string, error) {
+			*lookups++
+			if webfingerErr != nil {
+				return "", webfingerErr
+			}
+			return "https://" + domain + "/users/" + username, nil
+		},
+		fetchActor: func(actorURI string) (*domain.RemoteAccount, error) {
+			return &domain.RemoteAccount{Id: uuid.New(), Username: "alice", Domain: "example.com", ActorURI: actorURI}, nil
+		},
+	}
+	return resolver, &now
+}
+
+func TestResolveAccount(t *testing.T) {
+	lookups := 0
+	resolver, _ := newTestResolver(nil, &lookups)
+
+	account, err := resolver.resolve("@alice@example.com")
+	if err != nil {
+		t.Fatalf("resolve failed: %v", err)
+	}
+	if account.ActorURI != "https://example.com/users/alice" || lookups != 1 {
+		t.Errorf("Expected the WebFinger-resolved actor, got %+v after %d lookups", account, lookups)
+	}
+
+	if _, err := resolver.resolve("alice"); !errors.Is(err, ErrInvalidHandle) {
+		t.Errorf("Expected ErrInvalidHandle, got %v", err)
+	}
+}
+
+func TestResolveAccount_UsesFreshCache(t *testing.T) {
+	lookups := 0
+	resolver, now := newTestResolver(nil, &lookups)
+	cached := &domain.RemoteAccount{Id: uuid.New(), Username: "alice", Domain: "example.com", LastFetchedAt: now.Add(-time.Hour)}
+	resolver.readCached = func(username, domain string) (*domain.RemoteAccount, error) {
+		return cached, nil
+	}
+
+	account, err := resolver.resolve("alice@example.com")
+	if err != nil || account != cached || lookups != 0 {
+		t.Errorf("Expected the cached account without a lookup, got %+v, %v after %d lookups", account, err, lookups)
+	}
+
+	// A stale cached account is fetched again
+	cached.LastFetchedAt = now.Add(-2 * cachedActorTTL)
+	if _, err := resolver.resolve("alice@example.com"); err != nil || lookups != 1 {
+		t.Errorf("Expected a stale account to be resolved again, got %v after %d lookups", err, lookups)
+	}
+}
+
+func TestResolveAccount_CachesFailures(t *testing.T) {
+	lookups := 0
+	resolver, now := newTestResolver(errors.New("webfinger failed with status: 404"), &lookups)
+
+	if _, err := resolver.resolve("typo@example.com"); err == nil {
+		t.Fatal("Expected the lookup to fail")
+	}
+	if _, err := resolver.resolve("@Typo@example.com"); !errors.Is(err, ErrAccountNotFound) || lookups != 1 {
+		t.Errorf("Expected the cached failure without a lookup, got %v after %d lookups", err, lookups)
+	}
+
+	// Once the failure expires the handle is looked up again
+	*now = now.Add(failedLookupTTL + time.Second)
+	resolver.resolve("typo@example.com")
+	if lookups != 2 {
+		t.Errorf("Expected a new lookup after the failure expired, got %d lookups", lookups)
+	}
+}
@@ -1,17 +1,16 @@
 package web
 
 import (
-	"bytes"
 	"embed"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
-	"io"
 	"log"
 	"strings"
 
 	"github.com/deemkeen/stegodon/activitypub"
 	"github.com/deemkeen/stegodon/db"
+	"github.com/deemkeen/stegodon/domain"
 	"github.com/deemkeen/stegodon/util"
 	"github.com/gin-contrib/gzip"
 	"github.com/gin-gonic/gin"
@@ -68,10 +67,43 @@ func Router(conf *util.AppConfig) (*gin.Engine, error) {
 	})
 
 	g.GET("/u/:username", func(c *gin.Context) {
+		c.Header("Vary", "Accept")
+		// ActivityPub clients asking for the profile page get the actor
+		if conf.Conf.WithAp && wantsActivityJSON(c.GetHeader("Accept")) {
+			c.Header("Content-Type", activityContentType(c.GetHeader("Accept")))
+			err, actor := GetActor(c.Param("username"), conf)
+			if errors.Is(err, ErrAccountDisabled) {
+				c.Render(403, render.String{Format: actor})
+			} else if err != nil {
+				c.Render(404, render.String{Format: actor})
+			} else {
+				c.Render(200, render.String{Format: actor})
+			}
+			return
+		}
 		HandleProfile(c, conf)
 	})
 
 	g.GET("/u/:username/:noteid", func(c *gin.Context) {
+		c.Header("Vary", "Accept")
+		// ActivityPub clients asking for the post page get the Note
+		if conf.Conf.WithAp && wantsActivityJSON(c.GetHeader("Accept")) {
+			c.Header("Content-Type", activityContentType(c.GetHeader("Accept")))
+			noteId, err := uuid.Parse(c.Param("noteid"))
+			if err != nil {
+				c.JSON(404, gin.H{"error": "Invalid note ID"})
+				return
+			}
+			err, note := GetNoteObject(noteId, conf)
+			if errors.Is(err, ErrNoteDeleted) {
+				c.Render(410, render.String{Format: note})
+			} else if err != nil {
+				c.JSON(404, gin.H{"error": "Note not found"})
+			} else {
+				c.Render(200, render.String{Format: note})
+			}
+			return
+		}
 		HandleSinglePost(c, conf)
 	})
 
@@ -86,6 +118,79 @@ func Router(conf *util.AppConfig) (*gin.Engine, error) {
 		HandleTagFeed(c, conf)
 	})
 
+	// Trending hashtags (Mastodon-compatible)
+	g.GET("/api/v1/trends/tags", func(c *gin.Context) {
+		c.Header("Content-Type", "application/json; charset=utf-8")
+		err, trends := GetTrendingTags(ParseTrendsLimit(c.Query("limit")), conf)
+		if err != nil {
+			c.Render(500, render.String{Format: trends})
+		} else {
+			c.Render(200, render.String{Format: trends})
+		}
+	})
+
+	// Instance metadata (Mastodon-compatible), probed by clients and fediverse tools
+	g.GET("/api/v1/instance", func(c *gin.Context) {
+		c.Header("Content-Type", "application/json; charset=utf-8")
+		err, instance := GetInstanceV1(conf)
+		if err != nil {
+			c.Render(500, render.String{Format: instance})
+		} else {
+			c.Render(200, render.String{Format: instance})
+		}
+	})
+	g.GET("/api/v2/instance", func(c *gin.Context) {
+		c.Header("Content-Type", "application/json; charset=utf-8")
+		err, instance := GetInstanceV2(conf)
+		if err != nil {
+			c.Render(500, render.String{Format: instance})
+		} else {
+			c.Render(200, render.String{Format: instance})
+		}
+	})
+
+	// A user's profile posts as JSON, without replies unless with_replies is set
+	g.GET("/api/v1/accounts/:username/posts", func(c *gin.Context) {
+		c.Header("Content-Type", "application/json; charset=utf-8")
+		err, posts := GetProfilePosts(c.Param("username"), ParsePage(c.Query("page")), ParseAPITimelineLimit(c.Query("limit")),
+			ParseWithReplies(c.Query("with_replies")), conf)
+		switch {
+		case errors.Is(err, ErrProfileNotFound):
+			c.Render(404, render.String{Format: posts})
+		case err != nil:
+			c.Render(500, render.String{Format: posts})
+		default:
+			c.Render(200, render.String{Format: posts})
+		}
+	})
+
+	// JSON timelines, authenticated with an API token granting the read scope
+	timelines := g.Group("/api/v1/timelines", APIAuthMiddleware(domain.ScopeRead))
+	timelines.GET("/home", func(c *gin.Context) {
+		account := c.MustGet(apiAccountKey).(*domain.Account)
+		err, timeline := GetHomeTimeline(account, c.Query("cursor"), ParseAPITimelineLimit(c.Query("limit")), conf)
+		renderTimeline(c, err, timeline)
+	})
+	timelines.GET("/public", func(c *gin.Context) {
+		err, timeline := GetPublicTimeline(c.Query("cursor"), ParseAPITimelineLimit(c.Query("limit")), conf)
+		renderTimeline(c, err, timeline)
+	})
+	timelines.GET("/tag/:tag", func(c *gin.Context) {
+		err, timeline := GetTagTimeline(c.Param("tag"), c.Query("cursor"), ParseAPITimelineLimit(c.Query("limit")), conf)
+		renderTimeline(c, err, timeline)
+	})
+
+	// Instance statistics, for API tokens of admin accounts
+	g.GET("/admin/stats", APIAuthMiddleware(domain.ScopeRead), AdminOnlyMiddleware(), func(c *gin.Context) {
+		c.Header("Content-Type", "application/json; charset=utf-8")
+		err, stats := GetAdminStats(conf)
+		if err != nil {
+			c.Render(500, render.String{Format: stats})
+		} else {
+			c.Render(200, render.String{Format: stats})
+		}
+	})
+
 	// RSS Feed
 	g.GET("/feed", func(c *gin.Context) {
 
@@ -125,9 +230,12 @@ func Router(conf *util.AppConfig) (*gin.Engine, error) {
 		// Max 1MB request body size for ActivityPub activities
 		maxBodySize := MaxBytesMiddleware(1 * 1024 * 1024) // 1MB
 
+		// In secure mode, actors, objects and collections are only served to signed fetches
+		signedFetch := SignedFetchMiddleware(conf, nil)
+
 		// Serve individual notes as ActivityPub objects
-		g.GET("/notes/:id", func(c *gin.Context) {
-			c.Header("Content-Type", "application/activity+json; charset=utf-8")
+		g.GET("/notes/:id", signedFetch, func(c *gin.Context) {
+			c.Header("Vary", "Accept")
 
 			noteIdStr := c.Param("id")
 			noteId, err := uuid.Parse(noteIdStr)
@@ -136,158 +244,110 @@ func Router(conf *util.AppConfig) (*gin.Engine, error) {
 				return
 			}
 
+			// Browsers get redirected to the post page
+			if wantsHTML(c.GetHeader("Accept")) {
+				note, err := db.GetDB().ReadNoteId(noteId)
+				if err != nil {
+					c.Status(404)
+					return
+				}
+				c.Redirect(302, fmt.Sprintf("/u/%s/%s", note.CreatedBy, note.Id.String()))
+				return
+			}
+
+			c.Header("Content-Type", activityContentType(c.GetHeader("Accept")))
+
 			err, note := GetNoteObject(noteId, conf)
-			if err != nil {
+			if errors.Is(err, ErrNoteDeleted) {
+				c.Render(410, render.String{Format: note})
+			} else if err != nil {
 				c.JSON(404, gin.H{"error": "Note not found"})
 			} else {
 				c.Render(200, render.String{Format: note})
 			}
 		})
 
-		g.GET("/users/:actor", func(c *gin.Context) {
+		// Serve the replies collection of a note so remote servers can discover its thread
+		g.GET("/notes/:id/replies", signedFetch, func(c *gin.Context) {
+			c.Header("Content-Type", activityContentType(c.GetHeader("Accept")))
 
-			c.Header("Content-Type", "application/activity+json; charset=utf-8")
-			err, actor := GetActor(c.Param("actor"), conf)
+			noteId, err := uuid.Parse(c.Param("id"))
 			if err != nil {
-				c.Render(404, render.String{Format: actor})
-			} else {
-				c.Render(200, render.String{Format: actor})
+				c.JSON(404, gin.H{"error": "Invalid note ID"})
+				return
 			}
-		})
 
-		g.POST("/inbox", RateLimitMiddleware(apLimiter), maxBodySize, func(c *gin.Context) {
-			log.Println("POST /inbox (shared inbox)")
-			// Shared inbox - extract target username from activity object
-			body, err := c.GetRawData()
+			err, replies := GetNoteReplies(noteId, ParsePageParam(c.Query("page")), conf)
 			if err != nil {
-				log.Printf("Shared inbox: Failed to read body: %v", err)
-				c.Status(400)
+				c.JSON(404, gin.H{"error": "Note not found"})
 				return
 			}
+			c.Render(200, render.String{Format: replies})
+		})
 
-			// Parse to get target actor
-			var activity map[string]any
-			if err := json.Unmarshal(body, &activity); err != nil {
-				log.Printf("Shared inbox: Failed to parse activity: %v", err)
-				c.Status(400)
+		// Serve activities we emitted (Create, Like) so remote servers can dereference their ids
+		g.GET("/activities/:id", signedFetch, func(c *gin.Context) {
+			activityId, err := uuid.Parse(c.Param("id"))
+			if err != nil {
+				c.JSON(404, gin.H{"error": "Invalid activity ID"})
 				return
 			}
 
-			// Extract username from activity addressing
-			var targetUsername string
-
-			// Helper function to extract username from URI
-			extractUsername := func(uri string) string {
-				// Check if it's one of our users: https://domain/users/username
-				if strings.Contains(uri, conf.Conf.SslDomain) && strings.Contains(uri, "/users/") {
-					parts := strings.Split(uri, "/")
-					for i, part := range parts {
-						if part == "users" && i+1 < len(parts) {
-							// Extract just the username, handle /followers suffix
-							username := parts[i+1]
-							// Remove /followers or /following if present
-							if slashIdx := strings.Index(username, "/"); slashIdx > 0 {
-								username = username[:slashIdx]
-							}
-							return username
-						}
-					}
-				}
-				return ""
+			err, activity := GetLocalActivity(activityId, conf)
+			if err != nil {
+				c.JSON(404, gin.H{"error": "Activity not found"})
+				return
 			}
 
-			// Try to find target in "to" field first
-			if toArray, ok := activity["to"].([]any); ok {
-				for _, to := range toArray {
-					if toStr, ok := to.(string); ok {
-						if username := extractUsername(toStr); username != "" {
-							targetUsername = username
-							break
-						}
-					}
-				}
+			// Browsers get redirected to the HTML page, ActivityPub clients get the JSON
+			c.Header("Vary", "Accept")
+			if wantsHTML(c.GetHeader("Accept")) {
+				c.Redirect(302, activityHTMLURL(activity, conf))
+				return
 			}
 
-			// If not found, try "cc" field (followers collections)
-			if targetUsername == "" {
-				if ccArray, ok := activity["cc"].([]any); ok {
-					for _, cc := range ccArray {
-						if ccStr, ok := cc.(string); ok {
-							// Check for followers URI: https://domain/users/username/followers
-							if username := extractUsername(ccStr); username != "" {
-								targetUsername = username
-								break
-							}
-						}
-					}
-				}
-			}
+			c.Header("Content-Type", activityContentType(c.GetHeader("Accept")))
+			c.Render(200, render.String{Format: activity.RawJSON})
+		})
 
-			// For Follow activities, check the object field
-			if targetUsername == "" {
-				if objStr, ok := activity["object"].(string); ok {
-					targetUsername = extractUsername(objStr)
-				}
+		// Unsigned fetches of an actor still get its public key, which servers that don't sign
+		// their fetches need to verify our deliveries
+		actorKey := func(c *gin.Context) {
+			c.Header("Vary", "Accept")
+			c.Header("Content-Type", activityContentType(c.GetHeader("Accept")))
+			err, actor := GetActorKey(c.Param("actor"), conf)
+			if errors.Is(err, ErrAccountDisabled) {
+				c.Render(403, render.String{Format: actor})
+			} else if err != nil {
+				c.Render(404, render.String{Format: actor})
+			} else {
+				c.Render(200, render.String{Format: actor})
 			}
+		}
 
-			if targetUsername == "" {
-				// For Create/Update/Delete activities, find which local user(s) follow this actor
-				actorURI, _ := activity["actor"].(string)
-				if actorURI != "" {
-					database := db.GetDB()
+		g.GET("/users/:actor", SignedFetchMiddleware(conf, actorKey), func(c *gin.Context) {
+			c.Header("Vary", "Accept")
 
-					// Get the remote actor
-					err, remoteActor := database.ReadRemoteAccountByActorURI(actorURI)
-					if err == nil && remoteActor != nil {
-						// Find followers of this remote actor (local users who follow them)
-						err, followers := database.ReadFollowersByAccountId(remoteActor.Id)
-						if err == nil && followers != nil && len(*followers) > 0 {
-							// Get the first local user who follows this actor
-							firstFollower := (*followers)[0]
-							err, localAccount := database.ReadAccById(firstFollower.AccountId)
-							if err == nil && localAccount != nil {
-								targetUsername = localAccount.Username
-								log.Printf("Shared inbox: Routing to follower %s of %s", targetUsername, actorURI)
-							}
-						} else {
-							log.Printf("Shared inbox: No local followers found for %s", actorURI)
-						}
-					} else {
-						log.Printf("Shared inbox: Remote actor %s not found in cache", actorURI)
-					}
-				}
-			}
-
-			// Check if this is relay content (activity from an active relay)
-			// Relays send to shared inbox, not individual user inboxes
-			if targetUsername == "" {
-				activityType, _ := activity["type"].(string)
-				if activityType == "Create" || activityType == "Announce" {
-					database := db.GetDB()
-					// Check if we have any active relays - if so, process relay content
-					err, relays := database.ReadActiveRelays()
-					if err == nil && relays != nil && len(*relays) > 0 {
-						// Get any local user to process this (relay content is instance-wide)
-						err, accounts := database.ReadAllAccounts()
-						if err == nil && accounts != nil && len(*accounts) > 0 {
-							targetUsername = (*accounts)[0].Username
-							log.Printf("Shared inbox: Routing relay content to %s", targetUsername)
-						}
-					}
-				}
+			// Browsers get redirected to the profile page
+			if wantsHTML(c.GetHeader("Accept")) {
+				c.Redirect(302, "/u/"+c.Param("actor"))
+				return
 			}
 
-			if targetUsername == "" {
-				log.Printf("Shared inbox: Could not determine target username from activity type %v", activity["type"])
-				c.Status(202) // Accept anyway to be nice
-				return
+			c.Header("Content-Type", activityContentType(c.GetHeader("Accept")))
+			err, actor := GetActor(c.Param("actor"), conf)
+			if errors.Is(err, ErrAccountDisabled) {
+				c.Render(403, render.String{Format: actor})
+			} else if err != nil {
+				c.Render(404, render.String{Format: actor})
+			} else {
+				c.Render(200, render.String{Format: actor})
 			}
+		})
 
-			log.Printf("Shared inbox: Routing to user %s", targetUsername)
-			// Create a new request with the body
-			req := c.Request.Clone(c.Request.Context())
-			req.Body = io.NopCloser(bytes.NewReader(body))
-			activitypub.HandleInbox(c.Writer, req, targetUsername, conf)
+		g.POST("/inbox", RateLimitMiddleware(apLimiter), maxBodySize, func(c *gin.Context) {
+			log.Println("POST /inbox (shared inbox)")
+			activitypub.HandleSharedInbox(c.Writer, c.Request, conf)
 		})
 
 		g.POST("/users/:actor/inbox", RateLimitMiddleware(apLimiter), maxBodySize, func(c *gin.Context) {
@@ -296,7 +356,7 @@ func Router(conf *util.AppConfig) (*gin.Engine, error) {
 			activitypub.HandleInbox(c.Writer, c.Request, actor, conf)
 		})
 
-		g.GET("/users/:actor/outbox", func(c *gin.Context) {
+		g.GET("/users/:actor/outbox", signedFetch, func(c *gin.Context) {
 			actor := c.Param("actor")
 			pageStr := c.Query("page")
 			page := ParsePageParam(pageStr)
@@ -304,25 +364,29 @@ func Router(conf *util.AppConfig) (*gin.Engine, error) {
 			log.Printf("GET /users/%s/outbox (page=%d)", actor, page)
 
 			err, outbox := GetOutbox(actor, page, conf)
-			if err != nil {
-				c.Header("Content-Type", "application/activity+json; charset=utf-8")
+			if errors.Is(err, ErrAccountDisabled) {
+				c.Header("Content-Type", activityContentType(c.GetHeader("Accept")))
+				c.Render(403, render.String{Format: outbox})
+				return
+			} else if err != nil {
+				c.Header("Content-Type", activityContentType(c.GetHeader("Accept")))
 				c.Render(404, render.String{Format: "{}"})
 				return
 			}
 
-			c.Header("Content-Type", "application/activity+json; charset=utf-8")
+			c.Header("Content-Type", activityContentType(c.GetHeader("Accept")))
 			c.Render(200, render.String{Format: outbox})
 		})
 
-		g.GET("/users/:actor/followers", func(c *gin.Context) {
+		g.GET("/users/:actor/followers", signedFetch, func(c *gin.Context) {
 			actor := c.Param("actor")
 			page := c.Query("page")
 			log.Printf("Get followers for %s (page=%s)", actor, page)
-			c.Header("Content-Type", "application/activity+json; charset=utf-8")
+			c.Header("Content-Type", activityContentType(c.GetHeader("Accept")))
 
 			// Get the account
 			database := db.GetDB()
-			err, account := database.ReadAccByUsername(actor)
+			account, err := database.ReadAccByUsername(actor)
 			if err != nil {
 				log.Printf("Failed to get account %s: %v", actor, err)
 				c.Render(404, render.String{Format: "{}"})
@@ -330,7 +394,7 @@ func Router(conf *util.AppConfig) (*gin.Engine, error) {
 			}
 
 			// Get followers
-			err, followers := database.ReadFollowersByAccountId(account.Id)
+			followers, err := database.ReadFollowersByAccountId(account.Id)
 			if err != nil {
 				log.Printf("Failed to get followers: %v", err)
 				c.Render(200, render.String{Format: GetFollowersCollection(actor, conf, []string{})})
@@ -342,13 +406,13 @@ func Router(conf *util.AppConfig) (*gin.Engine, error) {
 			if followers != nil {
 				for _, follower := range *followers {
 					// Get the remote account
-					err, remoteActor := database.ReadRemoteAccountById(follower.AccountId)
+					remoteActor, err := database.ReadRemoteAccountById(follower.AccountId)
 					if err == nil && remoteActor != nil {
 						followerURIs = append(followerURIs, remoteActor.ActorURI)
 						log.Printf("Added remote follower: %s", remoteActor.ActorURI)
 					} else {
 						// Check if it's a local account
-						err, localAcc := database.ReadAccById(follower.AccountId)
+						localAcc, err := database.ReadAccById(follower.AccountId)
 						if err == nil && localAcc != nil {
 							localURI := fmt.Sprintf("https://%s/users/%s", conf.Conf.SslDomain, localAcc.Username)
 							followerURIs = append(followerURIs, localURI)
@@ -371,15 +435,15 @@ func Router(conf *util.AppConfig) (*gin.Engine, error) {
 			}
 		})
 
-		g.GET("/users/:actor/following", func(c *gin.Context) {
+		g.GET("/users/:actor/following", signedFetch, func(c *gin.Context) {
 			actor := c.Param("actor")
 			page := c.Query("page")
 			log.Printf("Get following for %s (page=%s)", actor, page)
-			c.Header("Content-Type", "application/activity+json; charset=utf-8")
+			c.Header("Content-Type", activityContentType(c.GetHeader("Accept")))
 
 			// Get the account
 			database := db.GetDB()
-			err, account := database.ReadAccByUsername(actor)
+			account, err := database.ReadAccByUsername(actor)
 			if err != nil {
 				log.Printf("Failed to get account %s: %v", actor, err)
 				c.Render(404, render.String{Format: "{}"})
@@ -387,7 +451,7 @@ func Router(conf *util.AppConfig) (*gin.Engine, error) {
 			}
 
 			// Get following
-			err, following := database.ReadFollowingByAccountId(account.Id)
+			following, err := database.ReadFollowingByAccountId(account.Id)
 			if err != nil {
 				log.Printf("Failed to get following: %v", err)
 				c.Render(200, render.String{Format: GetFollowingCollection(actor, conf, []string{})})
@@ -399,13 +463,13 @@ func Router(conf *util.AppConfig) (*gin.Engine, error) {
 			if following != nil {
 				for _, follow := range *following {
 					// Get the remote account
-					err, remoteActor := database.ReadRemoteAccountById(follow.TargetAccountId)
+					remoteActor, err := database.ReadRemoteAccountById(follow.TargetAccountId)
 					if err == nil && remoteActor != nil {
 						followingURIs = append(followingURIs, remoteActor.ActorURI)
 						log.Printf("Added remote following: %s", remoteActor.ActorURI)
 					} else {
 						// Check if it's a local account
-						err, localAcc := database.ReadAccById(follow.TargetAccountId)
+						localAcc, err := database.ReadAccById(follow.TargetAccountId)
 						if err == nil && localAcc != nil {
 							localURI := fmt.Sprintf("https://%s/users/%s", conf.Conf.SslDomain, localAcc.Username)
 							followingURIs = append(followingURIs, localURI)
@@ -32,7 +32,7 @@ func GetRSS(conf *util.AppConfig, username string) (string, error) {
 	link := buildURL(conf, "/feed")
 
 	if username != "" {
-		err, notes = db.GetDB().ReadNotesByUsername(username)
+		notes, err = db.GetDB().ReadNotesByUsername(username)
 		if err != nil {
 			log.Println(fmt.Sprintf("Could not get notes from %s!", username), err)
 			return "", errors.New("error retrieving notes by username")
@@ -47,7 +47,7 @@ func GetRSS(conf *util.AppConfig, username string) (string, error) {
 			email = fmt.Sprintf("%s@stegodon", (*notes)[0].CreatedBy)
 		}
 	} else {
-		err, notes = db.GetDB().ReadAllNotes()
+		notes, err = db.GetDB().ReadAllNotes()
 		if err != nil {
 			log.Println("Could not get notes!", err)
 			return "", errors.New("error retrieving notes")
@@ -92,9 +92,9 @@ func GetRSS(conf *util.AppConfig, username string) (string, error) {
 }
 
 func GetRSSItem(conf *util.AppConfig, id uuid.UUID) (string, error) {
-	err, note := db.GetDB().ReadNoteId(id)
+	note, err := db.GetDB().ReadNoteId(id)
 
-	if err != nil || note == nil {
+	if err != nil || note == nil || note.IsLocalOnly() || note.IsDeleted() {
 		log.Println("Could not get note!", err)
 		return "", errors.New("error retrieving note by id")
 	}
@@ -0,0 +1,79 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/deemkeen/stegodon/db"
+	"github.com/deemkeen/stegodon/domain"
+	"github.com/deemkeen/stegodon/util"
+)
+
+const (
+	// trendsWindow is how far back hashtag use counts toward trends (Mastodon also uses a week)
+	trendsWindow = 7 * 24 * time.Hour
+
+	defaultTrendsLimit = 10
+	maxTrendsLimit     = 20
+)
+
+// TrendingTag is a Mastodon-compatible Tag entity, as returned by /api/v1/trends/tags
+type TrendingTag struct {
+	Name    string            `json:"name"`
+	URL     string            `json:"url"`
+	History []TrendingHistory `json:"history"`
+}
+
+// TrendingHistory holds a tag's usage. Stegodon reports a single entry covering the whole
+// trends window, starting at Day; counts are strings as in Mastodon's API.
+type TrendingHistory struct {
+	Day      string `json:"day"`
+	Uses     string `json:"uses"`
+	Accounts string `json:"accounts"`
+}
+
+// ParseTrendsLimit parses the limit query parameter, falling back to the default
+// for missing or invalid values and capping it at maxTrendsLimit
+func ParseTrendsLimit(limitStr string) int {
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit < 1 {
+		return defaultTrendsLimit
+	}
+	return min(limit, maxTrendsLimit)
+}
+
+// GetTrendingTags returns the hashtags trending in public notes over the last week as JSON
+func GetTrendingTags(limit int, conf *util.AppConfig) (error, string) {
+	trends, err := db.GetDB().ReadTrendingHashtags(trendsWindow, limit)
+	if err != nil {
+		log.Printf("GetTrendingTags: Failed to read trending hashtags: %v", err)
+		return err, "[]"
+	}
+
+	jsonData, err := json.Marshal(makeTrendingTags(trends, time.Now().Add(-trendsWindow), conf))
+	if err != nil {
+		log.Printf("GetTrendingTags: Failed to marshal trends: %v", err)
+		return err, "[]"
+	}
+	return nil, string(jsonData)
+}
+
+// makeTrendingTags converts ranked hashtags to Tag entities whose history starts at since
+func makeTrendingTags(trends []domain.HashtagTrend, since time.Time, conf *util.AppConfig) []TrendingTag {
+	tags := make([]TrendingTag, 0, len(trends))
+	for _, trend := range trends {
+		tags = append(tags, TrendingTag{
+			Name: trend.Name,
+			URL:  fmt.Sprintf("https://%s/tags/%s", conf.Conf.SslDomain, trend.Name),
+			History: []TrendingHistory{{
+				Day:      strconv.FormatInt(since.Unix(), 10),
+				Uses:     strconv.Itoa(trend.Uses),
+				Accounts: strconv.Itoa(trend.Accounts),
+			}},
+		})
+	}
+	return tags
+}
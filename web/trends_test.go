@@ -0,0 +1,52 @@
+package web
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/deemkeen/stegodon/domain"
+	"github.com/deemkeen/stegodon/util"
+)
+
+func TestParseTrendsLimit(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int
+	}{
+		{"", defaultTrendsLimit},
+		{"abc", defaultTrendsLimit},
+		{"0", defaultTrendsLimit},
+		{"5", 5},
+		{"100", maxTrendsLimit},
+	}
+
+	for _, tt := range tests {
+		if result := ParseTrendsLimit(tt.input); result != tt.expected {
+			t.Errorf("ParseTrendsLimit(%q) = %d, want %d", tt.input, result, tt.expected)
+		}
+	}
+}
+
+func TestMakeTrendingTags(t *testing.T) {
+	conf := &util.AppConfig{}
+	conf.Conf.SslDomain = "example.com"
+	since := time.Unix(1700000000, 0)
+
+	tags := makeTrendingTags([]domain.HashtagTrend{{Name: "golang", Uses: 5, Accounts: 3}}, since, conf)
+	jsonData, err := json.Marshal(tags)
+	if err != nil {
+		t.Fatalf("Failed to marshal tags: %v", err)
+	}
+
+	expected := `[{"name":"golang","url":"https://example.com/tags/golang","history":[{"day":"1700000000","uses":"5","accounts":"3"}]}]`
+	if string(jsonData) != expected {
+		t.Errorf("Expected %s, got %s", expected, jsonData)
+	}
+
+	// No trends must still encode as an empty array
+	jsonData, _ = json.Marshal(makeTrendingTags(nil, since, conf))
+	if string(jsonData) != "[]" {
+		t.Errorf("Expected [], got %s", jsonData)
+	}
+}
@@ -2,9 +2,11 @@ package web
 
 import (
 	"fmt"
+	"html"
 	"html/template"
 	"log"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/deemkeen/stegodon/db"
@@ -27,17 +29,18 @@ type IndexPageData struct {
 }
 
 type ProfilePageData struct {
-	Title      string
-	Host       string
-	SSHPort    int
-	Version    string
-	User       UserView
-	Posts      []PostView
-	TotalPosts int
-	HasPrev    bool
-	HasNext    bool
-	PrevPage   int
-	NextPage   int
+	Title       string
+	Host        string
+	SSHPort     int
+	Version     string
+	User        UserView
+	Posts       []PostView
+	TotalPosts  int
+	WithReplies bool // "Posts and replies" tab instead of "Posts"
+	HasPrev     bool
+	HasNext     bool
+	PrevPage    int
+	NextPage    int
 }
 
 type UserView struct {
@@ -57,6 +60,7 @@ type PostView struct {
 	ReplyCount   int    // Number of replies to this post
 	LikeCount    int    // Number of likes on this post
 	BoostCount   int    // Number of boosts on this post
+	RemoteURL    string // Original URL of a federated post (empty for local posts)
 }
 
 func formatTimeAgo(t time.Time) string {
@@ -102,7 +106,7 @@ func HandleIndex(c *gin.Context, conf *util.AppConfig) {
 	offset := (page - 1) * postsPerPage
 
 	// Get all notes from all users (local timeline)
-	err, notes := database.ReadAllNotes()
+	notes, err := database.ReadAllNotes()
 	if err != nil {
 		log.Printf("Failed to read notes: %v", err)
 		c.HTML(500, "base.html", gin.H{"Title": "Error", "Error": "Failed to load timeline"})
@@ -187,7 +191,7 @@ func HandleProfile(c *gin.Context, conf *util.AppConfig) {
 	database := db.GetDB()
 
 	// Get user account
-	err, account := database.ReadAccByUsername(username)
+	account, err := database.ReadAccByUsername(username)
 	if err != nil {
 		log.Printf("User not found: %s", username)
 		c.HTML(404, "base.html", gin.H{"Title": "Not Found", "Error": "User not found"})
@@ -205,43 +209,27 @@ func HandleProfile(c *gin.Context, conf *util.AppConfig) {
 	postsPerPage := 20
 	offset := (page - 1) * postsPerPage
 
-	// Get user's notes
-	err, notes := database.ReadNotesByUserId(account.Id)
+	// Replies are only listed on the "Posts and replies" tab
+	withReplies := ParseWithReplies(c.Query("with_replies"))
+
+	totalPosts, err := database.CountNotesByUsername(account.Username, withReplies)
 	if err != nil {
-		log.Printf("Failed to read notes for user %s: %v", username, err)
+		log.Printf("Failed to count notes for user %s: %v", username, err)
 		c.HTML(500, "base.html", gin.H{"Title": "Error", "Error": "Failed to load user posts"})
 		return
 	}
 
-	if notes == nil {
-		notes = &[]domain.Note{}
-	}
-
-	// Filter out replies (posts with InReplyToURI set)
-	var topLevelNotes []domain.Note
-	for _, note := range *notes {
-		if note.InReplyToURI == "" {
-			topLevelNotes = append(topLevelNotes, note)
-		}
-	}
-
-	totalPosts := len(topLevelNotes)
-
-	// Apply pagination
-	start := offset
-	end := offset + postsPerPage
-	if start > totalPosts {
-		start = totalPosts
-	}
-	if end > totalPosts {
-		end = totalPosts
+	// Get the page of the user's notes
+	notes, err := database.ReadNotesByUsernamePaged(account.Username, postsPerPage, offset, withReplies)
+	if err != nil {
+		log.Printf("Failed to read notes for user %s: %v", username, err)
+		c.HTML(500, "base.html", gin.H{"Title": "Error", "Error": "Failed to load user posts"})
+		return
 	}
 
-	paginatedNotes := topLevelNotes[start:end]
-
 	// Convert to PostView
-	posts := make([]PostView, 0, len(paginatedNotes))
-	for _, note := range paginatedNotes {
+	posts := make([]PostView, 0, len(*notes))
+	for _, note := range *notes {
 		// First convert markdown links, then highlight hashtags and mentions
 		messageHTML := util.MarkdownLinksToHTML(note.Message)
 		messageHTML = util.HighlightHashtagsHTML(messageHTML)
@@ -254,14 +242,15 @@ func HandleProfile(c *gin.Context, conf *util.AppConfig) {
 		}
 
 		posts = append(posts, PostView{
-			NoteId:      note.Id.String(),
-			Username:    note.CreatedBy,
-			Message:     note.Message,
-			MessageHTML: template.HTML(messageHTML),
-			TimeAgo:     formatTimeAgo(note.CreatedAt),
-			ReplyCount:  replyCount,
-			LikeCount:   note.LikeCount,
-			BoostCount:  note.BoostCount,
+			NoteId:       note.Id.String(),
+			Username:     note.CreatedBy,
+			Message:      note.Message,
+			MessageHTML:  template.HTML(messageHTML),
+			TimeAgo:      formatTimeAgo(note.CreatedAt),
+			InReplyToURI: note.InReplyToURI,
+			ReplyCount:   replyCount,
+			LikeCount:    note.LikeCount,
+			BoostCount:   note.BoostCount,
 		})
 	}
 
@@ -282,12 +271,13 @@ func HandleProfile(c *gin.Context, conf *util.AppConfig) {
 			Summary:     account.Summary,
 			JoinedAgo:   formatTimeAgo(account.CreatedAt),
 		},
-		Posts:      posts,
-		TotalPosts: totalPosts,
-		HasPrev:    page > 1,
-		HasNext:    end < totalPosts,
-		PrevPage:   page - 1,
-		NextPage:   page + 1,
+		Posts:       posts,
+		TotalPosts:  totalPosts,
+		WithReplies: withReplies,
+		HasPrev:     page > 1,
+		HasNext:     offset+len(posts) < totalPosts,
+		PrevPage:    page - 1,
+		NextPage:    page + 1,
 	}
 
 	c.HTML(200, "profile.html", data)
@@ -332,7 +322,7 @@ func HandleSinglePost(c *gin.Context, conf *util.AppConfig) {
 	}
 
 	// Get user account
-	err, account := database.ReadAccByUsername(username)
+	account, err := database.ReadAccByUsername(username)
 	if err != nil {
 		log.Printf("User not found: %s", username)
 		c.HTML(404, "base.html", gin.H{"Title": "Not Found", "Error": "User not found"})
@@ -340,12 +330,16 @@ func HandleSinglePost(c *gin.Context, conf *util.AppConfig) {
 	}
 
 	// Get the note
-	err, note := database.ReadNoteId(noteId)
-	if err != nil || note == nil {
+	note, err := database.ReadNoteId(noteId)
+	if err != nil || note == nil || note.IsLocalOnly() {
 		log.Printf("Note not found: %s", noteIdStr)
 		c.HTML(404, "base.html", gin.H{"Title": "Not Found", "Error": "Post not found"})
 		return
 	}
+	if note.IsDeleted() {
+		c.HTML(410, "base.html", gin.H{"Title": "Gone", "Error": "This post was deleted"})
+		return
+	}
 
 	// Verify the note belongs to this user
 	if note.CreatedBy != username {
@@ -387,8 +381,8 @@ func HandleSinglePost(c *gin.Context, conf *util.AppConfig) {
 	var parentPost *PostView
 	if note.InReplyToURI != "" {
 		// Try to find parent post in local notes
-		err, parentNote := database.ReadNoteByURI(note.InReplyToURI)
-		if err == nil && parentNote != nil {
+		parentNote, err := database.ReadNoteByURI(note.InReplyToURI)
+		if err == nil && parentNote != nil && !parentNote.IsLocalOnly() {
 			parentMessageHTML := util.MarkdownLinksToHTML(parentNote.Message)
 			parentMessageHTML = util.HighlightHashtagsHTML(parentMessageHTML)
 			parentMessageHTML = util.HighlightMentionsHTML(parentMessageHTML, conf.Conf.SslDomain)
@@ -414,9 +408,12 @@ func HandleSinglePost(c *gin.Context, conf *util.AppConfig) {
 
 	// Fetch replies to this post
 	var replies []PostView
-	err, replyNotes := database.ReadRepliesByNoteId(noteId)
+	replyNotes, err := database.ReadRepliesByNoteId(noteId)
 	if err == nil && replyNotes != nil {
 		for _, replyNote := range *replyNotes {
+			if replyNote.IsLocalOnly() {
+				continue
+			}
 			replyMessageHTML := util.MarkdownLinksToHTML(replyNote.Message)
 			replyMessageHTML = util.HighlightHashtagsHTML(replyMessageHTML)
 			replyMessageHTML = util.HighlightMentionsHTML(replyMessageHTML, conf.Conf.SslDomain)
@@ -474,48 +471,65 @@ func HandleTagFeed(c *gin.Context, conf *util.AppConfig) {
 	postsPerPage := 20
 	offset := (page - 1) * postsPerPage
 
-	// Get total count for pagination
-	totalPosts, err := database.CountNotesByHashtag(tag)
+	// Get total count for pagination (local notes plus federated posts)
+	totalPosts, err := database.CountFederatedNotesByHashtag(tag)
 	if err != nil {
-		log.Printf("Failed to count notes for hashtag %s: %v", tag, err)
+		log.Printf("Failed to count posts for hashtag %s: %v", tag, err)
 		totalPosts = 0
 	}
 
-	// Get notes with this hashtag
-	err, notes := database.ReadNotesByHashtag(tag, postsPerPage, offset)
+	// Get local and federated posts with this hashtag
+	tagged, err := database.ReadFederatedNotesByHashtag(tag, postsPerPage, offset)
 	if err != nil {
-		log.Printf("Failed to read notes for hashtag %s: %v", tag, err)
+		log.Printf("Failed to read posts for hashtag %s: %v", tag, err)
 		c.HTML(500, "base.html", gin.H{"Title": "Error", "Error": "Failed to load tagged posts"})
 		return
 	}
 
-	if notes == nil {
-		notes = &[]domain.Note{}
+	if tagged == nil {
+		tagged = &[]domain.HomePost{}
 	}
 
 	// Convert to PostView with hashtag-highlighted content
-	posts := make([]PostView, 0, len(*notes))
-	for _, note := range *notes {
+	posts := make([]PostView, 0, len(*tagged))
+	for _, post := range *tagged {
+		if !post.IsLocal {
+			// Remote content is plain text stripped from the activity's HTML, so escape it
+			messageHTML := util.HighlightHashtagsHTML(html.EscapeString(post.DisplayContent()))
+			posts = append(posts, PostView{
+				NoteId:      post.ID.String(),
+				Username:    strings.TrimPrefix(post.Author, "@"),
+				Message:     post.DisplayContent(),
+				MessageHTML: template.HTML(messageHTML),
+				TimeAgo:     formatTimeAgo(post.Time),
+				ReplyCount:  post.ReplyCount,
+				LikeCount:   post.LikeCount,
+				BoostCount:  post.BoostCount,
+				RemoteURL:   post.ObjectURI,
+			})
+			continue
+		}
+
 		// First convert markdown links, then highlight hashtags and mentions
-		messageHTML := util.MarkdownLinksToHTML(note.Message)
+		messageHTML := util.MarkdownLinksToHTML(post.Content)
 		messageHTML = util.HighlightHashtagsHTML(messageHTML)
 		messageHTML = util.HighlightMentionsHTML(messageHTML, conf.Conf.SslDomain)
 
 		// Get reply count for this post
 		replyCount := 0
-		if count, err := database.CountRepliesByNoteId(note.Id); err == nil {
+		if count, err := database.CountRepliesByNoteId(post.NoteID); err == nil {
 			replyCount = count
 		}
 
 		posts = append(posts, PostView{
-			NoteId:      note.Id.String(),
-			Username:    note.CreatedBy,
-			Message:     note.Message,
+			NoteId:      post.NoteID.String(),
+			Username:    post.Author,
+			Message:     post.Content,
 			MessageHTML: template.HTML(messageHTML),
-			TimeAgo:     formatTimeAgo(note.CreatedAt),
+			TimeAgo:     formatTimeAgo(post.Time),
 			ReplyCount:  replyCount,
-			LikeCount:   note.LikeCount,
-			BoostCount:  note.BoostCount,
+			LikeCount:   post.LikeCount,
+			BoostCount:  post.BoostCount,
 		})
 	}
 
@@ -1,12 +1,12 @@
 package web
 
 import (
+	"database/sql"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
-	"time"
 
+	"github.com/deemkeen/stegodon/activitypub"
 	"github.com/deemkeen/stegodon/db"
 	"github.com/deemkeen/stegodon/util"
 )
@@ -14,10 +14,14 @@ import (
 // GetWebfinger returns WebFinger JSON for a local user
 func GetWebfinger(user string, conf *util.AppConfig) (error, string) {
 
-	err, acc := db.GetDB().ReadAccByUsername(user)
+	acc, err := db.GetDB().ReadAccByUsername(user)
 	if err != nil {
 		return err, GetWebFingerNotFound()
 	}
+	// Accounts awaiting approval don't federate
+	if acc.Pending {
+		return sql.ErrNoRows, GetWebFingerNotFound()
+	}
 
 	username := acc.Username
 
@@ -30,9 +34,15 @@ func GetWebfinger(user string, conf *util.AppConfig) (error, string) {
 							"rel": "self",
 							"type": "application/activity+json",
 							"href": "https://%s/users/%s"
+						},
+						{
+							"rel": "http://webfinger.net/rel/profile-page",
+							"type": "text/html",
+							"href": "https://%s/u/%s"
 						}
 					]
 				}`, username, conf.Conf.SslDomain,
+		conf.Conf.SslDomain, username,
 		conf.Conf.SslDomain, username)
 }
 
@@ -62,11 +72,10 @@ func ResolveWebFinger(username, domain string) (string, error) {
 		return "", fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Accept", "application/jrd+json")
-	req.Header.Set("User-Agent", "stegodon/1.0 ActivityPub")
+	activitypub.SetRequestHeaders(req, "application/jrd+json")
 
-	client := &http.Client{Timeout: 5 * time.Second}
-	resp, err := client.Do(req)
+	// The domain is user input, so go through the federation client and its fetch policy
+	resp, err := activitypub.FederationHTTPClient().Do(req)
 	if err != nil {
 		return "", fmt.Errorf("webfinger request failed: %w", err)
 	}
@@ -76,9 +85,9 @@ func ResolveWebFinger(username, domain string) (string, error) {
 		return "", fmt.Errorf("webfinger failed with status: %d", resp.StatusCode)
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	body, err := activitypub.ReadJSONResponse(resp)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
+		return "", err
 	}
 
 	var result WebFingerResponse
@@ -90,8 +99,7 @@ func ResolveWebFinger(username, domain string) (string, error) {
 	// Accept both application/activity+json and application/ld+json with ActivityStreams profile
 	for _, link := range result.Links {
 		if link.Rel == "self" {
-			if link.Type == "application/activity+json" ||
-				link.Type == "application/ld+json; profile=\"https://www.w3.org/ns/activitystreams\"" {
+			if activitypub.IsActivityStreamsContentType(link.Type) {
 				return link.Href, nil
 			}
 		}